@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/crypto"
+	"github.com/atreya2011/health-management-api/internal/log"
+)
+
+// generateDataKeyCmd represents the generate-data-key command
+var generateDataKeyCmd = &cobra.Command{
+	Use:   "generate-data-key",
+	Short: "Generate a data key wrapped by the configured KMS",
+	Long: `Generate a random data key for diary encryption, wrap it with the KMS of the encryption
+config and print it, to set as encryption.data_key. The data key itself is never printed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runGenerateDataKey() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateDataKeyCmd)
+}
+
+func runGenerateDataKey() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	if err := cfg.Encryption.Validate(); err != nil {
+		logger.Error("Invalid encryption config", "error", err)
+		return false
+	}
+
+	kms, err := newKMS(cfg.Encryption)
+	if err != nil {
+		logger.Error("Invalid encryption config", "error", err)
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	dataKey, err := crypto.GenerateDataKey(ctx, kms)
+	if err != nil {
+		logger.Error("Failed to generate data key", "driver", cfg.Encryption.Driver, "error", err)
+		return false
+	}
+	fmt.Println(dataKey)
+	return true
+}
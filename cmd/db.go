@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/migration"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+var dbVerifyTimeout time.Duration
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect the database",
+}
+
+// dbVerifyCmd represents the db verify command
+var dbVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Compare the database schema to the schema of the migrations",
+	Long: `Compare the tables, columns and indexes of the configured database to those created by the
+migrations up to its schema version, and list the missing, changed and unexpected ones. The
+migrations are applied to an empty schema in a transaction rolled back afterwards, so the database
+is left unchanged. Exits non-zero if objects are missing or changed, or migrations are pending;
+objects unexpected by the migrations, e.g. added by those of a newer release, are only listed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runDBVerify() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbVerifyCmd)
+
+	// Local flags
+	dbVerifyCmd.Flags().StringVar(&migrationsDir, "dir", "./db/migrations", "directory of the migrations")
+	dbVerifyCmd.Flags().DurationVar(&dbVerifyTimeout, "timeout", time.Minute, "timeout of the verification")
+}
+
+func runDBVerify() bool {
+	logger := log.NewLogger()
+
+	migrations, err := migration.Load(migrationsDir)
+	if err != nil {
+		logger.Error("Failed to load migrations", "error", err)
+		return false
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", "error", err)
+		return false
+	}
+	defer dbPool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbVerifyTimeout)
+	defer cancel()
+	version, dirty, err := migration.Version(ctx, dbPool)
+	if err != nil {
+		logger.Error("Failed to read schema version", "error", err)
+		return false
+	}
+	if dirty {
+		fmt.Printf("Migration %d failed halfway; fix the schema and force its version before verifying\n", version)
+		return false
+	}
+
+	// Databases without a recorded version, e.g. migrated by the tests, should have every migration
+	applied, pending := migrations, []migration.Migration(nil)
+	if version > 0 {
+		pending = migration.Pending(migrations, version)
+		applied = migrations[:len(migrations)-len(pending)]
+	}
+	expected, err := migration.ExpectedSchema(ctx, dbPool, applied)
+	if err != nil {
+		logger.Error("Failed to apply migrations to an empty schema", "error", err)
+		return false
+	}
+	live, err := migration.LiveSchema(ctx, dbPool)
+	if err != nil {
+		logger.Error("Failed to read database schema", "error", err)
+		return false
+	}
+
+	ok := true
+	diffs := migration.Diff(expected, live)
+	for _, d := range diffs {
+		fmt.Println(d)
+		ok = ok && d.Kind == migration.Unexpected
+	}
+	if version > 0 {
+		fmt.Printf("Schema version %d, %d tables verified\n", version, len(expected.Tables))
+	} else {
+		fmt.Printf("No schema version recorded, %d tables verified against every migration\n", len(expected.Tables))
+	}
+	if len(pending) > 0 {
+		fmt.Printf("%d pending migrations, starting with %s; run make migrate-up\n", len(pending), pending[0].Name)
+		ok = false
+	}
+	if !ok {
+		fmt.Println("The database schema differs from the schema of the migrations")
+	}
+	return ok
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/cors"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/migration"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/sandbox"
+)
+
+// devScopes are the scopes of the token logged for the seeded user: those of the RPCs of their own
+// account, without the admin ones
+var devScopes = []string{authz.ScopeRecordsRead, authz.ScopeRecordsWrite, authz.ScopeSharesWrite}
+
+const (
+	// devJWTSecretKey signs the tokens of serve --dev, so tokens signed for a development server
+	// keep working across restarts and machines. Never use it outside development.
+	devJWTSecretKey = "healthapp-dev-secret-key-not-for-production"
+	// devSubjectID is the JWT subject of the user seeded by serve --dev
+	devSubjectID = "dev-user"
+	// devTokenTTL is the lifetime of the token logged for the seeded user
+	devTokenTTL = 30 * 24 * time.Hour
+	// devMigrationsDir is the directory of the migrations applied by serve --dev
+	devMigrationsDir = "./db/migrations"
+)
+
+// applyDevFlags overrides the settings of cfg set by serve --dev: browsers may call the API from
+// any origin, reflection is served, tokens are signed with devJWTSecretKey and logs aren't
+// redacted, so the token of the development user can be copied from them
+func applyDevFlags(cfg *config.Config) {
+	cfg.Log.Mode = log.ModeDevelopment
+	cfg.Server.CORSOrigins = []string{cors.AnyOrigin}
+	cfg.Server.Reflection = true
+	cfg.JWT.SecretKey = devJWTSecretKey
+}
+
+// setUpDev applies the pending migrations and, on the first start, seeds the development user
+// with the sandbox fixtures, then logs a token to call the API as them
+func setUpDev(ctx context.Context, pool *pgxpool.Pool, logger *slog.Logger) error {
+	migrations, err := migration.Load(devMigrationsDir)
+	if err != nil {
+		return err
+	}
+	applied, err := migration.Up(ctx, pool, migrations)
+	if err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+	for _, m := range applied {
+		logger.Info("Migration applied", "migration", m.Name)
+	}
+
+	// The user is only seeded once, so data created through the API is kept across restarts
+	_, err = repo.NewUserRepository(pool).FindBySubjectID(ctx, devSubjectID)
+	switch {
+	case errors.Is(err, repo.ErrUserNotFound):
+		resetter := sandbox.NewResetter(repo.NewSandboxRepository(pool, nil, nil), devSubjectID, 0, logger, clock.NewRealClock())
+		if err := resetter.Reset(ctx); err != nil {
+			return fmt.Errorf("failed to seed development user: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to look up development user: %w", err)
+	}
+
+	token, err := signToken(devJWTSecretKey, devSubjectID, devScopes, devTokenTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign development token: %w", err)
+	}
+	logger.Info("Development user ready; send the token as 'Authorization: Bearer <token>'", "subjectID", devSubjectID, "token", token)
+	return nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/email"
+	"github.com/atreya2011/health-management-api/internal/log"
+)
+
+var emailTestTo string
+
+// emailTestCmd represents the email-test command
+var emailTestCmd = &cobra.Command{
+	Use:   "email-test",
+	Short: "Send a test email through the configured driver",
+	Long: `Send a test email to --to through the email driver of the config, to verify the
+sender address and credentials after changing them. Exits non-zero if sending fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runEmailTest() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(emailTestCmd)
+
+	// Local flags
+	emailTestCmd.Flags().StringVar(&emailTestTo, "to", "", "address to send the test email to")
+	_ = emailTestCmd.MarkFlagRequired("to")
+}
+
+func runEmailTest() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+
+	mailer, err := email.NewMailer(newEmailSender(cfg.Email, logger), cfg.Email.From)
+	if err != nil {
+		logger.Error("Invalid email config", "error", err)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := mailer.Send(ctx, emailTestTo, email.TemplateTest, nil); err != nil {
+		logger.Error("Failed to send test email", "driver", cfg.Email.Driver, "error", err)
+		return false
+	}
+	logger.Info("Test email sent", "driver", cfg.Email.Driver, "to", emailTestTo)
+	return true
+}
+
+// newEmailSender creates the sender of the configured driver. The config is validated on load,
+// so the driver is known.
+func newEmailSender(cfg config.EmailConfig, logger *slog.Logger) email.Sender {
+	switch cfg.Driver {
+	case email.DriverSMTP:
+		return email.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password)
+	case email.DriverSES:
+		return email.NewSES(cfg.SES.Region, cfg.SES.AccessKeyID, cfg.SES.SecretAccessKey)
+	case email.DriverSendGrid:
+		return email.NewSendGrid(cfg.SendGrid.APIKey)
+	}
+	return email.NewLogSender(logger)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+var encryptDiaryBatchSize int
+
+// encryptDiaryCmd represents the encrypt-diary command
+var encryptDiaryCmd = &cobra.Command{
+	Use:   "encrypt-diary",
+	Short: "Encrypt diary entries stored as plaintext",
+	Long: `Encrypt the diary entries and their revisions written before encryption was enabled with
+the configured data key, in batches of --batch-size entries each committed on its own. The server may keep running:
+entries are readable while they are encrypted, and the command can be interrupted and rerun.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runEncryptDiary() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptDiaryCmd)
+
+	// Local flags
+	encryptDiaryCmd.Flags().IntVar(&encryptDiaryBatchSize, "batch-size", 500, "number of entries encrypted per transaction")
+}
+
+func runEncryptDiary() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	if encryptDiaryBatchSize <= 0 {
+		logger.Error("Batch size must be positive", "batchSize", encryptDiaryBatchSize)
+		return false
+	}
+	diaryCipher, err := newDiaryCipher(cfg.Encryption)
+	if err != nil {
+		logger.Error("Failed to initialize diary encryption", "error", err)
+		return false
+	}
+	if diaryCipher == nil {
+		logger.Error("No encryption data key set; create one with the generate-data-key command")
+		return false
+	}
+
+	// Initialize database connection
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", "error", err)
+		return false
+	}
+	defer dbPool.Close()
+	diaryEntryRepo := repo.NewDiaryEntryRepository(dbPool, diaryCipher)
+
+	// An interrupt rolls back the current batch; committed batches stay encrypted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var total int
+	for {
+		n, err := diaryEntryRepo.EncryptPlaintext(ctx, encryptDiaryBatchSize)
+		if err != nil {
+			logger.Error("Failed to encrypt diary entries", "encrypted", total, "error", err)
+			return false
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+		logger.Info("Encrypted diary entries", "batch", n, "total", total)
+	}
+	logger.Info("All diary entries are encrypted", "encrypted", total)
+	return true
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+)
+
+var (
+	loadTestURL         string
+	loadTestToken       string
+	loadTestSubject     string
+	loadTestRPCs        []string
+	loadTestConcurrency int
+	loadTestDuration    time.Duration
+	loadTestRequests    int
+	loadTestPageSize    int32
+	loadTestPageNumber  int32
+)
+
+// loadtestCmd represents the loadtest command
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "Measure the latency of RPCs of a running server under concurrent load",
+	Long: `Call read RPCs of a running server from --concurrency workers, each sending its next request
+once the previous one returned, for --duration or until --requests were sent, then report the
+throughput and the p50, p95 and p99 latency of each RPC. Run it against a seeded database (see the
+seed command) before and after a performance-affecting change, e.g. to pagination, and compare the
+reports; the rate limits of the server apply, so raise them on the server under test. Exits
+non-zero if any request fails.
+
+Without --token, a token for --subject is signed with the JWT secret key from the config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runLoadTest() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loadtestCmd)
+
+	// Local flags
+	loadtestCmd.Flags().StringVar(&loadTestURL, "url", "http://localhost:8080", "base URL of the server to test")
+	loadtestCmd.Flags().StringVar(&loadTestToken, "token", os.Getenv("HEALTHAPP_LOADTEST_TOKEN"), "bearer token to authenticate with (default $HEALTHAPP_LOADTEST_TOKEN)")
+	loadtestCmd.Flags().StringVar(&loadTestSubject, "subject", "test-subject-id", "subject of the signed token when --token is not set; defaults to the user of the seed command")
+	loadtestCmd.Flags().StringSliceVar(&loadTestRPCs, "rpcs", loadTestRPCNames(), "RPCs to call, in turn")
+	loadtestCmd.Flags().IntVarP(&loadTestConcurrency, "concurrency", "c", 10, "number of concurrent workers")
+	loadtestCmd.Flags().DurationVar(&loadTestDuration, "duration", 30*time.Second, "duration of the load")
+	loadtestCmd.Flags().IntVarP(&loadTestRequests, "requests", "n", 0, "stop after this many requests (0 for no limit)")
+	loadtestCmd.Flags().Int32Var(&loadTestPageSize, "page-size", 0, "page size of the list RPCs (0 for the server default)")
+	loadtestCmd.Flags().Int32Var(&loadTestPageNumber, "page", 1, "page number of the list RPCs, to measure deep pages")
+}
+
+// loadTestRPC sends one request of an RPC
+type loadTestRPC func(ctx context.Context, c *loadTestClients) error
+
+// loadTestClients are the clients of the services called by the loadtest command
+type loadTestClients struct {
+	bodyRecords     healthappv1connect.BodyRecordServiceClient
+	exerciseRecords healthappv1connect.ExerciseRecordServiceClient
+	diaryEntries    healthappv1connect.DiaryServiceClient
+	dashboard       healthappv1connect.DashboardServiceClient
+}
+
+// loadTestCalls are the RPCs the loadtest command can call, by name
+var loadTestCalls = map[string]loadTestRPC{
+	"ListBodyRecords": func(ctx context.Context, c *loadTestClients) error {
+		_, err := c.bodyRecords.ListBodyRecords(ctx, connect.NewRequest(&v1.ListBodyRecordsRequest{Pagination: loadTestPage()}))
+		return err
+	},
+	"ListExerciseRecords": func(ctx context.Context, c *loadTestClients) error {
+		_, err := c.exerciseRecords.ListExerciseRecords(ctx, connect.NewRequest(&v1.ListExerciseRecordsRequest{Pagination: loadTestPage()}))
+		return err
+	},
+	"ListDiaryEntries": func(ctx context.Context, c *loadTestClients) error {
+		_, err := c.diaryEntries.ListDiaryEntries(ctx, connect.NewRequest(&v1.ListDiaryEntriesRequest{Pagination: loadTestPage()}))
+		return err
+	},
+	"GetDashboard": func(ctx context.Context, c *loadTestClients) error {
+		_, err := c.dashboard.GetDashboard(ctx, connect.NewRequest(&v1.GetDashboardRequest{Days: 30}))
+		return err
+	},
+}
+
+// loadTestRPCNames returns the names of the RPCs the loadtest command can call, sorted
+func loadTestRPCNames() []string {
+	names := make([]string, 0, len(loadTestCalls))
+	for name := range loadTestCalls {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func loadTestPage() *v1.PageRequest {
+	return &v1.PageRequest{PageSize: loadTestPageSize, PageNumber: loadTestPageNumber}
+}
+
+// loadTestResult is the outcome of one request
+type loadTestResult struct {
+	rpc      string
+	duration time.Duration
+	err      error
+}
+
+func runLoadTest() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	rpcs := make([]loadTestRPC, len(loadTestRPCs))
+	for i, name := range loadTestRPCs {
+		rpc, ok := loadTestCalls[name]
+		if !ok {
+			logger.Error("Unknown RPC", "rpc", name, "known", strings.Join(loadTestRPCNames(), ", "))
+			return false
+		}
+		rpcs[i] = rpc
+	}
+	if len(rpcs) == 0 {
+		logger.Error("No RPCs to call", "known", strings.Join(loadTestRPCNames(), ", "))
+		return false
+	}
+	if loadTestConcurrency < 1 {
+		logger.Error("Concurrency must be at least 1", "concurrency", loadTestConcurrency)
+		return false
+	}
+
+	token := loadTestToken
+	if token == "" {
+		// Load configuration
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Error("Failed to load configuration", "error", err)
+			return false
+		}
+		token, err = signToken(cfg.JWT.SecretKey, loadTestSubject, recordScopes, loadTestDuration+10*time.Minute)
+		if err != nil {
+			logger.Error("Failed to sign load test token", "error", err)
+			return false
+		}
+	}
+
+	// Connections are kept alive across requests, as those of real clients are
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: loadTestConcurrency},
+	}
+	authenticated := connect.WithInterceptors(bearerTokenInterceptor(token))
+	clients := &loadTestClients{
+		bodyRecords:     healthappv1connect.NewBodyRecordServiceClient(httpClient, loadTestURL, authenticated),
+		exerciseRecords: healthappv1connect.NewExerciseRecordServiceClient(httpClient, loadTestURL, authenticated),
+		diaryEntries:    healthappv1connect.NewDiaryServiceClient(httpClient, loadTestURL, authenticated),
+		dashboard:       healthappv1connect.NewDashboardServiceClient(httpClient, loadTestURL, authenticated),
+	}
+
+	logger.Info("Starting load test...", "url", loadTestURL, "rpcs", loadTestRPCs, "concurrency", loadTestConcurrency, "duration", loadTestDuration, "requests", loadTestRequests)
+	ctx, cancel := context.WithTimeout(context.Background(), loadTestDuration)
+	defer cancel()
+
+	// Workers take the next request slot from sent, so --requests is shared between them, and call
+	// the RPCs in turn starting at different ones
+	var (
+		mu      sync.Mutex
+		sent    int
+		results []loadTestResult
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for w := 0; w < loadTestConcurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; ctx.Err() == nil; i++ {
+				mu.Lock()
+				if loadTestRequests > 0 && sent >= loadTestRequests {
+					mu.Unlock()
+					return
+				}
+				sent++
+				mu.Unlock()
+
+				name := loadTestRPCs[i%len(rpcs)]
+				reqStart := time.Now()
+				err := rpcs[i%len(rpcs)](ctx, clients)
+				result := loadTestResult{rpc: name, duration: time.Since(reqStart), err: err}
+				if ctx.Err() != nil {
+					// Requests cut off by the end of the load are left out
+					return
+				}
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	return reportLoadTest(results, elapsed, logger)
+}
+
+// reportLoadTest prints the latency percentiles of each RPC and of all requests, and reports
+// whether all requests succeeded
+func reportLoadTest(results []loadTestResult, elapsed time.Duration, logger *slog.Logger) bool {
+	byRPC := map[string][]time.Duration{}
+	failures := map[string]int{}
+	var all []time.Duration
+	for _, r := range results {
+		byRPC[r.rpc] = append(byRPC[r.rpc], r.duration)
+		all = append(all, r.duration)
+		if r.err != nil {
+			if failures[r.rpc] == 0 {
+				logger.Error("Request failed", "rpc", r.rpc, "code", connect.CodeOf(r.err), "error", r.err)
+			}
+			failures[r.rpc]++
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RPC\tRequests\tErrors\tp50\tp95\tp99\tMax\t")
+	printRow := func(name string, durations []time.Duration, errors int) {
+		slices.Sort(durations)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", name, len(durations), errors,
+			latencyPercentile(durations, 0.50), latencyPercentile(durations, 0.95), latencyPercentile(durations, 0.99), latencyPercentile(durations, 1))
+	}
+	total := 0
+	for _, name := range loadTestRPCs {
+		if durations, ok := byRPC[name]; ok {
+			printRow(name, durations, failures[name])
+			total += failures[name]
+			// RPCs listed twice are printed once
+			delete(byRPC, name)
+		}
+	}
+	printRow("All", all, total)
+	w.Flush()
+	fmt.Printf("%d requests in %s (%.1f requests/s) from %d workers\n", len(all), elapsed.Round(time.Millisecond), float64(len(all))/elapsed.Seconds(), loadTestConcurrency)
+
+	if total > 0 {
+		fmt.Printf("%d requests failed\n", total)
+		return false
+	}
+	return len(all) > 0
+}
+
+// latencyPercentile returns the nearest-rank percentile p (0 to 1) of the sorted durations,
+// rounded for display
+func latencyPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i].Round(10 * time.Microsecond)
+}
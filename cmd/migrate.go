@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/migration"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+var migrationsDir string
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Check schema migrations before they are applied",
+	Long: `Check that the schema migrations are safe to apply during a blue/green rollout, while
+servers of the previous release still run against the schema. Migrations are applied with
+golang-migrate (make migrate-up).`,
+}
+
+// migrateLintCmd represents the migrate lint command
+var migrateLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check every migration for unflagged destructive changes",
+	Long: `Check every up migration for changes breaking the servers of the previous release, e.g.
+dropping or renaming a column, unless the migration flags them with a comment such as
+"-- migrate:allow drop_column <reason>", or the migrate-allow.txt file of the directory allows them
+for migrations already applied. Exits non-zero if any change isn't flagged, e.g. in CI.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runMigrateLint() {
+			os.Exit(1)
+		}
+	},
+}
+
+// migratePlanCmd represents the migrate plan command
+var migratePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "List the migrations pending on the database and their destructive changes",
+	Long: `List the migrations newer than the schema version of the configured database, with the
+changes of each breaking the servers of the previous release. Exits non-zero if one of them isn't
+flagged, or if the last migration failed halfway, so a deployment can stop before migrating.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runMigratePlan() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateLintCmd, migratePlanCmd)
+
+	// Persistent flags for the subcommands
+	migrateCmd.PersistentFlags().StringVar(&migrationsDir, "dir", "./db/migrations", "directory of the migrations")
+}
+
+func runMigrateLint() bool {
+	logger := log.NewLogger()
+
+	migrations, err := migration.Load(migrationsDir)
+	if err != nil {
+		logger.Error("Failed to load migrations", "error", err)
+		return false
+	}
+	safe, ok := printFindings(migrations, false)
+	if !ok {
+		return false
+	}
+	fmt.Printf("%d migrations checked\n", len(migrations))
+	return safe
+}
+
+func runMigratePlan() bool {
+	logger := log.NewLogger()
+
+	migrations, err := migration.Load(migrationsDir)
+	if err != nil {
+		logger.Error("Failed to load migrations", "error", err)
+		return false
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", "error", err)
+		return false
+	}
+	defer dbPool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	version, dirty, err := migration.Version(ctx, dbPool)
+	if err != nil {
+		logger.Error("Failed to read schema version", "error", err)
+		return false
+	}
+	if dirty {
+		fmt.Printf("Migration %d failed halfway; fix the schema and force its version before migrating\n", version)
+		return false
+	}
+
+	pending := migration.Pending(migrations, version)
+	fmt.Printf("Schema version %d, %d pending migrations\n", version, len(pending))
+	safe, ok := printFindings(pending, true)
+	return safe && ok
+}
+
+// printFindings prints the destructive changes of migrations, under the names of the migrations
+// making them, or of every migration with listAll. It reports whether the changes are all
+// flagged, and whether the flags of the migrations were valid.
+func printFindings(migrations []migration.Migration, listAll bool) (safe, ok bool) {
+	safe = true
+	for _, m := range migrations {
+		findings, err := migration.Lint(m)
+		if err != nil {
+			fmt.Println(err)
+			return false, false
+		}
+		if listAll || len(findings) > 0 {
+			fmt.Println(m.Name)
+		}
+		for _, f := range findings {
+			fmt.Println("  " + f.String())
+			safe = safe && f.Allowed()
+		}
+	}
+	if !safe {
+		fmt.Println("Unflagged destructive changes; make them in a later release, or flag them with a migrate:allow comment")
+	}
+	return safe, true
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/crypto"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+var reencryptTokensBatchSize int
+
+// reencryptTokensCmd represents the reencrypt-tokens command
+var reencryptTokensCmd = &cobra.Command{
+	Use:   "reencrypt-tokens",
+	Short: "Re-encrypt provider tokens with the current token encryption key",
+	Long: `Re-encrypt the provider tokens of linked integrations encrypted with one of
+integrations.previous_token_encryption_keys with integrations.token_encryption_key, in batches of
+--batch-size integrations each committed on its own. Run it after restarting the servers with the
+new key; once it completes, the previous keys can be removed from the config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runReencryptTokens() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reencryptTokensCmd)
+
+	// Local flags
+	reencryptTokensCmd.Flags().IntVar(&reencryptTokensBatchSize, "batch-size", 500, "number of integrations re-encrypted per transaction")
+}
+
+func runReencryptTokens() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	if reencryptTokensBatchSize <= 0 {
+		logger.Error("Batch size must be positive", "batchSize", reencryptTokensBatchSize)
+		return false
+	}
+	tokenCipher, err := crypto.NewCipherFromBase64(cfg.Integrations.TokenEncryptionKey, cfg.Integrations.PreviousTokenEncryptionKeys...)
+	if err != nil {
+		logger.Error("Invalid integration token encryption key", "error", err)
+		return false
+	}
+
+	// Initialize database connection
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", "error", err)
+		return false
+	}
+	defer dbPool.Close()
+	integrationRepo := repo.NewIntegrationRepository(dbPool, tokenCipher)
+
+	// An interrupt rolls back the current batch; committed batches stay re-encrypted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var total int
+	for {
+		n, err := integrationRepo.ReencryptTokens(ctx, reencryptTokensBatchSize)
+		if err != nil {
+			logger.Error("Failed to re-encrypt provider tokens", "reencrypted", total, "error", err)
+			return false
+		}
+		total += n
+		if n == 0 {
+			break
+		}
+		logger.Info("Re-encrypted provider tokens", "batch", n, "total", total)
+	}
+	logger.Info("All provider tokens are encrypted with the current key", "reencrypted", total)
+	return true
+}
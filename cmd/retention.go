@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/retention"
+)
+
+var retentionDryRun bool
+
+// retentionCmd represents the retention command
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Apply the data retention policy once",
+	Long: `Apply the retention policy of the retention config once, as the server's retention job
+does every interval: purge record changes older than change_history_days and archive body and
+exercise records older than archive_after_years to the attachment store. Data of users who opted
+out is kept. Daily request counts older than api_usage_days are purged for all users. With
+--dry-run, only report how much data would be purged and archived.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runRetention() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retentionCmd)
+
+	// Local flags
+	retentionCmd.Flags().BoolVar(&retentionDryRun, "dry-run", false, "only report the data past the policy")
+}
+
+func runRetention() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	realClock := clock.NewRealClock()
+	store, _, err := newAttachmentStore(cfg.Storage, logger, realClock)
+	if err != nil {
+		logger.Error("Invalid storage config", "error", err)
+		return false
+	}
+
+	// Initialize database connection
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", "error", err)
+		return false
+	}
+	defer dbPool.Close()
+	job := retention.NewJob(repo.NewRetentionRepository(dbPool), store, cfg.Retention, logger, realClock)
+
+	// An interrupt rolls back the current batch; completed batches stay purged and archived
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if _, err := job.Apply(ctx, retentionDryRun || cfg.Retention.DryRun); err != nil {
+		logger.Error("Failed to apply retention policy", "error", err)
+		return false
+	}
+	return true
+}
@@ -51,9 +51,12 @@ func runSeed() {
 		logger.Error("Failed to load configuration", "error", err)
 		return
 	}
+	for _, warning := range cfg.Warnings {
+		logger.Warn("Deprecated configuration", "warning", warning)
+	}
 
 	// Initialize database connection
-	dbPool, err := repo.NewDBPool(&cfg.Database)
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
 	if err != nil {
 		logger.Error("Failed to connect to database", "error", err)
 		return
@@ -122,7 +125,7 @@ func runSeed() {
 
 		// Call Save with individual arguments, passing current time
 		currentTime := time.Now() // Get current time for this record
-		_, err := bodyRecordRepo.Save(ctx, testUser.ID, date, &weight, &bodyFat, currentTime) // Pass currentTime
+		_, err := bodyRecordRepo.Save(ctx, testUser.ID, date, &weight, &bodyFat, "", currentTime) // Pass currentTime
 		if err != nil {
 			logger.Warn("Failed to create mock body record", "date", date, "error", err)
 			continue // Continue to next day even if one fails
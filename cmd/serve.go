@@ -2,31 +2,96 @@ package cmd
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
+	"expvar"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"connectrpc.com/grpcreflect"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 
+	"github.com/atreya2011/health-management-api/internal/announce"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
 	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/compress"
 	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/cors"
+	"github.com/atreya2011/health-management-api/internal/crypto"
+	"github.com/atreya2011/health-management-api/internal/digest"
+	"github.com/atreya2011/health-management-api/internal/docs"
+	"github.com/atreya2011/health-management-api/internal/email"
+	"github.com/atreya2011/health-management-api/internal/errreport"
+	"github.com/atreya2011/health-management-api/internal/features"
+	"github.com/atreya2011/health-management-api/internal/food"
+	"github.com/atreya2011/health-management-api/internal/graphql"
+	"github.com/atreya2011/health-management-api/internal/health"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/integration"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
 	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/maintenance"
+	"github.com/atreya2011/health-management-api/internal/metrics"
+	"github.com/atreya2011/health-management-api/internal/milestone"
+	"github.com/atreya2011/health-management-api/internal/msgsize"
+	"github.com/atreya2011/health-management-api/internal/outbox"
+	"github.com/atreya2011/health-management-api/internal/partition"
+	"github.com/atreya2011/health-management-api/internal/pdf"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/quota"
+	"github.com/atreya2011/health-management-api/internal/reminder"
 	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/report"
+	"github.com/atreya2011/health-management-api/internal/research"
+	"github.com/atreya2011/health-management-api/internal/rest"
+	"github.com/atreya2011/health-management-api/internal/retention"
+	"github.com/atreya2011/health-management-api/internal/rollup"
 	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
 	"github.com/atreya2011/health-management-api/internal/rpc/handlers"
+	"github.com/atreya2011/health-management-api/internal/sandbox"
+	"github.com/atreya2011/health-management-api/internal/sharelink"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/timeout"
+	"github.com/atreya2011/health-management-api/internal/trash"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"github.com/atreya2011/health-management-api/internal/warmup"
 )
 
+// openAPIDir is the output directory of the OpenAPI plugin in buf.gen.yaml
+const openAPIDir = "./third_party/openapi"
+
+// swaggerUIDir is the directory make swagger-ui installs the Swagger UI assets of the docs to
+const swaggerUIDir = "./third_party/swagger-ui"
+
+// healthDependencyDatabase names the database in the gRPC health checks
+const healthDependencyDatabase = "database"
+
 var (
-	port string
+	port        string
+	sandboxMode bool
+	// maintenanceMode enables the maintenance mode, overriding the config
+	maintenanceMode bool
+	// devMode migrates and seeds the database on startup and relaxes the config for development
+	devMode bool
+	// graphqlGateway serves the GraphQL gateway at /graphql
+	graphqlGateway bool
 )
 
 // serveCmd represents the serve command
@@ -44,6 +109,10 @@ func init() {
 
 	// Local flags
 	serveCmd.Flags().StringVarP(&port, "port", "p", "", "port to run the server on (overrides config)")
+	serveCmd.Flags().BoolVar(&sandboxMode, "sandbox", false, "reset the sandbox user to seeded fixtures daily (overrides config)")
+	serveCmd.Flags().BoolVar(&maintenanceMode, "maintenance", false, "answer all RPCs with unavailable and pause background jobs, e.g. during migrations (overrides config)")
+	serveCmd.Flags().BoolVar(&devMode, "dev", false, "migrate and seed the database, allow any CORS origin, serve reflection and sign tokens with a fixed dev key; for development only")
+	serveCmd.Flags().BoolVar(&graphqlGateway, "graphql", false, "serve the GraphQL gateway of aggregated queries at "+graphql.Procedure)
 }
 
 func runServer() {
@@ -53,7 +122,7 @@ func runServer() {
 		// Set more verbose logging when verbose flag is enabled
 		logger.Info("Verbose mode enabled")
 	}
-	logger.Info("Starting server...")
+	logger.Info("Starting server...", "version", version.Version)
 
 	// Load configuration
 	cfg, err := config.LoadConfig(configPath)
@@ -61,16 +130,59 @@ func runServer() {
 		logger.Error("Failed to load configuration", "error", err)
 		os.Exit(1)
 	}
+	applyFlags(cfg)
+	// Apply the configured logging policy from now on; the level is reloaded without a restart
+	logOutput, err := log.Open(cfg.Log.Output)
+	if err != nil {
+		logger.Error("Invalid log config", "error", err)
+		os.Exit(1)
+	}
+	defer logOutput.Close()
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Log.SlogLevel())
+	logger = log.New(log.Options{
+		Mode:    cfg.Log.Mode,
+		HashKey: cfg.Log.HashKey,
+		Level:   logLevel,
+		Format:  cfg.Log.Format,
+		Output:  logOutput,
+		Sampling: log.Sampling{
+			Initial:    cfg.Log.Sampling.Initial,
+			Thereafter: cfg.Log.Sampling.Thereafter,
+			Interval:   cfg.Log.Sampling.Interval,
+		},
+	})
+	for _, warning := range cfg.Warnings {
+		logger.Warn("Deprecated configuration", "warning", warning)
+	}
+
+	// Report panics and the errors logged from now on, if a DSN is configured
+	reporter, err := errreport.New(cfg.ErrorReporting, version.Get(), cfg.Log.HashKey, logger, clock.NewRealClock())
+	if err != nil {
+		logger.Error("Invalid error reporting config", "error", err)
+		os.Exit(1)
+	}
+	logger = slog.New(errreport.NewLogHandler(logger.Handler(), reporter))
+	reportCtx, reportCancel := context.WithCancel(context.Background())
+	reportDone := make(chan struct{})
+	go func() {
+		reporter.Run(reportCtx)
+		close(reportDone)
+	}()
+	if reporter != nil {
+		logger.Info("Error reporting enabled", "environment", cfg.ErrorReporting.Environment, "sampleRate", cfg.ErrorReporting.SampleRate)
+	}
 
-	// Override port if specified via flag
+	if devMode {
+		logger.Warn("Development mode enabled: any origin may call the API and tokens are signed with a fixed key")
+	}
 	if port != "" {
-		cfg.Server.Port = port
 		logger.Info("Using port from command line flag", "port", port)
 	}
 
 	// Initialize database connection
 	logger.Info("Connecting to database...", "url", cfg.Database.URL)
-	dbPool, err := repo.NewDBPool(&cfg.Database)
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
 	if err != nil {
 		logger.Error("Failed to connect to database", "error", err)
 		os.Exit(1)
@@ -78,6 +190,17 @@ func runServer() {
 	defer dbPool.Close()
 	logger.Info("Database connection pool established")
 
+	// Bring the database to the latest migration and seed the development user
+	if devMode {
+		devCtx, devCancel := context.WithTimeout(context.Background(), time.Minute)
+		err := setUpDev(devCtx, dbPool, logger)
+		devCancel()
+		if err != nil {
+			logger.Error("Failed to set up development database", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Check if the database schema is correct
 	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer schemaCancel()
@@ -90,17 +213,92 @@ func runServer() {
 		} else {
 			logger.Error("Failed to check database schema", "error", err)
 		}
-		logger.Info("Run 'make migrate-up' to apply migrations")
+		logger.Info("Run 'make migrate-up' to apply migrations, and 'db verify' to list the differences of the schema")
 		os.Exit(1)
 	}
 	logger.Info("Database schema verified", "column_name", columnName)
 
-	// Initialize repositories
-	userRepo := repo.NewUserRepository(dbPool)
-	bodyRecordRepo := repo.NewBodyRecordRepository(dbPool)
-	diaryEntryRepo := repo.NewDiaryEntryRepository(dbPool)
-	exerciseRecordRepo := repo.NewExerciseRecordRepository(dbPool)
-	columnRepo := repo.NewColumnRepository(dbPool)
+	// Unwrap the data key of diary entries with the KMS
+	diaryCipher, err := newDiaryCipher(cfg.Encryption)
+	if err != nil {
+		logger.Error("Failed to initialize diary encryption", "error", err)
+		os.Exit(1)
+	}
+	if diaryCipher == nil {
+		logger.Warn("No encryption data key set: diary entries are stored as plaintext")
+	}
+
+	// Spread the reads of RPCs over the read replicas, if any; all pools are primed on startup
+	var database repo.DB = dbPool
+	warmPools := []*pgxpool.Pool{dbPool}
+	if len(cfg.Database.ReplicaURLs) > 0 {
+		router, err := repo.NewRouter(dbPool, &cfg.Database, logger)
+		if err != nil {
+			logger.Error("Failed to connect to read replicas", "error", err)
+			os.Exit(1)
+		}
+		defer router.Close()
+		routerCtx, routerCancel := context.WithCancel(context.Background())
+		defer routerCancel()
+		go router.Run(routerCtx)
+		database = router
+		warmPools = append(warmPools, router.ReplicaPools()...)
+		logger.Info("Read replicas configured", "replicas", len(cfg.Database.ReplicaURLs), "maxLag", cfg.Database.ReplicaMaxLag)
+	}
+
+	// Retry transient failures, e.g. during a failover, reset the primary pool after failovers and
+	// fail fast while the database is down
+	database = repo.NewResilientDB(database, &cfg.Database, logger, clock.NewRealClock())
+
+	// Keep the records of users pinned to a data region in its database, with retries of its own;
+	// users, sessions, quotas, shares and columns stay in the home database
+	homeDatabase := database
+	dataRegions := []string{""} // Of the background jobs; "" is the home database
+	if len(cfg.Database.Regions) > 0 {
+		regionPools, err := repo.NewRegionPools(&cfg.Database, logger)
+		if err != nil {
+			logger.Error("Failed to connect to data region databases", "error", err)
+			os.Exit(1)
+		}
+		regionDBs := make(map[string]repo.DB, len(regionPools))
+		for name, pool := range regionPools {
+			defer pool.Close()
+			regionDBs[name] = repo.NewResilientDB(pool, &cfg.Database, logger, clock.NewRealClock())
+			warmPools = append(warmPools, pool)
+		}
+		regionalDB := repo.NewRegionalDB(homeDatabase, regionDBs)
+		database = regionalDB
+		dataRegions = append(dataRegions, regionalDB.Regions()...)
+		logger.Info("Data regions configured", "regions", regionalDB.Regions())
+	}
+
+	// Initialize repositories; the auth interceptor resolves the caller's user through the cache
+	userCache := repo.NewUserCache(cfg.JWT.UserCacheSize, cfg.JWT.UserCacheTTL, clock.NewRealClock())
+	userRepo := repo.NewCachedUserRepository(database, userCache)
+	// Suspensions, emails and support lookups of users are made on their home database row
+	homeUserRepo := repo.NewCachedUserRepository(homeDatabase, userCache)
+	// Imports, trash restores, retention and sandbox resets evict the counts of the records they change
+	bodyRecordCounts := countCache(cfg, config.PaginationEndpointBodyRecords, clock.NewRealClock())
+	diaryEntryCounts := countCache(cfg, config.PaginationEndpointDiaryEntries, clock.NewRealClock())
+	exerciseRecordCounts := countCache(cfg, config.PaginationEndpointExerciseRecords, clock.NewRealClock())
+	recordCounts := repo.CountCaches{bodyRecordCounts, diaryEntryCounts, exerciseRecordCounts}
+	bodyRecordRepo := repo.NewCachedBodyRecordRepository(database, bodyRecordCounts)
+	diaryEntryRepo := repo.NewCachedDiaryEntryRepository(database, diaryCipher, diaryEntryCounts)
+	exerciseRecordRepo := repo.NewCachedExerciseRecordRepository(database, exerciseRecordCounts)
+	columnRepo := repo.NewColumnRepository(homeDatabase)
+	supportRepo := repo.NewSupportRepository(database)
+	statsRepo := repo.NewStatsRepository(homeDatabase)
+	sessionRepo := repo.NewSessionRepository(homeDatabase)
+	importRepo := repo.NewCachedImportRepository(database, recordCounts)
+	recordChangeRepo := repo.NewRecordChangeRepository(database)
+	stepRecordRepo := repo.NewStepRecordRepository(database)
+	goalRepo := repo.NewGoalRepository(database)
+	preferenceRepo := repo.NewPreferenceRepository(database)
+	mealRecordRepo := repo.NewMealRecordRepository(database)
+	achievementRepo := repo.NewAchievementRepository(database)
+	attachmentRepo := repo.NewAttachmentRepository(database)
+	// Shares are kept in the home database, which has every user they name
+	dataShareRepo := repo.NewDataShareRepository(homeDatabase)
 
 	// Initialize application services
 	// bodyRecordService := application.NewBodyRecordService(bodyRecordRepo, logger) // Removed
@@ -111,49 +309,494 @@ func runServer() {
 	// Initialize clock
 	realClock := clock.NewRealClock()
 
+	// Initialize integration providers; providers without OAuth credentials are disabled
+	var providers []integration.Provider
+	if cfg.Integrations.GoogleFit.ClientID != "" {
+		providers = append(providers, integration.NewGoogleFit(cfg.Integrations.GoogleFit.ClientID, cfg.Integrations.GoogleFit.ClientSecret))
+	}
+	if cfg.Integrations.Fitbit.ClientID != "" {
+		providers = append(providers, integration.NewFitbit(cfg.Integrations.Fitbit.ClientID, cfg.Integrations.Fitbit.ClientSecret))
+	}
+	withingsCfg := cfg.Integrations.Withings
+	withingsNotifications := withingsCfg.ClientID != "" && withingsCfg.NotificationURL != ""
+	if withingsCfg.ClientID != "" {
+		var callbackURL string
+		if withingsNotifications {
+			if withingsCfg.NotificationSecret == "" {
+				logger.Error("Withings notification secret is required when a notification URL is set")
+				os.Exit(1)
+			}
+			callbackURL, err = integration.WithingsCallbackURL(withingsCfg.NotificationURL, withingsCfg.NotificationSecret)
+			if err != nil {
+				logger.Error("Invalid Withings notification URL", "error", err)
+				os.Exit(1)
+			}
+		}
+		providers = append(providers, integration.NewWithings(withingsCfg.ClientID, withingsCfg.ClientSecret, callbackURL))
+	}
+
+	// Provider tokens are encrypted at rest, so integrations require an encryption key
+	var integrationRepo *repo.IntegrationRepository
+	var syncer *integration.Syncer
+	if len(providers) > 0 {
+		tokenCipher, err := crypto.NewCipherFromBase64(cfg.Integrations.TokenEncryptionKey, cfg.Integrations.PreviousTokenEncryptionKeys...)
+		if err != nil {
+			logger.Error("Invalid integration token encryption key", "error", err)
+			os.Exit(1)
+		}
+		if cfg.Integrations.SyncInterval <= 0 {
+			logger.Error("Invalid integration sync interval", "interval", cfg.Integrations.SyncInterval)
+			os.Exit(1)
+		}
+		integrationRepo = repo.NewIntegrationRepository(database, tokenCipher)
+		syncer = integration.NewSyncer(integrationRepo, importRepo, providers, cfg.Integrations.SyncInterval, logger, realClock)
+	}
+
+	// Initialize the food database; food lookup is disabled without a source
+	var foodDB food.Database
+	switch cfg.Food.Source {
+	case food.SourceOpenFoodFacts:
+		foodDB = food.NewOpenFoodFacts(cfg.Food.BaseURL, "health-management-api/"+version.Version, cfg.Food.Timeout)
+	case food.SourceUSDA:
+		foodDB = food.NewUSDA(cfg.Food.BaseURL, cfg.Food.APIKey, cfg.Food.Timeout)
+	}
+	if foodDB != nil {
+		foodDB = food.NewCache(foodDB, cfg.Food.CacheSize, cfg.Food.CacheTTL, realClock)
+	}
+
+	// Initialize push senders; platforms without credentials are disabled
+	pushRepo := repo.NewPushRepository(database)
+	reminderRepo := repo.NewReminderRepository(database)
+	pushSenders, err := newPushSenders(cfg.Push)
+	if err != nil {
+		logger.Error("Invalid push notification config", "error", err)
+		os.Exit(1)
+	}
+	pushPlatforms := make([]string, 0, len(pushSenders))
+	for _, s := range pushSenders {
+		pushPlatforms = append(pushPlatforms, s.Platform())
+	}
+
+	// Initialize the attachment store; the local driver serves its signed URLs itself
+	attachmentStore, localStoreHandler, err := newAttachmentStore(cfg.Storage, logger, realClock)
+	if err != nil {
+		logger.Error("Invalid storage config", "error", err)
+		os.Exit(1)
+	}
+	// Keep the objects of the data regions with a bucket of their own in it
+	regionStores := make(map[string]storage.Store)
+	for _, region := range cfg.Database.Regions {
+		if region.Storage.Driver == "" {
+			logger.Warn("Data region without a storage bucket, its objects are kept in the home bucket", "region", region.Name)
+			continue
+		}
+		regionStores[region.Name], _, err = newAttachmentStore(region.Storage, logger, realClock)
+		if err != nil {
+			logger.Error("Invalid storage config of data region", "region", region.Name, "error", err)
+			os.Exit(1)
+		}
+	}
+	if len(regionStores) > 0 {
+		attachmentStore = storage.NewRegional(attachmentStore, regionStores, repo.RegionOf)
+	}
+
 	// Initialize auth interceptor
 	jwtConfig := &auth.JWTConfig{
-		SecretKey: cfg.JWT.SecretKey,
+		SecretKey:       cfg.JWT.SecretKey,
+		AccessTokenTTL:  cfg.JWT.AccessTokenTTL,
+		RefreshTokenTTL: cfg.JWT.RefreshTokenTTL,
+		LegacyScopes:    cfg.JWT.LegacyScopes,
+	}
+	if len(jwtConfig.LegacyScopes) > 0 {
+		logger.Warn("Tokens without scopes are granted the deprecated jwt.legacy_scopes", "scopes", jwtConfig.LegacyScopes)
+	}
+	authInterceptor := auth.AuthInterceptor(jwtConfig, userRepo, sessionRepo, logger)
+	// Scopes are checked once the auth interceptor has read them from the token
+	scopeInterceptor := authz.ScopeInterceptor(authz.RPCScopes, logger)
+
+	// Requests are counted against the daily quota once authorized; polling usage is free
+	usageRepo := repo.NewAPIUsageRepository(homeDatabase)
+	dailyQuota := quota.NewLimit(cfg.Quota.DailyRequests)
+	// Feature flags are reloaded with the configuration
+	featureFlags := features.New(cfg.Features)
+	quotaInterceptor := quota.Interceptor(usageRepo, dailyQuota, []string{healthappv1connect.UsageServiceGetUsageProcedure}, logger, realClock)
+
+	// RPCs are cancelled after their timeout, including the queries authenticating them
+	rpcTimeouts := make(map[string]time.Duration, len(cfg.Server.RPCTimeouts))
+	for _, t := range cfg.Server.RPCTimeouts {
+		if _, ok := authz.RPCScopes[t.Procedure]; !ok {
+			logger.Error("Unknown procedure in RPC timeouts", "procedure", t.Procedure)
+			os.Exit(1)
+		}
+		rpcTimeouts[t.Procedure] = t.Timeout
 	}
-	authInterceptor := auth.AuthInterceptor(jwtConfig, userRepo, logger)
+	timeoutInterceptor := timeout.Interceptor(cfg.Server.RPCTimeout, rpcTimeouts)
 
-	// Create interceptors
+	// In maintenance mode, RPCs fail before they reach the database
+	var maintenanceWindow *maintenance.Mode
+	if cfg.Maintenance.Enabled {
+		endsAt, _ := cfg.Maintenance.EndTime() // Validated by LoadConfig
+		maintenanceWindow = maintenance.New(cfg.Maintenance.Message, endsAt, realClock)
+	}
+	maintenanceInterceptor := maintenance.Interceptor(maintenanceWindow)
+
+	// Create interceptors; metrics come first so authentication failures and timeouts are counted
+	// and timed, then internal errors are reported and errors are localized for the clients'
+	// Accept-Language
+	latencySLO := metrics.NewSLO(cfg.Server.SLO.LatencyThreshold, cfg.Server.SLO.Objective, cfg.Server.SLO.Window, realClock)
+	metrics.Register(latencySLO)
+	latencyInterceptor := latencySLO.Interceptor()
+	errorMetricsInterceptor := metrics.ErrorInterceptor()
+	errorReportInterceptor := errreport.Interceptor(reporter)
 	interceptors := connect.WithInterceptors(
+		latencyInterceptor,
+		errorMetricsInterceptor,
+		errorReportInterceptor,
+		i18n.Interceptor(),
+		maintenanceInterceptor,
+		timeoutInterceptor,
+		databaseErrorInterceptor(),
 		authInterceptor,
-		// Add more interceptors here (logging, metrics, recovery)
+		scopeInterceptor,
+		quotaInterceptor,
+		// Innermost, so that only handlers read from replicas; reads once they wrote go to the primary
+		replicaReadsInterceptor(),
 	)
 
-	// Initialize handlers
-	bodyRecordHandler := handlers.NewBodyRecordHandler(bodyRecordRepo, logger, realClock)
-	diaryHandler := handlers.NewDiaryHandler(diaryEntryRepo, logger, realClock)
-	exerciseRecordHandler := handlers.NewExerciseRecordHandler(exerciseRecordRepo, logger, realClock)
-	columnHandler := handlers.NewColumnHandler(columnRepo, logger, realClock)
+	// Responses are gzipped from the configured size for clients accepting it, REST calls included;
+	// compressed requests are accepted either way
+	compressMinBytes := cfg.Server.Compression.MinBytes
+	if !cfg.Server.Compression.Enabled {
+		compressMinBytes = math.MaxInt
+	}
+	compression := connect.WithCompressMinBytes(compressMinBytes)
+
+	// Requests larger than their maximum size are rejected before they are read into memory
+	maxMessageSizes := make(map[string]int64, len(cfg.Server.MaxMessageSizes))
+	for _, m := range cfg.Server.MaxMessageSizes {
+		if _, ok := authz.RPCScopes[m.Procedure]; !ok {
+			logger.Error("Unknown procedure in max message sizes", "procedure", m.Procedure)
+			os.Exit(1)
+		}
+		maxMessageSizes[m.Procedure] = m.MaxBytes
+	}
+	// Panics of handlers fail their RPC with an internal error and are reported
+	handlerOptions := connect.WithHandlerOptions(compression, msgsize.HandlerOption(cfg.Server.MaxMessageBytes, maxMessageSizes), connect.WithRecover(errreport.Recover(reporter, logger)))
+
+	// Initialize handlers; list and get handlers of shareable records authorize reads of other users' records
+	authorizer := authz.NewAuthorizer(dataShareRepo, userRepo, realClock)
+	bodyRecordHandler := handlers.NewBodyRecordHandler(bodyRecordRepo, attachmentRepo, preferenceRepo, attachmentStore, authorizer, pageLimits(cfg, config.PaginationEndpointBodyRecords), cfg.Pagination.MaxDateRangeDays, logger, realClock)
+	diaryHandler := handlers.NewDiaryHandler(diaryEntryRepo, authorizer, pageLimits(cfg, config.PaginationEndpointDiaryEntries), logger, realClock)
+	exerciseRecordHandler := handlers.NewExerciseRecordHandler(exerciseRecordRepo, authorizer, pageLimits(cfg, config.PaginationEndpointExerciseRecords), logger, realClock)
+	columnHandler := handlers.NewColumnHandler(columnRepo, pageLimits(cfg, config.PaginationEndpointColumns), logger, realClock)
+	supportHandler := handlers.NewSupportHandler(homeUserRepo, supportRepo, logger, realClock)
+	userAdminHandler := handlers.NewUserAdminHandler(homeUserRepo, userRepo, pageLimits(cfg, config.PaginationEndpointUsers), logger, realClock)
+	adminStatsHandler := handlers.NewAdminStatsHandler(statsRepo, logger, realClock)
+	authHandler := handlers.NewAuthHandler(sessionRepo, homeUserRepo, jwtConfig, logger, realClock)
+	importHandler := handlers.NewImportHandler(importRepo, logger, realClock)
+	recordHistoryHandler := handlers.NewRecordHistoryHandler(recordChangeRepo, logger, realClock)
+	fhirHandler := handlers.NewFHIRHandler(bodyRecordRepo, stepRecordRepo, logger, realClock)
+	supplementRepo := repo.NewSupplementRepository(database)
+	dailyTargetRepo := repo.NewDailyTargetRepository(database)
+	dashboardHandler := handlers.NewDashboardHandler(userRepo, bodyRecordRepo, exerciseRecordRepo, diaryEntryRepo, goalRepo, columnRepo, mealRecordRepo, achievementRepo, supplementRepo, dailyTargetRepo, logger, realClock)
+	goalHandler := handlers.NewGoalHandler(goalRepo, preferenceRepo, logger, realClock)
+	achievementHandler := handlers.NewAchievementHandler(achievementRepo, logger, realClock)
+	mealRecordHandler := handlers.NewMealRecordHandler(mealRecordRepo, authorizer, pageLimits(cfg, config.PaginationEndpointMealRecords), logger, realClock)
+	sharingHandler := handlers.NewSharingHandler(dataShareRepo, homeUserRepo, logger, realClock)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentRepo, attachmentStore, cfg.Storage.MaxUploadBytes, logger, realClock)
+	notificationHandler := handlers.NewNotificationHandler(pushRepo, pushPlatforms, logger, realClock)
+	reminderHandler := handlers.NewReminderHandler(reminderRepo, logger, realClock)
+	exerciseTemplateHandler := handlers.NewExerciseTemplateHandler(repo.NewExerciseTemplateRepository(database), exerciseRecordRepo, logger, realClock)
+	recipeHandler := handlers.NewRecipeHandler(repo.NewRecipeRepository(database), mealRecordRepo, logger, realClock)
+	mealPlanHandler := handlers.NewMealPlanHandler(repo.NewPlannedMealRepository(database), authorizer, logger, realClock)
+	supplementHandler := handlers.NewSupplementHandler(supplementRepo, logger, realClock)
+	stepHandler := handlers.NewStepHandler(stepRecordRepo, logger, realClock)
+	heartRateRepo := repo.NewHeartRateRepository(database)
+	heartRateHandler := handlers.NewHeartRateHandler(heartRateRepo, logger, realClock)
+	shareLinkKey := []byte(cfg.ShareLinks.SigningKey)
+	if len(shareLinkKey) == 0 {
+		// Links signed with a random key break on every restart and deploy
+		if !devMode {
+			logger.Error("Share link signing key is required outside development mode")
+			os.Exit(1)
+		}
+		shareLinkKey = make([]byte, 32)
+		if _, err := rand.Read(shareLinkKey); err != nil {
+			logger.Error("Failed to generate share link signing key", "error", err)
+			os.Exit(1)
+		}
+		logger.Warn("No share link signing key set, using a random key: diary share links stop working on restart")
+	}
+	diaryShareLinkHandler := handlers.NewDiaryShareLinkHandler(diaryEntryRepo, sharelink.NewSigner(shareLinkKey), logger, realClock)
+	moodRecordHandler := handlers.NewMoodRecordHandler(repo.NewMoodRecordRepository(database), pageLimits(cfg, config.PaginationEndpointMoodRecords), logger, realClock)
+	fastingHandler := handlers.NewFastingHandler(repo.NewFastRepository(database), pageLimits(cfg, config.PaginationEndpointFasts), logger, realClock)
+	dailyTargetHandler := handlers.NewDailyTargetHandler(dailyTargetRepo, featureFlags, logger, realClock)
+	workoutSessionHandler := handlers.NewWorkoutSessionHandler(repo.NewWorkoutSessionRepository(database), pageLimits(cfg, config.PaginationEndpointWorkoutSessions), logger, realClock)
+	usageHandler := handlers.NewUsageHandler(usageRepo, dailyQuota, logger, realClock)
+	retentionRepo := repo.NewCachedRetentionRepository(database, recordCounts)
+	retentionHandler := handlers.NewRetentionHandler(retentionRepo, cfg.Retention, logger)
+	trashRepo := repo.NewCachedTrashRepository(database, recordCounts)
+	trashHandler := handlers.NewTrashHandler(trashRepo, cfg.Trash.Window(), pageLimits(cfg, config.PaginationEndpointTrash), logger, realClock)
+	researchRepo := repo.NewResearchRepository(database)
+	researchHandler := handlers.NewResearchHandler(researchRepo, logger)
+	preferenceHandler := handlers.NewPreferenceHandler(preferenceRepo, logger)
+	// Digest settings are kept with the email address in the home database, next to the columns
+	columnDigestRepo := repo.NewColumnDigestRepository(homeDatabase)
+	columnDigestHandler := handlers.NewColumnDigestHandler(columnDigestRepo, logger)
+	monthlyReportRepo := repo.NewMonthlyReportRepository(database)
+	reportHandler := handlers.NewReportHandler(monthlyReportRepo, attachmentStore, logger, realClock)
+	researchExportHandler := handlers.NewResearchExportHandler(researchRepo, attachmentStore, cfg.Research, pageLimits(cfg, config.PaginationEndpointResearchExports), logger, realClock)
+	serverHandler := handlers.NewServerHandler(version.Get(), logger)
 
 	// Create router
 	mux := http.NewServeMux()
 
 	// Register Connect handlers
-	bodyRecordHandlerPath, bodyRecordServiceHandler := healthappv1connect.NewBodyRecordServiceHandler(bodyRecordHandler, interceptors)
-	mux.Handle(bodyRecordHandlerPath, bodyRecordServiceHandler)
-	diaryHandlerPath, diaryServiceHandler := healthappv1connect.NewDiaryServiceHandler(diaryHandler, interceptors)
-	mux.Handle(diaryHandlerPath, diaryServiceHandler)
-	exerciseRecordHandlerPath, exerciseRecordServiceHandler := healthappv1connect.NewExerciseRecordServiceHandler(exerciseRecordHandler, interceptors)
-	mux.Handle(exerciseRecordHandlerPath, exerciseRecordServiceHandler)
-	// Column service doesn't require authentication
-	columnHandlerPath, columnServiceHandler := healthappv1connect.NewColumnServiceHandler(columnHandler)
-	mux.Handle(columnHandlerPath, columnServiceHandler)
-
-	// Serve OpenAPI spec
-	mux.Handle("/openapi/", http.StripPrefix("/openapi/", http.FileServer(http.Dir("./third_party/openapi"))))
+	bodyRecordHandlerPath, bodyRecordServiceHandler := healthappv1connect.NewBodyRecordServiceHandler(bodyRecordHandler, interceptors, handlerOptions)
+	mux.Handle(bodyRecordHandlerPath, msgsize.Handler(bodyRecordServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	diaryHandlerPath, diaryServiceHandler := healthappv1connect.NewDiaryServiceHandler(diaryHandler, interceptors, handlerOptions)
+	mux.Handle(diaryHandlerPath, msgsize.Handler(diaryServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	exerciseRecordHandlerPath, exerciseRecordServiceHandler := healthappv1connect.NewExerciseRecordServiceHandler(exerciseRecordHandler, interceptors, handlerOptions)
+	mux.Handle(exerciseRecordHandlerPath, msgsize.Handler(exerciseRecordServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	exerciseTemplateHandlerPath, exerciseTemplateServiceHandler := healthappv1connect.NewExerciseTemplateServiceHandler(exerciseTemplateHandler, interceptors, handlerOptions)
+	mux.Handle(exerciseTemplateHandlerPath, msgsize.Handler(exerciseTemplateServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	workoutSessionHandlerPath, workoutSessionServiceHandler := healthappv1connect.NewWorkoutSessionServiceHandler(workoutSessionHandler, interceptors, handlerOptions)
+	mux.Handle(workoutSessionHandlerPath, msgsize.Handler(workoutSessionServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	stepHandlerPath, stepServiceHandler := healthappv1connect.NewStepServiceHandler(stepHandler, interceptors, handlerOptions)
+	mux.Handle(stepHandlerPath, msgsize.Handler(stepServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	heartRateHandlerPath, heartRateServiceHandler := healthappv1connect.NewHeartRateServiceHandler(heartRateHandler, interceptors, handlerOptions)
+	mux.Handle(heartRateHandlerPath, msgsize.Handler(heartRateServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	moodRecordHandlerPath, moodRecordServiceHandler := healthappv1connect.NewMoodRecordServiceHandler(moodRecordHandler, interceptors, handlerOptions)
+	mux.Handle(moodRecordHandlerPath, msgsize.Handler(moodRecordServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	fastingHandlerPath, fastingServiceHandler := healthappv1connect.NewFastingServiceHandler(fastingHandler, interceptors, handlerOptions)
+	mux.Handle(fastingHandlerPath, msgsize.Handler(fastingServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	dailyTargetHandlerPath, dailyTargetServiceHandler := healthappv1connect.NewDailyTargetServiceHandler(dailyTargetHandler, interceptors, handlerOptions)
+	mux.Handle(dailyTargetHandlerPath, msgsize.Handler(dailyTargetServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	reportHandlerPath, reportServiceHandler := healthappv1connect.NewReportServiceHandler(reportHandler, interceptors, handlerOptions)
+	mux.Handle(reportHandlerPath, msgsize.Handler(reportServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	diaryShareLinkHandlerPath, diaryShareLinkServiceHandler := healthappv1connect.NewDiaryShareLinkServiceHandler(diaryShareLinkHandler, interceptors, handlerOptions)
+	mux.Handle(diaryShareLinkHandlerPath, msgsize.Handler(diaryShareLinkServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	supportHandlerPath, supportServiceHandler := healthappv1connect.NewSupportServiceHandler(supportHandler, interceptors, handlerOptions)
+	mux.Handle(supportHandlerPath, msgsize.Handler(supportServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	userAdminHandlerPath, userAdminServiceHandler := healthappv1connect.NewUserAdminServiceHandler(userAdminHandler, interceptors, handlerOptions)
+	mux.Handle(userAdminHandlerPath, msgsize.Handler(userAdminServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	adminStatsHandlerPath, adminStatsServiceHandler := healthappv1connect.NewAdminStatsServiceHandler(adminStatsHandler, interceptors, handlerOptions)
+	mux.Handle(adminStatsHandlerPath, msgsize.Handler(adminStatsServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	researchExportHandlerPath, researchExportServiceHandler := healthappv1connect.NewResearchExportServiceHandler(researchExportHandler, interceptors, handlerOptions)
+	mux.Handle(researchExportHandlerPath, msgsize.Handler(researchExportServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	// The auth interceptor lets refresh requests through, as they are authenticated by their refresh token
+	authHandlerPath, authServiceHandler := healthappv1connect.NewAuthServiceHandler(authHandler, interceptors, handlerOptions)
+	mux.Handle(authHandlerPath, msgsize.Handler(authServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	importHandlerPath, importServiceHandler := healthappv1connect.NewImportServiceHandler(importHandler, interceptors, handlerOptions)
+	mux.Handle(importHandlerPath, msgsize.Handler(importServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	recordHistoryHandlerPath, recordHistoryServiceHandler := healthappv1connect.NewRecordHistoryServiceHandler(recordHistoryHandler, interceptors, handlerOptions)
+	mux.Handle(recordHistoryHandlerPath, msgsize.Handler(recordHistoryServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	fhirHandlerPath, fhirServiceHandler := healthappv1connect.NewFHIRServiceHandler(fhirHandler, interceptors, handlerOptions)
+	// Observation streams last as long as the history they send, past the server's write timeout
+	mux.Handle(fhirHandlerPath, streaming(msgsize.Handler(fhirServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes), logger, healthappv1connect.FHIRServiceStreamObservationsProcedure))
+	dashboardHandlerPath, dashboardServiceHandler := healthappv1connect.NewDashboardServiceHandler(dashboardHandler, interceptors, handlerOptions)
+	mux.Handle(dashboardHandlerPath, msgsize.Handler(dashboardServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	goalHandlerPath, goalServiceHandler := healthappv1connect.NewGoalServiceHandler(goalHandler, interceptors, handlerOptions)
+	mux.Handle(goalHandlerPath, msgsize.Handler(goalServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	achievementHandlerPath, achievementServiceHandler := healthappv1connect.NewAchievementServiceHandler(achievementHandler, interceptors, handlerOptions)
+	mux.Handle(achievementHandlerPath, msgsize.Handler(achievementServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	mealRecordHandlerPath, mealRecordServiceHandler := healthappv1connect.NewMealRecordServiceHandler(mealRecordHandler, interceptors, handlerOptions)
+	mux.Handle(mealRecordHandlerPath, msgsize.Handler(mealRecordServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	recipeHandlerPath, recipeServiceHandler := healthappv1connect.NewRecipeServiceHandler(recipeHandler, interceptors, handlerOptions)
+	mux.Handle(recipeHandlerPath, msgsize.Handler(recipeServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	mealPlanHandlerPath, mealPlanServiceHandler := healthappv1connect.NewMealPlanServiceHandler(mealPlanHandler, interceptors, handlerOptions)
+	mux.Handle(mealPlanHandlerPath, msgsize.Handler(mealPlanServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	supplementHandlerPath, supplementServiceHandler := healthappv1connect.NewSupplementServiceHandler(supplementHandler, interceptors, handlerOptions)
+	mux.Handle(supplementHandlerPath, msgsize.Handler(supplementServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	sharingHandlerPath, sharingServiceHandler := healthappv1connect.NewSharingServiceHandler(sharingHandler, interceptors, handlerOptions)
+	mux.Handle(sharingHandlerPath, msgsize.Handler(sharingServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	attachmentHandlerPath, attachmentServiceHandler := healthappv1connect.NewAttachmentServiceHandler(attachmentHandler, interceptors, handlerOptions)
+	mux.Handle(attachmentHandlerPath, msgsize.Handler(attachmentServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	notificationHandlerPath, notificationServiceHandler := healthappv1connect.NewNotificationServiceHandler(notificationHandler, interceptors, handlerOptions)
+	mux.Handle(notificationHandlerPath, msgsize.Handler(notificationServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	reminderHandlerPath, reminderServiceHandler := healthappv1connect.NewReminderServiceHandler(reminderHandler, interceptors, handlerOptions)
+	mux.Handle(reminderHandlerPath, msgsize.Handler(reminderServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	usageHandlerPath, usageServiceHandler := healthappv1connect.NewUsageServiceHandler(usageHandler, interceptors, handlerOptions)
+	mux.Handle(usageHandlerPath, msgsize.Handler(usageServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	retentionHandlerPath, retentionServiceHandler := healthappv1connect.NewRetentionServiceHandler(retentionHandler, interceptors, handlerOptions)
+	mux.Handle(retentionHandlerPath, msgsize.Handler(retentionServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	trashHandlerPath, trashServiceHandler := healthappv1connect.NewTrashServiceHandler(trashHandler, interceptors, handlerOptions)
+	mux.Handle(trashHandlerPath, msgsize.Handler(trashServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	researchHandlerPath, researchServiceHandler := healthappv1connect.NewResearchServiceHandler(researchHandler, interceptors, handlerOptions)
+	mux.Handle(researchHandlerPath, msgsize.Handler(researchServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	preferenceHandlerPath, preferenceServiceHandler := healthappv1connect.NewPreferenceServiceHandler(preferenceHandler, interceptors, handlerOptions)
+	mux.Handle(preferenceHandlerPath, msgsize.Handler(preferenceServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	columnDigestHandlerPath, columnDigestServiceHandler := healthappv1connect.NewColumnDigestServiceHandler(columnDigestHandler, interceptors, handlerOptions)
+	mux.Handle(columnDigestHandlerPath, msgsize.Handler(columnDigestServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	serverHandlerPath, serverServiceHandler := healthappv1connect.NewServerServiceHandler(serverHandler, interceptors, handlerOptions)
+	mux.Handle(serverHandlerPath, msgsize.Handler(serverServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	// Integration service is only available when a provider is enabled
+	if integrationRepo != nil {
+		integrationHandler := handlers.NewIntegrationHandler(integrationRepo, providers, logger, realClock)
+		integrationHandlerPath, integrationServiceHandler := healthappv1connect.NewIntegrationServiceHandler(integrationHandler, interceptors, handlerOptions)
+		mux.Handle(integrationHandlerPath, msgsize.Handler(integrationServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	}
+	// Food lookup service is only available when a food database is configured
+	if foodDB != nil {
+		foodLookupHandler := handlers.NewFoodLookupHandler(foodDB, logger)
+		foodLookupHandlerPath, foodLookupServiceHandler := healthappv1connect.NewFoodLookupServiceHandler(foodLookupHandler, interceptors, handlerOptions)
+		mux.Handle(foodLookupHandlerPath, msgsize.Handler(foodLookupServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	}
+	// The GraphQL gateway authenticates and counts requests as the interceptors do RPCs; its fields
+	// check their scopes themselves
+	if graphqlGateway {
+		if maintenanceWindow != nil {
+			mux.Handle(graphql.Procedure, maintenanceWindow.UnavailableHandler())
+		} else {
+			resolver := graphql.NewResolver(bodyRecordRepo, exerciseRecordRepo, diaryEntryRepo, logger, realClock)
+			checks := []interceptor.Check{
+				auth.Authenticate(jwtConfig, userRepo, sessionRepo, logger),
+				quota.Check(usageRepo, dailyQuota, nil, logger, realClock),
+			}
+			mux.Handle(graphql.Procedure, graphql.NewHandler(resolver, checks, cfg.Server.RPCTimeout, cfg.Server.Reflection))
+		}
+	}
+	// Withings notifications are authenticated by the secret in the callback URL, not by a JWT
+	var withingsWebhook *integration.WithingsWebhook
+	if withingsNotifications {
+		withingsWebhook = integration.NewWithingsWebhook(syncer, withingsCfg.NotificationSecret, dataRegions, logger)
+		if maintenanceWindow != nil {
+			// Notifications would sync against the database, so they are refused until the maintenance ends
+			mux.Handle(integration.WithingsWebhookPath, maintenanceWindow.UnavailableHandler())
+		} else {
+			mux.Handle(integration.WithingsWebhookPath, withingsWebhook)
+		}
+	}
+	// Unsubscribe links of column digests are authenticated by their signed token
+	var digestSigner *digest.Signer
+	if cfg.ColumnDigest.Enabled {
+		digestSigner = digest.NewSigner([]byte(cfg.ColumnDigest.SigningKey))
+		if maintenanceWindow != nil {
+			mux.Handle(digest.UnsubscribePath, maintenanceWindow.UnavailableHandler())
+		} else {
+			mux.Handle(digest.UnsubscribePath, digest.NewUnsubscribeHandler(columnDigestRepo, digestSigner, logger))
+		}
+	}
+	// Signed URLs of the local attachment store authenticate themselves
+	if localStoreHandler != nil {
+		mux.Handle(localStoreHandler.path+"/", localStoreHandler)
+	}
+	// Column service doesn't require authentication, but its RPCs still need a scope policy
+	columnHandlerPath, columnServiceHandler := healthappv1connect.NewColumnServiceHandler(columnHandler, connect.WithInterceptors(latencyInterceptor, errorMetricsInterceptor, errorReportInterceptor, maintenanceInterceptor, timeoutInterceptor, databaseErrorInterceptor(), scopeInterceptor, replicaReadsInterceptor()), handlerOptions)
+	mux.Handle(columnHandlerPath, msgsize.Handler(columnServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+	// Shared diary entries are authenticated by the token of their link; they are read from the
+	// primary so revoked links stop working immediately
+	sharedDiaryHandlerPath, sharedDiaryServiceHandler := healthappv1connect.NewSharedDiaryServiceHandler(diaryShareLinkHandler, connect.WithInterceptors(latencyInterceptor, errorMetricsInterceptor, errorReportInterceptor, i18n.Interceptor(), maintenanceInterceptor, timeoutInterceptor, databaseErrorInterceptor(), scopeInterceptor), handlerOptions)
+	mux.Handle(sharedDiaryHandlerPath, msgsize.Handler(sharedDiaryServiceHandler, cfg.Server.MaxMessageBytes, maxMessageSizes))
+
+	// Serve the annotated RPCs of the registered services at their REST paths
+	restServices := []string{
+		healthappv1connect.BodyRecordServiceName,
+		healthappv1connect.DiaryServiceName,
+		healthappv1connect.DiaryShareLinkServiceName,
+		healthappv1connect.ExerciseRecordServiceName,
+		healthappv1connect.ExerciseTemplateServiceName,
+		healthappv1connect.WorkoutSessionServiceName,
+		healthappv1connect.StepServiceName,
+		healthappv1connect.HeartRateServiceName,
+		healthappv1connect.MoodRecordServiceName,
+		healthappv1connect.FastingServiceName,
+		healthappv1connect.DailyTargetServiceName,
+		healthappv1connect.ReportServiceName,
+		healthappv1connect.SupportServiceName,
+		healthappv1connect.UserAdminServiceName,
+		healthappv1connect.AdminStatsServiceName,
+		healthappv1connect.ResearchExportServiceName,
+		healthappv1connect.AuthServiceName,
+		healthappv1connect.ImportServiceName,
+		healthappv1connect.RecordHistoryServiceName,
+		healthappv1connect.FHIRServiceName,
+		healthappv1connect.DashboardServiceName,
+		healthappv1connect.GoalServiceName,
+		healthappv1connect.MealRecordServiceName,
+		healthappv1connect.RecipeServiceName,
+		healthappv1connect.MealPlanServiceName,
+		healthappv1connect.SupplementServiceName,
+		healthappv1connect.AchievementServiceName,
+		healthappv1connect.AttachmentServiceName,
+		healthappv1connect.SharingServiceName,
+		healthappv1connect.NotificationServiceName,
+		healthappv1connect.ReminderServiceName,
+		healthappv1connect.UsageServiceName,
+		healthappv1connect.RetentionServiceName,
+		healthappv1connect.TrashServiceName,
+		healthappv1connect.ResearchServiceName,
+		healthappv1connect.PreferenceServiceName,
+		healthappv1connect.ColumnDigestServiceName,
+		healthappv1connect.ServerServiceName,
+		healthappv1connect.ColumnServiceName,
+		healthappv1connect.SharedDiaryServiceName,
+	}
+	if integrationRepo != nil {
+		restServices = append(restServices, healthappv1connect.IntegrationServiceName)
+	}
+	if foodDB != nil {
+		restServices = append(restServices, healthappv1connect.FoodLookupServiceName)
+	}
+
+	// Report the health of the registered services over the gRPC health protocol, without
+	// authentication; all of them but ServerService need the database
+	healthChecker := health.NewChecker(logger, realClock)
+	if cfg.Server.WarmupTimeout > 0 {
+		healthChecker.Warming()
+	}
+	healthChecker.AddDependency(healthDependencyDatabase, health.DatabaseCheck(database))
+	for _, service := range restServices {
+		if service == healthappv1connect.ServerServiceName {
+			healthChecker.AddService(service)
+			continue
+		}
+		healthChecker.AddService(service, healthDependencyDatabase)
+	}
+	mux.Handle(grpchealth.NewHandler(healthChecker, handlerOptions))
+
+	// Describe the registered services to gRPC clients such as grpcurl, without authentication
+	if cfg.Server.Reflection {
+		reflector := grpcreflect.NewStaticReflector(append(slices.Clone(restServices), grpchealth.HealthV1ServiceName)...)
+		mux.Handle(grpcreflect.NewHandlerV1(reflector, handlerOptions))
+		mux.Handle(grpcreflect.NewHandlerV1Alpha(reflector, handlerOptions))
+	}
+
+	transcoder, err := rest.NewTranscoder(mux, restServices...)
+	if err != nil {
+		logger.Error("Failed to create REST transcoder", "error", err)
+		os.Exit(1)
+	}
+	mux.Handle("/v1/", transcoder)
+
+	// Expose error counters by reason, query and RPC duration histograms and the SLO when enabled
+	if cfg.Server.MetricsEnabled {
+		mux.Handle("/debug/vars", expvar.Handler())
+		mux.Handle("/metrics", metrics.PrometheusHandler())
+	}
+
+	// Serve the OpenAPI specs generated from the protos by make proto, and Swagger UI for them with
+	// the assets installed by make swagger-ui
+	openAPISpecs := os.DirFS(openAPIDir)
+	// Health checks report the maintenance mode, but succeed during it
+	mux.Handle("GET /healthz", health.ReadyHandler(healthChecker, maintenance.HealthHandler(maintenanceWindow)))
+	mux.Handle("/openapi/", compressed(cfg.Server.Compression, http.StripPrefix("/openapi/", http.FileServer(http.FS(openAPISpecs)))))
+	swaggerUIAssets := os.DirFS(swaggerUIDir)
+	mux.Handle("/docs/assets/", compressed(cfg.Server.Compression, http.StripPrefix("/docs/assets/", http.FileServer(http.FS(swaggerUIAssets)))))
+	mux.Handle("GET /docs/{$}", compressed(cfg.Server.Compression, docs.Handler(openAPISpecs, "/openapi/", swaggerUIAssets, "/docs/assets/")))
+	mux.Handle("GET /docs", http.RedirectHandler("/docs/", http.StatusMovedPermanently))
 
 	// Configure server
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
 	logger.Info("Server listening", "address", addr)
 
-	// Create server with h2c for HTTP/2 without TLS
+	// Browsers may call the API from the configured origins, which are reloaded without a restart
+	corsPolicy := cors.NewPolicy(cfg.Server.CORSOrigins)
+
+	// Create server with h2c for HTTP/2 without TLS; every request gets an ID for its logs
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      h2c.NewHandler(mux, &http2.Server{}),
+		Handler:      h2c.NewHandler(log.Middleware(corsPolicy.Handler(mux)), &http2.Server{}),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
@@ -167,11 +810,199 @@ func runServer() {
 		}
 	}()
 
+	// Warm up before health checks report the server ready, so load balancers only route to it
+	// once connections are open and the statements of hot queries prepared
+	if cfg.Server.WarmupTimeout > 0 {
+		go func() {
+			warmup.Run(context.Background(), warmupSteps(cfg, jwtConfig, database, warmPools, realClock), cfg.Server.WarmupTimeout, logger, realClock)
+			healthChecker.Ready()
+		}()
+	}
+
+	// Start the background jobs; they query the database, so they are paused during maintenance.
+	// Jobs over the records of users run once per data region, on its database.
+	syncCtx, syncCancel := context.WithCancel(context.Background())
+	defer syncCancel()
+	if maintenanceWindow != nil {
+		logger.Warn("Maintenance mode enabled: RPCs fail with unavailable and background jobs are paused", "endsAt", cfg.Maintenance.EndsAt)
+	} else {
+		// Start periodic integration sync in the background
+		if syncer != nil {
+			for _, region := range dataRegions {
+				go syncer.Run(repo.WithRegion(syncCtx, region))
+			}
+			logger.Info("Integration sync started", "providers", len(providers), "interval", cfg.Integrations.SyncInterval)
+		}
+
+		// Start push notification delivery in the background
+		if len(pushSenders) > 0 {
+			if cfg.Push.Interval <= 0 {
+				logger.Error("Invalid push delivery interval", "interval", cfg.Push.Interval)
+				os.Exit(1)
+			}
+			for _, region := range dataRegions {
+				worker := push.NewWorker(pushRepo, pushSenders, cfg.Push.Interval, logger, realClock)
+				go worker.Run(repo.WithRegion(syncCtx, region))
+			}
+			logger.Info("Push notification delivery started", "platforms", pushPlatforms, "interval", cfg.Push.Interval)
+		}
+
+		// Fire due reminders in the background; without push senders their notifications stay queued
+		if cfg.Reminders.Interval <= 0 {
+			logger.Error("Invalid reminder interval", "interval", cfg.Reminders.Interval)
+			os.Exit(1)
+		}
+		for _, region := range dataRegions {
+			scheduler := reminder.NewScheduler(reminderRepo, push.NewNotifier(pushRepo, realClock), cfg.Reminders.Interval, logger, realClock)
+			go scheduler.Run(repo.WithRegion(syncCtx, region))
+		}
+		logger.Info("Reminder scheduler started", "interval", cfg.Reminders.Interval)
+
+		// Notify users of the goals they reach and of the columns published in their digest categories
+		if cfg.Push.MilestoneInterval <= 0 || cfg.Push.ColumnInterval <= 0 {
+			logger.Error("Invalid push notification intervals", "milestoneInterval", cfg.Push.MilestoneInterval, "columnInterval", cfg.Push.ColumnInterval)
+			os.Exit(1)
+		}
+		for _, region := range dataRegions {
+			milestoneJob := milestone.NewJob(goalRepo, push.NewNotifier(pushRepo, realClock), cfg.Push.MilestoneInterval, logger, realClock)
+			go milestoneJob.Run(repo.WithRegion(syncCtx, region))
+		}
+		announceJob := announce.NewJob(columnRepo, push.NewNotifier(pushRepo, realClock), cfg.Push.ColumnInterval, logger, realClock)
+		go announceJob.Run(syncCtx)
+		logger.Info("Goal milestone and column notifications started", "milestoneInterval", cfg.Push.MilestoneInterval, "columnInterval", cfg.Push.ColumnInterval)
+
+		// Roll up the heart rate samples in the background
+		if cfg.Rollups.Interval <= 0 {
+			logger.Error("Invalid rollup interval", "interval", cfg.Rollups.Interval)
+			os.Exit(1)
+		}
+		for _, region := range dataRegions {
+			rollupWorker := rollup.NewWorker(heartRateRepo, cfg.Rollups.Interval, logger)
+			go rollupWorker.Run(repo.WithRegion(syncCtx, region))
+		}
+		logger.Info("Heart rate rollups started", "interval", cfg.Rollups.Interval)
+
+		// Create the record partitions of the coming months in the background
+		for _, region := range dataRegions {
+			maintainer := partition.NewMaintainer(repo.NewPartitionRepository(database), cfg.Database.Partitions.MonthsAhead, cfg.Database.Partitions.Interval, logger, realClock)
+			go maintainer.Run(repo.WithRegion(syncCtx, region))
+		}
+		logger.Info("Partition maintenance started", "monthsAhead", cfg.Database.Partitions.MonthsAhead, "interval", cfg.Database.Partitions.Interval)
+
+		// Publish the domain events of the outbox in the background
+		for _, region := range dataRegions {
+			relay := outbox.NewRelay(repo.NewOutboxRepository(database), newOutboxPublisher(cfg.Outbox, logger, realClock), cfg.Outbox.Interval, cfg.Outbox.RetainPublished, logger, realClock)
+			go relay.Run(repo.WithRegion(syncCtx, region))
+		}
+		logger.Info("Outbox relay started", "driver", cfg.Outbox.Driver, "interval", cfg.Outbox.Interval)
+
+		// Apply the retention policy in the background
+		if cfg.Retention.Enabled {
+			for _, region := range dataRegions {
+				retentionJob := retention.NewJob(retentionRepo, attachmentStore, cfg.Retention, logger, realClock)
+				go retentionJob.Run(repo.WithRegion(syncCtx, region))
+			}
+			logger.Info("Retention job started", "dryRun", cfg.Retention.DryRun, "changeHistoryDays", cfg.Retention.ChangeHistoryDays, "apiUsageDays", cfg.Retention.APIUsageDays, "archiveAfterYears", cfg.Retention.ArchiveAfterYears, "interval", cfg.Retention.Interval)
+		}
+
+		// Purge the trash past its window in the background
+		if cfg.Trash.Enabled {
+			for _, region := range dataRegions {
+				trashJob := trash.NewJob(trashRepo, attachmentStore, cfg.Trash, logger, realClock)
+				go trashJob.Run(repo.WithRegion(syncCtx, region))
+			}
+			logger.Info("Trash job started", "dryRun", cfg.Trash.DryRun, "days", cfg.Trash.Days, "interval", cfg.Trash.Interval)
+		}
+
+		// Write the requested research exports in the background
+		if cfg.Research.Enabled {
+			for _, region := range dataRegions {
+				researchJob := research.NewJob(researchRepo, attachmentStore, cfg.Research, logger, realClock)
+				go researchJob.Run(repo.WithRegion(syncCtx, region))
+			}
+			logger.Info("Research export job started", "minGroupSize", cfg.Research.MinGroupSize, "interval", cfg.Research.Interval)
+		}
+
+		// Write the requested monthly reports in the background
+		reportFont, err := newReportFont(cfg.Reports.FontFile)
+		if err != nil {
+			logger.Error("Invalid report font", "error", err)
+			os.Exit(1)
+		}
+		for _, region := range dataRegions {
+			reportJob := report.NewJob(monthlyReportRepo, bodyRecordRepo, exerciseRecordRepo, diaryEntryRepo, preferenceRepo, attachmentStore, cfg.Reports, reportFont, logger, realClock)
+			go reportJob.Run(repo.WithRegion(syncCtx, region))
+		}
+		logger.Info("Monthly report job started", "interval", cfg.Reports.Interval)
+
+		// Email the weekly digests of new columns in the background
+		if cfg.ColumnDigest.Enabled {
+			mailer, err := email.NewMailer(newEmailSender(cfg.Email, logger), cfg.Email.From)
+			if err != nil {
+				logger.Error("Invalid email config", "error", err)
+				os.Exit(1)
+			}
+			digestJob := digest.NewJob(columnDigestRepo, mailer, digestSigner, cfg.ColumnDigest, logger, realClock)
+			go digestJob.Run(syncCtx)
+			logger.Info("Column digest job started", "interval", cfg.ColumnDigest.Interval)
+		}
+
+		// Start daily sandbox resets in the background
+		if cfg.Sandbox.Enabled {
+			resetAt, err := cfg.Sandbox.ResetOffset()
+			if err != nil {
+				logger.Error("Invalid sandbox reset time", "error", err)
+				os.Exit(1)
+			}
+			resetter := sandbox.NewResetter(repo.NewSandboxRepository(database, userCache, recordCounts), cfg.Sandbox.SubjectID, resetAt, logger, realClock)
+			go resetter.Run(syncCtx)
+			logger.Warn("Sandbox mode enabled: the sandbox user is reset daily", "subjectID", cfg.Sandbox.SubjectID, "resetTime", cfg.Sandbox.ResetTime)
+		}
+	}
+
+	// Reload the settings that are safe to change on SIGHUP, and when the config files change if watched
+	live := &liveSettings{logLevel: logLevel, quota: dailyQuota, cors: corsPolicy, features: featureFlags}
+	reloads := make(chan struct{}, 1)
+	requestReload := func() {
+		select {
+		case reloads <- struct{}{}:
+		default: // A reload is pending already
+		}
+	}
+	hangupChan := make(chan os.Signal, 1)
+	signal.Notify(hangupChan, syscall.SIGHUP)
+	go func() {
+		for range hangupChan {
+			requestReload()
+		}
+	}()
+	if cfg.Server.WatchConfig {
+		if err := config.Watch(syncCtx, configPath, requestReload, logger); err != nil {
+			logger.Error("Failed to watch configuration", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Watching configuration for changes", "path", configPath)
+	}
+	go func() {
+		current := cfg
+		for {
+			select {
+			case <-syncCtx.Done():
+				return
+			case <-reloads:
+				current = reloadConfig(current, live, logger)
+			}
+		}
+	}()
+
 	// Wait for interrupt signal
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
 	<-stopChan
 	logger.Info("Shutdown signal received, initiating graceful shutdown...")
+	// Load balancers checking the gRPC health stop routing to the server while it drains
+	healthChecker.Drain()
+	syncCancel()
 
 	// Create shutdown context with timeout
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -182,6 +1013,271 @@ func runServer() {
 		logger.Error("Server graceful shutdown failed", "error", err)
 		os.Exit(1)
 	}
+	if withingsWebhook != nil {
+		withingsWebhook.Wait()
+	}
+
+	// Send the reports still queued
+	reportCancel()
+	<-reportDone
 
 	logger.Info("Server shutdown gracefully")
 }
+
+// applyFlags overrides the settings of cfg set by command line flags
+func applyFlags(cfg *config.Config) {
+	if port != "" {
+		cfg.Server.Port = port
+	}
+	if sandboxMode {
+		cfg.Sandbox.Enabled = true
+	}
+	if maintenanceMode {
+		cfg.Maintenance.Enabled = true
+	}
+	if devMode {
+		applyDevFlags(cfg)
+	}
+}
+
+// liveSettings are the settings of a running server that are changed when the config is reloaded
+type liveSettings struct {
+	logLevel *slog.LevelVar
+	quota    *quota.Limit
+	cors     *cors.Policy
+	features *features.Flags
+}
+
+// apply changes the live settings to those of cfg
+func (s *liveSettings) apply(cfg *config.Config) {
+	s.logLevel.Set(cfg.Log.SlogLevel())
+	s.quota.Set(cfg.Quota.DailyRequests)
+	s.cors.SetOrigins(cfg.Server.CORSOrigins)
+	s.features.Set(cfg.Features)
+}
+
+// reloadConfig loads the configuration again and applies its reloadable settings to live. Other
+// changes, e.g. of the database or JWT settings, are logged and ignored until the server is
+// restarted. It returns the configuration in effect, which is current if the new one is invalid.
+func reloadConfig(current *config.Config, live *liveSettings, logger *slog.Logger) *config.Config {
+	next, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to reload configuration, keeping the current one", "error", err)
+		return current
+	}
+	applyFlags(next)
+	if sections := config.RestartRequired(current, next); len(sections) > 0 {
+		logger.Warn("Configuration changes take effect on restart", "sections", sections)
+	}
+	reloaded := config.Reloadable(current, next)
+	live.apply(reloaded)
+	logger.Info("Configuration reloaded", "logLevel", reloaded.Log.Level, "dailyQuota", reloaded.Quota.DailyRequests, "corsOrigins", reloaded.Server.CORSOrigins, "features", reloaded.Features)
+	return reloaded
+}
+
+// warmupSteps returns the steps of the warmup: pinging the database, priming the connections of
+// the pools and checking the JWT signing key
+func warmupSteps(cfg *config.Config, jwtConfig *auth.JWTConfig, database repo.DB, pools []*pgxpool.Pool, clock clock.Clock) []warmup.Step {
+	conns := max(int(cfg.Database.MinConns), 1)
+	columnsPageSize := int32(cfg.Pagination.Limits("columns").DefaultPageSize)
+	return []warmup.Step{
+		{Name: "database", Run: health.DatabaseCheck(database)},
+		{Name: "statements", Run: func(ctx context.Context) error {
+			for _, pool := range pools {
+				if err := repo.Prime(ctx, pool, conns, columnsPageSize, clock.Now()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{Name: "jwt", Run: func(ctx context.Context) error {
+			return auth.CheckSigningKey(jwtConfig, clock.Now())
+		}},
+	}
+}
+
+// replicaReadsInterceptor creates a Connect interceptor letting the reads of RPC handlers, streams
+// included, go to read replicas. Background jobs keep reading from the primary.
+func replicaReadsInterceptor() connect.Interceptor {
+	return interceptor.New(func(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+		return repo.WithReplicaReads(ctx), nil
+	})
+}
+
+// databaseErrorInterceptor creates a Connect interceptor failing RPCs with unavailable instead
+// of internal when they failed because the database was unavailable, so that clients retry them
+// later, and with deadline_exceeded when a statement exceeded the statement or lock timeout
+func databaseErrorInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			ctx = repo.WithTimeoutTracking(repo.WithUnavailableTracking(ctx))
+			resp, err := next(ctx, req)
+			if err == nil || connect.CodeOf(err) != connect.CodeInternal {
+				return resp, err
+			}
+			switch {
+			case repo.DatabaseUnavailable(ctx):
+				return nil, apierror.New(connect.CodeUnavailable, apierror.ReasonDatabaseUnavailable, errors.New("database temporarily unavailable"))
+			case repo.StatementTimedOut(ctx):
+				return nil, apierror.New(connect.CodeDeadlineExceeded, apierror.ReasonDatabaseTimeout, errors.New("database query timed out"))
+			}
+			return resp, err
+		}
+	}
+}
+
+// streaming lifts the write timeout of the server from the calls of the streaming procedures of
+// the Connect handler h, which are bounded by their clients instead
+func streaming(h http.Handler, logger *slog.Logger, procedures ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(procedures, r.URL.Path) {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				logger.WarnContext(r.Context(), "Failed to lift write timeout of stream", "procedure", r.URL.Path, "error", err)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// compressed gzips the responses of the plain HTTP handler h if compression is enabled
+func compressed(cfg config.CompressionConfig, h http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return h
+	}
+	return compress.Handler(h, cfg.MinBytes)
+}
+
+// pageLimits returns the configured page limits of a list endpoint
+func pageLimits(cfg *config.Config, endpoint string) handlers.PageLimits {
+	limits := cfg.Pagination.Limits(endpoint)
+	return handlers.PageLimits{
+		DefaultPageSize: limits.DefaultPageSize,
+		MaxPageSize:     limits.MaxPageSize,
+	}
+}
+
+// countCache returns the cache of the total counts of a list endpoint, or nil if the endpoint has
+// no count cache TTL
+func countCache(cfg *config.Config, endpoint string, clk clock.Clock) *repo.CountCache {
+	ttl := cfg.Pagination.CountCacheTTL[endpoint]
+	if ttl <= 0 {
+		return nil
+	}
+	return repo.NewCountCache(cfg.Pagination.CountCacheSize, ttl, clk)
+}
+
+// localStoreHandler serves the signed URLs of a local attachment store at path
+type localStoreHandler struct {
+	http.Handler
+	path string
+}
+
+// newAttachmentStore creates the attachment store of the configured driver. For the local
+// driver, it also returns the handler serving the store, to mount on the server.
+func newAttachmentStore(cfg config.StorageConfig, logger *slog.Logger, clk clock.Clock) (storage.Store, *localStoreHandler, error) {
+	switch cfg.Driver {
+	case storage.DriverS3:
+		return storage.NewS3(cfg.S3.Bucket, cfg.S3.Region, cfg.S3.AccessKeyID, cfg.S3.SecretAccessKey, cfg.S3.Endpoint), nil, nil
+	case storage.DriverGCS:
+		return storage.NewGCS(cfg.GCS.Bucket, cfg.GCS.AccessID, cfg.GCS.Secret), nil, nil
+	}
+
+	baseURL, err := url.Parse(cfg.Local.BaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid local base URL: %w", err)
+	}
+	path := strings.TrimSuffix(baseURL.Path, "/")
+	if path == "" {
+		return nil, nil, errors.New("local base URL must have a path, e.g. /attachments")
+	}
+	signingKey := []byte(cfg.Local.SigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		logger.Warn("No local storage signing key set, using a random key: attachment URLs stop working on restart")
+	}
+	local := storage.NewLocal(cfg.Local.Dir, cfg.Local.BaseURL, signingKey, clk)
+	return local, &localStoreHandler{Handler: http.StripPrefix(path, local.Handler()), path: path}, nil
+}
+
+// newReportFont loads the TrueType font of the text of reports outside Latin-1, or returns nil
+// without a font file
+func newReportFont(path string) (*pdf.TrueType, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+	font, err := pdf.ParseTrueType(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid font %s: %w", path, err)
+	}
+	return font, nil
+}
+
+// newKMS creates the KMS of the configured driver. The config is validated on load when it has
+// a data key, so the driver is known.
+func newKMS(cfg config.EncryptionConfig) (crypto.KMS, error) {
+	if cfg.Driver == crypto.KMSDriverAWS {
+		return crypto.NewAWSKMS(cfg.AWS.Region, cfg.AWS.KeyID, cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey), nil
+	}
+	master, err := crypto.NewCipherFromBase64(cfg.Local.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local master key: %w", err)
+	}
+	return crypto.NewLocalKMS(master), nil
+}
+
+// newDiaryCipher creates the cipher of the configured data key, or returns nil without one
+func newDiaryCipher(cfg config.EncryptionConfig) (*crypto.Cipher, error) {
+	if cfg.DataKey == "" {
+		return nil, nil
+	}
+	kms, err := newKMS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return crypto.NewEnvelopeCipher(ctx, kms, cfg.DataKey)
+}
+
+// newOutboxPublisher creates the event publisher of the configured driver
+func newOutboxPublisher(cfg config.OutboxConfig, logger *slog.Logger, clk clock.Clock) outbox.Publisher {
+	if cfg.Driver == outbox.DriverWebhook {
+		return outbox.NewWebhookPublisher(cfg.Webhook.URL, cfg.Webhook.Secret, cfg.Webhook.Timeout, clk)
+	}
+	return outbox.NewLogPublisher(logger)
+}
+
+// newPushSenders creates the push senders of the platforms with credentials
+func newPushSenders(cfg config.PushConfig) ([]push.Sender, error) {
+	var senders []push.Sender
+	if cfg.FCM.CredentialsFile != "" {
+		credentials, err := os.ReadFile(cfg.FCM.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FCM credentials: %w", err)
+		}
+		fcm, err := push.NewFCM(credentials)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, fcm)
+	}
+	if cfg.APNs.KeyFile != "" {
+		key, err := os.ReadFile(cfg.APNs.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read APNs key: %w", err)
+		}
+		apns, err := push.NewAPNs(key, cfg.APNs.KeyID, cfg.APNs.TeamID, cfg.APNs.Topic, cfg.APNs.Sandbox)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, apns)
+	}
+	return senders, nil
+}
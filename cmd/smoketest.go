@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+)
+
+var (
+	smokeTestURL     string
+	smokeTestToken   string
+	smokeTestSubject string
+	smokeTestTimeout time.Duration
+)
+
+// smoketestCmd represents the smoketest command
+var smoketestCmd = &cobra.Command{
+	Use:   "smoketest",
+	Short: "Run an end-to-end smoke test against a running server",
+	Long: `Run a scripted scenario against a running server for post-deploy verification:
+check authentication, create one record of each type, list them, fetch the dashboard
+summary and delete the created records. Exits non-zero if any step fails.
+
+Without --token, a token for --subject is signed with the JWT secret key from the config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runSmokeTest() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(smoketestCmd)
+
+	// Local flags
+	smoketestCmd.Flags().StringVar(&smokeTestURL, "url", "http://localhost:8080", "base URL of the server to test")
+	smoketestCmd.Flags().StringVar(&smokeTestToken, "token", os.Getenv("HEALTHAPP_SMOKETEST_TOKEN"), "bearer token to authenticate with (default $HEALTHAPP_SMOKETEST_TOKEN)")
+	smoketestCmd.Flags().StringVar(&smokeTestSubject, "subject", "smoketest", "subject of the signed token when --token is not set")
+	smoketestCmd.Flags().DurationVar(&smokeTestTimeout, "timeout", time.Minute, "timeout of the whole scenario")
+}
+
+func runSmokeTest() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+	logger.Info("Starting smoke test...", "url", smokeTestURL)
+
+	token := smokeTestToken
+	if token == "" {
+		// Load configuration
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Error("Failed to load configuration", "error", err)
+			return false
+		}
+		token, err = signToken(cfg.JWT.SecretKey, smokeTestSubject, recordScopes, 10*time.Minute)
+		if err != nil {
+			logger.Error("Failed to sign smoke test token", "error", err)
+			return false
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestTimeout)
+	defer cancel()
+
+	s := newSmokeTest(smokeTestURL, token, logger)
+	return s.run(ctx)
+}
+
+// recordScopes are the scopes of the tokens signed for the smoke and load tests, which read and
+// write records of their user
+var recordScopes = []string{authz.ScopeRecordsRead, authz.ScopeRecordsWrite}
+
+// signToken signs a token for subject granted scopes, valid for ttl
+func signToken(secretKey, subject string, scopes []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":   subject,
+		"scope": strings.Join(scopes, " "),
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secretKey))
+}
+
+// smokeTest is the scripted scenario run by the smoketest command
+type smokeTest struct {
+	log              *slog.Logger
+	bodyRecords      healthappv1connect.BodyRecordServiceClient
+	exerciseRecords  healthappv1connect.ExerciseRecordServiceClient
+	diaryEntries     healthappv1connect.DiaryServiceClient
+	dashboard        healthappv1connect.DashboardServiceClient
+	anonymousDiaries healthappv1connect.DiaryServiceClient
+
+	// IDs of the records created by the scenario, cleared once deleted
+	exerciseRecordID string
+	diaryEntryID     string
+}
+
+func newSmokeTest(baseURL, token string, logger *slog.Logger) *smokeTest {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	authenticated := connect.WithInterceptors(bearerTokenInterceptor(token))
+	return &smokeTest{
+		log:              logger,
+		bodyRecords:      healthappv1connect.NewBodyRecordServiceClient(httpClient, baseURL, authenticated),
+		exerciseRecords:  healthappv1connect.NewExerciseRecordServiceClient(httpClient, baseURL, authenticated),
+		diaryEntries:     healthappv1connect.NewDiaryServiceClient(httpClient, baseURL, authenticated),
+		dashboard:        healthappv1connect.NewDashboardServiceClient(httpClient, baseURL, authenticated),
+		anonymousDiaries: healthappv1connect.NewDiaryServiceClient(httpClient, baseURL),
+	}
+}
+
+// bearerTokenInterceptor sets the Authorization header of every request
+func bearerTokenInterceptor(token string) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			req.Header().Set("Authorization", "Bearer "+token)
+			return next(ctx, req)
+		}
+	}
+}
+
+// run runs the steps in order, stopping at the first failure. Records created before a
+// failure are still deleted. Reports whether all steps passed.
+func (s *smokeTest) run(ctx context.Context) bool {
+	steps := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"reject unauthenticated request", s.rejectUnauthenticated},
+		{"create body record", s.createBodyRecord},
+		{"create exercise record", s.createExerciseRecord},
+		{"create diary entry", s.createDiaryEntry},
+		{"list records", s.listRecords},
+		{"get dashboard summary", s.getDashboard},
+		{"delete records", s.deleteRecords},
+	}
+
+	passed := true
+	for _, step := range steps {
+		start := time.Now()
+		if err := step.fn(ctx); err != nil {
+			s.log.Error("Smoke test step failed", "step", step.name, "duration", time.Since(start), "error", err)
+			passed = false
+			break
+		}
+		s.log.Info("Smoke test step passed", "step", step.name, "duration", time.Since(start))
+	}
+
+	if !passed {
+		// Remove what the failed run created, on a fresh deadline
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.deleteRecords(cleanupCtx); err != nil {
+			s.log.Warn("Failed to delete smoke test records", "error", err)
+		}
+		s.log.Error("Smoke test failed")
+		return false
+	}
+	s.log.Info("Smoke test passed")
+	return true
+}
+
+func (s *smokeTest) rejectUnauthenticated(ctx context.Context) error {
+	_, err := s.anonymousDiaries.ListDiaryEntries(ctx, connect.NewRequest(&v1.ListDiaryEntriesRequest{}))
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		return fmt.Errorf("expected unauthenticated error, got %v", err)
+	}
+	return nil
+}
+
+func (s *smokeTest) createBodyRecord(ctx context.Context) error {
+	// Body records are upserted per date and have no delete RPC, so repeated runs overwrite today's record
+	_, err := s.bodyRecords.CreateBodyRecord(ctx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     time.Now().UTC().Format("2006-01-02"),
+		WeightKg: wrapperspb.Double(70),
+	}))
+	return err
+}
+
+func (s *smokeTest) createExerciseRecord(ctx context.Context) error {
+	end := time.Now().Add(-time.Minute)
+	resp, err := s.exerciseRecords.CreateExerciseRecord(ctx, connect.NewRequest(&v1.CreateExerciseRecordRequest{
+		ExerciseName: "Smoke test",
+		StartedAt:    timestamppb.New(end.Add(-10 * time.Minute)),
+		EndedAt:      timestamppb.New(end),
+	}))
+	if err != nil {
+		return err
+	}
+	s.exerciseRecordID = resp.Msg.ExerciseRecord.Id
+	return nil
+}
+
+func (s *smokeTest) createDiaryEntry(ctx context.Context) error {
+	resp, err := s.diaryEntries.CreateDiaryEntry(ctx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+		Title:     wrapperspb.String("Smoke test"),
+		Content:   "Created by the smoketest command",
+		EntryDate: time.Now().UTC().Format("2006-01-02"),
+	}))
+	if err != nil {
+		return err
+	}
+	s.diaryEntryID = resp.Msg.DiaryEntry.Id
+	return nil
+}
+
+func (s *smokeTest) listRecords(ctx context.Context) error {
+	bodyResp, err := s.bodyRecords.ListBodyRecords(ctx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list body records: %w", err)
+	}
+	if len(bodyResp.Msg.BodyRecords) == 0 {
+		return errors.New("created body record is not listed")
+	}
+
+	exerciseResp, err := s.exerciseRecords.ListExerciseRecords(ctx, connect.NewRequest(&v1.ListExerciseRecordsRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list exercise records: %w", err)
+	}
+	if !containsID(exerciseResp.Msg.ExerciseRecords, s.exerciseRecordID, (*v1.ExerciseRecord).GetId) {
+		return errors.New("created exercise record is not listed")
+	}
+
+	diaryResp, err := s.diaryEntries.ListDiaryEntries(ctx, connect.NewRequest(&v1.ListDiaryEntriesRequest{}))
+	if err != nil {
+		return fmt.Errorf("failed to list diary entries: %w", err)
+	}
+	if !containsID(diaryResp.Msg.DiaryEntries, s.diaryEntryID, (*v1.DiaryEntry).GetId) {
+		return errors.New("created diary entry is not listed")
+	}
+	return nil
+}
+
+func (s *smokeTest) getDashboard(ctx context.Context) error {
+	resp, err := s.dashboard.GetDashboard(ctx, connect.NewRequest(&v1.GetDashboardRequest{Days: 1}))
+	if err != nil {
+		return err
+	}
+	if len(resp.Msg.BodyRecords) == 0 {
+		return errors.New("dashboard has no body record for today")
+	}
+	if resp.Msg.ExerciseTotals.GetSessionCount() == 0 {
+		return errors.New("dashboard exercise totals do not include the created record")
+	}
+	return nil
+}
+
+func (s *smokeTest) deleteRecords(ctx context.Context) error {
+	if s.exerciseRecordID != "" {
+		if _, err := s.exerciseRecords.DeleteExerciseRecord(ctx, connect.NewRequest(&v1.DeleteExerciseRecordRequest{Id: s.exerciseRecordID})); err != nil {
+			return fmt.Errorf("failed to delete exercise record: %w", err)
+		}
+		s.exerciseRecordID = ""
+	}
+	if s.diaryEntryID != "" {
+		if _, err := s.diaryEntries.DeleteDiaryEntry(ctx, connect.NewRequest(&v1.DeleteDiaryEntryRequest{Id: s.diaryEntryID})); err != nil {
+			return fmt.Errorf("failed to delete diary entry: %w", err)
+		}
+		s.diaryEntryID = ""
+	}
+	return nil
+}
+
+// containsID reports whether one of the items has the given ID
+func containsID[T any](items []T, id string, getID func(T) string) bool {
+	for _, item := range items {
+		if getID(item) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/trash"
+)
+
+var trashDryRun bool
+
+// purgeTrashCmd represents the purge-trash command
+var purgeTrashCmd = &cobra.Command{
+	Use:   "purge-trash",
+	Short: "Purge the records deleted before the trash window once",
+	Long: `Purge the trash once, as the server's trash job does every interval: delete the records
+deleted more than the days of the trash config ago for good, with the files of their photos. With
+--dry-run, only report how many deleted records would be purged.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !runPurgeTrash() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(purgeTrashCmd)
+
+	// Local flags
+	purgeTrashCmd.Flags().BoolVar(&trashDryRun, "dry-run", false, "only report the deleted records past the window")
+}
+
+func runPurgeTrash() bool {
+	// Initialize logger
+	logger := log.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return false
+	}
+	realClock := clock.NewRealClock()
+	store, _, err := newAttachmentStore(cfg.Storage, logger, realClock)
+	if err != nil {
+		logger.Error("Invalid storage config", "error", err)
+		return false
+	}
+
+	// Initialize database connection
+	dbPool, err := repo.NewDBPool(&cfg.Database, logger)
+	if err != nil {
+		logger.Error("Failed to connect to database", "error", err)
+		return false
+	}
+	defer dbPool.Close()
+	job := trash.NewJob(repo.NewTrashRepository(dbPool), store, cfg.Trash, logger, realClock)
+
+	// An interrupt stops after the current batch; completed batches stay purged
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if _, err := job.Purge(ctx, trashDryRun || cfg.Trash.DryRun); err != nil {
+		logger.Error("Failed to purge trash", "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/atreya2011/health-management-api/internal/version"
+)
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of the binary",
+	Long: `Print the version, git SHA, build date and Go version of the binary. A running server
+returns the same through ServerService.GetServerInfo.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		info := version.Get()
+		fmt.Printf("Version:    %s\n", info.Version)
+		fmt.Printf("Git SHA:    %s\n", valueOrUnknown(info.GitSHA))
+		fmt.Printf("Build date: %s\n", valueOrUnknown(info.BuildDate))
+		fmt.Printf("Go version: %s\n", info.GoVersion)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+// valueOrUnknown returns value, or "unknown" if it is empty
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
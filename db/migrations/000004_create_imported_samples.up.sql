@@ -0,0 +1,12 @@
+-- Tracks samples imported from external sources so re-imports are idempotent
+CREATE TABLE imported_samples (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    source TEXT NOT NULL, -- e.g., "healthkit"
+    source_sample_id TEXT NOT NULL, -- Sample UUID assigned by the source
+    record_type TEXT NOT NULL, -- e.g., "body_record", "exercise_record"
+    record_id UUID, -- Record created or updated from the sample
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, source, source_sample_id) -- Dedupe on the source sample UUID
+);
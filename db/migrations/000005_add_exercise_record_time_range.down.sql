@@ -0,0 +1,4 @@
+ALTER TABLE exercise_records
+    DROP CONSTRAINT IF EXISTS chk_exercise_records_time_range,
+    DROP COLUMN IF EXISTS ended_at,
+    DROP COLUMN IF EXISTS started_at;
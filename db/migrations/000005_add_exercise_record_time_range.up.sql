@@ -0,0 +1,8 @@
+-- Optional start/end times for exercise records; duration is derived from them when provided
+ALTER TABLE exercise_records
+    ADD COLUMN started_at TIMESTAMPTZ,
+    ADD COLUMN ended_at TIMESTAMPTZ,
+    ADD CONSTRAINT chk_exercise_records_time_range CHECK (
+        (started_at IS NULL AND ended_at IS NULL)
+        OR (started_at IS NOT NULL AND ended_at IS NOT NULL AND ended_at > started_at)
+    );
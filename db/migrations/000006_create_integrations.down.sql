@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS step_records;
+DROP TABLE IF EXISTS integrations;
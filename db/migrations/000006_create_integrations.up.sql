@@ -0,0 +1,28 @@
+-- Linked third-party accounts used to sync data from external providers
+CREATE TABLE integrations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    provider TEXT NOT NULL, -- e.g., "google_fit"
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    token_expires_at TIMESTAMPTZ NOT NULL,
+    sync_cursor TIMESTAMPTZ, -- End of the last successfully synced time window
+    last_synced_at TIMESTAMPTZ, -- Time of the last sync attempt
+    last_sync_error TEXT, -- Error of the last sync attempt, NULL when it succeeded
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, provider) -- One linked account per provider
+);
+
+-- Daily step counts synced from integrations
+CREATE TABLE step_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    date DATE NOT NULL,
+    steps INTEGER NOT NULL CHECK (steps >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, date) -- One step count per day
+);
@@ -0,0 +1,5 @@
+-- Encrypted tokens cannot be decrypted in SQL, so links are removed and must be relinked.
+DELETE FROM integrations;
+
+ALTER TABLE integrations RENAME COLUMN encrypted_access_token TO access_token;
+ALTER TABLE integrations RENAME COLUMN encrypted_refresh_token TO refresh_token;
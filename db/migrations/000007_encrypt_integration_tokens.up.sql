@@ -0,0 +1,6 @@
+-- Provider tokens are now encrypted by the application (AES-GCM, base64 encoded).
+-- Plaintext tokens cannot be encrypted in SQL, so existing links are removed and must be relinked.
+DELETE FROM integrations;
+
+ALTER TABLE integrations RENAME COLUMN access_token TO encrypted_access_token;
+ALTER TABLE integrations RENAME COLUMN refresh_token TO encrypted_refresh_token;
@@ -0,0 +1,14 @@
+-- User-visible change history of records, written by the application alongside each change
+CREATE TABLE record_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    entity_type TEXT NOT NULL, -- e.g., "body_record", "exercise_record", "diary_entry"
+    entity_id UUID NOT NULL, -- Not a foreign key: history outlives deleted records
+    action TEXT NOT NULL, -- "created", "updated", "imported", "merged" or "deleted"
+    source TEXT NOT NULL, -- "user" for API changes, otherwise the import source, e.g. "fitbit"
+    details TEXT, -- Optional, e.g. the records a merge combined
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_record_changes_entity ON record_changes(user_id, entity_type, entity_id, changed_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_integrations_external_user;
+
+ALTER TABLE integrations DROP COLUMN IF EXISTS external_user_id;
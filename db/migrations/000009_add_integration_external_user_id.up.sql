@@ -0,0 +1,5 @@
+-- The provider's ID of the linked account, used to route push notifications to the linked user.
+-- NULL for providers that do not send notifications.
+ALTER TABLE integrations ADD COLUMN external_user_id TEXT;
+
+CREATE INDEX idx_integrations_external_user ON integrations(provider, external_user_id) WHERE external_user_id IS NOT NULL;
@@ -0,0 +1,32 @@
+-- Push notification tokens of the users' devices
+CREATE TABLE device_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    platform TEXT NOT NULL CHECK (platform IN ('fcm', 'apns')),
+    token TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (platform, token) -- A device belongs to the user who registered it last
+);
+
+CREATE INDEX idx_device_tokens_user ON device_tokens(user_id);
+
+-- Outbox of push notifications, one row per device, delivered by the push worker
+CREATE TABLE push_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_token_id UUID NOT NULL,
+    kind TEXT NOT NULL, -- e.g., "reminder"
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}', -- String key-value pairs passed to the app
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ,
+    CONSTRAINT fk_device_token FOREIGN KEY(device_token_id) REFERENCES device_tokens(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_push_notifications_due ON push_notifications(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_users_last_activity;
+
+DROP TRIGGER IF EXISTS track_user_activity ON record_changes;
+DROP FUNCTION IF EXISTS update_user_last_activity();
+
+DROP TRIGGER IF EXISTS count_body_records ON body_records;
+DROP TRIGGER IF EXISTS count_exercise_records ON exercise_records;
+DROP TRIGGER IF EXISTS count_diary_entries ON diary_entries;
+DROP TRIGGER IF EXISTS count_step_records ON step_records;
+DROP FUNCTION IF EXISTS update_user_record_count();
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS body_record_count,
+    DROP COLUMN IF EXISTS exercise_record_count,
+    DROP COLUMN IF EXISTS diary_entry_count,
+    DROP COLUMN IF EXISTS step_record_count,
+    DROP COLUMN IF EXISTS last_activity_at;
@@ -0,0 +1,64 @@
+-- Denormalized per-user record counts and last activity, so listings and the dashboard
+-- don't have to count records across the record tables
+ALTER TABLE users
+    ADD COLUMN body_record_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN exercise_record_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN diary_entry_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN step_record_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_activity_at TIMESTAMPTZ; -- Last change made by the user through the API; NULL if none
+
+-- Counts are maintained by triggers so that every write path, including imports and sandbox
+-- resets, keeps them in sync. TRUNCATE does not fire them.
+CREATE OR REPLACE FUNCTION update_user_record_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta INTEGER := CASE WHEN TG_OP = 'INSERT' THEN 1 ELSE -1 END;
+    target_user_id UUID := CASE WHEN TG_OP = 'INSERT' THEN NEW.user_id ELSE OLD.user_id END;
+BEGIN
+    CASE TG_TABLE_NAME
+        WHEN 'body_records' THEN
+            UPDATE users SET body_record_count = body_record_count + delta WHERE id = target_user_id;
+        WHEN 'exercise_records' THEN
+            UPDATE users SET exercise_record_count = exercise_record_count + delta WHERE id = target_user_id;
+        WHEN 'diary_entries' THEN
+            UPDATE users SET diary_entry_count = diary_entry_count + delta WHERE id = target_user_id;
+        WHEN 'step_records' THEN
+            UPDATE users SET step_record_count = step_record_count + delta WHERE id = target_user_id;
+    END CASE;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER count_body_records AFTER INSERT OR DELETE ON body_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count();
+CREATE TRIGGER count_exercise_records AFTER INSERT OR DELETE ON exercise_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count();
+CREATE TRIGGER count_diary_entries AFTER INSERT OR DELETE ON diary_entries
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count();
+CREATE TRIGGER count_step_records AFTER INSERT OR DELETE ON step_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count();
+
+-- Activity is taken from the change history, whose changed_at is set by the application.
+-- Imports and syncs of linked accounts are not user activity.
+CREATE OR REPLACE FUNCTION update_user_last_activity()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.source = 'user' THEN
+        UPDATE users SET last_activity_at = GREATEST(last_activity_at, NEW.changed_at) WHERE id = NEW.user_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER track_user_activity AFTER INSERT ON record_changes
+FOR EACH ROW EXECUTE FUNCTION update_user_last_activity();
+
+-- Backfill from the existing data
+UPDATE users u SET
+    body_record_count = (SELECT COUNT(*) FROM body_records WHERE user_id = u.id),
+    exercise_record_count = (SELECT COUNT(*) FROM exercise_records WHERE user_id = u.id),
+    diary_entry_count = (SELECT COUNT(*) FROM diary_entries WHERE user_id = u.id),
+    step_record_count = (SELECT COUNT(*) FROM step_records WHERE user_id = u.id),
+    last_activity_at = (SELECT MAX(changed_at) FROM record_changes WHERE user_id = u.id AND source = 'user');
+
+CREATE INDEX idx_users_last_activity ON users(last_activity_at);
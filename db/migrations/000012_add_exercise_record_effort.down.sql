@@ -0,0 +1,5 @@
+ALTER TABLE exercise_records
+    DROP CONSTRAINT IF EXISTS chk_exercise_records_intensity,
+    DROP CONSTRAINT IF EXISTS chk_exercise_records_rpe,
+    DROP COLUMN IF EXISTS intensity,
+    DROP COLUMN IF EXISTS rpe;
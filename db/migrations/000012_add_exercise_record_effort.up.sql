@@ -0,0 +1,7 @@
+-- Optional effort of a session: the session RPE on the CR-10 scale, and/or a coarse intensity.
+-- Both are nullable, so existing records need no backfill and can be rated later.
+ALTER TABLE exercise_records
+    ADD COLUMN rpe SMALLINT,
+    ADD COLUMN intensity TEXT,
+    ADD CONSTRAINT chk_exercise_records_rpe CHECK (rpe BETWEEN 1 AND 10),
+    ADD CONSTRAINT chk_exercise_records_intensity CHECK (intensity IN ('light', 'moderate', 'vigorous'));
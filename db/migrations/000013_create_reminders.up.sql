@@ -0,0 +1,18 @@
+-- Recurring reminders of users, delivered as push notifications by the reminder scheduler
+CREATE TABLE reminders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    title TEXT NOT NULL,
+    message TEXT NOT NULL,
+    schedule TEXT NOT NULL, -- 5-field cron expression, e.g. "30 7 * * *"
+    timezone TEXT NOT NULL, -- IANA time zone the schedule is evaluated in, e.g. "Asia/Tokyo"
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_fire_at TIMESTAMPTZ, -- NULL when disabled
+    last_fired_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reminders_user ON reminders(user_id);
+CREATE INDEX idx_reminders_due ON reminders(next_fire_at) WHERE enabled;
@@ -0,0 +1,3 @@
+-- Address emails to the user are sent to, taken from the email claim of their token.
+-- NULL until the user authenticates with a token carrying the claim.
+ALTER TABLE users ADD COLUMN email TEXT;
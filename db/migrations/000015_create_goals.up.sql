@@ -0,0 +1,11 @@
+-- Goals of a user, one row per user. Unset goals are NULL.
+CREATE TABLE goals (
+    user_id UUID PRIMARY KEY,
+    target_weight_kg NUMERIC(5, 2),
+    weekly_exercise_minutes INTEGER CHECK (weekly_exercise_minutes > 0),
+    weekly_calories_burned INTEGER CHECK (weekly_calories_burned > 0),
+    weekly_diary_entries INTEGER CHECK (weekly_diary_entries > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+-- Meals logged by users, counted as calories consumed by the calorie balance
+CREATE TABLE meal_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL, -- e.g., "Breakfast", "Chicken salad"
+    calories INTEGER NOT NULL CHECK (calories >= 0),
+    eaten_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_meal_records_user_eaten_at ON meal_records (user_id, eaten_at DESC);
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS streak_body_records ON body_records;
+DROP TRIGGER IF EXISTS streak_diary_entries ON diary_entries;
+DROP FUNCTION IF EXISTS update_user_streaks();
+DROP FUNCTION IF EXISTS log_streak_day(UUID, TEXT, DATE);
+DROP FUNCTION IF EXISTS recompute_streak(UUID, TEXT);
+DROP FUNCTION IF EXISTS award_streak_badges(UUID, TEXT, INTEGER);
+
+DROP TABLE IF EXISTS achievements;
+DROP TABLE IF EXISTS streaks;
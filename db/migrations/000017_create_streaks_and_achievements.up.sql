@@ -0,0 +1,160 @@
+-- Logging streaks per user and kind: 'logging' counts days with a body record or diary entry,
+-- 'body_record' and 'diary_entry' days with a record of that type. Streaks are kept up to date
+-- by triggers, so reads don't scan the record tables. current_length is the streak ending on
+-- last_logged_on; whether it is still running is decided at read time against today.
+CREATE TABLE streaks (
+    user_id UUID NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('logging', 'body_record', 'diary_entry')),
+    current_length INTEGER NOT NULL,
+    longest_length INTEGER NOT NULL,
+    last_logged_on DATE NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, kind),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Badges awarded to users. Badges are kept when the records that earned them are deleted.
+CREATE TABLE achievements (
+    user_id UUID NOT NULL,
+    badge TEXT NOT NULL, -- e.g., "logging_streak_7"
+    awarded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, badge),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Awards the logging streak badges up to longest_length. The thresholds must match the badge
+-- catalog of the achievement service.
+CREATE OR REPLACE FUNCTION award_streak_badges(target_user_id UUID, streak_kind TEXT, longest_length INTEGER)
+RETURNS VOID AS $$
+BEGIN
+    IF streak_kind <> 'logging' THEN
+        RETURN;
+    END IF;
+    INSERT INTO achievements (user_id, badge)
+    SELECT target_user_id, 'logging_streak_' || threshold
+    FROM unnest(ARRAY[1, 7, 30, 100, 365]) AS threshold
+    WHERE threshold <= longest_length
+    ON CONFLICT DO NOTHING;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Recomputes a streak from the user's records, for changes that can't be applied
+-- incrementally: deletions, date changes and backfilled days
+CREATE OR REPLACE FUNCTION recompute_streak(target_user_id UUID, streak_kind TEXT)
+RETURNS VOID AS $$
+DECLARE
+    s RECORD;
+BEGIN
+    WITH days AS (
+        SELECT date AS day FROM body_records
+        WHERE user_id = target_user_id AND streak_kind IN ('logging', 'body_record')
+        UNION
+        SELECT entry_date FROM diary_entries
+        WHERE user_id = target_user_id AND streak_kind IN ('logging', 'diary_entry')
+    ), islands AS (
+        -- Consecutive days share day + their rank, newest first
+        SELECT day, day + (ROW_NUMBER() OVER (ORDER BY day DESC))::int AS island FROM days
+    ), lengths AS (
+        SELECT island, COUNT(*)::int AS length, MAX(day) AS last_day FROM islands GROUP BY island
+    )
+    SELECT
+        (SELECT length FROM lengths ORDER BY last_day DESC LIMIT 1) AS current_length,
+        (SELECT MAX(length) FROM lengths) AS longest_length,
+        (SELECT MAX(last_day) FROM lengths) AS last_logged_on
+    INTO s;
+
+    IF s.last_logged_on IS NULL THEN
+        DELETE FROM streaks WHERE user_id = target_user_id AND kind = streak_kind;
+        RETURN;
+    END IF;
+    INSERT INTO streaks (user_id, kind, current_length, longest_length, last_logged_on)
+    VALUES (target_user_id, streak_kind, s.current_length, s.longest_length, s.last_logged_on)
+    ON CONFLICT (user_id, kind) DO UPDATE
+    SET current_length = EXCLUDED.current_length,
+        longest_length = EXCLUDED.longest_length,
+        last_logged_on = EXCLUDED.last_logged_on,
+        updated_at = CURRENT_TIMESTAMP;
+    PERFORM award_streak_badges(target_user_id, streak_kind, s.longest_length);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Adds a logged day to a streak. Days after the last logged day extend or restart the streak
+-- without reading the records; earlier days fall back to recompute_streak.
+CREATE OR REPLACE FUNCTION log_streak_day(target_user_id UUID, streak_kind TEXT, logged_on DATE)
+RETURNS VOID AS $$
+DECLARE
+    s streaks%ROWTYPE;
+BEGIN
+    SELECT * INTO s FROM streaks WHERE user_id = target_user_id AND kind = streak_kind FOR UPDATE;
+    IF NOT FOUND THEN
+        INSERT INTO streaks (user_id, kind, current_length, longest_length, last_logged_on)
+        VALUES (target_user_id, streak_kind, 1, 1, logged_on)
+        ON CONFLICT (user_id, kind) DO NOTHING;
+        IF NOT FOUND THEN
+            -- Created concurrently; apply the day to that row
+            PERFORM log_streak_day(target_user_id, streak_kind, logged_on);
+            RETURN;
+        END IF;
+        s.longest_length := 1;
+    ELSIF logged_on = s.last_logged_on THEN
+        RETURN;
+    ELSIF logged_on < s.last_logged_on THEN
+        PERFORM recompute_streak(target_user_id, streak_kind);
+        RETURN;
+    ELSE
+        IF logged_on = s.last_logged_on + 1 THEN
+            s.current_length := s.current_length + 1;
+        ELSE
+            s.current_length := 1;
+        END IF;
+        s.longest_length := GREATEST(s.longest_length, s.current_length);
+        UPDATE streaks
+        SET current_length = s.current_length,
+            longest_length = s.longest_length,
+            last_logged_on = logged_on,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = target_user_id AND kind = streak_kind;
+    END IF;
+    PERFORM award_streak_badges(target_user_id, streak_kind, s.longest_length);
+END;
+$$ LANGUAGE plpgsql;
+
+-- Maintains the streaks on every write path, including imports and sandbox resets. The trigger
+-- arguments are the streak kind of the table and the name of its date column.
+CREATE OR REPLACE FUNCTION update_user_streaks()
+RETURNS TRIGGER AS $$
+DECLARE
+    own_kind TEXT := TG_ARGV[0];
+    new_day DATE;
+    old_day DATE;
+BEGIN
+    IF TG_OP <> 'DELETE' THEN
+        new_day := (to_jsonb(NEW) ->> TG_ARGV[1])::date;
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        old_day := (to_jsonb(OLD) ->> TG_ARGV[1])::date;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        PERFORM log_streak_day(NEW.user_id, own_kind, new_day);
+        PERFORM log_streak_day(NEW.user_id, 'logging', new_day);
+    ELSIF TG_OP = 'DELETE' OR old_day IS DISTINCT FROM new_day THEN
+        -- Records deleted along with their user have no streak to keep
+        IF NOT EXISTS (SELECT 1 FROM users WHERE id = OLD.user_id) THEN
+            RETURN NULL;
+        END IF;
+        PERFORM recompute_streak(OLD.user_id, own_kind);
+        PERFORM recompute_streak(OLD.user_id, 'logging');
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER streak_body_records AFTER INSERT OR UPDATE OF date OR DELETE ON body_records
+FOR EACH ROW EXECUTE FUNCTION update_user_streaks('body_record', 'date');
+CREATE TRIGGER streak_diary_entries AFTER INSERT OR UPDATE OF entry_date OR DELETE ON diary_entries
+FOR EACH ROW EXECUTE FUNCTION update_user_streaks('diary_entry', 'entry_date');
+
+-- Backfill from the existing data
+SELECT recompute_streak(u.id, k.kind)
+FROM users u CROSS JOIN (VALUES ('logging'), ('body_record'), ('diary_entry')) AS k(kind);
@@ -0,0 +1,16 @@
+-- Files attached to records, stored in the attachment store under storage_key. Attachments are
+-- pending until the client has uploaded the file and the upload is verified.
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    body_record_id UUID NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    status TEXT NOT NULL CHECK (status IN ('pending', 'ready')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_body_record FOREIGN KEY(body_record_id) REFERENCES body_records(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_attachments_body_record ON attachments (body_record_id, created_at);
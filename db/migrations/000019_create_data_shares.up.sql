@@ -0,0 +1,23 @@
+-- Read access to selected record types of a user (the owner), granted to another user (the
+-- grantee, e.g. a coach or doctor) between starts_at and expires_at, unless revoked earlier.
+CREATE TABLE data_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL,
+    grantee_id UUID NOT NULL,
+    record_types TEXT[] NOT NULL CHECK (
+        cardinality(record_types) > 0
+        AND record_types <@ ARRAY['body_records', 'exercise_records', 'diary_entries', 'meal_records']
+    ),
+    starts_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_owner FOREIGN KEY(owner_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_grantee FOREIGN KEY(grantee_id) REFERENCES users(id) ON DELETE CASCADE,
+    CHECK (owner_id <> grantee_id),
+    CHECK (expires_at > starts_at)
+);
+-- Access checks look up the shares of an owner with a grantee
+CREATE INDEX idx_data_shares_grantee_owner ON data_shares (grantee_id, owner_id);
+CREATE INDEX idx_data_shares_owner ON data_shares (owner_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Suspended users are rejected by the auth interceptor; NULL if the account is not suspended
+ALTER TABLE users
+    ADD COLUMN suspended_at TIMESTAMPTZ;
@@ -0,0 +1,11 @@
+-- Daily read counts of columns, for the most-read columns of the admin stats. Counting per day
+-- keeps one row per column and day however often the column is read.
+CREATE TABLE column_reads (
+    column_id UUID NOT NULL,
+    day DATE NOT NULL, -- UTC
+    read_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (column_id, day),
+    CONSTRAINT fk_column FOREIGN KEY(column_id) REFERENCES columns(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_column_reads_day ON column_reads(day);
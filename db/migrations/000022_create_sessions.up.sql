@@ -0,0 +1,29 @@
+-- Sessions of signed-in devices. Access tokens name their session in the "sid" claim, so
+-- revoking a session rejects its access tokens immediately.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    device_name TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    roles TEXT[] NOT NULL DEFAULT '{}', -- Roles of the identity provider token the session was created with
+    expires_at TIMESTAMPTZ NOT NULL, -- Expiry of the latest refresh token; extended on every refresh
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL, -- Creation or last refresh
+    revoked_at TIMESTAMPTZ,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id, last_used_at DESC);
+
+-- Refresh tokens are stored as SHA-256 hashes and can be used once. Used tokens are kept so
+-- that presenting one again, a sign of a stolen token, revokes the whole session.
+CREATE TABLE refresh_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    session_id UUID NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    CONSTRAINT fk_session FOREIGN KEY(session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_session ON refresh_tokens(session_id);
@@ -0,0 +1,4 @@
+-- Scopes of the identity provider token the session was created with, carried over to the
+-- session's access tokens
+ALTER TABLE sessions
+    ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
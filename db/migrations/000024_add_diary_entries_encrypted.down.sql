@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_diary_entries_plaintext;
+
+ALTER TABLE diary_entries
+    DROP COLUMN IF EXISTS encrypted;
@@ -0,0 +1,7 @@
+-- Title and content of encrypted entries are ciphertexts of the data key in the encryption
+-- config. Entries written before encryption was enabled stay plaintext until the
+-- encrypt-diary command encrypts them.
+ALTER TABLE diary_entries
+    ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_diary_entries_plaintext ON diary_entries (id) WHERE NOT encrypted;
@@ -0,0 +1,8 @@
+-- Daily request counts of users, enforced against the daily request quota
+CREATE TABLE api_usage (
+    user_id UUID NOT NULL,
+    day DATE NOT NULL, -- UTC
+    request_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,83 @@
+-- Move the records back into unpartitioned tables, as created by the earlier migrations
+ALTER TABLE attachments DROP CONSTRAINT fk_body_record;
+
+ALTER TABLE body_records RENAME TO body_records_partitioned;
+ALTER TABLE exercise_records RENAME TO exercise_records_partitioned;
+ALTER INDEX body_records_pkey RENAME TO body_records_partitioned_pkey;
+ALTER INDEX body_records_user_id_date_key RENAME TO body_records_partitioned_user_id_date_key;
+ALTER INDEX exercise_records_pkey RENAME TO exercise_records_partitioned_pkey;
+DROP INDEX idx_exercise_records_user_recorded_at;
+
+CREATE TABLE body_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    date DATE NOT NULL, -- Date of the record
+    weight_kg NUMERIC(5, 2), -- Weight in kilograms, e.g., 75.50
+    body_fat_percentage NUMERIC(4, 2), -- Body fat percentage, e.g., 15.25
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, date) -- Allow only one record per user per day
+);
+CREATE INDEX idx_body_records_user_date ON body_records (user_id, date DESC);
+
+CREATE TABLE exercise_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    exercise_name TEXT NOT NULL, -- e.g., "Running", "Weight Lifting"
+    duration_minutes INTEGER, -- Duration in minutes
+    calories_burned INTEGER, -- Estimated calories burned
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP, -- When the exercise was performed/logged
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    rpe SMALLINT,
+    intensity TEXT,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT chk_exercise_records_time_range CHECK (
+        (started_at IS NULL AND ended_at IS NULL)
+        OR (started_at IS NOT NULL AND ended_at IS NOT NULL AND ended_at > started_at)
+    ),
+    CONSTRAINT chk_exercise_records_rpe CHECK (rpe BETWEEN 1 AND 10),
+    CONSTRAINT chk_exercise_records_intensity CHECK (intensity IN ('light', 'moderate', 'vigorous'))
+);
+CREATE INDEX idx_exercise_records_user_recorded_at ON exercise_records (user_id, recorded_at DESC);
+
+INSERT INTO body_records SELECT * FROM body_records_partitioned;
+INSERT INTO exercise_records SELECT * FROM exercise_records_partitioned;
+DROP TABLE body_records_partitioned;
+DROP TABLE exercise_records_partitioned;
+
+DROP FUNCTION IF EXISTS create_record_partitions(TEXT, DATE, DATE);
+DROP FUNCTION IF EXISTS create_record_partition(TEXT, DATE);
+
+ALTER TABLE attachments DROP COLUMN body_record_date;
+ALTER TABLE attachments ADD CONSTRAINT fk_body_record FOREIGN KEY(body_record_id) REFERENCES body_records(id) ON DELETE CASCADE;
+
+CREATE OR REPLACE FUNCTION update_user_record_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta INTEGER := CASE WHEN TG_OP = 'INSERT' THEN 1 ELSE -1 END;
+    target_user_id UUID := CASE WHEN TG_OP = 'INSERT' THEN NEW.user_id ELSE OLD.user_id END;
+BEGIN
+    CASE TG_TABLE_NAME
+        WHEN 'body_records' THEN
+            UPDATE users SET body_record_count = body_record_count + delta WHERE id = target_user_id;
+        WHEN 'exercise_records' THEN
+            UPDATE users SET exercise_record_count = exercise_record_count + delta WHERE id = target_user_id;
+        WHEN 'diary_entries' THEN
+            UPDATE users SET diary_entry_count = diary_entry_count + delta WHERE id = target_user_id;
+        WHEN 'step_records' THEN
+            UPDATE users SET step_record_count = step_record_count + delta WHERE id = target_user_id;
+    END CASE;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER count_body_records AFTER INSERT OR DELETE ON body_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count();
+CREATE TRIGGER count_exercise_records AFTER INSERT OR DELETE ON exercise_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count();
+CREATE TRIGGER streak_body_records AFTER INSERT OR UPDATE OF date OR DELETE ON body_records
+FOR EACH ROW EXECUTE FUNCTION update_user_streaks('body_record', 'date');
@@ -0,0 +1,170 @@
+-- Range-partition the record tables by month (UTC), so queries on recent records only touch
+-- recent partitions and old months can be detached and archived as whole tables. Partitions are
+-- named <table>_YYYY_MM; create_record_partitions creates them ahead of time. Records outside
+-- the monthly partitions, e.g. imports older than the oldest partition, go to <table>_default.
+
+-- Partitioned tables can't carry unique constraints without the partition key, so attachments
+-- reference their body record by ID and date
+ALTER TABLE attachments DROP CONSTRAINT fk_body_record;
+ALTER TABLE attachments ADD COLUMN body_record_date DATE;
+UPDATE attachments a SET body_record_date = b.date FROM body_records b WHERE b.id = a.body_record_id;
+ALTER TABLE attachments ALTER COLUMN body_record_date SET NOT NULL;
+
+ALTER TABLE body_records RENAME TO body_records_unpartitioned;
+ALTER INDEX body_records_pkey RENAME TO body_records_unpartitioned_pkey;
+ALTER INDEX body_records_user_id_date_key RENAME TO body_records_unpartitioned_user_id_date_key;
+DROP INDEX idx_body_records_user_date;
+
+ALTER TABLE exercise_records RENAME TO exercise_records_unpartitioned;
+ALTER INDEX exercise_records_pkey RENAME TO exercise_records_unpartitioned_pkey;
+DROP INDEX idx_exercise_records_user_recorded_at;
+
+-- Lookups by ID can't be pruned to a partition, so the primary keys lead with the ID to keep
+-- them an index probe per partition. The (user_id, date) unique index also serves the listings
+-- by user and date, so body records need no separate index for them.
+CREATE TABLE body_records (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    date DATE NOT NULL, -- Date of the record
+    weight_kg NUMERIC(5, 2), -- Weight in kilograms, e.g., 75.50
+    body_fat_percentage NUMERIC(4, 2), -- Body fat percentage, e.g., 15.25
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id, date),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, date) -- Allow only one record per user per day
+) PARTITION BY RANGE (date);
+CREATE TABLE body_records_default PARTITION OF body_records DEFAULT;
+
+CREATE TABLE exercise_records (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    exercise_name TEXT NOT NULL, -- e.g., "Running", "Weight Lifting"
+    duration_minutes INTEGER, -- Duration in minutes
+    calories_burned INTEGER, -- Estimated calories burned
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP, -- When the exercise was performed/logged
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    rpe SMALLINT,
+    intensity TEXT,
+    PRIMARY KEY (id, recorded_at),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT chk_exercise_records_time_range CHECK (
+        (started_at IS NULL AND ended_at IS NULL)
+        OR (started_at IS NOT NULL AND ended_at IS NOT NULL AND ended_at > started_at)
+    ),
+    CONSTRAINT chk_exercise_records_rpe CHECK (rpe BETWEEN 1 AND 10),
+    CONSTRAINT chk_exercise_records_intensity CHECK (intensity IN ('light', 'moderate', 'vigorous'))
+) PARTITION BY RANGE (recorded_at);
+CREATE TABLE exercise_records_default PARTITION OF exercise_records DEFAULT;
+CREATE INDEX idx_exercise_records_user_recorded_at ON exercise_records (user_id, recorded_at DESC);
+
+-- Creates the partition of parent for the UTC month of month, returning its name, or NULL if it
+-- exists. Months with rows in the default partition are skipped with a warning: the rows would
+-- have to be moved, which would fire the record triggers and cascade to their attachments.
+CREATE OR REPLACE FUNCTION create_record_partition(parent TEXT, month DATE)
+RETURNS TEXT AS $$
+DECLARE
+    first_day DATE := date_trunc('month', month)::date;
+    partition_name TEXT := parent || '_' || to_char(first_day, 'YYYY_MM');
+    key_column TEXT;
+    lower_bound TEXT;
+    upper_bound TEXT;
+    has_default_rows BOOLEAN;
+BEGIN
+    CASE parent
+        WHEN 'body_records' THEN
+            key_column := 'date';
+            lower_bound := first_day::text;
+            upper_bound := (first_day + INTERVAL '1 month')::date::text;
+        WHEN 'exercise_records' THEN
+            key_column := 'recorded_at';
+            lower_bound := (first_day::timestamp AT TIME ZONE 'UTC')::text;
+            upper_bound := ((first_day + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC')::text;
+    END CASE;
+
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN NULL;
+    END IF;
+    EXECUTE format('SELECT EXISTS (SELECT 1 FROM %I WHERE %I >= %L AND %I < %L)',
+        parent || '_default', key_column, lower_bound, key_column, upper_bound)
+    INTO has_default_rows;
+    IF has_default_rows THEN
+        RAISE WARNING 'not creating partition %: its rows are in the default partition', partition_name;
+        RETURN NULL;
+    END IF;
+    EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+        partition_name, parent, lower_bound, upper_bound);
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Creates the missing partitions of parent for the months from from_month through
+-- through_month, returning the names of the created partitions. Concurrent calls, e.g. of
+-- several servers starting at once, are serialized.
+CREATE OR REPLACE FUNCTION create_record_partitions(parent TEXT, from_month DATE, through_month DATE)
+RETURNS TEXT[] AS $$
+DECLARE
+    month DATE := date_trunc('month', from_month)::date;
+    partition_name TEXT;
+    created TEXT[] := ARRAY[]::TEXT[];
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('create_record_partitions'));
+    WHILE month <= through_month LOOP
+        partition_name := create_record_partition(parent, month);
+        IF partition_name IS NOT NULL THEN
+            created := created || partition_name;
+        END IF;
+        month := (month + INTERVAL '1 month')::date;
+    END LOOP;
+    RETURN created;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Partitions for the existing records and the next three months
+SELECT create_record_partitions('body_records',
+    LEAST((SELECT MIN(date) FROM body_records_unpartitioned), CURRENT_DATE),
+    (CURRENT_DATE + INTERVAL '3 months')::date);
+SELECT create_record_partitions('exercise_records',
+    LEAST((SELECT MIN(recorded_at AT TIME ZONE 'UTC')::date FROM exercise_records_unpartitioned), CURRENT_DATE),
+    (CURRENT_DATE + INTERVAL '3 months')::date);
+
+INSERT INTO body_records SELECT * FROM body_records_unpartitioned;
+INSERT INTO exercise_records SELECT * FROM exercise_records_unpartitioned;
+DROP TABLE body_records_unpartitioned;
+DROP TABLE exercise_records_unpartitioned;
+
+ALTER TABLE attachments ADD CONSTRAINT fk_body_record FOREIGN KEY(body_record_id, body_record_date)
+    REFERENCES body_records(id, date) ON DELETE CASCADE;
+
+-- Triggers of partitioned tables fire with the name of the partition as TG_TABLE_NAME, so the
+-- record tables pass their name as the trigger argument
+CREATE OR REPLACE FUNCTION update_user_record_count()
+RETURNS TRIGGER AS $$
+DECLARE
+    delta INTEGER := CASE WHEN TG_OP = 'INSERT' THEN 1 ELSE -1 END;
+    target_user_id UUID := CASE WHEN TG_OP = 'INSERT' THEN NEW.user_id ELSE OLD.user_id END;
+BEGIN
+    CASE COALESCE(TG_ARGV[0], TG_TABLE_NAME)
+        WHEN 'body_records' THEN
+            UPDATE users SET body_record_count = body_record_count + delta WHERE id = target_user_id;
+        WHEN 'exercise_records' THEN
+            UPDATE users SET exercise_record_count = exercise_record_count + delta WHERE id = target_user_id;
+        WHEN 'diary_entries' THEN
+            UPDATE users SET diary_entry_count = diary_entry_count + delta WHERE id = target_user_id;
+        WHEN 'step_records' THEN
+            UPDATE users SET step_record_count = step_record_count + delta WHERE id = target_user_id;
+    END CASE;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Created after copying the records, which are already counted and in the streaks
+CREATE TRIGGER count_body_records AFTER INSERT OR DELETE ON body_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count('body_records');
+CREATE TRIGGER count_exercise_records AFTER INSERT OR DELETE ON exercise_records
+FOR EACH ROW EXECUTE FUNCTION update_user_record_count('exercise_records');
+CREATE TRIGGER streak_body_records AFTER INSERT OR UPDATE OF date OR DELETE ON body_records
+FOR EACH ROW EXECUTE FUNCTION update_user_streaks('body_record', 'date');
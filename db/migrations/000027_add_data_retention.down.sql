@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_record_changes_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS retention_opt_out;
@@ -0,0 +1,5 @@
+-- Users who opt out of the retention policy keep their full change history and records
+ALTER TABLE users ADD COLUMN retention_opt_out BOOLEAN NOT NULL DEFAULT false;
+
+-- The retention job purges changes by age across users
+CREATE INDEX idx_record_changes_changed_at ON record_changes (changed_at);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS diary_entry_revisions;
@@ -0,0 +1,17 @@
+-- Previous versions of diary entries, written on every update so overwritten entries can be
+-- restored. Revisions keep the title and content as stored, so revisions of encrypted entries
+-- are encrypted too.
+CREATE TABLE diary_entry_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    diary_entry_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    title TEXT,
+    content TEXT NOT NULL,
+    encrypted BOOLEAN NOT NULL,
+    edited_at TIMESTAMPTZ NOT NULL, -- When this version was saved, the entry's updated_at at the time
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP, -- When this version was replaced
+    CONSTRAINT fk_diary_entry FOREIGN KEY(diary_entry_id) REFERENCES diary_entries(id) ON DELETE CASCADE,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_diary_entry_revisions_entry_created_at ON diary_entry_revisions (diary_entry_id, created_at DESC);
+CREATE INDEX idx_diary_entry_revisions_plaintext ON diary_entry_revisions (id) WHERE NOT encrypted;
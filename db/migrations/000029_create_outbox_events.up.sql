@@ -0,0 +1,18 @@
+-- Outbox of domain events, written in the transaction of the change they describe and
+-- published by the outbox relay at least once
+CREATE TABLE outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    event_type TEXT NOT NULL, -- e.g., "body_record.created"
+    payload JSONB NOT NULL, -- Event data; IDs only, never record contents
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'published', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMPTZ,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_outbox_events_due ON outbox_events(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_outbox_events_published_at ON outbox_events(published_at) WHERE status = 'published';
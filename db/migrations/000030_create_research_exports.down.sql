@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS research_exports;
+ALTER TABLE users DROP COLUMN IF EXISTS research_opt_in;
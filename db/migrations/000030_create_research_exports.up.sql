@@ -0,0 +1,25 @@
+-- Users who opt in donate their data to research exports, de-identified and aggregated
+ALTER TABLE users ADD COLUMN research_opt_in BOOLEAN NOT NULL DEFAULT false;
+
+-- Research exports requested by admins, written to the attachment store by the research export job
+CREATE TABLE research_exports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    requested_by UUID NOT NULL, -- Admin who requested the export
+    start_date DATE NOT NULL, -- First day of the exported data
+    end_date DATE NOT NULL, -- Last day of the exported data
+    min_group_size INTEGER NOT NULL CHECK (min_group_size >= 2), -- k: rows aggregate the data of at least this many users
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL, -- Running exports are leased until then, so they are retried if their job stopped
+    contributors INTEGER NOT NULL DEFAULT 0, -- Opted-in users with data in the window
+    suppressed_groups INTEGER NOT NULL DEFAULT 0, -- Groups left out for aggregating fewer than min_group_size users
+    datasets JSONB NOT NULL DEFAULT '[]', -- Names, storage keys and row counts of the written datasets
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMPTZ,
+    CONSTRAINT fk_requested_by FOREIGN KEY(requested_by) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT chk_research_exports_window CHECK (end_date >= start_date)
+);
+
+CREATE INDEX idx_research_exports_created_at ON research_exports(created_at);
+CREATE INDEX idx_research_exports_due ON research_exports(next_attempt_at) WHERE status IN ('pending', 'running');
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS height_unit;
+ALTER TABLE users DROP COLUMN IF EXISTS weight_unit;
@@ -0,0 +1,3 @@
+-- Units the user's weights and heights are shown in; values are always stored in kg and cm
+ALTER TABLE users ADD COLUMN weight_unit TEXT NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb'));
+ALTER TABLE users ADD COLUMN height_unit TEXT NOT NULL DEFAULT 'cm' CHECK (height_unit IN ('cm', 'in'));
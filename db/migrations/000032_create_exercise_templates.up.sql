@@ -0,0 +1,16 @@
+-- Exercises users log often, from which exercise records are created with one call
+CREATE TABLE exercise_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL, -- Exercise name of the records logged from the template
+    duration_minutes INTEGER CHECK (duration_minutes BETWEEN 1 AND 1440),
+    calories_burned INTEGER CHECK (calories_burned BETWEEN 0 AND 10000),
+    intensity TEXT CHECK (intensity IN ('light', 'moderate', 'vigorous')),
+    favorite BOOLEAN NOT NULL DEFAULT false,
+    use_count INTEGER NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_exercise_templates_user_name ON exercise_templates (user_id, lower(name));
@@ -0,0 +1,4 @@
+ALTER TABLE exercise_records
+    DROP COLUMN IF EXISTS workout_position,
+    DROP COLUMN IF EXISTS workout_session_id;
+DROP TABLE IF EXISTS workout_sessions;
@@ -0,0 +1,17 @@
+-- Workout sessions group exercise records performed together, e.g. a gym session
+CREATE TABLE workout_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL, -- Recorded time of the session's exercise records
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_workout_sessions_user_started_at ON workout_sessions (user_id, started_at DESC);
+
+-- Deleting a session keeps its records
+ALTER TABLE exercise_records
+    ADD COLUMN workout_session_id UUID REFERENCES workout_sessions(id) ON DELETE SET NULL,
+    ADD COLUMN workout_position SMALLINT; -- Order of the record within its session
+CREATE INDEX idx_exercise_records_workout_session_id ON exercise_records (workout_session_id) WHERE workout_session_id IS NOT NULL;
@@ -0,0 +1,15 @@
+-- GPS routes of outdoor exercise records. Partitioned tables can't carry unique constraints
+-- without the partition key, so routes reference their record by ID and recorded time.
+CREATE TABLE exercise_routes (
+    exercise_record_id UUID PRIMARY KEY,
+    exercise_recorded_at TIMESTAMPTZ NOT NULL,
+    user_id UUID NOT NULL,
+    polyline BYTEA NOT NULL, -- gzip-compressed encoded polyline
+    point_count INTEGER NOT NULL CHECK (point_count >= 2),
+    distance_meters DOUBLE PRECISION NOT NULL CHECK (distance_meters >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_exercise_record FOREIGN KEY(exercise_record_id, exercise_recorded_at)
+        REFERENCES exercise_records(id, recorded_at) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,12 @@
+-- Hourly step counts by device, written in bulk by the step service. Devices worn together
+-- count the same steps, so an hour's steps are the highest count of its sources.
+CREATE TABLE step_buckets (
+    user_id UUID NOT NULL,
+    hour TIMESTAMPTZ NOT NULL, -- Start of the hour
+    source TEXT NOT NULL, -- The counting device
+    steps INTEGER NOT NULL CHECK (steps >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, hour, source),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,57 @@
+-- Heart rate samples, written in bulk by the heart rate service. Devices sample every few
+-- seconds, so the samples are range-partitioned by UTC month on measured_at like the record
+-- tables, and charts read them downsampled to per-minute, per-hour or per-day averages.
+CREATE TABLE heart_rate_samples (
+    user_id UUID NOT NULL,
+    measured_at TIMESTAMPTZ NOT NULL,
+    source TEXT NOT NULL, -- The measuring device
+    bpm SMALLINT NOT NULL CHECK (bpm BETWEEN 20 AND 300),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, measured_at, source),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+) PARTITION BY RANGE (measured_at);
+CREATE TABLE heart_rate_samples_default PARTITION OF heart_rate_samples DEFAULT;
+
+CREATE OR REPLACE FUNCTION create_record_partition(parent TEXT, month DATE)
+RETURNS TEXT AS $$
+DECLARE
+    first_day DATE := date_trunc('month', month)::date;
+    partition_name TEXT := parent || '_' || to_char(first_day, 'YYYY_MM');
+    key_column TEXT;
+    lower_bound TEXT;
+    upper_bound TEXT;
+    has_default_rows BOOLEAN;
+BEGIN
+    CASE parent
+        WHEN 'body_records' THEN
+            key_column := 'date';
+            lower_bound := first_day::text;
+            upper_bound := (first_day + INTERVAL '1 month')::date::text;
+        WHEN 'exercise_records' THEN
+            key_column := 'recorded_at';
+            lower_bound := (first_day::timestamp AT TIME ZONE 'UTC')::text;
+            upper_bound := ((first_day + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC')::text;
+        WHEN 'heart_rate_samples' THEN
+            key_column := 'measured_at';
+            lower_bound := (first_day::timestamp AT TIME ZONE 'UTC')::text;
+            upper_bound := ((first_day + INTERVAL '1 month')::timestamp AT TIME ZONE 'UTC')::text;
+    END CASE;
+
+    IF to_regclass(partition_name) IS NOT NULL THEN
+        RETURN NULL;
+    END IF;
+    EXECUTE format('SELECT EXISTS (SELECT 1 FROM %I WHERE %I >= %L AND %I < %L)',
+        parent || '_default', key_column, lower_bound, key_column, upper_bound)
+    INTO has_default_rows;
+    IF has_default_rows THEN
+        RAISE WARNING 'not creating partition %: its rows are in the default partition', partition_name;
+        RETURN NULL;
+    END IF;
+    EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+        partition_name, parent, lower_bound, upper_bound);
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Partitions for the current and the next three months
+SELECT create_record_partitions('heart_rate_samples', CURRENT_DATE, (CURRENT_DATE + INTERVAL '3 months')::date);
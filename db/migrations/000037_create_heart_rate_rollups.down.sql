@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS heart_rate_pending_hours;
+DROP TABLE IF EXISTS heart_rate_daily;
+DROP TABLE IF EXISTS heart_rate_hourly;
@@ -0,0 +1,37 @@
+-- Hourly and daily rollups of the heart rate samples, so charts over weeks or months read a
+-- row per hour or day rather than every sample. Rollups keep the sum rather than the average
+-- of their samples, so the daily ones are rolled up from the hourly ones.
+CREATE TABLE heart_rate_hourly (
+    user_id UUID NOT NULL,
+    hour TIMESTAMPTZ NOT NULL, -- Start of the UTC hour
+    bpm_sum BIGINT NOT NULL,
+    min_bpm SMALLINT NOT NULL,
+    max_bpm SMALLINT NOT NULL,
+    sample_count INTEGER NOT NULL,
+    PRIMARY KEY (user_id, hour),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE heart_rate_daily (
+    user_id UUID NOT NULL,
+    day DATE NOT NULL, -- UTC day
+    bpm_sum BIGINT NOT NULL,
+    min_bpm SMALLINT NOT NULL,
+    max_bpm SMALLINT NOT NULL,
+    sample_count INTEGER NOT NULL,
+    PRIMARY KEY (user_id, day),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Hours with samples inserted since they were last rolled up, queued by the inserts and
+-- claimed by the rollup job
+CREATE TABLE heart_rate_pending_hours (
+    user_id UUID NOT NULL,
+    hour TIMESTAMPTZ NOT NULL, -- Start of the UTC hour
+    PRIMARY KEY (user_id, hour),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Queue the hours of the existing samples
+INSERT INTO heart_rate_pending_hours (user_id, hour)
+SELECT DISTINCT user_id, date_trunc('hour', measured_at, 'UTC') FROM heart_rate_samples;
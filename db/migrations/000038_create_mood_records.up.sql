@@ -0,0 +1,13 @@
+-- Moods logged by users, with their energy level and any symptoms they noticed
+CREATE TABLE mood_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    mood_score SMALLINT NOT NULL CHECK (mood_score BETWEEN 1 AND 10), -- 1 (very low) to 10 (very good)
+    energy_level SMALLINT NOT NULL CHECK (energy_level BETWEEN 1 AND 10), -- 1 (exhausted) to 10 (energetic)
+    symptoms TEXT[] NOT NULL DEFAULT '{}', -- Free-form, e.g., "headache", "anxious"
+    recorded_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_mood_records_user_recorded_at ON mood_records (user_id, recorded_at DESC);
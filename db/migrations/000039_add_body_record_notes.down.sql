@@ -0,0 +1 @@
+ALTER TABLE body_records DROP COLUMN IF EXISTS note;
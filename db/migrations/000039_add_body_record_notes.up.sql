@@ -0,0 +1,2 @@
+-- Optional notes annotating a weigh-in, e.g., "after flu", "new scale"
+ALTER TABLE body_records ADD COLUMN note TEXT;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS diary_share_links;
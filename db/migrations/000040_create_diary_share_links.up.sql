@@ -0,0 +1,14 @@
+-- Read-only links to a diary entry that anyone holding their signed token may open until they
+-- expire or are revoked, e.g. to show an entry to a clinician without an account
+CREATE TABLE diary_share_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    diary_entry_id UUID NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_diary_entry FOREIGN KEY(diary_entry_id) REFERENCES diary_entries(id) ON DELETE CASCADE,
+    CHECK (expires_at > created_at)
+);
+CREATE INDEX idx_diary_share_links_diary_entry ON diary_share_links (diary_entry_id);
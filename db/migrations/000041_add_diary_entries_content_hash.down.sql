@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_diary_entries_user_content_hash;
+
+ALTER TABLE diary_entries
+    DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,6 @@
+-- Keyed hash of the title and content an entry was created with, so retried creates can be
+-- detected without decrypting entries. It is cleared when the entry is updated.
+ALTER TABLE diary_entries
+    ADD COLUMN content_hash BYTEA;
+
+CREATE INDEX idx_diary_entries_user_content_hash ON diary_entries (user_id, content_hash) WHERE content_hash IS NOT NULL;
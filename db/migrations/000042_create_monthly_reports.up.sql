@@ -0,0 +1,17 @@
+-- Monthly PDF reports requested by users, written to the attachment store by the report job
+CREATE TABLE monthly_reports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    month DATE NOT NULL CHECK (extract(day FROM month) = 1), -- First day of the reported month
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL, -- Running reports are leased until then, so they are retried if their job stopped
+    storage_key TEXT, -- Key of the PDF in the attachment store, once completed
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMPTZ,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_monthly_reports_user ON monthly_reports(user_id, created_at DESC);
+CREATE INDEX idx_monthly_reports_due ON monthly_reports(next_attempt_at) WHERE status IN ('pending', 'running');
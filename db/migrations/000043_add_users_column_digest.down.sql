@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_column_digest_due;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS column_digest_sent_at,
+    DROP COLUMN IF EXISTS column_digest_categories,
+    DROP COLUMN IF EXISTS column_digest_opt_in;
@@ -0,0 +1,7 @@
+-- Users who opt in are emailed a weekly digest of the columns published in their categories
+ALTER TABLE users
+    ADD COLUMN column_digest_opt_in BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN column_digest_categories TEXT[] NOT NULL DEFAULT '{}', -- Lowercase; empty for all categories
+    ADD COLUMN column_digest_sent_at TIMESTAMPTZ; -- When the digest job last emailed the user
+
+CREATE INDEX idx_users_column_digest_due ON users (column_digest_sent_at) WHERE column_digest_opt_in;
@@ -0,0 +1,27 @@
+-- Recipes users make of food items, from which meal records are logged with one call
+CREATE TABLE recipes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL, -- Name of the meal records logged from the recipe
+    servings INTEGER NOT NULL CHECK (servings BETWEEN 1 AND 100), -- Servings the ingredients make
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_recipes_user_name ON recipes (user_id, lower(name));
+
+-- Food items of a recipe with their nutrition values per 100 g; updates replace them all
+CREATE TABLE recipe_ingredients (
+    recipe_id UUID NOT NULL,
+    position INTEGER NOT NULL, -- Order of the ingredient in the recipe, from 0
+    name TEXT NOT NULL,
+    quantity_g DOUBLE PRECISION NOT NULL CHECK (quantity_g > 0),
+    calories_kcal DOUBLE PRECISION NOT NULL CHECK (calories_kcal >= 0),
+    protein_g DOUBLE PRECISION CHECK (protein_g >= 0),
+    carbohydrates_g DOUBLE PRECISION CHECK (carbohydrates_g >= 0),
+    fat_g DOUBLE PRECISION CHECK (fat_g >= 0),
+    food_source TEXT, -- Food database the values were looked up in, with food_id
+    food_id TEXT,
+    PRIMARY KEY (recipe_id, position),
+    CONSTRAINT fk_recipe FOREIGN KEY(recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+UPDATE data_shares SET record_types = array_remove(record_types, 'meal_plans');
+DELETE FROM data_shares WHERE cardinality(record_types) = 0;
+ALTER TABLE data_shares DROP CONSTRAINT data_shares_record_types_check;
+ALTER TABLE data_shares ADD CONSTRAINT data_shares_record_types_check CHECK (
+    cardinality(record_types) > 0
+    AND record_types <@ ARRAY['body_records', 'exercise_records', 'diary_entries', 'meal_records']
+);
+
+DROP TABLE IF EXISTS planned_meals;
@@ -0,0 +1,21 @@
+-- Meals planned for a day, by the user or by a coach the user shares their meal plans with
+CREATE TABLE planned_meals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    date DATE NOT NULL, -- UTC day the meal is planned for
+    name TEXT NOT NULL,
+    calories INTEGER NOT NULL CHECK (calories BETWEEN 0 AND 10000),
+    planned_by UUID, -- User who planned the meal: the user or a coach; NULL once deleted
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_planned_by FOREIGN KEY(planned_by) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX idx_planned_meals_user_date ON planned_meals (user_id, date);
+
+-- Meal plans can be shared; unlike other record types, grantees may also edit them
+ALTER TABLE data_shares DROP CONSTRAINT data_shares_record_types_check;
+ALTER TABLE data_shares ADD CONSTRAINT data_shares_record_types_check CHECK (
+    cardinality(record_types) > 0
+    AND record_types <@ ARRAY['body_records', 'exercise_records', 'diary_entries', 'meal_records', 'meal_plans']
+);
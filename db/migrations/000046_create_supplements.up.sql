@@ -0,0 +1,27 @@
+-- Supplements users take, optionally reminded of by a reminder kept in sync with the supplement
+CREATE TABLE supplements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    dose TEXT NOT NULL, -- Free text usual dose, e.g. "1000 IU" or "2 capsules"
+    reminder_id UUID, -- Reminder to take the supplement; NULL when none, or once deleted
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_reminder FOREIGN KEY(reminder_id) REFERENCES reminders(id) ON DELETE SET NULL
+);
+CREATE UNIQUE INDEX idx_supplements_user_name ON supplements (user_id, lower(name));
+
+-- Intakes of supplements; deleting a supplement deletes its intakes
+CREATE TABLE supplement_intakes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    supplement_id UUID NOT NULL,
+    dose TEXT NOT NULL, -- Dose taken; the supplement's usual dose unless another was given
+    taken_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_supplement FOREIGN KEY(supplement_id) REFERENCES supplements(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_supplement_intakes_user_taken_at ON supplement_intakes (user_id, taken_at DESC);
+CREATE INDEX idx_supplement_intakes_supplement ON supplement_intakes (supplement_id);
@@ -0,0 +1,14 @@
+-- Fasts of users. A fast without ended_at is still running; a user has at most one running fast,
+-- and their fasts never overlap, which the repository checks under a per-user lock.
+CREATE TABLE fasts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ CHECK (ended_at > started_at),
+    target_minutes INTEGER CHECK (target_minutes BETWEEN 60 AND 10080), -- NULL without a target
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_fasts_user_started_at ON fasts (user_id, started_at DESC);
+CREATE UNIQUE INDEX idx_fasts_user_running ON fasts (user_id) WHERE ended_at IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS daily_targets;
+ALTER TABLE users DROP COLUMN IF EXISTS activity_level;
@@ -0,0 +1,16 @@
+-- How active the user is, which scales their daily targets
+ALTER TABLE users ADD COLUMN activity_level TEXT NOT NULL DEFAULT 'sedentary'
+    CHECK (activity_level IN ('sedentary', 'light', 'moderate', 'active', 'very_active'));
+
+-- Daily water and calorie targets of users, with the weight and activity level they were
+-- calculated from to tell when they are out of date
+CREATE TABLE daily_targets (
+    user_id UUID PRIMARY KEY,
+    water_ml INTEGER NOT NULL CHECK (water_ml > 0),
+    calories INTEGER NOT NULL CHECK (calories > 0), -- kcal
+    weight_kg NUMERIC(5, 2) NOT NULL, -- Latest weight when calculated
+    activity_level TEXT NOT NULL, -- Activity level when calculated
+    calculated_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- Deleted records, kept for the trash window so users can restore them before the trash job
+-- purges them. data holds the deleted row under "record" and the rows deleted with it, e.g. the
+-- photos of a body record, under the name of their table.
+CREATE TABLE trash (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    entity_type TEXT NOT NULL, -- e.g., "body_record", "meal_record", "supplement"
+    entity_id UUID NOT NULL, -- ID of the deleted record, reused when it is restored
+    data JSONB NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_trash_user_deleted_at ON trash(user_id, deleted_at DESC);
+CREATE INDEX idx_trash_deleted_at ON trash(deleted_at);
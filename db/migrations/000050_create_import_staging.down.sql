@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS import_staging_daily_steps;
+DROP TABLE IF EXISTS import_staging_exercises;
+DROP TABLE IF EXISTS import_staging_body_measurements;
@@ -0,0 +1,38 @@
+-- Staging tables of bulk imports: a batch's samples are copied in with COPY, merged into the
+-- record tables with a few statements, and deleted again in the same transaction, so no row is
+-- ever committed. Unlogged, as their rows never outlive the transaction.
+CREATE UNLOGGED TABLE import_staging_body_measurements (
+    batch_id UUID NOT NULL,
+    ordinal INTEGER NOT NULL, -- Position of the sample in the batch
+    source_sample_id TEXT NOT NULL,
+    date DATE NOT NULL,
+    weight_kg NUMERIC(5, 2),
+    body_fat_percentage NUMERIC(4, 2),
+    sample_id UUID, -- Imported sample, once claimed; NULL for samples imported before
+    record_id UUID, -- Body record the sample was merged into
+    PRIMARY KEY (batch_id, ordinal)
+);
+
+CREATE UNLOGGED TABLE import_staging_exercises (
+    batch_id UUID NOT NULL,
+    ordinal INTEGER NOT NULL, -- Position of the sample in the batch
+    source_sample_id TEXT NOT NULL,
+    exercise_name TEXT NOT NULL,
+    duration_minutes INTEGER,
+    calories_burned INTEGER,
+    recorded_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    new_record_id UUID NOT NULL DEFAULT gen_random_uuid(), -- ID of the record to create
+    sample_id UUID, -- Imported sample, once claimed; NULL for samples imported before
+    record_id UUID, -- Exercise record created; NULL for conflicting samples
+    PRIMARY KEY (batch_id, ordinal)
+);
+
+CREATE UNLOGGED TABLE import_staging_daily_steps (
+    batch_id UUID NOT NULL,
+    ordinal INTEGER NOT NULL, -- Position of the total in the batch
+    date DATE NOT NULL,
+    steps INTEGER NOT NULL,
+    PRIMARY KEY (batch_id, ordinal)
+);
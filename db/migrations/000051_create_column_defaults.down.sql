@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS column_defaults(REGCLASS);
@@ -0,0 +1,23 @@
+-- Returns the defaults of the columns of a table as a JSON object keyed by column name, each
+-- default expression being evaluated. Restoring the trash populates rows over these defaults, so
+-- the columns added after a row was trashed get their default rather than NULL. Columns without
+-- a default and generated columns are left out.
+CREATE OR REPLACE FUNCTION column_defaults(rel REGCLASS)
+RETURNS JSONB AS $$
+DECLARE
+    col RECORD;
+    value JSONB;
+    defaults JSONB := '{}';
+BEGIN
+    FOR col IN
+        SELECT a.attname, pg_get_expr(d.adbin, d.adrelid) AS expr
+        FROM pg_attribute a
+        JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+        WHERE a.attrelid = rel AND a.attnum > 0 AND NOT a.attisdropped AND a.attgenerated = ''
+    LOOP
+        EXECUTE format('SELECT to_jsonb(%s)', col.expr) INTO value;
+        defaults := defaults || jsonb_build_object(col.attname, value);
+    END LOOP;
+    RETURN defaults;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+ALTER TABLE integrations
+    DROP COLUMN IF EXISTS sync_failure_count;
@@ -0,0 +1,4 @@
+-- Number of syncs of an integration that failed in a row since its last successful sync, or since
+-- it was linked
+ALTER TABLE integrations
+    ADD COLUMN sync_failure_count INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Water drunk by users, summed per UTC day against their daily water target
+CREATE TABLE water_intakes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    amount_ml INTEGER NOT NULL CHECK (amount_ml > 0),
+    taken_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_water_intakes_user_taken_at ON water_intakes(user_id, taken_at);
@@ -0,0 +1,4 @@
+-- Data region whose database holds the user's records, from the data_region claim of the token
+-- they were created with; NULL for the home database
+ALTER TABLE users
+    ADD COLUMN data_region TEXT;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_columns_unannounced;
+
+ALTER TABLE columns
+    DROP COLUMN IF EXISTS announced_at;
@@ -0,0 +1,7 @@
+-- When users were notified of a published column; columns published before are never announced
+ALTER TABLE columns
+    ADD COLUMN announced_at TIMESTAMPTZ;
+
+UPDATE columns SET announced_at = CURRENT_TIMESTAMP WHERE published_at <= CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_columns_unannounced ON columns (published_at) WHERE announced_at IS NULL;
@@ -0,0 +1,9 @@
+-- Goals users were notified of reaching, so each goal is only announced once a week
+CREATE TABLE goal_milestones (
+    user_id UUID NOT NULL,
+    goal TEXT NOT NULL, -- "target_weight", "weekly_exercise_minutes", "weekly_calories_burned" or "weekly_diary_entries"
+    week_start DATE NOT NULL, -- Monday of the UTC week the goal was reached in
+    reached_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, goal, week_start),
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
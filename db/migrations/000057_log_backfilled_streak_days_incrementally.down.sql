@@ -0,0 +1,42 @@
+-- Adds a logged day to a streak. Days after the last logged day extend or restart the streak
+-- without reading the records; earlier days fall back to recompute_streak.
+CREATE OR REPLACE FUNCTION log_streak_day(target_user_id UUID, streak_kind TEXT, logged_on DATE)
+RETURNS VOID AS $$
+DECLARE
+    s streaks%ROWTYPE;
+BEGIN
+    SELECT * INTO s FROM streaks WHERE user_id = target_user_id AND kind = streak_kind FOR UPDATE;
+    IF NOT FOUND THEN
+        INSERT INTO streaks (user_id, kind, current_length, longest_length, last_logged_on)
+        VALUES (target_user_id, streak_kind, 1, 1, logged_on)
+        ON CONFLICT (user_id, kind) DO NOTHING;
+        IF NOT FOUND THEN
+            -- Created concurrently; apply the day to that row
+            PERFORM log_streak_day(target_user_id, streak_kind, logged_on);
+            RETURN;
+        END IF;
+        s.longest_length := 1;
+    ELSIF logged_on = s.last_logged_on THEN
+        RETURN;
+    ELSIF logged_on < s.last_logged_on THEN
+        PERFORM recompute_streak(target_user_id, streak_kind);
+        RETURN;
+    ELSE
+        IF logged_on = s.last_logged_on + 1 THEN
+            s.current_length := s.current_length + 1;
+        ELSE
+            s.current_length := 1;
+        END IF;
+        s.longest_length := GREATEST(s.longest_length, s.current_length);
+        UPDATE streaks
+        SET current_length = s.current_length,
+            longest_length = s.longest_length,
+            last_logged_on = logged_on,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = target_user_id AND kind = streak_kind;
+    END IF;
+    PERFORM award_streak_badges(target_user_id, streak_kind, s.longest_length);
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS logged_run(UUID, TEXT, DATE, INTEGER);
@@ -0,0 +1,82 @@
+-- Returns the number of consecutive days logged for a streak from from_day on, walking a day at a
+-- time towards step (1 for later days, -1 for earlier ones)
+CREATE OR REPLACE FUNCTION logged_run(target_user_id UUID, streak_kind TEXT, from_day DATE, step INTEGER)
+RETURNS INTEGER AS $$
+DECLARE
+    d DATE := from_day;
+    run_length INTEGER := 0;
+BEGIN
+    WHILE (streak_kind IN ('logging', 'body_record')
+            AND EXISTS (SELECT 1 FROM body_records WHERE user_id = target_user_id AND date = d))
+        OR (streak_kind IN ('logging', 'diary_entry')
+            AND EXISTS (SELECT 1 FROM diary_entries WHERE user_id = target_user_id AND entry_date = d))
+    LOOP
+        run_length := run_length + 1;
+        d := d + step;
+    END LOOP;
+    RETURN run_length;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Adds a logged day to a streak without recomputing it from all records. Days after the last
+-- logged day extend or restart the streak; earlier days join the runs of logged days around them,
+-- which are walked from the days before and after, and extend the current streak if they touch it.
+CREATE OR REPLACE FUNCTION log_streak_day(target_user_id UUID, streak_kind TEXT, logged_on DATE)
+RETURNS VOID AS $$
+DECLARE
+    s streaks%ROWTYPE;
+    current_start DATE;
+    later_days INTEGER;
+    run_length INTEGER;
+BEGIN
+    SELECT * INTO s FROM streaks WHERE user_id = target_user_id AND kind = streak_kind FOR UPDATE;
+    IF NOT FOUND THEN
+        INSERT INTO streaks (user_id, kind, current_length, longest_length, last_logged_on)
+        VALUES (target_user_id, streak_kind, 1, 1, logged_on)
+        ON CONFLICT (user_id, kind) DO NOTHING;
+        IF NOT FOUND THEN
+            -- Created concurrently; apply the day to that row
+            PERFORM log_streak_day(target_user_id, streak_kind, logged_on);
+            RETURN;
+        END IF;
+        s.longest_length := 1;
+    ELSIF logged_on < s.last_logged_on THEN
+        current_start := s.last_logged_on - s.current_length + 1;
+        -- Days of the current streak are already counted
+        IF logged_on >= current_start THEN
+            RETURN;
+        END IF;
+        IF logged_on = current_start - 1 THEN
+            later_days := s.current_length;
+        ELSE
+            later_days := logged_run(target_user_id, streak_kind, logged_on + 1, 1);
+        END IF;
+        run_length := logged_run(target_user_id, streak_kind, logged_on - 1, -1) + 1 + later_days;
+        IF logged_on = current_start - 1 THEN
+            s.current_length := run_length;
+        END IF;
+        s.longest_length := GREATEST(s.longest_length, run_length);
+        UPDATE streaks
+        SET current_length = s.current_length,
+            longest_length = s.longest_length,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = target_user_id AND kind = streak_kind;
+    ELSIF logged_on = s.last_logged_on THEN
+        RETURN;
+    ELSE
+        IF logged_on = s.last_logged_on + 1 THEN
+            s.current_length := s.current_length + 1;
+        ELSE
+            s.current_length := 1;
+        END IF;
+        s.longest_length := GREATEST(s.longest_length, s.current_length);
+        UPDATE streaks
+        SET current_length = s.current_length,
+            longest_length = s.longest_length,
+            last_logged_on = logged_on,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = target_user_id AND kind = streak_kind;
+    END IF;
+    PERFORM award_streak_badges(target_user_id, streak_kind, s.longest_length);
+END;
+$$ LANGUAGE plpgsql;
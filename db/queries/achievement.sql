@@ -0,0 +1,12 @@
+-- name: ListStreaksByUser :many
+SELECT * FROM streaks
+WHERE user_id = $1;
+
+-- name: GetStreakByUserAndKind :one
+SELECT * FROM streaks
+WHERE user_id = $1 AND kind = $2;
+
+-- name: ListAchievementsByUser :many
+SELECT * FROM achievements
+WHERE user_id = $1
+ORDER BY awarded_at ASC, badge ASC;
@@ -0,0 +1,12 @@
+-- name: IncrementAPIUsage :one
+-- Counts a request unless the user already reached daily_quota; returns no row then.
+-- A NULL daily_quota counts every request.
+INSERT INTO api_usage (user_id, day, request_count)
+VALUES (sqlc.arg(user_id), sqlc.arg(day), 1)
+ON CONFLICT (user_id, day) DO UPDATE SET request_count = api_usage.request_count + 1
+WHERE sqlc.narg(daily_quota)::integer IS NULL OR api_usage.request_count < sqlc.narg(daily_quota)::integer
+RETURNING request_count;
+
+-- name: GetAPIUsage :one
+SELECT request_count FROM api_usage
+WHERE user_id = $1 AND day = $2;
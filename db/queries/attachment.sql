@@ -0,0 +1,72 @@
+-- name: CreateBodyRecordAttachment :one
+-- Creates a pending attachment of a body record; returns no row if the user has no such record
+INSERT INTO attachments (id, user_id, body_record_id, body_record_date, storage_key, content_type, size_bytes, status, created_at, updated_at)
+SELECT sqlc.arg(id)::uuid, b.user_id, b.id, b.date, sqlc.arg(storage_key)::text, sqlc.arg(content_type)::text, sqlc.arg(size_bytes)::bigint, 'pending', sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+FROM body_records b
+WHERE b.id = sqlc.arg(body_record_id) AND b.user_id = sqlc.arg(user_id)
+RETURNING *;
+
+-- name: CountAttachmentsByBodyRecord :one
+SELECT COUNT(*) FROM attachments
+WHERE body_record_id = $1 AND user_id = $2;
+
+-- name: LockBodyRecordForAttachment :one
+-- Locks a body record of the user, so the photo limit of concurrent uploads is checked in turn
+SELECT id FROM body_records
+WHERE id = sqlc.arg(body_record_id) AND user_id = sqlc.arg(user_id)
+FOR NO KEY UPDATE;
+
+-- name: CountLiveAttachmentsByBodyRecord :one
+-- Counts the photos of a body record toward its limit: the ready ones, and the pending ones
+-- created from pending_cutoff, which can still be completed
+SELECT COUNT(*) FROM attachments
+WHERE body_record_id = sqlc.arg(body_record_id) AND user_id = sqlc.arg(user_id)
+    AND (status = 'ready' OR created_at >= sqlc.arg(pending_cutoff)::timestamptz);
+
+-- name: CountStalePendingAttachments :one
+SELECT COUNT(*) FROM attachments
+WHERE status = 'pending' AND created_at < sqlc.arg(cutoff)::timestamptz;
+
+-- name: DeleteStalePendingAttachments :many
+-- Deletes up to batch_size attachments pending since before cutoff, whose uploads can no longer
+-- be completed, returning them so the job can delete their files
+DELETE FROM attachments
+WHERE id IN (
+    SELECT id FROM attachments
+    WHERE status = 'pending' AND created_at < sqlc.arg(cutoff)::timestamptz
+    ORDER BY created_at ASC
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING *;
+
+-- name: GetAttachmentByID :one
+SELECT * FROM attachments
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAttachmentReady :one
+UPDATE attachments
+SET status = 'ready', updated_at = $3
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteAttachment :execrows
+DELETE FROM attachments
+WHERE id = $1 AND user_id = $2;
+
+-- name: TrashAttachment :one
+-- Moves a photo of a user to the trash; its file is kept until the trash is purged
+WITH deleted AS (
+    DELETE FROM attachments a
+    WHERE a.id = $1 AND a.user_id = $2
+    RETURNING *
+), trashed AS (
+    INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+    SELECT d.user_id, 'attachment', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+    FROM deleted d
+)
+SELECT * FROM deleted;
+
+-- name: ListReadyAttachmentsByBodyRecords :many
+SELECT * FROM attachments
+WHERE body_record_id = ANY(sqlc.arg(body_record_ids)::uuid[]) AND status = 'ready'
+ORDER BY created_at ASC;
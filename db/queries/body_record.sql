@@ -1,12 +1,19 @@
 -- name: CreateBodyRecord :one
-INSERT INTO body_records (user_id, date, weight_kg, body_fat_percentage, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO body_records (user_id, date, weight_kg, body_fat_percentage, created_at, updated_at, note)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 ON CONFLICT (user_id, date) DO UPDATE SET
     weight_kg = EXCLUDED.weight_kg,
     body_fat_percentage = EXCLUDED.body_fat_percentage,
+    note = EXCLUDED.note,
     updated_at = $6
 RETURNING *;
 
+-- name: GetBodyRecordByUserDateForUpdate :one
+-- Locks the record of a date, if there is one, until the end of the transaction.
+SELECT * FROM body_records
+WHERE user_id = $1 AND date = $2
+FOR UPDATE;
+
 -- name: ListBodyRecordsByUser :many
 SELECT * FROM body_records
 WHERE user_id = $1
@@ -14,10 +21,52 @@ ORDER BY date DESC
 LIMIT $2 OFFSET $3; -- For pagination
 
 -- name: ListBodyRecordsByUserDateRange :many
+-- Records are unique per user and date, so ordering by date alone is deterministic.
 SELECT * FROM body_records
-WHERE user_id = $1 AND date >= $2 AND date <= $3
-ORDER BY date ASC;
+WHERE user_id = sqlc.arg(user_id) AND date >= sqlc.arg(start_date) AND date <= sqlc.arg(end_date)
+ORDER BY
+    CASE WHEN sqlc.arg(newest_first)::bool THEN date END DESC,
+    date ASC;
+
+-- name: ListBodyRecordsByUserDateRangeBatch :many
+-- Lists up to batch_size body records of a user within a date range, oldest first; the next
+-- batch starts the day after the last record of the batch.
+SELECT * FROM body_records
+WHERE user_id = sqlc.arg(user_id) AND date >= sqlc.arg(start_date) AND date <= sqlc.arg(end_date)
+ORDER BY date ASC
+LIMIT sqlc.arg(batch_size);
+
+-- name: CountBodyRecordsByUserDateRange :one
+SELECT COUNT(*) FROM body_records
+WHERE user_id = sqlc.arg(user_id) AND date >= sqlc.arg(start_date) AND date <= sqlc.arg(end_date);
+
+-- name: TrashBodyRecordsByUserDateRange :many
+-- Moves the body records of a user within a date range to the trash, each with its photos, whose
+-- files are kept until the trash is purged
+WITH deleted AS (
+    DELETE FROM body_records b
+    WHERE b.user_id = sqlc.arg(user_id) AND b.date >= sqlc.arg(start_date) AND b.date <= sqlc.arg(end_date)
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'body_record', d.id, jsonb_build_object(
+    'record', to_jsonb(d),
+    'attachments', (SELECT COALESCE(jsonb_agg(to_jsonb(a)), '[]') FROM attachments a WHERE a.body_record_id = d.id)
+), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d
+RETURNING entity_id;
 
 -- name: CountBodyRecordsByUser :one
 SELECT COUNT(*) FROM body_records
 WHERE user_id = $1;
+
+-- name: MergeBodyRecord :one
+-- Like CreateBodyRecord, but keeps existing values for measurements that are NULL in the input,
+-- and the existing note.
+INSERT INTO body_records (user_id, date, weight_kg, body_fat_percentage, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id, date) DO UPDATE SET
+    weight_kg = COALESCE(EXCLUDED.weight_kg, body_records.weight_kg),
+    body_fat_percentage = COALESCE(EXCLUDED.body_fat_percentage, body_records.body_fat_percentage),
+    updated_at = $6
+RETURNING *;
@@ -32,3 +32,38 @@ WHERE category = $1 AND published_at IS NOT NULL AND published_at <= $2;
 -- name: CountColumnsByTag :one
 SELECT COUNT(*) FROM columns
 WHERE $1::text = ANY(tags) AND published_at IS NOT NULL AND published_at <= $2;
+
+-- name: RecordColumnRead :exec
+INSERT INTO column_reads (column_id, day, read_count)
+VALUES (sqlc.arg(column_id), sqlc.arg(day), 1)
+ON CONFLICT (column_id, day) DO UPDATE SET read_count = column_reads.read_count + 1;
+
+-- name: ClaimUnannouncedColumn :one
+-- Marks the earliest published column users were not notified of as announced at now, so
+-- concurrent jobs never announce a column twice
+UPDATE columns
+SET announced_at = sqlc.arg(now)::timestamptz
+WHERE id = (
+    SELECT c.id FROM columns c
+    WHERE c.announced_at IS NULL AND c.published_at <= sqlc.arg(now)::timestamptz
+    ORDER BY c.published_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ReleaseColumnAnnouncement :exec
+-- Undoes a claim whose recipients could not be listed, so a later run retries it
+UPDATE columns
+SET announced_at = NULL
+WHERE id = $1;
+
+-- name: ListColumnRecipients :many
+-- Users opted in to the column digest of the category, in all categories if they chose none,
+-- after the user ID after; the cursor of the next page is the last ID
+SELECT id, data_region FROM users
+WHERE column_digest_opt_in AND suspended_at IS NULL
+    AND (cardinality(column_digest_categories) = 0 OR lower(sqlc.narg(category)::text) = ANY(column_digest_categories))
+    AND id > sqlc.arg(after)::uuid
+ORDER BY id
+LIMIT sqlc.arg(max_users);
@@ -0,0 +1,47 @@
+-- name: GetUserColumnDigest :one
+SELECT column_digest_opt_in, column_digest_categories, column_digest_sent_at FROM users
+WHERE id = $1;
+
+-- name: SetUserColumnDigest :one
+UPDATE users
+SET column_digest_opt_in = $2, column_digest_categories = $3
+WHERE id = $1
+RETURNING column_digest_opt_in, column_digest_categories, column_digest_sent_at;
+
+-- name: UnsubscribeUserColumnDigest :execrows
+UPDATE users
+SET column_digest_opt_in = false
+WHERE id = $1 AND column_digest_opt_in;
+
+-- name: ClaimColumnDigest :one
+-- Marks the digest of an opted-in user not emailed since due_before as sent at now, so
+-- concurrent jobs never email the same user twice; it returns when the previous digest was sent
+-- so the job can list the columns published since and undo the claim if sending fails.
+WITH due AS (
+    SELECT u.id, u.column_digest_sent_at AS previous_sent_at FROM users u
+    WHERE u.column_digest_opt_in AND u.email IS NOT NULL AND u.suspended_at IS NULL
+        AND (u.column_digest_sent_at IS NULL OR u.column_digest_sent_at <= sqlc.arg(due_before)::timestamptz)
+    ORDER BY u.column_digest_sent_at ASC NULLS FIRST
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE users
+SET column_digest_sent_at = sqlc.arg(now)::timestamptz
+FROM due
+WHERE users.id = due.id
+RETURNING users.id, users.email, users.column_digest_categories, due.previous_sent_at;
+
+-- name: ReleaseColumnDigest :exec
+-- Undoes a claim whose digest could not be sent, so a later run retries it
+UPDATE users
+SET column_digest_sent_at = sqlc.narg(previous_sent_at)::timestamptz
+WHERE id = sqlc.arg(id);
+
+-- name: ListColumnsPublishedBetween :many
+-- Columns published after published_after until published_until, newest first, in the given
+-- lowercase categories or in all categories if none are given
+SELECT * FROM columns
+WHERE published_at > sqlc.arg(published_after)::timestamptz AND published_at <= sqlc.arg(published_until)::timestamptz
+    AND (cardinality(sqlc.arg(categories)::text[]) = 0 OR lower(category) = ANY(sqlc.arg(categories)::text[]))
+ORDER BY published_at DESC
+LIMIT sqlc.arg(max_columns);
@@ -0,0 +1,41 @@
+-- name: GetUserActivityLevel :one
+SELECT activity_level FROM users
+WHERE id = $1;
+
+-- name: SetUserActivityLevel :one
+UPDATE users
+SET activity_level = $2
+WHERE id = $1
+RETURNING activity_level;
+
+-- name: GetLatestBodyWeight :one
+-- The latest body record of the user with a weight
+SELECT weight_kg, body_fat_percentage FROM body_records
+WHERE user_id = $1 AND weight_kg IS NOT NULL
+ORDER BY date DESC
+LIMIT 1;
+
+-- name: GetDailyTargets :one
+SELECT * FROM daily_targets
+WHERE user_id = $1;
+
+-- name: UpsertDailyTargets :one
+INSERT INTO daily_targets (user_id, water_ml, calories, weight_kg, activity_level, calculated_at, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+ON CONFLICT (user_id) DO UPDATE SET
+    water_ml = EXCLUDED.water_ml,
+    calories = EXCLUDED.calories,
+    weight_kg = EXCLUDED.weight_kg,
+    activity_level = EXCLUDED.activity_level,
+    calculated_at = EXCLUDED.calculated_at
+RETURNING *;
+
+-- name: CreateWaterIntake :one
+INSERT INTO water_intakes (user_id, amount_ml, taken_at, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: SumWaterIntakeByUserRange :one
+-- Total water drunk by a user from start to end (exclusive)
+SELECT COALESCE(SUM(amount_ml), 0)::bigint AS total_ml FROM water_intakes
+WHERE user_id = sqlc.arg(user_id) AND taken_at >= sqlc.arg(start_time) AND taken_at < sqlc.arg(end_time);
@@ -0,0 +1,37 @@
+-- name: CreateDataShare :one
+INSERT INTO data_shares (owner_id, grantee_id, record_types, starts_at, expires_at, created_at, updated_at)
+VALUES (sqlc.arg(owner_id), sqlc.arg(grantee_id), sqlc.arg(record_types)::text[], sqlc.arg(starts_at), sqlc.arg(expires_at), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz)
+RETURNING *;
+
+-- name: ListDataSharesByOwner :many
+-- Shares granted by the owner that are not revoked or expired, newest first
+SELECT sqlc.embed(s), o.email AS owner_email, g.email AS grantee_email
+FROM data_shares s
+JOIN users o ON o.id = s.owner_id
+JOIN users g ON g.id = s.grantee_id
+WHERE s.owner_id = sqlc.arg(owner_id) AND s.revoked_at IS NULL AND s.expires_at > sqlc.arg(now)::timestamptz
+ORDER BY s.created_at DESC;
+
+-- name: ListDataSharesByGrantee :many
+-- Shares granted to the grantee that are in effect, newest first
+SELECT sqlc.embed(s), o.email AS owner_email, g.email AS grantee_email
+FROM data_shares s
+JOIN users o ON o.id = s.owner_id
+JOIN users g ON g.id = s.grantee_id
+WHERE s.grantee_id = sqlc.arg(grantee_id) AND s.revoked_at IS NULL
+  AND s.starts_at <= sqlc.arg(now)::timestamptz AND s.expires_at > sqlc.arg(now)::timestamptz
+ORDER BY s.created_at DESC;
+
+-- name: RevokeDataShare :execrows
+UPDATE data_shares
+SET revoked_at = sqlc.arg(now)::timestamptz, updated_at = sqlc.arg(now)::timestamptz
+WHERE id = sqlc.arg(id) AND owner_id = sqlc.arg(owner_id) AND revoked_at IS NULL;
+
+-- name: HasActiveDataShare :one
+-- Whether the owner shares the record type with the grantee at the given time
+SELECT EXISTS (
+    SELECT 1 FROM data_shares
+    WHERE owner_id = sqlc.arg(owner_id) AND grantee_id = sqlc.arg(grantee_id)
+      AND sqlc.arg(record_type)::text = ANY(record_types) AND revoked_at IS NULL
+      AND starts_at <= sqlc.arg(now)::timestamptz AND expires_at > sqlc.arg(now)::timestamptz
+);
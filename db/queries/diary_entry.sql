@@ -1,11 +1,25 @@
 -- name: CreateDiaryEntry :one
-INSERT INTO diary_entries (user_id, title, content, entry_date, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO diary_entries (user_id, title, content, entry_date, created_at, updated_at, encrypted, content_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
+-- name: LockDiaryEntryCreates :exec
+-- Serializes the creates of a user until the end of the transaction, so concurrent retries
+-- see each other's entries
+SELECT pg_advisory_xact_lock(hashtextextended(sqlc.arg(user_id)::uuid::text, 0));
+
+-- name: FindDuplicateDiaryEntry :one
+-- Returns the newest entry of the user for the date created with the same content since the
+-- given time
+SELECT * FROM diary_entries
+WHERE user_id = @user_id AND entry_date = @entry_date AND content_hash = @content_hash
+    AND created_at >= @created_since
+ORDER BY created_at DESC
+LIMIT 1;
+
 -- name: UpdateDiaryEntry :one
 UPDATE diary_entries
-SET title = $2, content = $3, updated_at = $5
+SET title = $2, content = $3, updated_at = $5, encrypted = $6, content_hash = NULL
 WHERE id = $1 AND user_id = $4
 RETURNING *;
 
@@ -20,10 +34,107 @@ SELECT * FROM diary_entries
 WHERE id = $1 AND user_id = $2
 LIMIT 1;
 
--- name: DeleteDiaryEntry :exec
-DELETE FROM diary_entries
-WHERE id = $1 AND user_id = $2;
+-- name: TrashDiaryEntry :execrows
+-- Moves a diary entry of a user to the trash with its revisions. Its share links are not kept, so
+-- a restored entry is private.
+WITH deleted AS (
+    DELETE FROM diary_entries e
+    WHERE e.id = $1 AND e.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'diary_entry', d.id, jsonb_build_object(
+    'record', to_jsonb(d),
+    'diary_entry_revisions', (SELECT COALESCE(jsonb_agg(to_jsonb(r)), '[]') FROM diary_entry_revisions r WHERE r.diary_entry_id = d.id)
+), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
 
 -- name: CountDiaryEntriesByUser :one
 SELECT COUNT(*) FROM diary_entries
 WHERE user_id = $1;
+
+-- name: ListPlaintextDiaryEntriesForUpdate :many
+-- Locks a batch of entries not encrypted yet, skipping those locked by concurrent batches
+SELECT id, title, content FROM diary_entries
+WHERE NOT encrypted
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: SetDiaryEntryCiphertext :exec
+-- Replaces the plaintext of an entry with its ciphertext, keeping updated_at as the content is unchanged
+UPDATE diary_entries
+SET title = $2, content = $3, encrypted = true
+WHERE id = $1;
+
+-- name: CreateDiaryEntryRevision :execrows
+-- Copies the current version of an entry to its revisions, locking the entry so concurrent
+-- updates each keep the version they replace
+INSERT INTO diary_entry_revisions (diary_entry_id, user_id, title, content, encrypted, edited_at, created_at)
+SELECT e.id, e.user_id, e.title, e.content, e.encrypted, e.updated_at, @created_at
+FROM diary_entries e
+WHERE e.id = @diary_entry_id AND e.user_id = @user_id
+FOR UPDATE;
+
+-- name: PruneDiaryEntryRevisions :exec
+-- Deletes all but the newest revisions of an entry
+DELETE FROM diary_entry_revisions r
+WHERE r.diary_entry_id = @diary_entry_id AND r.id NOT IN (
+    SELECT newest.id FROM diary_entry_revisions newest
+    WHERE newest.diary_entry_id = @diary_entry_id
+    ORDER BY newest.created_at DESC, newest.id
+    LIMIT @keep
+);
+
+-- name: ListDiaryEntryRevisions :many
+SELECT * FROM diary_entry_revisions
+WHERE diary_entry_id = $1 AND user_id = $2
+ORDER BY created_at DESC, id
+LIMIT $3 OFFSET $4;
+
+-- name: CountDiaryEntryRevisions :one
+SELECT COUNT(*) FROM diary_entry_revisions
+WHERE diary_entry_id = $1 AND user_id = $2;
+
+-- name: GetDiaryEntryRevision :one
+SELECT * FROM diary_entry_revisions
+WHERE id = $1 AND diary_entry_id = $2 AND user_id = $3
+LIMIT 1;
+
+-- name: ListPlaintextDiaryEntryRevisionsForUpdate :many
+-- Locks a batch of revisions not encrypted yet, skipping those locked by concurrent batches
+SELECT id, title, content FROM diary_entry_revisions
+WHERE NOT encrypted
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: SetDiaryEntryRevisionCiphertext :exec
+UPDATE diary_entry_revisions
+SET title = $2, content = $3, encrypted = true
+WHERE id = $1;
+
+-- name: CreateDiaryShareLink :one
+-- Creates a link to an entry of the user; no row is returned if they have no such entry
+INSERT INTO diary_share_links (user_id, diary_entry_id, expires_at, created_at)
+SELECT e.user_id, e.id, @expires_at, @created_at
+FROM diary_entries e
+WHERE e.id = @diary_entry_id AND e.user_id = @user_id
+RETURNING *;
+
+-- name: RevokeDiaryShareLink :execrows
+UPDATE diary_share_links
+SET revoked_at = sqlc.arg(now)::timestamptz
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
+
+-- name: GetSharedDiaryEntry :one
+-- Returns the entry of a link that is neither revoked nor expired at the given time
+SELECT sqlc.embed(e), l.expires_at AS link_expires_at
+FROM diary_share_links l
+JOIN diary_entries e ON e.id = l.diary_entry_id
+WHERE l.id = sqlc.arg(id) AND l.revoked_at IS NULL AND l.expires_at > sqlc.arg(now)::timestamptz;
+
+-- name: ListDiaryEntriesByUserDateRange :many
+SELECT * FROM diary_entries
+WHERE user_id = @user_id AND entry_date BETWEEN @start_date AND @end_date
+ORDER BY entry_date ASC, created_at ASC;
@@ -1,6 +1,6 @@
 -- name: CreateExerciseRecord :one
-INSERT INTO exercise_records (user_id, exercise_name, duration_minutes, calories_burned, recorded_at, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7)
+INSERT INTO exercise_records (user_id, exercise_name, duration_minutes, calories_burned, recorded_at, created_at, updated_at, started_at, ended_at, rpe, intensity, workout_session_id, workout_position)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 RETURNING *;
 
 -- name: ListExerciseRecordsByUser :many
@@ -14,10 +14,92 @@ SELECT * FROM exercise_records
 WHERE id = $1 AND user_id = $2
 LIMIT 1;
 
--- name: DeleteExerciseRecord :exec
-DELETE FROM exercise_records
-WHERE id = $1 AND user_id = $2;
+-- name: TrashExerciseRecord :execrows
+-- Moves an exercise record of a user to the trash with its route
+WITH deleted AS (
+    DELETE FROM exercise_records e
+    WHERE e.id = $1 AND e.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'exercise_record', d.id, jsonb_build_object(
+    'record', to_jsonb(d),
+    'exercise_routes', (SELECT COALESCE(jsonb_agg(to_jsonb(r)), '[]') FROM exercise_routes r WHERE r.exercise_record_id = d.id)
+), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: CountExerciseRecordsByUserRange :one
+-- Records with recorded_at in [range_start, range_end)
+SELECT COUNT(*) FROM exercise_records
+WHERE user_id = sqlc.arg(user_id) AND recorded_at >= sqlc.arg(range_start)::timestamptz AND recorded_at < sqlc.arg(range_end)::timestamptz;
+
+-- name: TrashExerciseRecordsByUserRange :many
+-- Moves the records with recorded_at in [range_start, range_end) to the trash, each with its route
+WITH deleted AS (
+    DELETE FROM exercise_records e
+    WHERE e.user_id = sqlc.arg(user_id) AND e.recorded_at >= sqlc.arg(range_start)::timestamptz AND e.recorded_at < sqlc.arg(range_end)::timestamptz
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'exercise_record', d.id, jsonb_build_object(
+    'record', to_jsonb(d),
+    'exercise_routes', (SELECT COALESCE(jsonb_agg(to_jsonb(r)), '[]') FROM exercise_routes r WHERE r.exercise_record_id = d.id)
+), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d
+RETURNING entity_id;
 
 -- name: CountExerciseRecordsByUser :one
 SELECT COUNT(*) FROM exercise_records
 WHERE user_id = $1;
+
+-- name: ListOverlappingExerciseRecords :many
+-- Records with a time range intersecting [range_start, range_end)
+SELECT * FROM exercise_records
+WHERE user_id = sqlc.arg(user_id) AND started_at < sqlc.arg(range_end)::timestamptz AND ended_at > sqlc.arg(range_start)::timestamptz
+ORDER BY started_at ASC;
+
+-- name: ListExerciseRecordsByIDsForUpdate :many
+SELECT * FROM exercise_records
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[])
+ORDER BY created_at ASC
+FOR UPDATE;
+
+-- name: UpdateExerciseRecord :one
+UPDATE exercise_records
+SET exercise_name = $3, duration_minutes = $4, calories_burned = $5, recorded_at = $6, started_at = $7, ended_at = $8, updated_at = $9, rpe = $10, intensity = $11
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteExerciseRecordsByIDs :exec
+DELETE FROM exercise_records
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetExerciseTotalsByUserRange :one
+-- Totals of records with recorded_at in [range_start, range_end)
+SELECT
+    COUNT(*) AS session_count,
+    COALESCE(SUM(duration_minutes), 0)::bigint AS total_duration_minutes,
+    COALESCE(SUM(calories_burned), 0)::bigint AS total_calories_burned
+FROM exercise_records
+WHERE user_id = sqlc.arg(user_id) AND recorded_at >= sqlc.arg(range_start)::timestamptz AND recorded_at < sqlc.arg(range_end)::timestamptz;
+
+-- name: ListDailyTrainingLoadsByUserRange :many
+-- Session-RPE training load (duration_minutes x RPE) per UTC day of records with recorded_at in
+-- [range_start, range_end). Records without an RPE are rated by their intensity; records with
+-- neither, or without a duration, add no load and are counted as unrated.
+SELECT
+    (recorded_at AT TIME ZONE 'UTC')::date AS day,
+    COUNT(*) AS session_count,
+    COUNT(*) FILTER (WHERE load IS NULL) AS unrated_session_count,
+    COALESCE(SUM(load), 0)::bigint AS load
+FROM (
+    SELECT recorded_at, duration_minutes * COALESCE(rpe, CASE intensity
+        WHEN 'light' THEN 3
+        WHEN 'moderate' THEN 5
+        WHEN 'vigorous' THEN 8
+    END) AS load
+    FROM exercise_records
+    WHERE user_id = sqlc.arg(user_id) AND recorded_at >= sqlc.arg(range_start)::timestamptz AND recorded_at < sqlc.arg(range_end)::timestamptz
+) rated
+GROUP BY day
+ORDER BY day ASC;
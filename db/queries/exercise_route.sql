@@ -0,0 +1,14 @@
+-- name: UpsertExerciseRoute :one
+-- Attaches a route to a user's exercise record, replacing any previous one; returns no rows if
+-- the user has no such record
+INSERT INTO exercise_routes (exercise_record_id, exercise_recorded_at, user_id, polyline, point_count, distance_meters, created_at, updated_at)
+SELECT e.id, e.recorded_at, e.user_id, sqlc.arg(polyline), sqlc.arg(point_count), sqlc.arg(distance_meters), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+FROM exercise_records e
+WHERE e.id = sqlc.arg(exercise_record_id) AND e.user_id = sqlc.arg(user_id)
+ON CONFLICT (exercise_record_id) DO UPDATE
+SET polyline = EXCLUDED.polyline, point_count = EXCLUDED.point_count, distance_meters = EXCLUDED.distance_meters, updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetExerciseRoute :one
+SELECT * FROM exercise_routes
+WHERE exercise_record_id = $1 AND user_id = $2;
@@ -0,0 +1,40 @@
+-- name: CreateExerciseTemplate :one
+INSERT INTO exercise_templates (user_id, name, duration_minutes, calories_burned, intensity, favorite, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+RETURNING *;
+
+-- name: GetExerciseTemplate :one
+SELECT * FROM exercise_templates
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListExerciseTemplatesByUser :many
+-- Favorites first, then the most recently used, then by name
+SELECT * FROM exercise_templates
+WHERE user_id = sqlc.arg(user_id) AND (favorite OR NOT sqlc.arg(favorites_only)::boolean)
+ORDER BY favorite DESC, last_used_at DESC NULLS LAST, lower(name) ASC;
+
+-- name: CountExerciseTemplatesByUser :one
+SELECT COUNT(*) FROM exercise_templates
+WHERE user_id = $1;
+
+-- name: UpdateExerciseTemplate :one
+UPDATE exercise_templates
+SET name = $3, duration_minutes = $4, calories_burned = $5, intensity = $6, favorite = $7, updated_at = $8
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: TrashExerciseTemplate :execrows
+-- Moves an exercise template of a user to the trash
+WITH deleted AS (
+    DELETE FROM exercise_templates t
+    WHERE t.id = $1 AND t.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'exercise_template', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: MarkExerciseTemplateUsed :exec
+UPDATE exercise_templates
+SET use_count = use_count + 1, last_used_at = sqlc.arg(used_at)::timestamptz
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,67 @@
+-- name: LockFasts :exec
+-- Serializes the writes of a user's fasts until the end of the transaction, so overlap checks
+-- see each other's fasts
+SELECT pg_advisory_xact_lock(hashtextextended('fasts:' || sqlc.arg(user_id)::uuid::text, 0));
+
+-- name: CountOverlappingFasts :one
+-- Counts the fasts of a user overlapping the range from start to end; a NULL end, like the end
+-- of a running fast, is open
+SELECT COUNT(*) FROM fasts
+WHERE user_id = sqlc.arg(user_id)
+    AND started_at < COALESCE(sqlc.narg(end_time)::timestamptz, 'infinity')
+    AND COALESCE(ended_at, 'infinity') > sqlc.arg(start_time)::timestamptz;
+
+-- name: CreateFast :one
+INSERT INTO fasts (user_id, started_at, target_minutes, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $4)
+RETURNING *;
+
+-- name: GetRunningFast :one
+SELECT * FROM fasts
+WHERE user_id = $1 AND ended_at IS NULL;
+
+-- name: EndFast :one
+UPDATE fasts
+SET ended_at = $3, updated_at = $4
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: ListFastsByUser :many
+SELECT * FROM fasts
+WHERE user_id = $1
+ORDER BY started_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountFastsByUser :one
+SELECT COUNT(*) FROM fasts
+WHERE user_id = $1;
+
+-- name: TrashFast :execrows
+-- Moves a fast of a user to the trash
+WITH deleted AS (
+    DELETE FROM fasts f
+    WHERE f.id = $1 AND f.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'fast', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: GetFastingStreak :one
+-- Returns the streak of consecutive UTC days on which a fast of the user ended that reached its
+-- target (any ended fast without a target counts) ending on the last such day, and the longest
+-- streak. last_fasted_on is NULL without such fasts.
+WITH days AS (
+    SELECT DISTINCT (ended_at AT TIME ZONE 'UTC')::date AS day FROM fasts
+    WHERE user_id = sqlc.arg(user_id) AND ended_at IS NOT NULL
+        AND (target_minutes IS NULL OR ended_at - started_at >= make_interval(mins => target_minutes))
+), islands AS (
+    -- Consecutive days share day + their rank, newest first
+    SELECT day, day + (ROW_NUMBER() OVER (ORDER BY day DESC))::int AS island FROM days
+), lengths AS (
+    SELECT COUNT(*)::int AS length, MAX(day) AS last_day FROM islands GROUP BY island
+)
+SELECT
+    COALESCE((SELECT length FROM lengths ORDER BY last_day DESC LIMIT 1), 0)::int AS current_length,
+    COALESCE((SELECT MAX(length) FROM lengths), 0)::int AS longest_length,
+    (SELECT MAX(last_day) FROM lengths)::date AS last_fasted_on;
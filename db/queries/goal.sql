@@ -0,0 +1,74 @@
+-- name: GetGoalsByUser :one
+SELECT * FROM goals
+WHERE user_id = $1;
+
+-- name: UpsertGoals :one
+INSERT INTO goals (user_id, target_weight_kg, weekly_exercise_minutes, weekly_calories_burned, weekly_diary_entries, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET target_weight_kg = EXCLUDED.target_weight_kg,
+    weekly_exercise_minutes = EXCLUDED.weekly_exercise_minutes,
+    weekly_calories_burned = EXCLUDED.weekly_calories_burned,
+    weekly_diary_entries = EXCLUDED.weekly_diary_entries,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetWeeklySummary :one
+-- Aggregates of the user's records in the week [week_start, week_end), along with their goals.
+-- Body records and diary entries count by their UTC date, exercise records by recorded_at.
+-- previous_weight_kg is the last weight recorded before the week.
+WITH week_weights AS (
+    SELECT date, weight_kg FROM body_records
+    WHERE user_id = sqlc.arg(user_id) AND weight_kg IS NOT NULL
+        AND date >= (sqlc.arg(week_start)::timestamptz AT TIME ZONE 'UTC')::date
+        AND date < (sqlc.arg(week_end)::timestamptz AT TIME ZONE 'UTC')::date
+)
+SELECT
+    (SELECT b.weight_kg FROM body_records b
+        WHERE b.user_id = sqlc.arg(user_id) AND b.weight_kg IS NOT NULL
+            AND b.date < (sqlc.arg(week_start)::timestamptz AT TIME ZONE 'UTC')::date
+        ORDER BY b.date DESC LIMIT 1)::numeric AS previous_weight_kg,
+    (SELECT w.weight_kg FROM week_weights w ORDER BY w.date ASC LIMIT 1)::numeric AS first_weight_kg,
+    (SELECT w.weight_kg FROM week_weights w ORDER BY w.date DESC LIMIT 1)::numeric AS last_weight_kg,
+    (SELECT COUNT(*) FROM week_weights) AS weigh_in_count,
+    e.session_count,
+    e.total_duration_minutes,
+    e.total_calories_burned,
+    (SELECT COUNT(*) FROM diary_entries d
+        WHERE d.user_id = sqlc.arg(user_id)
+            AND d.entry_date >= (sqlc.arg(week_start)::timestamptz AT TIME ZONE 'UTC')::date
+            AND d.entry_date < (sqlc.arg(week_end)::timestamptz AT TIME ZONE 'UTC')::date) AS diary_entry_count,
+    g.target_weight_kg AS goal_target_weight_kg,
+    g.weekly_exercise_minutes AS goal_weekly_exercise_minutes,
+    g.weekly_calories_burned AS goal_weekly_calories_burned,
+    g.weekly_diary_entries AS goal_weekly_diary_entries
+FROM (
+    SELECT
+        COUNT(*) AS session_count,
+        COALESCE(SUM(duration_minutes), 0)::bigint AS total_duration_minutes,
+        COALESCE(SUM(calories_burned), 0)::bigint AS total_calories_burned
+    FROM exercise_records
+    WHERE user_id = sqlc.arg(user_id) AND recorded_at >= sqlc.arg(week_start)::timestamptz AND recorded_at < sqlc.arg(week_end)::timestamptz
+) e
+LEFT JOIN goals g ON g.user_id = sqlc.arg(user_id);
+
+-- name: ListUsersWithGoalChangesBetween :many
+-- Users with goals whose body records, exercise records or diary entries changed after
+-- changed_after until changed_until, the candidates for reaching a goal
+SELECT DISTINCT c.user_id FROM record_changes c
+JOIN goals g ON g.user_id = c.user_id
+WHERE c.changed_at > sqlc.arg(changed_after)::timestamptz AND c.changed_at <= sqlc.arg(changed_until)::timestamptz
+    AND c.entity_type IN ('body_record', 'exercise_record', 'diary_entry')
+ORDER BY c.user_id;
+
+-- name: InsertGoalMilestone :execrows
+-- Records that the user reached the goal in the week; it affects no rows if they were already
+-- notified of it
+INSERT INTO goal_milestones (user_id, goal, week_start, reached_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, goal, week_start) DO NOTHING;
+
+-- name: DeleteGoalMilestone :exec
+-- Undoes a milestone whose notification could not be queued, so a later run retries it
+DELETE FROM goal_milestones
+WHERE user_id = $1 AND goal = $2 AND week_start = $3;
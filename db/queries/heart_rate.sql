@@ -0,0 +1,87 @@
+-- name: InsertHeartRateSamples :one
+-- Samples already stored for their time and source are skipped, so devices can resend them.
+-- The hours of the inserted samples are queued for the rollup job.
+WITH inserted AS (
+    INSERT INTO heart_rate_samples (user_id, measured_at, source, bpm, created_at)
+    SELECT sqlc.arg(user_id), unnest(sqlc.arg(measured_at)::timestamptz[]), unnest(sqlc.arg(sources)::text[]), unnest(sqlc.arg(bpm)::smallint[]),
+        sqlc.arg(now)::timestamptz
+    ON CONFLICT (user_id, measured_at, source) DO NOTHING
+    RETURNING user_id, measured_at
+), queued AS (
+    INSERT INTO heart_rate_pending_hours (user_id, hour)
+    SELECT DISTINCT user_id, date_trunc('hour', measured_at, 'UTC') FROM inserted
+    ON CONFLICT DO NOTHING
+)
+SELECT COUNT(*)::bigint AS inserted FROM inserted;
+
+-- name: ListHeartRateByUser :many
+-- Averages of the samples in [range_start, range_end) by the UTC minute, hour or day
+SELECT date_trunc(sqlc.arg(unit)::text, measured_at, 'UTC')::timestamptz AS bucket,
+    AVG(bpm)::float8 AS average_bpm,
+    MIN(bpm)::integer AS min_bpm,
+    MAX(bpm)::integer AS max_bpm,
+    COUNT(*)::integer AS sample_count
+FROM heart_rate_samples
+WHERE user_id = sqlc.arg(user_id) AND measured_at >= sqlc.arg(range_start)::timestamptz AND measured_at < sqlc.arg(range_end)::timestamptz
+GROUP BY bucket
+ORDER BY bucket ASC;
+
+-- name: ClaimHeartRatePendingHours :many
+-- Dequeues up to batch_size pending hours, oldest first; concurrent jobs claim different hours
+DELETE FROM heart_rate_pending_hours
+WHERE (user_id, hour) IN (
+    SELECT p.user_id, p.hour FROM heart_rate_pending_hours p
+    ORDER BY p.hour
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING user_id, hour;
+
+-- name: RollUpHeartRateHours :exec
+-- Recomputes the hourly rollups of the given users' hours from their samples
+INSERT INTO heart_rate_hourly (user_id, hour, bpm_sum, min_bpm, max_bpm, sample_count)
+SELECT p.user_id, p.hour, SUM(s.bpm), MIN(s.bpm), MAX(s.bpm), COUNT(*)
+FROM (SELECT unnest(sqlc.arg(user_ids)::uuid[]) AS user_id, unnest(sqlc.arg(hours)::timestamptz[]) AS hour) p
+JOIN heart_rate_samples s ON s.user_id = p.user_id AND s.measured_at >= p.hour AND s.measured_at < p.hour + INTERVAL '1 hour'
+GROUP BY p.user_id, p.hour
+ON CONFLICT (user_id, hour) DO UPDATE SET
+    bpm_sum = EXCLUDED.bpm_sum,
+    min_bpm = EXCLUDED.min_bpm,
+    max_bpm = EXCLUDED.max_bpm,
+    sample_count = EXCLUDED.sample_count;
+
+-- name: RollUpHeartRateDays :exec
+-- Recomputes the daily rollups of the given users' UTC days from their hourly rollups
+INSERT INTO heart_rate_daily (user_id, day, bpm_sum, min_bpm, max_bpm, sample_count)
+SELECT p.user_id, p.day, SUM(h.bpm_sum), MIN(h.min_bpm), MAX(h.max_bpm), SUM(h.sample_count)
+FROM (SELECT unnest(sqlc.arg(user_ids)::uuid[]) AS user_id, unnest(sqlc.arg(days)::date[]) AS day) p
+JOIN heart_rate_hourly h ON h.user_id = p.user_id
+    AND h.hour >= p.day::timestamp AT TIME ZONE 'UTC' AND h.hour < (p.day + 1)::timestamp AT TIME ZONE 'UTC'
+GROUP BY p.user_id, p.day
+ON CONFLICT (user_id, day) DO UPDATE SET
+    bpm_sum = EXCLUDED.bpm_sum,
+    min_bpm = EXCLUDED.min_bpm,
+    max_bpm = EXCLUDED.max_bpm,
+    sample_count = EXCLUDED.sample_count;
+
+-- name: ListHeartRateHourlyByUser :many
+-- Hourly rollups of the user in [range_start, range_end)
+SELECT hour AS bucket,
+    (bpm_sum::float8 / sample_count)::float8 AS average_bpm,
+    min_bpm::integer AS min_bpm,
+    max_bpm::integer AS max_bpm,
+    sample_count
+FROM heart_rate_hourly
+WHERE user_id = sqlc.arg(user_id) AND hour >= sqlc.arg(range_start)::timestamptz AND hour < sqlc.arg(range_end)::timestamptz
+ORDER BY hour ASC;
+
+-- name: ListHeartRateDailyByUser :many
+-- Daily rollups of the user for the UTC days in [range_start, range_end)
+SELECT (day::timestamp AT TIME ZONE 'UTC')::timestamptz AS bucket,
+    (bpm_sum::float8 / sample_count)::float8 AS average_bpm,
+    min_bpm::integer AS min_bpm,
+    max_bpm::integer AS max_bpm,
+    sample_count
+FROM heart_rate_daily
+WHERE user_id = sqlc.arg(user_id) AND day >= sqlc.arg(range_start)::date AND day < sqlc.arg(range_end)::date
+ORDER BY day ASC;
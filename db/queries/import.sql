@@ -0,0 +1,158 @@
+-- name: CreateImportedSample :one
+-- Returns no rows when the sample has already been imported.
+INSERT INTO imported_samples (user_id, source, source_sample_id, record_type, imported_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, source, source_sample_id) DO NOTHING
+RETURNING *;
+
+-- name: SetImportedSampleRecord :exec
+UPDATE imported_samples
+SET record_id = $2
+WHERE id = $1;
+
+-- name: ReassignImportedSamples :exec
+-- Points samples at the record they were merged into, so merged samples are not imported again.
+UPDATE imported_samples
+SET record_id = sqlc.arg(record_id)::uuid
+WHERE user_id = sqlc.arg(user_id) AND record_id = ANY(sqlc.arg(from_record_ids)::uuid[]);
+
+-- name: ClaimStagedBodyMeasurements :execrows
+-- Records the staged body measurements of a batch as imported, setting the sample of those not
+-- imported before. Source sample IDs must not repeat within a batch.
+WITH claimed AS (
+    INSERT INTO imported_samples (user_id, source, source_sample_id, record_type, imported_at)
+    SELECT sqlc.arg(user_id)::uuid, sqlc.arg(source)::text, s.source_sample_id, 'body_record', sqlc.arg(now)::timestamptz
+    FROM import_staging_body_measurements s
+    WHERE s.batch_id = sqlc.arg(batch_id)
+    ON CONFLICT (user_id, source, source_sample_id) DO NOTHING
+    RETURNING id, source_sample_id
+)
+UPDATE import_staging_body_measurements s
+SET sample_id = c.id
+FROM claimed c
+WHERE s.batch_id = sqlc.arg(batch_id) AND s.source_sample_id = c.source_sample_id;
+
+-- name: MergeStagedBodyMeasurements :exec
+-- Merges the claimed body measurements of a batch into one body record per date, as merging
+-- them one after the other in order would: the latest measurement of a date wins, and existing
+-- values are kept for measurements no sample has.
+WITH merged AS (
+    INSERT INTO body_records (user_id, date, weight_kg, body_fat_percentage, created_at, updated_at)
+    SELECT sqlc.arg(user_id)::uuid, s.date,
+        (array_agg(s.weight_kg ORDER BY s.ordinal DESC) FILTER (WHERE s.weight_kg IS NOT NULL))[1],
+        (array_agg(s.body_fat_percentage ORDER BY s.ordinal DESC) FILTER (WHERE s.body_fat_percentage IS NOT NULL))[1],
+        sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+    FROM import_staging_body_measurements s
+    WHERE s.batch_id = sqlc.arg(batch_id) AND s.sample_id IS NOT NULL
+    GROUP BY s.date
+    ON CONFLICT (user_id, date) DO UPDATE SET
+        weight_kg = COALESCE(EXCLUDED.weight_kg, body_records.weight_kg),
+        body_fat_percentage = COALESCE(EXCLUDED.body_fat_percentage, body_records.body_fat_percentage),
+        updated_at = EXCLUDED.updated_at
+    RETURNING id, date
+)
+UPDATE import_staging_body_measurements s
+SET record_id = m.id
+FROM merged m
+WHERE s.batch_id = sqlc.arg(batch_id) AND s.sample_id IS NOT NULL AND s.date = m.date;
+
+-- name: ClaimStagedExercises :execrows
+-- Records the staged exercises of a batch as imported, setting the sample of those not imported
+-- before. Source sample IDs must not repeat within a batch.
+WITH claimed AS (
+    INSERT INTO imported_samples (user_id, source, source_sample_id, record_type, imported_at)
+    SELECT sqlc.arg(user_id)::uuid, sqlc.arg(source)::text, s.source_sample_id, 'exercise_record', sqlc.arg(now)::timestamptz
+    FROM import_staging_exercises s
+    WHERE s.batch_id = sqlc.arg(batch_id)
+    ON CONFLICT (user_id, source, source_sample_id) DO NOTHING
+    RETURNING id, source_sample_id
+)
+UPDATE import_staging_exercises s
+SET sample_id = c.id
+FROM claimed c
+WHERE s.batch_id = sqlc.arg(batch_id) AND s.source_sample_id = c.source_sample_id;
+
+-- name: ListStagedTimedExercises :many
+-- The timed exercises claimed in a batch, in order, and whether each overlaps an existing record,
+-- so the overlaps between exercises of the batch can be resolved in order
+SELECT s.ordinal, s.started_at::timestamptz AS started_at, s.ended_at::timestamptz AS ended_at,
+    EXISTS (
+        SELECT 1 FROM exercise_records e
+        WHERE e.user_id = sqlc.arg(user_id)::uuid AND e.started_at < s.ended_at AND e.ended_at > s.started_at
+    ) AS overlaps_existing
+FROM import_staging_exercises s
+WHERE s.batch_id = sqlc.arg(batch_id) AND s.sample_id IS NOT NULL AND s.started_at IS NOT NULL AND s.ended_at IS NOT NULL
+ORDER BY s.ordinal;
+
+-- name: CreateStagedExercises :execrows
+-- Creates an exercise record per claimed exercise of a batch, except for the skipped ordinals;
+-- their samples stay claimed, so they are not retried on every sync.
+WITH created AS (
+    INSERT INTO exercise_records (id, user_id, exercise_name, duration_minutes, calories_burned, recorded_at, created_at, updated_at, started_at, ended_at)
+    SELECT s.new_record_id, sqlc.arg(user_id)::uuid, s.exercise_name, s.duration_minutes, s.calories_burned, s.recorded_at,
+        sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz, s.started_at, s.ended_at
+    FROM import_staging_exercises s
+    WHERE s.batch_id = sqlc.arg(batch_id) AND s.sample_id IS NOT NULL
+        AND s.ordinal <> ALL(sqlc.arg(skipped_ordinals)::integer[])
+    RETURNING id
+)
+UPDATE import_staging_exercises s
+SET record_id = c.id
+FROM created c
+WHERE s.batch_id = sqlc.arg(batch_id) AND s.new_record_id = c.id;
+
+-- name: MergeStagedDailySteps :exec
+-- Merges the staged daily step totals of a batch into the daily step records; as with
+-- MergeStepRecord, the higher count of a day wins.
+INSERT INTO step_records (user_id, date, steps, created_at, updated_at)
+SELECT sqlc.arg(user_id)::uuid, s.date, MAX(s.steps), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+FROM import_staging_daily_steps s
+WHERE s.batch_id = sqlc.arg(batch_id)
+GROUP BY s.date
+ON CONFLICT (user_id, date) DO UPDATE SET
+    steps = GREATEST(step_records.steps, EXCLUDED.steps),
+    updated_at = EXCLUDED.updated_at;
+
+-- name: LinkStagedSamples :exec
+-- Stores the IDs of the records the claimed samples of a batch were written to
+UPDATE imported_samples i
+SET record_id = s.record_id
+FROM (
+    SELECT b.sample_id, b.record_id FROM import_staging_body_measurements b WHERE b.batch_id = sqlc.arg(batch_id)
+    UNION ALL
+    SELECT e.sample_id, e.record_id FROM import_staging_exercises e WHERE e.batch_id = sqlc.arg(batch_id)
+) s
+WHERE i.id = s.sample_id AND s.record_id IS NOT NULL;
+
+-- name: RecordStagedImportChanges :exec
+-- Records an imported change per sample of a batch written to a record, and publishes its
+-- record changed event, as recordChange does for single records
+WITH staged AS (
+    SELECT 'body_record' AS entity_type, b.record_id, b.ordinal
+    FROM import_staging_body_measurements b
+    WHERE b.batch_id = sqlc.arg(batch_id) AND b.record_id IS NOT NULL
+    UNION ALL
+    SELECT 'exercise_record' AS entity_type, e.record_id, e.ordinal
+    FROM import_staging_exercises e
+    WHERE e.batch_id = sqlc.arg(batch_id) AND e.record_id IS NOT NULL
+), changes AS (
+    INSERT INTO record_changes (user_id, entity_type, entity_id, action, source, changed_at)
+    SELECT sqlc.arg(user_id)::uuid, s.entity_type, s.record_id, 'imported', sqlc.arg(source)::text, sqlc.arg(now)::timestamptz
+    FROM staged s
+    ORDER BY s.entity_type, s.ordinal
+    RETURNING entity_type, entity_id
+)
+INSERT INTO outbox_events (user_id, event_type, payload, next_attempt_at, created_at)
+SELECT sqlc.arg(user_id)::uuid, c.entity_type || '.imported',
+    jsonb_build_object('entity_type', c.entity_type, 'entity_id', c.entity_id, 'action', 'imported', 'source', sqlc.arg(source)::text),
+    sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+FROM changes c;
+
+-- name: DeleteStagedImports :exec
+-- Deletes the staged rows of a batch, before its transaction commits
+WITH body AS (
+    DELETE FROM import_staging_body_measurements b WHERE b.batch_id = sqlc.arg(batch_id)
+), exercises AS (
+    DELETE FROM import_staging_exercises e WHERE e.batch_id = sqlc.arg(batch_id)
+)
+DELETE FROM import_staging_daily_steps s WHERE s.batch_id = sqlc.arg(batch_id);
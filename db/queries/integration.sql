@@ -0,0 +1,62 @@
+-- name: UpsertIntegration :one
+-- Relinking an account replaces its tokens but keeps the sync cursor.
+INSERT INTO integrations (user_id, provider, external_user_id, encrypted_access_token, encrypted_refresh_token, token_expires_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (user_id, provider) DO UPDATE SET
+    external_user_id = EXCLUDED.external_user_id,
+    encrypted_access_token = EXCLUDED.encrypted_access_token,
+    encrypted_refresh_token = EXCLUDED.encrypted_refresh_token,
+    token_expires_at = EXCLUDED.token_expires_at,
+    last_sync_error = NULL,
+    sync_failure_count = 0,
+    updated_at = $8
+RETURNING *;
+
+-- name: ListIntegrationsByUser :many
+SELECT * FROM integrations
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteIntegration :one
+DELETE FROM integrations
+WHERE user_id = $1 AND provider = $2
+RETURNING *;
+
+-- name: ListIntegrationsDueForSync :many
+SELECT * FROM integrations
+WHERE provider = sqlc.arg(provider)
+  AND (last_synced_at IS NULL OR last_synced_at <= sqlc.arg(synced_before)::timestamptz)
+ORDER BY last_synced_at ASC NULLS FIRST;
+
+-- name: ListIntegrationsByExternalUser :many
+SELECT * FROM integrations
+WHERE provider = $1 AND external_user_id = $2
+ORDER BY created_at ASC;
+
+-- name: UpdateIntegrationTokens :exec
+UPDATE integrations
+SET encrypted_access_token = $2, encrypted_refresh_token = $3, token_expires_at = $4, updated_at = $5
+WHERE id = $1;
+
+-- name: UpdateIntegrationSyncState :exec
+-- Failed syncs, with a last_sync_error, are counted until a sync succeeds
+UPDATE integrations
+SET sync_cursor = $2, last_synced_at = $3, last_sync_error = $4,
+    sync_failure_count = CASE WHEN $4::text IS NULL THEN 0 ELSE sync_failure_count + 1 END,
+    updated_at = $3
+WHERE id = $1;
+
+-- name: ListIntegrationsToReencryptForUpdate :many
+-- Locks a batch of integrations with a token not encrypted with the key of prefix, skipping
+-- those locked by concurrent batches
+SELECT id, encrypted_access_token, encrypted_refresh_token FROM integrations
+WHERE NOT (starts_with(encrypted_access_token, sqlc.arg(prefix)::text) AND starts_with(encrypted_refresh_token, sqlc.arg(prefix)::text))
+ORDER BY id
+LIMIT sqlc.arg(max_count)
+FOR UPDATE SKIP LOCKED;
+
+-- name: SetIntegrationCiphertexts :exec
+-- Replaces the token ciphertexts of an integration, keeping updated_at as the tokens are unchanged
+UPDATE integrations
+SET encrypted_access_token = $2, encrypted_refresh_token = $3
+WHERE id = $1;
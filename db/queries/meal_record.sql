@@ -0,0 +1,58 @@
+-- name: CreateMealRecord :one
+INSERT INTO meal_records (user_id, name, calories, eaten_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $5)
+RETURNING *;
+
+-- name: ListMealRecordsByUser :many
+SELECT * FROM meal_records
+WHERE user_id = $1
+ORDER BY eaten_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountMealRecordsByUser :one
+SELECT COUNT(*) FROM meal_records
+WHERE user_id = $1;
+
+-- name: TrashMealRecord :execrows
+-- Moves a meal record of a user to the trash
+WITH deleted AS (
+    DELETE FROM meal_records m
+    WHERE m.id = $1 AND m.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'meal_record', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: ListDailyCalorieBalance :many
+-- Calories consumed (meal records) and burned through exercise per UTC day in
+-- [start_date, end_date], along with the latest weight recorded on or before each day and the
+-- body fat percentage of that record, for the BMR estimate, and the meals planned for the day
+WITH days AS (
+    SELECT sqlc.arg(start_date)::date + n AS day
+    FROM generate_series(0, sqlc.arg(end_date)::date - sqlc.arg(start_date)::date) AS n
+)
+SELECT
+    d.day::date AS day,
+    (SELECT COALESCE(SUM(m.calories), 0) FROM meal_records m
+        WHERE m.user_id = sqlc.arg(user_id)
+            AND m.eaten_at >= d.day::timestamp AT TIME ZONE 'UTC'
+            AND m.eaten_at < (d.day + 1)::timestamp AT TIME ZONE 'UTC')::bigint AS calories_consumed,
+    (SELECT COALESCE(SUM(e.calories_burned), 0) FROM exercise_records e
+        WHERE e.user_id = sqlc.arg(user_id)
+            AND e.recorded_at >= d.day::timestamp AT TIME ZONE 'UTC'
+            AND e.recorded_at < (d.day + 1)::timestamp AT TIME ZONE 'UTC')::bigint AS exercise_calories_burned,
+    (SELECT COUNT(*) FROM planned_meals p
+        WHERE p.user_id = sqlc.arg(user_id) AND p.date = d.day)::bigint AS planned_meal_count,
+    (SELECT COALESCE(SUM(p.calories), 0) FROM planned_meals p
+        WHERE p.user_id = sqlc.arg(user_id) AND p.date = d.day)::bigint AS planned_calories,
+    w.weight_kg::numeric AS weight_kg,
+    w.body_fat_percentage::numeric AS body_fat_percentage
+FROM days d
+LEFT JOIN LATERAL (
+    SELECT b.weight_kg, b.body_fat_percentage FROM body_records b
+    WHERE b.user_id = sqlc.arg(user_id) AND b.weight_kg IS NOT NULL AND b.date <= d.day
+    ORDER BY b.date DESC
+    LIMIT 1
+) w ON true
+ORDER BY d.day ASC;
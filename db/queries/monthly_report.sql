@@ -0,0 +1,38 @@
+-- name: CreateMonthlyReport :one
+INSERT INTO monthly_reports (user_id, month, next_attempt_at, created_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(month), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz)
+RETURNING *;
+
+-- name: GetMonthlyReport :one
+SELECT * FROM monthly_reports
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimMonthlyReport :one
+-- Leases the oldest due report until lease_until, so concurrent jobs never write the same
+-- report at once; running reports are due again once their lease expired. Each claim counts as
+-- an attempt.
+UPDATE monthly_reports
+SET status = 'running', attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamptz
+WHERE id = (
+    SELECT r.id FROM monthly_reports r
+    WHERE r.status IN ('pending', 'running') AND r.next_attempt_at <= sqlc.arg(now)::timestamptz
+    ORDER BY r.created_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteMonthlyReport :exec
+UPDATE monthly_reports
+SET status = 'completed', storage_key = $2, completed_at = $3, last_error = NULL
+WHERE id = $1;
+
+-- name: RetryMonthlyReport :exec
+UPDATE monthly_reports
+SET status = 'pending', next_attempt_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: FailMonthlyReport :exec
+UPDATE monthly_reports
+SET status = 'failed', last_error = $2, completed_at = $3
+WHERE id = $1;
@@ -0,0 +1,60 @@
+-- name: CreateMoodRecord :one
+INSERT INTO mood_records (user_id, mood_score, energy_level, symptoms, recorded_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+
+-- name: ListMoodRecordsByUser :many
+SELECT * FROM mood_records
+WHERE user_id = $1
+ORDER BY recorded_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CountMoodRecordsByUser :one
+SELECT COUNT(*) FROM mood_records
+WHERE user_id = $1;
+
+-- name: TrashMoodRecord :execrows
+-- Moves a mood record of a user to the trash
+WITH deleted AS (
+    DELETE FROM mood_records m
+    WHERE m.id = $1 AND m.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'mood_record', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: ListDailyMoodMetrics :many
+-- The average mood and energy of each UTC day in [start_date, end_date] with mood records,
+-- along with the day's steps, exercise minutes and calories consumed; steps are NULL and
+-- meal_count 0 on days without them
+WITH moods AS (
+    SELECT (recorded_at AT TIME ZONE 'UTC')::date AS day,
+        AVG(mood_score)::float8 AS mood,
+        AVG(energy_level)::float8 AS energy
+    FROM mood_records
+    WHERE user_id = sqlc.arg(user_id)
+        AND recorded_at >= sqlc.arg(start_date)::date::timestamp AT TIME ZONE 'UTC'
+        AND recorded_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+    GROUP BY 1
+)
+SELECT
+    m.day::date AS day,
+    m.mood::float8 AS mood,
+    m.energy::float8 AS energy,
+    s.steps,
+    (SELECT COALESCE(SUM(e.duration_minutes), 0) FROM exercise_records e
+        WHERE e.user_id = sqlc.arg(user_id)
+            AND e.recorded_at >= m.day::timestamp AT TIME ZONE 'UTC'
+            AND e.recorded_at < (m.day + 1)::timestamp AT TIME ZONE 'UTC')::bigint AS exercise_minutes,
+    (SELECT COUNT(*) FROM meal_records ml
+        WHERE ml.user_id = sqlc.arg(user_id)
+            AND ml.eaten_at >= m.day::timestamp AT TIME ZONE 'UTC'
+            AND ml.eaten_at < (m.day + 1)::timestamp AT TIME ZONE 'UTC')::bigint AS meal_count,
+    (SELECT COALESCE(SUM(ml.calories), 0) FROM meal_records ml
+        WHERE ml.user_id = sqlc.arg(user_id)
+            AND ml.eaten_at >= m.day::timestamp AT TIME ZONE 'UTC'
+            AND ml.eaten_at < (m.day + 1)::timestamp AT TIME ZONE 'UTC')::bigint AS calories_consumed
+FROM moods m
+LEFT JOIN step_records s ON s.user_id = sqlc.arg(user_id) AND s.date = m.day
+ORDER BY m.day ASC;
@@ -0,0 +1,36 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (user_id, event_type, payload, next_attempt_at, created_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(event_type), sqlc.arg(payload), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz);
+
+-- name: ClaimDueOutboxEvents :many
+-- Leases due events until lease_until, so concurrent relays never publish the same event at
+-- once. Each claim counts as a publish attempt; older events are claimed first.
+UPDATE outbox_events
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamptz
+WHERE id IN (
+    SELECT e.id FROM outbox_events e
+    WHERE e.status = 'pending' AND e.next_attempt_at <= sqlc.arg(now)::timestamptz
+    ORDER BY e.created_at ASC
+    LIMIT sqlc.arg(max_count)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET status = 'published', published_at = $2, last_error = NULL
+WHERE id = $1;
+
+-- name: RescheduleOutboxEvent :exec
+UPDATE outbox_events
+SET next_attempt_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET status = 'failed', last_error = $2
+WHERE id = $1;
+
+-- name: DeletePublishedOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE status = 'published' AND published_at < $1;
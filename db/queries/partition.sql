@@ -0,0 +1,3 @@
+-- name: CreateRecordPartitions :one
+-- Creates the missing monthly partitions of a record table from from_month through through_month
+SELECT create_record_partitions(sqlc.arg(parent)::text, sqlc.arg(from_month)::date, sqlc.arg(through_month)::date)::text[] AS created;
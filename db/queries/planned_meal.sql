@@ -0,0 +1,30 @@
+-- name: CreatePlannedMeal :one
+INSERT INTO planned_meals (user_id, date, name, calories, planned_by, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+
+-- name: ListPlannedMealsByUserDateRange :many
+SELECT * FROM planned_meals
+WHERE user_id = sqlc.arg(user_id) AND date BETWEEN sqlc.arg(start_date)::date AND sqlc.arg(end_date)::date
+ORDER BY date ASC, created_at ASC;
+
+-- name: CountPlannedMealsByUserDate :one
+SELECT COUNT(*) FROM planned_meals
+WHERE user_id = $1 AND date = $2;
+
+-- name: UpdatePlannedMeal :one
+UPDATE planned_meals
+SET date = $3, name = $4, calories = $5, updated_at = $6
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: TrashPlannedMeal :execrows
+-- Moves a planned meal of a user to the trash
+WITH deleted AS (
+    DELETE FROM planned_meals p
+    WHERE p.id = $1 AND p.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'planned_meal', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
@@ -0,0 +1,9 @@
+-- name: GetUserUnits :one
+SELECT weight_unit, height_unit FROM users
+WHERE id = $1;
+
+-- name: SetUserUnits :one
+UPDATE users
+SET weight_unit = $2, height_unit = $3
+WHERE id = $1
+RETURNING weight_unit, height_unit;
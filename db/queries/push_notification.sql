@@ -0,0 +1,60 @@
+-- name: UpsertDeviceToken :one
+-- Registering a known token moves it to the registering user.
+INSERT INTO device_tokens (user_id, platform, token, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (platform, token) DO UPDATE SET
+    user_id = EXCLUDED.user_id,
+    updated_at = $5
+RETURNING *;
+
+-- name: DeleteDeviceTokenForUser :execrows
+DELETE FROM device_tokens
+WHERE user_id = $1 AND platform = $2 AND token = $3;
+
+-- name: DeleteDeviceToken :exec
+DELETE FROM device_tokens
+WHERE id = $1;
+
+-- name: ListDeviceTokensByUser :many
+SELECT * FROM device_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: EnqueuePushNotifications :execrows
+-- Queues the notification for every device of the user
+INSERT INTO push_notifications (device_token_id, kind, title, body, data, next_attempt_at, created_at)
+SELECT id, sqlc.arg(kind), sqlc.arg(title), sqlc.arg(body), sqlc.arg(data), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+FROM device_tokens
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: ClaimDuePushNotifications :many
+-- Leases due notifications of the given platforms until lease_until, so concurrent workers
+-- never send the same notification. Each claim counts as a delivery attempt.
+UPDATE push_notifications n
+SET attempts = n.attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamptz
+FROM device_tokens d
+WHERE n.device_token_id = d.id AND n.id IN (
+    SELECT p.id FROM push_notifications p
+    JOIN device_tokens t ON t.id = p.device_token_id
+    WHERE p.status = 'pending' AND p.next_attempt_at <= sqlc.arg(now)::timestamptz
+      AND t.platform = ANY(sqlc.arg(platforms)::text[])
+    ORDER BY p.next_attempt_at ASC
+    LIMIT sqlc.arg(max_count)
+    FOR UPDATE OF p SKIP LOCKED
+)
+RETURNING n.*, d.platform, d.token;
+
+-- name: MarkPushNotificationSent :exec
+UPDATE push_notifications
+SET status = 'sent', sent_at = $2, last_error = NULL
+WHERE id = $1;
+
+-- name: ReschedulePushNotification :exec
+UPDATE push_notifications
+SET next_attempt_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: MarkPushNotificationFailed :exec
+UPDATE push_notifications
+SET status = 'failed', last_error = $2
+WHERE id = $1;
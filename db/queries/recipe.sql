@@ -0,0 +1,50 @@
+-- name: CreateRecipe :one
+INSERT INTO recipes (user_id, name, servings, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $4)
+RETURNING *;
+
+-- name: GetRecipe :one
+SELECT * FROM recipes
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListRecipesByUser :many
+SELECT * FROM recipes
+WHERE user_id = $1
+ORDER BY lower(name) ASC;
+
+-- name: CountRecipesByUser :one
+SELECT COUNT(*) FROM recipes
+WHERE user_id = $1;
+
+-- name: UpdateRecipe :one
+UPDATE recipes
+SET name = $3, servings = $4, updated_at = $5
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: TrashRecipe :execrows
+-- Moves a recipe of a user to the trash with its ingredients
+WITH deleted AS (
+    DELETE FROM recipes r
+    WHERE r.id = $1 AND r.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'recipe', d.id, jsonb_build_object(
+    'record', to_jsonb(d),
+    'recipe_ingredients', (SELECT COALESCE(jsonb_agg(to_jsonb(i)), '[]') FROM recipe_ingredients i WHERE i.recipe_id = d.id)
+), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: CreateRecipeIngredient :exec
+INSERT INTO recipe_ingredients (recipe_id, position, name, quantity_g, calories_kcal, protein_g, carbohydrates_g, fat_g, food_source, food_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: DeleteRecipeIngredients :exec
+DELETE FROM recipe_ingredients
+WHERE recipe_id = $1;
+
+-- name: ListRecipeIngredients :many
+SELECT * FROM recipe_ingredients
+WHERE recipe_id = ANY(sqlc.arg(recipe_ids)::uuid[])
+ORDER BY recipe_id, position;
@@ -0,0 +1,8 @@
+-- name: CreateRecordChange :exec
+INSERT INTO record_changes (user_id, entity_type, entity_id, action, source, details, changed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ListRecordChanges :many
+SELECT * FROM record_changes
+WHERE user_id = $1 AND entity_type = $2 AND entity_id = $3
+ORDER BY changed_at ASC, id ASC;
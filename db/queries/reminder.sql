@@ -0,0 +1,54 @@
+-- name: CreateReminder :one
+INSERT INTO reminders (user_id, title, message, schedule, timezone, enabled, next_fire_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: ListRemindersByUser :many
+SELECT * FROM reminders
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: CountRemindersByUser :one
+SELECT COUNT(*) FROM reminders
+WHERE user_id = $1;
+
+-- name: UpdateReminder :one
+UPDATE reminders
+SET title = $3, message = $4, schedule = $5, timezone = $6, enabled = $7, next_fire_at = $8, updated_at = $9
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteReminder :execrows
+DELETE FROM reminders
+WHERE id = $1 AND user_id = $2;
+
+-- name: TrashReminder :execrows
+-- Moves a reminder of a user to the trash
+WITH deleted AS (
+    DELETE FROM reminders r
+    WHERE r.id = $1 AND r.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'reminder', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: ClaimDueReminders :many
+-- Leases due reminders until lease_until, so concurrent schedulers never fire the same reminder.
+-- A reminder whose notification could not be queued fires again once the lease expires.
+UPDATE reminders
+SET next_fire_at = sqlc.arg(lease_until)::timestamptz
+WHERE id IN (
+    SELECT id FROM reminders
+    WHERE enabled AND next_fire_at <= sqlc.arg(now)::timestamptz
+    ORDER BY next_fire_at ASC
+    LIMIT sqlc.arg(max_count)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkReminderFired :exec
+-- Schedules the next occurrence of a claimed reminder, unless the reminder was updated since it was claimed
+UPDATE reminders
+SET last_fired_at = sqlc.arg(fired_at)::timestamptz, next_fire_at = sqlc.arg(next_fire_at)
+WHERE id = sqlc.arg(id) AND next_fire_at = sqlc.arg(lease_until)::timestamptz;
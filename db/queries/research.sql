@@ -0,0 +1,114 @@
+-- name: GetUserResearchOptIn :one
+SELECT research_opt_in FROM users
+WHERE id = $1;
+
+-- name: SetUserResearchOptIn :one
+UPDATE users
+SET research_opt_in = $2
+WHERE id = $1
+RETURNING research_opt_in;
+
+-- name: CreateResearchExport :one
+INSERT INTO research_exports (requested_by, start_date, end_date, min_group_size, next_attempt_at, created_at)
+VALUES (sqlc.arg(requested_by), sqlc.arg(start_date), sqlc.arg(end_date), sqlc.arg(min_group_size), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz)
+RETURNING *;
+
+-- name: GetResearchExport :one
+SELECT * FROM research_exports
+WHERE id = $1;
+
+-- name: ListResearchExports :many
+SELECT * FROM research_exports
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
+
+-- name: CountResearchExports :one
+SELECT COUNT(*) FROM research_exports;
+
+-- name: ClaimResearchExport :one
+-- Leases the oldest due export until lease_until, so concurrent jobs never write the same
+-- export at once; running exports are due again once their lease expired. Each claim counts as
+-- an attempt.
+UPDATE research_exports
+SET status = 'running', attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until)::timestamptz
+WHERE id = (
+    SELECT e.id FROM research_exports e
+    WHERE e.status IN ('pending', 'running') AND e.next_attempt_at <= sqlc.arg(now)::timestamptz
+    ORDER BY e.created_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteResearchExport :exec
+UPDATE research_exports
+SET status = 'completed', contributors = $2, suppressed_groups = $3, datasets = $4,
+    completed_at = $5, last_error = NULL
+WHERE id = $1;
+
+-- name: RetryResearchExport :exec
+UPDATE research_exports
+SET status = 'pending', next_attempt_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: FailResearchExport :exec
+UPDATE research_exports
+SET status = 'failed', last_error = $2, completed_at = $3
+WHERE id = $1;
+
+-- name: CountResearchContributors :one
+-- Users who opted in to research and have any record in the window
+SELECT COUNT(*) FROM users u
+WHERE u.research_opt_in AND (
+    EXISTS (SELECT 1 FROM body_records b WHERE b.user_id = u.id
+        AND b.date BETWEEN sqlc.arg(start_date)::date AND sqlc.arg(end_date)::date)
+    OR EXISTS (SELECT 1 FROM exercise_records e WHERE e.user_id = u.id
+        AND e.recorded_at >= (sqlc.arg(start_date)::date)::timestamp AT TIME ZONE 'UTC'
+        AND e.recorded_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC')
+    OR EXISTS (SELECT 1 FROM step_records s WHERE s.user_id = u.id
+        AND s.date BETWEEN sqlc.arg(start_date)::date AND sqlc.arg(end_date)::date)
+);
+
+-- name: ListResearchBodyMetrics :many
+-- Body records of the users who opted in to research by month; averages are 0 without values
+SELECT date_trunc('month', b.date)::date AS month,
+    COUNT(DISTINCT b.user_id)::bigint AS users,
+    COUNT(b.weight_kg)::bigint AS weight_records,
+    COALESCE(AVG(b.weight_kg), 0)::float8 AS avg_weight_kg,
+    COUNT(b.body_fat_percentage)::bigint AS body_fat_records,
+    COALESCE(AVG(b.body_fat_percentage), 0)::float8 AS avg_body_fat_percentage
+FROM body_records b
+JOIN users u ON u.id = b.user_id
+WHERE u.research_opt_in AND b.date BETWEEN sqlc.arg(start_date)::date AND sqlc.arg(end_date)::date
+GROUP BY 1
+ORDER BY 1;
+
+-- name: ListResearchExerciseActivity :many
+-- Exercise records of the users who opted in to research by UTC month and activity, compared
+-- case-insensitively; the average is 0 without calories
+SELECT date_trunc('month', e.recorded_at AT TIME ZONE 'UTC')::date AS month,
+    lower(trim(e.exercise_name))::text AS activity,
+    COUNT(DISTINCT e.user_id)::bigint AS users,
+    COUNT(*)::bigint AS sessions,
+    COALESCE(SUM(e.duration_minutes), 0)::bigint AS total_minutes,
+    COUNT(e.calories_burned)::bigint AS calorie_records,
+    COALESCE(AVG(e.calories_burned), 0)::float8 AS avg_calories_burned
+FROM exercise_records e
+JOIN users u ON u.id = e.user_id
+WHERE u.research_opt_in
+    AND e.recorded_at >= (sqlc.arg(start_date)::date)::timestamp AT TIME ZONE 'UTC'
+        AND e.recorded_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+GROUP BY 1, 2
+ORDER BY 1, 2;
+
+-- name: ListResearchDailySteps :many
+-- Daily step totals of the users who opted in to research by month
+SELECT date_trunc('month', s.date)::date AS month,
+    COUNT(DISTINCT s.user_id)::bigint AS users,
+    COUNT(*)::bigint AS days,
+    AVG(s.steps)::float8 AS avg_steps
+FROM step_records s
+JOIN users u ON u.id = s.user_id
+WHERE u.research_opt_in AND s.date BETWEEN sqlc.arg(start_date)::date AND sqlc.arg(end_date)::date
+GROUP BY 1
+ORDER BY 1;
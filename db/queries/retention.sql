@@ -0,0 +1,93 @@
+-- name: GetUserRetentionOptOut :one
+SELECT retention_opt_out FROM users
+WHERE id = $1;
+
+-- name: SetUserRetentionOptOut :one
+UPDATE users
+SET retention_opt_out = $2
+WHERE id = $1
+RETURNING retention_opt_out;
+
+-- name: CountExpiredRecordChanges :one
+-- Changes made before cutoff of users who didn't opt out of retention
+SELECT COUNT(*) FROM record_changes c
+JOIN users u ON u.id = c.user_id
+WHERE c.changed_at < sqlc.arg(cutoff)::timestamptz AND NOT u.retention_opt_out;
+
+-- name: DeleteExpiredRecordChanges :execrows
+-- Deletes up to batch_size changes made before cutoff of users who didn't opt out of retention
+DELETE FROM record_changes
+WHERE id IN (
+    SELECT c.id FROM record_changes c
+    JOIN users u ON u.id = c.user_id
+    WHERE c.changed_at < sqlc.arg(cutoff)::timestamptz AND NOT u.retention_opt_out
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: CountExpiredAPIUsage :one
+-- Daily request counts of days before cutoff, of all users: they only enforce the quota of their
+-- day
+SELECT COUNT(*) FROM api_usage
+WHERE day < sqlc.arg(cutoff)::date;
+
+-- name: DeleteExpiredAPIUsage :execrows
+-- Deletes up to batch_size daily request counts of days before cutoff
+DELETE FROM api_usage
+WHERE (user_id, day) IN (
+    SELECT user_id, day FROM api_usage
+    WHERE day < sqlc.arg(cutoff)::date
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: CountArchivableBodyRecords :one
+-- Body records dated before cutoff of users who didn't opt out of retention
+SELECT COUNT(*) FROM body_records b
+JOIN users u ON u.id = b.user_id
+WHERE b.date < sqlc.arg(cutoff)::date AND NOT u.retention_opt_out;
+
+-- name: ListArchivableBodyRecordsForUpdate :many
+-- Locks up to batch_size body records dated before cutoff of users who didn't opt out of
+-- retention, skipping records locked by concurrent archiving
+SELECT b.* FROM body_records b
+JOIN users u ON u.id = b.user_id
+WHERE b.date < sqlc.arg(cutoff)::date AND NOT u.retention_opt_out
+ORDER BY b.date ASC, b.id ASC
+LIMIT sqlc.arg(batch_size)
+FOR UPDATE OF b SKIP LOCKED;
+
+-- name: CountArchivableAttachments :one
+-- Attachments of the body records dated before cutoff of users who didn't opt out of retention
+SELECT COUNT(*) FROM attachments a
+JOIN users u ON u.id = a.user_id
+WHERE a.body_record_date < sqlc.arg(cutoff)::date AND NOT u.retention_opt_out;
+
+-- name: ListArchivableAttachmentsForUpdate :many
+-- Locks the attachments of the body records being archived, which are deleted with them
+SELECT * FROM attachments
+WHERE body_record_id = ANY(sqlc.arg(body_record_ids)::uuid[])
+ORDER BY created_at ASC, id ASC
+FOR UPDATE;
+
+-- name: DeleteArchivedBodyRecords :exec
+DELETE FROM body_records
+WHERE id = ANY(sqlc.arg(ids)::uuid[]) AND date < sqlc.arg(cutoff)::date;
+
+-- name: CountArchivableExerciseRecords :one
+-- Exercise records recorded before cutoff of users who didn't opt out of retention
+SELECT COUNT(*) FROM exercise_records e
+JOIN users u ON u.id = e.user_id
+WHERE e.recorded_at < sqlc.arg(cutoff)::timestamptz AND NOT u.retention_opt_out;
+
+-- name: ListArchivableExerciseRecordsForUpdate :many
+-- Locks up to batch_size exercise records recorded before cutoff of users who didn't opt out
+-- of retention, skipping records locked by concurrent archiving
+SELECT e.* FROM exercise_records e
+JOIN users u ON u.id = e.user_id
+WHERE e.recorded_at < sqlc.arg(cutoff)::timestamptz AND NOT u.retention_opt_out
+ORDER BY e.recorded_at ASC, e.id ASC
+LIMIT sqlc.arg(batch_size)
+FOR UPDATE OF e SKIP LOCKED;
+
+-- name: DeleteArchivedExerciseRecords :exec
+DELETE FROM exercise_records
+WHERE id = ANY(sqlc.arg(ids)::uuid[]) AND recorded_at < sqlc.arg(cutoff)::timestamptz;
@@ -0,0 +1,47 @@
+-- name: CreateSession :one
+INSERT INTO sessions (user_id, device_name, user_agent, roles, scopes, expires_at, created_at, last_used_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(device_name), sqlc.arg(user_agent), sqlc.arg(roles)::text[], sqlc.arg(scopes)::text[], sqlc.arg(expires_at), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz)
+RETURNING *;
+
+-- name: CreateRefreshToken :exec
+INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at)
+VALUES (sqlc.arg(token_hash), sqlc.arg(session_id), sqlc.arg(expires_at), sqlc.arg(now)::timestamptz);
+
+-- name: GetRefreshTokenForUpdate :one
+-- Locks the token so that concurrent refreshes with it can't both succeed
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: UseRefreshToken :exec
+UPDATE refresh_tokens
+SET used_at = sqlc.arg(now)::timestamptz
+WHERE token_hash = sqlc.arg(token_hash);
+
+-- name: GetActiveSession :one
+SELECT * FROM sessions
+WHERE id = $1 AND revoked_at IS NULL
+LIMIT 1;
+
+-- name: TouchSession :one
+UPDATE sessions
+SET expires_at = sqlc.arg(expires_at), last_used_at = sqlc.arg(now)::timestamptz
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ListActiveSessionsByUser :many
+-- Sessions not revoked whose refresh token is still valid, most recently used first
+SELECT * FROM sessions
+WHERE user_id = sqlc.arg(user_id) AND revoked_at IS NULL AND expires_at > sqlc.arg(now)::timestamptz
+ORDER BY last_used_at DESC, id;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = sqlc.arg(now)::timestamptz
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND revoked_at IS NULL;
+
+-- name: RevokeSessionByID :exec
+-- Revokes a session regardless of its user, when one of its used refresh tokens is replayed
+UPDATE sessions
+SET revoked_at = sqlc.arg(now)::timestamptz
+WHERE id = sqlc.arg(id) AND revoked_at IS NULL;
@@ -0,0 +1,49 @@
+-- name: GetUserStats :one
+-- Activity is the last change made through the API, so users who only read are not active.
+SELECT
+    COUNT(*) AS total_users,
+    COUNT(*) FILTER (WHERE last_activity_at >= sqlc.arg(weekly_active_since)::timestamptz) AS weekly_active_users,
+    COUNT(*) FILTER (WHERE last_activity_at >= sqlc.arg(monthly_active_since)::timestamptz) AS monthly_active_users
+FROM users;
+
+-- name: CountRecordsCreatedByDay :many
+-- Records created per UTC day from start_date to end_date, by record type. Each record table is
+-- scanned once for the whole range rather than once per day, and only its rows created in the
+-- range are grouped.
+WITH days AS (
+    SELECT sqlc.arg(start_date)::date + n AS day
+    FROM generate_series(0, sqlc.arg(end_date)::date - sqlc.arg(start_date)::date) AS n
+),
+created AS (
+    SELECT 'body_records' AS record_type, (created_at AT TIME ZONE 'UTC')::date AS day FROM body_records
+    WHERE created_at >= sqlc.arg(start_date)::date::timestamp AT TIME ZONE 'UTC' AND created_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+    UNION ALL
+    SELECT 'exercise_records', (created_at AT TIME ZONE 'UTC')::date FROM exercise_records
+    WHERE created_at >= sqlc.arg(start_date)::date::timestamp AT TIME ZONE 'UTC' AND created_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+    UNION ALL
+    SELECT 'diary_entries', (created_at AT TIME ZONE 'UTC')::date FROM diary_entries
+    WHERE created_at >= sqlc.arg(start_date)::date::timestamp AT TIME ZONE 'UTC' AND created_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+    UNION ALL
+    SELECT 'meal_records', (created_at AT TIME ZONE 'UTC')::date FROM meal_records
+    WHERE created_at >= sqlc.arg(start_date)::date::timestamp AT TIME ZONE 'UTC' AND created_at < (sqlc.arg(end_date)::date + 1)::timestamp AT TIME ZONE 'UTC'
+)
+SELECT
+    d.day::date AS day,
+    COUNT(c.day) FILTER (WHERE c.record_type = 'body_records') AS body_records,
+    COUNT(c.day) FILTER (WHERE c.record_type = 'exercise_records') AS exercise_records,
+    COUNT(c.day) FILTER (WHERE c.record_type = 'diary_entries') AS diary_entries,
+    COUNT(c.day) FILTER (WHERE c.record_type = 'meal_records') AS meal_records
+FROM days d
+LEFT JOIN created c ON c.day = d.day
+GROUP BY d.day
+ORDER BY d.day ASC;
+
+-- name: ListMostReadColumns :many
+-- Columns by their reads from start_date to end_date, most read first
+SELECT c.id, c.title, SUM(r.read_count)::bigint AS read_count
+FROM column_reads r
+JOIN columns c ON c.id = r.column_id
+WHERE r.day BETWEEN sqlc.arg(start_date)::date AND sqlc.arg(end_date)::date
+GROUP BY c.id, c.title
+ORDER BY read_count DESC, c.id
+LIMIT sqlc.arg(max_count);
@@ -0,0 +1,38 @@
+-- name: UpsertStepBuckets :execrows
+-- The buckets must not repeat an hour and source
+INSERT INTO step_buckets (user_id, hour, source, steps, created_at, updated_at)
+SELECT sqlc.arg(user_id), unnest(sqlc.arg(hours)::timestamptz[]), unnest(sqlc.arg(sources)::text[]), unnest(sqlc.arg(steps)::integer[]),
+    sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+ON CONFLICT (user_id, hour, source) DO UPDATE
+SET steps = EXCLUDED.steps, updated_at = EXCLUDED.updated_at;
+
+-- name: MergeStepRecordsFromBuckets :exec
+-- Sets the daily step records of the given UTC days to the totals of their buckets, recomputed
+-- from every bucket of the day, so counts of hours resent lower lower the day too
+INSERT INTO step_records (user_id, date, steps, created_at, updated_at)
+SELECT sqlc.arg(user_id), h.day, SUM(h.steps), sqlc.arg(now)::timestamptz, sqlc.arg(now)::timestamptz
+FROM (
+    SELECT (hour AT TIME ZONE 'UTC')::date AS day, MAX(steps) AS steps
+    FROM step_buckets
+    WHERE user_id = sqlc.arg(user_id) AND (hour AT TIME ZONE 'UTC')::date = ANY(sqlc.arg(days)::date[])
+    GROUP BY hour
+) h
+GROUP BY h.day
+ON CONFLICT (user_id, date) DO UPDATE SET
+    steps = EXCLUDED.steps,
+    updated_at = EXCLUDED.updated_at;
+
+-- name: ListDailyStepsByUser :many
+-- Daily totals in a time zone of the hours in [range_start, range_end), each hour counted once
+-- with the highest count of its sources
+SELECT (h.hour AT TIME ZONE sqlc.arg(timezone)::text)::date AS day,
+    SUM(h.steps)::integer AS steps,
+    COUNT(*) FILTER (WHERE h.steps > 0)::integer AS active_hours
+FROM (
+    SELECT hour, MAX(steps) AS steps
+    FROM step_buckets
+    WHERE user_id = sqlc.arg(user_id) AND hour >= sqlc.arg(range_start)::timestamptz AND hour < sqlc.arg(range_end)::timestamptz
+    GROUP BY hour
+) h
+GROUP BY day
+ORDER BY day ASC;
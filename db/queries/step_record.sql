@@ -0,0 +1,27 @@
+-- name: MergeStepRecord :one
+-- Sources report overlapping daily totals, so the higher count wins instead of summing.
+INSERT INTO step_records (user_id, date, steps, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, date) DO UPDATE SET
+    steps = GREATEST(step_records.steps, EXCLUDED.steps),
+    updated_at = $5
+RETURNING *;
+
+-- name: ListStepRecordsByUser :many
+SELECT * FROM step_records
+WHERE user_id = $1
+ORDER BY date DESC
+LIMIT $2 OFFSET $3; -- For pagination
+
+-- name: ListStepRecordsByUserDateRange :many
+SELECT * FROM step_records
+WHERE user_id = $1 AND date >= $2 AND date <= $3
+ORDER BY date ASC;
+
+-- name: ListStepRecordsByUserDateRangeBatch :many
+-- Lists up to batch_size step records of a user within a date range, oldest first; records are
+-- unique per user and date, so the next batch starts the day after the last record of the batch.
+SELECT * FROM step_records
+WHERE user_id = sqlc.arg(user_id) AND date >= sqlc.arg(start_date) AND date <= sqlc.arg(end_date)
+ORDER BY date ASC
+LIMIT sqlc.arg(batch_size);
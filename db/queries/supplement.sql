@@ -0,0 +1,86 @@
+-- name: CreateSupplement :one
+INSERT INTO supplements (user_id, name, dose, reminder_id, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $5)
+RETURNING *;
+
+-- name: GetSupplementForUpdate :one
+SELECT * FROM supplements
+WHERE id = $1 AND user_id = $2
+FOR UPDATE;
+
+-- name: ListSupplementsByUser :many
+-- Returns the supplements of a user by name, with the schedule of their reminders
+SELECT sqlc.embed(s), r.schedule AS reminder_schedule, r.timezone AS reminder_timezone
+FROM supplements s
+LEFT JOIN reminders r ON r.id = s.reminder_id
+WHERE s.user_id = $1
+ORDER BY lower(s.name) ASC;
+
+-- name: CountSupplementsByUser :one
+SELECT COUNT(*) FROM supplements
+WHERE user_id = $1;
+
+-- name: UpdateSupplement :one
+UPDATE supplements
+SET name = $3, dose = $4, reminder_id = $5, updated_at = $6
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: TrashSupplement :one
+-- Moves a supplement of a user to the trash with its intakes and reminder. Returns the reminder,
+-- so it can be deleted too.
+WITH deleted AS (
+    DELETE FROM supplements s
+    WHERE s.id = $1 AND s.user_id = $2
+    RETURNING *
+), trashed AS (
+    INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+    SELECT d.user_id, 'supplement', d.id, jsonb_build_object(
+        'record', to_jsonb(d),
+        'supplement_intakes', (SELECT COALESCE(jsonb_agg(to_jsonb(i)), '[]') FROM supplement_intakes i WHERE i.supplement_id = d.id),
+        'reminders', (SELECT COALESCE(jsonb_agg(to_jsonb(r)), '[]') FROM reminders r WHERE r.id = d.reminder_id)
+    ), sqlc.arg(deleted_at)::timestamptz
+    FROM deleted d
+)
+SELECT reminder_id FROM deleted;
+
+-- name: CreateSupplementIntake :one
+-- Logs an intake of a user's supplement, of its usual dose unless another is given. Returns no
+-- rows if the user has no such supplement.
+INSERT INTO supplement_intakes (user_id, supplement_id, dose, taken_at, created_at)
+SELECT s.user_id, s.id, COALESCE(sqlc.narg(dose)::text, s.dose), sqlc.arg(taken_at)::timestamptz, sqlc.arg(created_at)::timestamptz
+FROM supplements s
+WHERE s.id = sqlc.arg(supplement_id) AND s.user_id = sqlc.arg(user_id)
+RETURNING *, (SELECT name FROM supplements WHERE id = supplement_intakes.supplement_id)::text AS supplement_name;
+
+-- name: ListSupplementIntakesByUser :many
+-- Returns the intakes of a user taken from start to end (exclusive), newest first
+SELECT sqlc.embed(i), s.name AS supplement_name
+FROM supplement_intakes i
+JOIN supplements s ON s.id = i.supplement_id
+WHERE i.user_id = sqlc.arg(user_id) AND i.taken_at >= sqlc.arg(start_time)::timestamptz AND i.taken_at < sqlc.arg(end_time)::timestamptz
+ORDER BY i.taken_at DESC
+LIMIT sqlc.arg(max_count);
+
+-- name: TrashSupplementIntake :execrows
+-- Moves a supplement intake of a user to the trash
+WITH deleted AS (
+    DELETE FROM supplement_intakes i
+    WHERE i.id = $1 AND i.user_id = $2
+    RETURNING *
+)
+INSERT INTO trash (user_id, entity_type, entity_id, data, deleted_at)
+SELECT d.user_id, 'supplement_intake', d.id, jsonb_build_object('record', to_jsonb(d)), sqlc.arg(deleted_at)::timestamptz
+FROM deleted d;
+
+-- name: CountSupplementIntakesByUserRange :many
+-- Returns every supplement of a user with the number of intakes taken from start to end
+-- (exclusive) and the schedule of its reminder
+SELECT s.id, s.name, s.dose, r.schedule AS reminder_schedule, r.timezone AS reminder_timezone, r.enabled AS reminder_enabled,
+       COUNT(i.id) AS intake_count
+FROM supplements s
+LEFT JOIN reminders r ON r.id = s.reminder_id
+LEFT JOIN supplement_intakes i ON i.supplement_id = s.id AND i.taken_at >= sqlc.arg(start_time)::timestamptz AND i.taken_at < sqlc.arg(end_time)::timestamptz
+WHERE s.user_id = sqlc.arg(user_id)
+GROUP BY s.id, r.id
+ORDER BY lower(s.name) ASC;
@@ -0,0 +1,15 @@
+-- name: GetUserRecordSummary :one
+-- Aggregates only; support views must never read record contents.
+SELECT
+    (SELECT COUNT(*) FROM body_records b WHERE b.user_id = $1) AS body_record_count,
+    (SELECT MAX(b.updated_at) FROM body_records b WHERE b.user_id = $1) AS body_record_last_updated_at,
+    (SELECT COUNT(*) FROM exercise_records e WHERE e.user_id = $1) AS exercise_record_count,
+    (SELECT MAX(e.updated_at) FROM exercise_records e WHERE e.user_id = $1) AS exercise_record_last_updated_at,
+    (SELECT COUNT(*) FROM diary_entries d WHERE d.user_id = $1) AS diary_entry_count,
+    (SELECT MAX(d.updated_at) FROM diary_entries d WHERE d.user_id = $1) AS diary_entry_last_updated_at;
+
+-- name: ListIntegrationSyncStatesByUser :many
+-- Sync state only; support views must never read integration tokens.
+SELECT provider, last_synced_at, last_sync_error, sync_failure_count FROM integrations
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,117 @@
+-- name: ListTrashByUser :many
+-- Returns the trash of a user deleted at or after cutoff, newest first
+SELECT * FROM trash
+WHERE user_id = sqlc.arg(user_id) AND deleted_at >= sqlc.arg(cutoff)::timestamptz
+ORDER BY deleted_at DESC, id ASC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: CountTrashByUser :one
+SELECT COUNT(*) FROM trash
+WHERE user_id = sqlc.arg(user_id) AND deleted_at >= sqlc.arg(cutoff)::timestamptz;
+
+-- name: DeleteTrashItem :one
+-- Takes an item deleted at or after cutoff out of the trash of a user, to restore it
+DELETE FROM trash
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND deleted_at >= sqlc.arg(cutoff)::timestamptz
+RETURNING *;
+
+-- name: CountExpiredTrash :one
+SELECT COUNT(*) FROM trash
+WHERE deleted_at < sqlc.arg(cutoff)::timestamptz;
+
+-- name: PurgeExpiredTrash :many
+-- Deletes up to batch_size items deleted before cutoff, returning them so the job can delete the
+-- files of purged photos
+DELETE FROM trash
+WHERE id IN (
+    SELECT id FROM trash
+    WHERE deleted_at < sqlc.arg(cutoff)::timestamptz
+    ORDER BY deleted_at ASC
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING *;
+
+-- Restoring inserts the rows of a trash item back into their tables, parents before children.
+-- Each query takes a JSON array of rows as saved by the trash queries. Rows are populated over the
+-- column defaults, so columns added since the rows were trashed get their default; columns added
+-- as NOT NULL must therefore have a default, as they must anyway to be added to existing tables.
+
+-- name: RestoreAttachments :exec
+INSERT INTO attachments
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::attachments, column_defaults('attachments')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreBodyRecords :exec
+INSERT INTO body_records
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::body_records, column_defaults('body_records')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreDiaryEntries :exec
+INSERT INTO diary_entries
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::diary_entries, column_defaults('diary_entries')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreDiaryEntryRevisions :exec
+INSERT INTO diary_entry_revisions
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::diary_entry_revisions, column_defaults('diary_entry_revisions')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreExerciseRecords :exec
+INSERT INTO exercise_records
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::exercise_records, column_defaults('exercise_records')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreExerciseRoutes :exec
+INSERT INTO exercise_routes
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::exercise_routes, column_defaults('exercise_routes')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreExerciseTemplates :exec
+INSERT INTO exercise_templates
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::exercise_templates, column_defaults('exercise_templates')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreFasts :exec
+INSERT INTO fasts
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::fasts, column_defaults('fasts')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreMealRecords :exec
+INSERT INTO meal_records
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::meal_records, column_defaults('meal_records')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreMoodRecords :exec
+INSERT INTO mood_records
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::mood_records, column_defaults('mood_records')), sqlc.arg(rows)::jsonb);
+
+-- name: RestorePlannedMeals :exec
+INSERT INTO planned_meals
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::planned_meals, column_defaults('planned_meals')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreRecipeIngredients :exec
+INSERT INTO recipe_ingredients
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::recipe_ingredients, column_defaults('recipe_ingredients')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreRecipes :exec
+INSERT INTO recipes
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::recipes, column_defaults('recipes')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreReminders :exec
+INSERT INTO reminders
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::reminders, column_defaults('reminders')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreSupplementIntakes :exec
+INSERT INTO supplement_intakes
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::supplement_intakes, column_defaults('supplement_intakes')), sqlc.arg(rows)::jsonb);
+
+-- name: RestoreSupplements :exec
+INSERT INTO supplements
+SELECT * FROM jsonb_populate_recordset(
+    jsonb_populate_record(NULL::supplements, column_defaults('supplements')), sqlc.arg(rows)::jsonb);
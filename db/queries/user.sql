@@ -3,6 +3,18 @@ INSERT INTO users (subject_id)
 VALUES ($1)
 RETURNING *;
 
+-- name: CreateUserInRegion :one
+INSERT INTO users (subject_id, data_region)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: MirrorUser :exec
+-- Copies a user of the home database into the database of their data region, whose records
+-- reference them; users copied before are kept
+INSERT INTO users (id, subject_id, data_region, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
 -- name: GetUserByID :one
 SELECT * FROM users
 WHERE id = $1 LIMIT 1;
@@ -10,3 +22,54 @@ WHERE id = $1 LIMIT 1;
 -- name: GetUserBySubjectID :one
 SELECT * FROM users
 WHERE subject_id = $1 LIMIT 1;
+
+-- name: DeleteUserBySubjectID :many
+-- Record tables cascade, so this removes all data of the user.
+DELETE FROM users
+WHERE subject_id = $1
+RETURNING id;
+
+-- name: ListUsersInactiveSince :many
+-- Users whose last activity is before the given time, least recently active first.
+-- Users who have never been active are not listed.
+SELECT * FROM users
+WHERE last_activity_at < sqlc.arg(inactive_before)::timestamptz
+ORDER BY last_activity_at ASC
+LIMIT sqlc.arg(max_count);
+
+-- name: UpdateUserEmail :exec
+UPDATE users
+SET email = $2
+WHERE id = $1;
+
+-- name: ListUsersByEmail :many
+-- Users with the email address, compared case-insensitively; at most two, enough to tell
+-- whether the address is ambiguous.
+SELECT * FROM users
+WHERE lower(email) = lower(sqlc.arg(email)::text)
+ORDER BY created_at
+LIMIT 2;
+
+-- name: SearchUsersBySubjectID :many
+-- Users whose subject ID contains the query, newest first
+SELECT * FROM users
+WHERE strpos(subject_id, sqlc.arg(query)::text) > 0
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg(max_count) OFFSET sqlc.arg(skip);
+
+-- name: CountUsersBySubjectID :one
+SELECT COUNT(*) FROM users
+WHERE strpos(subject_id, sqlc.arg(query)::text) > 0;
+
+-- name: SuspendUser :one
+-- Suspending a suspended user keeps the time they were first suspended
+UPDATE users
+SET suspended_at = COALESCE(suspended_at, sqlc.arg(now)::timestamptz)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL
+WHERE id = $1
+RETURNING *;
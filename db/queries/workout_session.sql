@@ -0,0 +1,19 @@
+-- name: CreateWorkoutSession :one
+INSERT INTO workout_sessions (user_id, name, started_at, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $4)
+RETURNING *;
+
+-- name: ListWorkoutSessionsByUser :many
+SELECT * FROM workout_sessions
+WHERE user_id = $1
+ORDER BY started_at DESC, id
+LIMIT $2 OFFSET $3; -- For pagination
+
+-- name: CountWorkoutSessionsByUser :one
+SELECT COUNT(*) FROM workout_sessions
+WHERE user_id = $1;
+
+-- name: ListExerciseRecordsByWorkoutSessions :many
+SELECT * FROM exercise_records
+WHERE user_id = sqlc.arg(user_id) AND workout_session_id = ANY(sqlc.arg(session_ids)::uuid[])
+ORDER BY workout_session_id, workout_position;
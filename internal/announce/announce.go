@@ -0,0 +1,105 @@
+// Package announce notifies users through push notifications of the columns published in the
+// categories of their column digest.
+package announce
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+)
+
+// batchSize is how many recipients are listed at once
+const batchSize = 500
+
+// Job announces newly published columns. Columns are kept in the home database, while each
+// recipient's devices are kept in the database of their data region.
+type Job struct {
+	repo     *repo.ColumnRepository
+	notifier *push.Notifier
+	interval time.Duration
+	log      *slog.Logger
+	clock    clock.Clock
+}
+
+// NewJob creates a job announcing the columns published since its previous run once per interval
+func NewJob(repo *repo.ColumnRepository, notifier *push.Notifier, interval time.Duration, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		repo:     repo,
+		notifier: notifier,
+		interval: interval,
+		log:      log,
+		clock:    clock,
+	}
+}
+
+// Run announces the published columns immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.AnnouncePublished(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AnnouncePublished announces columns until every published column was announced. A column is
+// announced once: recipients whose notification cannot be queued miss it.
+func (j *Job) AnnouncePublished(ctx context.Context) {
+	for ctx.Err() == nil {
+		column, ok, err := j.repo.ClaimUnannounced(ctx, j.clock.Now())
+		if err != nil {
+			j.log.ErrorContext(ctx, "Failed to claim unannounced column", "error", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		if err := j.announce(ctx, column); err != nil {
+			j.log.ErrorContext(ctx, "Failed to list column recipients", "columnID", column.ID, "error", err)
+			return
+		}
+	}
+}
+
+// announce notifies the recipients of a claimed column. The claim is undone if the first
+// recipients cannot be listed, before anyone was notified.
+func (j *Job) announce(ctx context.Context, column db.Column) error {
+	msg := push.Message{
+		Kind:  push.KindNewColumn,
+		Title: "New column",
+		Body:  column.Title,
+		Data:  map[string]string{"column_id": column.ID.String()},
+	}
+	after := uuid.Nil
+	for {
+		recipients, err := j.repo.ListRecipients(ctx, column.Category, after, batchSize)
+		if err != nil {
+			if after == uuid.Nil {
+				if err := j.repo.ReleaseAnnouncement(ctx, column.ID); err != nil {
+					j.log.ErrorContext(ctx, "Failed to release column announcement", "columnID", column.ID, "error", err)
+				}
+			}
+			return err
+		}
+		for _, r := range recipients {
+			if err := j.notifier.Notify(repo.WithRegion(ctx, r.DataRegion.String), r.ID, msg); err != nil {
+				j.log.ErrorContext(ctx, "Failed to announce column", "columnID", column.ID, "userID", r.ID, "error", err)
+			}
+		}
+		if len(recipients) < batchSize {
+			return nil
+		}
+		after = recipients[len(recipients)-1].ID
+	}
+}
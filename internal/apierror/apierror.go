@@ -0,0 +1,66 @@
+package apierror
+
+import (
+	"errors"
+
+	"connectrpc.com/connect"
+)
+
+// ReasonMetadataKey is the error metadata key carrying the machine-readable reason
+const ReasonMetadataKey = "Error-Reason"
+
+// Reasons for errors that are more specific than their Connect code
+const (
+	// ReasonValidationFutureDate is returned when a record date lies in the future
+	ReasonValidationFutureDate = "validation_future_date"
+	// ReasonNotFound is returned when the requested record does not exist or belongs to another user
+	ReasonNotFound = "not_found"
+	// ReasonLimitExceeded is returned when a request exceeds a per-request size limit or a per-user quota
+	ReasonLimitExceeded = "limit_exceeded"
+	// ReasonNotShared is returned when another user's records are requested without a data share
+	// of the record type in effect
+	ReasonNotShared = "not_shared"
+	// ReasonAccountSuspended is returned for every request of a user whose account an admin suspended
+	ReasonAccountSuspended = "account_suspended"
+	// ReasonMissingScope is returned when the caller's token lacks the scope the RPC requires
+	ReasonMissingScope = "missing_scope"
+	// ReasonQuotaExceeded is returned for every request of a user who made their daily quota of
+	// requests, until the next UTC day
+	ReasonQuotaExceeded = "quota_exceeded"
+	// ReasonDatabaseUnavailable is returned when a request failed because the database was
+	// unreachable, e.g. during a failover; retrying later is expected to succeed
+	ReasonDatabaseUnavailable = "database_unavailable"
+	// ReasonDatabaseTimeout is returned when a request failed because a database statement ran
+	// longer than the statement timeout, or waited longer than the lock timeout
+	ReasonDatabaseTimeout = "database_timeout"
+	// ReasonMaintenance is returned for every request while the server is in maintenance mode
+	ReasonMaintenance = "maintenance"
+	// ReasonOverlappingFast is returned when a fast would overlap another fast of the user
+	ReasonOverlappingFast = "overlapping_fast"
+	// ReasonRecordCountChanged is returned when a bulk delete finds a different number of records
+	// than the expected count of its dry run
+	ReasonRecordCountChanged = "record_count_changed"
+	// ReasonUnknownDataRegion is returned when the data region of a user, or of the token creating
+	// them, has no database on the server
+	ReasonUnknownDataRegion = "unknown_data_region"
+)
+
+// New creates a Connect error tagged with a reason
+func New(code connect.Code, reason string, err error) *connect.Error {
+	connectErr := connect.NewError(code, err)
+	connectErr.Meta().Set(ReasonMetadataKey, reason)
+	return connectErr
+}
+
+// Reason returns the reason of an error. Errors without an explicit reason
+// fall back to their Connect code, e.g. "invalid_argument".
+func Reason(err error) string {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return connect.CodeUnknown.String()
+	}
+	if reason := connectErr.Meta().Get(ReasonMetadataKey); reason != "" {
+		return reason
+	}
+	return connectErr.Code().String()
+}
@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+	"github.com/atreya2011/health-management-api/internal/log"
 	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -15,84 +21,160 @@ import (
 // contextKey is a private type for context keys
 type contextKey int
 
-// UserContextKey is the key for user ID in the context
-const UserContextKey contextKey = iota
+const (
+	// UserContextKey is the key for user ID in the context
+	UserContextKey contextKey = iota
+	// RolesContextKey is the key for the caller's roles in the context
+	RolesContextKey
+	// SessionContextKey is the key for the session of the caller's access token in the context
+	SessionContextKey
+	// ScopesContextKey is the key for the scopes of the caller's token in the context
+	ScopesContextKey
+)
+
+// Roles granted through the "roles" claim
+const (
+	// RoleSupport grants access to the read-only, redacted support views
+	RoleSupport = "support"
+	// RoleAdmin grants access to user administration, such as suspending accounts
+	RoleAdmin = "admin"
+)
 
 // JWTConfig contains JWT validation configuration
 type JWTConfig struct {
-	SecretKey string
+	SecretKey       string
+	AccessTokenTTL  time.Duration // Lifetime of access tokens issued for sessions
+	RefreshTokenTTL time.Duration // Lifetime of refresh tokens; a session expires when its latest one does
+	LegacyScopes    []string      // Scopes granted to tokens without any during their deprecation
 }
 
-// AuthInterceptor creates a Connect interceptor for JWT authentication
-func AuthInterceptor(jwtConfig *JWTConfig, userRepo *repo.UserRepository, logger *slog.Logger) connect.UnaryInterceptorFunc { // Use concrete repo type
-	return func(next connect.UnaryFunc) connect.UnaryFunc {
-		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
-			// Skip auth for public endpoints (if any)
-			// Example: if strings.HasSuffix(req.Spec().Procedure, "PublicMethod") { return next(ctx, req) }
-
-			// Extract the Authorization header
-			authHeader := req.Header().Get("Authorization")
-			if authHeader == "" {
-				logger.WarnContext(ctx, "Missing Authorization header")
-				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("missing authorization header"))
-			}
+// AuthInterceptor creates a Connect interceptor for JWT authentication of unary and streaming RPCs
+func AuthInterceptor(jwtConfig *JWTConfig, userRepo *repo.UserRepository, sessionRepo *repo.SessionRepository, logger *slog.Logger) connect.Interceptor { // Use concrete repo type
+	return interceptor.New(Authenticate(jwtConfig, userRepo, sessionRepo, logger))
+}
 
-			// Check for Bearer prefix
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				logger.WarnContext(ctx, "Invalid Authorization header format")
-				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid authorization header format"))
-			}
+// Authenticate creates the check of AuthInterceptor, for requests served outside of Connect such
+// as GraphQL queries
+func Authenticate(jwtConfig *JWTConfig, userRepo *repo.UserRepository, sessionRepo *repo.SessionRepository, logger *slog.Logger) interceptor.Check {
+	return func(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+		// Skip auth for public endpoints: refresh requests are authenticated by their refresh token
+		if procedure == healthappv1connect.AuthServiceRefreshSessionProcedure {
+			return ctx, nil
+		}
+
+		// Extract the Authorization header
+		authHeader := header.Get("Authorization")
+		if authHeader == "" {
+			logger.WarnContext(ctx, "Missing Authorization header")
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("missing authorization header"))
+		}
+
+		// Check for Bearer prefix
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			logger.WarnContext(ctx, "Invalid Authorization header format")
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid authorization header format"))
+		}
+
+		// Parse and validate the JWT
+		token, err := jwt.Parse(parts[1], keyFunc(jwtConfig))
+
+		if err != nil {
+			logger.WarnContext(ctx, "Failed to parse JWT", "error", err)
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token"))
+		}
 
-			// Parse and validate the JWT
-			token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-				// Validate the signing method
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, errors.New("unexpected signing method")
-				}
-				return []byte(jwtConfig.SecretKey), nil
-			})
+		if !token.Valid {
+			logger.WarnContext(ctx, "Invalid JWT")
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token"))
+		}
+
+		// Extract claims
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			logger.WarnContext(ctx, "Failed to extract JWT claims")
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token claims"))
+		}
+
+		// Extract the subject (User ID)
+		sub, ok := claims["sub"].(string)
+		if !ok || sub == "" {
+			logger.WarnContext(ctx, "Missing subject claim in token")
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token subject"))
+		}
+
+		// Find or create the user, from the cache of recently resolved users if possible; new users
+		// are pinned to the data region of the token
+		user, err := userRepo.Resolve(ctx, sub, dataRegionFromClaims(claims))
+		if errors.Is(err, repo.ErrUnknownRegion) {
+			logger.WarnContext(ctx, "User of a data region without a database", "subject_id", sub, "error", err)
+			return nil, apierror.New(connect.CodeFailedPrecondition, apierror.ReasonUnknownDataRegion, errors.New("data region not available"))
+		}
+		if err != nil {
+			// Resolve handles the "already exists" case by returning the existing user.
+			// Any error returned here is likely a database issue or context cancellation.
+			logger.ErrorContext(ctx, "Failed to find or create user", "subject_id", sub, "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to retrieve or create user"))
+		}
+
+		// Suspended users can't use the API until an admin unsuspends them
+		if user.SuspendedAt.Valid {
+			logger.WarnContext(ctx, "Request from suspended user", "userID", user.ID)
+			return nil, apierror.New(connect.CodePermissionDenied, apierror.ReasonAccountSuspended, errors.New("account suspended"))
+		}
 
+		// Access tokens of sessions are rejected as soon as their session is revoked
+		if sid, ok := claims[sessionClaim].(string); ok {
+			sessionID, err := uuid.Parse(sid)
 			if err != nil {
-				logger.WarnContext(ctx, "Failed to parse JWT", "error", err)
-				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token"))
+				logger.WarnContext(ctx, "Invalid session claim in token", "error", err)
+				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token session"))
 			}
-
-			if !token.Valid {
-				logger.WarnContext(ctx, "Invalid JWT")
-				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token"))
+			session, err := sessionRepo.FindActive(ctx, sessionID)
+			if err != nil && !errors.Is(err, repo.ErrSessionNotFound) {
+				logger.ErrorContext(ctx, "Failed to get session", "sessionID", sessionID, "error", err)
+				return nil, connect.NewError(connect.CodeInternal, errors.New("failed to retrieve session"))
 			}
-
-			// Extract claims
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				logger.WarnContext(ctx, "Failed to extract JWT claims")
-				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token claims"))
+			if err != nil || session.UserID != user.ID {
+				logger.WarnContext(ctx, "Token of revoked session", "sessionID", sessionID, "userID", user.ID)
+				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("session revoked"))
 			}
+			ctx = context.WithValue(ctx, SessionContextKey, sessionID)
+		}
 
-			// Extract the subject (User ID)
-			sub, ok := claims["sub"].(string)
-			if !ok || sub == "" {
-				logger.WarnContext(ctx, "Missing subject claim in token")
-				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid token subject"))
+		// Keep the email address up to date; a failure only delays emails, so it doesn't fail the request
+		if email := emailFromClaims(claims); email != "" && email != user.Email.String {
+			if err := userRepo.SetEmail(ctx, user.ID, email); err != nil {
+				logger.WarnContext(ctx, "Failed to update user email", "userID", user.ID, "error", err)
 			}
+		}
 
-			// Find or create the user
-			// Find or create the user using the updated Create method
-			user, err := userRepo.Create(ctx, sub)
-			if err != nil {
-				// Create now handles the "already exists" case by returning the existing user.
-				// Any error returned here is likely a database issue or context cancellation.
-				logger.ErrorContext(ctx, "Failed to find or create user", "subject_id", sub, "error", err)
-				return nil, connect.NewError(connect.CodeInternal, errors.New("failed to retrieve or create user"))
-			}
+		// Add the user ID, roles and scopes to the context
+		ctx = context.WithValue(ctx, UserContextKey, user.ID) // user is now db.User, which has ID
+		ctx = log.WithUserID(ctx, user.ID.String())
+		ctx = context.WithValue(ctx, RolesContextKey, rolesFromClaims(claims))
+		scopes := scopesFromClaims(claims)
+		if len(scopes) == 0 && len(jwtConfig.LegacyScopes) > 0 {
+			// Tokens from before RPCs required scopes keep working until their clients request some
+			logger.WarnContext(ctx, "Token without scopes granted the legacy scopes", "userID", user.ID, "scopes", jwtConfig.LegacyScopes)
+			scopes = jwtConfig.LegacyScopes
+		}
+		ctx = context.WithValue(ctx, ScopesContextKey, scopes)
+		// The records of the user are read and written in the database of their data region
+		ctx = repo.WithRegion(ctx, user.DataRegion.String)
 
-			// Add the user ID to the context
-			ctx = context.WithValue(ctx, UserContextKey, user.ID) // user is now db.User, which has ID
+		// Call the next handler with the authenticated context
+		return ctx, nil
+	}
+}
 
-			// Call the next handler with the authenticated context
-			return next(ctx, req)
+// keyFunc returns the key tokens are verified with, after checking their signing method
+func keyFunc(jwtConfig *JWTConfig) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
 		}
+		return []byte(jwtConfig.SecretKey), nil
 	}
 }
 
@@ -104,3 +186,68 @@ func GetUserID(ctx context.Context) (uuid.UUID, error) {
 	}
 	return userID, nil
 }
+
+// HasRole reports whether the authenticated caller was granted the given role
+func HasRole(ctx context.Context, role string) bool {
+	for _, r := range GetRoles(ctx) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GetScopes extracts the scopes of the authenticated caller's token from the context
+func GetScopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(ScopesContextKey).([]string)
+	return scopes
+}
+
+// HasScope reports whether the authenticated caller's token was granted the given scope
+func HasScope(ctx context.Context, scope string) bool {
+	for _, s := range GetScopes(ctx) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// rolesFromClaims reads the optional "roles" claim as a list of strings
+func rolesFromClaims(claims jwt.MapClaims) []string {
+	raw, ok := claims["roles"].([]interface{})
+	if !ok {
+		return nil
+	}
+	roles := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if s, ok := r.(string); ok && s != "" {
+			roles = append(roles, s)
+		}
+	}
+	return roles
+}
+
+// scopesFromClaims reads the optional "scope" claim, a space-separated list as in OAuth access
+// tokens
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	scope, _ := claims["scope"].(string)
+	return strings.Fields(scope)
+}
+
+// dataRegionFromClaims reads the optional "data_region" claim naming the data region new users
+// are pinned to
+func dataRegionFromClaims(claims jwt.MapClaims) string {
+	region, _ := claims["data_region"].(string)
+	return region
+}
+
+// emailFromClaims reads the optional "email" claim, ignoring addresses the identity provider
+// marked as unverified
+func emailFromClaims(claims jwt.MapClaims) string {
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return ""
+	}
+	email, _ := claims["email"].(string)
+	return email
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// sessionClaim is the claim naming the session of access tokens issued for sessions. Tokens
+// without it come from the identity provider.
+const sessionClaim = "sid"
+
+// refreshTokenBytes is the number of random bytes of a refresh token
+const refreshTokenBytes = 32
+
+// IssueAccessToken signs an access token of the session for the user with subjectID, valid for
+// the configured access token TTL from now
+func IssueAccessToken(jwtConfig *JWTConfig, subjectID string, session db.Session, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(jwtConfig.AccessTokenTTL)
+	claims := jwt.MapClaims{
+		"sub":        subjectID,
+		sessionClaim: session.ID.String(),
+		"roles":      session.Roles,
+		"scope":      strings.Join(session.Scopes, " "),
+		"iat":        now.Unix(),
+		"exp":        expiresAt.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtConfig.SecretKey))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// CheckSigningKey checks that access tokens signed with the configured key are verified by the
+// auth interceptor, e.g. at startup
+func CheckSigningKey(jwtConfig *JWTConfig, now time.Time) error {
+	signed, _, err := IssueAccessToken(jwtConfig, "warmup", db.Session{}, now)
+	if err != nil {
+		return err
+	}
+	token, err := jwt.Parse(signed, keyFunc(jwtConfig), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil {
+		return fmt.Errorf("failed to verify access token: %w", err)
+	}
+	if !token.Valid {
+		return errors.New("access token not valid")
+	}
+	return nil
+}
+
+// NewRefreshToken returns a random refresh token and the hash it is stored as
+func NewRefreshToken() (string, []byte, error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hash a refresh token is stored as. Refresh tokens are random, so
+// an unsalted hash is enough to keep a database leak from revealing usable tokens.
+func HashRefreshToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// GetSessionID extracts the session of the access token from the context. It returns false for
+// identity provider tokens.
+func GetSessionID(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(SessionContextKey).(uuid.UUID)
+	return sessionID, ok
+}
+
+// GetRoles extracts the roles granted to the authenticated caller from the context
+func GetRoles(ctx context.Context) []string {
+	roles, _ := ctx.Value(RolesContextKey).([]string)
+	return roles
+}
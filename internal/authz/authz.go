@@ -0,0 +1,103 @@
+// Package authz decides what a request may do. Every RPC requires the scope RPCScopes maps it
+// to. Users always read their own records; the records of other users are readable while their
+// owner shares the record type with the caller. Shared meal plans are also writable, so coaches
+// plan their clients' meals. The records of other users are read and written in the database of
+// their owner's data region.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/google/uuid"
+)
+
+// Record types that can be shared, as stored in data_shares
+const (
+	RecordTypeBodyRecords     = "body_records"
+	RecordTypeExerciseRecords = "exercise_records"
+	RecordTypeDiaryEntries    = "diary_entries"
+	RecordTypeMealRecords     = "meal_records"
+	RecordTypeMealPlans       = "meal_plans"
+)
+
+// writableRecordTypes are the record types that grantees of a share may also write
+var writableRecordTypes = map[string]bool{
+	RecordTypeMealPlans: true,
+}
+
+var (
+	// ErrInvalidOwner is returned when the requested owner ID is not a UUID
+	ErrInvalidOwner = errors.New("invalid owner ID")
+	// ErrNotShared is returned when another user's records are requested without an active share
+	ErrNotShared = errors.New("records are not shared with the user")
+)
+
+// Authorizer authorizes access to other users' records against their data shares
+type Authorizer struct {
+	shares *repo.DataShareRepository
+	users  *repo.UserRepository
+	clock  clock.Clock
+}
+
+// NewAuthorizer creates a new authorizer, looking up the data regions of owners with users
+func NewAuthorizer(shares *repo.DataShareRepository, users *repo.UserRepository, clock clock.Clock) *Authorizer {
+	return &Authorizer{
+		shares: shares,
+		users:  users,
+		clock:  clock,
+	}
+}
+
+// ReadableOwner returns the user whose records of recordType the caller reads, given the owner
+// ID of a list or get request, and the context to read them with. An empty owner ID or the
+// caller's own ID reads the caller's records with ctx; any other owner requires a share of the
+// record type with the caller in effect now, and is read in the database of their data region.
+func (a *Authorizer) ReadableOwner(ctx context.Context, callerID uuid.UUID, ownerID, recordType string) (context.Context, uuid.UUID, error) {
+	if ownerID == "" {
+		return ctx, callerID, nil
+	}
+	owner, err := uuid.Parse(ownerID)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("%w: %w", ErrInvalidOwner, err)
+	}
+	if owner == callerID {
+		return ctx, callerID, nil
+	}
+	shared, err := a.shares.HasActive(ctx, owner, callerID, recordType, a.clock.Now())
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if !shared {
+		return nil, uuid.Nil, ErrNotShared
+	}
+	ownerCtx, err := a.users.RegionContext(ctx, owner)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("failed to look up data region of owner: %w", err)
+	}
+	return ownerCtx, owner, nil
+}
+
+// WritableOwner returns the user whose records of recordType the caller writes, given the owner
+// ID of a write request, and the context to write them with, like ReadableOwner. Other owners are
+// only writable for the record types grantees may write; the caller is copied into the database
+// of the owner's region, so the records can refer to them.
+func (a *Authorizer) WritableOwner(ctx context.Context, callerID uuid.UUID, ownerID, recordType string) (context.Context, uuid.UUID, error) {
+	ownerCtx, owner, err := a.ReadableOwner(ctx, callerID, ownerID, recordType)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if owner == callerID {
+		return ownerCtx, owner, nil
+	}
+	if !writableRecordTypes[recordType] {
+		return nil, uuid.Nil, ErrNotShared
+	}
+	if err := a.users.CopyToRegion(ownerCtx, callerID); err != nil {
+		return nil, uuid.Nil, err
+	}
+	return ownerCtx, owner, nil
+}
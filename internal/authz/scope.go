@@ -0,0 +1,223 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+)
+
+// Scopes granted through the "scope" claim of tokens
+const (
+	// ScopeRecordsRead grants reading the caller's records and those shared with them
+	ScopeRecordsRead = "records:read"
+	// ScopeRecordsWrite grants creating, changing, importing and deleting the caller's records
+	ScopeRecordsWrite = "records:write"
+	// ScopeSharesWrite grants sharing the caller's records with other users and revoking shares
+	ScopeSharesWrite = "shares:write"
+	// ScopeSupportRead grants the redacted support views of other users' accounts
+	ScopeSupportRead = "support:read"
+	// ScopeUsersAdmin grants user administration, such as suspending accounts
+	ScopeUsersAdmin = "users:admin"
+	// ScopeStatsAdmin grants the system-wide usage statistics
+	ScopeStatsAdmin = "stats:admin"
+	// ScopeResearchAdmin grants the de-identified research exports of the users who opted in
+	ScopeResearchAdmin = "research:admin"
+)
+
+// NoScope marks RPCs that any authenticated caller, or any caller of a public RPC, may call
+const NoScope = ""
+
+// RPCScopes maps every RPC procedure to the scope it requires. The scope interceptor rejects
+// procedures missing from it, so a new RPC can't be called until it is listed here.
+var RPCScopes = map[string]string{
+	healthappv1connect.UserServiceGetAuthenticatedUserProcedure: NoScope,
+
+	healthappv1connect.AuthServiceCreateSessionProcedure:  NoScope,
+	healthappv1connect.AuthServiceRefreshSessionProcedure: NoScope, // Public, authenticated by the refresh token
+	healthappv1connect.AuthServiceListSessionsProcedure:   NoScope,
+	healthappv1connect.AuthServiceRevokeSessionProcedure:  NoScope,
+
+	healthappv1connect.BodyRecordServiceCreateBodyRecordProcedure:             ScopeRecordsWrite,
+	healthappv1connect.BodyRecordServiceListBodyRecordsProcedure:              ScopeRecordsRead,
+	healthappv1connect.BodyRecordServiceGetBodyRecordsByDateRangeProcedure:    ScopeRecordsRead,
+	healthappv1connect.BodyRecordServiceDeleteBodyRecordsByDateRangeProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.DiaryServiceCreateDiaryEntryProcedure: ScopeRecordsWrite,
+	healthappv1connect.DiaryServiceUpdateDiaryEntryProcedure: ScopeRecordsWrite,
+	healthappv1connect.DiaryServiceListDiaryEntriesProcedure: ScopeRecordsRead,
+	healthappv1connect.DiaryServiceGetDiaryEntryProcedure:    ScopeRecordsRead,
+	healthappv1connect.DiaryServiceDeleteDiaryEntryProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.DiaryServiceListDiaryEntryRevisionsProcedure:   ScopeRecordsRead,
+	healthappv1connect.DiaryServiceRestoreDiaryEntryRevisionProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.DiaryShareLinkServiceCreateShareLinkProcedure: ScopeSharesWrite,
+	healthappv1connect.DiaryShareLinkServiceRevokeShareLinkProcedure: ScopeSharesWrite,
+
+	healthappv1connect.ExerciseRecordServiceCreateExerciseRecordProcedure:             ScopeRecordsWrite,
+	healthappv1connect.ExerciseRecordServiceListExerciseRecordsProcedure:              ScopeRecordsRead,
+	healthappv1connect.ExerciseRecordServiceDeleteExerciseRecordProcedure:             ScopeRecordsWrite,
+	healthappv1connect.ExerciseRecordServiceDeleteExerciseRecordsByDateRangeProcedure: ScopeRecordsWrite,
+	healthappv1connect.ExerciseRecordServiceListDuplicateExerciseRecordsProcedure:     ScopeRecordsRead,
+	healthappv1connect.ExerciseRecordServiceMergeExerciseRecordsProcedure:             ScopeRecordsWrite,
+	healthappv1connect.ExerciseRecordServiceGetTrainingLoadProcedure:                  ScopeRecordsRead,
+	healthappv1connect.ExerciseRecordServiceAttachExerciseRouteProcedure:              ScopeRecordsWrite,
+	healthappv1connect.ExerciseRecordServiceGetExerciseRouteProcedure:                 ScopeRecordsRead,
+
+	healthappv1connect.ExerciseTemplateServiceCreateExerciseTemplateProcedure: ScopeRecordsWrite,
+	healthappv1connect.ExerciseTemplateServiceListExerciseTemplatesProcedure:  ScopeRecordsRead,
+	healthappv1connect.ExerciseTemplateServiceUpdateExerciseTemplateProcedure: ScopeRecordsWrite,
+	healthappv1connect.ExerciseTemplateServiceDeleteExerciseTemplateProcedure: ScopeRecordsWrite,
+	healthappv1connect.ExerciseTemplateServiceLogExerciseTemplateProcedure:    ScopeRecordsWrite,
+
+	healthappv1connect.WorkoutSessionServiceCreateWorkoutSessionProcedure: ScopeRecordsWrite,
+	healthappv1connect.WorkoutSessionServiceListWorkoutSessionsProcedure:  ScopeRecordsRead,
+
+	healthappv1connect.StepServiceBatchUpsertStepsProcedure: ScopeRecordsWrite,
+	healthappv1connect.StepServiceGetDailyStepsProcedure:    ScopeRecordsRead,
+
+	healthappv1connect.HeartRateServiceBatchInsertHeartRateProcedure: ScopeRecordsWrite,
+	healthappv1connect.HeartRateServiceGetHeartRateProcedure:         ScopeRecordsRead,
+
+	healthappv1connect.MoodRecordServiceCreateMoodRecordProcedure: ScopeRecordsWrite,
+	healthappv1connect.MoodRecordServiceListMoodRecordsProcedure:  ScopeRecordsRead,
+	healthappv1connect.MoodRecordServiceDeleteMoodRecordProcedure: ScopeRecordsWrite,
+	healthappv1connect.MoodRecordServiceGetCorrelationsProcedure:  ScopeRecordsRead,
+
+	healthappv1connect.FastingServiceStartFastProcedure:        ScopeRecordsWrite,
+	healthappv1connect.FastingServiceEndFastProcedure:          ScopeRecordsWrite,
+	healthappv1connect.FastingServiceGetFastingStatusProcedure: ScopeRecordsRead,
+	healthappv1connect.FastingServiceListFastsProcedure:        ScopeRecordsRead,
+	healthappv1connect.FastingServiceDeleteFastProcedure:       ScopeRecordsWrite,
+
+	healthappv1connect.DailyTargetServiceGetDailyTargetsProcedure:     ScopeRecordsRead,
+	healthappv1connect.DailyTargetServiceUpdateActivityLevelProcedure: ScopeRecordsWrite,
+	healthappv1connect.DailyTargetServiceLogWaterIntakeProcedure:      ScopeRecordsWrite,
+
+	healthappv1connect.MealRecordServiceCreateMealRecordProcedure: ScopeRecordsWrite,
+	healthappv1connect.MealRecordServiceListMealRecordsProcedure:  ScopeRecordsRead,
+	healthappv1connect.MealRecordServiceDeleteMealRecordProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.RecipeServiceCreateRecipeProcedure:  ScopeRecordsWrite,
+	healthappv1connect.RecipeServiceGetRecipeProcedure:     ScopeRecordsRead,
+	healthappv1connect.RecipeServiceListRecipesProcedure:   ScopeRecordsRead,
+	healthappv1connect.RecipeServiceUpdateRecipeProcedure:  ScopeRecordsWrite,
+	healthappv1connect.RecipeServiceDeleteRecipeProcedure:  ScopeRecordsWrite,
+	healthappv1connect.RecipeServiceLogRecipeMealProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.MealPlanServiceCreatePlannedMealProcedure: ScopeRecordsWrite,
+	healthappv1connect.MealPlanServiceListPlannedMealsProcedure:  ScopeRecordsRead,
+	healthappv1connect.MealPlanServiceUpdatePlannedMealProcedure: ScopeRecordsWrite,
+	healthappv1connect.MealPlanServiceDeletePlannedMealProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.SupplementServiceCreateSupplementProcedure:       ScopeRecordsWrite,
+	healthappv1connect.SupplementServiceListSupplementsProcedure:        ScopeRecordsRead,
+	healthappv1connect.SupplementServiceUpdateSupplementProcedure:       ScopeRecordsWrite,
+	healthappv1connect.SupplementServiceDeleteSupplementProcedure:       ScopeRecordsWrite,
+	healthappv1connect.SupplementServiceLogSupplementIntakeProcedure:    ScopeRecordsWrite,
+	healthappv1connect.SupplementServiceListSupplementIntakesProcedure:  ScopeRecordsRead,
+	healthappv1connect.SupplementServiceDeleteSupplementIntakeProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.AttachmentServiceUploadAttachmentProcedure:   ScopeRecordsWrite,
+	healthappv1connect.AttachmentServiceCompleteAttachmentProcedure: ScopeRecordsWrite,
+	healthappv1connect.AttachmentServiceDeleteAttachmentProcedure:   ScopeRecordsWrite,
+
+	healthappv1connect.ImportServiceImportHealthKitProcedure:           ScopeRecordsWrite,
+	healthappv1connect.IntegrationServiceLinkIntegrationProcedure:      ScopeRecordsWrite,
+	healthappv1connect.IntegrationServiceUnlinkIntegrationProcedure:    ScopeRecordsWrite,
+	healthappv1connect.IntegrationServiceGetIntegrationStatusProcedure: ScopeRecordsRead,
+	healthappv1connect.RecordHistoryServiceGetRecordHistoryProcedure:   ScopeRecordsRead,
+	healthappv1connect.FHIRServiceExportObservationsProcedure:          ScopeRecordsRead,
+	healthappv1connect.FHIRServiceStreamObservationsProcedure:          ScopeRecordsRead,
+	healthappv1connect.DashboardServiceGetDashboardProcedure:           ScopeRecordsRead,
+	healthappv1connect.DashboardServiceGetWeeklySummaryProcedure:       ScopeRecordsRead,
+	healthappv1connect.DashboardServiceGetCalorieBalanceProcedure:      ScopeRecordsRead,
+	healthappv1connect.GoalServiceGetGoalsProcedure:                    ScopeRecordsRead,
+	healthappv1connect.GoalServiceUpdateGoalsProcedure:                 ScopeRecordsWrite,
+	healthappv1connect.AchievementServiceListAchievementsProcedure:     ScopeRecordsRead,
+	healthappv1connect.AchievementServiceGetCurrentStreaksProcedure:    ScopeRecordsRead,
+
+	healthappv1connect.ReportServiceGenerateMonthlyReportProcedure: ScopeRecordsRead,
+	healthappv1connect.ReportServiceGetReportStatusProcedure:       ScopeRecordsRead,
+
+	healthappv1connect.SharingServiceCreateShareProcedure:      ScopeSharesWrite,
+	healthappv1connect.SharingServiceListSharesProcedure:       ScopeRecordsRead,
+	healthappv1connect.SharingServiceListSharesWithMeProcedure: ScopeRecordsRead,
+	healthappv1connect.SharingServiceRevokeShareProcedure:      ScopeSharesWrite,
+
+	healthappv1connect.UsageServiceGetUsageProcedure:                NoScope,
+	healthappv1connect.NotificationServiceRegisterDeviceProcedure:   NoScope,
+	healthappv1connect.NotificationServiceUnregisterDeviceProcedure: NoScope,
+	healthappv1connect.NotificationServiceListDevicesProcedure:      NoScope,
+	healthappv1connect.ReminderServiceCreateReminderProcedure:       NoScope,
+	healthappv1connect.ReminderServiceListRemindersProcedure:        NoScope,
+	healthappv1connect.ReminderServiceUpdateReminderProcedure:       NoScope,
+	healthappv1connect.ReminderServiceDeleteReminderProcedure:       NoScope,
+
+	healthappv1connect.RetentionServiceGetRetentionSettingsProcedure:    NoScope,
+	healthappv1connect.RetentionServiceUpdateRetentionSettingsProcedure: NoScope,
+
+	healthappv1connect.TrashServiceListTrashProcedure:        ScopeRecordsRead,
+	healthappv1connect.TrashServiceRestoreTrashItemProcedure: ScopeRecordsWrite,
+
+	healthappv1connect.ResearchServiceGetResearchSettingsProcedure:    NoScope,
+	healthappv1connect.ResearchServiceUpdateResearchSettingsProcedure: NoScope,
+
+	healthappv1connect.PreferenceServiceGetUnitPreferencesProcedure:    NoScope,
+	healthappv1connect.PreferenceServiceUpdateUnitPreferencesProcedure: NoScope,
+
+	healthappv1connect.ColumnDigestServiceGetColumnDigestSettingsProcedure:    NoScope,
+	healthappv1connect.ColumnDigestServiceUpdateColumnDigestSettingsProcedure: NoScope,
+
+	healthappv1connect.SupportServiceGetUserSupportViewProcedure: ScopeSupportRead,
+	healthappv1connect.UserAdminServiceSearchUsersProcedure:      ScopeUsersAdmin,
+	healthappv1connect.UserAdminServiceGetUserProcedure:          ScopeUsersAdmin,
+	healthappv1connect.UserAdminServiceSuspendUserProcedure:      ScopeUsersAdmin,
+	healthappv1connect.UserAdminServiceUnsuspendUserProcedure:    ScopeUsersAdmin,
+	healthappv1connect.AdminStatsServiceGetSystemStatsProcedure:  ScopeStatsAdmin,
+
+	healthappv1connect.ResearchExportServiceStartResearchExportProcedure: ScopeResearchAdmin,
+	healthappv1connect.ResearchExportServiceGetResearchExportProcedure:   ScopeResearchAdmin,
+	healthappv1connect.ResearchExportServiceListResearchExportsProcedure: ScopeResearchAdmin,
+
+	healthappv1connect.ServerServiceGetServerInfoProcedure: NoScope,
+
+	// Columns are public
+	healthappv1connect.ColumnServiceListPublishedColumnsProcedure:  NoScope,
+	healthappv1connect.ColumnServiceGetColumnProcedure:             NoScope,
+	healthappv1connect.ColumnServiceListColumnsByCategoryProcedure: NoScope,
+	healthappv1connect.ColumnServiceListColumnsByTagProcedure:      NoScope,
+
+	// Foods come from a public database
+	healthappv1connect.FoodLookupServiceSearchFoodsProcedure:         NoScope,
+	healthappv1connect.FoodLookupServiceLookupFoodByBarcodeProcedure: NoScope,
+
+	// Shared diary entries are public, authenticated by the token of their link
+	healthappv1connect.SharedDiaryServiceGetSharedDiaryEntryProcedure: NoScope,
+}
+
+// ScopeInterceptor creates a Connect interceptor that rejects calls whose token lacks the scope
+// scopes maps their procedure to. It must run after the auth interceptor.
+func ScopeInterceptor(scopes map[string]string, logger *slog.Logger) connect.Interceptor {
+	return interceptor.New(func(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+		scope, ok := scopes[procedure]
+		if !ok {
+			// Fail closed: an RPC without a policy is a missing entry, not a public RPC
+			logger.ErrorContext(ctx, "No scope policy for procedure", "procedure", procedure)
+			return nil, connect.NewError(connect.CodePermissionDenied, errors.New("procedure not permitted"))
+		}
+		if scope != NoScope && !auth.HasScope(ctx, scope) {
+			logger.WarnContext(ctx, "Token missing required scope", "procedure", procedure, "scope", scope)
+			return nil, apierror.New(connect.CodePermissionDenied, apierror.ReasonMissingScope, fmt.Errorf("token lacks the %s scope", scope))
+		}
+		return ctx, nil
+	})
+}
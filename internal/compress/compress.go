@@ -0,0 +1,167 @@
+// Package compress gzips the plain HTTP responses of the server, such as the OpenAPI specs and
+// downloads. Connect responses are compressed by Connect itself and pass unchanged.
+package compress
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoding is the content coding of compressed responses
+const Encoding = "gzip"
+
+// writers pools the gzip writers, which allocate large buffers
+var writers = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// Handler wraps next, gzipping its responses of at least minBytes to clients accepting gzip.
+// Responses that are already encoded, partial, or of compressed media types pass unchanged.
+// Responses flushed before minBytes were written, e.g. streamed exports, are compressed as well.
+func Handler(next http.Handler, minBytes int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !AcceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &writer{ResponseWriter: w, minBytes: minBytes, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// AcceptsGzip reports whether the Accept-Encoding header of r accepts gzip
+func AcceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, Encoding) && name != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		quality, err := strconv.ParseFloat(q, 64)
+		return err == nil && quality > 0
+	}
+	return false
+}
+
+// writer buffers the start of a response until it knows whether to compress it
+type writer struct {
+	http.ResponseWriter
+	minBytes    int
+	status      int
+	wroteHeader bool // WriteHeader was called by the handler
+	decided     bool // The headers were sent, compressed or not
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+// WriteHeader records the status; it is sent with the first bytes of the body
+func (w *writer) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	// Informational responses are sent as they are
+	if status < http.StatusOK {
+		w.wroteHeader = false
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write buffers p until minBytes were written, then compresses the response if it should
+func (w *writer) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minBytes {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends the response so far, compressed unless it shouldn't be, and flushes it
+func (w *writer) Flush() {
+	if !w.decided {
+		_ = w.decide(len(w.buf) > 0)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the rest of the response; smaller responses than minBytes are sent uncompressed
+func (w *writer) Close() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+		w.gz.Reset(nil)
+		writers.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the headers, with gzip encoding if compress and the response can be compressed,
+// and the buffered body
+func (w *writer) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	// The type is sniffed from the uncompressed body, as net/http would sniff the compressed one
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && compressible(w.status, header) {
+		header.Set("Content-Encoding", Encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		w.gz = writers.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// compressible reports whether a response of status and header may be gzipped
+func compressible(status int, header http.Header) bool {
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range []string{"image/", "video/", "audio/", "application/zip", "application/gzip", "application/zstd"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CurrentVersion is the version of the config file layout described by Config
+const CurrentVersion = 2
+
+// legacyVersion is the version of config files without a version key
+const legacyVersion = 1
+
+// migration upgrades settings of the previous version in place, returning a deprecation
+// warning per setting it moved
+type migration func(settings map[string]any) []string
+
+// migrations upgrade a config file layout to the next version, keyed by the version they upgrade from
+var migrations = map[int]migration{
+	1: migrateV1,
+}
+
+// v1Renames are the keys renamed by version 2, which spells multi-word keys in snake_case.
+// A "*" segment matches any key, e.g. the endpoint names of pagination.endpoints.
+var v1Renames = []struct{ from, to string }{
+	{"server.metricsenabled", "server.metrics_enabled"},
+	{"jwt.secretkey", "jwt.secret_key"},
+	{"integrations.syncinterval", "integrations.sync_interval"},
+	{"integrations.tokenencryptionkey", "integrations.token_encryption_key"},
+	{"integrations.googlefit.clientid", "integrations.google_fit.client_id"},
+	{"integrations.googlefit.clientsecret", "integrations.google_fit.client_secret"},
+	{"integrations.fitbit.clientid", "integrations.fitbit.client_id"},
+	{"integrations.fitbit.clientsecret", "integrations.fitbit.client_secret"},
+	{"integrations.withings.clientid", "integrations.withings.client_id"},
+	{"integrations.withings.clientsecret", "integrations.withings.client_secret"},
+	{"integrations.withings.notificationurl", "integrations.withings.notification_url"},
+	{"integrations.withings.notificationsecret", "integrations.withings.notification_secret"},
+	{"pagination.defaultpagesize", "pagination.default_page_size"},
+	{"pagination.maxpagesize", "pagination.max_page_size"},
+	{"pagination.endpoints.*.defaultpagesize", "pagination.endpoints.*.default_page_size"},
+	{"pagination.endpoints.*.maxpagesize", "pagination.endpoints.*.max_page_size"},
+}
+
+// Migrate upgrades the settings of a config file to CurrentVersion and returns the
+// deprecation warnings of the settings it moved. Files without a version are version 1.
+func Migrate(settings map[string]any) ([]string, error) {
+	version := legacyVersion
+	if raw, ok := settings["version"]; ok {
+		v, ok := raw.(int)
+		if !ok {
+			return nil, fmt.Errorf("config version must be an integer, got %v", raw)
+		}
+		version = v
+	}
+	if version < legacyVersion {
+		return nil, fmt.Errorf("invalid config version %d", version)
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("config version %d is newer than the supported version %d", version, CurrentVersion)
+	}
+
+	var warnings []string
+	for ; version < CurrentVersion; version++ {
+		warnings = append(warnings, migrations[version](settings)...)
+	}
+	settings["version"] = CurrentVersion
+	return warnings, nil
+}
+
+// migrateV1 renames the run-together keys of version 1
+func migrateV1(settings map[string]any) []string {
+	var warnings []string
+	for _, r := range v1Renames {
+		for _, from := range matchKeys(settings, strings.Split(r.from, "."), nil) {
+			to := substituteWildcards(strings.Split(r.to, "."), from, strings.Split(r.from, "."))
+			moveKey(settings, from, to)
+			warnings = append(warnings, fmt.Sprintf("config key %q is deprecated, use %q", strings.Join(from, "."), strings.Join(to, ".")))
+		}
+	}
+	// Drop the google fit section once its keys have moved
+	if integrations, ok := settings["integrations"].(map[string]any); ok {
+		if section, ok := integrations["googlefit"].(map[string]any); ok && len(section) == 0 {
+			delete(integrations, "googlefit")
+		}
+	}
+	if len(warnings) > 0 {
+		warnings = append(warnings, fmt.Sprintf("config file has no version, set version: %d after renaming the deprecated keys", CurrentVersion))
+	}
+	return warnings
+}
+
+// matchKeys returns the key paths present in settings that match pattern, where a "*"
+// segment matches any key
+func matchKeys(settings map[string]any, pattern, prefix []string) [][]string {
+	keys := []string{pattern[0]}
+	if pattern[0] == "*" {
+		keys = keys[:0]
+		for key := range settings {
+			keys = append(keys, key)
+		}
+	}
+
+	var matches [][]string
+	for _, key := range keys {
+		value, ok := settings[key]
+		if !ok {
+			continue
+		}
+		path := append(append([]string{}, prefix...), key)
+		if len(pattern) == 1 {
+			matches = append(matches, path)
+			continue
+		}
+		if child, ok := value.(map[string]any); ok {
+			matches = append(matches, matchKeys(child, pattern[1:], path)...)
+		}
+	}
+	return matches
+}
+
+// substituteWildcards replaces the "*" segments of target with the segments of path matched
+// by the corresponding "*" of pattern
+func substituteWildcards(target, path, pattern []string) []string {
+	var matched []string
+	for i, segment := range pattern {
+		if segment == "*" {
+			matched = append(matched, path[i])
+		}
+	}
+	result := append([]string{}, target...)
+	for i, segment := range result {
+		if segment == "*" {
+			result[i], matched = matched[0], matched[1:]
+		}
+	}
+	return result
+}
+
+// moveKey moves the value at path from to path to. A value already at the new key takes
+// precedence over the old one.
+func moveKey(settings map[string]any, from, to []string) {
+	parent := settings
+	for _, key := range from[:len(from)-1] {
+		parent = parent[key].(map[string]any)
+	}
+	value := parent[from[len(from)-1]]
+	delete(parent, from[len(from)-1])
+
+	parent = settings
+	for _, key := range to[:len(to)-1] {
+		child, ok := parent[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			parent[key] = child
+		}
+		parent = child
+	}
+	if _, exists := parent[to[len(to)-1]]; !exists {
+		parent[to[len(to)-1]] = value
+	}
+}
+
+// legacyEnv applies environment variables named after version 1 keys that are not
+// overridden by the variable of the current key, returning their deprecation warnings
+func legacyEnv(set func(key string, value any)) []string {
+	var warnings []string
+	for _, r := range v1Renames {
+		if strings.Contains(r.from, "*") {
+			// Endpoint overrides have no environment variables
+			continue
+		}
+		legacy, current := envName(r.from), envName(r.to)
+		value, ok := os.LookupEnv(legacy)
+		if !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(current); ok {
+			warnings = append(warnings, fmt.Sprintf("environment variable %s is deprecated and ignored since %s is set", legacy, current))
+			continue
+		}
+		set(r.to, value)
+		warnings = append(warnings, fmt.Sprintf("environment variable %s is deprecated, use %s", legacy, current))
+	}
+	return warnings
+}
+
+// envName returns the environment variable of a config key
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long Watch waits for the changes to the config files to settle, as
+// editors and config map updates write files in several steps
+const watchDebounce = 500 * time.Millisecond
+
+// Reloadable copies the settings applied without a restart from next into a copy of current:
+// the log level, the daily quota, the CORS origins and the feature flags. Everything else,
+// notably the database and JWT settings, keeps its value until the server is restarted.
+func Reloadable(current, next *Config) *Config {
+	reloaded := *current
+	reloaded.Log.Level = next.Log.Level
+	reloaded.Quota = next.Quota
+	reloaded.Server.CORSOrigins = next.Server.CORSOrigins
+	reloaded.Features = next.Features
+	reloaded.Warnings = next.Warnings
+	return &reloaded
+}
+
+// RestartRequired returns the keys of the sections of next that differ from current in settings
+// that are only applied on restart, e.g. "database" or "jwt"
+func RestartRequired(current, next *Config) []string {
+	reloaded := reflect.ValueOf(*Reloadable(current, next))
+	wanted := reflect.ValueOf(*next)
+	var sections []string
+	for i := 0; i < reloaded.NumField(); i++ {
+		if !reflect.DeepEqual(reloaded.Field(i).Interface(), wanted.Field(i).Interface()) {
+			sections = append(sections, strings.ToLower(reloaded.Type().Field(i).Name))
+		}
+	}
+	return sections
+}
+
+// Watch calls onChange when the files of the config directory change, e.g. when a config map
+// mounted there is updated, until ctx is done
+func Watch(ctx context.Context, configPath string, onChange func(), logger *slog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	// The directory is watched rather than the files, as files replaced by renames stop being watched
+	if err := watcher.Add(configPath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		debounce := time.NewTimer(watchDebounce)
+		debounce.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				debounce.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				debounce.Reset(watchDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("Config watcher failed", "error", err)
+			case <-debounce.C:
+				onChange()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package cors lets browsers call the API from the allowed origins. The origins can be changed
+// while the server runs, e.g. when the configuration is reloaded.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// AnyOrigin allows requests from every origin
+const AnyOrigin = "*"
+
+// maxAge is how long browsers may cache the answers to preflight requests
+const maxAge = 2 * 60 * 60
+
+var (
+	// allowedMethods are the methods of Connect and REST requests
+	allowedMethods = strings.Join([]string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}, ", ")
+	// exposedHeaders are the response headers of Connect, gRPC-Web and the API read by clients
+	exposedHeaders = strings.Join([]string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin", "Retry-After", "Maintenance-Ends-At", "Connect-Content-Encoding", "Content-Encoding", "X-Request-Id"}, ", ")
+)
+
+// Policy allows cross-origin requests from a set of origins
+type Policy struct {
+	origins atomic.Pointer[map[string]bool]
+}
+
+// NewPolicy creates a policy allowing origins, e.g. "https://app.example.com", or any origin
+// with AnyOrigin. Without origins, cross-origin requests are refused by browsers.
+func NewPolicy(origins []string) *Policy {
+	p := &Policy{}
+	p.SetOrigins(origins)
+	return p
+}
+
+// SetOrigins replaces the allowed origins
+func (p *Policy) SetOrigins(origins []string) {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	p.origins.Store(&allowed)
+}
+
+// Allowed reports whether requests from origin are allowed
+func (p *Policy) Allowed(origin string) bool {
+	allowed := *p.origins.Load()
+	return origin != "" && (allowed[AnyOrigin] || allowed[origin])
+}
+
+// Handler wraps next, adding the CORS headers to the responses to allowed origins and answering
+// their preflight requests. Requests from other origins pass without CORS headers, so browsers
+// don't let pages read their responses.
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if !p.Allowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			// Requests carry the Connect headers and the bearer token, whichever headers they ask for
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
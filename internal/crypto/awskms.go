@@ -0,0 +1,157 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// kmsService is the service name AWS KMS requests are signed for
+const kmsService = "kms"
+
+// AWSKMS wraps data keys with a symmetric key of AWS KMS through its Encrypt and Decrypt
+// APIs, signing requests with AWS Signature Version 4
+type AWSKMS struct {
+	Region          string
+	KeyID           string // Key ID, key ARN or alias of the KMS key
+	AccessKeyID     string
+	SecretAccessKey string
+	// Endpoint defaults to the KMS endpoint of Region
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// NewAWSKMS creates an AWS KMS client for a key, authenticating with the access key of an IAM
+// user or role allowed to call kms:Encrypt and kms:Decrypt on it
+func NewAWSKMS(region, keyID, accessKeyID, secretAccessKey string) *AWSKMS {
+	return &AWSKMS{
+		Region:          region,
+		KeyID:           keyID,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Endpoint:        fmt.Sprintf("https://kms.%s.amazonaws.com", region),
+		HTTPClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WrapKey encrypts a data key with the KMS key
+func (k *AWSKMS) WrapKey(ctx context.Context, dataKey []byte) (string, error) {
+	var resp struct {
+		CiphertextBlob string
+	}
+	err := k.call(ctx, "Encrypt", map[string]string{
+		"KeyId":     k.KeyID,
+		"Plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts a data key encrypted with the KMS key
+func (k *AWSKMS) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	var resp struct {
+		Plaintext string
+	}
+	err := k.call(ctx, "Decrypt", map[string]string{
+		"KeyId":          k.KeyID,
+		"CiphertextBlob": wrapped,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode KMS plaintext: %w", err)
+	}
+	return dataKey, nil
+}
+
+// call calls a KMS API action with the JSON request body in, decoding the response into out
+func (k *AWSKMS) call(ctx context.Context, action string, in, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode KMS %s request: %w", action, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create KMS %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	k.sign(req, payload, time.Now())
+
+	resp, err := k.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send KMS %s request: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, 1<<16)
+	if resp.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(body).Decode(&kmsErr)
+		return fmt.Errorf("KMS %s request failed with status %d: %s %s", action, resp.StatusCode, kmsErr.Type, kmsErr.Message)
+	}
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode KMS %s response: %w", action, err)
+	}
+	return nil
+}
+
+// sign adds the Signature Version 4 authorization of req, whose body is payload, at now
+func (k *AWSKMS) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	// Requests go to the root path and have no query string
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalRequest := req.Method + "\n" +
+		"/\n" +
+		"\n" +
+		"content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"x-amz-target:" + req.Header.Get("X-Amz-Target") + "\n" +
+		"\n" +
+		signedHeaders + "\n" +
+		payloadHash
+
+	scope := date + "/" + k.Region + "/" + kmsService + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+k.SecretAccessKey), date)
+	key = hmacSHA256(key, k.Region)
+	key = hmacSHA256(key, kmsService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", k.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the required key length in bytes (AES-256)
+const KeySize = 32
+
+// keyIDSeparator separates the key ID from the sealed value in ciphertexts. It is not in the
+// base64 alphabet, so ciphertexts written before key IDs were added have none.
+const keyIDSeparator = ":"
+
+// ErrDecrypt is returned when a ciphertext is malformed or was not sealed with the key
+var ErrDecrypt = errors.New("failed to decrypt value")
+
+// Cipher encrypts secrets such as OAuth tokens, and sensitive fields such as diary content,
+// with AES-256-GCM. Values are encrypted with the primary key and decrypted with the key that
+// sealed them, so keys are rotated by making a new key primary and keeping the old ones until
+// every value is re-encrypted.
+type Cipher struct {
+	primary string
+	keys    map[string]cipher.AEAD
+	// order lists the key IDs, primary first, to try on ciphertexts without a key ID
+	order []string
+	// hashKey keys the hashes of Hash; it is derived from the primary key
+	hashKey []byte
+}
+
+// NewCipher creates a cipher encrypting with a 32-byte primary key, and decrypting with it or
+// the previous keys
+func NewCipher(key []byte, previousKeys ...[]byte) (*Cipher, error) {
+	c := &Cipher{keys: make(map[string]cipher.AEAD)}
+	for _, k := range append([][]byte{key}, previousKeys...) {
+		if len(k) != KeySize {
+			return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(k))
+		}
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCM cipher: %w", err)
+		}
+		id := keyID(k)
+		if _, ok := c.keys[id]; ok {
+			continue
+		}
+		c.keys[id] = aead
+		c.order = append(c.order, id)
+	}
+	c.primary = c.order[0]
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("hash"))
+	c.hashKey = mac.Sum(nil)
+	return c, nil
+}
+
+// NewCipherFromBase64 creates a cipher from base64 (standard encoding) keys, as stored in config
+func NewCipherFromBase64(encodedKey string, encodedPreviousKeys ...string) (*Cipher, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+	}
+	previousKeys := make([][]byte, len(encodedPreviousKeys))
+	for i, encoded := range encodedPreviousKeys {
+		if previousKeys[i], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("failed to decode previous encryption key %d: %w", i+1, err)
+		}
+	}
+	return NewCipher(key, previousKeys...)
+}
+
+// keyID identifies a key in ciphertexts without revealing it
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// PrimaryKeyPrefix returns the prefix of the values encrypted with the primary key
+func (c *Cipher) PrimaryKeyPrefix() string {
+	return c.primary + keyIDSeparator
+}
+
+// Encrypt seals plaintext with the primary key and a random nonce and returns
+// keyID:base64(nonce || ciphertext)
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	aead := c.keys[c.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return c.PrimaryKeyPrefix() + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with any of the cipher's keys. Values without a key
+// ID are tried with every key.
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	id, encoded, ok := strings.Cut(ciphertext, keyIDSeparator)
+	if !ok {
+		for _, id := range c.order {
+			if plaintext, err := open(c.keys[id], ciphertext); err == nil {
+				return plaintext, nil
+			}
+		}
+		return "", ErrDecrypt
+	}
+	aead, ok := c.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key %s", ErrDecrypt, id)
+	}
+	return open(aead, encoded)
+}
+
+// Hash returns a keyed hash of data, to compare encrypted values without decrypting them. Hashes
+// change when the primary key does.
+func (c *Cipher) Hash(data string) []byte {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Reencrypt decrypts a value and encrypts it with the primary key, unless it already is
+func (c *Cipher) Reencrypt(ciphertext string) (string, error) {
+	if strings.HasPrefix(ciphertext, c.PrimaryKeyPrefix()) {
+		return ciphertext, nil
+	}
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return c.Encrypt(plaintext)
+}
+
+// open opens base64(nonce || ciphertext) with aead
+func open(aead cipher.AEAD, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
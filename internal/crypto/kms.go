@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// KMS drivers selectable in the encryption config
+const (
+	KMSDriverLocal = "local"
+	KMSDriverAWS   = "aws"
+)
+
+// KMS wraps and unwraps data keys with a key that stays in a key management service. Records
+// are encrypted with a data key, which is only stored wrapped (envelope encryption).
+type KMS interface {
+	// WrapKey encrypts a data key and returns it in base64, as stored in config
+	WrapKey(ctx context.Context, dataKey []byte) (string, error)
+	// UnwrapKey decrypts a data key wrapped by WrapKey
+	UnwrapKey(ctx context.Context, wrapped string) ([]byte, error)
+}
+
+// GenerateDataKey returns a random data key wrapped by kms
+func GenerateDataKey(ctx context.Context, kms KMS) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return kms.WrapKey(ctx, dataKey)
+}
+
+// NewEnvelopeCipher creates a cipher from a data key wrapped by kms
+func NewEnvelopeCipher(ctx context.Context, kms KMS, wrappedDataKey string) (*Cipher, error) {
+	dataKey, err := kms.UnwrapKey(ctx, wrappedDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return NewCipher(dataKey)
+}
+
+// LocalKMS wraps data keys with a master key held by the application, for development
+// without a key management service
+type LocalKMS struct {
+	master *Cipher
+}
+
+// NewLocalKMS creates a local KMS wrapping data keys with master
+func NewLocalKMS(master *Cipher) *LocalKMS {
+	return &LocalKMS{master: master}
+}
+
+// WrapKey encrypts a data key with the master key
+func (k *LocalKMS) WrapKey(_ context.Context, dataKey []byte) (string, error) {
+	return k.master.Encrypt(string(dataKey))
+}
+
+// UnwrapKey decrypts a data key encrypted with the master key
+func (k *LocalKMS) UnwrapKey(_ context.Context, wrapped string) ([]byte, error) {
+	dataKey, err := k.master.Decrypt(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(dataKey), nil
+}
@@ -0,0 +1,116 @@
+// Package digest emails the users who opted in a weekly digest of the columns published in their
+// categories, and serves the unsubscribe links of the digests.
+package digest
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/email"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+)
+
+const (
+	// period is how often users receive a digest
+	period = 7 * 24 * time.Hour
+	// maxColumns is the most columns a digest lists, the newest
+	maxColumns = 20
+)
+
+// Job emails the weekly digests of new columns
+type Job struct {
+	repo   *repo.ColumnDigestRepository
+	mailer *email.Mailer
+	signer *Signer
+	cfg    config.ColumnDigestConfig
+	log    *slog.Logger
+	clock  clock.Clock
+}
+
+// NewJob creates a job emailing digests through mailer, with unsubscribe links signed by signer
+func NewJob(repo *repo.ColumnDigestRepository, mailer *email.Mailer, signer *Signer, cfg config.ColumnDigestConfig, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		repo:   repo,
+		mailer: mailer,
+		signer: signer,
+		cfg:    cfg,
+		log:    log,
+		clock:  clock,
+	}
+}
+
+// Run sends the due digests immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.SendDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends digests until none are due, or until one fails to send; it is retried by the
+// next run
+func (j *Job) SendDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := j.clock.Now()
+		digest, ok, err := j.repo.Claim(ctx, now.Add(-period), now)
+		if err != nil {
+			j.log.ErrorContext(ctx, "Failed to claim column digest", "error", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		if err := j.send(ctx, digest, now); err != nil {
+			j.log.ErrorContext(ctx, "Failed to send column digest", "userID", digest.ID, "error", err)
+			if err := j.repo.Release(ctx, digest); err != nil {
+				j.log.ErrorContext(ctx, "Failed to release column digest", "userID", digest.ID, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// send emails a claimed digest of the columns published since the previous one, or in the last
+// period for the first; nothing is sent without new columns
+func (j *Job) send(ctx context.Context, digest db.ClaimColumnDigestRow, now time.Time) error {
+	since := now.Add(-period)
+	if digest.PreviousSentAt.Valid {
+		since = digest.PreviousSentAt.Time
+	}
+	columns, err := j.repo.ListNewColumns(ctx, since, now, digest.ColumnDigestCategories, maxColumns)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		j.log.DebugContext(ctx, "No new columns for column digest", "userID", digest.ID)
+		return nil
+	}
+
+	data := email.ColumnDigestData{
+		Columns:        make([]email.DigestColumn, len(columns)),
+		UnsubscribeURL: UnsubscribeURL(j.cfg.BaseURL, j.signer.Sign(digest.ID)),
+	}
+	for i, column := range columns {
+		data.Columns[i] = email.DigestColumn{
+			Title:       column.Title,
+			Category:    column.Category.String,
+			PublishedAt: column.PublishedAt.Time,
+		}
+	}
+	if err := j.mailer.Send(ctx, digest.Email.String, email.TemplateColumnDigest, data); err != nil {
+		return err
+	}
+	j.log.InfoContext(ctx, "Column digest sent", "userID", digest.ID, "columns", len(columns))
+	return nil
+}
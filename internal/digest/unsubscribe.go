@@ -0,0 +1,145 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/google/uuid"
+)
+
+const (
+	// UnsubscribePath is the path the unsubscribe links of digests point to
+	UnsubscribePath = "/unsubscribe/column-digest"
+	// unsubscribeTokenParam is the query parameter of unsubscribe links carrying their token
+	unsubscribeTokenParam = "token"
+	// signatureSize is the size of the truncated HMAC of tokens, keeping links short
+	signatureSize = 16
+)
+
+// ErrInvalidToken is returned for unsubscribe tokens that are malformed or not signed with the key
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Signer signs and verifies the tokens of unsubscribe links, which carry the ID of their user.
+// Tokens don't expire, so the links of old digests keep working.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer of unsubscribe tokens with key
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Sign returns the URL-safe unsubscribe token of a user
+func (s *Signer) Sign(userID uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(append(userID[:], s.mac(userID[:])...))
+}
+
+// Verify returns the user ID of a token signed with the key
+func (s *Signer) Verify(token string) (uuid.UUID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) != 16+signatureSize {
+		return uuid.Nil, ErrInvalidToken
+	}
+	if !hmac.Equal(data[16:], s.mac(data[:16])) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	id, _ := uuid.FromBytes(data[:16])
+	return id, nil
+}
+
+func (s *Signer) mac(userID []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte("column-digest-unsubscribe:"))
+	h.Write(userID)
+	return h.Sum(nil)[:signatureSize]
+}
+
+// UnsubscribeURL returns the unsubscribe link of a token on the server at baseURL
+func UnsubscribeURL(baseURL, token string) string {
+	return strings.TrimSuffix(baseURL, "/") + UnsubscribePath + "?" + url.Values{unsubscribeTokenParam: {token}}.Encode()
+}
+
+// unsubscribePage is the page of unsubscribe links. Opening a link only shows the form, so link
+// scanners of mail services don't unsubscribe users; the form, or a one-click unsubscribe of
+// the mail client, posts to the link.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Column digest</title></head>
+<body>
+{{if .Unsubscribed}}<p>You are unsubscribed from the weekly column digest. You can subscribe again in the app.</p>
+{{else}}<p>Stop receiving the weekly digest of new columns?</p>
+<form method="post" action="{{.Action}}"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+// UnsubscribeHandler serves the unsubscribe links of digests
+type UnsubscribeHandler struct {
+	repo   *repo.ColumnDigestRepository
+	signer *Signer
+	log    *slog.Logger
+}
+
+// NewUnsubscribeHandler creates a handler opting out the users of the links signed by signer
+func NewUnsubscribeHandler(repo *repo.ColumnDigestRepository, signer *Signer, log *slog.Logger) *UnsubscribeHandler {
+	return &UnsubscribeHandler{
+		repo:   repo,
+		signer: signer,
+		log:    log,
+	}
+}
+
+// ServeHTTP shows the unsubscribe form of a link, and unsubscribes its user when posted
+func (h *UnsubscribeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get(unsubscribeTokenParam)
+	userID, err := h.signer.Verify(token)
+	if err != nil {
+		h.log.WarnContext(r.Context(), "Invalid column digest unsubscribe token", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "invalid unsubscribe link", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		h.render(w, r, false)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	unsubscribed, err := h.repo.Unsubscribe(r.Context(), userID)
+	if err != nil {
+		h.log.ErrorContext(r.Context(), "Failed to unsubscribe from column digest", "userID", userID, "error", err)
+		http.Error(w, "failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	if unsubscribed {
+		h.log.InfoContext(r.Context(), "Unsubscribed from column digest", "userID", userID)
+	}
+	h.render(w, r, true)
+}
+
+// render writes the unsubscribe page
+func (h *UnsubscribeHandler) render(w http.ResponseWriter, r *http.Request, unsubscribed bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	data := struct {
+		Unsubscribed bool
+		Action       string
+	}{unsubscribed, r.URL.RequestURI()}
+	if err := unsubscribePage.Execute(w, data); err != nil {
+		h.log.ErrorContext(r.Context(), "Failed to render unsubscribe page", "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package docs
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// swaggerUIAsset is the script of Swagger UI whose absence tells that its assets weren't
+// installed with make swagger-ui
+const swaggerUIAsset = "swagger-ui-bundle.js"
+
+// specSuffix is the file name suffix of the specs generated by protoc-gen-connect-openapi
+const specSuffix = ".openapi.yaml"
+
+// page renders Swagger UI with a spec selector listing every generated spec. The Swagger UI
+// assets are served by the server itself, so the page runs no script of a third-party host.
+var page = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Health Management API</title>
+  <link rel="stylesheet" href="{{.AssetsPath}}swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.AssetsPath}}swagger-ui-bundle.js"></script>
+  <script src="{{.AssetsPath}}swagger-ui-standalone-preset.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      urls: {{.URLs}},
+      dom_id: "#swagger-ui",
+      presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+      layout: "StandaloneLayout",
+      persistAuthorization: true
+    });
+  </script>
+</body>
+</html>
+`))
+
+// specURL is an entry of the Swagger UI spec selector
+type specURL struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Handler serves Swagger UI for the OpenAPI specs in specs, which are served under specsPath,
+// loading the Swagger UI assets in assets, which are served under assetsPath. Specs are listed on
+// every request, so regenerated specs show up without a restart.
+func Handler(specs fs.FS, specsPath string, assets fs.FS, assetsPath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := fs.Stat(assets, swaggerUIAsset); err != nil {
+			http.Error(w, "no Swagger UI assets found, run make swagger-ui", http.StatusNotFound)
+			return
+		}
+		var urls []specURL
+		err := fs.WalkDir(specs, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(p, specSuffix) {
+				return nil
+			}
+			urls = append(urls, specURL{
+				Name: strings.TrimSuffix(p, specSuffix),
+				URL:  path.Join(specsPath, p),
+			})
+			return nil
+		})
+		if err != nil || len(urls) == 0 {
+			http.Error(w, "no OpenAPI specs found, run make proto", http.StatusNotFound)
+			return
+		}
+		sort.Slice(urls, func(i, j int) bool { return urls[i].Name < urls[j].Name })
+
+		urlsJSON, err := json.Marshal(urls)
+		if err != nil {
+			http.Error(w, "failed to list OpenAPI specs", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = page.Execute(w, struct {
+			AssetsPath string
+			URLs       template.JS
+		}{
+			AssetsPath: assetsPath,
+			URLs:       template.JS(urlsJSON),
+		})
+	})
+}
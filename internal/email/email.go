@@ -0,0 +1,156 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	"text/template"
+	"time"
+)
+
+// Drivers selectable in the email config
+const (
+	DriverLog      = "log"
+	DriverSMTP     = "smtp"
+	DriverSES      = "ses"
+	DriverSendGrid = "sendgrid"
+)
+
+// Template names an email template in the templates directory
+type Template string
+
+// Emails sent to users
+const (
+	TemplateExportReady    Template = "export_ready"
+	TemplateWeeklySummary  Template = "weekly_summary"
+	TemplateAccountDeleted Template = "account_deleted"
+	TemplateColumnDigest   Template = "column_digest"
+	// TemplateTest is sent by the email-test command to check the driver configuration
+	TemplateTest Template = "test"
+)
+
+// ExportReadyData is the data of TemplateExportReady
+type ExportReadyData struct {
+	DownloadURL string
+	ExpiresAt   time.Time
+}
+
+// WeeklySummaryData is the data of TemplateWeeklySummary
+type WeeklySummaryData struct {
+	WeekStart time.Time
+	// WeightChangeKg is nil without body records at both ends of the week
+	WeightChangeKg  *float64
+	ExerciseMinutes int64
+	CaloriesBurned  int64
+	DiaryEntries    int64
+}
+
+// AccountDeletedData is the data of TemplateAccountDeleted
+type AccountDeletedData struct {
+	DeletedAt time.Time
+}
+
+// ColumnDigestData is the data of TemplateColumnDigest
+type ColumnDigestData struct {
+	Columns []DigestColumn // Newest first
+	// UnsubscribeURL opts the recipient out of the digest
+	UnsubscribeURL string
+}
+
+// DigestColumn is a column listed in TemplateColumnDigest
+type DigestColumn struct {
+	Title       string
+	Category    string // Empty for uncategorized columns
+	PublishedAt time.Time
+}
+
+// Message is a rendered email
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	// HTML is the alternative HTML body, sent along with Text
+	HTML string
+}
+
+// Sender delivers emails through a mail service
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// The file of each template defines <name>.subject, <name>.text and <name>.html. The subject
+// and text are rendered as plain text, the html with HTML escaping.
+var (
+	textTemplates = template.Must(template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/*.tmpl"))
+)
+
+// templateFuncs are the functions available to templates
+var templateFuncs = map[string]any{
+	"date": func(t time.Time) string { return t.UTC().Format("January 2, 2006") },
+	"deref": func(f *float64) float64 {
+		if f == nil {
+			return 0
+		}
+		return *f
+	},
+}
+
+// Mailer renders templates and sends them from a fixed address
+type Mailer struct {
+	sender Sender
+	from   string
+}
+
+// NewMailer creates a mailer sending through sender from the given address, e.g.
+// "Health App <no-reply@example.com>"
+func NewMailer(sender Sender, from string) (*Mailer, error) {
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+	return &Mailer{sender: sender, from: from}, nil
+}
+
+// Send renders a template with data and sends it to the given address
+func (m *Mailer) Send(ctx context.Context, to string, tmpl Template, data any) error {
+	if _, err := mail.ParseAddress(to); err != nil {
+		return fmt.Errorf("invalid recipient address %q: %w", to, err)
+	}
+	msg, err := Render(tmpl, data)
+	if err != nil {
+		return err
+	}
+	msg.From = m.from
+	msg.To = to
+	if err := m.sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", tmpl, err)
+	}
+	return nil
+}
+
+// Render renders the subject and bodies of a template with data
+func Render(tmpl Template, data any) (Message, error) {
+	var subject, text, html bytes.Buffer
+	name := string(tmpl)
+	if err := textTemplates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email subject: %w", tmpl, err)
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+".text", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email text: %w", tmpl, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s email HTML: %w", tmpl, err)
+	}
+	return Message{
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
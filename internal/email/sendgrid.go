@@ -0,0 +1,84 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+)
+
+const sendGridAPIURL = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGrid sends emails through the SendGrid v3 Mail Send API
+type SendGrid struct {
+	APIKey string
+	// APIURL defaults to the SendGrid Mail Send endpoint
+	APIURL     string
+	HTTPClient *http.Client
+}
+
+// NewSendGrid creates a SendGrid sender authenticating with an API key
+func NewSendGrid(apiKey string) *SendGrid {
+	return &SendGrid{
+		APIKey:     apiKey,
+		APIURL:     sendGridAPIURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// sendGridAddress is an email address of the SendGrid API
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Send sends msg through SendGrid
+func (s *SendGrid) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"personalizations": []map[string]any{
+			{"to": []sendGridAddress{{Email: to.Address, Name: to.Name}}},
+		},
+		"from":    sendGridAddress{Email: from.Address, Name: from.Name},
+		"subject": msg.Subject,
+		// SendGrid requires the plain text part first
+		"content": []map[string]string{
+			{"type": "text/plain", "value": msg.Text},
+			{"type": "text/html", "value": msg.HTML},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode SendGrid message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.APIURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create SendGrid request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SendGrid request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	return fmt.Errorf("SendGrid request failed with status %d: %s", resp.StatusCode, body)
+}
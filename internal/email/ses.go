@@ -0,0 +1,131 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// sesService is the service name SES requests are signed for
+const sesService = "ses"
+
+// SES sends emails through the Amazon SES v2 SendEmail API, signing requests with AWS
+// Signature Version 4
+type SES struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Endpoint defaults to the SES endpoint of Region
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// NewSES creates an SES sender for a region, authenticating with the access key of an IAM
+// user or role allowed to call ses:SendEmail
+func NewSES(region, accessKeyID, secretAccessKey string) *SES {
+	return &SES{
+		Region:          region,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Endpoint:        fmt.Sprintf("https://email.%s.amazonaws.com", region),
+		HTTPClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Send sends msg through SES
+func (s *SES) Send(ctx context.Context, msg Message) error {
+	content := func(data string) map[string]string {
+		return map[string]string{"Data": data, "Charset": "UTF-8"}
+	}
+	payload, err := json.Marshal(map[string]any{
+		"FromEmailAddress": msg.From,
+		"Destination":      map[string][]string{"ToAddresses": {msg.To}},
+		"Content": map[string]any{
+			"Simple": map[string]any{
+				"Subject": content(msg.Subject),
+				"Body": map[string]any{
+					"Text": content(msg.Text),
+					"Html": content(msg.HTML),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode SES message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint+"/v2/email/outbound-emails", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create SES request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.sign(req, payload, time.Now())
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SES request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body)
+	return fmt.Errorf("SES request failed with status %d: %s", resp.StatusCode, body.Message)
+}
+
+// sign adds the Signature Version 4 authorization of req, whose body is payload, at now
+func (s *SES) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// The path needs no further escaping and the request has no query string
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := req.Method + "\n" +
+		(&url.URL{Path: req.URL.Path}).EscapedPath() + "\n" +
+		"\n" +
+		"content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n" +
+		"\n" +
+		signedHeaders + "\n" +
+		payloadHash
+
+	scope := date + "/" + s.Region + "/" + sesService + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, sesService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,247 @@
+// Package errreport reports panics and internal errors to a Sentry-compatible service (Sentry,
+// GlitchTip, ...). Reports are tagged with the environment and the revision of the build, and
+// scrubbed of personal data the way production logs are.
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"github.com/google/uuid"
+)
+
+const (
+	// queueSize bounds the reports waiting to be sent; more are dropped
+	queueSize = 100
+	// throttleInterval is how long reports of the same error are dropped after one was queued,
+	// so a failing dependency doesn't flood the service
+	throttleInterval = time.Minute
+	// maxThrottled bounds the errors remembered for throttling
+	maxThrottled = 1000
+)
+
+var (
+	// emailPattern and uuidPattern match personal data and identifiers in error messages
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	uuidPattern  = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+)
+
+// Reporter sends reports to the project of a DSN in the background. A nil Reporter drops them,
+// so callers don't check whether reporting is enabled.
+type Reporter struct {
+	endpoint    string // Envelope endpoint of the project
+	auth        string // X-Sentry-Auth header
+	dsn         string
+	environment string
+	build       version.Info
+	sampleRate  float64
+	hashKey     string
+	client      *http.Client
+	log         *slog.Logger
+	clock       clock.Clock
+
+	events chan *event
+
+	mu        sync.Mutex
+	throttled map[string]time.Time // When the last report of an error was queued, by error
+}
+
+// New creates a reporter of the builds described by build, or nil if cfg has no DSN. User
+// identifiers are hashed with hashKey, as in production logs.
+func New(cfg config.ErrorReportingConfig, build version.Info, hashKey string, log *slog.Logger, clock clock.Clock) (*Reporter, error) {
+	if cfg.DSN == "" {
+		return nil, nil
+	}
+	dsn, err := url.Parse(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	// The project ID is the last segment of the path, behind an optional prefix
+	path := strings.Trim(dsn.Path, "/")
+	prefix, project := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, project = "/"+path[:i], path[i+1:]
+	}
+	if dsn.User.Username() == "" || project == "" {
+		return nil, fmt.Errorf("DSN must include the key and the project ID")
+	}
+
+	return &Reporter{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", dsn.Scheme, dsn.Host, prefix, project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=health-management-api/%s, sentry_key=%s", build.Version, dsn.User.Username()),
+		dsn:         cfg.DSN,
+		environment: cfg.Environment,
+		build:       build,
+		sampleRate:  cfg.SampleRate,
+		hashKey:     hashKey,
+		client:      &http.Client{Timeout: cfg.Timeout},
+		log:         log,
+		clock:       clock,
+		events:      make(chan *event, queueSize),
+		throttled:   make(map[string]time.Time),
+	}, nil
+}
+
+// Run sends the queued reports until ctx is done, then those left in the queue
+func (r *Reporter) Run(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-r.events:
+					r.send(e)
+				default:
+					return
+				}
+			}
+		case e := <-r.events:
+			r.send(e)
+		}
+	}
+}
+
+// reportError queues a report of an error logged or returned by an RPC or background job, with
+// the attributes of the log record. Errors are sampled and throttled.
+func (r *Reporter) reportError(ctx context.Context, message string, errText string, attrs []slog.Attr) {
+	if r == nil || rand.Float64() >= r.sampleRate {
+		return
+	}
+	e := r.newEvent(ctx, "error", message)
+	if errText != "" {
+		e.Exception = &exceptions{Values: []exception{{Type: message, Value: scrubText(errText)}}}
+	}
+	for _, a := range attrs {
+		a = log.Scrub(r.hashKey, a)
+		e.Extra[a.Key] = scrubText(a.Value.Resolve().String())
+	}
+	if r.throttle(e.Transaction + "\x00" + e.Message) {
+		return
+	}
+	r.queue(e)
+}
+
+// reportPanic queues a report of a panic of an RPC with the stack of the panicking goroutine
+func (r *Reporter) reportPanic(ctx context.Context, procedure string, value any, frames []frame) {
+	if r == nil {
+		return
+	}
+	e := r.newEvent(ctx, "fatal", "panic")
+	e.Transaction = procedure
+	e.Exception = &exceptions{Values: []exception{{
+		Type:       "panic",
+		Value:      scrubText(fmt.Sprint(value)),
+		Stacktrace: &stacktrace{Frames: frames},
+		Mechanism:  &mechanism{Type: "recover", Handled: false},
+	}}}
+	r.queue(e)
+}
+
+// newEvent creates an event tagged with the build, the procedure and request ID of the RPC of
+// ctx, if any, and its hashed user
+func (r *Reporter) newEvent(ctx context.Context, level, message string) *event {
+	e := &event{
+		EventID:     strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Timestamp:   r.clock.Now().UTC().Format(time.RFC3339Nano),
+		Level:       level,
+		Platform:    "go",
+		Message:     scrubText(message),
+		Environment: r.environment,
+		Release:     r.build.Version,
+		Tags:        map[string]string{"revision": r.build.GitSHA, "go_version": r.build.GoVersion},
+		Extra:       make(map[string]string),
+	}
+	if s := scopeFrom(ctx); s != nil {
+		e.Transaction = s.procedure
+	}
+	if requestID := log.RequestID(ctx); requestID != "" {
+		e.Tags["request_id"] = requestID
+	}
+	if userID, err := auth.GetUserID(ctx); err == nil {
+		e.User = &user{ID: log.Scrub(r.hashKey, slog.String("userID", userID.String())).Value.String()}
+	}
+	return e
+}
+
+// throttle reports whether a report of the error with key was queued within throttleInterval
+// and should be dropped, and otherwise remembers it as queued now
+func (r *Reporter) throttle(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	if last, ok := r.throttled[key]; ok && now.Sub(last) < throttleInterval {
+		return true
+	}
+	if len(r.throttled) >= maxThrottled {
+		clear(r.throttled)
+	}
+	r.throttled[key] = now
+	return false
+}
+
+// queue queues e for Run, dropping it if the queue is full
+func (r *Reporter) queue(e *event) {
+	select {
+	case r.events <- e:
+	default:
+		r.log.Warn("Error report dropped, the queue is full", "eventID", e.EventID)
+	}
+}
+
+// send sends e in an envelope. Failures are logged below the error level, which is reported.
+func (r *Reporter) send(e *event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		r.log.Warn("Failed to encode error report", "eventID", e.EventID, "error", err)
+		return
+	}
+	header, _ := json.Marshal(map[string]string{"event_id": e.EventID, "dsn": r.dsn, "sent_at": r.clock.Now().UTC().Format(time.RFC3339Nano)})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+	var body bytes.Buffer
+	for _, line := range [][]byte{header, item, payload} {
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, &body)
+	if err != nil {
+		r.log.Warn("Failed to create error report request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.log.Warn("Failed to send error report", "eventID", e.EventID, "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		r.log.Warn("Error report rejected", "eventID", e.EventID, "status", resp.StatusCode)
+	}
+}
+
+// scrubText replaces email addresses and UUIDs, e.g. of users, in free text. UUIDs of records
+// are replaced too, which groups the reports of an error regardless of the records involved.
+func scrubText(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[REDACTED]")
+	return uuidPattern.ReplaceAllString(s, "[UUID]")
+}
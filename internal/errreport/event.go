@@ -0,0 +1,96 @@
+package errreport
+
+import (
+	"runtime"
+	"strings"
+)
+
+// event is the subset of the Sentry event payload the reporter sends
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Message     string            `json:"message,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Transaction string            `json:"transaction,omitempty"` // Procedure of the RPC
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+	User        *user             `json:"user,omitempty"`
+	Exception   *exceptions       `json:"exception,omitempty"`
+}
+
+// user identifies the user of a report by the hash of their ID
+type user struct {
+	ID string `json:"id"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+	Mechanism  *mechanism  `json:"mechanism,omitempty"`
+}
+
+// mechanism tells how an exception was caught
+type mechanism struct {
+	Type    string `json:"type"`
+	Handled bool   `json:"handled"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"` // Oldest call first
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// modulePath is the import path of the server's packages, whose frames are marked in app
+const modulePath = "github.com/atreya2011/health-management-api/"
+
+// callers returns the frames of the calling goroutine's stack, oldest first, skipping the skip
+// innermost frames besides callers itself
+func callers(skip int) []frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var stack []frame
+	for {
+		f, more := frames.Next()
+		module, function := splitFunction(f.Function)
+		stack = append(stack, frame{
+			Function: function,
+			Module:   module,
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(f.Function, modulePath),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+		stack[i], stack[j] = stack[j], stack[i]
+	}
+	return stack
+}
+
+// splitFunction splits a qualified function name, e.g. "a/b/pkg.(*T).M", into its package
+// "a/b/pkg" and function "(*T).M"
+func splitFunction(name string) (pkg, function string) {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot], name[slash+2+dot:]
+	}
+	return "", name
+}
@@ -0,0 +1,125 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+)
+
+// reportedCodes are the codes of RPC errors that are reported: those of server bugs or faults,
+// rather than of invalid requests or unavailable dependencies
+var reportedCodes = map[connect.Code]bool{
+	connect.CodeUnknown:  true,
+	connect.CodeInternal: true,
+	connect.CodeDataLoss: true,
+}
+
+// scope is the RPC a context belongs to, recording whether one of its errors was reported
+type scope struct {
+	procedure string
+	reported  atomic.Bool
+}
+
+// scopeKey is the context key of the scope of an RPC
+type scopeKey struct{}
+
+// noReportKey is the context key marking the errors logged with a context as reported otherwise
+type noReportKey struct{}
+
+// scopeFrom returns the scope of the RPC of ctx, or nil outside RPCs
+func scopeFrom(ctx context.Context) *scope {
+	s, _ := ctx.Value(scopeKey{}).(*scope)
+	return s
+}
+
+// Interceptor creates a Connect interceptor tagging the reports of an RPC with its procedure, and
+// reporting it if it fails with an internal error none of its logged errors was reported for.
+// It should come right after the metrics interceptor, so that the errors of the interceptors
+// behind it are reported too.
+func Interceptor(r *Reporter) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if r == nil {
+				return next(ctx, req)
+			}
+			s := &scope{procedure: req.Spec().Procedure}
+			ctx = context.WithValue(ctx, scopeKey{}, s)
+			resp, err := next(ctx, req)
+			if err != nil && reportedCodes[connect.CodeOf(err)] && !s.reported.Load() {
+				r.reportError(ctx, "RPC failed", err.Error(), []slog.Attr{slog.String("code", connect.CodeOf(err).String())})
+			}
+			return resp, err
+		}
+	}
+}
+
+// Recover creates a function for connect.WithRecover answering RPCs that panicked with an
+// internal error, after logging the panic and reporting it with the stack of the panicking
+// goroutine
+func Recover(r *Reporter, log *slog.Logger) func(context.Context, connect.Spec, http.Header, any) error {
+	return func(ctx context.Context, spec connect.Spec, _ http.Header, value any) error {
+		// The handler's frames are still on the stack, below those of Connect's recover
+		frames := callers(1)
+		r.reportPanic(ctx, spec.Procedure, value, frames)
+		// Connect recovers inside the interceptors, which mustn't report the internal error too
+		if s := scopeFrom(ctx); s != nil {
+			s.reported.Store(true)
+		}
+		log.ErrorContext(context.WithValue(ctx, noReportKey{}, true), "RPC panicked", "procedure", spec.Procedure, "panic", value)
+		return connect.NewError(connect.CodeInternal, errors.New("internal error"))
+	}
+}
+
+// LogHandler is a slog.Handler reporting the records logged at the error level, e.g. by the
+// error paths of handlers and background jobs, before passing every record to the next
+// handler. The "error" attribute of a record is reported as its exception.
+type LogHandler struct {
+	next     slog.Handler
+	reporter *Reporter
+	attrs    []slog.Attr // Of With
+}
+
+// NewLogHandler creates a log handler reporting the errors logged to next with r
+func NewLogHandler(next slog.Handler, r *Reporter) *LogHandler {
+	return &LogHandler{next: next, reporter: r}
+}
+
+// Enabled implements slog.Handler
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if h.reporter != nil && record.Level >= slog.LevelError && ctx.Value(noReportKey{}) == nil {
+		var errText string
+		attrs := append([]slog.Attr{}, h.attrs...)
+		record.Attrs(func(a slog.Attr) bool {
+			if a.Key == "error" {
+				errText = a.Value.Resolve().String()
+			} else {
+				attrs = append(attrs, a)
+			}
+			return true
+		})
+		if s := scopeFrom(ctx); s != nil {
+			s.reported.Store(true)
+		}
+		h.reporter.reportError(ctx, record.Message, errText, attrs)
+	}
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{next: h.next.WithAttrs(attrs), reporter: h.reporter, attrs: append(append([]slog.Attr{}, h.attrs...), attrs...)}
+}
+
+// WithGroup implements slog.Handler; the attributes of groups are reported without their group
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{next: h.next.WithGroup(name), reporter: h.reporter, attrs: h.attrs}
+}
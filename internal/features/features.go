@@ -0,0 +1,39 @@
+// Package features holds the feature flags of the server. Flags are read on every use, so they
+// take effect as soon as the configuration is reloaded.
+package features
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// DailyTargetAdjustment recalculates the daily water and calorie targets of users when their
+// weight or activity level changes materially
+const DailyTargetAdjustment = "daily_target_adjustment"
+
+// Flags are feature flags by name. Unknown flags are disabled.
+type Flags struct {
+	enabled atomic.Pointer[map[string]bool]
+}
+
+// New creates the feature flags of enabled
+func New(enabled map[string]bool) *Flags {
+	f := &Flags{}
+	f.Set(enabled)
+	return f
+}
+
+// Enabled reports whether the named feature is enabled. Names are case-insensitive, as config
+// keys are.
+func (f *Flags) Enabled(name string) bool {
+	return (*f.enabled.Load())[strings.ToLower(name)]
+}
+
+// Set replaces all the flags with enabled
+func (f *Flags) Set(enabled map[string]bool) {
+	flags := make(map[string]bool, len(enabled))
+	for name, on := range enabled {
+		flags[strings.ToLower(name)] = on
+	}
+	f.enabled.Store(&flags)
+}
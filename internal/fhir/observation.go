@@ -0,0 +1,147 @@
+package fhir
+
+import (
+	"time"
+)
+
+const (
+	// loincSystem and ucumSystem are the code systems of observation codes and units
+	loincSystem = "http://loinc.org"
+	ucumSystem  = "http://unitsofmeasure.org"
+	// observationCategorySystem is the code system of observation categories
+	observationCategorySystem = "http://terminology.hl7.org/CodeSystem/observation-category"
+	// dateLayout is the FHIR date format; a date is a valid dateTime with day precision
+	dateLayout = "2006-01-02"
+)
+
+// Measure describes how a measurement is coded in an Observation
+type Measure struct {
+	Category    string // observation-category code
+	LOINCCode   string
+	Display     string
+	UnitCode    string // UCUM code
+	UnitDisplay string
+}
+
+// Measures exported as Observations
+var (
+	BodyWeight = Measure{
+		Category:    "vital-signs",
+		LOINCCode:   "29463-7",
+		Display:     "Body weight",
+		UnitCode:    "kg",
+		UnitDisplay: "kg",
+	}
+	BodyFatPercentage = Measure{
+		Category:    "exam",
+		LOINCCode:   "41982-0",
+		Display:     "Percentage of body fat Measured",
+		UnitCode:    "%",
+		UnitDisplay: "%",
+	}
+	DailySteps = Measure{
+		Category:    "activity",
+		LOINCCode:   "41950-7",
+		Display:     "Number of steps in 24 hour Measured",
+		UnitCode:    "{steps}",
+		UnitDisplay: "steps",
+	}
+)
+
+// Bundle is a FHIR R4 Bundle resource
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Timestamp    string        `json:"timestamp"`
+	Total        int           `json:"total"`
+	Entry        []BundleEntry `json:"entry,omitempty"`
+}
+
+// BundleEntry is an entry of a Bundle
+type BundleEntry struct {
+	Resource Observation `json:"resource"`
+}
+
+// Observation is a FHIR R4 Observation resource with a quantity value
+type Observation struct {
+	ResourceType      string            `json:"resourceType"`
+	ID                string            `json:"id"`
+	Meta              Meta              `json:"meta"`
+	Status            string            `json:"status"`
+	Category          []CodeableConcept `json:"category"`
+	Code              CodeableConcept   `json:"code"`
+	Subject           Reference         `json:"subject"`
+	EffectiveDateTime string            `json:"effectiveDateTime"`
+	ValueQuantity     Quantity          `json:"valueQuantity"`
+}
+
+// Meta holds resource metadata
+type Meta struct {
+	LastUpdated string `json:"lastUpdated"`
+}
+
+// CodeableConcept is a concept defined by codings
+type CodeableConcept struct {
+	Coding []Coding `json:"coding"`
+	Text   string   `json:"text,omitempty"`
+}
+
+// Coding is a code defined by a code system
+type Coding struct {
+	System  string `json:"system"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+// Reference refers to another resource
+type Reference struct {
+	Reference string `json:"reference"`
+}
+
+// Quantity is a measured amount with a UCUM unit
+type Quantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+	System string  `json:"system"`
+	Code   string  `json:"code"`
+}
+
+// NewObservation creates a final Observation of a measurement taken on date by patientID.
+// id must be stable across exports so receiving systems can update previously exported observations.
+func NewObservation(id, patientID string, measure Measure, date time.Time, value float64, lastUpdated time.Time) Observation {
+	return Observation{
+		ResourceType: "Observation",
+		ID:           id,
+		Meta:         Meta{LastUpdated: lastUpdated.UTC().Format(time.RFC3339)},
+		Status:       "final",
+		Category: []CodeableConcept{{
+			Coding: []Coding{{System: observationCategorySystem, Code: measure.Category}},
+		}},
+		Code: CodeableConcept{
+			Coding: []Coding{{System: loincSystem, Code: measure.LOINCCode, Display: measure.Display}},
+			Text:   measure.Display,
+		},
+		Subject:           Reference{Reference: "Patient/" + patientID},
+		EffectiveDateTime: date.Format(dateLayout),
+		ValueQuantity: Quantity{
+			Value:  value,
+			Unit:   measure.UnitDisplay,
+			System: ucumSystem,
+			Code:   measure.UnitCode,
+		},
+	}
+}
+
+// NewSearchSetBundle wraps observations in a searchset Bundle
+func NewSearchSetBundle(observations []Observation, now time.Time) Bundle {
+	bundle := Bundle{
+		ResourceType: "Bundle",
+		Type:         "searchset",
+		Timestamp:    now.UTC().Format(time.RFC3339),
+		Total:        len(observations),
+	}
+	for _, o := range observations {
+		bundle.Entry = append(bundle.Entry, BundleEntry{Resource: o})
+	}
+	return bundle
+}
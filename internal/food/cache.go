@@ -0,0 +1,126 @@
+package food
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// Cache is a Database caching the responses of another for a TTL, evicting the least recently
+// used responses once it holds its capacity. Barcodes that are not found are cached too, as
+// scanning them again is common; failed requests are not.
+type Cache struct {
+	db       Database
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	clock    clock.Clock
+	order    *list.List // of *cachedResponse, most recently used first
+	byKey    map[string]*list.Element
+}
+
+// cachedResponse is a response cached until expiresAt
+type cachedResponse struct {
+	key       string
+	foods     []Food // The search results, or the food of a barcode unless not found
+	expiresAt time.Time
+}
+
+// NewCache creates a cache of up to capacity responses of db, each kept for ttl
+func NewCache(db Database, capacity int, ttl time.Duration, clock clock.Clock) *Cache {
+	return &Cache{
+		db:       db,
+		capacity: capacity,
+		ttl:      ttl,
+		clock:    clock,
+		order:    list.New(),
+		byKey:    make(map[string]*list.Element),
+	}
+}
+
+// Search returns the cached results of a query, compared case-insensitively, or searches db
+func (c *Cache) Search(ctx context.Context, query string, limit int) ([]Food, error) {
+	key := "search:" + strconv.Itoa(limit) + ":" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if foods, ok := c.get(key); ok {
+		return foods, nil
+	}
+	foods, err := c.db.Search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, foods)
+	return foods, nil
+}
+
+// LookupBarcode returns the cached food of a barcode, or looks it up in db
+func (c *Cache) LookupBarcode(ctx context.Context, barcode string) (Food, error) {
+	key := "barcode:" + barcode
+	if foods, ok := c.get(key); ok {
+		if len(foods) == 0 {
+			return Food{}, ErrNotFound
+		}
+		return foods[0], nil
+	}
+	food, err := c.db.LookupBarcode(ctx, barcode)
+	if errors.Is(err, ErrNotFound) {
+		c.put(key, nil)
+		return Food{}, err
+	}
+	if err != nil {
+		return Food{}, err
+	}
+	c.put(key, []Food{food})
+	return food, nil
+}
+
+// Len returns the number of cached responses, including expired ones not evicted yet
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// get returns the cached response of key, if it hasn't expired
+func (c *Cache) get(key string) ([]Food, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.byKey[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedResponse)
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.foods, true
+}
+
+// put caches the response of key, evicting the least recently used response if the cache is full
+func (c *Cache) put(key string, foods []Food) {
+	if c.capacity <= 0 || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.byKey[key]; ok {
+		c.remove(elem)
+	}
+	for c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+	c.byKey[key] = c.order.PushFront(&cachedResponse{key: key, foods: foods, expiresAt: c.clock.Now().Add(c.ttl)})
+}
+
+// remove evicts the response of elem; c.mu must be held
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*cachedResponse)
+	delete(c.byKey, entry.key)
+}
@@ -0,0 +1,113 @@
+// Package food looks up the nutrition values of foods in an external food database, Open Food
+// Facts or the USDA FoodData Central, so meals are logged from a search or a scanned barcode.
+package food
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Sources selectable in the food config
+const (
+	SourceOpenFoodFacts = "openfoodfacts"
+	SourceUSDA          = "usda"
+)
+
+// ErrNotFound is returned when no food has a barcode
+var ErrNotFound = errors.New("food not found")
+
+// Food is a food of a database. Nutrition values are per 100 g, and nil when the database
+// doesn't have them.
+type Food struct {
+	ID      string // ID of the food in its database
+	Source  string // SourceOpenFoodFacts or SourceUSDA
+	Name    string
+	Brand   string // Empty for generic foods
+	Barcode string // GTIN of packaged foods, when known
+	// Energy, protein, carbohydrates and fat per 100 g
+	CaloriesKcal   *float64
+	ProteinG       *float64
+	CarbohydratesG *float64
+	FatG           *float64
+	// ServingSizeG is the weight of a serving, when known
+	ServingSizeG *float64
+}
+
+// Database searches a food database
+type Database interface {
+	// Search returns up to limit foods matching a name, best matches first
+	Search(ctx context.Context, query string, limit int) ([]Food, error)
+	// LookupBarcode returns the food with a barcode, or ErrNotFound
+	LookupBarcode(ctx context.Context, barcode string) (Food, error)
+}
+
+// statusError is returned for unsuccessful responses of a database
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.status, e.body)
+}
+
+// getJSON sends a GET request and decodes its JSON response into out
+func getJSON(client *http.Client, req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{status: resp.StatusCode, body: string(bytes.TrimSpace(body))}
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// number is an optional JSON number, which databases sometimes encode as a string
+type number struct {
+	value *float64
+}
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		n.value = &v
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			n.value = &f
+		}
+	}
+	return nil
+}
+
+// nonNegative returns v unless it is negative, which databases use for unknown values
+func nonNegative(v *float64) *float64 {
+	if v == nil || *v < 0 {
+		return nil
+	}
+	return v
+}
+
+// positive returns v if it is positive
+func positive(v *float64) *float64 {
+	if v == nil || *v <= 0 {
+		return nil
+	}
+	return v
+}
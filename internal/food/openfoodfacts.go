@@ -0,0 +1,132 @@
+package food
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openFoodFactsBaseURL = "https://world.openfoodfacts.org"
+	// openFoodFactsFields are the product fields requested, keeping responses small
+	openFoodFactsFields = "code,product_name,brands,nutriments,serving_quantity"
+)
+
+// openFoodFactsProduct is a product of an Open Food Facts response
+type openFoodFactsProduct struct {
+	Code        string `json:"code"`
+	ProductName string `json:"product_name"`
+	Brands      string `json:"brands"` // Comma-separated
+	Nutriments  struct {
+		EnergyKcal    number `json:"energy-kcal_100g"`
+		Proteins      number `json:"proteins_100g"`
+		Carbohydrates number `json:"carbohydrates_100g"`
+		Fat           number `json:"fat_100g"`
+	} `json:"nutriments"`
+	ServingQuantity number `json:"serving_quantity"` // Grams
+}
+
+// OpenFoodFacts searches the Open Food Facts database of packaged foods, which needs no API key
+type OpenFoodFacts struct {
+	// BaseURL defaults to the Open Food Facts API
+	BaseURL string
+	// UserAgent identifies the app, as Open Food Facts asks of its clients
+	UserAgent  string
+	HTTPClient *http.Client
+}
+
+// NewOpenFoodFacts creates an Open Food Facts database at baseURL, or at the public API if empty
+func NewOpenFoodFacts(baseURL, userAgent string, timeout time.Duration) *OpenFoodFacts {
+	if baseURL == "" {
+		baseURL = openFoodFactsBaseURL
+	}
+	return &OpenFoodFacts{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		UserAgent:  userAgent,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Search returns up to limit products matching a name; products without a name are left out
+func (o *OpenFoodFacts) Search(ctx context.Context, query string, limit int) ([]Food, error) {
+	params := url.Values{
+		"search_terms":  {query},
+		"search_simple": {"1"},
+		"action":        {"process"},
+		"json":          {"1"},
+		"page_size":     {strconv.Itoa(limit)},
+		"fields":        {openFoodFactsFields},
+	}
+	var body struct {
+		Products []openFoodFactsProduct `json:"products"`
+	}
+	if err := o.get(ctx, o.BaseURL+"/cgi/search.pl?"+params.Encode(), &body); err != nil {
+		return nil, fmt.Errorf("failed to search Open Food Facts: %w", err)
+	}
+
+	foods := make([]Food, 0, len(body.Products))
+	for _, product := range body.Products {
+		if strings.TrimSpace(product.ProductName) == "" {
+			continue
+		}
+		foods = append(foods, product.food())
+		if len(foods) == limit {
+			break
+		}
+	}
+	return foods, nil
+}
+
+// LookupBarcode returns the product with a barcode
+func (o *OpenFoodFacts) LookupBarcode(ctx context.Context, barcode string) (Food, error) {
+	var body struct {
+		Status  int                  `json:"status"` // 1 if found
+		Product openFoodFactsProduct `json:"product"`
+	}
+	err := o.get(ctx, o.BaseURL+"/api/v2/product/"+url.PathEscape(barcode)+".json?fields="+openFoodFactsFields, &body)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+		return Food{}, ErrNotFound
+	}
+	if err != nil {
+		return Food{}, fmt.Errorf("failed to look up Open Food Facts barcode: %w", err)
+	}
+	if body.Status != 1 {
+		return Food{}, ErrNotFound
+	}
+	if body.Product.Code == "" {
+		body.Product.Code = barcode
+	}
+	return body.Product.food(), nil
+}
+
+func (o *OpenFoodFacts) get(ctx context.Context, rawURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", o.UserAgent)
+	return getJSON(o.HTTPClient, req, out)
+}
+
+// food converts a product to a Food; the first of its brands is kept
+func (p openFoodFactsProduct) food() Food {
+	brand, _, _ := strings.Cut(p.Brands, ",")
+	return Food{
+		ID:             p.Code,
+		Source:         SourceOpenFoodFacts,
+		Name:           strings.TrimSpace(p.ProductName),
+		Brand:          strings.TrimSpace(brand),
+		Barcode:        p.Code,
+		CaloriesKcal:   nonNegative(p.Nutriments.EnergyKcal.value),
+		ProteinG:       nonNegative(p.Nutriments.Proteins.value),
+		CarbohydratesG: nonNegative(p.Nutriments.Carbohydrates.value),
+		FatG:           nonNegative(p.Nutriments.Fat.value),
+		ServingSizeG:   positive(p.ServingQuantity.value),
+	}
+}
@@ -0,0 +1,144 @@
+package food
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	usdaBaseURL = "https://api.nal.usda.gov/fdc/v1"
+	// usdaBarcodePageSize is how many branded foods a barcode search checks for the barcode
+	usdaBarcodePageSize = 10
+)
+
+// Nutrient IDs of FoodData Central
+const (
+	usdaNutrientEnergy        = 1008 // kcal
+	usdaNutrientProtein       = 1003
+	usdaNutrientFat           = 1004
+	usdaNutrientCarbohydrates = 1005
+)
+
+// usdaFood is a food of a FoodData Central search response; the nutrients of branded foods are
+// per 100 g
+type usdaFood struct {
+	FDCID           int64  `json:"fdcId"`
+	Description     string `json:"description"`
+	BrandOwner      string `json:"brandOwner"`
+	BrandName       string `json:"brandName"`
+	GTINUPC         string `json:"gtinUpc"`
+	ServingSize     number `json:"servingSize"`
+	ServingSizeUnit string `json:"servingSizeUnit"`
+	FoodNutrients   []struct {
+		NutrientID int64  `json:"nutrientId"`
+		Value      number `json:"value"`
+	} `json:"foodNutrients"`
+}
+
+// USDA searches the USDA FoodData Central database of generic and branded foods
+type USDA struct {
+	// BaseURL defaults to the FoodData Central API
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewUSDA creates a FoodData Central database at baseURL, or at the public API if empty
+func NewUSDA(baseURL, apiKey string, timeout time.Duration) *USDA {
+	if baseURL == "" {
+		baseURL = usdaBaseURL
+	}
+	return &USDA{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Search returns up to limit foods matching a name
+func (u *USDA) Search(ctx context.Context, query string, limit int) ([]Food, error) {
+	foods, err := u.search(ctx, url.Values{
+		"query":    {query},
+		"pageSize": {strconv.Itoa(limit)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search FoodData Central: %w", err)
+	}
+	result := make([]Food, len(foods))
+	for i, f := range foods {
+		result[i] = f.food()
+	}
+	return result, nil
+}
+
+// LookupBarcode returns the branded food with a barcode. FoodData Central has no barcode
+// lookup, so branded foods are searched for the barcode and compared without leading zeros, as
+// UPC-A codes are stored both as 12 and 13 digits.
+func (u *USDA) LookupBarcode(ctx context.Context, barcode string) (Food, error) {
+	foods, err := u.search(ctx, url.Values{
+		"query":    {barcode},
+		"dataType": {"Branded"},
+		"pageSize": {strconv.Itoa(usdaBarcodePageSize)},
+	})
+	if err != nil {
+		return Food{}, fmt.Errorf("failed to look up FoodData Central barcode: %w", err)
+	}
+	for _, f := range foods {
+		if f.GTINUPC != "" && strings.TrimLeft(f.GTINUPC, "0") == strings.TrimLeft(barcode, "0") {
+			return f.food(), nil
+		}
+	}
+	return Food{}, ErrNotFound
+}
+
+func (u *USDA) search(ctx context.Context, params url.Values) ([]usdaFood, error) {
+	params.Set("api_key", u.APIKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.BaseURL+"/foods/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	var body struct {
+		Foods []usdaFood `json:"foods"`
+	}
+	if err := getJSON(u.HTTPClient, req, &body); err != nil {
+		return nil, err
+	}
+	return body.Foods, nil
+}
+
+// food converts a FoodData Central food to a Food; serving sizes are only kept in grams
+func (f usdaFood) food() Food {
+	brand := f.BrandName
+	if brand == "" {
+		brand = f.BrandOwner
+	}
+	result := Food{
+		ID:      strconv.FormatInt(f.FDCID, 10),
+		Source:  SourceUSDA,
+		Name:    strings.TrimSpace(f.Description),
+		Brand:   strings.TrimSpace(brand),
+		Barcode: f.GTINUPC,
+	}
+	if strings.EqualFold(f.ServingSizeUnit, "g") {
+		result.ServingSizeG = positive(f.ServingSize.value)
+	}
+	for _, nutrient := range f.FoodNutrients {
+		value := nonNegative(nutrient.Value.value)
+		switch nutrient.NutrientID {
+		case usdaNutrientEnergy:
+			result.CaloriesKcal = value
+		case usdaNutrientProtein:
+			result.ProteinG = value
+		case usdaNutrientFat:
+			result.FatG = value
+		case usdaNutrientCarbohydrates:
+			result.CarbohydratesG = value
+		}
+	}
+	return result
+}
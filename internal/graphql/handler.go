@@ -0,0 +1,117 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/graphql/generated"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Procedure names GraphQL requests in the checks run before them, e.g. for exemptions from the quota
+const Procedure = "/graphql"
+
+// NewHandler creates the HTTP handler serving GraphQL queries with resolver. The checks run in turn
+// before each request, as the interceptors do before RPCs; a failing check fails the request with
+// the HTTP status of its code. The context of a request, checks included, is cancelled after the
+// timeout. Introspection is only allowed if introspection is set.
+func NewHandler(resolver *Resolver, checks []interceptor.Check, timeout time.Duration, introspection bool) http.Handler {
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  resolver,
+		Directives: generated.DirectiveRoot{HasScope: hasScope},
+	}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	if introspection {
+		srv.Use(extension.Introspection{})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		for _, check := range checks {
+			var err error
+			if ctx, err = check(ctx, Procedure, r.Header); err != nil {
+				writeCheckError(w, err)
+				return
+			}
+		}
+		srv.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// hasScope implements the @hasScope directive, resolving the field only if the caller's token has
+// the scope
+func hasScope(ctx context.Context, obj any, next graphql.Resolver, scope string) (any, error) {
+	if _, err := auth.GetUserID(ctx); err != nil {
+		return nil, newError(connect.CodeUnauthenticated, "", "user not authenticated")
+	}
+	if !auth.HasScope(ctx, scope) {
+		return nil, newError(connect.CodePermissionDenied, apierror.ReasonMissingScope, "token lacks the "+scope+" scope")
+	}
+	return next(ctx)
+}
+
+// newError creates the GraphQL error of a field, with the code and reason of the equivalent RPC
+// error in its extensions. Errors without a reason fall back to their code, as with apierror.Reason.
+func newError(code connect.Code, reason, message string) *gqlerror.Error {
+	if reason == "" {
+		reason = code.String()
+	}
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]any{"code": code.String(), "reason": reason},
+	}
+}
+
+// writeCheckError answers a request failing a check with the error as a GraphQL response and the
+// metadata of the error, such as Retry-After, as headers
+func writeCheckError(w http.ResponseWriter, err error) {
+	code := connect.CodeOf(err)
+	var connectErr *connect.Error
+	message := err.Error()
+	if errors.As(err, &connectErr) {
+		message = connectErr.Message()
+		for key, values := range connectErr.Meta() {
+			w.Header()[key] = values
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(code))
+	json.NewEncoder(w).Encode(graphql.Response{ //nolint:errcheck
+		Errors: gqlerror.List{newError(code, apierror.Reason(err), message)},
+	})
+}
+
+// httpStatus returns the HTTP status of a failed check's code, as Connect answers unary RPCs
+func httpStatus(code connect.Code) int {
+	switch code {
+	case connect.CodeInvalidArgument, connect.CodeFailedPrecondition:
+		return http.StatusBadRequest
+	case connect.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	case connect.CodePermissionDenied:
+		return http.StatusForbidden
+	case connect.CodeNotFound:
+		return http.StatusNotFound
+	case connect.CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case connect.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case connect.CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,97 @@
+// Package graphql serves the GraphQL gateway, which resolves the aggregated queries of mobile
+// dashboards against the repositories. Requests are authenticated like RPCs, and each field checks
+// the scope it needs with the @hasScope directive.
+package graphql
+
+//go:generate go run github.com/99designs/gqlgen@v0.17.63 generate --config gqlgen.yml
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/graphql/model"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxDays bounds the days covered by the body records and exercise totals
+	maxDays = 90
+	// maxDiaryEntries bounds the latest diary entries returned
+	maxDiaryEntries = 20
+)
+
+// BodyRecordRepository is the body record storage read by the gateway
+type BodyRecordRepository interface {
+	FindByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]db.BodyRecord, error)
+}
+
+// ExerciseRecordRepository is the exercise record storage read by the gateway
+type ExerciseRecordRepository interface {
+	TotalsByUser(ctx context.Context, userID uuid.UUID, start, end time.Time) (db.GetExerciseTotalsByUserRangeRow, error)
+}
+
+// DiaryEntryRepository is the diary entry storage read by the gateway
+type DiaryEntryRepository interface {
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.DiaryEntry, error)
+}
+
+// Resolver resolves the fields of the schema
+type Resolver struct {
+	bodyRecords     BodyRecordRepository
+	exerciseRecords ExerciseRecordRepository
+	diaryEntries    DiaryEntryRepository
+	log             *slog.Logger
+	clock           clock.Clock
+}
+
+// NewResolver creates a resolver reading the repositories
+func NewResolver(bodyRecords BodyRecordRepository, exerciseRecords ExerciseRecordRepository, diaryEntries DiaryEntryRepository, log *slog.Logger, clock clock.Clock) *Resolver {
+	return &Resolver{
+		bodyRecords:     bodyRecords,
+		exerciseRecords: exerciseRecords,
+		diaryEntries:    diaryEntries,
+		log:             log,
+		clock:           clock,
+	}
+}
+
+// today returns the start of the current UTC day, which the day ranges end with
+func (r *Resolver) today() time.Time {
+	return r.clock.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// toModelBodyRecord converts a db.BodyRecord to a model.BodyRecord
+func toModelBodyRecord(record db.BodyRecord) *model.BodyRecord {
+	result := &model.BodyRecord{
+		ID:   record.ID.String(),
+		Note: record.Note.String,
+	}
+	if record.Date.Valid {
+		result.Date = record.Date.Time.Format("2006-01-02")
+	}
+	if w, err := record.WeightKg.Float64Value(); err == nil && w.Valid {
+		result.WeightKg = &w.Float64
+	}
+	if bf, err := record.BodyFatPercentage.Float64Value(); err == nil && bf.Valid {
+		result.BodyFatPercentage = &bf.Float64
+	}
+	return result
+}
+
+// toModelDiaryEntry converts a decrypted db.DiaryEntry to a model.DiaryEntry
+func toModelDiaryEntry(entry db.DiaryEntry) *model.DiaryEntry {
+	result := &model.DiaryEntry{
+		ID:      entry.ID.String(),
+		Content: entry.Content,
+	}
+	if entry.EntryDate.Valid {
+		result.EntryDate = entry.EntryDate.Time.Format("2006-01-02")
+	}
+	if entry.Title.Valid {
+		result.Title = &entry.Title.String
+	}
+	return result
+}
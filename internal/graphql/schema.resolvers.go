@@ -0,0 +1,89 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.63
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/graphql/generated"
+	"github.com/atreya2011/health-management-api/internal/graphql/model"
+)
+
+// BodyRecords is the resolver for the bodyRecords field.
+func (r *queryResolver) BodyRecords(ctx context.Context, days int) ([]*model.BodyRecord, error) {
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		return nil, newError(connect.CodeUnauthenticated, "", "user not authenticated")
+	}
+	if days < 1 || days > maxDays {
+		return nil, newError(connect.CodeInvalidArgument, "", fmt.Sprintf("days must be between 1 and %d", maxDays))
+	}
+
+	today := r.today()
+	records, err := r.bodyRecords.FindByUserAndDateRange(ctx, userID, today.AddDate(0, 0, -(days-1)), today)
+	if err != nil {
+		r.log.ErrorContext(ctx, "Failed to get body records", "userID", userID, "error", err)
+		return nil, newError(connect.CodeInternal, "", "failed to get body records")
+	}
+	result := make([]*model.BodyRecord, 0, len(records))
+	for _, record := range records {
+		result = append(result, toModelBodyRecord(record))
+	}
+	return result, nil
+}
+
+// ExerciseTotals is the resolver for the exerciseTotals field.
+func (r *queryResolver) ExerciseTotals(ctx context.Context, days int) (*model.ExerciseTotals, error) {
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		return nil, newError(connect.CodeUnauthenticated, "", "user not authenticated")
+	}
+	if days < 1 || days > maxDays {
+		return nil, newError(connect.CodeInvalidArgument, "", fmt.Sprintf("days must be between 1 and %d", maxDays))
+	}
+
+	today := r.today()
+	totals, err := r.exerciseRecords.TotalsByUser(ctx, userID, today.AddDate(0, 0, -(days-1)), today.Add(24*time.Hour))
+	if err != nil {
+		r.log.ErrorContext(ctx, "Failed to get exercise totals", "userID", userID, "error", err)
+		return nil, newError(connect.CodeInternal, "", "failed to get exercise totals")
+	}
+	return &model.ExerciseTotals{
+		SessionCount:         int(totals.SessionCount),
+		TotalDurationMinutes: int(totals.TotalDurationMinutes),
+		TotalCaloriesBurned:  int(totals.TotalCaloriesBurned),
+	}, nil
+}
+
+// LatestDiaryEntries is the resolver for the latestDiaryEntries field.
+func (r *queryResolver) LatestDiaryEntries(ctx context.Context, count int) ([]*model.DiaryEntry, error) {
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		return nil, newError(connect.CodeUnauthenticated, "", "user not authenticated")
+	}
+	if count < 1 || count > maxDiaryEntries {
+		return nil, newError(connect.CodeInvalidArgument, "", fmt.Sprintf("count must be between 1 and %d", maxDiaryEntries))
+	}
+
+	entries, err := r.diaryEntries.FindByUser(ctx, userID, count, 0)
+	if err != nil {
+		r.log.ErrorContext(ctx, "Failed to get diary entries", "userID", userID, "error", err)
+		return nil, newError(connect.CodeInternal, "", "failed to get diary entries")
+	}
+	result := make([]*model.DiaryEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, toModelDiaryEntry(entry))
+	}
+	return result, nil
+}
+
+// Query returns generated.QueryResolver implementation.
+func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
+
+type queryResolver struct{ *Resolver }
@@ -0,0 +1,170 @@
+// Package health implements the gRPC health checking protocol (grpc.health.v1.Health) for the
+// services of the server, so gRPC-aware load balancers and meshes route away from instances
+// that cannot serve them.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+const (
+	// checkTimeout bounds a check of a dependency
+	checkTimeout = 2 * time.Second
+	// checkInterval is how long the result of a check of a dependency is reused, so frequent
+	// health checks of many services don't each reach the dependency
+	checkInterval = time.Second
+)
+
+// Check checks a dependency of services, returning an error while it is unhealthy
+type Check func(ctx context.Context) error
+
+// DatabaseCheck checks that database answers a query. While the circuit breaker of a
+// repo.ResilientDB is open, it fails without reaching the database.
+func DatabaseCheck(database repo.DB) Check {
+	return func(ctx context.Context) error {
+		_, err := database.Exec(ctx, "SELECT 1")
+		return err
+	}
+}
+
+// Checker reports services as serving while their dependencies are healthy, and every service
+// as not serving while the server warms up or once it is draining. The overall health of the server, asked for with
+// an empty service name, is that of all dependencies. Unknown services fail with not_found, as
+// the protocol requires.
+type Checker struct {
+	log   *slog.Logger
+	clock clock.Clock
+
+	dependencies map[string]*dependency
+	services     map[string][]string // Dependency names by service
+	warming      atomic.Bool
+	draining     atomic.Bool
+}
+
+// dependency is a dependency of services with the result of its last check
+type dependency struct {
+	check Check
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// NewChecker creates a checker without services or dependencies
+func NewChecker(log *slog.Logger, clock clock.Clock) *Checker {
+	return &Checker{
+		log:          log,
+		clock:        clock,
+		dependencies: make(map[string]*dependency),
+		services:     make(map[string][]string),
+	}
+}
+
+// AddDependency adds the dependency name, checked with check. Dependencies and services must
+// be added before the checker is used.
+func (c *Checker) AddDependency(name string, check Check) {
+	c.dependencies[name] = &dependency{check: check}
+}
+
+// AddService adds service, serving while the named dependencies are healthy
+func (c *Checker) AddService(service string, dependencies ...string) {
+	c.services[service] = dependencies
+}
+
+// Warming reports every service as not serving until Ready is called, e.g. while the server
+// warms up after starting
+func (c *Checker) Warming() {
+	c.warming.Store(true)
+}
+
+// Ready ends the warmup started by Warming
+func (c *Checker) Ready() {
+	c.warming.Store(false)
+}
+
+// IsWarming reports whether the server is warming up
+func (c *Checker) IsWarming() bool {
+	return c.warming.Load()
+}
+
+// Drain reports every service as not serving from now on, e.g. while the server shuts down
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// ReadyHandler answers with 503 and {"status":"starting"} while c is warming up, so load
+// balancers only route to the server once it is ready, and with next afterwards
+func ReadyHandler(c *Checker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.IsWarming() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"starting"}` + "\n")) //nolint:errcheck // The client may be gone
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check implements grpchealth.Checker
+func (c *Checker) Check(ctx context.Context, req *grpchealth.CheckRequest) (*grpchealth.CheckResponse, error) {
+	var names []string
+	if req.Service == "" {
+		for name := range c.dependencies {
+			names = append(names, name)
+		}
+	} else {
+		var ok bool
+		if names, ok = c.services[req.Service]; !ok {
+			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("unknown service %q", req.Service))
+		}
+	}
+
+	if c.warming.Load() || c.draining.Load() {
+		return &grpchealth.CheckResponse{Status: grpchealth.StatusNotServing}, nil
+	}
+	for _, name := range names {
+		dep, ok := c.dependencies[name]
+		if !ok {
+			return nil, fmt.Errorf("service %q depends on unknown dependency %q", req.Service, name)
+		}
+		if err := c.checkDependency(ctx, name, dep); err != nil {
+			return &grpchealth.CheckResponse{Status: grpchealth.StatusNotServing}, nil
+		}
+	}
+	return &grpchealth.CheckResponse{Status: grpchealth.StatusServing}, nil
+}
+
+// checkDependency checks dep, reusing the result of a check within checkInterval. Concurrent
+// health checks wait for a single check.
+func (c *Checker) checkDependency(ctx context.Context, name string, dep *dependency) error {
+	dep.mu.Lock()
+	defer dep.mu.Unlock()
+	now := c.clock.Now()
+	if !dep.checkedAt.IsZero() && now.Sub(dep.checkedAt) < checkInterval {
+		return dep.err
+	}
+
+	// The result is shared, so it must not depend on the caller giving up
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkTimeout)
+	defer cancel()
+	err := dep.check(ctx)
+	if err != nil && dep.err == nil {
+		c.log.WarnContext(ctx, "Dependency unhealthy", "dependency", name, "error", err)
+	} else if err == nil && dep.err != nil {
+		c.log.InfoContext(ctx, "Dependency healthy again", "dependency", name)
+	}
+	dep.checkedAt, dep.err = now, err
+	return err
+}
@@ -0,0 +1,117 @@
+// Package i18n localizes the messages of API errors. Messages are keyed by stable codes and
+// translated to the language of the request's Accept-Language header; the error message itself
+// stays in English, and the localized one is returned as a LocalizedError detail.
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"golang.org/x/text/language"
+)
+
+// Locales of the translations
+const (
+	English  = "en"
+	Japanese = "ja"
+)
+
+// supported are the languages of the translations; the first one is the default
+var supported = []language.Tag{language.English, language.Japanese}
+
+var matcher = language.NewMatcher(supported)
+
+// Error is an error with a message looked up by its code
+type Error struct {
+	Code string
+	Args []any // Arguments of the message's format verbs
+}
+
+// NewError creates an error with the message of code, formatted with args
+func NewError(code string, args ...any) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+// Error returns the English message
+func (e *Error) Error() string {
+	return Message(English, e.Code, e.Args...)
+}
+
+// Locale returns the supported locale best matching an Accept-Language header, English if none
+// matches
+func Locale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return English
+	}
+	_, i, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return English
+	}
+	base, _ := supported[i].Base()
+	return base.String()
+}
+
+// Message returns the message of code in locale, formatted with args. Args that are *Error, such
+// as the error of an item of a list, are formatted with their message in locale. Codes without a
+// translation in locale fall back to English, and unknown codes to the code itself.
+func Message(locale, code string, args ...any) string {
+	translations, ok := messages[code]
+	if !ok {
+		return code
+	}
+	format, ok := translations[locale]
+	if !ok {
+		format = translations[English]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	localized := make([]any, len(args))
+	for i, arg := range args {
+		if err, ok := arg.(*Error); ok {
+			arg = Message(locale, err.Code, err.Args...)
+		}
+		localized[i] = arg
+	}
+	return fmt.Sprintf(format, localized...)
+}
+
+// Localize attaches the message of err in locale to it as a LocalizedError detail. Errors created
+// with NewError get their own message; others the message of their reason.
+func Localize(connectErr *connect.Error, locale string) {
+	code, args := apierror.Reason(connectErr), []any(nil)
+	var i18nErr *Error
+	if errors.As(connectErr, &i18nErr) {
+		code, args = i18nErr.Code, i18nErr.Args
+	}
+	detail, err := connect.NewErrorDetail(&v1.LocalizedError{
+		Code:    code,
+		Locale:  locale,
+		Message: Message(locale, code, args...),
+	})
+	if err != nil {
+		return
+	}
+	connectErr.AddDetail(detail)
+}
+
+// Interceptor creates a Connect interceptor localizing the errors of RPCs to the language of
+// their Accept-Language header. It should run before the interceptors that fail requests, such
+// as the auth interceptor, so their errors are localized too.
+func Interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			res, err := next(ctx, req)
+			var connectErr *connect.Error
+			if errors.As(err, &connectErr) {
+				Localize(connectErr, Locale(req.Header().Get("Accept-Language")))
+			}
+			return res, err
+		}
+	}
+}
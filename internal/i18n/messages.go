@@ -0,0 +1,629 @@
+package i18n
+
+// Codes of the messages of errors created with NewError
+const (
+	ContentEmpty                 = "content_empty"
+	ContentTooLong               = "content_too_long"
+	TitleTooLong                 = "title_too_long"
+	EndDateBeforeStartDate       = "end_date_before_start_date"
+	StartDateAfterEndDate        = "start_date_after_end_date"
+	SearchWindowTooLong          = "search_window_too_long"
+	UnsupportedProvider          = "unsupported_provider"
+	UnsupportedPlatform          = "unsupported_platform"
+	UnsupportedRecordType        = "unsupported_record_type"
+	UnsupportedIntensity         = "unsupported_intensity"
+	UnsupportedContentType       = "unsupported_content_type"
+	UploadMismatch               = "upload_mismatch"
+	WeightNotPositive            = "weight_not_positive"
+	WeightTooLarge               = "weight_too_large"
+	WeightAmbiguous              = "weight_ambiguous"
+	WeightUnitInvalid            = "weight_unit_invalid"
+	HeightUnitInvalid            = "height_unit_invalid"
+	TargetWeightOutOfRange       = "target_weight_out_of_range"
+	BodyFatNegative              = "body_fat_negative"
+	BodyFatTooLarge              = "body_fat_too_large"
+	WeeklyExerciseMinutesInvalid = "weekly_exercise_minutes_invalid"
+	WeeklyCaloriesBurnedInvalid  = "weekly_calories_burned_invalid"
+	WeeklyDiaryEntriesInvalid    = "weekly_diary_entries_invalid"
+	ExerciseNameEmpty            = "exercise_name_empty"
+	ExerciseNameTooLong          = "exercise_name_too_long"
+	ExerciseTemplateNameTaken    = "exercise_template_name_taken"
+	RouteRequired                = "route_required"
+	RouteTooLarge                = "route_too_large"
+	RouteInvalid                 = "route_invalid"
+	RoutePointCount              = "route_point_count"
+	RouteCoordinatesInvalid      = "route_coordinates_invalid"
+	DistanceOutOfRange           = "distance_out_of_range"
+	StepBucketCount              = "step_bucket_count"
+	StepHourNotAligned           = "step_hour_not_aligned"
+	StepOffsetInvalid            = "step_offset_invalid"
+	StepSourceInvalid            = "step_source_invalid"
+	StepsOutOfRange              = "steps_out_of_range"
+	HeartRateSampleCount         = "heart_rate_sample_count"
+	HeartRateTimeRequired        = "heart_rate_time_required"
+	HeartRateSourceInvalid       = "heart_rate_source_invalid"
+	HeartRateOutOfRange          = "heart_rate_out_of_range"
+	HeartRateResolutionInvalid   = "heart_rate_resolution_invalid"
+	HeartRateRangeTooLong        = "heart_rate_range_too_long"
+	MoodScoreOutOfRange          = "mood_score_out_of_range"
+	EnergyLevelOutOfRange        = "energy_level_out_of_range"
+	SymptomsInvalid              = "symptoms_invalid"
+	NoteTooLong                  = "note_too_long"
+	DurationNotPositive          = "duration_not_positive"
+	DurationTooLong              = "duration_too_long"
+	DurationMismatch             = "duration_mismatch"
+	CaloriesNegative             = "calories_negative"
+	CaloriesTooLarge             = "calories_too_large"
+	RPEOutOfRange                = "rpe_out_of_range"
+	TimeRangeIncomplete          = "time_range_incomplete"
+	EndedBeforeStarted           = "ended_before_started"
+	EndTimeBeforeStartTime       = "end_time_before_start_time"
+	MergeNeedsTwoRecords         = "merge_needs_two_records"
+	NoSamples                    = "no_samples"
+	UnsupportedSampleType        = "unsupported_sample_type"
+	NameRequired                 = "name_required"
+	NameTooLong                  = "name_too_long"
+	RangeTooLong                 = "range_too_long"
+	TimezoneInvalid              = "timezone_invalid"
+	ServingsOutOfRange           = "servings_out_of_range"
+	RecipeServingsOutOfRange     = "recipe_servings_out_of_range"
+	MealCaloriesTooLarge         = "meal_calories_too_large"
+	MealCaloriesOutOfRange       = "meal_calories_out_of_range"
+	IngredientCount              = "ingredient_count"
+	IngredientInvalid            = "ingredient_invalid"
+	QuantityOutOfRange           = "quantity_out_of_range"
+	IngredientCaloriesOutOfRange = "ingredient_calories_out_of_range"
+	MacroOutOfRange              = "macro_out_of_range"
+	FoodReferenceIncomplete      = "food_reference_incomplete"
+	FoodReferenceTooLong         = "food_reference_too_long"
+	FoodQueryLength              = "food_query_length"
+	FoodSearchLimitOutOfRange    = "food_search_limit_out_of_range"
+	BarcodeInvalid               = "barcode_invalid"
+	DoseTooLong                  = "dose_too_long"
+	DoseInvalid                  = "dose_invalid"
+	WorkoutExerciseCount         = "workout_exercise_count"
+	WorkoutExerciseInvalid       = "workout_exercise_invalid"
+	DashboardDaysOutOfRange      = "dashboard_days_out_of_range"
+	DiaryEntryCountOutOfRange    = "diary_entry_count_out_of_range"
+	ReminderScheduleRequired     = "reminder_schedule_required"
+	DeviceTokenInvalid           = "device_token_invalid"
+	GranteeEmailRequired         = "grantee_email_required"
+	CannotShareWithSelf          = "cannot_share_with_self"
+	RecordTypesRequired          = "record_types_required"
+	ExpiresAtRequired            = "expires_at_required"
+	ShareExpiryInvalid           = "share_expiry_invalid"
+	ShareTooLong                 = "share_too_long"
+	ShareLinkExpiryInvalid       = "share_link_expiry_invalid"
+	RefreshTokenRequired         = "refresh_token_required"
+	AuthorizationCodeRequired    = "authorization_code_required"
+	AuthorizationCodeRejected    = "authorization_code_rejected"
+	SubjectIDRequired            = "subject_id_required"
+	UserSelectorInvalid          = "user_selector_invalid"
+	CannotSuspendSelf            = "cannot_suspend_self"
+	DateInvalid                  = "date_invalid"
+	IDInvalid                    = "id_invalid"
+	PageTokenInvalid             = "page_token_invalid"
+	DateRangeTooLong             = "date_range_too_long"
+	SortOrderInvalid             = "sort_order_invalid"
+)
+
+// messages are the translations of the codes by locale. Besides the codes above, they hold a
+// message for every error reason and Connect code, for errors without a message of their own.
+var messages = map[string]map[string]string{
+	ContentEmpty: {
+		English:  "content cannot be empty",
+		Japanese: "内容を入力してください",
+	},
+	ContentTooLong: {
+		English:  "content exceeds maximum allowed length (10000 characters)",
+		Japanese: "内容は10000文字以内で入力してください",
+	},
+	TitleTooLong: {
+		English:  "title exceeds maximum allowed length (200 characters)",
+		Japanese: "タイトルは200文字以内で入力してください",
+	},
+	EndDateBeforeStartDate: {
+		English:  "end date must not be before start date",
+		Japanese: "終了日には開始日以降の日付を指定してください",
+	},
+	StartDateAfterEndDate: {
+		English:  "start_date must not be after end_date",
+		Japanese: "開始日には終了日以前の日付を指定してください",
+	},
+	SearchWindowTooLong: {
+		English:  "search window exceeds maximum allowed length (366 days)",
+		Japanese: "検索期間は366日以内で指定してください",
+	},
+	UnsupportedProvider: {
+		English:  "unsupported provider",
+		Japanese: "対応していない連携サービスです",
+	},
+	UnsupportedPlatform: {
+		English:  "unsupported platform",
+		Japanese: "対応していないプラットフォームです",
+	},
+	UnsupportedRecordType: {
+		English:  "unsupported record type",
+		Japanese: "対応していない記録の種類です",
+	},
+	UnsupportedIntensity: {
+		English:  "unsupported intensity",
+		Japanese: "対応していない運動強度です",
+	},
+	UnsupportedContentType: {
+		English:  "content type must be image/jpeg, image/png or image/webp",
+		Japanese: "画像の形式はJPEG、PNG、WebPのいずれかにしてください",
+	},
+	UploadMismatch: {
+		English:  "uploaded file does not match the declared type and size",
+		Japanese: "アップロードされたファイルが指定された形式・サイズと一致しません",
+	},
+	WeightNotPositive: {
+		English:  "weight must be positive",
+		Japanese: "体重には0より大きい値を入力してください",
+	},
+	WeightTooLarge: {
+		English:  "weight exceeds maximum allowed value",
+		Japanese: "体重が上限を超えています",
+	},
+	WeightAmbiguous: {
+		English:  "set either the weight in kg or the weight with its unit, not both",
+		Japanese: "体重はkgか単位付きのどちらか一方で指定してください",
+	},
+	WeightUnitInvalid: {
+		English:  "weight unit must be kg or lb",
+		Japanese: "体重の単位にはkgかlbを指定してください",
+	},
+	HeightUnitInvalid: {
+		English:  "height unit must be cm or in",
+		Japanese: "身長の単位にはcmかinを指定してください",
+	},
+	TargetWeightOutOfRange: {
+		English:  "target weight must be positive and at most 500 kg",
+		Japanese: "目標体重には0より大きく500 kg以下の値を入力してください",
+	},
+	BodyFatNegative: {
+		English:  "body fat percentage cannot be negative",
+		Japanese: "体脂肪率に負の値は入力できません",
+	},
+	BodyFatTooLarge: {
+		English:  "body fat percentage cannot exceed 100%",
+		Japanese: "体脂肪率は100%以下で入力してください",
+	},
+	WeeklyExerciseMinutesInvalid: {
+		English:  "weekly exercise minutes must be positive",
+		Japanese: "週の運動時間には0より大きい値を入力してください",
+	},
+	WeeklyCaloriesBurnedInvalid: {
+		English:  "weekly calories burned must be positive",
+		Japanese: "週の消費カロリーには0より大きい値を入力してください",
+	},
+	WeeklyDiaryEntriesInvalid: {
+		English:  "weekly diary entries must be between 1 and 7",
+		Japanese: "週の日記の数は1から7の間で入力してください",
+	},
+	ExerciseNameEmpty: {
+		English:  "exercise name cannot be empty",
+		Japanese: "運動名を入力してください",
+	},
+	ExerciseNameTooLong: {
+		English:  "exercise name exceeds maximum allowed length (100 characters)",
+		Japanese: "運動名は100文字以内で入力してください",
+	},
+	ExerciseTemplateNameTaken: {
+		English:  "an exercise template with this name already exists",
+		Japanese: "同じ名前の運動テンプレートがすでにあります",
+	},
+	RouteRequired: {
+		English:  "a route is required as a GPX file or an encoded polyline",
+		Japanese: "ルートをGPXファイルかエンコードされたポリラインで指定してください",
+	},
+	RouteTooLarge: {
+		English:  "route exceeds maximum allowed size (%d bytes)",
+		Japanese: "ルートは%dバイト以内にしてください",
+	},
+	RouteInvalid: {
+		English:  "route is not a valid GPX file or encoded polyline",
+		Japanese: "ルートの形式が正しくありません",
+	},
+	RoutePointCount: {
+		English:  "a route must have between 2 and %d points",
+		Japanese: "ルートの地点は2から%dの間にしてください",
+	},
+	RouteCoordinatesInvalid: {
+		English:  "route has latitudes or longitudes out of range",
+		Japanese: "ルートに範囲外の緯度または経度があります",
+	},
+	DistanceOutOfRange: {
+		English:  "distance must be between 0 and %d meters",
+		Japanese: "距離は0から%dメートルの間で入力してください",
+	},
+	StepBucketCount: {
+		English:  "a batch must have between 1 and %d step buckets",
+		Japanese: "歩数の件数は1から%dの間にしてください",
+	},
+	StepHourNotAligned: {
+		English:  "step bucket hours must start on the hour",
+		Japanese: "歩数の時刻は正時を指定してください",
+	},
+	StepOffsetInvalid: {
+		English:  "step bucket UTC offsets must be multiples of 15 minutes between -%d and %d",
+		Japanese: "歩数のUTCオフセットは-%dから%dの間の15分単位で指定してください",
+	},
+	StepSourceInvalid: {
+		English:  "step bucket source is required and must be at most %d characters",
+		Japanese: "歩数の計測元を%d文字以内で入力してください",
+	},
+	StepsOutOfRange: {
+		English:  "steps must be between 0 and %d per hour",
+		Japanese: "1時間の歩数は0から%dの間で入力してください",
+	},
+	HeartRateSampleCount: {
+		English:  "a batch must have between 1 and %d heart rate samples",
+		Japanese: "心拍数の件数は1から%dの間にしてください",
+	},
+	HeartRateTimeRequired: {
+		English:  "heart rate samples must have a measurement time",
+		Japanese: "心拍数の計測時刻を指定してください",
+	},
+	HeartRateSourceInvalid: {
+		English:  "heart rate source is required and must be at most %d characters",
+		Japanese: "心拍数の計測元を%d文字以内で入力してください",
+	},
+	HeartRateOutOfRange: {
+		English:  "heart rate must be between %d and %d bpm",
+		Japanese: "心拍数は%dから%dbpmの間で入力してください",
+	},
+	HeartRateResolutionInvalid: {
+		English:  "resolution must be minute, hour or day",
+		Japanese: "集計単位は分、時間、日のいずれかを指定してください",
+	},
+	HeartRateRangeTooLong: {
+		English:  "time range exceeds %d buckets at this resolution",
+		Japanese: "この集計単位では期間を%d区間以内にしてください",
+	},
+	MoodScoreOutOfRange: {
+		English:  "mood score must be between 1 and %d",
+		Japanese: "気分のスコアは1から%dの間で入力してください",
+	},
+	EnergyLevelOutOfRange: {
+		English:  "energy level must be between 1 and %d",
+		Japanese: "エネルギーレベルは1から%dの間で入力してください",
+	},
+	SymptomsInvalid: {
+		English:  "up to %d symptoms are allowed, each non-empty and at most %d characters",
+		Japanese: "症状は%d件まで、それぞれ%d文字以内で入力してください",
+	},
+	NoteTooLong: {
+		English:  "note must be at most %d characters",
+		Japanese: "メモは%d文字以内で入力してください",
+	},
+	DurationNotPositive: {
+		English:  "duration must be positive",
+		Japanese: "運動時間には0より大きい値を入力してください",
+	},
+	DurationTooLong: {
+		English:  "duration exceeds maximum allowed value (24 hours)",
+		Japanese: "運動時間は24時間以内で入力してください",
+	},
+	DurationMismatch: {
+		English:  "duration_minutes does not match started_at/ended_at",
+		Japanese: "運動時間が開始・終了時刻と一致しません",
+	},
+	CaloriesNegative: {
+		English:  "calories burned cannot be negative",
+		Japanese: "消費カロリーに負の値は入力できません",
+	},
+	CaloriesTooLarge: {
+		English:  "calories burned exceeds maximum allowed value",
+		Japanese: "消費カロリーが上限を超えています",
+	},
+	RPEOutOfRange: {
+		English:  "rpe must be between 1 and 10",
+		Japanese: "運動のきつさ(RPE)は1から10の間で入力してください",
+	},
+	TimeRangeIncomplete: {
+		English:  "started_at and ended_at must be provided together",
+		Japanese: "開始時刻と終了時刻は両方指定してください",
+	},
+	EndedBeforeStarted: {
+		English:  "ended_at must be after started_at",
+		Japanese: "終了時刻には開始時刻より後の時刻を指定してください",
+	},
+	EndTimeBeforeStartTime: {
+		English:  "end_time must be after start_time",
+		Japanese: "終了時刻には開始時刻より後の時刻を指定してください",
+	},
+	MergeNeedsTwoRecords: {
+		English:  "at least two distinct record IDs are required",
+		Japanese: "異なる記録を2件以上指定してください",
+	},
+	NoSamples: {
+		English:  "no samples provided",
+		Japanese: "データが指定されていません",
+	},
+	UnsupportedSampleType: {
+		English:  "sample %s has an unsupported type: only body mass, body fat percentage and workouts can be imported",
+		Japanese: "データ%sの種類には対応していません。体重、体脂肪率、ワークアウトのみ取り込めます",
+	},
+	NameRequired: {
+		English:  "name is required",
+		Japanese: "名前を入力してください",
+	},
+	NameTooLong: {
+		English:  "name must be at most %d characters",
+		Japanese: "名前は%d文字以内で入力してください",
+	},
+	RangeTooLong: {
+		English:  "range must be at most %d days",
+		Japanese: "期間は%d日以内で指定してください",
+	},
+	TimezoneInvalid: {
+		English:  "invalid time zone %q",
+		Japanese: "タイムゾーン%qは正しくありません",
+	},
+	ServingsOutOfRange: {
+		English:  "servings must be above 0 and at most %d",
+		Japanese: "食べた量には0より大きく%d以下の人数分を入力してください",
+	},
+	RecipeServingsOutOfRange: {
+		English:  "servings must be between 1 and %d",
+		Japanese: "レシピの人数分は1から%dの間で入力してください",
+	},
+	MealCaloriesTooLarge: {
+		English:  "meal calories must be at most %d",
+		Japanese: "食事のカロリーは%d kcal以下にしてください",
+	},
+	MealCaloriesOutOfRange: {
+		English:  "calories must be between 0 and %d",
+		Japanese: "カロリーは0から%dの間で入力してください",
+	},
+	IngredientCount: {
+		English:  "recipes must have 1 to %d ingredients",
+		Japanese: "レシピの材料は1から%d件の間にしてください",
+	},
+	IngredientInvalid: {
+		English:  "ingredient %d: %s",
+		Japanese: "%d番目の材料: %s",
+	},
+	QuantityOutOfRange: {
+		English:  "quantity_g must be above 0 and at most %d",
+		Japanese: "分量には0より大きく%d g以下の値を入力してください",
+	},
+	IngredientCaloriesOutOfRange: {
+		English:  "calories_kcal must be between 0 and %d",
+		Japanese: "100 gあたりのカロリーは0から%dの間で入力してください",
+	},
+	MacroOutOfRange: {
+		English:  "%s must be between 0 and 100",
+		Japanese: "%sは0から100の間で入力してください",
+	},
+	FoodReferenceIncomplete: {
+		English:  "food_source and food_id must be set together",
+		Japanese: "食品の提供元とIDは両方指定してください",
+	},
+	FoodReferenceTooLong: {
+		English:  "food_source or food_id is too long",
+		Japanese: "食品の提供元またはIDが長すぎます",
+	},
+	FoodQueryLength: {
+		English:  "query must have 2 to 100 characters",
+		Japanese: "検索語は2文字から100文字の間で入力してください",
+	},
+	FoodSearchLimitOutOfRange: {
+		English:  "limit must be between 1 and %d",
+		Japanese: "件数は1から%dの間で指定してください",
+	},
+	BarcodeInvalid: {
+		English:  "barcode must have 8 to 14 digits",
+		Japanese: "バーコードは8桁から14桁の数字で入力してください",
+	},
+	DoseTooLong: {
+		English:  "dose must be at most %d characters",
+		Japanese: "用量は%d文字以内で入力してください",
+	},
+	DoseInvalid: {
+		English:  "dose is required and must be at most %d characters",
+		Japanese: "用量を%d文字以内で入力してください",
+	},
+	WorkoutExerciseCount: {
+		English:  "a workout session must have between 1 and %d exercises",
+		Japanese: "ワークアウトの運動は1から%d件の間にしてください",
+	},
+	WorkoutExerciseInvalid: {
+		English:  "exercise %d: %s",
+		Japanese: "%d番目の運動: %s",
+	},
+	DashboardDaysOutOfRange: {
+		English:  "days must be between 1 and %d",
+		Japanese: "日数は1から%dの間で指定してください",
+	},
+	DiaryEntryCountOutOfRange: {
+		English:  "diary entry count must be between 1 and %d",
+		Japanese: "日記の件数は1から%dの間で指定してください",
+	},
+	ReminderScheduleRequired: {
+		English:  "daily_at or cron is required",
+		Japanese: "通知する時刻かスケジュールを指定してください",
+	},
+	DeviceTokenInvalid: {
+		English:  "token is required and must be at most 4096 characters",
+		Japanese: "トークンを4096文字以内で指定してください",
+	},
+	GranteeEmailRequired: {
+		English:  "grantee email is required",
+		Japanese: "共有相手のメールアドレスを入力してください",
+	},
+	CannotShareWithSelf: {
+		English:  "cannot share records with yourself",
+		Japanese: "自分自身とは記録を共有できません",
+	},
+	RecordTypesRequired: {
+		English:  "at least one record type is required",
+		Japanese: "記録の種類を1つ以上指定してください",
+	},
+	ExpiresAtRequired: {
+		English:  "expires_at is required",
+		Japanese: "共有の終了日時を指定してください",
+	},
+	ShareExpiryInvalid: {
+		English:  "expires_at must be after starts_at and in the future",
+		Japanese: "共有の終了日時には開始日時より後の未来の日時を指定してください",
+	},
+	ShareTooLong: {
+		English:  "a share can last at most 366 days",
+		Japanese: "共有期間は366日以内で指定してください",
+	},
+	ShareLinkExpiryInvalid: {
+		English:  "expires_in_days must be between 1 and %d",
+		Japanese: "共有リンクの有効期間は1日から%d日の間で指定してください",
+	},
+	RefreshTokenRequired: {
+		English:  "refresh token is required",
+		Japanese: "リフレッシュトークンを指定してください",
+	},
+	AuthorizationCodeRequired: {
+		English:  "authorization code is required",
+		Japanese: "認可コードを指定してください",
+	},
+	AuthorizationCodeRejected: {
+		English:  "authorization code was rejected by the provider",
+		Japanese: "連携サービスが認可コードを受け付けませんでした",
+	},
+	SubjectIDRequired: {
+		English:  "subject_id is required",
+		Japanese: "subject_idを指定してください",
+	},
+	UserSelectorInvalid: {
+		English:  "exactly one of user_id or subject_id must be set",
+		Japanese: "user_idかsubject_idのどちらか一方を指定してください",
+	},
+	CannotSuspendSelf: {
+		English:  "cannot suspend your own account",
+		Japanese: "自分のアカウントは停止できません",
+	},
+	DateInvalid: {
+		English:  "%s must be a date in YYYY-MM-DD format",
+		Japanese: "%sにはYYYY-MM-DD形式の日付を指定してください",
+	},
+	IDInvalid: {
+		English:  "%s must be a UUID",
+		Japanese: "%sにはUUIDを指定してください",
+	},
+	PageTokenInvalid: {
+		English:  "%s must be the next_page_token of a previous response",
+		Japanese: "%sには前回のレスポンスのnext_page_tokenを指定してください",
+	},
+	DateRangeTooLong: {
+		English:  "date range must span at most %d days",
+		Japanese: "期間は%d日以内で指定してください",
+	},
+	SortOrderInvalid: {
+		English:  "order must be ascending or descending",
+		Japanese: "並び順には昇順か降順を指定してください",
+	},
+
+	// Reasons of apierror
+	"validation_future_date": {
+		English:  "the date cannot be in the future",
+		Japanese: "未来の日付は指定できません",
+	},
+	"not_shared": {
+		English:  "these records are not shared with you",
+		Japanese: "この記録は共有されていません",
+	},
+	"account_suspended": {
+		English:  "your account is suspended",
+		Japanese: "アカウントは停止されています",
+	},
+	"missing_scope": {
+		English:  "your token is not allowed to make this request",
+		Japanese: "このトークンではこの操作を行えません",
+	},
+	"quota_exceeded": {
+		English:  "you made too many requests today; try again tomorrow",
+		Japanese: "本日のリクエスト数の上限に達しました。明日また試してください",
+	},
+	"limit_exceeded": {
+		English:  "the request exceeds a limit",
+		Japanese: "リクエストが上限を超えています",
+	},
+	"database_unavailable": {
+		English:  "the service is temporarily unavailable; try again later",
+		Japanese: "一時的にサービスを利用できません。しばらくしてから試してください",
+	},
+	"database_timeout": {
+		English:  "the request took too long; try again later",
+		Japanese: "処理に時間がかかりすぎました。しばらくしてから試してください",
+	},
+	"maintenance": {
+		English:  "the service is under maintenance",
+		Japanese: "メンテナンス中です",
+	},
+
+	// Connect codes, for errors without a reason; not_found is also a reason
+	"canceled": {
+		English:  "the request was canceled",
+		Japanese: "リクエストはキャンセルされました",
+	},
+	"unknown": {
+		English:  "an unknown error occurred",
+		Japanese: "不明なエラーが発生しました",
+	},
+	"invalid_argument": {
+		English:  "the request is invalid",
+		Japanese: "入力内容に誤りがあります",
+	},
+	"deadline_exceeded": {
+		English:  "the request took too long",
+		Japanese: "処理に時間がかかりすぎました",
+	},
+	"not_found": {
+		English:  "not found",
+		Japanese: "見つかりません",
+	},
+	"already_exists": {
+		English:  "it already exists",
+		Japanese: "既に存在します",
+	},
+	"permission_denied": {
+		English:  "you are not allowed to do this",
+		Japanese: "この操作を行う権限がありません",
+	},
+	"resource_exhausted": {
+		English:  "a limit was reached",
+		Japanese: "上限に達しました",
+	},
+	"failed_precondition": {
+		English:  "this can't be done right now",
+		Japanese: "現在この操作は行えません",
+	},
+	"aborted": {
+		English:  "the request was aborted; try again",
+		Japanese: "処理が中断されました。もう一度試してください",
+	},
+	"out_of_range": {
+		English:  "a value is out of range",
+		Japanese: "値が範囲外です",
+	},
+	"unimplemented": {
+		English:  "this is not supported",
+		Japanese: "この操作には対応していません",
+	},
+	"internal": {
+		English:  "something went wrong on our side",
+		Japanese: "サーバーでエラーが発生しました",
+	},
+	"unavailable": {
+		English:  "the service is temporarily unavailable; try again later",
+		Japanese: "一時的にサービスを利用できません。しばらくしてから試してください",
+	},
+	"data_loss": {
+		English:  "data was lost",
+		Japanese: "データが失われました",
+	},
+	"unauthenticated": {
+		English:  "please sign in",
+		Japanese: "ログインしてください",
+	},
+}
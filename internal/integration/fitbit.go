@@ -0,0 +1,261 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// ProviderFitbit is the provider name of Fitbit integrations
+const ProviderFitbit = "fitbit"
+
+const (
+	fitbitTokenURL   = "https://api.fitbit.com/oauth2/token"
+	fitbitRevokeURL  = "https://api.fitbit.com/oauth2/revoke"
+	fitbitAPIBaseURL = "https://api.fitbit.com/1/user/-"
+	// fitbitWeightLogMaxRange is the longest date range accepted by the weight log endpoint
+	fitbitWeightLogMaxRange = 31 * 24 * time.Hour
+	// fitbitActivityPageSize is the maximum page size of the activity log list
+	fitbitActivityPageSize = 100
+	// fitbitActivityTimeLayout is the start time format of activity log entries
+	fitbitActivityTimeLayout = "2006-01-02T15:04:05.000-07:00"
+)
+
+// Fitbit fetches weight logs, daily steps and logged activities from the Fitbit Web API.
+// Requests are sent without Accept-Language, so measurements are reported in metric units.
+type Fitbit struct {
+	ClientID     string
+	ClientSecret string
+	// TokenURL, RevokeURL and APIBaseURL default to the Fitbit endpoints
+	TokenURL   string
+	RevokeURL  string
+	APIBaseURL string
+	HTTPClient *http.Client
+}
+
+// NewFitbit creates a Fitbit provider for an OAuth client
+func NewFitbit(clientID, clientSecret string) *Fitbit {
+	return &Fitbit{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     fitbitTokenURL,
+		RevokeURL:    fitbitRevokeURL,
+		APIBaseURL:   fitbitAPIBaseURL,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (f *Fitbit) Name() string {
+	return ProviderFitbit
+}
+
+// ExchangeCode exchanges an OAuth authorization code for tokens
+func (f *Fitbit) ExchangeCode(ctx context.Context, code, redirectURI string) (Token, error) {
+	return f.token(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+		"client_id":    {f.ClientID},
+	})
+}
+
+// RefreshToken obtains a new access token from a refresh token.
+// Fitbit refresh tokens are single-use, so the returned token includes a new refresh token.
+func (f *Fitbit) RefreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	return f.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// RevokeToken revokes an access or refresh token
+func (f *Fitbit) RevokeToken(ctx context.Context, token string) error {
+	req, err := f.formRequest(ctx, f.RevokeURL, url.Values{"token": {token}})
+	if err != nil {
+		return fmt.Errorf("failed to create revoke request: %w", err)
+	}
+	return sendRevoke(f.HTTPClient, req)
+}
+
+func (f *Fitbit) token(ctx context.Context, form url.Values) (Token, error) {
+	req, err := f.formRequest(ctx, f.TokenURL, form)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	return requestToken(f.HTTPClient, req)
+}
+
+// formRequest creates a form POST authenticated with the client credentials, as Fitbit requires
+func (f *Fitbit) formRequest(ctx context.Context, endpoint string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(f.ClientID, f.ClientSecret)
+	return req, nil
+}
+
+// FetchData retrieves weight logs, daily step totals and logged activities in [start, end)
+func (f *Fitbit) FetchData(ctx context.Context, accessToken string, start, end time.Time) (repo.ImportBatch, error) {
+	var batch repo.ImportBatch
+	var err error
+
+	if batch.BodyMeasurements, err = f.fetchWeight(ctx, accessToken, start, end); err != nil {
+		return repo.ImportBatch{}, err
+	}
+	if batch.DailySteps, err = f.fetchDailySteps(ctx, accessToken, start, end); err != nil {
+		return repo.ImportBatch{}, err
+	}
+	if batch.Exercises, err = f.fetchActivities(ctx, accessToken, start, end); err != nil {
+		return repo.ImportBatch{}, err
+	}
+
+	return batch, nil
+}
+
+func (f *Fitbit) fetchWeight(ctx context.Context, accessToken string, start, end time.Time) ([]repo.ImportedBodyMeasurement, error) {
+	var measurements []repo.ImportedBodyMeasurement
+
+	// The weight log endpoint only accepts ranges of up to 31 days
+	for from := start.UTC(); from.Before(end); from = from.Add(fitbitWeightLogMaxRange) {
+		to := from.Add(fitbitWeightLogMaxRange - 24*time.Hour)
+		if to.After(end) {
+			to = end.UTC()
+		}
+
+		endpoint := fmt.Sprintf("%s/body/log/weight/date/%s/%s.json", f.APIBaseURL, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create weight request: %w", err)
+		}
+
+		var log struct {
+			Weight []struct {
+				LogID  int64    `json:"logId"`
+				Date   string   `json:"date"`
+				Weight float64  `json:"weight"`
+				Fat    *float64 `json:"fat"`
+			} `json:"weight"`
+		}
+		if err := getJSON(f.HTTPClient, req, accessToken, &log); err != nil {
+			return nil, fmt.Errorf("failed to fetch weight: %w", err)
+		}
+
+		for _, entry := range log.Weight {
+			date, err := time.Parse("2006-01-02", entry.Date)
+			if err != nil {
+				continue
+			}
+			w, ok := roundedWeightKg(entry.Weight)
+			if !ok {
+				continue
+			}
+			m := repo.ImportedBodyMeasurement{
+				SourceSampleID: "weight:" + strconv.FormatInt(entry.LogID, 10),
+				Date:           date,
+				WeightKg:       &w,
+			}
+			if entry.Fat != nil && *entry.Fat >= 0 && *entry.Fat <= 100 {
+				m.BodyFatPercentage = entry.Fat
+			}
+			measurements = append(measurements, m)
+		}
+	}
+
+	return measurements, nil
+}
+
+func (f *Fitbit) fetchDailySteps(ctx context.Context, accessToken string, start, end time.Time) ([]repo.ImportedDailySteps, error) {
+	endpoint := fmt.Sprintf("%s/activities/steps/date/%s/%s.json", f.APIBaseURL, start.UTC().Format("2006-01-02"), end.UTC().Format("2006-01-02"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create steps request: %w", err)
+	}
+
+	var series struct {
+		Steps []struct {
+			DateTime string `json:"dateTime"`
+			Value    string `json:"value"`
+		} `json:"activities-steps"`
+	}
+	if err := getJSON(f.HTTPClient, req, accessToken, &series); err != nil {
+		return nil, fmt.Errorf("failed to fetch steps: %w", err)
+	}
+
+	steps := make([]repo.ImportedDailySteps, 0, len(series.Steps))
+	for _, s := range series.Steps {
+		date, err := time.Parse("2006-01-02", s.DateTime)
+		if err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(s.Value, 10, 32)
+		if err != nil || count <= 0 {
+			continue
+		}
+		steps = append(steps, repo.ImportedDailySteps{Date: date, Steps: int32(count)})
+	}
+	return steps, nil
+}
+
+func (f *Fitbit) fetchActivities(ctx context.Context, accessToken string, start, end time.Time) ([]repo.ImportedExercise, error) {
+	query := url.Values{
+		"afterDate": {start.UTC().Format("2006-01-02T15:04:05")},
+		"sort":      {"asc"},
+		"offset":    {"0"},
+		"limit":     {strconv.Itoa(fitbitActivityPageSize)},
+	}
+	next := f.APIBaseURL + "/activities/list.json?" + query.Encode()
+
+	var exercises []repo.ImportedExercise
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create activities request: %w", err)
+		}
+
+		var page struct {
+			Activities []struct {
+				LogID        int64  `json:"logId"`
+				ActivityName string `json:"activityName"`
+				StartTime    string `json:"startTime"`
+				Duration     int64  `json:"duration"` // Milliseconds
+				Calories     *int32 `json:"calories"`
+			} `json:"activities"`
+			Pagination struct {
+				Next string `json:"next"`
+			} `json:"pagination"`
+		}
+		if err := getJSON(f.HTTPClient, req, accessToken, &page); err != nil {
+			return nil, fmt.Errorf("failed to fetch activities: %w", err)
+		}
+
+		next = page.Pagination.Next
+		for _, a := range page.Activities {
+			started, err := time.Parse(fitbitActivityTimeLayout, a.StartTime)
+			if err != nil {
+				continue
+			}
+			if !started.Before(end) {
+				// Sorted ascending, so the remaining activities are outside the window
+				next = ""
+				break
+			}
+			ended := started.Add(time.Duration(a.Duration) * time.Millisecond)
+			e, ok := timedExercise("activity:"+strconv.FormatInt(a.LogID, 10), a.ActivityName, started, ended, a.Calories)
+			if !ok {
+				continue
+			}
+			exercises = append(exercises, e)
+		}
+	}
+
+	return exercises, nil
+}
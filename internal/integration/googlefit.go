@@ -0,0 +1,261 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// ProviderGoogleFit is the provider name of Google Fit integrations
+const ProviderGoogleFit = "google_fit"
+
+const (
+	googleFitTokenURL   = "https://oauth2.googleapis.com/token"
+	googleFitRevokeURL  = "https://oauth2.googleapis.com/revoke"
+	googleFitAPIBaseURL = "https://www.googleapis.com/fitness/v1/users/me"
+	// googleFitWeightSource is the merged weight stream across all apps and devices
+	googleFitWeightSource = "derived:com.google.weight:com.google.android.gms:merge_weight"
+	// googleFitActivitySleep is the session activity type for sleep, which is not stored
+	googleFitActivitySleep = 72
+)
+
+// googleFitActivityNames maps common Google Fit activity types to exercise names
+var googleFitActivityNames = map[int]string{
+	1:   "Biking",
+	7:   "Walking",
+	8:   "Running",
+	24:  "Dancing",
+	35:  "Hiking",
+	80:  "Strength training",
+	82:  "Swimming",
+	100: "Yoga",
+}
+
+// GoogleFit fetches weight, daily steps and workout sessions from the Google Fit REST API
+type GoogleFit struct {
+	ClientID     string
+	ClientSecret string
+	// TokenURL, RevokeURL and APIBaseURL default to the Google endpoints
+	TokenURL   string
+	RevokeURL  string
+	APIBaseURL string
+	HTTPClient *http.Client
+}
+
+// NewGoogleFit creates a Google Fit provider for an OAuth client
+func NewGoogleFit(clientID, clientSecret string) *GoogleFit {
+	return &GoogleFit{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     googleFitTokenURL,
+		RevokeURL:    googleFitRevokeURL,
+		APIBaseURL:   googleFitAPIBaseURL,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (g *GoogleFit) Name() string {
+	return ProviderGoogleFit
+}
+
+// ExchangeCode exchanges an OAuth authorization code for tokens
+func (g *GoogleFit) ExchangeCode(ctx context.Context, code, redirectURI string) (Token, error) {
+	return g.token(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	})
+}
+
+// RefreshToken obtains a new access token from a refresh token
+func (g *GoogleFit) RefreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	return g.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// RevokeToken revokes an access or refresh token
+func (g *GoogleFit) RevokeToken(ctx context.Context, token string) error {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.RevokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create revoke request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return sendRevoke(g.HTTPClient, req)
+}
+
+func (g *GoogleFit) token(ctx context.Context, form url.Values) (Token, error) {
+	form.Set("client_id", g.ClientID)
+	form.Set("client_secret", g.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return requestToken(g.HTTPClient, req)
+}
+
+// FetchData retrieves weight measurements, daily step totals and workout sessions in [start, end)
+func (g *GoogleFit) FetchData(ctx context.Context, accessToken string, start, end time.Time) (repo.ImportBatch, error) {
+	var batch repo.ImportBatch
+	var err error
+
+	if batch.BodyMeasurements, err = g.fetchWeight(ctx, accessToken, start, end); err != nil {
+		return repo.ImportBatch{}, err
+	}
+	if batch.DailySteps, err = g.fetchDailySteps(ctx, accessToken, start, end); err != nil {
+		return repo.ImportBatch{}, err
+	}
+	if batch.Exercises, err = g.fetchSessions(ctx, accessToken, start, end); err != nil {
+		return repo.ImportBatch{}, err
+	}
+
+	return batch, nil
+}
+
+func (g *GoogleFit) fetchWeight(ctx context.Context, accessToken string, start, end time.Time) ([]repo.ImportedBodyMeasurement, error) {
+	endpoint := fmt.Sprintf("%s/dataSources/%s/datasets/%d-%d", g.APIBaseURL, url.PathEscape(googleFitWeightSource), start.UnixNano(), end.UnixNano())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create weight request: %w", err)
+	}
+
+	var dataset struct {
+		Point []struct {
+			StartTimeNanos int64 `json:"startTimeNanos,string"`
+			Value          []struct {
+				FpVal float64 `json:"fpVal"`
+			} `json:"value"`
+		} `json:"point"`
+	}
+	if err := getJSON(g.HTTPClient, req, accessToken, &dataset); err != nil {
+		return nil, fmt.Errorf("failed to fetch weight: %w", err)
+	}
+
+	measurements := make([]repo.ImportedBodyMeasurement, 0, len(dataset.Point))
+	for _, p := range dataset.Point {
+		if len(p.Value) == 0 {
+			continue
+		}
+		w, ok := roundedWeightKg(p.Value[0].FpVal)
+		if !ok {
+			continue
+		}
+		measurements = append(measurements, repo.ImportedBodyMeasurement{
+			SourceSampleID: "weight:" + strconv.FormatInt(p.StartTimeNanos, 10),
+			Date:           time.Unix(0, p.StartTimeNanos).UTC().Truncate(24 * time.Hour),
+			WeightKg:       &w,
+		})
+	}
+	return measurements, nil
+}
+
+func (g *GoogleFit) fetchDailySteps(ctx context.Context, accessToken string, start, end time.Time) ([]repo.ImportedDailySteps, error) {
+	// Buckets are aligned to the start time, so start from midnight UTC
+	dayStart := start.UTC().Truncate(24 * time.Hour)
+	body, err := json.Marshal(map[string]any{
+		"aggregateBy":     []map[string]string{{"dataTypeName": "com.google.step_count.delta"}},
+		"bucketByTime":    map[string]int64{"durationMillis": (24 * time.Hour).Milliseconds()},
+		"startTimeMillis": dayStart.UnixMilli(),
+		"endTimeMillis":   end.UnixMilli(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode steps request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.APIBaseURL+"/dataset:aggregate", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create steps request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var aggregate struct {
+		Bucket []struct {
+			StartTimeMillis int64 `json:"startTimeMillis,string"`
+			Dataset         []struct {
+				Point []struct {
+					Value []struct {
+						IntVal int32 `json:"intVal"`
+					} `json:"value"`
+				} `json:"point"`
+			} `json:"dataset"`
+		} `json:"bucket"`
+	}
+	if err := getJSON(g.HTTPClient, req, accessToken, &aggregate); err != nil {
+		return nil, fmt.Errorf("failed to fetch steps: %w", err)
+	}
+
+	steps := make([]repo.ImportedDailySteps, 0, len(aggregate.Bucket))
+	for _, b := range aggregate.Bucket {
+		var total int32
+		for _, ds := range b.Dataset {
+			for _, p := range ds.Point {
+				for _, v := range p.Value {
+					total += v.IntVal
+				}
+			}
+		}
+		if total <= 0 {
+			continue
+		}
+		steps = append(steps, repo.ImportedDailySteps{
+			Date:  time.UnixMilli(b.StartTimeMillis).UTC().Truncate(24 * time.Hour),
+			Steps: total,
+		})
+	}
+	return steps, nil
+}
+
+func (g *GoogleFit) fetchSessions(ctx context.Context, accessToken string, start, end time.Time) ([]repo.ImportedExercise, error) {
+	query := url.Values{
+		"startTime": {start.UTC().Format(time.RFC3339)},
+		"endTime":   {end.UTC().Format(time.RFC3339)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.APIBaseURL+"/sessions?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sessions request: %w", err)
+	}
+
+	var sessions struct {
+		Session []struct {
+			ID              string `json:"id"`
+			Name            string `json:"name"`
+			StartTimeMillis int64  `json:"startTimeMillis,string"`
+			EndTimeMillis   int64  `json:"endTimeMillis,string"`
+			ActivityType    int    `json:"activityType"`
+		} `json:"session"`
+	}
+	if err := getJSON(g.HTTPClient, req, accessToken, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+
+	exercises := make([]repo.ImportedExercise, 0, len(sessions.Session))
+	for _, s := range sessions.Session {
+		if s.ActivityType == googleFitActivitySleep || s.ID == "" {
+			continue
+		}
+		name := googleFitActivityNames[s.ActivityType]
+		if name == "" {
+			name = s.Name
+		}
+		e, ok := timedExercise("session:"+s.ID, name, time.UnixMilli(s.StartTimeMillis), time.UnixMilli(s.EndTimeMillis), nil)
+		if !ok {
+			continue
+		}
+		exercises = append(exercises, e)
+	}
+	return exercises, nil
+}
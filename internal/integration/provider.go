@@ -0,0 +1,153 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// ErrInvalidGrant is returned when a provider rejects an authorization code or refresh token
+var ErrInvalidGrant = errors.New("authorization grant rejected by provider")
+
+// Token is an OAuth token set issued by a provider
+type Token struct {
+	AccessToken  string
+	RefreshToken string // May be empty on refresh, in which case the previous one stays valid
+	ExpiresIn    time.Duration
+	// ExternalUserID is the provider's ID of the account, set by providers that push notifications
+	ExternalUserID string
+}
+
+// Provider is an external health data source a user can link via OAuth
+type Provider interface {
+	// Name identifies the provider in the integrations and imported_samples tables
+	Name() string
+	// ExchangeCode exchanges an OAuth authorization code for tokens
+	ExchangeCode(ctx context.Context, code, redirectURI string) (Token, error)
+	// RefreshToken obtains a new access token from a refresh token
+	RefreshToken(ctx context.Context, refreshToken string) (Token, error)
+	// RevokeToken invalidates a token when an account is unlinked
+	RevokeToken(ctx context.Context, token string) error
+	// FetchData retrieves the samples recorded in [start, end)
+	FetchData(ctx context.Context, accessToken string, start, end time.Time) (repo.ImportBatch, error)
+}
+
+// NotificationSubscriber is implemented by providers that push notifications when new data is
+// recorded, so linked accounts are synced without waiting for the next sync interval
+type NotificationSubscriber interface {
+	// Subscribe registers the account of accessToken for data notifications
+	Subscribe(ctx context.Context, accessToken string) error
+}
+
+// roundedWeightKg rounds a weight to two decimals, reporting false outside the CreateBodyRecord bounds
+func roundedWeightKg(w float64) (float64, bool) {
+	w = math.Round(w*100) / 100
+	return w, w > 0 && w <= 500
+}
+
+// timedExercise builds an exercise spanning [started, ended), reporting false for ranges
+// CreateExerciseRecord would reject
+func timedExercise(sourceSampleID, name string, started, ended time.Time, caloriesBurned *int32) (repo.ImportedExercise, bool) {
+	started, ended = started.UTC(), ended.UTC()
+	if !ended.After(started) {
+		return repo.ImportedExercise{}, false
+	}
+	duration := int32(math.Round(ended.Sub(started).Minutes()))
+	if duration <= 0 {
+		duration = 1
+	}
+	if duration > 1440 {
+		return repo.ImportedExercise{}, false
+	}
+	if name == "" || len(name) > 100 {
+		name = "Workout"
+	}
+	if caloriesBurned != nil && (*caloriesBurned < 0 || *caloriesBurned > 10000) {
+		caloriesBurned = nil
+	}
+
+	return repo.ImportedExercise{
+		SourceSampleID:  sourceSampleID,
+		ExerciseName:    name,
+		DurationMinutes: &duration,
+		CaloriesBurned:  caloriesBurned,
+		RecordedAt:      started,
+		StartedAt:       &started,
+		EndedAt:         &ended,
+	}, true
+}
+
+// requestToken sends an OAuth token request and decodes the token response
+func requestToken(client *http.Client, req *http.Request) (Token, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to send token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Error        string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return Token{}, fmt.Errorf("failed to decode token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if body.Error == "invalid_grant" {
+			return Token{}, ErrInvalidGrant
+		}
+		return Token{}, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, body.Error)
+	}
+	if body.AccessToken == "" {
+		return Token{}, errors.New("token response has no access token")
+	}
+
+	return Token{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		ExpiresIn:    time.Duration(body.ExpiresIn) * time.Second,
+	}, nil
+}
+
+// sendRevoke sends an OAuth token revocation request
+func sendRevoke(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send revoke request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revoke request failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getJSON sends an authenticated API request and decodes the JSON response into out
+func getJSON(client *http.Client, req *http.Request, accessToken string, out any) error {
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to %s: %w", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed with status %d", req.URL.Path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+const (
+	// initialSyncWindow is how far back the first sync of a newly linked account reaches
+	initialSyncWindow = 30 * 24 * time.Hour
+	// lateSampleWindow is how far before the sync cursor each sync reaches again, so samples the
+	// device uploads to the provider after a sync, dated before it, are still imported; samples
+	// already imported are skipped as duplicates
+	lateSampleWindow = 24 * time.Hour
+	// tokenRefreshMargin refreshes access tokens that expire within this margin
+	tokenRefreshMargin = time.Minute
+)
+
+// Syncer periodically pulls data from linked integrations into the record tables
+type Syncer struct {
+	integrations *repo.IntegrationRepository
+	imports      *repo.ImportRepository
+	providers    map[string]Provider
+	interval     time.Duration
+	log          *slog.Logger
+	clock        clock.Clock
+	// mu serializes sync passes so tokens of an integration are never refreshed concurrently
+	mu sync.Mutex
+}
+
+// NewSyncer creates a syncer for the given providers, syncing each integration once per interval
+func NewSyncer(integrations *repo.IntegrationRepository, imports *repo.ImportRepository, providers []Provider, interval time.Duration, log *slog.Logger, clock clock.Clock) *Syncer {
+	byName := make(map[string]Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return &Syncer{
+		integrations: integrations,
+		imports:      imports,
+		providers:    byName,
+		interval:     interval,
+		log:          log,
+		clock:        clock,
+	}
+}
+
+// Run syncs all due integrations once per interval until ctx is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.SyncDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncDue syncs every integration not synced during the last interval.
+// Failures are recorded on the integration and do not stop the remaining syncs.
+func (s *Syncer) SyncDue(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	syncedBefore := s.clock.Now().Add(-s.interval)
+	for name := range s.providers {
+		integrations, err := s.integrations.FindDueForSync(ctx, name, syncedBefore)
+		if err != nil {
+			s.log.ErrorContext(ctx, "Failed to list integrations due for sync", "provider", name, "error", err)
+			continue
+		}
+		for _, integration := range integrations {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := s.Sync(ctx, integration); err != nil {
+				s.log.WarnContext(ctx, "Integration sync failed", "provider", name, "userID", integration.UserID, "error", err)
+			}
+		}
+	}
+}
+
+// SyncExternalUser syncs the integrations linked to a provider account, e.g. after the provider
+// notified new data. Notifications for accounts that are not linked are ignored.
+func (s *Syncer) SyncExternalUser(ctx context.Context, provider, externalUserID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	integrations, err := s.integrations.FindByExternalUser(ctx, provider, externalUserID)
+	if err != nil {
+		return err
+	}
+	if len(integrations) == 0 {
+		s.log.InfoContext(ctx, "Ignoring notification for unlinked account", "provider", provider, "externalUserID", externalUserID)
+		return nil
+	}
+
+	var errs []error
+	for _, integration := range integrations {
+		if err := s.Sync(ctx, integration); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync integration %s: %w", integration.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sync pulls the data recorded since the integration's sync cursor, less the late sample window,
+// and stores the resulting sync state.
+// Unlike SyncDue and SyncExternalUser it is not serialized with other syncs.
+func (s *Syncer) Sync(ctx context.Context, integration repo.Integration) error {
+	now := s.clock.Now()
+	result, err := s.sync(ctx, integration, now)
+	if err != nil {
+		if recordErr := s.integrations.RecordSyncFailure(ctx, integration.Integration, err, now); recordErr != nil {
+			s.log.ErrorContext(ctx, "Failed to record integration sync failure", "integrationID", integration.ID, "error", recordErr)
+		}
+		return err
+	}
+
+	if err := s.integrations.RecordSyncSuccess(ctx, integration.ID, now, now); err != nil {
+		return err
+	}
+	s.log.InfoContext(ctx, "Integration synced", "provider", integration.Provider, "userID", integration.UserID,
+		"imported", result.Imported, "duplicates", result.Duplicates, "conflicts", result.Conflicts)
+	return nil
+}
+
+func (s *Syncer) sync(ctx context.Context, integration repo.Integration, now time.Time) (repo.ImportBatchResult, error) {
+	provider, ok := s.providers[integration.Provider]
+	if !ok {
+		return repo.ImportBatchResult{}, fmt.Errorf("provider %q is not configured", integration.Provider)
+	}
+
+	accessToken := integration.AccessToken
+	if !integration.TokenExpiresAt.After(now.Add(tokenRefreshMargin)) {
+		token, err := provider.RefreshToken(ctx, integration.RefreshToken)
+		if err != nil {
+			return repo.ImportBatchResult{}, fmt.Errorf("failed to refresh access token: %w", err)
+		}
+		refreshToken := token.RefreshToken
+		if refreshToken == "" {
+			refreshToken = integration.RefreshToken
+		}
+		if err := s.integrations.UpdateTokens(ctx, integration.ID, token.AccessToken, refreshToken, now.Add(token.ExpiresIn), now); err != nil {
+			return repo.ImportBatchResult{}, err
+		}
+		accessToken = token.AccessToken
+	}
+
+	start := now.Add(-initialSyncWindow)
+	if integration.SyncCursor.Valid {
+		start = integration.SyncCursor.Time.Add(-lateSampleWindow)
+	}
+
+	batch, err := provider.FetchData(ctx, accessToken, start, now)
+	if err != nil {
+		return repo.ImportBatchResult{}, err
+	}
+	// Device sessions duplicating manually entered workouts are dropped rather than doubled
+	batch.SkipOverlappingExercises = true
+
+	return s.imports.ImportBatch(ctx, integration.UserID, integration.Provider, batch, now)
+}
@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+const (
+	// WithingsWebhookPath is the path the Withings notification webhook is served at
+	WithingsWebhookPath = "/webhooks/withings"
+	// withingsWebhookSecretParam is the callback URL query parameter carrying the webhook secret
+	withingsWebhookSecretParam = "secret"
+	// withingsNotificationSyncTimeout bounds the sync triggered by a notification
+	withingsNotificationSyncTimeout = time.Minute
+)
+
+// WithingsCallbackURL builds the callback URL registered with Withings from the public URL of
+// the webhook and its secret
+func WithingsCallbackURL(webhookURL, secret string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("webhook URL must be an absolute http(s) URL: %q", webhookURL)
+	}
+	query := u.Query()
+	query.Set(withingsWebhookSecretParam, secret)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// WithingsWebhook receives Withings data notifications and syncs the notified accounts.
+// Notifications only identify the account; the measurements are always fetched from the API.
+type WithingsWebhook struct {
+	syncer  *Syncer
+	secret  string
+	regions []string // Data regions whose databases may link the notified accounts
+	log     *slog.Logger
+	wg      sync.WaitGroup
+}
+
+// NewWithingsWebhook creates a webhook accepting notifications whose callback URL carries secret.
+// Notifications name no user of the API, so the accounts are looked up in the databases of all
+// the data regions; "" is the home database.
+func NewWithingsWebhook(syncer *Syncer, secret string, regions []string, log *slog.Logger) *WithingsWebhook {
+	return &WithingsWebhook{
+		syncer:  syncer,
+		secret:  secret,
+		regions: regions,
+		log:     log,
+	}
+}
+
+// ServeHTTP handles the callback URL verification and data notifications
+func (h *WithingsWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Notifications are not signed, so the callback URL carries a shared secret
+	secret := r.URL.Query().Get(withingsWebhookSecretParam)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(h.secret)) != 1 {
+		h.log.WarnContext(r.Context(), "Withings notification with invalid secret", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		// Withings checks that the callback URL responds before accepting a subscription
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<16)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid notification", http.StatusBadRequest)
+		return
+	}
+	externalUserID := r.PostForm.Get("userid")
+	if externalUserID == "" {
+		http.Error(w, "userid is required", http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("appli") != strconv.Itoa(withingsNotifyAppliWeight) {
+		// Only weight notifications are subscribed; other categories are acknowledged so they are not retried
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Withings expects a quick response, so the account is synced after responding
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), withingsNotificationSyncTimeout)
+		defer cancel()
+		for _, region := range h.regions {
+			if err := h.syncer.SyncExternalUser(repo.WithRegion(ctx, region), ProviderWithings, externalUserID); err != nil {
+				h.log.WarnContext(ctx, "Withings notification sync failed", "externalUserID", externalUserID, "region", region, "error", err)
+			}
+		}
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+// Wait blocks until the syncs triggered by notifications have finished
+func (h *WithingsWebhook) Wait() {
+	h.wg.Wait()
+}
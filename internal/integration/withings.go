@@ -0,0 +1,257 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// ProviderWithings is the provider name of Withings integrations
+const ProviderWithings = "withings"
+
+const (
+	withingsTokenURL   = "https://wbsapi.withings.net/v2/oauth2"
+	withingsAPIBaseURL = "https://wbsapi.withings.net"
+	// withingsMeasureTypeWeight and withingsMeasureTypeFatRatio are the getmeas measure types (kg and %)
+	withingsMeasureTypeWeight   = 1
+	withingsMeasureTypeFatRatio = 6
+	// withingsAttribAmbiguous marks device measurements that may belong to another user of the scale
+	withingsAttribAmbiguous = 1
+	// withingsNotifyAppliWeight is the notification category of weight measurements
+	withingsNotifyAppliWeight = 1
+)
+
+// withingsInvalidGrantStatuses are the response statuses of rejected codes and refresh tokens
+var withingsInvalidGrantStatuses = map[int]bool{
+	401: true,
+	503: true,
+}
+
+// Withings fetches scale measurements from the Withings API. Accounts can be subscribed to
+// weight notifications so new weigh-ins are synced as soon as Withings reports them.
+type Withings struct {
+	ClientID     string
+	ClientSecret string
+	// CallbackURL is the public URL of the notification webhook, including its secret.
+	// Accounts are not subscribed to notifications when it is empty.
+	CallbackURL string
+	// TokenURL and APIBaseURL default to the Withings endpoints
+	TokenURL   string
+	APIBaseURL string
+	HTTPClient *http.Client
+}
+
+// NewWithings creates a Withings provider for an OAuth client
+func NewWithings(clientID, clientSecret, callbackURL string) *Withings {
+	return &Withings{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CallbackURL:  callbackURL,
+		TokenURL:     withingsTokenURL,
+		APIBaseURL:   withingsAPIBaseURL,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (w *Withings) Name() string {
+	return ProviderWithings
+}
+
+// ExchangeCode exchanges an OAuth authorization code for tokens and the Withings user ID
+func (w *Withings) ExchangeCode(ctx context.Context, code, redirectURI string) (Token, error) {
+	return w.token(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	})
+}
+
+// RefreshToken obtains a new access token from a refresh token.
+// Withings rotates refresh tokens, so the returned token includes a new refresh token.
+func (w *Withings) RefreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	return w.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// RevokeToken is a no-op: Withings has no token revocation endpoint, access is revoked
+// by the user in the Withings app. Notifications for unlinked accounts are ignored.
+func (w *Withings) RevokeToken(ctx context.Context, token string) error {
+	return nil
+}
+
+// Subscribe registers the account for weight notifications to CallbackURL.
+// Withings verifies the callback URL before accepting the subscription.
+func (w *Withings) Subscribe(ctx context.Context, accessToken string) error {
+	if w.CallbackURL == "" {
+		return nil
+	}
+	form := url.Values{
+		"action":      {"subscribe"},
+		"callbackurl": {w.CallbackURL},
+		"appli":       {strconv.Itoa(withingsNotifyAppliWeight)},
+	}
+	if err := w.call(ctx, w.APIBaseURL+"/notify", form, accessToken, nil); err != nil {
+		return fmt.Errorf("failed to subscribe to notifications: %w", err)
+	}
+	return nil
+}
+
+func (w *Withings) token(ctx context.Context, form url.Values) (Token, error) {
+	form.Set("action", "requesttoken")
+	form.Set("client_id", w.ClientID)
+	form.Set("client_secret", w.ClientSecret)
+
+	var body struct {
+		UserID       json.Number `json:"userid"`
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    int64       `json:"expires_in"`
+	}
+	if err := w.call(ctx, w.TokenURL, form, "", &body); err != nil {
+		var statusErr *withingsStatusError
+		if errors.As(err, &statusErr) && withingsInvalidGrantStatuses[statusErr.status] {
+			return Token{}, ErrInvalidGrant
+		}
+		return Token{}, fmt.Errorf("token request failed: %w", err)
+	}
+	if body.AccessToken == "" {
+		return Token{}, errors.New("token response has no access token")
+	}
+
+	return Token{
+		AccessToken:    body.AccessToken,
+		RefreshToken:   body.RefreshToken,
+		ExpiresIn:      time.Duration(body.ExpiresIn) * time.Second,
+		ExternalUserID: body.UserID.String(),
+	}, nil
+}
+
+// FetchData retrieves the weight and body fat measurements recorded in [start, end)
+func (w *Withings) FetchData(ctx context.Context, accessToken string, start, end time.Time) (repo.ImportBatch, error) {
+	form := url.Values{
+		"action":    {"getmeas"},
+		"meastypes": {fmt.Sprintf("%d,%d", withingsMeasureTypeWeight, withingsMeasureTypeFatRatio)},
+		"category":  {"1"}, // Real measurements, not user objectives
+		"startdate": {strconv.FormatInt(start.Unix(), 10)},
+		"enddate":   {strconv.FormatInt(end.Unix()-1, 10)}, // enddate is inclusive
+		"offset":    {"0"},
+	}
+
+	var batch repo.ImportBatch
+	for {
+		var body struct {
+			MeasureGroups []struct {
+				GroupID  int64 `json:"grpid"`
+				Attrib   int   `json:"attrib"`
+				Date     int64 `json:"date"`
+				Measures []struct {
+					Value int64 `json:"value"`
+					Type  int   `json:"type"`
+					Unit  int   `json:"unit"`
+				} `json:"measures"`
+			} `json:"measuregrps"`
+			More   int   `json:"more"`
+			Offset int64 `json:"offset"`
+		}
+		if err := w.call(ctx, w.APIBaseURL+"/measure", form, accessToken, &body); err != nil {
+			return repo.ImportBatch{}, fmt.Errorf("failed to fetch measurements: %w", err)
+		}
+
+		for _, group := range body.MeasureGroups {
+			if group.Attrib == withingsAttribAmbiguous {
+				// Not confirmed to be a weigh-in of the linked user
+				continue
+			}
+			m := repo.ImportedBodyMeasurement{
+				SourceSampleID: "measuregrp:" + strconv.FormatInt(group.GroupID, 10),
+				Date:           time.Unix(group.Date, 0).UTC().Truncate(24 * time.Hour),
+			}
+			for _, measure := range group.Measures {
+				value := float64(measure.Value) * math.Pow10(measure.Unit)
+				switch measure.Type {
+				case withingsMeasureTypeWeight:
+					if weight, ok := roundedWeightKg(value); ok {
+						m.WeightKg = &weight
+					}
+				case withingsMeasureTypeFatRatio:
+					if bodyFat := math.Round(value*100) / 100; bodyFat >= 0 && bodyFat <= 100 {
+						m.BodyFatPercentage = &bodyFat
+					}
+				}
+			}
+			if m.WeightKg == nil && m.BodyFatPercentage == nil {
+				continue
+			}
+			batch.BodyMeasurements = append(batch.BodyMeasurements, m)
+		}
+
+		if body.More == 0 {
+			return batch, nil
+		}
+		form.Set("offset", strconv.FormatInt(body.Offset, 10))
+	}
+}
+
+// withingsStatusError is a Withings response with a non-zero status
+type withingsStatusError struct {
+	status  int
+	message string
+}
+
+func (e *withingsStatusError) Error() string {
+	return fmt.Sprintf("withings returned status %d: %s", e.status, e.message)
+}
+
+// call sends a Withings API request and decodes the response body into out. Withings reports
+// errors in the status field of an HTTP 200 response. accessToken is empty for token requests.
+func (w *Withings) call(ctx context.Context, endpoint string, form url.Values, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to %s: %w", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed with status %d", req.URL.Path, resp.StatusCode)
+	}
+	var envelope struct {
+		Status int             `json:"status"`
+		Error  string          `json:"error"`
+		Body   json.RawMessage `json:"body"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL.Path, err)
+	}
+	if envelope.Status != 0 {
+		return &withingsStatusError{status: envelope.Status, message: envelope.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Body, out); err != nil {
+		return fmt.Errorf("failed to decode response body from %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Package interceptor turns the checks run before RPCs, such as authentication, into Connect
+// interceptors covering streaming RPCs as well as unary ones, so that streams can't bypass them.
+package interceptor
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// Check runs before an RPC of procedure with the header of its request, returning the context to
+// call the handler with, or the error failing the RPC
+type Check func(ctx context.Context, procedure string, header http.Header) (context.Context, error)
+
+// New creates a Connect interceptor running check before the unary and streaming RPCs it serves.
+// Calls made by clients are left as they are.
+func New(check Check) connect.Interceptor {
+	return checkInterceptor(check)
+}
+
+// checkInterceptor is a Connect interceptor running a Check
+type checkInterceptor Check
+
+// WrapUnary implements connect.Interceptor
+func (c checkInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		ctx, err := c(ctx, req.Spec().Procedure, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor
+func (c checkInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor
+func (c checkInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := c(ctx, conn.Spec().Procedure, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
@@ -1,10 +1,25 @@
 package log
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// Formats of log records
+const (
+	// FormatJSON writes records as JSON lines
+	FormatJSON = "json"
+	// FormatText writes records as key=value lines, easier to read in a terminal
+	FormatText = "text"
 )
 
+// OutputStdout is the output writing records to stdout; any other output is a file path
+const OutputStdout = "stdout"
+
 // NewLogger creates a new structured logger
 func NewLogger() *slog.Logger {
 	// Create a JSON handler with default options
@@ -15,3 +30,52 @@ func NewLogger() *slog.Logger {
 	
 	return logger
 }
+
+// New creates a structured logger applying the logging policy of opts. In production mode, user
+// identifiers are hashed and sensitive attributes are redacted before records are written.
+// Records logged with the context of a request carry its request ID, trace ID and user ID.
+func New(opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Mode == ModeProduction {
+		p := policy{hashKey: []byte(opts.HashKey)}
+		handlerOpts.ReplaceAttr = p.replaceAttr
+	}
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	var handler slog.Handler
+	if opts.Format == FormatText {
+		handler = slog.NewTextHandler(output, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(output, handlerOpts)
+	}
+	if opts.Sampling.Initial > 0 {
+		c := opts.Clock
+		if c == nil {
+			c = clock.NewRealClock()
+		}
+		handler = newSampler(handler, opts.Sampling, c)
+	}
+	return slog.New(newContextHandler(handler))
+}
+
+// Open opens the output of records: os.Stdout for OutputStdout, otherwise the file at output,
+// which records are appended to
+func Open(output string) (io.WriteCloser, error) {
+	if output == OutputStdout {
+		return nopCloser{os.Stdout}, nil
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return f, nil
+}
+
+// nopCloser keeps stdout open when the output is closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// Modes of the logging policy
+const (
+	// ModeDevelopment logs attributes as they are
+	ModeDevelopment = "development"
+	// ModeProduction hashes user identifiers and redacts sensitive attributes
+	ModeProduction = "production"
+)
+
+// redacted replaces the values of sensitive attributes in production mode
+const redacted = "[REDACTED]"
+
+// hashedKeys are the keys of attributes identifying users. In production mode their values are
+// replaced by a keyed hash, so the logs of a user can still be correlated.
+var hashedKeys = map[string]bool{
+	"userID":         true,
+	"callerID":       true,
+	"ownerID":        true,
+	"granteeID":      true,
+	"subjectID":      true,
+	"subject_id":     true,
+	"externalUserID": true,
+}
+
+// redactedKeys are the keys of attributes with personal data or secrets. In production mode
+// their values are replaced by redacted.
+var redactedKeys = map[string]bool{
+	"email":   true,
+	"to":      true, // Email recipients
+	"subject": true, // Email subjects
+	"text":    true, // Email bodies
+	"query":   true, // User searches by subject ID
+	"title":   true,
+	"content": true,
+	"token":   true,
+	"args":    true, // Query arguments
+}
+
+// Options configures the logging policy of a logger
+type Options struct {
+	// Mode is ModeDevelopment or ModeProduction
+	Mode string
+	// HashKey keys the hashes of user identifiers, so they can't be matched against known IDs
+	HashKey string
+	// Level is the minimum level of logged records, e.g. a *slog.LevelVar to change it at runtime;
+	// nil logs from info
+	Level slog.Leveler
+	// Format is FormatJSON or FormatText; empty writes JSON
+	Format string
+	// Output receives the records; nil writes them to stdout
+	Output io.Writer
+	// Sampling drops repetitive records; the zero value logs every record
+	Sampling Sampling
+	// Clock times the sampling intervals; nil uses the real clock
+	Clock clock.Clock
+}
+
+// policy rewrites the attributes of log records according to the mode
+type policy struct {
+	hashKey []byte
+}
+
+// replaceAttr hashes user identifiers and redacts sensitive attributes, in any group
+func (p policy) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	switch {
+	case hashedKeys[a.Key]:
+		mac := hmac.New(sha256.New, p.hashKey)
+		mac.Write([]byte(a.Value.Resolve().String()))
+		return slog.String(a.Key, hex.EncodeToString(mac.Sum(nil)[:8]))
+	case redactedKeys[a.Key]:
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// Scrub applies the production policy to a whatever the mode, for attributes leaving the server
+// other than in the logs, e.g. in error reports
+func Scrub(hashKey string, a slog.Attr) slog.Attr {
+	return policy{hashKey: []byte(hashKey)}.replaceAttr(nil, a)
+}
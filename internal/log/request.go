@@ -0,0 +1,150 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, kept from clients and proxies that
+// send one and set on every response
+const RequestIDHeader = "X-Request-Id"
+
+var (
+	// requestIDPattern matches the request IDs kept from requests; others are replaced
+	requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+	// traceparentPattern matches W3C traceparent headers, capturing their trace ID
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// Keys of the attributes attached to the records logged within a request
+const (
+	requestIDKey = "requestID"
+	traceIDKey   = "traceID"
+	userIDKey    = "userID"
+)
+
+// requestKey and userKey are the context keys of the IDs of a request and of its user
+type (
+	requestKey struct{}
+	userKey    struct{}
+)
+
+// request holds the IDs of a request
+type request struct {
+	id      string
+	traceID string // Empty unless the request is part of a trace
+}
+
+// WithRequest returns a copy of ctx with the IDs of a request, attached to the records logged
+// with it
+func WithRequest(ctx context.Context, requestID, traceID string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: requestID, traceID: traceID})
+}
+
+// WithUserID returns a copy of ctx with the ID of the authenticated user, attached to the records
+// logged with it
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// RequestID returns the ID of the request of ctx, or "" outside requests
+func RequestID(ctx context.Context) string {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		return r.id
+	}
+	return ""
+}
+
+// TraceID returns the trace ID of the request of ctx, or "" if it isn't part of a trace
+func TraceID(ctx context.Context) string {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		return r.traceID
+	}
+	return ""
+}
+
+// Middleware assigns an ID to every request: the X-Request-Id of the request if it has a valid
+// one, or a new UUID. The ID is returned in the X-Request-Id header of the response. Requests
+// with a W3C traceparent header also log the ID of their trace.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		var traceID string
+		if m := traceparentPattern.FindStringSubmatch(r.Header.Get("Traceparent")); m != nil && m[1] != "00000000000000000000000000000000" {
+			traceID = m[1]
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequest(r.Context(), requestID, traceID)))
+	})
+}
+
+// contextHandler is a slog.Handler attaching the request ID, trace ID and user ID of the context
+// of a record to it, unless the record already has an attribute of that key, e.g. the ID of
+// another user
+type contextHandler struct {
+	next slog.Handler
+	keys map[string]bool // Keys of the attributes added with WithAttrs
+}
+
+func newContextHandler(next slog.Handler) *contextHandler {
+	return &contextHandler{next: next}
+}
+
+// Enabled implements slog.Handler
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	var attrs []slog.Attr
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		attrs = append(attrs, slog.String(requestIDKey, r.id))
+		if r.traceID != "" {
+			attrs = append(attrs, slog.String(traceIDKey, r.traceID))
+		}
+	}
+	if userID, ok := ctx.Value(userKey{}).(string); ok {
+		attrs = append(attrs, slog.String(userIDKey, userID))
+	}
+	if len(attrs) == 0 {
+		return h.next.Handle(ctx, record)
+	}
+
+	present := make(map[string]bool, record.NumAttrs())
+	record.Attrs(func(a slog.Attr) bool {
+		present[a.Key] = true
+		return true
+	})
+	record = record.Clone()
+	for _, a := range attrs {
+		if !present[a.Key] && !h.keys[a.Key] {
+			record.AddAttrs(a)
+		}
+	}
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	keys := make(map[string]bool, len(h.keys)+len(attrs))
+	for k := range h.keys {
+		keys[k] = true
+	}
+	for _, a := range attrs {
+		keys[a.Key] = true
+	}
+	return &contextHandler{next: h.next.WithAttrs(attrs), keys: keys}
+}
+
+// WithGroup implements slog.Handler. The IDs are still attached, inside the group.
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// maxSampled bounds the messages counted in an interval; beyond it, new messages aren't sampled
+const maxSampled = 10000
+
+// Sampling drops repetitive records: of the records with the same level and message in an
+// interval, the first Initial are written, then every Thereafter-th. Records at the error level
+// are always written.
+type Sampling struct {
+	// Initial is the number of records written per level, message and interval; 0 disables sampling
+	Initial int
+	// Thereafter writes every Thereafter-th record past Initial; 0 drops them all
+	Thereafter int
+	// Interval is how long records are counted before the counts start over
+	Interval time.Duration
+}
+
+// sampler is a slog.Handler sampling the records passed to the next handler
+type sampler struct {
+	next   slog.Handler
+	counts *sampleCounts // Shared by the handlers derived with With
+}
+
+// sampleCounts counts the records of the current interval by level and message
+type sampleCounts struct {
+	sampling Sampling
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	started time.Time
+	counts  map[sampleKey]int
+}
+
+type sampleKey struct {
+	level   slog.Level
+	message string
+}
+
+func newSampler(next slog.Handler, sampling Sampling, clock clock.Clock) *sampler {
+	return &sampler{next: next, counts: &sampleCounts{sampling: sampling, clock: clock, counts: make(map[sampleKey]int)}}
+}
+
+// Enabled implements slog.Handler
+func (s *sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler
+func (s *sampler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < slog.LevelError && !s.counts.keep(sampleKey{level: record.Level, message: record.Message}) {
+		return nil
+	}
+	return s.next.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{next: s.next.WithAttrs(attrs), counts: s.counts}
+}
+
+// WithGroup implements slog.Handler
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{next: s.next.WithGroup(name), counts: s.counts}
+}
+
+// keep counts a record with key and reports whether it should be written
+func (c *sampleCounts) keep(key sampleKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := c.clock.Now(); now.Sub(c.started) >= c.sampling.Interval {
+		c.started = now
+		clear(c.counts)
+	}
+	n, ok := c.counts[key]
+	if !ok && len(c.counts) >= maxSampled {
+		return true
+	}
+	n++
+	c.counts[key] = n
+	if n <= c.sampling.Initial {
+		return true
+	}
+	return c.sampling.Thereafter > 0 && (n-c.sampling.Initial)%c.sampling.Thereafter == 0
+}
@@ -0,0 +1,108 @@
+// Package maintenance implements the maintenance mode of the server, in which requests fail with
+// unavailable and a message for users instead of reaching the database, e.g. while migrations run.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+)
+
+// EndsAtMetadataKey is the error metadata key carrying the expected end of the maintenance
+const EndsAtMetadataKey = "Maintenance-Ends-At"
+
+// Mode answers requests while the server is in maintenance
+type Mode struct {
+	message string
+	endsAt  time.Time // Zero if unknown
+	clock   clock.Clock
+}
+
+// New creates a maintenance mode returning message to clients and asking them to retry at
+// endsAt, unless it is zero
+func New(message string, endsAt time.Time, clock clock.Clock) *Mode {
+	return &Mode{
+		message: message,
+		endsAt:  endsAt,
+		clock:   clock,
+	}
+}
+
+// Interceptor creates a Connect interceptor failing every RPC in maintenance mode m with
+// unavailable, reason maintenance and the message; m is nil outside maintenance, when RPCs pass.
+// With an end time, the error carries it in EndsAtMetadataKey and the seconds until then in
+// Retry-After. It should run before the auth interceptor, which queries the database.
+func Interceptor(m *Mode) connect.Interceptor {
+	return interceptor.New(func(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+		if m == nil {
+			return ctx, nil
+		}
+		connectErr := apierror.New(connect.CodeUnavailable, apierror.ReasonMaintenance, errors.New(m.message))
+		if retryAfter, ok := m.retryAfter(); ok {
+			connectErr.Meta().Set(EndsAtMetadataKey, m.endsAt.UTC().Format(time.RFC3339))
+			connectErr.Meta().Set("Retry-After", retryAfter)
+		}
+		return nil, connectErr
+	})
+}
+
+// UnavailableHandler answers plain HTTP requests, e.g. of webhooks, with 503 and the message as
+// JSON, so their senders retry them later
+func (m *Mode) UnavailableHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter, ok := m.retryAfter(); ok {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		m.writeStatus(w, http.StatusServiceUnavailable)
+	})
+}
+
+// HealthHandler reports the status of the server, "ok" or, in maintenance mode m, "maintenance"
+// with the message; m is nil outside maintenance. The status code is 200 either way, so load
+// balancers keep routing clients to the server and they get the message instead of
+// connection errors.
+func HealthHandler(m *Mode) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m != nil {
+			m.writeStatus(w, http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}` + "\n")) //nolint:errcheck // The client may be gone
+	})
+}
+
+// writeStatus writes the maintenance status as the JSON response body
+func (m *Mode) writeStatus(w http.ResponseWriter, code int) {
+	status := struct {
+		Status  string     `json:"status"`
+		Message string     `json:"message"`
+		EndsAt  *time.Time `json:"ends_at,omitempty"`
+	}{Status: "maintenance", Message: m.message}
+	if !m.endsAt.IsZero() {
+		endsAt := m.endsAt.UTC()
+		status.EndsAt = &endsAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status) //nolint:errcheck // The client may be gone
+}
+
+// retryAfter returns the seconds until the expected end of the maintenance, at least 1, and
+// whether an end is set
+func (m *Mode) retryAfter() (string, bool) {
+	if m.endsAt.IsZero() {
+		return "", false
+	}
+	seconds := math.Ceil(m.endsAt.Sub(m.clock.Now()).Seconds())
+	return strconv.Itoa(int(max(seconds, 1))), true
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// DBFailovers counts the failovers of the primary database detected by the server, each of which
+// resets its connection pool
+var DBFailovers = NewCounter(
+	"healthapp_db_failovers",
+	"Failovers of the primary database detected, each resetting the connection pool.",
+)
+
+// Counter is a Prometheus counter without labels
+type Counter struct {
+	name  string // Without the _total suffix of its samples
+	help  string
+	value atomic.Uint64
+}
+
+// NewCounter creates a counter and registers it for PrometheusHandler
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	Register(c)
+	return c
+}
+
+// Inc increments the counter
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Value returns the count
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+// write writes the counter in the Prometheus text format or OpenMetrics
+func (c *Counter) write(w io.Writer, openMetrics bool) {
+	writeCounterHeader(w, c.name, c.help, openMetrics)
+	fmt.Fprintf(w, "%s_total %d\n", c.name, c.Value())
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QueryDuration observes the duration of database queries in seconds, by sqlc query name
+var QueryDuration = NewHistogramVec(
+	"healthapp_db_query_duration_seconds",
+	"Duration of database queries by query name.",
+	"query",
+	[]float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+)
+
+// RPCDuration observes the duration of RPCs in seconds, by procedure. Observations of traced
+// requests are exemplified with their trace ID, others with their request ID.
+var RPCDuration = NewHistogramVec(
+	"healthapp_rpc_duration_seconds",
+	"Duration of RPCs by procedure.",
+	"procedure",
+	[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.3, 0.5, 1, 2.5, 5, 10},
+)
+
+// Content types of the exposition formats; exemplars are only served in OpenMetrics
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Collector is a set of metrics served by PrometheusHandler
+type Collector interface {
+	// write writes the metrics in the Prometheus text format, or in OpenMetrics with openMetrics
+	write(w io.Writer, openMetrics bool)
+}
+
+var (
+	// collectors are the collectors served by PrometheusHandler, in registration order
+	collectors   []Collector
+	collectorsMu sync.Mutex
+)
+
+// Exemplar labels an observation with the request it was made for, linking metrics to traces
+// and logs
+type Exemplar struct {
+	Label string // e.g. "trace_id"
+	Value string
+}
+
+// HistogramVec is a Prometheus histogram partitioned by the values of one label
+type HistogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64 // Upper bounds, ascending
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+// histogram is the series of one label value
+type histogram struct {
+	counts    []uint64    // Per bucket, not cumulative; the last counts observations above all bounds
+	exemplars []*exemplar // Latest exemplified observation per bucket
+	sum       float64
+	count     uint64
+}
+
+// exemplar is an exemplified observation
+type exemplar struct {
+	Exemplar
+	value float64
+}
+
+// NewHistogramVec creates a histogram with the bucket upper bounds and registers it for
+// PrometheusHandler
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	Register(h)
+	return h
+}
+
+// Register registers a collector for PrometheusHandler
+func Register(c Collector) {
+	collectorsMu.Lock()
+	defer collectorsMu.Unlock()
+	collectors = append(collectors, c)
+}
+
+// Observe records an observation of the series of a label value
+func (h *HistogramVec) Observe(labelValue string, v float64) {
+	h.ObserveWithExemplar(labelValue, v, Exemplar{})
+}
+
+// ObserveWithExemplar records an observation of the series of a label value, keeping it as the
+// exemplar of its bucket unless e is empty
+func (h *HistogramVec) ObserveWithExemplar(labelValue string, v float64, e Exemplar) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets)+1), exemplars: make([]*exemplar, len(h.buckets)+1)}
+		h.series[labelValue] = s
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	if e.Value != "" {
+		s.exemplars[i] = &exemplar{Exemplar: e, value: v}
+	}
+}
+
+// write writes the histogram in the Prometheus text format, or in OpenMetrics with the exemplars
+// of its buckets
+func (h *HistogramVec) write(w io.Writer, openMetrics bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	labelValues := make([]string, 0, len(h.series))
+	for v := range h.series {
+		labelValues = append(labelValues, v)
+	}
+	sort.Strings(labelValues)
+	for _, v := range labelValues {
+		s := h.series[v]
+		label := fmt.Sprintf("%s=\"%s\"", h.label, labelValueEscaper.Replace(v))
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d%s\n", h.name, label, strconv.FormatFloat(bound, 'g', -1, 64), cumulative, s.exemplar(i, openMetrics))
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d%s\n", h.name, label, s.count, s.exemplar(len(h.buckets), openMetrics))
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", h.name, label, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", h.name, label, s.count)
+	}
+}
+
+// exemplar returns the exemplar of bucket i to append to its sample, or "" if it has none or
+// the format has no exemplars
+func (s *histogram) exemplar(i int, openMetrics bool) string {
+	e := s.exemplars[i]
+	if !openMetrics || e == nil {
+		return ""
+	}
+	return fmt.Sprintf(" # {%s=\"%s\"} %s", e.Label, labelValueEscaper.Replace(e.Value), strconv.FormatFloat(e.value, 'g', -1, 64))
+}
+
+// labelValueEscaper escapes label values for the Prometheus text format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// PrometheusHandler serves the registered collectors in the Prometheus text exposition format,
+// or in OpenMetrics with exemplars to scrapers accepting it
+func PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		if openMetrics {
+			w.Header().Set("Content-Type", openMetricsContentType)
+		} else {
+			w.Header().Set("Content-Type", textContentType)
+		}
+		collectorsMu.Lock()
+		defer collectorsMu.Unlock()
+		for _, c := range collectors {
+			c.write(w, openMetrics)
+		}
+		if openMetrics {
+			fmt.Fprint(w, "# EOF\n")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"sync"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+)
+
+var (
+	// errorsByReason counts failed RPCs per error reason
+	errorsByReason = expvar.NewMap("rpc_errors_by_reason")
+	// errorsByProcedure counts failed RPCs per procedure, then per error reason
+	errorsByProcedure = expvar.NewMap("rpc_errors_by_procedure")
+	// procedureMu serializes creation of the per-procedure maps
+	procedureMu sync.Mutex
+)
+
+// ErrorInterceptor creates a Connect interceptor counting failed RPCs by error reason.
+// It should come right after the SLO interceptor so that authentication failures are counted too.
+func ErrorInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			resp, err := next(ctx, req)
+			if err != nil {
+				recordError(req.Spec().Procedure, apierror.Reason(err))
+			}
+			return resp, err
+		}
+	}
+}
+
+// recordError increments the counters of a failed RPC
+func recordError(procedure, reason string) {
+	errorsByReason.Add(reason, 1)
+	procedureErrors(procedure).Add(reason, 1)
+}
+
+// procedureErrors returns the reason counters of a procedure, creating them on first use
+func procedureErrors(procedure string) *expvar.Map {
+	if m, ok := errorsByProcedure.Get(procedure).(*expvar.Map); ok {
+		return m
+	}
+
+	procedureMu.Lock()
+	defer procedureMu.Unlock()
+	if m, ok := errorsByProcedure.Get(procedure).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map)
+	errorsByProcedure.Set(procedure, m)
+	return m
+}
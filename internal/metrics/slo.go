@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/log"
+)
+
+// sloSlot is the length of the slots the SLO window is counted in
+const sloSlot = time.Minute
+
+// SLO measures the compliance of RPCs with a latency objective, e.g. 99% of them within 300ms.
+// Besides the counts of RPCs and of those within the threshold, it serves their ratio over the
+// last window by procedure and overall, so alerts compare it to the objective without queries
+// over the counters.
+type SLO struct {
+	threshold time.Duration
+	objective float64
+	slots     int64 // Slots of the window
+	clock     clock.Clock
+
+	mu     sync.Mutex
+	series map[string]*sloSeries // By procedure
+}
+
+// sloSeries counts the RPCs of a procedure
+type sloSeries struct {
+	total, fast uint64
+	window      []sloCounts // Ring of the counts of the slots of the window
+}
+
+// sloCounts counts the RPCs of a slot
+type sloCounts struct {
+	slot        int64 // Index of the slot since the Unix epoch
+	total, fast uint64
+}
+
+// NewSLO creates an SLO of RPCs completing within threshold, measured over window, rounded up
+// to whole minutes
+func NewSLO(threshold time.Duration, objective float64, window time.Duration, clock clock.Clock) *SLO {
+	return &SLO{
+		threshold: threshold,
+		objective: objective,
+		slots:     int64((window + sloSlot - 1) / sloSlot),
+		clock:     clock,
+		series:    make(map[string]*sloSeries),
+	}
+}
+
+// Interceptor creates a Connect interceptor observing the duration of every RPC, failed ones
+// included, in RPCDuration and the SLO. It should be the outermost interceptor so that the time
+// spent authenticating and authorizing RPCs counts too.
+func (s *SLO) Interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			start := s.clock.Now()
+			resp, err := next(ctx, req)
+			duration := s.clock.Now().Sub(start)
+
+			e := Exemplar{Label: "trace_id", Value: log.TraceID(ctx)}
+			if e.Value == "" {
+				e = Exemplar{Label: "request_id", Value: log.RequestID(ctx)}
+			}
+			RPCDuration.ObserveWithExemplar(req.Spec().Procedure, duration.Seconds(), e)
+			s.observe(req.Spec().Procedure, duration)
+			return resp, err
+		}
+	}
+}
+
+// observe counts an RPC of a procedure that took duration
+func (s *SLO) observe(procedure string, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series, ok := s.series[procedure]
+	if !ok {
+		series = &sloSeries{window: make([]sloCounts, s.slots)}
+		s.series[procedure] = series
+	}
+	slot := s.clock.Now().Unix() / int64(sloSlot/time.Second)
+	counts := &series.window[slot%s.slots]
+	if counts.slot != slot {
+		*counts = sloCounts{slot: slot}
+	}
+	fast := duration <= s.threshold
+	series.total++
+	counts.total++
+	if fast {
+		series.fast++
+		counts.fast++
+	}
+}
+
+// windowCounts returns the counts of the RPCs of series within the window ending in slot
+func (s *SLO) windowCounts(series *sloSeries, slot int64) (total, fast uint64) {
+	for _, counts := range series.window {
+		if counts.slot > slot-s.slots {
+			total += counts.total
+			fast += counts.fast
+		}
+	}
+	return total, fast
+}
+
+// write writes the counters, the compliance of the window and the objective
+func (s *SLO) write(w io.Writer, openMetrics bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	procedures := make([]string, 0, len(s.series))
+	for p := range s.series {
+		procedures = append(procedures, p)
+	}
+	sort.Strings(procedures)
+	label := func(procedure string) string {
+		return fmt.Sprintf("procedure=\"%s\"", labelValueEscaper.Replace(procedure))
+	}
+
+	writeCounterHeader(w, "healthapp_rpc_slo_requests", "RPCs counted against the latency SLO by procedure.", openMetrics)
+	for _, p := range procedures {
+		fmt.Fprintf(w, "healthapp_rpc_slo_requests_total{%s} %d\n", label(p), s.series[p].total)
+	}
+	writeCounterHeader(w, "healthapp_rpc_slo_fast_requests", "RPCs completed within the latency SLO threshold by procedure.", openMetrics)
+	for _, p := range procedures {
+		fmt.Fprintf(w, "healthapp_rpc_slo_fast_requests_total{%s} %d\n", label(p), s.series[p].fast)
+	}
+
+	// Procedures without RPCs in the window have no compliance
+	fmt.Fprint(w, "# HELP healthapp_rpc_slo_compliance_ratio Share of the RPCs of the SLO window completed within the threshold, by procedure and overall.\n# TYPE healthapp_rpc_slo_compliance_ratio gauge\n")
+	slot := s.clock.Now().Unix() / int64(sloSlot/time.Second)
+	var allTotal, allFast uint64
+	for _, p := range procedures {
+		total, fast := s.windowCounts(s.series[p], slot)
+		if total == 0 {
+			continue
+		}
+		allTotal += total
+		allFast += fast
+		fmt.Fprintf(w, "healthapp_rpc_slo_compliance_ratio{%s} %s\n", label(p), formatRatio(fast, total))
+	}
+	if allTotal > 0 {
+		fmt.Fprintf(w, "healthapp_rpc_slo_compliance_ratio %s\n", formatRatio(allFast, allTotal))
+	}
+
+	fmt.Fprintf(w, "# HELP healthapp_rpc_slo_objective_ratio Share of RPCs the latency SLO requires within the threshold.\n# TYPE healthapp_rpc_slo_objective_ratio gauge\nhealthapp_rpc_slo_objective_ratio %s\n", strconv.FormatFloat(s.objective, 'g', -1, 64))
+	fmt.Fprintf(w, "# HELP healthapp_rpc_slo_latency_threshold_seconds Latency threshold of the SLO.\n# TYPE healthapp_rpc_slo_latency_threshold_seconds gauge\nhealthapp_rpc_slo_latency_threshold_seconds %s\n", strconv.FormatFloat(s.threshold.Seconds(), 'g', -1, 64))
+}
+
+// writeCounterHeader writes the help and type of a counter family. Its samples are named with the
+// _total suffix, which OpenMetrics leaves out of the family name.
+func writeCounterHeader(w io.Writer, name, help string, openMetrics bool) {
+	if !openMetrics {
+		name += "_total"
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
+
+func formatRatio(n, total uint64) string {
+	return strconv.FormatFloat(float64(n)/float64(total), 'g', -1, 64)
+}
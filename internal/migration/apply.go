@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey is the key of the advisory lock held while migrating, so servers started together
+// don't apply the same migrations
+const lockKey = 4_127_193_695
+
+// Up applies the migrations after the schema version of the database, in order, each in a
+// transaction recording its version the way golang-migrate does, so the database can be migrated
+// further with either. It returns the migrations applied. Migrations of databases whose last
+// migration failed halfway aren't applied.
+func Up(ctx context.Context, pool *pgxpool.Pool, migrations []Migration) ([]Migration, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+	// Migrations, and waiting for the lock or their tables, may take longer than the statement and
+	// lock timeouts of the pool; they are restored before the connection is released
+	if _, err := conn.Exec(ctx, "SET statement_timeout = 0"); err != nil {
+		return nil, fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "RESET statement_timeout")
+	if _, err := conn.Exec(ctx, "SET lock_timeout = 0"); err != nil {
+		return nil, fmt.Errorf("failed to lift lock timeout: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "RESET lock_timeout")
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS "+versionTable+" (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)"); err != nil {
+		return nil, fmt.Errorf("failed to create version table: %w", err)
+	}
+	version, dirty, err := Version(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("migration %d failed halfway; fix the schema and force its version", version)
+	}
+
+	pending := Pending(migrations, version)
+	for i, m := range pending {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return pending[:i], fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			tx.Rollback(ctx)
+			return pending[:i], fmt.Errorf("failed to apply %s: %w", m.Name, err)
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM "+versionTable); err != nil {
+			tx.Rollback(ctx)
+			return pending[:i], fmt.Errorf("failed to record version: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO "+versionTable+" (version, dirty) VALUES ($1, false)", int64(m.Version)); err != nil {
+			tx.Rollback(ctx)
+			return pending[:i], fmt.Errorf("failed to record version: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return pending[:i], fmt.Errorf("failed to commit %s: %w", m.Name, err)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,331 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule is a kind of schema change breaking the servers of the previous release, which still run
+// against the new schema until the rollout completes
+type Rule string
+
+const (
+	// RuleDropTable drops a table or view
+	RuleDropTable Rule = "drop_table"
+	// RuleDropColumn drops a column
+	RuleDropColumn Rule = "drop_column"
+	// RuleRename renames a table or column
+	RuleRename Rule = "rename"
+	// RuleChangeType changes the type of a column
+	RuleChangeType Rule = "change_type"
+	// RuleSetNotNull makes a column required
+	RuleSetNotNull Rule = "set_not_null"
+	// RuleRequiredColumn adds a required column without a default
+	RuleRequiredColumn Rule = "add_required_column"
+	// RuleTruncate deletes every row of a table
+	RuleTruncate Rule = "truncate"
+)
+
+// ruleDescriptions tell how the changes of each rule break the previous release, and how to make
+// them safely instead
+var ruleDescriptions = map[Rule]string{
+	RuleDropTable:      "the previous release may still use the table; drop it in a later release",
+	RuleDropColumn:     "the previous release may still read or write the column; drop it in a later release",
+	RuleRename:         "the previous release uses the old name; add the new table or column, backfill it and drop the old one in a later release",
+	RuleChangeType:     "the previous release reads and writes values of the old type; add a column of the new type and backfill it",
+	RuleSetNotNull:     "the previous release may insert rows without the column; make it required in a later release",
+	RuleRequiredColumn: "inserts of the previous release fail without the column; give it a default or add it as nullable",
+	RuleTruncate:       "every row of the table is deleted while servers use it",
+}
+
+// Description tells how the changes of r break the previous release
+func (r Rule) Description() string {
+	return ruleDescriptions[r]
+}
+
+// Finding is a statement of a migration making a change of a rule
+type Finding struct {
+	Migration string // Name of the file
+	Line      int    // Of the start of the statement
+	Rule      Rule
+	// Reason is that of the migrate:allow comment of the migration allowing the change, or empty
+	// if it isn't allowed
+	Reason string
+}
+
+// Allowed reports whether the migration flags the change of f as intended
+func (f Finding) Allowed() bool {
+	return f.Reason != ""
+}
+
+func (f Finding) String() string {
+	if f.Allowed() {
+		return fmt.Sprintf("%s:%d: %s (allowed: %s)", f.Migration, f.Line, f.Rule, f.Reason)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", f.Migration, f.Line, f.Rule, f.Rule.Description())
+}
+
+var (
+	// allowPattern matches the comments allowing the changes of rules in a migration, e.g.
+	// "-- migrate:allow drop_column,rename v1.8 stopped using avatar_url", capturing the rules
+	// and the reason
+	allowPattern = regexp.MustCompile(`^\s*migrate:allow\s+([a-z_,]+)\s*(.*?)\s*$`)
+	// dollarQuotePattern matches the opening of dollar-quoted strings, e.g. function bodies
+	dollarQuotePattern = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+	// alterTablePattern matches the start of ALTER TABLE statements, up to their actions
+	alterTablePattern = regexp.MustCompile(`^ALTER TABLE (IF EXISTS )?(ONLY )?\S+ `)
+
+	renamePattern     = regexp.MustCompile(`^RENAME (\S+)`)
+	dropPattern       = regexp.MustCompile(`^DROP (\S+)`)
+	changeTypePattern = regexp.MustCompile(`^ALTER (COLUMN )?\S+ (SET DATA )?TYPE `)
+	setNotNullPattern = regexp.MustCompile(`^ALTER (COLUMN )?\S+ SET NOT NULL\b`)
+	addColumnPattern  = regexp.MustCompile(`^ADD (COLUMN )?(IF NOT EXISTS )?(\S+)`)
+)
+
+// Lint returns the changes of m breaking the servers of the previous release, allowed or not.
+// Changes are allowed by a comment of the migration, anywhere in its file, naming their rules and
+// the reason they are safe, e.g.
+//
+//	-- migrate:allow drop_column v1.8 stopped reading users.avatar_url
+//
+// or, for migrations already applied, by m.Allowed. Only the statements run by the migration
+// itself are checked, not the bodies of the functions it creates.
+func Lint(m Migration) ([]Finding, error) {
+	statements, comments := split(m.SQL)
+
+	allowed := make(map[Rule]string)
+	for rule, reason := range m.Allowed {
+		allowed[rule] = reason
+	}
+	for _, c := range comments {
+		match := allowPattern.FindStringSubmatch(c.text)
+		if match == nil {
+			continue
+		}
+		if err := parseAllow(match[1], match[2], allowed); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", m.Name, c.line, err)
+		}
+	}
+
+	var findings []Finding
+	for _, s := range statements {
+		for _, rule := range check(s.text) {
+			findings = append(findings, Finding{Migration: m.Name, Line: s.line, Rule: rule, Reason: allowed[rule]})
+		}
+	}
+	return findings, nil
+}
+
+// parseAllow adds the reason to allowed for each of the comma-separated rules
+func parseAllow(rules, reason string, allowed map[Rule]string) error {
+	if reason == "" {
+		return errors.New("migrate:allow needs the reason the change is safe")
+	}
+	for _, name := range strings.Split(rules, ",") {
+		rule := Rule(name)
+		if _, ok := ruleDescriptions[rule]; !ok {
+			return fmt.Errorf("unknown rule %q", name)
+		}
+		allowed[rule] = reason
+	}
+	return nil
+}
+
+// allow sets the changes of migrations allowed by the content of an AllowFile. Each of its lines
+// names a migration, the comma-separated rules and the reason, as a migrate:allow comment does:
+//
+//	000002_rename_auth0_sub_to_subject_id.up.sql rename applied before the first release
+//
+// Blank lines and lines starting with # are skipped.
+func allow(migrations []Migration, content string) error {
+	byName := make(map[string]*Migration, len(migrations))
+	for i := range migrations {
+		byName[migrations[i].Name] = &migrations[i]
+	}
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest, _ := strings.Cut(line, " ")
+		m, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown migration %q", AllowFile, i+1, name)
+		}
+		match := allowPattern.FindStringSubmatch("migrate:allow " + rest)
+		if match == nil {
+			return fmt.Errorf("%s:%d: expected the rules and reason of the changes allowed in %s", AllowFile, i+1, name)
+		}
+		if m.Allowed == nil {
+			m.Allowed = make(map[Rule]string)
+		}
+		if err := parseAllow(match[1], match[2], m.Allowed); err != nil {
+			return fmt.Errorf("%s:%d: %w", AllowFile, i+1, err)
+		}
+	}
+	return nil
+}
+
+// check returns the rules of the changes made by an upper-cased statement
+func check(text string) []Rule {
+	switch {
+	case strings.HasPrefix(text, "DROP TABLE "), strings.HasPrefix(text, "DROP VIEW "), strings.HasPrefix(text, "DROP MATERIALIZED VIEW "):
+		return []Rule{RuleDropTable}
+	case strings.HasPrefix(text, "TRUNCATE "):
+		return []Rule{RuleTruncate}
+	}
+	prefix := alterTablePattern.FindString(text)
+	if prefix == "" {
+		return nil
+	}
+
+	var rules []Rule
+	for _, action := range splitActions(text[len(prefix):]) {
+		if m := renamePattern.FindStringSubmatch(action); m != nil && m[1] != "CONSTRAINT" {
+			rules = append(rules, RuleRename)
+		}
+		if m := dropPattern.FindStringSubmatch(action); m != nil {
+			switch m[1] {
+			case "CONSTRAINT", "DEFAULT", "NOT", "EXPRESSION", "IDENTITY":
+			default: // DROP COLUMN, or DROP and the name of the column
+				rules = append(rules, RuleDropColumn)
+			}
+		}
+		if changeTypePattern.MatchString(action) {
+			rules = append(rules, RuleChangeType)
+		}
+		if setNotNullPattern.MatchString(action) {
+			rules = append(rules, RuleSetNotNull)
+		}
+		if m := addColumnPattern.FindStringSubmatch(action); m != nil {
+			switch m[3] {
+			case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "EXCLUDE":
+			default:
+				if strings.Contains(action, " NOT NULL") && !strings.Contains(action, " DEFAULT ") && !strings.Contains(action, " GENERATED ") {
+					rules = append(rules, RuleRequiredColumn)
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// splitActions splits the actions of an ALTER TABLE statement on the commas outside parentheses
+func splitActions(text string) []string {
+	var actions []string
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				actions = append(actions, strings.TrimSpace(text[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(actions, strings.TrimSpace(text[start:]))
+}
+
+// statement is a statement of a migration, upper-cased, with its whitespace collapsed and without
+// its comments or the contents of its strings
+type statement struct {
+	line int // Of its first token
+	text string
+}
+
+// comment is a line comment of a migration, without its leading dashes
+type comment struct {
+	line int
+	text string
+}
+
+// split splits the SQL of a migration into its statements and its line comments
+func split(sql string) ([]statement, []comment) {
+	var (
+		statements []statement
+		comments   []comment
+		b          strings.Builder
+		line       = 1
+		start      int // Line of the start of the current statement, 0 before its first token
+	)
+	flush := func() {
+		if text := strings.Join(strings.Fields(strings.ToUpper(b.String())), " "); text != "" {
+			statements = append(statements, statement{line: start, text: text})
+		}
+		b.Reset()
+		start = 0
+	}
+	// skip moves past the token of sql[i:end], counting its lines
+	skip := func(i, end int) int {
+		if end > len(sql) {
+			end = len(sql)
+		}
+		line += strings.Count(sql[i:end], "\n")
+		return end
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		if start == 0 && !strings.ContainsRune(" \t\r\n;", rune(c)) && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+			start = line
+		}
+		switch {
+		case c == '\n':
+			line++
+			b.WriteByte(' ')
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			comments = append(comments, comment{line: line, text: sql[i+2 : i+end]})
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			b.WriteByte(' ')
+			if end < 0 {
+				i = skip(i, len(sql))
+			} else {
+				i = skip(i, i+2+end+2)
+			}
+		case c == '\'' || c == '"':
+			// Doubled quotes escaping quotes read as two strings, which doesn't matter here
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end = i + 1 + end + 1
+			}
+			if c == '"' {
+				b.WriteString(sql[i:end]) // Quoted identifiers are kept
+			} else {
+				b.WriteString("''")
+			}
+			i = skip(i, end)
+		case c == '$' && dollarQuotePattern.MatchString(sql[i:]):
+			tag := dollarQuotePattern.FindString(sql[i:])
+			end := strings.Index(sql[i+len(tag):], tag)
+			b.WriteString("$$")
+			if end < 0 {
+				i = skip(i, len(sql))
+			} else {
+				i = skip(i, i+len(tag)+end+len(tag))
+			}
+		case c == ';':
+			flush()
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return statements, comments
+}
@@ -0,0 +1,109 @@
+// Package migration checks that the schema migrations of db/migrations are safe to apply during
+// a blue/green rollout, while servers of the previous release still run against the schema.
+// Migrations follow the expand–contract pattern: a release only adds to the schema (expand), and
+// the objects the previous release used are dropped or changed in a later release, once no server
+// uses them anymore (contract). Lint reports the statements that would break the previous release;
+// migrations making such changes on purpose flag them with a migrate:allow comment.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// filePattern matches the names of up migrations, e.g. 000049_add_user_timezone.up.sql
+var filePattern = regexp.MustCompile(`^(\d+)_(\w+)\.up\.sql$`)
+
+// AllowFile is the file of a migrations directory allowing the changes of migrations that were
+// already applied, and can't be flagged with a comment anymore since their files must not change
+const AllowFile = "migrate-allow.txt"
+
+// Migration is the up migration of a schema version
+type Migration struct {
+	Version uint64
+	Name    string // Of the file, e.g. 000049_add_user_timezone.up.sql
+	SQL     string
+	// Allowed are the reasons the changes of each rule are safe, as given in the AllowFile
+	Allowed map[Rule]string
+}
+
+// Load reads the up migrations of dir, in order of version, along with the changes allowed by the
+// AllowFile of dir, if any. Down migrations only revert a release that was rolled back, and aren't
+// checked.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	var migrations []Migration
+	for _, entry := range entries {
+		m := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of %s: %w", entry.Name(), err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: entry.Name(), SQL: string(content)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate version %d: %s and %s", migrations[i].Version, migrations[i-1].Name, migrations[i].Name)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, AllowFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read %s: %w", AllowFile, err)
+	default:
+		if err := allow(migrations, string(content)); err != nil {
+			return nil, err
+		}
+	}
+	return migrations, nil
+}
+
+// Pending returns the migrations of migrations after version
+func Pending(migrations []Migration, version uint64) []Migration {
+	i := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version > version })
+	return migrations[i:]
+}
+
+// querier runs queries on a pool, connection or transaction
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Version returns the schema version of the database, as recorded by golang-migrate, and whether
+// its last migration failed halfway. Databases no migration was applied to are at version 0.
+func Version(ctx context.Context, q querier) (version uint64, dirty bool, err error) {
+	var v int64
+	err = q.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&v, &dirty)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "42P01": // undefined_table
+		return 0, false, nil
+	case errors.Is(err, pgx.ErrNoRows):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return uint64(v), dirty, nil
+}
@@ -0,0 +1,230 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// versionTable is the table golang-migrate records the schema version in, which no migration
+// creates
+const versionTable = "schema_migrations"
+
+// Schema is the tables of a database schema, with their columns and indexes. Partitions are left
+// out: they are created over time, and have the columns and indexes of their parent.
+type Schema struct {
+	Tables map[string]*Table
+}
+
+// Table is a table of a schema
+type Table struct {
+	Columns map[string]Column
+	Indexes map[string]string // Definitions, by name
+}
+
+// Column is a column of a table
+type Column struct {
+	Type    string
+	NotNull bool
+}
+
+func (c Column) String() string {
+	if c.NotNull {
+		return c.Type + " NOT NULL"
+	}
+	return c.Type
+}
+
+// DifferenceKind tells how the live schema differs from the schema of the migrations
+type DifferenceKind string
+
+const (
+	// Missing objects are created by the migrations but absent from the live schema
+	Missing DifferenceKind = "missing"
+	// Changed objects have another definition in the live schema
+	Changed DifferenceKind = "changed"
+	// Unexpected objects are in the live schema but created by none of the migrations, e.g. by
+	// the migrations of a newer release
+	Unexpected DifferenceKind = "unexpected"
+)
+
+// Difference is an object of the live schema differing from the schema of the migrations
+type Difference struct {
+	Kind   DifferenceKind
+	Object string // e.g. "table users", "column users.subject_id" or "index idx_users_subject_id"
+	Detail string // Expected and live definitions of changed objects
+}
+
+func (d Difference) String() string {
+	if d.Detail != "" {
+		return fmt.Sprintf("%s %s: %s", d.Kind, d.Object, d.Detail)
+	}
+	return fmt.Sprintf("%s %s", d.Kind, d.Object)
+}
+
+// ExpectedSchema returns the schema created by migrations: they are applied to an empty schema
+// of the database of pool, in a transaction rolled back afterwards, so the database is left
+// unchanged. Needs the privilege to create schemas.
+func ExpectedSchema(ctx context.Context, pool *pgxpool.Pool, migrations []Migration) (*Schema, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	name := "healthapp_verify_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	// Unqualified objects are created in the new schema; extensions are found in public
+	if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE SCHEMA %s; SET LOCAL search_path = %s, public", name, name)); err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	for _, m := range migrations {
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			return nil, fmt.Errorf("failed to apply %s: %w", m.Name, err)
+		}
+	}
+	return readSchema(ctx, tx, name)
+}
+
+// LiveSchema returns the schema of the database of pool the server uses, without the version
+// table of golang-migrate
+func LiveSchema(ctx context.Context, pool *pgxpool.Pool) (*Schema, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var name string
+	if err := tx.QueryRow(ctx, "SELECT current_schema()").Scan(&name); err != nil {
+		return nil, fmt.Errorf("failed to read current schema: %w", err)
+	}
+	schema, err := readSchema(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	delete(schema.Tables, versionTable)
+	return schema, nil
+}
+
+// readSchema reads the tables of the schema name from the catalog. Qualified names in the
+// definitions of indexes and types are stripped of the schema, so schemas of different names
+// compare equal.
+func readSchema(ctx context.Context, tx pgx.Tx, name string) (*Schema, error) {
+	schema := &Schema{Tables: make(map[string]*Table)}
+	unqualify := strings.NewReplacer(name+".", "", `"`+name+`".`, "")
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND NOT c.relispartition`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	var table, column, columnType string
+	var notNull bool
+	_, err = pgx.ForEachRow(rows, []any{&table, &column, &columnType, &notNull}, func() error {
+		t := schema.table(table)
+		t.Columns[column] = Column{Type: unqualify.Replace(columnType), NotNull: notNull}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT t.relname, i.relname, pg_get_indexdef(i.oid)
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1 AND t.relkind IN ('r', 'p') AND NOT t.relispartition`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	var index, definition string
+	_, err = pgx.ForEachRow(rows, []any{&table, &index, &definition}, func() error {
+		schema.table(table).Indexes[index] = unqualify.Replace(definition)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+	return schema, nil
+}
+
+// table returns the table of s named name, adding it if it isn't there yet
+func (s *Schema) table(name string) *Table {
+	t, ok := s.Tables[name]
+	if !ok {
+		t = &Table{Columns: make(map[string]Column), Indexes: make(map[string]string)}
+		s.Tables[name] = t
+	}
+	return t
+}
+
+// Diff returns the differences of live from expected, by table and by kind. The columns and
+// indexes of missing or unexpected tables aren't listed.
+func Diff(expected, live *Schema) []Difference {
+	var diffs []Difference
+	for _, name := range sortedKeys(expected.Tables) {
+		want := expected.Tables[name]
+		got, ok := live.Tables[name]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: Missing, Object: "table " + name})
+			continue
+		}
+		for _, column := range sortedKeys(want.Columns) {
+			object := "column " + name + "." + column
+			wantColumn := want.Columns[column]
+			gotColumn, ok := got.Columns[column]
+			switch {
+			case !ok:
+				diffs = append(diffs, Difference{Kind: Missing, Object: object})
+			case gotColumn != wantColumn:
+				diffs = append(diffs, Difference{Kind: Changed, Object: object, Detail: fmt.Sprintf("expected %s, found %s", wantColumn, gotColumn)})
+			}
+		}
+		for _, index := range sortedKeys(want.Indexes) {
+			object := "index " + index + " on " + name
+			gotDefinition, ok := got.Indexes[index]
+			switch {
+			case !ok:
+				diffs = append(diffs, Difference{Kind: Missing, Object: object})
+			case gotDefinition != want.Indexes[index]:
+				diffs = append(diffs, Difference{Kind: Changed, Object: object, Detail: fmt.Sprintf("expected %q, found %q", want.Indexes[index], gotDefinition)})
+			}
+		}
+		for _, column := range sortedKeys(got.Columns) {
+			if _, ok := want.Columns[column]; !ok {
+				diffs = append(diffs, Difference{Kind: Unexpected, Object: "column " + name + "." + column})
+			}
+		}
+		for _, index := range sortedKeys(got.Indexes) {
+			if _, ok := want.Indexes[index]; !ok {
+				diffs = append(diffs, Difference{Kind: Unexpected, Object: "index " + index + " on " + name})
+			}
+		}
+	}
+	for _, name := range sortedKeys(live.Tables) {
+		if _, ok := expected.Tables[name]; !ok {
+			diffs = append(diffs, Difference{Kind: Unexpected, Object: "table " + name})
+		}
+	}
+	return diffs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
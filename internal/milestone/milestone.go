@@ -0,0 +1,185 @@
+// Package milestone notifies users through push notifications when they reach one of their goals.
+package milestone
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// commitLag is how long before a check the records committed after the previous check may have
+// changed; each check covers the changes of the previous one's last commitLag again
+const commitLag = time.Minute
+
+// Goals recorded in the goal_milestones table
+const (
+	GoalTargetWeight          = "target_weight"
+	GoalWeeklyExerciseMinutes = "weekly_exercise_minutes"
+	GoalWeeklyCaloriesBurned  = "weekly_calories_burned"
+	GoalWeeklyDiaryEntries    = "weekly_diary_entries"
+)
+
+// Job notifies users of the goals they reached in the UTC week, as on the weekly summary of the
+// dashboard. Each goal is announced at most once a week.
+type Job struct {
+	repo     *repo.GoalRepository
+	notifier *push.Notifier
+	interval time.Duration
+	log      *slog.Logger
+	clock    clock.Clock
+	// checkedUntil is when the records were last checked; the zero time checks the current week
+	checkedUntil time.Time
+}
+
+// NewJob creates a job checking the users whose records changed once per interval
+func NewJob(repo *repo.GoalRepository, notifier *push.Notifier, interval time.Duration, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		repo:     repo,
+		notifier: notifier,
+		interval: interval,
+		log:      log,
+		clock:    clock,
+	}
+}
+
+// Run checks for reached goals immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check notifies the users whose records changed since the last check of the goals they reached.
+// If a notification cannot be queued, the next check covers the same changes again.
+func (j *Job) Check(ctx context.Context) {
+	now := j.clock.Now().UTC()
+	since := j.checkedUntil.Add(-commitLag)
+	if j.checkedUntil.IsZero() {
+		since = WeekStart(now)
+	}
+	userIDs, err := j.repo.ListChangedBetween(ctx, since, now)
+	if err != nil {
+		j.log.ErrorContext(ctx, "Failed to list users with goal changes", "error", err)
+		return
+	}
+
+	// Changes made late in the previous week may have reached its goals
+	weeks := []time.Time{WeekStart(now)}
+	if previous := WeekStart(since); !previous.Equal(weeks[0]) {
+		weeks = append(weeks, previous)
+	}
+	ok := true
+	for _, userID := range userIDs {
+		for _, weekStart := range weeks {
+			if err := j.check(ctx, userID, weekStart, now); err != nil {
+				j.log.ErrorContext(ctx, "Failed to check goal milestones", "userID", userID, "weekStart", weekStart, "error", err)
+				ok = false
+			}
+		}
+	}
+	if ok {
+		j.checkedUntil = now
+	}
+}
+
+// check notifies a user of the goals they reached in the week starting at weekStart and were not
+// notified of yet
+func (j *Job) check(ctx context.Context, userID uuid.UUID, weekStart, now time.Time) error {
+	summary, err := j.repo.WeeklySummary(ctx, userID, weekStart)
+	if err != nil {
+		return err
+	}
+	for _, goal := range Reached(summary) {
+		recorded, err := j.repo.RecordMilestone(ctx, userID, goal, weekStart, now)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			continue
+		}
+		err = j.notifier.Notify(ctx, userID, push.Message{
+			Kind:  push.KindGoalMilestone,
+			Title: "Goal reached",
+			Body:  messages[goal],
+			Data:  map[string]string{"goal": goal, "week_start": weekStart.Format("2006-01-02")},
+		})
+		if err != nil {
+			if err := j.repo.ReleaseMilestone(ctx, userID, goal, weekStart); err != nil {
+				j.log.ErrorContext(ctx, "Failed to release goal milestone", "userID", userID, "goal", goal, "error", err)
+			}
+			return fmt.Errorf("failed to notify goal milestone: %w", err)
+		}
+	}
+	return nil
+}
+
+// messages are the bodies of the notifications of each goal
+var messages = map[string]string{
+	GoalTargetWeight:          "You reached your target weight.",
+	GoalWeeklyExerciseMinutes: "You reached your exercise minutes goal for this week.",
+	GoalWeeklyCaloriesBurned:  "You reached your calories burned goal for this week.",
+	GoalWeeklyDiaryEntries:    "You reached your diary entries goal for this week.",
+}
+
+// WeekStart returns the Monday starting the UTC week of t
+func WeekStart(t time.Time) time.Time {
+	date := t.UTC().Truncate(24 * time.Hour)
+	return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+}
+
+// Reached returns the goals reached in the week of a summary: the weekly goals whose totals met
+// their targets, and the target weight if the week's last weight got to it from the weight the
+// week started at
+func Reached(summary db.GetWeeklySummaryRow) []string {
+	var goals []string
+	if target, ok := toFloat64(summary.GoalTargetWeightKg); ok {
+		start, hasStart := toFloat64(summary.PreviousWeightKg)
+		if !hasStart {
+			start, hasStart = toFloat64(summary.FirstWeightKg)
+			hasStart = hasStart && summary.WeighInCount > 1
+		}
+		end, hasEnd := toFloat64(summary.LastWeightKg)
+		if hasStart && hasEnd && start != target && (start-end)/(start-target) >= 1 {
+			goals = append(goals, GoalTargetWeight)
+		}
+	}
+	for _, g := range []struct {
+		goal   string
+		target pgtype.Int4
+		value  int64
+	}{
+		{GoalWeeklyExerciseMinutes, summary.GoalWeeklyExerciseMinutes, summary.TotalDurationMinutes},
+		{GoalWeeklyCaloriesBurned, summary.GoalWeeklyCaloriesBurned, summary.TotalCaloriesBurned},
+		{GoalWeeklyDiaryEntries, summary.GoalWeeklyDiaryEntries, summary.DiaryEntryCount},
+	} {
+		if g.target.Valid && g.value >= int64(g.target.Int32) {
+			goals = append(goals, g.goal)
+		}
+	}
+	return goals
+}
+
+// toFloat64 converts a NUMERIC value, reporting false if it is NULL
+func toFloat64(n pgtype.Numeric) (float64, bool) {
+	f, err := n.Float64Value()
+	if err != nil || !f.Valid {
+		return 0, false
+	}
+	return f.Float64, true
+}
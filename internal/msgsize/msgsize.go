@@ -0,0 +1,68 @@
+// Package msgsize bounds the size of request messages, so that huge payloads are rejected
+// before they are read into memory instead of exhausting it.
+package msgsize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+)
+
+// errorWriter writes the errors of rejected requests in the protocol of the request
+var errorWriter = connect.NewErrorWriter()
+
+// maxFor returns the maximum size maxBytes maps procedure to, or defaultMax
+func maxFor(procedure string, defaultMax int64, maxBytes map[string]int64) int64 {
+	if limit, ok := maxBytes[procedure]; ok {
+		return limit
+	}
+	return defaultMax
+}
+
+// Handler wraps the Connect handler next, rejecting requests whose body is larger than the
+// maximum maxBytes maps their procedure to, or defaultMax for other procedures, with
+// invalid_argument and reason limit_exceeded. Bodies without a Content-Length are read up to the
+// maximum before next is called, so no more than the maximum is ever held in memory.
+func Handler(next http.Handler, defaultMax int64, maxBytes map[string]int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := maxFor(r.URL.Path, defaultMax, maxBytes)
+		if r.ContentLength > limit {
+			reject(w, r, limit)
+			return
+		}
+		if r.ContentLength < 0 {
+			body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+			if err != nil {
+				_ = errorWriter.Write(w, r, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("failed to read request body: %w", err)))
+				return
+			}
+			if int64(len(body)) > limit {
+				reject(w, r, limit)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		} else {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandlerOption creates a Connect handler option limiting the size of request messages in the
+// same way after they were decompressed, so that compressed payloads can't expand beyond the
+// maximum either. Connect fails those requests with resource_exhausted.
+func HandlerOption(defaultMax int64, maxBytes map[string]int64) connect.HandlerOption {
+	return connect.WithConditionalHandlerOptions(func(spec connect.Spec) []connect.HandlerOption {
+		return []connect.HandlerOption{connect.WithReadMaxBytes(int(maxFor(spec.Procedure, defaultMax, maxBytes)))}
+	})
+}
+
+// reject fails a request larger than limit bytes
+func reject(w http.ResponseWriter, r *http.Request, limit int64) {
+	err := apierror.New(connect.CodeInvalidArgument, apierror.ReasonLimitExceeded, fmt.Errorf("request message exceeds the maximum size of %d bytes", limit))
+	_ = errorWriter.Write(w, r, err)
+}
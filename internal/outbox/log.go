@@ -0,0 +1,22 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogPublisher logs events instead of publishing them, for development
+type LogPublisher struct {
+	log *slog.Logger
+}
+
+// NewLogPublisher creates a publisher logging to log
+func NewLogPublisher(log *slog.Logger) *LogPublisher {
+	return &LogPublisher{log: log}
+}
+
+// Publish logs the ID, type and data of event
+func (p *LogPublisher) Publish(ctx context.Context, event Event) error {
+	p.log.InfoContext(ctx, "Event not published by log driver", "eventID", event.ID, "eventType", event.Type, "data", string(event.Data))
+	return nil
+}
@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+)
+
+// Drivers selectable in the outbox config
+const (
+	DriverLog     = "log"
+	DriverWebhook = "webhook"
+)
+
+// Event is a domain event as published to subscribers. Events are published at least once:
+// subscribers deduplicate them by ID.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	UserID     string          `json:"user_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher publishes events to their subscribers, e.g. a webhook or a message bus
+type Publisher interface {
+	// Publish returns nil once the subscribers accepted event; events are retried on errors
+	Publish(ctx context.Context, event Event) error
+}
+
+// newEvent converts an event stored in the outbox to its published form
+func newEvent(e db.OutboxEvent) Event {
+	return Event{
+		ID:         e.ID.String(),
+		Type:       e.EventType,
+		UserID:     e.UserID.String(),
+		OccurredAt: e.CreatedAt.UTC(),
+		Data:       json.RawMessage(e.Payload),
+	}
+}
@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+)
+
+const (
+	// batchSize is how many events are claimed at once
+	batchSize = 100
+	// leaseDuration is how long claimed events are reserved for the publishing relay; events of
+	// a relay that stopped mid-batch are retried once it expires
+	leaseDuration = 5 * time.Minute
+	// maxAttempts is how many times publishing is attempted before an event is marked failed
+	maxAttempts = 10
+	// initialBackoff is the delay before the first retry, doubled on every further retry
+	initialBackoff = 10 * time.Second
+	// maxBackoff caps the delay between retries
+	maxBackoff = time.Hour
+)
+
+// Relay publishes the events of the outbox, in the order they were written except for retries
+type Relay struct {
+	repo      *repo.OutboxRepository
+	publisher Publisher
+	interval  time.Duration
+	// retainPublished is how long published events are kept, e.g. to investigate deliveries
+	retainPublished time.Duration
+	log             *slog.Logger
+	clock           clock.Clock
+}
+
+// NewRelay creates a relay publishing through publisher, polling for due events once per
+// interval and deleting events published more than retainPublished ago
+func NewRelay(repo *repo.OutboxRepository, publisher Publisher, interval, retainPublished time.Duration, log *slog.Logger, clock clock.Clock) *Relay {
+	return &Relay{
+		repo:            repo,
+		publisher:       publisher,
+		interval:        interval,
+		retainPublished: retainPublished,
+		log:             log,
+		clock:           clock,
+	}
+}
+
+// Run publishes due events once per interval until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.PublishDue(ctx)
+		r.DeletePublished(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes events until none are due
+func (r *Relay) PublishDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := r.clock.Now()
+		events, err := r.repo.ClaimDue(ctx, batchSize, now, now.Add(leaseDuration))
+		if err != nil {
+			r.log.ErrorContext(ctx, "Failed to claim outbox events", "error", err)
+			return
+		}
+		for _, e := range events {
+			r.publish(ctx, e)
+		}
+		if len(events) < batchSize {
+			return
+		}
+	}
+}
+
+// DeletePublished deletes the events published before the retention period
+func (r *Relay) DeletePublished(ctx context.Context) {
+	deleted, err := r.repo.DeletePublished(ctx, r.clock.Now().Add(-r.retainPublished))
+	if err != nil {
+		r.log.ErrorContext(ctx, "Failed to delete published outbox events", "error", err)
+		return
+	}
+	if deleted > 0 {
+		r.log.InfoContext(ctx, "Deleted published outbox events", "count", deleted)
+	}
+}
+
+// publish publishes a claimed event and records the outcome
+func (r *Relay) publish(ctx context.Context, e db.OutboxEvent) {
+	err := r.publisher.Publish(ctx, newEvent(e))
+	switch {
+	case err == nil:
+		if err := r.repo.MarkPublished(ctx, e.ID, r.clock.Now()); err != nil {
+			r.log.ErrorContext(ctx, "Failed to mark outbox event published", "eventID", e.ID, "error", err)
+		}
+	case e.Attempts < maxAttempts:
+		nextAttemptAt := r.clock.Now().Add(backoff(e.Attempts))
+		r.log.WarnContext(ctx, "Publishing event failed, retrying", "eventID", e.ID, "eventType", e.EventType, "attempts", e.Attempts, "nextAttemptAt", nextAttemptAt, "error", err)
+		if err := r.repo.Reschedule(ctx, e.ID, nextAttemptAt, err.Error()); err != nil {
+			r.log.ErrorContext(ctx, "Failed to reschedule outbox event", "eventID", e.ID, "error", err)
+		}
+	default:
+		r.log.ErrorContext(ctx, "Publishing event failed", "eventID", e.ID, "eventType", e.EventType, "attempts", e.Attempts, "error", err)
+		if err := r.repo.MarkFailed(ctx, e.ID, err.Error()); err != nil {
+			r.log.ErrorContext(ctx, "Failed to mark outbox event failed", "eventID", e.ID, "error", err)
+		}
+	}
+}
+
+// backoff returns the delay before retrying an event after its given number of attempts
+func backoff(attempts int32) time.Duration {
+	delay := initialBackoff
+	for i := int32(1); i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// Headers of webhook requests
+const (
+	HeaderEventID   = "X-Healthapp-Event-Id"
+	HeaderTimestamp = "X-Healthapp-Timestamp"
+	HeaderSignature = "X-Healthapp-Signature"
+)
+
+// WebhookPublisher posts every event as JSON to a URL. Requests are signed with HMAC-SHA256
+// of "<timestamp>.<body>" keyed by the secret, sent as "sha256=<hex>" in HeaderSignature, so
+// subscribers can check that events come from the server and reject replays.
+type WebhookPublisher struct {
+	URL        string
+	Secret     []byte
+	HTTPClient *http.Client
+	clock      clock.Clock
+}
+
+// NewWebhookPublisher creates a publisher posting to url, signing with secret and giving up
+// on requests after timeout
+func NewWebhookPublisher(url, secret string, timeout time.Duration, clock clock.Clock) *WebhookPublisher {
+	return &WebhookPublisher{
+		URL:        url,
+		Secret:     []byte(secret),
+		HTTPClient: &http.Client{Timeout: timeout},
+		clock:      clock,
+	}
+}
+
+// Publish posts event, succeeding on any 2xx response
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	timestamp := strconv.FormatInt(p.clock.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(p.Secret, timestamp, body))
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	return fmt.Errorf("webhook request failed with status %d: %s", resp.StatusCode, respBody)
+}
+
+// Sign returns the signature of a webhook request with the given timestamp and body
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
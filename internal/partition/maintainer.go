@@ -0,0 +1,59 @@
+package partition
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// Maintainer creates the monthly partitions of the record tables ahead of time, so records of
+// the coming months don't land in the default partitions
+type Maintainer struct {
+	repo        *repo.PartitionRepository
+	monthsAhead int
+	interval    time.Duration
+	log         *slog.Logger
+	clock       clock.Clock
+}
+
+// NewMaintainer creates a maintainer keeping partitions monthsAhead months ahead, checking
+// once per interval
+func NewMaintainer(repo *repo.PartitionRepository, monthsAhead int, interval time.Duration, log *slog.Logger, clock clock.Clock) *Maintainer {
+	return &Maintainer{
+		repo:        repo,
+		monthsAhead: monthsAhead,
+		interval:    interval,
+		log:         log,
+		clock:       clock,
+	}
+}
+
+// Run creates missing partitions immediately and then once per interval until ctx is cancelled
+func (m *Maintainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.CreatePartitions(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CreatePartitions creates the missing partitions through monthsAhead months from now
+func (m *Maintainer) CreatePartitions(ctx context.Context) {
+	created, err := m.repo.CreatePartitions(ctx, m.clock.Now(), m.monthsAhead)
+	if err != nil {
+		m.log.ErrorContext(ctx, "Failed to create record partitions", "error", err)
+		return
+	}
+	if len(created) > 0 {
+		m.log.InfoContext(ctx, "Record partitions created", "partitions", created)
+	}
+}
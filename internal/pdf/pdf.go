@@ -0,0 +1,258 @@
+// Package pdf writes simple PDF documents: pages of text in the standard Helvetica fonts, lines
+// and filled rectangles. The standard fonts need no embedding but only cover Latin-1; text with
+// other characters is drawn with the fallback TrueType font of the document, whose glyphs are
+// embedded, or has them replaced by "?" without one.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Size of A4 pages in points. Coordinates start at the bottom left corner of the page.
+const (
+	A4Width  = 595.0
+	A4Height = 842.0
+)
+
+// Font is one of the standard fonts of documents
+type Font int
+
+// Fonts of documents
+const (
+	Regular Font = iota // Helvetica
+	Bold                // Helvetica-Bold
+)
+
+// fontNames are the base fonts of the fonts, by font
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Point is a point on a page
+type Point struct {
+	X, Y float64
+}
+
+// Document is a PDF document of A4 pages
+type Document struct {
+	pages    []*Page
+	fallback *TrueType
+	// glyphs are the glyphs of fallback drawn in the document, with the rune drawn as each
+	glyphs map[uint16]rune
+}
+
+// New creates an empty document drawing the text the standard fonts can't with fallback; with a
+// nil fallback, characters outside Latin-1 are replaced by "?"
+func New(fallback *TrueType) *Document {
+	return &Document{fallback: fallback, glyphs: make(map[uint16]rune)}
+}
+
+// AddPage appends a blank page to the document
+func (d *Document) AddPage() *Page {
+	page := &Page{doc: d}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Page is a page of a document, drawn by appending operators to its content stream
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+// Text draws text with its baseline starting at x, y. Text outside Latin-1 is drawn with the
+// fallback font of the document, emboldened by stroking its outlines for Bold.
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	fallback := p.doc.fallback
+	if fallback == nil || latin1(text) {
+		fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n", font+1, num(size), num(x), num(y), escape(text))
+		return
+	}
+
+	var glyphs strings.Builder
+	for _, r := range text {
+		if r < ' ' {
+			r = ' '
+		}
+		glyph := fallback.glyph(r)
+		if _, ok := p.doc.glyphs[glyph]; !ok {
+			p.doc.glyphs[glyph] = r
+		}
+		fmt.Fprintf(&glyphs, "%04X", glyph)
+	}
+	if font == Bold {
+		fmt.Fprintf(&p.content, "q %s w 2 Tr ", num(size/30))
+	}
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td <%s> Tj ET", len(fontNames)+1, num(size), num(x), num(y), glyphs.String())
+	if font == Bold {
+		p.content.WriteString(" Q")
+	}
+	p.content.WriteString("\n")
+}
+
+// Line strokes a line from x1, y1 to x2, y2
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// Polyline strokes the lines joining points; it draws nothing for fewer than two points
+func (p *Page) Polyline(points []Point, width float64) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(&p.content, "%s w %s %s m", num(width), num(points[0].X), num(points[0].Y))
+	for _, point := range points[1:] {
+		fmt.Fprintf(&p.content, " %s %s l", num(point.X), num(point.Y))
+	}
+	p.content.WriteString(" S\n")
+}
+
+// Rect fills the rectangle with its bottom left corner at x, y
+func (p *Page) Rect(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(y), num(width), num(height))
+}
+
+// Gray sets the gray level of the text, lines and rectangles drawn next, from 0 (black) to 1
+// (white)
+func (p *Page) Gray(level float64) {
+	fmt.Fprintf(&p.content, "%s g %s G\n", num(level), num(level))
+}
+
+// TextWidth estimates the width of text at size from the average width of Helvetica characters,
+// and the full width of Chinese, Japanese and Korean characters, e.g. to wrap lines
+func TextWidth(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		if wide(r) {
+			width += size
+		} else {
+			width += size * 0.5
+		}
+	}
+	return width
+}
+
+// wide reports whether r is drawn at full width, as Chinese, Japanese and Korean characters and
+// the fullwidth forms are
+func wide(r rune) bool {
+	return r >= 0x1100 && (unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xff60)
+}
+
+// Bytes encodes the document. Objects 1 to 4 are the catalog, the page tree and the fonts;
+// every page is followed by its content stream.
+func (d *Document) Bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // The page tree, which lists the pages
+	}
+	for _, name := range fontNames {
+		objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /"+name+" /Encoding /WinAnsiEncoding >>")
+	}
+	fonts := ""
+	for i := range fontNames {
+		fonts += fmt.Sprintf(" /F%d %d 0 R", i+1, i+3)
+	}
+
+	if len(d.glyphs) > 0 {
+		fonts += fmt.Sprintf(" /F%d %d 0 R", len(fontNames)+1, len(objects)+1)
+		objects = append(objects, d.fallbackObjects(len(objects)+1)...)
+	}
+
+	kids := make([]string, len(d.pages))
+	for i, page := range d.pages {
+		pageID := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", pageID)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font <<%s >> >> /Contents %d 0 R >>", num(A4Width), num(A4Height), fonts, pageID+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	var out bytes.Buffer
+	// The binary comment marks the file as binary for transfer tools
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// fallbackObjects returns the objects of the fallback font, numbered from first: the Type 0 font,
+// its CIDFont, the font descriptor, the compressed subset of the font file and the ToUnicode map.
+// CIDs are the glyph IDs of the font.
+func (d *Document) fallbackObjects(first int) []string {
+	f := d.fallback
+	glyphs := sortedGlyphs(d.glyphs)
+	name := f.subsetName(glyphs)
+	widths := make([]string, len(glyphs))
+	for i, glyph := range glyphs {
+		widths[i] = fmt.Sprintf("%d [%d]", glyph, f.width(glyph))
+	}
+
+	font := f.subset(d.glyphs)
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(font) //nolint:errcheck // Writes to a buffer don't fail
+	w.Close()     //nolint:errcheck
+	cmap := toUnicode(glyphs, d.glyphs)
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>", name, first+2, strings.Join(widths, " ")),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			name, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]), f.scale(f.ascent), f.scale(f.descent), f.scale(f.ascent), first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), len(font), compressed.String()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(cmap), cmap),
+	}
+}
+
+// latin1 reports whether the standard fonts draw all characters of text
+func latin1(text string) bool {
+	for _, r := range text {
+		if r >= 0x7f && (r < 0xa0 || r > 0xff) {
+			return false
+		}
+	}
+	return true
+}
+
+// escape encodes text as the content of a PDF string in WinAnsiEncoding, which matches Latin-1
+// for printable characters; others become "?", and control characters spaces
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ':
+			b.WriteByte(' ')
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// num formats a number of points with at most two decimals
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
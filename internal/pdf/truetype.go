@@ -0,0 +1,412 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// errMalformedFont is returned for fonts whose tables are truncated or inconsistent
+var errMalformedFont = errors.New("malformed TrueType font")
+
+// subsetTables are the tables kept in the embedded subsets of fonts, sorted by tag as the table
+// directory requires; the optional hinting tables are copied when present
+var subsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// TrueType is a TrueType font drawing the text the standard fonts can't, such as Chinese,
+// Japanese or Korean text. Documents embed the subset of the glyphs they use, as a CIDFont with
+// the Identity-H encoding and a ToUnicode map, so the text can be copied and searched.
+type TrueType struct {
+	name        string
+	tables      map[string][]byte
+	unitsPerEm  int
+	bbox        [4]int // xMin, yMin, xMax, yMax in font units
+	ascent      int
+	descent     int
+	advances    []int // Advance widths of glyphs in font units; the last one repeats
+	glyphs      [][]byte
+	glyphOfRune map[rune]uint16
+}
+
+// fontData reads the big-endian values of a font table, recording reads past its end
+type fontData struct {
+	b   []byte
+	bad bool
+}
+
+func (d *fontData) u16(off int) int {
+	if off < 0 || off+2 > len(d.b) {
+		d.bad = true
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(d.b[off:]))
+}
+
+func (d *fontData) i16(off int) int {
+	return int(int16(d.u16(off)))
+}
+
+func (d *fontData) u32(off int) int {
+	if off < 0 || off+4 > len(d.b) {
+		d.bad = true
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(d.b[off:]))
+}
+
+// ParseTrueType parses a TrueType font file, such as a .ttf file of Noto Sans JP or IPAGothic.
+// Fonts with CFF outlines (most .otf files) and font collections (.ttc) are not supported.
+func ParseTrueType(data []byte) (*TrueType, error) {
+	file := &fontData{b: data}
+	switch version := file.u32(0); {
+	case version == 0x00010000 || version == 0x74727565: // "true"
+	case version == 0x4f54544f: // "OTTO"
+		return nil, errors.New("font has CFF outlines, only TrueType outlines are supported")
+	case version == 0x74746366: // "ttcf"
+		return nil, errors.New("font collections are not supported, extract one font of the collection")
+	default:
+		return nil, errMalformedFont
+	}
+
+	f := &TrueType{tables: make(map[string][]byte)}
+	numTables := file.u16(4)
+	for i := 0; i < numTables; i++ {
+		record := 12 + 16*i
+		if record+16 > len(data) {
+			return nil, errMalformedFont
+		}
+		offset, length := file.u32(record+8), file.u32(record+12)
+		if offset+length > len(data) {
+			return nil, errMalformedFont
+		}
+		f.tables[string(data[record:record+4])] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"cmap", "glyf", "head", "hhea", "hmtx", "loca", "maxp"} {
+		if f.tables[tag] == nil {
+			return nil, fmt.Errorf("font has no %s table", strings.TrimSpace(tag))
+		}
+	}
+
+	head := &fontData{b: f.tables["head"]}
+	f.unitsPerEm = head.u16(18)
+	f.bbox = [4]int{head.i16(36), head.i16(38), head.i16(40), head.i16(42)}
+	longLoca := head.i16(50) == 1
+	hhea := &fontData{b: f.tables["hhea"]}
+	f.ascent, f.descent = hhea.i16(4), hhea.i16(6)
+	numGlyphs := (&fontData{b: f.tables["maxp"]}).u16(4)
+
+	hmtx := &fontData{b: f.tables["hmtx"]}
+	f.advances = make([]int, hhea.u16(34))
+	for i := range f.advances {
+		f.advances[i] = hmtx.u16(4 * i)
+	}
+
+	loca := &fontData{b: f.tables["loca"]}
+	glyf := f.tables["glyf"]
+	f.glyphs = make([][]byte, numGlyphs)
+	for i := range f.glyphs {
+		var start, end int
+		if longLoca {
+			start, end = loca.u32(4*i), loca.u32(4*i+4)
+		} else {
+			start, end = 2*loca.u16(2*i), 2*loca.u16(2*i+2)
+		}
+		if start > end || end > len(glyf) {
+			return nil, errMalformedFont
+		}
+		f.glyphs[i] = glyf[start:end]
+	}
+
+	var err error
+	if f.glyphOfRune, err = parseCmap(f.tables["cmap"]); err != nil {
+		return nil, err
+	}
+	if head.bad || hhea.bad || hmtx.bad || loca.bad || f.unitsPerEm == 0 || len(f.advances) == 0 {
+		return nil, errMalformedFont
+	}
+	f.name = postScriptName(f.tables["name"])
+	return f, nil
+}
+
+// parseCmap returns the glyphs of the runes of the Unicode subtables of a cmap table, in format 4
+// (the Basic Multilingual Plane) or 12 (all planes, preferred)
+func parseCmap(table []byte) (map[rune]uint16, error) {
+	cmap := &fontData{b: table}
+	var format4, format12 []int
+	for i := 0; i < cmap.u16(2); i++ {
+		platform, encoding, offset := cmap.u16(4+8*i), cmap.u16(6+8*i), cmap.u32(8+8*i)
+		if platform != 0 && (platform != 3 || (encoding != 1 && encoding != 10)) {
+			continue
+		}
+		switch cmap.u16(offset) {
+		case 4:
+			format4 = append(format4, offset)
+		case 12:
+			format12 = append(format12, offset)
+		}
+	}
+
+	glyphs := make(map[rune]uint16)
+	for _, offset := range format4 {
+		segCount := cmap.u16(offset+6) / 2
+		ends, starts := offset+14, offset+16+2*segCount
+		deltas, rangeOffsets := starts+2*segCount, starts+4*segCount
+		for s := 0; s < segCount && !cmap.bad; s++ {
+			start, end := cmap.u16(starts+2*s), cmap.u16(ends+2*s)
+			delta, rangeOffset := cmap.u16(deltas+2*s), cmap.u16(rangeOffsets+2*s)
+			for c := start; c <= end && c != 0xffff; c++ {
+				glyph := c + delta
+				if rangeOffset != 0 {
+					if glyph = cmap.u16(rangeOffsets + 2*s + rangeOffset + 2*(c-start)); glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph&0xffff != 0 {
+					glyphs[rune(c)] = uint16(glyph)
+				}
+			}
+		}
+	}
+	for _, offset := range format12 {
+		for g := 0; g < cmap.u32(offset+12) && !cmap.bad; g++ {
+			group := offset + 16 + 12*g
+			start, end, glyph := cmap.u32(group), cmap.u32(group+4), cmap.u32(group+8)
+			if end-start > 0x10ffff {
+				return nil, errMalformedFont
+			}
+			for c := start; c <= end; c++ {
+				glyphs[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+	}
+	if cmap.bad {
+		return nil, errMalformedFont
+	}
+	if len(glyphs) == 0 {
+		return nil, errors.New("font has no Unicode cmap")
+	}
+	return glyphs, nil
+}
+
+// postScriptName returns the PostScript name of a font from its name table, with only the
+// characters allowed in PDF names
+func postScriptName(table []byte) string {
+	names := &fontData{b: table}
+	stringOffset := names.u16(4)
+	for i := 0; i < names.u16(2) && !names.bad; i++ {
+		record := 6 + 12*i
+		if names.u16(record+6) != 6 {
+			continue
+		}
+		length, offset := names.u16(record+8), stringOffset+names.u16(record+10)
+		if offset+length > len(table) {
+			break
+		}
+		raw := table[offset : offset+length]
+		if platform := names.u16(record); platform == 0 || platform == 3 {
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[2*j:])
+			}
+			raw = []byte(string(utf16.Decode(units)))
+		}
+		var name []byte
+		for _, c := range raw {
+			if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' {
+				name = append(name, c)
+			}
+		}
+		if len(name) > 0 {
+			return string(name)
+		}
+	}
+	return "Fallback"
+}
+
+// glyph returns the glyph of r, or the glyph of "?" if the font has none
+func (f *TrueType) glyph(r rune) uint16 {
+	if glyph, ok := f.glyphOfRune[r]; ok {
+		return glyph
+	}
+	return f.glyphOfRune['?']
+}
+
+// width returns the advance width of a glyph in thousandths of the font size
+func (f *TrueType) width(glyph uint16) int {
+	advance := f.advances[len(f.advances)-1]
+	if int(glyph) < len(f.advances) {
+		advance = f.advances[glyph]
+	}
+	return advance * 1000 / f.unitsPerEm
+}
+
+// scale converts font units to thousandths of the font size
+func (f *TrueType) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+// subset returns the font file keeping the outlines of glyphs and of the glyphs they are
+// composed of; the other glyphs are left empty, so glyph IDs stay the same
+func (f *TrueType) subset(glyphs map[uint16]rune) []byte {
+	keep := map[uint16]bool{0: true}
+	var add func(glyph uint16)
+	add = func(glyph uint16) {
+		if keep[glyph] || int(glyph) >= len(f.glyphs) {
+			return
+		}
+		keep[glyph] = true
+		// Composite glyphs have a negative number of contours, followed by their components
+		data := &fontData{b: f.glyphs[glyph]}
+		if len(data.b) < 10 || data.i16(0) >= 0 {
+			return
+		}
+		for offset := 10; !data.bad; {
+			flags := data.u16(offset)
+			add(uint16(data.u16(offset + 2)))
+			offset += 4
+			switch {
+			case flags&0x0001 != 0: // Arguments are words
+				offset += 4
+			default:
+				offset += 2
+			}
+			switch {
+			case flags&0x0008 != 0: // A scale
+				offset += 2
+			case flags&0x0040 != 0: // An x and y scale
+				offset += 4
+			case flags&0x0080 != 0: // A two by two transformation
+				offset += 8
+			}
+			if flags&0x0020 == 0 { // No more components
+				break
+			}
+		}
+	}
+	for glyph := range glyphs {
+		add(glyph)
+	}
+
+	// Glyphs are stored with long offsets, 4-byte aligned
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(len(f.glyphs)+1))
+	for i, data := range f.glyphs {
+		binary.BigEndian.PutUint32(loca[4*i:], uint32(glyf.Len()))
+		if keep[uint16(i)] {
+			glyf.Write(data)
+			glyf.Write(make([]byte, -len(data)&3))
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*len(f.glyphs):], uint32(glyf.Len()))
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0) // checkSumAdjustment, set once the file is written
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{"glyf": glyf.Bytes(), "loca": loca, "head": head}
+	var tags []string
+	for _, tag := range subsetTables {
+		if tables[tag] == nil {
+			tables[tag] = f.tables[tag]
+		}
+		if tables[tag] != nil {
+			tags = append(tags, tag)
+		}
+	}
+
+	// Offset table, then the table directory and the tables, each 4-byte aligned
+	var out bytes.Buffer
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= len(tags) {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+	for _, v := range []uint16{1, 0, uint16(len(tags)), uint16(searchRange), uint16(entrySelector), uint16(16*len(tags) - searchRange)} {
+		binary.Write(&out, binary.BigEndian, v) //nolint:errcheck // Writes to a buffer don't fail
+	}
+	offset := 12 + 16*len(tags)
+	headOffset := 0
+	for _, tag := range tags {
+		data := tables[tag]
+		if tag == "head" {
+			headOffset = offset
+		}
+		out.WriteString(tag)
+		for _, v := range []uint32{checksum(data), uint32(offset), uint32(len(data))} {
+			binary.Write(&out, binary.BigEndian, v) //nolint:errcheck // Writes to a buffer don't fail
+		}
+		offset += len(data) + -len(data)&3
+	}
+	for _, tag := range tags {
+		out.Write(tables[tag])
+		out.Write(make([]byte, -len(tables[tag])&3))
+	}
+	font := out.Bytes()
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xb1b0afba-checksum(font))
+	return font
+}
+
+// checksum returns the checksum of a table or font file: the sum of its big-endian words
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// subsetName returns the name of the subset of glyphs: a tag of six capital letters identifying
+// the subset, then the font name
+func (f *TrueType) subsetName(glyphs []uint16) string {
+	h := fnv.New32a()
+	for _, glyph := range glyphs {
+		binary.Write(h, binary.BigEndian, glyph) //nolint:errcheck // Writes to a hash don't fail
+	}
+	sum := h.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(sum%26)
+		sum /= 26
+	}
+	return string(tag) + "+" + f.name
+}
+
+// toUnicode returns the CMap mapping glyphs back to the runes they were drawn for
+func toUnicode(glyphs []uint16, runes map[uint16]rune) string {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// The entries of a block are limited to 100
+	for start := 0; start < len(glyphs); start += 100 {
+		end := min(start+100, len(glyphs))
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, glyph := range glyphs[start:end] {
+			fmt.Fprintf(&b, "<%04X> <", glyph)
+			for _, unit := range utf16.Encode([]rune{runes[glyph]}) {
+				fmt.Fprintf(&b, "%04X", unit)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.String()
+}
+
+// sortedGlyphs returns the glyphs of a document in ascending order
+func sortedGlyphs(glyphs map[uint16]rune) []uint16 {
+	sorted := make([]uint16, 0, len(glyphs))
+	for glyph := range glyphs {
+		sorted = append(sorted, glyph)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
@@ -0,0 +1,168 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+	// apnsTokenRefresh is how long a provider token is reused. APNs rejects tokens older than
+	// an hour and throttles tokens renewed more often than every 20 minutes.
+	apnsTokenRefresh = 50 * time.Minute
+)
+
+// apnsInvalidTokenReasons are the APNs error reasons of device tokens that will never be accepted
+var apnsInvalidTokenReasons = map[string]bool{
+	"BadDeviceToken":         true,
+	"DeviceTokenNotForTopic": true,
+	"Unregistered":           true,
+}
+
+// APNs sends notifications through the Apple Push Notification service, authenticating with
+// a token signing key
+type APNs struct {
+	KeyID  string
+	TeamID string
+	Key    *ecdsa.PrivateKey
+	// Topic is the bundle ID of the app
+	Topic string
+	// BaseURL is the production or sandbox endpoint
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// mu guards the cached provider token
+	mu            sync.Mutex
+	providerToken string
+	tokenIssuedAt time.Time
+}
+
+// NewAPNs creates an APNs sender from a .p8 token signing key. sandbox selects the
+// development environment, for builds signed with a development certificate.
+func NewAPNs(keyPEM []byte, keyID, teamID, topic string, sandbox bool) (*APNs, error) {
+	key, err := jwt.ParseECPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse APNs signing key: %w", err)
+	}
+	baseURL := apnsProductionURL
+	if sandbox {
+		baseURL = apnsSandboxURL
+	}
+
+	return &APNs{
+		KeyID:   keyID,
+		TeamID:  teamID,
+		Key:     key,
+		Topic:   topic,
+		BaseURL: baseURL,
+		// APNs requires HTTP/2, which the default transport negotiates over TLS
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Platform returns the platform of APNs device tokens
+func (a *APNs) Platform() string {
+	return PlatformAPNs
+}
+
+// Send delivers a message to an APNs device token
+func (a *APNs) Send(ctx context.Context, token string, msg Message) error {
+	providerToken, err := a.token()
+	if err != nil {
+		return err
+	}
+
+	// Custom data is sent as top-level keys next to aps
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"sound": "default",
+		},
+		"kind": msg.Kind,
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode APNs payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.BaseURL+"/3/device/"+token, bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("failed to create APNs request: %w", err)
+	}
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", a.Topic)
+	req.Header.Set("apns-push-type", "alert")
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return transient(fmt.Errorf("failed to send APNs request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	err = fmt.Errorf("APNs request failed with status %d: %s", resp.StatusCode, body.Reason)
+
+	switch {
+	case resp.StatusCode == http.StatusGone || apnsInvalidTokenReasons[body.Reason]:
+		return ErrInvalidToken
+	case body.Reason == "ExpiredProviderToken":
+		// Sign a new provider token on the next attempt
+		a.mu.Lock()
+		a.providerToken = ""
+		a.mu.Unlock()
+		return transient(err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return transient(err)
+	}
+	return err
+}
+
+// token returns the cached provider token, signing a new one when it is due for renewal
+func (a *APNs) token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	if a.providerToken != "" && now.Sub(a.tokenIssuedAt) < apnsTokenRefresh {
+		return a.providerToken, nil
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": a.TeamID,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = a.KeyID
+	signed, err := t.SignedString(a.Key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign APNs provider token: %w", err)
+	}
+
+	a.providerToken = signed
+	a.tokenIssuedAt = now
+	return signed, nil
+}
@@ -0,0 +1,214 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmAPIBaseURL = "https://fcm.googleapis.com/v1"
+	fcmScope      = "https://www.googleapis.com/auth/firebase.messaging"
+	// fcmTokenLifetime is the lifetime requested for the service account assertion, the maximum Google accepts
+	fcmTokenLifetime = time.Hour
+	// accessTokenMargin renews cached access tokens that expire within this margin
+	accessTokenMargin = time.Minute
+)
+
+// FCM sends notifications through the Firebase Cloud Messaging HTTP v1 API, authenticating
+// as a service account
+type FCM struct {
+	ProjectID   string
+	ClientEmail string
+	PrivateKey  *rsa.PrivateKey
+	// TokenURL and APIBaseURL default to the endpoints of the service account file and Google
+	TokenURL   string
+	APIBaseURL string
+	HTTPClient *http.Client
+
+	// mu guards the cached access token
+	mu             sync.Mutex
+	accessToken    string
+	tokenExpiresAt time.Time
+}
+
+// NewFCM creates an FCM sender from the JSON key file of a service account
+func NewFCM(serviceAccountJSON []byte) (*FCM, error) {
+	var key struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(serviceAccountJSON, &key); err != nil {
+		return nil, fmt.Errorf("failed to decode service account key: %w", err)
+	}
+	if key.ProjectID == "" || key.ClientEmail == "" || key.TokenURI == "" {
+		return nil, errors.New("service account key must have project_id, client_email and token_uri")
+	}
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private key: %w", err)
+	}
+
+	return &FCM{
+		ProjectID:   key.ProjectID,
+		ClientEmail: key.ClientEmail,
+		PrivateKey:  privateKey,
+		TokenURL:    key.TokenURI,
+		APIBaseURL:  fcmAPIBaseURL,
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Platform returns the platform of FCM registration tokens
+func (f *FCM) Platform() string {
+	return PlatformFCM
+}
+
+// Send delivers a message to an FCM registration token
+func (f *FCM) Send(ctx context.Context, token string, msg Message) error {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return transient(err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token": token,
+			"notification": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"data": fcmData(msg),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode FCM message: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s/messages:send", f.APIBaseURL, url.PathEscape(f.ProjectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create FCM request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return transient(fmt.Errorf("failed to send FCM request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var body struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	err = fmt.Errorf("FCM request failed with status %d: %s %s", resp.StatusCode, body.Error.Status, body.Error.Message)
+
+	for _, detail := range body.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return ErrInvalidToken
+		}
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// Expired or unregistered tokens are reported as not found
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusUnauthorized:
+		// Fetch a new access token on the next attempt
+		f.mu.Lock()
+		f.accessToken = ""
+		f.mu.Unlock()
+		return transient(err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return transient(err)
+	}
+	return err
+}
+
+// fcmData returns the data payload of a message, which includes its kind
+func fcmData(msg Message) map[string]string {
+	data := make(map[string]string, len(msg.Data)+1)
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	data["kind"] = msg.Kind
+	return data
+}
+
+// token returns a cached access token, exchanging a signed service account assertion for a
+// new one when it is about to expire
+func (f *FCM) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.accessToken != "" && now.Add(accessTokenMargin).Before(f.tokenExpiresAt) {
+		return f.accessToken, nil
+	}
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(fcmTokenLifetime).Unix(),
+	}).SignedString(f.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign service account assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, body.Error)
+	}
+
+	f.accessToken = body.AccessToken
+	f.tokenExpiresAt = now.Add(time.Duration(body.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
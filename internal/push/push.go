@@ -0,0 +1,92 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/google/uuid"
+)
+
+// Platforms stored in the device_tokens table
+const (
+	PlatformFCM  = "fcm"
+	PlatformAPNs = "apns"
+)
+
+// Kinds of notifications sent to users
+const (
+	KindReminder      = "reminder"
+	KindGoalMilestone = "goal_milestone"
+	KindNewColumn     = "new_column"
+)
+
+// ErrInvalidToken is returned when a push service reports a device token as permanently
+// invalid, e.g. because the app was uninstalled. The token is unregistered.
+var ErrInvalidToken = errors.New("device token rejected by push service")
+
+// TransientError is returned for failures worth retrying, e.g. rate limits or outages of the push service
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return fmt.Sprintf("transient push error: %v", e.Err)
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// transient wraps err in a TransientError
+func transient(err error) error {
+	return &TransientError{Err: err}
+}
+
+// IsTransient reports whether delivery failed with a TransientError and may be retried
+func IsTransient(err error) bool {
+	var t *TransientError
+	return errors.As(err, &t)
+}
+
+// Message is the content of a push notification
+type Message struct {
+	Kind  string
+	Title string
+	Body  string
+	// Data is passed to the app along with the notification, e.g. the ID of a new column
+	Data map[string]string
+}
+
+// Sender delivers messages through a push service
+type Sender interface {
+	// Platform is the platform of the device tokens the sender delivers to
+	Platform() string
+	// Send delivers a message to a device. It returns ErrInvalidToken for tokens that will
+	// never be accepted again and a TransientError for failures worth retrying.
+	Send(ctx context.Context, token string, msg Message) error
+}
+
+// Notifier queues notifications for delivery by the Worker
+type Notifier struct {
+	repo  *repo.PushRepository
+	clock clock.Clock
+}
+
+// NewNotifier creates a notifier queueing to repo
+func NewNotifier(repo *repo.PushRepository, clock clock.Clock) *Notifier {
+	return &Notifier{
+		repo:  repo,
+		clock: clock,
+	}
+}
+
+// Notify queues a message for every device of a user. Users without devices are skipped silently.
+func (n *Notifier) Notify(ctx context.Context, userID uuid.UUID, msg Message) error {
+	if _, err := n.repo.Enqueue(ctx, userID, msg.Kind, msg.Title, msg.Body, msg.Data, n.clock.Now()); err != nil {
+		return fmt.Errorf("failed to notify user: %w", err)
+	}
+	return nil
+}
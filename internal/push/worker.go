@@ -0,0 +1,137 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+const (
+	// batchSize is how many notifications are claimed at once
+	batchSize = 100
+	// leaseDuration is how long claimed notifications are reserved for the delivering worker;
+	// notifications of a worker that stopped mid-batch are retried once it expires
+	leaseDuration = 5 * time.Minute
+	// maxAttempts is how many times delivery is attempted before a notification is marked failed
+	maxAttempts = 8
+	// initialBackoff is the delay before the first retry, doubled on every further retry
+	initialBackoff = 30 * time.Second
+	// maxBackoff caps the delay between retries
+	maxBackoff = time.Hour
+)
+
+// Worker delivers queued notifications through the configured senders
+type Worker struct {
+	repo     *repo.PushRepository
+	senders  map[string]Sender
+	interval time.Duration
+	log      *slog.Logger
+	clock    clock.Clock
+}
+
+// NewWorker creates a worker delivering through senders, polling for due notifications once per interval
+func NewWorker(repo *repo.PushRepository, senders []Sender, interval time.Duration, log *slog.Logger, clock clock.Clock) *Worker {
+	byPlatform := make(map[string]Sender, len(senders))
+	for _, s := range senders {
+		byPlatform[s.Platform()] = s
+	}
+	return &Worker{
+		repo:     repo,
+		senders:  byPlatform,
+		interval: interval,
+		log:      log,
+		clock:    clock,
+	}
+}
+
+// Run delivers due notifications once per interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.DeliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue delivers notifications until none are due. Notifications of platforms without
+// a sender stay queued.
+func (w *Worker) DeliverDue(ctx context.Context) {
+	platforms := make([]string, 0, len(w.senders))
+	for platform := range w.senders {
+		platforms = append(platforms, platform)
+	}
+
+	for ctx.Err() == nil {
+		now := w.clock.Now()
+		notifications, err := w.repo.ClaimDue(ctx, platforms, batchSize, now, now.Add(leaseDuration))
+		if err != nil {
+			w.log.ErrorContext(ctx, "Failed to claim push notifications", "error", err)
+			return
+		}
+		for _, n := range notifications {
+			w.deliver(ctx, n)
+		}
+		if len(notifications) < batchSize {
+			return
+		}
+	}
+}
+
+// deliver sends a claimed notification and records the outcome
+func (w *Worker) deliver(ctx context.Context, n repo.PushNotification) {
+	msg := Message{Kind: n.Kind, Title: n.Title, Body: n.Body}
+	if err := json.Unmarshal(n.Data, &msg.Data); err != nil {
+		w.fail(ctx, n, err)
+		return
+	}
+
+	err := w.senders[n.Platform].Send(ctx, n.Token, msg)
+	switch {
+	case err == nil:
+		if err := w.repo.MarkSent(ctx, n.ID, w.clock.Now()); err != nil {
+			w.log.ErrorContext(ctx, "Failed to mark push notification sent", "notificationID", n.ID, "error", err)
+		}
+	case errors.Is(err, ErrInvalidToken):
+		// Deleting the token also deletes its queued notifications
+		w.log.InfoContext(ctx, "Unregistering device rejected by push service", "deviceID", n.DeviceTokenID, "platform", n.Platform)
+		if err := w.repo.DeleteDevice(ctx, n.DeviceTokenID); err != nil {
+			w.log.ErrorContext(ctx, "Failed to delete device", "deviceID", n.DeviceTokenID, "error", err)
+		}
+	case IsTransient(err) && n.Attempts < maxAttempts:
+		nextAttemptAt := w.clock.Now().Add(backoff(n.Attempts))
+		w.log.WarnContext(ctx, "Push notification delivery failed, retrying", "notificationID", n.ID, "attempts", n.Attempts, "nextAttemptAt", nextAttemptAt, "error", err)
+		if err := w.repo.Reschedule(ctx, n.ID, nextAttemptAt, err.Error()); err != nil {
+			w.log.ErrorContext(ctx, "Failed to reschedule push notification", "notificationID", n.ID, "error", err)
+		}
+	default:
+		w.fail(ctx, n, err)
+	}
+}
+
+// fail gives up on a notification
+func (w *Worker) fail(ctx context.Context, n repo.PushNotification, err error) {
+	w.log.ErrorContext(ctx, "Push notification delivery failed", "notificationID", n.ID, "attempts", n.Attempts, "error", err)
+	if err := w.repo.MarkFailed(ctx, n.ID, err.Error()); err != nil {
+		w.log.ErrorContext(ctx, "Failed to mark push notification failed", "notificationID", n.ID, "error", err)
+	}
+}
+
+// backoff returns the delay before retrying a notification after its given number of attempts
+func backoff(attempts int32) time.Duration {
+	delay := initialBackoff
+	for i := int32(1); i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
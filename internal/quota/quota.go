@@ -0,0 +1,90 @@
+// Package quota enforces the daily request quota of users. Requests are counted per user and
+// UTC day; once a user made their quota of requests, their requests fail until the next day.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// ResetsAt returns the time the daily quota in effect at now resets: the start of the next UTC day
+func ResetsAt(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// Limit is the daily request quota of users. It can be changed while requests are counted, e.g.
+// when the configuration is reloaded.
+type Limit struct {
+	dailyRequests atomic.Int32
+}
+
+// NewLimit creates a limit of dailyRequests requests per UTC day; 0 for no limit
+func NewLimit(dailyRequests int32) *Limit {
+	l := &Limit{}
+	l.Set(dailyRequests)
+	return l
+}
+
+// DailyRequests returns the number of requests a user may make per UTC day; 0 for no limit
+func (l *Limit) DailyRequests() int32 {
+	return l.dailyRequests.Load()
+}
+
+// Set changes the number of requests a user may make per UTC day
+func (l *Limit) Set(dailyRequests int32) {
+	l.dailyRequests.Store(dailyRequests)
+}
+
+// Interceptor creates a Connect interceptor counting the requests of authenticated users and
+// rejecting them once the user made the limit of requests on the current UTC day. A limit of 0
+// counts requests without limiting them. Calls of the exempt procedures are neither counted nor
+// limited. It must run after the auth interceptor; unauthenticated calls are not counted. A
+// stream counts as one request, however many messages it sends.
+func Interceptor(usage *repo.APIUsageRepository, limit *Limit, exempt []string, logger *slog.Logger, clock clock.Clock) connect.Interceptor {
+	return interceptor.New(Check(usage, limit, exempt, logger, clock))
+}
+
+// Check creates the check of Interceptor, for requests served outside of Connect such as GraphQL
+// queries
+func Check(usage *repo.APIUsageRepository, limit *Limit, exempt []string, logger *slog.Logger, clock clock.Clock) interceptor.Check {
+	exempted := make(map[string]bool, len(exempt))
+	for _, procedure := range exempt {
+		exempted[procedure] = true
+	}
+	return func(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+		userID, err := auth.GetUserID(ctx)
+		if err != nil || exempted[procedure] {
+			return ctx, nil
+		}
+
+		now := clock.Now()
+		dailyQuota := limit.DailyRequests()
+		_, err = usage.Increment(ctx, userID, dailyQuota, now)
+		if errors.Is(err, repo.ErrQuotaExceeded) {
+			logger.WarnContext(ctx, "Daily request quota exceeded", "userID", userID, "quota", dailyQuota)
+			connectErr := apierror.New(connect.CodeResourceExhausted, apierror.ReasonQuotaExceeded, fmt.Errorf("daily quota of %d requests exceeded", dailyQuota))
+			retryAfter := math.Ceil(ResetsAt(now).Sub(now).Seconds())
+			connectErr.Meta().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+			return nil, connectErr
+		}
+		if err != nil {
+			// Quotas protect capacity, not data, so a failure to count doesn't fail the request
+			logger.ErrorContext(ctx, "Failed to count request against quota", "userID", userID, "error", err)
+		}
+		return ctx, nil
+	}
+}
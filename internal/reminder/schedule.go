@@ -0,0 +1,255 @@
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the time zone database, so user time zones resolve on hosts without one
+	_ "time/tzdata"
+)
+
+// maxLookahead bounds the search for the next occurrence of a schedule
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+// Schedule is a parsed 5-field cron expression: minute, hour, day of month, month and day
+// of week. Fields accept *, numbers, ranges (1-5), steps (*/15, 1-10/2) and lists of these.
+// Day of week is 0-6 starting on Sunday, 7 also being Sunday. As in cron, a day matches if
+// either the day of month or the day of week matches when both are restricted.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set for unrestricted (*) day fields
+	domAny, dowAny bool
+}
+
+// field describes the bounds of a cron field
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField = field{"minute", 0, 59}
+	hourField   = field{"hour", 0, 23}
+	domField    = field{"day of month", 1, 31}
+	monthField  = field{"month", 1, 12}
+	dowField    = field{"day of week", 0, 7}
+)
+
+// ParseSchedule parses a 5-field cron expression
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule must have 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	// Sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = strings.HasPrefix(fields[2], "*")
+	s.dowAny = strings.HasPrefix(fields[4], "*")
+	return &s, nil
+}
+
+// DailyAt returns the cron expression of a schedule firing every day at a time of day given as HH:MM
+func DailyAt(timeOfDay string) (string, error) {
+	t, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return "", fmt.Errorf("time of day must be HH:MM, got %q", timeOfDay)
+	}
+	return fmt.Sprintf("%d %d * * *", t.Minute(), t.Hour()), nil
+}
+
+// parseField parses a comma-separated cron field into a bit set of the matching values
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepExpr, f.name)
+			}
+			step = n
+		}
+
+		start, end := f.min, f.max
+		if rangeExpr != "*" {
+			lo, hi, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if start, err = parseValue(lo, f); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseValue(hi, f); err != nil {
+					return 0, err
+				}
+				if end < start {
+					return 0, fmt.Errorf("invalid range %q in %s field", rangeExpr, f.name)
+				}
+			} else if hasStep {
+				// "5/10" steps from 5 to the end of the field
+				end = f.max
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number within the bounds of a field
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %q", f.name, f.min, f.max, s)
+	}
+	return v, nil
+}
+
+// ErrNeverFires is returned for schedules without an occurrence, e.g. on February 30
+var ErrNeverFires = errors.New("schedule never fires")
+
+// Next returns the first occurrence of the schedule after t, evaluated in loc, or
+// ErrNeverFires. Times skipped by a daylight saving transition fire at the first instant after it.
+func (s *Schedule) Next(t time.Time, loc *time.Location) (time.Time, error) {
+	limit := t.Add(maxLookahead)
+	t = t.In(loc).Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case s.month&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			// t is on a whole minute, so this is the start of the next hour
+			next := t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			if skipped := s.skippedHour(t, next); skipped {
+				return next, nil
+			}
+			t = skipRepeated(t, next)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = skipRepeated(t, t.Add(time.Minute))
+		default:
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrNeverFires
+}
+
+// skippedHour reports whether a scheduled hour of the day is skipped by a forward daylight
+// saving transition between t and the start of the next hour
+func (s *Schedule) skippedHour(t, next time.Time) bool {
+	if next.Day() != t.Day() {
+		return false
+	}
+	for h := t.Hour() + 1; h < next.Hour(); h++ {
+		if s.hour&(1<<uint(h)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRepeated returns next, or the end of the repeated wall clock hour if a backward daylight
+// saving transition between t and next turned the clock back, so the hour doesn't fire twice
+func skipRepeated(t, next time.Time) time.Time {
+	wall := func(t time.Time) int { return t.Hour()*60 + t.Minute() }
+	if next.Day() == t.Day() && wall(next) <= wall(t) {
+		return next.Add(time.Duration(60-next.Minute()) * time.Minute)
+	}
+	return next
+}
+
+// matchesDay reports whether the day of t matches the day of month and day of week fields
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// LoadLocation loads an IANA time zone by name. Unlike time.LoadLocation it rejects "Local",
+// whose meaning depends on the server.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	return loc, nil
+}
+
+// NextFire returns the first occurrence after t of the cron expression in the named time zone
+func NextFire(expr, timezone string, t time.Time) (time.Time, error) {
+	s, err := ParseSchedule(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.Next(t, loc)
+}
+
+// CountFires returns the number of occurrences of the cron expression in the named time zone
+// from start (inclusive) to end (exclusive), counting at most max
+func CountFires(expr, timezone string, start, end time.Time, max int) (int, error) {
+	s, err := ParseSchedule(expr)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	// Next returns occurrences after t on whole minutes, so start itself can fire
+	t := start.Add(-time.Nanosecond)
+	for count < max {
+		next, err := s.Next(t, loc)
+		if err != nil || !next.Before(end) {
+			break // ErrNeverFires is the only error
+		}
+		count++
+		t = next
+	}
+	return count, nil
+}
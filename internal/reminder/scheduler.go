@@ -0,0 +1,104 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+)
+
+const (
+	// batchSize is how many reminders are claimed at once
+	batchSize = 100
+	// leaseDuration is how long claimed reminders are reserved for the firing scheduler;
+	// reminders of a scheduler that stopped mid-batch fire once it expires
+	leaseDuration = 5 * time.Minute
+)
+
+// Scheduler fires due reminders as push notifications
+type Scheduler struct {
+	repo     *repo.ReminderRepository
+	notifier *push.Notifier
+	interval time.Duration
+	log      *slog.Logger
+	clock    clock.Clock
+}
+
+// NewScheduler creates a scheduler notifying through notifier, polling for due reminders once per interval
+func NewScheduler(repo *repo.ReminderRepository, notifier *push.Notifier, interval time.Duration, log *slog.Logger, clock clock.Clock) *Scheduler {
+	return &Scheduler{
+		repo:     repo,
+		notifier: notifier,
+		interval: interval,
+		log:      log,
+		clock:    clock,
+	}
+}
+
+// Run fires due reminders once per interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.FireDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FireDue fires reminders until none are due
+func (s *Scheduler) FireDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := s.clock.Now()
+		reminders, err := s.repo.ClaimDue(ctx, batchSize, now, now.Add(leaseDuration))
+		if err != nil {
+			s.log.ErrorContext(ctx, "Failed to claim due reminders", "error", err)
+			return
+		}
+		for _, r := range reminders {
+			s.fire(ctx, r)
+		}
+		if len(reminders) < batchSize {
+			return
+		}
+	}
+}
+
+// fire notifies the owner of a claimed reminder and schedules its next occurrence. Occurrences
+// missed while the scheduler was down are skipped rather than fired in a burst.
+func (s *Scheduler) fire(ctx context.Context, r db.Reminder) {
+	err := s.notifier.Notify(ctx, r.UserID, push.Message{
+		Kind:  push.KindReminder,
+		Title: r.Title,
+		Body:  r.Message,
+		Data:  map[string]string{"reminder_id": r.ID.String()},
+	})
+	if err != nil {
+		// The reminder fires again once its lease expires
+		s.log.ErrorContext(ctx, "Failed to send reminder", "reminderID", r.ID, "error", err)
+		return
+	}
+
+	now := s.clock.Now()
+	var nextFireAt *time.Time
+	next, err := NextFire(r.Schedule, r.Timezone, now)
+	switch {
+	case err == nil:
+		nextFireAt = &next
+	case errors.Is(err, ErrNeverFires):
+	default:
+		s.log.ErrorContext(ctx, "Failed to schedule next reminder occurrence, it will not fire again", "reminderID", r.ID, "error", err)
+	}
+	if err := s.repo.MarkFired(ctx, r.ID, now, nextFireAt, r.NextFireAt.Time); err != nil {
+		s.log.ErrorContext(ctx, "Failed to mark reminder fired", "reminderID", r.ID, "error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrStreakNotFound is returned when a user has never logged a day of a streak kind
+var ErrStreakNotFound = errors.New("streak not found")
+
+// Kinds of streaks stored in streaks, which are maintained by database triggers
+const (
+	StreakKindLogging    = "logging" // Days with a body record or diary entry
+	StreakKindBodyRecord = "body_record"
+	StreakKindDiaryEntry = "diary_entry"
+)
+
+// AchievementRepository provides database operations for streaks and achievements
+type AchievementRepository struct {
+	q *db.Queries
+}
+
+// NewAchievementRepository creates a new PostgreSQL achievement repository
+func NewAchievementRepository(pool DB) *AchievementRepository {
+	return &AchievementRepository{
+		q: db.New(pool),
+	}
+}
+
+// Streaks retrieves the streaks of a user. Kinds the user never logged a day of are omitted.
+func (r *AchievementRepository) Streaks(ctx context.Context, userID uuid.UUID) ([]db.Streak, error) {
+	streaks, err := r.q.ListStreaksByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list streaks: %w", err)
+	}
+	return streaks, nil
+}
+
+// Streak retrieves a streak of a user, returning ErrStreakNotFound if the user never logged a
+// day of that kind
+func (r *AchievementRepository) Streak(ctx context.Context, userID uuid.UUID, kind string) (db.Streak, error) {
+	streak, err := r.q.GetStreakByUserAndKind(ctx, db.GetStreakByUserAndKindParams{
+		UserID: userID,
+		Kind:   kind,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Streak{}, ErrStreakNotFound
+		}
+		return db.Streak{}, fmt.Errorf("failed to get streak: %w", err)
+	}
+	return streak, nil
+}
+
+// FindByUser retrieves the badges awarded to a user, oldest first
+func (r *AchievementRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]db.Achievement, error) {
+	achievements, err := r.q.ListAchievementsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list achievements: %w", err)
+	}
+	return achievements, nil
+}
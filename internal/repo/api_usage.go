@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrQuotaExceeded is returned when a user has already made their daily quota of requests
+var ErrQuotaExceeded = errors.New("daily request quota exceeded")
+
+// APIUsageRepository provides database operations for the daily API usage of users
+type APIUsageRepository struct {
+	q *db.Queries
+}
+
+// NewAPIUsageRepository creates a new PostgreSQL API usage repository
+func NewAPIUsageRepository(pool DB) *APIUsageRepository {
+	return &APIUsageRepository{
+		q: db.New(pool),
+	}
+}
+
+// Increment counts a request of a user on the UTC day of now and returns the user's request
+// count of that day. When dailyQuota is positive and the user already made that many requests,
+// the request is not counted and ErrQuotaExceeded is returned.
+func (r *APIUsageRepository) Increment(ctx context.Context, userID uuid.UUID, dailyQuota int32, now time.Time) (int32, error) {
+	params := db.IncrementAPIUsageParams{
+		UserID: userID,
+		Day:    usageDay(now),
+	}
+	if dailyQuota > 0 {
+		params.DailyQuota = pgtype.Int4{Int32: dailyQuota, Valid: true}
+	}
+	count, err := r.q.IncrementAPIUsage(ctx, params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrQuotaExceeded
+		}
+		return 0, fmt.Errorf("failed to increment API usage: %w", err)
+	}
+	return count, nil
+}
+
+// FindByDay returns the request count of a user on the UTC day of now; 0 if they made none
+func (r *APIUsageRepository) FindByDay(ctx context.Context, userID uuid.UUID, now time.Time) (int32, error) {
+	count, err := r.q.GetAPIUsage(ctx, db.GetAPIUsageParams{
+		UserID: userID,
+		Day:    usageDay(now),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get API usage: %w", err)
+	}
+	return count, nil
+}
+
+// usageDay returns the UTC day of now
+func usageDay(now time.Time) pgtype.Date {
+	return pgtype.Date{Time: now.UTC().Truncate(24 * time.Hour), Valid: true}
+}
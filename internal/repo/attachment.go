@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrAttachmentNotFound is returned when an attachment is not found
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrBodyRecordNotFound is returned when a body record is not found
+	ErrBodyRecordNotFound = errors.New("body record not found")
+	// ErrAttachmentLimitExceeded is returned when a body record already has the maximum number
+	// of photos
+	ErrAttachmentLimitExceeded = errors.New("attachment limit exceeded")
+)
+
+// Statuses of attachments
+const (
+	AttachmentStatusPending = "pending" // Created, the file is not verified yet
+	AttachmentStatusReady   = "ready"
+)
+
+// PendingAttachmentWindow is how long a pending attachment can be completed after its creation.
+// It counts toward the photos of its record meanwhile; the trash job deletes it afterwards.
+const PendingAttachmentWindow = time.Hour
+
+// AttachmentRepository provides database operations for Attachment
+type AttachmentRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewAttachmentRepository creates a new PostgreSQL attachment repository
+func NewAttachmentRepository(pool DB) *AttachmentRepository {
+	return &AttachmentRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// AttachmentKey returns the storage key of an attachment of a user
+func AttachmentKey(userID, id uuid.UUID) string {
+	return fmt.Sprintf("users/%s/attachments/%s", userID, id)
+}
+
+// CreateForBodyRecord creates a pending attachment of a body record of the user, returning
+// ErrBodyRecordNotFound if the user has no such record and ErrAttachmentLimitExceeded if it
+// already has maxCount photos, ready or pending within PendingAttachmentWindow. The record is
+// locked while its photos are counted, so concurrent uploads can't exceed the limit.
+func (r *AttachmentRepository) CreateForBodyRecord(ctx context.Context, userID, bodyRecordID, id uuid.UUID, contentType string, size, maxCount int64, now time.Time) (db.Attachment, error) {
+	var attachment db.Attachment
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		if _, err := q.LockBodyRecordForAttachment(ctx, db.LockBodyRecordForAttachmentParams{
+			BodyRecordID: bodyRecordID,
+			UserID:       userID,
+		}); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrBodyRecordNotFound
+			}
+			return fmt.Errorf("failed to lock body record: %w", err)
+		}
+		count, err := q.CountLiveAttachmentsByBodyRecord(ctx, db.CountLiveAttachmentsByBodyRecordParams{
+			BodyRecordID:  bodyRecordID,
+			UserID:        userID,
+			PendingCutoff: now.Add(-PendingAttachmentWindow),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to count attachments: %w", err)
+		}
+		if count >= maxCount {
+			return ErrAttachmentLimitExceeded
+		}
+		attachment, err = q.CreateBodyRecordAttachment(ctx, db.CreateBodyRecordAttachmentParams{
+			ID:           id,
+			StorageKey:   AttachmentKey(userID, id),
+			ContentType:  contentType,
+			SizeBytes:    size,
+			Now:          now,
+			BodyRecordID: bodyRecordID,
+			UserID:       userID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrBodyRecordNotFound
+			}
+			return fmt.Errorf("failed to create attachment: %w", err)
+		}
+		return nil
+	})
+	return attachment, err
+}
+
+// CountByBodyRecord returns the number of attachments of a body record of the user, pending
+// ones included
+func (r *AttachmentRepository) CountByBodyRecord(ctx context.Context, bodyRecordID, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountAttachmentsByBodyRecord(ctx, db.CountAttachmentsByBodyRecordParams{
+		BodyRecordID: bodyRecordID,
+		UserID:       userID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count attachments: %w", err)
+	}
+	return count, nil
+}
+
+// FindByID retrieves an attachment of the user, returning ErrAttachmentNotFound if there is none
+func (r *AttachmentRepository) FindByID(ctx context.Context, id, userID uuid.UUID) (db.Attachment, error) {
+	attachment, err := r.q.GetAttachmentByID(ctx, db.GetAttachmentByIDParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Attachment{}, ErrAttachmentNotFound
+		}
+		return db.Attachment{}, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+// MarkReady marks an attachment of the user as uploaded and verified
+func (r *AttachmentRepository) MarkReady(ctx context.Context, id, userID uuid.UUID, now time.Time) (db.Attachment, error) {
+	attachment, err := r.q.MarkAttachmentReady(ctx, db.MarkAttachmentReadyParams{
+		ID:        id,
+		UserID:    userID,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Attachment{}, ErrAttachmentNotFound
+		}
+		return db.Attachment{}, fmt.Errorf("failed to mark attachment ready: %w", err)
+	}
+	return attachment, nil
+}
+
+// Delete moves an attachment of the user to the trash at now and returns it; its file is kept
+// until the trash is purged
+func (r *AttachmentRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) (db.Attachment, error) {
+	attachment, err := r.q.TrashAttachment(ctx, db.TrashAttachmentParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Attachment{}, ErrAttachmentNotFound
+		}
+		return db.Attachment{}, fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return db.Attachment(attachment), nil
+}
+
+// Discard deletes an attachment of the user for good, without moving it to the trash, e.g. a
+// rejected upload; its file is left to the caller
+func (r *AttachmentRepository) Discard(ctx context.Context, id, userID uuid.UUID) error {
+	deleted, err := r.q.DeleteAttachment(ctx, db.DeleteAttachmentParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to discard attachment: %w", err)
+	}
+	if deleted == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+// FindReadyByBodyRecords retrieves the ready attachments of body records, oldest first
+func (r *AttachmentRepository) FindReadyByBodyRecords(ctx context.Context, bodyRecordIDs []uuid.UUID) ([]db.Attachment, error) {
+	attachments, err := r.q.ListReadyAttachmentsByBodyRecords(ctx, bodyRecordIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	return attachments, nil
+}
@@ -2,49 +2,71 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // BodyRecordRepository provides database operations for BodyRecord
 type BodyRecordRepository struct {
-	q *db.Queries
+	pool   DB
+	q      *db.Queries
+	counts *CountCache
 }
 
 // NewBodyRecordRepository creates a new PostgreSQL body record repository
-func NewBodyRecordRepository(pool *pgxpool.Pool) *BodyRecordRepository { // Return exported type
+func NewBodyRecordRepository(pool DB) *BodyRecordRepository { // Return exported type
 	return &BodyRecordRepository{ // Use exported type
-		q: db.New(pool),
+		pool: pool,
+		q:    db.New(pool),
 	}
 }
 
+// NewCachedBodyRecordRepository creates a PostgreSQL body record repository counting records
+// through counts, which it keeps up to date with the records it creates and deletes
+func NewCachedBodyRecordRepository(pool DB, counts *CountCache) *BodyRecordRepository {
+	return &BodyRecordRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		counts: counts,
+	}
+}
+
+// BodyRecordUpsert is the outcome of saving the body record of a date
+type BodyRecordUpsert struct {
+	Record   db.BodyRecord
+	Created  bool           // Whether the date had no record yet
+	Previous *db.BodyRecord // The record before the update; nil if Created
+}
+
 // Save creates a new body record or updates an existing one based on UserID and Date
-// Accepts the current time to set created_at and updated_at.
-func (r *BodyRecordRepository) Save(ctx context.Context, userID uuid.UUID, date time.Time, weightKg *float64, bodyFatPercentage *float64, now time.Time) (db.BodyRecord, error) {
-	var weightVal, bodyFatVal pgtype.Numeric
-
-	// Convert *float64 to pgtype.Numeric by scanning from string
-	if weightKg != nil {
-		weightStr := fmt.Sprintf("%f", *weightKg)
-		if err := weightVal.Scan(weightStr); err != nil {
-			return db.BodyRecord{}, fmt.Errorf("failed to scan weight string '%s' into pgtype.Numeric: %w", weightStr, err)
-		}
-	} else {
-		weightVal = pgtype.Numeric{Valid: false}
+// Accepts the current time to set created_at and updated_at. An empty note clears the record's note.
+// The change is added to the record's history.
+func (r *BodyRecordRepository) Save(ctx context.Context, userID uuid.UUID, date time.Time, weightKg *float64, bodyFatPercentage *float64, note string, now time.Time) (db.BodyRecord, error) {
+	upsert, err := r.Upsert(ctx, userID, date, weightKg, bodyFatPercentage, note, now)
+	if err != nil {
+		return db.BodyRecord{}, err
 	}
+	return upsert.Record, nil
+}
 
-	if bodyFatPercentage != nil {
-		bodyFatStr := fmt.Sprintf("%f", *bodyFatPercentage)
-		if err := bodyFatVal.Scan(bodyFatStr); err != nil {
-			return db.BodyRecord{}, fmt.Errorf("failed to scan bodyFat string '%s' into pgtype.Numeric: %w", bodyFatStr, err)
-		}
-	} else {
-		bodyFatVal = pgtype.Numeric{Valid: false}
+// Upsert is like Save, but also reports whether the record was created and, if it was updated,
+// its previous values. The previous record is locked until the update, so concurrent saves of the
+// same date each see the values the other one replaced.
+func (r *BodyRecordRepository) Upsert(ctx context.Context, userID uuid.UUID, date time.Time, weightKg *float64, bodyFatPercentage *float64, note string, now time.Time) (BodyRecordUpsert, error) {
+	weightVal, err := toNumeric(weightKg)
+	if err != nil {
+		return BodyRecordUpsert{}, fmt.Errorf("failed to convert weight: %w", err)
+	}
+
+	bodyFatVal, err := toNumeric(bodyFatPercentage)
+	if err != nil {
+		return BodyRecordUpsert{}, fmt.Errorf("failed to convert body fat percentage: %w", err)
 	}
 
 	pgDate := pgtype.Date{Time: date, Valid: true}
@@ -56,16 +78,40 @@ func (r *BodyRecordRepository) Save(ctx context.Context, userID uuid.UUID, date
 		BodyFatPercentage: bodyFatVal,
 		CreatedAt:         now,
 		UpdatedAt:         now,
+		Note:              pgtype.Text{String: note, Valid: note != ""},
 	}
 
-	dbRecord, err := r.q.CreateBodyRecord(ctx, params)
+	var upsert BodyRecordUpsert
+	err = withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		previous, err := q.GetBodyRecordByUserDateForUpdate(ctx, db.GetBodyRecordByUserDateForUpdateParams{UserID: userID, Date: pgDate})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			upsert.Created = true
+		case err != nil:
+			return fmt.Errorf("failed to get previous body record: %w", err)
+		default:
+			upsert.Previous = &previous
+		}
+
+		upsert.Record, err = q.CreateBodyRecord(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to save body record: %w", err)
+		}
+
+		action := ChangeActionUpdated
+		if upsert.Created {
+			action = ChangeActionCreated
+		}
+		return recordChange(ctx, q, userID, EntityTypeBodyRecord, upsert.Record.ID, action, ChangeSourceUser, "", now)
+	})
 	if err != nil {
-		// Return zero value of db.BodyRecord on error
-		return db.BodyRecord{}, fmt.Errorf("failed to save body record: %w", err)
+		return BodyRecordUpsert{}, err
+	}
+	if upsert.Created {
+		r.counts.Invalidate(userID)
 	}
 
-	// Return generated struct directly
-	return dbRecord, nil
+	return upsert, nil
 }
 
 // FindByUser retrieves paginated body records for a user
@@ -85,15 +131,19 @@ func (r *BodyRecordRepository) FindByUser(ctx context.Context, userID uuid.UUID,
 	return dbRecords, nil
 }
 
-// FindByUserAndDateRange retrieves body records for a user within a specific date range
+// FindByUserAndDateRange retrieves body records for a user within a specific date range, oldest first
 func (r *BodyRecordRepository) FindByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]db.BodyRecord, error) {
-	pgStartDate := pgtype.Date{Time: startDate, Valid: true}
-	pgEndDate := pgtype.Date{Time: endDate, Valid: true}
+	return r.FindByUserAndDateRangeOrdered(ctx, userID, startDate, endDate, false)
+}
 
+// FindByUserAndDateRangeOrdered retrieves body records for a user within a specific date range,
+// oldest first or, with newestFirst, newest first
+func (r *BodyRecordRepository) FindByUserAndDateRangeOrdered(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, newestFirst bool) ([]db.BodyRecord, error) {
 	params := db.ListBodyRecordsByUserDateRangeParams{
-		UserID: userID,
-		Date:   pgStartDate,
-		Date_2: pgEndDate,
+		UserID:      userID,
+		StartDate:   pgtype.Date{Time: startDate, Valid: true},
+		EndDate:     pgtype.Date{Time: endDate, Valid: true},
+		NewestFirst: newestFirst,
 	}
 
 	dbRecords, err := r.q.ListBodyRecordsByUserDateRange(ctx, params)
@@ -105,12 +155,102 @@ func (r *BodyRecordRepository) FindByUserAndDateRange(ctx context.Context, userI
 	return dbRecords, nil
 }
 
-// CountByUser returns the total number of body records for a user
+// EachBatchByUserAndDateRange calls fn with the body records of a user within a date range, oldest
+// first, in batches of up to batchSize records read one after the other, so that no more than a
+// batch is held in memory however long the range. It stops at the first error of fn.
+func (r *BodyRecordRepository) EachBatchByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, batchSize int32, fn func([]db.BodyRecord) error) error {
+	for {
+		batch, err := r.q.ListBodyRecordsByUserDateRangeBatch(ctx, db.ListBodyRecordsByUserDateRangeBatchParams{
+			UserID:    userID,
+			StartDate: pgtype.Date{Time: startDate, Valid: true},
+			EndDate:   pgtype.Date{Time: endDate, Valid: true},
+			BatchSize: batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list body records batch: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < int(batchSize) {
+			return nil
+		}
+		startDate = batch[len(batch)-1].Date.Time.AddDate(0, 0, 1)
+	}
+}
+
+// CountByUserAndDateRange returns the number of body records of a user within a date range
+func (r *BodyRecordRepository) CountByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) (int64, error) {
+	count, err := r.q.CountBodyRecordsByUserDateRange(ctx, db.CountBodyRecordsByUserDateRangeParams{
+		UserID:    userID,
+		StartDate: pgtype.Date{Time: startDate, Valid: true},
+		EndDate:   pgtype.Date{Time: endDate, Valid: true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count body records by date range: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteByDateRange moves the body records of a user within a date range to the trash with their
+// photos, if there are expected records; otherwise it deletes nothing and returns
+// ErrRecordCountChanged. The deletions are added to the records' history. The files of the photos
+// are kept until the trash is purged.
+func (r *BodyRecordRepository) DeleteByDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, expected int64, now time.Time) error {
+	defer r.counts.Invalidate(userID)
+	return withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		deleted, err := q.TrashBodyRecordsByUserDateRange(ctx, db.TrashBodyRecordsByUserDateRangeParams{
+			UserID:    userID,
+			StartDate: pgtype.Date{Time: startDate, Valid: true},
+			EndDate:   pgtype.Date{Time: endDate, Valid: true},
+			DeletedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete body records by date range: %w", err)
+		}
+		// Rolled back, so records saved since they were counted are not deleted unseen
+		if int64(len(deleted)) != expected {
+			return ErrRecordCountChanged
+		}
+
+		for _, id := range deleted {
+			if err := recordChange(ctx, q, userID, EntityTypeBodyRecord, id, ChangeActionDeleted, ChangeSourceUser, "", now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountByUser returns the total number of body records for a user, from the count cache of the
+// repository if it has one
 func (r *BodyRecordRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	if count, ok := r.counts.Get(userID); ok {
+		return count, nil
+	}
+	generation := r.counts.Generation(userID)
 	count, err := r.q.CountBodyRecordsByUser(ctx, userID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count body records: %w", err)
 	}
+	r.counts.Put(userID, count, generation)
 
 	return count, nil
 }
+
+// toNumeric converts an optional float64 to pgtype.Numeric by scanning from its string form.
+// A nil value yields an invalid (NULL) Numeric.
+func toNumeric(v *float64) (pgtype.Numeric, error) {
+	var n pgtype.Numeric
+	if v == nil {
+		return pgtype.Numeric{Valid: false}, nil
+	}
+	s := fmt.Sprintf("%f", *v)
+	if err := n.Scan(s); err != nil {
+		return pgtype.Numeric{}, fmt.Errorf("failed to scan string '%s' into pgtype.Numeric: %w", s, err)
+	}
+	return n, nil
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ErrColumnNotFound is returned when a column is not found
@@ -22,7 +21,7 @@ type ColumnRepository struct {
 }
 
 // NewColumnRepository creates a new PostgreSQL column repository
-func NewColumnRepository(pool *pgxpool.Pool) *ColumnRepository { // Return exported type
+func NewColumnRepository(pool DB) *ColumnRepository { // Return exported type
 	return &ColumnRepository{ // Use exported type
 		q: db.New(pool),
 	}
@@ -135,3 +134,50 @@ func (r *ColumnRepository) CountByTag(ctx context.Context, tag string, now time.
 
 	return count, nil
 }
+
+// RecordRead counts a read of a column on the UTC day of now
+func (r *ColumnRepository) RecordRead(ctx context.Context, id uuid.UUID, now time.Time) error {
+	err := r.q.RecordColumnRead(ctx, db.RecordColumnReadParams{
+		ColumnID: id,
+		Day:      pgtype.Date{Time: now.UTC().Truncate(24 * time.Hour), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record column read: %w", err)
+	}
+	return nil
+}
+
+// ClaimUnannounced marks the earliest published column users were not notified of as announced
+// at now and returns it. It reports false if every published column was announced.
+func (r *ColumnRepository) ClaimUnannounced(ctx context.Context, now time.Time) (db.Column, bool, error) {
+	column, err := r.q.ClaimUnannouncedColumn(ctx, now)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Column{}, false, nil
+		}
+		return db.Column{}, false, fmt.Errorf("failed to claim unannounced column: %w", err)
+	}
+	return column, true, nil
+}
+
+// ReleaseAnnouncement undoes the claim of a column, so it is announced again by a later claim
+func (r *ColumnRepository) ReleaseAnnouncement(ctx context.Context, id uuid.UUID) error {
+	if err := r.q.ReleaseColumnAnnouncement(ctx, id); err != nil {
+		return fmt.Errorf("failed to release column announcement: %w", err)
+	}
+	return nil
+}
+
+// ListRecipients returns up to limit users opted in to the column digest of the category, ordered
+// by ID after the user ID after
+func (r *ColumnRepository) ListRecipients(ctx context.Context, category pgtype.Text, after uuid.UUID, limit int32) ([]db.ListColumnRecipientsRow, error) {
+	recipients, err := r.q.ListColumnRecipients(ctx, db.ListColumnRecipientsParams{
+		Category: category,
+		After:    after,
+		MaxUsers: limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list column recipients: %w", err)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ColumnDigestSettings are whether a user receives the weekly digest of new columns, and of
+// which categories
+type ColumnDigestSettings struct {
+	OptedIn    bool
+	Categories []string // Lowercase; empty for all categories
+	LastSentAt pgtype.Timestamptz
+}
+
+// ColumnDigestRepository provides database operations for the weekly digests of new columns
+type ColumnDigestRepository struct {
+	q *db.Queries
+}
+
+// NewColumnDigestRepository creates a new PostgreSQL column digest repository
+func NewColumnDigestRepository(pool DB) *ColumnDigestRepository {
+	return &ColumnDigestRepository{
+		q: db.New(pool),
+	}
+}
+
+// GetSettings returns the digest settings of a user
+func (r *ColumnDigestRepository) GetSettings(ctx context.Context, userID uuid.UUID) (ColumnDigestSettings, error) {
+	row, err := r.q.GetUserColumnDigest(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ColumnDigestSettings{}, ErrUserNotFound
+		}
+		return ColumnDigestSettings{}, fmt.Errorf("failed to get column digest settings: %w", err)
+	}
+	return ColumnDigestSettings{
+		OptedIn:    row.ColumnDigestOptIn,
+		Categories: row.ColumnDigestCategories,
+		LastSentAt: row.ColumnDigestSentAt,
+	}, nil
+}
+
+// SetSettings opts a user in to the digest of the given categories, compared case-insensitively,
+// or back out
+func (r *ColumnDigestRepository) SetSettings(ctx context.Context, userID uuid.UUID, optIn bool, categories []string) (ColumnDigestSettings, error) {
+	row, err := r.q.SetUserColumnDigest(ctx, db.SetUserColumnDigestParams{
+		ID:                     userID,
+		ColumnDigestOptIn:      optIn,
+		ColumnDigestCategories: lowercase(categories),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ColumnDigestSettings{}, ErrUserNotFound
+		}
+		return ColumnDigestSettings{}, fmt.Errorf("failed to set column digest settings: %w", err)
+	}
+	return ColumnDigestSettings{
+		OptedIn:    row.ColumnDigestOptIn,
+		Categories: row.ColumnDigestCategories,
+		LastSentAt: row.ColumnDigestSentAt,
+	}, nil
+}
+
+// Unsubscribe opts a user out of the digest, keeping their categories. It reports whether the
+// user was opted in.
+func (r *ColumnDigestRepository) Unsubscribe(ctx context.Context, userID uuid.UUID) (bool, error) {
+	rows, err := r.q.UnsubscribeUserColumnDigest(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unsubscribe from column digest: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// Claim marks the digest of an opted-in user with an email address who wasn't emailed since
+// dueBefore as sent at now. It returns false when no digest is due.
+func (r *ColumnDigestRepository) Claim(ctx context.Context, dueBefore, now time.Time) (db.ClaimColumnDigestRow, bool, error) {
+	digest, err := r.q.ClaimColumnDigest(ctx, db.ClaimColumnDigestParams{
+		DueBefore: dueBefore,
+		Now:       now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ClaimColumnDigestRow{}, false, nil
+		}
+		return db.ClaimColumnDigestRow{}, false, fmt.Errorf("failed to claim column digest: %w", err)
+	}
+	return digest, true, nil
+}
+
+// Release undoes the claim of a digest that could not be sent, restoring when the previous one
+// was sent
+func (r *ColumnDigestRepository) Release(ctx context.Context, digest db.ClaimColumnDigestRow) error {
+	err := r.q.ReleaseColumnDigest(ctx, db.ReleaseColumnDigestParams{
+		ID:             digest.ID,
+		PreviousSentAt: digest.PreviousSentAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release column digest: %w", err)
+	}
+	return nil
+}
+
+// ListNewColumns lists at most limit columns published after publishedAfter until
+// publishedUntil, newest first, in the given categories or in all if none are given
+func (r *ColumnDigestRepository) ListNewColumns(ctx context.Context, publishedAfter, publishedUntil time.Time, categories []string, limit int) ([]db.Column, error) {
+	columns, err := r.q.ListColumnsPublishedBetween(ctx, db.ListColumnsPublishedBetweenParams{
+		PublishedAfter: publishedAfter,
+		PublishedUntil: publishedUntil,
+		Categories:     lowercase(categories),
+		MaxColumns:     int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list new columns: %w", err)
+	}
+	return columns, nil
+}
+
+// lowercase returns the lowercase copies of values, never nil so they are stored as an empty
+// array
+func lowercase(values []string) []string {
+	lower := make([]string, len(values))
+	for i, v := range values {
+		lower[i] = strings.ToLower(v)
+	}
+	return lower
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/google/uuid"
+)
+
+// CountCache keeps the total record counts of users for a TTL, evicting the least recently used
+// counts once it holds its capacity, so paging through a list doesn't count the records again on
+// every page. Counts are evicted when the records of their user are created, deleted, imported,
+// restored from the trash or archived through the repositories using the cache; changes by other
+// instances show after the TTL. A nil cache caches nothing.
+//
+// A count is only cached if no eviction of its user happened since it was counted, as read with
+// Generation before counting, so a count racing a change isn't cached after the change evicted it.
+type CountCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	clock    clock.Clock
+	order    *list.List // of *cachedCount, most recently used first
+	byUser   map[uuid.UUID]*list.Element
+	// generations count the evictions of the users hashed to each stripe
+	generations [generationStripes]uint64
+}
+
+// generationStripes is the number of eviction counters users are hashed to; evicting a user makes
+// the counts of the others of their stripe racing the eviction uncached too
+const generationStripes = 256
+
+// cachedCount is the count of a user's records cached until expiresAt
+type cachedCount struct {
+	userID    uuid.UUID
+	count     int64
+	expiresAt time.Time
+}
+
+// NewCountCache creates a cache of the counts of up to capacity users, each kept for ttl
+func NewCountCache(capacity int, ttl time.Duration, clock clock.Clock) *CountCache {
+	return &CountCache{
+		capacity: capacity,
+		ttl:      ttl,
+		clock:    clock,
+		order:    list.New(),
+		byUser:   make(map[uuid.UUID]*list.Element),
+	}
+}
+
+// Get returns the cached count of the user, if it hasn't expired
+func (c *CountCache) Get(userID uuid.UUID) (int64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.byUser[userID]
+	if !ok {
+		return 0, false
+	}
+	entry := elem.Value.(*cachedCount)
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.remove(elem)
+		return 0, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.count, true
+}
+
+// Generation returns the number of evictions of the user, to pass to Put with the count then
+// counted
+func (c *CountCache) Generation(userID uuid.UUID) uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[stripe(userID)]
+}
+
+// Put caches the count of the user counted at the generation, evicting the least recently used
+// count if the cache is full. The count isn't cached if the user was evicted since.
+func (c *CountCache) Put(userID uuid.UUID, count int64, generation uint64) {
+	if c == nil || c.capacity <= 0 || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[stripe(userID)] != generation {
+		return
+	}
+	if elem, ok := c.byUser[userID]; ok {
+		c.remove(elem)
+	}
+	for c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+	c.byUser[userID] = c.order.PushFront(&cachedCount{userID: userID, count: count, expiresAt: c.clock.Now().Add(c.ttl)})
+}
+
+// Invalidate evicts the count of the user
+func (c *CountCache) Invalidate(userID uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[stripe(userID)]++
+	if elem, ok := c.byUser[userID]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of cached counts, including expired ones not evicted yet
+func (c *CountCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove evicts the count of elem; c.mu must be held
+func (c *CountCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*cachedCount)
+	delete(c.byUser, entry.userID)
+}
+
+// stripe returns the index of the eviction counter of the user
+func stripe(userID uuid.UUID) int {
+	return int(binary.LittleEndian.Uint64(userID[8:]) % generationStripes)
+}
+
+// CountCaches are the count caches of several repositories, evicted together by the repositories
+// changing records of several types. Nil caches in it cache nothing.
+type CountCaches []*CountCache
+
+// Invalidate evicts the count of the user from every cache
+func (cs CountCaches) Invalidate(userID uuid.UUID) {
+	for _, c := range cs {
+		c.Invalidate(userID)
+	}
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrDailyTargetsNotFound is returned when a user's daily targets have not been calculated
+	ErrDailyTargetsNotFound = errors.New("daily targets not found")
+	// ErrBodyWeightNotFound is returned when a user has no body record with a weight
+	ErrBodyWeightNotFound = errors.New("body weight not found")
+)
+
+// DailyTargetRepository provides database operations for the users' daily targets
+type DailyTargetRepository struct {
+	q *db.Queries
+}
+
+// NewDailyTargetRepository creates a new PostgreSQL daily target repository
+func NewDailyTargetRepository(pool DB) *DailyTargetRepository {
+	return &DailyTargetRepository{
+		q: db.New(pool),
+	}
+}
+
+// GetActivityLevel retrieves the activity level of a user
+func (r *DailyTargetRepository) GetActivityLevel(ctx context.Context, userID uuid.UUID) (string, error) {
+	level, err := r.q.GetUserActivityLevel(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("failed to get activity level: %w", err)
+	}
+	return level, nil
+}
+
+// SetActivityLevel replaces the activity level of a user
+func (r *DailyTargetRepository) SetActivityLevel(ctx context.Context, userID uuid.UUID, level string) (string, error) {
+	level, err := r.q.SetUserActivityLevel(ctx, db.SetUserActivityLevelParams{
+		ID:            userID,
+		ActivityLevel: level,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("failed to set activity level: %w", err)
+	}
+	return level, nil
+}
+
+// LatestWeight retrieves the weight and body fat percentage of a user's latest body record with
+// a weight, returning ErrBodyWeightNotFound if they have none
+func (r *DailyTargetRepository) LatestWeight(ctx context.Context, userID uuid.UUID) (db.GetLatestBodyWeightRow, error) {
+	row, err := r.q.GetLatestBodyWeight(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.GetLatestBodyWeightRow{}, ErrBodyWeightNotFound
+		}
+		return db.GetLatestBodyWeightRow{}, fmt.Errorf("failed to get latest body weight: %w", err)
+	}
+	return row, nil
+}
+
+// Find retrieves the daily targets of a user, returning ErrDailyTargetsNotFound if they have
+// not been calculated
+func (r *DailyTargetRepository) Find(ctx context.Context, userID uuid.UUID) (db.DailyTarget, error) {
+	targets, err := r.q.GetDailyTargets(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.DailyTarget{}, ErrDailyTargetsNotFound
+		}
+		return db.DailyTarget{}, fmt.Errorf("failed to get daily targets: %w", err)
+	}
+	return targets, nil
+}
+
+// Save replaces the daily targets of a user with those calculated at now from weightKg and
+// activityLevel
+func (r *DailyTargetRepository) Save(ctx context.Context, userID uuid.UUID, waterMl, calories int32, weightKg float64, activityLevel string, now time.Time) (db.DailyTarget, error) {
+	weight, err := toNumeric(&weightKg)
+	if err != nil {
+		return db.DailyTarget{}, fmt.Errorf("failed to convert weight: %w", err)
+	}
+	targets, err := r.q.UpsertDailyTargets(ctx, db.UpsertDailyTargetsParams{
+		UserID:        userID,
+		WaterMl:       waterMl,
+		Calories:      calories,
+		WeightKg:      weight,
+		ActivityLevel: activityLevel,
+		CalculatedAt:  now,
+	})
+	if err != nil {
+		return db.DailyTarget{}, fmt.Errorf("failed to save daily targets: %w", err)
+	}
+	return targets, nil
+}
+
+// LogWaterIntake records that a user drank amountMl of water at takenAt
+func (r *DailyTargetRepository) LogWaterIntake(ctx context.Context, userID uuid.UUID, amountMl int32, takenAt, now time.Time) (db.WaterIntake, error) {
+	intake, err := r.q.CreateWaterIntake(ctx, db.CreateWaterIntakeParams{
+		UserID:    userID,
+		AmountMl:  amountMl,
+		TakenAt:   takenAt.UTC(),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return db.WaterIntake{}, fmt.Errorf("failed to log water intake: %w", err)
+	}
+	return intake, nil
+}
+
+// WaterIntakeTotal returns the water in ml a user drank from start to end (exclusive)
+func (r *DailyTargetRepository) WaterIntakeTotal(ctx context.Context, userID uuid.UUID, start, end time.Time) (int64, error) {
+	total, err := r.q.SumWaterIntakeByUserRange(ctx, db.SumWaterIntakeByUserRangeParams{
+		UserID:    userID,
+		StartTime: start,
+		EndTime:   end,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum water intake: %w", err)
+	}
+	return total, nil
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrDataShareNotFound is returned when a data share is not found
+var ErrDataShareNotFound = errors.New("data share not found")
+
+// DataShareWithEmails is a data share with the email addresses of its owner and grantee, which
+// are empty for users without one
+type DataShareWithEmails struct {
+	db.DataShare
+	OwnerEmail   string
+	GranteeEmail string
+}
+
+// DataShareRepository provides database operations for DataShare
+type DataShareRepository struct {
+	q *db.Queries
+}
+
+// NewDataShareRepository creates a new PostgreSQL data share repository
+func NewDataShareRepository(pool DB) *DataShareRepository {
+	return &DataShareRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create grants the grantee read access to record types of the owner between startsAt and
+// expiresAt
+func (r *DataShareRepository) Create(ctx context.Context, ownerID, granteeID uuid.UUID, recordTypes []string, startsAt, expiresAt, now time.Time) (db.DataShare, error) {
+	share, err := r.q.CreateDataShare(ctx, db.CreateDataShareParams{
+		OwnerID:     ownerID,
+		GranteeID:   granteeID,
+		RecordTypes: recordTypes,
+		StartsAt:    startsAt,
+		ExpiresAt:   expiresAt,
+		Now:         now,
+	})
+	if err != nil {
+		return db.DataShare{}, fmt.Errorf("failed to create data share: %w", err)
+	}
+	return share, nil
+}
+
+// FindByOwner retrieves the shares granted by the owner that are not revoked or expired at now,
+// including those that start later, newest first
+func (r *DataShareRepository) FindByOwner(ctx context.Context, ownerID uuid.UUID, now time.Time) ([]DataShareWithEmails, error) {
+	rows, err := r.q.ListDataSharesByOwner(ctx, db.ListDataSharesByOwnerParams{
+		OwnerID: ownerID,
+		Now:     now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data shares by owner: %w", err)
+	}
+	shares := make([]DataShareWithEmails, len(rows))
+	for i, row := range rows {
+		shares[i] = withEmails(row.DataShare, row.OwnerEmail, row.GranteeEmail)
+	}
+	return shares, nil
+}
+
+// FindByGrantee retrieves the shares granted to the grantee that are in effect at now, newest
+// first
+func (r *DataShareRepository) FindByGrantee(ctx context.Context, granteeID uuid.UUID, now time.Time) ([]DataShareWithEmails, error) {
+	rows, err := r.q.ListDataSharesByGrantee(ctx, db.ListDataSharesByGranteeParams{
+		GranteeID: granteeID,
+		Now:       now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data shares by grantee: %w", err)
+	}
+	shares := make([]DataShareWithEmails, len(rows))
+	for i, row := range rows {
+		shares[i] = withEmails(row.DataShare, row.OwnerEmail, row.GranteeEmail)
+	}
+	return shares, nil
+}
+
+// Revoke ends a share granted by the owner at now, returning ErrDataShareNotFound if the owner
+// has no such share or it is already revoked
+func (r *DataShareRepository) Revoke(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error {
+	revoked, err := r.q.RevokeDataShare(ctx, db.RevokeDataShareParams{
+		ID:      id,
+		OwnerID: ownerID,
+		Now:     now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke data share: %w", err)
+	}
+	if revoked == 0 {
+		return ErrDataShareNotFound
+	}
+	return nil
+}
+
+// HasActive reports whether the owner shares the record type with the grantee at now
+func (r *DataShareRepository) HasActive(ctx context.Context, ownerID, granteeID uuid.UUID, recordType string, now time.Time) (bool, error) {
+	shared, err := r.q.HasActiveDataShare(ctx, db.HasActiveDataShareParams{
+		OwnerID:    ownerID,
+		GranteeID:  granteeID,
+		RecordType: recordType,
+		Now:        now,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check data share: %w", err)
+	}
+	return shared, nil
+}
+
+func withEmails(share db.DataShare, ownerEmail, granteeEmail pgtype.Text) DataShareWithEmails {
+	return DataShareWithEmails{
+		DataShare:    share,
+		OwnerEmail:   ownerEmail.String,
+		GranteeEmail: granteeEmail.String,
+	}
+}
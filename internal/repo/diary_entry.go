@@ -2,84 +2,293 @@ package repo
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/atreya2011/health-management-api/internal/crypto"
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ErrDiaryEntryNotFound is returned when a diary entry is not found
 var ErrDiaryEntryNotFound = errors.New("diary entry not found")
 
-// DiaryEntryRepository provides database operations for DiaryEntry
+// ErrDiaryEntryRevisionNotFound is returned when a revision of a diary entry is not found
+var ErrDiaryEntryRevisionNotFound = errors.New("diary entry revision not found")
+
+// ErrDiaryShareLinkNotFound is returned when a share link of a diary entry is not found, or is
+// revoked or expired
+var ErrDiaryShareLinkNotFound = errors.New("diary share link not found")
+
+// MaxDiaryEntryRevisions is how many previous versions are kept per entry; older ones are
+// deleted on updates
+const MaxDiaryEntryRevisions = 50
+
+// DiaryEntryRepository provides database operations for DiaryEntry. Titles and contents are
+// encrypted with its cipher when it has one; entries are always returned decrypted.
 type DiaryEntryRepository struct {
-	q *db.Queries
+	pool   DB
+	q      *db.Queries
+	cipher *crypto.Cipher
+	counts *CountCache
 }
 
-// NewDiaryEntryRepository creates a new PostgreSQL diary entry repository
-func NewDiaryEntryRepository(pool *pgxpool.Pool) *DiaryEntryRepository { // Return exported type
+// NewDiaryEntryRepository creates a new PostgreSQL diary entry repository. New and updated
+// entries are encrypted with cipher, or stored as plaintext if it is nil.
+func NewDiaryEntryRepository(pool DB, cipher *crypto.Cipher) *DiaryEntryRepository { // Return exported type
 	return &DiaryEntryRepository{ // Use exported type
-		q: db.New(pool),
+		pool:   pool,
+		q:      db.New(pool),
+		cipher: cipher,
+	}
+}
+
+// NewCachedDiaryEntryRepository creates a PostgreSQL diary entry repository like
+// NewDiaryEntryRepository, counting entries through counts, which it keeps up to date with the
+// entries it creates and deletes
+func NewCachedDiaryEntryRepository(pool DB, cipher *crypto.Cipher, counts *CountCache) *DiaryEntryRepository {
+	return &DiaryEntryRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		cipher: cipher,
+		counts: counts,
 	}
 }
 
 // Create creates a new diary entry, accepting the current time.
 func (r *DiaryEntryRepository) Create(ctx context.Context, userID uuid.UUID, title *string, content string, entryDate time.Time, now time.Time) (db.DiaryEntry, error) {
+	entry, _, err := r.create(ctx, userID, title, content, entryDate, time.Time{}, now)
+	return entry, err
+}
+
+// CreateUnlessDuplicate creates a new diary entry like Create, unless the user created one for
+// the same date with the same title and content since the given time. It returns that entry
+// instead, and whether it is a duplicate.
+func (r *DiaryEntryRepository) CreateUnlessDuplicate(ctx context.Context, userID uuid.UUID, title *string, content string, entryDate, since, now time.Time) (db.DiaryEntry, bool, error) {
+	return r.create(ctx, userID, title, content, entryDate, since, now)
+}
+
+// create creates a new diary entry, looking for a duplicate created since the given time first
+// unless it is zero
+func (r *DiaryEntryRepository) create(ctx context.Context, userID uuid.UUID, title *string, content string, entryDate, since, now time.Time) (db.DiaryEntry, bool, error) {
 	var titleVal pgtype.Text
 	if title != nil {
 		titleVal = pgtype.Text{String: *title, Valid: true}
 	}
 
 	pgDate := pgtype.Date{Time: entryDate, Valid: true}
+	contentHash := r.contentHash(titleVal, content)
 
+	sealedTitle, sealedContent, err := r.seal(titleVal, content)
+	if err != nil {
+		return db.DiaryEntry{}, false, err
+	}
 	params := db.CreateDiaryEntryParams{
-		UserID:    userID,
-		Title:     titleVal,
-		Content:   content,
-		EntryDate: pgDate,
-		CreatedAt: now,
-		UpdatedAt: now,
+		UserID:      userID,
+		Title:       sealedTitle,
+		Content:     sealedContent,
+		EntryDate:   pgDate,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Encrypted:   r.cipher != nil,
+		ContentHash: contentHash,
 	}
 
-	dbEntry, err := r.q.CreateDiaryEntry(ctx, params)
+	var dbEntry db.DiaryEntry
+	var duplicate bool
+	err = withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		if !since.IsZero() {
+			if err := q.LockDiaryEntryCreates(ctx, userID); err != nil {
+				return fmt.Errorf("failed to lock diary entry creates: %w", err)
+			}
+			existing, err := q.FindDuplicateDiaryEntry(ctx, db.FindDuplicateDiaryEntryParams{
+				UserID:       userID,
+				EntryDate:    pgDate,
+				ContentHash:  contentHash,
+				CreatedSince: since,
+			})
+			if err == nil {
+				dbEntry, duplicate = existing, true
+				return nil
+			}
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("failed to find duplicate diary entry: %w", err)
+			}
+		}
+
+		var err error
+		dbEntry, err = q.CreateDiaryEntry(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to create diary entry: %w", err)
+		}
+		return recordChange(ctx, q, userID, EntityTypeDiaryEntry, dbEntry.ID, ChangeActionCreated, ChangeSourceUser, "", now)
+	})
 	if err != nil {
-		return db.DiaryEntry{}, fmt.Errorf("failed to create diary entry: %w", err)
+		return db.DiaryEntry{}, false, err
+	}
+	if !duplicate {
+		r.counts.Invalidate(userID)
 	}
 
-	// Return generated struct directly
-	return dbEntry, nil
+	dbEntry, err = r.open(dbEntry)
+	return dbEntry, duplicate, err
 }
 
-// Update updates an existing diary entry, accepting the current time.
+// Update updates an existing diary entry, accepting the current time. The previous version is
+// kept as a revision.
 func (r *DiaryEntryRepository) Update(ctx context.Context, id, userID uuid.UUID, title *string, content string, now time.Time) (db.DiaryEntry, error) {
 	var titleVal pgtype.Text
 	if title != nil {
 		titleVal = pgtype.Text{String: *title, Valid: true}
 	}
 
+	titleVal, content, err := r.seal(titleVal, content)
+	if err != nil {
+		return db.DiaryEntry{}, err
+	}
 	params := db.UpdateDiaryEntryParams{
 		ID:        id,
 		Title:     titleVal,
 		Content:   content,
 		UserID:    userID, // Need UserID to ensure user owns the entry being updated
 		UpdatedAt: now,
+		Encrypted: r.cipher != nil,
+	}
+
+	var dbEntry db.DiaryEntry
+	err = withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var err error
+		dbEntry, err = updateWithRevision(ctx, q, params)
+		if err != nil {
+			return err
+		}
+		return recordChange(ctx, q, userID, EntityTypeDiaryEntry, dbEntry.ID, ChangeActionUpdated, ChangeSourceUser, "", now)
+	})
+	if err != nil {
+		return db.DiaryEntry{}, err
+	}
+
+	return r.open(dbEntry)
+}
+
+// FindRevisions retrieves paginated previous versions of a diary entry, newest first
+func (r *DiaryEntryRepository) FindRevisions(ctx context.Context, id, userID uuid.UUID, limit, offset int) ([]db.DiaryEntryRevision, error) {
+	revisions, err := r.q.ListDiaryEntryRevisions(ctx, db.ListDiaryEntryRevisionsParams{
+		DiaryEntryID: id,
+		UserID:       userID,
+		Limit:        int32(limit),
+		Offset:       int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list diary entry revisions: %w", err)
+	}
+
+	for i, revision := range revisions {
+		revisions[i].Title, revisions[i].Content, err = r.decrypt("diary entry revision "+revision.ID.String(), revision.Title, revision.Content, revision.Encrypted)
+		if err != nil {
+			return nil, err
+		}
+		revisions[i].Encrypted = false
+	}
+	return revisions, nil
+}
+
+// CountRevisions returns the number of previous versions kept of a diary entry
+func (r *DiaryEntryRepository) CountRevisions(ctx context.Context, id, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountDiaryEntryRevisions(ctx, db.CountDiaryEntryRevisionsParams{
+		DiaryEntryID: id,
+		UserID:       userID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count diary entry revisions: %w", err)
+	}
+	return count, nil
+}
+
+// RestoreRevision replaces the title and content of a diary entry with those of one of its
+// revisions, accepting the current time. Like an update, the replaced version is kept as a
+// revision, so restores can be undone.
+func (r *DiaryEntryRepository) RestoreRevision(ctx context.Context, id, revisionID, userID uuid.UUID, now time.Time) (db.DiaryEntry, error) {
+	var dbEntry db.DiaryEntry
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		revision, err := q.GetDiaryEntryRevision(ctx, db.GetDiaryEntryRevisionParams{
+			ID:           revisionID,
+			DiaryEntryID: id,
+			UserID:       userID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrDiaryEntryRevisionNotFound
+			}
+			return fmt.Errorf("failed to find diary entry revision: %w", err)
+		}
+
+		// Revisions are re-encrypted, as they may predate encryption
+		title, content, err := r.decrypt("diary entry revision "+revision.ID.String(), revision.Title, revision.Content, revision.Encrypted)
+		if err != nil {
+			return err
+		}
+		title, content, err = r.seal(title, content)
+		if err != nil {
+			return err
+		}
+
+		dbEntry, err = updateWithRevision(ctx, q, db.UpdateDiaryEntryParams{
+			ID:        id,
+			Title:     title,
+			Content:   content,
+			UserID:    userID,
+			UpdatedAt: now,
+			Encrypted: r.cipher != nil,
+		})
+		if err != nil {
+			return err
+		}
+		return recordChange(ctx, q, userID, EntityTypeDiaryEntry, id, ChangeActionUpdated, ChangeSourceUser, "restored revision "+revisionID.String(), now)
+	})
+	if err != nil {
+		return db.DiaryEntry{}, err
+	}
+
+	return r.open(dbEntry)
+}
+
+// updateWithRevision keeps the current version of an entry as a revision, deleting the oldest
+// revisions past MaxDiaryEntryRevisions, and updates the entry
+func updateWithRevision(ctx context.Context, q *db.Queries, params db.UpdateDiaryEntryParams) (db.DiaryEntry, error) {
+	copied, err := q.CreateDiaryEntryRevision(ctx, db.CreateDiaryEntryRevisionParams{
+		CreatedAt:    params.UpdatedAt,
+		DiaryEntryID: params.ID,
+		UserID:       params.UserID,
+	})
+	if err != nil {
+		return db.DiaryEntry{}, fmt.Errorf("failed to create diary entry revision: %w", err)
+	}
+	if copied == 0 {
+		return db.DiaryEntry{}, ErrDiaryEntryNotFound
 	}
 
-	dbEntry, err := r.q.UpdateDiaryEntry(ctx, params)
+	entry, err := q.UpdateDiaryEntry(ctx, params)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return db.DiaryEntry{}, ErrDiaryEntryNotFound // Return zero value and local error
+			return db.DiaryEntry{}, ErrDiaryEntryNotFound
 		}
-		return db.DiaryEntry{}, fmt.Errorf("failed to update diary entry: %w", err) // Use fmt.Errorf
+		return db.DiaryEntry{}, fmt.Errorf("failed to update diary entry: %w", err)
 	}
 
-	// Return generated struct directly
-	return dbEntry, nil
+	err = q.PruneDiaryEntryRevisions(ctx, db.PruneDiaryEntryRevisionsParams{
+		DiaryEntryID: params.ID,
+		Keep:         MaxDiaryEntryRevisions,
+	})
+	if err != nil {
+		return db.DiaryEntry{}, fmt.Errorf("failed to prune diary entry revisions: %w", err)
+	}
+	return entry, nil
 }
 
 // FindByID retrieves a diary entry by ID and user ID
@@ -97,8 +306,7 @@ func (r *DiaryEntryRepository) FindByID(ctx context.Context, id, userID uuid.UUI
 		return db.DiaryEntry{}, fmt.Errorf("failed to find diary entry: %w", err) // Use fmt.Errorf
 	}
 
-	// Return generated struct directly
-	return dbEntry, nil
+	return r.open(dbEntry)
 }
 
 // FindByUser retrieves paginated diary entries for a user
@@ -114,15 +322,41 @@ func (r *DiaryEntryRepository) FindByUser(ctx context.Context, userID uuid.UUID,
 		return nil, fmt.Errorf("failed to list diary entries: %w", err)
 	}
 
-	// Return generated structs directly
+	for i, entry := range dbEntries {
+		if dbEntries[i], err = r.open(entry); err != nil {
+			return nil, err
+		}
+	}
 	return dbEntries, nil
 }
 
-// Delete deletes a diary entry by ID and user ID
-func (r *DiaryEntryRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
-	params := db.DeleteDiaryEntryParams{
-		ID:     id,
-		UserID: userID,
+// FindByUserAndDateRange retrieves the diary entries of a user for the days from startDate to
+// endDate, oldest first
+func (r *DiaryEntryRepository) FindByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]db.DiaryEntry, error) {
+	dbEntries, err := r.q.ListDiaryEntriesByUserDateRange(ctx, db.ListDiaryEntriesByUserDateRangeParams{
+		UserID:    userID,
+		StartDate: pgtype.Date{Time: startDate, Valid: true},
+		EndDate:   pgtype.Date{Time: endDate, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list diary entries by date range: %w", err)
+	}
+
+	for i, entry := range dbEntries {
+		if dbEntries[i], err = r.open(entry); err != nil {
+			return nil, err
+		}
+	}
+	return dbEntries, nil
+}
+
+// Delete moves a diary entry by ID and user ID to the trash with its revisions, accepting the
+// current time for its history; its share links are deleted
+func (r *DiaryEntryRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	params := db.TrashDiaryEntryParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
 	}
 
 	// 1. Check if the entry exists and belongs to the user *before* deleting.
@@ -137,23 +371,211 @@ func (r *DiaryEntryRepository) Delete(ctx context.Context, id, userID uuid.UUID)
 	}
 
 	// 2. Entry exists, proceed with deletion.
-	// We assume the sqlc generated DeleteDiaryEntry only returns error.
-	err = r.q.DeleteDiaryEntry(ctx, params)
+	defer r.counts.Invalidate(userID)
+	return withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		_, err := q.TrashDiaryEntry(ctx, params)
+		if err != nil {
+			// We don't expect ErrNoRows here anymore because we checked existence first.
+			// Any error here is likely a real database issue.
+			return fmt.Errorf("failed to execute delete diary entry query: %w", err)
+		}
+		return recordChange(ctx, q, userID, EntityTypeDiaryEntry, id, ChangeActionDeleted, ChangeSourceUser, "", now)
+	})
+}
+
+// CreateShareLink creates a link to a diary entry of the user expiring at expiresAt, accepting
+// the current time
+func (r *DiaryEntryRepository) CreateShareLink(ctx context.Context, entryID, userID uuid.UUID, expiresAt, now time.Time) (db.DiaryShareLink, error) {
+	link, err := r.q.CreateDiaryShareLink(ctx, db.CreateDiaryShareLinkParams{
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+		DiaryEntryID: entryID,
+		UserID:       userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.DiaryShareLink{}, ErrDiaryEntryNotFound
+		}
+		return db.DiaryShareLink{}, fmt.Errorf("failed to create diary share link: %w", err)
+	}
+	return link, nil
+}
+
+// RevokeShareLink revokes a share link of the user, which stops working immediately
+func (r *DiaryEntryRepository) RevokeShareLink(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	revoked, err := r.q.RevokeDiaryShareLink(ctx, db.RevokeDiaryShareLinkParams{
+		ID:     id,
+		UserID: userID,
+		Now:    now,
+	})
 	if err != nil {
-		// We don't expect ErrNoRows here anymore because we checked existence first.
-		// Any error here is likely a real database issue.
-		return fmt.Errorf("failed to execute delete diary entry query: %w", err)
+		return fmt.Errorf("failed to revoke diary share link: %w", err)
 	}
+	if revoked == 0 {
+		return ErrDiaryShareLinkNotFound
+	}
+	return nil
+}
 
-	return nil // Deletion successful
+// FindShared returns the decrypted entry of a share link in effect at now, and when the link
+// expires
+func (r *DiaryEntryRepository) FindShared(ctx context.Context, linkID uuid.UUID, now time.Time) (db.DiaryEntry, time.Time, error) {
+	row, err := r.q.GetSharedDiaryEntry(ctx, db.GetSharedDiaryEntryParams{
+		ID:  linkID,
+		Now: now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.DiaryEntry{}, time.Time{}, ErrDiaryShareLinkNotFound
+		}
+		return db.DiaryEntry{}, time.Time{}, fmt.Errorf("failed to find shared diary entry: %w", err)
+	}
+	entry, err := r.open(row.DiaryEntry)
+	if err != nil {
+		return db.DiaryEntry{}, time.Time{}, err
+	}
+	return entry, row.LinkExpiresAt, nil
 }
 
-// CountByUser returns the total number of diary entries for a user
+// CountByUser returns the total number of diary entries for a user, from the count cache of the
+// repository if it has one
 func (r *DiaryEntryRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	if count, ok := r.counts.Get(userID); ok {
+		return count, nil
+	}
+	generation := r.counts.Generation(userID)
 	count, err := r.q.CountDiaryEntriesByUser(ctx, userID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count diary entries: %w", err)
 	}
+	r.counts.Put(userID, count, generation)
 
 	return count, nil
 }
+
+// EncryptPlaintext encrypts up to batchSize entries and revisions stored as plaintext, returning
+// how many it encrypted. Entries are locked while they are encrypted, so concurrent calls encrypt
+// different entries.
+func (r *DiaryEntryRepository) EncryptPlaintext(ctx context.Context, batchSize int) (int, error) {
+	if r.cipher == nil {
+		return 0, errors.New("diary encryption is not configured")
+	}
+
+	var encrypted int
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		entries, err := q.ListPlaintextDiaryEntriesForUpdate(ctx, int32(batchSize))
+		if err != nil {
+			return fmt.Errorf("failed to list plaintext diary entries: %w", err)
+		}
+		for _, entry := range entries {
+			title, content, err := r.seal(entry.Title, entry.Content)
+			if err != nil {
+				return err
+			}
+			err = q.SetDiaryEntryCiphertext(ctx, db.SetDiaryEntryCiphertextParams{
+				ID:      entry.ID,
+				Title:   title,
+				Content: content,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encrypt diary entry %s: %w", entry.ID, err)
+			}
+		}
+		encrypted = len(entries)
+		if encrypted == batchSize {
+			return nil
+		}
+
+		revisions, err := q.ListPlaintextDiaryEntryRevisionsForUpdate(ctx, int32(batchSize-encrypted))
+		if err != nil {
+			return fmt.Errorf("failed to list plaintext diary entry revisions: %w", err)
+		}
+		for _, revision := range revisions {
+			title, content, err := r.seal(revision.Title, revision.Content)
+			if err != nil {
+				return err
+			}
+			err = q.SetDiaryEntryRevisionCiphertext(ctx, db.SetDiaryEntryRevisionCiphertextParams{
+				ID:      revision.ID,
+				Title:   title,
+				Content: content,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to encrypt diary entry revision %s: %w", revision.ID, err)
+			}
+		}
+		encrypted += len(revisions)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return encrypted, nil
+}
+
+// contentHash returns the hash of a title and content identifying duplicate entries. It is keyed
+// by the cipher when the repository has one, so hashes of encrypted entries don't reveal them.
+func (r *DiaryEntryRepository) contentHash(title pgtype.Text, content string) []byte {
+	// Titles are length-prefixed, so moving text between the title and content changes the hash
+	data := "-" + content
+	if title.Valid {
+		data = strconv.Itoa(len(title.String)) + ":" + title.String + content
+	}
+	if r.cipher != nil {
+		return r.cipher.Hash(data)
+	}
+	sum := sha256.Sum256([]byte(data))
+	return sum[:]
+}
+
+// seal encrypts the title and content of an entry to store, unless the repository has no cipher
+func (r *DiaryEntryRepository) seal(title pgtype.Text, content string) (pgtype.Text, string, error) {
+	if r.cipher == nil {
+		return title, content, nil
+	}
+	if title.Valid {
+		sealed, err := r.cipher.Encrypt(title.String)
+		if err != nil {
+			return pgtype.Text{}, "", fmt.Errorf("failed to encrypt diary entry title: %w", err)
+		}
+		title.String = sealed
+	}
+	sealed, err := r.cipher.Encrypt(content)
+	if err != nil {
+		return pgtype.Text{}, "", fmt.Errorf("failed to encrypt diary entry content: %w", err)
+	}
+	return title, sealed, nil
+}
+
+// open decrypts the title and content of a stored entry; plaintext entries are returned as is
+func (r *DiaryEntryRepository) open(entry db.DiaryEntry) (db.DiaryEntry, error) {
+	var err error
+	entry.Title, entry.Content, err = r.decrypt("diary entry "+entry.ID.String(), entry.Title, entry.Content, entry.Encrypted)
+	if err != nil {
+		return db.DiaryEntry{}, err
+	}
+	return entry, nil
+}
+
+// decrypt decrypts the title and content of a stored entry or revision, named by name in errors,
+// if they are encrypted
+func (r *DiaryEntryRepository) decrypt(name string, title pgtype.Text, content string, encrypted bool) (pgtype.Text, string, error) {
+	if !encrypted {
+		return title, content, nil
+	}
+	if r.cipher == nil {
+		return pgtype.Text{}, "", fmt.Errorf("%s is encrypted but diary encryption is not configured", name)
+	}
+	if title.Valid {
+		plaintext, err := r.cipher.Decrypt(title.String)
+		if err != nil {
+			return pgtype.Text{}, "", fmt.Errorf("failed to decrypt %s title: %w", name, err)
+		}
+		title.String = plaintext
+	}
+	plaintext, err := r.cipher.Decrypt(content)
+	if err != nil {
+		return pgtype.Text{}, "", fmt.Errorf("failed to decrypt %s content: %w", name, err)
+	}
+	return title, plaintext, nil
+}
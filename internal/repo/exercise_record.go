@@ -4,32 +4,68 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ErrExerciseRecordNotFound is returned when an exercise record is not found
 var ErrExerciseRecordNotFound = errors.New("exercise record not found")
 
+// Intensities stored in exercise_records
+const (
+	ExerciseIntensityLight    = "light"
+	ExerciseIntensityModerate = "moderate"
+	ExerciseIntensityVigorous = "vigorous"
+)
+
+// ExerciseEffort is the optional perceived effort of an exercise session
+type ExerciseEffort struct {
+	RPE       *int32 // Session rating of perceived exertion, 1-10 (CR-10 scale)
+	Intensity string // One of the ExerciseIntensity constants; empty if not rated
+}
+
+// params returns the nullable columns of the effort
+func (e ExerciseEffort) params() (pgtype.Int2, pgtype.Text) {
+	var rpe pgtype.Int2
+	if e.RPE != nil {
+		rpe = pgtype.Int2{Int16: int16(*e.RPE), Valid: true}
+	}
+	return rpe, pgtype.Text{String: e.Intensity, Valid: e.Intensity != ""}
+}
+
 // ExerciseRecordRepository provides database operations for ExerciseRecord
 type ExerciseRecordRepository struct {
-	q *db.Queries
+	pool   DB
+	q      *db.Queries
+	counts *CountCache
 }
 
 // NewExerciseRecordRepository creates a new PostgreSQL exercise record repository
-func NewExerciseRecordRepository(pool *pgxpool.Pool) *ExerciseRecordRepository { // Return exported type
+func NewExerciseRecordRepository(pool DB) *ExerciseRecordRepository { // Return exported type
 	return &ExerciseRecordRepository{ // Use exported type
-		q: db.New(pool),
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// NewCachedExerciseRecordRepository creates a PostgreSQL exercise record repository counting
+// records through counts, which it keeps up to date with the records it creates, merges and deletes
+func NewCachedExerciseRecordRepository(pool DB, counts *CountCache) *ExerciseRecordRepository {
+	return &ExerciseRecordRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		counts: counts,
 	}
 }
 
-// Create creates a new exercise record, accepting the current time.
-func (r *ExerciseRecordRepository) Create(ctx context.Context, userID uuid.UUID, exerciseName string, durationMinutes *int32, caloriesBurned *int32, recordedAt time.Time, now time.Time) (db.ExerciseRecord, error) {
+// Create creates a new exercise record, accepting the current time. The change is added to the record's history.
+// startedAt and endedAt are optional but must be provided together.
+func (r *ExerciseRecordRepository) Create(ctx context.Context, userID uuid.UUID, exerciseName string, durationMinutes *int32, caloriesBurned *int32, recordedAt time.Time, startedAt, endedAt *time.Time, effort ExerciseEffort, now time.Time) (db.ExerciseRecord, error) {
 	var durationMinutesVal, caloriesBurnedVal pgtype.Int4
 
 	if durationMinutes != nil {
@@ -42,6 +78,13 @@ func (r *ExerciseRecordRepository) Create(ctx context.Context, userID uuid.UUID,
 
 	recordedAtUTC := recordedAt.UTC() // Ensure UTC
 
+	var startedAtVal, endedAtVal pgtype.Timestamptz
+	if startedAt != nil && endedAt != nil {
+		startedAtVal = pgtype.Timestamptz{Time: startedAt.UTC(), Valid: true}
+		endedAtVal = pgtype.Timestamptz{Time: endedAt.UTC(), Valid: true}
+	}
+	rpeVal, intensityVal := effort.params()
+
 	params := db.CreateExerciseRecordParams{
 		UserID:          userID,
 		ExerciseName:    exerciseName,
@@ -50,12 +93,25 @@ func (r *ExerciseRecordRepository) Create(ctx context.Context, userID uuid.UUID,
 		RecordedAt:      recordedAtUTC,
 		CreatedAt:       now,
 		UpdatedAt:       now,
+		StartedAt:       startedAtVal,
+		EndedAt:         endedAtVal,
+		Rpe:             rpeVal,
+		Intensity:       intensityVal,
 	}
 
-	dbRecord, err := r.q.CreateExerciseRecord(ctx, params)
+	var dbRecord db.ExerciseRecord
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var err error
+		dbRecord, err = q.CreateExerciseRecord(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to create exercise record: %w", err)
+		}
+		return recordChange(ctx, q, userID, EntityTypeExerciseRecord, dbRecord.ID, ChangeActionCreated, ChangeSourceUser, "", now)
+	})
 	if err != nil {
-		return db.ExerciseRecord{}, fmt.Errorf("failed to create exercise record: %w", err)
+		return db.ExerciseRecord{}, err
 	}
+	r.counts.Invalidate(userID)
 
 	// Return generated struct directly
 	return dbRecord, nil
@@ -78,31 +134,262 @@ func (r *ExerciseRecordRepository) FindByUser(ctx context.Context, userID uuid.U
 	return dbRecords, nil
 }
 
-// Delete deletes an exercise record by ID and user ID
-func (r *ExerciseRecordRepository) Delete(ctx context.Context, id, userID uuid.UUID) error {
-	params := db.DeleteExerciseRecordParams{
-		ID:     id,
+// FindOverlapping retrieves the user's exercise records whose start/end range intersects [start, end)
+func (r *ExerciseRecordRepository) FindOverlapping(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ExerciseRecord, error) {
+	params := db.ListOverlappingExerciseRecordsParams{
+		UserID:     userID,
+		RangeStart: start.UTC(),
+		RangeEnd:   end.UTC(),
+	}
+
+	dbRecords, err := r.q.ListOverlappingExerciseRecords(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overlapping exercise records: %w", err)
+	}
+
+	return dbRecords, nil
+}
+
+// Merge combines the user's records with the given IDs into one record, accepting the current time.
+// The record with the most populated fields is kept and its missing fields are filled from the others,
+// which are deleted. Imported samples of the deleted records are pointed at the kept record.
+// Returns ErrExerciseRecordNotFound if any ID does not belong to the user.
+func (r *ExerciseRecordRepository) Merge(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, now time.Time) (db.ExerciseRecord, []uuid.UUID, error) {
+	defer r.counts.Invalidate(userID)
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return db.ExerciseRecord{}, nil, fmt.Errorf("failed to begin merge transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // Rollback after Commit is a no-op
+
+	q := r.q.WithTx(tx)
+
+	records, err := q.ListExerciseRecordsByIDsForUpdate(ctx, db.ListExerciseRecordsByIDsForUpdateParams{
+		UserID: userID,
+		Ids:    ids,
+	})
+	if err != nil {
+		return db.ExerciseRecord{}, nil, fmt.Errorf("failed to get exercise records to merge: %w", err)
+	}
+	if len(records) != len(ids) {
+		return db.ExerciseRecord{}, nil, ErrExerciseRecordNotFound
+	}
+
+	merged, removedIDs := mergeExerciseRecords(records)
+	updated, err := q.UpdateExerciseRecord(ctx, db.UpdateExerciseRecordParams{
+		ID:              merged.ID,
+		UserID:          userID,
+		ExerciseName:    merged.ExerciseName,
+		DurationMinutes: merged.DurationMinutes,
+		CaloriesBurned:  merged.CaloriesBurned,
+		RecordedAt:      merged.RecordedAt,
+		StartedAt:       merged.StartedAt,
+		EndedAt:         merged.EndedAt,
+		UpdatedAt:       now,
+		Rpe:             merged.Rpe,
+		Intensity:       merged.Intensity,
+	})
+	if err != nil {
+		return db.ExerciseRecord{}, nil, fmt.Errorf("failed to update merged exercise record: %w", err)
+	}
+
+	err = q.DeleteExerciseRecordsByIDs(ctx, db.DeleteExerciseRecordsByIDsParams{
 		UserID: userID,
+		Ids:    removedIDs,
+	})
+	if err != nil {
+		return db.ExerciseRecord{}, nil, fmt.Errorf("failed to delete merged exercise records: %w", err)
 	}
 
-	err := r.q.DeleteExerciseRecord(ctx, params)
+	err = q.ReassignImportedSamples(ctx, db.ReassignImportedSamplesParams{
+		RecordID:      updated.ID,
+		UserID:        userID,
+		FromRecordIds: removedIDs,
+	})
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			// If no rows were deleted (record not found or doesn't belong to user), return specific error.
-			return ErrExerciseRecordNotFound
+		return db.ExerciseRecord{}, nil, fmt.Errorf("failed to reassign imported samples: %w", err)
+	}
+
+	// Both sides of the merge stay visible in the history
+	removed := make([]string, len(removedIDs))
+	for i, id := range removedIDs {
+		removed[i] = id.String()
+		if err := recordChange(ctx, q, userID, EntityTypeExerciseRecord, id, ChangeActionMerged, ChangeSourceUser, "merged into "+updated.ID.String(), now); err != nil {
+			return db.ExerciseRecord{}, nil, err
 		}
-		return fmt.Errorf("failed to delete exercise record: %w", err)
+	}
+	if err := recordChange(ctx, q, userID, EntityTypeExerciseRecord, updated.ID, ChangeActionMerged, ChangeSourceUser, "merged with "+strings.Join(removed, ", "), now); err != nil {
+		return db.ExerciseRecord{}, nil, err
 	}
 
-	return nil
+	if err := tx.Commit(ctx); err != nil {
+		return db.ExerciseRecord{}, nil, fmt.Errorf("failed to commit merge transaction: %w", err)
+	}
+
+	return updated, removedIDs, nil
 }
 
-// CountByUser returns the total number of exercise records for a user
+// mergeExerciseRecords picks the richest record (oldest first on ties) and fills its missing
+// fields from the others, returning the merged record and the IDs of the records merged into it
+func mergeExerciseRecords(records []db.ExerciseRecord) (db.ExerciseRecord, []uuid.UUID) {
+	richness := func(r db.ExerciseRecord) int {
+		n := 0
+		for _, set := range []bool{r.DurationMinutes.Valid, r.CaloriesBurned.Valid, r.StartedAt.Valid} {
+			if set {
+				n++
+			}
+		}
+		return n
+	}
+
+	base := 0
+	for i, r := range records {
+		if richness(r) > richness(records[base]) {
+			base = i
+		}
+	}
+
+	merged := records[base]
+	removedIDs := make([]uuid.UUID, 0, len(records)-1)
+	for i, r := range records {
+		if i == base {
+			continue
+		}
+		removedIDs = append(removedIDs, r.ID)
+		if !merged.DurationMinutes.Valid {
+			merged.DurationMinutes = r.DurationMinutes
+		}
+		if !merged.CaloriesBurned.Valid {
+			merged.CaloriesBurned = r.CaloriesBurned
+		}
+		if !merged.StartedAt.Valid && r.StartedAt.Valid {
+			merged.StartedAt, merged.EndedAt = r.StartedAt, r.EndedAt
+		}
+		if !merged.Rpe.Valid {
+			merged.Rpe = r.Rpe
+		}
+		if !merged.Intensity.Valid {
+			merged.Intensity = r.Intensity
+		}
+	}
+
+	return merged, removedIDs
+}
+
+// Delete moves an exercise record by ID and user ID to the trash with its route, accepting the
+// current time for its history
+func (r *ExerciseRecordRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	params := db.TrashExerciseRecordParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	}
+
+	defer r.counts.Invalidate(userID)
+	return withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		deleted, err := q.TrashExerciseRecord(ctx, params)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				// If no rows were deleted (record not found or doesn't belong to user), return specific error.
+				return ErrExerciseRecordNotFound
+			}
+			return fmt.Errorf("failed to delete exercise record: %w", err)
+		}
+		if deleted == 0 {
+			return nil
+		}
+		return recordChange(ctx, q, userID, EntityTypeExerciseRecord, id, ChangeActionDeleted, ChangeSourceUser, "", now)
+	})
+}
+
+// CountByUserAndRange returns the number of exercise records of a user recorded in [start, end)
+func (r *ExerciseRecordRepository) CountByUserAndRange(ctx context.Context, userID uuid.UUID, start, end time.Time) (int64, error) {
+	count, err := r.q.CountExerciseRecordsByUserRange(ctx, db.CountExerciseRecordsByUserRangeParams{
+		UserID:     userID,
+		RangeStart: start,
+		RangeEnd:   end,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count exercise records by range: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteByRange moves the exercise records of a user recorded in [start, end) to the trash, with
+// their routes, if there are expected records; otherwise it deletes nothing and returns
+// ErrRecordCountChanged. The deletions are added to the records' history.
+func (r *ExerciseRecordRepository) DeleteByRange(ctx context.Context, userID uuid.UUID, start, end time.Time, expected int64, now time.Time) error {
+	defer r.counts.Invalidate(userID)
+	return withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		deleted, err := q.TrashExerciseRecordsByUserRange(ctx, db.TrashExerciseRecordsByUserRangeParams{
+			UserID:     userID,
+			RangeStart: start,
+			RangeEnd:   end,
+			DeletedAt:  now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete exercise records by range: %w", err)
+		}
+		// Rolled back, so records saved since they were counted are not deleted unseen
+		if int64(len(deleted)) != expected {
+			return ErrRecordCountChanged
+		}
+
+		for _, id := range deleted {
+			if err := recordChange(ctx, q, userID, EntityTypeExerciseRecord, id, ChangeActionDeleted, ChangeSourceUser, "", now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CountByUser returns the total number of exercise records for a user, from the count cache of the
+// repository if it has one
 func (r *ExerciseRecordRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	if count, ok := r.counts.Get(userID); ok {
+		return count, nil
+	}
+	generation := r.counts.Generation(userID)
 	count, err := r.q.CountExerciseRecordsByUser(ctx, userID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count exercise records: %w", err)
 	}
+	r.counts.Put(userID, count, generation)
 
 	return count, nil
 }
+
+// TotalsByUser returns the number of sessions and summed duration and calories of the user's
+// exercise records recorded in [start, end)
+func (r *ExerciseRecordRepository) TotalsByUser(ctx context.Context, userID uuid.UUID, start, end time.Time) (db.GetExerciseTotalsByUserRangeRow, error) {
+	params := db.GetExerciseTotalsByUserRangeParams{
+		UserID:     userID,
+		RangeStart: start.UTC(),
+		RangeEnd:   end.UTC(),
+	}
+
+	totals, err := r.q.GetExerciseTotalsByUserRange(ctx, params)
+	if err != nil {
+		return db.GetExerciseTotalsByUserRangeRow{}, fmt.Errorf("failed to get exercise totals: %w", err)
+	}
+
+	return totals, nil
+}
+
+// DailyTrainingLoads returns the session-RPE training load per UTC day of the user's exercise
+// records recorded in [start, end). Days without records are omitted.
+func (r *ExerciseRecordRepository) DailyTrainingLoads(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ListDailyTrainingLoadsByUserRangeRow, error) {
+	params := db.ListDailyTrainingLoadsByUserRangeParams{
+		UserID:     userID,
+		RangeStart: start.UTC(),
+		RangeEnd:   end.UTC(),
+	}
+
+	loads, err := r.q.ListDailyTrainingLoadsByUserRange(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily training loads: %w", err)
+	}
+
+	return loads, nil
+}
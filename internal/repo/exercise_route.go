@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrExerciseRouteNotFound is returned when an exercise record has no route
+var ErrExerciseRouteNotFound = errors.New("exercise route not found")
+
+// SetRoute attaches a route, a compressed encoded polyline of pointCount points, to a user's
+// exercise record, replacing any previous one and accepting the current time. Returns
+// ErrExerciseRecordNotFound if the user has no such record.
+func (r *ExerciseRecordRepository) SetRoute(ctx context.Context, recordID, userID uuid.UUID, polyline []byte, pointCount int32, distanceMeters float64, now time.Time) (db.ExerciseRoute, error) {
+	route, err := r.q.UpsertExerciseRoute(ctx, db.UpsertExerciseRouteParams{
+		Polyline:         polyline,
+		PointCount:       pointCount,
+		DistanceMeters:   distanceMeters,
+		Now:              now,
+		ExerciseRecordID: recordID,
+		UserID:           userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ExerciseRoute{}, ErrExerciseRecordNotFound
+		}
+		return db.ExerciseRoute{}, fmt.Errorf("failed to set exercise route: %w", err)
+	}
+	return route, nil
+}
+
+// FindRoute retrieves the route of a user's exercise record, returning ErrExerciseRouteNotFound
+// if the user has no such record or it has no route
+func (r *ExerciseRecordRepository) FindRoute(ctx context.Context, recordID, userID uuid.UUID) (db.ExerciseRoute, error) {
+	route, err := r.q.GetExerciseRoute(ctx, db.GetExerciseRouteParams{
+		ExerciseRecordID: recordID,
+		UserID:           userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ExerciseRoute{}, ErrExerciseRouteNotFound
+		}
+		return db.ExerciseRoute{}, fmt.Errorf("failed to get exercise route: %w", err)
+	}
+	return route, nil
+}
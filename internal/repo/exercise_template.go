@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrExerciseTemplateNotFound is returned when an exercise template is not found
+	ErrExerciseTemplateNotFound = errors.New("exercise template not found")
+	// ErrExerciseTemplateExists is returned when a user already has a template of the same name
+	ErrExerciseTemplateExists = errors.New("exercise template already exists")
+)
+
+// ExerciseTemplateFields are the user-editable fields of an exercise template
+type ExerciseTemplateFields struct {
+	Name            string
+	DurationMinutes *int32 // Optional
+	CaloriesBurned  *int32 // Optional
+	Intensity       string // One of the ExerciseIntensity constants; empty if not rated
+	Favorite        bool
+}
+
+// params returns the nullable columns of the fields
+func (f ExerciseTemplateFields) params() (pgtype.Int4, pgtype.Int4, pgtype.Text) {
+	var duration, calories pgtype.Int4
+	if f.DurationMinutes != nil {
+		duration = pgtype.Int4{Int32: *f.DurationMinutes, Valid: true}
+	}
+	if f.CaloriesBurned != nil {
+		calories = pgtype.Int4{Int32: *f.CaloriesBurned, Valid: true}
+	}
+	return duration, calories, pgtype.Text{String: f.Intensity, Valid: f.Intensity != ""}
+}
+
+// ExerciseTemplateRepository provides database operations for ExerciseTemplate
+type ExerciseTemplateRepository struct {
+	q *db.Queries
+}
+
+// NewExerciseTemplateRepository creates a new PostgreSQL exercise template repository
+func NewExerciseTemplateRepository(pool DB) *ExerciseTemplateRepository {
+	return &ExerciseTemplateRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create creates an exercise template, accepting the current time. Returns
+// ErrExerciseTemplateExists if the user has a template of the same name, ignoring case.
+func (r *ExerciseTemplateRepository) Create(ctx context.Context, userID uuid.UUID, fields ExerciseTemplateFields, now time.Time) (db.ExerciseTemplate, error) {
+	duration, calories, intensity := fields.params()
+	template, err := r.q.CreateExerciseTemplate(ctx, db.CreateExerciseTemplateParams{
+		UserID:          userID,
+		Name:            fields.Name,
+		DurationMinutes: duration,
+		CaloriesBurned:  calories,
+		Intensity:       intensity,
+		Favorite:        fields.Favorite,
+		CreatedAt:       now,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return db.ExerciseTemplate{}, ErrExerciseTemplateExists
+		}
+		return db.ExerciseTemplate{}, fmt.Errorf("failed to create exercise template: %w", err)
+	}
+	return template, nil
+}
+
+// FindByID retrieves a user's exercise template, returning ErrExerciseTemplateNotFound if the
+// user has no such template
+func (r *ExerciseTemplateRepository) FindByID(ctx context.Context, id, userID uuid.UUID) (db.ExerciseTemplate, error) {
+	template, err := r.q.GetExerciseTemplate(ctx, db.GetExerciseTemplateParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ExerciseTemplate{}, ErrExerciseTemplateNotFound
+		}
+		return db.ExerciseTemplate{}, fmt.Errorf("failed to get exercise template: %w", err)
+	}
+	return template, nil
+}
+
+// FindByUser retrieves the exercise templates of a user: favorites first, then the most
+// recently used, then by name
+func (r *ExerciseTemplateRepository) FindByUser(ctx context.Context, userID uuid.UUID, favoritesOnly bool) ([]db.ExerciseTemplate, error) {
+	templates, err := r.q.ListExerciseTemplatesByUser(ctx, db.ListExerciseTemplatesByUserParams{
+		UserID:        userID,
+		FavoritesOnly: favoritesOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list exercise templates: %w", err)
+	}
+	return templates, nil
+}
+
+// CountByUser returns the number of exercise templates of a user
+func (r *ExerciseTemplateRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountExerciseTemplatesByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count exercise templates: %w", err)
+	}
+	return count, nil
+}
+
+// Update replaces the fields of a user's exercise template, accepting the current time. Returns
+// ErrExerciseTemplateNotFound if the user has no such template, and ErrExerciseTemplateExists
+// if the new name is taken by another of their templates.
+func (r *ExerciseTemplateRepository) Update(ctx context.Context, id, userID uuid.UUID, fields ExerciseTemplateFields, now time.Time) (db.ExerciseTemplate, error) {
+	duration, calories, intensity := fields.params()
+	template, err := r.q.UpdateExerciseTemplate(ctx, db.UpdateExerciseTemplateParams{
+		ID:              id,
+		UserID:          userID,
+		Name:            fields.Name,
+		DurationMinutes: duration,
+		CaloriesBurned:  calories,
+		Intensity:       intensity,
+		Favorite:        fields.Favorite,
+		UpdatedAt:       now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ExerciseTemplate{}, ErrExerciseTemplateNotFound
+		}
+		if isUniqueViolation(err) {
+			return db.ExerciseTemplate{}, ErrExerciseTemplateExists
+		}
+		return db.ExerciseTemplate{}, fmt.Errorf("failed to update exercise template: %w", err)
+	}
+	return template, nil
+}
+
+// Delete moves a user's exercise template to the trash at now, returning
+// ErrExerciseTemplateNotFound if the user has no such template
+func (r *ExerciseTemplateRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashExerciseTemplate(ctx, db.TrashExerciseTemplateParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete exercise template: %w", err)
+	}
+	if deleted == 0 {
+		return ErrExerciseTemplateNotFound
+	}
+	return nil
+}
+
+// MarkUsed counts a record logged from an exercise template at usedAt
+func (r *ExerciseTemplateRepository) MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	err := r.q.MarkExerciseTemplateUsed(ctx, db.MarkExerciseTemplateUsedParams{
+		ID:     id,
+		UsedAt: usedAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark exercise template used: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrFastNotFound is returned when a fast is not found, or no fast is running
+	ErrFastNotFound = errors.New("fast not found")
+	// ErrFastOverlaps is returned when a fast would overlap another fast of the user
+	ErrFastOverlaps = errors.New("fast overlaps another fast")
+	// ErrFastEndsBeforeStart is returned when a fast would end before it started
+	ErrFastEndsBeforeStart = errors.New("fast ends before it started")
+)
+
+// FastRepository provides database operations for Fast
+type FastRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewFastRepository creates a new PostgreSQL fast repository
+func NewFastRepository(pool DB) *FastRepository {
+	return &FastRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// Start starts a fast of a user at startedAt with an optional target, accepting the current
+// time. Returns ErrFastOverlaps if a fast of the user is running or ended after startedAt.
+func (r *FastRepository) Start(ctx context.Context, userID uuid.UUID, startedAt time.Time, targetMinutes *int32, now time.Time) (db.Fast, error) {
+	var fast db.Fast
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		if err := q.LockFasts(ctx, userID); err != nil {
+			return fmt.Errorf("failed to lock fasts: %w", err)
+		}
+		overlapping, err := q.CountOverlappingFasts(ctx, db.CountOverlappingFastsParams{
+			UserID:    userID,
+			StartTime: startedAt.UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to count overlapping fasts: %w", err)
+		}
+		if overlapping > 0 {
+			return ErrFastOverlaps
+		}
+
+		var target pgtype.Int4
+		if targetMinutes != nil {
+			target = pgtype.Int4{Int32: *targetMinutes, Valid: true}
+		}
+		fast, err = q.CreateFast(ctx, db.CreateFastParams{
+			UserID:        userID,
+			StartedAt:     startedAt.UTC(),
+			TargetMinutes: target,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrFastOverlaps
+			}
+			return fmt.Errorf("failed to create fast: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return db.Fast{}, err
+	}
+	return fast, nil
+}
+
+// End ends the running fast of a user at endedAt, accepting the current time. Returns
+// ErrFastNotFound if no fast is running and ErrFastEndsBeforeStart if endedAt isn't after
+// its start.
+func (r *FastRepository) End(ctx context.Context, userID uuid.UUID, endedAt, now time.Time) (db.Fast, error) {
+	var fast db.Fast
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		if err := q.LockFasts(ctx, userID); err != nil {
+			return fmt.Errorf("failed to lock fasts: %w", err)
+		}
+		running, err := q.GetRunningFast(ctx, userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrFastNotFound
+			}
+			return fmt.Errorf("failed to get running fast: %w", err)
+		}
+		// No other fast can start after a running fast, so ending it never overlaps
+		if !endedAt.After(running.StartedAt) {
+			return ErrFastEndsBeforeStart
+		}
+
+		fast, err = q.EndFast(ctx, db.EndFastParams{
+			ID:        running.ID,
+			UserID:    userID,
+			EndedAt:   pgtype.Timestamptz{Time: endedAt.UTC(), Valid: true},
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to end fast: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return db.Fast{}, err
+	}
+	return fast, nil
+}
+
+// FindRunning retrieves the running fast of a user, returning ErrFastNotFound if none is running
+func (r *FastRepository) FindRunning(ctx context.Context, userID uuid.UUID) (db.Fast, error) {
+	fast, err := r.q.GetRunningFast(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Fast{}, ErrFastNotFound
+		}
+		return db.Fast{}, fmt.Errorf("failed to get running fast: %w", err)
+	}
+	return fast, nil
+}
+
+// FindByUser retrieves paginated fasts of a user, newest first
+func (r *FastRepository) FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.Fast, error) {
+	fasts, err := r.q.ListFastsByUser(ctx, db.ListFastsByUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fasts: %w", err)
+	}
+	return fasts, nil
+}
+
+// CountByUser returns the total number of fasts of a user
+func (r *FastRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountFastsByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count fasts: %w", err)
+	}
+	return count, nil
+}
+
+// Delete moves a user's fast to the trash at now, returning ErrFastNotFound if the user has no
+// such fast
+func (r *FastRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashFast(ctx, db.TrashFastParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete fast: %w", err)
+	}
+	if deleted == 0 {
+		return ErrFastNotFound
+	}
+	return nil
+}
+
+// Streak returns the fasting streak of a user: the run of consecutive UTC days with a fast
+// that ended reaching its target, up to the last such day, and the longest run
+func (r *FastRepository) Streak(ctx context.Context, userID uuid.UUID) (db.GetFastingStreakRow, error) {
+	streak, err := r.q.GetFastingStreak(ctx, userID)
+	if err != nil {
+		return db.GetFastingStreakRow{}, fmt.Errorf("failed to get fasting streak: %w", err)
+	}
+	return streak, nil
+}
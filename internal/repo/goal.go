@@ -0,0 +1,133 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrGoalsNotFound is returned when a user has never set goals
+var ErrGoalsNotFound = errors.New("goals not found")
+
+// GoalTargets are the goals of a user; nil goals are unset
+type GoalTargets struct {
+	TargetWeightKg        *float64
+	WeeklyExerciseMinutes *int32
+	WeeklyCaloriesBurned  *int32
+	WeeklyDiaryEntries    *int32
+}
+
+// GoalRepository provides database operations for Goal
+type GoalRepository struct {
+	q *db.Queries
+}
+
+// NewGoalRepository creates a new PostgreSQL goal repository
+func NewGoalRepository(pool DB) *GoalRepository {
+	return &GoalRepository{
+		q: db.New(pool),
+	}
+}
+
+// FindByUser retrieves the goals of a user, returning ErrGoalsNotFound if they never set any
+func (r *GoalRepository) FindByUser(ctx context.Context, userID uuid.UUID) (db.Goal, error) {
+	goals, err := r.q.GetGoalsByUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Goal{}, ErrGoalsNotFound
+		}
+		return db.Goal{}, fmt.Errorf("failed to get goals: %w", err)
+	}
+	return goals, nil
+}
+
+// Set replaces the goals of a user, accepting the current time
+func (r *GoalRepository) Set(ctx context.Context, userID uuid.UUID, targets GoalTargets, now time.Time) (db.Goal, error) {
+	targetWeightVal, err := toNumeric(targets.TargetWeightKg)
+	if err != nil {
+		return db.Goal{}, fmt.Errorf("failed to convert target weight: %w", err)
+	}
+
+	goals, err := r.q.UpsertGoals(ctx, db.UpsertGoalsParams{
+		UserID:                userID,
+		TargetWeightKg:        targetWeightVal,
+		WeeklyExerciseMinutes: toInt4(targets.WeeklyExerciseMinutes),
+		WeeklyCaloriesBurned:  toInt4(targets.WeeklyCaloriesBurned),
+		WeeklyDiaryEntries:    toInt4(targets.WeeklyDiaryEntries),
+		CreatedAt:             now,
+	})
+	if err != nil {
+		return db.Goal{}, fmt.Errorf("failed to set goals: %w", err)
+	}
+	return goals, nil
+}
+
+// WeeklySummary aggregates the user's records of the week starting at weekStart, along with
+// their goals, in a single query
+func (r *GoalRepository) WeeklySummary(ctx context.Context, userID uuid.UUID, weekStart time.Time) (db.GetWeeklySummaryRow, error) {
+	summary, err := r.q.GetWeeklySummary(ctx, db.GetWeeklySummaryParams{
+		UserID:    userID,
+		WeekStart: weekStart.UTC(),
+		WeekEnd:   weekStart.UTC().AddDate(0, 0, 7),
+	})
+	if err != nil {
+		return db.GetWeeklySummaryRow{}, fmt.Errorf("failed to get weekly summary: %w", err)
+	}
+	return summary, nil
+}
+
+// toInt4 converts an optional int32 to pgtype.Int4; nil yields NULL
+func toInt4(v *int32) pgtype.Int4 {
+	if v == nil {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: *v, Valid: true}
+}
+
+// ListChangedBetween returns the users with goals whose body records, exercise records or diary
+// entries changed after changedAfter until changedUntil
+func (r *GoalRepository) ListChangedBetween(ctx context.Context, changedAfter, changedUntil time.Time) ([]uuid.UUID, error) {
+	userIDs, err := r.q.ListUsersWithGoalChangesBetween(ctx, db.ListUsersWithGoalChangesBetweenParams{
+		ChangedAfter: changedAfter,
+		ChangedUntil: changedUntil,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users with goal changes: %w", err)
+	}
+	return userIDs, nil
+}
+
+// RecordMilestone records that a user reached a goal in the week starting at weekStart. It
+// reports false if the milestone was already recorded.
+func (r *GoalRepository) RecordMilestone(ctx context.Context, userID uuid.UUID, goal string, weekStart, now time.Time) (bool, error) {
+	rows, err := r.q.InsertGoalMilestone(ctx, db.InsertGoalMilestoneParams{
+		UserID:    userID,
+		Goal:      goal,
+		WeekStart: pgtype.Date{Time: weekStart, Valid: true},
+		ReachedAt: now,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record goal milestone: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// ReleaseMilestone deletes a recorded milestone, so it is recorded again once the goal is
+// found reached
+func (r *GoalRepository) ReleaseMilestone(ctx context.Context, userID uuid.UUID, goal string, weekStart time.Time) error {
+	err := r.q.DeleteGoalMilestone(ctx, db.DeleteGoalMilestoneParams{
+		UserID:    userID,
+		Goal:      goal,
+		WeekStart: pgtype.Date{Time: weekStart, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release goal milestone: %w", err)
+	}
+	return nil
+}
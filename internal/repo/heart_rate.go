@@ -0,0 +1,172 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Resolutions of downsampled heart rate, as units of date_trunc
+const (
+	HeartRateResolutionMinute = "minute"
+	HeartRateResolutionHour   = "hour"
+	HeartRateResolutionDay    = "day"
+)
+
+// HeartRateSample is a heart rate a source measured at MeasuredAt
+type HeartRateSample struct {
+	MeasuredAt time.Time
+	Source     string
+	BPM        int16
+}
+
+// HeartRatePoint is the heart rate of the samples of the minute, hour or day starting at
+// Bucket; its fields match the rows of the downsampling queries
+type HeartRatePoint struct {
+	Bucket      time.Time
+	AverageBpm  float64
+	MinBpm      int32
+	MaxBpm      int32
+	SampleCount int32
+}
+
+// HeartRateRepository stores heart rate samples and their hourly and daily rollups in PostgreSQL
+type HeartRateRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewHeartRateRepository creates a new PostgreSQL heart rate repository
+func NewHeartRateRepository(pool DB) *HeartRateRepository {
+	return &HeartRateRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// Insert writes heart rate samples of a user in a single statement, accepting the current time,
+// and returns the number of samples inserted. Samples already stored for their time and source
+// are skipped, so resending a batch inserts nothing. The hours of the inserted samples are queued
+// for RollUp.
+func (r *HeartRateRepository) Insert(ctx context.Context, userID uuid.UUID, samples []HeartRateSample, now time.Time) (int64, error) {
+	params := db.InsertHeartRateSamplesParams{
+		UserID:     userID,
+		MeasuredAt: make([]time.Time, len(samples)),
+		Sources:    make([]string, len(samples)),
+		Bpm:        make([]int16, len(samples)),
+		Now:        now,
+	}
+	for i, s := range samples {
+		params.MeasuredAt[i], params.Sources[i], params.Bpm[i] = s.MeasuredAt.UTC(), s.Source, s.BPM
+	}
+	inserted, err := r.q.InsertHeartRateSamples(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert heart rate samples: %w", err)
+	}
+	return inserted, nil
+}
+
+// Downsample returns the average, minimum and maximum heart rate of a user in [start, end) by
+// UTC minute, hour or day, oldest first. Buckets without samples are omitted. Minutes are
+// computed from the samples, hours and days read from their rollups, which include the
+// samples rolled up so far; a bucket starting before start is included as a whole.
+func (r *HeartRateRepository) Downsample(ctx context.Context, userID uuid.UUID, resolution string, start, end time.Time) ([]HeartRatePoint, error) {
+	var points []HeartRatePoint
+	switch resolution {
+	case HeartRateResolutionHour:
+		rows, err := r.q.ListHeartRateHourlyByUser(ctx, db.ListHeartRateHourlyByUserParams{
+			UserID:     userID,
+			RangeStart: start.UTC().Truncate(time.Hour),
+			RangeEnd:   end.UTC(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list hourly heart rate: %w", err)
+		}
+		for _, row := range rows {
+			points = append(points, HeartRatePoint(row))
+		}
+	case HeartRateResolutionDay:
+		// The days starting before end
+		endDay := end.UTC().Truncate(24 * time.Hour)
+		if endDay.Before(end) {
+			endDay = endDay.AddDate(0, 0, 1)
+		}
+		rows, err := r.q.ListHeartRateDailyByUser(ctx, db.ListHeartRateDailyByUserParams{
+			UserID:     userID,
+			RangeStart: pgtype.Date{Time: start.UTC().Truncate(24 * time.Hour), Valid: true},
+			RangeEnd:   pgtype.Date{Time: endDay, Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list daily heart rate: %w", err)
+		}
+		for _, row := range rows {
+			points = append(points, HeartRatePoint(row))
+		}
+	default:
+		rows, err := r.q.ListHeartRateByUser(ctx, db.ListHeartRateByUserParams{
+			Unit:       resolution,
+			UserID:     userID,
+			RangeStart: start.UTC(),
+			RangeEnd:   end.UTC(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list heart rate: %w", err)
+		}
+		for _, row := range rows {
+			points = append(points, HeartRatePoint(row))
+		}
+	}
+	return points, nil
+}
+
+// RollUp recomputes the hourly rollups of up to batchSize pending hours from their samples, and
+// the daily rollups of their days, in a single transaction. It returns the number of hours
+// rolled up; fewer than batchSize means none are pending.
+func (r *HeartRateRepository) RollUp(ctx context.Context, batchSize int32) (int, error) {
+	var rolledUp int
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		pending, err := q.ClaimHeartRatePendingHours(ctx, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to claim pending heart rate hours: %w", err)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		hours := db.RollUpHeartRateHoursParams{
+			UserIds: make([]uuid.UUID, len(pending)),
+			Hours:   make([]time.Time, len(pending)),
+		}
+		var days db.RollUpHeartRateDaysParams
+		type userDay struct {
+			userID uuid.UUID
+			day    time.Time
+		}
+		seenDays := make(map[userDay]bool)
+		for i, p := range pending {
+			hours.UserIds[i], hours.Hours[i] = p.UserID, p.Hour
+			day := userDay{userID: p.UserID, day: p.Hour.UTC().Truncate(24 * time.Hour)}
+			if !seenDays[day] {
+				seenDays[day] = true
+				days.UserIds = append(days.UserIds, day.userID)
+				days.Days = append(days.Days, pgtype.Date{Time: day.day, Valid: true})
+			}
+		}
+		if err := q.RollUpHeartRateHours(ctx, hours); err != nil {
+			return fmt.Errorf("failed to roll up heart rate hours: %w", err)
+		}
+		if err := q.RollUpHeartRateDays(ctx, days); err != nil {
+			return fmt.Errorf("failed to roll up heart rate days: %w", err)
+		}
+		rolledUp = len(pending)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rolledUp, nil
+}
@@ -0,0 +1,429 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Record types stored in imported_samples
+const (
+	ImportedRecordTypeBody     = EntityTypeBodyRecord
+	ImportedRecordTypeExercise = EntityTypeExerciseRecord
+)
+
+// ImportedBodyMeasurement is a body measurement sample from an external source.
+// Samples for the same date are merged into a single body record.
+type ImportedBodyMeasurement struct {
+	SourceSampleID    string
+	Date              time.Time
+	WeightKg          *float64
+	BodyFatPercentage *float64
+}
+
+// ImportedExercise is a workout sample from an external source
+type ImportedExercise struct {
+	SourceSampleID  string
+	ExerciseName    string
+	DurationMinutes *int32
+	CaloriesBurned  *int32
+	RecordedAt      time.Time
+	StartedAt       *time.Time
+	EndedAt         *time.Time
+}
+
+// ImportedDailySteps is a daily step total from an external source.
+// Totals are not deduped by sample ID since they grow over the day; the higher count is kept.
+type ImportedDailySteps struct {
+	Date  time.Time
+	Steps int32
+}
+
+// ImportBatch is a set of samples written in a single transaction
+type ImportBatch struct {
+	BodyMeasurements []ImportedBodyMeasurement
+	Exercises        []ImportedExercise
+	DailySteps       []ImportedDailySteps
+	// SkipOverlappingExercises drops timed exercises overlapping an existing record
+	// instead of creating a duplicate; they are reported as conflicts
+	SkipOverlappingExercises bool
+}
+
+// ImportBatchResult reports how many samples in a batch were newly imported, already known
+// or dropped because they conflicted with existing records
+type ImportBatchResult struct {
+	Imported   int
+	Duplicates int
+	Conflicts  int
+}
+
+// ImportRepository provides transactional bulk imports with per-sample dedupe
+type ImportRepository struct {
+	pool   DB
+	q      *db.Queries
+	counts CountCaches
+}
+
+// NewImportRepository creates a new PostgreSQL import repository
+func NewImportRepository(pool DB) *ImportRepository {
+	return &ImportRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// NewCachedImportRepository creates a PostgreSQL import repository evicting the counts of the
+// users it imports records of from counts
+func NewCachedImportRepository(pool DB, counts CountCaches) *ImportRepository {
+	return &ImportRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		counts: counts,
+	}
+}
+
+// CopyBatchMinSamples is the number of samples from which ImportBatch copies a batch with
+// CopyBatch; smaller batches take fewer round trips sample by sample
+const CopyBatchMinSamples = 100
+
+// ImportBatch writes all samples of a batch in one transaction, skipping samples whose
+// source sample ID was already imported for the user. Accepts the current time.
+func (r *ImportRepository) ImportBatch(ctx context.Context, userID uuid.UUID, source string, batch ImportBatch, now time.Time) (ImportBatchResult, error) {
+	if len(batch.BodyMeasurements)+len(batch.Exercises)+len(batch.DailySteps) >= CopyBatchMinSamples {
+		return r.CopyBatch(ctx, userID, source, batch, now)
+	}
+
+	var result ImportBatchResult
+	defer r.counts.Invalidate(userID)
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to begin import transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // Rollback after Commit is a no-op
+
+	q := r.q.WithTx(tx)
+
+	for _, m := range batch.BodyMeasurements {
+		sample, isNew, err := claimSample(ctx, q, userID, source, m.SourceSampleID, ImportedRecordTypeBody, now)
+		if err != nil {
+			return ImportBatchResult{}, err
+		}
+		if !isNew {
+			result.Duplicates++
+			continue
+		}
+
+		weightVal, err := toNumeric(m.WeightKg)
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to convert weight: %w", err)
+		}
+		bodyFatVal, err := toNumeric(m.BodyFatPercentage)
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to convert body fat percentage: %w", err)
+		}
+
+		record, err := q.MergeBodyRecord(ctx, db.MergeBodyRecordParams{
+			UserID:            userID,
+			Date:              pgtype.Date{Time: m.Date, Valid: true},
+			WeightKg:          weightVal,
+			BodyFatPercentage: bodyFatVal,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to merge imported body record: %w", err)
+		}
+
+		if err := linkSample(ctx, q, sample.ID, record.ID); err != nil {
+			return ImportBatchResult{}, err
+		}
+		if err := recordChange(ctx, q, userID, EntityTypeBodyRecord, record.ID, ChangeActionImported, source, "", now); err != nil {
+			return ImportBatchResult{}, err
+		}
+		result.Imported++
+	}
+
+	for _, e := range batch.Exercises {
+		sample, isNew, err := claimSample(ctx, q, userID, source, e.SourceSampleID, ImportedRecordTypeExercise, now)
+		if err != nil {
+			return ImportBatchResult{}, err
+		}
+		if !isNew {
+			result.Duplicates++
+			continue
+		}
+
+		if batch.SkipOverlappingExercises && e.StartedAt != nil && e.EndedAt != nil {
+			overlapping, err := q.ListOverlappingExerciseRecords(ctx, db.ListOverlappingExerciseRecordsParams{
+				UserID:     userID,
+				RangeStart: e.StartedAt.UTC(),
+				RangeEnd:   e.EndedAt.UTC(),
+			})
+			if err != nil {
+				return ImportBatchResult{}, fmt.Errorf("failed to list overlapping exercise records: %w", err)
+			}
+			if len(overlapping) > 0 {
+				// The sample stays claimed without a record so it is not retried on every sync
+				result.Conflicts++
+				continue
+			}
+		}
+
+		var durationMinutesVal, caloriesBurnedVal pgtype.Int4
+		if e.DurationMinutes != nil {
+			durationMinutesVal = pgtype.Int4{Int32: *e.DurationMinutes, Valid: true}
+		}
+		if e.CaloriesBurned != nil {
+			caloriesBurnedVal = pgtype.Int4{Int32: *e.CaloriesBurned, Valid: true}
+		}
+		var startedAtVal, endedAtVal pgtype.Timestamptz
+		if e.StartedAt != nil && e.EndedAt != nil {
+			startedAtVal = pgtype.Timestamptz{Time: e.StartedAt.UTC(), Valid: true}
+			endedAtVal = pgtype.Timestamptz{Time: e.EndedAt.UTC(), Valid: true}
+		}
+
+		record, err := q.CreateExerciseRecord(ctx, db.CreateExerciseRecordParams{
+			UserID:          userID,
+			ExerciseName:    e.ExerciseName,
+			DurationMinutes: durationMinutesVal,
+			CaloriesBurned:  caloriesBurnedVal,
+			RecordedAt:      e.RecordedAt.UTC(),
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			StartedAt:       startedAtVal,
+			EndedAt:         endedAtVal,
+		})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to create imported exercise record: %w", err)
+		}
+
+		if err := linkSample(ctx, q, sample.ID, record.ID); err != nil {
+			return ImportBatchResult{}, err
+		}
+		if err := recordChange(ctx, q, userID, EntityTypeExerciseRecord, record.ID, ChangeActionImported, source, "", now); err != nil {
+			return ImportBatchResult{}, err
+		}
+		result.Imported++
+	}
+
+	for _, d := range batch.DailySteps {
+		_, err := q.MergeStepRecord(ctx, db.MergeStepRecordParams{
+			UserID:    userID,
+			Date:      pgtype.Date{Time: d.Date, Valid: true},
+			Steps:     d.Steps,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to merge imported step record: %w", err)
+		}
+		result.Imported++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to commit import transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// CopyBatch writes all samples of a batch in one transaction like ImportBatch, but with a
+// constant number of statements whatever the size of the batch: the samples are copied into
+// staging tables with COPY, then merged into the records with one statement per step. Timed
+// exercises skipped with SkipOverlappingExercises are those overlapping an existing record or an
+// earlier exercise of the batch that was kept, as they are sample by sample.
+func (r *ImportRepository) CopyBatch(ctx context.Context, userID uuid.UUID, source string, batch ImportBatch, now time.Time) (ImportBatchResult, error) {
+	var result ImportBatchResult
+	batchID := uuid.New()
+	defer r.counts.Invalidate(userID)
+
+	// A sample repeated within the batch is a duplicate of its first occurrence, as it is
+	// sample by sample
+	seen := make(map[string]bool, len(batch.BodyMeasurements)+len(batch.Exercises))
+	bodyRows := make([][]any, 0, len(batch.BodyMeasurements))
+	for i, m := range batch.BodyMeasurements {
+		if seen[m.SourceSampleID] {
+			result.Duplicates++
+			continue
+		}
+		seen[m.SourceSampleID] = true
+		weightVal, err := toNumeric(m.WeightKg)
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to convert weight: %w", err)
+		}
+		bodyFatVal, err := toNumeric(m.BodyFatPercentage)
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to convert body fat percentage: %w", err)
+		}
+		bodyRows = append(bodyRows, []any{batchID, int32(i), m.SourceSampleID, pgtype.Date{Time: m.Date, Valid: true}, weightVal, bodyFatVal})
+	}
+	clear(seen)
+	exerciseRows := make([][]any, 0, len(batch.Exercises))
+	for i, e := range batch.Exercises {
+		if seen[e.SourceSampleID] {
+			result.Duplicates++
+			continue
+		}
+		seen[e.SourceSampleID] = true
+		var startedAtVal, endedAtVal pgtype.Timestamptz
+		if e.StartedAt != nil && e.EndedAt != nil {
+			startedAtVal = pgtype.Timestamptz{Time: e.StartedAt.UTC(), Valid: true}
+			endedAtVal = pgtype.Timestamptz{Time: e.EndedAt.UTC(), Valid: true}
+		}
+		exerciseRows = append(exerciseRows, []any{batchID, int32(i), e.SourceSampleID, e.ExerciseName, e.DurationMinutes, e.CaloriesBurned, e.RecordedAt.UTC(), startedAtVal, endedAtVal})
+	}
+	stepRows := make([][]any, 0, len(batch.DailySteps))
+	for i, d := range batch.DailySteps {
+		stepRows = append(stepRows, []any{batchID, int32(i), pgtype.Date{Time: d.Date, Valid: true}, d.Steps})
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to begin import transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // Rollback after Commit is a no-op
+
+	q := r.q.WithTx(tx)
+
+	if err := copyStaged(ctx, tx, "import_staging_body_measurements", []string{"batch_id", "ordinal", "source_sample_id", "date", "weight_kg", "body_fat_percentage"}, bodyRows); err != nil {
+		return ImportBatchResult{}, err
+	}
+	if err := copyStaged(ctx, tx, "import_staging_exercises", []string{"batch_id", "ordinal", "source_sample_id", "exercise_name", "duration_minutes", "calories_burned", "recorded_at", "started_at", "ended_at"}, exerciseRows); err != nil {
+		return ImportBatchResult{}, err
+	}
+	if err := copyStaged(ctx, tx, "import_staging_daily_steps", []string{"batch_id", "ordinal", "date", "steps"}, stepRows); err != nil {
+		return ImportBatchResult{}, err
+	}
+
+	if len(bodyRows) > 0 {
+		claimed, err := q.ClaimStagedBodyMeasurements(ctx, db.ClaimStagedBodyMeasurementsParams{BatchID: batchID, UserID: userID, Source: source, Now: now})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to record imported body samples: %w", err)
+		}
+		err = q.MergeStagedBodyMeasurements(ctx, db.MergeStagedBodyMeasurementsParams{BatchID: batchID, UserID: userID, Now: now})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to merge imported body records: %w", err)
+		}
+		result.Imported += int(claimed)
+		result.Duplicates += len(bodyRows) - int(claimed)
+	}
+	if len(exerciseRows) > 0 {
+		claimed, err := q.ClaimStagedExercises(ctx, db.ClaimStagedExercisesParams{BatchID: batchID, UserID: userID, Source: source, Now: now})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to record imported exercise samples: %w", err)
+		}
+		skipped := []int32{}
+		if batch.SkipOverlappingExercises {
+			timed, err := q.ListStagedTimedExercises(ctx, db.ListStagedTimedExercisesParams{BatchID: batchID, UserID: userID})
+			if err != nil {
+				return ImportBatchResult{}, fmt.Errorf("failed to list imported timed exercises: %w", err)
+			}
+			skipped = overlappingExercises(timed)
+		}
+		created, err := q.CreateStagedExercises(ctx, db.CreateStagedExercisesParams{
+			BatchID:         batchID,
+			UserID:          userID,
+			Now:             now,
+			SkippedOrdinals: skipped,
+		})
+		if err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to create imported exercise records: %w", err)
+		}
+		result.Imported += int(created)
+		result.Conflicts += int(claimed - created)
+		result.Duplicates += len(exerciseRows) - int(claimed)
+	}
+	if len(stepRows) > 0 {
+		if err := q.MergeStagedDailySteps(ctx, db.MergeStagedDailyStepsParams{BatchID: batchID, UserID: userID, Now: now}); err != nil {
+			return ImportBatchResult{}, fmt.Errorf("failed to merge imported step records: %w", err)
+		}
+		result.Imported += len(stepRows)
+	}
+
+	if err := q.LinkStagedSamples(ctx, batchID); err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to link imported samples to records: %w", err)
+	}
+	err = q.RecordStagedImportChanges(ctx, db.RecordStagedImportChangesParams{BatchID: batchID, UserID: userID, Source: source, Now: now})
+	if err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to record imported changes: %w", err)
+	}
+	if err := q.DeleteStagedImports(ctx, batchID); err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to delete staged samples: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ImportBatchResult{}, fmt.Errorf("failed to commit import transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// copyStaged copies rows into the staging table of a bulk import
+func copyStaged(ctx context.Context, tx pgx.Tx, table string, columns []string, rows [][]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy samples into %s: %w", table, err)
+	}
+	return nil
+}
+
+// claimSample records a sample as imported, reporting false if it was already known
+func claimSample(ctx context.Context, q *db.Queries, userID uuid.UUID, source, sourceSampleID, recordType string, now time.Time) (db.ImportedSample, bool, error) {
+	sample, err := q.CreateImportedSample(ctx, db.CreateImportedSampleParams{
+		UserID:         userID,
+		Source:         source,
+		SourceSampleID: sourceSampleID,
+		RecordType:     recordType,
+		ImportedAt:     now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// ON CONFLICT DO NOTHING returned no row: the sample was imported before
+			return db.ImportedSample{}, false, nil
+		}
+		return db.ImportedSample{}, false, fmt.Errorf("failed to record imported sample: %w", err)
+	}
+	return sample, true, nil
+}
+
+// linkSample stores the ID of the record a sample was written to
+func linkSample(ctx context.Context, q *db.Queries, sampleID, recordID uuid.UUID) error {
+	err := q.SetImportedSampleRecord(ctx, db.SetImportedSampleRecordParams{
+		ID:       sampleID,
+		RecordID: pgtype.UUID{Bytes: recordID, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to link imported sample to record: %w", err)
+	}
+	return nil
+}
+
+// overlappingExercises returns the ordinals of the timed exercises to skip, in batch order: those
+// overlapping an existing record, or an earlier exercise that was not skipped itself
+func overlappingExercises(timed []db.ListStagedTimedExercisesRow) []int32 {
+	skipped := []int32{}
+	// kept holds the spans of the kept exercises, which never overlap, ordered by start
+	var kept []db.ListStagedTimedExercisesRow
+	for _, e := range timed {
+		// The first kept span ending after e starts is the only one e may overlap
+		i := sort.Search(len(kept), func(i int) bool { return kept[i].EndedAt.After(e.StartedAt) })
+		if e.OverlapsExisting || (i < len(kept) && kept[i].StartedAt.Before(e.EndedAt)) {
+			skipped = append(skipped, e.Ordinal)
+			continue
+		}
+		kept = append(kept, db.ListStagedTimedExercisesRow{})
+		copy(kept[i+1:], kept[i:])
+		kept[i] = e
+	}
+	return skipped
+}
@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/crypto"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrIntegrationNotFound is returned when a user has not linked a provider
+var ErrIntegrationNotFound = errors.New("integration not found")
+
+// Integration is a linked account with its provider tokens decrypted
+type Integration struct {
+	db.Integration
+	AccessToken  string
+	RefreshToken string
+}
+
+// IntegrationRepository provides database operations for linked third-party accounts.
+// Provider tokens are encrypted before they are stored.
+type IntegrationRepository struct {
+	pool   DB
+	q      *db.Queries
+	cipher *crypto.Cipher
+}
+
+// NewIntegrationRepository creates a new PostgreSQL integration repository
+func NewIntegrationRepository(pool DB, cipher *crypto.Cipher) *IntegrationRepository {
+	return &IntegrationRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		cipher: cipher,
+	}
+}
+
+// Link stores the tokens of a linked account, replacing the tokens of an existing link
+// for the same provider. externalUserID is the provider's account ID, empty if unknown.
+// Accepts the current time.
+func (r *IntegrationRepository) Link(ctx context.Context, userID uuid.UUID, provider, externalUserID, accessToken, refreshToken string, tokenExpiresAt, now time.Time) (db.Integration, error) {
+	encryptedAccessToken, encryptedRefreshToken, err := r.encryptTokens(accessToken, refreshToken)
+	if err != nil {
+		return db.Integration{}, err
+	}
+
+	integration, err := r.q.UpsertIntegration(ctx, db.UpsertIntegrationParams{
+		UserID:                userID,
+		Provider:              provider,
+		ExternalUserID:        pgtype.Text{String: externalUserID, Valid: externalUserID != ""},
+		EncryptedAccessToken:  encryptedAccessToken,
+		EncryptedRefreshToken: encryptedRefreshToken,
+		TokenExpiresAt:        tokenExpiresAt.UTC(),
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	})
+	if err != nil {
+		return db.Integration{}, fmt.Errorf("failed to link integration: %w", err)
+	}
+	return integration, nil
+}
+
+// Unlink deletes a user's link to a provider and returns it, so its tokens can be revoked
+func (r *IntegrationRepository) Unlink(ctx context.Context, userID uuid.UUID, provider string) (Integration, error) {
+	integration, err := r.q.DeleteIntegration(ctx, db.DeleteIntegrationParams{
+		UserID:   userID,
+		Provider: provider,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Integration{}, ErrIntegrationNotFound
+		}
+		return Integration{}, fmt.Errorf("failed to unlink integration: %w", err)
+	}
+	return r.decrypt(integration)
+}
+
+// FindByUser retrieves all integrations linked by a user. Tokens are not decrypted.
+func (r *IntegrationRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]db.Integration, error) {
+	integrations, err := r.q.ListIntegrationsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list integrations: %w", err)
+	}
+	return integrations, nil
+}
+
+// FindDueForSync retrieves the integrations of a provider not synced since syncedBefore
+func (r *IntegrationRepository) FindDueForSync(ctx context.Context, provider string, syncedBefore time.Time) ([]Integration, error) {
+	dbIntegrations, err := r.q.ListIntegrationsDueForSync(ctx, db.ListIntegrationsDueForSyncParams{
+		Provider:     provider,
+		SyncedBefore: syncedBefore.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list integrations due for sync: %w", err)
+	}
+	return r.decryptAll(dbIntegrations)
+}
+
+// FindByExternalUser retrieves the integrations linked to a provider account, e.g. to handle
+// a push notification for it
+func (r *IntegrationRepository) FindByExternalUser(ctx context.Context, provider, externalUserID string) ([]Integration, error) {
+	dbIntegrations, err := r.q.ListIntegrationsByExternalUser(ctx, db.ListIntegrationsByExternalUserParams{
+		Provider:       provider,
+		ExternalUserID: pgtype.Text{String: externalUserID, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list integrations by external user: %w", err)
+	}
+	return r.decryptAll(dbIntegrations)
+}
+
+// UpdateTokens stores refreshed tokens, accepting the current time
+func (r *IntegrationRepository) UpdateTokens(ctx context.Context, id uuid.UUID, accessToken, refreshToken string, tokenExpiresAt, now time.Time) error {
+	encryptedAccessToken, encryptedRefreshToken, err := r.encryptTokens(accessToken, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	err = r.q.UpdateIntegrationTokens(ctx, db.UpdateIntegrationTokensParams{
+		ID:                    id,
+		EncryptedAccessToken:  encryptedAccessToken,
+		EncryptedRefreshToken: encryptedRefreshToken,
+		TokenExpiresAt:        tokenExpiresAt.UTC(),
+		UpdatedAt:             now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update integration tokens: %w", err)
+	}
+	return nil
+}
+
+// RecordSyncSuccess advances the sync cursor and clears the last sync error
+func (r *IntegrationRepository) RecordSyncSuccess(ctx context.Context, id uuid.UUID, cursor, now time.Time) error {
+	err := r.q.UpdateIntegrationSyncState(ctx, db.UpdateIntegrationSyncStateParams{
+		ID:           id,
+		SyncCursor:   pgtype.Timestamptz{Time: cursor.UTC(), Valid: true},
+		LastSyncedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record integration sync: %w", err)
+	}
+	return nil
+}
+
+// RecordSyncFailure stores the error of a failed sync, keeping the previous cursor.
+// The attempt time is still recorded so a failing integration is retried on the next interval.
+func (r *IntegrationRepository) RecordSyncFailure(ctx context.Context, integration db.Integration, syncErr error, now time.Time) error {
+	err := r.q.UpdateIntegrationSyncState(ctx, db.UpdateIntegrationSyncStateParams{
+		ID:            integration.ID,
+		SyncCursor:    integration.SyncCursor,
+		LastSyncedAt:  pgtype.Timestamptz{Time: now, Valid: true},
+		LastSyncError: pgtype.Text{String: syncErr.Error(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record integration sync failure: %w", err)
+	}
+	return nil
+}
+
+// ReencryptTokens re-encrypts the tokens of up to batchSize integrations encrypted with a
+// previous key with the primary key, returning how many it re-encrypted. Integrations are locked
+// while they are re-encrypted, so concurrent calls re-encrypt different integrations.
+func (r *IntegrationRepository) ReencryptTokens(ctx context.Context, batchSize int) (int, error) {
+	var reencrypted int
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		integrations, err := q.ListIntegrationsToReencryptForUpdate(ctx, db.ListIntegrationsToReencryptForUpdateParams{
+			Prefix:   r.cipher.PrimaryKeyPrefix(),
+			MaxCount: int32(batchSize),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list integrations to re-encrypt: %w", err)
+		}
+		for _, i := range integrations {
+			accessToken, err := r.cipher.Reencrypt(i.EncryptedAccessToken)
+			if err != nil {
+				return fmt.Errorf("failed to re-encrypt access token of integration %s: %w", i.ID, err)
+			}
+			refreshToken, err := r.cipher.Reencrypt(i.EncryptedRefreshToken)
+			if err != nil {
+				return fmt.Errorf("failed to re-encrypt refresh token of integration %s: %w", i.ID, err)
+			}
+			err = q.SetIntegrationCiphertexts(ctx, db.SetIntegrationCiphertextsParams{
+				ID:                    i.ID,
+				EncryptedAccessToken:  accessToken,
+				EncryptedRefreshToken: refreshToken,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update tokens of integration %s: %w", i.ID, err)
+			}
+		}
+		reencrypted = len(integrations)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return reencrypted, nil
+}
+
+func (r *IntegrationRepository) encryptTokens(accessToken, refreshToken string) (string, string, error) {
+	encryptedAccessToken, err := r.cipher.Encrypt(accessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	encryptedRefreshToken, err := r.cipher.Encrypt(refreshToken)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+	return encryptedAccessToken, encryptedRefreshToken, nil
+}
+
+func (r *IntegrationRepository) decryptAll(dbIntegrations []db.Integration) ([]Integration, error) {
+	integrations := make([]Integration, 0, len(dbIntegrations))
+	for _, i := range dbIntegrations {
+		integration, err := r.decrypt(i)
+		if err != nil {
+			return nil, err
+		}
+		integrations = append(integrations, integration)
+	}
+	return integrations, nil
+}
+
+func (r *IntegrationRepository) decrypt(integration db.Integration) (Integration, error) {
+	accessToken, err := r.cipher.Decrypt(integration.EncryptedAccessToken)
+	if err != nil {
+		return Integration{}, fmt.Errorf("failed to decrypt access token of integration %s: %w", integration.ID, err)
+	}
+	refreshToken, err := r.cipher.Decrypt(integration.EncryptedRefreshToken)
+	if err != nil {
+		return Integration{}, fmt.Errorf("failed to decrypt refresh token of integration %s: %w", integration.ID, err)
+	}
+	return Integration{
+		Integration:  integration,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrMealRecordNotFound is returned when a meal record is not found
+var ErrMealRecordNotFound = errors.New("meal record not found")
+
+// MealRecordRepository provides database operations for MealRecord
+type MealRecordRepository struct {
+	q *db.Queries
+}
+
+// NewMealRecordRepository creates a new PostgreSQL meal record repository
+func NewMealRecordRepository(pool DB) *MealRecordRepository {
+	return &MealRecordRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create creates a new meal record, accepting the current time
+func (r *MealRecordRepository) Create(ctx context.Context, userID uuid.UUID, name string, calories int32, eatenAt, now time.Time) (db.MealRecord, error) {
+	record, err := r.q.CreateMealRecord(ctx, db.CreateMealRecordParams{
+		UserID:    userID,
+		Name:      name,
+		Calories:  calories,
+		EatenAt:   eatenAt.UTC(),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return db.MealRecord{}, fmt.Errorf("failed to create meal record: %w", err)
+	}
+	return record, nil
+}
+
+// FindByUser retrieves paginated meal records for a user, newest first
+func (r *MealRecordRepository) FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.MealRecord, error) {
+	records, err := r.q.ListMealRecordsByUser(ctx, db.ListMealRecordsByUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list meal records: %w", err)
+	}
+	return records, nil
+}
+
+// CountByUser returns the total number of meal records for a user
+func (r *MealRecordRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountMealRecordsByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count meal records: %w", err)
+	}
+	return count, nil
+}
+
+// Delete moves a meal record by ID and user ID to the trash at now, returning
+// ErrMealRecordNotFound if the user has no such record
+func (r *MealRecordRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashMealRecord(ctx, db.TrashMealRecordParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete meal record: %w", err)
+	}
+	if deleted == 0 {
+		return ErrMealRecordNotFound
+	}
+	return nil
+}
+
+// DailyCalorieBalance returns, for every UTC day from start to end inclusive, the calories
+// consumed and burned through exercise, the latest weight recorded on or before the day and
+// the calories of the meals planned for the day
+func (r *MealRecordRepository) DailyCalorieBalance(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ListDailyCalorieBalanceRow, error) {
+	days, err := r.q.ListDailyCalorieBalance(ctx, db.ListDailyCalorieBalanceParams{
+		UserID:    userID,
+		StartDate: pgtype.Date{Time: start, Valid: true},
+		EndDate:   pgtype.Date{Time: end, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily calorie balance: %w", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Statuses of monthly reports
+const (
+	MonthlyReportPending   = "pending"
+	MonthlyReportRunning   = "running"
+	MonthlyReportCompleted = "completed"
+	MonthlyReportFailed    = "failed"
+)
+
+// ErrMonthlyReportNotFound is returned when a monthly report is not found
+var ErrMonthlyReportNotFound = errors.New("monthly report not found")
+
+// MonthlyReportRepository provides database operations for the monthly reports of users
+type MonthlyReportRepository struct {
+	q *db.Queries
+}
+
+// NewMonthlyReportRepository creates a new PostgreSQL monthly report repository
+func NewMonthlyReportRepository(pool DB) *MonthlyReportRepository {
+	return &MonthlyReportRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create queues a report of the month starting on the given day, accepting the current time
+func (r *MonthlyReportRepository) Create(ctx context.Context, userID uuid.UUID, month, now time.Time) (db.MonthlyReport, error) {
+	report, err := r.q.CreateMonthlyReport(ctx, db.CreateMonthlyReportParams{
+		UserID: userID,
+		Month:  pgtype.Date{Time: month, Valid: true},
+		Now:    now,
+	})
+	if err != nil {
+		return db.MonthlyReport{}, fmt.Errorf("failed to create monthly report: %w", err)
+	}
+	return report, nil
+}
+
+// FindByID retrieves a report of a user by ID
+func (r *MonthlyReportRepository) FindByID(ctx context.Context, id, userID uuid.UUID) (db.MonthlyReport, error) {
+	report, err := r.q.GetMonthlyReport(ctx, db.GetMonthlyReportParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.MonthlyReport{}, ErrMonthlyReportNotFound
+		}
+		return db.MonthlyReport{}, fmt.Errorf("failed to get monthly report: %w", err)
+	}
+	return report, nil
+}
+
+// Claim claims the oldest report due at now until leaseUntil, so a job that stops midway leaves
+// it to be retried. It returns false when no report is due.
+func (r *MonthlyReportRepository) Claim(ctx context.Context, now, leaseUntil time.Time) (db.MonthlyReport, bool, error) {
+	report, err := r.q.ClaimMonthlyReport(ctx, db.ClaimMonthlyReportParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.MonthlyReport{}, false, nil
+		}
+		return db.MonthlyReport{}, false, fmt.Errorf("failed to claim monthly report: %w", err)
+	}
+	return report, true, nil
+}
+
+// Complete records the key of the written PDF of a report, accepting the current time
+func (r *MonthlyReportRepository) Complete(ctx context.Context, id uuid.UUID, storageKey string, now time.Time) error {
+	err := r.q.CompleteMonthlyReport(ctx, db.CompleteMonthlyReportParams{
+		ID:          id,
+		StorageKey:  pgtype.Text{String: storageKey, Valid: true},
+		CompletedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete monthly report: %w", err)
+	}
+	return nil
+}
+
+// Retry records a failed attempt and retries the report at nextAttemptAt
+func (r *MonthlyReportRepository) Retry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	err := r.q.RetryMonthlyReport(ctx, db.RetryMonthlyReportParams{
+		ID:            id,
+		NextAttemptAt: nextAttemptAt,
+		LastError:     pgtype.Text{String: lastError, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retry monthly report: %w", err)
+	}
+	return nil
+}
+
+// Fail marks a report failed after its last attempt, accepting the current time
+func (r *MonthlyReportRepository) Fail(ctx context.Context, id uuid.UUID, lastError string, now time.Time) error {
+	err := r.q.FailMonthlyReport(ctx, db.FailMonthlyReportParams{
+		ID:          id,
+		LastError:   pgtype.Text{String: lastError, Valid: true},
+		CompletedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark monthly report failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrMoodRecordNotFound is returned when a mood record is not found
+var ErrMoodRecordNotFound = errors.New("mood record not found")
+
+// MoodRecordRepository provides database operations for MoodRecord
+type MoodRecordRepository struct {
+	q *db.Queries
+}
+
+// NewMoodRecordRepository creates a new PostgreSQL mood record repository
+func NewMoodRecordRepository(pool DB) *MoodRecordRepository {
+	return &MoodRecordRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create creates a new mood record, accepting the current time
+func (r *MoodRecordRepository) Create(ctx context.Context, userID uuid.UUID, moodScore, energyLevel int16, symptoms []string, recordedAt, now time.Time) (db.MoodRecord, error) {
+	if symptoms == nil {
+		symptoms = []string{}
+	}
+	record, err := r.q.CreateMoodRecord(ctx, db.CreateMoodRecordParams{
+		UserID:      userID,
+		MoodScore:   moodScore,
+		EnergyLevel: energyLevel,
+		Symptoms:    symptoms,
+		RecordedAt:  recordedAt.UTC(),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return db.MoodRecord{}, fmt.Errorf("failed to create mood record: %w", err)
+	}
+	return record, nil
+}
+
+// FindByUser retrieves paginated mood records for a user, newest first
+func (r *MoodRecordRepository) FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.MoodRecord, error) {
+	records, err := r.q.ListMoodRecordsByUser(ctx, db.ListMoodRecordsByUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mood records: %w", err)
+	}
+	return records, nil
+}
+
+// CountByUser returns the total number of mood records for a user
+func (r *MoodRecordRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountMoodRecordsByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count mood records: %w", err)
+	}
+	return count, nil
+}
+
+// Delete moves a mood record by ID and user ID to the trash at now, returning
+// ErrMoodRecordNotFound if the user has no such record
+func (r *MoodRecordRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashMoodRecord(ctx, db.TrashMoodRecordParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete mood record: %w", err)
+	}
+	if deleted == 0 {
+		return ErrMoodRecordNotFound
+	}
+	return nil
+}
+
+// DailyMetrics returns, for every UTC day from start to end inclusive with mood records, the
+// average mood and energy along with the day's steps, exercise minutes and calories consumed
+func (r *MoodRecordRepository) DailyMetrics(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ListDailyMoodMetricsRow, error) {
+	days, err := r.q.ListDailyMoodMetrics(ctx, db.ListDailyMoodMetricsParams{
+		UserID:    userID,
+		StartDate: pgtype.Date{Time: start, Valid: true},
+		EndDate:   pgtype.Date{Time: end, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily mood metrics: %w", err)
+	}
+	return days, nil
+}
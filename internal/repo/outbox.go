@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RecordChangedEvent is the payload of the events of record changes, typed
+// "<entity type>.<action>", e.g. "body_record.created". Subscribers read the record itself
+// through the API.
+type RecordChangedEvent struct {
+	EntityType string    `json:"entity_type"`
+	EntityID   uuid.UUID `json:"entity_id"`
+	Action     string    `json:"action"`
+	Source     string    `json:"source"`
+	Details    string    `json:"details,omitempty"`
+}
+
+// OutboxRepository provides database operations for the outbox of domain events
+type OutboxRepository struct {
+	q *db.Queries
+}
+
+// NewOutboxRepository creates a new PostgreSQL outbox repository
+func NewOutboxRepository(pool DB) *OutboxRepository {
+	return &OutboxRepository{
+		q: db.New(pool),
+	}
+}
+
+// ClaimDue claims up to limit events that are due at now, oldest first. Claimed events are not
+// claimed again before leaseUntil, so a relay that stops mid-publish leaves them to be retried.
+func (r *OutboxRepository) ClaimDue(ctx context.Context, limit int32, now, leaseUntil time.Time) ([]db.OutboxEvent, error) {
+	events, err := r.q.ClaimDueOutboxEvents(ctx, db.ClaimDueOutboxEventsParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		MaxCount:   limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	return events, nil
+}
+
+// MarkPublished records the publication of an event, accepting the current time
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID, now time.Time) error {
+	err := r.q.MarkOutboxEventPublished(ctx, db.MarkOutboxEventPublishedParams{
+		ID:          id,
+		PublishedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+	return nil
+}
+
+// Reschedule records a failed publish attempt and retries the event at nextAttemptAt
+func (r *OutboxRepository) Reschedule(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	err := r.q.RescheduleOutboxEvent(ctx, db.RescheduleOutboxEventParams{
+		ID:            id,
+		NextAttemptAt: nextAttemptAt,
+		LastError:     pgtype.Text{String: lastError, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox event: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed gives up on an event
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string) error {
+	err := r.q.MarkOutboxEventFailed(ctx, db.MarkOutboxEventFailedParams{
+		ID:        id,
+		LastError: pgtype.Text{String: lastError, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event failed: %w", err)
+	}
+	return nil
+}
+
+// DeletePublished deletes the events published before cutoff, returning how many were deleted
+func (r *OutboxRepository) DeletePublished(ctx context.Context, cutoff time.Time) (int64, error) {
+	deleted, err := r.q.DeletePublishedOutboxEvents(ctx, pgtype.Timestamptz{Time: cutoff, Valid: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", err)
+	}
+	return deleted, nil
+}
+
+// publishEvent writes an event to the outbox with q, so it is published once the transaction
+// of q commits
+func publishEvent(ctx context.Context, q *db.Queries, userID uuid.UUID, eventType string, payload any, now time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	err = q.CreateOutboxEvent(ctx, db.CreateOutboxEventParams{
+		UserID:    userID,
+		EventType: eventType,
+		Payload:   encoded,
+		Now:       now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s event: %w", eventType, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// partitionedTables are the record tables partitioned by UTC month
+var partitionedTables = []string{"body_records", "exercise_records", "heart_rate_samples"}
+
+// PartitionRepository maintains the monthly partitions of the record tables
+type PartitionRepository struct {
+	q *db.Queries
+}
+
+// NewPartitionRepository creates a new PostgreSQL partition repository
+func NewPartitionRepository(pool DB) *PartitionRepository {
+	return &PartitionRepository{
+		q: db.New(pool),
+	}
+}
+
+// CreatePartitions creates the missing partitions of the record tables from the UTC month of
+// now through monthsAhead months later, returning the names of the created partitions
+func (r *PartitionRepository) CreatePartitions(ctx context.Context, now time.Time, monthsAhead int) ([]string, error) {
+	y, m, _ := now.UTC().Date()
+	fromMonth := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	throughMonth := fromMonth.AddDate(0, monthsAhead, 0)
+
+	var created []string
+	for _, table := range partitionedTables {
+		names, err := r.q.CreateRecordPartitions(ctx, db.CreateRecordPartitionsParams{
+			Parent:       table,
+			FromMonth:    pgtype.Date{Time: fromMonth, Valid: true},
+			ThroughMonth: pgtype.Date{Time: throughMonth, Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create partitions of %s: %w", table, err)
+		}
+		created = append(created, names...)
+	}
+	return created, nil
+}
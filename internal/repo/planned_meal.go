@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrPlannedMealNotFound is returned when a planned meal is not found
+var ErrPlannedMealNotFound = errors.New("planned meal not found")
+
+// PlannedMealRepository provides database operations for PlannedMeal
+type PlannedMealRepository struct {
+	q *db.Queries
+}
+
+// NewPlannedMealRepository creates a new PostgreSQL planned meal repository
+func NewPlannedMealRepository(pool DB) *PlannedMealRepository {
+	return &PlannedMealRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create plans a meal of a user for a UTC day, planned by plannedBy (the user or a coach),
+// accepting the current time
+func (r *PlannedMealRepository) Create(ctx context.Context, userID, plannedBy uuid.UUID, date time.Time, name string, calories int32, now time.Time) (db.PlannedMeal, error) {
+	meal, err := r.q.CreatePlannedMeal(ctx, db.CreatePlannedMealParams{
+		UserID:    userID,
+		Date:      pgtype.Date{Time: date, Valid: true},
+		Name:      name,
+		Calories:  calories,
+		PlannedBy: pgtype.UUID{Bytes: plannedBy, Valid: true},
+		CreatedAt: now,
+	})
+	if err != nil {
+		return db.PlannedMeal{}, fmt.Errorf("failed to create planned meal: %w", err)
+	}
+	return meal, nil
+}
+
+// FindByUserDateRange retrieves the meals planned for a user from start to end inclusive, by
+// day and then in the order they were planned
+func (r *PlannedMealRepository) FindByUserDateRange(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.PlannedMeal, error) {
+	meals, err := r.q.ListPlannedMealsByUserDateRange(ctx, db.ListPlannedMealsByUserDateRangeParams{
+		UserID:    userID,
+		StartDate: pgtype.Date{Time: start, Valid: true},
+		EndDate:   pgtype.Date{Time: end, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list planned meals: %w", err)
+	}
+	return meals, nil
+}
+
+// CountByUserDate returns the number of meals planned for a user on a day
+func (r *PlannedMealRepository) CountByUserDate(ctx context.Context, userID uuid.UUID, date time.Time) (int64, error) {
+	count, err := r.q.CountPlannedMealsByUserDate(ctx, db.CountPlannedMealsByUserDateParams{
+		UserID: userID,
+		Date:   pgtype.Date{Time: date, Valid: true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count planned meals: %w", err)
+	}
+	return count, nil
+}
+
+// Update replaces the day, name and calories of a user's planned meal, accepting the current
+// time. Returns ErrPlannedMealNotFound if the user has no such planned meal.
+func (r *PlannedMealRepository) Update(ctx context.Context, id, userID uuid.UUID, date time.Time, name string, calories int32, now time.Time) (db.PlannedMeal, error) {
+	meal, err := r.q.UpdatePlannedMeal(ctx, db.UpdatePlannedMealParams{
+		ID:        id,
+		UserID:    userID,
+		Date:      pgtype.Date{Time: date, Valid: true},
+		Name:      name,
+		Calories:  calories,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.PlannedMeal{}, ErrPlannedMealNotFound
+		}
+		return db.PlannedMeal{}, fmt.Errorf("failed to update planned meal: %w", err)
+	}
+	return meal, nil
+}
+
+// Delete moves a user's planned meal to the trash at now, returning ErrPlannedMealNotFound if the
+// user has no such planned meal
+func (r *PlannedMealRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashPlannedMeal(ctx, db.TrashPlannedMealParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete planned meal: %w", err)
+	}
+	if deleted == 0 {
+		return ErrPlannedMealNotFound
+	}
+	return nil
+}
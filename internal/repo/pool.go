@@ -3,26 +3,31 @@ package repo
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/atreya2011/health-management-api/internal/config"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DB is the database of the repositories: a connection pool of the primary, or a Router
+// spreading reads over replicas
+type DB interface {
+	db.DBTX
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 // NewDBPool creates a new PostgreSQL connection pool
-func NewDBPool(cfg *config.DatabaseConfig) (*pgxpool.Pool, error) {
+func NewDBPool(cfg *config.DatabaseConfig, log *slog.Logger) (*pgxpool.Pool, error) {
 	// Create a connection pool configuration
-	poolConfig, err := pgxpool.ParseConfig(cfg.URL)
+	poolConfig, err := newPoolConfig(cfg.URL, cfg, log)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing database URL: %w", err)
 	}
 
-	// Set pool configuration options
-	poolConfig.MaxConns = 10
-	poolConfig.MinConns = 2
-	poolConfig.MaxConnLifetime = 1 * time.Hour
-	poolConfig.MaxConnIdleTime = 30 * time.Minute
-
 	// Create a connection context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -40,3 +45,34 @@ func NewDBPool(cfg *config.DatabaseConfig) (*pgxpool.Pool, error) {
 
 	return pool, nil
 }
+
+// newPoolConfig parses the pool configuration of url, applying the pool options of cfg and
+// tracing its queries
+func newPoolConfig(url string, cfg *config.DatabaseConfig, log *slog.Logger) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set pool configuration options; they take precedence over pool_* parameters of the URL
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	poolConfig.ConnConfig.Tracer = NewQueryTracer(cfg.SlowQueryThreshold, cfg.LogQueryArgs, log)
+	// Set on every connection as it starts, so runaway statements can't hold connections
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = milliseconds(cfg.StatementTimeout)
+	}
+	if cfg.LockTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["lock_timeout"] = milliseconds(cfg.LockTimeout)
+	}
+	return poolConfig, nil
+}
+
+// milliseconds formats d as a PostgreSQL setting in milliseconds, rounded up so that short
+// durations don't disable the setting
+func milliseconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Millisecond-1)/time.Millisecond), 10)
+}
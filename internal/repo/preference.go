@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PreferenceRepository provides database operations for the users' preferences
+type PreferenceRepository struct {
+	q *db.Queries
+}
+
+// NewPreferenceRepository creates a new PostgreSQL preference repository
+func NewPreferenceRepository(pool DB) *PreferenceRepository {
+	return &PreferenceRepository{
+		q: db.New(pool),
+	}
+}
+
+// GetUnits retrieves the units a user prefers
+func (r *PreferenceRepository) GetUnits(ctx context.Context, userID uuid.UUID) (units.Preferences, error) {
+	row, err := r.q.GetUserUnits(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return units.Preferences{}, ErrUserNotFound
+		}
+		return units.Preferences{}, fmt.Errorf("failed to get unit preferences: %w", err)
+	}
+	return units.Preferences{Weight: units.WeightUnit(row.WeightUnit), Height: units.HeightUnit(row.HeightUnit)}, nil
+}
+
+// SetUnits replaces the units a user prefers
+func (r *PreferenceRepository) SetUnits(ctx context.Context, userID uuid.UUID, prefs units.Preferences) (units.Preferences, error) {
+	row, err := r.q.SetUserUnits(ctx, db.SetUserUnitsParams{
+		ID:         userID,
+		WeightUnit: string(prefs.Weight),
+		HeightUnit: string(prefs.Height),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return units.Preferences{}, ErrUserNotFound
+		}
+		return units.Preferences{}, fmt.Errorf("failed to set unit preferences: %w", err)
+	}
+	return units.Preferences{Weight: units.WeightUnit(row.WeightUnit), Height: units.HeightUnit(row.HeightUnit)}, nil
+}
@@ -0,0 +1,159 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrDeviceNotFound is returned when a user has not registered a device token
+var ErrDeviceNotFound = errors.New("device not found")
+
+// PushNotification is a queued notification with the device it is delivered to
+type PushNotification = db.ClaimDuePushNotificationsRow
+
+// PushRepository provides database operations for device tokens and the push notification outbox
+type PushRepository struct {
+	q *db.Queries
+}
+
+// NewPushRepository creates a new PostgreSQL push repository
+func NewPushRepository(pool DB) *PushRepository {
+	return &PushRepository{
+		q: db.New(pool),
+	}
+}
+
+// RegisterDevice stores the push token of a user's device, accepting the current time.
+// A token registered by another user before is moved to userID.
+func (r *PushRepository) RegisterDevice(ctx context.Context, userID uuid.UUID, platform, token string, now time.Time) (db.DeviceToken, error) {
+	device, err := r.q.UpsertDeviceToken(ctx, db.UpsertDeviceTokenParams{
+		UserID:    userID,
+		Platform:  platform,
+		Token:     token,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return db.DeviceToken{}, fmt.Errorf("failed to register device: %w", err)
+	}
+	return device, nil
+}
+
+// UnregisterDevice deletes a device token of a user, returning ErrDeviceNotFound if the user has not registered it
+func (r *PushRepository) UnregisterDevice(ctx context.Context, userID uuid.UUID, platform, token string) error {
+	rows, err := r.q.DeleteDeviceTokenForUser(ctx, db.DeleteDeviceTokenForUserParams{
+		UserID:   userID,
+		Platform: platform,
+		Token:    token,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unregister device: %w", err)
+	}
+	if rows == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// FindDevicesByUser retrieves the devices registered by a user
+func (r *PushRepository) FindDevicesByUser(ctx context.Context, userID uuid.UUID) ([]db.DeviceToken, error) {
+	devices, err := r.q.ListDeviceTokensByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	return devices, nil
+}
+
+// DeleteDevice deletes a device token, e.g. after the push service reported it as invalid.
+// Its queued notifications are deleted with it.
+func (r *PushRepository) DeleteDevice(ctx context.Context, id uuid.UUID) error {
+	if err := r.q.DeleteDeviceToken(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	return nil
+}
+
+// Enqueue queues a notification for every device of a user and returns the number queued.
+// Accepts the current time.
+func (r *PushRepository) Enqueue(ctx context.Context, userID uuid.UUID, kind, title, body string, data map[string]string, now time.Time) (int64, error) {
+	if data == nil {
+		data = map[string]string{}
+	}
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode notification data: %w", err)
+	}
+
+	count, err := r.q.EnqueuePushNotifications(ctx, db.EnqueuePushNotificationsParams{
+		Kind:   kind,
+		Title:  title,
+		Body:   body,
+		Data:   encodedData,
+		Now:    now,
+		UserID: userID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue push notifications: %w", err)
+	}
+	return count, nil
+}
+
+// ClaimDue claims up to limit notifications for the given platforms that are due at now.
+// Claimed notifications are not claimed again before leaseUntil, so a worker that stops
+// mid-delivery leaves them to be retried.
+func (r *PushRepository) ClaimDue(ctx context.Context, platforms []string, limit int32, now, leaseUntil time.Time) ([]PushNotification, error) {
+	notifications, err := r.q.ClaimDuePushNotifications(ctx, db.ClaimDuePushNotificationsParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		Platforms:  platforms,
+		MaxCount:   limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim push notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// MarkSent records the delivery of a notification, accepting the current time
+func (r *PushRepository) MarkSent(ctx context.Context, id uuid.UUID, now time.Time) error {
+	err := r.q.MarkPushNotificationSent(ctx, db.MarkPushNotificationSentParams{
+		ID:     id,
+		SentAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark push notification sent: %w", err)
+	}
+	return nil
+}
+
+// Reschedule records a failed delivery attempt and retries the notification at nextAttemptAt
+func (r *PushRepository) Reschedule(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	err := r.q.ReschedulePushNotification(ctx, db.ReschedulePushNotificationParams{
+		ID:            id,
+		NextAttemptAt: nextAttemptAt,
+		LastError:     pgtype.Text{String: lastError, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reschedule push notification: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed gives up on a notification
+func (r *PushRepository) MarkFailed(ctx context.Context, id uuid.UUID, lastError string) error {
+	err := r.q.MarkPushNotificationFailed(ctx, db.MarkPushNotificationFailedParams{
+		ID:        id,
+		LastError: pgtype.Text{String: lastError, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark push notification failed: %w", err)
+	}
+	return nil
+}
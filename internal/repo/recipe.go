@@ -0,0 +1,280 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrRecipeNotFound is returned when a recipe is not found
+	ErrRecipeNotFound = errors.New("recipe not found")
+	// ErrRecipeExists is returned when a user already has a recipe of the same name
+	ErrRecipeExists = errors.New("recipe already exists")
+)
+
+// Recipe is a recipe with its ingredients, in order
+type Recipe struct {
+	db.Recipe
+	Ingredients []db.RecipeIngredient
+}
+
+// RecipeIngredientFields are the fields of an ingredient; nutrition values are per 100 g
+type RecipeIngredientFields struct {
+	Name           string
+	QuantityG      float64
+	CaloriesKcal   float64
+	ProteinG       *float64 // Optional
+	CarbohydratesG *float64 // Optional
+	FatG           *float64 // Optional
+	FoodSource     string   // Food database the values come from, with FoodID; empty if entered
+	FoodID         string
+}
+
+// RecipeFields are the user-editable fields of a recipe
+type RecipeFields struct {
+	Name        string
+	Servings    int32
+	Ingredients []RecipeIngredientFields
+}
+
+// NutritionValues are the nutrition values of an amount of food. Macronutrients are nil unless
+// known for every ingredient.
+type NutritionValues struct {
+	CaloriesKcal   float64
+	ProteinG       *float64
+	CarbohydratesG *float64
+	FatG           *float64
+}
+
+// NutritionPerServing returns the nutrition values of one serving of the recipe, summed over its
+// ingredients by their quantities
+func (r Recipe) NutritionPerServing() NutritionValues {
+	var calories, protein, carbohydrates, fat float64
+	proteinKnown, carbohydratesKnown, fatKnown := true, true, true
+	for _, ingredient := range r.Ingredients {
+		factor := ingredient.QuantityG / 100
+		calories += ingredient.CaloriesKcal * factor
+		protein, proteinKnown = addNutrient(protein, proteinKnown, ingredient.ProteinG, factor)
+		carbohydrates, carbohydratesKnown = addNutrient(carbohydrates, carbohydratesKnown, ingredient.CarbohydratesG, factor)
+		fat, fatKnown = addNutrient(fat, fatKnown, ingredient.FatG, factor)
+	}
+
+	servings := float64(r.Servings)
+	values := NutritionValues{CaloriesKcal: calories / servings}
+	if proteinKnown {
+		values.ProteinG = perServing(protein, servings)
+	}
+	if carbohydratesKnown {
+		values.CarbohydratesG = perServing(carbohydrates, servings)
+	}
+	if fatKnown {
+		values.FatG = perServing(fat, servings)
+	}
+	return values
+}
+
+// addNutrient adds an ingredient's amount of a nutrient to a sum, which is no longer known once
+// an ingredient lacks it
+func addNutrient(sum float64, known bool, per100g pgtype.Float8, factor float64) (float64, bool) {
+	if !known || !per100g.Valid {
+		return 0, false
+	}
+	return sum + per100g.Float64*factor, true
+}
+
+func perServing(total, servings float64) *float64 {
+	v := total / servings
+	return &v
+}
+
+// RecipeRepository provides database operations for Recipe
+type RecipeRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewRecipeRepository creates a new PostgreSQL recipe repository
+func NewRecipeRepository(pool DB) *RecipeRepository {
+	return &RecipeRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// Create creates a recipe with its ingredients, accepting the current time. Returns
+// ErrRecipeExists if the user has a recipe of the same name, ignoring case.
+func (r *RecipeRepository) Create(ctx context.Context, userID uuid.UUID, fields RecipeFields, now time.Time) (Recipe, error) {
+	var recipe Recipe
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		created, err := q.CreateRecipe(ctx, db.CreateRecipeParams{
+			UserID:    userID,
+			Name:      fields.Name,
+			Servings:  fields.Servings,
+			CreatedAt: now,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrRecipeExists
+			}
+			return fmt.Errorf("failed to create recipe: %w", err)
+		}
+		recipe, err = createRecipeIngredients(ctx, q, created, fields.Ingredients)
+		return err
+	})
+	if err != nil {
+		return Recipe{}, err
+	}
+	return recipe, nil
+}
+
+// FindByID retrieves a user's recipe, returning ErrRecipeNotFound if the user has no such recipe
+func (r *RecipeRepository) FindByID(ctx context.Context, id, userID uuid.UUID) (Recipe, error) {
+	recipe, err := r.q.GetRecipe(ctx, db.GetRecipeParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Recipe{}, ErrRecipeNotFound
+		}
+		return Recipe{}, fmt.Errorf("failed to get recipe: %w", err)
+	}
+	recipes, err := r.withIngredients(ctx, []db.Recipe{recipe})
+	if err != nil {
+		return Recipe{}, err
+	}
+	return recipes[0], nil
+}
+
+// FindByUser retrieves the recipes of a user by name
+func (r *RecipeRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]Recipe, error) {
+	recipes, err := r.q.ListRecipesByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes: %w", err)
+	}
+	return r.withIngredients(ctx, recipes)
+}
+
+// CountByUser returns the number of recipes of a user
+func (r *RecipeRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountRecipesByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recipes: %w", err)
+	}
+	return count, nil
+}
+
+// Update replaces the fields and ingredients of a user's recipe, accepting the current time.
+// Returns ErrRecipeNotFound if the user has no such recipe, and ErrRecipeExists if the new name
+// is taken by another of their recipes.
+func (r *RecipeRepository) Update(ctx context.Context, id, userID uuid.UUID, fields RecipeFields, now time.Time) (Recipe, error) {
+	var recipe Recipe
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		updated, err := q.UpdateRecipe(ctx, db.UpdateRecipeParams{
+			ID:        id,
+			UserID:    userID,
+			Name:      fields.Name,
+			Servings:  fields.Servings,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrRecipeNotFound
+			}
+			if isUniqueViolation(err) {
+				return ErrRecipeExists
+			}
+			return fmt.Errorf("failed to update recipe: %w", err)
+		}
+		if err := q.DeleteRecipeIngredients(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete recipe ingredients: %w", err)
+		}
+		recipe, err = createRecipeIngredients(ctx, q, updated, fields.Ingredients)
+		return err
+	})
+	if err != nil {
+		return Recipe{}, err
+	}
+	return recipe, nil
+}
+
+// Delete moves a user's recipe to the trash at now with its ingredients, returning
+// ErrRecipeNotFound if the user has no such recipe
+func (r *RecipeRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashRecipe(ctx, db.TrashRecipeParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", err)
+	}
+	if deleted == 0 {
+		return ErrRecipeNotFound
+	}
+	return nil
+}
+
+// withIngredients fetches the ingredients of recipes with one query
+func (r *RecipeRepository) withIngredients(ctx context.Context, recipes []db.Recipe) ([]Recipe, error) {
+	if len(recipes) == 0 {
+		return []Recipe{}, nil
+	}
+	ids := make([]uuid.UUID, len(recipes))
+	for i, recipe := range recipes {
+		ids[i] = recipe.ID
+	}
+	ingredients, err := r.q.ListRecipeIngredients(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipe ingredients: %w", err)
+	}
+	byRecipe := make(map[uuid.UUID][]db.RecipeIngredient, len(recipes))
+	for _, ingredient := range ingredients {
+		byRecipe[ingredient.RecipeID] = append(byRecipe[ingredient.RecipeID], ingredient)
+	}
+
+	result := make([]Recipe, len(recipes))
+	for i, recipe := range recipes {
+		result[i] = Recipe{Recipe: recipe, Ingredients: byRecipe[recipe.ID]}
+	}
+	return result, nil
+}
+
+// createRecipeIngredients inserts the ingredients of a recipe in order
+func createRecipeIngredients(ctx context.Context, q *db.Queries, recipe db.Recipe, fields []RecipeIngredientFields) (Recipe, error) {
+	result := Recipe{Recipe: recipe, Ingredients: make([]db.RecipeIngredient, len(fields))}
+	for i, f := range fields {
+		params := db.CreateRecipeIngredientParams{
+			RecipeID:       recipe.ID,
+			Position:       int32(i),
+			Name:           f.Name,
+			QuantityG:      f.QuantityG,
+			CaloriesKcal:   f.CaloriesKcal,
+			ProteinG:       optionalFloat8(f.ProteinG),
+			CarbohydratesG: optionalFloat8(f.CarbohydratesG),
+			FatG:           optionalFloat8(f.FatG),
+			FoodSource:     pgtype.Text{String: f.FoodSource, Valid: f.FoodSource != ""},
+			FoodID:         pgtype.Text{String: f.FoodID, Valid: f.FoodID != ""},
+		}
+		if err := q.CreateRecipeIngredient(ctx, params); err != nil {
+			return Recipe{}, fmt.Errorf("failed to create recipe ingredient: %w", err)
+		}
+		result.Ingredients[i] = db.RecipeIngredient(params)
+	}
+	return result, nil
+}
+
+// optionalFloat8 converts an optional value to a nullable column
+func optionalFloat8(v *float64) pgtype.Float8 {
+	if v == nil {
+		return pgtype.Float8{}
+	}
+	return pgtype.Float8{Float64: *v, Valid: true}
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Entity types stored in record_changes
+const (
+	EntityTypeBodyRecord     = "body_record"
+	EntityTypeExerciseRecord = "exercise_record"
+	EntityTypeDiaryEntry     = "diary_entry"
+)
+
+// Actions stored in record_changes
+const (
+	ChangeActionCreated  = "created"
+	ChangeActionUpdated  = "updated"
+	ChangeActionImported = "imported"
+	ChangeActionMerged   = "merged"
+	ChangeActionDeleted  = "deleted"
+	ChangeActionRestored = "restored" // Restored from the trash
+)
+
+// ErrRecordCountChanged is returned by the bulk deletes when the records to delete are not the
+// expected number, e.g. because some were added since they were counted; nothing is deleted
+var ErrRecordCountChanged = errors.New("number of records to delete changed")
+
+// ChangeSourceUser is the source of changes made by the user through the API.
+// Imported changes use the import source instead, e.g. "healthkit" or "fitbit".
+const ChangeSourceUser = "user"
+
+// RecordChangeRepository provides read access to the change history of records
+type RecordChangeRepository struct {
+	q *db.Queries
+}
+
+// NewRecordChangeRepository creates a new PostgreSQL record change repository
+func NewRecordChangeRepository(pool DB) *RecordChangeRepository {
+	return &RecordChangeRepository{
+		q: db.New(pool),
+	}
+}
+
+// FindByRecord retrieves the change history of a user's record, oldest first
+func (r *RecordChangeRepository) FindByRecord(ctx context.Context, userID uuid.UUID, entityType string, entityID uuid.UUID) ([]db.RecordChange, error) {
+	changes, err := r.q.ListRecordChanges(ctx, db.ListRecordChangesParams{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   entityID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record changes: %w", err)
+	}
+	return changes, nil
+}
+
+// recordChange appends an entry to a record's change history and publishes it as an event;
+// details is optional
+func recordChange(ctx context.Context, q *db.Queries, userID uuid.UUID, entityType string, entityID uuid.UUID, action, source, details string, now time.Time) error {
+	err := q.CreateRecordChange(ctx, db.CreateRecordChangeParams{
+		UserID:     userID,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Source:     source,
+		Details:    pgtype.Text{String: details, Valid: details != ""},
+		ChangedAt:  now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record %s change: %w", entityType, err)
+	}
+	return publishEvent(ctx, q, userID, entityType+"."+action, RecordChangedEvent{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Source:     source,
+		Details:    details,
+	}, now)
+}
+
+// withTx runs fn with queries bound to a new transaction, committing if fn succeeds
+func withTx(ctx context.Context, pool DB, q *db.Queries, fn func(q *db.Queries) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // Rollback after Commit is a no-op
+
+	if err := fn(q.WithTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
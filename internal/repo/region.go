@@ -0,0 +1,138 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrUnknownRegion is returned for data regions without a database
+var ErrUnknownRegion = errors.New("unknown data region")
+
+// regionKey is the context key of the data region of WithRegion
+type regionKey struct{}
+
+// WithRegion returns a context whose statements a RegionalDB runs on the database of the data
+// region, e.g. for the RPCs of a user pinned to it; "" is the home database
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey{}, region)
+}
+
+// RegionOf returns the data region of a context of WithRegion; "" for the home database
+func RegionOf(ctx context.Context) string {
+	region, _ := ctx.Value(regionKey{}).(string)
+	return region
+}
+
+// RegionalDB is a DB running the statements of each context on the database of its data region,
+// and those of contexts without one on the home DB. Statements of a region without a database
+// fail with ErrUnknownRegion instead of falling back to the home DB, so records pinned to a
+// region never land anywhere else.
+type RegionalDB struct {
+	home    DB
+	regions map[string]DB
+}
+
+// NewRegionalDB creates a DB routing the statements of the data regions to their databases
+func NewRegionalDB(home DB, regions map[string]DB) *RegionalDB {
+	return &RegionalDB{home: home, regions: regions}
+}
+
+// NewRegionPools creates the connection pools of the data regions of cfg.Regions, with the pool
+// options of cfg
+func NewRegionPools(cfg *config.DatabaseConfig, log *slog.Logger) (map[string]*pgxpool.Pool, error) {
+	pools := make(map[string]*pgxpool.Pool, len(cfg.Regions))
+	for _, region := range cfg.Regions {
+		poolConfig, err := newPoolConfig(region.URL, cfg, log)
+		if err != nil {
+			closePools(pools)
+			return nil, fmt.Errorf("error parsing URL of region %s: %w", region.Name, err)
+		}
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			closePools(pools)
+			return nil, fmt.Errorf("error creating connection pool of region %s: %w", region.Name, err)
+		}
+		pools[region.Name] = pool
+	}
+	return pools, nil
+}
+
+// closePools closes the pools of NewRegionPools
+func closePools(pools map[string]*pgxpool.Pool) {
+	for _, pool := range pools {
+		pool.Close()
+	}
+}
+
+// Exec runs a statement on the database of the context's region
+func (r *RegionalDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	db, err := r.route(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return db.Exec(ctx, sql, args...)
+}
+
+// Query runs a query on the database of the context's region
+func (r *RegionalDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	db, err := r.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.Query(ctx, sql, args...)
+}
+
+// QueryRow runs a query returning a row on the database of the context's region
+func (r *RegionalDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	db, err := r.route(ctx)
+	if err != nil {
+		return &firstRow{err: err}
+	}
+	return db.QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction on the database of the context's region
+func (r *RegionalDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	db, err := r.route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.Begin(ctx)
+}
+
+// HasRegion reports whether the data region has a database
+func (r *RegionalDB) HasRegion(region string) bool {
+	_, ok := r.regions[region]
+	return ok
+}
+
+// Regions returns the data regions with a database, sorted by name
+func (r *RegionalDB) Regions() []string {
+	regions := make([]string, 0, len(r.regions))
+	for region := range r.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// route returns the DB of the context's region
+func (r *RegionalDB) route(ctx context.Context) (DB, error) {
+	region := RegionOf(ctx)
+	if region == "" {
+		return r.home, nil
+	}
+	db, ok := r.regions[region]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRegion, region)
+	}
+	return db, nil
+}
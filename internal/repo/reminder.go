@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrReminderNotFound is returned when a reminder is not found
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// ReminderRepository provides database operations for Reminder
+type ReminderRepository struct {
+	q *db.Queries
+}
+
+// NewReminderRepository creates a new PostgreSQL reminder repository
+func NewReminderRepository(pool DB) *ReminderRepository {
+	return &ReminderRepository{
+		q: db.New(pool),
+	}
+}
+
+// Create creates an enabled reminder firing first at nextFireAt, accepting the current time
+func (r *ReminderRepository) Create(ctx context.Context, userID uuid.UUID, title, message, schedule, timezone string, nextFireAt, now time.Time) (db.Reminder, error) {
+	reminder, err := r.q.CreateReminder(ctx, db.CreateReminderParams{
+		UserID:     userID,
+		Title:      title,
+		Message:    message,
+		Schedule:   schedule,
+		Timezone:   timezone,
+		Enabled:    true,
+		NextFireAt: pgtype.Timestamptz{Time: nextFireAt.UTC(), Valid: true},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return db.Reminder{}, fmt.Errorf("failed to create reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// FindByUser retrieves all reminders of a user, oldest first
+func (r *ReminderRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]db.Reminder, error) {
+	reminders, err := r.q.ListRemindersByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// CountByUser returns the number of reminders of a user
+func (r *ReminderRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountRemindersByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reminders: %w", err)
+	}
+	return count, nil
+}
+
+// Update replaces the fields of a user's reminder, accepting the current time. nextFireAt
+// is nil for disabled reminders. Returns ErrReminderNotFound if the user has no such reminder.
+func (r *ReminderRepository) Update(ctx context.Context, id, userID uuid.UUID, title, message, schedule, timezone string, enabled bool, nextFireAt *time.Time, now time.Time) (db.Reminder, error) {
+	var nextFireAtVal pgtype.Timestamptz
+	if nextFireAt != nil {
+		nextFireAtVal = pgtype.Timestamptz{Time: nextFireAt.UTC(), Valid: true}
+	}
+
+	reminder, err := r.q.UpdateReminder(ctx, db.UpdateReminderParams{
+		ID:         id,
+		UserID:     userID,
+		Title:      title,
+		Message:    message,
+		Schedule:   schedule,
+		Timezone:   timezone,
+		Enabled:    enabled,
+		NextFireAt: nextFireAtVal,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Reminder{}, ErrReminderNotFound
+		}
+		return db.Reminder{}, fmt.Errorf("failed to update reminder: %w", err)
+	}
+	return reminder, nil
+}
+
+// Delete moves a user's reminder to the trash at now, returning ErrReminderNotFound if the user
+// has no such reminder
+func (r *ReminderRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashReminder(ctx, db.TrashReminderParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete reminder: %w", err)
+	}
+	if deleted == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// ClaimDue claims up to limit enabled reminders due at now. Claimed reminders are not claimed
+// again before leaseUntil unless they are marked fired.
+func (r *ReminderRepository) ClaimDue(ctx context.Context, limit int32, now, leaseUntil time.Time) ([]db.Reminder, error) {
+	reminders, err := r.q.ClaimDueReminders(ctx, db.ClaimDueRemindersParams{
+		LeaseUntil: leaseUntil.UTC(),
+		Now:        now.UTC(),
+		MaxCount:   limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// MarkFired records that a reminder claimed until leaseUntil fired at firedAt and schedules
+// its next occurrence; nil stops it from firing again. Reminders updated since they were
+// claimed keep their new schedule.
+func (r *ReminderRepository) MarkFired(ctx context.Context, id uuid.UUID, firedAt time.Time, nextFireAt *time.Time, leaseUntil time.Time) error {
+	var nextFireAtVal pgtype.Timestamptz
+	if nextFireAt != nil {
+		nextFireAtVal = pgtype.Timestamptz{Time: nextFireAt.UTC(), Valid: true}
+	}
+
+	err := r.q.MarkReminderFired(ctx, db.MarkReminderFiredParams{
+		FiredAt:    firedAt.UTC(),
+		NextFireAt: nextFireAtVal,
+		ID:         id,
+		LeaseUntil: leaseUntil.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark reminder fired: %w", err)
+	}
+	return nil
+}
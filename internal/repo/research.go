@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Statuses of research exports
+const (
+	ResearchExportPending   = "pending"
+	ResearchExportRunning   = "running"
+	ResearchExportCompleted = "completed"
+	ResearchExportFailed    = "failed"
+)
+
+// ErrResearchExportNotFound is returned when a research export is not found
+var ErrResearchExportNotFound = errors.New("research export not found")
+
+// ResearchDataset is a dataset written by a research export
+type ResearchDataset struct {
+	Name string `json:"name"` // e.g. "body_metrics"
+	Key  string `json:"key"`  // Key of the dataset in the attachment store
+	Rows int    `json:"rows"`
+}
+
+// ResearchExportResult is the outcome of a completed research export
+type ResearchExportResult struct {
+	Contributors     int
+	SuppressedGroups int
+	Datasets         []ResearchDataset
+}
+
+// ResearchData is the data of the users who opted in to research, aggregated by month, before
+// groups of too few users are suppressed
+type ResearchData struct {
+	Contributors     int64
+	BodyMetrics      []db.ListResearchBodyMetricsRow
+	ExerciseActivity []db.ListResearchExerciseActivityRow
+	DailySteps       []db.ListResearchDailyStepsRow
+}
+
+// ResearchRepository provides database operations for research exports and the users' opt-in
+type ResearchRepository struct {
+	q *db.Queries
+}
+
+// NewResearchRepository creates a new PostgreSQL research repository
+func NewResearchRepository(pool DB) *ResearchRepository {
+	return &ResearchRepository{
+		q: db.New(pool),
+	}
+}
+
+// GetOptIn reports whether a user opted in to research exports
+func (r *ResearchRepository) GetOptIn(ctx context.Context, userID uuid.UUID) (bool, error) {
+	optIn, err := r.q.GetUserResearchOptIn(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, fmt.Errorf("failed to get research opt-in: %w", err)
+	}
+	return optIn, nil
+}
+
+// SetOptIn opts a user in to research exports, or back out
+func (r *ResearchRepository) SetOptIn(ctx context.Context, userID uuid.UUID, optIn bool) (bool, error) {
+	optIn, err := r.q.SetUserResearchOptIn(ctx, db.SetUserResearchOptInParams{
+		ID:            userID,
+		ResearchOptIn: optIn,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, fmt.Errorf("failed to set research opt-in: %w", err)
+	}
+	return optIn, nil
+}
+
+// CreateExport queues an export of the data of the days from startDate to endDate, aggregating
+// at least minGroupSize users per row. Accepts the current time.
+func (r *ResearchRepository) CreateExport(ctx context.Context, requestedBy uuid.UUID, startDate, endDate time.Time, minGroupSize int, now time.Time) (db.ResearchExport, error) {
+	export, err := r.q.CreateResearchExport(ctx, db.CreateResearchExportParams{
+		RequestedBy:  requestedBy,
+		StartDate:    pgtype.Date{Time: startDate, Valid: true},
+		EndDate:      pgtype.Date{Time: endDate, Valid: true},
+		MinGroupSize: int32(minGroupSize),
+		Now:          now,
+	})
+	if err != nil {
+		return db.ResearchExport{}, fmt.Errorf("failed to create research export: %w", err)
+	}
+	return export, nil
+}
+
+// GetExport retrieves a research export by ID
+func (r *ResearchRepository) GetExport(ctx context.Context, id uuid.UUID) (db.ResearchExport, error) {
+	export, err := r.q.GetResearchExport(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ResearchExport{}, ErrResearchExportNotFound
+		}
+		return db.ResearchExport{}, fmt.Errorf("failed to get research export: %w", err)
+	}
+	return export, nil
+}
+
+// ListExports retrieves research exports, newest first, with pagination
+func (r *ResearchRepository) ListExports(ctx context.Context, limit, offset int) ([]db.ResearchExport, error) {
+	exports, err := r.q.ListResearchExports(ctx, db.ListResearchExportsParams{
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list research exports: %w", err)
+	}
+	return exports, nil
+}
+
+// CountExports counts all research exports
+func (r *ResearchRepository) CountExports(ctx context.Context) (int64, error) {
+	count, err := r.q.CountResearchExports(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count research exports: %w", err)
+	}
+	return count, nil
+}
+
+// ClaimExport claims the oldest export due at now until leaseUntil, so a job that stops midway
+// leaves it to be retried. It returns false when no export is due.
+func (r *ResearchRepository) ClaimExport(ctx context.Context, now, leaseUntil time.Time) (db.ResearchExport, bool, error) {
+	export, err := r.q.ClaimResearchExport(ctx, db.ClaimResearchExportParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.ResearchExport{}, false, nil
+		}
+		return db.ResearchExport{}, false, fmt.Errorf("failed to claim research export: %w", err)
+	}
+	return export, true, nil
+}
+
+// Data aggregates the data of the days from startDate to endDate of the users who opted in
+func (r *ResearchRepository) Data(ctx context.Context, startDate, endDate time.Time) (ResearchData, error) {
+	start := pgtype.Date{Time: startDate, Valid: true}
+	end := pgtype.Date{Time: endDate, Valid: true}
+	var data ResearchData
+	var err error
+	if data.Contributors, err = r.q.CountResearchContributors(ctx, db.CountResearchContributorsParams{StartDate: start, EndDate: end}); err != nil {
+		return ResearchData{}, fmt.Errorf("failed to count research contributors: %w", err)
+	}
+	if data.BodyMetrics, err = r.q.ListResearchBodyMetrics(ctx, db.ListResearchBodyMetricsParams{StartDate: start, EndDate: end}); err != nil {
+		return ResearchData{}, fmt.Errorf("failed to aggregate body records: %w", err)
+	}
+	if data.ExerciseActivity, err = r.q.ListResearchExerciseActivity(ctx, db.ListResearchExerciseActivityParams{StartDate: start, EndDate: end}); err != nil {
+		return ResearchData{}, fmt.Errorf("failed to aggregate exercise records: %w", err)
+	}
+	if data.DailySteps, err = r.q.ListResearchDailySteps(ctx, db.ListResearchDailyStepsParams{StartDate: start, EndDate: end}); err != nil {
+		return ResearchData{}, fmt.Errorf("failed to aggregate step records: %w", err)
+	}
+	return data, nil
+}
+
+// CompleteExport records the result of an export, accepting the current time
+func (r *ResearchRepository) CompleteExport(ctx context.Context, id uuid.UUID, result ResearchExportResult, now time.Time) error {
+	datasets, err := json.Marshal(result.Datasets)
+	if err != nil {
+		return fmt.Errorf("failed to encode research datasets: %w", err)
+	}
+	err = r.q.CompleteResearchExport(ctx, db.CompleteResearchExportParams{
+		ID:               id,
+		Contributors:     int32(result.Contributors),
+		SuppressedGroups: int32(result.SuppressedGroups),
+		Datasets:         datasets,
+		CompletedAt:      pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete research export: %w", err)
+	}
+	return nil
+}
+
+// RetryExport records a failed attempt and retries the export at nextAttemptAt
+func (r *ResearchRepository) RetryExport(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastError string) error {
+	err := r.q.RetryResearchExport(ctx, db.RetryResearchExportParams{
+		ID:            id,
+		NextAttemptAt: nextAttemptAt,
+		LastError:     pgtype.Text{String: lastError, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to retry research export: %w", err)
+	}
+	return nil
+}
+
+// FailExport gives up on an export, accepting the current time
+func (r *ResearchRepository) FailExport(ctx context.Context, id uuid.UUID, lastError string, now time.Time) error {
+	err := r.q.FailResearchExport(ctx, db.FailResearchExportParams{
+		ID:          id,
+		LastError:   pgtype.Text{String: lastError, Valid: true},
+		CompletedAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark research export failed: %w", err)
+	}
+	return nil
+}
+
+// ResearchDatasets decodes the datasets written by a completed export
+func ResearchDatasets(export db.ResearchExport) ([]ResearchDataset, error) {
+	var datasets []ResearchDataset
+	if err := json.Unmarshal(export.Datasets, &datasets); err != nil {
+		return nil, fmt.Errorf("failed to decode research datasets: %w", err)
+	}
+	return datasets, nil
+}
@@ -0,0 +1,376 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/metrics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrDatabaseUnavailable is returned without running the statement while the circuit is open
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+
+// ResilientDB is a DB retrying statements that fail with transient errors, and failing them
+// fast while the database is down. Reads are retried on any transient error; writes only when
+// they are known not to have reached the database, so they never run twice. Statements of
+// transactions are not retried, as their transaction is aborted; beginning one is.
+//
+// Statements failing because the primary failed over, transactions included, reset the pool of
+// the DB if it has a Reset method, like *pgxpool.Pool and *Router, so connections to the former
+// primary are replaced by connections to the current one instead of failing until they expire.
+type ResilientDB struct {
+	db          DB
+	maxAttempts int
+	backoff     time.Duration
+	breaker     *circuitBreaker
+	log         *slog.Logger
+
+	clock       clock.Clock
+	failoverMu  sync.Mutex
+	lastResetAt time.Time // Of the pool, after the last failover
+}
+
+// failoverResetInterval is the time after a pool reset during which further failover errors,
+// e.g. of statements running on the replaced connections, don't reset the pool again
+const failoverResetInterval = time.Second
+
+// resetter is a DB whose connections can be closed and replaced
+type resetter interface {
+	Reset()
+}
+
+// NewResilientDB wraps db with the retry policy and circuit breaker of cfg
+func NewResilientDB(db DB, cfg *config.DatabaseConfig, log *slog.Logger, clock clock.Clock) *ResilientDB {
+	return &ResilientDB{
+		db:          db,
+		maxAttempts: cfg.Retry.MaxAttempts,
+		backoff:     cfg.Retry.Backoff,
+		breaker: &circuitBreaker{
+			threshold: cfg.CircuitBreaker.FailureThreshold,
+			cooldown:  cfg.CircuitBreaker.Cooldown,
+			clock:     clock,
+		},
+		log:   log,
+		clock: clock,
+	}
+}
+
+// WithUnavailableTracking returns a context recording whether a statement run with it failed
+// because the database was unavailable, for DatabaseUnavailable
+func WithUnavailableTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unavailableKey{}, &atomic.Bool{})
+}
+
+// DatabaseUnavailable reports whether a statement run with a context of WithUnavailableTracking
+// failed because the database was unavailable, after its retries
+func DatabaseUnavailable(ctx context.Context) bool {
+	unavailable, ok := ctx.Value(unavailableKey{}).(*atomic.Bool)
+	return ok && unavailable.Load()
+}
+
+// unavailableKey is the context key of the flag of WithUnavailableTracking
+type unavailableKey struct{}
+
+// Exec runs a statement, retrying it while it fails with a transient error
+func (r *ResilientDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+	err := r.do(ctx, sql, func() error {
+		var err error
+		tag, err = r.db.Exec(ctx, sql, args...)
+		return err
+	})
+	return tag, err
+}
+
+// Query runs a query, retrying it while it fails with a transient error before returning rows
+func (r *ResilientDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	var rows pgx.Rows
+	err := r.do(ctx, sql, func() error {
+		var err error
+		rows, err = r.db.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRow runs a query returning a row; the query runs, and is retried, when the row is scanned
+func (r *ResilientDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &retryRow{r: r, ctx: ctx, sql: sql, args: args}
+}
+
+// Begin starts a transaction, retrying while it fails with a transient error. Its statements are
+// not retried, but reset the pool when they fail because the primary failed over.
+func (r *ResilientDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	var tx pgx.Tx
+	err := r.do(ctx, "BEGIN", func() error {
+		var err error
+		tx, err = r.db.Begin(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &failoverTx{Tx: tx, r: r}, nil
+}
+
+// do runs attempt until it succeeds, fails with an error that is not retryable for sql, or used
+// up its attempts, waiting with exponential backoff between attempts
+func (r *ResilientDB) do(ctx context.Context, sql string, attempt func() error) error {
+	if !r.breaker.allow() {
+		markUnavailable(ctx)
+		return ErrDatabaseUnavailable
+	}
+	backoff := r.backoff
+	for i := 1; ; i++ {
+		err := attempt()
+		r.checkFailover(ctx, err)
+		if err == nil || !isUnavailable(err) {
+			// Errors of the statement itself, e.g. constraint violations, show the database is up
+			r.breaker.record(true)
+			return err
+		}
+		if i >= r.maxAttempts || !retryable(sql, err) || ctx.Err() != nil {
+			if r.breaker.record(false) {
+				r.log.ErrorContext(ctx, "Database unavailable, failing statements fast", "cooldown", r.breaker.cooldown, "error", err)
+			}
+			markUnavailable(ctx)
+			return err
+		}
+		r.log.WarnContext(ctx, "Retrying statement after transient error", "attempt", i, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			markUnavailable(ctx)
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// checkFailover resets the pool of the DB if err shows the primary failed over, unless it was
+// reset less than failoverResetInterval ago
+func (r *ResilientDB) checkFailover(ctx context.Context, err error) {
+	if !isFailover(err) {
+		return
+	}
+	r.failoverMu.Lock()
+	defer r.failoverMu.Unlock()
+	now := r.clock.Now()
+	if !r.lastResetAt.IsZero() && now.Sub(r.lastResetAt) < failoverResetInterval {
+		return
+	}
+	r.lastResetAt = now
+	metrics.DBFailovers.Inc()
+	if pool, ok := r.db.(resetter); ok {
+		pool.Reset()
+	}
+	r.log.WarnContext(ctx, "Database failover detected, resetting connection pool", "error", err)
+}
+
+// isFailover reports whether err shows the primary is no longer the server the pool connects
+// to: a write reached a read-only server, as after a demotion, or the server shut down
+func isFailover(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "25006", "57P01", "57P02": // read_only_sql_transaction, admin_shutdown, crash_shutdown
+		return true
+	}
+	return false
+}
+
+// markUnavailable records an unavailable database in a context of WithUnavailableTracking
+func markUnavailable(ctx context.Context) {
+	if unavailable, ok := ctx.Value(unavailableKey{}).(*atomic.Bool); ok {
+		unavailable.Store(true)
+	}
+}
+
+// isUnavailable reports whether err shows the database or the connection to it failing, as
+// during a failover or restart, rather than the statement
+func isUnavailable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return true
+		case pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		case pgErr.Code == "25006": // read_only_sql_transaction: a write reached a demoted primary
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryable reports whether a statement failing with an unavailable error can run again: reads
+// always, writes when they didn't run, because they weren't sent or the server refused them
+func retryable(sql string, err error) bool {
+	if isRead(sql) || pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "25006" || pgErr.Code == "57P03")
+}
+
+// failoverTx is a transaction of ResilientDB.Begin, checking the errors of its statements for
+// failovers
+type failoverTx struct {
+	pgx.Tx
+	r *ResilientDB
+}
+
+// Exec runs a statement in the transaction
+func (tx *failoverTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := tx.Tx.Exec(ctx, sql, args...)
+	tx.r.checkFailover(ctx, err)
+	return tag, err
+}
+
+// Query runs a query in the transaction
+func (tx *failoverTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := tx.Tx.Query(ctx, sql, args...)
+	if err != nil {
+		tx.r.checkFailover(ctx, err)
+		return nil, err
+	}
+	return &failoverRows{Rows: rows, r: tx.r, ctx: ctx}, nil
+}
+
+// QueryRow runs a query returning a row in the transaction
+func (tx *failoverTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &failoverRow{row: tx.Tx.QueryRow(ctx, sql, args...), r: tx.r, ctx: ctx}
+}
+
+// CopyFrom copies rows into a table in the transaction
+func (tx *failoverTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	n, err := tx.Tx.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	tx.r.checkFailover(ctx, err)
+	return n, err
+}
+
+// Commit commits the transaction
+func (tx *failoverTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	tx.r.checkFailover(ctx, err)
+	return err
+}
+
+// failoverRows are the rows of a query of a failoverTx, whose errors are reported after reading
+type failoverRows struct {
+	pgx.Rows
+	r   *ResilientDB
+	ctx context.Context
+}
+
+// Err returns the error of the query, if any
+func (rows *failoverRows) Err() error {
+	err := rows.Rows.Err()
+	rows.r.checkFailover(rows.ctx, err)
+	return err
+}
+
+// failoverRow is the row of a query of a failoverTx
+type failoverRow struct {
+	row pgx.Row
+	r   *ResilientDB
+	ctx context.Context
+}
+
+// Scan scans the row
+func (row *failoverRow) Scan(dest ...any) error {
+	err := row.row.Scan(dest...)
+	row.r.checkFailover(row.ctx, err)
+	return err
+}
+
+// retryRow is the pgx.Row of ResilientDB.QueryRow
+type retryRow struct {
+	r    *ResilientDB
+	ctx  context.Context
+	sql  string
+	args []interface{}
+}
+
+// Scan runs the query and scans its row, retrying the query while it fails with a transient error
+func (row *retryRow) Scan(dest ...any) error {
+	return row.r.do(row.ctx, row.sql, func() error {
+		return row.r.db.QueryRow(row.ctx, row.sql, row.args...).Scan(dest...)
+	})
+}
+
+// circuitBreaker opens after threshold consecutive failures, rejecting statements for cooldown.
+// Then it lets one statement probe the database: its success closes the circuit, its failure
+// opens it for another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	clock     clock.Clock
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time // Zero while closed
+	probing  bool
+}
+
+// allow reports whether a statement may run
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || b.clock.Now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the outcome of a statement, returning true when it opened the circuit
+func (b *circuitBreaker) record(ok bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasProbing := b.probing
+	b.probing = false
+	if ok {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return false
+	}
+	b.failures++
+	if wasProbing {
+		b.openedAt = b.clock.Now()
+		return false
+	}
+	if b.openedAt.IsZero() && b.failures >= b.threshold {
+		b.openedAt = b.clock.Now()
+		return true
+	}
+	return false
+}
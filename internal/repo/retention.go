@@ -0,0 +1,222 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RetentionReport counts the data past the retention policy, of users who didn't opt out except
+// for the API usage of all users
+type RetentionReport struct {
+	ExpiredRecordChanges      int64
+	ExpiredAPIUsage           int64
+	ArchivableBodyRecords     int64
+	ArchivableAttachments     int64
+	ArchivableExerciseRecords int64
+}
+
+// RetentionRepository provides database operations for the data retention policy
+type RetentionRepository struct {
+	pool   DB
+	q      *db.Queries
+	counts CountCaches
+}
+
+// NewRetentionRepository creates a new PostgreSQL retention repository
+func NewRetentionRepository(pool DB) *RetentionRepository {
+	return &RetentionRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// NewCachedRetentionRepository creates a PostgreSQL retention repository evicting the counts of the
+// users it archives records of from counts
+func NewCachedRetentionRepository(pool DB, counts CountCaches) *RetentionRepository {
+	return &RetentionRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		counts: counts,
+	}
+}
+
+// GetOptOut reports whether a user opted out of the retention policy
+func (r *RetentionRepository) GetOptOut(ctx context.Context, userID uuid.UUID) (bool, error) {
+	optOut, err := r.q.GetUserRetentionOptOut(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, fmt.Errorf("failed to get retention opt-out: %w", err)
+	}
+	return optOut, nil
+}
+
+// SetOptOut opts a user out of or back into the retention policy
+func (r *RetentionRepository) SetOptOut(ctx context.Context, userID uuid.UUID, optOut bool) (bool, error) {
+	optOut, err := r.q.SetUserRetentionOptOut(ctx, db.SetUserRetentionOptOutParams{
+		ID:              userID,
+		RetentionOptOut: optOut,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, ErrUserNotFound
+		}
+		return false, fmt.Errorf("failed to set retention opt-out: %w", err)
+	}
+	return optOut, nil
+}
+
+// Report counts the record changes made before changeCutoff, the daily request counts of days
+// before usageCutoff and the records dated before recordCutoff; a zero cutoff counts nothing
+func (r *RetentionRepository) Report(ctx context.Context, changeCutoff, usageCutoff, recordCutoff time.Time) (RetentionReport, error) {
+	var report RetentionReport
+	var err error
+	if !changeCutoff.IsZero() {
+		if report.ExpiredRecordChanges, err = r.q.CountExpiredRecordChanges(ctx, changeCutoff); err != nil {
+			return RetentionReport{}, fmt.Errorf("failed to count expired record changes: %w", err)
+		}
+	}
+	if !usageCutoff.IsZero() {
+		if report.ExpiredAPIUsage, err = r.q.CountExpiredAPIUsage(ctx, pgtype.Date{Time: usageCutoff, Valid: true}); err != nil {
+			return RetentionReport{}, fmt.Errorf("failed to count expired API usage: %w", err)
+		}
+	}
+	if !recordCutoff.IsZero() {
+		if report.ArchivableBodyRecords, err = r.q.CountArchivableBodyRecords(ctx, pgtype.Date{Time: recordCutoff, Valid: true}); err != nil {
+			return RetentionReport{}, fmt.Errorf("failed to count archivable body records: %w", err)
+		}
+		if report.ArchivableAttachments, err = r.q.CountArchivableAttachments(ctx, pgtype.Date{Time: recordCutoff, Valid: true}); err != nil {
+			return RetentionReport{}, fmt.Errorf("failed to count archivable attachments: %w", err)
+		}
+		if report.ArchivableExerciseRecords, err = r.q.CountArchivableExerciseRecords(ctx, recordCutoff); err != nil {
+			return RetentionReport{}, fmt.Errorf("failed to count archivable exercise records: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// PurgeRecordChanges deletes up to batchSize record changes made before cutoff, returning how
+// many were deleted
+func (r *RetentionRepository) PurgeRecordChanges(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+	deleted, err := r.q.DeleteExpiredRecordChanges(ctx, db.DeleteExpiredRecordChangesParams{
+		Cutoff:    cutoff,
+		BatchSize: batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge record changes: %w", err)
+	}
+	return deleted, nil
+}
+
+// PurgeAPIUsage deletes up to batchSize daily request counts of days before cutoff, returning
+// how many were deleted
+func (r *RetentionRepository) PurgeAPIUsage(ctx context.Context, cutoff time.Time, batchSize int32) (int64, error) {
+	deleted, err := r.q.DeleteExpiredAPIUsage(ctx, db.DeleteExpiredAPIUsageParams{
+		Cutoff:    pgtype.Date{Time: cutoff, Valid: true},
+		BatchSize: batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge API usage: %w", err)
+	}
+	return deleted, nil
+}
+
+// ArchiveBodyRecords passes up to batchSize body records dated before cutoff and their
+// attachments to archive and deletes them once it succeeded, returning how many records were
+// archived and the deleted attachments, whose files are left to the caller. The records stay
+// locked while archive runs, so concurrent jobs archive other records.
+func (r *RetentionRepository) ArchiveBodyRecords(ctx context.Context, cutoff time.Time, batchSize int32, archive func([]db.BodyRecord, []db.Attachment) error) (int, []db.Attachment, error) {
+	var archived int
+	var attachments []db.Attachment
+	var userIDs []uuid.UUID
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		date := pgtype.Date{Time: cutoff, Valid: true}
+		records, err := q.ListArchivableBodyRecordsForUpdate(ctx, db.ListArchivableBodyRecordsForUpdateParams{
+			Cutoff:    date,
+			BatchSize: batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list archivable body records: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(records))
+		for i, record := range records {
+			ids[i] = record.ID
+		}
+		// The attachments are deleted with their records by the foreign key
+		recordAttachments, err := q.ListArchivableAttachmentsForUpdate(ctx, ids)
+		if err != nil {
+			return fmt.Errorf("failed to list archivable attachments: %w", err)
+		}
+		if err := archive(records, recordAttachments); err != nil {
+			return err
+		}
+		if err := q.DeleteArchivedBodyRecords(ctx, db.DeleteArchivedBodyRecordsParams{Ids: ids, Cutoff: date}); err != nil {
+			return fmt.Errorf("failed to delete archived body records: %w", err)
+		}
+		archived = len(records)
+		attachments = recordAttachments
+		for _, record := range records {
+			userIDs = append(userIDs, record.UserID)
+		}
+		return nil
+	})
+	r.invalidate(userIDs, err)
+	return archived, attachments, err
+}
+
+// ArchiveExerciseRecords is ArchiveBodyRecords for exercise records recorded before cutoff
+func (r *RetentionRepository) ArchiveExerciseRecords(ctx context.Context, cutoff time.Time, batchSize int32, archive func([]db.ExerciseRecord) error) (int, error) {
+	var archived int
+	var userIDs []uuid.UUID
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		records, err := q.ListArchivableExerciseRecordsForUpdate(ctx, db.ListArchivableExerciseRecordsForUpdateParams{
+			Cutoff:    cutoff,
+			BatchSize: batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list archivable exercise records: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := archive(records); err != nil {
+			return err
+		}
+		ids := make([]uuid.UUID, len(records))
+		for i, record := range records {
+			ids[i] = record.ID
+		}
+		if err := q.DeleteArchivedExerciseRecords(ctx, db.DeleteArchivedExerciseRecordsParams{Ids: ids, Cutoff: cutoff}); err != nil {
+			return fmt.Errorf("failed to delete archived exercise records: %w", err)
+		}
+		archived = len(records)
+		for _, record := range records {
+			userIDs = append(userIDs, record.UserID)
+		}
+		return nil
+	})
+	r.invalidate(userIDs, err)
+	return archived, err
+}
+
+// invalidate evicts the counts of the users whose records were archived, unless archiving failed
+// and rolled back
+func (r *RetentionRepository) invalidate(userIDs []uuid.UUID, err error) {
+	if err != nil {
+		return
+	}
+	for _, userID := range userIDs {
+		r.counts.Invalidate(userID)
+	}
+}
@@ -0,0 +1,268 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaCheckTimeout bounds a health check of a replica
+const replicaCheckTimeout = 5 * time.Second
+
+// replicaLagQuery returns the replication lag of a replica in seconds: 0 when it replayed all
+// WAL it received, e.g. while the primary is idle, or when it is not a replica at all
+const replicaLagQuery = `SELECT CASE
+    WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END::float8`
+
+var (
+	// readStatement matches statements starting with SELECT or WITH, after comments
+	readStatement = regexp.MustCompile(`(?is)^\s*(--[^\n]*\n\s*)*(SELECT|WITH)\b`)
+	// writeKeyword matches data-modifying statements, including CTEs, and row locks
+	writeKeyword = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE)\b|\bFOR\s+(NO\s+KEY\s+|KEY\s+)?(UPDATE|SHARE)\b`)
+	// readStatements caches whether each statement is a read
+	readStatements sync.Map
+)
+
+// Router is a DB sending writes and transactions to the primary and spreading reads over the
+// healthy replicas. Only reads made with a context from WithReplicaReads go to replicas, and
+// once such a context was used for a write, its later reads go to the primary too, so requests
+// read their own writes. Reads fall back to the primary while no replica is healthy.
+type Router struct {
+	primary  *pgxpool.Pool
+	replicas []*replica
+	maxLag   time.Duration
+	period   time.Duration
+	next     atomic.Uint32
+	log      *slog.Logger
+}
+
+// replica is a replica pool and its last health check result
+type replica struct {
+	host    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// NewRouter creates a router for the primary pool and the replicas of cfg.ReplicaURLs, with the
+// pool options of cfg. Replicas are checked before it returns; unreachable replicas are skipped
+// until a later check finds them healthy.
+func NewRouter(primary *pgxpool.Pool, cfg *config.DatabaseConfig, log *slog.Logger) (*Router, error) {
+	r := &Router{
+		primary: primary,
+		maxLag:  cfg.ReplicaMaxLag,
+		period:  cfg.HealthCheckPeriod,
+		log:     log,
+	}
+	for i, url := range cfg.ReplicaURLs {
+		poolConfig, err := newPoolConfig(url, cfg, log)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("error parsing URL of replica %d: %w", i+1, err)
+		}
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("error creating connection pool of replica %d: %w", i+1, err)
+		}
+		r.replicas = append(r.replicas, &replica{host: poolConfig.ConnConfig.Host, pool: pool})
+	}
+	r.checkReplicas(context.Background())
+	return r, nil
+}
+
+// WithReplicaReads returns a context whose reads may be sent to replicas, e.g. for an RPC
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readStateKey{}, &readState{})
+}
+
+// readStateKey is the context key of the readState of WithReplicaReads
+type readStateKey struct{}
+
+// readState records whether a context was used for a write
+type readState struct {
+	wrote atomic.Bool
+}
+
+// Exec runs a statement on the primary
+func (r *Router) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	r.markWrite(ctx)
+	return r.primary.Exec(ctx, sql, args...)
+}
+
+// Query runs a query on a replica if it is a read, falling back to the primary when the replica
+// can't be reached
+func (r *Router) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	replica := r.route(ctx, sql)
+	if replica == nil {
+		return r.primary.Query(ctx, sql, args...)
+	}
+	return r.queryReplica(ctx, replica, sql, args...)
+}
+
+// QueryRow runs a query returning a row like Query
+func (r *Router) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	replica := r.route(ctx, sql)
+	if replica == nil {
+		return r.primary.QueryRow(ctx, sql, args...)
+	}
+	rows, err := r.queryReplica(ctx, replica, sql, args...)
+	return &firstRow{rows: rows, err: err}
+}
+
+// Begin starts a transaction on the primary
+func (r *Router) Begin(ctx context.Context) (pgx.Tx, error) {
+	r.markWrite(ctx)
+	return r.primary.Begin(ctx)
+}
+
+// Run checks the health of the replicas every health check period until ctx is done
+func (r *Router) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkReplicas(ctx)
+		}
+	}
+}
+
+// ReplicaPools returns the pools of the replicas, healthy or not
+func (r *Router) ReplicaPools() []*pgxpool.Pool {
+	pools := make([]*pgxpool.Pool, len(r.replicas))
+	for i, replica := range r.replicas {
+		pools[i] = replica.pool
+	}
+	return pools
+}
+
+// Reset closes the connections of the primary pool, replacing them as they are needed, e.g. after
+// the primary failed over. The replicas keep theirs: their health checks skip replicas that fail.
+func (r *Router) Reset() {
+	r.primary.Reset()
+}
+
+// Close closes the replica pools; the primary pool is closed by its owner
+func (r *Router) Close() {
+	for _, replica := range r.replicas {
+		replica.pool.Close()
+	}
+}
+
+// route returns the replica to run sql on, or nil for the primary
+func (r *Router) route(ctx context.Context, sql string) *replica {
+	state, _ := ctx.Value(readStateKey{}).(*readState)
+	if state == nil || state.wrote.Load() {
+		return nil
+	}
+	if !isRead(sql) {
+		state.wrote.Store(true)
+		return nil
+	}
+	// Round robin over the healthy replicas
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		replica := r.replicas[(int(start)+i)%len(r.replicas)]
+		if replica.healthy.Load() {
+			return replica
+		}
+	}
+	return nil
+}
+
+// queryReplica runs a query on a replica, retrying it on the primary if it failed before
+// reaching the replica
+func (r *Router) queryReplica(ctx context.Context, replica *replica, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := replica.pool.Query(ctx, sql, args...)
+	if err != nil && pgconn.SafeToRetry(err) {
+		r.markUnhealthy(replica, err)
+		return r.primary.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+// markWrite makes later reads of a WithReplicaReads context go to the primary
+func (r *Router) markWrite(ctx context.Context) {
+	if state, ok := ctx.Value(readStateKey{}).(*readState); ok {
+		state.wrote.Store(true)
+	}
+}
+
+// isRead reports whether sql only reads, caching the result per statement
+func isRead(sql string) bool {
+	if read, ok := readStatements.Load(sql); ok {
+		return read.(bool)
+	}
+	read := readStatement.MatchString(sql) && !writeKeyword.MatchString(sql)
+	readStatements.Store(sql, read)
+	return read
+}
+
+// checkReplicas marks the replicas healthy that answer and lag at most maxLag behind the primary
+func (r *Router) checkReplicas(ctx context.Context) {
+	for _, replica := range r.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+		var lagSeconds float64
+		err := replica.pool.QueryRow(checkCtx, replicaLagQuery).Scan(&lagSeconds)
+		cancel()
+		if err != nil {
+			r.markUnhealthy(replica, err)
+			continue
+		}
+		lag := time.Duration(lagSeconds * float64(time.Second))
+		if lag > r.maxLag {
+			if replica.healthy.Swap(false) {
+				r.log.WarnContext(ctx, "Replica lagging, reading from primary", "host", replica.host, "lag", lag)
+			}
+			continue
+		}
+		if !replica.healthy.Swap(true) {
+			r.log.InfoContext(ctx, "Replica healthy", "host", replica.host, "lag", lag)
+		}
+	}
+}
+
+// markUnhealthy stops routing reads to a replica until its next successful health check
+func (r *Router) markUnhealthy(replica *replica, err error) {
+	if replica.healthy.Swap(false) {
+		r.log.Warn("Replica unreachable, reading from primary", "host", replica.host, "error", err)
+	}
+}
+
+// firstRow is the pgx.Row of the first row of rows
+type firstRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+// Scan scans the first row, returning pgx.ErrNoRows without rows
+func (r *firstRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
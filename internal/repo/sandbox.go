@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SandboxBodyRecord is a body record fixture of the sandbox user
+type SandboxBodyRecord struct {
+	Date              time.Time
+	WeightKg          *float64
+	BodyFatPercentage *float64
+}
+
+// SandboxExerciseRecord is a timed exercise record fixture of the sandbox user
+type SandboxExerciseRecord struct {
+	ExerciseName   string
+	StartedAt      time.Time
+	EndedAt        time.Time
+	CaloriesBurned *int32
+}
+
+// SandboxDiaryEntry is a diary entry fixture of the sandbox user
+type SandboxDiaryEntry struct {
+	Title     string
+	Content   string
+	EntryDate time.Time
+}
+
+// SandboxStepRecord is a daily step total fixture of the sandbox user
+type SandboxStepRecord struct {
+	Date  time.Time
+	Steps int32
+}
+
+// SandboxFixtures is the data the sandbox user is reset to
+type SandboxFixtures struct {
+	BodyRecords     []SandboxBodyRecord
+	ExerciseRecords []SandboxExerciseRecord
+	DiaryEntries    []SandboxDiaryEntry
+	StepRecords     []SandboxStepRecord
+}
+
+// SandboxRepository resets the sandbox user of demo deployments
+type SandboxRepository struct {
+	pool   DB
+	q      *db.Queries
+	users  *UserCache
+	counts CountCaches
+}
+
+// NewSandboxRepository creates a new PostgreSQL sandbox repository. The reset user is evicted from
+// users, the cache of user lookups, and their record counts from counts; both may be nil.
+func NewSandboxRepository(pool DB, users *UserCache, counts CountCaches) *SandboxRepository {
+	return &SandboxRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		users:  users,
+		counts: counts,
+	}
+}
+
+// Reset deletes the user with the given subject ID with all of their data, including linked
+// integrations, and recreates them with the fixtures in one transaction. The user gets a new ID.
+// Accepts the current time.
+func (r *SandboxRepository) Reset(ctx context.Context, subjectID string, fixtures SandboxFixtures, now time.Time) (db.User, error) {
+	var user db.User
+	var deletedIDs []uuid.UUID
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var err error
+		deletedIDs, err = q.DeleteUserBySubjectID(ctx, subjectID)
+		if err != nil {
+			return fmt.Errorf("failed to delete sandbox user: %w", err)
+		}
+		user, err = q.CreateUser(ctx, subjectID)
+		if err != nil {
+			return fmt.Errorf("failed to create sandbox user: %w", err)
+		}
+
+		for _, b := range fixtures.BodyRecords {
+			weightVal, err := toNumeric(b.WeightKg)
+			if err != nil {
+				return fmt.Errorf("failed to convert weight: %w", err)
+			}
+			bodyFatVal, err := toNumeric(b.BodyFatPercentage)
+			if err != nil {
+				return fmt.Errorf("failed to convert body fat percentage: %w", err)
+			}
+			_, err = q.CreateBodyRecord(ctx, db.CreateBodyRecordParams{
+				UserID:            user.ID,
+				Date:              pgtype.Date{Time: b.Date, Valid: true},
+				WeightKg:          weightVal,
+				BodyFatPercentage: bodyFatVal,
+				CreatedAt:         now,
+				UpdatedAt:         now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create sandbox body record: %w", err)
+			}
+		}
+
+		for _, e := range fixtures.ExerciseRecords {
+			var caloriesBurnedVal pgtype.Int4
+			if e.CaloriesBurned != nil {
+				caloriesBurnedVal = pgtype.Int4{Int32: *e.CaloriesBurned, Valid: true}
+			}
+			_, err := q.CreateExerciseRecord(ctx, db.CreateExerciseRecordParams{
+				UserID:          user.ID,
+				ExerciseName:    e.ExerciseName,
+				DurationMinutes: pgtype.Int4{Int32: int32(e.EndedAt.Sub(e.StartedAt).Minutes()), Valid: true},
+				CaloriesBurned:  caloriesBurnedVal,
+				RecordedAt:      e.StartedAt.UTC(),
+				CreatedAt:       now,
+				UpdatedAt:       now,
+				StartedAt:       pgtype.Timestamptz{Time: e.StartedAt.UTC(), Valid: true},
+				EndedAt:         pgtype.Timestamptz{Time: e.EndedAt.UTC(), Valid: true},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create sandbox exercise record: %w", err)
+			}
+		}
+
+		for _, d := range fixtures.DiaryEntries {
+			_, err := q.CreateDiaryEntry(ctx, db.CreateDiaryEntryParams{
+				UserID:    user.ID,
+				Title:     pgtype.Text{String: d.Title, Valid: d.Title != ""},
+				Content:   d.Content,
+				EntryDate: pgtype.Date{Time: d.EntryDate, Valid: true},
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create sandbox diary entry: %w", err)
+			}
+		}
+
+		for _, s := range fixtures.StepRecords {
+			_, err := q.MergeStepRecord(ctx, db.MergeStepRecordParams{
+				UserID:    user.ID,
+				Date:      pgtype.Date{Time: s.Date, Valid: true},
+				Steps:     s.Steps,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create sandbox step record: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+	// The cached user and their counts were deleted with the old ID
+	r.users.InvalidateSubject(subjectID)
+	for _, id := range deletedIDs {
+		r.counts.Invalidate(id)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrSessionNotFound is returned when a session does not exist, belongs to another user or
+	// is revoked, or when a refresh token is unknown or expired
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshTokenReused is returned when a refresh token that was already used is presented
+	// again; its session is revoked
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// SessionRepository provides database operations for sessions and their refresh tokens
+type SessionRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewSessionRepository creates a new PostgreSQL session repository
+func NewSessionRepository(pool DB) *SessionRepository {
+	return &SessionRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// Create creates a session with its first refresh token, given by its hash, valid until expiresAt
+func (r *SessionRepository) Create(ctx context.Context, userID uuid.UUID, deviceName, userAgent string, roles, scopes []string, tokenHash []byte, expiresAt, now time.Time) (db.Session, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return db.Session{}, fmt.Errorf("failed to begin session transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // Rollback after Commit is a no-op
+
+	q := r.q.WithTx(tx)
+	if roles == nil {
+		roles = []string{}
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	session, err := q.CreateSession(ctx, db.CreateSessionParams{
+		UserID:     userID,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		Roles:      roles,
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+		Now:        now,
+	})
+	if err != nil {
+		return db.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	err = q.CreateRefreshToken(ctx, db.CreateRefreshTokenParams{
+		TokenHash: tokenHash,
+		SessionID: session.ID,
+		ExpiresAt: expiresAt,
+		Now:       now,
+	})
+	if err != nil {
+		return db.Session{}, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return db.Session{}, fmt.Errorf("failed to commit session transaction: %w", err)
+	}
+	return session, nil
+}
+
+// Rotate uses the refresh token with oldHash and replaces it with the token with newHash, valid
+// until expiresAt, returning its session. Returns ErrSessionNotFound if the token is unknown or
+// expired or its session is revoked, and ErrRefreshTokenReused, after revoking the session, if
+// the token was already used.
+func (r *SessionRepository) Rotate(ctx context.Context, oldHash, newHash []byte, expiresAt, now time.Time) (db.Session, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return db.Session{}, fmt.Errorf("failed to begin refresh transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // Rollback after Commit is a no-op
+
+	q := r.q.WithTx(tx)
+	token, err := q.GetRefreshTokenForUpdate(ctx, oldHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Session{}, ErrSessionNotFound
+		}
+		return db.Session{}, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if token.UsedAt.Valid {
+		// The token was already exchanged, so either the client or a thief holds a stolen copy
+		if err := q.RevokeSessionByID(ctx, db.RevokeSessionByIDParams{ID: token.SessionID, Now: now}); err != nil {
+			return db.Session{}, fmt.Errorf("failed to revoke session of reused refresh token: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return db.Session{}, fmt.Errorf("failed to commit refresh transaction: %w", err)
+		}
+		return db.Session{}, ErrRefreshTokenReused
+	}
+	if !token.ExpiresAt.After(now) {
+		return db.Session{}, ErrSessionNotFound
+	}
+	if _, err := q.GetActiveSession(ctx, token.SessionID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Session{}, ErrSessionNotFound
+		}
+		return db.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if err := q.UseRefreshToken(ctx, db.UseRefreshTokenParams{TokenHash: oldHash, Now: now}); err != nil {
+		return db.Session{}, fmt.Errorf("failed to use refresh token: %w", err)
+	}
+	err = q.CreateRefreshToken(ctx, db.CreateRefreshTokenParams{
+		TokenHash: newHash,
+		SessionID: token.SessionID,
+		ExpiresAt: expiresAt,
+		Now:       now,
+	})
+	if err != nil {
+		return db.Session{}, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	session, err := q.TouchSession(ctx, db.TouchSessionParams{ID: token.SessionID, ExpiresAt: expiresAt, Now: now})
+	if err != nil {
+		return db.Session{}, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return db.Session{}, fmt.Errorf("failed to commit refresh transaction: %w", err)
+	}
+	return session, nil
+}
+
+// FindActive retrieves a session that is not revoked
+func (r *SessionRepository) FindActive(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	session, err := r.q.GetActiveSession(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.Session{}, ErrSessionNotFound
+		}
+		return db.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+// FindActiveByUser retrieves the user's sessions that are not revoked or expired, most recently
+// used first
+func (r *SessionRepository) FindActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]db.Session, error) {
+	sessions, err := r.q.ListActiveSessionsByUser(ctx, db.ListActiveSessionsByUserParams{
+		UserID: userID,
+		Now:    now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Revoke revokes one of the user's sessions, returning ErrSessionNotFound if it doesn't exist,
+// belongs to another user or is already revoked
+func (r *SessionRepository) Revoke(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	rows, err := r.q.RevokeSession(ctx, db.RevokeSessionParams{
+		ID:     id,
+		UserID: userID,
+		Now:    now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if rows == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StatsRepository provides read-only aggregate queries for the admin system stats
+type StatsRepository struct {
+	q *db.Queries
+}
+
+// NewStatsRepository creates a new PostgreSQL stats repository
+func NewStatsRepository(pool DB) *StatsRepository {
+	return &StatsRepository{
+		q: db.New(pool),
+	}
+}
+
+// UserStats returns the number of users and of those active in the 7 and 30 days before now
+func (r *StatsRepository) UserStats(ctx context.Context, now time.Time) (db.GetUserStatsRow, error) {
+	stats, err := r.q.GetUserStats(ctx, db.GetUserStatsParams{
+		WeeklyActiveSince:  now.AddDate(0, 0, -7),
+		MonthlyActiveSince: now.AddDate(0, 0, -30),
+	})
+	if err != nil {
+		return db.GetUserStatsRow{}, fmt.Errorf("failed to get user stats: %w", err)
+	}
+	return stats, nil
+}
+
+// RecordsCreatedByDay returns the number of records of each type created on every UTC day from
+// startDate to endDate, including days without records
+func (r *StatsRepository) RecordsCreatedByDay(ctx context.Context, startDate, endDate time.Time) ([]db.CountRecordsCreatedByDayRow, error) {
+	rows, err := r.q.CountRecordsCreatedByDay(ctx, db.CountRecordsCreatedByDayParams{
+		StartDate: pgtype.Date{Time: startDate, Valid: true},
+		EndDate:   pgtype.Date{Time: endDate, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count records created by day: %w", err)
+	}
+	return rows, nil
+}
+
+// MostReadColumns returns up to limit columns by their reads from startDate to endDate, most
+// read first
+func (r *StatsRepository) MostReadColumns(ctx context.Context, startDate, endDate time.Time, limit int32) ([]db.ListMostReadColumnsRow, error) {
+	rows, err := r.q.ListMostReadColumns(ctx, db.ListMostReadColumnsParams{
+		StartDate: pgtype.Date{Time: startDate, Valid: true},
+		EndDate:   pgtype.Date{Time: endDate, Valid: true},
+		MaxCount:  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list most read columns: %w", err)
+	}
+	return rows, nil
+}
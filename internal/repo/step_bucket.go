@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StepBucket is the steps a source counted in the hour starting at Hour
+type StepBucket struct {
+	Hour   time.Time
+	Source string
+	Steps  int32
+}
+
+// UpsertBuckets writes hourly step buckets of a user in a single statement, replacing the
+// previous counts of their hours and sources, and merges the totals of their UTC days into the
+// daily step records, accepting the current time. The buckets must not repeat an hour and source.
+func (r *StepRecordRepository) UpsertBuckets(ctx context.Context, userID uuid.UUID, buckets []StepBucket, now time.Time) (int64, error) {
+	params := db.UpsertStepBucketsParams{
+		UserID:  userID,
+		Hours:   make([]time.Time, len(buckets)),
+		Sources: make([]string, len(buckets)),
+		Steps:   make([]int32, len(buckets)),
+		Now:     now,
+	}
+	var days []pgtype.Date
+	seenDays := make(map[time.Time]bool)
+	for i, b := range buckets {
+		params.Hours[i], params.Sources[i], params.Steps[i] = b.Hour.UTC(), b.Source, b.Steps
+		day := b.Hour.UTC().Truncate(24 * time.Hour)
+		if !seenDays[day] {
+			seenDays[day] = true
+			days = append(days, pgtype.Date{Time: day, Valid: true})
+		}
+	}
+
+	var upserted int64
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var err error
+		upserted, err = q.UpsertStepBuckets(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to upsert step buckets: %w", err)
+		}
+		err = q.MergeStepRecordsFromBuckets(ctx, db.MergeStepRecordsFromBucketsParams{
+			UserID: userID,
+			Now:    now,
+			Days:   days,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to merge daily steps: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return upserted, nil
+}
+
+// DailySteps returns the daily step totals of a user in a time zone from the hourly buckets in
+// [start, end), oldest first. An hour counted by several sources counts with its highest count.
+func (r *StepRecordRepository) DailySteps(ctx context.Context, userID uuid.UUID, timezone string, start, end time.Time) ([]db.ListDailyStepsByUserRow, error) {
+	days, err := r.q.ListDailyStepsByUser(ctx, db.ListDailyStepsByUserParams{
+		Timezone:   timezone,
+		UserID:     userID,
+		RangeStart: start.UTC(),
+		RangeEnd:   end.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily steps: %w", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// StepRecordRepository provides database operations for StepRecord
+type StepRecordRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewStepRecordRepository creates a new PostgreSQL step record repository
+func NewStepRecordRepository(pool DB) *StepRecordRepository {
+	return &StepRecordRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// FindByUser retrieves paginated step records for a user, newest first
+func (r *StepRecordRepository) FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.StepRecord, error) {
+	params := db.ListStepRecordsByUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	}
+
+	dbRecords, err := r.q.ListStepRecordsByUser(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list step records: %w", err)
+	}
+
+	return dbRecords, nil
+}
+
+// FindByUserAndDateRange retrieves step records for a user within a date range, oldest first
+func (r *StepRecordRepository) FindByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]db.StepRecord, error) {
+	params := db.ListStepRecordsByUserDateRangeParams{
+		UserID: userID,
+		Date:   pgtype.Date{Time: startDate, Valid: true},
+		Date_2: pgtype.Date{Time: endDate, Valid: true},
+	}
+
+	dbRecords, err := r.q.ListStepRecordsByUserDateRange(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list step records by date range: %w", err)
+	}
+
+	return dbRecords, nil
+}
+
+// EachBatchByUserAndDateRange calls fn with the step records of a user within a date range, oldest
+// first, in batches of up to batchSize records read one after the other. It stops at the first
+// error of fn.
+func (r *StepRecordRepository) EachBatchByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, batchSize int32, fn func([]db.StepRecord) error) error {
+	for {
+		batch, err := r.q.ListStepRecordsByUserDateRangeBatch(ctx, db.ListStepRecordsByUserDateRangeBatchParams{
+			UserID:    userID,
+			StartDate: pgtype.Date{Time: startDate, Valid: true},
+			EndDate:   pgtype.Date{Time: endDate, Valid: true},
+			BatchSize: batchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list step records batch: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < int(batchSize) {
+			return nil
+		}
+		startDate = batch[len(batch)-1].Date.Time.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,332 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrSupplementNotFound is returned when a supplement is not found
+	ErrSupplementNotFound = errors.New("supplement not found")
+	// ErrSupplementExists is returned when a user already has a supplement of the same name
+	ErrSupplementExists = errors.New("supplement already exists")
+	// ErrSupplementIntakeNotFound is returned when a supplement intake is not found
+	ErrSupplementIntakeNotFound = errors.New("supplement intake not found")
+)
+
+// Supplement is a supplement with the schedule of its reminder
+type Supplement struct {
+	db.Supplement
+	Schedule string // Cron expression of the reminder; empty without one
+	Timezone string // Time zone of the reminder; empty without one
+}
+
+// SupplementFields are the fields of a supplement set on create and update
+type SupplementFields struct {
+	Name     string
+	Dose     string
+	Reminder *SupplementReminder // nil for no reminder
+}
+
+// SupplementReminder is the schedule of the reminder to take a supplement
+type SupplementReminder struct {
+	Schedule   string // Cron expression
+	Timezone   string
+	NextFireAt time.Time
+}
+
+// SupplementIntake is an intake with the name of its supplement
+type SupplementIntake struct {
+	db.SupplementIntake
+	SupplementName string
+}
+
+// SupplementRepository provides database operations for Supplement and SupplementIntake. The
+// reminders of supplements are kept in sync with them.
+type SupplementRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewSupplementRepository creates a new PostgreSQL supplement repository
+func NewSupplementRepository(pool DB) *SupplementRepository {
+	return &SupplementRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// Create creates a supplement and its reminder, if any, accepting the current time. Returns
+// ErrSupplementExists if the user has a supplement of the same name, ignoring case.
+func (r *SupplementRepository) Create(ctx context.Context, userID uuid.UUID, fields SupplementFields, now time.Time) (Supplement, error) {
+	var supplement Supplement
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var reminderID pgtype.UUID
+		if fields.Reminder != nil {
+			created, err := q.CreateReminder(ctx, supplementReminderParams(userID, fields, now))
+			if err != nil {
+				return fmt.Errorf("failed to create supplement reminder: %w", err)
+			}
+			reminderID = pgtype.UUID{Bytes: created.ID, Valid: true}
+		}
+
+		created, err := q.CreateSupplement(ctx, db.CreateSupplementParams{
+			UserID:     userID,
+			Name:       fields.Name,
+			Dose:       fields.Dose,
+			ReminderID: reminderID,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrSupplementExists
+			}
+			return fmt.Errorf("failed to create supplement: %w", err)
+		}
+		supplement = withSupplementReminder(created, fields.Reminder)
+		return nil
+	})
+	if err != nil {
+		return Supplement{}, err
+	}
+	return supplement, nil
+}
+
+// FindByUser retrieves the supplements of a user by name
+func (r *SupplementRepository) FindByUser(ctx context.Context, userID uuid.UUID) ([]Supplement, error) {
+	rows, err := r.q.ListSupplementsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supplements: %w", err)
+	}
+
+	supplements := make([]Supplement, 0, len(rows))
+	for _, row := range rows {
+		supplements = append(supplements, Supplement{
+			Supplement: row.Supplement,
+			Schedule:   row.ReminderSchedule.String,
+			Timezone:   row.ReminderTimezone.String,
+		})
+	}
+	return supplements, nil
+}
+
+// CountByUser returns the number of supplements of a user
+func (r *SupplementRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountSupplementsByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count supplements: %w", err)
+	}
+	return count, nil
+}
+
+// Update replaces the fields of a user's supplement, accepting the current time. Its reminder
+// is created, rescheduled and enabled, or deleted to match fields.Reminder. Returns
+// ErrSupplementNotFound if the user has no such supplement, and ErrSupplementExists if the new
+// name is taken by another of their supplements.
+func (r *SupplementRepository) Update(ctx context.Context, id, userID uuid.UUID, fields SupplementFields, now time.Time) (Supplement, error) {
+	var supplement Supplement
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		current, err := q.GetSupplementForUpdate(ctx, db.GetSupplementForUpdateParams{
+			ID:     id,
+			UserID: userID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrSupplementNotFound
+			}
+			return fmt.Errorf("failed to get supplement: %w", err)
+		}
+
+		reminderID := current.ReminderID
+		switch {
+		case fields.Reminder == nil && reminderID.Valid:
+			if _, err := q.DeleteReminder(ctx, db.DeleteReminderParams{ID: reminderID.Bytes, UserID: userID}); err != nil {
+				return fmt.Errorf("failed to delete supplement reminder: %w", err)
+			}
+			reminderID = pgtype.UUID{}
+		case fields.Reminder != nil && reminderID.Valid:
+			params := supplementReminderParams(userID, fields, now)
+			if _, err := q.UpdateReminder(ctx, db.UpdateReminderParams{
+				ID:         reminderID.Bytes,
+				UserID:     userID,
+				Title:      params.Title,
+				Message:    params.Message,
+				Schedule:   params.Schedule,
+				Timezone:   params.Timezone,
+				Enabled:    true,
+				NextFireAt: params.NextFireAt,
+				UpdatedAt:  now,
+			}); err != nil {
+				return fmt.Errorf("failed to update supplement reminder: %w", err)
+			}
+		case fields.Reminder != nil:
+			created, err := q.CreateReminder(ctx, supplementReminderParams(userID, fields, now))
+			if err != nil {
+				return fmt.Errorf("failed to create supplement reminder: %w", err)
+			}
+			reminderID = pgtype.UUID{Bytes: created.ID, Valid: true}
+		}
+
+		updated, err := q.UpdateSupplement(ctx, db.UpdateSupplementParams{
+			ID:         id,
+			UserID:     userID,
+			Name:       fields.Name,
+			Dose:       fields.Dose,
+			ReminderID: reminderID,
+			UpdatedAt:  now,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return ErrSupplementExists
+			}
+			return fmt.Errorf("failed to update supplement: %w", err)
+		}
+		supplement = withSupplementReminder(updated, fields.Reminder)
+		return nil
+	})
+	if err != nil {
+		return Supplement{}, err
+	}
+	return supplement, nil
+}
+
+// Delete moves a user's supplement to the trash at now with its intakes and reminder, returning
+// ErrSupplementNotFound if the user has no such supplement
+func (r *SupplementRepository) Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	return withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		reminderID, err := q.TrashSupplement(ctx, db.TrashSupplementParams{
+			ID:        id,
+			UserID:    userID,
+			DeletedAt: now,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrSupplementNotFound
+			}
+			return fmt.Errorf("failed to delete supplement: %w", err)
+		}
+		if reminderID.Valid {
+			if _, err := q.DeleteReminder(ctx, db.DeleteReminderParams{ID: reminderID.Bytes, UserID: userID}); err != nil {
+				return fmt.Errorf("failed to delete supplement reminder: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// LogIntake records that a user took their supplement at takenAt, accepting the current time.
+// An empty dose logs the supplement's usual dose. Returns ErrSupplementNotFound if the user has
+// no such supplement.
+func (r *SupplementRepository) LogIntake(ctx context.Context, supplementID, userID uuid.UUID, dose string, takenAt, now time.Time) (SupplementIntake, error) {
+	row, err := r.q.CreateSupplementIntake(ctx, db.CreateSupplementIntakeParams{
+		Dose:         pgtype.Text{String: dose, Valid: dose != ""},
+		TakenAt:      takenAt.UTC(),
+		CreatedAt:    now,
+		SupplementID: supplementID,
+		UserID:       userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SupplementIntake{}, ErrSupplementNotFound
+		}
+		return SupplementIntake{}, fmt.Errorf("failed to log supplement intake: %w", err)
+	}
+	return SupplementIntake{
+		SupplementIntake: db.SupplementIntake{
+			ID:           row.ID,
+			UserID:       row.UserID,
+			SupplementID: row.SupplementID,
+			Dose:         row.Dose,
+			TakenAt:      row.TakenAt,
+			CreatedAt:    row.CreatedAt,
+		},
+		SupplementName: row.SupplementName,
+	}, nil
+}
+
+// FindIntakesByUser retrieves up to limit intakes of a user taken from start to end
+// (exclusive), newest first
+func (r *SupplementRepository) FindIntakesByUser(ctx context.Context, userID uuid.UUID, start, end time.Time, limit int32) ([]SupplementIntake, error) {
+	rows, err := r.q.ListSupplementIntakesByUser(ctx, db.ListSupplementIntakesByUserParams{
+		UserID:    userID,
+		StartTime: start.UTC(),
+		EndTime:   end.UTC(),
+		MaxCount:  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supplement intakes: %w", err)
+	}
+
+	intakes := make([]SupplementIntake, 0, len(rows))
+	for _, row := range rows {
+		intakes = append(intakes, SupplementIntake{
+			SupplementIntake: row.SupplementIntake,
+			SupplementName:   row.SupplementName,
+		})
+	}
+	return intakes, nil
+}
+
+// DeleteIntake moves a user's supplement intake to the trash at now, returning
+// ErrSupplementIntakeNotFound if the user has no such intake
+func (r *SupplementRepository) DeleteIntake(ctx context.Context, id, userID uuid.UUID, now time.Time) error {
+	deleted, err := r.q.TrashSupplementIntake(ctx, db.TrashSupplementIntakeParams{
+		ID:        id,
+		UserID:    userID,
+		DeletedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete supplement intake: %w", err)
+	}
+	if deleted == 0 {
+		return ErrSupplementIntakeNotFound
+	}
+	return nil
+}
+
+// IntakeCountsByUser returns every supplement of a user, by name, with the number of intakes
+// taken from start to end (exclusive) and the schedule of its reminder
+func (r *SupplementRepository) IntakeCountsByUser(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.CountSupplementIntakesByUserRangeRow, error) {
+	counts, err := r.q.CountSupplementIntakesByUserRange(ctx, db.CountSupplementIntakesByUserRangeParams{
+		UserID:    userID,
+		StartTime: start.UTC(),
+		EndTime:   end.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count supplement intakes: %w", err)
+	}
+	return counts, nil
+}
+
+// supplementReminderParams returns the parameters of the enabled reminder to take a supplement,
+// which shows its name and dose
+func supplementReminderParams(userID uuid.UUID, fields SupplementFields, now time.Time) db.CreateReminderParams {
+	return db.CreateReminderParams{
+		UserID:     userID,
+		Title:      "Take " + fields.Name,
+		Message:    fields.Dose,
+		Schedule:   fields.Reminder.Schedule,
+		Timezone:   fields.Reminder.Timezone,
+		Enabled:    true,
+		NextFireAt: pgtype.Timestamptz{Time: fields.Reminder.NextFireAt.UTC(), Valid: true},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// withSupplementReminder returns a supplement with the schedule of its reminder
+func withSupplementReminder(s db.Supplement, reminder *SupplementReminder) Supplement {
+	supplement := Supplement{Supplement: s}
+	if reminder != nil {
+		supplement.Schedule = reminder.Schedule
+		supplement.Timezone = reminder.Timezone
+	}
+	return supplement
+}
@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+)
+
+// SupportRepository provides read-only aggregate queries for support views
+type SupportRepository struct {
+	q *db.Queries
+}
+
+// NewSupportRepository creates a new PostgreSQL support repository
+func NewSupportRepository(pool DB) *SupportRepository {
+	return &SupportRepository{
+		q: db.New(pool),
+	}
+}
+
+// GetUserRecordSummary returns per-type record counts and last-updated timestamps for a user
+func (r *SupportRepository) GetUserRecordSummary(ctx context.Context, userID uuid.UUID) (db.GetUserRecordSummaryRow, error) {
+	summary, err := r.q.GetUserRecordSummary(ctx, userID)
+	if err != nil {
+		return db.GetUserRecordSummaryRow{}, fmt.Errorf("failed to get user record summary: %w", err)
+	}
+
+	return summary, nil
+}
+
+// ListIntegrationSyncStates returns the sync state of each integration of a user, without its
+// tokens, in the order they were linked
+func (r *SupportRepository) ListIntegrationSyncStates(ctx context.Context, userID uuid.UUID) ([]db.ListIntegrationSyncStatesByUserRow, error) {
+	states, err := r.q.ListIntegrationSyncStatesByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list integration sync states: %w", err)
+	}
+
+	return states, nil
+}
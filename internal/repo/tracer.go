@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/metrics"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// queryName matches the name comment sqlc starts its queries with
+var queryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+// unnamedQuery is the name of queries not generated by sqlc, e.g. health checks
+const unnamedQuery = "unnamed"
+
+// QueryTracer is a pgx tracer observing the duration of every query in the query duration
+// histogram, and logging queries taking at least threshold with their name, duration and row
+// count, as well as queries cancelled by the statement or lock timeout of their connection.
+// Arguments are only logged with logArgs, as they hold personal data.
+type QueryTracer struct {
+	threshold time.Duration
+	logArgs   bool
+	log       *slog.Logger
+}
+
+// NewQueryTracer creates a query tracer; a threshold of 0 disables slow query logging
+func NewQueryTracer(threshold time.Duration, logArgs bool, log *slog.Logger) *QueryTracer {
+	return &QueryTracer{
+		threshold: threshold,
+		logArgs:   logArgs,
+		log:       log,
+	}
+}
+
+// tracedQueryKey is the context key of the tracedQuery of a running query
+type tracedQueryKey struct{}
+
+// tracedQuery is a query started by TraceQueryStart
+type tracedQuery struct {
+	sql   string
+	args  []any
+	start time.Time
+}
+
+// TraceQueryStart records the start of a query in the context passed to TraceQueryEnd
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, tracedQueryKey{}, &tracedQuery{sql: data.SQL, args: data.Args, start: time.Now()})
+}
+
+// TraceQueryEnd observes the duration of a query and logs it if it was slow
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	query, ok := ctx.Value(tracedQueryKey{}).(*tracedQuery)
+	if !ok {
+		return
+	}
+	duration := time.Since(query.start)
+	name := unnamedQuery
+	if m := queryName.FindStringSubmatch(query.sql); m != nil {
+		name = m[1]
+	}
+	metrics.QueryDuration.Observe(name, duration.Seconds())
+
+	timedOut := isStatementTimeout(ctx, data.Err)
+	if timedOut {
+		markTimedOut(ctx)
+	} else if t.threshold <= 0 || duration < t.threshold {
+		return
+	}
+	attrs := []any{"queryName", name, "duration", duration, "rows", data.CommandTag.RowsAffected()}
+	if t.logArgs {
+		attrs = append(attrs, "args", query.args)
+	}
+	if data.Err != nil {
+		attrs = append(attrs, "error", data.Err)
+	}
+	if timedOut {
+		t.log.WarnContext(ctx, "Query timed out", attrs...)
+		return
+	}
+	t.log.WarnContext(ctx, "Slow query", attrs...)
+}
+
+// WithTimeoutTracking returns a context recording whether a statement run with it was cancelled
+// by the statement or lock timeout of its connection, for StatementTimedOut
+func WithTimeoutTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, timedOutKey{}, &atomic.Bool{})
+}
+
+// StatementTimedOut reports whether a statement run with a context of WithTimeoutTracking was
+// cancelled by the statement or lock timeout of its connection
+func StatementTimedOut(ctx context.Context) bool {
+	timedOut, ok := ctx.Value(timedOutKey{}).(*atomic.Bool)
+	return ok && timedOut.Load()
+}
+
+// timedOutKey is the context key of the flag of WithTimeoutTracking
+type timedOutKey struct{}
+
+// markTimedOut records a timed out statement in a context of WithTimeoutTracking
+func markTimedOut(ctx context.Context) {
+	if timedOut, ok := ctx.Value(timedOutKey{}).(*atomic.Bool); ok {
+		timedOut.Store(true)
+	}
+}
+
+// isStatementTimeout reports whether err shows a statement cancelled by the statement or lock
+// timeout of its connection, rather than by the cancellation of ctx, which pgx also reports as a
+// cancelled query
+func isStatementTimeout(ctx context.Context, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "55P03": // lock_not_available
+		return true
+	case "57014": // query_canceled
+		return ctx.Err() == nil
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Entity types of the trash, besides those stored in record_changes
+const (
+	EntityTypeAttachment       = "attachment"
+	EntityTypeExerciseTemplate = "exercise_template"
+	EntityTypeFast             = "fast"
+	EntityTypeMealRecord       = "meal_record"
+	EntityTypeMoodRecord       = "mood_record"
+	EntityTypePlannedMeal      = "planned_meal"
+	EntityTypeRecipe           = "recipe"
+	EntityTypeReminder         = "reminder"
+	EntityTypeSupplement       = "supplement"
+	EntityTypeSupplementIntake = "supplement_intake"
+)
+
+var (
+	// ErrTrashItemNotFound is returned when a trash item is not found, or was deleted before the
+	// trash window
+	ErrTrashItemNotFound = errors.New("trash item not found")
+	// ErrTrashRestoreConflict is returned when a restored record conflicts with one saved since it
+	// was deleted, e.g. a body record of the same date or an overlapping fast
+	ErrTrashRestoreConflict = errors.New("restored record conflicts with an existing record")
+	// ErrTrashRestoreParentMissing is returned when the record a restored record belongs to is
+	// deleted, e.g. the body record of a photo; restoring the parent first fixes it
+	ErrTrashRestoreParentMissing = errors.New("restored record belongs to a deleted record")
+)
+
+// trashRestoreStep inserts the rows saved under key in the data of a trash item
+type trashRestoreStep struct {
+	key     string
+	restore func(q *db.Queries, ctx context.Context, rows []byte) error
+}
+
+// trashRestoreSteps are the steps restoring each entity type, parents before children. The key
+// "record" holds the deleted row itself, the other keys the rows deleted with it.
+var trashRestoreSteps = map[string][]trashRestoreStep{
+	EntityTypeAttachment: {{"record", (*db.Queries).RestoreAttachments}},
+	EntityTypeBodyRecord: {
+		{"record", (*db.Queries).RestoreBodyRecords},
+		{"attachments", (*db.Queries).RestoreAttachments},
+	},
+	EntityTypeDiaryEntry: {
+		{"record", (*db.Queries).RestoreDiaryEntries},
+		{"diary_entry_revisions", (*db.Queries).RestoreDiaryEntryRevisions},
+	},
+	EntityTypeExerciseRecord: {
+		{"record", (*db.Queries).RestoreExerciseRecords},
+		{"exercise_routes", (*db.Queries).RestoreExerciseRoutes},
+	},
+	EntityTypeExerciseTemplate: {{"record", (*db.Queries).RestoreExerciseTemplates}},
+	EntityTypeFast:             {{"record", (*db.Queries).RestoreFasts}},
+	EntityTypeMealRecord:       {{"record", (*db.Queries).RestoreMealRecords}},
+	EntityTypeMoodRecord:       {{"record", (*db.Queries).RestoreMoodRecords}},
+	EntityTypePlannedMeal:      {{"record", (*db.Queries).RestorePlannedMeals}},
+	EntityTypeRecipe: {
+		{"record", (*db.Queries).RestoreRecipes},
+		{"recipe_ingredients", (*db.Queries).RestoreRecipeIngredients},
+	},
+	EntityTypeReminder: {{"record", (*db.Queries).RestoreReminders}},
+	EntityTypeSupplement: {
+		// The reminder first, as the supplement references it
+		{"reminders", (*db.Queries).RestoreReminders},
+		{"record", (*db.Queries).RestoreSupplements},
+		{"supplement_intakes", (*db.Queries).RestoreSupplementIntakes},
+	},
+	EntityTypeSupplementIntake: {{"record", (*db.Queries).RestoreSupplementIntakes}},
+}
+
+// historyEntityTypes are the entity types whose changes are stored in record_changes
+var historyEntityTypes = map[string]bool{
+	EntityTypeBodyRecord:     true,
+	EntityTypeExerciseRecord: true,
+	EntityTypeDiaryEntry:     true,
+}
+
+// TrashRepository provides database operations for the trash, which the Delete methods of the
+// other repositories move records to
+type TrashRepository struct {
+	pool   DB
+	q      *db.Queries
+	counts CountCaches
+}
+
+// NewTrashRepository creates a new PostgreSQL trash repository
+func NewTrashRepository(pool DB) *TrashRepository {
+	return &TrashRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// NewCachedTrashRepository creates a PostgreSQL trash repository evicting the counts of the users
+// it restores records of from counts
+func NewCachedTrashRepository(pool DB, counts CountCaches) *TrashRepository {
+	return &TrashRepository{
+		pool:   pool,
+		q:      db.New(pool),
+		counts: counts,
+	}
+}
+
+// FindByUser retrieves paginated items of a user's trash deleted at or after cutoff, newest first
+func (r *TrashRepository) FindByUser(ctx context.Context, userID uuid.UUID, cutoff time.Time, limit, offset int) ([]db.Trash, error) {
+	items, err := r.q.ListTrashByUser(ctx, db.ListTrashByUserParams{
+		UserID:     userID,
+		Cutoff:     cutoff,
+		PageLimit:  int32(limit),
+		PageOffset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	return items, nil
+}
+
+// CountByUser returns the number of items of a user's trash deleted at or after cutoff
+func (r *TrashRepository) CountByUser(ctx context.Context, userID uuid.UUID, cutoff time.Time) (int64, error) {
+	count, err := r.q.CountTrashByUser(ctx, db.CountTrashByUserParams{
+		UserID: userID,
+		Cutoff: cutoff,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count trash: %w", err)
+	}
+	return count, nil
+}
+
+// Restore takes an item deleted at or after cutoff out of a user's trash and inserts its records
+// back, with their original IDs, accepting the current time for their history. It returns
+// ErrTrashItemNotFound, ErrTrashRestoreConflict or ErrTrashRestoreParentMissing, leaving the item
+// in the trash, if it can't be restored.
+func (r *TrashRepository) Restore(ctx context.Context, id, userID uuid.UUID, cutoff, now time.Time) (db.Trash, error) {
+	var item db.Trash
+	defer r.counts.Invalidate(userID)
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var err error
+		item, err = q.DeleteTrashItem(ctx, db.DeleteTrashItemParams{
+			ID:     id,
+			UserID: userID,
+			Cutoff: cutoff,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTrashItemNotFound
+			}
+			return fmt.Errorf("failed to take item out of the trash: %w", err)
+		}
+
+		steps, ok := trashRestoreSteps[item.EntityType]
+		if !ok {
+			return fmt.Errorf("unknown trash entity type %q", item.EntityType)
+		}
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(item.Data, &data); err != nil {
+			return fmt.Errorf("failed to decode trash item: %w", err)
+		}
+		if item.EntityType == EntityTypeFast {
+			if err := checkRestoredFast(ctx, q, userID, data["record"]); err != nil {
+				return err
+			}
+		}
+		for _, step := range steps {
+			rows, ok := data[step.key]
+			if !ok {
+				continue
+			}
+			if step.key == "record" {
+				rows = append(append(json.RawMessage("["), rows...), ']')
+			}
+			if err := step.restore(q, ctx, rows); err != nil {
+				return restoreError(err)
+			}
+		}
+
+		if !historyEntityTypes[item.EntityType] {
+			return nil
+		}
+		return recordChange(ctx, q, userID, item.EntityType, item.EntityID, ChangeActionRestored, ChangeSourceUser, "", now)
+	})
+	if err != nil {
+		return db.Trash{}, err
+	}
+	return item, nil
+}
+
+// checkRestoredFast returns ErrTrashRestoreConflict if a fast would overlap the fasts of the user
+// saved since it was deleted, locking them like the writes of FastRepository
+func checkRestoredFast(ctx context.Context, q *db.Queries, userID uuid.UUID, record json.RawMessage) error {
+	var fast struct {
+		StartedAt time.Time  `json:"started_at"`
+		EndedAt   *time.Time `json:"ended_at"`
+	}
+	if err := json.Unmarshal(record, &fast); err != nil {
+		return fmt.Errorf("failed to decode trashed fast: %w", err)
+	}
+	if err := q.LockFasts(ctx, userID); err != nil {
+		return fmt.Errorf("failed to lock fasts: %w", err)
+	}
+
+	params := db.CountOverlappingFastsParams{
+		UserID:    userID,
+		StartTime: fast.StartedAt,
+	}
+	if fast.EndedAt != nil {
+		params.EndTime = pgtype.Timestamptz{Time: *fast.EndedAt, Valid: true}
+	}
+	overlapping, err := q.CountOverlappingFasts(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to count overlapping fasts: %w", err)
+	}
+	if overlapping > 0 {
+		return ErrTrashRestoreConflict
+	}
+	return nil
+}
+
+// restoreError maps the constraint violations of restoring records to the errors of Restore
+func restoreError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505", "23P01": // unique_violation, exclusion_violation
+			return ErrTrashRestoreConflict
+		case "23503": // foreign_key_violation
+			return ErrTrashRestoreParentMissing
+		}
+	}
+	return fmt.Errorf("failed to restore trash item: %w", err)
+}
+
+// CountExpired returns the number of trash items deleted before cutoff
+func (r *TrashRepository) CountExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	count, err := r.q.CountExpiredTrash(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count expired trash: %w", err)
+	}
+	return count, nil
+}
+
+// PurgeExpired deletes up to batchSize trash items deleted before cutoff and returns them
+func (r *TrashRepository) PurgeExpired(ctx context.Context, cutoff time.Time, batchSize int32) ([]db.Trash, error) {
+	items, err := r.q.PurgeExpiredTrash(ctx, db.PurgeExpiredTrashParams{
+		Cutoff:    cutoff,
+		BatchSize: batchSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge expired trash: %w", err)
+	}
+	return items, nil
+}
+
+// CountStalePendingAttachments counts the attachments pending since before cutoff
+func (r *TrashRepository) CountStalePendingAttachments(ctx context.Context, cutoff time.Time) (int64, error) {
+	count, err := r.q.CountStalePendingAttachments(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count stale pending attachments: %w", err)
+	}
+	return count, nil
+}
+
+// PurgeStalePendingAttachments deletes up to batchSize attachments pending since before cutoff
+// and returns them, so their files can be deleted
+func (r *TrashRepository) PurgeStalePendingAttachments(ctx context.Context, cutoff time.Time, batchSize int32) ([]db.Attachment, error) {
+	attachments, err := r.q.DeleteStalePendingAttachments(ctx, db.DeleteStalePendingAttachmentsParams{
+		Cutoff:    cutoff,
+		BatchSize: batchSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge stale pending attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// TrashStorageKeys returns the storage keys of the photos of a trash item: the photo itself, or
+// the photos of a body record
+func TrashStorageKeys(item db.Trash) ([]string, error) {
+	var data struct {
+		Record struct {
+			StorageKey string `json:"storage_key"`
+		} `json:"record"`
+		Attachments []struct {
+			StorageKey string `json:"storage_key"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(item.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode trash item: %w", err)
+	}
+
+	var keys []string
+	if item.EntityType == EntityTypeAttachment {
+		keys = append(keys, data.Record.StorageKey)
+	}
+	for _, a := range data.Attachments {
+		keys = append(keys, a.StorageKey)
+	}
+	return keys, nil
+}
@@ -4,27 +4,163 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// ErrUserNotFound is returned when a user is not found
-var ErrUserNotFound = errors.New("user not found")
+var (
+	// ErrUserNotFound is returned when a user is not found
+	ErrUserNotFound = errors.New("user not found")
+	// ErrAmbiguousEmail is returned when several users have the email address looked up
+	ErrAmbiguousEmail = errors.New("email address belongs to several users")
+)
 
 // UserRepository provides database operations for User
 type UserRepository struct {
-	q *db.Queries
+	q       *db.Queries
+	cache   *UserCache
+	regions regionChecker // Of the pool, nil without data regions
+}
+
+// regionChecker is a DB with databases of data regions, like *RegionalDB
+type regionChecker interface {
+	HasRegion(region string) bool
 }
 
 // NewUserRepository creates a new PostgreSQL user repository
-func NewUserRepository(pool *pgxpool.Pool) *UserRepository { // Return exported type
+func NewUserRepository(pool DB) *UserRepository { // Return exported type
+	regions, _ := pool.(regionChecker)
 	return &UserRepository{ // Use exported type
-		q: db.New(pool),
+		q:       db.New(pool),
+		regions: regions,
+	}
+}
+
+// NewCachedUserRepository creates a PostgreSQL user repository resolving users through cache,
+// which it keeps up to date with the changes it makes
+func NewCachedUserRepository(pool DB, cache *UserCache) *UserRepository {
+	regions, _ := pool.(regionChecker)
+	return &UserRepository{
+		q:       db.New(pool),
+		cache:   cache,
+		regions: regions,
+	}
+}
+
+// Resolve returns the user with the subject ID, creating them on first use in dataRegion like
+// CreateInRegion. Users are served from the cache of the repository, if it has one.
+func (r *UserRepository) Resolve(ctx context.Context, subjectID, dataRegion string) (db.User, error) {
+	if user, ok := r.cache.Get(subjectID); ok {
+		return user, nil
+	}
+	user, err := r.CreateInRegion(ctx, subjectID, dataRegion)
+	if err != nil {
+		return db.User{}, err
+	}
+	r.cache.Put(user)
+	return user, nil
+}
+
+// CreateInRegion creates a user whose records are kept in the database of dataRegion, or in the
+// home database for "", returning the existing user instead if there is one, whatever their
+// region. Users of a data region are copied into its database, whose records reference them. It
+// returns ErrUnknownRegion if the region of the user has no database, which for a new user fails
+// their creation.
+func (r *UserRepository) CreateInRegion(ctx context.Context, subjectID, dataRegion string) (db.User, error) {
+	// Users are kept in the home database, whatever their region
+	ctx = WithRegion(ctx, "")
+	user, err := r.FindBySubjectID(ctx, subjectID)
+	if errors.Is(err, ErrUserNotFound) {
+		if dataRegion == "" {
+			user, err = r.Create(ctx, subjectID)
+		} else if !r.hasRegion(dataRegion) {
+			return db.User{}, fmt.Errorf("%w: %s", ErrUnknownRegion, dataRegion)
+		} else {
+			user, err = r.createInRegion(ctx, subjectID, dataRegion)
+		}
+	}
+	if err != nil {
+		return db.User{}, err
+	}
+	if !user.DataRegion.Valid {
+		return user, nil
+	}
+
+	// Copied on every resolution rather than once, so users whose copy failed are copied later
+	if err := r.copyToRegion(ctx, user, user.DataRegion.String); err != nil {
+		return db.User{}, err
+	}
+	return user, nil
+}
+
+// RegionContext returns a context whose statements run on the database of the data region of the
+// user with the ID, e.g. to read their records for another user they are shared with
+func (r *UserRepository) RegionContext(ctx context.Context, id uuid.UUID) (context.Context, error) {
+	user, err := r.FindByID(WithRegion(ctx, ""), id)
+	if err != nil {
+		return nil, err
+	}
+	return WithRegion(ctx, user.DataRegion.String), nil
+}
+
+// CopyToRegion copies the user with the ID into the database of the data region of ctx, unless it
+// is the home database, so records of other users kept there can refer to them, such as the meals
+// coaches plan for their clients
+func (r *UserRepository) CopyToRegion(ctx context.Context, id uuid.UUID) error {
+	region := RegionOf(ctx)
+	if region == "" {
+		return nil
+	}
+	user, err := r.FindByID(WithRegion(ctx, ""), id)
+	if err != nil {
+		return err
 	}
+	return r.copyToRegion(ctx, user, region)
+}
+
+// copyToRegion copies the home database row of user into the database of the data region, if
+// not there yet
+func (r *UserRepository) copyToRegion(ctx context.Context, user db.User, region string) error {
+	if !r.hasRegion(region) {
+		return fmt.Errorf("%w: %s", ErrUnknownRegion, region)
+	}
+	err := r.q.MirrorUser(WithRegion(ctx, region), db.MirrorUserParams{
+		ID:         user.ID,
+		SubjectID:  user.SubjectID,
+		DataRegion: user.DataRegion,
+		CreatedAt:  user.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy user to region %s: %w", region, err)
+	}
+	return nil
+}
+
+// createInRegion creates a user pinned to a data region, returning the existing user if another
+// request created them first
+func (r *UserRepository) createInRegion(ctx context.Context, subjectID, dataRegion string) (db.User, error) {
+	user, err := r.q.CreateUserInRegion(ctx, db.CreateUserInRegionParams{
+		SubjectID:  subjectID,
+		DataRegion: pgtype.Text{String: dataRegion, Valid: true},
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return r.FindBySubjectID(ctx, subjectID)
+	}
+	if err != nil {
+		return db.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// hasRegion reports whether the data region has a database
+func (r *UserRepository) hasRegion(region string) bool {
+	return r.regions != nil && r.regions.HasRegion(region)
 }
 
 // Create creates a new user record
@@ -73,4 +209,94 @@ func (r *UserRepository) FindBySubjectID(ctx context.Context, subjectID string)
 	return dbUser, nil
 }
 
+// FindInactiveSince retrieves up to limit users whose last activity is before inactiveBefore,
+// least recently active first, e.g. for re-engagement notifications
+func (r *UserRepository) FindInactiveSince(ctx context.Context, inactiveBefore time.Time, limit int32) ([]db.User, error) {
+	users, err := r.q.ListUsersInactiveSince(ctx, db.ListUsersInactiveSinceParams{
+		InactiveBefore: inactiveBefore,
+		MaxCount:       limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inactive users: %w", err)
+	}
+	return users, nil
+}
+
+// SetEmail sets the address emails to the user are sent to
+func (r *UserRepository) SetEmail(ctx context.Context, id uuid.UUID, email string) error {
+	err := r.q.UpdateUserEmail(ctx, db.UpdateUserEmailParams{
+		ID:    id,
+		Email: pgtype.Text{String: email, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update user email: %w", err)
+	}
+	r.cache.Invalidate(id)
+	return nil
+}
+
+// FindByEmail retrieves the user with an email address, compared case-insensitively. It returns
+// ErrAmbiguousEmail rather than picking one when several users have the address.
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (db.User, error) {
+	users, err := r.q.ListUsersByEmail(ctx, email)
+	if err != nil {
+		return db.User{}, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	switch len(users) {
+	case 0:
+		return db.User{}, ErrUserNotFound
+	case 1:
+		return users[0], nil
+	}
+	return db.User{}, ErrAmbiguousEmail
+}
+
+// SearchBySubjectID retrieves paginated users whose subject ID contains query, newest first
+func (r *UserRepository) SearchBySubjectID(ctx context.Context, query string, limit, offset int) ([]db.User, error) {
+	users, err := r.q.SearchUsersBySubjectID(ctx, db.SearchUsersBySubjectIDParams{
+		Query:    query,
+		MaxCount: int32(limit),
+		Skip:     int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, nil
+}
+
+// CountBySubjectIDSearch returns the number of users whose subject ID contains query
+func (r *UserRepository) CountBySubjectIDSearch(ctx context.Context, query string) (int64, error) {
+	count, err := r.q.CountUsersBySubjectID(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// Suspend suspends a user at now; suspending a suspended user keeps their suspension time
+func (r *UserRepository) Suspend(ctx context.Context, id uuid.UUID, now time.Time) (db.User, error) {
+	user, err := r.q.SuspendUser(ctx, db.SuspendUserParams{ID: id, Now: now})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.User{}, ErrUserNotFound
+		}
+		return db.User{}, fmt.Errorf("failed to suspend user: %w", err)
+	}
+	r.cache.Invalidate(id)
+	return user, nil
+}
+
+// Unsuspend lifts the suspension of a user
+func (r *UserRepository) Unsuspend(ctx context.Context, id uuid.UUID) (db.User, error) {
+	user, err := r.q.UnsuspendUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.User{}, ErrUserNotFound
+		}
+		return db.User{}, fmt.Errorf("failed to unsuspend user: %w", err)
+	}
+	r.cache.Invalidate(id)
+	return user, nil
+}
+
 // Removed toLocalUser function as it's no longer needed
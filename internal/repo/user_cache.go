@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+)
+
+// UserCache keeps recently resolved users by subject ID for a TTL, evicting the least recently
+// used users once it holds its capacity. Users are evicted when they are changed through the
+// repositories of this process; changes made elsewhere, e.g. by other instances, show after the TTL.
+// A nil cache caches nothing.
+type UserCache struct {
+	mu        sync.Mutex
+	capacity  int
+	ttl       time.Duration
+	clock     clock.Clock
+	order     *list.List // of *cachedUser, most recently used first
+	bySubject map[string]*list.Element
+	byID      map[uuid.UUID]*list.Element
+}
+
+// cachedUser is a user cached until expiresAt
+type cachedUser struct {
+	user      db.User
+	expiresAt time.Time
+}
+
+// NewUserCache creates a cache of up to capacity users, each kept for ttl
+func NewUserCache(capacity int, ttl time.Duration, clock clock.Clock) *UserCache {
+	return &UserCache{
+		capacity:  capacity,
+		ttl:       ttl,
+		clock:     clock,
+		order:     list.New(),
+		bySubject: make(map[string]*list.Element),
+		byID:      make(map[uuid.UUID]*list.Element),
+	}
+}
+
+// Get returns the cached user with the subject ID, if it hasn't expired
+func (c *UserCache) Get(subjectID string) (db.User, bool) {
+	if c == nil {
+		return db.User{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.bySubject[subjectID]
+	if !ok {
+		return db.User{}, false
+	}
+	entry := elem.Value.(*cachedUser)
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.remove(elem)
+		return db.User{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.user, true
+}
+
+// Put caches user, evicting the least recently used user if the cache is full
+func (c *UserCache) Put(user db.User) {
+	if c == nil || c.capacity <= 0 || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.bySubject[user.SubjectID]; ok {
+		c.remove(elem)
+	}
+	if elem, ok := c.byID[user.ID]; ok {
+		c.remove(elem)
+	}
+	for c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+	elem := c.order.PushFront(&cachedUser{user: user, expiresAt: c.clock.Now().Add(c.ttl)})
+	c.bySubject[user.SubjectID] = elem
+	c.byID[user.ID] = elem
+}
+
+// Invalidate evicts the user with the ID
+func (c *UserCache) Invalidate(id uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.byID[id]; ok {
+		c.remove(elem)
+	}
+}
+
+// InvalidateSubject evicts the user with the subject ID
+func (c *UserCache) InvalidateSubject(subjectID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.bySubject[subjectID]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of cached users, including expired ones not evicted yet
+func (c *UserCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove evicts the user of elem; c.mu must be held
+func (c *UserCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*cachedUser)
+	delete(c.bySubject, entry.user.SubjectID)
+	delete(c.byID, entry.user.ID)
+}
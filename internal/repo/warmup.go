@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// primers run the read queries of the hot paths, authenticating RPCs and listing columns, so
+// their statements are prepared and cached on a connection. Lookups are made with IDs matching
+// no rows; the first page of columns is read, which brings it into the database's cache too.
+var primers = []func(ctx context.Context, q *db.Queries, columnsPageSize int32, now time.Time) error{
+	func(ctx context.Context, q *db.Queries, _ int32, _ time.Time) error {
+		_, err := q.GetUserBySubjectID(ctx, "")
+		return err
+	},
+	func(ctx context.Context, q *db.Queries, _ int32, _ time.Time) error {
+		_, err := q.GetActiveSession(ctx, uuid.Nil)
+		return err
+	},
+	func(ctx context.Context, q *db.Queries, columnsPageSize int32, now time.Time) error {
+		_, err := q.ListPublishedColumns(ctx, db.ListPublishedColumnsParams{
+			PublishedAt: pgtype.Timestamptz{Time: now, Valid: true},
+			Limit:       columnsPageSize,
+		})
+		return err
+	},
+	func(ctx context.Context, q *db.Queries, _ int32, now time.Time) error {
+		_, err := q.CountPublishedColumns(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+		return err
+	},
+}
+
+// Prime opens conns connections of pool, if it hasn't yet, and prepares the statements of the
+// hot paths on each, so the first RPCs after a start don't wait for connections or statement
+// preparation
+func Prime(ctx context.Context, pool *pgxpool.Pool, conns int, columnsPageSize int32, now time.Time) error {
+	// The connections are held until all are primed, so each is a different one
+	acquired := make([]*pgxpool.Conn, 0, conns)
+	defer func() {
+		for _, conn := range acquired {
+			conn.Release()
+		}
+	}()
+	for range conns {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to open connection: %w", err)
+		}
+		acquired = append(acquired, conn)
+	}
+
+	for _, conn := range acquired {
+		q := db.New(conn)
+		for _, prime := range primers {
+			if err := prime(ctx, q, columnsPageSize, now); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("failed to prime statements: %w", err)
+			}
+		}
+	}
+	return nil
+}
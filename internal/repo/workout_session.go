@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// WorkoutExercise is an exercise record of a new workout session
+type WorkoutExercise struct {
+	ExerciseName    string
+	DurationMinutes *int32 // Optional
+	CaloriesBurned  *int32 // Optional
+	Effort          ExerciseEffort
+}
+
+// WorkoutSessionRepository provides database operations for WorkoutSession
+type WorkoutSessionRepository struct {
+	pool DB
+	q    *db.Queries
+}
+
+// NewWorkoutSessionRepository creates a new PostgreSQL workout session repository
+func NewWorkoutSessionRepository(pool DB) *WorkoutSessionRepository {
+	return &WorkoutSessionRepository{
+		pool: pool,
+		q:    db.New(pool),
+	}
+}
+
+// Create creates a workout session and an exercise record of each of its exercises, recorded at
+// startedAt, accepting the current time. The records are added to their history.
+func (r *WorkoutSessionRepository) Create(ctx context.Context, userID uuid.UUID, name string, startedAt time.Time, exercises []WorkoutExercise, now time.Time) (db.WorkoutSession, []db.ExerciseRecord, error) {
+	var session db.WorkoutSession
+	records := make([]db.ExerciseRecord, 0, len(exercises))
+	err := withTx(ctx, r.pool, r.q, func(q *db.Queries) error {
+		var err error
+		session, err = q.CreateWorkoutSession(ctx, db.CreateWorkoutSessionParams{
+			UserID:    userID,
+			Name:      name,
+			StartedAt: startedAt.UTC(),
+			CreatedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create workout session: %w", err)
+		}
+
+		for i, exercise := range exercises {
+			var durationMinutes, caloriesBurned pgtype.Int4
+			if exercise.DurationMinutes != nil {
+				durationMinutes = pgtype.Int4{Int32: *exercise.DurationMinutes, Valid: true}
+			}
+			if exercise.CaloriesBurned != nil {
+				caloriesBurned = pgtype.Int4{Int32: *exercise.CaloriesBurned, Valid: true}
+			}
+			rpe, intensity := exercise.Effort.params()
+
+			record, err := q.CreateExerciseRecord(ctx, db.CreateExerciseRecordParams{
+				UserID:           userID,
+				ExerciseName:     exercise.ExerciseName,
+				DurationMinutes:  durationMinutes,
+				CaloriesBurned:   caloriesBurned,
+				RecordedAt:       startedAt.UTC(),
+				CreatedAt:        now,
+				UpdatedAt:        now,
+				Rpe:              rpe,
+				Intensity:        intensity,
+				WorkoutSessionID: pgtype.UUID{Bytes: session.ID, Valid: true},
+				WorkoutPosition:  pgtype.Int2{Int16: int16(i), Valid: true},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create exercise record: %w", err)
+			}
+			if err := recordChange(ctx, q, userID, EntityTypeExerciseRecord, record.ID, ChangeActionCreated, ChangeSourceUser, "", now); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return db.WorkoutSession{}, nil, err
+	}
+	return session, records, nil
+}
+
+// FindByUser retrieves paginated workout sessions of a user, newest first
+func (r *WorkoutSessionRepository) FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.WorkoutSession, error) {
+	sessions, err := r.q.ListWorkoutSessionsByUser(ctx, db.ListWorkoutSessionsByUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workout sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// CountByUser returns the number of workout sessions of a user
+func (r *WorkoutSessionRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := r.q.CountWorkoutSessionsByUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count workout sessions: %w", err)
+	}
+	return count, nil
+}
+
+// FindRecords retrieves the exercise records of a user's workout sessions, keyed by session in
+// the order of the session's exercises
+func (r *WorkoutSessionRepository) FindRecords(ctx context.Context, userID uuid.UUID, sessionIDs []uuid.UUID) (map[uuid.UUID][]db.ExerciseRecord, error) {
+	records, err := r.q.ListExerciseRecordsByWorkoutSessions(ctx, db.ListExerciseRecordsByWorkoutSessionsParams{
+		UserID:     userID,
+		SessionIds: sessionIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workout session records: %w", err)
+	}
+
+	bySession := make(map[uuid.UUID][]db.ExerciseRecord, len(sessionIDs))
+	for _, record := range records {
+		sessionID := uuid.UUID(record.WorkoutSessionID.Bytes)
+		bySession[sessionID] = append(bySession[sessionID], record)
+	}
+	return bySession, nil
+}
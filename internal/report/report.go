@@ -0,0 +1,300 @@
+// Package report writes the monthly PDF reports requested by users: a chart of their weight,
+// their exercise totals and highlights of their diary.
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/pdf"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/units"
+)
+
+const (
+	// leaseDuration is how long a claimed report is reserved for the job; reports of a job that
+	// stopped midway are retried once it expires
+	leaseDuration = 10 * time.Minute
+	// maxAttempts is how many times a report is attempted before it is marked failed
+	maxAttempts = 3
+	// retryDelay is the delay before retrying a failed report
+	retryDelay = time.Minute
+	// contentType is the content type of the reports
+	contentType = "application/pdf"
+	// maxHighlights is how many diary entries a report shows, the latest of the month
+	maxHighlights = 5
+	// maxHighlightRunes is how much of the content of a diary entry a report shows
+	maxHighlightRunes = 200
+)
+
+// Data is the data of a user shown in the report of a month
+type Data struct {
+	Month       time.Time // First day of the month
+	WeightUnit  units.WeightUnit
+	BodyRecords []db.BodyRecord // Oldest first
+	Exercise    db.GetExerciseTotalsByUserRangeRow
+	Diary       []db.DiaryEntry // Decrypted, oldest first
+}
+
+// Job writes the monthly reports requested by users
+type Job struct {
+	reports     *repo.MonthlyReportRepository
+	bodyRecords *repo.BodyRecordRepository
+	exercises   *repo.ExerciseRecordRepository
+	diary       *repo.DiaryEntryRepository
+	prefs       *repo.PreferenceRepository
+	store       storage.Store
+	cfg         config.ReportsConfig
+	font        *pdf.TrueType
+	log         *slog.Logger
+	clock       clock.Clock
+}
+
+// NewJob creates a job writing reports of the records of the repositories to store under the
+// prefix of cfg. Text outside Latin-1, such as Japanese diary entries, is drawn with font; with
+// a nil font, its characters show as "?".
+func NewJob(reports *repo.MonthlyReportRepository, bodyRecords *repo.BodyRecordRepository, exercises *repo.ExerciseRecordRepository, diary *repo.DiaryEntryRepository, prefs *repo.PreferenceRepository, store storage.Store, cfg config.ReportsConfig, font *pdf.TrueType, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		reports:     reports,
+		bodyRecords: bodyRecords,
+		exercises:   exercises,
+		diary:       diary,
+		prefs:       prefs,
+		store:       store,
+		cfg:         cfg,
+		font:        font,
+		log:         log,
+		clock:       clock,
+	}
+}
+
+// Run writes the requested reports immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.GenerateDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GenerateDue writes reports until none are due
+func (j *Job) GenerateDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := j.clock.Now()
+		report, ok, err := j.reports.Claim(ctx, now, now.Add(leaseDuration))
+		if err != nil {
+			j.log.ErrorContext(ctx, "Failed to claim monthly report", "error", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		j.run(ctx, report)
+	}
+}
+
+// run writes a claimed report and records the outcome
+func (j *Job) run(ctx context.Context, report db.MonthlyReport) {
+	key, err := j.Generate(ctx, report)
+	switch {
+	case err == nil:
+		if err := j.reports.Complete(ctx, report.ID, key, j.clock.Now()); err != nil {
+			j.log.ErrorContext(ctx, "Failed to complete monthly report", "reportID", report.ID, "error", err)
+			return
+		}
+		j.log.InfoContext(ctx, "Monthly report completed", "reportID", report.ID, "userID", report.UserID)
+	case report.Attempts < maxAttempts:
+		nextAttemptAt := j.clock.Now().Add(retryDelay)
+		j.log.WarnContext(ctx, "Monthly report failed, retrying", "reportID", report.ID, "attempts", report.Attempts, "nextAttemptAt", nextAttemptAt, "error", err)
+		if err := j.reports.Retry(ctx, report.ID, nextAttemptAt, err.Error()); err != nil {
+			j.log.ErrorContext(ctx, "Failed to retry monthly report", "reportID", report.ID, "error", err)
+		}
+	default:
+		j.log.ErrorContext(ctx, "Monthly report failed", "reportID", report.ID, "attempts", report.Attempts, "error", err)
+		if err := j.reports.Fail(ctx, report.ID, err.Error(), j.clock.Now()); err != nil {
+			j.log.ErrorContext(ctx, "Failed to mark monthly report failed", "reportID", report.ID, "error", err)
+		}
+	}
+}
+
+// Generate renders the PDF of a report and stores it, returning its key
+func (j *Job) Generate(ctx context.Context, report db.MonthlyReport) (string, error) {
+	month := report.Month.Time
+	lastDay := month.AddDate(0, 1, -1)
+	data := Data{Month: month}
+	prefs, err := j.prefs.GetUnits(ctx, report.UserID)
+	if err != nil {
+		return "", err
+	}
+	data.WeightUnit = prefs.Weight
+	if data.BodyRecords, err = j.bodyRecords.FindByUserAndDateRange(ctx, report.UserID, month, lastDay); err != nil {
+		return "", err
+	}
+	if data.Exercise, err = j.exercises.TotalsByUser(ctx, report.UserID, month, month.AddDate(0, 1, 0)); err != nil {
+		return "", err
+	}
+	if data.Diary, err = j.diary.FindByUserAndDateRange(ctx, report.UserID, month, lastDay); err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s%s/%s.pdf", j.cfg.Prefix, report.UserID, report.ID)
+	if err := j.store.Put(ctx, key, contentType, Render(data, j.font, j.clock.Now())); err != nil {
+		return "", fmt.Errorf("failed to store report %s: %w", key, err)
+	}
+	return key, nil
+}
+
+// Layout of the report page, in points
+const (
+	marginLeft  = 60.0
+	marginRight = pdf.A4Width - 60
+	chartTop    = 715.0
+	chartBottom = 535.0
+)
+
+// Render renders the report of data, generated at now, as a PDF drawing the text outside Latin-1
+// with font
+func Render(data Data, font *pdf.TrueType, now time.Time) []byte {
+	doc := pdf.New(font)
+	page := doc.AddPage()
+	page.Text(marginLeft, 790, pdf.Bold, 20, "Monthly Report - "+data.Month.Format("January 2006"))
+	page.Text(marginLeft, 772, pdf.Regular, 10, "Generated on "+now.UTC().Format("2006-01-02"))
+
+	page.Text(marginLeft, 740, pdf.Bold, 14, "Weight")
+	renderWeight(page, data)
+
+	y := 470.0
+	page.Text(marginLeft, y, pdf.Bold, 14, "Exercise")
+	for _, line := range []string{
+		fmt.Sprintf("Sessions: %d", data.Exercise.SessionCount),
+		fmt.Sprintf("Total duration: %d min", data.Exercise.TotalDurationMinutes),
+		fmt.Sprintf("Calories burned: %d kcal", data.Exercise.TotalCaloriesBurned),
+	} {
+		y -= 18
+		page.Text(marginLeft, y, pdf.Regular, 11, line)
+	}
+
+	y -= 36
+	page.Text(marginLeft, y, pdf.Bold, 14, "Diary Highlights")
+	entries := data.Diary
+	if len(entries) > maxHighlights {
+		entries = entries[len(entries)-maxHighlights:]
+	}
+	if len(entries) == 0 {
+		page.Text(marginLeft, y-18, pdf.Regular, 11, "No diary entries this month")
+	}
+	for _, entry := range entries {
+		title := "Untitled"
+		if entry.Title.Valid && strings.TrimSpace(entry.Title.String) != "" {
+			title = entry.Title.String
+		}
+		y -= 20
+		page.Text(marginLeft, y, pdf.Bold, 11, entry.EntryDate.Time.Format("Jan 2")+"  "+title)
+		for _, line := range wrap(excerpt(entry.Content), 10, marginRight-marginLeft) {
+			y -= 13
+			page.Text(marginLeft, y, pdf.Regular, 10, line)
+		}
+	}
+	return doc.Bytes()
+}
+
+// renderWeight draws the chart of the weigh-ins of the month, by day, with a summary below it
+func renderWeight(page *pdf.Page, data Data) {
+	days := float64(data.Month.AddDate(0, 1, -1).Day())
+	var weights []float64
+	var points []pdf.Point
+	for _, record := range data.BodyRecords {
+		kg, err := record.WeightKg.Float64Value()
+		if err != nil || !kg.Valid {
+			continue
+		}
+		weights = append(weights, data.WeightUnit.FromKg(kg.Float64))
+		points = append(points, pdf.Point{X: float64(record.Date.Time.Day())})
+	}
+	if len(weights) == 0 {
+		page.Text(marginLeft, chartTop-18, pdf.Regular, 11, "No weigh-ins this month")
+		return
+	}
+
+	// Scale the weights to the chart, with a margin so the line doesn't touch the frame
+	low, high := weights[0], weights[0]
+	for _, w := range weights {
+		low, high = math.Min(low, w), math.Max(high, w)
+	}
+	low, high = math.Floor(low-1), math.Ceil(high+1)
+	for i, w := range weights {
+		points[i].X = marginLeft + (points[i].X-1)/math.Max(days-1, 1)*(marginRight-marginLeft)
+		points[i].Y = chartBottom + (w-low)/(high-low)*(chartTop-chartBottom)
+	}
+
+	page.Gray(0.6)
+	page.Line(marginLeft, chartBottom, marginRight, chartBottom, 0.5)
+	page.Line(marginLeft, chartBottom, marginLeft, chartTop, 0.5)
+	page.Text(marginLeft-35, chartTop-4, pdf.Regular, 8, fmt.Sprintf("%.0f %s", high, data.WeightUnit))
+	page.Text(marginLeft-35, chartBottom-4, pdf.Regular, 8, fmt.Sprintf("%.0f %s", low, data.WeightUnit))
+	page.Text(marginLeft, chartBottom-14, pdf.Regular, 8, data.Month.Format("Jan 2"))
+	page.Text(marginRight-24, chartBottom-14, pdf.Regular, 8, data.Month.AddDate(0, 1, -1).Format("Jan 2"))
+	page.Gray(0)
+	page.Polyline(points, 1.5)
+	for _, point := range points {
+		page.Rect(point.X-2, point.Y-2, 4, 4)
+	}
+
+	change := weights[len(weights)-1] - weights[0]
+	page.Text(marginLeft, chartBottom-36, pdf.Regular, 11, fmt.Sprintf("Start %.1f %s, end %.1f %s, change %+.1f %s (%d weigh-ins)",
+		weights[0], data.WeightUnit, weights[len(weights)-1], data.WeightUnit, change, data.WeightUnit, len(weights)))
+}
+
+// excerpt returns the beginning of the content of a diary entry on a single line
+func excerpt(content string) string {
+	runes := []rune(strings.Join(strings.Fields(content), " "))
+	if len(runes) <= maxHighlightRunes {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:maxHighlightRunes])) + "..."
+}
+
+// wrap breaks text into lines of words fitting width at size; words longer than a line, such as
+// Japanese sentences without spaces, are broken between characters
+func wrap(text string, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && pdf.TextWidth(candidate, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		for pdf.TextWidth(candidate, size) > width {
+			runes := []rune(candidate)
+			fit := 1
+			for fit < len(runes) && pdf.TextWidth(string(runes[:fit+1]), size) <= width {
+				fit++
+			}
+			lines = append(lines, string(runes[:fit]))
+			candidate = string(runes[fit:])
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
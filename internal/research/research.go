@@ -0,0 +1,234 @@
+package research
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// leaseDuration is how long a claimed export is reserved for the job; exports of a job that
+	// stopped midway are retried once it expires
+	leaseDuration = 30 * time.Minute
+	// maxAttempts is how many times an export is attempted before it is marked failed
+	maxAttempts = 3
+	// retryDelay is the delay before retrying a failed export
+	retryDelay = 5 * time.Minute
+	// datasetContentType is the content type of the datasets
+	datasetContentType = "text/csv"
+)
+
+// Names of the datasets of an export
+const (
+	DatasetBodyMetrics      = "body_metrics"
+	DatasetExerciseActivity = "exercise_activity"
+	DatasetDailySteps       = "daily_steps"
+)
+
+// Job writes the research exports requested by admins. Datasets hold aggregates by month, never
+// user IDs or single records, and leave out the groups of fewer than the export's minimum
+// group size of users, so every row hides each contributor among at least that many.
+type Job struct {
+	repo  *repo.ResearchRepository
+	store storage.Store
+	cfg   config.ResearchConfig
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewJob creates a job writing exports to store under the prefix of cfg
+func NewJob(repo *repo.ResearchRepository, store storage.Store, cfg config.ResearchConfig, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		repo:  repo,
+		store: store,
+		cfg:   cfg,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// Run writes the requested exports immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.ExportDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExportDue writes exports until none are due
+func (j *Job) ExportDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := j.clock.Now()
+		export, ok, err := j.repo.ClaimExport(ctx, now, now.Add(leaseDuration))
+		if err != nil {
+			j.log.ErrorContext(ctx, "Failed to claim research export", "error", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		j.run(ctx, export)
+	}
+}
+
+// run writes a claimed export and records the outcome
+func (j *Job) run(ctx context.Context, export db.ResearchExport) {
+	result, err := j.Export(ctx, export)
+	switch {
+	case err == nil:
+		if err := j.repo.CompleteExport(ctx, export.ID, result, j.clock.Now()); err != nil {
+			j.log.ErrorContext(ctx, "Failed to complete research export", "exportID", export.ID, "error", err)
+			return
+		}
+		j.log.InfoContext(ctx, "Research export completed", "exportID", export.ID, "contributors", result.Contributors, "suppressedGroups", result.SuppressedGroups)
+	case export.Attempts < maxAttempts:
+		nextAttemptAt := j.clock.Now().Add(retryDelay)
+		j.log.WarnContext(ctx, "Research export failed, retrying", "exportID", export.ID, "attempts", export.Attempts, "nextAttemptAt", nextAttemptAt, "error", err)
+		if err := j.repo.RetryExport(ctx, export.ID, nextAttemptAt, err.Error()); err != nil {
+			j.log.ErrorContext(ctx, "Failed to retry research export", "exportID", export.ID, "error", err)
+		}
+	default:
+		j.log.ErrorContext(ctx, "Research export failed", "exportID", export.ID, "attempts", export.Attempts, "error", err)
+		if err := j.repo.FailExport(ctx, export.ID, err.Error(), j.clock.Now()); err != nil {
+			j.log.ErrorContext(ctx, "Failed to mark research export failed", "exportID", export.ID, "error", err)
+		}
+	}
+}
+
+// Export aggregates the data of an export and writes its datasets under the export's key prefix
+func (j *Job) Export(ctx context.Context, export db.ResearchExport) (repo.ResearchExportResult, error) {
+	data, err := j.repo.Data(ctx, export.StartDate.Time, export.EndDate.Time)
+	if err != nil {
+		return repo.ResearchExportResult{}, err
+	}
+
+	result := repo.ResearchExportResult{Contributors: int(data.Contributors)}
+	for _, dataset := range Datasets(data, int(export.MinGroupSize)) {
+		key := fmt.Sprintf("%s%s/%s.csv", j.cfg.ExportPrefix, export.ID, dataset.Name)
+		content, err := encodeCSV(dataset.Rows)
+		if err != nil {
+			return repo.ResearchExportResult{}, fmt.Errorf("failed to encode %s: %w", dataset.Name, err)
+		}
+		if err := j.store.Put(ctx, key, datasetContentType, content); err != nil {
+			return repo.ResearchExportResult{}, fmt.Errorf("failed to store dataset %s: %w", key, err)
+		}
+		result.SuppressedGroups += dataset.Suppressed
+		result.Datasets = append(result.Datasets, repo.ResearchDataset{
+			Name: dataset.Name,
+			Key:  key,
+			Rows: len(dataset.Rows) - 1,
+		})
+	}
+	return result, nil
+}
+
+// Dataset is a CSV table of an export
+type Dataset struct {
+	Name string
+	// Rows are the header followed by one row per group of at least the minimum group size
+	Rows [][]string
+	// Suppressed is the number of groups left out for aggregating too few users
+	Suppressed int
+}
+
+// Datasets converts the aggregates of data to the datasets of an export, leaving out the groups
+// of fewer than minGroupSize users
+func Datasets(data repo.ResearchData, minGroupSize int) []Dataset {
+	body := Dataset{
+		Name: DatasetBodyMetrics,
+		Rows: [][]string{{"month", "users", "weight_records", "avg_weight_kg", "body_fat_records", "avg_body_fat_percentage"}},
+	}
+	for _, m := range data.BodyMetrics {
+		if m.Users < int64(minGroupSize) {
+			body.Suppressed++
+			continue
+		}
+		body.Rows = append(body.Rows, []string{
+			month(m.Month),
+			strconv.FormatInt(m.Users, 10),
+			strconv.FormatInt(m.WeightRecords, 10),
+			average(m.AvgWeightKg, m.WeightRecords),
+			strconv.FormatInt(m.BodyFatRecords, 10),
+			average(m.AvgBodyFatPercentage, m.BodyFatRecords),
+		})
+	}
+
+	exercise := Dataset{
+		Name: DatasetExerciseActivity,
+		Rows: [][]string{{"month", "activity", "users", "sessions", "total_minutes", "calorie_records", "avg_calories_burned"}},
+	}
+	for _, a := range data.ExerciseActivity {
+		if a.Users < int64(minGroupSize) {
+			exercise.Suppressed++
+			continue
+		}
+		exercise.Rows = append(exercise.Rows, []string{
+			month(a.Month),
+			a.Activity,
+			strconv.FormatInt(a.Users, 10),
+			strconv.FormatInt(a.Sessions, 10),
+			strconv.FormatInt(a.TotalMinutes, 10),
+			strconv.FormatInt(a.CalorieRecords, 10),
+			average(a.AvgCaloriesBurned, a.CalorieRecords),
+		})
+	}
+
+	steps := Dataset{
+		Name: DatasetDailySteps,
+		Rows: [][]string{{"month", "users", "days", "avg_steps"}},
+	}
+	for _, s := range data.DailySteps {
+		if s.Users < int64(minGroupSize) {
+			steps.Suppressed++
+			continue
+		}
+		steps.Rows = append(steps.Rows, []string{
+			month(s.Month),
+			strconv.FormatInt(s.Users, 10),
+			strconv.FormatInt(s.Days, 10),
+			average(s.AvgSteps, s.Days),
+		})
+	}
+
+	return []Dataset{body, exercise, steps}
+}
+
+// month formats the first day of a month as YYYY-MM
+func month(d pgtype.Date) string {
+	return d.Time.Format("2006-01")
+}
+
+// average formats an average of count values, empty without values
+func average(avg float64, count int64) string {
+	if count == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(avg, 'f', 2, 64)
+}
+
+func encodeCSV(rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
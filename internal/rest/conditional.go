@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// conditionalWriter answers conditional GET requests with 304 Not Modified when the RPC
+// response carries the ETag or Last-Modified validator the client cached. The response is
+// still computed, but its body isn't sent.
+type conditionalWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	wroteHeader bool
+	notModified bool
+}
+
+// WriteHeader sends 304 instead of a successful status if the client's copy is current
+func (w *conditionalWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusOK && notModified(w.req, w.Header()) {
+		w.notModified = true
+		for _, key := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			w.Header().Del(key)
+		}
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write drops the body of not modified responses
+func (w *conditionalWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// notModified evaluates the conditions of r against the validators of a response with header.
+// If-None-Match takes precedence over If-Modified-Since, as in RFC 9110.
+func notModified(r *http.Request, header http.Header) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// GET requests use the weak comparison
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
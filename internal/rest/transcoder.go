@@ -0,0 +1,317 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"connectrpc.com/connect"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// maxBodySize caps REST request bodies; HealthKit imports are the largest requests
+	maxBodySize = 16 << 20
+	// paginationField is the request field that unqualified page_size, page_number and
+	// page_token query parameters are bound to
+	paginationField = "pagination"
+)
+
+// pathParamPattern matches the {field} segments of a route path
+var pathParamPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// route is an RPC exposed at a REST path
+type route struct {
+	procedure  string
+	input      protoreflect.MessageType
+	pathParams []string
+	body       bool
+	// conditional answers requests with 304 when the client's copy is current: GET responses
+	// with an ETag or Last-Modified header set by their handler can be revalidated
+	conditional bool
+}
+
+// Transcoder serves the RPCs annotated with a (healthapp.v1.http) rule at their REST paths.
+// Requests are translated to Connect JSON requests and served by the Connect handlers,
+// so REST calls go through the same interceptors as Connect calls.
+type Transcoder struct {
+	mux *http.ServeMux
+}
+
+// NewTranscoder creates a transcoder for the annotated RPCs of the named services, forwarding
+// the translated requests to connectHandler
+func NewTranscoder(connectHandler http.Handler, serviceNames ...string) (*Transcoder, error) {
+	t := &Transcoder{mux: http.NewServeMux()}
+	for _, name := range serviceNames {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find service %s: %w", name, err)
+		}
+		service, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a service", name)
+		}
+		methods := service.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			method := methods.Get(i)
+			rule, ok := httpRule(method)
+			if !ok {
+				continue
+			}
+			httpMethod, path, err := rulePattern(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid http rule of %s: %w", method.FullName(), err)
+			}
+			input, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+			if err != nil {
+				return nil, fmt.Errorf("failed to find input type of %s: %w", method.FullName(), err)
+			}
+
+			r := route{
+				procedure:   "/" + string(service.FullName()) + "/" + string(method.Name()),
+				input:       input,
+				body:        rule.GetBody() == "*",
+				conditional: httpMethod == http.MethodGet,
+			}
+			for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+				if input.Descriptor().Fields().ByName(protoreflect.Name(m[1])) == nil {
+					return nil, fmt.Errorf("path parameter %q of %s is not a request field", m[1], method.FullName())
+				}
+				r.pathParams = append(r.pathParams, m[1])
+			}
+			t.mux.Handle(httpMethod+" "+path, t.handler(connectHandler, r))
+		}
+	}
+	return t, nil
+}
+
+// ServeHTTP routes a REST request to its RPC
+func (t *Transcoder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mux.ServeHTTP(w, r)
+}
+
+// handler translates requests of a route into Connect unary JSON requests
+func (t *Transcoder) handler(connectHandler http.Handler, rt route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := rt.input.New().Interface()
+		if err := bindRequest(r, rt, msg); err != nil {
+			writeError(w, err)
+			return
+		}
+		body, err := protojson.Marshal(msg)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		req := r.Clone(r.Context())
+		req.Method = http.MethodPost
+		req.URL = &url.URL{Path: rt.procedure}
+		req.RequestURI = ""
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Connect-Protocol-Version", "1")
+		req.Header.Del("Content-Encoding")
+		if rt.conditional {
+			w = &conditionalWriter{ResponseWriter: w, req: r}
+		}
+		connectHandler.ServeHTTP(w, req)
+	})
+}
+
+// bindRequest sets the request fields from the JSON body, path parameters and query string
+func bindRequest(r *http.Request, rt route, msg proto.Message) error {
+	if rt.body {
+		data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := protojson.Unmarshal(data, msg); err != nil {
+				return fmt.Errorf("invalid request body: %w", err)
+			}
+		}
+	}
+
+	m := msg.ProtoReflect()
+	for _, name := range rt.pathParams {
+		if err := setField(m, name, []string{r.PathValue(name)}); err != nil {
+			return err
+		}
+	}
+	if rt.body {
+		// All other fields come from the body
+		return nil
+	}
+	for key, values := range r.URL.Query() {
+		if err := setField(m, key, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField sets the field at a dotted path, e.g. pagination.page_size. Unqualified
+// page_size, page_number and page_token refer to the pagination field of list requests.
+func setField(m protoreflect.Message, path string, values []string) error {
+	names := strings.Split(path, ".")
+	if len(names) == 1 && findField(m.Descriptor(), names[0]) == nil {
+		if p := m.Descriptor().Fields().ByName(paginationField); p != nil && p.Message() != nil && findField(p.Message(), names[0]) != nil {
+			names = []string{paginationField, names[0]}
+		}
+	}
+
+	for i, name := range names {
+		fd := findField(m.Descriptor(), name)
+		if fd == nil {
+			return fmt.Errorf("unknown parameter %q", path)
+		}
+		if i == len(names)-1 {
+			return setValues(m, fd, path, values)
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("parameter %q does not refer to a message field", path)
+		}
+		m = m.Mutable(fd).Message()
+	}
+	return nil
+}
+
+// findField looks up a field by its proto or JSON name
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return md.Fields().ByJSONName(name)
+}
+
+// setValues parses and sets the values of a scalar or repeated field
+func setValues(m protoreflect.Message, fd protoreflect.FieldDescriptor, path string, values []string) error {
+	if fd.IsMap() {
+		return fmt.Errorf("parameter %q refers to a map field", path)
+	}
+	if !fd.IsList() && len(values) > 1 {
+		return fmt.Errorf("parameter %q must not be repeated", path)
+	}
+	for _, s := range values {
+		v, err := parseValue(m, fd, s)
+		if err != nil {
+			return fmt.Errorf("invalid value for parameter %q: %w", path, err)
+		}
+		if fd.IsList() {
+			m.Mutable(fd).List().Append(v)
+		} else {
+			m.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+// parseValue parses a path or query parameter into a value of the field's type.
+// Message fields such as timestamps and wrappers use their JSON string form.
+func parseValue(m protoreflect.Message, fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n)) == nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown enum value %q", s)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.MessageKind:
+		var v protoreflect.Value
+		if fd.IsList() {
+			v = m.Mutable(fd).List().NewElement()
+		} else {
+			v = m.NewField(fd)
+		}
+		// Well-known types accept a JSON string (timestamps, string wrappers) or a bare
+		// JSON value (numeric and bool wrappers)
+		msg := v.Message().Interface()
+		if err := protojson.Unmarshal([]byte(strconv.Quote(s)), msg); err != nil {
+			if err := protojson.Unmarshal([]byte(s), msg); err != nil {
+				return protoreflect.Value{}, err
+			}
+		}
+		return v, nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+	}
+}
+
+// httpRule returns the (healthapp.v1.http) option of a method
+func httpRule(method protoreflect.MethodDescriptor) (*v1.HttpRule, bool) {
+	opts, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok || opts == nil || !proto.HasExtension(opts, v1.E_Http) {
+		return nil, false
+	}
+	rule, ok := proto.GetExtension(opts, v1.E_Http).(*v1.HttpRule)
+	return rule, ok && rule != nil
+}
+
+// rulePattern returns the HTTP method and path of a rule
+func rulePattern(rule *v1.HttpRule) (string, string, error) {
+	switch p := rule.GetPattern().(type) {
+	case *v1.HttpRule_Get:
+		return http.MethodGet, p.Get, nil
+	case *v1.HttpRule_Post:
+		return http.MethodPost, p.Post, nil
+	case *v1.HttpRule_Put:
+		return http.MethodPut, p.Put, nil
+	case *v1.HttpRule_Patch:
+		return http.MethodPatch, p.Patch, nil
+	case *v1.HttpRule_Delete:
+		return http.MethodDelete, p.Delete, nil
+	default:
+		return "", "", errors.New("no method and path set")
+	}
+}
+
+// writeError writes a request translation error in the Connect error format
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	body, _ := json.Marshal(map[string]string{
+		"code":    connect.CodeInvalidArgument.String(),
+		"message": err.Error(),
+	})
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,217 @@
+package retention
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/google/uuid"
+)
+
+// batchSize is how many rows are purged or archived at once
+const batchSize = 500
+
+// archiveContentType is the content type of archives, one JSON record per line
+const archiveContentType = "application/x-ndjson"
+
+// Report is the outcome of applying the policy once; in dry runs, the data it would purge and
+// archive
+type Report struct {
+	DryRun                  bool
+	RecordChangesPurged     int64
+	APIUsagePurged          int64
+	BodyRecordsArchived     int64
+	AttachmentsArchived     int64
+	ExerciseRecordsArchived int64
+	// FilesDeleted counts the deleted photo files of the archived attachments
+	FilesDeleted int64
+}
+
+// Job applies the retention policy: it purges old record changes and moves old records to the
+// attachment store, skipping the data of users who opted out. The attachments of body records
+// are archived with them and their photo files deleted. Old daily request counts of the quota
+// are purged for all users.
+type Job struct {
+	repo  *repo.RetentionRepository
+	store storage.Store
+	cfg   config.RetentionConfig
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewJob creates a job applying the policy of cfg, archiving records to store
+func NewJob(repo *repo.RetentionRepository, store storage.Store, cfg config.RetentionConfig, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		repo:  repo,
+		store: store,
+		cfg:   cfg,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// Run applies the policy immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Apply(ctx, j.cfg.DryRun); err != nil {
+			j.log.ErrorContext(ctx, "Failed to apply retention policy", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Apply purges and archives the data past the policy, or with dryRun only counts it, and logs
+// the report
+func (j *Job) Apply(ctx context.Context, dryRun bool) (Report, error) {
+	changeCutoff, usageCutoff, recordCutoff := j.Cutoffs(j.clock.Now())
+	report := Report{DryRun: dryRun}
+	if dryRun {
+		counts, err := j.repo.Report(ctx, changeCutoff, usageCutoff, recordCutoff)
+		if err != nil {
+			return Report{}, err
+		}
+		report.RecordChangesPurged = counts.ExpiredRecordChanges
+		report.APIUsagePurged = counts.ExpiredAPIUsage
+		report.BodyRecordsArchived = counts.ArchivableBodyRecords
+		report.AttachmentsArchived = counts.ArchivableAttachments
+		report.ExerciseRecordsArchived = counts.ArchivableExerciseRecords
+		j.logReport(ctx, report)
+		return report, nil
+	}
+
+	// Partial progress is reported, so a failed run shows what it already did
+	err := j.apply(ctx, changeCutoff, usageCutoff, recordCutoff, &report)
+	j.logReport(ctx, report)
+	return report, err
+}
+
+// Cutoffs returns the times before which record changes, daily request counts and records are
+// past the policy at now; zero when they are kept forever. Request counts and records are purged
+// and archived by UTC day.
+func (j *Job) Cutoffs(now time.Time) (changeCutoff, usageCutoff, recordCutoff time.Time) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	if j.cfg.ChangeHistoryDays > 0 {
+		changeCutoff = now.AddDate(0, 0, -j.cfg.ChangeHistoryDays)
+	}
+	if j.cfg.APIUsageDays > 0 {
+		usageCutoff = today.AddDate(0, 0, -j.cfg.APIUsageDays)
+	}
+	if j.cfg.ArchiveAfterYears > 0 {
+		recordCutoff = today.AddDate(-j.cfg.ArchiveAfterYears, 0, 0)
+	}
+	return changeCutoff, usageCutoff, recordCutoff
+}
+
+func (j *Job) apply(ctx context.Context, changeCutoff, usageCutoff, recordCutoff time.Time, report *Report) error {
+	if !changeCutoff.IsZero() {
+		for {
+			deleted, err := j.repo.PurgeRecordChanges(ctx, changeCutoff, batchSize)
+			if err != nil {
+				return err
+			}
+			report.RecordChangesPurged += deleted
+			if deleted < batchSize {
+				break
+			}
+		}
+	}
+	if !usageCutoff.IsZero() {
+		for {
+			deleted, err := j.repo.PurgeAPIUsage(ctx, usageCutoff, batchSize)
+			if err != nil {
+				return err
+			}
+			report.APIUsagePurged += deleted
+			if deleted < batchSize {
+				break
+			}
+		}
+	}
+	if recordCutoff.IsZero() {
+		return nil
+	}
+
+	for {
+		archived, attachments, err := j.repo.ArchiveBodyRecords(ctx, recordCutoff, batchSize, func(records []db.BodyRecord, attachments []db.Attachment) error {
+			if err := archive(ctx, j, "body_records", records); err != nil {
+				return err
+			}
+			if len(attachments) == 0 {
+				return nil
+			}
+			return archive(ctx, j, "attachments", attachments)
+		})
+		if err != nil {
+			return err
+		}
+		report.BodyRecordsArchived += int64(archived)
+		report.AttachmentsArchived += int64(len(attachments))
+
+		// The rows are gone, so files left behind are only storage; don't fail the run for them
+		for _, attachment := range attachments {
+			if err := j.store.Delete(ctx, attachment.StorageKey); err != nil {
+				j.log.WarnContext(ctx, "Failed to delete photo file of archived attachment", "attachmentID", attachment.ID, "key", attachment.StorageKey, "error", err)
+				continue
+			}
+			report.FilesDeleted++
+		}
+		if archived < batchSize {
+			break
+		}
+	}
+	for {
+		archived, err := j.repo.ArchiveExerciseRecords(ctx, recordCutoff, batchSize, func(records []db.ExerciseRecord) error {
+			return archive(ctx, j, "exercise_records", records)
+		})
+		if err != nil {
+			return err
+		}
+		report.ExerciseRecordsArchived += int64(archived)
+		if archived < batchSize {
+			break
+		}
+	}
+	return nil
+}
+
+// archive stores records of a table as JSON lines under a new key of the archive prefix
+func archive[T any](ctx context.Context, j *Job, table string, records []T) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode %s record: %w", table, err)
+		}
+	}
+	key := fmt.Sprintf("%s%s/%s/%s.jsonl", j.cfg.ArchivePrefix, table, j.clock.Now().UTC().Format("2006-01-02"), uuid.New())
+	if err := j.store.Put(ctx, key, archiveContentType, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to store archive %s: %w", key, err)
+	}
+	return nil
+}
+
+func (j *Job) logReport(ctx context.Context, report Report) {
+	j.log.InfoContext(ctx, "Retention policy applied",
+		"dryRun", report.DryRun,
+		"recordChangesPurged", report.RecordChangesPurged,
+		"apiUsagePurged", report.APIUsagePurged,
+		"bodyRecordsArchived", report.BodyRecordsArchived,
+		"attachmentsArchived", report.AttachmentsArchived,
+		"exerciseRecordsArchived", report.ExerciseRecordsArchived,
+		"filesDeleted", report.FilesDeleted)
+}
@@ -0,0 +1,64 @@
+// Package rollup pre-aggregates intraday time series, so charts over long ranges read hourly
+// and daily rollups rather than the raw samples.
+package rollup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// batchSize is how many pending hours are rolled up at once
+const batchSize = 500
+
+// Worker rolls up the hours with new heart rate samples into the hourly and daily rollups
+type Worker struct {
+	repo     *repo.HeartRateRepository
+	interval time.Duration
+	log      *slog.Logger
+}
+
+// NewWorker creates a worker rolling up pending hours once per interval
+func NewWorker(repo *repo.HeartRateRepository, interval time.Duration, log *slog.Logger) *Worker {
+	return &Worker{
+		repo:     repo,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// Run rolls up pending hours immediately and then once per interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.RollUp(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RollUp rolls up pending hours until none are left
+func (w *Worker) RollUp(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		rolledUp, err := w.repo.RollUp(ctx, batchSize)
+		if err != nil {
+			w.log.ErrorContext(ctx, "Failed to roll up heart rate", "error", err)
+			break
+		}
+		total += rolledUp
+		if rolledUp < batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		w.log.DebugContext(ctx, "Heart rate rolled up", "hours", total)
+	}
+}
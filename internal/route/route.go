@@ -0,0 +1,207 @@
+// Package route parses, measures and encodes the GPS tracks of outdoor exercises. Tracks are
+// uploaded as GPX files or encoded polylines and stored as gzip-compressed encoded polylines,
+// which keep coordinates to 5 decimal places (about a meter).
+package route
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// MaxPoints bounds the points of a track
+const MaxPoints = 50000
+
+// earthRadiusMeters is the mean radius of the Earth
+const earthRadiusMeters = 6371008.8
+
+var (
+	// ErrInvalidGPX is returned for GPX files that can't be parsed
+	ErrInvalidGPX = errors.New("invalid GPX file")
+	// ErrInvalidPolyline is returned for malformed encoded polylines
+	ErrInvalidPolyline = errors.New("invalid encoded polyline")
+	// ErrPointCount is returned for tracks of fewer than 2 or more than MaxPoints points
+	ErrPointCount = errors.New("invalid number of points")
+	// ErrInvalidCoordinates is returned for points outside the valid latitudes and longitudes
+	ErrInvalidCoordinates = errors.New("coordinates out of range")
+)
+
+// Point is a position of a track, in degrees
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// gpx is the part of a GPX 1.1 file holding positions: the points of its tracks and routes
+type gpx struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// ParseGPX returns the points of the tracks of a GPX file, or of its routes if it has no
+// tracks, in file order. Segments are joined into a single track.
+func ParseGPX(data []byte) ([]Point, error) {
+	var doc gpx
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGPX, err)
+	}
+
+	var points []Point
+	for _, track := range doc.Tracks {
+		for _, segment := range track.Segments {
+			for _, p := range segment.Points {
+				points = append(points, Point{Lat: p.Lat, Lon: p.Lon})
+			}
+		}
+	}
+	if len(points) == 0 {
+		for _, r := range doc.Routes {
+			for _, p := range r.Points {
+				points = append(points, Point{Lat: p.Lat, Lon: p.Lon})
+			}
+		}
+	}
+	return points, nil
+}
+
+// Validate checks that a track has between 2 and MaxPoints points with valid coordinates
+func Validate(points []Point) error {
+	if len(points) < 2 || len(points) > MaxPoints {
+		return ErrPointCount
+	}
+	for _, p := range points {
+		if math.IsNaN(p.Lat) || math.IsNaN(p.Lon) || p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			return ErrInvalidCoordinates
+		}
+	}
+	return nil
+}
+
+// Distance returns the length of a track in meters, along great circles between its points
+func Distance(points []Point) float64 {
+	var total float64
+	for i := 1; i < len(points); i++ {
+		total += haversine(points[i-1], points[i])
+	}
+	return total
+}
+
+// haversine returns the great-circle distance between two points in meters
+func haversine(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat, dLon := lat2-lat1, (b.Lon-a.Lon)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// EncodePolyline encodes a track in the encoded polyline format, at a precision of 5 decimal
+// places
+func EncodePolyline(points []Point) string {
+	var sb strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range points {
+		lat, lon := int64(math.Round(p.Lat*1e5)), int64(math.Round(p.Lon*1e5))
+		encodeValue(&sb, lat-prevLat)
+		encodeValue(&sb, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return sb.String()
+}
+
+// encodeValue appends a signed coordinate delta to an encoded polyline
+func encodeValue(sb *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte(0x20|(u&0x1f)) + 63)
+		u >>= 5
+	}
+	sb.WriteByte(byte(u) + 63)
+}
+
+// DecodePolyline decodes a track in the encoded polyline format, at a precision of 5 decimal
+// places
+func DecodePolyline(s string) ([]Point, error) {
+	var points []Point
+	var lat, lon int64
+	for i := 0; i < len(s); {
+		dLat, n, err := decodeValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		dLon, n, err := decodeValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		lat, lon = lat+dLat, lon+dLon
+		points = append(points, Point{Lat: float64(lat) / 1e5, Lon: float64(lon) / 1e5})
+	}
+	return points, nil
+}
+
+// decodeValue decodes the signed coordinate delta at the start of s, returning the bytes read
+func decodeValue(s string) (int64, int, error) {
+	var u uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 63 || c > 126 || i >= 12 {
+			return 0, 0, ErrInvalidPolyline
+		}
+		u |= uint64(c-63) & 0x1f << (5 * i)
+		if c-63 < 0x20 {
+			v := int64(u >> 1)
+			if u&1 != 0 {
+				v = ^v
+			}
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, ErrInvalidPolyline
+}
+
+// Compress returns a track as a gzip-compressed encoded polyline, as stored
+func Compress(points []Point) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, EncodePolyline(points)); err != nil {
+		return nil, fmt.Errorf("failed to compress route: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress route: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the encoded polyline of a track stored by Compress
+func Decompress(data []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress route: %w", err)
+	}
+	defer zr.Close()
+	polyline, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress route: %w", err)
+	}
+	return string(polyline), nil
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// streakTypes are the streak types reported by GetCurrentStreaks, in enum order, with the kind
+// stored in streaks
+var streakTypes = []struct {
+	streakType v1.StreakType
+	kind       string
+}{
+	{v1.StreakType_STREAK_TYPE_LOGGING, repo.StreakKindLogging},
+	{v1.StreakType_STREAK_TYPE_BODY_RECORDS, repo.StreakKindBodyRecord},
+	{v1.StreakType_STREAK_TYPE_DIARY_ENTRIES, repo.StreakKindDiaryEntry},
+}
+
+// achievementBadges is the badge catalog. The badges are awarded by the award_streak_badges
+// database function, whose thresholds must match.
+var achievementBadges = []struct {
+	id          string
+	title       string
+	description string
+	days        int32
+}{
+	{"logging_streak_1", "First Log", "Log a body record or diary entry", 1},
+	{"logging_streak_7", "One Week Streak", "Log 7 days in a row", 7},
+	{"logging_streak_30", "One Month Streak", "Log 30 days in a row", 30},
+	{"logging_streak_100", "Hundred Days", "Log 100 days in a row", 100},
+	{"logging_streak_365", "One Year Streak", "Log 365 days in a row", 365},
+}
+
+// AchievementHandler implements the achievement service RPCs
+type AchievementHandler struct {
+	repo  AchievementRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewAchievementHandler creates a new achievement handler
+func NewAchievementHandler(repo AchievementRepository, log *slog.Logger, clock clock.Clock) *AchievementHandler {
+	return &AchievementHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// ListAchievements lists the badge catalog with the user's progress
+func (h *AchievementHandler) ListAchievements(ctx context.Context, req *connect.Request[v1.ListAchievementsRequest]) (*connect.Response[v1.ListAchievementsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	awarded, err := h.repo.FindByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list achievements", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list achievements"))
+	}
+	var longest int32
+	streak, err := h.repo.Streak(ctx, userID, repo.StreakKindLogging)
+	switch {
+	case err == nil:
+		longest = streak.LongestLength
+	case !errors.Is(err, repo.ErrStreakNotFound):
+		h.log.ErrorContext(ctx, "Failed to get logging streak", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list achievements"))
+	}
+
+	awardedAt := make(map[string]time.Time, len(awarded))
+	for _, a := range awarded {
+		awardedAt[a.Badge] = a.AwardedAt
+	}
+
+	// Create response
+	resp := &v1.ListAchievementsResponse{
+		Achievements: make([]*v1.Achievement, 0, len(achievementBadges)),
+	}
+	for _, badge := range achievementBadges {
+		achievement := &v1.Achievement{
+			Id:           badge.id,
+			Title:        badge.title,
+			Description:  badge.description,
+			StreakType:   v1.StreakType_STREAK_TYPE_LOGGING,
+			TargetDays:   badge.days,
+			ProgressDays: min(longest, badge.days),
+		}
+		// Badges are kept when the records that earned them are deleted
+		if at, ok := awardedAt[badge.id]; ok {
+			achievement.Earned = true
+			achievement.EarnedAt = timestamppb.New(at)
+			achievement.ProgressDays = badge.days
+		}
+		resp.Achievements = append(resp.Achievements, achievement)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// GetCurrentStreaks returns the user's streak of each type
+func (h *AchievementHandler) GetCurrentStreaks(ctx context.Context, req *connect.Request[v1.GetCurrentStreaksRequest]) (*connect.Response[v1.GetCurrentStreaksResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	streaks, err := h.repo.Streaks(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list streaks", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get streaks"))
+	}
+	byKind := make(map[string]db.Streak, len(streaks))
+	for _, s := range streaks {
+		byKind[s.Kind] = s
+	}
+
+	// Create response
+	today := h.clock.Now().UTC().Truncate(24 * time.Hour)
+	resp := &v1.GetCurrentStreaksResponse{
+		Streaks: make([]*v1.Streak, 0, len(streakTypes)),
+	}
+	for _, t := range streakTypes {
+		streak := &v1.Streak{Type: t.streakType}
+		if s, ok := byKind[t.kind]; ok {
+			streak.CurrentDays = currentStreakDays(s, today)
+			streak.LongestDays = s.LongestLength
+			streak.LastLoggedOn = s.LastLoggedOn.Time.Format("2006-01-02")
+		}
+		resp.Streaks = append(resp.Streaks, streak)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// currentStreakDays returns the length of a streak on today: a streak whose last logged day is
+// before yesterday is broken
+func currentStreakDays(streak db.Streak, today time.Time) int32 {
+	if !streak.LastLoggedOn.Valid || streak.LastLoggedOn.Time.Before(today.AddDate(0, 0, -1)) {
+		return 0
+	}
+	return streak.CurrentLength
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAchievementHandler(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewAchievementHandler(repo.NewAchievementRepository(testPool), testLogger, mockClock)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	today := fixedTime.Truncate(24 * time.Hour)
+
+	getStreaks := func(t *testing.T) []*v1.Streak {
+		t.Helper()
+		resp, err := handler.GetCurrentStreaks(testCtx, connect.NewRequest(&v1.GetCurrentStreaksRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Streaks, 3)
+		return resp.Msg.Streaks
+	}
+
+	t.Run("No Records", func(t *testing.T) {
+		streaks := getStreaks(t)
+		assert.Equal(t, v1.StreakType_STREAK_TYPE_LOGGING, streaks[0].Type)
+		assert.Zero(t, streaks[0].CurrentDays)
+		assert.Empty(t, streaks[0].LastLoggedOn)
+
+		resp, err := handler.ListAchievements(testCtx, connect.NewRequest(&v1.ListAchievementsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Achievements, len(achievementBadges))
+		for _, a := range resp.Msg.Achievements {
+			assert.False(t, a.Earned, a.Id)
+		}
+	})
+
+	// Six body records in a row up to yesterday, then a diary entry today
+	weight := 80.0
+	for i := 6; i >= 1; i-- {
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, -i), &weight, nil, fixedTime)
+		require.NoError(t, err)
+	}
+	todayEntry, err := testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Today", today, fixedTime)
+	require.NoError(t, err)
+
+	t.Run("Incremental Streaks", func(t *testing.T) {
+		streaks := getStreaks(t)
+		assert.EqualValues(t, 7, streaks[0].CurrentDays)
+		assert.EqualValues(t, 7, streaks[0].LongestDays)
+		assert.Equal(t, "2024-01-15", streaks[0].LastLoggedOn)
+		assert.Equal(t, v1.StreakType_STREAK_TYPE_BODY_RECORDS, streaks[1].Type)
+		assert.EqualValues(t, 6, streaks[1].CurrentDays)
+		assert.Equal(t, "2024-01-14", streaks[1].LastLoggedOn)
+		assert.EqualValues(t, 1, streaks[2].CurrentDays)
+	})
+
+	t.Run("Badges", func(t *testing.T) {
+		resp, err := handler.ListAchievements(testCtx, connect.NewRequest(&v1.ListAchievementsRequest{}))
+		require.NoError(t, err)
+		byID := make(map[string]*v1.Achievement)
+		for _, a := range resp.Msg.Achievements {
+			byID[a.Id] = a
+		}
+		assert.True(t, byID["logging_streak_1"].Earned)
+		assert.NotNil(t, byID["logging_streak_1"].EarnedAt)
+		assert.True(t, byID["logging_streak_7"].Earned)
+		assert.False(t, byID["logging_streak_30"].Earned)
+		assert.Nil(t, byID["logging_streak_30"].EarnedAt)
+		assert.EqualValues(t, 7, byID["logging_streak_30"].ProgressDays)
+	})
+
+	t.Run("Deletion", func(t *testing.T) {
+		// Deleting a record in the middle splits the streak
+		records, err := repo.NewBodyRecordRepository(testPool).FindByUserAndDateRange(ctx, testUserID, today.AddDate(0, 0, -3), today.AddDate(0, 0, -3))
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		_, err = testPool.Exec(ctx, "DELETE FROM body_records WHERE id = $1", records[0].ID)
+		require.NoError(t, err)
+
+		streaks := getStreaks(t)
+		assert.EqualValues(t, 3, streaks[0].CurrentDays)
+		assert.EqualValues(t, 3, streaks[0].LongestDays)
+
+		// Badges are kept
+		resp, err := handler.ListAchievements(testCtx, connect.NewRequest(&v1.ListAchievementsRequest{}))
+		require.NoError(t, err)
+		assert.True(t, resp.Msg.Achievements[1].Earned)
+	})
+
+	t.Run("Backfill", func(t *testing.T) {
+		_, err := testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Backfilled", today.AddDate(0, 0, -3), fixedTime)
+		require.NoError(t, err)
+
+		streaks := getStreaks(t)
+		assert.EqualValues(t, 7, streaks[0].CurrentDays)
+		assert.EqualValues(t, 3, streaks[1].LongestDays)
+		assert.EqualValues(t, 1, streaks[2].CurrentDays)
+		assert.EqualValues(t, 1, streaks[2].LongestDays)
+	})
+
+	t.Run("Broken Streak", func(t *testing.T) {
+		// A streak that reached yesterday is current until today is over
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 1))
+		assert.EqualValues(t, 7, getStreaks(t)[0].CurrentDays)
+
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 2))
+		defer mockClock.SetTime(fixedTime)
+		streaks := getStreaks(t)
+		assert.Zero(t, streaks[0].CurrentDays)
+		assert.EqualValues(t, 7, streaks[0].LongestDays)
+	})
+
+	t.Run("Delete Last Record", func(t *testing.T) {
+		require.NoError(t, diaryRepo.Delete(ctx, todayEntry.ID, testUserID, fixedTime))
+
+		streaks := getStreaks(t)
+		assert.EqualValues(t, 6, streaks[0].CurrentDays)
+		assert.Equal(t, "2024-01-14", streaks[0].LastLoggedOn)
+	})
+
+	t.Run("Backfilled Days Join the Runs Around Them", func(t *testing.T) {
+		// Days before a gap, then the day closing it
+		for _, days := range []int{-9, -8, -7} {
+			_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, days), &weight, nil, fixedTime)
+			require.NoError(t, err)
+		}
+
+		streaks := getStreaks(t)
+		assert.EqualValues(t, 9, streaks[0].CurrentDays)
+		assert.EqualValues(t, 9, streaks[0].LongestDays)
+		assert.EqualValues(t, 2, streaks[1].CurrentDays)
+		assert.EqualValues(t, 6, streaks[1].LongestDays)
+
+		// The streaks match those recomputed from the records
+		_, err := testPool.Exec(ctx, "SELECT recompute_streak($1, kind) FROM unnest(ARRAY['logging', 'body_record', 'diary_entry']) AS kind", testUserID)
+		require.NoError(t, err)
+		for i, recomputed := range getStreaks(t) {
+			assert.Equal(t, streaks[i].CurrentDays, recomputed.CurrentDays, recomputed.Type)
+			assert.Equal(t, streaks[i].LongestDays, recomputed.LongestDays, recomputed.Type)
+			assert.Equal(t, streaks[i].LastLoggedOn, recomputed.LastLoggedOn, recomputed.Type)
+		}
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.GetCurrentStreaks(ctx, connect.NewRequest(&v1.GetCurrentStreaksRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+)
+
+const (
+	// defaultStatsDays and maxStatsDays bound the window of the system stats
+	defaultStatsDays = 30
+	maxStatsDays     = 90
+	// mostReadColumnsLimit is the number of most read columns reported
+	mostReadColumnsLimit = 10
+)
+
+// AdminStatsHandler implements the admin stats service RPCs
+type AdminStatsHandler struct {
+	repo  StatsRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewAdminStatsHandler creates a new admin stats handler
+func NewAdminStatsHandler(repo StatsRepository, log *slog.Logger, clock clock.Clock) *AdminStatsHandler {
+	return &AdminStatsHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// GetSystemStats returns usage stats of the whole system
+func (h *AdminStatsHandler) GetSystemStats(ctx context.Context, req *connect.Request[v1.GetSystemStatsRequest]) (*connect.Response[v1.GetSystemStatsResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	days := int(req.Msg.Days)
+	if days == 0 {
+		days = defaultStatsDays
+	}
+	if days < 0 || days > maxStatsDays {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("days must be between 1 and %d", maxStatsDays))
+	}
+	now := h.clock.Now()
+	endDate := now.UTC().Truncate(24 * time.Hour)
+	startDate := endDate.AddDate(0, 0, 1-days)
+
+	h.log.InfoContext(ctx, "Admin system stats accessed", "callerID", callerID, "days", days)
+	users, err := h.repo.UserStats(ctx, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get user stats", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get system stats"))
+	}
+	recordDays, err := h.repo.RecordsCreatedByDay(ctx, startDate, endDate)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count records created by day", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get system stats"))
+	}
+	columns, err := h.repo.MostReadColumns(ctx, startDate, endDate, mostReadColumnsLimit)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list most read columns", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get system stats"))
+	}
+
+	// Create response
+	stats := &v1.SystemStats{
+		TotalUsers:         users.TotalUsers,
+		WeeklyActiveUsers:  users.WeeklyActiveUsers,
+		MonthlyActiveUsers: users.MonthlyActiveUsers,
+		RecordsPerDay:      make([]*v1.DailyRecordCount, len(recordDays)),
+		MostReadColumns:    make([]*v1.ColumnReadCount, len(columns)),
+	}
+	for i, d := range recordDays {
+		stats.RecordsPerDay[i] = &v1.DailyRecordCount{
+			Date:            d.Day.Time.Format("2006-01-02"),
+			BodyRecords:     d.BodyRecords,
+			ExerciseRecords: d.ExerciseRecords,
+			DiaryEntries:    d.DiaryEntries,
+			MealRecords:     d.MealRecords,
+			Total:           d.BodyRecords + d.ExerciseRecords + d.DiaryEntries + d.MealRecords,
+		}
+	}
+	for i, c := range columns {
+		stats.MostReadColumns[i] = &v1.ColumnReadCount{
+			ColumnId: c.ID.String(),
+			Title:    c.Title,
+			Reads:    c.ReadCount,
+		}
+	}
+
+	return connect.NewResponse(&v1.GetSystemStatsResponse{Stats: stats}), nil
+}
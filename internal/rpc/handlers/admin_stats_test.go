@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSystemStats(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewAdminStatsHandler(repo.NewStatsRepository(testPool), testLogger, mockClock)
+	columnHandler := NewColumnHandler(repo.NewColumnRepository(testPool), DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	adminCtx := newTestContext(context.WithValue(ctx, auth.RolesContextKey, []string{auth.RoleAdmin}))
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	// Setup: the test user was active 2 days ago and another user 10 days ago
+	otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+	require.NoError(t, err)
+	for userID, activeAt := range map[uuid.UUID]time.Time{
+		testUserID:  fixedTime.AddDate(0, 0, -2),
+		otherUserID: fixedTime.AddDate(0, 0, -10),
+	} {
+		_, err := testPool.Exec(ctx, "UPDATE users SET last_activity_at = $1 WHERE id = $2", activeAt, userID)
+		require.NoError(t, err)
+	}
+
+	// Two body records and a diary entry created today, a body record created yesterday
+	today := fixedTime.Truncate(24 * time.Hour)
+	weight := 70.0
+	for _, date := range []time.Time{today, today.AddDate(0, 0, -3)} {
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, date, &weight, nil, fixedTime)
+		require.NoError(t, err)
+	}
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, -1), &weight, nil, fixedTime.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "Title", "Content", today, fixedTime)
+	require.NoError(t, err)
+
+	// Columns read 2 and 1 times, and one never read
+	published := pgtype.Timestamptz{Time: fixedTime.AddDate(0, 0, -30), Valid: true}
+	var columnIDs []uuid.UUID
+	for _, title := range []string{"Popular", "Niche", "Unread"} {
+		column, err := testutil.CreateTestColumn(ctx, testPool, mockClock, uuid.New(), title, "Content", pgtype.Text{}, nil, published)
+		require.NoError(t, err)
+		columnIDs = append(columnIDs, column.ID)
+	}
+	for _, id := range []uuid.UUID{columnIDs[0], columnIDs[1], columnIDs[0]} {
+		_, err := columnHandler.GetColumn(ctx, connect.NewRequest(&v1.GetColumnRequest{Id: id.String()}))
+		require.NoError(t, err)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		resp, err := handler.GetSystemStats(adminCtx, connect.NewRequest(&v1.GetSystemStatsRequest{Days: 7}))
+		require.NoError(t, err)
+		stats := resp.Msg.Stats
+		assert.GreaterOrEqual(t, stats.TotalUsers, int64(2))
+		assert.EqualValues(t, 1, stats.WeeklyActiveUsers)
+		assert.EqualValues(t, 2, stats.MonthlyActiveUsers)
+
+		require.Len(t, stats.RecordsPerDay, 7)
+		assert.Equal(t, "2024-01-09", stats.RecordsPerDay[0].Date)
+		yesterday, todayCount := stats.RecordsPerDay[5], stats.RecordsPerDay[6]
+		assert.Equal(t, "2024-01-14", yesterday.Date)
+		assert.EqualValues(t, 1, yesterday.BodyRecords)
+		assert.Equal(t, "2024-01-15", todayCount.Date)
+		assert.EqualValues(t, 2, todayCount.BodyRecords)
+		assert.EqualValues(t, 1, todayCount.DiaryEntries)
+		assert.EqualValues(t, 3, todayCount.Total)
+		assert.EqualValues(t, 0, stats.RecordsPerDay[0].Total)
+
+		require.Len(t, stats.MostReadColumns, 2)
+		assert.Equal(t, columnIDs[0].String(), stats.MostReadColumns[0].ColumnId)
+		assert.Equal(t, "Popular", stats.MostReadColumns[0].Title)
+		assert.EqualValues(t, 2, stats.MostReadColumns[0].Reads)
+		assert.EqualValues(t, 1, stats.MostReadColumns[1].Reads)
+	})
+
+	t.Run("Default Window", func(t *testing.T) {
+		resp, err := handler.GetSystemStats(adminCtx, connect.NewRequest(&v1.GetSystemStatsRequest{}))
+		require.NoError(t, err)
+		assert.Len(t, resp.Msg.Stats.RecordsPerDay, defaultStatsDays)
+	})
+
+	t.Run("Reads Outside the Window", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 2))
+		defer mockClock.SetTime(fixedTime)
+		resp, err := handler.GetSystemStats(adminCtx, connect.NewRequest(&v1.GetSystemStatsRequest{Days: 1}))
+		require.NoError(t, err)
+		assert.Empty(t, resp.Msg.Stats.MostReadColumns)
+	})
+
+	t.Run("Error - Invalid Days", func(t *testing.T) {
+		for _, days := range []int32{-1, maxStatsDays + 1} {
+			_, err := handler.GetSystemStats(adminCtx, connect.NewRequest(&v1.GetSystemStatsRequest{Days: days}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+	})
+
+	t.Run("Error - Missing Admin Role", func(t *testing.T) {
+		_, err := handler.GetSystemStats(newTestContext(ctx), connect.NewRequest(&v1.GetSystemStatsRequest{}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+}
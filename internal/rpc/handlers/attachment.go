@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxAttachmentsPerBodyRecord bounds the photos of a body record, pending ones included until
+	// repo.PendingAttachmentWindow has passed
+	maxAttachmentsPerBodyRecord = 10
+	// attachmentUploadTTL is how long a signed upload request is valid
+	attachmentUploadTTL = 15 * time.Minute
+	// attachmentDownloadTTL is how long a signed download URL is valid
+	attachmentDownloadTTL = time.Hour
+	// sniffLen is the number of bytes http.DetectContentType considers
+	sniffLen = 512
+)
+
+// attachmentContentTypes are the accepted photo types
+var attachmentContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// AttachmentHandler implements the attachment service RPCs
+type AttachmentHandler struct {
+	repo           AttachmentRepository
+	store          storage.Store
+	maxUploadBytes int64
+	log            *slog.Logger
+	clock          clock.Clock
+}
+
+// NewAttachmentHandler creates a new attachment handler
+func NewAttachmentHandler(repo AttachmentRepository, store storage.Store, maxUploadBytes int64, log *slog.Logger, clock clock.Clock) *AttachmentHandler {
+	return &AttachmentHandler{
+		repo:           repo,
+		store:          store,
+		maxUploadBytes: maxUploadBytes,
+		log:            log,
+		clock:          clock,
+	}
+}
+
+// UploadAttachment creates a pending photo of a body record and signs its upload
+func (h *AttachmentHandler) UploadAttachment(ctx context.Context, req *connect.Request[v1.UploadAttachmentRequest]) (*connect.Response[v1.UploadAttachmentResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	bodyRecordID, err := reqparse.ParseUUID("body_record_id", req.Msg.BodyRecordId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid body record ID", "bodyRecordID", req.Msg.BodyRecordId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	if !attachmentContentTypes[req.Msg.ContentType] {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedContentType))
+	}
+	if req.Msg.SizeBytes <= 0 || req.Msg.SizeBytes > h.maxUploadBytes {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("size must be between 1 and %d bytes", h.maxUploadBytes))
+	}
+
+	now := h.clock.Now()
+	h.log.InfoContext(ctx, "Creating attachment", "bodyRecordID", bodyRecordID, "size", req.Msg.SizeBytes)
+	attachment, err := h.repo.CreateForBodyRecord(ctx, userID, bodyRecordID, uuid.New(), req.Msg.ContentType, req.Msg.SizeBytes, maxAttachmentsPerBodyRecord, now)
+	if err != nil {
+		if errors.Is(err, repo.ErrBodyRecordNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("body record not found"))
+		}
+		if errors.Is(err, repo.ErrAttachmentLimitExceeded) {
+			return nil, apierror.New(connect.CodeResourceExhausted, apierror.ReasonLimitExceeded, fmt.Errorf("too many photos (maximum %d per body record)", maxAttachmentsPerBodyRecord))
+		}
+		h.log.ErrorContext(ctx, "Failed to create attachment", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create attachment"))
+	}
+
+	upload, err := h.store.SignUpload(ctx, attachment.StorageKey, attachment.ContentType, attachment.SizeBytes, attachmentUploadTTL)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to sign upload", "attachmentID", attachment.ID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create attachment"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UploadAttachmentResponse{
+		Attachment: ToProtoAttachment(attachment),
+		Upload: &v1.SignedUpload{
+			Method:    upload.Method,
+			Url:       upload.URL,
+			Headers:   upload.Headers,
+			ExpiresAt: timestamppb.New(now.Add(attachmentUploadTTL)),
+		},
+	})
+
+	return res, nil
+}
+
+// CompleteAttachment verifies the uploaded file of a pending photo and marks it ready
+func (h *AttachmentHandler) CompleteAttachment(ctx context.Context, req *connect.Request[v1.CompleteAttachmentRequest]) (*connect.Response[v1.CompleteAttachmentResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse attachment ID
+	id, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid attachment ID", "attachmentID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	attachment, err := h.repo.FindByID(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrAttachmentNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("attachment not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to get attachment", "attachmentID", id, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to complete attachment"))
+	}
+	if attachment.Status == repo.AttachmentStatusReady {
+		return connect.NewResponse(&v1.CompleteAttachmentResponse{
+			Attachment: h.signedAttachment(ctx, attachment),
+		}), nil
+	}
+
+	// Expired uploads no longer count toward the photo limit, so they can't be completed either
+	if !attachment.CreatedAt.After(h.clock.Now().Add(-repo.PendingAttachmentWindow)) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("attachment upload has expired"))
+	}
+
+	// Verify the file against the declared type and size, so clients can't store anything else
+	object, err := h.store.Stat(ctx, attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("attachment has not been uploaded"))
+		}
+		h.log.ErrorContext(ctx, "Failed to stat attachment", "attachmentID", id, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to complete attachment"))
+	}
+	prefix, err := h.store.ReadPrefix(ctx, attachment.StorageKey, sniffLen)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to read attachment", "attachmentID", id, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to complete attachment"))
+	}
+	if object.Size != attachment.SizeBytes || http.DetectContentType(prefix) != attachment.ContentType {
+		h.log.WarnContext(ctx, "Uploaded file does not match attachment", "attachmentID", id, "size", object.Size, "detectedType", http.DetectContentType(prefix))
+		h.discard(ctx, attachment)
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UploadMismatch))
+	}
+
+	attachment, err = h.repo.MarkReady(ctx, id, userID, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrAttachmentNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("attachment not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to mark attachment ready", "attachmentID", id, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to complete attachment"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CompleteAttachmentResponse{
+		Attachment: h.signedAttachment(ctx, attachment),
+	})
+
+	return res, nil
+}
+
+// DeleteAttachment deletes a photo and its file
+func (h *AttachmentHandler) DeleteAttachment(ctx context.Context, req *connect.Request[v1.DeleteAttachmentRequest]) (*connect.Response[v1.DeleteAttachmentResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse attachment ID
+	id, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid attachment ID", "attachmentID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Deleting attachment", "attachmentID", id)
+	// The file is kept for restoring the attachment, until the trash job purges it
+	_, err = h.repo.Delete(ctx, id, userID, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrAttachmentNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("attachment not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete attachment", "attachmentID", id, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete attachment"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteAttachmentResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// discard deletes a rejected upload and its attachment, so the photo can be uploaded again
+func (h *AttachmentHandler) discard(ctx context.Context, attachment db.Attachment) {
+	if err := h.store.Delete(ctx, attachment.StorageKey); err != nil {
+		h.log.WarnContext(ctx, "Failed to delete rejected attachment file", "attachmentID", attachment.ID, "error", err)
+	}
+	if err := h.repo.Discard(ctx, attachment.ID, attachment.UserID); err != nil {
+		h.log.WarnContext(ctx, "Failed to delete rejected attachment", "attachmentID", attachment.ID, "error", err)
+	}
+}
+
+// signedAttachment converts a ready attachment with a signed download URL
+func (h *AttachmentHandler) signedAttachment(ctx context.Context, attachment db.Attachment) *v1.Attachment {
+	return signedAttachment(ctx, h.store, h.log, attachment, h.clock.Now())
+}
+
+// signedAttachment converts an attachment, signing a download URL valid from now. A URL that
+// can't be signed is logged and left empty, so one broken photo doesn't fail a listing.
+func signedAttachment(ctx context.Context, store storage.Store, log *slog.Logger, attachment db.Attachment, now time.Time) *v1.Attachment {
+	protoAttachment := ToProtoAttachment(attachment)
+	url, err := store.SignDownload(ctx, attachment.StorageKey, attachmentDownloadTTL)
+	if err != nil {
+		log.WarnContext(ctx, "Failed to sign attachment download", "attachmentID", attachment.ID, "error", err)
+		return protoAttachment
+	}
+	protoAttachment.DownloadUrl = url
+	protoAttachment.DownloadUrlExpiresAt = timestamppb.New(now.Add(attachmentDownloadTTL))
+	return protoAttachment
+}
+
+// ToProtoAttachment converts a db.Attachment to a v1.Attachment, without a download URL
+func ToProtoAttachment(attachment db.Attachment) *v1.Attachment {
+	status := v1.AttachmentStatus_ATTACHMENT_STATUS_PENDING
+	if attachment.Status == repo.AttachmentStatusReady {
+		status = v1.AttachmentStatus_ATTACHMENT_STATUS_READY
+	}
+	return &v1.Attachment{
+		Id:           attachment.ID.String(),
+		BodyRecordId: attachment.BodyRecordID.String(),
+		ContentType:  attachment.ContentType,
+		SizeBytes:    attachment.SizeBytes,
+		Status:       status,
+		CreatedAt:    timestamppb.New(attachment.CreatedAt),
+	}
+}
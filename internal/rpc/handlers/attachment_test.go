@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG starts with the PNG signature, so it is detected as image/png
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func TestAttachmentHandler(t *testing.T) {
+	resetDB(t, testPool)
+	store := newTestStore(t)
+	attachmentRepo := repo.NewAttachmentRepository(testPool)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	handler := NewAttachmentHandler(attachmentRepo, store, 1024, testLogger, mockClock)
+	bodyHandler := NewBodyRecordHandler(bodyRecordRepo, attachmentRepo, repo.NewPreferenceRepository(testPool), store, testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	weight := 70.0
+	record, err := bodyRecordRepo.Save(ctx, testUserID, fixedTime.Truncate(24*time.Hour), &weight, nil, "", fixedTime)
+	require.NoError(t, err)
+
+	// upload creates a pending photo and sends data with its signed request
+	upload := func(t *testing.T, contentType string, data []byte) string {
+		t.Helper()
+		resp, err := handler.UploadAttachment(testCtx, connect.NewRequest(&v1.UploadAttachmentRequest{
+			BodyRecordId: record.ID.String(),
+			ContentType:  contentType,
+			SizeBytes:    int64(len(data)),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.AttachmentStatus_ATTACHMENT_STATUS_PENDING, resp.Msg.Attachment.Status)
+		assert.Equal(t, fixedTime.Add(attachmentUploadTTL), resp.Msg.Upload.ExpiresAt.AsTime())
+
+		req := httptest.NewRequest(resp.Msg.Upload.Method, resp.Msg.Upload.Url, bytes.NewReader(data))
+		for name, value := range resp.Msg.Upload.Headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		store.Handler().ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return resp.Msg.Attachment.Id
+	}
+
+	var photoID, downloadURL string
+	t.Run("Upload and Complete", func(t *testing.T) {
+		photoID = upload(t, "image/png", testPNG)
+
+		resp, err := handler.CompleteAttachment(testCtx, connect.NewRequest(&v1.CompleteAttachmentRequest{Id: photoID}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.AttachmentStatus_ATTACHMENT_STATUS_READY, resp.Msg.Attachment.Status)
+		assert.Equal(t, record.ID.String(), resp.Msg.Attachment.BodyRecordId)
+		assert.EqualValues(t, len(testPNG), resp.Msg.Attachment.SizeBytes)
+		assert.NotEmpty(t, resp.Msg.Attachment.DownloadUrl)
+		assert.Equal(t, fixedTime.Add(attachmentDownloadTTL), resp.Msg.Attachment.DownloadUrlExpiresAt.AsTime())
+	})
+
+	t.Run("Photos on Body Records", func(t *testing.T) {
+		// Pending photos are not listed
+		_, err := handler.UploadAttachment(testCtx, connect.NewRequest(&v1.UploadAttachmentRequest{
+			BodyRecordId: record.ID.String(),
+			ContentType:  "image/jpeg",
+			SizeBytes:    100,
+		}))
+		require.NoError(t, err)
+
+		resp, err := bodyHandler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.BodyRecords, 1)
+		require.Len(t, resp.Msg.BodyRecords[0].Photos, 1)
+		assert.Equal(t, photoID, resp.Msg.BodyRecords[0].Photos[0].Id)
+		downloadURL = resp.Msg.BodyRecords[0].Photos[0].DownloadUrl
+
+		rangeResp, err := bodyHandler.GetBodyRecordsByDateRange(testCtx, connect.NewRequest(&v1.GetBodyRecordsByDateRangeRequest{
+			StartDate: "2024-01-01",
+			EndDate:   "2024-01-31",
+		}))
+		require.NoError(t, err)
+		require.Len(t, rangeResp.Msg.BodyRecords, 1)
+		assert.Len(t, rangeResp.Msg.BodyRecords[0].Photos, 1)
+
+		rec := httptest.NewRecorder()
+		store.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, downloadURL, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+		body, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		assert.Equal(t, testPNG, body)
+	})
+
+	t.Run("Rejected Upload", func(t *testing.T) {
+		// A PNG declared as a JPEG is deleted, so it can be uploaded again
+		id := upload(t, "image/jpeg", testPNG)
+		_, err := handler.CompleteAttachment(testCtx, connect.NewRequest(&v1.CompleteAttachmentRequest{Id: id}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+		_, err = handler.CompleteAttachment(testCtx, connect.NewRequest(&v1.CompleteAttachmentRequest{Id: id}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Complete Before Upload", func(t *testing.T) {
+		resp, err := handler.UploadAttachment(testCtx, connect.NewRequest(&v1.UploadAttachmentRequest{
+			BodyRecordId: record.ID.String(),
+			ContentType:  "image/webp",
+			SizeBytes:    100,
+		}))
+		require.NoError(t, err)
+
+		_, err = handler.CompleteAttachment(testCtx, connect.NewRequest(&v1.CompleteAttachmentRequest{Id: resp.Msg.Attachment.Id}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := handler.DeleteAttachment(testCtx, connect.NewRequest(&v1.DeleteAttachmentRequest{Id: photoID}))
+		require.NoError(t, err)
+
+		// The file is kept in the trash, for restoring the photo, until the trash is purged
+		rec := httptest.NewRecorder()
+		store.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, downloadURL, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		_, err = handler.DeleteAttachment(testCtx, connect.NewRequest(&v1.DeleteAttachmentRequest{Id: photoID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Photo Limit", func(t *testing.T) {
+		var err error
+		for i := 0; i <= maxAttachmentsPerBodyRecord && err == nil; i++ {
+			_, err = handler.UploadAttachment(testCtx, connect.NewRequest(&v1.UploadAttachmentRequest{
+				BodyRecordId: record.ID.String(),
+				ContentType:  "image/png",
+				SizeBytes:    100,
+			}))
+		}
+		assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+		count, err := attachmentRepo.CountByBodyRecord(ctx, record.ID, testUserID)
+		require.NoError(t, err)
+		assert.EqualValues(t, maxAttachmentsPerBodyRecord, count)
+
+		// Uploads pending past their window no longer count, and can't be completed
+		mockClock.SetTime(fixedTime.Add(repo.PendingAttachmentWindow))
+		defer mockClock.SetTime(fixedTime)
+		resp, err := handler.UploadAttachment(testCtx, connect.NewRequest(&v1.UploadAttachmentRequest{
+			BodyRecordId: record.ID.String(),
+			ContentType:  "image/png",
+			SizeBytes:    100,
+		}))
+		require.NoError(t, err)
+		var staleID string
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT id FROM attachments WHERE body_record_id = $1 AND status = 'pending' AND id <> $2 LIMIT 1", record.ID, resp.Msg.Attachment.Id).Scan(&staleID))
+		_, err = handler.CompleteAttachment(testCtx, connect.NewRequest(&v1.CompleteAttachmentRequest{Id: staleID}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Invalid Input", func(t *testing.T) {
+		tests := []struct {
+			name string
+			req  *v1.UploadAttachmentRequest
+			code connect.Code
+		}{
+			{"Invalid Body Record ID", &v1.UploadAttachmentRequest{BodyRecordId: "invalid", ContentType: "image/png", SizeBytes: 100}, connect.CodeInvalidArgument},
+			{"Unsupported Content Type", &v1.UploadAttachmentRequest{BodyRecordId: record.ID.String(), ContentType: "image/gif", SizeBytes: 100}, connect.CodeInvalidArgument},
+			{"Empty File", &v1.UploadAttachmentRequest{BodyRecordId: record.ID.String(), ContentType: "image/png", SizeBytes: 0}, connect.CodeInvalidArgument},
+			{"File Too Large", &v1.UploadAttachmentRequest{BodyRecordId: record.ID.String(), ContentType: "image/png", SizeBytes: 1025}, connect.CodeInvalidArgument},
+			{"Unknown Body Record", &v1.UploadAttachmentRequest{BodyRecordId: uuid.NewString(), ContentType: "image/png", SizeBytes: 100}, connect.CodeNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := handler.UploadAttachment(testCtx, connect.NewRequest(tt.req))
+				assert.Equal(t, tt.code, connect.CodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.UploadAttachment(ctx, connect.NewRequest(&v1.UploadAttachmentRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
@@ -5,31 +5,55 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
 	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
 	"github.com/atreya2011/health-management-api/internal/repo"
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/google/uuid"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// maxBodyRecordNoteLength bounds the note of a body record, in characters
+const maxBodyRecordNoteLength = 200
+
 // BodyRecordHandler implements the body record service RPCs
 type BodyRecordHandler struct {
-	repo  *repo.BodyRecordRepository // Use concrete repository type
-	log   *slog.Logger
-	clock clock.Clock
+	repo        BodyRecordRepository
+	attachments AttachmentRepository
+	prefs       PreferenceRepository // Units the weights are shown in
+	store       storage.Store        // Signs the download URLs of photos
+	authorizer  *authz.Authorizer    // Authorizes reads of records shared by other users
+	pageLimits  PageLimits
+	maxRange    int // Days GetBodyRecordsByDateRange may span
+	log         *slog.Logger
+	clock       clock.Clock
 }
 
 // NewBodyRecordHandler creates a new body record handler
-func NewBodyRecordHandler(repo *repo.BodyRecordRepository, log *slog.Logger, clock clock.Clock) *BodyRecordHandler {
+func NewBodyRecordHandler(repo BodyRecordRepository, attachments AttachmentRepository, prefs PreferenceRepository, store storage.Store, authorizer *authz.Authorizer, pageLimits PageLimits, maxDateRangeDays int, log *slog.Logger, clock clock.Clock) *BodyRecordHandler {
 	return &BodyRecordHandler{
-		repo:  repo,
-		log:   log,
-		clock: clock,
+		repo:        repo,
+		attachments: attachments,
+		prefs:       prefs,
+		store:       store,
+		authorizer:  authorizer,
+		pageLimits:  pageLimits,
+		maxRange:    maxDateRangeDays,
+		log:         log,
+		clock:       clock,
 	}
 }
 
@@ -43,20 +67,18 @@ func (h *BodyRecordHandler) CreateBodyRecord(ctx context.Context, req *connect.R
 	}
 
 	// Parse date
-	date, err := time.Parse("2006-01-02", req.Msg.Date)
+	date, err := reqparse.ParseDate("date", req.Msg.Date)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid date format", "date", req.Msg.Date, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid date format: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
-	// Convert protobuf wrappers to Go pointers
-	var weight *float64
-	var bodyFat *float64
-
-	if req.Msg.WeightKg != nil {
-		w := req.Msg.WeightKg.Value
-		weight = &w
+	// Convert protobuf wrappers to Go pointers; the weight is given in kg or in an explicit unit
+	weight, err := requestWeightKg(req.Msg.WeightKg, req.Msg.Weight)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
 	}
+	var bodyFat *float64
 
 	if req.Msg.BodyFatPercentage != nil {
 		bf := req.Msg.BodyFatPercentage.Value
@@ -67,87 +89,89 @@ func (h *BodyRecordHandler) CreateBodyRecord(ctx context.Context, req *connect.R
 	if weight != nil {
 		w := *weight
 		if w <= 0 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("weight must be positive"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WeightNotPositive))
 		}
 		if w > 500 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("weight exceeds maximum allowed value"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WeightTooLarge))
 		}
 	}
 	if bodyFat != nil {
 		bf := *bodyFat
 		if bf < 0 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("body fat percentage cannot be negative"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.BodyFatNegative))
 		}
 		if bf > 100 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("body fat percentage cannot exceed 100%"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.BodyFatTooLarge))
 		}
 	}
+	note := strings.TrimSpace(req.Msg.Note)
+	if utf8.RuneCountInString(note) > maxBodyRecordNoteLength {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NoteTooLong, maxBodyRecordNoteLength))
+	}
 	// Removed instantiation of repo.BodyRecord
 
+	unit, err := preferredWeightUnit(ctx, h.prefs, h.log, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Call repository directly with new signature, passing current time from clock
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Saving body record", "userID", userID, "date", date, "now", now)
-	savedRecord, err := h.repo.Save(ctx, userID, date, weight, bodyFat, now)
+	h.log.InfoContext(ctx, "Saving body record", "date", date, "now", now)
+	saved, err := h.repo.Upsert(ctx, userID, date, weight, bodyFat, note, now)
 	if err != nil {
-		h.log.ErrorContext(ctx, "Failed to save body record", "userID", userID, "error", err)
+		h.log.ErrorContext(ctx, "Failed to save body record", "error", err)
 		// Use CodeInternal for persistence errors
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to save body record"))
 	}
 
 	// Convert persistence model to protobuf message
-	protoRecord := ToProtoBodyRecord(savedRecord) // Use savedRecord (now db.BodyRecord)
+	protoRecord := ToProtoBodyRecord(saved.Record)
+	protoRecord.Weight = toProtoWeight(protoRecord.WeightKg, unit)
 
 	// Create response
 	res := connect.NewResponse(&v1.CreateBodyRecordResponse{
 		BodyRecord: protoRecord,
+		Created:    saved.Created,
 	})
+	if saved.Previous != nil {
+		res.Msg.Previous = ToProtoBodyRecord(*saved.Previous)
+		res.Msg.Previous.Weight = toProtoWeight(res.Msg.Previous.WeightKg, unit)
+	}
 
 	return res, nil
 }
 
 // ListBodyRecords lists body records for the authenticated user
 func (h *BodyRecordHandler) ListBodyRecords(ctx context.Context, req *connect.Request[v1.ListBodyRecordsRequest]) (*connect.Response[v1.ListBodyRecordsResponse], error) {
-	// Get user ID from context
-	userID, err := auth.GetUserID(ctx)
+	// Get the owner of the records to read: the authenticated user, or a user sharing them
+	ownerCtx, userID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeBodyRecords)
 	if err != nil {
-		h.log.ErrorContext(ctx, "User ID not found in context")
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+		return nil, err
 	}
 
-	// Get pagination parameters & apply defaults (moved from service)
-	pageSize := 20  // Default page size
-	pageNumber := 1 // Default page number
-
-	if req.Msg.Pagination != nil {
-		if req.Msg.Pagination.PageSize > 0 {
-			pageSize = int(req.Msg.Pagination.PageSize)
-			// Apply max page size (from service)
-			if pageSize > 100 {
-				pageSize = 100
-			}
-		}
-		if req.Msg.Pagination.PageNumber > 0 {
-			pageNumber = int(req.Msg.Pagination.PageNumber)
-		}
-	}
-	// Ensure page is at least 1 (from service)
-	if pageNumber <= 0 {
-		pageNumber = 1
+	// Weights are shown in the caller's units, also for shared records read in the owner's region
+	unit, err := h.callerWeightUnit(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate offset (from service)
-	offset := (pageNumber - 1) * pageSize
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
 
 	// Call repository directly
-	h.log.InfoContext(ctx, "Fetching body records for user", "userID", userID, "page", pageNumber, "pageSize", pageSize)
-	records, err := h.repo.FindByUser(ctx, userID, pageSize, offset) // Changed from bodyRecordApp.GetBodyRecordsForUser
+	h.log.InfoContext(ctx, "Fetching body records for user", "userID", userID, "page", page.Number, "pageSize", page.Size)
+	records, err := h.repo.FindByUser(ownerCtx, userID, page.Size, page.Offset) // Changed from bodyRecordApp.GetBodyRecordsForUser
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch body records", "userID", userID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch body records"))
 	}
 
 	// Get total count (from service)
-	total, err := h.repo.CountByUser(ctx, userID)
+	total, err := h.repo.CountByUser(ownerCtx, userID)
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to count body records", "userID", userID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count body records"))
@@ -157,22 +181,17 @@ func (h *BodyRecordHandler) ListBodyRecords(ctx context.Context, req *connect.Re
 	protoRecords := make([]*v1.BodyRecord, len(records)) // records is now []db.BodyRecord
 	for i, record := range records {
 		protoRecords[i] = ToProtoBodyRecord(record) // Pass db.BodyRecord
+		protoRecords[i].Weight = toProtoWeight(protoRecords[i].WeightKg, unit)
 	}
-
-	// Calculate pagination response
-	totalPages := (int(total) + pageSize - 1) / pageSize // Ceiling division
-	if totalPages == 0 {
-		totalPages = 1
+	if err := h.attachPhotos(ownerCtx, records, protoRecords); err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch body record photos", "userID", userID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch body records"))
 	}
 
 	// Create response
 	res := connect.NewResponse(&v1.ListBodyRecordsResponse{
 		BodyRecords: protoRecords,
-		Pagination: &v1.PageResponse{
-			TotalItems:  int32(total),
-			TotalPages:  int32(totalPages),
-			CurrentPage: int32(pageNumber),
-		},
+		Pagination:  page.Response(total),
 	})
 
 	return res, nil
@@ -180,29 +199,49 @@ func (h *BodyRecordHandler) ListBodyRecords(ctx context.Context, req *connect.Re
 
 // GetBodyRecordsByDateRange retrieves body records for a specific date range
 func (h *BodyRecordHandler) GetBodyRecordsByDateRange(ctx context.Context, req *connect.Request[v1.GetBodyRecordsByDateRangeRequest]) (*connect.Response[v1.GetBodyRecordsByDateRangeResponse], error) {
-	// Get user ID from context
-	userID, err := auth.GetUserID(ctx)
+	// Get the owner of the records to read: the authenticated user, or a user sharing them
+	ownerCtx, userID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeBodyRecords)
 	if err != nil {
-		h.log.ErrorContext(ctx, "User ID not found in context")
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+		return nil, err
 	}
 
 	// Parse dates
-	startDate, err := time.Parse("2006-01-02", req.Msg.StartDate)
+	startDate, err := reqparse.ParseDate("start_date", req.Msg.StartDate)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid start date format", "startDate", req.Msg.StartDate, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid start date format: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
-	endDate, err := time.Parse("2006-01-02", req.Msg.EndDate)
+	endDate, err := reqparse.ParseDate("end_date", req.Msg.EndDate)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid end date format", "endDate", req.Msg.EndDate, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid end date format: %w", err))
+		return nil, reqparse.Error(err)
+	}
+	if endDate.Before(startDate) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+	// Both dates are inclusive, so a range of one day has equal dates
+	if endDate.Sub(startDate) >= time.Duration(h.maxRange)*24*time.Hour {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DateRangeTooLong, h.maxRange))
+	}
+	var newestFirst bool
+	switch req.Msg.Order {
+	case v1.SortOrder_SORT_ORDER_UNSPECIFIED, v1.SortOrder_SORT_ORDER_ASCENDING:
+	case v1.SortOrder_SORT_ORDER_DESCENDING:
+		newestFirst = true
+	default:
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.SortOrderInvalid))
+	}
+
+	// Weights are shown in the caller's units, also for shared records read in the owner's region
+	unit, err := h.callerWeightUnit(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Call repository directly
-	h.log.InfoContext(ctx, "Fetching body records for user by date range", "userID", userID, "startDate", startDate, "endDate", endDate)
-	records, err := h.repo.FindByUserAndDateRange(ctx, userID, startDate, endDate) // Changed from bodyRecordApp.GetBodyRecordsForUserDateRange
+	h.log.InfoContext(ctx, "Fetching body records for user by date range", "userID", userID, "startDate", startDate, "endDate", endDate, "newestFirst", newestFirst)
+	records, err := h.repo.FindByUserAndDateRangeOrdered(ownerCtx, userID, startDate, endDate, newestFirst) // Changed from bodyRecordApp.GetBodyRecordsForUserDateRange
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch body records by date range", "userID", userID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch body records by date range"))
@@ -212,6 +251,11 @@ func (h *BodyRecordHandler) GetBodyRecordsByDateRange(ctx context.Context, req *
 	protoRecords := make([]*v1.BodyRecord, len(records)) // records is now []db.BodyRecord
 	for i, record := range records {
 		protoRecords[i] = ToProtoBodyRecord(record) // Pass db.BodyRecord
+		protoRecords[i].Weight = toProtoWeight(protoRecords[i].WeightKg, unit)
+	}
+	if err := h.attachPhotos(ownerCtx, records, protoRecords); err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch body record photos", "userID", userID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch body records by date range"))
 	}
 
 	// Create response
@@ -222,7 +266,93 @@ func (h *BodyRecordHandler) GetBodyRecordsByDateRange(ctx context.Context, req *
 	return res, nil
 }
 
-// toProtoBodyRecord converts a db.BodyRecord (sqlc generated) to a v1.BodyRecord
+// DeleteBodyRecordsByDateRange deletes the body records of a date range and their photos, or counts
+// them on a dry run
+func (h *BodyRecordHandler) DeleteBodyRecordsByDateRange(ctx context.Context, req *connect.Request[v1.DeleteBodyRecordsByDateRangeRequest]) (*connect.Response[v1.DeleteBodyRecordsByDateRangeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	startDate, endDate, err := parseDateRange(req.Msg.StartDate, req.Msg.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Msg.DryRun {
+		count, err := h.repo.CountByUserAndDateRange(ctx, userID, startDate, endDate)
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to count body records by date range", "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count body records"))
+		}
+		return connect.NewResponse(&v1.DeleteBodyRecordsByDateRangeResponse{DeletedCount: int32(count)}), nil
+	}
+
+	h.log.InfoContext(ctx, "Deleting body records by date range", "startDate", startDate, "endDate", endDate, "expectedCount", req.Msg.ExpectedCount)
+	// The files of the photos are kept for restoring the records, until the trash job purges them
+	if err := h.repo.DeleteByDateRange(ctx, userID, startDate, endDate, int64(req.Msg.ExpectedCount), h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrRecordCountChanged) {
+			return nil, apierror.New(connect.CodeFailedPrecondition, apierror.ReasonRecordCountChanged, errors.New("the range holds a different number of body records than expected_count"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete body records by date range", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete body records"))
+	}
+	return connect.NewResponse(&v1.DeleteBodyRecordsByDateRangeResponse{DeletedCount: req.Msg.ExpectedCount}), nil
+}
+
+// parseDateRange parses the inclusive date range of a request
+func parseDateRange(start, end string) (time.Time, time.Time, error) {
+	startDate, err := reqparse.ParseDate("start_date", start)
+	if err != nil {
+		return time.Time{}, time.Time{}, reqparse.Error(err)
+	}
+	endDate, err := reqparse.ParseDate("end_date", end)
+	if err != nil {
+		return time.Time{}, time.Time{}, reqparse.Error(err)
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+	return startDate, endDate, nil
+}
+
+// callerWeightUnit returns the weight unit the authenticated caller prefers
+func (h *BodyRecordHandler) callerWeightUnit(ctx context.Context) (units.WeightUnit, error) {
+	callerID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return "", connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+	return preferredWeightUnit(ctx, h.prefs, h.log, callerID)
+}
+
+// attachPhotos sets the ready photos, with signed download URLs, of the converted records
+func (h *BodyRecordHandler) attachPhotos(ctx context.Context, records []db.BodyRecord, protoRecords []*v1.BodyRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(records))
+	byID := make(map[uuid.UUID]*v1.BodyRecord, len(records))
+	for i, record := range records {
+		ids[i] = record.ID
+		byID[record.ID] = protoRecords[i]
+	}
+	attachments, err := h.attachments.FindReadyByBodyRecords(ctx, ids)
+	if err != nil {
+		return err
+	}
+	now := h.clock.Now()
+	for _, attachment := range attachments {
+		protoRecord := byID[attachment.BodyRecordID]
+		protoRecord.Photos = append(protoRecord.Photos, signedAttachment(ctx, h.store, h.log, attachment, now))
+	}
+	return nil
+}
+
+// toProtoBodyRecord converts a db.BodyRecord (sqlc generated) to a v1.BodyRecord, with its weight
+// in kg; handlers convert it to the caller's unit
 func ToProtoBodyRecord(record db.BodyRecord) *v1.BodyRecord { // Accept db.BodyRecord
 	protoRecord := &v1.BodyRecord{
 		Id:     record.ID.String(),
@@ -230,6 +360,7 @@ func ToProtoBodyRecord(record db.BodyRecord) *v1.BodyRecord { // Accept db.BodyR
 		// Date needs conversion from pgtype.Date
 		CreatedAt: timestamppb.New(record.CreatedAt),
 		UpdatedAt: timestamppb.New(record.UpdatedAt),
+		Note:      record.Note.String,
 	}
 
 	// Handle pgtype.Date
@@ -243,6 +374,7 @@ func ToProtoBodyRecord(record db.BodyRecord) *v1.BodyRecord { // Accept db.BodyR
 		w, err := record.WeightKg.Float64Value()
 		if err == nil {
 			protoRecord.WeightKg = &wrapperspb.DoubleValue{Value: w.Float64}
+			protoRecord.Weight = toProtoWeight(protoRecord.WeightKg, units.Kilograms)
 		} else {
 			// Log or handle the error appropriately if conversion fails
 			fmt.Printf("Warning: could not convert WeightKg %v to float64: %v\n", record.WeightKg, err)
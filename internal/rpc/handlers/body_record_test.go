@@ -2,16 +2,26 @@ package handlers
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/rest"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"github.com/atreya2011/health-management-api/internal/testutil"
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -47,6 +57,7 @@ func TestCreateBodyRecord(t *testing.T) {
 					CreatedAt: fixedTimestampPb, // Use fixed time
 					UpdatedAt: fixedTimestampPb, // Use fixed time
 				},
+				Created: true,
 			},
 		},
 		{
@@ -73,6 +84,7 @@ func TestCreateBodyRecord(t *testing.T) {
 					CreatedAt: fixedTimestampPb, // Use fixed time
 					UpdatedAt: fixedTimestampPb, // Use fixed time
 				},
+				Created: true,
 			},
 		},
 		{
@@ -92,15 +104,46 @@ func TestCreateBodyRecord(t *testing.T) {
 					CreatedAt:         fixedTimestampPb, // Use fixed time
 					UpdatedAt:         fixedTimestampPb, // Use fixed time
 				},
+				Created: true,
 			},
 		},
+		{
+			name: "Success - With Note",
+			req: &v1.CreateBodyRecordRequest{
+				Date:     dateStr,
+				WeightKg: &wrapperspb.DoubleValue{Value: 75.0},
+				Note:     "  new scale ",
+			},
+			expectError: false,
+			expectedResp: &v1.CreateBodyRecordResponse{
+				BodyRecord: &v1.BodyRecord{
+					UserId:    testUserID.String(),
+					Date:      dateStr,
+					WeightKg:  &wrapperspb.DoubleValue{Value: 75.0},
+					Note:      "new scale",
+					CreatedAt: fixedTimestampPb, // Use fixed time
+					UpdatedAt: fixedTimestampPb, // Use fixed time
+				},
+				Created: true,
+			},
+		},
+		{
+			name: "Error - Note Too Long",
+			req: &v1.CreateBodyRecordRequest{
+				Date:     dateStr,
+				WeightKg: &wrapperspb.DoubleValue{Value: 75.0},
+				Note:     strings.Repeat("あ", 201),
+			},
+			expectError:  true,
+			expectedResp: nil,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
 			bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
-			handler := NewBodyRecordHandler(bodyRecordRepo, testLogger, mockClock) // Pass mockClock
+			handler := NewBodyRecordHandler(bodyRecordRepo, repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -131,10 +174,124 @@ func TestCreateBodyRecord(t *testing.T) {
 	}
 }
 
+func TestCreateBodyRecordUpsert(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	testCtx := newTestContext(context.Background())
+
+	created, err := handler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-15",
+		WeightKg: wrapperspb.Double(70),
+		Note:     "morning",
+	}))
+	require.NoError(t, err)
+	assert.True(t, created.Msg.Created)
+	assert.Nil(t, created.Msg.Previous)
+
+	// Saving the date again updates its record and returns the replaced values
+	mockClock.SetTime(fixedTime.Add(time.Hour))
+	defer mockClock.SetTime(fixedTime)
+	updated, err := handler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-15",
+		WeightKg: wrapperspb.Double(69.5),
+	}))
+	require.NoError(t, err)
+	assert.False(t, updated.Msg.Created)
+	assert.Equal(t, created.Msg.BodyRecord.Id, updated.Msg.BodyRecord.Id)
+	assert.Equal(t, 69.5, updated.Msg.BodyRecord.WeightKg.GetValue())
+	require.NotNil(t, updated.Msg.Previous)
+	assert.Equal(t, 70.0, updated.Msg.Previous.WeightKg.GetValue())
+	assert.Equal(t, "morning", updated.Msg.Previous.Note)
+	assert.Equal(t, fixedTime, updated.Msg.Previous.UpdatedAt.AsTime())
+}
+
+func TestDeleteBodyRecordsByDateRange(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	handler := NewBodyRecordHandler(bodyRecordRepo, repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	weight := 70.0
+	for _, day := range []int{10, 12, 15} {
+		_, err := bodyRecordRepo.Save(ctx, testUserID, time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC), &weight, nil, "", fixedTime)
+		require.NoError(t, err)
+	}
+	byRange := func(dryRun bool, expected int32) (*connect.Response[v1.DeleteBodyRecordsByDateRangeResponse], error) {
+		return handler.DeleteBodyRecordsByDateRange(testCtx, connect.NewRequest(&v1.DeleteBodyRecordsByDateRangeRequest{
+			StartDate:     "2024-01-10",
+			EndDate:       "2024-01-12",
+			DryRun:        dryRun,
+			ExpectedCount: expected,
+		}))
+	}
+
+	dryRun, err := byRange(true, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, dryRun.Msg.DeletedCount)
+
+	// Records saved since the dry run make the delete fail without deleting anything
+	_, err = bodyRecordRepo.Save(ctx, testUserID, time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC), &weight, nil, "", fixedTime)
+	require.NoError(t, err)
+	_, err = byRange(false, dryRun.Msg.DeletedCount)
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	assert.Equal(t, apierror.ReasonRecordCountChanged, apierror.Reason(err))
+
+	deleted, err := byRange(false, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, deleted.Msg.DeletedCount)
+
+	list, err := handler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, list.Msg.BodyRecords, 1)
+	assert.Equal(t, "2024-01-15", list.Msg.BodyRecords[0].Date)
+}
+
+func TestBodyRecordNotes(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	testCtx := newTestContext(context.Background())
+
+	for date, note := range map[string]string{"2024-01-14": "after flu", "2024-01-15": ""} {
+		_, err := handler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+			Date:     date,
+			WeightKg: wrapperspb.Double(70),
+			Note:     note,
+		}))
+		require.NoError(t, err)
+	}
+
+	list, err := handler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, list.Msg.BodyRecords, 2)
+	assert.Equal(t, "", list.Msg.BodyRecords[0].Note)
+	assert.Equal(t, "after flu", list.Msg.BodyRecords[1].Note)
+
+	// Saving the record again without a note clears it
+	_, err = handler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-14",
+		WeightKg: wrapperspb.Double(69.5),
+	}))
+	require.NoError(t, err)
+	byRange, err := handler.GetBodyRecordsByDateRange(testCtx, connect.NewRequest(&v1.GetBodyRecordsByDateRangeRequest{
+		StartDate: "2024-01-14",
+		EndDate:   "2024-01-14",
+	}))
+	require.NoError(t, err)
+	require.Len(t, byRange.Msg.BodyRecords, 1)
+	assert.Equal(t, "", byRange.Msg.BodyRecords[0].Note)
+}
+
 func TestListBodyRecords(t *testing.T) {
 	resetDB(t, testPool)
 	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
-	handler := NewBodyRecordHandler(bodyRecordRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewBodyRecordHandler(bodyRecordRepo, repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	testCtx := newTestContext(ctx)
 
@@ -184,9 +341,11 @@ func TestListBodyRecords(t *testing.T) {
 			expectedResp: &v1.ListBodyRecordsResponse{
 				BodyRecords: []*v1.BodyRecord{protoRecord1},
 				Pagination: &v1.PageResponse{
-					TotalItems:  2,
-					TotalPages:  2,
-					CurrentPage: 1,
+					TotalItems:    2,
+					TotalPages:    2,
+					CurrentPage:   1,
+					HasNext:       true,
+					NextPageToken: reqparse.EncodePageToken(1),
 				},
 			},
 		},
@@ -202,6 +361,7 @@ func TestListBodyRecords(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 2,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -217,6 +377,7 @@ func TestListBodyRecords(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 3,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -267,10 +428,110 @@ func TestListBodyRecords(t *testing.T) {
 	}
 }
 
+func TestListBodyRecordsPageLimits(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, PageLimits{DefaultPageSize: 2, MaxPageSize: 3}, DefaultMaxDateRangeDays, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	// Setup: five consecutive days of records
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	today := mockClock.Now().UTC().Truncate(24 * time.Hour)
+	weight := 75.0
+	for i := 0; i < 5; i++ {
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.Add(-time.Duration(i)*24*time.Hour), &weight, nil, mockClock.Now())
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name          string
+		pagination    *v1.PageRequest
+		expectedCount int
+		expectedPages int32
+	}{
+		{name: "Configured Default", pagination: nil, expectedCount: 2, expectedPages: 3},
+		{name: "Capped To Configured Maximum", pagination: &v1.PageRequest{PageSize: 10}, expectedCount: 3, expectedPages: 2},
+		{name: "Within Maximum", pagination: &v1.PageRequest{PageSize: 1, PageNumber: 5}, expectedCount: 1, expectedPages: 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{Pagination: tc.pagination}))
+			require.NoError(t, err)
+			assert.Len(t, resp.Msg.BodyRecords, tc.expectedCount)
+			assert.EqualValues(t, 5, resp.Msg.Pagination.TotalItems)
+			assert.Equal(t, tc.expectedPages, resp.Msg.Pagination.TotalPages)
+		})
+	}
+}
+
+func TestBodyRecordsREST(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	ctx := context.Background()
+
+	// Serve the Connect handler behind the transcoder, authenticating as the test user
+	withTestUser := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return next(newTestContext(ctx), req)
+		}
+	})
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewBodyRecordServiceHandler(handler, connect.WithInterceptors(withTestUser)))
+	transcoder, err := rest.NewTranscoder(mux, healthappv1connect.BodyRecordServiceName)
+	require.NoError(t, err)
+	server := httptest.NewServer(transcoder)
+	defer server.Close()
+
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	today := mockClock.Now().UTC().Truncate(24 * time.Hour)
+	weight := 75.0
+	for i := 0; i < 3; i++ {
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.Add(-time.Duration(i)*24*time.Hour), &weight, nil, mockClock.Now())
+		require.NoError(t, err)
+	}
+
+	t.Run("List With Query Pagination", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v1/body-records?page_size=2&page_number=2")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var listResp v1.ListBodyRecordsResponse
+		require.NoError(t, protojson.Unmarshal(body, &listResp))
+		assert.Len(t, listResp.BodyRecords, 1)
+		assert.EqualValues(t, 3, listResp.Pagination.TotalItems)
+		assert.EqualValues(t, 2, listResp.Pagination.CurrentPage)
+	})
+
+	t.Run("Create With JSON Body", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/v1/body-records", "application/json",
+			strings.NewReader(`{"date":"2024-01-10","weightKg":74.5}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var createResp v1.CreateBodyRecordResponse
+		require.NoError(t, protojson.Unmarshal(body, &createResp))
+		assert.Equal(t, 74.5, createResp.BodyRecord.GetWeightKg().GetValue())
+	})
+
+	t.Run("Unknown Query Parameter", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v1/body-records?page_sise=2")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
 func TestGetBodyRecordsByDateRange(t *testing.T) {
 	resetDB(t, testPool)
 	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
-	handler := NewBodyRecordHandler(bodyRecordRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewBodyRecordHandler(bodyRecordRepo, repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	testCtx := newTestContext(ctx)
 
@@ -298,6 +559,7 @@ func TestGetBodyRecordsByDateRange(t *testing.T) {
 		name         string
 		startDate    time.Time
 		endDate      time.Time
+		order        v1.SortOrder
 		expectError  bool
 		expectedResp *v1.GetBodyRecordsByDateRangeResponse
 	}{
@@ -346,6 +608,35 @@ func TestGetBodyRecordsByDateRange(t *testing.T) {
 				BodyRecords: []*v1.BodyRecord{protoLastWeek},
 			},
 		},
+		{
+			name:      "Newest first",
+			startDate: lastWeek,
+			endDate:   today,
+			order:     v1.SortOrder_SORT_ORDER_DESCENDING,
+			expectedResp: &v1.GetBodyRecordsByDateRangeResponse{
+				BodyRecords: []*v1.BodyRecord{protoToday, protoYesterday, protoLastWeek},
+			},
+		},
+		{
+			name:        "End before start",
+			startDate:   today,
+			endDate:     yesterday,
+			expectError: true,
+		},
+		{
+			name:        "Range longer than the maximum",
+			startDate:   today.AddDate(0, 0, -DefaultMaxDateRangeDays),
+			endDate:     today,
+			expectError: true,
+		},
+		{
+			name:      "Range of the maximum",
+			startDate: today.AddDate(0, 0, -DefaultMaxDateRangeDays+1),
+			endDate:   today,
+			expectedResp: &v1.GetBodyRecordsByDateRangeResponse{
+				BodyRecords: []*v1.BodyRecord{protoToday, protoYesterday, protoLastWeek},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -353,11 +644,13 @@ func TestGetBodyRecordsByDateRange(t *testing.T) {
 			req := connect.NewRequest(&v1.GetBodyRecordsByDateRangeRequest{
 				StartDate: tc.startDate.Format("2006-01-02"),
 				EndDate:   tc.endDate.Format("2006-01-02"),
+				Order:     tc.order,
 			})
 			resp, err := handler.GetBodyRecordsByDateRange(testCtx, req)
 
 			if tc.expectError {
 				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
 				assert.Nil(t, resp)
 			} else {
 				require.NoError(t, err)
@@ -397,6 +690,17 @@ func TestGetBodyRecordsByDateRange(t *testing.T) {
 				for date := range foundDates {
 					assert.True(t, expectedDates[date], "Found unexpected record for date %s", date)
 				}
+
+				// Records are ordered by date, oldest first unless newest first is requested
+				dates := make([]string, len(resp.Msg.BodyRecords))
+				for i, record := range resp.Msg.BodyRecords {
+					dates[i] = record.Date
+				}
+				if tc.order == v1.SortOrder_SORT_ORDER_DESCENDING {
+					assert.True(t, slices.IsSortedFunc(dates, func(a, b string) int { return strings.Compare(b, a) }), "Records not newest first: %v", dates)
+				} else {
+					assert.True(t, slices.IsSorted(dates), "Records not oldest first: %v", dates)
+				}
 			}
 		})
 	}
@@ -2,66 +2,57 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"html"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/markdown"
 	"github.com/atreya2011/health-management-api/internal/repo"
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
-	"github.com/google/uuid"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // ColumnHandler implements the column service RPCs
 type ColumnHandler struct {
-	repo  *repo.ColumnRepository // Use concrete repository type
-	log   *slog.Logger
-	clock clock.Clock
+	repo       ColumnRepository
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
 }
 
 // NewColumnHandler creates a new column handler
-func NewColumnHandler(repo *repo.ColumnRepository, log *slog.Logger, clock clock.Clock) *ColumnHandler {
+func NewColumnHandler(repo ColumnRepository, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *ColumnHandler {
 	return &ColumnHandler{
-		repo:  repo,
-		log:   log,
-		clock: clock,
+		repo:       repo,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
 	}
 }
 
 // ListPublishedColumns lists published columns
 func (h *ColumnHandler) ListPublishedColumns(ctx context.Context, req *connect.Request[v1.ListPublishedColumnsRequest]) (*connect.Response[v1.ListPublishedColumnsResponse], error) {
 	// Get pagination parameters
-	pageSize := 20  // Default page size
-	pageNumber := 1 // Default page number
-
-	if req.Msg.Pagination != nil {
-		if req.Msg.Pagination.PageSize > 0 {
-			pageSize = int(req.Msg.Pagination.PageSize)
-		}
-		if req.Msg.Pagination.PageNumber > 0 {
-			pageNumber = int(req.Msg.Pagination.PageNumber)
-		}
-	}
-	// Ensure page is at least 1 (from service)
-	if pageNumber <= 0 {
-		pageNumber = 1
-	}
-	// Apply max page size (from service)
-	if pageSize > 100 {
-		pageSize = 100
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
 	}
 
-	// Calculate offset (from service)
-	offset := (pageNumber - 1) * pageSize
-
 	// Call repository directly, passing current time
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Fetching published columns", "page", pageNumber, "pageSize", pageSize, "now", now)
-	columns, err := h.repo.FindPublished(ctx, pageSize, offset, now)
+	h.log.InfoContext(ctx, "Fetching published columns", "page", page.Number, "pageSize", page.Size, "now", now)
+	columns, err := h.repo.FindPublished(ctx, page.Size, page.Offset, now)
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch published columns", "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch published columns"))
@@ -80,21 +71,13 @@ func (h *ColumnHandler) ListPublishedColumns(ctx context.Context, req *connect.R
 		protoColumns[i] = ToProtoColumn(column) // Pass db.Column
 	}
 
-	// Calculate pagination response
-	totalPages := (int(total) + pageSize - 1) / pageSize // Ceiling division
-	if totalPages == 0 {
-		totalPages = 1
-	}
-
 	// Create response
 	res := connect.NewResponse(&v1.ListPublishedColumnsResponse{
-		Columns: protoColumns,
-		Pagination: &v1.PageResponse{
-			TotalItems:  int32(total),
-			TotalPages:  int32(totalPages),
-			CurrentPage: int32(pageNumber),
-		},
+		Columns:    protoColumns,
+		Pagination: page.Response(total),
 	})
+	// The page changes with its columns, and with the total when columns are published or removed
+	setColumnValidators(res.Header(), columnsETag(fmt.Sprintf("%d/%d/%d", total, page.Number, page.Size), columns...), time.Time{})
 
 	return res, nil
 }
@@ -102,10 +85,10 @@ func (h *ColumnHandler) ListPublishedColumns(ctx context.Context, req *connect.R
 // GetColumn retrieves a specific column by ID
 func (h *ColumnHandler) GetColumn(ctx context.Context, req *connect.Request[v1.GetColumnRequest]) (*connect.Response[v1.GetColumnResponse], error) {
 	// Parse column ID
-	columnID, err := uuid.Parse(req.Msg.Id)
+	columnID, err := reqparse.ParseUUID("id", req.Msg.Id)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid column ID", "columnID", req.Msg.Id, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid column ID: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
 	// Call repository directly, passing current time
@@ -115,7 +98,7 @@ func (h *ColumnHandler) GetColumn(ctx context.Context, req *connect.Request[v1.G
 	if err != nil {
 		if errors.Is(err, repo.ErrColumnNotFound) { // Use postgres error
 			h.log.WarnContext(ctx, "Column not found", "columnID", columnID)
-			return nil, connect.NewError(connect.CodeNotFound, errors.New("column not found"))
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("column not found"))
 		}
 		h.log.ErrorContext(ctx, "Failed to fetch column", "columnID", columnID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch column"))
@@ -127,7 +110,12 @@ func (h *ColumnHandler) GetColumn(ctx context.Context, req *connect.Request[v1.G
 	isPublished := h.isColumnPublished(column) // Use method check
 	if !isPublished {
 		h.log.WarnContext(ctx, "Attempted to access unpublished column", "id", columnID)
-		return nil, connect.NewError(connect.CodeNotFound, errors.New("column not found")) // Treat unpublished as not found
+		return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("column not found")) // Treat unpublished as not found
+	}
+
+	// Count the read for the admin stats; a failure only skews the stats, so it doesn't fail the request
+	if err := h.repo.RecordRead(ctx, columnID, now); err != nil {
+		h.log.WarnContext(ctx, "Failed to record column read", "columnID", columnID, "error", err)
 	}
 
 	// Convert persistence model to protobuf message
@@ -137,6 +125,7 @@ func (h *ColumnHandler) GetColumn(ctx context.Context, req *connect.Request[v1.G
 	res := connect.NewResponse(&v1.GetColumnResponse{
 		Column: protoColumn,
 	})
+	setColumnValidators(res.Header(), columnsETag("", column), columnModifiedAt(column))
 
 	return res, nil
 }
@@ -144,33 +133,15 @@ func (h *ColumnHandler) GetColumn(ctx context.Context, req *connect.Request[v1.G
 // ListColumnsByCategory lists columns by category
 func (h *ColumnHandler) ListColumnsByCategory(ctx context.Context, req *connect.Request[v1.ListColumnsByCategoryRequest]) (*connect.Response[v1.ListColumnsByCategoryResponse], error) {
 	// Get pagination parameters
-	pageSize := 20  // Default page size
-	pageNumber := 1 // Default page number
-
-	if req.Msg.Pagination != nil {
-		if req.Msg.Pagination.PageSize > 0 {
-			pageSize = int(req.Msg.Pagination.PageSize)
-		}
-		if req.Msg.Pagination.PageNumber > 0 {
-			pageNumber = int(req.Msg.Pagination.PageNumber)
-		}
-	}
-	// Ensure page is at least 1 (from service)
-	if pageNumber <= 0 {
-		pageNumber = 1
-	}
-	// Apply max page size (from service)
-	if pageSize > 100 {
-		pageSize = 100
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
 	}
 
-	// Calculate offset (from service)
-	offset := (pageNumber - 1) * pageSize
-
 	// Call repository directly, passing current time
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Fetching columns by category", "category", req.Msg.Category, "page", pageNumber, "pageSize", pageSize, "now", now)
-	columns, err := h.repo.FindByCategory(ctx, req.Msg.Category, pageSize, offset, now)
+	h.log.InfoContext(ctx, "Fetching columns by category", "category", req.Msg.Category, "page", page.Number, "pageSize", page.Size, "now", now)
+	columns, err := h.repo.FindByCategory(ctx, req.Msg.Category, page.Size, page.Offset, now)
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch columns by category", "category", req.Msg.Category, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch columns by category"))
@@ -189,20 +160,10 @@ func (h *ColumnHandler) ListColumnsByCategory(ctx context.Context, req *connect.
 		protoColumns[i] = ToProtoColumn(column) // Pass db.Column
 	}
 
-	// Calculate pagination response
-	totalPages := (int(total) + pageSize - 1) / pageSize // Ceiling division
-	if totalPages == 0 {
-		totalPages = 1
-	}
-
 	// Create response
 	res := connect.NewResponse(&v1.ListColumnsByCategoryResponse{
-		Columns: protoColumns,
-		Pagination: &v1.PageResponse{
-			TotalItems:  int32(total),
-			TotalPages:  int32(totalPages),
-			CurrentPage: int32(pageNumber),
-		},
+		Columns:    protoColumns,
+		Pagination: page.Response(total),
 	})
 
 	return res, nil
@@ -211,33 +172,15 @@ func (h *ColumnHandler) ListColumnsByCategory(ctx context.Context, req *connect.
 // ListColumnsByTag lists columns by tag
 func (h *ColumnHandler) ListColumnsByTag(ctx context.Context, req *connect.Request[v1.ListColumnsByTagRequest]) (*connect.Response[v1.ListColumnsByTagResponse], error) {
 	// Get pagination parameters
-	pageSize := 20  // Default page size
-	pageNumber := 1 // Default page number
-
-	if req.Msg.Pagination != nil {
-		if req.Msg.Pagination.PageSize > 0 {
-			pageSize = int(req.Msg.Pagination.PageSize)
-		}
-		if req.Msg.Pagination.PageNumber > 0 {
-			pageNumber = int(req.Msg.Pagination.PageNumber)
-		}
-	}
-	// Ensure page is at least 1 (from service)
-	if pageNumber <= 0 {
-		pageNumber = 1
-	}
-	// Apply max page size (from service)
-	if pageSize > 100 {
-		pageSize = 100
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
 	}
 
-	// Calculate offset (from service)
-	offset := (pageNumber - 1) * pageSize
-
 	// Call repository directly, passing current time
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Fetching columns by tag", "tag", req.Msg.Tag, "page", pageNumber, "pageSize", pageSize, "now", now)
-	columns, err := h.repo.FindByTag(ctx, req.Msg.Tag, pageSize, offset, now)
+	h.log.InfoContext(ctx, "Fetching columns by tag", "tag", req.Msg.Tag, "page", page.Number, "pageSize", page.Size, "now", now)
+	columns, err := h.repo.FindByTag(ctx, req.Msg.Tag, page.Size, page.Offset, now)
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch columns by tag", "tag", req.Msg.Tag, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch columns by tag"))
@@ -256,20 +199,10 @@ func (h *ColumnHandler) ListColumnsByTag(ctx context.Context, req *connect.Reque
 		protoColumns[i] = ToProtoColumn(column) // Pass db.Column
 	}
 
-	// Calculate pagination response
-	totalPages := (int(total) + pageSize - 1) / pageSize // Ceiling division
-	if totalPages == 0 {
-		totalPages = 1
-	}
-
 	// Create response
 	res := connect.NewResponse(&v1.ListColumnsByTagResponse{
-		Columns: protoColumns,
-		Pagination: &v1.PageResponse{
-			TotalItems:  int32(total),
-			TotalPages:  int32(totalPages),
-			CurrentPage: int32(pageNumber),
-		},
+		Columns:    protoColumns,
+		Pagination: page.Response(total),
 	})
 
 	return res, nil
@@ -278,12 +211,13 @@ func (h *ColumnHandler) ListColumnsByTag(ctx context.Context, req *connect.Reque
 // ToProtoColumn converts a db.Column (sqlc generated) to a v1.Column
 func ToProtoColumn(column db.Column) *v1.Column { // Accept db.Column
 	protoColumn := &v1.Column{
-		Id:        column.ID.String(),
-		Title:     column.Title,
-		Content:   column.Content,
-		Tags:      column.Tags, // Assuming Tags is []string in db.Column
-		CreatedAt: timestamppb.New(column.CreatedAt),
-		UpdatedAt: timestamppb.New(column.UpdatedAt),
+		Id:           column.ID.String(),
+		Title:        column.Title,
+		Content:      column.Content,
+		RenderedHtml: renderColumnContent(column.Content),
+		Tags:         column.Tags, // Assuming Tags is []string in db.Column
+		CreatedAt:    timestamppb.New(column.CreatedAt),
+		UpdatedAt:    timestamppb.New(column.UpdatedAt),
 	}
 
 	// Handle pgtype.Text for Category
@@ -299,6 +233,48 @@ func ToProtoColumn(column db.Column) *v1.Column { // Accept db.Column
 	return protoColumn
 }
 
+// columnsETag returns a strong entity tag of a response of columns, changing whenever one of
+// them is updated or published; suffix identifies the rest of the response, e.g. a page
+func columnsETag(suffix string, columns ...db.Column) string {
+	hash := sha256.New()
+	for _, column := range columns {
+		fmt.Fprintf(hash, "%s:%d:%d;", column.ID, column.UpdatedAt.UnixNano(), columnModifiedAt(column).UnixNano())
+	}
+	hash.Write([]byte(suffix))
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// columnModifiedAt returns the last time the response of a column changed: when it was updated,
+// or published if later
+func columnModifiedAt(column db.Column) time.Time {
+	if column.PublishedAt.Valid && column.PublishedAt.Time.After(column.UpdatedAt) {
+		return column.PublishedAt.Time
+	}
+	return column.UpdatedAt
+}
+
+// setColumnValidators sets the validators of a column response, so REST clients can revalidate
+// their copy with If-None-Match or If-Modified-Since and get 304 Not Modified while it's current.
+// Responses unchanged since a time without one, such as pages, only get the entity tag.
+func setColumnValidators(header http.Header, etag string, modifiedAt time.Time) {
+	header.Set("ETag", etag)
+	if !modifiedAt.IsZero() {
+		header.Set("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+	}
+	// Columns are public, so shared caches may keep them, but must revalidate them first
+	header.Set("Cache-Control", "public, no-cache")
+}
+
+// renderColumnContent renders the Markdown content of a column to sanitized HTML, falling back
+// to the escaped text if it can't be rendered
+func renderColumnContent(content string) string {
+	rendered, err := markdown.Render(content)
+	if err != nil {
+		return "<p>" + html.EscapeString(content) + "</p>"
+	}
+	return rendered
+}
+
 // isColumnPublished checks if a column is published based on its PublishedAt time.
 func (h *ColumnHandler) isColumnPublished(column db.Column) bool {
 	return column.PublishedAt.Valid && column.PublishedAt.Time.Before(h.clock.Now())
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxDigestCategories is the most categories a user subscribes to
+	maxDigestCategories = 20
+	// maxDigestCategoryLength is the longest category a user subscribes to, in characters
+	maxDigestCategoryLength = 50
+)
+
+// ColumnDigestHandler implements the column digest service RPCs
+type ColumnDigestHandler struct {
+	repo ColumnDigestRepository
+	log  *slog.Logger
+}
+
+// NewColumnDigestHandler creates a new column digest handler
+func NewColumnDigestHandler(repo ColumnDigestRepository, log *slog.Logger) *ColumnDigestHandler {
+	return &ColumnDigestHandler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// GetColumnDigestSettings returns whether the user subscribed to the column digest
+func (h *ColumnDigestHandler) GetColumnDigestSettings(ctx context.Context, req *connect.Request[v1.GetColumnDigestSettingsRequest]) (*connect.Response[v1.GetColumnDigestSettingsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	settings, err := h.repo.GetSettings(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get column digest settings", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get column digest settings"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetColumnDigestSettingsResponse{
+		Settings: toProtoColumnDigestSettings(settings),
+	})
+
+	return res, nil
+}
+
+// UpdateColumnDigestSettings subscribes the user to the column digest of the given categories,
+// or unsubscribes them
+func (h *ColumnDigestHandler) UpdateColumnDigestSettings(ctx context.Context, req *connect.Request[v1.UpdateColumnDigestSettingsRequest]) (*connect.Response[v1.UpdateColumnDigestSettingsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input; duplicates are dropped
+	if len(req.Msg.Categories) > maxDigestCategories {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("at most %d categories are allowed", maxDigestCategories))
+	}
+	categories := make([]string, 0, len(req.Msg.Categories))
+	seen := make(map[string]bool)
+	for _, category := range req.Msg.Categories {
+		category = strings.TrimSpace(category)
+		if category == "" || utf8.RuneCountInString(category) > maxDigestCategoryLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("categories must have 1 to %d characters", maxDigestCategoryLength))
+		}
+		if key := strings.ToLower(category); !seen[key] {
+			seen[key] = true
+			categories = append(categories, category)
+		}
+	}
+
+	settings, err := h.repo.SetSettings(ctx, userID, req.Msg.OptedIn, categories)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to set column digest settings", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update column digest settings"))
+	}
+	h.log.InfoContext(ctx, "Column digest settings updated", "optedIn", settings.OptedIn, "categories", settings.Categories)
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateColumnDigestSettingsResponse{
+		Settings: toProtoColumnDigestSettings(settings),
+	})
+
+	return res, nil
+}
+
+// toProtoColumnDigestSettings converts repo.ColumnDigestSettings to v1.ColumnDigestSettings
+func toProtoColumnDigestSettings(settings repo.ColumnDigestSettings) *v1.ColumnDigestSettings {
+	protoSettings := &v1.ColumnDigestSettings{
+		OptedIn:    settings.OptedIn,
+		Categories: settings.Categories,
+	}
+	if settings.LastSentAt.Valid {
+		protoSettings.LastSentAt = timestamppb.New(settings.LastSentAt.Time)
+	}
+	return protoSettings
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/digest"
+	"github.com/atreya2011/health-management-api/internal/email"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSender records the emails sent instead of sending them, failing while err is set
+type recordingSender struct {
+	sent []email.Message
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg email.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestColumnDigest(t *testing.T) {
+	resetDB(t, testPool)
+	digestRepo := repo.NewColumnDigestRepository(testPool)
+	handler := NewColumnDigestHandler(digestRepo, testLogger)
+	sender := &recordingSender{}
+	mailer, err := email.NewMailer(sender, "Health App <no-reply@example.com>")
+	require.NoError(t, err)
+	signer := digest.NewSigner([]byte("test-digest-key"))
+	cfg := config.ColumnDigestConfig{Enabled: true, Interval: time.Hour, BaseURL: "https://api.example.com/", SigningKey: "test-digest-key"}
+	job := digest.NewJob(digestRepo, mailer, signer, cfg, testLogger, mockClock)
+	unsubscribe := digest.NewUnsubscribeHandler(digestRepo, signer, testLogger)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	address := "reader-" + uuid.NewString() + "@example.com"
+	require.NoError(t, repo.NewUserRepository(testPool).SetEmail(ctx, testUserID, address))
+
+	// publish creates a column of category published at publishedAt
+	publish := func(t *testing.T, title, category string, publishedAt time.Time) {
+		t.Helper()
+		_, err := testutil.CreateTestColumn(ctx, testPool, mockClock, uuid.New(), title, "Content", pgtype.Text{String: category, Valid: category != ""}, nil, pgtype.Timestamptz{Time: publishedAt, Valid: true})
+		require.NoError(t, err)
+	}
+	publish(t, "Sleep Better", "Wellness", fixedTime.Add(-24*time.Hour))
+	publish(t, "Protein Basics", "Nutrition", fixedTime.Add(-48*time.Hour))
+	publish(t, "Old News", "Wellness", fixedTime.AddDate(0, 0, -10))
+	publish(t, "Coming Soon", "Wellness", fixedTime.Add(24*time.Hour))
+
+	t.Run("Opted Out By Default", func(t *testing.T) {
+		resp, err := handler.GetColumnDigestSettings(testCtx, connect.NewRequest(&v1.GetColumnDigestSettingsRequest{}))
+		require.NoError(t, err)
+		assert.False(t, resp.Msg.Settings.OptedIn)
+		assert.Empty(t, resp.Msg.Settings.Categories)
+
+		job.SendDue(ctx)
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("Weekly Digest Of The Categories", func(t *testing.T) {
+		resp, err := handler.UpdateColumnDigestSettings(testCtx, connect.NewRequest(&v1.UpdateColumnDigestSettingsRequest{
+			OptedIn:    true,
+			Categories: []string{" Wellness ", "wellness"},
+		}))
+		require.NoError(t, err)
+		assert.True(t, resp.Msg.Settings.OptedIn)
+		assert.Equal(t, []string{"wellness"}, resp.Msg.Settings.Categories)
+
+		job.SendDue(ctx)
+		require.Len(t, sender.sent, 1)
+		msg := sender.sent[0]
+		assert.Equal(t, address, msg.To)
+		assert.Equal(t, "1 new column this week", msg.Subject)
+		assert.Contains(t, msg.Text, "Sleep Better (Wellness)")
+		assert.NotContains(t, msg.Text, "Protein Basics")
+		assert.NotContains(t, msg.Text, "Old News")
+		assert.NotContains(t, msg.Text, "Coming Soon")
+		assert.Contains(t, msg.Text, "https://api.example.com/unsubscribe/column-digest?token=")
+
+		// Nothing is due until a week passed, and then only the columns published since
+		job.SendDue(ctx)
+		assert.Len(t, sender.sent, 1)
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 7))
+		job.SendDue(ctx)
+		require.Len(t, sender.sent, 2)
+		assert.Contains(t, sender.sent[1].Text, "Coming Soon")
+		assert.NotContains(t, sender.sent[1].Text, "Sleep Better")
+
+		settings, err := handler.GetColumnDigestSettings(testCtx, connect.NewRequest(&v1.GetColumnDigestSettingsRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, fixedTime.AddDate(0, 0, 7), settings.Msg.Settings.LastSentAt.AsTime())
+	})
+
+	t.Run("Failed Digests Are Retried", func(t *testing.T) {
+		publish(t, "Stretching", "Wellness", fixedTime.AddDate(0, 0, 10))
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 14))
+		sender.err = assert.AnError
+		job.SendDue(ctx)
+		sender.err = nil
+		job.SendDue(ctx)
+		require.Len(t, sender.sent, 3)
+		assert.Contains(t, sender.sent[2].Text, "Stretching")
+	})
+
+	t.Run("Unsubscribe Links", func(t *testing.T) {
+		link, err := url.Parse(digest.UnsubscribeURL(cfg.BaseURL, signer.Sign(testUserID)))
+		require.NoError(t, err)
+
+		// Opening the link only shows the form
+		rec := httptest.NewRecorder()
+		unsubscribe.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `<form method="post"`)
+		settings, err := digestRepo.GetSettings(ctx, testUserID)
+		require.NoError(t, err)
+		assert.True(t, settings.OptedIn)
+
+		rec = httptest.NewRecorder()
+		unsubscribe.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, link.RequestURI(), strings.NewReader("List-Unsubscribe=One-Click")))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "You are unsubscribed")
+		settings, err = digestRepo.GetSettings(ctx, testUserID)
+		require.NoError(t, err)
+		assert.False(t, settings.OptedIn)
+		assert.Equal(t, []string{"wellness"}, settings.Categories)
+
+		// Forged tokens are rejected
+		for _, token := range []string{digest.NewSigner([]byte("another-key")).Sign(testUserID), "", "not a token"} {
+			rec = httptest.NewRecorder()
+			unsubscribe.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, digest.UnsubscribePath+"?"+url.Values{"token": {token}}.Encode(), nil))
+			assert.Equal(t, http.StatusNotFound, rec.Code, token)
+		}
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		for name, categories := range map[string][]string{
+			"Empty Category":      {" "},
+			"Long Category":       {strings.Repeat("a", 51)},
+			"Too Many Categories": make([]string, 21),
+		} {
+			_, err := handler.UpdateColumnDigestSettings(testCtx, connect.NewRequest(&v1.UpdateColumnDigestSettingsRequest{OptedIn: true, Categories: categories}))
+			require.Error(t, err, name)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+	})
+}
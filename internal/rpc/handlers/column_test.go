@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"connectrpc.com/connect"
 	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/rest"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"github.com/atreya2011/health-management-api/internal/testutil"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -59,7 +64,7 @@ func setupTestColumns(t *testing.T, ctx context.Context, pool *pgxpool.Pool, clk
 func TestListPublishedColumns(t *testing.T) {
 	resetDB(t, testPool)
 	columnRepo := repo.NewColumnRepository(testPool)
-	handler := NewColumnHandler(columnRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewColumnHandler(columnRepo, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	// Set a fixed time for setup consistency
 	mockClock.SetTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
@@ -98,9 +103,11 @@ func TestListPublishedColumns(t *testing.T) {
 			expectedResp: &v1.ListPublishedColumnsResponse{
 				Columns: []*v1.Column{protoCol1},
 				Pagination: &v1.PageResponse{
-					TotalItems:  2,
-					TotalPages:  2,
-					CurrentPage: 1,
+					TotalItems:    2,
+					TotalPages:    2,
+					CurrentPage:   1,
+					HasNext:       true,
+					NextPageToken: reqparse.EncodePageToken(1),
 				},
 			},
 		},
@@ -116,6 +123,7 @@ func TestListPublishedColumns(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 2,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -154,7 +162,7 @@ func TestListPublishedColumns(t *testing.T) {
 func TestGetColumn(t *testing.T) {
 	resetDB(t, testPool)
 	columnRepo := repo.NewColumnRepository(testPool)
-	handler := NewColumnHandler(columnRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewColumnHandler(columnRepo, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	// Set a fixed time for setup consistency
 	fixedTime := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
@@ -219,10 +227,38 @@ func TestGetColumn(t *testing.T) {
 	}
 }
 
+func TestGetColumnRenderedHTML(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewColumnHandler(repo.NewColumnRepository(testPool), DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	content := "## Hydration\n\n" +
+		"Drink **water** <script>alert(1)</script>, see [the guide](https://example.com/water).\n\n" +
+		"[Click](javascript:alert(1)) <img src=x onerror=alert(1)>\n\n" +
+		"| Time | Glasses |\n|:-----|--------:|\n| Morning | 2 |\n"
+	column, err := testutil.CreateTestColumn(ctx, testPool, mockClock, uuid.New(), "Hydration", content,
+		pgtype.Text{}, []string{}, pgtype.Timestamptz{Time: fixedTime.Add(-time.Hour), Valid: true})
+	require.NoError(t, err)
+
+	resp, err := handler.GetColumn(ctx, connect.NewRequest(&v1.GetColumnRequest{Id: column.ID.String()}))
+	require.NoError(t, err)
+	rendered := resp.Msg.Column.RenderedHtml
+	assert.Equal(t, content, resp.Msg.Column.Content)
+	assert.Contains(t, rendered, "<h2>Hydration</h2>")
+	assert.Contains(t, rendered, "<strong>water</strong>")
+	assert.Contains(t, rendered, `<a href="https://example.com/water" rel="noreferrer noopener" target="_blank">the guide</a>`)
+	assert.Contains(t, rendered, `<th align="right">Glasses</th>`)
+	for _, unsafe := range []string{"<script", "javascript:", "onerror", "<img"} {
+		assert.NotContains(t, rendered, unsafe)
+	}
+}
+
 func TestListColumnsByCategory(t *testing.T) {
 	resetDB(t, testPool)
 	columnRepo := repo.NewColumnRepository(testPool)
-	handler := NewColumnHandler(columnRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewColumnHandler(columnRepo, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	// Set a fixed time for setup consistency
 	mockClock.SetTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
@@ -320,7 +356,7 @@ func TestListColumnsByCategory(t *testing.T) {
 func TestListColumnsByTag(t *testing.T) {
 	resetDB(t, testPool)
 	columnRepo := repo.NewColumnRepository(testPool)
-	handler := NewColumnHandler(columnRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewColumnHandler(columnRepo, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	// Set a fixed time for setup consistency
 	mockClock.SetTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
@@ -430,3 +466,76 @@ func TestListColumnsByTag(t *testing.T) {
 		})
 	}
 }
+
+func TestColumnConditionalRequests(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewColumnHandler(repo.NewColumnRepository(testPool), DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	mockClock.SetTime(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC))
+	col1, _, _, _, _ := setupTestColumns(t, ctx, testPool, mockClock)
+
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewColumnServiceHandler(handler))
+	transcoder, err := rest.NewTranscoder(mux, healthappv1connect.ColumnServiceName)
+	require.NoError(t, err)
+	server := httptest.NewServer(transcoder)
+	defer server.Close()
+
+	// get requests path with the conditional header set to value, if any
+	get := func(t *testing.T, path, header, value string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	columnPath := "/v1/columns/" + col1.ID.String()
+
+	t.Run("Column Revalidated by ETag", func(t *testing.T) {
+		resp := get(t, columnPath, "", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		assert.Equal(t, col1.UpdatedAt.UTC().Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+
+		notModified := get(t, columnPath, "If-None-Match", etag)
+		assert.Equal(t, http.StatusNotModified, notModified.StatusCode)
+		assert.Equal(t, etag, notModified.Header.Get("ETag"))
+		assert.Equal(t, http.StatusOK, get(t, columnPath, "If-None-Match", `"stale"`).StatusCode)
+	})
+
+	t.Run("Column Revalidated by Date", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotModified, get(t, columnPath, "If-Modified-Since", mockClock.Now().UTC().Format(http.TimeFormat)).StatusCode)
+		assert.Equal(t, http.StatusOK, get(t, columnPath, "If-Modified-Since", col1.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)).StatusCode)
+	})
+
+	t.Run("Updated Column Changes ETag", func(t *testing.T) {
+		etag := get(t, columnPath, "", "").Header.Get("ETag")
+		_, err := testPool.Exec(ctx, "UPDATE columns SET title = 'Updated', updated_at = $2 WHERE id = $1", col1.ID, mockClock.Now().Add(time.Minute))
+		require.NoError(t, err)
+
+		resp := get(t, columnPath, "If-None-Match", etag)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+	})
+
+	t.Run("Published Columns Revalidated", func(t *testing.T) {
+		resp := get(t, "/v1/columns?page_size=10", "", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		etag := resp.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+		assert.Equal(t, http.StatusNotModified, get(t, "/v1/columns?page_size=10", "If-None-Match", etag).StatusCode)
+		// Another page is another response
+		assert.Equal(t, http.StatusOK, get(t, "/v1/columns?page_size=1", "If-None-Match", etag).StatusCode)
+
+		// Publishing a column changes the list
+		_, err := testPool.Exec(ctx, "UPDATE columns SET published_at = $1 WHERE published_at > $1", mockClock.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, get(t, "/v1/columns?page_size=10", "If-None-Match", etag).StatusCode)
+	})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/compress"
+	"github.com/atreya2011/health-management-api/internal/rest"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	// get requests path from handler, accepting gzip if gzipped
+	get := func(t *testing.T, handler http.Handler, path string, gzipped bool) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip, deflate")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	// body reads the body of resp, gunzipping it if it is compressed
+	body := func(t *testing.T, resp *http.Response) string {
+		t.Helper()
+		var r io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == compress.Encoding {
+			gz, err := gzip.NewReader(resp.Body)
+			require.NoError(t, err)
+			r = gz
+		}
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	large := strings.Repeat(`{"weight":70.5,"date":"2024-01-15"}`, 100)
+	handler := compress.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/small" {
+			_, _ = io.WriteString(w, `{}`)
+			return
+		}
+		_, _ = io.WriteString(w, large)
+	}), 1024)
+
+	t.Run("Large Responses Are Gzipped", func(t *testing.T) {
+		resp := get(t, handler, "/large", true)
+		assert.Equal(t, compress.Encoding, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, large, body(t, resp))
+	})
+
+	t.Run("Small Responses Are Not", func(t *testing.T) {
+		resp := get(t, handler, "/small", true)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, `{}`, body(t, resp))
+	})
+
+	t.Run("Clients Not Accepting Gzip", func(t *testing.T) {
+		resp := get(t, handler, "/large", false)
+		assert.Empty(t, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, large, body(t, resp))
+
+		req := httptest.NewRequest(http.MethodGet, "/large", nil)
+		req.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+		assert.False(t, compress.AcceptsGzip(req))
+	})
+
+	t.Run("Streamed Responses Are Gzipped", func(t *testing.T) {
+		streaming := compress.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = io.WriteString(w, "date,weight\n")
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, "2024-01-15,70.5\n")
+		}), 1024)
+		resp := get(t, streaming, "/export.csv", true)
+		assert.Equal(t, compress.Encoding, resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "date,weight\n2024-01-15,70.5\n", body(t, resp))
+	})
+
+	t.Run("Connect and REST Responses Are Gzipped", func(t *testing.T) {
+		mux := http.NewServeMux()
+		path, serverService := healthappv1connect.NewServerServiceHandler(NewServerHandler(version.Get(), testLogger), connect.WithCompressMinBytes(1))
+		mux.Handle(path, serverService)
+		transcoder, err := rest.NewTranscoder(mux, healthappv1connect.ServerServiceName)
+		require.NoError(t, err)
+
+		resp := get(t, transcoder, "/v1/server-info", true)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, compress.Encoding, resp.Header.Get("Content-Encoding"))
+		assert.Contains(t, body(t, resp), `"goVersion"`)
+	})
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIPackage is the proto package whose services the OpenAPI specs document
+const openAPIPackage = "healthapp.v1"
+
+// openAPISpec is the part of a spec generated by protoc-gen-connect-openapi the contract tests read
+type openAPISpec struct {
+	Paths map[string]struct {
+		Get  *openAPIOperation `yaml:"get"`
+		Post *openAPIOperation `yaml:"post"`
+	} `yaml:"paths"`
+	Components struct {
+		Schemas map[string]any `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type openAPIOperation struct {
+	RequestBody struct {
+		Content map[string]openAPIMediaType `yaml:"content"`
+	} `yaml:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]openAPIMediaType `yaml:"content"`
+	} `yaml:"responses"`
+}
+
+type openAPIMediaType struct {
+	Schema struct {
+		Ref string `yaml:"$ref"`
+	} `yaml:"schema"`
+}
+
+// openAPIContract validates the JSON of RPCs against the OpenAPI specs
+type openAPIContract struct {
+	// specs by the procedure paths they document
+	specs map[string]*openAPISpec
+	// schemas of the whole package, by name; the specs of all files define the same messages alike
+	schemas map[string]any
+
+	mu       sync.Mutex
+	compiled map[string]*gojsonschema.Schema
+}
+
+// loadOpenAPIContract reads the specs generated by make proto from third_party/openapi
+func loadOpenAPIContract(t *testing.T) *openAPIContract {
+	t.Helper()
+	dir, err := findProjectDir(filepath.Join("third_party", "openapi"))
+	require.NoError(t, err, "no OpenAPI specs found, run make proto")
+
+	c := &openAPIContract{specs: map[string]*openAPISpec{}, schemas: map[string]any{}, compiled: map[string]*gojsonschema.Schema{}}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".openapi.yaml") {
+			return err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var spec openAPISpec
+		if err := yaml.Unmarshal(raw, &spec); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for p := range spec.Paths {
+			c.specs[p] = &spec
+		}
+		for name, schema := range spec.Components.Schemas {
+			c.schemas[name] = schema
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, c.specs, "no OpenAPI specs found in %s, run make proto", dir)
+	return c
+}
+
+// findProjectDir finds the directory rel of the project root, walking up from the working directory
+func findProjectDir(rel string) (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, rel)
+		if stat, err := os.Stat(candidate); err == nil && stat.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("could not find %s by walking up from the working directory", rel)
+		}
+		dir = parent
+	}
+}
+
+// operation returns the operation documenting procedure, e.g. /healthapp.v1.DiaryService/GetDiaryEntry
+func (c *openAPIContract) operation(procedure string) (*openAPIOperation, error) {
+	spec, ok := c.specs[procedure]
+	if !ok {
+		return nil, fmt.Errorf("%s is not documented", procedure)
+	}
+	item := spec.Paths[procedure]
+	if item.Post != nil {
+		return item.Post, nil
+	}
+	if item.Get != nil {
+		return item.Get, nil
+	}
+	return nil, fmt.Errorf("%s has no POST or GET operation", procedure)
+}
+
+// schemaRefs returns the references of the request, success response and error response schemas of
+// procedure
+func (c *openAPIContract) schemaRefs(procedure string) (request, response, errorResponse string, err error) {
+	op, err := c.operation(procedure)
+	if err != nil {
+		return "", "", "", err
+	}
+	return op.RequestBody.Content["application/json"].Schema.Ref,
+		op.Responses["200"].Content["application/json"].Schema.Ref,
+		op.Responses["default"].Content["application/json"].Schema.Ref,
+		nil
+}
+
+// validate validates the JSON document against the component schema referenced by ref
+func (c *openAPIContract) validate(ref string, document []byte) error {
+	schema, err := c.compile(ref)
+	if err != nil {
+		return err
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(document))
+	if err != nil {
+		return err
+	}
+	if !result.Valid() {
+		var problems []string
+		for _, e := range result.Errors() {
+			problems = append(problems, e.String())
+		}
+		return fmt.Errorf("%s does not match %s: %s", document, ref, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c *openAPIContract) compile(ref string) (*gojsonschema.Schema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if schema, ok := c.compiled[ref]; ok {
+		return schema, nil
+	}
+	if !strings.HasPrefix(ref, "#/components/schemas/") {
+		return nil, fmt.Errorf("unexpected schema reference %q", ref)
+	}
+	// The reference resolves within a document holding the components, as in the specs
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(map[string]any{
+		"$ref":       ref,
+		"components": map[string]any{"schemas": c.schemas},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s: %w", ref, err)
+	}
+	c.compiled[ref] = schema
+	return schema, nil
+}
+
+// middleware validates the JSON requests and responses of the Connect procedures it serves
+func (c *openAPIContract) middleware(t *testing.T, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestRef, responseRef, errorRef, err := c.schemaRefs(r.URL.Path)
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := c.validate(requestRef, body); err != nil {
+			t.Errorf("request of %s: %v", r.URL.Path, err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		// Responses are left uncompressed to be validated
+		r.Header.Del("Accept-Encoding")
+
+		recorder := httptest.NewRecorder()
+		next.ServeHTTP(recorder, r)
+		ref := responseRef
+		if recorder.Code != http.StatusOK {
+			ref = errorRef
+		}
+		if err := c.validate(ref, recorder.Body.Bytes()); err != nil {
+			t.Errorf("response %d of %s: %v", recorder.Code, r.URL.Path, err)
+		}
+
+		for k, v := range recorder.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(recorder.Code)
+		_, _ = w.Write(recorder.Body.Bytes())
+	})
+}
+
+// populateSample sets every field of m, following one member of each oneof and adding one element
+// to lists and maps, so its JSON has every property a client may receive. Messages nested deeper
+// than depth are left unset, which ends recursive messages.
+func populateSample(m protoreflect.Message, depth int) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && m.WhichOneof(oneof) != nil {
+			continue
+		}
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			if fd.Message() != nil {
+				element := list.NewElement()
+				populateSample(element.Message(), depth-1)
+				list.Append(element)
+			} else {
+				list.Append(sampleScalar(fd))
+			}
+		case fd.IsMap():
+			entries := m.Mutable(fd).Map()
+			value := sampleScalar(fd.MapValue())
+			if fd.MapValue().Message() != nil {
+				value = entries.NewValue()
+				populateSample(value.Message(), depth-1)
+			}
+			entries.Set(sampleScalar(fd.MapKey()).MapKey(), value)
+		case fd.Message() != nil:
+			if depth > 0 {
+				populateSample(m.Mutable(fd).Message(), depth-1)
+			}
+		default:
+			m.Set(fd, sampleScalar(fd))
+		}
+	}
+}
+
+// sampleScalar returns a non-zero value of the scalar or enum field fd
+func sampleScalar(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(values.Len() - 1).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(1)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(1.5)
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte("sample"))
+	default:
+		return protoreflect.ValueOfString("sample")
+	}
+}
+
+// packageServices returns the services of openAPIPackage
+func packageServices() []protoreflect.ServiceDescriptor {
+	var services []protoreflect.ServiceDescriptor
+	protoregistry.GlobalFiles.RangeFilesByPackage(openAPIPackage, func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			services = append(services, fd.Services().Get(i))
+		}
+		return true
+	})
+	return services
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	contract := loadOpenAPIContract(t)
+	services := packageServices()
+	require.NotEmpty(t, services)
+
+	t.Run("Every RPC Is Documented", func(t *testing.T) {
+		for _, service := range services {
+			for i := 0; i < service.Methods().Len(); i++ {
+				method := service.Methods().Get(i)
+				procedure := fmt.Sprintf("/%s/%s", service.FullName(), method.Name())
+				requestRef, responseRef, errorRef, err := contract.schemaRefs(procedure)
+				if !assert.NoError(t, err) {
+					continue
+				}
+				assert.Equal(t, "#/components/schemas/"+string(method.Input().FullName()), requestRef, procedure)
+				assert.Equal(t, "#/components/schemas/"+string(method.Output().FullName()), responseRef, procedure)
+				assert.NotEmpty(t, errorRef, "%s documents no error response", procedure)
+			}
+		}
+	})
+
+	t.Run("Schemas Match Messages", func(t *testing.T) {
+		for name, schema := range contract.schemas {
+			if !strings.HasPrefix(name, openAPIPackage+".") {
+				continue
+			}
+			desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+			if !assert.NoError(t, err, "schema %s has no message or enum", name) {
+				continue
+			}
+			object, _ := schema.(map[string]any)
+			switch desc := desc.(type) {
+			case protoreflect.MessageDescriptor:
+				var want, got []string
+				for i := 0; i < desc.Fields().Len(); i++ {
+					want = append(want, desc.Fields().Get(i).JSONName())
+				}
+				properties, _ := object["properties"].(map[string]any)
+				for property := range properties {
+					got = append(got, property)
+				}
+				assert.ElementsMatch(t, want, got, "properties of %s", name)
+			case protoreflect.EnumDescriptor:
+				var want, got []string
+				for i := 0; i < desc.Values().Len(); i++ {
+					want = append(want, string(desc.Values().Get(i).Name()))
+				}
+				values, _ := object["enum"].([]any)
+				for _, v := range values {
+					got = append(got, fmt.Sprint(v))
+				}
+				assert.ElementsMatch(t, want, got, "values of %s", name)
+			}
+		}
+	})
+
+	t.Run("Every Message Matches Its Schema", func(t *testing.T) {
+		for _, service := range services {
+			for i := 0; i < service.Methods().Len(); i++ {
+				method := service.Methods().Get(i)
+				procedure := fmt.Sprintf("/%s/%s", service.FullName(), method.Name())
+				requestRef, responseRef, _, err := contract.schemaRefs(procedure)
+				if err != nil {
+					continue // Reported by Every RPC Is Documented
+				}
+				for ref, desc := range map[string]protoreflect.MessageDescriptor{requestRef: method.Input(), responseRef: method.Output()} {
+					msg := dynamicpb.NewMessage(desc)
+					populateSample(msg, 4)
+					document, err := protojson.Marshal(msg)
+					require.NoError(t, err)
+					assert.NoError(t, contract.validate(ref, document), procedure)
+
+					// Unset fields are left out of the JSON, which the schemas allow too
+					empty, err := protojson.Marshal(dynamicpb.NewMessage(desc))
+					require.NoError(t, err)
+					assert.NoError(t, contract.validate(ref, empty), procedure)
+				}
+			}
+		}
+	})
+
+	t.Run("RPCs Over HTTP JSON", func(t *testing.T) {
+		resetDB(t, testPool)
+		mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+		withTestUser := connect.WithInterceptors(connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				return next(newTestContext(ctx), req)
+			}
+		}))
+		bodyHandler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+		diaryHandler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+		mux := http.NewServeMux()
+		mux.Handle(healthappv1connect.NewBodyRecordServiceHandler(bodyHandler, withTestUser))
+		mux.Handle(healthappv1connect.NewDiaryServiceHandler(diaryHandler, withTestUser))
+		server := httptest.NewServer(contract.middleware(t, mux))
+		defer server.Close()
+
+		ctx := context.Background()
+		bodyRecords := healthappv1connect.NewBodyRecordServiceClient(server.Client(), server.URL, connect.WithProtoJSON())
+		diaryEntries := healthappv1connect.NewDiaryServiceClient(server.Client(), server.URL, connect.WithProtoJSON())
+
+		_, err := bodyRecords.CreateBodyRecord(ctx, connect.NewRequest(&v1.CreateBodyRecordRequest{Date: "2024-01-15", WeightKg: wrapperspb.Double(72.5), Note: "after flu"}))
+		require.NoError(t, err)
+		listed, err := bodyRecords.ListBodyRecords(ctx, connect.NewRequest(&v1.ListBodyRecordsRequest{Pagination: &v1.PageRequest{PageSize: 10, PageNumber: 1}}))
+		require.NoError(t, err)
+		assert.Len(t, listed.Msg.BodyRecords, 1)
+
+		created, err := diaryEntries.CreateDiaryEntry(ctx, connect.NewRequest(&v1.CreateDiaryEntryRequest{Title: wrapperspb.String("Run"), Content: "Ran 5k", EntryDate: "2024-01-15"}))
+		require.NoError(t, err)
+		_, err = diaryEntries.GetDiaryEntry(ctx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: created.Msg.DiaryEntry.Id}))
+		require.NoError(t, err)
+
+		// Errors match the error schema
+		_, err = diaryEntries.GetDiaryEntry(ctx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: uuid.NewString()}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		_, err = bodyRecords.CreateBodyRecord(ctx, connect.NewRequest(&v1.CreateBodyRecordRequest{Date: "15/01/2024"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountCache(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Counts Expire After the TTL", func(t *testing.T) {
+		cacheClock := clock.NewDefaultMockClock()
+		cacheClock.SetTime(now)
+		cache := repo.NewCountCache(10, 10*time.Second, cacheClock)
+		userID := uuid.New()
+		cache.Put(userID, 42, 0)
+
+		cacheClock.SetTime(now.Add(9 * time.Second))
+		count, ok := cache.Get(userID)
+		assert.True(t, ok)
+		assert.EqualValues(t, 42, count)
+
+		cacheClock.SetTime(now.Add(10 * time.Second))
+		_, ok = cache.Get(userID)
+		assert.False(t, ok)
+		assert.Zero(t, cache.Len())
+	})
+
+	t.Run("Least Recently Used Count Is Evicted", func(t *testing.T) {
+		cache := repo.NewCountCache(2, time.Minute, clock.NewDefaultMockClock())
+		a, b, c := uuid.New(), uuid.New(), uuid.New()
+		cache.Put(a, 1, 0)
+		cache.Put(b, 2, 0)
+		_, ok := cache.Get(a)
+		assert.True(t, ok)
+
+		cache.Put(c, 3, 0)
+		assert.Equal(t, 2, cache.Len())
+		_, ok = cache.Get(b)
+		assert.False(t, ok)
+		_, ok = cache.Get(a)
+		assert.True(t, ok)
+	})
+
+	t.Run("Counts Racing an Eviction Aren't Cached", func(t *testing.T) {
+		cache := repo.NewCountCache(10, time.Minute, clock.NewDefaultMockClock())
+		userID := uuid.New()
+		generation := cache.Generation(userID)
+		cache.Invalidate(userID)
+		cache.Put(userID, 1, generation)
+		_, ok := cache.Get(userID)
+		assert.False(t, ok)
+
+		cache.Put(userID, 2, cache.Generation(userID))
+		count, ok := cache.Get(userID)
+		assert.True(t, ok)
+		assert.EqualValues(t, 2, count)
+	})
+
+	t.Run("Nil and Disabled Caches Cache Nothing", func(t *testing.T) {
+		userID := uuid.New()
+		disabled := repo.NewCountCache(10, 0, clock.NewDefaultMockClock())
+		disabled.Put(userID, 1, 0)
+		_, ok := disabled.Get(userID)
+		assert.False(t, ok)
+
+		var none *repo.CountCache
+		none.Put(userID, 1, none.Generation(userID))
+		none.Invalidate(userID)
+		_, ok = none.Get(userID)
+		assert.False(t, ok)
+	})
+
+	t.Run("Repositories", func(t *testing.T) {
+		resetDB(t, testPool)
+		ctx := context.Background()
+		cache := repo.NewCountCache(10, time.Minute, clock.NewDefaultMockClock())
+		cached := repo.NewCachedBodyRecordRepository(testPool, cache)
+		uncached := repo.NewBodyRecordRepository(testPool)
+		weight := 70.0
+		day := now.Truncate(24 * time.Hour)
+
+		count, err := cached.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		// Records saved elsewhere show once the count expires
+		_, err = uncached.Save(ctx, testUserID, day, &weight, nil, "", now)
+		require.NoError(t, err)
+		count, err = cached.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		// Records created through the repository evict the count, updates don't
+		_, err = cached.Save(ctx, testUserID, day.AddDate(0, 0, -1), &weight, nil, "", now)
+		require.NoError(t, err)
+		count, err = cached.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+		_, err = cached.Save(ctx, testUserID, day, &weight, nil, "updated", now)
+		require.NoError(t, err)
+		_, ok := cache.Get(testUserID)
+		assert.True(t, ok)
+
+		require.NoError(t, cached.DeleteByDateRange(ctx, testUserID, day.AddDate(0, 0, -1), day, 2, now))
+		count, err = cached.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		// Restores from the trash and imports evict the count of every cache passed to them
+		counts := repo.CountCaches{cache}
+		trashed, err := repo.NewTrashRepository(testPool).FindByUser(ctx, testUserID, now.AddDate(0, 0, -1), 10, 0)
+		require.NoError(t, err)
+		require.Len(t, trashed, 2)
+		_, err = repo.NewCachedTrashRepository(testPool, counts).Restore(ctx, trashed[0].ID, testUserID, now.AddDate(0, 0, -1), now)
+		require.NoError(t, err)
+		count, err = cached.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+
+		_, err = repo.NewCachedImportRepository(testPool, counts).ImportBatch(ctx, testUserID, "fitbit", repo.ImportBatch{
+			BodyMeasurements: []repo.ImportedBodyMeasurement{{SourceSampleID: "weight-1", Date: day.AddDate(0, 0, -7), WeightKg: &weight}},
+		}, now)
+		require.NoError(t, err)
+		count, err = cached.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+	})
+}
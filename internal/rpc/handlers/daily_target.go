@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/features"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// waterMlPerKg is the daily water target per kg of weight, before activity
+	waterMlPerKg = 35
+	// waterMlPerActivityLevel is added to the daily water target per activity level above sedentary
+	waterMlPerActivityLevel = 250
+	// targetWeightChange is the fraction of the weight the targets were calculated from by which
+	// the weight has to change for them to be recalculated
+	targetWeightChange = 0.02
+	// maxWaterIntakeMl bounds the water of one intake
+	maxWaterIntakeMl = 5000
+)
+
+// activityLevel is an activity level: its proto value, the factor of the BMR burned per day and
+// its rank above sedentary
+type activityLevel struct {
+	proto  v1.ActivityLevel
+	factor float64
+	rank   int
+}
+
+// activityLevels maps the activity levels stored to their properties
+var activityLevels = map[string]activityLevel{
+	"sedentary":   {v1.ActivityLevel_ACTIVITY_LEVEL_SEDENTARY, 1.2, 0},
+	"light":       {v1.ActivityLevel_ACTIVITY_LEVEL_LIGHT, 1.375, 1},
+	"moderate":    {v1.ActivityLevel_ACTIVITY_LEVEL_MODERATE, 1.55, 2},
+	"active":      {v1.ActivityLevel_ACTIVITY_LEVEL_ACTIVE, 1.725, 3},
+	"very_active": {v1.ActivityLevel_ACTIVITY_LEVEL_VERY_ACTIVE, 1.9, 4},
+}
+
+// DailyTargetHandler implements the daily target service RPCs
+type DailyTargetHandler struct {
+	repo  DailyTargetRepository
+	flags *features.Flags
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewDailyTargetHandler creates a new daily target handler
+func NewDailyTargetHandler(repo DailyTargetRepository, flags *features.Flags, log *slog.Logger, clock clock.Clock) *DailyTargetHandler {
+	return &DailyTargetHandler{
+		repo:  repo,
+		flags: flags,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// GetDailyTargets returns the daily targets of the user. They are calculated from the first
+// weight and, with daily target adjustment enabled, recalculated once the weight or activity
+// level changed materially.
+func (h *DailyTargetHandler) GetDailyTargets(ctx context.Context, req *connect.Request[v1.GetDailyTargetsRequest]) (*connect.Response[v1.GetDailyTargetsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	level, err := h.repo.GetActivityLevel(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get activity level", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get daily targets"))
+	}
+	resp := &v1.GetDailyTargetsResponse{
+		ActivityLevel: activityLevels[level].proto,
+	}
+
+	latest, err := h.repo.LatestWeight(ctx, userID)
+	if err != nil && !errors.Is(err, repo.ErrBodyWeightNotFound) {
+		h.log.ErrorContext(ctx, "Failed to get latest weight", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get daily targets"))
+	}
+	weightKg, hasWeight := numericToFloat64(latest.WeightKg)
+
+	targets, err := h.repo.Find(ctx, userID)
+	hasTargets := err == nil
+	if err != nil && !errors.Is(err, repo.ErrDailyTargetsNotFound) {
+		h.log.ErrorContext(ctx, "Failed to get daily targets", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get daily targets"))
+	}
+
+	calculate := hasWeight && !hasTargets
+	if hasWeight && hasTargets && targetsOutdated(targets, weightKg, level) {
+		if h.flags.Enabled(features.DailyTargetAdjustment) {
+			calculate = true
+			resp.Adjusted = true
+		} else {
+			resp.Outdated = true
+		}
+	}
+	if calculate {
+		waterMl, calories := calculateDailyTargets(weightKg, latest, level)
+		targets, err = h.repo.Save(ctx, userID, waterMl, calories, weightKg, level, h.clock.Now())
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to save daily targets", "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get daily targets"))
+		}
+		hasTargets = true
+		h.log.InfoContext(ctx, "Daily targets calculated", "waterMl", waterMl, "calories", calories, "adjusted", resp.Adjusted)
+	}
+	if hasTargets {
+		resp.Targets = toProtoDailyTargets(targets)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// UpdateActivityLevel changes the activity level of the user
+func (h *DailyTargetHandler) UpdateActivityLevel(ctx context.Context, req *connect.Request[v1.UpdateActivityLevelRequest]) (*connect.Response[v1.UpdateActivityLevelResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	level, ok := activityLevelFromProto(req.Msg.ActivityLevel)
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("activity_level is required"))
+	}
+
+	level, err = h.repo.SetActivityLevel(ctx, userID, level)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to set activity level", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update activity level"))
+	}
+	h.log.InfoContext(ctx, "Activity level updated", "activityLevel", level)
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateActivityLevelResponse{
+		ActivityLevel: activityLevels[level].proto,
+	})
+
+	return res, nil
+}
+
+// LogWaterIntake records water drunk by the user
+func (h *DailyTargetHandler) LogWaterIntake(ctx context.Context, req *connect.Request[v1.LogWaterIntakeRequest]) (*connect.Response[v1.LogWaterIntakeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	if req.Msg.AmountMl <= 0 || req.Msg.AmountMl > maxWaterIntakeMl {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("amount_ml must be between 1 and %d", maxWaterIntakeMl))
+	}
+	now := h.clock.Now()
+	takenAt := now
+	if req.Msg.TakenAt != nil {
+		takenAt = req.Msg.TakenAt.AsTime()
+		if takenAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("taken_at cannot be in the future"))
+		}
+	}
+
+	intake, err := h.repo.LogWaterIntake(ctx, userID, req.Msg.AmountMl, takenAt, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to log water intake", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to log water intake"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.LogWaterIntakeResponse{
+		Intake: &v1.WaterIntake{
+			Id:        intake.ID.String(),
+			AmountMl:  intake.AmountMl,
+			TakenAt:   timestamppb.New(intake.TakenAt),
+			CreatedAt: timestamppb.New(intake.CreatedAt),
+		},
+	})
+
+	return res, nil
+}
+
+// targetsOutdated reports whether targets need recalculating for the weight and activity level
+func targetsOutdated(targets db.DailyTarget, weightKg float64, level string) bool {
+	if targets.ActivityLevel != level {
+		return true
+	}
+	basisKg, ok := numericToFloat64(targets.WeightKg)
+	return !ok || math.Abs(weightKg-basisKg) >= targetWeightChange*basisKg
+}
+
+// calculateDailyTargets calculates the daily water target, rounded to 50 ml, and calorie target,
+// rounded to 10 kcal, for a weight and activity level
+func calculateDailyTargets(weightKg float64, latest db.GetLatestBodyWeightRow, level string) (waterMl, calories int32) {
+	activity := activityLevels[level]
+	water := weightKg*waterMlPerKg + float64(activity.rank*waterMlPerActivityLevel)
+	kcal := float64(estimateBMR(weightKg, latest.BodyFatPercentage)) * activity.factor
+	return int32(math.Round(water/50) * 50), int32(math.Round(kcal/10) * 10)
+}
+
+// activityLevelFromProto converts a proto activity level, which must be specified
+func activityLevelFromProto(level v1.ActivityLevel) (string, bool) {
+	for name, l := range activityLevels {
+		if l.proto == level {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// toProtoDailyTargets converts db.DailyTarget to v1.DailyTargets
+func toProtoDailyTargets(t db.DailyTarget) *v1.DailyTargets {
+	weightKg, _ := numericToFloat64(t.WeightKg)
+	return &v1.DailyTargets{
+		WaterMl:       t.WaterMl,
+		Calories:      t.Calories,
+		WeightKg:      weightKg,
+		ActivityLevel: activityLevels[t.ActivityLevel].proto,
+		CalculatedAt:  timestamppb.New(t.CalculatedAt),
+	}
+}
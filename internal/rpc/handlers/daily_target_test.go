@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/features"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDailyTargetHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	flags := features.New(nil)
+	handler := NewDailyTargetHandler(repo.NewDailyTargetRepository(testPool), flags, testLogger, mockClock)
+
+	logWeight := func(t *testing.T, daysAgo int, weightKg float64) {
+		t.Helper()
+		_, err := bodyRecordRepo.Save(ctx, testUserID, fixedTime.Truncate(24*time.Hour).AddDate(0, 0, -daysAgo), &weightKg, nil, "", fixedTime)
+		require.NoError(t, err)
+	}
+	getTargets := func(t *testing.T) *v1.GetDailyTargetsResponse {
+		t.Helper()
+		resp, err := handler.GetDailyTargets(testCtx, connect.NewRequest(&v1.GetDailyTargetsRequest{}))
+		require.NoError(t, err)
+		return resp.Msg
+	}
+
+	t.Run("No Targets Without Weight", func(t *testing.T) {
+		resp := getTargets(t)
+		assert.Nil(t, resp.Targets)
+		assert.Equal(t, v1.ActivityLevel_ACTIVITY_LEVEL_SEDENTARY, resp.ActivityLevel)
+	})
+
+	t.Run("Targets From First Weight", func(t *testing.T) {
+		logWeight(t, 3, 80)
+		resp := getTargets(t)
+		require.NotNil(t, resp.Targets)
+		assert.Equal(t, int32(2800), resp.Targets.WaterMl)
+		// 80 kg * 24 kcal * 1.2, rounded to 10 kcal
+		assert.Equal(t, int32(2300), resp.Targets.Calories)
+		assert.InDelta(t, 80, resp.Targets.WeightKg, 0.001)
+		assert.False(t, resp.Adjusted)
+		assert.False(t, resp.Outdated)
+
+		// A small change of the weight keeps the targets
+		logWeight(t, 2, 81)
+		resp = getTargets(t)
+		assert.Equal(t, int32(2800), resp.Targets.WaterMl)
+		assert.False(t, resp.Outdated)
+	})
+
+	t.Run("Outdated Targets Kept Without Adjustment", func(t *testing.T) {
+		resp, err := handler.UpdateActivityLevel(testCtx, connect.NewRequest(&v1.UpdateActivityLevelRequest{
+			ActivityLevel: v1.ActivityLevel_ACTIVITY_LEVEL_MODERATE,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.ActivityLevel_ACTIVITY_LEVEL_MODERATE, resp.Msg.ActivityLevel)
+
+		targets := getTargets(t)
+		assert.True(t, targets.Outdated)
+		assert.False(t, targets.Adjusted)
+		assert.Equal(t, v1.ActivityLevel_ACTIVITY_LEVEL_MODERATE, targets.ActivityLevel)
+		assert.Equal(t, v1.ActivityLevel_ACTIVITY_LEVEL_SEDENTARY, targets.Targets.ActivityLevel)
+		assert.Equal(t, int32(2300), targets.Targets.Calories)
+	})
+
+	t.Run("Adjusted Targets", func(t *testing.T) {
+		flags.Set(map[string]bool{features.DailyTargetAdjustment: true})
+		mockClock.SetTime(fixedTime.Add(time.Hour))
+
+		resp := getTargets(t)
+		assert.True(t, resp.Adjusted)
+		assert.False(t, resp.Outdated)
+		// 81 kg * 35 ml + 2 levels * 250 ml, rounded to 50 ml
+		assert.Equal(t, int32(3350), resp.Targets.WaterMl)
+		// 81 kg * 24 kcal * 1.55
+		assert.Equal(t, int32(3010), resp.Targets.Calories)
+		assert.Equal(t, v1.ActivityLevel_ACTIVITY_LEVEL_MODERATE, resp.Targets.ActivityLevel)
+		assert.True(t, fixedTime.Add(time.Hour).Equal(resp.Targets.CalculatedAt.AsTime()))
+
+		resp = getTargets(t)
+		assert.False(t, resp.Adjusted)
+
+		// A weight change of 2% or more adjusts them again
+		logWeight(t, 1, 83)
+		resp = getTargets(t)
+		assert.True(t, resp.Adjusted)
+		assert.Equal(t, int32(3400), resp.Targets.WaterMl)
+		assert.Equal(t, int32(3090), resp.Targets.Calories)
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		_, err := handler.UpdateActivityLevel(testCtx, connect.NewRequest(&v1.UpdateActivityLevelRequest{}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.LogWaterIntake(testCtx, connect.NewRequest(&v1.LogWaterIntakeRequest{}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.LogWaterIntake(testCtx, connect.NewRequest(&v1.LogWaterIntakeRequest{AmountMl: 5001}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.LogWaterIntake(testCtx, connect.NewRequest(&v1.LogWaterIntakeRequest{AmountMl: 250, TakenAt: timestamppb.New(fixedTime.Add(time.Minute))}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
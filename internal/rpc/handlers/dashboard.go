@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/reminder"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// dashboardDefaultDays and dashboardMaxDays bound the days covered by a dashboard
+	dashboardDefaultDays = 7
+	dashboardMaxDays     = 90
+	// dashboardDefaultDiaryEntries and dashboardMaxDiaryEntries bound the latest diary entries returned
+	dashboardDefaultDiaryEntries = 3
+	dashboardMaxDiaryEntries     = 20
+	// dashboardColumns is the number of newest columns returned
+	dashboardColumns = 2
+	// dashboardTimeout bounds the queries of a dashboard, which run concurrently
+	dashboardTimeout = 5 * time.Second
+	// calorieBalanceDefaultDays and calorieBalanceMaxDays bound the range of a calorie balance
+	calorieBalanceDefaultDays = 7
+	calorieBalanceMaxDays     = 366
+	// bmrKcalPerKg estimates the BMR from the weight alone, for body records without a body fat
+	// percentage
+	bmrKcalPerKg = 24
+)
+
+// DashboardHandler implements the dashboard RPCs, which aggregate records of several types
+type DashboardHandler struct {
+	users           UserRepository
+	bodyRecords     BodyRecordRepository
+	exerciseRecords ExerciseRecordRepository
+	diaryEntries    DiaryEntryRepository
+	goals           GoalRepository
+	columns         ColumnRepository
+	mealRecords     MealRecordRepository
+	achievements    AchievementRepository
+	supplements     SupplementRepository
+	dailyTargets    DailyTargetRepository
+	log             *slog.Logger
+	clock           clock.Clock
+}
+
+// NewDashboardHandler creates a new dashboard handler
+func NewDashboardHandler(users UserRepository, bodyRecords BodyRecordRepository, exerciseRecords ExerciseRecordRepository, diaryEntries DiaryEntryRepository, goals GoalRepository, columns ColumnRepository, mealRecords MealRecordRepository, achievements AchievementRepository, supplements SupplementRepository, dailyTargets DailyTargetRepository, log *slog.Logger, clock clock.Clock) *DashboardHandler {
+	return &DashboardHandler{
+		users:           users,
+		bodyRecords:     bodyRecords,
+		exerciseRecords: exerciseRecords,
+		diaryEntries:    diaryEntries,
+		goals:           goals,
+		columns:         columns,
+		mealRecords:     mealRecords,
+		achievements:    achievements,
+		supplements:     supplements,
+		dailyTargets:    dailyTargets,
+		log:             log,
+		clock:           clock,
+	}
+}
+
+// GetDashboard returns the user's recent body records, exercise totals, latest diary entries,
+// record counts and today's supplement and water intakes
+func (h *DashboardHandler) GetDashboard(ctx context.Context, req *connect.Request[v1.GetDashboardRequest]) (*connect.Response[v1.GetDashboardResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	days := int(req.Msg.Days)
+	if days == 0 {
+		days = dashboardDefaultDays
+	}
+	if days < 0 || days > dashboardMaxDays {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DashboardDaysOutOfRange, dashboardMaxDays))
+	}
+	diaryEntryCount := int(req.Msg.DiaryEntryCount)
+	if diaryEntryCount == 0 {
+		diaryEntryCount = dashboardDefaultDiaryEntries
+	}
+	if diaryEntryCount < 0 || diaryEntryCount > dashboardMaxDiaryEntries {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DiaryEntryCountOutOfRange, dashboardMaxDiaryEntries))
+	}
+
+	today := h.clock.Now().UTC().Truncate(24 * time.Hour)
+	startDate := today.AddDate(0, 0, -(days - 1))
+
+	// The queries are independent, so they run concurrently under a shared deadline; the first
+	// failure cancels the others
+	h.log.InfoContext(ctx, "Getting dashboard", "userID", userID, "startDate", startDate, "diaryEntryCount", diaryEntryCount)
+	ctx, cancel := context.WithTimeout(ctx, dashboardTimeout)
+	defer cancel()
+	g, gctx := errgroup.WithContext(ctx)
+
+	var (
+		bodyRecords       []db.BodyRecord
+		latestBodyRecords []db.BodyRecord
+		totals            db.GetExerciseTotalsByUserRangeRow
+		todayTotals       db.GetExerciseTotalsByUserRangeRow
+		diaryEntries      []db.DiaryEntry
+		user              db.User
+		streak            int32
+		columns           []db.Column
+		supplements       []db.CountSupplementIntakesByUserRangeRow
+		waterMl           int64
+	)
+	fetch := func(what string, fn func() error) {
+		g.Go(func() error {
+			if err := fn(); err != nil {
+				return fmt.Errorf("failed to fetch %s: %w", what, err)
+			}
+			return nil
+		})
+	}
+	fetch("body records", func() (err error) {
+		bodyRecords, err = h.bodyRecords.FindByUserAndDateRange(gctx, userID, startDate, today)
+		return err
+	})
+	fetch("latest body record", func() (err error) {
+		latestBodyRecords, err = h.bodyRecords.FindByUser(gctx, userID, 1, 0)
+		return err
+	})
+	fetch("exercise totals", func() (err error) {
+		totals, err = h.exerciseRecords.TotalsByUser(gctx, userID, startDate, today.Add(24*time.Hour))
+		return err
+	})
+	fetch("today's exercise totals", func() (err error) {
+		todayTotals, err = h.exerciseRecords.TotalsByUser(gctx, userID, today, today.Add(24*time.Hour))
+		return err
+	})
+	fetch("diary entries", func() (err error) {
+		diaryEntries, err = h.diaryEntries.FindByUser(gctx, userID, diaryEntryCount, 0)
+		return err
+	})
+	// Counts are cached on the user row, so they cost no scan of the record tables
+	fetch("user", func() (err error) {
+		user, err = h.users.FindByID(gctx, userID)
+		return err
+	})
+	// Streaks are maintained as records are written
+	fetch("logging streak", func() error {
+		s, err := h.achievements.Streak(gctx, userID, repo.StreakKindLogging)
+		if err != nil {
+			if errors.Is(err, repo.ErrStreakNotFound) {
+				return nil
+			}
+			return err
+		}
+		streak = currentStreakDays(s, today)
+		return nil
+	})
+	fetch("columns", func() (err error) {
+		columns, err = h.columns.FindPublished(gctx, dashboardColumns, 0, h.clock.Now())
+		return err
+	})
+	fetch("supplement intakes", func() (err error) {
+		supplements, err = h.supplements.IntakeCountsByUser(gctx, userID, today, today.Add(24*time.Hour))
+		return err
+	})
+	fetch("water intake", func() (err error) {
+		waterMl, err = h.dailyTargets.WaterIntakeTotal(gctx, userID, today, today.Add(24*time.Hour))
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		h.log.ErrorContext(ctx, "Failed to get dashboard", "userID", userID, "error", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, connect.NewError(connect.CodeDeadlineExceeded, errors.New("dashboard took too long to load"))
+		}
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get dashboard"))
+	}
+
+	// Create response; weights are shown in the units on the user row
+	unit := units.WeightUnit(user.WeightUnit)
+	protoBodyRecords := make([]*v1.BodyRecord, 0, len(bodyRecords))
+	for _, record := range bodyRecords {
+		protoRecord := ToProtoBodyRecord(record)
+		protoRecord.Weight = toProtoWeight(protoRecord.WeightKg, unit)
+		protoBodyRecords = append(protoBodyRecords, protoRecord)
+	}
+	protoDiaryEntries := make([]*v1.DiaryEntry, 0, len(diaryEntries))
+	for _, entry := range diaryEntries {
+		protoDiaryEntries = append(protoDiaryEntries, ToProtoDiaryEntry(entry))
+	}
+	protoColumns := make([]*v1.Column, 0, len(columns))
+	for _, column := range columns {
+		protoColumns = append(protoColumns, ToProtoColumn(column))
+	}
+
+	resp := &v1.GetDashboardResponse{
+		StartDate:          startDate.Format("2006-01-02"),
+		BodyRecords:        protoBodyRecords,
+		ExerciseTotals:     toProtoExerciseTotals(totals),
+		LatestDiaryEntries: protoDiaryEntries,
+		RecordCounts: &v1.RecordCounts{
+			BodyRecords:     user.BodyRecordCount,
+			ExerciseRecords: user.ExerciseRecordCount,
+			DiaryEntries:    user.DiaryEntryCount,
+			StepRecords:     user.StepRecordCount,
+		},
+		TodayExerciseTotals: toProtoExerciseTotals(todayTotals),
+		StreakDays:          streak,
+		LatestColumns:       protoColumns,
+		TodaySupplements:    h.toProtoSupplementIntakeCounts(ctx, supplements, today),
+		TodayWaterMl:        int32(waterMl),
+	}
+	if user.LastActivityAt.Valid {
+		resp.LastActivityAt = timestamppb.New(user.LastActivityAt.Time)
+	}
+	if len(latestBodyRecords) > 0 {
+		resp.LatestBodyRecord = ToProtoBodyRecord(latestBodyRecords[0])
+		resp.LatestBodyRecord.Weight = toProtoWeight(resp.LatestBodyRecord.WeightKg, unit)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// toProtoSupplementIntakeCounts converts the intake counts of supplements in a day, counting
+// the times their enabled reminders fire in the day
+func (h *DashboardHandler) toProtoSupplementIntakeCounts(ctx context.Context, rows []db.CountSupplementIntakesByUserRangeRow, day time.Time) []*v1.SupplementIntakeCount {
+	counts := make([]*v1.SupplementIntakeCount, 0, len(rows))
+	for _, row := range rows {
+		count := &v1.SupplementIntakeCount{
+			SupplementId: row.ID.String(),
+			Name:         row.Name,
+			Dose:         row.Dose,
+			IntakeCount:  int32(row.IntakeCount),
+		}
+		if row.ReminderEnabled.Bool {
+			scheduled, err := reminder.CountFires(row.ReminderSchedule.String, row.ReminderTimezone.String, day, day.Add(24*time.Hour), 24*60)
+			if err != nil {
+				// Schedules are validated when set, so this only logs
+				h.log.WarnContext(ctx, "Invalid supplement reminder schedule", "supplementID", row.ID, "error", err)
+			}
+			count.ScheduledCount = int32(scheduled)
+		}
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+// GetWeeklySummary returns the aggregates of the user's records of a week and their progress
+// towards their goals
+func (h *DashboardHandler) GetWeeklySummary(ctx context.Context, req *connect.Request[v1.GetWeeklySummaryRequest]) (*connect.Response[v1.GetWeeklySummaryResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	date := h.clock.Now().UTC().Truncate(24 * time.Hour)
+	if req.Msg.Date != "" {
+		date, err = reqparse.ParseDate("date", req.Msg.Date)
+		if err != nil {
+			return nil, reqparse.Error(err)
+		}
+	}
+	// Weeks start on Monday
+	weekStart := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+
+	summary, err := h.goals.WeeklySummary(ctx, userID, weekStart)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get weekly summary", "weekStart", weekStart, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get weekly summary"))
+	}
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get user", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get weekly summary"))
+	}
+	unit := units.WeightUnit(user.WeightUnit)
+
+	// Create response
+	resp := &v1.GetWeeklySummaryResponse{
+		WeekStart: weekStart.Format("2006-01-02"),
+		WeekEnd:   weekStart.AddDate(0, 0, 6).Format("2006-01-02"),
+		Weight:    &v1.WeightChange{WeighInCount: int32(summary.WeighInCount)},
+		ExerciseTotals: &v1.ExerciseTotals{
+			SessionCount:         int32(summary.SessionCount),
+			TotalDurationMinutes: int32(summary.TotalDurationMinutes),
+			TotalCaloriesBurned:  int32(summary.TotalCaloriesBurned),
+		},
+		DiaryEntryCount: int32(summary.DiaryEntryCount),
+	}
+
+	startWeight, hasStart := numericToFloat64(summary.PreviousWeightKg)
+	if !hasStart {
+		// Without an earlier weight, the week starts at its first weigh-in, so a change needs two
+		startWeight, hasStart = numericToFloat64(summary.FirstWeightKg)
+		hasStart = hasStart && summary.WeighInCount > 1
+	}
+	endWeight, hasEnd := numericToFloat64(summary.LastWeightKg)
+	if hasStart {
+		resp.Weight.StartWeightKg = wrapperspb.Double(startWeight)
+	}
+	if hasEnd {
+		resp.Weight.EndWeightKg = wrapperspb.Double(endWeight)
+	}
+	if hasStart && hasEnd {
+		resp.Weight.ChangeKg = wrapperspb.Double(endWeight - startWeight)
+	}
+	resp.Weight.StartWeight = toProtoWeight(resp.Weight.StartWeightKg, unit)
+	resp.Weight.EndWeight = toProtoWeight(resp.Weight.EndWeightKg, unit)
+	resp.Weight.Change = toProtoWeight(resp.Weight.ChangeKg, unit)
+
+	if target, ok := numericToFloat64(summary.GoalTargetWeightKg); ok && hasStart && hasEnd {
+		progress := 0.0
+		switch {
+		case startWeight != target:
+			progress = (startWeight - endWeight) / (startWeight - target)
+		case endWeight == target:
+			progress = 1
+		}
+		resp.GoalProgress = append(resp.GoalProgress, goalProgress(v1.GoalType_GOAL_TYPE_TARGET_WEIGHT, unit.FromKg(target), unit.FromKg(endWeight), progress))
+	}
+	for _, g := range []struct {
+		goal   v1.GoalType
+		target pgtype.Int4
+		actual int64
+	}{
+		{v1.GoalType_GOAL_TYPE_WEEKLY_EXERCISE_MINUTES, summary.GoalWeeklyExerciseMinutes, summary.TotalDurationMinutes},
+		{v1.GoalType_GOAL_TYPE_WEEKLY_CALORIES_BURNED, summary.GoalWeeklyCaloriesBurned, summary.TotalCaloriesBurned},
+		{v1.GoalType_GOAL_TYPE_WEEKLY_DIARY_ENTRIES, summary.GoalWeeklyDiaryEntries, summary.DiaryEntryCount},
+	} {
+		if g.target.Valid {
+			target, actual := float64(g.target.Int32), float64(g.actual)
+			resp.GoalProgress = append(resp.GoalProgress, goalProgress(g.goal, target, actual, actual/target))
+		}
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// GetCalorieBalance returns the calories consumed and burned by the user per day of a range
+func (h *DashboardHandler) GetCalorieBalance(ctx context.Context, req *connect.Request[v1.GetCalorieBalanceRequest]) (*connect.Response[v1.GetCalorieBalanceResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	endDate := h.clock.Now().UTC().Truncate(24 * time.Hour)
+	if req.Msg.EndDate != "" {
+		endDate, err = reqparse.ParseDate("end_date", req.Msg.EndDate)
+		if err != nil {
+			return nil, reqparse.Error(err)
+		}
+	}
+	startDate := endDate.AddDate(0, 0, -(calorieBalanceDefaultDays - 1))
+	if req.Msg.StartDate != "" {
+		startDate, err = reqparse.ParseDate("start_date", req.Msg.StartDate)
+		if err != nil {
+			return nil, reqparse.Error(err)
+		}
+	}
+	if startDate.After(endDate) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StartDateAfterEndDate))
+	}
+	if endDate.Sub(startDate) >= calorieBalanceMaxDays*24*time.Hour {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RangeTooLong, calorieBalanceMaxDays))
+	}
+
+	days, err := h.mealRecords.DailyCalorieBalance(ctx, userID, startDate, endDate)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get calorie balance", "startDate", startDate, "endDate", endDate, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get calorie balance"))
+	}
+
+	// Create response
+	resp := &v1.GetCalorieBalanceResponse{
+		Days: make([]*v1.DailyCalorieBalance, 0, len(days)),
+	}
+	for _, day := range days {
+		balance := &v1.DailyCalorieBalance{
+			Date:                   day.Day.Time.Format("2006-01-02"),
+			CaloriesConsumed:       int32(day.CaloriesConsumed),
+			ExerciseCaloriesBurned: int32(day.ExerciseCaloriesBurned),
+		}
+		if weight, ok := numericToFloat64(day.WeightKg); ok {
+			bmr := estimateBMR(weight, day.BodyFatPercentage)
+			balance.BmrCalories = wrapperspb.Int32(bmr)
+			balance.Balance = wrapperspb.Int32(balance.CaloriesConsumed - balance.ExerciseCaloriesBurned - bmr)
+		}
+		if day.PlannedMealCount > 0 {
+			balance.PlannedCalories = wrapperspb.Int32(int32(day.PlannedCalories))
+			balance.PlannedDifference = wrapperspb.Int32(balance.CaloriesConsumed - int32(day.PlannedCalories))
+		}
+		resp.Days = append(resp.Days, balance)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// estimateBMR estimates the basal metabolic rate in kcal per day. With a body fat percentage,
+// it uses the Katch-McArdle formula on the lean body mass; otherwise bmrKcalPerKg, as height,
+// age and sex are not known.
+func estimateBMR(weightKg float64, bodyFatPercentage pgtype.Numeric) int32 {
+	if bodyFat, ok := numericToFloat64(bodyFatPercentage); ok {
+		leanMassKg := weightKg * (1 - bodyFat/100)
+		return int32(math.Round(370 + 21.6*leanMassKg))
+	}
+	return int32(math.Round(weightKg * bmrKcalPerKg))
+}
+
+// toProtoExerciseTotals converts exercise totals to v1.ExerciseTotals
+func toProtoExerciseTotals(totals db.GetExerciseTotalsByUserRangeRow) *v1.ExerciseTotals {
+	return &v1.ExerciseTotals{
+		SessionCount:         int32(totals.SessionCount),
+		TotalDurationMinutes: int32(totals.TotalDurationMinutes),
+		TotalCaloriesBurned:  int32(totals.TotalCaloriesBurned),
+	}
+}
+
+// goalProgress returns the progress towards a goal; it is achieved once progress reaches 1
+func goalProgress(goal v1.GoalType, target, actual, progress float64) *v1.GoalProgress {
+	return &v1.GoalProgress{
+		Goal:     goal,
+		Target:   target,
+		Actual:   actual,
+		Progress: progress,
+		Achieved: progress >= 1,
+	}
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/features"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGetDashboard(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewDashboardHandler(repo.NewUserRepository(testPool), repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), repo.NewGoalRepository(testPool), repo.NewColumnRepository(testPool), repo.NewMealRecordRepository(testPool), repo.NewAchievementRepository(testPool), repo.NewSupplementRepository(testPool), repo.NewDailyTargetRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	// Setup: records inside and outside the default 7-day window
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	today := fixedTime.Truncate(24 * time.Hour)
+	weight1, weight2 := 75.5, 76.0
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today, &weight1, nil, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, -6), &weight2, nil, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, -7), &weight2, nil, fixedTime)
+	require.NoError(t, err)
+
+	duration1, duration2, calories := int32(30), int32(45), int32(300)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Running", &duration1, &calories, fixedTime.Add(-time.Hour), fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Yoga", &duration2, nil, today.AddDate(0, 0, -6), fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Cycling", &duration2, &calories, today.AddDate(0, 0, -7), fixedTime)
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Entry", today.AddDate(0, 0, -10*i), fixedTime)
+		require.NoError(t, err)
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		resp, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01-09", resp.Msg.StartDate)
+		require.Len(t, resp.Msg.BodyRecords, 2)
+		assert.Equal(t, "2024-01-09", resp.Msg.BodyRecords[0].Date)
+		assert.Equal(t, "2024-01-15", resp.Msg.BodyRecords[1].Date)
+		assert.EqualValues(t, 2, resp.Msg.ExerciseTotals.SessionCount)
+		assert.EqualValues(t, 75, resp.Msg.ExerciseTotals.TotalDurationMinutes)
+		assert.EqualValues(t, 300, resp.Msg.ExerciseTotals.TotalCaloriesBurned)
+		require.Len(t, resp.Msg.LatestDiaryEntries, 3)
+		assert.Equal(t, "2024-01-15", resp.Msg.LatestDiaryEntries[0].EntryDate)
+	})
+
+	t.Run("Custom Window", func(t *testing.T) {
+		resp, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{Days: 1, DiaryEntryCount: 10}))
+		require.NoError(t, err)
+		assert.Len(t, resp.Msg.BodyRecords, 1)
+		assert.EqualValues(t, 1, resp.Msg.ExerciseTotals.SessionCount)
+		assert.Len(t, resp.Msg.LatestDiaryEntries, 4)
+	})
+
+	t.Run("Latest Activity", func(t *testing.T) {
+		for i, title := range []string{"Oldest", "Older", "Newest"} {
+			_, err := testutil.CreateTestColumn(ctx, testPool, mockClock, uuid.New(), title, "Content",
+				pgtype.Text{}, nil, pgtype.Timestamptz{Time: fixedTime.AddDate(0, 0, i-3), Valid: true})
+			require.NoError(t, err)
+		}
+		// Scheduled columns are not published yet
+		_, err := testutil.CreateTestColumn(ctx, testPool, mockClock, uuid.New(), "Scheduled", "Content",
+			pgtype.Text{}, nil, pgtype.Timestamptz{Time: fixedTime.Add(time.Hour), Valid: true})
+		require.NoError(t, err)
+
+		resp, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		require.NotNil(t, resp.Msg.LatestBodyRecord)
+		assert.Equal(t, "2024-01-15", resp.Msg.LatestBodyRecord.Date)
+		assert.EqualValues(t, 1, resp.Msg.TodayExerciseTotals.SessionCount)
+		assert.EqualValues(t, 30, resp.Msg.TodayExerciseTotals.TotalDurationMinutes)
+		// Nothing was logged yesterday, so only today counts
+		assert.EqualValues(t, 1, resp.Msg.StreakDays)
+		require.Len(t, resp.Msg.LatestColumns, 2)
+		assert.Equal(t, "Newest", resp.Msg.LatestColumns[0].Title)
+		assert.Equal(t, "Older", resp.Msg.LatestColumns[1].Title)
+	})
+
+	t.Run("Record Counts", func(t *testing.T) {
+		resp, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, resp.Msg.RecordCounts.BodyRecords)
+		assert.EqualValues(t, 3, resp.Msg.RecordCounts.ExerciseRecords)
+		assert.EqualValues(t, 4, resp.Msg.RecordCounts.DiaryEntries)
+		// Records created by the test helpers bypass the change history
+		assert.Nil(t, resp.Msg.LastActivityAt)
+
+		diaryHandler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+		created, err := diaryHandler.CreateDiaryEntry(testCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+			Content:   "Counted",
+			EntryDate: "2024-01-15",
+		}))
+		require.NoError(t, err)
+		mockClock.SetTime(fixedTime.Add(time.Hour))
+		_, err = diaryHandler.DeleteDiaryEntry(testCtx, connect.NewRequest(&v1.DeleteDiaryEntryRequest{Id: created.Msg.DiaryEntry.Id}))
+		require.NoError(t, err)
+		mockClock.SetTime(fixedTime)
+
+		resp, err = handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, resp.Msg.RecordCounts.DiaryEntries)
+		require.NotNil(t, resp.Msg.LastActivityAt)
+		assert.Equal(t, fixedTime.Add(time.Hour), resp.Msg.LastActivityAt.AsTime())
+	})
+
+	t.Run("Streak", func(t *testing.T) {
+		_, err := testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Yesterday", today.AddDate(0, 0, -1), fixedTime)
+		require.NoError(t, err)
+		_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Two days ago", today.AddDate(0, 0, -2), fixedTime)
+		require.NoError(t, err)
+
+		resp, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, resp.Msg.StreakDays)
+
+		// A streak ending yesterday has not been broken yet
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 1))
+		defer mockClock.SetTime(fixedTime)
+		resp, err = handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, resp.Msg.StreakDays)
+	})
+
+	t.Run("Water Intake", func(t *testing.T) {
+		resp, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.Zero(t, resp.Msg.TodayWaterMl)
+
+		targetHandler := NewDailyTargetHandler(repo.NewDailyTargetRepository(testPool), features.New(nil), testLogger, mockClock)
+		for _, intake := range []struct {
+			amountMl int32
+			takenAt  time.Time
+		}{
+			{250, today.Add(8 * time.Hour)},
+			{500, fixedTime},
+			{300, today.Add(-time.Minute)}, // Yesterday, so not counted
+		} {
+			_, err := targetHandler.LogWaterIntake(testCtx, connect.NewRequest(&v1.LogWaterIntakeRequest{
+				AmountMl: intake.amountMl,
+				TakenAt:  timestamppb.New(intake.takenAt),
+			}))
+			require.NoError(t, err)
+		}
+
+		resp, err = handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 750, resp.Msg.TodayWaterMl)
+	})
+
+	t.Run("Error - Too Many Days", func(t *testing.T) {
+		_, err := handler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{Days: 91}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.GetDashboard(ctx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
+
+func TestGetWeeklySummary(t *testing.T) {
+	resetDB(t, testPool)
+	goalRepo := repo.NewGoalRepository(testPool)
+	handler := NewDashboardHandler(repo.NewUserRepository(testPool), repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), goalRepo, repo.NewColumnRepository(testPool), repo.NewMealRecordRepository(testPool), repo.NewAchievementRepository(testPool), repo.NewSupplementRepository(testPool), repo.NewDailyTargetRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	// Setup: the week of Monday 2024-01-15, with records just before and after it
+	fixedTime := time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	weekStart := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	for _, r := range []struct {
+		day    int
+		weight float64
+	}{{-3, 80}, {1, 79.5}, {5, 79}} {
+		weight := r.weight
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, weekStart.AddDate(0, 0, r.day), &weight, nil, fixedTime)
+		require.NoError(t, err)
+	}
+	duration1, duration2, calories := int32(30), int32(60), int32(300)
+	_, err := testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Running", &duration1, &calories, weekStart.Add(30*time.Hour), fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Cycling", &duration2, &calories, weekStart.AddDate(0, 0, 7), fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "In the week", weekStart.AddDate(0, 0, 2), fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Before the week", weekStart.AddDate(0, 0, -1), fixedTime)
+	require.NoError(t, err)
+
+	t.Run("Without Goals", func(t *testing.T) {
+		resp, err := handler.GetWeeklySummary(testCtx, connect.NewRequest(&v1.GetWeeklySummaryRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01-15", resp.Msg.WeekStart)
+		assert.Equal(t, "2024-01-21", resp.Msg.WeekEnd)
+		assert.Equal(t, 80.0, resp.Msg.Weight.StartWeightKg.GetValue())
+		assert.Equal(t, 79.0, resp.Msg.Weight.EndWeightKg.GetValue())
+		assert.Equal(t, -1.0, resp.Msg.Weight.ChangeKg.GetValue())
+		assert.EqualValues(t, 2, resp.Msg.Weight.WeighInCount)
+		assert.EqualValues(t, 1, resp.Msg.ExerciseTotals.SessionCount)
+		assert.EqualValues(t, 30, resp.Msg.ExerciseTotals.TotalDurationMinutes)
+		assert.EqualValues(t, 300, resp.Msg.ExerciseTotals.TotalCaloriesBurned)
+		assert.EqualValues(t, 1, resp.Msg.DiaryEntryCount)
+		assert.Empty(t, resp.Msg.GoalProgress)
+	})
+
+	t.Run("Goal Progress", func(t *testing.T) {
+		target, minutes, entries := 78.0, int32(150), int32(1)
+		_, err := goalRepo.Set(ctx, testUserID, repo.GoalTargets{
+			TargetWeightKg:        &target,
+			WeeklyExerciseMinutes: &minutes,
+			WeeklyDiaryEntries:    &entries,
+		}, fixedTime)
+		require.NoError(t, err)
+
+		// Any day of the week selects it
+		resp, err := handler.GetWeeklySummary(testCtx, connect.NewRequest(&v1.GetWeeklySummaryRequest{Date: "2024-01-21"}))
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01-15", resp.Msg.WeekStart)
+		require.Len(t, resp.Msg.GoalProgress, 3)
+
+		weight := resp.Msg.GoalProgress[0]
+		assert.Equal(t, v1.GoalType_GOAL_TYPE_TARGET_WEIGHT, weight.Goal)
+		assert.Equal(t, 79.0, weight.Actual)
+		assert.InDelta(t, 0.5, weight.Progress, 1e-9)
+		assert.False(t, weight.Achieved)
+
+		exercise := resp.Msg.GoalProgress[1]
+		assert.Equal(t, v1.GoalType_GOAL_TYPE_WEEKLY_EXERCISE_MINUTES, exercise.Goal)
+		assert.InDelta(t, 0.2, exercise.Progress, 1e-9)
+		assert.False(t, exercise.Achieved)
+
+		diary := resp.Msg.GoalProgress[2]
+		assert.Equal(t, v1.GoalType_GOAL_TYPE_WEEKLY_DIARY_ENTRIES, diary.Goal)
+		assert.True(t, diary.Achieved)
+	})
+
+	t.Run("Empty Week", func(t *testing.T) {
+		resp, err := handler.GetWeeklySummary(testCtx, connect.NewRequest(&v1.GetWeeklySummaryRequest{Date: "2023-06-01"}))
+		require.NoError(t, err)
+		assert.Equal(t, "2023-05-29", resp.Msg.WeekStart)
+		assert.Nil(t, resp.Msg.Weight.ChangeKg)
+		assert.Zero(t, resp.Msg.ExerciseTotals.SessionCount)
+		// Weekly goals are reported even for empty weeks; the weight goal needs weights
+		require.Len(t, resp.Msg.GoalProgress, 2)
+		assert.Zero(t, resp.Msg.GoalProgress[0].Progress)
+	})
+
+	t.Run("Error - Invalid Date", func(t *testing.T) {
+		_, err := handler.GetWeeklySummary(testCtx, connect.NewRequest(&v1.GetWeeklySummaryRequest{Date: "15/01/2024"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
+
+func TestGetCalorieBalance(t *testing.T) {
+	resetDB(t, testPool)
+	mealRepo := repo.NewMealRecordRepository(testPool)
+	handler := NewDashboardHandler(repo.NewUserRepository(testPool), repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), repo.NewGoalRepository(testPool), repo.NewColumnRepository(testPool), mealRepo, repo.NewAchievementRepository(testPool), repo.NewSupplementRepository(testPool), repo.NewDailyTargetRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	// Setup: a weight without body fat on the 14th, with body fat on the 15th
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	today := fixedTime.Truncate(24 * time.Hour)
+	weight, bodyFat := 80.0, 20.0
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, -1), &weight, nil, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today, &weight, &bodyFat, fixedTime)
+	require.NoError(t, err)
+
+	for _, meal := range []struct {
+		calories int32
+		eatenAt  time.Time
+	}{
+		{100, today.Add(-24*time.Hour - time.Second)}, // Last second of the 13th
+		{1200, today.Add(-16 * time.Hour)},
+		{800, today.Add(-5 * time.Hour)},
+		{500, today}, // First second of the 15th
+	} {
+		_, err = mealRepo.Create(ctx, testUserID, "Meal", meal.calories, meal.eatenAt, fixedTime)
+		require.NoError(t, err)
+	}
+	duration, calories := int32(30), int32(300)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Running", &duration, &calories, today.Add(-12*time.Hour), fixedTime)
+	require.NoError(t, err)
+
+	t.Run("Daily Balance", func(t *testing.T) {
+		resp, err := handler.GetCalorieBalance(testCtx, connect.NewRequest(&v1.GetCalorieBalanceRequest{StartDate: "2024-01-13"}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Days, 3)
+
+		// No weight yet, so no BMR or balance
+		assert.Equal(t, "2024-01-13", resp.Msg.Days[0].Date)
+		assert.EqualValues(t, 100, resp.Msg.Days[0].CaloriesConsumed)
+		assert.Nil(t, resp.Msg.Days[0].BmrCalories)
+		assert.Nil(t, resp.Msg.Days[0].Balance)
+
+		// 24 kcal per kg without body fat
+		assert.EqualValues(t, 2000, resp.Msg.Days[1].CaloriesConsumed)
+		assert.EqualValues(t, 300, resp.Msg.Days[1].ExerciseCaloriesBurned)
+		assert.EqualValues(t, 1920, resp.Msg.Days[1].BmrCalories.GetValue())
+		assert.EqualValues(t, -220, resp.Msg.Days[1].Balance.GetValue())
+
+		// Katch-McArdle: 370 + 21.6 x 64 kg lean mass
+		assert.EqualValues(t, 500, resp.Msg.Days[2].CaloriesConsumed)
+		assert.EqualValues(t, 1752, resp.Msg.Days[2].BmrCalories.GetValue())
+		assert.EqualValues(t, -1252, resp.Msg.Days[2].Balance.GetValue())
+	})
+
+	t.Run("Default Range", func(t *testing.T) {
+		resp, err := handler.GetCalorieBalance(testCtx, connect.NewRequest(&v1.GetCalorieBalanceRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Days, 7)
+		assert.Equal(t, "2024-01-09", resp.Msg.Days[0].Date)
+		assert.Equal(t, "2024-01-15", resp.Msg.Days[6].Date)
+	})
+
+	t.Run("Error - Invalid Range", func(t *testing.T) {
+		for name, req := range map[string]*v1.GetCalorieBalanceRequest{
+			"invalid date":   {StartDate: "2024/01/01"},
+			"reversed range": {StartDate: "2024-01-16", EndDate: "2024-01-15"},
+			"too long":       {StartDate: "2023-01-01", EndDate: "2024-01-15"},
+		} {
+			_, err := handler.GetCalorieBalance(testCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// newRegionPool creates a migrated database of a data region next to the test database
+func newRegionPool(t *testing.T, name string) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+	_, err := testPool.Exec(ctx, "CREATE DATABASE "+name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := testPool.Exec(ctx, "DROP DATABASE "+name+" WITH (FORCE)")
+		assert.NoError(t, err)
+	})
+
+	regionURL, err := url.Parse(testPool.Config().ConnString())
+	require.NoError(t, err)
+	regionURL.Path = "/" + name
+	migrationDB, err := sql.Open("postgres", regionURL.String())
+	require.NoError(t, err)
+	defer migrationDB.Close()
+	require.NoError(t, runMigrations(migrationDB))
+
+	pool, err := pgxpool.New(ctx, regionURL.String())
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestDataRegions(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	euPool := newRegionPool(t, "testdb_handlers_eu")
+	database := repo.NewRegionalDB(testPool, map[string]repo.DB{"eu": euPool})
+
+	// Serve the service behind the auth interceptor, as in production
+	jwtConfig := &auth.JWTConfig{SecretKey: "test-secret"}
+	userRepo := repo.NewUserRepository(database)
+	authorizer := authz.NewAuthorizer(repo.NewDataShareRepository(testPool), userRepo, mockClock)
+	handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(database), repo.NewAttachmentRepository(database), repo.NewPreferenceRepository(database), newTestStore(t), authorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewBodyRecordServiceHandler(handler, connect.WithInterceptors(
+		auth.AuthInterceptor(jwtConfig, userRepo, repo.NewSessionRepository(testPool), testLogger),
+	)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := healthappv1connect.NewBodyRecordServiceClient(server.Client(), server.URL)
+
+	// token signs a token of the subject carrying the data region claim, unless region is empty
+	token := func(t *testing.T, subject, region string) string {
+		t.Helper()
+		claims := jwt.MapClaims{"sub": subject, "scope": authz.ScopeRecordsRead + " " + authz.ScopeRecordsWrite}
+		if region != "" {
+			claims["data_region"] = region
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtConfig.SecretKey))
+		require.NoError(t, err)
+		return signed
+	}
+	// createBodyRecord creates a body record of the date with a token of the subject and region
+	createBodyRecord := func(t *testing.T, subject, region, date string) error {
+		t.Helper()
+		req := connect.NewRequest(&v1.CreateBodyRecordRequest{Date: date, WeightKg: wrapperspb.Double(70)})
+		req.Header().Set("Authorization", "Bearer "+token(t, subject, region))
+		_, err := client.CreateBodyRecord(ctx, req)
+		return err
+	}
+	// countBodyRecords counts the body records of the subject in the database of pool
+	countBodyRecords := func(t *testing.T, pool *pgxpool.Pool, subject string) int {
+		t.Helper()
+		var count int
+		require.NoError(t, pool.QueryRow(ctx, "SELECT count(*) FROM body_records JOIN users ON users.id = body_records.user_id WHERE users.subject_id = $1", subject).Scan(&count))
+		return count
+	}
+
+	t.Run("Records Are Kept in the Region", func(t *testing.T) {
+		require.NoError(t, createBodyRecord(t, "region-eu-user", "eu", "2024-01-15"))
+		assert.Equal(t, 1, countBodyRecords(t, euPool, "region-eu-user"))
+		assert.Equal(t, 0, countBodyRecords(t, testPool, "region-eu-user"))
+
+		// The user is kept in the home database, pinned to the region, and copied to its database
+		user, err := repo.NewUserRepository(testPool).FindBySubjectID(ctx, "region-eu-user")
+		require.NoError(t, err)
+		assert.Equal(t, "eu", user.DataRegion.String)
+		copied, err := repo.NewUserRepository(euPool).FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "region-eu-user", copied.SubjectID)
+		assert.EqualValues(t, 1, copied.BodyRecordCount)
+	})
+
+	t.Run("Users Without a Region Stay Home", func(t *testing.T) {
+		require.NoError(t, createBodyRecord(t, "region-home-user", "", "2024-01-15"))
+		assert.Equal(t, 1, countBodyRecords(t, testPool, "region-home-user"))
+		assert.Equal(t, 0, countBodyRecords(t, euPool, "region-home-user"))
+
+		// The region is only chosen when the user is created; records of the same date would be
+		// updated instead of created
+		require.NoError(t, createBodyRecord(t, "region-home-user", "eu", "2024-01-14"))
+		assert.Equal(t, 2, countBodyRecords(t, testPool, "region-home-user"))
+		assert.Equal(t, 0, countBodyRecords(t, euPool, "region-home-user"))
+	})
+
+	t.Run("Shared Records Are Read in the Owner's Region", func(t *testing.T) {
+		homeUsers := repo.NewUserRepository(testPool)
+		owner, err := homeUsers.FindBySubjectID(ctx, "region-eu-user")
+		require.NoError(t, err)
+		grantee, err := homeUsers.FindBySubjectID(ctx, "region-home-user")
+		require.NoError(t, err)
+		now := mockClock.Now()
+		_, err = repo.NewDataShareRepository(testPool).Create(ctx, owner.ID, grantee.ID, []string{authz.RecordTypeBodyRecords}, now.Add(-time.Hour), now.AddDate(0, 0, 7), now)
+		require.NoError(t, err)
+
+		req := connect.NewRequest(&v1.ListBodyRecordsRequest{OwnerId: owner.ID.String()})
+		req.Header().Set("Authorization", "Bearer "+token(t, "region-home-user", ""))
+		res, err := client.ListBodyRecords(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, res.Msg.BodyRecords, 1)
+		assert.Equal(t, "2024-01-15", res.Msg.BodyRecords[0].Date)
+	})
+
+	t.Run("Error - Region Without a Database", func(t *testing.T) {
+		err := createBodyRecord(t, "region-us-user", "us", "2024-01-15")
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonUnknownDataRegion, apierror.Reason(err))
+		_, err = repo.NewUserRepository(testPool).FindBySubjectID(ctx, "region-us-user")
+		assert.ErrorIs(t, err, repo.ErrUserNotFound)
+
+		// Statements of the region fail instead of running on the home database
+		_, err = database.Exec(repo.WithRegion(ctx, "us"), "SELECT 1")
+		assert.ErrorIs(t, err, repo.ErrUnknownRegion)
+	})
+}
@@ -3,36 +3,45 @@ package handlers
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"time"
 
 	"strings"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
 	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
 	"github.com/atreya2011/health-management-api/internal/repo"
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
-	"github.com/google/uuid"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// diaryDuplicateWindow is how long identical entries are considered retries of the same create
+const diaryDuplicateWindow = 5 * time.Minute
+
 // DiaryHandler implements the diary service RPCs
 type DiaryHandler struct {
-	repo  *repo.DiaryEntryRepository // Use concrete repository type
-	log   *slog.Logger
-	clock clock.Clock
+	repo       DiaryEntryRepository
+	authorizer *authz.Authorizer // Authorizes reads of records shared by other users
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
 }
 
 // NewDiaryHandler creates a new diary handler
-func NewDiaryHandler(repo *repo.DiaryEntryRepository, log *slog.Logger, clock clock.Clock) *DiaryHandler {
+func NewDiaryHandler(repo DiaryEntryRepository, authorizer *authz.Authorizer, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *DiaryHandler {
 	return &DiaryHandler{
-		repo:  repo,
-		log:   log,
-		clock: clock,
+		repo:       repo,
+		authorizer: authorizer,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
 	}
 }
 
@@ -46,10 +55,10 @@ func (h *DiaryHandler) CreateDiaryEntry(ctx context.Context, req *connect.Reques
 	}
 
 	// Parse date
-	entryDate, err := time.Parse("2006-01-02", req.Msg.EntryDate)
+	entryDate, err := reqparse.ParseDate("entry_date", req.Msg.EntryDate)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid date format", "entryDate", req.Msg.EntryDate, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid date format: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
 	// Convert protobuf wrapper to Go pointer
@@ -62,27 +71,30 @@ func (h *DiaryHandler) CreateDiaryEntry(ctx context.Context, req *connect.Reques
 	// Re-implement validation logic here
 	content := req.Msg.Content
 	if strings.TrimSpace(content) == "" {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("content cannot be empty"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ContentEmpty))
 	}
 	if len(content) > 10000 {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("content exceeds maximum allowed length (10000 characters)"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ContentTooLong))
 	}
 	if title != nil && len(*title) > 200 {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("title exceeds maximum allowed length (200 characters)"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.TitleTooLong))
 	}
 	if entryDate.After(h.clock.Now()) {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("entry date cannot be in the future"))
+		return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("entry date cannot be in the future"))
 	}
 	// Removed instantiation of repo.DiaryEntry
 
 	// Call repository directly with new signature, passing current time from clock
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Creating diary entry", "userID", userID, "entryDate", entryDate, "now", now)
-	savedEntry, err := h.repo.Create(ctx, userID, title, content, entryDate, now)
+	h.log.InfoContext(ctx, "Creating diary entry", "entryDate", entryDate, "now", now)
+	savedEntry, duplicate, err := h.repo.CreateUnlessDuplicate(ctx, userID, title, content, entryDate, now.Add(-diaryDuplicateWindow), now)
 	if err != nil {
-		h.log.ErrorContext(ctx, "Failed to create diary entry", "userID", userID, "error", err)
+		h.log.ErrorContext(ctx, "Failed to create diary entry", "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create diary entry"))
 	}
+	if duplicate {
+		h.log.InfoContext(ctx, "Duplicate diary entry returned", "entryID", savedEntry.ID)
+	}
 
 	// Convert persistence model to protobuf message
 	protoEntry := ToProtoDiaryEntry(savedEntry) // Use savedEntry (now db.DiaryEntry)
@@ -90,6 +102,7 @@ func (h *DiaryHandler) CreateDiaryEntry(ctx context.Context, req *connect.Reques
 	// Create response
 	res := connect.NewResponse(&v1.CreateDiaryEntryResponse{
 		DiaryEntry: protoEntry,
+		Duplicate:  duplicate,
 	})
 
 	return res, nil
@@ -105,10 +118,10 @@ func (h *DiaryHandler) UpdateDiaryEntry(ctx context.Context, req *connect.Reques
 	}
 
 	// Parse entry ID
-	entryID, err := uuid.Parse(req.Msg.Id)
+	entryID, err := reqparse.ParseUUID("id", req.Msg.Id)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid entry ID", "entryID", req.Msg.Id, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid entry ID: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
 	// Convert protobuf wrapper to Go pointer
@@ -121,27 +134,27 @@ func (h *DiaryHandler) UpdateDiaryEntry(ctx context.Context, req *connect.Reques
 	// Re-implement validation logic for update
 	content := req.Msg.Content
 	if strings.TrimSpace(content) == "" {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("content cannot be empty"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ContentEmpty))
 	}
 	if len(content) > 10000 {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("content exceeds maximum allowed length (10000 characters)"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ContentTooLong))
 	}
 	if title != nil && len(*title) > 200 {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("title exceeds maximum allowed length (200 characters)"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.TitleTooLong))
 	}
 
 	// Call repository directly with new signature
 	// Note: FindByID is implicitly called within the Update query in the repository now,
 	// ensuring the user owns the entry. We don't need to fetch it separately first.
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Updating diary entry", "entryID", entryID, "userID", userID, "now", now)
+	h.log.InfoContext(ctx, "Updating diary entry", "entryID", entryID, "now", now)
 	updatedEntry, err := h.repo.Update(ctx, entryID, userID, title, content, now)
 	if err != nil {
 		if errors.Is(err, repo.ErrDiaryEntryNotFound) { // Check if repo returned not found
-			h.log.WarnContext(ctx, "Diary entry not found during update", "entryID", entryID, "userID", userID)
-			return nil, connect.NewError(connect.CodeNotFound, errors.New("diary entry not found"))
+			h.log.WarnContext(ctx, "Diary entry not found during update", "entryID", entryID)
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("diary entry not found"))
 		}
-		h.log.ErrorContext(ctx, "Failed to update diary entry", "entryID", entryID, "userID", userID, "error", err)
+		h.log.ErrorContext(ctx, "Failed to update diary entry", "entryID", entryID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update diary entry"))
 	}
 
@@ -158,40 +171,21 @@ func (h *DiaryHandler) UpdateDiaryEntry(ctx context.Context, req *connect.Reques
 
 // ListDiaryEntries lists diary entries for the authenticated user
 func (h *DiaryHandler) ListDiaryEntries(ctx context.Context, req *connect.Request[v1.ListDiaryEntriesRequest]) (*connect.Response[v1.ListDiaryEntriesResponse], error) {
-	// Get user ID from context
-	userID, err := auth.GetUserID(ctx)
+	// Get the owner of the records to read: the authenticated user, or a user sharing them
+	ctx, userID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeDiaryEntries)
 	if err != nil {
-		h.log.ErrorContext(ctx, "User ID not found in context")
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+		return nil, err
 	}
 
 	// Get pagination parameters
-	pageSize := 20  // Default page size
-	pageNumber := 1 // Default page number
-
-	if req.Msg.Pagination != nil {
-		if req.Msg.Pagination.PageSize > 0 {
-			pageSize = int(req.Msg.Pagination.PageSize)
-		}
-		if req.Msg.Pagination.PageNumber > 0 {
-			pageNumber = int(req.Msg.Pagination.PageNumber)
-		}
-	}
-	// Ensure page is at least 1 (from service)
-	if pageNumber <= 0 {
-		pageNumber = 1
-	}
-	// Apply max page size (from service)
-	if pageSize > 100 {
-		pageSize = 100
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
 	}
 
-	// Calculate offset (from service)
-	offset := (pageNumber - 1) * pageSize
-
 	// Call repository directly
-	h.log.InfoContext(ctx, "Fetching diary entries for user", "userID", userID, "page", pageNumber, "pageSize", pageSize)
-	entries, err := h.repo.FindByUser(ctx, userID, pageSize, offset) // Changed from diaryApp.ListDiaryEntries
+	h.log.InfoContext(ctx, "Fetching diary entries for user", "userID", userID, "page", page.Number, "pageSize", page.Size)
+	entries, err := h.repo.FindByUser(ctx, userID, page.Size, page.Offset) // Changed from diaryApp.ListDiaryEntries
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch diary entries", "userID", userID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch diary entries"))
@@ -210,20 +204,10 @@ func (h *DiaryHandler) ListDiaryEntries(ctx context.Context, req *connect.Reques
 		protoEntries[i] = ToProtoDiaryEntry(entry) // Pass db.DiaryEntry
 	}
 
-	// Calculate pagination response
-	totalPages := (int(total) + pageSize - 1) / pageSize // Ceiling division
-	if totalPages == 0 {
-		totalPages = 1
-	}
-
 	// Create response
 	res := connect.NewResponse(&v1.ListDiaryEntriesResponse{
 		DiaryEntries: protoEntries,
-		Pagination: &v1.PageResponse{
-			TotalItems:  int32(total),
-			TotalPages:  int32(totalPages),
-			CurrentPage: int32(pageNumber),
-		},
+		Pagination:   page.Response(total),
 	})
 
 	return res, nil
@@ -231,18 +215,17 @@ func (h *DiaryHandler) ListDiaryEntries(ctx context.Context, req *connect.Reques
 
 // GetDiaryEntry retrieves a specific diary entry by ID
 func (h *DiaryHandler) GetDiaryEntry(ctx context.Context, req *connect.Request[v1.GetDiaryEntryRequest]) (*connect.Response[v1.GetDiaryEntryResponse], error) {
-	// Get user ID from context
-	userID, err := auth.GetUserID(ctx)
+	// Get the owner of the records to read: the authenticated user, or a user sharing them
+	ctx, userID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeDiaryEntries)
 	if err != nil {
-		h.log.ErrorContext(ctx, "User ID not found in context")
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+		return nil, err
 	}
 
 	// Parse entry ID
-	entryID, err := uuid.Parse(req.Msg.Id)
+	entryID, err := reqparse.ParseUUID("id", req.Msg.Id)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid entry ID", "entryID", req.Msg.Id, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid entry ID: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
 	// Call repository directly
@@ -251,7 +234,7 @@ func (h *DiaryHandler) GetDiaryEntry(ctx context.Context, req *connect.Request[v
 	if err != nil {
 		if errors.Is(err, repo.ErrDiaryEntryNotFound) { // Use postgres error
 			h.log.WarnContext(ctx, "Diary entry not found", "entryID", entryID, "userID", userID)
-			return nil, connect.NewError(connect.CodeNotFound, errors.New("diary entry not found"))
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("diary entry not found"))
 		}
 		h.log.ErrorContext(ctx, "Failed to fetch diary entry", "entryID", entryID, "userID", userID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch diary entry"))
@@ -278,24 +261,24 @@ func (h *DiaryHandler) DeleteDiaryEntry(ctx context.Context, req *connect.Reques
 	}
 
 	// Parse entry ID
-	entryID, err := uuid.Parse(req.Msg.Id)
+	entryID, err := reqparse.ParseUUID("id", req.Msg.Id)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid entry ID", "entryID", req.Msg.Id, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid entry ID: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
 	// Call repository directly
-	h.log.InfoContext(ctx, "Deleting diary entry", "entryID", entryID, "userID", userID)
-	err = h.repo.Delete(ctx, entryID, userID)
+	h.log.InfoContext(ctx, "Deleting diary entry", "entryID", entryID)
+	err = h.repo.Delete(ctx, entryID, userID, h.clock.Now())
 	if err != nil {
 		// Check if the error is ErrDiaryEntryNotFound from the repository
 		if errors.Is(err, repo.ErrDiaryEntryNotFound) {
-			h.log.WarnContext(ctx, "Diary entry not found or not owned by user during deletion", "entryID", entryID, "userID", userID)
+			h.log.WarnContext(ctx, "Diary entry not found or not owned by user during deletion", "entryID", entryID)
 			// Return NotFound error to the client
-			return nil, connect.NewError(connect.CodeNotFound, errors.New("diary entry not found"))
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("diary entry not found"))
 		}
 		// Handle other potential errors
-		h.log.ErrorContext(ctx, "Failed to delete diary entry", "entryID", entryID, "userID", userID, "error", err)
+		h.log.ErrorContext(ctx, "Failed to delete diary entry", "entryID", entryID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete diary entry"))
 	}
 
@@ -307,6 +290,105 @@ func (h *DiaryHandler) DeleteDiaryEntry(ctx context.Context, req *connect.Reques
 	return res, nil
 }
 
+// ListDiaryEntryRevisions lists the previous versions of a diary entry of the authenticated user
+func (h *DiaryHandler) ListDiaryEntryRevisions(ctx context.Context, req *connect.Request[v1.ListDiaryEntryRevisionsRequest]) (*connect.Response[v1.ListDiaryEntryRevisionsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse entry ID
+	entryID, err := reqparse.ParseUUID("diary_entry_id", req.Msg.DiaryEntryId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid entry ID", "entryID", req.Msg.DiaryEntryId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	// Entries without revisions list none, so check that the entry exists
+	if _, err := h.repo.FindByID(ctx, entryID, userID); err != nil {
+		if errors.Is(err, repo.ErrDiaryEntryNotFound) {
+			h.log.WarnContext(ctx, "Diary entry not found", "entryID", entryID)
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("diary entry not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to fetch diary entry", "entryID", entryID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch diary entry"))
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Fetching diary entry revisions", "entryID", entryID, "page", page.Number, "pageSize", page.Size)
+	revisions, err := h.repo.FindRevisions(ctx, entryID, userID, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch diary entry revisions", "entryID", entryID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch diary entry revisions"))
+	}
+	total, err := h.repo.CountRevisions(ctx, entryID, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count diary entry revisions", "entryID", entryID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count diary entry revisions"))
+	}
+
+	protoRevisions := make([]*v1.DiaryEntryRevision, len(revisions))
+	for i, revision := range revisions {
+		protoRevisions[i] = toProtoDiaryEntryRevision(revision)
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListDiaryEntryRevisionsResponse{
+		Revisions:  protoRevisions,
+		Pagination: page.Response(total),
+	})
+
+	return res, nil
+}
+
+// RestoreDiaryEntryRevision restores a diary entry of the authenticated user from one of its
+// revisions
+func (h *DiaryHandler) RestoreDiaryEntryRevision(ctx context.Context, req *connect.Request[v1.RestoreDiaryEntryRevisionRequest]) (*connect.Response[v1.RestoreDiaryEntryRevisionResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse entry and revision IDs
+	entryID, err := reqparse.ParseUUID("diary_entry_id", req.Msg.DiaryEntryId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid entry ID", "entryID", req.Msg.DiaryEntryId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	revisionID, err := reqparse.ParseUUID("revision_id", req.Msg.RevisionId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid revision ID", "revisionID", req.Msg.RevisionId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Restoring diary entry revision", "entryID", entryID, "revisionID", revisionID)
+	entry, err := h.repo.RestoreRevision(ctx, entryID, revisionID, userID, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrDiaryEntryRevisionNotFound) || errors.Is(err, repo.ErrDiaryEntryNotFound) {
+			h.log.WarnContext(ctx, "Diary entry revision not found", "entryID", entryID, "revisionID", revisionID)
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("diary entry revision not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to restore diary entry revision", "entryID", entryID, "revisionID", revisionID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to restore diary entry revision"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.RestoreDiaryEntryRevisionResponse{
+		DiaryEntry: ToProtoDiaryEntry(entry),
+	})
+
+	return res, nil
+}
+
 // ToProtoDiaryEntry converts a db.DiaryEntry (sqlc generated) to a v1.DiaryEntry
 func ToProtoDiaryEntry(entry db.DiaryEntry) *v1.DiaryEntry { // Accept db.DiaryEntry
 	protoEntry := &v1.DiaryEntry{
@@ -330,3 +412,18 @@ func ToProtoDiaryEntry(entry db.DiaryEntry) *v1.DiaryEntry { // Accept db.DiaryE
 
 	return protoEntry
 }
+
+// toProtoDiaryEntryRevision converts a decrypted db.DiaryEntryRevision to a v1.DiaryEntryRevision
+func toProtoDiaryEntryRevision(revision db.DiaryEntryRevision) *v1.DiaryEntryRevision {
+	protoRevision := &v1.DiaryEntryRevision{
+		Id:           revision.ID.String(),
+		DiaryEntryId: revision.DiaryEntryID.String(),
+		Content:      revision.Content,
+		EditedAt:     timestamppb.New(revision.EditedAt),
+		ReplacedAt:   timestamppb.New(revision.CreatedAt),
+	}
+	if revision.Title.Valid {
+		protoRevision.Title = &wrapperspb.StringValue{Value: revision.Title.String}
+	}
+	return protoRevision
+}
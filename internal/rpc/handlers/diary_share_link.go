@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/atreya2011/health-management-api/internal/sharelink"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// defaultShareLinkDays is how long share links last unless requested otherwise
+	defaultShareLinkDays = 7
+	// maxShareLinkDays is the longest a share link may last
+	maxShareLinkDays = 30
+)
+
+// DiaryShareLinkHandler implements the diary share link service RPCs, and the shared diary
+// service RPCs opening the links without authentication
+type DiaryShareLinkHandler struct {
+	repo   DiaryEntryRepository
+	signer *sharelink.Signer
+	log    *slog.Logger
+	clock  clock.Clock
+}
+
+// NewDiaryShareLinkHandler creates a new diary share link handler
+func NewDiaryShareLinkHandler(repo DiaryEntryRepository, signer *sharelink.Signer, log *slog.Logger, clock clock.Clock) *DiaryShareLinkHandler {
+	return &DiaryShareLinkHandler{
+		repo:   repo,
+		signer: signer,
+		log:    log,
+		clock:  clock,
+	}
+}
+
+// CreateShareLink creates an expiring share link to a diary entry of the authenticated user
+func (h *DiaryShareLinkHandler) CreateShareLink(ctx context.Context, req *connect.Request[v1.CreateShareLinkRequest]) (*connect.Response[v1.CreateShareLinkResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	entryID, err := reqparse.ParseUUID("diary_entry_id", req.Msg.DiaryEntryId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid entry ID", "entryID", req.Msg.DiaryEntryId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	days := req.Msg.ExpiresInDays
+	if days == 0 {
+		days = defaultShareLinkDays
+	}
+	if days < 1 || days > maxShareLinkDays {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ShareLinkExpiryInvalid, maxShareLinkDays))
+	}
+
+	now := h.clock.Now()
+	link, err := h.repo.CreateShareLink(ctx, entryID, userID, now.AddDate(0, 0, int(days)), now)
+	if err != nil {
+		if errors.Is(err, repo.ErrDiaryEntryNotFound) {
+			h.log.WarnContext(ctx, "Diary entry not found", "entryID", entryID)
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("diary entry not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to create diary share link", "entryID", entryID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create share link"))
+	}
+	h.log.InfoContext(ctx, "Diary share link created", "linkID", link.ID, "entryID", entryID, "expiresAt", link.ExpiresAt)
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateShareLinkResponse{
+		ShareLink: h.toProtoDiaryShareLink(ctx, link),
+	})
+
+	return res, nil
+}
+
+// RevokeShareLink revokes a share link of the authenticated user
+func (h *DiaryShareLinkHandler) RevokeShareLink(ctx context.Context, req *connect.Request[v1.RevokeShareLinkRequest]) (*connect.Response[v1.RevokeShareLinkResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse link ID
+	linkID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid share link ID", "linkID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Revoking diary share link", "linkID", linkID)
+	if err := h.repo.RevokeShareLink(ctx, linkID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrDiaryShareLinkNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("share link not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to revoke diary share link", "linkID", linkID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to revoke share link"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.RevokeShareLinkResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// GetSharedDiaryEntry returns the diary entry of a share link. Invalid, expired, and revoked
+// links are all not found, so callers can't tell them apart.
+func (h *DiaryShareLinkHandler) GetSharedDiaryEntry(ctx context.Context, req *connect.Request[v1.GetSharedDiaryEntryRequest]) (*connect.Response[v1.GetSharedDiaryEntryResponse], error) {
+	now := h.clock.Now()
+	notFound := apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("share link not found"))
+
+	linkID, region, err := h.signer.Verify(req.Msg.Token, now)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid diary share link token", "error", err)
+		return nil, notFound
+	}
+
+	// The link is opened without a token of its owner, so it names the data region of their entry
+	entry, expiresAt, err := h.repo.FindShared(repo.WithRegion(ctx, region), linkID, now)
+	if err != nil {
+		if errors.Is(err, repo.ErrDiaryShareLinkNotFound) {
+			h.log.WarnContext(ctx, "Diary share link revoked or expired", "linkID", linkID)
+			return nil, notFound
+		}
+		if errors.Is(err, repo.ErrUnknownRegion) {
+			h.log.WarnContext(ctx, "Diary share link of a data region without a database", "linkID", linkID, "region", region)
+			return nil, notFound
+		}
+		h.log.ErrorContext(ctx, "Failed to fetch shared diary entry", "linkID", linkID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch shared diary entry"))
+	}
+	h.log.InfoContext(ctx, "Shared diary entry opened", "linkID", linkID, "entryID", entry.ID)
+
+	// Create response
+	res := connect.NewResponse(&v1.GetSharedDiaryEntryResponse{
+		DiaryEntry: toProtoSharedDiaryEntry(entry, expiresAt),
+	})
+
+	return res, nil
+}
+
+// toProtoDiaryShareLink converts a db.DiaryShareLink created in the data region of ctx to a
+// v1.DiaryShareLink with its token
+func (h *DiaryShareLinkHandler) toProtoDiaryShareLink(ctx context.Context, link db.DiaryShareLink) *v1.DiaryShareLink {
+	return &v1.DiaryShareLink{
+		Id:           link.ID.String(),
+		DiaryEntryId: link.DiaryEntryID.String(),
+		Token:        h.signer.Sign(link.ID, link.ExpiresAt, repo.RegionOf(ctx)),
+		ExpiresAt:    timestamppb.New(link.ExpiresAt),
+		CreatedAt:    timestamppb.New(link.CreatedAt),
+	}
+}
+
+// toProtoSharedDiaryEntry converts a decrypted db.DiaryEntry opened with a link expiring at
+// expiresAt to a v1.SharedDiaryEntry
+func toProtoSharedDiaryEntry(entry db.DiaryEntry, expiresAt time.Time) *v1.SharedDiaryEntry {
+	protoEntry := &v1.SharedDiaryEntry{
+		Content:   entry.Content,
+		UpdatedAt: timestamppb.New(entry.UpdatedAt),
+		ExpiresAt: timestamppb.New(expiresAt),
+	}
+	if entry.EntryDate.Valid {
+		protoEntry.EntryDate = entry.EntryDate.Time.Format("2006-01-02")
+	}
+	if entry.Title.Valid {
+		protoEntry.Title = &wrapperspb.StringValue{Value: entry.Title.String}
+	}
+	return protoEntry
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/sharelink"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDiaryShareLinks(t *testing.T) {
+	resetDB(t, testPool)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+	diaryHandler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	handler := NewDiaryShareLinkHandler(diaryRepo, sharelink.NewSigner([]byte("test-share-link-key")), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	entry, err := diaryHandler.CreateDiaryEntry(testCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+		Title:     wrapperspb.String("Headaches"),
+		Content:   "Headaches every afternoon this week",
+		EntryDate: "2024-01-14",
+	}))
+	require.NoError(t, err)
+	entryID := entry.Msg.DiaryEntry.Id
+
+	// createLink creates a link to the entry expiring in days
+	createLink := func(t *testing.T, days int32) *v1.DiaryShareLink {
+		t.Helper()
+		resp, err := handler.CreateShareLink(testCtx, connect.NewRequest(&v1.CreateShareLinkRequest{DiaryEntryId: entryID, ExpiresInDays: days}))
+		require.NoError(t, err)
+		return resp.Msg.ShareLink
+	}
+	// open gets the entry of a token without authentication
+	open := func(token string) (*v1.SharedDiaryEntry, error) {
+		resp, err := handler.GetSharedDiaryEntry(ctx, connect.NewRequest(&v1.GetSharedDiaryEntryRequest{Token: token}))
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg.DiaryEntry, nil
+	}
+
+	t.Run("Opened Without Authentication", func(t *testing.T) {
+		link := createLink(t, 0)
+		assert.Equal(t, entryID, link.DiaryEntryId)
+		assert.Equal(t, fixedTime.AddDate(0, 0, 7), link.ExpiresAt.AsTime())
+		assert.NotEmpty(t, link.Token)
+
+		shared, err := open(link.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "Headaches", shared.Title.Value)
+		assert.Equal(t, "Headaches every afternoon this week", shared.Content)
+		assert.Equal(t, "2024-01-14", shared.EntryDate)
+		assert.Equal(t, link.ExpiresAt.AsTime(), shared.ExpiresAt.AsTime())
+	})
+
+	t.Run("Expired Links Stop Working", func(t *testing.T) {
+		link := createLink(t, 1)
+		mockClock.SetTime(fixedTime.Add(24*time.Hour - time.Second))
+		_, err := open(link.Token)
+		require.NoError(t, err)
+
+		mockClock.SetTime(fixedTime.Add(24 * time.Hour))
+		_, err = open(link.Token)
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		mockClock.SetTime(fixedTime)
+	})
+
+	t.Run("Revoked Links Stop Working", func(t *testing.T) {
+		link := createLink(t, 7)
+		_, err := handler.RevokeShareLink(testCtx, connect.NewRequest(&v1.RevokeShareLinkRequest{Id: link.Id}))
+		require.NoError(t, err)
+
+		_, err = open(link.Token)
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		// Revoking twice is not found
+		_, err = handler.RevokeShareLink(testCtx, connect.NewRequest(&v1.RevokeShareLinkRequest{Id: link.Id}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Forged Tokens Are Rejected", func(t *testing.T) {
+		link := createLink(t, 7)
+		otherSigner := sharelink.NewSigner([]byte("another-key"))
+		for name, token := range map[string]string{
+			"Other Key":     otherSigner.Sign(uuid.MustParse(link.Id), link.ExpiresAt.AsTime(), ""),
+			"Longer Expiry": otherSigner.Sign(uuid.MustParse(link.Id), link.ExpiresAt.AsTime().AddDate(1, 0, 0), ""),
+			"Truncated":     link.Token[:len(link.Token)-2],
+			"Not Base64":    "not a token!",
+			"Empty":         "",
+		} {
+			_, err := open(token)
+			require.Error(t, err, name)
+			assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), name)
+		}
+	})
+
+	t.Run("Deleted Entries Stop Working", func(t *testing.T) {
+		other, err := diaryHandler.CreateDiaryEntry(testCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{Content: "To delete", EntryDate: "2024-01-13"}))
+		require.NoError(t, err)
+		resp, err := handler.CreateShareLink(testCtx, connect.NewRequest(&v1.CreateShareLinkRequest{DiaryEntryId: other.Msg.DiaryEntry.Id}))
+		require.NoError(t, err)
+		_, err = diaryHandler.DeleteDiaryEntry(testCtx, connect.NewRequest(&v1.DeleteDiaryEntryRequest{Id: other.Msg.DiaryEntry.Id}))
+		require.NoError(t, err)
+
+		_, err = open(resp.Msg.ShareLink.Token)
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Only Owners Share And Revoke", func(t *testing.T) {
+		link := createLink(t, 7)
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+
+		_, err = handler.CreateShareLink(otherCtx, connect.NewRequest(&v1.CreateShareLinkRequest{DiaryEntryId: entryID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.RevokeShareLink(otherCtx, connect.NewRequest(&v1.RevokeShareLinkRequest{Id: link.Id}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = open(link.Token)
+		require.NoError(t, err)
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateShareLinkRequest{
+			"Invalid Entry ID": {DiaryEntryId: "not-a-uuid"},
+			"Negative Expiry":  {DiaryEntryId: entryID, ExpiresInDays: -1},
+			"Expiry Too Long":  {DiaryEntryId: entryID, ExpiresInDays: 31},
+		} {
+			_, err := handler.CreateShareLink(testCtx, connect.NewRequest(req))
+			require.Error(t, err, name)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+	})
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
 	"github.com/atreya2011/health-management-api/internal/repo"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"github.com/atreya2011/health-management-api/internal/testutil"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -103,8 +107,8 @@ func TestCreateDiaryEntry(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
-			diaryRepo := repo.NewDiaryEntryRepository(testPool)
-			handler := NewDiaryHandler(diaryRepo, testLogger, mockClock) // Pass mockClock
+			diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+			handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -135,6 +139,95 @@ func TestCreateDiaryEntry(t *testing.T) {
 	}
 }
 
+func TestCreateDiaryEntryDuplicates(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	create := func(t *testing.T, ctx context.Context, req *v1.CreateDiaryEntryRequest) *v1.CreateDiaryEntryResponse {
+		t.Helper()
+		resp, err := handler.CreateDiaryEntry(ctx, connect.NewRequest(req))
+		require.NoError(t, err)
+		return resp.Msg
+	}
+	original := &v1.CreateDiaryEntryRequest{Title: wrapperspb.String("Run"), Content: "Ran 5k", EntryDate: "2024-01-15"}
+	first := create(t, testCtx, original)
+	assert.False(t, first.Duplicate)
+
+	t.Run("Retries Return The Existing Entry", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.Add(diaryDuplicateWindow - time.Second))
+		defer mockClock.SetTime(fixedTime)
+		retry := create(t, testCtx, original)
+		assert.True(t, retry.Duplicate)
+		assert.Equal(t, first.DiaryEntry.Id, retry.DiaryEntry.Id)
+		assert.Equal(t, "Ran 5k", retry.DiaryEntry.Content)
+	})
+
+	t.Run("Different Entries Are Created", func(t *testing.T) {
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+		for name, tc := range map[string]struct {
+			ctx context.Context
+			req *v1.CreateDiaryEntryRequest
+		}{
+			"Other Title":   {testCtx, &v1.CreateDiaryEntryRequest{Title: wrapperspb.String("Jog"), Content: "Ran 5k", EntryDate: "2024-01-15"}},
+			"No Title":      {testCtx, &v1.CreateDiaryEntryRequest{Content: "Ran 5k", EntryDate: "2024-01-15"}},
+			"Shifted Title": {testCtx, &v1.CreateDiaryEntryRequest{Title: wrapperspb.String("Run Ran"), Content: " 5k", EntryDate: "2024-01-15"}},
+			"Other Date":    {testCtx, &v1.CreateDiaryEntryRequest{Title: wrapperspb.String("Run"), Content: "Ran 5k", EntryDate: "2024-01-14"}},
+			"Other User":    {otherCtx, original},
+		} {
+			resp := create(t, tc.ctx, tc.req)
+			assert.False(t, resp.Duplicate, name)
+			assert.NotEqual(t, first.DiaryEntry.Id, resp.DiaryEntry.Id, name)
+		}
+	})
+
+	t.Run("Entries Outside The Window Are Created", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.Add(diaryDuplicateWindow))
+		defer mockClock.SetTime(fixedTime)
+		resp := create(t, testCtx, original)
+		assert.False(t, resp.Duplicate)
+		assert.NotEqual(t, first.DiaryEntry.Id, resp.DiaryEntry.Id)
+	})
+
+	t.Run("Updated Entries Are Not Duplicates", func(t *testing.T) {
+		req := &v1.CreateDiaryEntryRequest{Content: "Slept badly", EntryDate: "2024-01-13"}
+		created := create(t, testCtx, req)
+		_, err := handler.UpdateDiaryEntry(testCtx, connect.NewRequest(&v1.UpdateDiaryEntryRequest{Id: created.DiaryEntry.Id, Content: "Slept well"}))
+		require.NoError(t, err)
+		_, err = handler.UpdateDiaryEntry(testCtx, connect.NewRequest(&v1.UpdateDiaryEntryRequest{Id: created.DiaryEntry.Id, Content: "Slept badly"}))
+		require.NoError(t, err)
+		resp := create(t, testCtx, req)
+		assert.False(t, resp.Duplicate)
+		assert.NotEqual(t, created.DiaryEntry.Id, resp.DiaryEntry.Id)
+	})
+
+	t.Run("Concurrent Retries Create One Entry", func(t *testing.T) {
+		req := &v1.CreateDiaryEntryRequest{Content: "Sent on a flaky connection", EntryDate: "2024-01-12"}
+		ids := make([]string, 5)
+		var wg sync.WaitGroup
+		for i := range ids {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := handler.CreateDiaryEntry(testCtx, connect.NewRequest(req))
+				if assert.NoError(t, err) {
+					ids[i] = resp.Msg.DiaryEntry.Id
+				}
+			}()
+		}
+		wg.Wait()
+		for _, id := range ids {
+			assert.Equal(t, ids[0], id)
+		}
+	})
+}
+
 func TestUpdateDiaryEntry(t *testing.T) {
 	// Set a fixed time for the test
 	fixedTime := time.Date(2024, 1, 15, 11, 10, 0, 0, time.UTC)
@@ -265,8 +358,8 @@ func TestUpdateDiaryEntry(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
-			diaryRepo := repo.NewDiaryEntryRepository(testPool)
-			handler := NewDiaryHandler(diaryRepo, testLogger, mockClock) // Pass mockClock
+			diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+			handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -378,8 +471,8 @@ func TestGetDiaryEntry(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
-			diaryRepo := repo.NewDiaryEntryRepository(testPool)
-			handler := NewDiaryHandler(diaryRepo, testLogger, mockClock) // Pass mockClock
+			diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+			handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -419,8 +512,8 @@ func TestGetDiaryEntry(t *testing.T) {
 
 func TestListDiaryEntries(t *testing.T) {
 	resetDB(t, testPool)
-	diaryRepo := repo.NewDiaryEntryRepository(testPool)
-	handler := NewDiaryHandler(diaryRepo, testLogger, mockClock) // Pass mockClock
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+	handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	testCtx := newTestContext(ctx)
 
@@ -470,9 +563,11 @@ func TestListDiaryEntries(t *testing.T) {
 			expectedResp: &v1.ListDiaryEntriesResponse{
 				DiaryEntries: []*v1.DiaryEntry{protoToday},
 				Pagination: &v1.PageResponse{
-					TotalItems:  2,
-					TotalPages:  2,
-					CurrentPage: 1,
+					TotalItems:    2,
+					TotalPages:    2,
+					CurrentPage:   1,
+					HasNext:       true,
+					NextPageToken: reqparse.EncodePageToken(1),
 				},
 			},
 		},
@@ -488,6 +583,7 @@ func TestListDiaryEntries(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 2,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -503,6 +599,7 @@ func TestListDiaryEntries(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 3,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -600,8 +697,8 @@ func TestDeleteDiaryEntry(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
-			diaryRepo := repo.NewDiaryEntryRepository(testPool)
-			handler := NewDiaryHandler(diaryRepo, testLogger, mockClock) // Pass mockClock
+			diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+			handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -636,3 +733,217 @@ func TestDeleteDiaryEntry(t *testing.T) {
 		})
 	}
 }
+
+func TestDiaryEncryption(t *testing.T) {
+	resetDB(t, testPool)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+	handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	// stored reads an entry as stored in the database
+	stored := func(t *testing.T, id string) (title *string, content string, encrypted bool) {
+		t.Helper()
+		err := testPool.QueryRow(ctx, "SELECT title, content, encrypted FROM diary_entries WHERE id = $1", id).Scan(&title, &content, &encrypted)
+		require.NoError(t, err)
+		return title, content, encrypted
+	}
+
+	t.Run("Entries Are Stored Encrypted", func(t *testing.T) {
+		resp, err := handler.CreateDiaryEntry(testCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+			Title:     wrapperspb.String("Private"),
+			Content:   "Feeling unwell",
+			EntryDate: "2024-01-15",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "Private", resp.Msg.DiaryEntry.Title.GetValue())
+		assert.Equal(t, "Feeling unwell", resp.Msg.DiaryEntry.Content)
+
+		title, content, encrypted := stored(t, resp.Msg.DiaryEntry.Id)
+		assert.True(t, encrypted)
+		require.NotNil(t, title)
+		assert.NotContains(t, *title, "Private")
+		assert.NotContains(t, content, "unwell")
+
+		updateResp, err := handler.UpdateDiaryEntry(testCtx, connect.NewRequest(&v1.UpdateDiaryEntryRequest{
+			Id:      resp.Msg.DiaryEntry.Id,
+			Content: "Better today",
+		}))
+		require.NoError(t, err)
+		assert.Nil(t, updateResp.Msg.DiaryEntry.Title)
+		title, content, encrypted = stored(t, resp.Msg.DiaryEntry.Id)
+		assert.True(t, encrypted)
+		assert.Nil(t, title)
+		assert.NotContains(t, content, "Better")
+
+		getResp, err := handler.GetDiaryEntry(testCtx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: resp.Msg.DiaryEntry.Id}))
+		require.NoError(t, err)
+		assert.Equal(t, "Better today", getResp.Msg.DiaryEntry.Content)
+	})
+
+	t.Run("Plaintext Entries", func(t *testing.T) {
+		// Entries written before encryption was enabled are readable, then encrypted in batches
+		var ids []string
+		for _, content := range []string{"Old entry", "Older entry", "Oldest entry"} {
+			entry, err := testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "Old", content, fixedTime.Truncate(24*time.Hour), fixedTime.Add(-time.Hour))
+			require.NoError(t, err)
+			ids = append(ids, entry.ID.String())
+		}
+		getResp, err := handler.GetDiaryEntry(testCtx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: ids[0]}))
+		require.NoError(t, err)
+		assert.Equal(t, "Old entry", getResp.Msg.DiaryEntry.Content)
+
+		n, err := diaryRepo.EncryptPlaintext(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		n, err = diaryRepo.EncryptPlaintext(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		n, err = diaryRepo.EncryptPlaintext(ctx, 2)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		for _, id := range ids {
+			_, content, encrypted := stored(t, id)
+			assert.True(t, encrypted)
+			assert.NotContains(t, content, "entry")
+		}
+		getResp, err = handler.GetDiaryEntry(testCtx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: ids[0]}))
+		require.NoError(t, err)
+		assert.Equal(t, "Old", getResp.Msg.DiaryEntry.Title.GetValue())
+		assert.Equal(t, "Old entry", getResp.Msg.DiaryEntry.Content)
+		// Encrypting doesn't change entries
+		assert.Equal(t, fixedTime.Add(-time.Hour), getResp.Msg.DiaryEntry.UpdatedAt.AsTime())
+	})
+
+	t.Run("Error - Encrypted Without Cipher", func(t *testing.T) {
+		listResp, err := handler.ListDiaryEntries(testCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{}))
+		require.NoError(t, err)
+		require.NotEmpty(t, listResp.Msg.DiaryEntries)
+
+		plaintextHandler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, nil), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+		_, err = plaintextHandler.GetDiaryEntry(testCtx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: listResp.Msg.DiaryEntries[0].Id}))
+		assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+	})
+}
+
+func TestDiaryEntryRevisions(t *testing.T) {
+	resetDB(t, testPool)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+	handler := NewDiaryHandler(diaryRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	createResp, err := handler.CreateDiaryEntry(testCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+		Title:     wrapperspb.String("Long entry"),
+		Content:   "First version",
+		EntryDate: "2024-01-15",
+	}))
+	require.NoError(t, err)
+	entryID := createResp.Msg.DiaryEntry.Id
+
+	for i, content := range []string{"Second version", "Overwritten by accident"} {
+		mockClock.SetTime(fixedTime.Add(time.Duration(i+1) * time.Hour))
+		_, err := handler.UpdateDiaryEntry(testCtx, connect.NewRequest(&v1.UpdateDiaryEntryRequest{
+			Id:      entryID,
+			Title:   wrapperspb.String("Long entry"),
+			Content: content,
+		}))
+		require.NoError(t, err)
+	}
+
+	t.Run("Updates Keep the Previous Versions", func(t *testing.T) {
+		resp, err := handler.ListDiaryEntryRevisions(testCtx, connect.NewRequest(&v1.ListDiaryEntryRevisionsRequest{DiaryEntryId: entryID}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Revisions, 2)
+		assert.EqualValues(t, 2, resp.Msg.Pagination.TotalItems)
+		assert.Equal(t, "Second version", resp.Msg.Revisions[0].Content)
+		assert.Equal(t, fixedTime.Add(time.Hour), resp.Msg.Revisions[0].EditedAt.AsTime())
+		assert.Equal(t, fixedTime.Add(2*time.Hour), resp.Msg.Revisions[0].ReplacedAt.AsTime())
+		assert.Equal(t, "First version", resp.Msg.Revisions[1].Content)
+		assert.Equal(t, "Long entry", resp.Msg.Revisions[1].Title.GetValue())
+		assert.Equal(t, fixedTime, resp.Msg.Revisions[1].EditedAt.AsTime())
+
+		// Revisions of encrypted entries are encrypted too
+		var plaintextCount int
+		err = testPool.QueryRow(ctx, "SELECT COUNT(*) FROM diary_entry_revisions WHERE NOT encrypted OR content LIKE '%version%'").Scan(&plaintextCount)
+		require.NoError(t, err)
+		assert.Zero(t, plaintextCount)
+	})
+
+	t.Run("Restore a Revision", func(t *testing.T) {
+		listResp, err := handler.ListDiaryEntryRevisions(testCtx, connect.NewRequest(&v1.ListDiaryEntryRevisionsRequest{DiaryEntryId: entryID}))
+		require.NoError(t, err)
+		firstVersion := listResp.Msg.Revisions[1]
+
+		mockClock.SetTime(fixedTime.Add(3 * time.Hour))
+		resp, err := handler.RestoreDiaryEntryRevision(testCtx, connect.NewRequest(&v1.RestoreDiaryEntryRevisionRequest{
+			DiaryEntryId: entryID,
+			RevisionId:   firstVersion.Id,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "First version", resp.Msg.DiaryEntry.Content)
+		assert.Equal(t, fixedTime.Add(3*time.Hour), resp.Msg.DiaryEntry.UpdatedAt.AsTime())
+
+		getResp, err := handler.GetDiaryEntry(testCtx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: entryID}))
+		require.NoError(t, err)
+		assert.Equal(t, "First version", getResp.Msg.DiaryEntry.Content)
+
+		// The restore can be undone
+		listResp, err = handler.ListDiaryEntryRevisions(testCtx, connect.NewRequest(&v1.ListDiaryEntryRevisionsRequest{DiaryEntryId: entryID}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Revisions, 3)
+		assert.Equal(t, "Overwritten by accident", listResp.Msg.Revisions[0].Content)
+	})
+
+	t.Run("Only the Latest Revisions Are Kept", func(t *testing.T) {
+		for i := 0; i < repo.MaxDiaryEntryRevisions; i++ {
+			mockClock.SetTime(fixedTime.Add(time.Duration(4+i) * time.Hour))
+			_, err := handler.UpdateDiaryEntry(testCtx, connect.NewRequest(&v1.UpdateDiaryEntryRequest{
+				Id:      entryID,
+				Content: fmt.Sprintf("Edit %d", i),
+			}))
+			require.NoError(t, err)
+		}
+
+		resp, err := handler.ListDiaryEntryRevisions(testCtx, connect.NewRequest(&v1.ListDiaryEntryRevisionsRequest{
+			DiaryEntryId: entryID,
+			Pagination:   &v1.PageRequest{PageSize: 100},
+		}))
+		require.NoError(t, err)
+		assert.EqualValues(t, repo.MaxDiaryEntryRevisions, resp.Msg.Pagination.TotalItems)
+		assert.Equal(t, fmt.Sprintf("Edit %d", repo.MaxDiaryEntryRevisions-2), resp.Msg.Revisions[0].Content)
+		assert.Equal(t, "First version", resp.Msg.Revisions[len(resp.Msg.Revisions)-1].Content)
+	})
+
+	t.Run("Error - Not Found", func(t *testing.T) {
+		otherResp, err := handler.CreateDiaryEntry(testCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+			Content:   "Another entry",
+			EntryDate: "2024-01-14",
+		}))
+		require.NoError(t, err)
+		listResp, err := handler.ListDiaryEntryRevisions(testCtx, connect.NewRequest(&v1.ListDiaryEntryRevisionsRequest{DiaryEntryId: entryID}))
+		require.NoError(t, err)
+
+		// Revisions can only be restored to their own entry
+		_, err = handler.RestoreDiaryEntryRevision(testCtx, connect.NewRequest(&v1.RestoreDiaryEntryRevisionRequest{
+			DiaryEntryId: otherResp.Msg.DiaryEntry.Id,
+			RevisionId:   listResp.Msg.Revisions[0].Id,
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.ListDiaryEntryRevisions(testCtx, connect.NewRequest(&v1.ListDiaryEntryRevisionsRequest{DiaryEntryId: uuid.NewString()}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.RestoreDiaryEntryRevision(testCtx, connect.NewRequest(&v1.RestoreDiaryEntryRevisionRequest{
+			DiaryEntryId: entryID,
+			RevisionId:   "not-a-uuid",
+		}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/errreport"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingServerHandler fails GetServerInfo as its fail function does
+type failingServerHandler struct {
+	healthappv1connect.UnimplementedServerServiceHandler
+	fail func(ctx context.Context) error
+}
+
+func (h *failingServerHandler) GetServerInfo(ctx context.Context, req *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error) {
+	return nil, h.fail(ctx)
+}
+
+// sentReport is an error report received by the fake error reporting service
+type sentReport struct {
+	path   string
+	auth   string
+	header map[string]any
+	event  map[string]any
+}
+
+func TestErrorReporter(t *testing.T) {
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	// The fake service decodes the envelopes it receives
+	reports := make(chan sentReport, 10)
+	sentry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		lines := bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+		require.Len(t, lines, 3)
+		report := sentReport{path: r.URL.Path, auth: r.Header.Get("X-Sentry-Auth")}
+		require.NoError(t, json.Unmarshal(lines[0], &report.header))
+		require.NoError(t, json.Unmarshal(lines[2], &report.event))
+		reports <- report
+	}))
+	defer sentry.Close()
+	receive := func(t *testing.T) sentReport {
+		t.Helper()
+		select {
+		case report := <-reports:
+			return report
+		case <-time.After(5 * time.Second):
+			t.Fatal("No error report received")
+			return sentReport{}
+		}
+	}
+
+	dsn := strings.Replace(sentry.URL, "http://", "http://public-key@", 1) + "/42"
+	reporter, err := errreport.New(config.ErrorReportingConfig{DSN: dsn, Environment: "staging", SampleRate: 1, Timeout: time.Second}, version.Info{Version: "v1.2.3", GitSHA: "abc123"}, "test-hash-key", testLogger, mockClock)
+	require.NoError(t, err)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go reporter.Run(runCtx)
+	logger := slog.New(errreport.NewLogHandler(testLogger.Handler(), reporter))
+
+	t.Run("Disabled Without a DSN", func(t *testing.T) {
+		disabled, err := errreport.New(config.ErrorReportingConfig{}, version.Info{}, "", testLogger, mockClock)
+		require.NoError(t, err)
+		assert.Nil(t, disabled)
+		// A nil reporter drops reports
+		slog.New(errreport.NewLogHandler(testLogger.Handler(), disabled)).Error("Failed to do something")
+	})
+
+	t.Run("Logged Errors Are Scrubbed", func(t *testing.T) {
+		logger.ErrorContext(newTestContext(ctx), "Failed to send email", "userID", testUserID, "email", "jane@example.com", "error", errors.New("rejected jane@example.com for user "+testUserID.String()))
+
+		report := receive(t)
+		assert.Equal(t, "/api/42/envelope/", report.path)
+		assert.Contains(t, report.auth, "sentry_key=public-key")
+		assert.Equal(t, dsn, report.header["dsn"])
+
+		event := report.event
+		assert.Equal(t, "error", event["level"])
+		assert.Equal(t, "Failed to send email", event["message"])
+		assert.Equal(t, "staging", event["environment"])
+		assert.Equal(t, "v1.2.3", event["release"])
+		assert.Equal(t, "abc123", event["tags"].(map[string]any)["revision"])
+		exception := event["exception"].(map[string]any)["values"].([]any)[0].(map[string]any)
+		assert.Equal(t, "rejected [REDACTED] for user [UUID]", exception["value"])
+		extra := event["extra"].(map[string]any)
+		assert.Equal(t, "[REDACTED]", extra["email"])
+		assert.NotEqual(t, testUserID.String(), extra["userID"])
+		userID := event["user"].(map[string]any)["id"]
+		assert.Equal(t, extra["userID"], userID)
+	})
+
+	// serve serves a server service failing as fail does, behind the reporter's hooks
+	serve := func(t *testing.T, fail func(ctx context.Context) error) healthappv1connect.ServerServiceClient {
+		t.Helper()
+		mux := http.NewServeMux()
+		mux.Handle(healthappv1connect.NewServerServiceHandler(&failingServerHandler{fail: fail},
+			connect.WithInterceptors(errreport.Interceptor(reporter)),
+			connect.WithRecover(errreport.Recover(reporter, logger)),
+		))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return healthappv1connect.NewServerServiceClient(server.Client(), server.URL)
+	}
+
+	t.Run("Panics Are Recovered", func(t *testing.T) {
+		client := serve(t, func(ctx context.Context) error {
+			panic("nil map")
+		})
+		_, err := client.GetServerInfo(ctx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+
+		event := receive(t).event
+		assert.Equal(t, "fatal", event["level"])
+		assert.Equal(t, healthappv1connect.ServerServiceGetServerInfoProcedure, event["transaction"])
+		exception := event["exception"].(map[string]any)["values"].([]any)[0].(map[string]any)
+		assert.Equal(t, "nil map", exception["value"])
+		var inApp []string
+		for _, f := range exception["stacktrace"].(map[string]any)["frames"].([]any) {
+			if frame := f.(map[string]any); frame["in_app"] == true {
+				inApp = append(inApp, frame["function"].(string))
+			}
+		}
+		assert.Contains(t, strings.Join(inApp, " "), "TestErrorReporter")
+	})
+
+	t.Run("Internal Errors Are Reported Once", func(t *testing.T) {
+		// Logged errors are reported instead of the internal error of the RPC
+		client := serve(t, func(ctx context.Context) error {
+			logger.ErrorContext(ctx, "Failed to read server info", "error", errors.New("disk failure"))
+			return connect.NewError(connect.CodeInternal, errors.New("failed to get server info"))
+		})
+		_, err := client.GetServerInfo(ctx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+		event := receive(t).event
+		assert.Equal(t, "Failed to read server info", event["message"])
+		assert.Equal(t, healthappv1connect.ServerServiceGetServerInfoProcedure, event["transaction"])
+
+		// The same error is throttled, and invalid requests aren't reported
+		_, err = client.GetServerInfo(ctx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		assert.Error(t, err)
+		client = serve(t, func(ctx context.Context) error {
+			return connect.NewError(connect.CodeInvalidArgument, errors.New("invalid"))
+		})
+		_, err = client.GetServerInfo(ctx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+		// Internal errors nothing was logged for are reported by the interceptor
+		client = serve(t, func(ctx context.Context) error {
+			return connect.NewError(connect.CodeInternal, errors.New("failed to get server info"))
+		})
+		_, err = client.GetServerInfo(ctx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+		event = receive(t).event
+		assert.Equal(t, "RPC failed", event["message"])
+		assert.Equal(t, "internal", event["extra"].(map[string]any)["code"])
+	})
+}
@@ -5,32 +5,62 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
 	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
 	"github.com/atreya2011/health-management-api/internal/repo"
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"github.com/google/uuid"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+const (
+	// duplicateSearchDefaultWindow is the default window searched for duplicate records
+	duplicateSearchDefaultWindow = 30 * 24 * time.Hour
+	// duplicateSearchMaxWindow caps the window searched for duplicate records
+	duplicateSearchMaxWindow = 366 * 24 * time.Hour
+	// maxMergeRecords caps the number of records merged by a single call
+	maxMergeRecords = 20
+	// trainingLoadDefaultWeeks and trainingLoadMaxWeeks bound the weeks covered by a training load request
+	trainingLoadDefaultWeeks = 8
+	trainingLoadMaxWeeks     = 52
+	// chronicLoadWeeks is the number of weeks averaged for the chronic load
+	chronicLoadWeeks = 4
+)
+
+// exerciseIntensityNames maps proto intensities to the intensities stored in the database
+var exerciseIntensityNames = map[v1.ExerciseIntensity]string{
+	v1.ExerciseIntensity_EXERCISE_INTENSITY_LIGHT:    repo.ExerciseIntensityLight,
+	v1.ExerciseIntensity_EXERCISE_INTENSITY_MODERATE: repo.ExerciseIntensityModerate,
+	v1.ExerciseIntensity_EXERCISE_INTENSITY_VIGOROUS: repo.ExerciseIntensityVigorous,
+}
+
 // ExerciseRecordHandler implements the exercise record service RPCs
 type ExerciseRecordHandler struct {
-	repo  *repo.ExerciseRecordRepository // Use concrete repository type
-	log   *slog.Logger
-	clock clock.Clock
+	repo       ExerciseRecordRepository
+	authorizer *authz.Authorizer // Authorizes reads of records shared by other users
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
 }
 
 // NewExerciseRecordHandler creates a new exercise record handler
-func NewExerciseRecordHandler(repo *repo.ExerciseRecordRepository, log *slog.Logger, clock clock.Clock) *ExerciseRecordHandler {
+func NewExerciseRecordHandler(repo ExerciseRecordRepository, authorizer *authz.Authorizer, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *ExerciseRecordHandler {
 	return &ExerciseRecordHandler{
-		repo:  repo,
-		log:   log,
-		clock: clock,
+		repo:       repo,
+		authorizer: authorizer,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
 	}
 }
 
@@ -43,14 +73,6 @@ func (h *ExerciseRecordHandler) CreateExerciseRecord(ctx context.Context, req *c
 		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
 	}
 
-	// Get recorded_at time, default to current time if not provided
-	var recordedAt time.Time
-	if req.Msg.RecordedAt != nil {
-		recordedAt = req.Msg.RecordedAt.AsTime()
-	} else {
-		recordedAt = h.clock.Now()
-	}
-
 	// Convert protobuf wrappers to Go pointers
 	var durationMinutes *int32
 	var caloriesBurned *int32
@@ -60,6 +82,41 @@ func (h *ExerciseRecordHandler) CreateExerciseRecord(ctx context.Context, req *c
 		durationMinutes = &d
 	}
 
+	// started_at/ended_at are an alternative to duration_minutes; the duration is derived from them
+	var startedAt, endedAt *time.Time
+	if (req.Msg.StartedAt == nil) != (req.Msg.EndedAt == nil) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.TimeRangeIncomplete))
+	}
+	if req.Msg.StartedAt != nil {
+		start, end := req.Msg.StartedAt.AsTime(), req.Msg.EndedAt.AsTime()
+		if !end.After(start) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndedBeforeStarted))
+		}
+		if end.After(h.clock.Now()) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("ended_at cannot be in the future"))
+		}
+		computed := int32(math.Round(end.Sub(start).Minutes()))
+		if computed < 1 {
+			computed = 1
+		}
+		if durationMinutes != nil && *durationMinutes != computed {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DurationMismatch))
+		}
+		durationMinutes = &computed
+		startedAt, endedAt = &start, &end
+	}
+
+	// Get recorded_at time, default to started_at or the current time if not provided
+	var recordedAt time.Time
+	switch {
+	case req.Msg.RecordedAt != nil:
+		recordedAt = req.Msg.RecordedAt.AsTime()
+	case startedAt != nil:
+		recordedAt = *startedAt
+	default:
+		recordedAt = h.clock.Now()
+	}
+
 	if req.Msg.CaloriesBurned != nil {
 		c := req.Msg.CaloriesBurned.Value
 		caloriesBurned = &c
@@ -68,40 +125,71 @@ func (h *ExerciseRecordHandler) CreateExerciseRecord(ctx context.Context, req *c
 	// Re-implement validation logic here
 	exerciseName := req.Msg.ExerciseName
 	if exerciseName == "" {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("exercise name cannot be empty"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ExerciseNameEmpty))
 	}
 	if len(exerciseName) > 100 {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("exercise name exceeds maximum allowed length (100 characters)"))
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ExerciseNameTooLong))
 	}
 	if durationMinutes != nil {
 		duration := *durationMinutes
 		if duration <= 0 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("duration must be positive"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DurationNotPositive))
 		}
 		if duration > 1440 { // 24 hours in minutes
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("duration exceeds maximum allowed value (24 hours)"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DurationTooLong))
 		}
 	}
 	if caloriesBurned != nil {
 		calories := *caloriesBurned
 		if calories < 0 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("calories burned cannot be negative"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.CaloriesNegative))
 		}
 		if calories > 10000 {
-			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("calories burned exceeds maximum allowed value"))
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.CaloriesTooLarge))
 		}
 	}
 	if recordedAt.After(h.clock.Now()) {
-		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("recorded date cannot be in the future"))
+		return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("recorded date cannot be in the future"))
+	}
+	var effort repo.ExerciseEffort
+	if req.Msg.Rpe != nil {
+		rpe := req.Msg.Rpe.Value
+		if rpe < 1 || rpe > 10 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RPEOutOfRange))
+		}
+		effort.RPE = &rpe
+	}
+	if req.Msg.Intensity != v1.ExerciseIntensity_EXERCISE_INTENSITY_UNSPECIFIED {
+		intensity, ok := exerciseIntensityNames[req.Msg.Intensity]
+		if !ok {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedIntensity))
+		}
+		effort.Intensity = intensity
 	}
 	// Removed instantiation of repo.ExerciseRecord
 
+	// Look for existing workouts overlapping the new one; these are reported, not rejected
+	var overlappingIDs []string
+	if startedAt != nil {
+		overlapping, err := h.repo.FindOverlapping(ctx, userID, *startedAt, *endedAt)
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to check for overlapping exercise records", "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create exercise record"))
+		}
+		for _, record := range overlapping {
+			overlappingIDs = append(overlappingIDs, record.ID.String())
+		}
+		if len(overlappingIDs) > 0 {
+			h.log.WarnContext(ctx, "Exercise record overlaps existing records", "overlapping", overlappingIDs)
+		}
+	}
+
 	// Call repository directly with new signature, passing current time from clock
 	now := h.clock.Now()
-	h.log.InfoContext(ctx, "Creating exercise record", "userID", userID, "exerciseName", exerciseName, "now", now)
-	savedRecord, err := h.repo.Create(ctx, userID, exerciseName, durationMinutes, caloriesBurned, recordedAt, now)
+	h.log.InfoContext(ctx, "Creating exercise record", "exerciseName", exerciseName, "now", now)
+	savedRecord, err := h.repo.Create(ctx, userID, exerciseName, durationMinutes, caloriesBurned, recordedAt, startedAt, endedAt, effort, now)
 	if err != nil {
-		h.log.ErrorContext(ctx, "Failed to create exercise record", "userID", userID, "error", err)
+		h.log.ErrorContext(ctx, "Failed to create exercise record", "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create exercise record"))
 	}
 
@@ -110,7 +198,8 @@ func (h *ExerciseRecordHandler) CreateExerciseRecord(ctx context.Context, req *c
 
 	// Create response
 	res := connect.NewResponse(&v1.CreateExerciseRecordResponse{
-		ExerciseRecord: protoRecord,
+		ExerciseRecord:       protoRecord,
+		OverlappingRecordIds: overlappingIDs,
 	})
 
 	return res, nil
@@ -118,40 +207,21 @@ func (h *ExerciseRecordHandler) CreateExerciseRecord(ctx context.Context, req *c
 
 // ListExerciseRecords lists exercise records for the authenticated user
 func (h *ExerciseRecordHandler) ListExerciseRecords(ctx context.Context, req *connect.Request[v1.ListExerciseRecordsRequest]) (*connect.Response[v1.ListExerciseRecordsResponse], error) {
-	// Get user ID from context
-	userID, err := auth.GetUserID(ctx)
+	// Get the owner of the records to read: the authenticated user, or a user sharing them
+	ctx, userID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeExerciseRecords)
 	if err != nil {
-		h.log.ErrorContext(ctx, "User ID not found in context")
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+		return nil, err
 	}
 
 	// Get pagination parameters
-	pageSize := 20  // Default page size
-	pageNumber := 1 // Default page number
-
-	if req.Msg.Pagination != nil {
-		if req.Msg.Pagination.PageSize > 0 {
-			pageSize = int(req.Msg.Pagination.PageSize)
-		}
-		if req.Msg.Pagination.PageNumber > 0 {
-			pageNumber = int(req.Msg.Pagination.PageNumber)
-		}
-	}
-	// Ensure page is at least 1 (from service)
-	if pageNumber <= 0 {
-		pageNumber = 1
-	}
-	// Apply max page size (from service)
-	if pageSize > 100 {
-		pageSize = 100
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
 	}
 
-	// Calculate offset (from service)
-	offset := (pageNumber - 1) * pageSize
-
 	// Call repository directly
-	h.log.InfoContext(ctx, "Fetching exercise records for user", "userID", userID, "page", pageNumber, "pageSize", pageSize)
-	records, err := h.repo.FindByUser(ctx, userID, pageSize, offset) // Changed from exerciseApp.ListExerciseRecords
+	h.log.InfoContext(ctx, "Fetching exercise records for user", "userID", userID, "page", page.Number, "pageSize", page.Size)
+	records, err := h.repo.FindByUser(ctx, userID, page.Size, page.Offset) // Changed from exerciseApp.ListExerciseRecords
 	if err != nil {
 		h.log.ErrorContext(ctx, "Failed to fetch exercise records", "userID", userID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch exercise records"))
@@ -170,20 +240,10 @@ func (h *ExerciseRecordHandler) ListExerciseRecords(ctx context.Context, req *co
 		protoRecords[i] = ToProtoExerciseRecord(record) // Pass db.ExerciseRecord
 	}
 
-	// Calculate pagination response
-	totalPages := (int(total) + pageSize - 1) / pageSize // Ceiling division
-	if totalPages == 0 {
-		totalPages = 1
-	}
-
 	// Create response
 	res := connect.NewResponse(&v1.ListExerciseRecordsResponse{
 		ExerciseRecords: protoRecords,
-		Pagination: &v1.PageResponse{
-			TotalItems:  int32(total),
-			TotalPages:  int32(totalPages),
-			CurrentPage: int32(pageNumber),
-		},
+		Pagination:      page.Response(total),
 	})
 
 	return res, nil
@@ -199,24 +259,24 @@ func (h *ExerciseRecordHandler) DeleteExerciseRecord(ctx context.Context, req *c
 	}
 
 	// Parse record ID
-	recordID, err := uuid.Parse(req.Msg.Id)
+	recordID, err := reqparse.ParseUUID("id", req.Msg.Id)
 	if err != nil {
 		h.log.WarnContext(ctx, "Invalid record ID", "recordID", req.Msg.Id, "error", err)
-		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid record ID: %w", err))
+		return nil, reqparse.Error(err)
 	}
 
 	// Call repository directly
-	h.log.InfoContext(ctx, "Deleting exercise record", "recordID", recordID, "userID", userID)
-	err = h.repo.Delete(ctx, recordID, userID)
+	h.log.InfoContext(ctx, "Deleting exercise record", "recordID", recordID)
+	err = h.repo.Delete(ctx, recordID, userID, h.clock.Now())
 	if err != nil {
 		// Check if the error is ErrExerciseRecordNotFound from the repository
 		if errors.Is(err, repo.ErrExerciseRecordNotFound) {
-			h.log.WarnContext(ctx, "Exercise record not found or not owned by user during deletion", "recordID", recordID, "userID", userID)
+			h.log.WarnContext(ctx, "Exercise record not found or not owned by user during deletion", "recordID", recordID)
 			// Return NotFound error to the client
-			return nil, connect.NewError(connect.CodeNotFound, errors.New("exercise record not found"))
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise record not found"))
 		}
 		// Handle other potential errors
-		h.log.ErrorContext(ctx, "Failed to delete exercise record", "recordID", recordID, "userID", userID, "error", err)
+		h.log.ErrorContext(ctx, "Failed to delete exercise record", "recordID", recordID, "error", err)
 		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete exercise record"))
 	}
 
@@ -228,6 +288,231 @@ func (h *ExerciseRecordHandler) DeleteExerciseRecord(ctx context.Context, req *c
 	return res, nil
 }
 
+// DeleteExerciseRecordsByDateRange deletes the exercise records recorded in a date range, or counts
+// them on a dry run
+func (h *ExerciseRecordHandler) DeleteExerciseRecordsByDateRange(ctx context.Context, req *connect.Request[v1.DeleteExerciseRecordsByDateRangeRequest]) (*connect.Response[v1.DeleteExerciseRecordsByDateRangeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	startDate, endDate, err := parseDateRange(req.Msg.StartDate, req.Msg.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	// The end date is inclusive
+	start, end := startDate, endDate.AddDate(0, 0, 1)
+
+	if req.Msg.DryRun {
+		count, err := h.repo.CountByUserAndRange(ctx, userID, start, end)
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to count exercise records by date range", "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count exercise records"))
+		}
+		return connect.NewResponse(&v1.DeleteExerciseRecordsByDateRangeResponse{DeletedCount: int32(count)}), nil
+	}
+
+	h.log.InfoContext(ctx, "Deleting exercise records by date range", "startDate", startDate, "endDate", endDate, "expectedCount", req.Msg.ExpectedCount)
+	err = h.repo.DeleteByRange(ctx, userID, start, end, int64(req.Msg.ExpectedCount), h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrRecordCountChanged) {
+			return nil, apierror.New(connect.CodeFailedPrecondition, apierror.ReasonRecordCountChanged, errors.New("the range holds a different number of exercise records than expected_count"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete exercise records by date range", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete exercise records"))
+	}
+
+	return connect.NewResponse(&v1.DeleteExerciseRecordsByDateRangeResponse{DeletedCount: req.Msg.ExpectedCount}), nil
+}
+
+// ListDuplicateExerciseRecords groups the user's exercise records whose time ranges overlap
+func (h *ExerciseRecordHandler) ListDuplicateExerciseRecords(ctx context.Context, req *connect.Request[v1.ListDuplicateExerciseRecordsRequest]) (*connect.Response[v1.ListDuplicateExerciseRecordsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Resolve the search window
+	end := h.clock.Now()
+	if req.Msg.EndTime != nil {
+		end = req.Msg.EndTime.AsTime()
+	}
+	start := end.Add(-duplicateSearchDefaultWindow)
+	if req.Msg.StartTime != nil {
+		start = req.Msg.StartTime.AsTime()
+	}
+	if !end.After(start) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndTimeBeforeStartTime))
+	}
+	if end.Sub(start) > duplicateSearchMaxWindow {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.SearchWindowTooLong))
+	}
+
+	records, err := h.repo.FindOverlapping(ctx, userID, start, end)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch exercise records", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch exercise records"))
+	}
+
+	// Create response
+	resp := &v1.ListDuplicateExerciseRecordsResponse{
+		Groups: []*v1.DuplicateExerciseRecordGroup{},
+	}
+	for _, group := range groupOverlappingExerciseRecords(records) {
+		protoGroup := &v1.DuplicateExerciseRecordGroup{
+			ExerciseRecords: make([]*v1.ExerciseRecord, len(group)),
+		}
+		for i, record := range group {
+			protoGroup.ExerciseRecords[i] = ToProtoExerciseRecord(record)
+		}
+		resp.Groups = append(resp.Groups, protoGroup)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// MergeExerciseRecords merges duplicate exercise records into the one with the most data
+func (h *ExerciseRecordHandler) MergeExerciseRecords(ctx context.Context, req *connect.Request[v1.MergeExerciseRecordsRequest]) (*connect.Response[v1.MergeExerciseRecordsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse record IDs, ignoring repeated IDs
+	seen := make(map[uuid.UUID]bool, len(req.Msg.Ids))
+	recordIDs := make([]uuid.UUID, 0, len(req.Msg.Ids))
+	for _, id := range req.Msg.Ids {
+		recordID, err := reqparse.ParseUUID("ids", id)
+		if err != nil {
+			h.log.WarnContext(ctx, "Invalid record ID", "recordID", id, "error", err)
+			return nil, reqparse.Error(err)
+		}
+		if !seen[recordID] {
+			seen[recordID] = true
+			recordIDs = append(recordIDs, recordID)
+		}
+	}
+	if len(recordIDs) < 2 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.MergeNeedsTwoRecords))
+	}
+	if len(recordIDs) > maxMergeRecords {
+		return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonLimitExceeded, fmt.Errorf("too many records to merge (maximum %d)", maxMergeRecords))
+	}
+
+	merged, removedIDs, err := h.repo.Merge(ctx, userID, recordIDs, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrExerciseRecordNotFound) {
+			h.log.WarnContext(ctx, "Exercise records not found or not owned by user during merge", "recordIDs", recordIDs)
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise record not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to merge exercise records", "recordIDs", recordIDs, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to merge exercise records"))
+	}
+	h.log.InfoContext(ctx, "Merged exercise records", "keptID", merged.ID, "removedIDs", removedIDs)
+
+	// Create response
+	resp := &v1.MergeExerciseRecordsResponse{
+		ExerciseRecord: ToProtoExerciseRecord(merged),
+		RemovedIds:     make([]string, len(removedIDs)),
+	}
+	for i, id := range removedIDs {
+		resp.RemovedIds[i] = id.String()
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// GetTrainingLoad returns the user's weekly session-RPE training load and acute:chronic workload ratio
+func (h *ExerciseRecordHandler) GetTrainingLoad(ctx context.Context, req *connect.Request[v1.GetTrainingLoadRequest]) (*connect.Response[v1.GetTrainingLoadResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	weeks := int(req.Msg.Weeks)
+	if weeks == 0 {
+		weeks = trainingLoadDefaultWeeks
+	}
+	if weeks < 0 || weeks > trainingLoadMaxWeeks {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("weeks must be between 1 and %d", trainingLoadMaxWeeks))
+	}
+
+	// Weeks are the 7-day periods ending today; the chronic load needs at least chronicLoadWeeks of them
+	tomorrow := h.clock.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	buckets := max(weeks, chronicLoadWeeks)
+	start := tomorrow.AddDate(0, 0, -7*buckets)
+
+	loads, err := h.repo.DailyTrainingLoads(ctx, userID, start, tomorrow)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch training loads", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get training load"))
+	}
+
+	allWeeks := make([]*v1.TrainingLoadWeek, buckets)
+	for i := range allWeeks {
+		allWeeks[i] = &v1.TrainingLoadWeek{StartDate: start.AddDate(0, 0, 7*i).Format("2006-01-02")}
+	}
+	for _, day := range loads {
+		week := allWeeks[int(day.Day.Time.Sub(start).Hours()/24)/7]
+		week.Load += int32(day.Load)
+		week.SessionCount += int32(day.SessionCount)
+		week.UnratedSessionCount += int32(day.UnratedSessionCount)
+	}
+
+	// Create response
+	resp := &v1.GetTrainingLoadResponse{
+		Weeks:     allWeeks[buckets-weeks:],
+		AcuteLoad: allWeeks[buckets-1].Load,
+	}
+	var chronic int32
+	for _, week := range allWeeks[buckets-chronicLoadWeeks:] {
+		chronic += week.Load
+	}
+	resp.ChronicLoad = float64(chronic) / chronicLoadWeeks
+	if resp.ChronicLoad > 0 {
+		resp.AcuteChronicRatio = math.Round(float64(resp.AcuteLoad)/resp.ChronicLoad*100) / 100
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// groupOverlappingExerciseRecords groups records sorted by started_at into runs of overlapping
+// time ranges, keeping only groups with more than one record
+func groupOverlappingExerciseRecords(records []db.ExerciseRecord) [][]db.ExerciseRecord {
+	var groups [][]db.ExerciseRecord
+	var current []db.ExerciseRecord
+	var currentEnd time.Time
+
+	for _, record := range records {
+		if len(current) > 0 && record.StartedAt.Time.Before(currentEnd) {
+			current = append(current, record)
+			if record.EndedAt.Time.After(currentEnd) {
+				currentEnd = record.EndedAt.Time
+			}
+			continue
+		}
+		if len(current) > 1 {
+			groups = append(groups, current)
+		}
+		current = []db.ExerciseRecord{record}
+		currentEnd = record.EndedAt.Time
+	}
+	if len(current) > 1 {
+		groups = append(groups, current)
+	}
+
+	return groups
+}
+
 // ToProtoExerciseRecord converts a db.ExerciseRecord (sqlc generated) to a v1.ExerciseRecord
 func ToProtoExerciseRecord(record db.ExerciseRecord) *v1.ExerciseRecord { // Accept db.ExerciseRecord
 	protoRecord := &v1.ExerciseRecord{
@@ -248,5 +533,24 @@ func ToProtoExerciseRecord(record db.ExerciseRecord) *v1.ExerciseRecord { // Acc
 		protoRecord.CaloriesBurned = &wrapperspb.Int32Value{Value: record.CaloriesBurned.Int32}
 	}
 
+	// Handle pgtype.Timestamptz for the optional time range
+	if record.StartedAt.Valid && record.EndedAt.Valid {
+		protoRecord.StartedAt = timestamppb.New(record.StartedAt.Time)
+		protoRecord.EndedAt = timestamppb.New(record.EndedAt.Time)
+	}
+
+	// Handle the optional effort ratings
+	if record.Rpe.Valid {
+		protoRecord.Rpe = &wrapperspb.Int32Value{Value: int32(record.Rpe.Int16)}
+	}
+	for i, name := range exerciseIntensityNames {
+		if record.Intensity.Valid && name == record.Intensity.String {
+			protoRecord.Intensity = i
+		}
+	}
+	if record.WorkoutSessionID.Valid {
+		protoRecord.WorkoutSessionId = uuid.UUID(record.WorkoutSessionID.Bytes).String()
+	}
+
 	return protoRecord
 }
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
 	"github.com/atreya2011/health-management-api/internal/repo"
 	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
 	"github.com/atreya2011/health-management-api/internal/testutil"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -32,10 +34,11 @@ func TestCreateExerciseRecord(t *testing.T) {
 	fixedTimestampPb := timestamppb.New(fixedTime)
 
 	testCases := []struct {
-		name         string
-		req          *v1.CreateExerciseRecordRequest
-		expectError  bool
-		expectedResp *v1.CreateExerciseRecordResponse
+		name           string
+		req            *v1.CreateExerciseRecordRequest
+		expectError    bool
+		expectedReason string
+		expectedResp   *v1.CreateExerciseRecordResponse
 	}{
 		{
 			name: "Success - Full Record",
@@ -87,6 +90,16 @@ func TestCreateExerciseRecord(t *testing.T) {
 			expectError:  true,
 			expectedResp: nil,
 		},
+		{
+			name: "Error - Future RecordedAt",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName: "Cycling",
+				RecordedAt:   timestamppb.New(fixedTime.Add(time.Hour)),
+			},
+			expectError:    true,
+			expectedReason: apierror.ReasonValidationFutureDate,
+			expectedResp:   nil,
+		},
 		{
 			name: "Error - Missing Exercise Name",
 			req: &v1.CreateExerciseRecordRequest{
@@ -114,13 +127,97 @@ func TestCreateExerciseRecord(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Success - Duration From Time Range",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName: "Rowing",
+				StartedAt:    timestamppb.New(fixedTime.Add(-45 * time.Minute)),
+				EndedAt:      timestamppb.New(fixedTime),
+			},
+			expectError: false,
+			expectedResp: &v1.CreateExerciseRecordResponse{
+				ExerciseRecord: &v1.ExerciseRecord{
+					UserId:          testUserID.String(),
+					ExerciseName:    "Rowing",
+					DurationMinutes: wrapperspb.Int32(45),
+					RecordedAt:      timestamppb.New(fixedTime.Add(-45 * time.Minute)), // Defaults to started_at
+					StartedAt:       timestamppb.New(fixedTime.Add(-45 * time.Minute)),
+					EndedAt:         timestamppb.New(fixedTime),
+					CreatedAt:       fixedTimestampPb,
+					UpdatedAt:       fixedTimestampPb,
+				},
+			},
+		},
+		{
+			name: "Error - Duration Does Not Match Time Range",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName:    "Rowing",
+				DurationMinutes: wrapperspb.Int32(10),
+				StartedAt:       timestamppb.New(fixedTime.Add(-45 * time.Minute)),
+				EndedAt:         timestamppb.New(fixedTime),
+			},
+			expectError:  true,
+			expectedResp: nil,
+		},
+		{
+			name: "Success - Effort Ratings",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName:    "Squats",
+				DurationMinutes: wrapperspb.Int32(40),
+				RecordedAt:      recordedAtPb,
+				Rpe:             wrapperspb.Int32(8),
+				Intensity:       v1.ExerciseIntensity_EXERCISE_INTENSITY_VIGOROUS,
+			},
+			expectError: false,
+			expectedResp: &v1.CreateExerciseRecordResponse{
+				ExerciseRecord: &v1.ExerciseRecord{
+					UserId:          testUserID.String(),
+					ExerciseName:    "Squats",
+					DurationMinutes: wrapperspb.Int32(40),
+					RecordedAt:      recordedAtPb,
+					Rpe:             wrapperspb.Int32(8),
+					Intensity:       v1.ExerciseIntensity_EXERCISE_INTENSITY_VIGOROUS,
+					CreatedAt:       fixedTimestampPb,
+					UpdatedAt:       fixedTimestampPb,
+				},
+			},
+		},
+		{
+			name: "Error - RPE Out Of Range",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName: "Squats",
+				RecordedAt:   recordedAtPb,
+				Rpe:          wrapperspb.Int32(11),
+			},
+			expectError:  true,
+			expectedResp: nil,
+		},
+		{
+			name: "Error - Unknown Intensity",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName: "Squats",
+				RecordedAt:   recordedAtPb,
+				Intensity:    v1.ExerciseIntensity(42),
+			},
+			expectError:  true,
+			expectedResp: nil,
+		},
+		{
+			name: "Error - StartedAt Without EndedAt",
+			req: &v1.CreateExerciseRecordRequest{
+				ExerciseName: "Rowing",
+				StartedAt:    timestamppb.New(fixedTime.Add(-45 * time.Minute)),
+			},
+			expectError:  true,
+			expectedResp: nil,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
 			exerciseRepo := repo.NewExerciseRecordRepository(testPool)
-			handler := NewExerciseRecordHandler(exerciseRepo, testLogger, mockClock) // Pass mockClock
+			handler := NewExerciseRecordHandler(exerciseRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -130,6 +227,9 @@ func TestCreateExerciseRecord(t *testing.T) {
 			if tc.expectError {
 				require.Error(t, err)
 				assert.Nil(t, resp)
+				if tc.expectedReason != "" {
+					assert.Equal(t, tc.expectedReason, apierror.Reason(err))
+				}
 			} else {
 				require.NoError(t, err)
 				require.NotNil(t, resp)
@@ -155,10 +255,46 @@ func TestCreateExerciseRecord(t *testing.T) {
 	}
 }
 
+func TestCreateExerciseRecordOverlap(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewExerciseRecordHandler(repo.NewExerciseRecordRepository(testPool), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	testCtx := newTestContext(context.Background())
+
+	fixedTime := time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	first, err := handler.CreateExerciseRecord(testCtx, connect.NewRequest(&v1.CreateExerciseRecordRequest{
+		ExerciseName: "Running",
+		StartedAt:    timestamppb.New(fixedTime.Add(-2 * time.Hour)),
+		EndedAt:      timestamppb.New(fixedTime.Add(-1 * time.Hour)),
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, first.Msg.OverlappingRecordIds)
+
+	// Overlapping session is created but reported
+	second, err := handler.CreateExerciseRecord(testCtx, connect.NewRequest(&v1.CreateExerciseRecordRequest{
+		ExerciseName: "Treadmill",
+		StartedAt:    timestamppb.New(fixedTime.Add(-90 * time.Minute)),
+		EndedAt:      timestamppb.New(fixedTime.Add(-30 * time.Minute)),
+	}))
+	require.NoError(t, err)
+	require.NotEmpty(t, second.Msg.ExerciseRecord.Id)
+	assert.Equal(t, []string{first.Msg.ExerciseRecord.Id}, second.Msg.OverlappingRecordIds)
+
+	// Back-to-back sessions do not overlap
+	third, err := handler.CreateExerciseRecord(testCtx, connect.NewRequest(&v1.CreateExerciseRecordRequest{
+		ExerciseName: "Stretching",
+		StartedAt:    timestamppb.New(fixedTime.Add(-30 * time.Minute)),
+		EndedAt:      timestamppb.New(fixedTime),
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, third.Msg.OverlappingRecordIds)
+}
+
 func TestListExerciseRecords(t *testing.T) {
 	resetDB(t, testPool)
 	exerciseRepo := repo.NewExerciseRecordRepository(testPool)
-	handler := NewExerciseRecordHandler(exerciseRepo, testLogger, mockClock) // Pass mockClock
+	handler := NewExerciseRecordHandler(exerciseRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 	ctx := context.Background()
 	testCtx := newTestContext(ctx)
 
@@ -208,9 +344,11 @@ func TestListExerciseRecords(t *testing.T) {
 			expectedResp: &v1.ListExerciseRecordsResponse{
 				ExerciseRecords: []*v1.ExerciseRecord{protoToday},
 				Pagination: &v1.PageResponse{
-					TotalItems:  2,
-					TotalPages:  2,
-					CurrentPage: 1,
+					TotalItems:    2,
+					TotalPages:    2,
+					CurrentPage:   1,
+					HasNext:       true,
+					NextPageToken: reqparse.EncodePageToken(1),
 				},
 			},
 		},
@@ -226,6 +364,7 @@ func TestListExerciseRecords(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 2,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -241,6 +380,7 @@ func TestListExerciseRecords(t *testing.T) {
 					TotalItems:  2,
 					TotalPages:  2,
 					CurrentPage: 3,
+					HasPrevious: true,
 				},
 			},
 		},
@@ -347,7 +487,7 @@ func TestDeleteExerciseRecord(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			resetDB(t, testPool)
 			exerciseRepo := repo.NewExerciseRecordRepository(testPool)
-			handler := NewExerciseRecordHandler(exerciseRepo, testLogger, mockClock) // Pass mockClock
+			handler := NewExerciseRecordHandler(exerciseRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock) // Pass mockClock
 			ctx := context.Background()
 			testCtx := newTestContext(ctx)
 
@@ -382,3 +522,206 @@ func TestDeleteExerciseRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestListDuplicateExerciseRecords(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	exerciseRepo := repo.NewExerciseRecordRepository(testPool)
+	handler := NewExerciseRecordHandler(exerciseRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	testCtx := newTestContext(ctx)
+
+	fixedTime := time.Date(2024, 1, 15, 20, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	createTimed := func(name string, start, end time.Time) db.ExerciseRecord {
+		record, err := exerciseRepo.Create(ctx, testUserID, name, nil, nil, start, &start, &end, repo.ExerciseEffort{}, fixedTime)
+		require.NoError(t, err)
+		return record
+	}
+
+	// A device run duplicating a manual run, chained to a third overlapping entry
+	morning := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+	manualRun := createTimed("Running", morning, morning.Add(30*time.Minute))
+	deviceRun := createTimed("Run", morning.Add(5*time.Minute), morning.Add(40*time.Minute))
+	cooldown := createTimed("Walking", morning.Add(35*time.Minute), morning.Add(50*time.Minute))
+	// Back-to-back and untimed records are not duplicates
+	createTimed("Yoga", morning.Add(50*time.Minute), morning.Add(80*time.Minute))
+	_, err := testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Stretching", nil, nil, morning, fixedTime)
+	require.NoError(t, err)
+
+	resp, err := handler.ListDuplicateExerciseRecords(testCtx, connect.NewRequest(&v1.ListDuplicateExerciseRecordsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, resp.Msg.Groups, 1)
+	var ids []string
+	for _, record := range resp.Msg.Groups[0].ExerciseRecords {
+		ids = append(ids, record.Id)
+	}
+	assert.Equal(t, []string{manualRun.ID.String(), deviceRun.ID.String(), cooldown.ID.String()}, ids)
+
+	// Invalid window
+	_, err = handler.ListDuplicateExerciseRecords(testCtx, connect.NewRequest(&v1.ListDuplicateExerciseRecordsRequest{
+		StartTime: timestamppb.New(fixedTime),
+		EndTime:   timestamppb.New(fixedTime.Add(-time.Hour)),
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestMergeExerciseRecords(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	exerciseRepo := repo.NewExerciseRecordRepository(testPool)
+	handler := NewExerciseRecordHandler(exerciseRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	testCtx := newTestContext(ctx)
+
+	fixedTime := time.Date(2024, 1, 15, 20, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	morning := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+
+	// The manual entry has calories, the device entry has the time range and duration
+	calories := int32(300)
+	manual, err := testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Morning run", nil, &calories, morning, fixedTime.Add(-2*time.Hour))
+	require.NoError(t, err)
+	start, end := morning, morning.Add(32*time.Minute)
+	duration := int32(32)
+	device, err := exerciseRepo.Create(ctx, testUserID, "Running", &duration, nil, start, &start, &end, repo.ExerciseEffort{}, fixedTime.Add(-time.Hour))
+	require.NoError(t, err)
+
+	// Error cases
+	_, err = handler.MergeExerciseRecords(testCtx, connect.NewRequest(&v1.MergeExerciseRecordsRequest{
+		Ids: []string{manual.ID.String(), manual.ID.String()},
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	_, err = handler.MergeExerciseRecords(testCtx, connect.NewRequest(&v1.MergeExerciseRecordsRequest{
+		Ids: []string{manual.ID.String(), uuid.NewString()},
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	assert.Equal(t, apierror.ReasonNotFound, apierror.Reason(err))
+
+	// Merge keeps the richer device record and fills in the calories
+	resp, err := handler.MergeExerciseRecords(testCtx, connect.NewRequest(&v1.MergeExerciseRecordsRequest{
+		Ids: []string{manual.ID.String(), device.ID.String()},
+	}))
+	require.NoError(t, err)
+	merged := resp.Msg.ExerciseRecord
+	assert.Equal(t, device.ID.String(), merged.Id)
+	assert.Equal(t, "Running", merged.ExerciseName)
+	assert.EqualValues(t, 32, merged.DurationMinutes.GetValue())
+	assert.EqualValues(t, 300, merged.CaloriesBurned.GetValue())
+	assert.True(t, merged.StartedAt.AsTime().Equal(start))
+	assert.True(t, merged.UpdatedAt.AsTime().Equal(fixedTime))
+	assert.Equal(t, []string{manual.ID.String()}, resp.Msg.RemovedIds)
+
+	records, err := exerciseRepo.FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, device.ID, records[0].ID)
+}
+
+func TestGetTrainingLoad(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewExerciseRecordHandler(repo.NewExerciseRecordRepository(testPool), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	testCtx := newTestContext(context.Background())
+
+	fixedTime := time.Date(2024, 1, 29, 14, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	create := func(t *testing.T, recordedAt time.Time, duration int32, rpe *wrapperspb.Int32Value, intensity v1.ExerciseIntensity) {
+		t.Helper()
+		_, err := handler.CreateExerciseRecord(testCtx, connect.NewRequest(&v1.CreateExerciseRecordRequest{
+			ExerciseName:    "Deadlifts",
+			DurationMinutes: wrapperspb.Int32(duration),
+			RecordedAt:      timestamppb.New(recordedAt),
+			Rpe:             rpe,
+			Intensity:       intensity,
+		}))
+		require.NoError(t, err)
+	}
+	// This week: 60 x 8 + 30 x moderate (5), plus an unrated session
+	create(t, fixedTime.Add(-time.Hour), 60, wrapperspb.Int32(8), v1.ExerciseIntensity_EXERCISE_INTENSITY_UNSPECIFIED)
+	create(t, fixedTime.AddDate(0, 0, -6), 30, nil, v1.ExerciseIntensity_EXERCISE_INTENSITY_MODERATE)
+	create(t, fixedTime.AddDate(0, 0, -2), 45, nil, v1.ExerciseIntensity_EXERCISE_INTENSITY_UNSPECIFIED)
+	// Three weeks ago: 50 x 6; the RPE takes precedence over the intensity
+	create(t, fixedTime.AddDate(0, 0, -21), 50, wrapperspb.Int32(6), v1.ExerciseIntensity_EXERCISE_INTENSITY_LIGHT)
+
+	t.Run("Weekly Loads", func(t *testing.T) {
+		resp, err := handler.GetTrainingLoad(testCtx, connect.NewRequest(&v1.GetTrainingLoadRequest{Weeks: 4}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Weeks, 4)
+		assert.Equal(t, "2024-01-02", resp.Msg.Weeks[0].StartDate)
+		assert.EqualValues(t, 300, resp.Msg.Weeks[0].Load)
+		assert.EqualValues(t, 0, resp.Msg.Weeks[1].Load)
+		assert.Equal(t, "2024-01-23", resp.Msg.Weeks[3].StartDate)
+		assert.EqualValues(t, 630, resp.Msg.Weeks[3].Load)
+		assert.EqualValues(t, 3, resp.Msg.Weeks[3].SessionCount)
+		assert.EqualValues(t, 1, resp.Msg.Weeks[3].UnratedSessionCount)
+
+		assert.EqualValues(t, 630, resp.Msg.AcuteLoad)
+		assert.InDelta(t, 232.5, resp.Msg.ChronicLoad, 0.001)
+		assert.InDelta(t, 2.71, resp.Msg.AcuteChronicRatio, 0.001)
+	})
+
+	t.Run("Chronic Load Beyond Requested Weeks", func(t *testing.T) {
+		resp, err := handler.GetTrainingLoad(testCtx, connect.NewRequest(&v1.GetTrainingLoadRequest{Weeks: 1}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Weeks, 1)
+		assert.InDelta(t, 232.5, resp.Msg.ChronicLoad, 0.001)
+	})
+
+	t.Run("Error - Too Many Weeks", func(t *testing.T) {
+		_, err := handler.GetTrainingLoad(testCtx, connect.NewRequest(&v1.GetTrainingLoadRequest{Weeks: 53}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
+
+func TestDeleteExerciseRecordsByDateRange(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 14, 20, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	handler := NewExerciseRecordHandler(repo.NewExerciseRecordRepository(testPool), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	duration := int32(30)
+	// Two records on the 14th, at its first and last minute, and one on the 15th
+	for _, recordedAt := range []time.Time{
+		time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 14, 23, 59, 0, 0, time.UTC),
+		fixedTime,
+	} {
+		_, err := testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Imported Run", &duration, nil, recordedAt, fixedTime)
+		require.NoError(t, err)
+	}
+	byRange := func(dryRun bool, expected int32) (*connect.Response[v1.DeleteExerciseRecordsByDateRangeResponse], error) {
+		return handler.DeleteExerciseRecordsByDateRange(testCtx, connect.NewRequest(&v1.DeleteExerciseRecordsByDateRangeRequest{
+			StartDate:     "2024-01-10",
+			EndDate:       "2024-01-14",
+			DryRun:        dryRun,
+			ExpectedCount: expected,
+		}))
+	}
+
+	dryRun, err := byRange(true, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, dryRun.Msg.DeletedCount)
+
+	// A count other than that of the dry run deletes nothing
+	_, err = byRange(false, 1)
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	assert.Equal(t, apierror.ReasonRecordCountChanged, apierror.Reason(err))
+
+	deleted, err := byRange(false, dryRun.Msg.DeletedCount)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, deleted.Msg.DeletedCount)
+
+	list, err := handler.ListExerciseRecords(testCtx, connect.NewRequest(&v1.ListExerciseRecordsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, list.Msg.ExerciseRecords, 1)
+	assert.Equal(t, fixedTime, list.Msg.ExerciseRecords[0].RecordedAt.AsTime())
+
+	_, err = handler.DeleteExerciseRecordsByDateRange(testCtx, connect.NewRequest(&v1.DeleteExerciseRecordsByDateRangeRequest{StartDate: "2024-01-15", EndDate: "2024-01-14", DryRun: true}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
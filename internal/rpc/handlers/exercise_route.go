@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/route"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxRouteBytes bounds the size of an uploaded GPX file or encoded polyline
+	maxRouteBytes = 2 << 20
+	// maxRouteDistanceMeters bounds the distance of a route
+	maxRouteDistanceMeters = 1000000
+)
+
+// AttachExerciseRoute attaches a GPS route to an exercise record of the user
+func (h *ExerciseRecordHandler) AttachExerciseRoute(ctx context.Context, req *connect.Request[v1.AttachExerciseRouteRequest]) (*connect.Response[v1.AttachExerciseRouteResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	recordID, err := reqparse.ParseUUID("exercise_record_id", req.Msg.ExerciseRecordId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid exercise record ID", "recordID", req.Msg.ExerciseRecordId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	var points []route.Point
+	switch track := req.Msg.Track.(type) {
+	case *v1.AttachExerciseRouteRequest_Gpx:
+		if len(track.Gpx) > maxRouteBytes {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonLimitExceeded, i18n.NewError(i18n.RouteTooLarge, maxRouteBytes))
+		}
+		points, err = route.ParseGPX(track.Gpx)
+	case *v1.AttachExerciseRouteRequest_Polyline:
+		if len(track.Polyline) > maxRouteBytes {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonLimitExceeded, i18n.NewError(i18n.RouteTooLarge, maxRouteBytes))
+		}
+		points, err = route.DecodePolyline(track.Polyline)
+	default:
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RouteRequired))
+	}
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid exercise route", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RouteInvalid))
+	}
+	if err := route.Validate(points); err != nil {
+		if errors.Is(err, route.ErrPointCount) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RoutePointCount, route.MaxPoints))
+		}
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RouteCoordinatesInvalid))
+	}
+	distance := route.Distance(points)
+	if req.Msg.DistanceMeters != nil {
+		distance = req.Msg.DistanceMeters.Value
+	}
+	if !(distance >= 0 && distance <= maxRouteDistanceMeters) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DistanceOutOfRange, maxRouteDistanceMeters))
+	}
+
+	polyline, err := route.Compress(points)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to compress exercise route", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to attach exercise route"))
+	}
+	saved, err := h.repo.SetRoute(ctx, recordID, userID, polyline, int32(len(points)), distance, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrExerciseRecordNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise record not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to attach exercise route", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to attach exercise route"))
+	}
+	h.log.InfoContext(ctx, "Exercise route attached", "recordID", recordID, "points", len(points), "compressedBytes", len(polyline))
+
+	protoRoute, err := ToProtoExerciseRoute(saved)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to decode exercise route", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to attach exercise route"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.AttachExerciseRouteResponse{
+		Route: protoRoute,
+	})
+
+	return res, nil
+}
+
+// GetExerciseRoute returns the GPS route of an exercise record of the user
+func (h *ExerciseRecordHandler) GetExerciseRoute(ctx context.Context, req *connect.Request[v1.GetExerciseRouteRequest]) (*connect.Response[v1.GetExerciseRouteResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	recordID, err := reqparse.ParseUUID("exercise_record_id", req.Msg.ExerciseRecordId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid exercise record ID", "recordID", req.Msg.ExerciseRecordId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	saved, err := h.repo.FindRoute(ctx, recordID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrExerciseRouteNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise route not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to get exercise route", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get exercise route"))
+	}
+
+	protoRoute, err := ToProtoExerciseRoute(saved)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to decode exercise route", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get exercise route"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetExerciseRouteResponse{
+		Route: protoRoute,
+	})
+
+	return res, nil
+}
+
+// ToProtoExerciseRoute converts a stored db.ExerciseRoute to a v1.ExerciseRoute with its points
+func ToProtoExerciseRoute(r db.ExerciseRoute) (*v1.ExerciseRoute, error) {
+	polyline, err := route.Decompress(r.Polyline)
+	if err != nil {
+		return nil, err
+	}
+	points, err := route.DecodePolyline(polyline)
+	if err != nil {
+		return nil, err
+	}
+
+	protoRoute := &v1.ExerciseRoute{
+		ExerciseRecordId: r.ExerciseRecordID.String(),
+		Points:           make([]*v1.RoutePoint, 0, len(points)),
+		Polyline:         polyline,
+		DistanceMeters:   r.DistanceMeters,
+		CreatedAt:        timestamppb.New(r.CreatedAt),
+		UpdatedAt:        timestamppb.New(r.UpdatedAt),
+	}
+	for _, p := range points {
+		protoRoute.Points = append(protoRoute.Points, &v1.RoutePoint{Latitude: p.Lat, Longitude: p.Lon})
+	}
+
+	return protoRoute, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/route"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// testGPX is a track from Tokyo Station to Tokyo Tower in two segments
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>Morning run</name>
+    <trkseg>
+      <trkpt lat="35.68123" lon="139.76712"><ele>3.2</ele><time>2024-01-15T07:00:00Z</time></trkpt>
+      <trkpt lat="35.67000" lon="139.75500"><ele>5.0</ele><time>2024-01-15T07:10:00Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="35.65858" lon="139.74543"><ele>17.9</ele><time>2024-01-15T07:20:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestExerciseRouteHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	exerciseRepo := repo.NewExerciseRecordRepository(testPool)
+	handler := NewExerciseRecordHandler(exerciseRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+
+	record, err := testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Running", nil, nil, fixedTime.Add(-3*time.Hour), fixedTime)
+	require.NoError(t, err)
+	recordID := record.ID.String()
+
+	t.Run("Attach GPX Route", func(t *testing.T) {
+		resp, err := handler.AttachExerciseRoute(testCtx, connect.NewRequest(&v1.AttachExerciseRouteRequest{
+			ExerciseRecordId: recordID,
+			Track:            &v1.AttachExerciseRouteRequest_Gpx{Gpx: []byte(testGPX)},
+		}))
+		require.NoError(t, err)
+		r := resp.Msg.Route
+		assert.Equal(t, recordID, r.ExerciseRecordId)
+		require.Len(t, r.Points, 3)
+		assert.Equal(t, &v1.RoutePoint{Latitude: 35.68123, Longitude: 139.76712}, r.Points[0])
+		assert.Equal(t, &v1.RoutePoint{Latitude: 35.65858, Longitude: 139.74543}, r.Points[2])
+		// About 3.2 km along the two legs, joined across the segments
+		assert.InDelta(t, 3200, r.DistanceMeters, 50)
+		assert.NotEmpty(t, r.Polyline)
+	})
+
+	t.Run("Get Route", func(t *testing.T) {
+		resp, err := handler.GetExerciseRoute(testCtx, connect.NewRequest(&v1.GetExerciseRouteRequest{ExerciseRecordId: recordID}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Route.Points, 3)
+		points, err := route.DecodePolyline(resp.Msg.Route.Polyline)
+		require.NoError(t, err)
+		assert.Equal(t, route.Point{Lat: 35.67, Lon: 139.755}, points[1])
+	})
+
+	t.Run("Replace With Polyline And Device Distance", func(t *testing.T) {
+		polyline := route.EncodePolyline([]route.Point{{Lat: 38.5, Lon: -120.2}, {Lat: 40.7, Lon: -120.95}})
+		assert.Equal(t, "_p~iF~ps|U_ulLnnqC", polyline)
+		resp, err := handler.AttachExerciseRoute(testCtx, connect.NewRequest(&v1.AttachExerciseRouteRequest{
+			ExerciseRecordId: recordID,
+			Track:            &v1.AttachExerciseRouteRequest_Polyline{Polyline: polyline},
+			DistanceMeters:   wrapperspb.Double(5000),
+		}))
+		require.NoError(t, err)
+		assert.Len(t, resp.Msg.Route.Points, 2)
+		assert.Equal(t, 5000.0, resp.Msg.Route.DistanceMeters)
+
+		got, err := handler.GetExerciseRoute(testCtx, connect.NewRequest(&v1.GetExerciseRouteRequest{ExerciseRecordId: recordID}))
+		require.NoError(t, err)
+		assert.Equal(t, polyline, got.Msg.Route.Polyline)
+	})
+
+	t.Run("Invalid Routes", func(t *testing.T) {
+		for name, req := range map[string]*v1.AttachExerciseRouteRequest{
+			"No Track":        {ExerciseRecordId: recordID},
+			"Invalid GPX":     {ExerciseRecordId: recordID, Track: &v1.AttachExerciseRouteRequest_Gpx{Gpx: []byte("<gpx><trk>")}},
+			"Single Point":    {ExerciseRecordId: recordID, Track: &v1.AttachExerciseRouteRequest_Polyline{Polyline: "_p~iF~ps|U"}},
+			"Truncated":       {ExerciseRecordId: recordID, Track: &v1.AttachExerciseRouteRequest_Polyline{Polyline: "_p~iF~ps|U_ulL"}},
+			"Out Of Range":    {ExerciseRecordId: recordID, Track: &v1.AttachExerciseRouteRequest_Gpx{Gpx: []byte(`<gpx><rte><rtept lat="95" lon="0"/><rtept lat="0" lon="0"/></rte></gpx>`)}},
+			"Too Large":       {ExerciseRecordId: recordID, Track: &v1.AttachExerciseRouteRequest_Gpx{Gpx: []byte(strings.Repeat(" ", maxRouteBytes+1))}},
+			"Negative Length": {ExerciseRecordId: recordID, Track: &v1.AttachExerciseRouteRequest_Polyline{Polyline: "_p~iF~ps|U_ulLnnqC"}, DistanceMeters: wrapperspb.Double(-1)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.AttachExerciseRoute(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Routes Are Private", func(t *testing.T) {
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+
+		_, err = handler.GetExerciseRoute(otherCtx, connect.NewRequest(&v1.GetExerciseRouteRequest{ExerciseRecordId: recordID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.AttachExerciseRoute(otherCtx, connect.NewRequest(&v1.AttachExerciseRouteRequest{
+			ExerciseRecordId: recordID,
+			Track:            &v1.AttachExerciseRouteRequest_Gpx{Gpx: []byte(testGPX)},
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Deleting The Record Deletes Its Route", func(t *testing.T) {
+		require.NoError(t, exerciseRepo.Delete(ctx, record.ID, testUserID, fixedTime))
+		_, err := handler.GetExerciseRoute(testCtx, connect.NewRequest(&v1.GetExerciseRouteRequest{ExerciseRecordId: recordID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+}
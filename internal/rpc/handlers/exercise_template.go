@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// maxExerciseTemplatesPerUser bounds the exercise templates a user can create
+const maxExerciseTemplatesPerUser = 100
+
+// ExerciseTemplateHandler implements the exercise template service RPCs
+type ExerciseTemplateHandler struct {
+	repo    ExerciseTemplateRepository
+	records ExerciseRecordRepository // Stores the records logged from templates
+	log     *slog.Logger
+	clock   clock.Clock
+}
+
+// NewExerciseTemplateHandler creates a new exercise template handler
+func NewExerciseTemplateHandler(repo ExerciseTemplateRepository, records ExerciseRecordRepository, log *slog.Logger, clock clock.Clock) *ExerciseTemplateHandler {
+	return &ExerciseTemplateHandler{
+		repo:    repo,
+		records: records,
+		log:     log,
+		clock:   clock,
+	}
+}
+
+// CreateExerciseTemplate creates an exercise template for the user
+func (h *ExerciseTemplateHandler) CreateExerciseTemplate(ctx context.Context, req *connect.Request[v1.CreateExerciseTemplateRequest]) (*connect.Response[v1.CreateExerciseTemplateResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	fields, err := exerciseTemplateFields(req.Msg.Name, req.Msg.DurationMinutes, req.Msg.CaloriesBurned, req.Msg.Intensity, req.Msg.Favorite)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count exercise templates", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create exercise template"))
+	}
+	if count >= maxExerciseTemplatesPerUser {
+		return nil, apierror.New(connect.CodeResourceExhausted, apierror.ReasonLimitExceeded, fmt.Errorf("too many exercise templates (maximum %d)", maxExerciseTemplatesPerUser))
+	}
+
+	created, err := h.repo.Create(ctx, userID, fields, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrExerciseTemplateExists) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, i18n.NewError(i18n.ExerciseTemplateNameTaken))
+		}
+		h.log.ErrorContext(ctx, "Failed to create exercise template", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create exercise template"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateExerciseTemplateResponse{
+		Template: ToProtoExerciseTemplate(created),
+	})
+
+	return res, nil
+}
+
+// ListExerciseTemplates lists the exercise templates of the user
+func (h *ExerciseTemplateHandler) ListExerciseTemplates(ctx context.Context, req *connect.Request[v1.ListExerciseTemplatesRequest]) (*connect.Response[v1.ListExerciseTemplatesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	templates, err := h.repo.FindByUser(ctx, userID, req.Msg.FavoritesOnly)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list exercise templates", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list exercise templates"))
+	}
+
+	// Create response
+	resp := &v1.ListExerciseTemplatesResponse{
+		Templates: make([]*v1.ExerciseTemplate, 0, len(templates)),
+	}
+	for _, t := range templates {
+		resp.Templates = append(resp.Templates, ToProtoExerciseTemplate(t))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// UpdateExerciseTemplate replaces an exercise template of the user
+func (h *ExerciseTemplateHandler) UpdateExerciseTemplate(ctx context.Context, req *connect.Request[v1.UpdateExerciseTemplateRequest]) (*connect.Response[v1.UpdateExerciseTemplateResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	templateID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid exercise template ID", "templateID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	fields, err := exerciseTemplateFields(req.Msg.Name, req.Msg.DurationMinutes, req.Msg.CaloriesBurned, req.Msg.Intensity, req.Msg.Favorite)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.repo.Update(ctx, templateID, userID, fields, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrExerciseTemplateNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise template not found"))
+		}
+		if errors.Is(err, repo.ErrExerciseTemplateExists) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, i18n.NewError(i18n.ExerciseTemplateNameTaken))
+		}
+		h.log.ErrorContext(ctx, "Failed to update exercise template", "templateID", templateID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update exercise template"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateExerciseTemplateResponse{
+		Template: ToProtoExerciseTemplate(updated),
+	})
+
+	return res, nil
+}
+
+// DeleteExerciseTemplate deletes an exercise template of the user
+func (h *ExerciseTemplateHandler) DeleteExerciseTemplate(ctx context.Context, req *connect.Request[v1.DeleteExerciseTemplateRequest]) (*connect.Response[v1.DeleteExerciseTemplateResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	templateID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid exercise template ID", "templateID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Delete(ctx, templateID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrExerciseTemplateNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise template not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete exercise template", "templateID", templateID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete exercise template"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteExerciseTemplateResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// LogExerciseTemplate creates an exercise record from an exercise template of the user
+func (h *ExerciseTemplateHandler) LogExerciseTemplate(ctx context.Context, req *connect.Request[v1.LogExerciseTemplateRequest]) (*connect.Response[v1.LogExerciseTemplateResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	templateID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid exercise template ID", "templateID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	now := h.clock.Now()
+	recordedAt := now
+	if req.Msg.RecordedAt != nil {
+		recordedAt = req.Msg.RecordedAt.AsTime()
+	}
+	if recordedAt.After(now) {
+		return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("recorded date cannot be in the future"))
+	}
+
+	template, err := h.repo.FindByID(ctx, templateID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrExerciseTemplateNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("exercise template not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to get exercise template", "templateID", templateID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to log exercise template"))
+	}
+
+	var durationMinutes, caloriesBurned *int32
+	if template.DurationMinutes.Valid {
+		durationMinutes = &template.DurationMinutes.Int32
+	}
+	if template.CaloriesBurned.Valid {
+		caloriesBurned = &template.CaloriesBurned.Int32
+	}
+	effort := repo.ExerciseEffort{Intensity: template.Intensity.String}
+
+	record, err := h.records.Create(ctx, userID, template.Name, durationMinutes, caloriesBurned, recordedAt, nil, nil, effort, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create exercise record from template", "templateID", templateID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to log exercise template"))
+	}
+	// The record is created either way; a failure only leaves the usage stats behind
+	if err := h.repo.MarkUsed(ctx, templateID, now); err != nil {
+		h.log.WarnContext(ctx, "Failed to mark exercise template used", "templateID", templateID, "error", err)
+	}
+	h.log.InfoContext(ctx, "Exercise template logged", "templateID", templateID, "exerciseRecordID", record.ID)
+
+	// Create response
+	res := connect.NewResponse(&v1.LogExerciseTemplateResponse{
+		ExerciseRecord: ToProtoExerciseRecord(record),
+	})
+
+	return res, nil
+}
+
+// exerciseTemplateFields validates the fields of a create or update request, applying the
+// limits of exercise records so every template can be logged
+func exerciseTemplateFields(name string, durationMinutes, caloriesBurned *wrapperspb.Int32Value, intensity v1.ExerciseIntensity, favorite bool) (repo.ExerciseTemplateFields, error) {
+	fields := repo.ExerciseTemplateFields{Name: name, Favorite: favorite}
+	if name == "" {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ExerciseNameEmpty))
+	}
+	if len(name) > 100 {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ExerciseNameTooLong))
+	}
+	if durationMinutes != nil {
+		if durationMinutes.Value <= 0 {
+			return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DurationNotPositive))
+		}
+		if durationMinutes.Value > 1440 { // 24 hours in minutes
+			return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DurationTooLong))
+		}
+		fields.DurationMinutes = &durationMinutes.Value
+	}
+	if caloriesBurned != nil {
+		if caloriesBurned.Value < 0 {
+			return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.CaloriesNegative))
+		}
+		if caloriesBurned.Value > 10000 {
+			return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.CaloriesTooLarge))
+		}
+		fields.CaloriesBurned = &caloriesBurned.Value
+	}
+	if intensity != v1.ExerciseIntensity_EXERCISE_INTENSITY_UNSPECIFIED {
+		name, ok := exerciseIntensityNames[intensity]
+		if !ok {
+			return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedIntensity))
+		}
+		fields.Intensity = name
+	}
+	return fields, nil
+}
+
+// ToProtoExerciseTemplate converts a db.ExerciseTemplate to a v1.ExerciseTemplate
+func ToProtoExerciseTemplate(t db.ExerciseTemplate) *v1.ExerciseTemplate {
+	protoTemplate := &v1.ExerciseTemplate{
+		Id:        t.ID.String(),
+		Name:      t.Name,
+		Favorite:  t.Favorite,
+		UseCount:  t.UseCount,
+		CreatedAt: timestamppb.New(t.CreatedAt),
+		UpdatedAt: timestamppb.New(t.UpdatedAt),
+	}
+
+	if t.DurationMinutes.Valid {
+		protoTemplate.DurationMinutes = &wrapperspb.Int32Value{Value: t.DurationMinutes.Int32}
+	}
+	if t.CaloriesBurned.Valid {
+		protoTemplate.CaloriesBurned = &wrapperspb.Int32Value{Value: t.CaloriesBurned.Int32}
+	}
+	for i, name := range exerciseIntensityNames {
+		if t.Intensity.Valid && name == t.Intensity.String {
+			protoTemplate.Intensity = i
+		}
+	}
+	if t.LastUsedAt.Valid {
+		protoTemplate.LastUsedAt = timestamppb.New(t.LastUsedAt.Time)
+	}
+
+	return protoTemplate
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestExerciseTemplateHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	recordRepo := repo.NewExerciseRecordRepository(testPool)
+	handler := NewExerciseTemplateHandler(repo.NewExerciseTemplateRepository(testPool), recordRepo, testLogger, mockClock)
+
+	var runID, yogaID string
+
+	t.Run("Create Templates", func(t *testing.T) {
+		resp, err := handler.CreateExerciseTemplate(testCtx, connect.NewRequest(&v1.CreateExerciseTemplateRequest{
+			Name:            "Morning run",
+			DurationMinutes: wrapperspb.Int32(30),
+			CaloriesBurned:  wrapperspb.Int32(300),
+			Intensity:       v1.ExerciseIntensity_EXERCISE_INTENSITY_VIGOROUS,
+		}))
+		require.NoError(t, err)
+		run := resp.Msg.Template
+		runID = run.Id
+		assert.Equal(t, "Morning run", run.Name)
+		assert.Equal(t, int32(30), run.DurationMinutes.Value)
+		assert.Equal(t, int32(300), run.CaloriesBurned.Value)
+		assert.Equal(t, v1.ExerciseIntensity_EXERCISE_INTENSITY_VIGOROUS, run.Intensity)
+		assert.False(t, run.Favorite)
+		assert.Zero(t, run.UseCount)
+		assert.Nil(t, run.LastUsedAt)
+
+		resp, err = handler.CreateExerciseTemplate(testCtx, connect.NewRequest(&v1.CreateExerciseTemplateRequest{
+			Name: "Yoga",
+		}))
+		require.NoError(t, err)
+		yogaID = resp.Msg.Template.Id
+		assert.Nil(t, resp.Msg.Template.DurationMinutes)
+		assert.Equal(t, v1.ExerciseIntensity_EXERCISE_INTENSITY_UNSPECIFIED, resp.Msg.Template.Intensity)
+	})
+
+	t.Run("Names Are Unique Ignoring Case", func(t *testing.T) {
+		_, err := handler.CreateExerciseTemplate(testCtx, connect.NewRequest(&v1.CreateExerciseTemplateRequest{
+			Name: "morning RUN",
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+
+		_, err = handler.UpdateExerciseTemplate(testCtx, connect.NewRequest(&v1.UpdateExerciseTemplateRequest{
+			Id:   yogaID,
+			Name: "Morning Run",
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateExerciseTemplateRequest{
+			"Empty Name":        {},
+			"Zero Duration":     {Name: "Swim", DurationMinutes: wrapperspb.Int32(0)},
+			"Negative Calories": {Name: "Swim", CaloriesBurned: wrapperspb.Int32(-1)},
+			"Unknown Intensity": {Name: "Swim", Intensity: v1.ExerciseIntensity(42)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.CreateExerciseTemplate(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Log Template", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.Add(time.Hour))
+		resp, err := handler.LogExerciseTemplate(testCtx, connect.NewRequest(&v1.LogExerciseTemplateRequest{
+			Id: runID,
+		}))
+		require.NoError(t, err)
+		record := resp.Msg.ExerciseRecord
+		assert.Equal(t, "Morning run", record.ExerciseName)
+		assert.Equal(t, int32(30), record.DurationMinutes.Value)
+		assert.Equal(t, int32(300), record.CaloriesBurned.Value)
+		assert.Equal(t, v1.ExerciseIntensity_EXERCISE_INTENSITY_VIGOROUS, record.Intensity)
+		assert.Equal(t, fixedTime.Add(time.Hour), record.RecordedAt.AsTime())
+
+		// Backfilled sessions keep their own time
+		yesterday := fixedTime.Add(-24 * time.Hour)
+		resp, err = handler.LogExerciseTemplate(testCtx, connect.NewRequest(&v1.LogExerciseTemplateRequest{
+			Id:         runID,
+			RecordedAt: timestamppb.New(yesterday),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, yesterday, resp.Msg.ExerciseRecord.RecordedAt.AsTime())
+
+		records, err := recordRepo.FindByUser(ctx, testUserID, 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+
+		_, err = handler.LogExerciseTemplate(testCtx, connect.NewRequest(&v1.LogExerciseTemplateRequest{
+			Id:         runID,
+			RecordedAt: timestamppb.New(fixedTime.Add(2 * time.Hour)),
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("List Favorites First", func(t *testing.T) {
+		resp, err := handler.ListExerciseTemplates(testCtx, connect.NewRequest(&v1.ListExerciseTemplatesRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Templates, 2)
+		// The logged template comes before the one never used
+		assert.Equal(t, runID, resp.Msg.Templates[0].Id)
+		assert.Equal(t, int32(2), resp.Msg.Templates[0].UseCount)
+		assert.Equal(t, fixedTime.Add(time.Hour), resp.Msg.Templates[0].LastUsedAt.AsTime())
+
+		updated, err := handler.UpdateExerciseTemplate(testCtx, connect.NewRequest(&v1.UpdateExerciseTemplateRequest{
+			Id:              yogaID,
+			Name:            "Yoga",
+			DurationMinutes: wrapperspb.Int32(45),
+			Favorite:        true,
+		}))
+		require.NoError(t, err)
+		assert.True(t, updated.Msg.Template.Favorite)
+		assert.Equal(t, int32(45), updated.Msg.Template.DurationMinutes.Value)
+
+		resp, err = handler.ListExerciseTemplates(testCtx, connect.NewRequest(&v1.ListExerciseTemplatesRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Templates, 2)
+		assert.Equal(t, yogaID, resp.Msg.Templates[0].Id)
+
+		resp, err = handler.ListExerciseTemplates(testCtx, connect.NewRequest(&v1.ListExerciseTemplatesRequest{FavoritesOnly: true}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Templates, 1)
+		assert.Equal(t, yogaID, resp.Msg.Templates[0].Id)
+	})
+
+	t.Run("Other Users' Templates Are Not Found", func(t *testing.T) {
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+
+		_, err = handler.LogExerciseTemplate(otherCtx, connect.NewRequest(&v1.LogExerciseTemplateRequest{Id: runID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.DeleteExerciseTemplate(otherCtx, connect.NewRequest(&v1.DeleteExerciseTemplateRequest{Id: runID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Delete Keeps Logged Records", func(t *testing.T) {
+		resp, err := handler.DeleteExerciseTemplate(testCtx, connect.NewRequest(&v1.DeleteExerciseTemplateRequest{Id: runID}))
+		require.NoError(t, err)
+		assert.True(t, resp.Msg.Success)
+
+		_, err = handler.LogExerciseTemplate(testCtx, connect.NewRequest(&v1.LogExerciseTemplateRequest{Id: runID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		records, err := recordRepo.FindByUser(ctx, testUserID, 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+	})
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// minFastTargetMinutes and maxFastTargetMinutes bound the target of a fast
+	minFastTargetMinutes = 60
+	maxFastTargetMinutes = 7 * 24 * 60
+)
+
+// FastingHandler implements the fasting service RPCs
+type FastingHandler struct {
+	repo       FastRepository
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewFastingHandler creates a new fasting handler
+func NewFastingHandler(repo FastRepository, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *FastingHandler {
+	return &FastingHandler{
+		repo:       repo,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// StartFast starts a fast of the user, unless it would overlap another of their fasts
+func (h *FastingHandler) StartFast(ctx context.Context, req *connect.Request[v1.StartFastRequest]) (*connect.Response[v1.StartFastResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	now := h.clock.Now()
+	startedAt := now
+	if req.Msg.StartedAt != nil {
+		startedAt = req.Msg.StartedAt.AsTime()
+		if startedAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("started_at cannot be in the future"))
+		}
+	}
+	var targetMinutes *int32
+	if req.Msg.TargetMinutes != 0 {
+		if req.Msg.TargetMinutes < minFastTargetMinutes || req.Msg.TargetMinutes > maxFastTargetMinutes {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("target_minutes must be between %d and %d", minFastTargetMinutes, maxFastTargetMinutes))
+		}
+		targetMinutes = &req.Msg.TargetMinutes
+	}
+
+	h.log.InfoContext(ctx, "Starting fast", "startedAt", startedAt)
+	fast, err := h.repo.Start(ctx, userID, startedAt, targetMinutes, now)
+	if err != nil {
+		if errors.Is(err, repo.ErrFastOverlaps) {
+			return nil, apierror.New(connect.CodeFailedPrecondition, apierror.ReasonOverlappingFast, errors.New("a fast is running or ended after started_at"))
+		}
+		h.log.ErrorContext(ctx, "Failed to start fast", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to start fast"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.StartFastResponse{
+		Fast: ToProtoFast(fast, now),
+	})
+
+	return res, nil
+}
+
+// EndFast ends the running fast of the user
+func (h *FastingHandler) EndFast(ctx context.Context, req *connect.Request[v1.EndFastRequest]) (*connect.Response[v1.EndFastResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	now := h.clock.Now()
+	endedAt := now
+	if req.Msg.EndedAt != nil {
+		endedAt = req.Msg.EndedAt.AsTime()
+		if endedAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("ended_at cannot be in the future"))
+		}
+	}
+
+	fast, err := h.repo.End(ctx, userID, endedAt, now)
+	if err != nil {
+		if errors.Is(err, repo.ErrFastNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("no fast is running"))
+		}
+		if errors.Is(err, repo.ErrFastEndsBeforeStart) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("ended_at must be after the start of the fast"))
+		}
+		h.log.ErrorContext(ctx, "Failed to end fast", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to end fast"))
+	}
+	h.log.InfoContext(ctx, "Fast ended", "fastID", fast.ID)
+
+	// Create response
+	res := connect.NewResponse(&v1.EndFastResponse{
+		Fast: ToProtoFast(fast, now),
+	})
+
+	return res, nil
+}
+
+// GetFastingStatus returns the running fast of the user and their fasting streaks
+func (h *FastingHandler) GetFastingStatus(ctx context.Context, req *connect.Request[v1.GetFastingStatusRequest]) (*connect.Response[v1.GetFastingStatusResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	now := h.clock.Now()
+	resp := &v1.GetFastingStatusResponse{}
+	running, err := h.repo.FindRunning(ctx, userID)
+	switch {
+	case err == nil:
+		resp.CurrentFast = ToProtoFast(running, now)
+	case !errors.Is(err, repo.ErrFastNotFound):
+		h.log.ErrorContext(ctx, "Failed to get running fast", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get fasting status"))
+	}
+
+	streak, err := h.repo.Streak(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get fasting streak", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get fasting status"))
+	}
+	// Like logging streaks, a streak that reached yesterday is still running
+	today := now.UTC().Truncate(24 * time.Hour)
+	if streak.LastFastedOn.Valid && !streak.LastFastedOn.Time.Before(today.AddDate(0, 0, -1)) {
+		resp.CurrentStreakDays = streak.CurrentLength
+	}
+	resp.LongestStreakDays = streak.LongestLength
+
+	return connect.NewResponse(resp), nil
+}
+
+// ListFasts lists the fasts of the user, newest first
+func (h *FastingHandler) ListFasts(ctx context.Context, req *connect.Request[v1.ListFastsRequest]) (*connect.Response[v1.ListFastsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	fasts, err := h.repo.FindByUser(ctx, userID, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch fasts", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch fasts"))
+	}
+
+	total, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count fasts", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count fasts"))
+	}
+
+	now := h.clock.Now()
+	protoFasts := make([]*v1.Fast, len(fasts))
+	for i, fast := range fasts {
+		protoFasts[i] = ToProtoFast(fast, now)
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListFastsResponse{
+		Fasts:      protoFasts,
+		Pagination: page.Response(total),
+	})
+
+	return res, nil
+}
+
+// DeleteFast deletes a fast of the user
+func (h *FastingHandler) DeleteFast(ctx context.Context, req *connect.Request[v1.DeleteFastRequest]) (*connect.Response[v1.DeleteFastResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	fastID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid fast ID", "fastID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Delete(ctx, fastID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrFastNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("fast not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete fast", "fastID", fastID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete fast"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteFastResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// ToProtoFast converts a db.Fast to a v1.Fast; running fasts last until now
+func ToProtoFast(f db.Fast, now time.Time) *v1.Fast {
+	end := now
+	if f.EndedAt.Valid {
+		end = f.EndedAt.Time
+	}
+	duration := end.Sub(f.StartedAt)
+
+	protoFast := &v1.Fast{
+		Id:              f.ID.String(),
+		StartedAt:       timestamppb.New(f.StartedAt),
+		DurationMinutes: int32(duration / time.Minute),
+		CreatedAt:       timestamppb.New(f.CreatedAt),
+		UpdatedAt:       timestamppb.New(f.UpdatedAt),
+	}
+	if f.EndedAt.Valid {
+		protoFast.EndedAt = timestamppb.New(f.EndedAt.Time)
+	}
+	if f.TargetMinutes.Valid {
+		protoFast.TargetMinutes = wrapperspb.Int32(f.TargetMinutes.Int32)
+		protoFast.TargetReached = duration >= time.Duration(f.TargetMinutes.Int32)*time.Minute
+	}
+	return protoFast
+}
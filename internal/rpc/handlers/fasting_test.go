@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFastingHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	handler := NewFastingHandler(repo.NewFastRepository(testPool), DefaultPageLimits, testLogger, mockClock)
+
+	var firstFastID string
+
+	t.Run("Start Fast", func(t *testing.T) {
+		resp, err := handler.StartFast(testCtx, connect.NewRequest(&v1.StartFastRequest{
+			StartedAt:     timestamppb.New(fixedTime.Add(-20 * time.Hour)),
+			TargetMinutes: 16 * 60,
+		}))
+		require.NoError(t, err)
+		fast := resp.Msg.Fast
+		firstFastID = fast.Id
+		assert.Nil(t, fast.EndedAt)
+		assert.Equal(t, int32(16*60), fast.TargetMinutes.GetValue())
+		assert.Equal(t, int32(20*60), fast.DurationMinutes)
+		assert.True(t, fast.TargetReached)
+
+		// Only one fast can run at a time
+		_, err = handler.StartFast(testCtx, connect.NewRequest(&v1.StartFastRequest{}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("Status Of Running Fast", func(t *testing.T) {
+		resp, err := handler.GetFastingStatus(testCtx, connect.NewRequest(&v1.GetFastingStatusRequest{}))
+		require.NoError(t, err)
+		require.NotNil(t, resp.Msg.CurrentFast)
+		assert.Equal(t, firstFastID, resp.Msg.CurrentFast.Id)
+		assert.Equal(t, int32(0), resp.Msg.CurrentStreakDays)
+		assert.Equal(t, int32(0), resp.Msg.LongestStreakDays)
+	})
+
+	t.Run("End Fast", func(t *testing.T) {
+		_, err := handler.EndFast(testCtx, connect.NewRequest(&v1.EndFastRequest{
+			EndedAt: timestamppb.New(fixedTime.Add(-21 * time.Hour)),
+		}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+		resp, err := handler.EndFast(testCtx, connect.NewRequest(&v1.EndFastRequest{}))
+		require.NoError(t, err)
+		require.NotNil(t, resp.Msg.Fast.EndedAt)
+		assert.True(t, fixedTime.Equal(resp.Msg.Fast.EndedAt.AsTime()))
+		assert.Equal(t, int32(20*60), resp.Msg.Fast.DurationMinutes)
+
+		_, err = handler.EndFast(testCtx, connect.NewRequest(&v1.EndFastRequest{}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		statusResp, err := handler.GetFastingStatus(testCtx, connect.NewRequest(&v1.GetFastingStatusRequest{}))
+		require.NoError(t, err)
+		assert.Nil(t, statusResp.Msg.CurrentFast)
+		assert.Equal(t, int32(1), statusResp.Msg.CurrentStreakDays)
+		assert.Equal(t, int32(1), statusResp.Msg.LongestStreakDays)
+	})
+
+	t.Run("Fasts Cannot Overlap", func(t *testing.T) {
+		_, err := handler.StartFast(testCtx, connect.NewRequest(&v1.StartFastRequest{
+			StartedAt: timestamppb.New(fixedTime.Add(-time.Hour)),
+		}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("Missed Target Keeps Streak", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 1))
+		_, err := handler.StartFast(testCtx, connect.NewRequest(&v1.StartFastRequest{TargetMinutes: 60}))
+		require.NoError(t, err)
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 1).Add(30 * time.Minute))
+		resp, err := handler.EndFast(testCtx, connect.NewRequest(&v1.EndFastRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(30), resp.Msg.Fast.DurationMinutes)
+		assert.False(t, resp.Msg.Fast.TargetReached)
+
+		// The streak that reached yesterday still counts today
+		statusResp, err := handler.GetFastingStatus(testCtx, connect.NewRequest(&v1.GetFastingStatusRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), statusResp.Msg.CurrentStreakDays)
+
+		// ...but not the day after
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 2))
+		statusResp, err = handler.GetFastingStatus(testCtx, connect.NewRequest(&v1.GetFastingStatusRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), statusResp.Msg.CurrentStreakDays)
+		assert.Equal(t, int32(1), statusResp.Msg.LongestStreakDays)
+	})
+
+	t.Run("List Fasts", func(t *testing.T) {
+		resp, err := handler.ListFasts(testCtx, connect.NewRequest(&v1.ListFastsRequest{
+			Pagination: &v1.PageRequest{PageSize: 1, PageNumber: 1},
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Fasts, 1)
+		assert.NotEqual(t, firstFastID, resp.Msg.Fasts[0].Id)
+		assert.Equal(t, int32(2), resp.Msg.Pagination.TotalItems)
+		assert.Equal(t, int32(2), resp.Msg.Pagination.TotalPages)
+
+		resp, err = handler.ListFasts(testCtx, connect.NewRequest(&v1.ListFastsRequest{
+			Pagination: &v1.PageRequest{PageSize: 1, PageNumber: 2},
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Fasts, 1)
+		assert.Equal(t, firstFastID, resp.Msg.Fasts[0].Id)
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		now := mockClock.Now()
+		for _, req := range []*v1.StartFastRequest{
+			{TargetMinutes: 30},
+			{TargetMinutes: 8 * 24 * 60},
+			{StartedAt: timestamppb.New(now.Add(time.Hour))},
+		} {
+			_, err := handler.StartFast(testCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+
+		_, err := handler.EndFast(testCtx, connect.NewRequest(&v1.EndFastRequest{EndedAt: timestamppb.New(now.Add(time.Hour))}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.DeleteFast(testCtx, connect.NewRequest(&v1.DeleteFastRequest{Id: "not-a-uuid"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Delete Fast", func(t *testing.T) {
+		_, err := handler.DeleteFast(testCtx, connect.NewRequest(&v1.DeleteFastRequest{Id: firstFastID}))
+		require.NoError(t, err)
+		_, err = handler.DeleteFast(testCtx, connect.NewRequest(&v1.DeleteFastRequest{Id: firstFastID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		resp, err := handler.GetFastingStatus(testCtx, connect.NewRequest(&v1.GetFastingStatusRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), resp.Msg.LongestStreakDays)
+	})
+}
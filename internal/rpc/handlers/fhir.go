@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/fhir"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+)
+
+const (
+	// fhirExportMaxDays caps the number of days covered by a single export
+	fhirExportMaxDays = 366
+	// fhirStreamBatchSize is the number of records read, and sent as a chunk, at a time by
+	// streamed exports
+	fhirStreamBatchSize = 500
+)
+
+// FHIRHandler implements the FHIR export RPCs
+type FHIRHandler struct {
+	bodyRecords BodyRecordRepository
+	stepRecords StepRecordRepository
+	log         *slog.Logger
+	clock       clock.Clock
+}
+
+// NewFHIRHandler creates a new FHIR export handler
+func NewFHIRHandler(bodyRecords BodyRecordRepository, stepRecords StepRecordRepository, log *slog.Logger, clock clock.Clock) *FHIRHandler {
+	return &FHIRHandler{
+		bodyRecords: bodyRecords,
+		stepRecords: stepRecords,
+		log:         log,
+		clock:       clock,
+	}
+}
+
+// ExportObservations exports the user's body records and daily steps as FHIR Observations
+func (h *FHIRHandler) ExportObservations(ctx context.Context, req *connect.Request[v1.ExportObservationsRequest]) (*connect.Response[v1.ExportObservationsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	startDate, err := reqparse.ParseDate("start_date", req.Msg.StartDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	endDate, err := reqparse.ParseDate("end_date", req.Msg.EndDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	if endDate.Before(startDate) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+	if endDate.Sub(startDate) > fhirExportMaxDays*24*time.Hour {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("export range exceeds maximum allowed length (%d days)", fhirExportMaxDays))
+	}
+
+	h.log.InfoContext(ctx, "Exporting FHIR observations", "startDate", startDate, "endDate", endDate)
+	bodyRecords, err := h.bodyRecords.FindByUserAndDateRange(ctx, userID, startDate, endDate)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch body records for FHIR export", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to export observations"))
+	}
+	stepRecords, err := h.stepRecords.FindByUserAndDateRange(ctx, userID, startDate, endDate)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch step records for FHIR export", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to export observations"))
+	}
+
+	observations := make([]fhir.Observation, 0, 2*len(bodyRecords)+len(stepRecords))
+	for _, record := range bodyRecords {
+		observations = append(observations, ToFHIRBodyObservations(record)...)
+	}
+	for _, record := range stepRecords {
+		observations = append(observations, ToFHIRStepObservation(record))
+	}
+
+	bundle, err := json.Marshal(fhir.NewSearchSetBundle(observations, h.clock.Now()))
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to encode FHIR bundle", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to export observations"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ExportObservationsResponse{
+		Bundle: string(bundle),
+	})
+
+	return res, nil
+}
+
+// StreamObservations streams the user's body records and daily steps as FHIR Observations, a
+// chunk per batch of records read, so neither end holds more than a batch in memory
+func (h *FHIRHandler) StreamObservations(ctx context.Context, req *connect.Request[v1.StreamObservationsRequest], stream *connect.ServerStream[v1.StreamObservationsResponse]) error {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	startDate, err := reqparse.ParseDate("start_date", req.Msg.StartDate)
+	if err != nil {
+		return reqparse.Error(err)
+	}
+	endDate, err := reqparse.ParseDate("end_date", req.Msg.EndDate)
+	if err != nil {
+		return reqparse.Error(err)
+	}
+	if endDate.Before(startDate) {
+		return connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+
+	h.log.InfoContext(ctx, "Streaming FHIR observations", "startDate", startDate, "endDate", endDate)
+	err = h.bodyRecords.EachBatchByUserAndDateRange(ctx, userID, startDate, endDate, fhirStreamBatchSize, func(records []db.BodyRecord) error {
+		observations := make([]fhir.Observation, 0, 2*len(records))
+		for _, record := range records {
+			observations = append(observations, ToFHIRBodyObservations(record)...)
+		}
+		return sendObservations(stream, observations)
+	})
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to stream body records for FHIR export", "error", err)
+		return connect.NewError(connect.CodeInternal, errors.New("failed to export observations"))
+	}
+	err = h.stepRecords.EachBatchByUserAndDateRange(ctx, userID, startDate, endDate, fhirStreamBatchSize, func(records []db.StepRecord) error {
+		observations := make([]fhir.Observation, 0, len(records))
+		for _, record := range records {
+			observations = append(observations, ToFHIRStepObservation(record))
+		}
+		return sendObservations(stream, observations)
+	})
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to stream step records for FHIR export", "error", err)
+		return connect.NewError(connect.CodeInternal, errors.New("failed to export observations"))
+	}
+	return nil
+}
+
+// sendObservations sends observations as a chunk of NDJSON; batches of records without
+// measurements send nothing
+func sendObservations(stream *connect.ServerStream[v1.StreamObservationsResponse], observations []fhir.Observation) error {
+	if len(observations) == 0 {
+		return nil
+	}
+	var chunk bytes.Buffer
+	enc := json.NewEncoder(&chunk) // Encode ends every resource with a newline
+	for _, observation := range observations {
+		if err := enc.Encode(observation); err != nil {
+			return fmt.Errorf("failed to encode FHIR observation: %w", err)
+		}
+	}
+	return stream.Send(&v1.StreamObservationsResponse{Observations: chunk.String()})
+}
+
+// ToFHIRBodyObservations converts a db.BodyRecord to one Observation per recorded measurement
+func ToFHIRBodyObservations(record db.BodyRecord) []fhir.Observation {
+	var observations []fhir.Observation
+	if w, err := record.WeightKg.Float64Value(); err == nil && w.Valid {
+		observations = append(observations, fhir.NewObservation(
+			fhirObservationID(record.ID, "weight"), record.UserID.String(), fhir.BodyWeight, record.Date.Time, w.Float64, record.UpdatedAt))
+	}
+	if bf, err := record.BodyFatPercentage.Float64Value(); err == nil && bf.Valid {
+		observations = append(observations, fhir.NewObservation(
+			fhirObservationID(record.ID, "body-fat"), record.UserID.String(), fhir.BodyFatPercentage, record.Date.Time, bf.Float64, record.UpdatedAt))
+	}
+	return observations
+}
+
+// ToFHIRStepObservation converts a db.StepRecord to a daily steps Observation
+func ToFHIRStepObservation(record db.StepRecord) fhir.Observation {
+	return fhir.NewObservation(
+		fhirObservationID(record.ID, "steps"), record.UserID.String(), fhir.DailySteps, record.Date.Time, float64(record.Steps), record.UpdatedAt)
+}
+
+// fhirObservationID derives a stable observation ID from the record ID and the measurement
+func fhirObservationID(recordID uuid.UUID, measurement string) string {
+	return recordID.String() + "-" + measurement
+}
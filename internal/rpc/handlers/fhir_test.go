@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportObservations(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewFHIRHandler(repo.NewBodyRecordRepository(testPool), repo.NewStepRecordRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	// Setup: a weight-only record, a record with both measurements and a day of steps
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	today := fixedTime.Truncate(24 * time.Hour)
+	weight1, weight2, bodyFat := 75.5, 76.0, 15.5
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today, &weight1, nil, fixedTime)
+	require.NoError(t, err)
+	withBodyFat, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.Add(-24*time.Hour), &weight2, &bodyFat, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.Add(-30*24*time.Hour), &weight2, nil, fixedTime) // Outside the range
+	require.NoError(t, err)
+	_, err = repo.NewImportRepository(testPool).ImportBatch(ctx, testUserID, "fitbit", repo.ImportBatch{
+		DailySteps: []repo.ImportedDailySteps{{Date: today, Steps: 9120}},
+	}, fixedTime)
+	require.NoError(t, err)
+
+	resp, err := handler.ExportObservations(testCtx, connect.NewRequest(&v1.ExportObservationsRequest{
+		StartDate: "2024-01-08",
+		EndDate:   "2024-01-15",
+	}))
+	require.NoError(t, err)
+
+	var bundle struct {
+		ResourceType string `json:"resourceType"`
+		Type         string `json:"type"`
+		Total        int    `json:"total"`
+		Entry        []struct {
+			Resource struct {
+				ResourceType string `json:"resourceType"`
+				ID           string `json:"id"`
+				Status       string `json:"status"`
+				Code         struct {
+					Coding []struct {
+						System string `json:"system"`
+						Code   string `json:"code"`
+					} `json:"coding"`
+				} `json:"code"`
+				Subject struct {
+					Reference string `json:"reference"`
+				} `json:"subject"`
+				EffectiveDateTime string `json:"effectiveDateTime"`
+				ValueQuantity     struct {
+					Value float64 `json:"value"`
+					Code  string  `json:"code"`
+				} `json:"valueQuantity"`
+			} `json:"resource"`
+		} `json:"entry"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resp.Msg.Bundle), &bundle))
+	assert.Equal(t, "Bundle", bundle.ResourceType)
+	assert.Equal(t, "searchset", bundle.Type)
+	require.Equal(t, 4, bundle.Total)
+	require.Len(t, bundle.Entry, 4)
+
+	type observation struct {
+		code, date, unit string
+		value            float64
+	}
+	var got []observation
+	for _, e := range bundle.Entry {
+		r := e.Resource
+		assert.Equal(t, "Observation", r.ResourceType)
+		assert.Equal(t, "final", r.Status)
+		assert.Equal(t, "Patient/"+testUserID.String(), r.Subject.Reference)
+		require.Len(t, r.Code.Coding, 1)
+		assert.Equal(t, "http://loinc.org", r.Code.Coding[0].System)
+		got = append(got, observation{r.Code.Coding[0].Code, r.EffectiveDateTime, r.ValueQuantity.Code, r.ValueQuantity.Value})
+	}
+	assert.ElementsMatch(t, []observation{
+		{"29463-7", "2024-01-15", "kg", 75.5},
+		{"29463-7", "2024-01-14", "kg", 76.0},
+		{"41982-0", "2024-01-14", "%", 15.5},
+		{"41950-7", "2024-01-15", "{steps}", 9120},
+	}, got)
+	assert.Contains(t, resp.Msg.Bundle, withBodyFat.ID.String()+"-body-fat")
+
+	// Invalid ranges are rejected
+	for _, req := range []*v1.ExportObservationsRequest{
+		{StartDate: "2024-01-15"},
+		{StartDate: "2024-01-15", EndDate: "2024-01-14"},
+		{StartDate: "2023-01-01", EndDate: "2024-01-15"},
+	} {
+		_, err := handler.ExportObservations(testCtx, connect.NewRequest(req))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	}
+}
+
+func TestStreamObservations(t *testing.T) {
+	resetDB(t, testPool)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	handler := NewFHIRHandler(bodyRecordRepo, repo.NewStepRecordRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+
+	// Streams bypass unary interceptors, so the test user is set by a check
+	withTestUser := interceptor.New(func(ctx context.Context, procedure string, header http.Header) (context.Context, error) {
+		return newTestContext(ctx), nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewFHIRServiceHandler(handler, connect.WithInterceptors(withTestUser)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := healthappv1connect.NewFHIRServiceClient(server.Client(), server.URL)
+
+	// Setup: three days of weights, the oldest years before the others, and a day of steps
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	today := fixedTime.Truncate(24 * time.Hour)
+	weight := 75.5
+	for _, date := range []time.Time{today.AddDate(-3, 0, 0), today.AddDate(0, 0, -1), today} {
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, date, &weight, nil, fixedTime)
+		require.NoError(t, err)
+	}
+	_, err := repo.NewImportRepository(testPool).ImportBatch(ctx, testUserID, "fitbit", repo.ImportBatch{
+		DailySteps: []repo.ImportedDailySteps{{Date: today, Steps: 9120}},
+	}, fixedTime)
+	require.NoError(t, err)
+
+	t.Run("Chunks", func(t *testing.T) {
+		res, err := client.StreamObservations(ctx, connect.NewRequest(&v1.StreamObservationsRequest{
+			StartDate: "2020-01-01",
+			EndDate:   "2024-01-15",
+		}))
+		require.NoError(t, err)
+		defer res.Close()
+
+		// The range is longer than a single export allows; every line is an observation
+		type observation struct {
+			ResourceType      string `json:"resourceType"`
+			EffectiveDateTime string `json:"effectiveDateTime"`
+		}
+		var got []observation
+		for res.Receive() {
+			chunk := res.Msg().Observations
+			require.True(t, strings.HasSuffix(chunk, "\n"), "chunks end with a newline")
+			for _, line := range strings.Split(strings.TrimSuffix(chunk, "\n"), "\n") {
+				var o observation
+				require.NoError(t, json.Unmarshal([]byte(line), &o))
+				assert.Equal(t, "Observation", o.ResourceType)
+				got = append(got, o)
+			}
+		}
+		require.NoError(t, res.Err())
+		// Body measurements come oldest first, then the daily steps
+		require.Len(t, got, 4)
+		assert.Equal(t, []string{"2021-01-15", "2024-01-14", "2024-01-15", "2024-01-15"},
+			[]string{got[0].EffectiveDateTime, got[1].EffectiveDateTime, got[2].EffectiveDateTime, got[3].EffectiveDateTime})
+	})
+
+	t.Run("Batches", func(t *testing.T) {
+		var batches [][]string
+		err := bodyRecordRepo.EachBatchByUserAndDateRange(ctx, testUserID, today.AddDate(-5, 0, 0), today, 2, func(records []db.BodyRecord) error {
+			var dates []string
+			for _, record := range records {
+				dates = append(dates, record.Date.Time.Format("2006-01-02"))
+			}
+			batches = append(batches, dates)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"2021-01-15", "2024-01-14"}, {"2024-01-15"}}, batches)
+	})
+
+	t.Run("Error - Invalid Range", func(t *testing.T) {
+		res, err := client.StreamObservations(ctx, connect.NewRequest(&v1.StreamObservationsRequest{
+			StartDate: "2024-01-15",
+			EndDate:   "2024-01-14",
+		}))
+		require.NoError(t, err)
+		defer res.Close()
+		assert.False(t, res.Receive())
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(res.Err()))
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/food"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// defaultFoodSearchLimit is how many foods a search returns unless requested otherwise
+	defaultFoodSearchLimit = 10
+	// maxFoodSearchLimit is the most foods a search returns
+	maxFoodSearchLimit = 25
+)
+
+// FoodLookupHandler implements the food lookup service RPCs
+type FoodLookupHandler struct {
+	db  food.Database
+	log *slog.Logger
+}
+
+// NewFoodLookupHandler creates a new food lookup handler searching db
+func NewFoodLookupHandler(db food.Database, log *slog.Logger) *FoodLookupHandler {
+	return &FoodLookupHandler{
+		db:  db,
+		log: log,
+	}
+}
+
+// SearchFoods searches foods by name
+func (h *FoodLookupHandler) SearchFoods(ctx context.Context, req *connect.Request[v1.SearchFoodsRequest]) (*connect.Response[v1.SearchFoodsResponse], error) {
+	// Validate input
+	query := strings.TrimSpace(req.Msg.Query)
+	if length := utf8.RuneCountInString(query); length < 2 || length > 100 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.FoodQueryLength))
+	}
+	limit := int(req.Msg.Limit)
+	if limit == 0 {
+		limit = defaultFoodSearchLimit
+	}
+	if limit < 1 || limit > maxFoodSearchLimit {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.FoodSearchLimitOutOfRange, maxFoodSearchLimit))
+	}
+
+	foods, err := h.db.Search(ctx, query, limit)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to search food database", "query", query, "error", err)
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("food database unavailable"))
+	}
+
+	// Create response
+	protoFoods := make([]*v1.Food, len(foods))
+	for i, f := range foods {
+		protoFoods[i] = toProtoFood(f)
+	}
+	res := connect.NewResponse(&v1.SearchFoodsResponse{
+		Foods: protoFoods,
+	})
+
+	return res, nil
+}
+
+// LookupFoodByBarcode looks up a packaged food by its barcode
+func (h *FoodLookupHandler) LookupFoodByBarcode(ctx context.Context, req *connect.Request[v1.LookupFoodByBarcodeRequest]) (*connect.Response[v1.LookupFoodByBarcodeResponse], error) {
+	// Validate input
+	barcode := req.Msg.Barcode
+	if len(barcode) < 8 || len(barcode) > 14 || strings.Trim(barcode, "0123456789") != "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.BarcodeInvalid))
+	}
+
+	f, err := h.db.LookupBarcode(ctx, barcode)
+	if err != nil {
+		if errors.Is(err, food.ErrNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, err)
+		}
+		h.log.ErrorContext(ctx, "Failed to look up barcode in food database", "barcode", barcode, "error", err)
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("food database unavailable"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.LookupFoodByBarcodeResponse{
+		Food: toProtoFood(f),
+	})
+
+	return res, nil
+}
+
+// toProtoFood converts a food.Food to a v1.Food
+func toProtoFood(f food.Food) *v1.Food {
+	protoFood := &v1.Food{
+		Id:             f.ID,
+		Source:         f.Source,
+		Name:           f.Name,
+		Brand:          f.Brand,
+		Barcode:        f.Barcode,
+		CaloriesKcal:   optionalDouble(f.CaloriesKcal),
+		ProteinG:       optionalDouble(f.ProteinG),
+		CarbohydratesG: optionalDouble(f.CarbohydratesG),
+		FatG:           optionalDouble(f.FatG),
+		ServingSizeG:   optionalDouble(f.ServingSizeG),
+	}
+	if f.CaloriesKcal != nil && f.ServingSizeG != nil {
+		protoFood.ServingCalories = wrapperspb.Int32(int32(math.Round(*f.CaloriesKcal * *f.ServingSizeG / 100)))
+	}
+	return protoFood
+}
+
+// optionalDouble converts an optional value to a wrapper, nil if unset
+func optionalDouble(v *float64) *wrapperspb.DoubleValue {
+	if v == nil {
+		return nil
+	}
+	return wrapperspb.Double(*v)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/food"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoodLookup(t *testing.T) {
+	// Fake Open Food Facts API, failing while failing is set
+	var requests atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case failing.Load():
+			http.Error(w, "upstream down", http.StatusInternalServerError)
+		case r.URL.Path == "/cgi/search.pl":
+			assert.Equal(t, "rolled oats", r.URL.Query().Get("search_terms"))
+			assert.Equal(t, "health-management-api/test", r.Header.Get("User-Agent"))
+			w.Write([]byte(`{"products": [
+				{"code": "5000000000001", "product_name": "Rolled Oats", "brands": "Mill Co, Other Co", "nutriments": {"energy-kcal_100g": 375, "proteins_100g": "13.5", "carbohydrates_100g": 60, "fat_100g": 7}, "serving_quantity": "40"},
+				{"code": "5000000000002", "product_name": ""},
+				{"code": "5000000000003", "product_name": "Oat Drink", "nutriments": {"energy-kcal_100g": 46}}
+			]}`))
+		case r.URL.Path == "/api/v2/product/5000000000001.json":
+			w.Write([]byte(`{"status": 1, "product": {"code": "5000000000001", "product_name": "Rolled Oats", "brands": "Mill Co", "nutriments": {"energy-kcal_100g": 375}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 0, "status_verbose": "product not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	cache := food.NewCache(food.NewOpenFoodFacts(server.URL, "health-management-api/test", time.Second), 100, time.Hour, mockClock)
+	handler := NewFoodLookupHandler(cache, testLogger)
+	ctx := newTestContext(context.Background())
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	t.Run("Search Foods", func(t *testing.T) {
+		resp, err := handler.SearchFoods(ctx, connect.NewRequest(&v1.SearchFoodsRequest{Query: "  rolled oats "}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Foods, 2)
+
+		oats := resp.Msg.Foods[0]
+		assert.Equal(t, "5000000000001", oats.Id)
+		assert.Equal(t, food.SourceOpenFoodFacts, oats.Source)
+		assert.Equal(t, "Rolled Oats", oats.Name)
+		assert.Equal(t, "Mill Co", oats.Brand)
+		assert.Equal(t, "5000000000001", oats.Barcode)
+		assert.Equal(t, 375.0, oats.CaloriesKcal.GetValue())
+		assert.Equal(t, 13.5, oats.ProteinG.GetValue())
+		assert.Equal(t, 60.0, oats.CarbohydratesG.GetValue())
+		assert.Equal(t, 7.0, oats.FatG.GetValue())
+		assert.Equal(t, 40.0, oats.ServingSizeG.GetValue())
+		assert.Equal(t, int32(150), oats.ServingCalories.GetValue())
+
+		drink := resp.Msg.Foods[1]
+		assert.Equal(t, "Oat Drink", drink.Name)
+		assert.Equal(t, 46.0, drink.CaloriesKcal.GetValue())
+		assert.Nil(t, drink.ProteinG)
+		assert.Nil(t, drink.ServingSizeG)
+		assert.Nil(t, drink.ServingCalories)
+	})
+
+	t.Run("Search Results Are Cached", func(t *testing.T) {
+		before := requests.Load()
+		resp, err := handler.SearchFoods(ctx, connect.NewRequest(&v1.SearchFoodsRequest{Query: "Rolled  OATS"}))
+		require.NoError(t, err)
+		assert.Len(t, resp.Msg.Foods, 2)
+		assert.Equal(t, before, requests.Load())
+
+		// Expired responses are fetched again
+		mockClock.SetTime(fixedTime.Add(time.Hour))
+		defer mockClock.SetTime(fixedTime)
+		_, err = handler.SearchFoods(ctx, connect.NewRequest(&v1.SearchFoodsRequest{Query: "rolled oats"}))
+		require.NoError(t, err)
+		assert.Equal(t, before+1, requests.Load())
+	})
+
+	t.Run("Lookup Barcode", func(t *testing.T) {
+		resp, err := handler.LookupFoodByBarcode(ctx, connect.NewRequest(&v1.LookupFoodByBarcodeRequest{Barcode: "5000000000001"}))
+		require.NoError(t, err)
+		assert.Equal(t, "Rolled Oats", resp.Msg.Food.Name)
+		assert.Equal(t, 375.0, resp.Msg.Food.CaloriesKcal.GetValue())
+	})
+
+	t.Run("Unknown Barcode", func(t *testing.T) {
+		_, err := handler.LookupFoodByBarcode(ctx, connect.NewRequest(&v1.LookupFoodByBarcodeRequest{Barcode: "12345678"}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		// Unknown barcodes are cached too
+		before := requests.Load()
+		_, err = handler.LookupFoodByBarcode(ctx, connect.NewRequest(&v1.LookupFoodByBarcodeRequest{Barcode: "12345678"}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		assert.Equal(t, before, requests.Load())
+	})
+
+	t.Run("Database Unavailable", func(t *testing.T) {
+		failing.Store(true)
+		defer failing.Store(false)
+
+		_, err := handler.SearchFoods(ctx, connect.NewRequest(&v1.SearchFoodsRequest{Query: "banana"}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+
+		_, err = handler.LookupFoodByBarcode(ctx, connect.NewRequest(&v1.LookupFoodByBarcodeRequest{Barcode: "87654321"}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+
+		// Failures are not cached
+		failing.Store(false)
+		_, err = handler.LookupFoodByBarcode(ctx, connect.NewRequest(&v1.LookupFoodByBarcodeRequest{Barcode: "87654321"}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		for _, req := range []*v1.SearchFoodsRequest{
+			{Query: " a "},
+			{Query: "oats", Limit: 26},
+			{Query: "oats", Limit: -1},
+		} {
+			_, err := handler.SearchFoods(ctx, connect.NewRequest(req))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+		for _, barcode := range []string{"", "1234567", "123456789012345", "12345678a"} {
+			_, err := handler.LookupFoodByBarcode(ctx, connect.NewRequest(&v1.LookupFoodByBarcodeRequest{Barcode: barcode}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/jackc/pgx/v5/pgtype"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// GoalHandler implements the goal service RPCs
+type GoalHandler struct {
+	repo  GoalRepository
+	prefs PreferenceRepository // Units the target weight is shown in
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewGoalHandler creates a new goal handler
+func NewGoalHandler(repo GoalRepository, prefs PreferenceRepository, log *slog.Logger, clock clock.Clock) *GoalHandler {
+	return &GoalHandler{
+		repo:  repo,
+		prefs: prefs,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// GetGoals returns the user's goals; users who never set goals get empty goals
+func (h *GoalHandler) GetGoals(ctx context.Context, req *connect.Request[v1.GetGoalsRequest]) (*connect.Response[v1.GetGoalsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	goals, err := h.repo.FindByUser(ctx, userID)
+	if err != nil && !errors.Is(err, repo.ErrGoalsNotFound) {
+		h.log.ErrorContext(ctx, "Failed to get goals", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get goals"))
+	}
+	unit, err := preferredWeightUnit(ctx, h.prefs, h.log, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create response
+	protoGoals := ToProtoGoals(goals)
+	protoGoals.TargetWeight = toProtoWeight(protoGoals.TargetWeightKg, unit)
+	res := connect.NewResponse(&v1.GetGoalsResponse{
+		Goals: protoGoals,
+	})
+
+	return res, nil
+}
+
+// UpdateGoals replaces the user's goals
+func (h *GoalHandler) UpdateGoals(ctx context.Context, req *connect.Request[v1.UpdateGoalsRequest]) (*connect.Response[v1.UpdateGoalsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	var targets repo.GoalTargets
+	targetWeight, err := requestWeightKg(req.Msg.TargetWeightKg, req.Msg.TargetWeight)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if targetWeight != nil {
+		if w := *targetWeight; w <= 0 || w > 500 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.TargetWeightOutOfRange))
+		}
+		targets.TargetWeightKg = targetWeight
+	}
+	if req.Msg.WeeklyExerciseMinutes != nil {
+		if req.Msg.WeeklyExerciseMinutes.Value <= 0 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WeeklyExerciseMinutesInvalid))
+		}
+		targets.WeeklyExerciseMinutes = &req.Msg.WeeklyExerciseMinutes.Value
+	}
+	if req.Msg.WeeklyCaloriesBurned != nil {
+		if req.Msg.WeeklyCaloriesBurned.Value <= 0 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WeeklyCaloriesBurnedInvalid))
+		}
+		targets.WeeklyCaloriesBurned = &req.Msg.WeeklyCaloriesBurned.Value
+	}
+	if req.Msg.WeeklyDiaryEntries != nil {
+		if req.Msg.WeeklyDiaryEntries.Value < 1 || req.Msg.WeeklyDiaryEntries.Value > 7 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WeeklyDiaryEntriesInvalid))
+		}
+		targets.WeeklyDiaryEntries = &req.Msg.WeeklyDiaryEntries.Value
+	}
+
+	goals, err := h.repo.Set(ctx, userID, targets, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to update goals", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update goals"))
+	}
+	unit, err := preferredWeightUnit(ctx, h.prefs, h.log, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create response
+	protoGoals := ToProtoGoals(goals)
+	protoGoals.TargetWeight = toProtoWeight(protoGoals.TargetWeightKg, unit)
+	res := connect.NewResponse(&v1.UpdateGoalsResponse{
+		Goals: protoGoals,
+	})
+
+	return res, nil
+}
+
+// ToProtoGoals converts a db.Goal to v1.Goals, with the target weight in kg; the zero db.Goal
+// yields empty goals
+func ToProtoGoals(g db.Goal) *v1.Goals {
+	protoGoals := &v1.Goals{}
+
+	if w, ok := numericToFloat64(g.TargetWeightKg); ok {
+		protoGoals.TargetWeightKg = wrapperspb.Double(w)
+		protoGoals.TargetWeight = toProtoWeight(protoGoals.TargetWeightKg, units.Kilograms)
+	}
+	if g.WeeklyExerciseMinutes.Valid {
+		protoGoals.WeeklyExerciseMinutes = wrapperspb.Int32(g.WeeklyExerciseMinutes.Int32)
+	}
+	if g.WeeklyCaloriesBurned.Valid {
+		protoGoals.WeeklyCaloriesBurned = wrapperspb.Int32(g.WeeklyCaloriesBurned.Int32)
+	}
+	if g.WeeklyDiaryEntries.Valid {
+		protoGoals.WeeklyDiaryEntries = wrapperspb.Int32(g.WeeklyDiaryEntries.Int32)
+	}
+	if !g.UpdatedAt.IsZero() {
+		protoGoals.UpdatedAt = timestamppb.New(g.UpdatedAt)
+	}
+
+	return protoGoals
+}
+
+// numericToFloat64 converts a pgtype.Numeric, reporting false for NULL
+func numericToFloat64(n pgtype.Numeric) (float64, bool) {
+	f, err := n.Float64Value()
+	if err != nil || !f.Valid {
+		return 0, false
+	}
+	return f.Float64, true
+}
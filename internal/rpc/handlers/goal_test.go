@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGoalHandler(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewGoalHandler(repo.NewGoalRepository(testPool), repo.NewPreferenceRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	t.Run("No Goals", func(t *testing.T) {
+		resp, err := handler.GetGoals(testCtx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		require.NoError(t, err)
+		assert.Nil(t, resp.Msg.Goals.TargetWeightKg)
+		assert.Nil(t, resp.Msg.Goals.UpdatedAt)
+	})
+
+	t.Run("Update Goals", func(t *testing.T) {
+		_, err := handler.UpdateGoals(testCtx, connect.NewRequest(&v1.UpdateGoalsRequest{
+			TargetWeightKg:        wrapperspb.Double(72.5),
+			WeeklyExerciseMinutes: wrapperspb.Int32(150),
+		}))
+		require.NoError(t, err)
+
+		// Unset goals are cleared
+		mockClock.SetTime(time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC))
+		_, err = handler.UpdateGoals(testCtx, connect.NewRequest(&v1.UpdateGoalsRequest{
+			WeeklyExerciseMinutes: wrapperspb.Int32(180),
+			WeeklyDiaryEntries:    wrapperspb.Int32(3),
+		}))
+		require.NoError(t, err)
+
+		resp, err := handler.GetGoals(testCtx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		require.NoError(t, err)
+		assert.Nil(t, resp.Msg.Goals.TargetWeightKg)
+		assert.EqualValues(t, 180, resp.Msg.Goals.WeeklyExerciseMinutes.GetValue())
+		assert.EqualValues(t, 3, resp.Msg.Goals.WeeklyDiaryEntries.GetValue())
+		assert.Nil(t, resp.Msg.Goals.WeeklyCaloriesBurned)
+		assert.Equal(t, mockClock.Now(), resp.Msg.Goals.UpdatedAt.AsTime())
+	})
+
+	t.Run("Error - Invalid Goals", func(t *testing.T) {
+		for name, req := range map[string]*v1.UpdateGoalsRequest{
+			"target weight":    {TargetWeightKg: wrapperspb.Double(0)},
+			"exercise minutes": {WeeklyExerciseMinutes: wrapperspb.Int32(-10)},
+			"diary entries":    {WeeklyDiaryEntries: wrapperspb.Int32(8)},
+		} {
+			_, err := handler.UpdateGoals(testCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// updateGolden rewrites the golden files with the responses of the tests instead of comparing them:
+// go test ./internal/rpc/handlers -run TestGoldenResponses -update
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+var goldenUUIDPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// canonicalJSON serializes msg as clients receive it, with sorted keys and indentation so diffs are
+// line by line. UUIDs, random in every run, are numbered in order of appearance, keeping which
+// fields share an ID visible.
+func canonicalJSON(msg proto.Message) ([]byte, error) {
+	raw, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	// protojson randomizes its whitespace, so the JSON is re-encoded
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	return goldenUUIDPattern.ReplaceAllFunc(buf.Bytes(), func(id []byte) []byte {
+		placeholder, ok := ids[string(id)]
+		if !ok {
+			placeholder = fmt.Sprintf("<uuid-%d>", len(ids)+1)
+			ids[string(id)] = placeholder
+		}
+		return []byte(placeholder)
+	}), nil
+}
+
+// assertGolden compares msg, in canonical JSON, to testdata/golden/<name>.json, or rewrites the
+// file with -update
+func assertGolden(t *testing.T, name string, msg proto.Message) {
+	t.Helper()
+	got, err := canonicalJSON(msg)
+	require.NoError(t, err)
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+		return
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file; run the test with -update to create it")
+	assert.Equal(t, string(want), string(got), "response differs from %s; if the change is intended, run the test with -update and review the diff", path)
+}
+
+func TestGoldenResponses(t *testing.T) {
+	ctx := newTestContext(context.Background())
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	t.Run("Body Records", func(t *testing.T) {
+		resetDB(t, testPool)
+		handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+
+		created, err := handler.CreateBodyRecord(ctx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+			Date:              "2024-01-15",
+			WeightKg:          wrapperspb.Double(72.5),
+			BodyFatPercentage: wrapperspb.Double(18.2),
+			Note:              "after flu",
+		}))
+		require.NoError(t, err)
+		assertGolden(t, "create_body_record", created.Msg)
+
+		listed, err := handler.ListBodyRecords(ctx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+		require.NoError(t, err)
+		assertGolden(t, "list_body_records", listed.Msg)
+	})
+
+	t.Run("Canonical JSON", func(t *testing.T) {
+		userID := testUserID.String()
+		got, err := canonicalJSON(&v1.ListBodyRecordsResponse{
+			BodyRecords: []*v1.BodyRecord{
+				{Id: "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b", UserId: userID, Date: "2024-01-15"},
+				{Id: "0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d", UserId: userID, Date: "2024-01-14"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, `{
+  "bodyRecords": [
+    {
+      "date": "2024-01-15",
+      "id": "<uuid-1>",
+      "userId": "<uuid-2>"
+    },
+    {
+      "date": "2024-01-14",
+      "id": "<uuid-3>",
+      "userId": "<uuid-2>"
+    }
+  ]
+}
+`, string(got))
+	})
+}
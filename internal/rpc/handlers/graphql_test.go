@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/graphql"
+	"github.com/atreya2011/health-management-api/internal/interceptor"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphqlResponse is the body of a GraphQL response
+type graphqlResponse struct {
+	Data struct {
+		BodyRecords []struct {
+			Date     string   `json:"date"`
+			WeightKg *float64 `json:"weightKg"`
+		} `json:"bodyRecords"`
+		ExerciseTotals *struct {
+			SessionCount         int `json:"sessionCount"`
+			TotalDurationMinutes int `json:"totalDurationMinutes"`
+		} `json:"exerciseTotals"`
+		LatestDiaryEntries []struct {
+			Content   string `json:"content"`
+			EntryDate string `json:"entryDate"`
+		} `json:"latestDiaryEntries"`
+	} `json:"data"`
+	Errors []struct {
+		Message    string            `json:"message"`
+		Path       []any             `json:"path"`
+		Extensions map[string]string `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestGraphQLGateway(t *testing.T) {
+	resetDB(t, testPool)
+	userRepo := repo.NewUserRepository(testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	today := fixedTime.Truncate(24 * time.Hour)
+
+	// Serve the gateway behind the auth check, as in production
+	jwtConfig := &auth.JWTConfig{SecretKey: "test-secret"}
+	resolver := graphql.NewResolver(repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testLogger, mockClock)
+	checks := []interceptor.Check{auth.Authenticate(jwtConfig, userRepo, repo.NewSessionRepository(testPool), testLogger)}
+	server := httptest.NewServer(graphql.NewHandler(resolver, checks, 5*time.Second, false))
+	defer server.Close()
+
+	weight := 75.5
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today, &weight, nil, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, today.AddDate(0, 0, -7), &weight, nil, fixedTime)
+	require.NoError(t, err)
+	duration, calories := int32(30), int32(300)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Running", &duration, &calories, fixedTime.Add(-time.Hour), fixedTime)
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "", "Entry", today.AddDate(0, 0, -i), fixedTime)
+		require.NoError(t, err)
+	}
+
+	user, err := userRepo.FindByID(ctx, testUserID)
+	require.NoError(t, err)
+	// token signs a token of the test user granted scope
+	token := func(t *testing.T, scope string) string {
+		t.Helper()
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": user.SubjectID, "scope": scope}).SignedString([]byte(jwtConfig.SecretKey))
+		require.NoError(t, err)
+		return signed
+	}
+	// query posts a GraphQL query with an access token, unless token is empty
+	query := func(t *testing.T, token, query string) (int, graphqlResponse) {
+		t.Helper()
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result graphqlResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
+	const dashboardQuery = `{
+		bodyRecords { date weightKg }
+		exerciseTotals { sessionCount totalDurationMinutes }
+		latestDiaryEntries(count: 2) { content entryDate }
+	}`
+
+	t.Run("Aggregated Query", func(t *testing.T) {
+		status, resp := query(t, token(t, authz.ScopeRecordsRead), dashboardQuery)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, resp.Errors)
+		// The default 7 days exclude the record of a week ago
+		require.Len(t, resp.Data.BodyRecords, 1)
+		assert.Equal(t, "2024-01-15", resp.Data.BodyRecords[0].Date)
+		require.NotNil(t, resp.Data.BodyRecords[0].WeightKg)
+		assert.Equal(t, 75.5, *resp.Data.BodyRecords[0].WeightKg)
+		require.NotNil(t, resp.Data.ExerciseTotals)
+		assert.Equal(t, 1, resp.Data.ExerciseTotals.SessionCount)
+		assert.Equal(t, 30, resp.Data.ExerciseTotals.TotalDurationMinutes)
+		require.Len(t, resp.Data.LatestDiaryEntries, 2)
+		assert.Equal(t, "2024-01-15", resp.Data.LatestDiaryEntries[0].EntryDate)
+		assert.Equal(t, "Entry", resp.Data.LatestDiaryEntries[0].Content)
+	})
+
+	t.Run("Missing Scope", func(t *testing.T) {
+		status, resp := query(t, token(t, authz.ScopeRecordsWrite), dashboardQuery)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Nil(t, resp.Data.BodyRecords)
+		assert.Nil(t, resp.Data.ExerciseTotals)
+		assert.Nil(t, resp.Data.LatestDiaryEntries)
+		require.Len(t, resp.Errors, 3)
+		for _, e := range resp.Errors {
+			assert.Equal(t, "permission_denied", e.Extensions["code"])
+			assert.Equal(t, "missing_scope", e.Extensions["reason"])
+		}
+	})
+
+	t.Run("Invalid Arguments", func(t *testing.T) {
+		status, resp := query(t, token(t, authz.ScopeRecordsRead), `{ bodyRecords(days: 91) { date } exerciseTotals { sessionCount } }`)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Nil(t, resp.Data.BodyRecords)
+		// The other fields are still resolved
+		require.NotNil(t, resp.Data.ExerciseTotals)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, []any{"bodyRecords"}, resp.Errors[0].Path)
+		assert.Equal(t, "invalid_argument", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		status, resp := query(t, "", dashboardQuery)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "unauthenticated", resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("No Introspection", func(t *testing.T) {
+		_, resp := query(t, token(t, authz.ScopeRecordsRead), `{ __schema { types { name } } }`)
+		require.NotEmpty(t, resp.Errors)
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"github.com/atreya2011/health-management-api/internal/health"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecker(t *testing.T) {
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	// The queue fails while down is set, counting its checks
+	var down bool
+	var queueChecks int
+	checker := health.NewChecker(testLogger, mockClock)
+	checker.AddDependency("database", health.DatabaseCheck(testPool))
+	checker.AddDependency("queue", func(ctx context.Context) error {
+		queueChecks++
+		if down {
+			return errors.New("queue down")
+		}
+		return nil
+	})
+	checker.AddService(healthappv1connect.BodyRecordServiceName, "database")
+	checker.AddService(healthappv1connect.NotificationServiceName, "database", "queue")
+	checker.AddService(healthappv1connect.ServerServiceName)
+
+	status := func(t *testing.T, service string) grpchealth.Status {
+		t.Helper()
+		resp, err := checker.Check(ctx, &grpchealth.CheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	t.Run("Healthy Dependencies", func(t *testing.T) {
+		assert.Equal(t, grpchealth.StatusServing, status(t, ""))
+		assert.Equal(t, grpchealth.StatusServing, status(t, healthappv1connect.BodyRecordServiceName))
+		assert.Equal(t, grpchealth.StatusServing, status(t, healthappv1connect.NotificationServiceName))
+
+		_, err := checker.Check(ctx, &grpchealth.CheckRequest{Service: "healthapp.v1.UnknownService"})
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Unhealthy Dependency", func(t *testing.T) {
+		// Results are reused for a second
+		down = true
+		checks := queueChecks
+		assert.Equal(t, grpchealth.StatusServing, status(t, healthappv1connect.NotificationServiceName))
+		assert.Equal(t, checks, queueChecks)
+
+		mockClock.SetTime(fixedTime.Add(time.Second))
+		assert.Equal(t, grpchealth.StatusNotServing, status(t, healthappv1connect.NotificationServiceName))
+		assert.Equal(t, grpchealth.StatusNotServing, status(t, ""))
+		// Services that don't depend on it keep serving
+		assert.Equal(t, grpchealth.StatusServing, status(t, healthappv1connect.BodyRecordServiceName))
+		assert.Equal(t, grpchealth.StatusServing, status(t, healthappv1connect.ServerServiceName))
+
+		down = false
+		mockClock.SetTime(fixedTime.Add(2 * time.Second))
+		assert.Equal(t, grpchealth.StatusServing, status(t, healthappv1connect.NotificationServiceName))
+	})
+
+	t.Run("Served Over Connect", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle(grpchealth.NewHandler(checker))
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		resp, err := server.Client().Post(server.URL+"/grpc.health.v1.Health/Check", "application/json", strings.NewReader(`{"service":"`+healthappv1connect.BodyRecordServiceName+`"}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"status":"SERVING_STATUS_SERVING"}`, string(body))
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		checker.Drain()
+		assert.Equal(t, grpchealth.StatusNotServing, status(t, ""))
+		assert.Equal(t, grpchealth.StatusNotServing, status(t, healthappv1connect.ServerServiceName))
+	})
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxHeartRateSamples bounds the samples of a batch, about 80 minutes of per-second samples
+	maxHeartRateSamples = 5000
+	// minHeartRateBPM and maxHeartRateBPM bound the heart rate of a sample
+	minHeartRateBPM = 20
+	maxHeartRateBPM = 300
+	// maxHeartRateSourceLength bounds the source of a sample, in bytes
+	maxHeartRateSourceLength = 100
+	// maxHeartRatePoints bounds the points of a heart rate request, a week of minutes
+	maxHeartRatePoints = 7 * 24 * 60
+	// heartRateAutoPoints bounds the points of a heart rate request without a resolution, which
+	// gets the finest resolution within it: minutes for up to a day, hours for up to 60 days
+	heartRateAutoPoints = 24 * 60
+	// heartRateDefaultWindow is the time range of a heart rate request without a start time
+	heartRateDefaultWindow = 24 * time.Hour
+)
+
+// heartRateResolution is a resolution of downsampled heart rate
+type heartRateResolution struct {
+	proto  v1.HeartRateResolution
+	unit   string
+	length time.Duration
+}
+
+// heartRateResolutions are the resolutions of heart rate requests, finest first
+var heartRateResolutions = []heartRateResolution{
+	{v1.HeartRateResolution_HEART_RATE_RESOLUTION_MINUTE, repo.HeartRateResolutionMinute, time.Minute},
+	{v1.HeartRateResolution_HEART_RATE_RESOLUTION_HOUR, repo.HeartRateResolutionHour, time.Hour},
+	{v1.HeartRateResolution_HEART_RATE_RESOLUTION_DAY, repo.HeartRateResolutionDay, 24 * time.Hour},
+}
+
+// HeartRateHandler implements the heart rate service RPCs
+type HeartRateHandler struct {
+	repo  HeartRateRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewHeartRateHandler creates a new heart rate handler
+func NewHeartRateHandler(repo HeartRateRepository, log *slog.Logger, clock clock.Clock) *HeartRateHandler {
+	return &HeartRateHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// BatchInsertHeartRate stores heart rate samples of the user's devices
+func (h *HeartRateHandler) BatchInsertHeartRate(ctx context.Context, req *connect.Request[v1.BatchInsertHeartRateRequest]) (*connect.Response[v1.BatchInsertHeartRateResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	if len(req.Msg.Samples) == 0 || len(req.Msg.Samples) > maxHeartRateSamples {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.HeartRateSampleCount, maxHeartRateSamples))
+	}
+	now := h.clock.Now()
+	samples := make([]repo.HeartRateSample, 0, len(req.Msg.Samples))
+	for _, s := range req.Msg.Samples {
+		if s.MeasuredAt == nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.HeartRateTimeRequired))
+		}
+		measuredAt := s.MeasuredAt.AsTime()
+		if measuredAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("heart rate samples cannot be in the future"))
+		}
+		if s.Source == "" || len(s.Source) > maxHeartRateSourceLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.HeartRateSourceInvalid, maxHeartRateSourceLength))
+		}
+		if s.Bpm < minHeartRateBPM || s.Bpm > maxHeartRateBPM {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.HeartRateOutOfRange, minHeartRateBPM, maxHeartRateBPM))
+		}
+		samples = append(samples, repo.HeartRateSample{MeasuredAt: measuredAt, Source: s.Source, BPM: int16(s.Bpm)})
+	}
+
+	inserted, err := h.repo.Insert(ctx, userID, samples, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to insert heart rate samples", "samples", len(samples), "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to insert heart rate"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.BatchInsertHeartRateResponse{
+		InsertedCount: int32(inserted),
+	})
+
+	return res, nil
+}
+
+// GetHeartRate returns the user's heart rate over a time range, downsampled to a resolution
+func (h *HeartRateHandler) GetHeartRate(ctx context.Context, req *connect.Request[v1.GetHeartRateRequest]) (*connect.Response[v1.GetHeartRateResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	end := h.clock.Now()
+	if req.Msg.EndTime != nil {
+		end = req.Msg.EndTime.AsTime()
+	}
+	start := end.Add(-heartRateDefaultWindow)
+	if req.Msg.StartTime != nil {
+		start = req.Msg.StartTime.AsTime()
+	}
+	if !end.After(start) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndTimeBeforeStartTime))
+	}
+	resolution, err := resolveHeartRateResolution(req.Msg.Resolution, end.Sub(start))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if end.Sub(start) > maxHeartRatePoints*resolution.length {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.HeartRateRangeTooLong, maxHeartRatePoints))
+	}
+
+	points, err := h.repo.Downsample(ctx, userID, resolution.unit, start, end)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get heart rate", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get heart rate"))
+	}
+
+	// Create response
+	resp := &v1.GetHeartRateResponse{
+		Points:     make([]*v1.HeartRatePoint, 0, len(points)),
+		Resolution: resolution.proto,
+	}
+	for _, p := range points {
+		resp.Points = append(resp.Points, &v1.HeartRatePoint{
+			StartTime:   timestamppb.New(p.Bucket),
+			AverageBpm:  p.AverageBpm,
+			MinBpm:      p.MinBpm,
+			MaxBpm:      p.MaxBpm,
+			SampleCount: p.SampleCount,
+		})
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// resolveHeartRateResolution returns the requested resolution or, if unspecified, the finest one
+// spanning at most heartRateAutoPoints points over a range of length
+func resolveHeartRateResolution(requested v1.HeartRateResolution, length time.Duration) (heartRateResolution, error) {
+	if requested == v1.HeartRateResolution_HEART_RATE_RESOLUTION_UNSPECIFIED {
+		for _, r := range heartRateResolutions {
+			if length <= heartRateAutoPoints*r.length {
+				return r, nil
+			}
+		}
+		return heartRateResolutions[len(heartRateResolutions)-1], nil
+	}
+	for _, r := range heartRateResolutions {
+		if r.proto == requested {
+			return r, nil
+		}
+	}
+	return heartRateResolution{}, i18n.NewError(i18n.HeartRateResolutionInvalid)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/rollup"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestHeartRateHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 16, 12, 30, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	heartRateRepo := repo.NewHeartRateRepository(testPool)
+	handler := NewHeartRateHandler(heartRateRepo, testLogger, mockClock)
+	worker := rollup.NewWorker(heartRateRepo, time.Minute, testLogger)
+
+	at := func(h, m, s int) *timestamppb.Timestamp {
+		return timestamppb.New(time.Date(2024, 1, 16, h, m, s, 0, time.UTC))
+	}
+	samples := []*v1.HeartRateSample{
+		{MeasuredAt: at(10, 0, 0), Source: "watch", Bpm: 60},
+		{MeasuredAt: at(10, 0, 30), Source: "watch", Bpm: 70},
+		{MeasuredAt: at(10, 0, 30), Source: "strap", Bpm: 74},
+		{MeasuredAt: at(10, 1, 0), Source: "watch", Bpm: 90},
+		{MeasuredAt: at(11, 15, 0), Source: "watch", Bpm: 120},
+	}
+
+	t.Run("Batch Insert Is Idempotent", func(t *testing.T) {
+		resp, err := handler.BatchInsertHeartRate(testCtx, connect.NewRequest(&v1.BatchInsertHeartRateRequest{Samples: samples}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), resp.Msg.InsertedCount)
+
+		// Resending the batch with one new sample only inserts the new one
+		resp, err = handler.BatchInsertHeartRate(testCtx, connect.NewRequest(&v1.BatchInsertHeartRateRequest{
+			Samples: append(samples, &v1.HeartRateSample{MeasuredAt: at(11, 15, 5), Source: "watch", Bpm: 130}),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), resp.Msg.InsertedCount)
+	})
+
+	t.Run("Downsampled By Minute", func(t *testing.T) {
+		resp, err := handler.GetHeartRate(testCtx, connect.NewRequest(&v1.GetHeartRateRequest{
+			StartTime: at(10, 0, 0),
+			EndTime:   at(12, 0, 0),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.HeartRateResolution_HEART_RATE_RESOLUTION_MINUTE, resp.Msg.Resolution)
+		require.Len(t, resp.Msg.Points, 3)
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(10, 0, 0), AverageBpm: 68, MinBpm: 60, MaxBpm: 74, SampleCount: 3}, resp.Msg.Points[0])
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(10, 1, 0), AverageBpm: 90, MinBpm: 90, MaxBpm: 90, SampleCount: 1}, resp.Msg.Points[1])
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(11, 15, 0), AverageBpm: 125, MinBpm: 120, MaxBpm: 130, SampleCount: 2}, resp.Msg.Points[2])
+	})
+
+	t.Run("Hours And Days Are Read From Rollups", func(t *testing.T) {
+		hourly := connect.NewRequest(&v1.GetHeartRateRequest{
+			StartTime:  at(0, 0, 0),
+			Resolution: v1.HeartRateResolution_HEART_RATE_RESOLUTION_HOUR,
+		})
+		resp, err := handler.GetHeartRate(testCtx, hourly)
+		require.NoError(t, err)
+		assert.Empty(t, resp.Msg.Points)
+
+		worker.RollUp(ctx)
+		resp, err = handler.GetHeartRate(testCtx, hourly)
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Points, 2)
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(10, 0, 0), AverageBpm: 73.5, MinBpm: 60, MaxBpm: 90, SampleCount: 4}, resp.Msg.Points[0])
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(11, 0, 0), AverageBpm: 125, MinBpm: 120, MaxBpm: 130, SampleCount: 2}, resp.Msg.Points[1])
+
+		resp, err = handler.GetHeartRate(testCtx, connect.NewRequest(&v1.GetHeartRateRequest{
+			StartTime:  timestamppb.New(fixedTime.AddDate(0, 0, -3)),
+			Resolution: v1.HeartRateResolution_HEART_RATE_RESOLUTION_DAY,
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Points, 1)
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(0, 0, 0), AverageBpm: 544.0 / 6, MinBpm: 60, MaxBpm: 130, SampleCount: 6}, resp.Msg.Points[0])
+	})
+
+	t.Run("Late Samples Update Their Rollups", func(t *testing.T) {
+		_, err := handler.BatchInsertHeartRate(testCtx, connect.NewRequest(&v1.BatchInsertHeartRateRequest{
+			Samples: []*v1.HeartRateSample{{MeasuredAt: at(10, 30, 0), Source: "strap", Bpm: 100}},
+		}))
+		require.NoError(t, err)
+		worker.RollUp(ctx)
+
+		resp, err := handler.GetHeartRate(testCtx, connect.NewRequest(&v1.GetHeartRateRequest{
+			StartTime:  at(10, 0, 0),
+			EndTime:    at(11, 0, 0),
+			Resolution: v1.HeartRateResolution_HEART_RATE_RESOLUTION_HOUR,
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Points, 1)
+		assert.Equal(t, &v1.HeartRatePoint{StartTime: at(10, 0, 0), AverageBpm: 78.8, MinBpm: 60, MaxBpm: 100, SampleCount: 5}, resp.Msg.Points[0])
+	})
+
+	t.Run("Resolution Follows The Range", func(t *testing.T) {
+		for length, want := range map[time.Duration]v1.HeartRateResolution{
+			24 * time.Hour:      v1.HeartRateResolution_HEART_RATE_RESOLUTION_MINUTE,
+			7 * 24 * time.Hour:  v1.HeartRateResolution_HEART_RATE_RESOLUTION_HOUR,
+			90 * 24 * time.Hour: v1.HeartRateResolution_HEART_RATE_RESOLUTION_DAY,
+		} {
+			resp, err := handler.GetHeartRate(testCtx, connect.NewRequest(&v1.GetHeartRateRequest{
+				StartTime: timestamppb.New(fixedTime.Add(-length)),
+			}))
+			require.NoError(t, err)
+			assert.Equal(t, want, resp.Msg.Resolution, length)
+			assert.NotEmpty(t, resp.Msg.Points, length)
+		}
+	})
+
+	t.Run("Other Users See No Samples", func(t *testing.T) {
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+		resp, err := handler.GetHeartRate(otherCtx, connect.NewRequest(&v1.GetHeartRateRequest{
+			StartTime: at(0, 0, 0),
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, resp.Msg.Points)
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		for name, req := range map[string]*v1.BatchInsertHeartRateRequest{
+			"No Samples": {},
+			"No Time":    {Samples: []*v1.HeartRateSample{{Source: "watch", Bpm: 60}}},
+			"Future":     {Samples: []*v1.HeartRateSample{{MeasuredAt: at(13, 0, 0), Source: "watch", Bpm: 60}}},
+			"No Source":  {Samples: []*v1.HeartRateSample{{MeasuredAt: at(10, 0, 0), Bpm: 60}}},
+			"Too Low":    {Samples: []*v1.HeartRateSample{{MeasuredAt: at(10, 0, 0), Source: "watch", Bpm: 10}}},
+			"Too High":   {Samples: []*v1.HeartRateSample{{MeasuredAt: at(10, 0, 0), Source: "watch", Bpm: 310}}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.BatchInsertHeartRate(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+
+		for name, req := range map[string]*v1.GetHeartRateRequest{
+			"End Before Start": {StartTime: at(12, 0, 0), EndTime: at(10, 0, 0)},
+			"Too Many Minutes": {StartTime: timestamppb.New(fixedTime.AddDate(0, 0, -8)), Resolution: v1.HeartRateResolution_HEART_RATE_RESOLUTION_MINUTE},
+			"Bad Resolution":   {StartTime: at(0, 0, 0), Resolution: v1.HeartRateResolution(42)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.GetHeartRate(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalizedErrors(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	interceptor := i18n.Interceptor()
+	testCtx := newTestContext(context.Background())
+
+	// call creates an empty diary entry through the interceptor with an Accept-Language header
+	call := func(t *testing.T, acceptLanguage string) *v1.LocalizedError {
+		t.Helper()
+		req := connect.NewRequest(&v1.CreateDiaryEntryRequest{EntryDate: "2024-01-15"})
+		req.Header().Set("Accept-Language", acceptLanguage)
+		_, err := interceptor(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return handler.CreateDiaryEntry(ctx, req.(*connect.Request[v1.CreateDiaryEntryRequest]))
+		})(testCtx, req)
+		return localizedError(t, err)
+	}
+
+	t.Run("Message In The Requested Language", func(t *testing.T) {
+		for acceptLanguage, want := range map[string]*v1.LocalizedError{
+			"ja-JP,ja;q=0.9,en;q=0.8": {Code: i18n.ContentEmpty, Locale: i18n.Japanese, Message: "内容を入力してください"},
+			"en-US":                   {Code: i18n.ContentEmpty, Locale: i18n.English, Message: "content cannot be empty"},
+			"fr, ja;q=0.5":            {Code: i18n.ContentEmpty, Locale: i18n.Japanese, Message: "内容を入力してください"},
+			"de":                      {Code: i18n.ContentEmpty, Locale: i18n.English, Message: "content cannot be empty"},
+			"":                        {Code: i18n.ContentEmpty, Locale: i18n.English, Message: "content cannot be empty"},
+		} {
+			got := call(t, acceptLanguage)
+			assert.Equal(t, want.Code, got.Code, acceptLanguage)
+			assert.Equal(t, want.Locale, got.Locale, acceptLanguage)
+			assert.Equal(t, want.Message, got.Message, acceptLanguage)
+		}
+	})
+
+	t.Run("Errors Without A Message Fall Back To Their Reason", func(t *testing.T) {
+		req := connect.NewRequest(&v1.GetGoalsRequest{})
+		req.Header().Set("Accept-Language", "ja")
+		_, err := interceptor(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return nil, apierror.New(connect.CodePermissionDenied, apierror.ReasonAccountSuspended, errors.New("account suspended"))
+		})(testCtx, req)
+		got := localizedError(t, err)
+		assert.Equal(t, apierror.ReasonAccountSuspended, got.Code)
+		assert.Equal(t, "アカウントは停止されています", got.Message)
+		// The error message stays in English
+		assert.Equal(t, "account suspended", err.(*connect.Error).Message())
+
+		_, err = interceptor(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get goals"))
+		})(testCtx, req)
+		got = localizedError(t, err)
+		assert.Equal(t, "internal", got.Code)
+		assert.Equal(t, "サーバーでエラーが発生しました", got.Message)
+	})
+
+	t.Run("Errors Of List Items In The Requested Language", func(t *testing.T) {
+		err := i18n.NewError(i18n.IngredientInvalid, 2, i18n.NewError(i18n.NameRequired))
+		assert.Equal(t, "ingredient 2: name is required", err.Error())
+		assert.Equal(t, "2番目の材料: 名前を入力してください", i18n.Message(i18n.Japanese, err.Code, err.Args...))
+	})
+
+	t.Run("Unknown Codes Fall Back To The Code", func(t *testing.T) {
+		for _, code := range []string{i18n.ContentEmpty, i18n.WeightAmbiguous, i18n.CannotSuspendSelf, "not_found", "unauthenticated"} {
+			assert.NotEqual(t, i18n.Message(i18n.English, code), i18n.Message(i18n.Japanese, code), code)
+		}
+		assert.Equal(t, "no_such_code", i18n.Message(i18n.Japanese, "no_such_code"))
+	})
+}
+
+// localizedError returns the LocalizedError detail of err
+func localizedError(t *testing.T, err error) *v1.LocalizedError {
+	t.Helper()
+	var connectErr *connect.Error
+	require.True(t, errors.As(err, &connectErr))
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		require.NoError(t, err)
+		if localized, ok := value.(*v1.LocalizedError); ok {
+			return localized
+		}
+	}
+	t.Fatalf("error %v has no localized message", err)
+	return nil
+}
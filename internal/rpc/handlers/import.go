@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+)
+
+const (
+	// healthKitSource identifies HealthKit samples in imported_samples
+	healthKitSource = "healthkit"
+	// maxImportSamples caps the number of samples accepted by a single import call
+	maxImportSamples = 10000
+	// importBatchSize is the number of samples written per transaction
+	importBatchSize = 500
+	// poundsToKg converts body mass reported in pounds
+	poundsToKg = 0.45359237
+)
+
+// importedSampleTypes are the HealthKit sample types stored by the importer
+var importedSampleTypes = map[v1.HealthKitSampleType]bool{
+	v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_MASS:           true,
+	v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_FAT_PERCENTAGE: true,
+	v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_WORKOUT:             true,
+}
+
+// ImportHandler implements the import service RPCs
+type ImportHandler struct {
+	repo  ImportRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(repo ImportRepository, log *slog.Logger, clock clock.Clock) *ImportHandler {
+	return &ImportHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// ImportHealthKit imports body measurements and workouts from HealthKit samples
+func (h *ImportHandler) ImportHealthKit(ctx context.Context, req *connect.Request[v1.ImportHealthKitRequest]) (*connect.Response[v1.ImportHealthKitResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	samples := req.Msg.Samples
+	if len(samples) == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NoSamples))
+	}
+	if len(samples) > maxImportSamples {
+		return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonLimitExceeded, fmt.Errorf("too many samples (maximum %d per request)", maxImportSamples))
+	}
+
+	// Samples of types without storage fail the request rather than being dropped unnoticed
+	for _, sample := range samples {
+		if !importedSampleTypes[sample.Type] {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedSampleType, sample.Uuid))
+		}
+	}
+
+	now := h.clock.Now()
+	resp := &v1.ImportHealthKitResponse{}
+
+	// Map samples to records, collecting per-sample validation errors
+	var batch repo.ImportBatch
+	flush := func() error {
+		if len(batch.BodyMeasurements)+len(batch.Exercises) == 0 {
+			return nil
+		}
+		result, err := h.repo.ImportBatch(ctx, userID, healthKitSource, batch, now)
+		if err != nil {
+			return err
+		}
+		resp.ImportedCount += int32(result.Imported)
+		resp.DuplicateCount += int32(result.Duplicates)
+		batch = repo.ImportBatch{}
+		return nil
+	}
+
+	h.log.InfoContext(ctx, "Importing HealthKit samples", "samples", len(samples), "now", now)
+	for _, sample := range samples {
+		if sample.Uuid == "" {
+			resp.Errors = append(resp.Errors, &v1.ImportSampleError{Message: "sample uuid is required"})
+			continue
+		}
+
+		switch sample.Type {
+		case v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_MASS, v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_FAT_PERCENTAGE:
+			m, err := toImportedBodyMeasurement(sample, now)
+			if err != nil {
+				resp.Errors = append(resp.Errors, &v1.ImportSampleError{Uuid: sample.Uuid, Message: err.Error()})
+				continue
+			}
+			batch.BodyMeasurements = append(batch.BodyMeasurements, m)
+		case v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_WORKOUT:
+			e, err := toImportedExercise(sample, now)
+			if err != nil {
+				resp.Errors = append(resp.Errors, &v1.ImportSampleError{Uuid: sample.Uuid, Message: err.Error()})
+				continue
+			}
+			batch.Exercises = append(batch.Exercises, e)
+		}
+
+		if len(batch.BodyMeasurements)+len(batch.Exercises) >= importBatchSize {
+			if err := flush(); err != nil {
+				h.log.ErrorContext(ctx, "Failed to import HealthKit batch", "error", err)
+				return nil, connect.NewError(connect.CodeInternal, errors.New("failed to import samples"))
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		h.log.ErrorContext(ctx, "Failed to import HealthKit batch", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to import samples"))
+	}
+
+	h.log.InfoContext(ctx, "HealthKit import finished",
+		"imported", resp.ImportedCount, "duplicates", resp.DuplicateCount, "errors", len(resp.Errors))
+
+	return connect.NewResponse(resp), nil
+}
+
+// toImportedBodyMeasurement maps a body mass or body fat sample, applying the CreateBodyRecord validation rules
+func toImportedBodyMeasurement(sample *v1.HealthKitSample, now time.Time) (repo.ImportedBodyMeasurement, error) {
+	if sample.StartDate == nil {
+		return repo.ImportedBodyMeasurement{}, errors.New("start_date is required")
+	}
+	start := sample.StartDate.AsTime()
+	if start.After(now) {
+		return repo.ImportedBodyMeasurement{}, errors.New("sample date cannot be in the future")
+	}
+
+	m := repo.ImportedBodyMeasurement{
+		SourceSampleID: sample.Uuid,
+		Date:           start.UTC().Truncate(24 * time.Hour),
+	}
+
+	if sample.Type == v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_MASS {
+		w := sample.Value
+		switch strings.ToLower(sample.Unit) {
+		case "kg", "":
+		case "lb", "lbs":
+			w = w * poundsToKg
+		default:
+			return repo.ImportedBodyMeasurement{}, fmt.Errorf("unsupported body mass unit %q", sample.Unit)
+		}
+		w = math.Round(w*100) / 100
+		if w <= 0 {
+			return repo.ImportedBodyMeasurement{}, i18n.NewError(i18n.WeightNotPositive)
+		}
+		if w > 500 {
+			return repo.ImportedBodyMeasurement{}, i18n.NewError(i18n.WeightTooLarge)
+		}
+		m.WeightKg = &w
+		return m, nil
+	}
+
+	// HealthKit reports body fat as a fraction
+	bf := math.Round(sample.Value*10000) / 100
+	if bf < 0 {
+		return repo.ImportedBodyMeasurement{}, i18n.NewError(i18n.BodyFatNegative)
+	}
+	if bf > 100 {
+		return repo.ImportedBodyMeasurement{}, i18n.NewError(i18n.BodyFatTooLarge)
+	}
+	m.BodyFatPercentage = &bf
+	return m, nil
+}
+
+// toImportedExercise maps a workout sample, applying the CreateExerciseRecord validation rules
+func toImportedExercise(sample *v1.HealthKitSample, now time.Time) (repo.ImportedExercise, error) {
+	if sample.StartDate == nil || sample.EndDate == nil {
+		return repo.ImportedExercise{}, errors.New("start_date and end_date are required for workouts")
+	}
+	start, end := sample.StartDate.AsTime(), sample.EndDate.AsTime()
+	if !end.After(start) {
+		return repo.ImportedExercise{}, errors.New("end_date must be after start_date")
+	}
+	if start.After(now) {
+		return repo.ImportedExercise{}, errors.New("recorded date cannot be in the future")
+	}
+
+	name := strings.TrimPrefix(sample.WorkoutActivityType, "HKWorkoutActivityType")
+	if name == "" {
+		name = "Workout"
+	}
+	if len(name) > 100 {
+		return repo.ImportedExercise{}, i18n.NewError(i18n.ExerciseNameTooLong)
+	}
+
+	duration := int32(math.Round(end.Sub(start).Minutes()))
+	if duration <= 0 {
+		duration = 1
+	}
+	if duration > 1440 {
+		return repo.ImportedExercise{}, i18n.NewError(i18n.DurationTooLong)
+	}
+
+	e := repo.ImportedExercise{
+		SourceSampleID:  sample.Uuid,
+		ExerciseName:    name,
+		DurationMinutes: &duration,
+		RecordedAt:      start,
+		StartedAt:       &start,
+		EndedAt:         &end,
+	}
+
+	if sample.TotalEnergyBurnedKcal != nil {
+		calories := int32(math.Round(sample.TotalEnergyBurnedKcal.Value))
+		if calories < 0 {
+			return repo.ImportedExercise{}, i18n.NewError(i18n.CaloriesNegative)
+		}
+		if calories > 10000 {
+			return repo.ImportedExercise{}, i18n.NewError(i18n.CaloriesTooLarge)
+		}
+		e.CaloriesBurned = &calories
+	}
+
+	return e, nil
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestImportHealthKit(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewImportHandler(repo.NewImportRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	morning := time.Date(2024, 1, 14, 7, 0, 0, 0, time.UTC)
+
+	samples := []*v1.HealthKitSample{
+		{
+			Uuid:      uuid.NewString(),
+			Type:      v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_MASS,
+			StartDate: timestamppb.New(morning),
+			EndDate:   timestamppb.New(morning),
+			Value:     75.5,
+			Unit:      "kg",
+		},
+		{
+			Uuid:      uuid.NewString(),
+			Type:      v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_FAT_PERCENTAGE,
+			StartDate: timestamppb.New(morning),
+			EndDate:   timestamppb.New(morning),
+			Value:     0.155,
+			Unit:      "%",
+		},
+		{
+			Uuid:                  uuid.NewString(),
+			Type:                  v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_WORKOUT,
+			StartDate:             timestamppb.New(morning.Add(time.Hour)),
+			EndDate:               timestamppb.New(morning.Add(time.Hour + 30*time.Minute)),
+			WorkoutActivityType:   "HKWorkoutActivityTypeRunning",
+			TotalEnergyBurnedKcal: wrapperspb.Double(250),
+		},
+		{
+			Uuid:      uuid.NewString(),
+			Type:      v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_MASS,
+			StartDate: timestamppb.New(fixedTime.Add(24 * time.Hour)), // In the future
+			Value:     80,
+			Unit:      "kg",
+		},
+	}
+
+	// First import stores the body measurements and the workout
+	resp, err := handler.ImportHealthKit(testCtx, connect.NewRequest(&v1.ImportHealthKitRequest{Samples: samples}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, resp.Msg.ImportedCount)
+	assert.EqualValues(t, 0, resp.Msg.DuplicateCount)
+	require.Len(t, resp.Msg.Errors, 1)
+	assert.Equal(t, samples[3].Uuid, resp.Msg.Errors[0].Uuid)
+
+	// Weight and body fat for the same day are merged into one body record
+	bodyRecords, err := repo.NewBodyRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, bodyRecords, 1)
+	protoRecord := ToProtoBodyRecord(bodyRecords[0])
+	assert.Equal(t, "2024-01-14", protoRecord.Date)
+	require.NotNil(t, protoRecord.WeightKg)
+	assert.Equal(t, 75.5, protoRecord.WeightKg.Value)
+	require.NotNil(t, protoRecord.BodyFatPercentage)
+	assert.Equal(t, 15.5, protoRecord.BodyFatPercentage.Value)
+
+	exerciseRecords, err := repo.NewExerciseRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, exerciseRecords, 1)
+	assert.Equal(t, "Running", exerciseRecords[0].ExerciseName)
+	assert.EqualValues(t, 30, exerciseRecords[0].DurationMinutes.Int32)
+	assert.EqualValues(t, 250, exerciseRecords[0].CaloriesBurned.Int32)
+
+	// Re-importing the same samples is a no-op
+	resp, err = handler.ImportHealthKit(testCtx, connect.NewRequest(&v1.ImportHealthKitRequest{Samples: samples[:3]}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, resp.Msg.ImportedCount)
+	assert.EqualValues(t, 3, resp.Msg.DuplicateCount)
+
+	exerciseRecords, err = repo.NewExerciseRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, exerciseRecords, 1)
+
+	// An empty request is rejected
+	_, err = handler.ImportHealthKit(testCtx, connect.NewRequest(&v1.ImportHealthKitRequest{}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	// A sample of a type without storage, such as the former sleep analysis, fails the request
+	sleep := &v1.HealthKitSample{
+		Uuid:      uuid.NewString(),
+		Type:      v1.HealthKitSampleType(4),
+		StartDate: timestamppb.New(morning.Add(-8 * time.Hour)),
+		EndDate:   timestamppb.New(morning),
+	}
+	weight := &v1.HealthKitSample{
+		Uuid:      uuid.NewString(),
+		Type:      v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_BODY_MASS,
+		StartDate: timestamppb.New(morning.Add(-24 * time.Hour)),
+		Value:     76,
+		Unit:      "kg",
+	}
+	_, err = handler.ImportHealthKit(testCtx, connect.NewRequest(&v1.ImportHealthKitRequest{Samples: []*v1.HealthKitSample{weight, sleep}}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	bodyRecords, err = repo.NewBodyRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, bodyRecords, 1, "nothing is imported")
+}
+
+func TestCopyBatch(t *testing.T) {
+	resetDB(t, testPool)
+	importRepo := repo.NewImportRepository(testPool)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	exerciseRecordRepo := repo.NewExerciseRecordRepository(testPool)
+	ctx := context.Background()
+
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	day1, day2 := time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)
+	at := func(hour int) *time.Time {
+		t := day2.Add(time.Duration(hour) * time.Hour)
+		return &t
+	}
+	float := func(v float64) *float64 { return &v }
+
+	// Existing records: body fat on day 2, a timed workout and a higher step count on day 2
+	bodyFat := 18.0
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, day2, nil, &bodyFat, fixedTime)
+	require.NoError(t, err)
+	_, err = exerciseRecordRepo.Create(ctx, testUserID, "Cycling", nil, nil, *at(8), at(8), at(9), repo.ExerciseEffort{}, fixedTime)
+	require.NoError(t, err)
+	_, err = importRepo.ImportBatch(ctx, testUserID, "fitbit", repo.ImportBatch{DailySteps: []repo.ImportedDailySteps{{Date: day2, Steps: 12000}}}, fixedTime)
+	require.NoError(t, err)
+
+	batch := repo.ImportBatch{
+		BodyMeasurements: []repo.ImportedBodyMeasurement{
+			{SourceSampleID: "w1", Date: day1, WeightKg: float(75)},
+			{SourceSampleID: "f1", Date: day1, BodyFatPercentage: float(15.5)},
+			{SourceSampleID: "w2", Date: day1, WeightKg: float(74.5)}, // Later, so it wins
+			{SourceSampleID: "w1", Date: day1, WeightKg: float(90)},   // Repeated within the batch
+			{SourceSampleID: "w3", Date: day2, WeightKg: float(74)},
+		},
+		Exercises: []repo.ImportedExercise{
+			{SourceSampleID: "e1", ExerciseName: "Running", RecordedAt: *at(8), StartedAt: at(8), EndedAt: at(10)}, // Overlaps the existing record
+			{SourceSampleID: "e2", ExerciseName: "Rowing", RecordedAt: *at(10), StartedAt: at(10), EndedAt: at(11)},
+			{SourceSampleID: "e3", ExerciseName: "Swimming", RecordedAt: *at(10), StartedAt: at(10), EndedAt: at(12)}, // Overlaps e2
+			{SourceSampleID: "e4", ExerciseName: "Yoga", RecordedAt: *at(13)},
+			{SourceSampleID: "e5", ExerciseName: "Walking", RecordedAt: *at(9), StartedAt: at(9), EndedAt: at(10)}, // Only overlaps e1, which is skipped
+		},
+		DailySteps: []repo.ImportedDailySteps{
+			{Date: day1, Steps: 8000},
+			{Date: day1, Steps: 9000},
+			{Date: day2, Steps: 11000},
+		},
+		SkipOverlappingExercises: true,
+	}
+	result, err := importRepo.CopyBatch(ctx, testUserID, "fitbit", batch, fixedTime)
+	require.NoError(t, err)
+	assert.Equal(t, repo.ImportBatchResult{Imported: 10, Duplicates: 1, Conflicts: 2}, result)
+
+	// Measurements of a date are merged into one record in the order of the batch
+	bodyRecords, err := bodyRecordRepo.FindByUserAndDateRange(ctx, testUserID, day1, day2)
+	require.NoError(t, err)
+	require.Len(t, bodyRecords, 2)
+	first, second := ToProtoBodyRecord(bodyRecords[0]), ToProtoBodyRecord(bodyRecords[1])
+	assert.Equal(t, 74.5, first.WeightKg.GetValue())
+	assert.Equal(t, 15.5, first.BodyFatPercentage.GetValue())
+	assert.Equal(t, 74.0, second.WeightKg.GetValue())
+	assert.Equal(t, 18.0, second.BodyFatPercentage.GetValue(), "existing measurements are kept")
+
+	exerciseRecords, err := exerciseRecordRepo.FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	var names []string
+	for _, record := range exerciseRecords {
+		names = append(names, record.ExerciseName)
+	}
+	assert.ElementsMatch(t, []string{"Cycling", "Rowing", "Yoga", "Walking"}, names)
+
+	steps, err := repo.NewStepRecordRepository(testPool).FindByUserAndDateRange(ctx, testUserID, day1, day2)
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+	assert.EqualValues(t, 9000, steps[0].Steps)
+	assert.EqualValues(t, 12000, steps[1].Steps, "the higher count wins")
+
+	// Every imported sample has its change, linked samples aren't imported again
+	changes, err := repo.NewRecordChangeRepository(testPool).FindByRecord(ctx, testUserID, repo.EntityTypeBodyRecord, bodyRecords[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, changes, 3)
+	result, err = importRepo.CopyBatch(ctx, testUserID, "fitbit", repo.ImportBatch{
+		BodyMeasurements: batch.BodyMeasurements,
+		Exercises:        batch.Exercises,
+	}, fixedTime)
+	require.NoError(t, err)
+	assert.Equal(t, repo.ImportBatchResult{Duplicates: 10}, result)
+
+	// Nothing is left in the staging tables
+	var staged int
+	require.NoError(t, testPool.QueryRow(ctx, "SELECT (SELECT COUNT(*) FROM import_staging_body_measurements) + (SELECT COUNT(*) FROM import_staging_exercises) + (SELECT COUNT(*) FROM import_staging_daily_steps)").Scan(&staged))
+	assert.Zero(t, staged)
+
+	// Large batches of ImportBatch are copied
+	large := repo.ImportBatch{}
+	for i := range repo.CopyBatchMinSamples {
+		large.BodyMeasurements = append(large.BodyMeasurements, repo.ImportedBodyMeasurement{
+			SourceSampleID: uuid.NewString(),
+			Date:           day1.AddDate(0, 0, -1-i),
+			WeightKg:       float(80),
+		})
+	}
+	result, err = importRepo.ImportBatch(ctx, testUserID, "healthkit", large, fixedTime)
+	require.NoError(t, err)
+	assert.Equal(t, repo.CopyBatchMinSamples, result.Imported)
+	count, err := bodyRecordRepo.CountByUser(ctx, testUserID)
+	require.NoError(t, err)
+	assert.EqualValues(t, repo.CopyBatchMinSamples+2, count)
+}
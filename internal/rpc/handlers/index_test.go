@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingDB runs statements on the test database, keeping the last query and its arguments so
+// its plan can be explained
+type capturingDB struct {
+	sql  string
+	args []interface{}
+}
+
+func (c *capturingDB) capture(sql string, args []interface{}) {
+	c.sql, c.args = sql, args
+}
+
+func (c *capturingDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return testPool.Exec(ctx, sql, args...)
+}
+
+func (c *capturingDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	c.capture(sql, args)
+	return testPool.Query(ctx, sql, args...)
+}
+
+func (c *capturingDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	c.capture(sql, args)
+	return testPool.QueryRow(ctx, sql, args...)
+}
+
+func (c *capturingDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return testPool.Begin(ctx)
+}
+
+// planNode is a node of a plan explained in JSON
+type planNode struct {
+	NodeType  string     `json:"Node Type"`
+	IndexName string     `json:"Index Name"`
+	Plans     []planNode `json:"Plans"`
+}
+
+// explainNodes explains the plan of sql with sequential scans and sorts disabled, so the planner
+// only picks them when no index can serve the query, and returns the node types and indexes used
+func explainNodes(t *testing.T, sql string, args []interface{}) (nodeTypes, indexes []string) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := testPool.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx) //nolint:errcheck
+	for _, setting := range []string{"enable_seqscan", "enable_sort"} {
+		_, err = tx.Exec(ctx, "SET LOCAL "+setting+" = off")
+		require.NoError(t, err)
+	}
+
+	var raw []byte
+	require.NoError(t, tx.QueryRow(ctx, "EXPLAIN (FORMAT JSON)\n"+sql, args...).Scan(&raw))
+	var plans []struct{ Plan planNode }
+	require.NoError(t, json.Unmarshal(raw, &plans))
+	require.Len(t, plans, 1)
+
+	var walk func(node planNode)
+	walk = func(node planNode) {
+		nodeTypes = append(nodeTypes, node.NodeType)
+		if node.IndexName != "" {
+			indexes = append(indexes, node.IndexName)
+		}
+		for _, child := range node.Plans {
+			walk(child)
+		}
+	}
+	walk(plans[0].Plan)
+	return nodeTypes, indexes
+}
+
+// TestHotQueryIndexes checks the listings and counts of records by user are served by their
+// (user_id, date) indexes, in order, rather than by scanning and sorting the tables
+func TestHotQueryIndexes(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	db := &capturingDB{}
+	bodyRecordRepo := repo.NewBodyRecordRepository(db)
+	exerciseRecordRepo := repo.NewExerciseRecordRepository(db)
+	diaryEntryRepo := repo.NewDiaryEntryRepository(db, testDiaryCipher)
+
+	testCases := []struct {
+		name string
+		run  func() error
+		// index is part of the names of the indexes expected, those of partitions being derived from
+		// the index of their table
+		index string
+		// sorts is set for queries of a bounded date range, whose order is picked by an argument,
+		// which may sort the rows read through the index
+		sorts bool
+	}{
+		{"List Body Records", func() error {
+			_, err := bodyRecordRepo.FindByUser(ctx, testUserID, 20, 0)
+			return err
+		}, "body_records_", false},
+		{"List Body Records By Date Range", func() error {
+			_, err := bodyRecordRepo.FindByUserAndDateRange(ctx, testUserID, now.AddDate(0, -1, 0), now)
+			return err
+		}, "body_records_", true},
+		{"Count Body Records", func() error {
+			_, err := bodyRecordRepo.CountByUser(ctx, testUserID)
+			return err
+		}, "body_records_", false},
+		{"List Exercise Records", func() error {
+			_, err := exerciseRecordRepo.FindByUser(ctx, testUserID, 20, 0)
+			return err
+		}, "exercise_records_", false},
+		{"Count Exercise Records", func() error {
+			_, err := exerciseRecordRepo.CountByUser(ctx, testUserID)
+			return err
+		}, "exercise_records_", false},
+		{"List Diary Entries", func() error {
+			_, err := diaryEntryRepo.FindByUser(ctx, testUserID, 20, 0)
+			return err
+		}, "idx_diary_entries_user_entry_date", false},
+		{"Count Diary Entries", func() error {
+			_, err := diaryEntryRepo.CountByUser(ctx, testUserID)
+			return err
+		}, "idx_diary_entries_user_entry_date", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.run())
+			nodeTypes, indexes := explainNodes(t, db.sql, db.args)
+			assert.NotContains(t, nodeTypes, "Seq Scan", "query:\n%s", db.sql)
+			if !tc.sorts {
+				assert.NotContains(t, nodeTypes, "Sort", "the index should return the rows in order; query:\n%s", db.sql)
+			}
+			require.NotEmpty(t, indexes, "query:\n%s", db.sql)
+			for _, index := range indexes {
+				assert.Contains(t, index, tc.index)
+			}
+		})
+	}
+}
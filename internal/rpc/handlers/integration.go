@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/integration"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// integrationProviderNames maps proto providers to the provider names stored in the database
+var integrationProviderNames = map[v1.IntegrationProvider]string{
+	v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT: integration.ProviderGoogleFit,
+	v1.IntegrationProvider_INTEGRATION_PROVIDER_FITBIT:     integration.ProviderFitbit,
+	v1.IntegrationProvider_INTEGRATION_PROVIDER_WITHINGS:   integration.ProviderWithings,
+}
+
+// IntegrationHandler implements the integration service RPCs
+type IntegrationHandler struct {
+	repo      IntegrationRepository
+	providers map[string]integration.Provider
+	log       *slog.Logger
+	clock     clock.Clock
+}
+
+// NewIntegrationHandler creates a new integration handler for the configured providers
+func NewIntegrationHandler(repo IntegrationRepository, providers []integration.Provider, log *slog.Logger, clock clock.Clock) *IntegrationHandler {
+	byName := make(map[string]integration.Provider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return &IntegrationHandler{
+		repo:      repo,
+		providers: byName,
+		log:       log,
+		clock:     clock,
+	}
+}
+
+// LinkIntegration exchanges an OAuth authorization code and links the provider account
+func (h *IntegrationHandler) LinkIntegration(ctx context.Context, req *connect.Request[v1.LinkIntegrationRequest]) (*connect.Response[v1.LinkIntegrationResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	name, ok := integrationProviderNames[req.Msg.Provider]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedProvider))
+	}
+	provider, ok := h.providers[name]
+	if !ok {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("provider is not enabled on this server"))
+	}
+	if req.Msg.AuthorizationCode == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.AuthorizationCodeRequired))
+	}
+
+	// Exchange the authorization code
+	token, err := provider.ExchangeCode(ctx, req.Msg.AuthorizationCode, req.Msg.RedirectUri)
+	if err != nil {
+		if errors.Is(err, integration.ErrInvalidGrant) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.AuthorizationCodeRejected))
+		}
+		h.log.ErrorContext(ctx, "Failed to exchange authorization code", "provider", name, "error", err)
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("failed to reach provider"))
+	}
+
+	now := h.clock.Now()
+	linked, err := h.repo.Link(ctx, userID, name, token.ExternalUserID, token.AccessToken, token.RefreshToken, now.Add(token.ExpiresIn), now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to link integration", "provider", name, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to link integration"))
+	}
+	h.log.InfoContext(ctx, "Integration linked", "provider", name)
+
+	// Subscribing is best effort: without notifications the account is still synced periodically
+	if subscriber, ok := provider.(integration.NotificationSubscriber); ok {
+		if err := subscriber.Subscribe(ctx, token.AccessToken); err != nil {
+			h.log.WarnContext(ctx, "Failed to subscribe to integration notifications", "provider", name, "error", err)
+		}
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.LinkIntegrationResponse{
+		Integration: ToProtoIntegration(linked),
+	})
+
+	return res, nil
+}
+
+// UnlinkIntegration removes a linked account and revokes its tokens at the provider
+func (h *IntegrationHandler) UnlinkIntegration(ctx context.Context, req *connect.Request[v1.UnlinkIntegrationRequest]) (*connect.Response[v1.UnlinkIntegrationResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	name, ok := integrationProviderNames[req.Msg.Provider]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedProvider))
+	}
+
+	unlinked, err := h.repo.Unlink(ctx, userID, name)
+	if err != nil {
+		if errors.Is(err, repo.ErrIntegrationNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("integration not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to unlink integration", "provider", name, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to unlink integration"))
+	}
+	h.log.InfoContext(ctx, "Integration unlinked", "provider", name)
+
+	// Revoking is best effort: the tokens are already deleted locally
+	if provider, ok := h.providers[name]; ok {
+		if err := provider.RevokeToken(ctx, unlinked.RefreshToken); err != nil {
+			h.log.WarnContext(ctx, "Failed to revoke integration token", "provider", name, "error", err)
+		}
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UnlinkIntegrationResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// GetIntegrationStatus lists the user's linked accounts and the providers enabled on this server
+func (h *IntegrationHandler) GetIntegrationStatus(ctx context.Context, req *connect.Request[v1.GetIntegrationStatusRequest]) (*connect.Response[v1.GetIntegrationStatusResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	integrations, err := h.repo.FindByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list integrations", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get integration status"))
+	}
+
+	// Create response
+	resp := &v1.GetIntegrationStatusResponse{
+		Integrations: make([]*v1.Integration, 0, len(integrations)),
+	}
+	for _, i := range integrations {
+		resp.Integrations = append(resp.Integrations, ToProtoIntegration(i))
+	}
+	for p, name := range integrationProviderNames {
+		if _, ok := h.providers[name]; ok {
+			resp.AvailableProviders = append(resp.AvailableProviders, p)
+		}
+	}
+	sort.Slice(resp.AvailableProviders, func(i, j int) bool {
+		return resp.AvailableProviders[i] < resp.AvailableProviders[j]
+	})
+
+	return connect.NewResponse(resp), nil
+}
+
+// ToProtoIntegration converts a db.Integration to a v1.Integration, omitting its tokens
+func ToProtoIntegration(i db.Integration) *v1.Integration {
+	protoIntegration := &v1.Integration{
+		Id:       i.ID.String(),
+		LinkedAt: timestamppb.New(i.CreatedAt),
+		Provider: toProtoIntegrationProvider(i.Provider),
+	}
+
+	if i.LastSyncedAt.Valid {
+		protoIntegration.LastSyncedAt = timestamppb.New(i.LastSyncedAt.Time)
+	}
+	if i.LastSyncError.Valid {
+		protoIntegration.LastSyncError = i.LastSyncError.String
+	}
+
+	return protoIntegration
+}
+
+// toProtoIntegrationProvider returns the proto provider of a provider name stored in the database
+func toProtoIntegrationProvider(name string) v1.IntegrationProvider {
+	for p, n := range integrationProviderNames {
+		if n == name {
+			return p
+		}
+	}
+	return v1.IntegrationProvider_INTEGRATION_PROVIDER_UNSPECIFIED
+}
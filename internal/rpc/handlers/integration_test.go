@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/crypto"
+	"github.com/atreya2011/health-management-api/internal/integration"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIntegrationRepository creates an integration repository with a random token key
+func newTestIntegrationRepository(t *testing.T) *repo.IntegrationRepository {
+	key := make([]byte, crypto.KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	tokenCipher, err := crypto.NewCipher(key)
+	require.NoError(t, err)
+	return repo.NewIntegrationRepository(testPool, tokenCipher)
+}
+
+// newFakeGoogleFit serves the Google Fit token and data endpoints used by the provider
+func newFakeGoogleFit(t *testing.T, day time.Time) (*integration.GoogleFit, *int) {
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		assert.NoError(t, r.ParseForm())
+		if r.Form.Get("code") == "bad-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","expires_in":3600}`)
+	})
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh", r.Form.Get("token"))
+	})
+	mux.HandleFunc("/api/dataSources/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		fmt.Fprintf(w, `{"point":[{"startTimeNanos":"%d","value":[{"fpVal":72.456}]}]}`, day.Add(7*time.Hour).UnixNano())
+	})
+	mux.HandleFunc("/api/dataset:aggregate", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"bucket":[{"startTimeMillis":"%d","dataset":[{"point":[{"value":[{"intVal":4000}]},{"value":[{"intVal":2500}]}]}]}]}`, day.UnixMilli())
+	})
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		sessions := []map[string]any{
+			{"id": "run-1", "startTimeMillis": fmt.Sprint(day.Add(8 * time.Hour).UnixMilli()), "endTimeMillis": fmt.Sprint(day.Add(8*time.Hour + 30*time.Minute).UnixMilli()), "activityType": 8},
+			{"id": "walk-1", "startTimeMillis": fmt.Sprint(day.Add(18 * time.Hour).UnixMilli()), "endTimeMillis": fmt.Sprint(day.Add(19 * time.Hour).UnixMilli()), "activityType": 7},
+			{"id": "sleep-1", "startTimeMillis": fmt.Sprint(day.Add(-8 * time.Hour).UnixMilli()), "endTimeMillis": fmt.Sprint(day.UnixMilli()), "activityType": 72},
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"session": sessions}))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	g := integration.NewGoogleFit("client-id", "client-secret")
+	g.TokenURL = server.URL + "/token"
+	g.RevokeURL = server.URL + "/revoke"
+	g.APIBaseURL = server.URL + "/api"
+	g.HTTPClient = server.Client()
+	return g, &tokenRequests
+}
+
+func TestLinkIntegration(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	googleFit, _ := newFakeGoogleFit(t, fixedTime.Truncate(24*time.Hour))
+	handler := NewIntegrationHandler(newTestIntegrationRepository(t), []integration.Provider{googleFit}, testLogger, mockClock)
+	testCtx := newTestContext(context.Background())
+
+	testCases := []struct {
+		name         string
+		req          *v1.LinkIntegrationRequest
+		expectedCode connect.Code
+	}{
+		{
+			name: "Success - Google Fit",
+			req: &v1.LinkIntegrationRequest{
+				Provider:          v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT,
+				AuthorizationCode: "good-code",
+				RedirectUri:       "https://app.example.com/callback",
+			},
+		},
+		{
+			name: "Error - Unspecified Provider",
+			req: &v1.LinkIntegrationRequest{
+				AuthorizationCode: "good-code",
+			},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name: "Error - Missing Authorization Code",
+			req: &v1.LinkIntegrationRequest{
+				Provider: v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT,
+			},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name: "Error - Rejected Authorization Code",
+			req: &v1.LinkIntegrationRequest{
+				Provider:          v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT,
+				AuthorizationCode: "bad-code",
+			},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handler.LinkIntegration(testCtx, connect.NewRequest(tc.req))
+
+			if tc.expectedCode != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedCode, connect.CodeOf(err))
+				assert.Nil(t, resp)
+				return
+			}
+
+			require.NoError(t, err)
+			linked := resp.Msg.Integration
+			require.NotNil(t, linked)
+			assert.NotEmpty(t, linked.Id)
+			assert.Equal(t, v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT, linked.Provider)
+			assert.True(t, linked.LinkedAt.AsTime().Equal(fixedTime))
+			assert.Nil(t, linked.LastSyncedAt)
+
+			// Tokens are stored encrypted
+			stored, err := testQueries.ListIntegrationsByUser(context.Background(), testUserID)
+			require.NoError(t, err)
+			require.Len(t, stored, 1)
+			assert.NotEqual(t, "access", stored[0].EncryptedAccessToken)
+			assert.NotEqual(t, "refresh", stored[0].EncryptedRefreshToken)
+		})
+	}
+
+	// Providers without credentials are rejected
+	disabled := NewIntegrationHandler(newTestIntegrationRepository(t), nil, testLogger, mockClock)
+	_, err := disabled.LinkIntegration(testCtx, connect.NewRequest(&v1.LinkIntegrationRequest{
+		Provider:          v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT,
+		AuthorizationCode: "good-code",
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+}
+
+func TestGoogleFitSync(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	day := fixedTime.Truncate(24 * time.Hour)
+	googleFit, tokenRequests := newFakeGoogleFit(t, day)
+
+	integrationRepo := newTestIntegrationRepository(t)
+	exerciseRepo := repo.NewExerciseRecordRepository(testPool)
+	syncer := integration.NewSyncer(integrationRepo, repo.NewImportRepository(testPool), []integration.Provider{googleFit}, 15*time.Minute, testLogger, mockClock)
+
+	// Linked with an expired access token, so the first sync refreshes it
+	_, err := integrationRepo.Link(ctx, testUserID, integration.ProviderGoogleFit, "", "expired", "refresh", fixedTime.Add(-time.Hour), fixedTime)
+	require.NoError(t, err)
+
+	// A manually entered walk overlapping the synced walk session
+	walkStart, walkEnd := day.Add(18*time.Hour+10*time.Minute), day.Add(18*time.Hour+50*time.Minute)
+	_, err = exerciseRepo.Create(ctx, testUserID, "Evening walk", nil, nil, walkStart, &walkStart, &walkEnd, repo.ExerciseEffort{}, fixedTime)
+	require.NoError(t, err)
+
+	syncer.SyncDue(ctx)
+	assert.Equal(t, 1, *tokenRequests)
+
+	bodyRecords, err := repo.NewBodyRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, bodyRecords, 1)
+	protoRecord := ToProtoBodyRecord(bodyRecords[0])
+	assert.Equal(t, "2024-01-15", protoRecord.Date)
+	require.NotNil(t, protoRecord.WeightKg)
+	assert.Equal(t, 72.46, protoRecord.WeightKg.Value)
+
+	stepRecords, err := repo.NewStepRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, stepRecords, 1)
+	assert.EqualValues(t, 6500, stepRecords[0].Steps)
+
+	// The run is imported, the walk conflicts with the manual entry and sleep is ignored
+	exerciseRecords, err := exerciseRepo.FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, exerciseRecords, 2)
+	names := []string{exerciseRecords[0].ExerciseName, exerciseRecords[1].ExerciseName}
+	assert.ElementsMatch(t, []string{"Running", "Evening walk"}, names)
+
+	// The integration is not due again within the interval
+	due, err := integrationRepo.FindDueForSync(ctx, integration.ProviderGoogleFit, fixedTime.Add(-15*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	// A later sync re-reads the same day without duplicating records
+	mockClock.SetTime(fixedTime.Add(30 * time.Minute))
+	syncer.SyncDue(ctx)
+	assert.Equal(t, 1, *tokenRequests)
+
+	exerciseRecords, err = exerciseRepo.FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	assert.Len(t, exerciseRecords, 2)
+	stepRecords, err = repo.NewStepRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, stepRecords, 1)
+	assert.EqualValues(t, 6500, stepRecords[0].Steps)
+}
+
+func TestGoogleFitSyncLateSamples(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	// Serves the weights uploaded so far within the requested range, and no steps or sessions
+	uploaded := []time.Time{fixedTime.Add(-time.Hour)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dataSources/", func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		_, err := fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], "%d-%d", &start, &end)
+		assert.NoError(t, err)
+		points := []map[string]any{}
+		for _, at := range uploaded {
+			if at.UnixNano() >= start && at.UnixNano() < end {
+				points = append(points, map[string]any{"startTimeNanos": fmt.Sprint(at.UnixNano()), "value": []map[string]any{{"fpVal": 72.0}}})
+			}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]any{"point": points}))
+	})
+	mux.HandleFunc("/dataset:aggregate", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"bucket":[]}`)
+	})
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"session":[]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	googleFit := integration.NewGoogleFit("client-id", "client-secret")
+	googleFit.APIBaseURL = server.URL
+	googleFit.HTTPClient = server.Client()
+
+	integrationRepo := newTestIntegrationRepository(t)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	_, err := integrationRepo.Link(ctx, testUserID, integration.ProviderGoogleFit, "", "access", "refresh", fixedTime.Add(24*time.Hour), fixedTime)
+	require.NoError(t, err)
+	syncer := integration.NewSyncer(integrationRepo, repo.NewImportRepository(testPool), []integration.Provider{googleFit}, 15*time.Minute, testLogger, mockClock)
+
+	syncer.SyncDue(ctx)
+	records, err := bodyRecordRepo.FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	// The scale uploads a weighing of the previous evening only after the sync
+	uploaded = append(uploaded, fixedTime.Add(-12*time.Hour))
+	mockClock.SetTime(fixedTime.Add(30 * time.Minute))
+	syncer.SyncDue(ctx)
+
+	records, err = bodyRecordRepo.FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2, "the late weight is imported and the first one isn't duplicated")
+	dates := []string{ToProtoBodyRecord(records[0]).Date, ToProtoBodyRecord(records[1]).Date}
+	assert.ElementsMatch(t, []string{"2024-01-14", "2024-01-15"}, dates)
+}
+
+func TestGoogleFitSyncFailure(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	googleFit := integration.NewGoogleFit("client-id", "client-secret")
+	googleFit.APIBaseURL = server.URL
+
+	integrationRepo := newTestIntegrationRepository(t)
+	_, err := integrationRepo.Link(ctx, testUserID, integration.ProviderGoogleFit, "", "access", "refresh", fixedTime.Add(time.Hour), fixedTime)
+	require.NoError(t, err)
+	linked, err := integrationRepo.FindDueForSync(ctx, integration.ProviderGoogleFit, fixedTime)
+	require.NoError(t, err)
+	require.Len(t, linked, 1)
+
+	syncer := integration.NewSyncer(integrationRepo, repo.NewImportRepository(testPool), []integration.Provider{googleFit}, 15*time.Minute, testLogger, mockClock)
+	err = syncer.Sync(ctx, linked[0])
+	require.Error(t, err)
+
+	// The failure is recorded and the cursor is not advanced
+	mockClock.SetTime(fixedTime.Add(time.Hour))
+	due, err := integrationRepo.FindDueForSync(ctx, integration.ProviderGoogleFit, fixedTime.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.False(t, due[0].SyncCursor.Valid)
+	assert.True(t, due[0].LastSyncError.Valid)
+	assert.Contains(t, due[0].LastSyncError.String, "status 500")
+	assert.EqualValues(t, 1, due[0].SyncFailureCount)
+	assert.Equal(t, due[0].LastSyncError.String, ToProtoIntegration(due[0].Integration).LastSyncError)
+}
+
+func TestUnlinkIntegration(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	googleFit, _ := newFakeGoogleFit(t, fixedTime.Truncate(24*time.Hour))
+	integrationRepo := newTestIntegrationRepository(t)
+	handler := NewIntegrationHandler(integrationRepo, []integration.Provider{googleFit}, testLogger, mockClock)
+	testCtx := newTestContext(context.Background())
+
+	_, err := integrationRepo.Link(context.Background(), testUserID, integration.ProviderGoogleFit, "", "access", "refresh", fixedTime.Add(time.Hour), fixedTime)
+	require.NoError(t, err)
+
+	status, err := handler.GetIntegrationStatus(testCtx, connect.NewRequest(&v1.GetIntegrationStatusRequest{}))
+	require.NoError(t, err)
+	require.Len(t, status.Msg.Integrations, 1)
+	assert.Equal(t, v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT, status.Msg.Integrations[0].Provider)
+	assert.Equal(t, []v1.IntegrationProvider{v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT}, status.Msg.AvailableProviders)
+
+	resp, err := handler.UnlinkIntegration(testCtx, connect.NewRequest(&v1.UnlinkIntegrationRequest{
+		Provider: v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT,
+	}))
+	require.NoError(t, err)
+	assert.True(t, resp.Msg.Success)
+
+	status, err = handler.GetIntegrationStatus(testCtx, connect.NewRequest(&v1.GetIntegrationStatusRequest{}))
+	require.NoError(t, err)
+	assert.Empty(t, status.Msg.Integrations)
+
+	// Unlinking again reports the account as not linked
+	_, err = handler.UnlinkIntegration(testCtx, connect.NewRequest(&v1.UnlinkIntegrationRequest{
+		Provider: v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT,
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestFitbitSync(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	refreshed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client-id", clientID)
+		assert.Equal(t, "client-secret", clientSecret)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "old-refresh", r.Form.Get("refresh_token"))
+		refreshed = true
+		fmt.Fprint(w, `{"access_token":"new-access","refresh_token":"new-refresh","expires_in":28800}`)
+	})
+	mux.HandleFunc("/api/body/log/weight/date/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer new-access", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"weight":[{"logId":1705302000000,"date":"2024-01-15","time":"07:00:00","weight":80.2,"fat":21.5}]}`)
+	})
+	mux.HandleFunc("/api/activities/steps/date/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"activities-steps":[{"dateTime":"2024-01-14","value":"0"},{"dateTime":"2024-01-15","value":"9120"}]}`)
+	})
+	mux.HandleFunc("/api/activities/list.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"activities":[{"logId":42,"activityName":"Run","startTime":"2024-01-15T08:00:00.000+00:00","duration":2700000,"calories":410}],"pagination":{"next":""}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fitbit := integration.NewFitbit("client-id", "client-secret")
+	fitbit.TokenURL = server.URL + "/oauth2/token"
+	fitbit.APIBaseURL = server.URL + "/api"
+
+	integrationRepo := newTestIntegrationRepository(t)
+	_, err := integrationRepo.Link(ctx, testUserID, integration.ProviderFitbit, "", "old-access", "old-refresh", fixedTime.Add(-time.Minute), fixedTime.Add(-24*time.Hour))
+	require.NoError(t, err)
+
+	syncer := integration.NewSyncer(integrationRepo, repo.NewImportRepository(testPool), []integration.Provider{fitbit}, 15*time.Minute, testLogger, mockClock)
+	syncer.SyncDue(ctx)
+	assert.True(t, refreshed)
+
+	bodyRecords, err := repo.NewBodyRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, bodyRecords, 1)
+	protoRecord := ToProtoBodyRecord(bodyRecords[0])
+	assert.Equal(t, 80.2, protoRecord.WeightKg.GetValue())
+	assert.Equal(t, 21.5, protoRecord.BodyFatPercentage.GetValue())
+
+	stepRecords, err := repo.NewStepRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, stepRecords, 1)
+	assert.EqualValues(t, 9120, stepRecords[0].Steps)
+
+	exerciseRecords, err := repo.NewExerciseRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, exerciseRecords, 1)
+	assert.Equal(t, "Run", exerciseRecords[0].ExerciseName)
+	assert.EqualValues(t, 45, exerciseRecords[0].DurationMinutes.Int32)
+	assert.EqualValues(t, 410, exerciseRecords[0].CaloriesBurned.Int32)
+
+	// The rotated refresh token is stored for the next sync
+	linked, err := integrationRepo.FindDueForSync(ctx, integration.ProviderFitbit, fixedTime)
+	require.NoError(t, err)
+	require.Len(t, linked, 1)
+	assert.Equal(t, "new-access", linked[0].AccessToken)
+	assert.Equal(t, "new-refresh", linked[0].RefreshToken)
+	assert.False(t, linked[0].LastSyncError.Valid)
+}
+
+func TestWithingsWebhook(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	weighIn := fixedTime.Add(-3 * time.Hour)
+
+	subscribed := ""
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "requesttoken", r.Form.Get("action"))
+		assert.Equal(t, "client-secret", r.Form.Get("client_secret"))
+		if r.Form.Get("code") == "bad-code" {
+			fmt.Fprint(w, `{"status":503,"error":"Invalid Params: invalid code"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":0,"body":{"userid":"363","access_token":"access","refresh_token":"refresh","expires_in":10800}}`)
+	})
+	mux.HandleFunc("/api/notify", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "1", r.Form.Get("appli"))
+		subscribed = r.Form.Get("callbackurl")
+		fmt.Fprint(w, `{"status":0,"body":{}}`)
+	})
+	mux.HandleFunc("/api/measure", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "getmeas", r.Form.Get("action"))
+		fmt.Fprintf(w, `{"status":0,"body":{"measuregrps":[`+
+			`{"grpid":101,"attrib":0,"date":%d,"measures":[{"value":80250,"type":1,"unit":-3},{"value":215,"type":6,"unit":-1}]},`+
+			`{"grpid":102,"attrib":1,"date":%d,"measures":[{"value":61000,"type":1,"unit":-3}]}`+
+			`],"more":0,"offset":0}}`, weighIn.Unix(), weighIn.Unix())
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	callbackURL, err := integration.WithingsCallbackURL("https://api.example.com"+integration.WithingsWebhookPath, "webhook-secret")
+	require.NoError(t, err)
+	withings := integration.NewWithings("client-id", "client-secret", callbackURL)
+	withings.TokenURL = server.URL + "/oauth2"
+	withings.APIBaseURL = server.URL + "/api"
+
+	// Linking stores the Withings user ID and subscribes to weigh-in notifications
+	integrationRepo := newTestIntegrationRepository(t)
+	handler := NewIntegrationHandler(integrationRepo, []integration.Provider{withings}, testLogger, mockClock)
+	testCtx := newTestContext(ctx)
+	_, err = handler.LinkIntegration(testCtx, connect.NewRequest(&v1.LinkIntegrationRequest{
+		Provider:          v1.IntegrationProvider_INTEGRATION_PROVIDER_WITHINGS,
+		AuthorizationCode: "bad-code",
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	_, err = handler.LinkIntegration(testCtx, connect.NewRequest(&v1.LinkIntegrationRequest{
+		Provider:          v1.IntegrationProvider_INTEGRATION_PROVIDER_WITHINGS,
+		AuthorizationCode: "good-code",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, callbackURL, subscribed)
+
+	syncer := integration.NewSyncer(integrationRepo, repo.NewImportRepository(testPool), []integration.Provider{withings}, 15*time.Minute, testLogger, mockClock)
+	webhook := integration.NewWithingsWebhook(syncer, "webhook-secret", []string{""}, testLogger)
+	notify := func(method, target string, form url.Values) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webhook.ServeHTTP(rec, req)
+		webhook.Wait()
+		return rec.Code
+	}
+	weightNotification := url.Values{"userid": {"363"}, "appli": {"1"}, "startdate": {fmt.Sprint(weighIn.Unix())}, "enddate": {fmt.Sprint(weighIn.Unix())}}
+
+	// Callback URL verification and secret checks
+	assert.Equal(t, http.StatusOK, notify(http.MethodHead, callbackURL, nil))
+	assert.Equal(t, http.StatusUnauthorized, notify(http.MethodPost, integration.WithingsWebhookPath+"?secret=wrong", weightNotification))
+	assert.Equal(t, http.StatusBadRequest, notify(http.MethodPost, callbackURL, url.Values{"appli": {"1"}}))
+
+	bodyRecords, err := repo.NewBodyRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, bodyRecords)
+
+	// Notifications for unlinked accounts are acknowledged and ignored
+	assert.Equal(t, http.StatusOK, notify(http.MethodPost, callbackURL, url.Values{"userid": {"999"}, "appli": {"1"}}))
+
+	// A weigh-in notification syncs the linked account; ambiguous measurements are skipped
+	assert.Equal(t, http.StatusOK, notify(http.MethodPost, callbackURL, weightNotification))
+	bodyRecords, err = repo.NewBodyRecordRepository(testPool).FindByUser(ctx, testUserID, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, bodyRecords, 1)
+	protoRecord := ToProtoBodyRecord(bodyRecords[0])
+	assert.Equal(t, "2024-01-15", protoRecord.Date)
+	assert.Equal(t, 80.25, protoRecord.WeightKg.GetValue())
+	assert.Equal(t, 21.5, protoRecord.BodyFatPercentage.GetValue())
+}
+
+func TestReencryptIntegrationTokens(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	oldCipher, err := crypto.NewCipher(oldKey)
+	require.NoError(t, err)
+	rotatedCipher, err := crypto.NewCipher(newKey, oldKey)
+	require.NoError(t, err)
+	newCipher, err := crypto.NewCipher(newKey)
+	require.NoError(t, err)
+
+	_, err = repo.NewIntegrationRepository(testPool, oldCipher).Link(ctx, testUserID, integration.ProviderGoogleFit, "external-user", "access", "refresh", fixedTime.Add(time.Hour), fixedTime)
+	require.NoError(t, err)
+	rotatedRepo := repo.NewIntegrationRepository(testPool, rotatedCipher)
+	newRepo := repo.NewIntegrationRepository(testPool, newCipher)
+
+	// Tokens encrypted with a previous key are decrypted until they are re-encrypted
+	integrations, err := rotatedRepo.FindByExternalUser(ctx, integration.ProviderGoogleFit, "external-user")
+	require.NoError(t, err)
+	require.Len(t, integrations, 1)
+	assert.Equal(t, "access", integrations[0].AccessToken)
+	assert.Equal(t, "refresh", integrations[0].RefreshToken)
+	_, err = newRepo.FindByExternalUser(ctx, integration.ProviderGoogleFit, "external-user")
+	require.Error(t, err)
+
+	n, err := rotatedRepo.ReencryptTokens(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	var accessToken, refreshToken string
+	var updatedAt time.Time
+	require.NoError(t, testPool.QueryRow(ctx, "SELECT encrypted_access_token, encrypted_refresh_token, updated_at FROM integrations").Scan(&accessToken, &refreshToken, &updatedAt))
+	assert.True(t, strings.HasPrefix(accessToken, newCipher.PrimaryKeyPrefix()))
+	assert.True(t, strings.HasPrefix(refreshToken, newCipher.PrimaryKeyPrefix()))
+	// Re-encrypting doesn't change the tokens, so updated_at is kept
+	assert.Equal(t, fixedTime, updatedAt.UTC())
+
+	// Once re-encrypted, the previous key is no longer needed
+	integrations, err = newRepo.FindByExternalUser(ctx, integration.ProviderGoogleFit, "external-user")
+	require.NoError(t, err)
+	require.Len(t, integrations, 1)
+	assert.Equal(t, "access", integrations[0].AccessToken)
+	assert.Equal(t, "refresh", integrations[0].RefreshToken)
+
+	n, err = rotatedRepo.ReencryptTokens(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
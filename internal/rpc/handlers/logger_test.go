@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	t.Run("Text Format", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := log.New(log.Options{Mode: log.ModeProduction, HashKey: "key", Level: slog.LevelWarn, Format: log.FormatText, Output: &out})
+		logger.Info("Not logged")
+		logger.Warn("Email bounced", "email", "jane@example.com")
+
+		assert.Contains(t, out.String(), `level=WARN msg="Email bounced" email=[REDACTED]`)
+		assert.NotContains(t, out.String(), "Not logged")
+	})
+
+	t.Run("File Output", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "server.log")
+		for _, message := range []string{"First start", "Second start"} {
+			output, err := log.Open(path)
+			require.NoError(t, err)
+			log.New(log.Options{Output: output}).Info(message)
+			require.NoError(t, output.Close())
+		}
+
+		// Records are appended as JSON lines
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"msg":"First start"`)
+		assert.Contains(t, lines[1], `"msg":"Second start"`)
+
+		_, err = log.Open(filepath.Join(t.TempDir(), "missing", "server.log"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Sampling", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := log.New(log.Options{
+			Output:   &out,
+			Sampling: log.Sampling{Initial: 2, Thereafter: 3, Interval: time.Second},
+			Clock:    mockClock,
+		}).With("job", "reminders")
+		count := func(message string) int {
+			return strings.Count(out.String(), `"msg":"`+message+`"`)
+		}
+
+		// The first 2 records are logged, then every third: the 5th and 8th
+		for range 9 {
+			logger.Info("Reminder fired")
+		}
+		assert.Equal(t, 4, count("Reminder fired"))
+		// Other messages and errors are counted apart, and errors are never dropped
+		logger.Info("Reminder skipped")
+		for range 5 {
+			logger.Error("Reminder failed")
+		}
+		assert.Equal(t, 1, count("Reminder skipped"))
+		assert.Equal(t, 5, count("Reminder failed"))
+
+		// The counts start over with the next interval
+		mockClock.SetTime(fixedTime.Add(time.Second))
+		logger.Info("Reminder fired")
+		assert.Equal(t, 5, count("Reminder fired"))
+	})
+
+	t.Run("Request Context", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := log.New(log.Options{Output: &out})
+		otherUserID := uuid.New()
+		handler := log.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := log.WithUserID(r.Context(), testUserID.String())
+			logger.InfoContext(ctx, "Record created")
+			// Attributes of the same key are kept
+			logger.InfoContext(ctx, "Record shared", "userID", otherUserID)
+		}))
+		lastRecords := func(t *testing.T, n int) []map[string]any {
+			t.Helper()
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			require.GreaterOrEqual(t, len(lines), n)
+			records := make([]map[string]any, n)
+			for i, line := range lines[len(lines)-n:] {
+				require.NoError(t, json.Unmarshal([]byte(line), &records[i]))
+			}
+			return records
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/healthapp.v1.BodyRecordService/CreateBodyRecord", nil)
+		req.Header.Set(log.RequestIDHeader, "req-123")
+		req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, "req-123", rec.Header().Get(log.RequestIDHeader))
+		records := lastRecords(t, 2)
+		assert.Equal(t, "req-123", records[0]["requestID"])
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0]["traceID"])
+		assert.Equal(t, testUserID.String(), records[0]["userID"])
+		assert.Equal(t, otherUserID.String(), records[1]["userID"])
+		assert.Equal(t, 2, strings.Count(out.String(), `"userID"`), "a user ID per record")
+
+		// Invalid request IDs are replaced, and requests outside traces have no trace ID
+		req = httptest.NewRequest(http.MethodPost, "/healthapp.v1.BodyRecordService/CreateBodyRecord", nil)
+		req.Header.Set(log.RequestIDHeader, "bad id\n")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		requestID := rec.Header().Get(log.RequestIDHeader)
+		_, err := uuid.Parse(requestID)
+		assert.NoError(t, err)
+		records = lastRecords(t, 2)
+		assert.Equal(t, requestID, records[0]["requestID"])
+		assert.NotContains(t, records[0], "traceID")
+
+		// Records logged outside requests are left as they are
+		logger.Info("Job started")
+		assert.NotContains(t, lastRecords(t, 1)[0], "requestID")
+	})
+}
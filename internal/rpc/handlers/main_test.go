@@ -12,9 +12,13 @@ import (
 	"testing"
 	"time"
 
-	"github.com/atreya2011/health-management-api/internal/auth"  // Added for UserContextKey
+	"github.com/atreya2011/health-management-api/internal/auth" // Added for UserContextKey
+	"github.com/atreya2011/health-management-api/internal/authz"
 	"github.com/atreya2011/health-management-api/internal/clock" // Added clock import
+	"github.com/atreya2011/health-management-api/internal/crypto"
+	"github.com/atreya2011/health-management-api/internal/repo"
 	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/storage"
 	"github.com/atreya2011/health-management-api/internal/testutil" // Keep for CreateTestUser
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -29,6 +33,10 @@ var (
 	testLogger  *slog.Logger
 	testUserID  uuid.UUID
 	mockClock   *clock.MockClock
+	// testAuthorizer authorizes reads against the data shares in the test database
+	testAuthorizer *authz.Authorizer
+	// testDiaryCipher encrypts diary entries as with a configured data key
+	testDiaryCipher *crypto.Cipher
 
 	// Keep track of these for teardown
 	dockerPool *dockertest.Pool
@@ -196,6 +204,11 @@ func TestMain(m *testing.M) {
 	}
 
 	testQueries = db.New(testPool)
+	testAuthorizer = authz.NewAuthorizer(repo.NewDataShareRepository(testPool), repo.NewUserRepository(testPool), mockClock)
+	testDiaryCipher, err = crypto.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		log.Fatalf("Could not create diary cipher: %s", err)
+	}
 	// --- End Database Setup ---
 
 	// --- Start Seeding Data ---
@@ -280,8 +293,48 @@ func resetDB(t *testing.T, pool *pgxpool.Pool) {
 	tables := []string{
 		"body_records",
 		"diary_entries",
+		"diary_share_links",
 		"exercise_records",
+		"exercise_routes",
+		"exercise_templates",
+		"workout_sessions",
 		"columns",
+		"column_reads",
+		"imported_samples",
+		"integrations",
+		"step_records",
+		"step_buckets",
+		"heart_rate_samples",
+		"heart_rate_hourly",
+		"heart_rate_daily",
+		"heart_rate_pending_hours",
+		"mood_records",
+		"fasts",
+		"daily_targets",
+		"water_intakes",
+		"record_changes",
+		"device_tokens",
+		"push_notifications",
+		"reminders",
+		"goals",
+		"goal_milestones",
+		"meal_records",
+		"recipes",
+		"recipe_ingredients",
+		"planned_meals",
+		"supplements",
+		"supplement_intakes",
+		"streaks",
+		"achievements",
+		"attachments",
+		"data_shares",
+		"sessions",
+		"refresh_tokens",
+		"api_usage",
+		"outbox_events",
+		"research_exports",
+		"monthly_reports",
+		"trash",
 		// Add other data tables here if necessary
 	}
 	for _, table := range tables {
@@ -293,5 +346,15 @@ func resetDB(t *testing.T, pool *pgxpool.Pool) {
 			t.Fatalf("Failed to truncate table %s: %v", table, err)
 		}
 	}
+	// TRUNCATE bypasses the triggers maintaining the cached record stats of users; settings are reset too
+	if _, err := pool.Exec(ctx, "UPDATE users SET body_record_count = 0, exercise_record_count = 0, diary_entry_count = 0, step_record_count = 0, last_activity_at = NULL, suspended_at = NULL, retention_opt_out = false, research_opt_in = false, column_digest_opt_in = false, column_digest_categories = '{}', column_digest_sent_at = NULL, weight_unit = 'kg', height_unit = 'cm', activity_level = 'sedentary'"); err != nil {
+		t.Fatalf("Failed to reset user record stats: %v", err)
+	}
 	testLogger.Debug("Truncated data tables", "tables", tables)
 }
+
+// newTestStore creates a local attachment store in a temporary directory of the test
+func newTestStore(t *testing.T) *storage.Local {
+	t.Helper()
+	return storage.NewLocal(t.TempDir(), "http://attachments.test", []byte("test-signing-key"), mockClock)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/maintenance"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	// handler fails the test if the interceptor lets the RPC through
+	var called bool
+	handler := func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		called = true
+		return connect.NewResponse(&v1.GetGoalsResponse{}), nil
+	}
+
+	t.Run("RPCs Pass Outside Maintenance", func(t *testing.T) {
+		called = false
+		_, err := maintenance.Interceptor(nil).WrapUnary(handler)(ctx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		require.NoError(t, err)
+		assert.True(t, called)
+
+		rec := httptest.NewRecorder()
+		maintenance.HealthHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+	})
+
+	t.Run("RPCs Fail With the Message", func(t *testing.T) {
+		called = false
+		mode := maintenance.New("Upgrading the database", fixedTime.Add(30*time.Minute), mockClock)
+		_, err := maintenance.Interceptor(mode).WrapUnary(handler)(ctx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		assert.False(t, called)
+		assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonMaintenance, apierror.Reason(err))
+
+		var connectErr *connect.Error
+		require.True(t, errors.As(err, &connectErr))
+		assert.Equal(t, "Upgrading the database", connectErr.Message())
+		assert.Equal(t, "2024-01-15T10:30:00Z", connectErr.Meta().Get(maintenance.EndsAtMetadataKey))
+		assert.Equal(t, "1800", connectErr.Meta().Get("Retry-After"))
+	})
+
+	t.Run("Without an End Time", func(t *testing.T) {
+		mode := maintenance.New("Back soon", time.Time{}, mockClock)
+		_, err := maintenance.Interceptor(mode).WrapUnary(handler)(ctx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		var connectErr *connect.Error
+		require.True(t, errors.As(err, &connectErr))
+		assert.Empty(t, connectErr.Meta().Get("Retry-After"))
+		assert.Empty(t, connectErr.Meta().Get(maintenance.EndsAtMetadataKey))
+	})
+
+	t.Run("HTTP Handlers Report the Maintenance", func(t *testing.T) {
+		mode := maintenance.New("Upgrading the database", fixedTime.Add(30*time.Minute), mockClock)
+		rec := httptest.NewRecorder()
+		mode.UnavailableHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks/withings", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1800", rec.Header().Get("Retry-After"))
+
+		// Health checks succeed, so clients keep reaching the server
+		rec = httptest.NewRecorder()
+		maintenance.HealthHandler(mode).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var status map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, map[string]string{"status": "maintenance", "message": "Upgrading the database", "ends_at": "2024-01-15T10:30:00Z"}, status)
+	})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxPlannedMealsPerDay bounds the meals planned for a user on one day
+	maxPlannedMealsPerDay = 20
+	// maxPlannedMealDays bounds the days a list of planned meals covers
+	maxPlannedMealDays = 92
+)
+
+// MealPlanHandler implements the meal plan service RPCs
+type MealPlanHandler struct {
+	repo       PlannedMealRepository
+	authorizer *authz.Authorizer // Authorizes coaches the meal plans are shared with
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewMealPlanHandler creates a new meal plan handler
+func NewMealPlanHandler(repo PlannedMealRepository, authorizer *authz.Authorizer, log *slog.Logger, clock clock.Clock) *MealPlanHandler {
+	return &MealPlanHandler{
+		repo:       repo,
+		authorizer: authorizer,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// CreatePlannedMeal plans a meal for the user or a user sharing their meal plans
+func (h *MealPlanHandler) CreatePlannedMeal(ctx context.Context, req *connect.Request[v1.CreatePlannedMealRequest]) (*connect.Response[v1.CreatePlannedMealResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+	ctx, ownerID, err := writableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeMealPlans)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	date, name, err := plannedMealFields(req.Msg.Date, req.Msg.Name, req.Msg.Calories)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := h.repo.CountByUserDate(ctx, ownerID, date)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count planned meals", "userID", ownerID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create planned meal"))
+	}
+	if count >= maxPlannedMealsPerDay {
+		return nil, apierror.New(connect.CodeResourceExhausted, apierror.ReasonLimitExceeded, fmt.Errorf("too many planned meals for the day (maximum %d)", maxPlannedMealsPerDay))
+	}
+
+	h.log.InfoContext(ctx, "Creating planned meal", "userID", ownerID, "plannedBy", userID, "date", req.Msg.Date)
+	created, err := h.repo.Create(ctx, ownerID, userID, date, name, req.Msg.Calories, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create planned meal", "userID", ownerID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create planned meal"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreatePlannedMealResponse{
+		PlannedMeal: ToProtoPlannedMeal(created),
+	})
+
+	return res, nil
+}
+
+// ListPlannedMeals lists the meals planned for the user or a user sharing their meal plans
+func (h *MealPlanHandler) ListPlannedMeals(ctx context.Context, req *connect.Request[v1.ListPlannedMealsRequest]) (*connect.Response[v1.ListPlannedMealsResponse], error) {
+	// Get the owner of the plans to read: the authenticated user, or a user sharing them
+	ctx, ownerID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeMealPlans)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	startDate, endDate := req.Msg.StartDate, req.Msg.EndDate
+	switch {
+	case startDate == "" && endDate == "":
+		startDate = h.clock.Now().UTC().Format("2006-01-02")
+		endDate = startDate
+	case startDate == "":
+		startDate = endDate
+	case endDate == "":
+		endDate = startDate
+	}
+	start, err := reqparse.ParseDate("start_date", startDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	end, err := reqparse.ParseDate("end_date", endDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	if start.After(end) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StartDateAfterEndDate))
+	}
+	if end.Sub(start) >= maxPlannedMealDays*24*time.Hour {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RangeTooLong, maxPlannedMealDays))
+	}
+
+	meals, err := h.repo.FindByUserDateRange(ctx, ownerID, start, end)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list planned meals", "userID", ownerID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list planned meals"))
+	}
+
+	// Create response
+	resp := &v1.ListPlannedMealsResponse{
+		PlannedMeals: make([]*v1.PlannedMeal, 0, len(meals)),
+	}
+	for _, meal := range meals {
+		resp.PlannedMeals = append(resp.PlannedMeals, ToProtoPlannedMeal(meal))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// UpdatePlannedMeal replaces a meal planned for the user or a user sharing their meal plans
+func (h *MealPlanHandler) UpdatePlannedMeal(ctx context.Context, req *connect.Request[v1.UpdatePlannedMealRequest]) (*connect.Response[v1.UpdatePlannedMealResponse], error) {
+	ctx, ownerID, err := writableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeMealPlans)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	mealID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid planned meal ID", "plannedMealID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	date, name, err := plannedMealFields(req.Msg.Date, req.Msg.Name, req.Msg.Calories)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.repo.Update(ctx, mealID, ownerID, date, name, req.Msg.Calories, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrPlannedMealNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("planned meal not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to update planned meal", "plannedMealID", mealID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update planned meal"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdatePlannedMealResponse{
+		PlannedMeal: ToProtoPlannedMeal(updated),
+	})
+
+	return res, nil
+}
+
+// DeletePlannedMeal deletes a meal planned for the user or a user sharing their meal plans
+func (h *MealPlanHandler) DeletePlannedMeal(ctx context.Context, req *connect.Request[v1.DeletePlannedMealRequest]) (*connect.Response[v1.DeletePlannedMealResponse], error) {
+	ctx, ownerID, err := writableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeMealPlans)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	mealID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid planned meal ID", "plannedMealID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Delete(ctx, mealID, ownerID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrPlannedMealNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("planned meal not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete planned meal", "plannedMealID", mealID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete planned meal"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeletePlannedMealResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// plannedMealFields validates the fields of a create or update request, applying the limits of
+// meal records
+func plannedMealFields(date, name string, calories int32) (time.Time, string, error) {
+	day, err := reqparse.ParseDate("date", date)
+	if err != nil {
+		return time.Time{}, "", reqparse.Error(err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.Time{}, "", connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameRequired))
+	}
+	if utf8.RuneCountInString(name) > maxMealNameLength {
+		return time.Time{}, "", connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameTooLong, maxMealNameLength))
+	}
+	if calories < 0 || calories > maxMealCalories {
+		return time.Time{}, "", connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.MealCaloriesOutOfRange, maxMealCalories))
+	}
+	return day, name, nil
+}
+
+// ToProtoPlannedMeal converts a db.PlannedMeal to a v1.PlannedMeal
+func ToProtoPlannedMeal(m db.PlannedMeal) *v1.PlannedMeal {
+	protoMeal := &v1.PlannedMeal{
+		Id:        m.ID.String(),
+		UserId:    m.UserID.String(),
+		Date:      m.Date.Time.Format("2006-01-02"),
+		Name:      m.Name,
+		Calories:  m.Calories,
+		CreatedAt: timestamppb.New(m.CreatedAt),
+		UpdatedAt: timestamppb.New(m.UpdatedAt),
+	}
+	if m.PlannedBy.Valid {
+		protoMeal.PlannedBy = uuid.UUID(m.PlannedBy.Bytes).String()
+	}
+	return protoMeal
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMealPlanHandler(t *testing.T) {
+	resetDB(t, testPool)
+	userRepo := repo.NewUserRepository(testPool)
+	handler := NewMealPlanHandler(repo.NewPlannedMealRepository(testPool), testAuthorizer, testLogger, mockClock)
+	sharingHandler := NewSharingHandler(repo.NewDataShareRepository(testPool), userRepo, testLogger, mockClock)
+	mealRepo := repo.NewMealRecordRepository(testPool)
+	mealHandler := NewMealRecordHandler(mealRepo, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	ownerCtx := newTestContext(ctx)
+	ownerID := testUserID.String()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	coachID, err := testutil.CreateTestUser(ctx, testQueries)
+	require.NoError(t, err)
+	coachEmail := "coach-" + uuid.NewString() + "@example.com"
+	require.NoError(t, userRepo.SetEmail(ctx, coachID, coachEmail))
+	coachCtx := context.WithValue(ctx, auth.UserContextKey, coachID)
+
+	var lunchID string
+	t.Run("Plan Own Meals", func(t *testing.T) {
+		resp, err := handler.CreatePlannedMeal(ownerCtx, connect.NewRequest(&v1.CreatePlannedMealRequest{
+			Date:     "2024-01-15",
+			Name:     " Oatmeal ",
+			Calories: 400,
+		}))
+		require.NoError(t, err)
+		meal := resp.Msg.PlannedMeal
+		assert.Equal(t, ownerID, meal.UserId)
+		assert.Equal(t, "2024-01-15", meal.Date)
+		assert.Equal(t, "Oatmeal", meal.Name)
+		assert.Equal(t, int32(400), meal.Calories)
+		assert.Equal(t, ownerID, meal.PlannedBy)
+
+		mockClock.SetTime(fixedTime.Add(time.Minute))
+		resp, err = handler.CreatePlannedMeal(ownerCtx, connect.NewRequest(&v1.CreatePlannedMealRequest{
+			Date:     "2024-01-15",
+			Name:     "Chicken salad",
+			Calories: 600,
+		}))
+		require.NoError(t, err)
+		lunchID = resp.Msg.PlannedMeal.Id
+	})
+
+	t.Run("List Planned Meals", func(t *testing.T) {
+		// Today by default
+		resp, err := handler.ListPlannedMeals(ownerCtx, connect.NewRequest(&v1.ListPlannedMealsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.PlannedMeals, 2)
+		assert.Equal(t, "Oatmeal", resp.Msg.PlannedMeals[0].Name)
+		assert.Equal(t, "Chicken salad", resp.Msg.PlannedMeals[1].Name)
+
+		resp, err = handler.ListPlannedMeals(ownerCtx, connect.NewRequest(&v1.ListPlannedMealsRequest{StartDate: "2024-01-16", EndDate: "2024-01-20"}))
+		require.NoError(t, err)
+		assert.Empty(t, resp.Msg.PlannedMeals)
+	})
+
+	t.Run("Coach Needs A Share", func(t *testing.T) {
+		_, err := handler.CreatePlannedMeal(coachCtx, connect.NewRequest(&v1.CreatePlannedMealRequest{
+			Date: "2024-01-16", Name: "Protein shake", Calories: 250, OwnerId: ownerID,
+		}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonNotShared, apierror.Reason(err))
+
+		_, err = handler.ListPlannedMeals(coachCtx, connect.NewRequest(&v1.ListPlannedMealsRequest{OwnerId: ownerID}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+
+	t.Run("Coach Plans Shared Meals", func(t *testing.T) {
+		_, err := sharingHandler.CreateShare(ownerCtx, connect.NewRequest(&v1.CreateShareRequest{
+			GranteeEmail: coachEmail,
+			RecordTypes:  []v1.SharedRecordType{v1.SharedRecordType_SHARED_RECORD_TYPE_MEAL_PLANS},
+			ExpiresAt:    timestamppb.New(fixedTime.AddDate(0, 0, 30)),
+		}))
+		require.NoError(t, err)
+
+		resp, err := handler.CreatePlannedMeal(coachCtx, connect.NewRequest(&v1.CreatePlannedMealRequest{
+			Date: "2024-01-15", Name: "Protein shake", Calories: 250, OwnerId: ownerID,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, ownerID, resp.Msg.PlannedMeal.UserId)
+		assert.Equal(t, coachID.String(), resp.Msg.PlannedMeal.PlannedBy)
+
+		updated, err := handler.UpdatePlannedMeal(coachCtx, connect.NewRequest(&v1.UpdatePlannedMealRequest{
+			Id: lunchID, Date: "2024-01-15", Name: "Chicken salad", Calories: 550, OwnerId: ownerID,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(550), updated.Msg.PlannedMeal.Calories)
+
+		listResp, err := handler.ListPlannedMeals(coachCtx, connect.NewRequest(&v1.ListPlannedMealsRequest{StartDate: "2024-01-15", OwnerId: ownerID}))
+		require.NoError(t, err)
+		assert.Len(t, listResp.Msg.PlannedMeals, 3)
+
+		// Without owner_id, the coach's own plans are read and written
+		_, err = handler.UpdatePlannedMeal(coachCtx, connect.NewRequest(&v1.UpdatePlannedMealRequest{
+			Id: lunchID, Date: "2024-01-15", Name: "Chicken salad", Calories: 500,
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		// Sharing meal plans doesn't share meal records
+		_, err = mealHandler.ListMealRecords(coachCtx, connect.NewRequest(&v1.ListMealRecordsRequest{OwnerId: ownerID}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+
+	t.Run("Calorie Balance Compares Planned Calories", func(t *testing.T) {
+		_, err := mealRepo.Create(ctx, testUserID, "Breakfast", 500, fixedTime.Add(-2*time.Hour), fixedTime)
+		require.NoError(t, err)
+		dashboardHandler := NewDashboardHandler(userRepo, repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), repo.NewGoalRepository(testPool), repo.NewColumnRepository(testPool), mealRepo, repo.NewAchievementRepository(testPool), repo.NewSupplementRepository(testPool), repo.NewDailyTargetRepository(testPool), testLogger, mockClock)
+
+		resp, err := dashboardHandler.GetCalorieBalance(ownerCtx, connect.NewRequest(&v1.GetCalorieBalanceRequest{StartDate: "2024-01-14", EndDate: "2024-01-15"}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Days, 2)
+		assert.Nil(t, resp.Msg.Days[0].PlannedCalories)
+		assert.Nil(t, resp.Msg.Days[0].PlannedDifference)
+		today := resp.Msg.Days[1]
+		assert.Equal(t, int32(500), today.CaloriesConsumed)
+		assert.Equal(t, int32(1200), today.PlannedCalories.GetValue())
+		assert.Equal(t, int32(-700), today.PlannedDifference.GetValue())
+	})
+
+	t.Run("Delete Planned Meal", func(t *testing.T) {
+		_, err := handler.DeletePlannedMeal(ownerCtx, connect.NewRequest(&v1.DeletePlannedMealRequest{Id: lunchID}))
+		require.NoError(t, err)
+
+		_, err = handler.DeletePlannedMeal(ownerCtx, connect.NewRequest(&v1.DeletePlannedMealRequest{Id: lunchID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		for _, req := range []*v1.CreatePlannedMealRequest{
+			{Date: "15/01/2024", Name: "Soup", Calories: 200},
+			{Date: "2024-01-15", Name: " ", Calories: 200},
+			{Date: "2024-01-15", Name: "Soup", Calories: -1},
+			{Date: "2024-01-15", Name: "Soup", Calories: 10001},
+			{Date: "2024-01-15", Name: "Soup", Calories: 200, OwnerId: "not-a-uuid"},
+		} {
+			_, err := handler.CreatePlannedMeal(ownerCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+
+		_, err := handler.ListPlannedMeals(ownerCtx, connect.NewRequest(&v1.ListPlannedMealsRequest{StartDate: "2024-01-20", EndDate: "2024-01-15"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.ListPlannedMeals(ownerCtx, connect.NewRequest(&v1.ListPlannedMealsRequest{StartDate: "2024-01-01", EndDate: "2024-04-30"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxMealNameLength bounds the name of a meal record, in characters
+	maxMealNameLength = 100
+	// maxMealCalories bounds the calories of a single meal record, in kcal
+	maxMealCalories = 10000
+)
+
+// MealRecordHandler implements the meal record service RPCs
+type MealRecordHandler struct {
+	repo       MealRecordRepository
+	authorizer *authz.Authorizer // Authorizes reads of records shared by other users
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewMealRecordHandler creates a new meal record handler
+func NewMealRecordHandler(repo MealRecordRepository, authorizer *authz.Authorizer, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *MealRecordHandler {
+	return &MealRecordHandler{
+		repo:       repo,
+		authorizer: authorizer,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// CreateMealRecord creates a meal record for the user
+func (h *MealRecordHandler) CreateMealRecord(ctx context.Context, req *connect.Request[v1.CreateMealRecordRequest]) (*connect.Response[v1.CreateMealRecordResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	name := strings.TrimSpace(req.Msg.Name)
+	if name == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameRequired))
+	}
+	if utf8.RuneCountInString(name) > maxMealNameLength {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("name must be at most %d characters", maxMealNameLength))
+	}
+	if req.Msg.Calories < 0 || req.Msg.Calories > maxMealCalories {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("calories must be between 0 and %d", maxMealCalories))
+	}
+	now := h.clock.Now()
+	eatenAt := now
+	if req.Msg.EatenAt != nil {
+		eatenAt = req.Msg.EatenAt.AsTime()
+		if eatenAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("eaten_at cannot be in the future"))
+		}
+	}
+
+	h.log.InfoContext(ctx, "Creating meal record", "eatenAt", eatenAt)
+	created, err := h.repo.Create(ctx, userID, name, req.Msg.Calories, eatenAt, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create meal record", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create meal record"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateMealRecordResponse{
+		MealRecord: ToProtoMealRecord(created),
+	})
+
+	return res, nil
+}
+
+// ListMealRecords lists meal records for the authenticated user
+func (h *MealRecordHandler) ListMealRecords(ctx context.Context, req *connect.Request[v1.ListMealRecordsRequest]) (*connect.Response[v1.ListMealRecordsResponse], error) {
+	// Get the owner of the records to read: the authenticated user, or a user sharing them
+	ctx, userID, err := readableOwner(ctx, h.authorizer, h.log, req.Msg.OwnerId, authz.RecordTypeMealRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Fetching meal records for user", "userID", userID, "page", page.Number, "pageSize", page.Size)
+	records, err := h.repo.FindByUser(ctx, userID, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch meal records", "userID", userID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch meal records"))
+	}
+
+	total, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count meal records", "userID", userID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count meal records"))
+	}
+
+	protoRecords := make([]*v1.MealRecord, len(records))
+	for i, record := range records {
+		protoRecords[i] = ToProtoMealRecord(record)
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListMealRecordsResponse{
+		MealRecords: protoRecords,
+		Pagination:  page.Response(total),
+	})
+
+	return res, nil
+}
+
+// DeleteMealRecord deletes a meal record
+func (h *MealRecordHandler) DeleteMealRecord(ctx context.Context, req *connect.Request[v1.DeleteMealRecordRequest]) (*connect.Response[v1.DeleteMealRecordResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse record ID
+	recordID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid record ID", "recordID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Deleting meal record", "recordID", recordID)
+	if err := h.repo.Delete(ctx, recordID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrMealRecordNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("meal record not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete meal record", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete meal record"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteMealRecordResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// ToProtoMealRecord converts a db.MealRecord to a v1.MealRecord
+func ToProtoMealRecord(record db.MealRecord) *v1.MealRecord {
+	return &v1.MealRecord{
+		Id:        record.ID.String(),
+		UserId:    record.UserID.String(),
+		Name:      record.Name,
+		Calories:  record.Calories,
+		EatenAt:   timestamppb.New(record.EatenAt),
+		CreatedAt: timestamppb.New(record.CreatedAt),
+		UpdatedAt: timestamppb.New(record.UpdatedAt),
+	}
+}
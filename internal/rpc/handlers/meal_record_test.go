@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMealRecordHandler(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewMealRecordHandler(repo.NewMealRecordRepository(testPool), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	var breakfastID string
+	t.Run("Create", func(t *testing.T) {
+		resp, err := handler.CreateMealRecord(testCtx, connect.NewRequest(&v1.CreateMealRecordRequest{
+			Name:     " Breakfast ",
+			Calories: 450,
+			EatenAt:  timestamppb.New(fixedTime.Add(-3 * time.Hour)),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "Breakfast", resp.Msg.MealRecord.Name)
+		assert.EqualValues(t, 450, resp.Msg.MealRecord.Calories)
+		assert.Equal(t, fixedTime.Add(-3*time.Hour), resp.Msg.MealRecord.EatenAt.AsTime())
+		breakfastID = resp.Msg.MealRecord.Id
+
+		// eaten_at defaults to the current time
+		resp, err = handler.CreateMealRecord(testCtx, connect.NewRequest(&v1.CreateMealRecordRequest{
+			Name:     "Lunch",
+			Calories: 700,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, fixedTime, resp.Msg.MealRecord.EatenAt.AsTime())
+	})
+
+	t.Run("List", func(t *testing.T) {
+		resp, err := handler.ListMealRecords(testCtx, connect.NewRequest(&v1.ListMealRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.MealRecords, 2)
+		assert.Equal(t, "Lunch", resp.Msg.MealRecords[0].Name)
+		assert.EqualValues(t, 2, resp.Msg.Pagination.TotalItems)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := handler.DeleteMealRecord(testCtx, connect.NewRequest(&v1.DeleteMealRecordRequest{Id: breakfastID}))
+		require.NoError(t, err)
+
+		_, err = handler.DeleteMealRecord(testCtx, connect.NewRequest(&v1.DeleteMealRecordRequest{Id: breakfastID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		_, err = handler.DeleteMealRecord(testCtx, connect.NewRequest(&v1.DeleteMealRecordRequest{Id: uuid.NewString()}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Invalid Input", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateMealRecordRequest{
+			"missing name":      {Name: "  ", Calories: 100},
+			"negative calories": {Name: "Snack", Calories: -1},
+			"too many calories": {Name: "Feast", Calories: 10001},
+			"future eaten_at":   {Name: "Dinner", Calories: 600, EatenAt: timestamppb.New(fixedTime.Add(time.Hour))},
+		} {
+			_, err := handler.CreateMealRecord(testCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.ListMealRecords(ctx, connect.NewRequest(&v1.ListMealRecordsRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/atreya2011/health-management-api/internal/migration"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationLint(t *testing.T) {
+	t.Run("Repository Migrations Are Flagged", func(t *testing.T) {
+		files, err := findMigrationFiles()
+		require.NoError(t, err)
+		migrations, err := migration.Load(filepath.Dir(files[0]))
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+		for _, m := range migrations {
+			findings, err := migration.Lint(m)
+			require.NoError(t, err)
+			for _, f := range findings {
+				assert.True(t, f.Allowed(), f.String())
+			}
+		}
+	})
+
+	t.Run("Destructive Changes", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			sql  string
+			want []migration.Rule
+		}{
+			{"Create Table", "CREATE TABLE fasts (id UUID PRIMARY KEY, note TEXT NOT NULL);", nil},
+			{"Nullable Column", "ALTER TABLE users ADD COLUMN timezone TEXT;", nil},
+			{"Required Column With Default", "ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';", nil},
+			{"Required Column", "ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL;", []migration.Rule{migration.RuleRequiredColumn}},
+			{"Constraints", "ALTER TABLE users DROP CONSTRAINT users_check, ADD CONSTRAINT users_check CHECK (a IN (1, 2)), ALTER COLUMN a DROP NOT NULL;", nil},
+			{"Drop Column", "ALTER TABLE users DROP COLUMN avatar_url, DROP legacy_id;", []migration.Rule{migration.RuleDropColumn, migration.RuleDropColumn}},
+			{"Drop Table", "DROP TABLE IF EXISTS legacy;", []migration.Rule{migration.RuleDropTable}},
+			{"Rename", "ALTER TABLE users RENAME COLUMN name TO display_name;\nALTER TABLE users RENAME TO accounts;", []migration.Rule{migration.RuleRename, migration.RuleRename}},
+			{"Rename Index", "ALTER INDEX idx_users RENAME TO idx_accounts;", nil},
+			{"Change Type", "ALTER TABLE users ALTER COLUMN height TYPE NUMERIC(5, 1);", []migration.Rule{migration.RuleChangeType}},
+			{"Set Not Null", "ALTER TABLE users ALTER COLUMN timezone SET NOT NULL;", []migration.Rule{migration.RuleSetNotNull}},
+			{"Truncate", "TRUNCATE sessions;", []migration.Rule{migration.RuleTruncate}},
+			{"Comments And Strings", "-- DROP TABLE users;\n/* TRUNCATE users; */ COMMENT ON TABLE users IS 'DROP TABLE users;';", nil},
+			{"Function Bodies", "CREATE FUNCTION purge() RETURNS void AS $$ BEGIN TRUNCATE sessions; END $$ LANGUAGE plpgsql;", nil},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				findings, err := migration.Lint(migration.Migration{Version: 49, Name: "000049_test.up.sql", SQL: tc.sql})
+				require.NoError(t, err)
+				var rules []migration.Rule
+				for _, f := range findings {
+					assert.False(t, f.Allowed())
+					rules = append(rules, f.Rule)
+				}
+				assert.Equal(t, tc.want, rules)
+			})
+		}
+	})
+
+	t.Run("Flagged Changes", func(t *testing.T) {
+		findings, err := migration.Lint(migration.Migration{Name: "000050_test.up.sql", SQL: `-- Contract the avatar column
+-- migrate:allow drop_column v1.8 stopped reading avatar_url
+ALTER TABLE users DROP COLUMN avatar_url;
+
+DROP TABLE legacy;`})
+		require.NoError(t, err)
+		require.Len(t, findings, 2)
+		assert.Equal(t, 3, findings[0].Line)
+		assert.True(t, findings[0].Allowed())
+		assert.Equal(t, "v1.8 stopped reading avatar_url", findings[0].Reason)
+		assert.Equal(t, 5, findings[1].Line)
+		assert.False(t, findings[1].Allowed())
+
+		// Flags need a known rule and a reason
+		_, err = migration.Lint(migration.Migration{Name: "000050_test.up.sql", SQL: "-- migrate:allow drop_column\nALTER TABLE users DROP COLUMN avatar_url;"})
+		assert.ErrorContains(t, err, "reason")
+		_, err = migration.Lint(migration.Migration{Name: "000050_test.up.sql", SQL: "-- migrate:allow drop_everything because\nSELECT 1;"})
+		assert.ErrorContains(t, err, "unknown rule")
+	})
+
+	t.Run("Allowed Changes of Applied Migrations", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "000001_a.up.sql"), []byte("ALTER TABLE users RENAME COLUMN a TO b;\nDROP TABLE legacy;"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, migration.AllowFile), []byte("# Applied before the first release\n\n000001_a.up.sql rename no server ran yet\n"), 0o644))
+		migrations, err := migration.Load(dir)
+		require.NoError(t, err)
+		findings, err := migration.Lint(migrations[0])
+		require.NoError(t, err)
+		require.Len(t, findings, 2)
+		assert.True(t, findings[0].Allowed())
+		assert.Equal(t, "no server ran yet", findings[0].Reason)
+		assert.False(t, findings[1].Allowed())
+
+		// Allowances need a known migration, a known rule and a reason
+		for content, want := range map[string]string{
+			"000002_b.up.sql rename no server ran yet": "unknown migration",
+			"000001_a.up.sql rename":                   "reason",
+			"000001_a.up.sql renames no server ran":    "unknown rule",
+		} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, migration.AllowFile), []byte(content), 0o644))
+			_, err = migration.Load(dir)
+			assert.ErrorContains(t, err, want, content)
+		}
+	})
+
+	t.Run("Pending Migrations", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"000002_b.up.sql", "000001_a.up.sql", "000001_a.down.sql", "000003_c.up.sql", "README.md"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644))
+		}
+		migrations, err := migration.Load(dir)
+		require.NoError(t, err)
+		require.Len(t, migrations, 3)
+		assert.Equal(t, "000001_a.up.sql", migrations[0].Name)
+
+		pending := migration.Pending(migrations, 1)
+		require.Len(t, pending, 2)
+		assert.Equal(t, uint64(2), pending[0].Version)
+		assert.Empty(t, migration.Pending(migrations, 3))
+
+		// The test database is migrated without golang-migrate, so it has no recorded version
+		version, dirty, err := migration.Version(context.Background(), testPool)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), version)
+		assert.False(t, dirty)
+	})
+}
+
+func TestSchemaVerification(t *testing.T) {
+	ctx := context.Background()
+	files, err := findMigrationFiles()
+	require.NoError(t, err)
+	migrations, err := migration.Load(filepath.Dir(files[0]))
+	require.NoError(t, err)
+
+	t.Run("Test Database Matches Migrations", func(t *testing.T) {
+		expected, err := migration.ExpectedSchema(ctx, testPool, migrations)
+		require.NoError(t, err)
+		live, err := migration.LiveSchema(ctx, testPool)
+		require.NoError(t, err)
+		require.Contains(t, expected.Tables, "users")
+		assert.Equal(t, migration.Column{Type: "text", NotNull: true}, expected.Tables["users"].Columns["subject_id"])
+		assert.Contains(t, expected.Tables["users"].Indexes, "idx_users_subject_id")
+		// Partitions are left out
+		assert.Contains(t, live.Tables, "body_records")
+		assert.NotContains(t, live.Tables, "body_records_default")
+		assert.Empty(t, migration.Diff(expected, live))
+
+		// The schema the migrations were applied to was rolled back
+		var schemas int
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT count(*) FROM pg_namespace WHERE nspname LIKE 'healthapp_verify_%'").Scan(&schemas))
+		assert.Zero(t, schemas)
+	})
+
+	t.Run("Early Versions", func(t *testing.T) {
+		// Before migration 000002, users had an auth0_sub column
+		expected, err := migration.ExpectedSchema(ctx, testPool, migrations[:1])
+		require.NoError(t, err)
+		live, err := migration.LiveSchema(ctx, testPool)
+		require.NoError(t, err)
+		diffs := migration.Diff(expected, live)
+		assert.Contains(t, diffs, migration.Difference{Kind: migration.Missing, Object: "column users.auth0_sub"})
+		assert.Contains(t, diffs, migration.Difference{Kind: migration.Unexpected, Object: "column users.subject_id"})
+		assert.Contains(t, diffs, migration.Difference{Kind: migration.Unexpected, Object: "table daily_targets"})
+	})
+
+	t.Run("Differences", func(t *testing.T) {
+		expected := &migration.Schema{Tables: map[string]*migration.Table{
+			"users": {
+				Columns: map[string]migration.Column{"id": {Type: "uuid", NotNull: true}, "height": {Type: "numeric(5,1)"}},
+				Indexes: map[string]string{"users_pkey": "CREATE UNIQUE INDEX users_pkey ON users USING btree (id)"},
+			},
+			"fasts": {Columns: map[string]migration.Column{"id": {Type: "uuid", NotNull: true}}, Indexes: map[string]string{}},
+		}}
+		live := &migration.Schema{Tables: map[string]*migration.Table{
+			"users": {
+				Columns: map[string]migration.Column{"id": {Type: "uuid", NotNull: true}, "height": {Type: "integer"}, "avatar_url": {Type: "text"}},
+				Indexes: map[string]string{},
+			},
+		}}
+		var got []string
+		for _, d := range migration.Diff(expected, live) {
+			got = append(got, d.String())
+		}
+		assert.Equal(t, []string{
+			"missing table fasts",
+			"changed column users.height: expected numeric(5,1), found integer",
+			"missing index users_pkey on users",
+			"unexpected column users.avatar_url",
+		}, got)
+	})
+}
+
+func TestMigrationUp(t *testing.T) {
+	ctx := context.Background()
+	files, err := findMigrationFiles()
+	require.NoError(t, err)
+	migrations, err := migration.Load(filepath.Dir(files[0]))
+	require.NoError(t, err)
+
+	// Migrations are applied to a database of their own, as serve --dev does
+	_, err = testPool.Exec(ctx, "CREATE DATABASE testdb_migrate_up")
+	require.NoError(t, err)
+	config := testPool.Config().Copy()
+	config.ConnConfig.Database = "testdb_migrate_up"
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		pool.Close()
+		_, err := testPool.Exec(ctx, "DROP DATABASE testdb_migrate_up")
+		assert.NoError(t, err)
+	})
+
+	// Up to the second migration, then the rest
+	applied, err := migration.Up(ctx, pool, migrations[:2])
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	version, dirty, err := migration.Version(ctx, pool)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[1].Version, version)
+	assert.False(t, dirty)
+
+	applied, err = migration.Up(ctx, pool, migrations)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrations)-2)
+	applied, err = migration.Up(ctx, pool, migrations)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// The migrated schema is the one of the migrations
+	expected, err := migration.ExpectedSchema(ctx, pool, migrations)
+	require.NoError(t, err)
+	live, err := migration.LiveSchema(ctx, pool)
+	require.NoError(t, err)
+	assert.Empty(t, migration.Diff(expected, live))
+
+	// A failed migration is rolled back without recording its version
+	failing := append(slices.Clone(migrations), migration.Migration{Version: 999999, Name: "999999_failing.up.sql", SQL: "CREATE TABLE failing (id INT); SELECT missing_column FROM users;"})
+	applied, err = migration.Up(ctx, pool, failing)
+	assert.ErrorContains(t, err, "999999_failing.up.sql")
+	assert.Empty(t, applied)
+	version, dirty, err = migration.Version(ctx, pool)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+	assert.False(t, dirty)
+	live, err = migration.LiveSchema(ctx, pool)
+	require.NoError(t, err)
+	assert.NotContains(t, live.Tables, "failing")
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/announce"
+	"github.com/atreya2011/health-management-api/internal/milestone"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoalMilestones(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	// 2024-01-15 is a Monday
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	goalRepo := repo.NewGoalRepository(testPool)
+	exerciseRepo := repo.NewExerciseRecordRepository(testPool)
+	pushRepo := repo.NewPushRepository(testPool)
+	job := milestone.NewJob(goalRepo, push.NewNotifier(pushRepo, mockClock), time.Minute, testLogger, mockClock)
+
+	_, err := pushRepo.RegisterDevice(ctx, testUserID, push.PlatformFCM, "token-a", mockClock.Now())
+	require.NoError(t, err)
+	minutes := int32(60)
+	_, err = goalRepo.Set(ctx, testUserID, repo.GoalTargets{WeeklyExerciseMinutes: &minutes}, mockClock.Now())
+	require.NoError(t, err)
+
+	// countMilestones counts the queued goal milestone notifications
+	countMilestones := func(t *testing.T) int {
+		t.Helper()
+		var count int
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT COUNT(*) FROM push_notifications WHERE kind = $1", push.KindGoalMilestone).Scan(&count))
+		return count
+	}
+	// exercise records an exercise of the duration at the current time
+	exercise := func(t *testing.T, duration int32) {
+		t.Helper()
+		_, err := exerciseRepo.Create(ctx, testUserID, "Running", &duration, nil, mockClock.Now(), nil, nil, repo.ExerciseEffort{}, mockClock.Now())
+		require.NoError(t, err)
+	}
+
+	t.Run("Goal Not Reached", func(t *testing.T) {
+		exercise(t, 30)
+		mockClock.SetTime(mockClock.Now().Add(time.Minute))
+		job.Check(ctx)
+		assert.Equal(t, 0, countMilestones(t))
+	})
+
+	t.Run("Goal Reached", func(t *testing.T) {
+		exercise(t, 30)
+		mockClock.SetTime(mockClock.Now().Add(time.Minute))
+		job.Check(ctx)
+		assert.Equal(t, 1, countMilestones(t))
+
+		var data map[string]string
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT data FROM push_notifications WHERE kind = $1", push.KindGoalMilestone).Scan(&data))
+		assert.Equal(t, map[string]string{"goal": milestone.GoalWeeklyExerciseMinutes, "week_start": "2024-01-15"}, data)
+	})
+
+	t.Run("Reached Goals Are Announced Once a Week", func(t *testing.T) {
+		exercise(t, 30)
+		mockClock.SetTime(mockClock.Now().Add(time.Minute))
+		job.Check(ctx)
+		assert.Equal(t, 1, countMilestones(t))
+
+		// A job of a restarted server checks the whole week again
+		restarted := milestone.NewJob(goalRepo, push.NewNotifier(pushRepo, mockClock), time.Minute, testLogger, mockClock)
+		restarted.Check(ctx)
+		assert.Equal(t, 1, countMilestones(t))
+
+		// The goal is announced again once reached in the next week
+		mockClock.SetTime(time.Date(2024, 1, 22, 10, 0, 0, 0, time.UTC))
+		exercise(t, 60)
+		mockClock.SetTime(mockClock.Now().Add(time.Minute))
+		job.Check(ctx)
+		assert.Equal(t, 2, countMilestones(t))
+	})
+
+	t.Run("Changes of the Previous Week", func(t *testing.T) {
+		// Records of the last minutes of a week are checked against its goals after it ended
+		mockClock.SetTime(time.Date(2024, 1, 28, 23, 59, 0, 0, time.UTC))
+		job.Check(ctx)
+		diaries := int32(1)
+		_, err := goalRepo.Set(ctx, testUserID, repo.GoalTargets{WeeklyExerciseMinutes: &minutes, WeeklyDiaryEntries: &diaries}, mockClock.Now())
+		require.NoError(t, err)
+		_, err = repo.NewDiaryEntryRepository(testPool, testDiaryCipher).Create(ctx, testUserID, nil, "Late entry", mockClock.Now(), mockClock.Now())
+		require.NoError(t, err)
+
+		mockClock.SetTime(time.Date(2024, 1, 29, 0, 1, 0, 0, time.UTC))
+		job.Check(ctx)
+		var goal, weekStart string
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT data->>'goal', data->>'week_start' FROM push_notifications WHERE kind = $1 ORDER BY created_at DESC LIMIT 1", push.KindGoalMilestone).Scan(&goal, &weekStart))
+		assert.Equal(t, milestone.GoalWeeklyDiaryEntries, goal)
+		assert.Equal(t, "2024-01-22", weekStart)
+	})
+}
+
+func TestGoalMilestoneReached(t *testing.T) {
+	// weight converts kilograms to the NUMERIC values of a summary
+	weight := func(kg float64) pgtype.Numeric {
+		var n pgtype.Numeric
+		require.NoError(t, n.Scan(strconv.FormatFloat(kg, 'f', -1, 64)))
+		return n
+	}
+
+	t.Run("Target Weight", func(t *testing.T) {
+		summary := db.GetWeeklySummaryRow{}
+		summary.GoalTargetWeightKg = weight(70)
+		summary.PreviousWeightKg = weight(72)
+		summary.LastWeightKg = weight(70.5)
+		summary.WeighInCount = 1
+		assert.Empty(t, milestone.Reached(summary))
+
+		summary.LastWeightKg = weight(69.8)
+		assert.Equal(t, []string{milestone.GoalTargetWeight}, milestone.Reached(summary))
+
+		// Weights gained towards a higher target reach it too
+		summary.GoalTargetWeightKg = weight(75)
+		summary.LastWeightKg = weight(75)
+		assert.Equal(t, []string{milestone.GoalTargetWeight}, milestone.Reached(summary))
+	})
+
+	t.Run("Unset Goals", func(t *testing.T) {
+		summary := db.GetWeeklySummaryRow{}
+		summary.TotalDurationMinutes = 1000
+		summary.DiaryEntryCount = 10
+		assert.Empty(t, milestone.Reached(summary))
+	})
+}
+
+func TestColumnAnnouncements(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	euPool := newRegionPool(t, "testdb_handlers_announce_eu")
+	database := repo.NewRegionalDB(testPool, map[string]repo.DB{"eu": euPool})
+	digestRepo := repo.NewColumnDigestRepository(testPool)
+	job := announce.NewJob(repo.NewColumnRepository(testPool), push.NewNotifier(repo.NewPushRepository(database), mockClock), time.Minute, testLogger, mockClock)
+
+	// The test user reads every category; a user pinned to the region reads diet columns only
+	_, err := digestRepo.SetSettings(ctx, testUserID, true, nil)
+	require.NoError(t, err)
+	_, err = repo.NewPushRepository(testPool).RegisterDevice(ctx, testUserID, push.PlatformFCM, "token-home", mockClock.Now())
+	require.NoError(t, err)
+	euUserID := uuid.New()
+	for _, pool := range []repo.DB{testPool, euPool} {
+		_, err := pool.Exec(ctx, "INSERT INTO users (id, subject_id, data_region) VALUES ($1, 'announce-eu-user', 'eu')", euUserID)
+		require.NoError(t, err)
+	}
+	t.Cleanup(func() {
+		_, err := testPool.Exec(ctx, "DELETE FROM users WHERE id = $1", euUserID)
+		assert.NoError(t, err)
+	})
+	_, err = digestRepo.SetSettings(ctx, euUserID, true, []string{"Diet"})
+	require.NoError(t, err)
+	_, err = repo.NewPushRepository(euPool).RegisterDevice(ctx, euUserID, push.PlatformFCM, "token-eu", mockClock.Now())
+	require.NoError(t, err)
+
+	// countAnnouncements counts the queued announcements of a column in the database of pool
+	countAnnouncements := func(t *testing.T, pool repo.DB, columnID uuid.UUID) int {
+		t.Helper()
+		var count int
+		require.NoError(t, pool.QueryRow(ctx, "SELECT COUNT(*) FROM push_notifications WHERE kind = $1 AND data->>'column_id' = $2", push.KindNewColumn, columnID.String()).Scan(&count))
+		return count
+	}
+
+	dietID, exerciseID, scheduledID := uuid.New(), uuid.New(), uuid.New()
+	published := pgtype.Timestamptz{Time: mockClock.Now().Add(-time.Minute), Valid: true}
+	_, err = testutil.CreateTestColumn(ctx, testPool, mockClock, dietID, "Eating well", "Content", pgtype.Text{String: "diet", Valid: true}, nil, published)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestColumn(ctx, testPool, mockClock, exerciseID, "Running", "Content", pgtype.Text{String: "Exercise", Valid: true}, nil, published)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestColumn(ctx, testPool, mockClock, scheduledID, "Sleep", "Content", pgtype.Text{String: "Diet", Valid: true}, nil, pgtype.Timestamptz{Time: mockClock.Now().Add(time.Hour), Valid: true})
+	require.NoError(t, err)
+
+	t.Run("Published Columns Are Announced", func(t *testing.T) {
+		job.AnnouncePublished(ctx)
+		assert.Equal(t, 1, countAnnouncements(t, testPool, dietID))
+		assert.Equal(t, 1, countAnnouncements(t, testPool, exerciseID))
+		// Users pinned to a region are notified on the devices kept in its database
+		assert.Equal(t, 1, countAnnouncements(t, euPool, dietID))
+		assert.Equal(t, 0, countAnnouncements(t, euPool, exerciseID))
+		assert.Equal(t, 0, countAnnouncements(t, testPool, scheduledID))
+	})
+
+	t.Run("Columns Are Announced Once", func(t *testing.T) {
+		job.AnnouncePublished(ctx)
+		assert.Equal(t, 1, countAnnouncements(t, testPool, dietID))
+		assert.Equal(t, 1, countAnnouncements(t, euPool, dietID))
+	})
+
+	t.Run("Scheduled Columns Are Announced Once Published", func(t *testing.T) {
+		mockClock.SetTime(mockClock.Now().Add(2 * time.Hour))
+		job.AnnouncePublished(ctx)
+		assert.Equal(t, 1, countAnnouncements(t, testPool, scheduledID))
+		assert.Equal(t, 1, countAnnouncements(t, euPool, scheduledID))
+	})
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// maxMoodScore bounds the mood score and energy level of a mood record, from 1
+	maxMoodScore = 10
+	// maxMoodSymptoms bounds the symptoms of a mood record
+	maxMoodSymptoms = 20
+	// maxMoodSymptomLength bounds each symptom of a mood record, in characters
+	maxMoodSymptomLength = 100
+	// minCorrelationDays is the fewest days a correlation is computed from
+	minCorrelationDays = 7
+	// correlationsMaxDays bounds the days of a correlations request
+	correlationsMaxDays = 366
+)
+
+// MoodRecordHandler implements the mood record service RPCs
+type MoodRecordHandler struct {
+	repo       MoodRecordRepository
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewMoodRecordHandler creates a new mood record handler
+func NewMoodRecordHandler(repo MoodRecordRepository, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *MoodRecordHandler {
+	return &MoodRecordHandler{
+		repo:       repo,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// CreateMoodRecord creates a mood record for the user
+func (h *MoodRecordHandler) CreateMoodRecord(ctx context.Context, req *connect.Request[v1.CreateMoodRecordRequest]) (*connect.Response[v1.CreateMoodRecordResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	if req.Msg.MoodScore < 1 || req.Msg.MoodScore > maxMoodScore {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.MoodScoreOutOfRange, maxMoodScore))
+	}
+	if req.Msg.EnergyLevel < 1 || req.Msg.EnergyLevel > maxMoodScore {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EnergyLevelOutOfRange, maxMoodScore))
+	}
+	if len(req.Msg.Symptoms) > maxMoodSymptoms {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.SymptomsInvalid, maxMoodSymptoms, maxMoodSymptomLength))
+	}
+	symptoms := make([]string, 0, len(req.Msg.Symptoms))
+	for _, s := range req.Msg.Symptoms {
+		s = strings.TrimSpace(s)
+		if s == "" || utf8.RuneCountInString(s) > maxMoodSymptomLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.SymptomsInvalid, maxMoodSymptoms, maxMoodSymptomLength))
+		}
+		symptoms = append(symptoms, s)
+	}
+	now := h.clock.Now()
+	recordedAt := now
+	if req.Msg.RecordedAt != nil {
+		recordedAt = req.Msg.RecordedAt.AsTime()
+		if recordedAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("recorded_at cannot be in the future"))
+		}
+	}
+
+	h.log.InfoContext(ctx, "Creating mood record", "recordedAt", recordedAt)
+	created, err := h.repo.Create(ctx, userID, int16(req.Msg.MoodScore), int16(req.Msg.EnergyLevel), symptoms, recordedAt, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create mood record", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create mood record"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateMoodRecordResponse{
+		MoodRecord: ToProtoMoodRecord(created),
+	})
+
+	return res, nil
+}
+
+// ListMoodRecords lists mood records for the authenticated user
+func (h *MoodRecordHandler) ListMoodRecords(ctx context.Context, req *connect.Request[v1.ListMoodRecordsRequest]) (*connect.Response[v1.ListMoodRecordsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Fetching mood records for user", "page", page.Number, "pageSize", page.Size)
+	records, err := h.repo.FindByUser(ctx, userID, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch mood records", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch mood records"))
+	}
+
+	total, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count mood records", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count mood records"))
+	}
+
+	protoRecords := make([]*v1.MoodRecord, len(records))
+	for i, record := range records {
+		protoRecords[i] = ToProtoMoodRecord(record)
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListMoodRecordsResponse{
+		MoodRecords: protoRecords,
+		Pagination:  page.Response(total),
+	})
+
+	return res, nil
+}
+
+// DeleteMoodRecord deletes a mood record
+func (h *MoodRecordHandler) DeleteMoodRecord(ctx context.Context, req *connect.Request[v1.DeleteMoodRecordRequest]) (*connect.Response[v1.DeleteMoodRecordResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse record ID
+	recordID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid record ID", "recordID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Deleting mood record", "recordID", recordID)
+	if err := h.repo.Delete(ctx, recordID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrMoodRecordNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("mood record not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete mood record", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete mood record"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteMoodRecordResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// GetCorrelations correlates the user's daily metrics with their daily average mood and energy
+func (h *MoodRecordHandler) GetCorrelations(ctx context.Context, req *connect.Request[v1.GetCorrelationsRequest]) (*connect.Response[v1.GetCorrelationsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	startDate, err := reqparse.ParseDate("start_date", req.Msg.StartDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	endDate, err := reqparse.ParseDate("end_date", req.Msg.EndDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	if endDate.Before(startDate) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+	if endDate.After(startDate.AddDate(0, 0, correlationsMaxDays)) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("date range exceeds maximum allowed length (%d days)", correlationsMaxDays))
+	}
+
+	days, err := h.repo.DailyMetrics(ctx, userID, startDate, endDate)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get daily mood metrics", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get correlations"))
+	}
+
+	// Create response
+	resp := &v1.GetCorrelationsResponse{
+		Correlations: []*v1.MoodCorrelation{
+			moodCorrelation(v1.MoodCorrelationMetric_MOOD_CORRELATION_METRIC_STEPS, days, func(d db.ListDailyMoodMetricsRow) (float64, bool) {
+				return float64(d.Steps.Int32), d.Steps.Valid
+			}),
+			moodCorrelation(v1.MoodCorrelationMetric_MOOD_CORRELATION_METRIC_EXERCISE_MINUTES, days, func(d db.ListDailyMoodMetricsRow) (float64, bool) {
+				return float64(d.ExerciseMinutes), true
+			}),
+			moodCorrelation(v1.MoodCorrelationMetric_MOOD_CORRELATION_METRIC_CALORIES_CONSUMED, days, func(d db.ListDailyMoodMetricsRow) (float64, bool) {
+				return float64(d.CaloriesConsumed), d.MealCount > 0
+			}),
+		},
+		MoodDays: int32(len(days)),
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// moodCorrelation correlates a daily metric, taken from the days it is known on, with the daily
+// average mood and energy
+func moodCorrelation(metric v1.MoodCorrelationMetric, days []db.ListDailyMoodMetricsRow, value func(db.ListDailyMoodMetricsRow) (float64, bool)) *v1.MoodCorrelation {
+	var values, moods, energies []float64
+	for _, d := range days {
+		if v, ok := value(d); ok {
+			values = append(values, v)
+			moods = append(moods, d.Mood)
+			energies = append(energies, d.Energy)
+		}
+	}
+	correlation := &v1.MoodCorrelation{Metric: metric, Days: int32(len(values))}
+	if len(values) < minCorrelationDays {
+		return correlation
+	}
+	if r, ok := pearson(values, moods); ok {
+		correlation.Mood = wrapperspb.Double(r)
+	}
+	if r, ok := pearson(values, energies); ok {
+		correlation.Energy = wrapperspb.Double(r)
+	}
+	return correlation
+}
+
+// pearson returns the Pearson correlation coefficient of xs and ys, rounded to 2 decimals, or
+// false if either is constant
+func pearson(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return math.Round(cov/math.Sqrt(varX*varY)*100) / 100, true
+}
+
+// ToProtoMoodRecord converts a db.MoodRecord to a v1.MoodRecord
+func ToProtoMoodRecord(record db.MoodRecord) *v1.MoodRecord {
+	return &v1.MoodRecord{
+		Id:          record.ID.String(),
+		UserId:      record.UserID.String(),
+		MoodScore:   int32(record.MoodScore),
+		EnergyLevel: int32(record.EnergyLevel),
+		Symptoms:    record.Symptoms,
+		RecordedAt:  timestamppb.New(record.RecordedAt),
+		CreatedAt:   timestamppb.New(record.CreatedAt),
+		UpdatedAt:   timestamppb.New(record.UpdatedAt),
+	}
+}
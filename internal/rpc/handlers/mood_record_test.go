@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestMoodRecordHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 16, 12, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	handler := NewMoodRecordHandler(repo.NewMoodRecordRepository(testPool), DefaultPageLimits, testLogger, mockClock)
+
+	t.Run("Create And List", func(t *testing.T) {
+		resp, err := handler.CreateMoodRecord(testCtx, connect.NewRequest(&v1.CreateMoodRecordRequest{
+			MoodScore:   3,
+			EnergyLevel: 2,
+			Symptoms:    []string{" headache ", "tired"},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), resp.Msg.MoodRecord.MoodScore)
+		assert.Equal(t, []string{"headache", "tired"}, resp.Msg.MoodRecord.Symptoms)
+		assert.Equal(t, fixedTime, resp.Msg.MoodRecord.RecordedAt.AsTime())
+
+		_, err = handler.CreateMoodRecord(testCtx, connect.NewRequest(&v1.CreateMoodRecordRequest{
+			MoodScore:   8,
+			EnergyLevel: 7,
+			RecordedAt:  timestamppb.New(fixedTime.Add(-time.Hour)),
+		}))
+		require.NoError(t, err)
+
+		list, err := handler.ListMoodRecords(testCtx, connect.NewRequest(&v1.ListMoodRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, list.Msg.MoodRecords, 2)
+		assert.Equal(t, int32(3), list.Msg.MoodRecords[0].MoodScore)
+		assert.Empty(t, list.Msg.MoodRecords[1].Symptoms)
+		assert.Equal(t, int32(2), list.Msg.Pagination.TotalItems)
+	})
+
+	t.Run("Invalid Records", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateMoodRecordRequest{
+			"No Mood":       {EnergyLevel: 5},
+			"Mood Too High": {MoodScore: 11, EnergyLevel: 5},
+			"No Energy":     {MoodScore: 5},
+			"Empty Symptom": {MoodScore: 5, EnergyLevel: 5, Symptoms: []string{" "}},
+			"Future":        {MoodScore: 5, EnergyLevel: 5, RecordedAt: timestamppb.New(fixedTime.Add(time.Hour))},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.CreateMoodRecord(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Delete Is Owner Only", func(t *testing.T) {
+		created, err := handler.CreateMoodRecord(testCtx, connect.NewRequest(&v1.CreateMoodRecordRequest{MoodScore: 5, EnergyLevel: 5}))
+		require.NoError(t, err)
+
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+		_, err = handler.DeleteMoodRecord(otherCtx, connect.NewRequest(&v1.DeleteMoodRecordRequest{Id: created.Msg.MoodRecord.Id}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.DeleteMoodRecord(testCtx, connect.NewRequest(&v1.DeleteMoodRecordRequest{Id: created.Msg.MoodRecord.Id}))
+		require.NoError(t, err)
+	})
+
+	t.Run("Correlations", func(t *testing.T) {
+		resetDB(t, testPool)
+		stepHandler := NewStepHandler(repo.NewStepRecordRepository(testPool), testLogger, mockClock)
+		// Eight days on which mood rises with the exercise minutes, at a constant energy
+		for i := range 8 {
+			day := time.Date(2024, 1, 1+i, 9, 0, 0, 0, time.UTC)
+			_, err := handler.CreateMoodRecord(testCtx, connect.NewRequest(&v1.CreateMoodRecordRequest{
+				MoodScore:   int32(i + 1),
+				EnergyLevel: 5,
+				RecordedAt:  timestamppb.New(day),
+			}))
+			require.NoError(t, err)
+			minutes := int32(10 * (i + 1))
+			_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, testUserID, "Running", &minutes, nil, day, fixedTime)
+			require.NoError(t, err)
+		}
+		// Steps of only three days
+		_, err := stepHandler.BatchUpsertSteps(testCtx, connect.NewRequest(&v1.BatchUpsertStepsRequest{
+			Buckets: []*v1.StepBucket{
+				{Hour: timestamppb.New(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)), Source: "phone", Steps: 1000},
+				{Hour: timestamppb.New(time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)), Source: "phone", Steps: 2000},
+				{Hour: timestamppb.New(time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)), Source: "phone", Steps: 3000},
+			},
+		}))
+		require.NoError(t, err)
+
+		resp, err := handler.GetCorrelations(testCtx, connect.NewRequest(&v1.GetCorrelationsRequest{
+			StartDate: "2024-01-01",
+			EndDate:   "2024-01-31",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(8), resp.Msg.MoodDays)
+		require.Len(t, resp.Msg.Correlations, 3)
+
+		steps, exercise, calories := resp.Msg.Correlations[0], resp.Msg.Correlations[1], resp.Msg.Correlations[2]
+		assert.Equal(t, v1.MoodCorrelationMetric_MOOD_CORRELATION_METRIC_STEPS, steps.Metric)
+		assert.Equal(t, int32(3), steps.Days)
+		assert.Nil(t, steps.Mood, "too few days")
+
+		assert.Equal(t, v1.MoodCorrelationMetric_MOOD_CORRELATION_METRIC_EXERCISE_MINUTES, exercise.Metric)
+		assert.Equal(t, int32(8), exercise.Days)
+		require.NotNil(t, exercise.Mood)
+		assert.Equal(t, 1.0, exercise.Mood.Value)
+		assert.Nil(t, exercise.Energy, "constant energy")
+
+		// No meals were logged
+		assert.Equal(t, int32(0), calories.Days)
+		assert.Nil(t, calories.Mood)
+	})
+
+	t.Run("Invalid Correlation Ranges", func(t *testing.T) {
+		for name, req := range map[string]*v1.GetCorrelationsRequest{
+			"No Dates":         {},
+			"End Before Start": {StartDate: "2024-01-31", EndDate: "2024-01-01"},
+			"Too Long":         {StartDate: "2022-01-01", EndDate: "2024-01-01"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.GetCorrelations(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+	})
+}
+
+func TestPearson(t *testing.T) {
+	r, ok := pearson([]float64{1, 2, 3, 4}, []float64{8, 6, 4, 2})
+	require.True(t, ok)
+	assert.Equal(t, -1.0, r)
+
+	r, ok = pearson([]float64{1, 2, 3, 4, 5}, []float64{2, 1, 4, 3, 5})
+	require.True(t, ok)
+	assert.Equal(t, 0.8, r)
+
+	_, ok = pearson([]float64{1, 2, 3}, []float64{5, 5, 5})
+	assert.False(t, ok)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/msgsize"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageSizeLimits(t *testing.T) {
+	// call posts a JSON body of size bytes to GetServerInfo through a handler allowing maxBytes by
+	// default and overrides by procedure; chunked bodies have no Content-Length
+	call := func(t *testing.T, size int, chunked bool, maxBytes int64, overrides map[string]int64) *httptest.ResponseRecorder {
+		t.Helper()
+		_, service := healthappv1connect.NewServerServiceHandler(NewServerHandler(version.Get(), testLogger), msgsize.HandlerOption(maxBytes, overrides))
+		handler := msgsize.Handler(service, maxBytes, overrides)
+		body := "{}" + strings.Repeat(" ", size-2)
+		req := httptest.NewRequest(http.MethodPost, healthappv1connect.ServerServiceGetServerInfoProcedure, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	// assertRejected checks that rec is a Connect invalid_argument error with reason limit_exceeded
+	assertRejected := func(t *testing.T, rec *httptest.ResponseRecorder) {
+		t.Helper()
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var wireErr struct {
+			Code string `json:"code"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wireErr))
+		assert.Equal(t, connect.CodeInvalidArgument.String(), wireErr.Code)
+		assert.Equal(t, apierror.ReasonLimitExceeded, rec.Header().Get(apierror.ReasonMetadataKey))
+	}
+
+	t.Run("Messages Within the Limit", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, call(t, 64, false, 64, nil).Code)
+		assert.Equal(t, http.StatusOK, call(t, 64, true, 64, nil).Code)
+	})
+
+	t.Run("Larger Messages Are Rejected", func(t *testing.T) {
+		assertRejected(t, call(t, 65, false, 64, nil))
+		assertRejected(t, call(t, 65, true, 64, nil))
+	})
+
+	t.Run("Limits by Procedure", func(t *testing.T) {
+		overrides := map[string]int64{healthappv1connect.ServerServiceGetServerInfoProcedure: 1024}
+		assert.Equal(t, http.StatusOK, call(t, 1000, false, 64, overrides).Code)
+		assertRejected(t, call(t, 1025, true, 64, overrides))
+	})
+}
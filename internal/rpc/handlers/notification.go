@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxDeviceTokenLength bounds device tokens; FCM tokens are about 160 characters, APNs tokens 64
+const maxDeviceTokenLength = 4096
+
+// devicePlatformNames maps proto platforms to the platform names stored in the database
+var devicePlatformNames = map[v1.DevicePlatform]string{
+	v1.DevicePlatform_DEVICE_PLATFORM_FCM:  push.PlatformFCM,
+	v1.DevicePlatform_DEVICE_PLATFORM_APNS: push.PlatformAPNs,
+}
+
+// NotificationHandler implements the notification service RPCs
+type NotificationHandler struct {
+	repo PushRepository
+	// platforms are the platforms notifications can be delivered to
+	platforms map[string]bool
+	log       *slog.Logger
+	clock     clock.Clock
+}
+
+// NewNotificationHandler creates a new notification handler accepting devices of the given platforms
+func NewNotificationHandler(repo PushRepository, platforms []string, log *slog.Logger, clock clock.Clock) *NotificationHandler {
+	enabled := make(map[string]bool, len(platforms))
+	for _, p := range platforms {
+		enabled[p] = true
+	}
+	return &NotificationHandler{
+		repo:      repo,
+		platforms: enabled,
+		log:       log,
+		clock:     clock,
+	}
+}
+
+// RegisterDevice stores the push token of a device of the user
+func (h *NotificationHandler) RegisterDevice(ctx context.Context, req *connect.Request[v1.RegisterDeviceRequest]) (*connect.Response[v1.RegisterDeviceResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	platform, err := h.platform(req.Msg.Platform)
+	if err != nil {
+		return nil, err
+	}
+	if req.Msg.Token == "" || len(req.Msg.Token) > maxDeviceTokenLength {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DeviceTokenInvalid))
+	}
+
+	device, err := h.repo.RegisterDevice(ctx, userID, platform, req.Msg.Token, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to register device", "platform", platform, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to register device"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.RegisterDeviceResponse{
+		Device: ToProtoDevice(device),
+	})
+
+	return res, nil
+}
+
+// UnregisterDevice deletes the push token of a device of the user
+func (h *NotificationHandler) UnregisterDevice(ctx context.Context, req *connect.Request[v1.UnregisterDeviceRequest]) (*connect.Response[v1.UnregisterDeviceResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input; devices of disabled platforms can still be unregistered
+	platform, ok := devicePlatformNames[req.Msg.Platform]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedPlatform))
+	}
+
+	if err := h.repo.UnregisterDevice(ctx, userID, platform, req.Msg.Token); err != nil {
+		if errors.Is(err, repo.ErrDeviceNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("device not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to unregister device", "platform", platform, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to unregister device"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UnregisterDeviceResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// ListDevices lists the devices registered by the user
+func (h *NotificationHandler) ListDevices(ctx context.Context, req *connect.Request[v1.ListDevicesRequest]) (*connect.Response[v1.ListDevicesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	devices, err := h.repo.FindDevicesByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list devices", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list devices"))
+	}
+
+	// Create response
+	resp := &v1.ListDevicesResponse{
+		Devices: make([]*v1.Device, 0, len(devices)),
+	}
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, ToProtoDevice(d))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// platform returns the platform name of a proto platform, rejecting platforms not enabled on this server
+func (h *NotificationHandler) platform(p v1.DevicePlatform) (string, error) {
+	name, ok := devicePlatformNames[p]
+	if !ok {
+		return "", connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedPlatform))
+	}
+	if !h.platforms[name] {
+		return "", connect.NewError(connect.CodeFailedPrecondition, errors.New("platform is not enabled on this server"))
+	}
+	return name, nil
+}
+
+// ToProtoDevice converts a db.DeviceToken to a v1.Device
+func ToProtoDevice(d db.DeviceToken) *v1.Device {
+	protoDevice := &v1.Device{
+		Id:           d.ID.String(),
+		Token:        d.Token,
+		RegisteredAt: timestamppb.New(d.UpdatedAt),
+		Platform:     v1.DevicePlatform_DEVICE_PLATFORM_UNSPECIFIED,
+	}
+
+	for p, name := range devicePlatformNames {
+		if name == d.Platform {
+			protoDevice.Platform = p
+		}
+	}
+
+	return protoDevice
+}
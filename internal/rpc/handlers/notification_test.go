@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender records the messages it delivers and fails with the error of the token, if any
+type fakeSender struct {
+	errs map[string]error
+	sent map[string][]push.Message
+}
+
+func (s *fakeSender) Platform() string {
+	return push.PlatformFCM
+}
+
+func (s *fakeSender) Send(ctx context.Context, token string, msg push.Message) error {
+	if err := s.errs[token]; err != nil {
+		return err
+	}
+	s.sent[token] = append(s.sent[token], msg)
+	return nil
+}
+
+func TestNotificationHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	pushRepo := repo.NewPushRepository(testPool)
+	handler := NewNotificationHandler(pushRepo, []string{push.PlatformFCM}, testLogger, mockClock)
+
+	register := func(t *testing.T, token string) {
+		t.Helper()
+		_, err := handler.RegisterDevice(testCtx, connect.NewRequest(&v1.RegisterDeviceRequest{
+			Platform: v1.DevicePlatform_DEVICE_PLATFORM_FCM,
+			Token:    token,
+		}))
+		require.NoError(t, err)
+	}
+
+	t.Run("Register Device", func(t *testing.T) {
+		register(t, "token-a")
+		// Registering again refreshes the device instead of duplicating it
+		register(t, "token-a")
+
+		resp, err := handler.ListDevices(testCtx, connect.NewRequest(&v1.ListDevicesRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Devices, 1)
+		assert.Equal(t, "token-a", resp.Msg.Devices[0].Token)
+		assert.Equal(t, v1.DevicePlatform_DEVICE_PLATFORM_FCM, resp.Msg.Devices[0].Platform)
+	})
+
+	t.Run("Disabled Platform", func(t *testing.T) {
+		_, err := handler.RegisterDevice(testCtx, connect.NewRequest(&v1.RegisterDeviceRequest{
+			Platform: v1.DevicePlatform_DEVICE_PLATFORM_APNS,
+			Token:    "apns-token",
+		}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("Missing Token", func(t *testing.T) {
+		_, err := handler.RegisterDevice(testCtx, connect.NewRequest(&v1.RegisterDeviceRequest{
+			Platform: v1.DevicePlatform_DEVICE_PLATFORM_FCM,
+		}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Unregister Device", func(t *testing.T) {
+		register(t, "token-b")
+		_, err := handler.UnregisterDevice(testCtx, connect.NewRequest(&v1.UnregisterDeviceRequest{
+			Platform: v1.DevicePlatform_DEVICE_PLATFORM_FCM,
+			Token:    "token-b",
+		}))
+		require.NoError(t, err)
+
+		_, err = handler.UnregisterDevice(testCtx, connect.NewRequest(&v1.UnregisterDeviceRequest{
+			Platform: v1.DevicePlatform_DEVICE_PLATFORM_FCM,
+			Token:    "token-b",
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Deliver Notifications", func(t *testing.T) {
+		register(t, "token-retry")
+		register(t, "token-gone")
+		sender := &fakeSender{
+			errs: map[string]error{
+				"token-retry": &push.TransientError{Err: errors.New("unavailable")},
+				"token-gone":  push.ErrInvalidToken,
+			},
+			sent: map[string][]push.Message{},
+		}
+		worker := push.NewWorker(pushRepo, []push.Sender{sender}, time.Minute, testLogger, mockClock)
+		notifier := push.NewNotifier(pushRepo, mockClock)
+		require.NoError(t, notifier.Notify(ctx, testUserID, push.Message{
+			Kind:  push.KindNewColumn,
+			Title: "New column",
+			Body:  "Eating well in winter",
+			Data:  map[string]string{"column_id": "42"},
+		}))
+
+		worker.DeliverDue(ctx)
+		require.Len(t, sender.sent["token-a"], 1)
+		assert.Equal(t, "New column", sender.sent["token-a"][0].Title)
+		assert.Equal(t, "42", sender.sent["token-a"][0].Data["column_id"])
+
+		// Rejected tokens are unregistered
+		resp, err := handler.ListDevices(testCtx, connect.NewRequest(&v1.ListDevicesRequest{}))
+		require.NoError(t, err)
+		var tokens []string
+		for _, d := range resp.Msg.Devices {
+			tokens = append(tokens, d.Token)
+		}
+		assert.ElementsMatch(t, []string{"token-a", "token-retry"}, tokens)
+
+		// Transient failures are retried after a backoff, sent notifications are not sent again
+		delete(sender.errs, "token-retry")
+		worker.DeliverDue(ctx)
+		assert.Empty(t, sender.sent["token-retry"])
+
+		mockClock.SetTime(mockClock.Now().Add(time.Minute))
+		worker.DeliverDue(ctx)
+		assert.Len(t, sender.sent["token-retry"], 1)
+		assert.Len(t, sender.sent["token-a"], 1)
+	})
+}
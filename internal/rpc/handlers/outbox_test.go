@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/outbox"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	outboxRepo := repo.NewOutboxRepository(testPool)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+
+	// The webhook records the requests it accepted, and fails while failing is set
+	var (
+		mu        sync.Mutex
+		failing   bool
+		delivered []outbox.Event
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, outbox.Sign([]byte("webhook-secret"), r.Header.Get(outbox.HeaderTimestamp), body), r.Header.Get(outbox.HeaderSignature))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event outbox.Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, event.ID, r.Header.Get(outbox.HeaderEventID))
+		delivered = append(delivered, event)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	publisher := outbox.NewWebhookPublisher(server.URL, "webhook-secret", 5*time.Second, mockClock)
+	relay := outbox.NewRelay(outboxRepo, publisher, time.Second, 24*time.Hour, testLogger, mockClock)
+
+	// status reads the publish state of the outbox events of the test user
+	status := func(t *testing.T) (statuses []string, attempts []int32) {
+		t.Helper()
+		rows, err := testPool.Query(ctx, "SELECT status, attempts FROM outbox_events WHERE user_id = $1 ORDER BY created_at", testUserID)
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var s string
+			var a int32
+			require.NoError(t, rows.Scan(&s, &a))
+			statuses = append(statuses, s)
+			attempts = append(attempts, a)
+		}
+		require.NoError(t, rows.Err())
+		return statuses, attempts
+	}
+
+	t.Run("Changes Are Published as Events", func(t *testing.T) {
+		entry, err := diaryRepo.Create(ctx, testUserID, nil, "Private thoughts", fixedTime, fixedTime)
+		require.NoError(t, err)
+		mockClock.SetTime(fixedTime.Add(time.Minute))
+		_, err = diaryRepo.Update(ctx, entry.ID, testUserID, nil, "Second thoughts", fixedTime.Add(time.Minute))
+		require.NoError(t, err)
+
+		relay.PublishDue(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, delivered, 2)
+		assert.Equal(t, "diary_entry.created", delivered[0].Type)
+		assert.Equal(t, "diary_entry.updated", delivered[1].Type)
+		assert.Equal(t, testUserID.String(), delivered[0].UserID)
+		assert.Equal(t, fixedTime, delivered[0].OccurredAt)
+
+		var data repo.RecordChangedEvent
+		require.NoError(t, json.Unmarshal(delivered[0].Data, &data))
+		assert.Equal(t, repo.RecordChangedEvent{
+			EntityType: repo.EntityTypeDiaryEntry,
+			EntityID:   entry.ID,
+			Action:     repo.ChangeActionCreated,
+			Source:     repo.ChangeSourceUser,
+		}, data)
+		// Events only carry IDs
+		assert.NotContains(t, string(delivered[0].Data), "thoughts")
+
+		statuses, _ := status(t)
+		assert.Equal(t, []string{"published", "published"}, statuses)
+	})
+
+	t.Run("No Event Without A Change", func(t *testing.T) {
+		_, err := diaryRepo.Update(ctx, uuid.New(), testUserID, nil, "Missing", fixedTime)
+		require.ErrorIs(t, err, repo.ErrDiaryEntryNotFound)
+		statuses, _ := status(t)
+		assert.Len(t, statuses, 2)
+	})
+
+	t.Run("Failed Events Are Retried", func(t *testing.T) {
+		mu.Lock()
+		failing = true
+		delivered = nil
+		mu.Unlock()
+
+		_, err := diaryRepo.Create(ctx, testUserID, nil, "Retried", fixedTime.AddDate(0, 0, -1), fixedTime.Add(time.Minute))
+		require.NoError(t, err)
+		relay.PublishDue(ctx)
+		statuses, attempts := status(t)
+		assert.Equal(t, "pending", statuses[2])
+		assert.EqualValues(t, 1, attempts[2])
+
+		// Not due until the backoff elapsed
+		mu.Lock()
+		failing = false
+		mu.Unlock()
+		relay.PublishDue(ctx)
+		_, attempts = status(t)
+		assert.EqualValues(t, 1, attempts[2])
+
+		mockClock.SetTime(fixedTime.Add(2 * time.Minute))
+		relay.PublishDue(ctx)
+		statuses, attempts = status(t)
+		assert.Equal(t, "published", statuses[2])
+		assert.EqualValues(t, 2, attempts[2])
+		mu.Lock()
+		assert.Len(t, delivered, 1)
+		mu.Unlock()
+	})
+
+	t.Run("Published Events Are Deleted After Retention", func(t *testing.T) {
+		relay.DeletePublished(ctx)
+		statuses, _ := status(t)
+		assert.Len(t, statuses, 3)
+
+		mockClock.SetTime(fixedTime.Add(25 * time.Hour))
+		relay.DeletePublished(ctx)
+		statuses, _ = status(t)
+		assert.Empty(t, statuses)
+	})
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+)
+
+// PageLimits are the default and maximum page sizes of a list endpoint
+type PageLimits = reqparse.PageLimits
+
+// DefaultPageLimits are the page limits of endpoints without configured limits
+var DefaultPageLimits = PageLimits{DefaultPageSize: 20, MaxPageSize: 100}
+
+// DefaultMaxDateRangeDays is the longest range of range queries without a configured maximum, a
+// leap year
+const DefaultMaxDateRangeDays = 366
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordPartitions(t *testing.T) {
+	resetDB(t, testPool)
+	partitionRepo := repo.NewPartitionRepository(testPool)
+	ctx := context.Background()
+
+	// partitionOf inserts a body record of the test user dated date and returns its partition
+	partitionOf := func(t *testing.T, date string) string {
+		t.Helper()
+		var partition string
+		require.NoError(t, testPool.QueryRow(ctx,
+			"INSERT INTO body_records (user_id, date, weight_kg) VALUES ($1, $2, 70) RETURNING tableoid::regclass::text",
+			testUserID, date).Scan(&partition))
+		return partition
+	}
+	recordCount := func(t *testing.T) int {
+		t.Helper()
+		var count int
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT body_record_count FROM users WHERE id = $1", testUserID).Scan(&count))
+		return count
+	}
+
+	t.Run("Partitions Are Created Ahead", func(t *testing.T) {
+		created, err := partitionRepo.CreatePartitions(ctx, time.Date(2031, 1, 15, 10, 0, 0, 0, time.UTC), 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"body_records_2031_01", "body_records_2031_02", "exercise_records_2031_01", "exercise_records_2031_02"}, created)
+
+		created, err = partitionRepo.CreatePartitions(ctx, time.Date(2031, 1, 31, 23, 0, 0, 0, time.UTC), 1)
+		require.NoError(t, err)
+		assert.Empty(t, created)
+	})
+
+	t.Run("Records Are Routed to Their Month", func(t *testing.T) {
+		before := recordCount(t)
+		assert.Equal(t, "body_records_2031_02", partitionOf(t, "2031-02-28"))
+		assert.Equal(t, "body_records_default", partitionOf(t, "2031-03-01"))
+		assert.Equal(t, before+2, recordCount(t))
+
+		var partition string
+		require.NoError(t, testPool.QueryRow(ctx,
+			"INSERT INTO exercise_records (user_id, exercise_name, recorded_at) VALUES ($1, 'Running', '2031-01-31T23:30:00Z') RETURNING tableoid::regclass::text",
+			testUserID).Scan(&partition))
+		assert.Equal(t, "exercise_records_2031_01", partition)
+	})
+
+	t.Run("Months With Default Rows Are Skipped", func(t *testing.T) {
+		created, err := partitionRepo.CreatePartitions(ctx, time.Date(2031, 3, 1, 0, 0, 0, 0, time.UTC), 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"exercise_records_2031_03"}, created)
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// protoWeightUnits maps the weight units to their proto values
+var protoWeightUnits = map[units.WeightUnit]v1.WeightUnit{
+	units.Kilograms: v1.WeightUnit_WEIGHT_UNIT_KG,
+	units.Pounds:    v1.WeightUnit_WEIGHT_UNIT_LB,
+}
+
+// protoHeightUnits maps the height units to their proto values
+var protoHeightUnits = map[units.HeightUnit]v1.HeightUnit{
+	units.Centimeters: v1.HeightUnit_HEIGHT_UNIT_CM,
+	units.Inches:      v1.HeightUnit_HEIGHT_UNIT_IN,
+}
+
+// PreferenceHandler implements the preference service RPCs
+type PreferenceHandler struct {
+	repo PreferenceRepository
+	log  *slog.Logger
+}
+
+// NewPreferenceHandler creates a new preference handler
+func NewPreferenceHandler(repo PreferenceRepository, log *slog.Logger) *PreferenceHandler {
+	return &PreferenceHandler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// GetUnitPreferences returns the units the user prefers
+func (h *PreferenceHandler) GetUnitPreferences(ctx context.Context, req *connect.Request[v1.GetUnitPreferencesRequest]) (*connect.Response[v1.GetUnitPreferencesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	prefs, err := h.repo.GetUnits(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get unit preferences", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get unit preferences"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetUnitPreferencesResponse{
+		Preferences: toProtoUnitPreferences(prefs),
+	})
+
+	return res, nil
+}
+
+// UpdateUnitPreferences changes the units the user prefers, keeping those left unspecified
+func (h *PreferenceHandler) UpdateUnitPreferences(ctx context.Context, req *connect.Request[v1.UpdateUnitPreferencesRequest]) (*connect.Response[v1.UpdateUnitPreferencesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	prefs, err := h.repo.GetUnits(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get unit preferences", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update unit preferences"))
+	}
+
+	// Validate input
+	if req.Msg.WeightUnit != v1.WeightUnit_WEIGHT_UNIT_UNSPECIFIED {
+		if prefs.Weight, err = weightUnitFromProto(req.Msg.WeightUnit); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+	if req.Msg.HeightUnit != v1.HeightUnit_HEIGHT_UNIT_UNSPECIFIED {
+		if prefs.Height, err = heightUnitFromProto(req.Msg.HeightUnit); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+
+	prefs, err = h.repo.SetUnits(ctx, userID, prefs)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to set unit preferences", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update unit preferences"))
+	}
+	h.log.InfoContext(ctx, "Unit preferences updated", "weightUnit", prefs.Weight, "heightUnit", prefs.Height)
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateUnitPreferencesResponse{
+		Preferences: toProtoUnitPreferences(prefs),
+	})
+
+	return res, nil
+}
+
+// toProtoUnitPreferences converts unit preferences to their proto message
+func toProtoUnitPreferences(prefs units.Preferences) *v1.UnitPreferences {
+	return &v1.UnitPreferences{
+		WeightUnit: protoWeightUnits[prefs.Weight],
+		HeightUnit: protoHeightUnits[prefs.Height],
+	}
+}
+
+// weightUnitFromProto converts a proto weight unit, which must be specified
+func weightUnitFromProto(unit v1.WeightUnit) (units.WeightUnit, error) {
+	for u, p := range protoWeightUnits {
+		if p == unit {
+			return u, nil
+		}
+	}
+	return "", i18n.NewError(i18n.WeightUnitInvalid)
+}
+
+// heightUnitFromProto converts a proto height unit, which must be specified
+func heightUnitFromProto(unit v1.HeightUnit) (units.HeightUnit, error) {
+	for u, p := range protoHeightUnits {
+		if p == unit {
+			return u, nil
+		}
+	}
+	return "", i18n.NewError(i18n.HeightUnitInvalid)
+}
+
+// requestWeightKg returns the weight of a request in kg, given either as kg or as a weight in
+// an explicit unit; it returns nil if neither is set
+func requestWeightKg(kg *wrapperspb.DoubleValue, weight *v1.Weight) (*float64, error) {
+	switch {
+	case kg != nil && weight != nil:
+		return nil, i18n.NewError(i18n.WeightAmbiguous)
+	case kg != nil:
+		return &kg.Value, nil
+	case weight != nil:
+		unit, err := weightUnitFromProto(weight.Unit)
+		if err != nil {
+			return nil, err
+		}
+		w := unit.ToKg(weight.Value)
+		return &w, nil
+	}
+	return nil, nil
+}
+
+// toProtoWeight converts a weight in kg to a weight in unit; it returns nil for an unset weight
+func toProtoWeight(kg *wrapperspb.DoubleValue, unit units.WeightUnit) *v1.Weight {
+	if kg == nil {
+		return nil
+	}
+	return &v1.Weight{Value: unit.FromKg(kg.Value), Unit: protoWeightUnits[unit]}
+}
+
+// preferredWeightUnit returns the weight unit the authenticated user prefers
+func preferredWeightUnit(ctx context.Context, prefs PreferenceRepository, log *slog.Logger, userID uuid.UUID) (units.WeightUnit, error) {
+	p, err := prefs.GetUnits(ctx, userID)
+	if err != nil {
+		log.ErrorContext(ctx, "Failed to get unit preferences", "userID", userID, "error", err)
+		return "", connect.NewError(connect.CodeInternal, errors.New("failed to get unit preferences"))
+	}
+	return p.Weight, nil
+}
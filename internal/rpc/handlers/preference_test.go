@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestUnitPreferences(t *testing.T) {
+	resetDB(t, testPool)
+	prefsRepo := repo.NewPreferenceRepository(testPool)
+	handler := NewPreferenceHandler(prefsRepo, testLogger)
+	bodyHandler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), prefsRepo, newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	goalHandler := NewGoalHandler(repo.NewGoalRepository(testPool), prefsRepo, testLogger, mockClock)
+	dashboardHandler := NewDashboardHandler(repo.NewUserRepository(testPool), repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), repo.NewGoalRepository(testPool), repo.NewColumnRepository(testPool), repo.NewMealRecordRepository(testPool), repo.NewAchievementRepository(testPool), repo.NewSupplementRepository(testPool), repo.NewDailyTargetRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 17, 10, 0, 0, 0, time.UTC) // A Wednesday
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	t.Run("Defaults to kg and cm", func(t *testing.T) {
+		resp, err := handler.GetUnitPreferences(testCtx, connect.NewRequest(&v1.GetUnitPreferencesRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.WeightUnit_WEIGHT_UNIT_KG, resp.Msg.Preferences.WeightUnit)
+		assert.Equal(t, v1.HeightUnit_HEIGHT_UNIT_CM, resp.Msg.Preferences.HeightUnit)
+	})
+
+	t.Run("Update Keeps Unspecified Units", func(t *testing.T) {
+		resp, err := handler.UpdateUnitPreferences(testCtx, connect.NewRequest(&v1.UpdateUnitPreferencesRequest{
+			HeightUnit: v1.HeightUnit_HEIGHT_UNIT_IN,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.WeightUnit_WEIGHT_UNIT_KG, resp.Msg.Preferences.WeightUnit)
+		assert.Equal(t, v1.HeightUnit_HEIGHT_UNIT_IN, resp.Msg.Preferences.HeightUnit)
+
+		resp, err = handler.UpdateUnitPreferences(testCtx, connect.NewRequest(&v1.UpdateUnitPreferencesRequest{
+			WeightUnit: v1.WeightUnit_WEIGHT_UNIT_LB,
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, v1.WeightUnit_WEIGHT_UNIT_LB, resp.Msg.Preferences.WeightUnit)
+		assert.Equal(t, v1.HeightUnit_HEIGHT_UNIT_IN, resp.Msg.Preferences.HeightUnit)
+
+		_, err = handler.UpdateUnitPreferences(testCtx, connect.NewRequest(&v1.UpdateUnitPreferencesRequest{
+			WeightUnit: v1.WeightUnit(42),
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Body Records Accept Both Units", func(t *testing.T) {
+		// 154.32 lb is 70 kg
+		resp, err := bodyHandler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+			Date:   "2024-01-15",
+			Weight: &v1.Weight{Value: 154.32, Unit: v1.WeightUnit_WEIGHT_UNIT_LB},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 70.0, resp.Msg.BodyRecord.WeightKg.Value)
+		assert.Equal(t, &v1.Weight{Value: 154.32, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}, resp.Msg.BodyRecord.Weight)
+
+		resp, err = bodyHandler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+			Date:     "2024-01-16",
+			WeightKg: wrapperspb.Double(69.5),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 69.5, resp.Msg.BodyRecord.WeightKg.Value)
+		assert.Equal(t, &v1.Weight{Value: 153.22, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}, resp.Msg.BodyRecord.Weight)
+
+		list, err := bodyHandler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, list.Msg.BodyRecords, 2)
+		assert.Equal(t, v1.WeightUnit_WEIGHT_UNIT_LB, list.Msg.BodyRecords[0].Weight.Unit)
+	})
+
+	t.Run("Body Records Reject Ambiguous Weights", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateBodyRecordRequest{
+			"Both Weights": {Date: "2024-01-15", WeightKg: wrapperspb.Double(70), Weight: &v1.Weight{Value: 154.32, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}},
+			"No Unit":      {Date: "2024-01-15", Weight: &v1.Weight{Value: 154.32}},
+			"Over Maximum": {Date: "2024-01-15", Weight: &v1.Weight{Value: 1200, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := bodyHandler.CreateBodyRecord(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Goals And Weekly Summary", func(t *testing.T) {
+		resp, err := goalHandler.UpdateGoals(testCtx, connect.NewRequest(&v1.UpdateGoalsRequest{
+			TargetWeight: &v1.Weight{Value: 150, Unit: v1.WeightUnit_WEIGHT_UNIT_LB},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, 68.04, resp.Msg.Goals.TargetWeightKg.Value)
+		assert.Equal(t, &v1.Weight{Value: 150, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}, resp.Msg.Goals.TargetWeight)
+
+		summary, err := dashboardHandler.GetWeeklySummary(testCtx, connect.NewRequest(&v1.GetWeeklySummaryRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, &v1.Weight{Value: 154.32, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}, summary.Msg.Weight.StartWeight)
+		assert.Equal(t, &v1.Weight{Value: 153.22, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}, summary.Msg.Weight.EndWeight)
+		assert.Equal(t, &v1.Weight{Value: -1.1, Unit: v1.WeightUnit_WEIGHT_UNIT_LB}, summary.Msg.Weight.Change)
+		require.Len(t, summary.Msg.GoalProgress, 1)
+		assert.Equal(t, 150.0, summary.Msg.GoalProgress[0].Target)
+		assert.Equal(t, 153.22, summary.Msg.GoalProgress[0].Actual)
+	})
+
+	t.Run("New Users Default To Metric", func(t *testing.T) {
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		prefs, err := prefsRepo.GetUnits(ctx, otherUserID)
+		require.NoError(t, err)
+		assert.Equal(t, units.DefaultPreferences, prefs)
+	})
+}
+
+func TestUnitConversion(t *testing.T) {
+	assert.Equal(t, 70.0, units.Kilograms.FromKg(70))
+	assert.Equal(t, 154.32, units.Pounds.FromKg(70))
+	assert.InDelta(t, 70.0, units.Pounds.ToKg(154.3236), 0.0001)
+	assert.Equal(t, 70.87, units.Inches.FromCm(180))
+	assert.InDelta(t, 180.0, units.Inches.ToCm(70.866), 0.001)
+	assert.True(t, units.Pounds.Valid())
+	assert.False(t, units.WeightUnit("st").Valid())
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	// maxRecipesPerUser bounds the recipes a user can create
+	maxRecipesPerUser = 200
+	// maxRecipeServings bounds the servings a recipe makes
+	maxRecipeServings = 100
+	// maxRecipeIngredients bounds the ingredients of a recipe
+	maxRecipeIngredients = 50
+	// maxIngredientQuantityG bounds the grams of an ingredient in a recipe
+	maxIngredientQuantityG = 10000
+	// maxCaloriesPer100g is above the energy density of pure fat, in kcal
+	maxCaloriesPer100g = 900
+	// maxLoggedServings bounds the servings of a recipe logged as one meal
+	maxLoggedServings = 20
+)
+
+// RecipeHandler implements the recipe service RPCs
+type RecipeHandler struct {
+	repo  RecipeRepository
+	meals MealRecordRepository // Stores the meals logged from recipes
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewRecipeHandler creates a new recipe handler
+func NewRecipeHandler(repo RecipeRepository, meals MealRecordRepository, log *slog.Logger, clock clock.Clock) *RecipeHandler {
+	return &RecipeHandler{
+		repo:  repo,
+		meals: meals,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// CreateRecipe creates a recipe for the user
+func (h *RecipeHandler) CreateRecipe(ctx context.Context, req *connect.Request[v1.CreateRecipeRequest]) (*connect.Response[v1.CreateRecipeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	fields, err := recipeFields(req.Msg.Name, req.Msg.Servings, req.Msg.Ingredients)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count recipes", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create recipe"))
+	}
+	if count >= maxRecipesPerUser {
+		return nil, apierror.New(connect.CodeResourceExhausted, apierror.ReasonLimitExceeded, fmt.Errorf("too many recipes (maximum %d)", maxRecipesPerUser))
+	}
+
+	created, err := h.repo.Create(ctx, userID, fields, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrRecipeExists) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, errors.New("a recipe with this name already exists"))
+		}
+		h.log.ErrorContext(ctx, "Failed to create recipe", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create recipe"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateRecipeResponse{
+		Recipe: ToProtoRecipe(created),
+	})
+
+	return res, nil
+}
+
+// GetRecipe gets a recipe of the user
+func (h *RecipeHandler) GetRecipe(ctx context.Context, req *connect.Request[v1.GetRecipeRequest]) (*connect.Response[v1.GetRecipeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	recipeID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid recipe ID", "recipeID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	recipe, err := h.repo.FindByID(ctx, recipeID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrRecipeNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("recipe not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to get recipe", "recipeID", recipeID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get recipe"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetRecipeResponse{
+		Recipe: ToProtoRecipe(recipe),
+	})
+
+	return res, nil
+}
+
+// ListRecipes lists the recipes of the user
+func (h *RecipeHandler) ListRecipes(ctx context.Context, req *connect.Request[v1.ListRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	recipes, err := h.repo.FindByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list recipes", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list recipes"))
+	}
+
+	// Create response
+	resp := &v1.ListRecipesResponse{
+		Recipes: make([]*v1.Recipe, 0, len(recipes)),
+	}
+	for _, r := range recipes {
+		resp.Recipes = append(resp.Recipes, ToProtoRecipe(r))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// UpdateRecipe replaces a recipe of the user
+func (h *RecipeHandler) UpdateRecipe(ctx context.Context, req *connect.Request[v1.UpdateRecipeRequest]) (*connect.Response[v1.UpdateRecipeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	recipeID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid recipe ID", "recipeID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	fields, err := recipeFields(req.Msg.Name, req.Msg.Servings, req.Msg.Ingredients)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.repo.Update(ctx, recipeID, userID, fields, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrRecipeNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("recipe not found"))
+		}
+		if errors.Is(err, repo.ErrRecipeExists) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, errors.New("a recipe with this name already exists"))
+		}
+		h.log.ErrorContext(ctx, "Failed to update recipe", "recipeID", recipeID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update recipe"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateRecipeResponse{
+		Recipe: ToProtoRecipe(updated),
+	})
+
+	return res, nil
+}
+
+// DeleteRecipe deletes a recipe of the user
+func (h *RecipeHandler) DeleteRecipe(ctx context.Context, req *connect.Request[v1.DeleteRecipeRequest]) (*connect.Response[v1.DeleteRecipeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	recipeID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid recipe ID", "recipeID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Delete(ctx, recipeID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrRecipeNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("recipe not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete recipe", "recipeID", recipeID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete recipe"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteRecipeResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// LogRecipeMeal creates a meal record of servings of a recipe of the user, with the calories of
+// the servings rounded to whole kcal
+func (h *RecipeHandler) LogRecipeMeal(ctx context.Context, req *connect.Request[v1.LogRecipeMealRequest]) (*connect.Response[v1.LogRecipeMealResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	recipeID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid recipe ID", "recipeID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	servings := req.Msg.Servings
+	if servings == 0 {
+		servings = 1
+	}
+	if servings < 0 || servings > maxLoggedServings || math.IsNaN(servings) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ServingsOutOfRange, maxLoggedServings))
+	}
+	now := h.clock.Now()
+	eatenAt := now
+	if req.Msg.EatenAt != nil {
+		eatenAt = req.Msg.EatenAt.AsTime()
+		if eatenAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("eaten_at cannot be in the future"))
+		}
+	}
+
+	recipe, err := h.repo.FindByID(ctx, recipeID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrRecipeNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("recipe not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to get recipe", "recipeID", recipeID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to log recipe meal"))
+	}
+
+	calories := math.Round(recipe.NutritionPerServing().CaloriesKcal * servings)
+	if calories > maxMealCalories {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.MealCaloriesTooLarge, maxMealCalories))
+	}
+
+	record, err := h.meals.Create(ctx, userID, recipe.Name, int32(calories), eatenAt, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create meal record from recipe", "recipeID", recipeID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to log recipe meal"))
+	}
+	h.log.InfoContext(ctx, "Recipe meal logged", "recipeID", recipeID, "mealRecordID", record.ID)
+
+	// Create response
+	res := connect.NewResponse(&v1.LogRecipeMealResponse{
+		MealRecord: ToProtoMealRecord(record),
+	})
+
+	return res, nil
+}
+
+// recipeFields validates the fields of a create or update request
+func recipeFields(name string, servings int32, ingredients []*v1.RecipeIngredient) (repo.RecipeFields, error) {
+	fields := repo.RecipeFields{Name: strings.TrimSpace(name), Servings: servings}
+	if fields.Name == "" {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameRequired))
+	}
+	if utf8.RuneCountInString(fields.Name) > maxMealNameLength {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameTooLong, maxMealNameLength))
+	}
+	if servings < 1 || servings > maxRecipeServings {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RecipeServingsOutOfRange, maxRecipeServings))
+	}
+	if len(ingredients) == 0 || len(ingredients) > maxRecipeIngredients {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.IngredientCount, maxRecipeIngredients))
+	}
+
+	fields.Ingredients = make([]repo.RecipeIngredientFields, len(ingredients))
+	for i, ingredient := range ingredients {
+		f, err := recipeIngredientFields(ingredient)
+		if err != nil {
+			return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.IngredientInvalid, i+1, err))
+		}
+		fields.Ingredients[i] = f
+	}
+	return fields, nil
+}
+
+// recipeIngredientFields validates an ingredient of a recipe
+func recipeIngredientFields(ingredient *v1.RecipeIngredient) (repo.RecipeIngredientFields, error) {
+	fields := repo.RecipeIngredientFields{
+		Name:         strings.TrimSpace(ingredient.Name),
+		QuantityG:    ingredient.QuantityG,
+		CaloriesKcal: ingredient.CaloriesKcal,
+		FoodSource:   ingredient.FoodSource,
+		FoodID:       ingredient.FoodId,
+	}
+	if fields.Name == "" {
+		return fields, i18n.NewError(i18n.NameRequired)
+	}
+	if utf8.RuneCountInString(fields.Name) > maxMealNameLength {
+		return fields, i18n.NewError(i18n.NameTooLong, maxMealNameLength)
+	}
+	if !(fields.QuantityG > 0 && fields.QuantityG <= maxIngredientQuantityG) {
+		return fields, i18n.NewError(i18n.QuantityOutOfRange, maxIngredientQuantityG)
+	}
+	if !(fields.CaloriesKcal >= 0 && fields.CaloriesKcal <= maxCaloriesPer100g) {
+		return fields, i18n.NewError(i18n.IngredientCaloriesOutOfRange, maxCaloriesPer100g)
+	}
+	for _, macro := range []struct {
+		name  string
+		value *wrapperspb.DoubleValue
+		field **float64
+	}{
+		{"protein_g", ingredient.ProteinG, &fields.ProteinG},
+		{"carbohydrates_g", ingredient.CarbohydratesG, &fields.CarbohydratesG},
+		{"fat_g", ingredient.FatG, &fields.FatG},
+	} {
+		if macro.value == nil {
+			continue
+		}
+		if !(macro.value.Value >= 0 && macro.value.Value <= 100) {
+			return fields, i18n.NewError(i18n.MacroOutOfRange, macro.name)
+		}
+		*macro.field = &macro.value.Value
+	}
+	if (fields.FoodSource == "") != (fields.FoodID == "") {
+		return fields, i18n.NewError(i18n.FoodReferenceIncomplete)
+	}
+	if len(fields.FoodSource) > 50 || len(fields.FoodID) > 100 {
+		return fields, i18n.NewError(i18n.FoodReferenceTooLong)
+	}
+	return fields, nil
+}
+
+// ToProtoRecipe converts a repo.Recipe to a v1.Recipe
+func ToProtoRecipe(r repo.Recipe) *v1.Recipe {
+	nutrition := r.NutritionPerServing()
+	protoRecipe := &v1.Recipe{
+		Id:          r.ID.String(),
+		Name:        r.Name,
+		Servings:    r.Servings,
+		Ingredients: make([]*v1.RecipeIngredient, len(r.Ingredients)),
+		NutritionPerServing: &v1.Nutrition{
+			CaloriesKcal:   nutrition.CaloriesKcal,
+			ProteinG:       optionalDouble(nutrition.ProteinG),
+			CarbohydratesG: optionalDouble(nutrition.CarbohydratesG),
+			FatG:           optionalDouble(nutrition.FatG),
+		},
+		CreatedAt: timestamppb.New(r.CreatedAt),
+		UpdatedAt: timestamppb.New(r.UpdatedAt),
+	}
+	for i, ingredient := range r.Ingredients {
+		protoIngredient := &v1.RecipeIngredient{
+			Name:         ingredient.Name,
+			QuantityG:    ingredient.QuantityG,
+			CaloriesKcal: ingredient.CaloriesKcal,
+			FoodSource:   ingredient.FoodSource.String,
+			FoodId:       ingredient.FoodID.String,
+		}
+		if ingredient.ProteinG.Valid {
+			protoIngredient.ProteinG = wrapperspb.Double(ingredient.ProteinG.Float64)
+		}
+		if ingredient.CarbohydratesG.Valid {
+			protoIngredient.CarbohydratesG = wrapperspb.Double(ingredient.CarbohydratesG.Float64)
+		}
+		if ingredient.FatG.Valid {
+			protoIngredient.FatG = wrapperspb.Double(ingredient.FatG.Float64)
+		}
+		protoRecipe.Ingredients[i] = protoIngredient
+	}
+	return protoRecipe
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRecipeHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	mealRepo := repo.NewMealRecordRepository(testPool)
+	handler := NewRecipeHandler(repo.NewRecipeRepository(testPool), mealRepo, testLogger, mockClock)
+
+	oats := &v1.RecipeIngredient{
+		Name:           "Rolled oats",
+		QuantityG:      160,
+		CaloriesKcal:   375,
+		ProteinG:       wrapperspb.Double(13.5),
+		CarbohydratesG: wrapperspb.Double(60),
+		FatG:           wrapperspb.Double(7),
+		FoodSource:     "openfoodfacts",
+		FoodId:         "5000000000001",
+	}
+	milk := &v1.RecipeIngredient{
+		Name:           "Milk",
+		QuantityG:      500,
+		CaloriesKcal:   64,
+		ProteinG:       wrapperspb.Double(3.4),
+		CarbohydratesG: wrapperspb.Double(4.8),
+	}
+	var porridgeID string
+
+	t.Run("Create Recipe", func(t *testing.T) {
+		resp, err := handler.CreateRecipe(testCtx, connect.NewRequest(&v1.CreateRecipeRequest{
+			Name:        " Porridge ",
+			Servings:    4,
+			Ingredients: []*v1.RecipeIngredient{oats, milk},
+		}))
+		require.NoError(t, err)
+		recipe := resp.Msg.Recipe
+		porridgeID = recipe.Id
+		assert.Equal(t, "Porridge", recipe.Name)
+		assert.Equal(t, int32(4), recipe.Servings)
+		require.Len(t, recipe.Ingredients, 2)
+		assert.Equal(t, "Rolled oats", recipe.Ingredients[0].Name)
+		assert.Equal(t, "openfoodfacts", recipe.Ingredients[0].FoodSource)
+		assert.Equal(t, "5000000000001", recipe.Ingredients[0].FoodId)
+		assert.Equal(t, "Milk", recipe.Ingredients[1].Name)
+		assert.Nil(t, recipe.Ingredients[1].FatG)
+
+		// (600 + 320) kcal and (21.6 + 17) g protein over 4 servings; milk has no fat value
+		nutrition := recipe.NutritionPerServing
+		assert.InDelta(t, 230, nutrition.CaloriesKcal, 0.001)
+		assert.InDelta(t, 9.65, nutrition.ProteinG.GetValue(), 0.001)
+		assert.InDelta(t, 30, nutrition.CarbohydratesG.GetValue(), 0.001)
+		assert.Nil(t, nutrition.FatG)
+	})
+
+	t.Run("Names Are Unique Ignoring Case", func(t *testing.T) {
+		_, err := handler.CreateRecipe(testCtx, connect.NewRequest(&v1.CreateRecipeRequest{
+			Name:        "PORRIDGE",
+			Servings:    1,
+			Ingredients: []*v1.RecipeIngredient{oats},
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+	})
+
+	t.Run("Get And List Recipes", func(t *testing.T) {
+		getResp, err := handler.GetRecipe(testCtx, connect.NewRequest(&v1.GetRecipeRequest{Id: porridgeID}))
+		require.NoError(t, err)
+		assert.Len(t, getResp.Msg.Recipe.Ingredients, 2)
+
+		_, err = handler.CreateRecipe(testCtx, connect.NewRequest(&v1.CreateRecipeRequest{
+			Name:        "Oat bars",
+			Servings:    8,
+			Ingredients: []*v1.RecipeIngredient{oats},
+		}))
+		require.NoError(t, err)
+
+		listResp, err := handler.ListRecipes(testCtx, connect.NewRequest(&v1.ListRecipesRequest{}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Recipes, 2)
+		assert.Equal(t, "Oat bars", listResp.Msg.Recipes[0].Name)
+		assert.Len(t, listResp.Msg.Recipes[0].Ingredients, 1)
+		assert.Equal(t, "Porridge", listResp.Msg.Recipes[1].Name)
+		assert.Len(t, listResp.Msg.Recipes[1].Ingredients, 2)
+	})
+
+	t.Run("Log Recipe Meal", func(t *testing.T) {
+		eatenAt := fixedTime.Add(-2 * time.Hour)
+		resp, err := handler.LogRecipeMeal(testCtx, connect.NewRequest(&v1.LogRecipeMealRequest{
+			Id:       porridgeID,
+			Servings: 1.5,
+			EatenAt:  timestamppb.New(eatenAt),
+		}))
+		require.NoError(t, err)
+		meal := resp.Msg.MealRecord
+		assert.Equal(t, "Porridge", meal.Name)
+		assert.Equal(t, int32(345), meal.Calories)
+		assert.True(t, eatenAt.Equal(meal.EatenAt.AsTime()))
+
+		// One serving by default, eaten now
+		resp, err = handler.LogRecipeMeal(testCtx, connect.NewRequest(&v1.LogRecipeMealRequest{Id: porridgeID}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(230), resp.Msg.MealRecord.Calories)
+		assert.True(t, fixedTime.Equal(resp.Msg.MealRecord.EatenAt.AsTime()))
+
+		count, err := mealRepo.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Update Recipe", func(t *testing.T) {
+		resp, err := handler.UpdateRecipe(testCtx, connect.NewRequest(&v1.UpdateRecipeRequest{
+			Id:          porridgeID,
+			Name:        "Porridge",
+			Servings:    2,
+			Ingredients: []*v1.RecipeIngredient{milk},
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Recipe.Ingredients, 1)
+		assert.Equal(t, "Milk", resp.Msg.Recipe.Ingredients[0].Name)
+		assert.InDelta(t, 160, resp.Msg.Recipe.NutritionPerServing.CaloriesKcal, 0.001)
+
+		getResp, err := handler.GetRecipe(testCtx, connect.NewRequest(&v1.GetRecipeRequest{Id: porridgeID}))
+		require.NoError(t, err)
+		assert.Len(t, getResp.Msg.Recipe.Ingredients, 1)
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		for _, req := range []*v1.CreateRecipeRequest{
+			{Name: "", Servings: 1, Ingredients: []*v1.RecipeIngredient{oats}},
+			{Name: "No servings", Ingredients: []*v1.RecipeIngredient{oats}},
+			{Name: "No ingredients", Servings: 1},
+			{Name: "No quantity", Servings: 1, Ingredients: []*v1.RecipeIngredient{{Name: "Salt", CaloriesKcal: 0}}},
+			{Name: "Too dense", Servings: 1, Ingredients: []*v1.RecipeIngredient{{Name: "Butter", QuantityG: 10, CaloriesKcal: 1000}}},
+			{Name: "Too much protein", Servings: 1, Ingredients: []*v1.RecipeIngredient{{Name: "Egg", QuantityG: 50, CaloriesKcal: 150, ProteinG: wrapperspb.Double(120)}}},
+			{Name: "Source without ID", Servings: 1, Ingredients: []*v1.RecipeIngredient{{Name: "Egg", QuantityG: 50, CaloriesKcal: 150, FoodSource: "usda"}}},
+		} {
+			_, err := handler.CreateRecipe(testCtx, connect.NewRequest(req))
+			require.Error(t, err, req.Name)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), req.Name)
+		}
+
+		for _, servings := range []float64{-1, 21} {
+			_, err := handler.LogRecipeMeal(testCtx, connect.NewRequest(&v1.LogRecipeMealRequest{Id: porridgeID, Servings: servings}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+		_, err := handler.LogRecipeMeal(testCtx, connect.NewRequest(&v1.LogRecipeMealRequest{Id: porridgeID, EatenAt: timestamppb.New(fixedTime.Add(time.Hour))}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Delete Recipe", func(t *testing.T) {
+		_, err := handler.DeleteRecipe(testCtx, connect.NewRequest(&v1.DeleteRecipeRequest{Id: porridgeID}))
+		require.NoError(t, err)
+
+		_, err = handler.GetRecipe(testCtx, connect.NewRequest(&v1.GetRecipeRequest{Id: porridgeID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		_, err = handler.LogRecipeMeal(testCtx, connect.NewRequest(&v1.LogRecipeMealRequest{Id: porridgeID}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		// Meals logged from the recipe are kept
+		count, err := mealRepo.CountByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+}
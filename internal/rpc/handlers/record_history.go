@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// recordTypeEntityTypes maps proto record types to the entity types stored in record_changes
+var recordTypeEntityTypes = map[v1.RecordType]string{
+	v1.RecordType_RECORD_TYPE_BODY_RECORD:     repo.EntityTypeBodyRecord,
+	v1.RecordType_RECORD_TYPE_EXERCISE_RECORD: repo.EntityTypeExerciseRecord,
+	v1.RecordType_RECORD_TYPE_DIARY_ENTRY:     repo.EntityTypeDiaryEntry,
+}
+
+// changeActions maps the actions stored in record_changes to proto actions
+var changeActions = map[string]v1.RecordChangeAction{
+	repo.ChangeActionCreated:  v1.RecordChangeAction_RECORD_CHANGE_ACTION_CREATED,
+	repo.ChangeActionUpdated:  v1.RecordChangeAction_RECORD_CHANGE_ACTION_UPDATED,
+	repo.ChangeActionImported: v1.RecordChangeAction_RECORD_CHANGE_ACTION_IMPORTED,
+	repo.ChangeActionMerged:   v1.RecordChangeAction_RECORD_CHANGE_ACTION_MERGED,
+	repo.ChangeActionDeleted:  v1.RecordChangeAction_RECORD_CHANGE_ACTION_DELETED,
+	repo.ChangeActionRestored: v1.RecordChangeAction_RECORD_CHANGE_ACTION_RESTORED,
+}
+
+// RecordHistoryHandler implements the record history service RPCs
+type RecordHistoryHandler struct {
+	repo  RecordChangeRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewRecordHistoryHandler creates a new record history handler
+func NewRecordHistoryHandler(repo RecordChangeRepository, log *slog.Logger, clock clock.Clock) *RecordHistoryHandler {
+	return &RecordHistoryHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// GetRecordHistory returns the change history of one of the user's records
+func (h *RecordHistoryHandler) GetRecordHistory(ctx context.Context, req *connect.Request[v1.GetRecordHistoryRequest]) (*connect.Response[v1.GetRecordHistoryResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	entityType, ok := recordTypeEntityTypes[req.Msg.RecordType]
+	if !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UnsupportedRecordType))
+	}
+	recordID, err := reqparse.ParseUUID("record_id", req.Msg.RecordId)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid record ID", "recordID", req.Msg.RecordId, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	// Only the user's own history is returned
+	changes, err := h.repo.FindByRecord(ctx, userID, entityType, recordID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get record history", "recordID", recordID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get record history"))
+	}
+
+	// Create response
+	resp := &v1.GetRecordHistoryResponse{
+		Changes: make([]*v1.RecordChange, len(changes)),
+	}
+	for i, change := range changes {
+		resp.Changes[i] = ToProtoRecordChange(change)
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// ToProtoRecordChange converts a db.RecordChange to a v1.RecordChange
+func ToProtoRecordChange(change db.RecordChange) *v1.RecordChange {
+	protoChange := &v1.RecordChange{
+		Action:    changeActions[change.Action],
+		Source:    change.Source,
+		ChangedAt: timestamppb.New(change.ChangedAt),
+	}
+	if change.Details.Valid {
+		protoChange.Details = change.Details.String
+	}
+	return protoChange
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestGetRecordHistory(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	handler := NewRecordHistoryHandler(repo.NewRecordChangeRepository(testPool), testLogger, mockClock)
+	bodyHandler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	exerciseHandler := NewExerciseRecordHandler(repo.NewExerciseRecordRepository(testPool), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	importHandler := NewImportHandler(repo.NewImportRepository(testPool), testLogger, mockClock)
+
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	// A body record created by the user, then edited
+	created, err := bodyHandler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-15",
+		WeightKg: wrapperspb.Double(75),
+	}))
+	require.NoError(t, err)
+	mockClock.SetTime(fixedTime.Add(time.Hour))
+	_, err = bodyHandler.CreateBodyRecord(testCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-15",
+		WeightKg: wrapperspb.Double(74.5),
+	}))
+	require.NoError(t, err)
+
+	// A manual workout merged with an imported duplicate
+	start := fixedTime.Add(-2 * time.Hour)
+	manual, err := exerciseHandler.CreateExerciseRecord(testCtx, connect.NewRequest(&v1.CreateExerciseRecordRequest{
+		ExerciseName: "Running",
+		StartedAt:    timestamppb.New(start),
+		EndedAt:      timestamppb.New(start.Add(30 * time.Minute)),
+	}))
+	require.NoError(t, err)
+	_, err = importHandler.ImportHealthKit(testCtx, connect.NewRequest(&v1.ImportHealthKitRequest{
+		Samples: []*v1.HealthKitSample{{
+			Uuid:                  uuid.NewString(),
+			Type:                  v1.HealthKitSampleType_HEALTH_KIT_SAMPLE_TYPE_WORKOUT,
+			StartDate:             timestamppb.New(start),
+			EndDate:               timestamppb.New(start.Add(31 * time.Minute)),
+			WorkoutActivityType:   "HKWorkoutActivityTypeRunning",
+			TotalEnergyBurnedKcal: wrapperspb.Double(300),
+		}},
+	}))
+	require.NoError(t, err)
+	imported, err := repo.NewExerciseRecordRepository(testPool).FindOverlapping(ctx, testUserID, start, start.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	importedID := imported[1].ID.String()
+	if importedID == manual.Msg.ExerciseRecord.Id {
+		importedID = imported[0].ID.String()
+	}
+	mockClock.SetTime(fixedTime.Add(2 * time.Hour))
+	merged, err := exerciseHandler.MergeExerciseRecords(testCtx, connect.NewRequest(&v1.MergeExerciseRecordsRequest{
+		Ids: []string{manual.Msg.ExerciseRecord.Id, importedID},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, importedID, merged.Msg.ExerciseRecord.Id) // The imported record has calories
+
+	testCases := []struct {
+		name            string
+		ctx             context.Context
+		req             *v1.GetRecordHistoryRequest
+		expectedCode    connect.Code
+		expectedActions []v1.RecordChangeAction
+		expectedSources []string
+	}{
+		{
+			name: "Success - Created Then Updated",
+			ctx:  testCtx,
+			req:  &v1.GetRecordHistoryRequest{RecordType: v1.RecordType_RECORD_TYPE_BODY_RECORD, RecordId: created.Msg.BodyRecord.Id},
+			expectedActions: []v1.RecordChangeAction{
+				v1.RecordChangeAction_RECORD_CHANGE_ACTION_CREATED,
+				v1.RecordChangeAction_RECORD_CHANGE_ACTION_UPDATED,
+			},
+			expectedSources: []string{repo.ChangeSourceUser, repo.ChangeSourceUser},
+		},
+		{
+			name: "Success - Imported Then Merged",
+			ctx:  testCtx,
+			req:  &v1.GetRecordHistoryRequest{RecordType: v1.RecordType_RECORD_TYPE_EXERCISE_RECORD, RecordId: importedID},
+			expectedActions: []v1.RecordChangeAction{
+				v1.RecordChangeAction_RECORD_CHANGE_ACTION_IMPORTED,
+				v1.RecordChangeAction_RECORD_CHANGE_ACTION_MERGED,
+			},
+			expectedSources: []string{"healthkit", repo.ChangeSourceUser},
+		},
+		{
+			name: "Success - History Of Merged Away Record",
+			ctx:  testCtx,
+			req:  &v1.GetRecordHistoryRequest{RecordType: v1.RecordType_RECORD_TYPE_EXERCISE_RECORD, RecordId: manual.Msg.ExerciseRecord.Id},
+			expectedActions: []v1.RecordChangeAction{
+				v1.RecordChangeAction_RECORD_CHANGE_ACTION_CREATED,
+				v1.RecordChangeAction_RECORD_CHANGE_ACTION_MERGED,
+			},
+			expectedSources: []string{repo.ChangeSourceUser, repo.ChangeSourceUser},
+		},
+		{
+			name:            "Success - Other User Sees No History",
+			ctx:             context.WithValue(ctx, auth.UserContextKey, uuid.New()),
+			req:             &v1.GetRecordHistoryRequest{RecordType: v1.RecordType_RECORD_TYPE_BODY_RECORD, RecordId: created.Msg.BodyRecord.Id},
+			expectedActions: []v1.RecordChangeAction{},
+			expectedSources: []string{},
+		},
+		{
+			name:         "Error - Unspecified Record Type",
+			ctx:          testCtx,
+			req:          &v1.GetRecordHistoryRequest{RecordId: created.Msg.BodyRecord.Id},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name:         "Error - Invalid Record ID",
+			ctx:          testCtx,
+			req:          &v1.GetRecordHistoryRequest{RecordType: v1.RecordType_RECORD_TYPE_BODY_RECORD, RecordId: "invalid-uuid"},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handler.GetRecordHistory(tc.ctx, connect.NewRequest(tc.req))
+
+			if tc.expectedCode != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedCode, connect.CodeOf(err))
+				assert.Nil(t, resp)
+				return
+			}
+
+			require.NoError(t, err)
+			actions := []v1.RecordChangeAction{}
+			sources := []string{}
+			for _, change := range resp.Msg.Changes {
+				actions = append(actions, change.Action)
+				sources = append(sources, change.Source)
+			}
+			assert.Equal(t, tc.expectedActions, actions)
+			assert.Equal(t, tc.expectedSources, sources)
+		})
+	}
+}
+
+func TestGetRecordHistoryAfterDelete(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	handler := NewRecordHistoryHandler(repo.NewRecordChangeRepository(testPool), testLogger, mockClock)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	entry, err := diaryRepo.Create(ctx, testUserID, nil, "Content", fixedTime, fixedTime)
+	require.NoError(t, err)
+	require.NoError(t, diaryRepo.Delete(ctx, entry.ID, testUserID, fixedTime.Add(time.Hour)))
+
+	// Records written directly to the database have no history
+	untracked, err := testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "Title", "Content", fixedTime, fixedTime)
+	require.NoError(t, err)
+
+	resp, err := handler.GetRecordHistory(testCtx, connect.NewRequest(&v1.GetRecordHistoryRequest{
+		RecordType: v1.RecordType_RECORD_TYPE_DIARY_ENTRY,
+		RecordId:   entry.ID.String(),
+	}))
+	require.NoError(t, err)
+	require.Len(t, resp.Msg.Changes, 2)
+	assert.Equal(t, v1.RecordChangeAction_RECORD_CHANGE_ACTION_CREATED, resp.Msg.Changes[0].Action)
+	assert.Equal(t, v1.RecordChangeAction_RECORD_CHANGE_ACTION_DELETED, resp.Msg.Changes[1].Action)
+	assert.True(t, resp.Msg.Changes[1].ChangedAt.AsTime().Equal(fixedTime.Add(time.Hour)))
+
+	resp, err = handler.GetRecordHistory(testCtx, connect.NewRequest(&v1.GetRecordHistoryRequest{
+		RecordType: v1.RecordType_RECORD_TYPE_DIARY_ENTRY,
+		RecordId:   untracked.ID.String(),
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, resp.Msg.Changes)
+}
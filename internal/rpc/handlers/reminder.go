@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/reminder"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxRemindersPerUser bounds the reminders a user can create
+	maxRemindersPerUser = 50
+	// maxReminderTitleLength and maxReminderMessageLength bound the notification text, in characters
+	maxReminderTitleLength   = 100
+	maxReminderMessageLength = 500
+	// defaultReminderTimezone is the time zone of reminders created without one
+	defaultReminderTimezone = "UTC"
+)
+
+// ReminderHandler implements the reminder service RPCs
+type ReminderHandler struct {
+	repo  ReminderRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewReminderHandler creates a new reminder handler
+func NewReminderHandler(repo ReminderRepository, log *slog.Logger, clock clock.Clock) *ReminderHandler {
+	return &ReminderHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// reminderSchedule is the validated schedule of a create or update request
+type reminderSchedule struct {
+	cron       string
+	timezone   string
+	nextFireAt time.Time
+}
+
+// CreateReminder creates a reminder for the user
+func (h *ReminderHandler) CreateReminder(ctx context.Context, req *connect.Request[v1.CreateReminderRequest]) (*connect.Response[v1.CreateReminderResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	if err := validateReminderText(req.Msg.Title, req.Msg.Message); err != nil {
+		return nil, err
+	}
+	schedule, err := parseReminderSchedule(req.Msg.GetDailyAt(), req.Msg.GetCron(), req.Msg.Timezone, h.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count reminders", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create reminder"))
+	}
+	if count >= maxRemindersPerUser {
+		return nil, apierror.New(connect.CodeResourceExhausted, apierror.ReasonLimitExceeded, fmt.Errorf("too many reminders (maximum %d)", maxRemindersPerUser))
+	}
+
+	created, err := h.repo.Create(ctx, userID, req.Msg.Title, req.Msg.Message, schedule.cron, schedule.timezone, schedule.nextFireAt, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create reminder", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create reminder"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateReminderResponse{
+		Reminder: ToProtoReminder(created),
+	})
+
+	return res, nil
+}
+
+// ListReminders lists the reminders of the user
+func (h *ReminderHandler) ListReminders(ctx context.Context, req *connect.Request[v1.ListRemindersRequest]) (*connect.Response[v1.ListRemindersResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	reminders, err := h.repo.FindByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list reminders", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list reminders"))
+	}
+
+	// Create response
+	resp := &v1.ListRemindersResponse{
+		Reminders: make([]*v1.Reminder, 0, len(reminders)),
+	}
+	for _, r := range reminders {
+		resp.Reminders = append(resp.Reminders, ToProtoReminder(r))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// UpdateReminder replaces a reminder of the user
+func (h *ReminderHandler) UpdateReminder(ctx context.Context, req *connect.Request[v1.UpdateReminderRequest]) (*connect.Response[v1.UpdateReminderResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	reminderID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid reminder ID", "reminderID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	if err := validateReminderText(req.Msg.Title, req.Msg.Message); err != nil {
+		return nil, err
+	}
+	schedule, err := parseReminderSchedule(req.Msg.GetDailyAt(), req.Msg.GetCron(), req.Msg.Timezone, h.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Disabled reminders have no next occurrence; enabling one schedules it from now
+	var nextFireAt *time.Time
+	if req.Msg.Enabled {
+		nextFireAt = &schedule.nextFireAt
+	}
+
+	updated, err := h.repo.Update(ctx, reminderID, userID, req.Msg.Title, req.Msg.Message, schedule.cron, schedule.timezone, req.Msg.Enabled, nextFireAt, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrReminderNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("reminder not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to update reminder", "reminderID", reminderID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update reminder"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateReminderResponse{
+		Reminder: ToProtoReminder(updated),
+	})
+
+	return res, nil
+}
+
+// DeleteReminder deletes a reminder of the user
+func (h *ReminderHandler) DeleteReminder(ctx context.Context, req *connect.Request[v1.DeleteReminderRequest]) (*connect.Response[v1.DeleteReminderResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	reminderID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid reminder ID", "reminderID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Delete(ctx, reminderID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrReminderNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("reminder not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete reminder", "reminderID", reminderID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete reminder"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteReminderResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// validateReminderText validates the title and message of a reminder
+func validateReminderText(title, message string) error {
+	if title == "" || utf8.RuneCountInString(title) > maxReminderTitleLength {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("title is required and must be at most %d characters", maxReminderTitleLength))
+	}
+	if utf8.RuneCountInString(message) > maxReminderMessageLength {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("message must be at most %d characters", maxReminderMessageLength))
+	}
+	return nil
+}
+
+// parseReminderSchedule validates the schedule of a request, given as a daily time or a cron
+// expression, and computes its next occurrence after now
+func parseReminderSchedule(dailyAt, cron, timezone string, now time.Time) (reminderSchedule, error) {
+	if dailyAt != "" {
+		var err error
+		if cron, err = reminder.DailyAt(dailyAt); err != nil {
+			return reminderSchedule{}, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+	if cron == "" {
+		return reminderSchedule{}, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ReminderScheduleRequired))
+	}
+	if timezone == "" {
+		timezone = defaultReminderTimezone
+	}
+
+	next, err := reminder.NextFire(cron, timezone, now)
+	if err != nil {
+		return reminderSchedule{}, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return reminderSchedule{cron: cron, timezone: timezone, nextFireAt: next}, nil
+}
+
+// ToProtoReminder converts a db.Reminder to a v1.Reminder
+func ToProtoReminder(r db.Reminder) *v1.Reminder {
+	protoReminder := &v1.Reminder{
+		Id:        r.ID.String(),
+		Title:     r.Title,
+		Message:   r.Message,
+		Schedule:  r.Schedule,
+		Timezone:  r.Timezone,
+		Enabled:   r.Enabled,
+		CreatedAt: timestamppb.New(r.CreatedAt),
+	}
+
+	if r.NextFireAt.Valid {
+		protoReminder.NextFireAt = timestamppb.New(r.NextFireAt.Time)
+	}
+	if r.LastFiredAt.Valid {
+		protoReminder.LastFiredAt = timestamppb.New(r.LastFiredAt.Time)
+	}
+
+	return protoReminder
+}
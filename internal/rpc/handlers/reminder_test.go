@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/push"
+	"github.com/atreya2011/health-management-api/internal/reminder"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReminderHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	// 2024-01-15 is a Monday
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	reminderRepo := repo.NewReminderRepository(testPool)
+	pushRepo := repo.NewPushRepository(testPool)
+	handler := NewReminderHandler(reminderRepo, testLogger, mockClock)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	var weighInID string
+
+	t.Run("Create Daily Reminder", func(t *testing.T) {
+		resp, err := handler.CreateReminder(testCtx, connect.NewRequest(&v1.CreateReminderRequest{
+			Title:    "Weigh in",
+			Message:  "Log your weight",
+			Schedule: &v1.CreateReminderRequest_DailyAt{DailyAt: "08:30"},
+			Timezone: "Asia/Tokyo",
+		}))
+		require.NoError(t, err)
+		r := resp.Msg.Reminder
+		weighInID = r.Id
+		assert.Equal(t, "30 8 * * *", r.Schedule)
+		assert.True(t, r.Enabled)
+		// 10:00 UTC is 19:00 in Tokyo, so the next 08:30 is tomorrow
+		assert.Equal(t, time.Date(2024, 1, 16, 8, 30, 0, 0, tokyo), r.NextFireAt.AsTime().In(tokyo))
+		assert.Nil(t, r.LastFiredAt)
+	})
+
+	t.Run("Create Cron Reminder", func(t *testing.T) {
+		// Reminders are listed oldest first
+		mockClock.SetTime(time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC))
+		resp, err := handler.CreateReminder(testCtx, connect.NewRequest(&v1.CreateReminderRequest{
+			Title:    "Medication",
+			Schedule: &v1.CreateReminderRequest_Cron{Cron: "0 12 * * 1-5"},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "UTC", resp.Msg.Reminder.Timezone)
+		assert.Equal(t, time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), resp.Msg.Reminder.NextFireAt.AsTime())
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateReminderRequest{
+			"missing title":    {Schedule: &v1.CreateReminderRequest_DailyAt{DailyAt: "08:30"}},
+			"missing schedule": {Title: "Weigh in"},
+			"invalid time":     {Title: "Weigh in", Schedule: &v1.CreateReminderRequest_DailyAt{DailyAt: "25:00"}},
+			"invalid cron":     {Title: "Weigh in", Schedule: &v1.CreateReminderRequest_Cron{Cron: "* * *"}},
+			"never fires":      {Title: "Weigh in", Schedule: &v1.CreateReminderRequest_Cron{Cron: "0 8 30 2 *"}},
+			"invalid timezone": {Title: "Weigh in", Schedule: &v1.CreateReminderRequest_DailyAt{DailyAt: "08:30"}, Timezone: "Mars/Olympus"},
+			"local timezone":   {Title: "Weigh in", Schedule: &v1.CreateReminderRequest_DailyAt{DailyAt: "08:30"}, Timezone: "Local"},
+		} {
+			_, err := handler.CreateReminder(testCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+	})
+
+	t.Run("Fire Due Reminders", func(t *testing.T) {
+		scheduler := reminder.NewScheduler(reminderRepo, push.NewNotifier(pushRepo, mockClock), time.Minute, testLogger, mockClock)
+		_, err := pushRepo.RegisterDevice(ctx, testUserID, push.PlatformFCM, "token-a", mockClock.Now())
+		require.NoError(t, err)
+		countQueued := func(t *testing.T) int {
+			t.Helper()
+			var count int
+			require.NoError(t, testPool.QueryRow(ctx, "SELECT COUNT(*) FROM push_notifications WHERE kind = $1", push.KindReminder).Scan(&count))
+			return count
+		}
+
+		// Not due yet
+		scheduler.FireDue(ctx)
+		list, err := handler.ListReminders(testCtx, connect.NewRequest(&v1.ListRemindersRequest{}))
+		require.NoError(t, err)
+		require.Len(t, list.Msg.Reminders, 2)
+		assert.Nil(t, list.Msg.Reminders[1].LastFiredAt)
+		assert.Zero(t, countQueued(t))
+
+		// Overdue: a missed day is skipped, not fired twice
+		mockClock.SetTime(time.Date(2024, 1, 16, 12, 30, 0, 0, time.UTC))
+		scheduler.FireDue(ctx)
+		list, err = handler.ListReminders(testCtx, connect.NewRequest(&v1.ListRemindersRequest{}))
+		require.NoError(t, err)
+		for _, r := range list.Msg.Reminders {
+			assert.Equal(t, mockClock.Now(), r.LastFiredAt.AsTime(), r.Title)
+		}
+		assert.Equal(t, time.Date(2024, 1, 17, 8, 30, 0, 0, tokyo), list.Msg.Reminders[0].NextFireAt.AsTime().In(tokyo))
+		assert.Equal(t, time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC), list.Msg.Reminders[1].NextFireAt.AsTime())
+		assert.Equal(t, 2, countQueued(t))
+
+		// Firing again before the next occurrence queues nothing
+		scheduler.FireDue(ctx)
+		assert.Equal(t, 2, countQueued(t))
+	})
+
+	t.Run("Disable Reminder", func(t *testing.T) {
+		resp, err := handler.UpdateReminder(testCtx, connect.NewRequest(&v1.UpdateReminderRequest{
+			Id:       weighInID,
+			Title:    "Weigh in",
+			Schedule: &v1.UpdateReminderRequest_DailyAt{DailyAt: "07:00"},
+			Timezone: "Asia/Tokyo",
+			Enabled:  false,
+		}))
+		require.NoError(t, err)
+		assert.False(t, resp.Msg.Reminder.Enabled)
+		assert.Nil(t, resp.Msg.Reminder.NextFireAt)
+		assert.Equal(t, "0 7 * * *", resp.Msg.Reminder.Schedule)
+	})
+
+	t.Run("Update Not Found", func(t *testing.T) {
+		_, err := handler.UpdateReminder(testCtx, connect.NewRequest(&v1.UpdateReminderRequest{
+			Id:       "00000000-0000-0000-0000-000000000000",
+			Title:    "Weigh in",
+			Schedule: &v1.UpdateReminderRequest_DailyAt{DailyAt: "07:00"},
+		}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Delete Reminder", func(t *testing.T) {
+		_, err := handler.DeleteReminder(testCtx, connect.NewRequest(&v1.DeleteReminderRequest{Id: weighInID}))
+		require.NoError(t, err)
+		_, err = handler.DeleteReminder(testCtx, connect.NewRequest(&v1.DeleteReminderRequest{Id: weighInID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Reminder Limit", func(t *testing.T) {
+		for i := 1; i < maxRemindersPerUser; i++ {
+			_, err := handler.CreateReminder(testCtx, connect.NewRequest(&v1.CreateReminderRequest{
+				Title:    "Drink water",
+				Schedule: &v1.CreateReminderRequest_Cron{Cron: "0 * * * *"},
+			}))
+			require.NoError(t, err)
+		}
+		_, err := handler.CreateReminder(testCtx, connect.NewRequest(&v1.CreateReminderRequest{
+			Title:    "Drink water",
+			Schedule: &v1.CreateReminderRequest_Cron{Cron: "0 * * * *"},
+		}))
+		assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// reportDownloadTTL is how long a signed report download URL is valid
+const reportDownloadTTL = time.Hour
+
+// reportStatuses maps the stored statuses of monthly reports to their proto values
+var reportStatuses = map[string]v1.ReportStatus{
+	repo.MonthlyReportPending:   v1.ReportStatus_REPORT_STATUS_PENDING,
+	repo.MonthlyReportRunning:   v1.ReportStatus_REPORT_STATUS_RUNNING,
+	repo.MonthlyReportCompleted: v1.ReportStatus_REPORT_STATUS_COMPLETED,
+	repo.MonthlyReportFailed:    v1.ReportStatus_REPORT_STATUS_FAILED,
+}
+
+// ReportHandler implements the report service RPCs
+type ReportHandler struct {
+	repo  MonthlyReportRepository
+	store storage.Store
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewReportHandler creates a new report handler, signing report downloads from store
+func NewReportHandler(repo MonthlyReportRepository, store storage.Store, log *slog.Logger, clock clock.Clock) *ReportHandler {
+	return &ReportHandler{
+		repo:  repo,
+		store: store,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// GenerateMonthlyReport queues a report of a month for the report job
+func (h *ReportHandler) GenerateMonthlyReport(ctx context.Context, req *connect.Request[v1.GenerateMonthlyReportRequest]) (*connect.Response[v1.GenerateMonthlyReportResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	month, err := time.Parse("2006-01", req.Msg.Month)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid month format", "month", req.Msg.Month, "error", err)
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid month format: %w", err))
+	}
+	if month.After(h.clock.Now()) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("month must not be in the future"))
+	}
+
+	report, err := h.repo.Create(ctx, userID, month, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create monthly report", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to generate monthly report"))
+	}
+	h.log.InfoContext(ctx, "Monthly report requested", "reportID", report.ID, "month", req.Msg.Month)
+
+	// Create response
+	res := connect.NewResponse(&v1.GenerateMonthlyReportResponse{
+		Report: toProtoMonthlyReport(report),
+	})
+
+	return res, nil
+}
+
+// GetReportStatus returns a report of the user with its download URL once completed
+func (h *ReportHandler) GetReportStatus(ctx context.Context, req *connect.Request[v1.GetReportStatusRequest]) (*connect.Response[v1.GetReportStatusResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	reportID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid monthly report ID", "reportID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	report, err := h.repo.FindByID(ctx, reportID, userID)
+	if err != nil {
+		if errors.Is(err, repo.ErrMonthlyReportNotFound) {
+			h.log.WarnContext(ctx, "Monthly report not found", "reportID", reportID)
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, err)
+		}
+		h.log.ErrorContext(ctx, "Failed to get monthly report", "reportID", reportID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get monthly report"))
+	}
+
+	protoReport := toProtoMonthlyReport(report)
+	if report.Status == repo.MonthlyReportCompleted && report.StorageKey.Valid {
+		url, err := h.store.SignDownload(ctx, report.StorageKey.String, reportDownloadTTL)
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to sign monthly report download", "reportID", reportID, "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get monthly report"))
+		}
+		protoReport.DownloadUrl = url
+		protoReport.DownloadUrlExpiresAt = timestamppb.New(h.clock.Now().Add(reportDownloadTTL))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetReportStatusResponse{
+		Report: protoReport,
+	})
+
+	return res, nil
+}
+
+// toProtoMonthlyReport converts a db.MonthlyReport to a v1.MonthlyReport without its download URL
+func toProtoMonthlyReport(report db.MonthlyReport) *v1.MonthlyReport {
+	protoReport := &v1.MonthlyReport{
+		Id:        report.ID.String(),
+		Month:     report.Month.Time.Format("2006-01"),
+		Status:    reportStatuses[report.Status],
+		Error:     report.LastError.String,
+		CreatedAt: timestamppb.New(report.CreatedAt),
+	}
+	if report.CompletedAt.Valid {
+		protoReport.CompletedAt = timestamppb.New(report.CompletedAt.Time)
+	}
+	return protoReport
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/report"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonthlyReports(t *testing.T) {
+	resetDB(t, testPool)
+	reportRepo := repo.NewMonthlyReportRepository(testPool)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	exerciseRecordRepo := repo.NewExerciseRecordRepository(testPool)
+	diaryRepo := repo.NewDiaryEntryRepository(testPool, testDiaryCipher)
+	store := newTestStore(t)
+	cfg := config.ReportsConfig{Prefix: "reports/", Interval: time.Minute}
+	handler := NewReportHandler(reportRepo, store, testLogger, mockClock)
+	newJob := func(store storage.Store) *report.Job {
+		return report.NewJob(reportRepo, bodyRecordRepo, exerciseRecordRepo, diaryRepo, repo.NewPreferenceRepository(testPool), store, cfg, nil, testLogger, mockClock)
+	}
+	job := newJob(store)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 2, 10, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	// January has weigh-ins, a workout and diary entries; February's weigh-in is left out
+	for i, weight := range []float64{72.4, 71.8, 71.1} {
+		_, err := bodyRecordRepo.Save(ctx, testUserID, time.Date(2024, 1, 1+i*14, 0, 0, 0, 0, time.UTC), &weight, nil, "", fixedTime)
+		require.NoError(t, err)
+	}
+	february := 90.0
+	_, err := bodyRecordRepo.Save(ctx, testUserID, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), &february, nil, "", fixedTime)
+	require.NoError(t, err)
+	duration, calories := int32(45), int32(400)
+	_, err = exerciseRecordRepo.Create(ctx, testUserID, "Running", &duration, &calories, time.Date(2024, 1, 20, 7, 0, 0, 0, time.UTC), nil, nil, repo.ExerciseEffort{}, fixedTime)
+	require.NoError(t, err)
+	title := "New routine"
+	_, err = diaryRepo.Create(ctx, testUserID, &title, "Started running before work", time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC), fixedTime)
+	require.NoError(t, err)
+
+	// generate queues a report of month
+	generate := func(t *testing.T, month string) *v1.MonthlyReport {
+		t.Helper()
+		resp, err := handler.GenerateMonthlyReport(testCtx, connect.NewRequest(&v1.GenerateMonthlyReportRequest{Month: month}))
+		require.NoError(t, err)
+		return resp.Msg.Report
+	}
+	// status gets a report
+	status := func(t *testing.T, id string) *v1.MonthlyReport {
+		t.Helper()
+		resp, err := handler.GetReportStatus(testCtx, connect.NewRequest(&v1.GetReportStatusRequest{Id: id}))
+		require.NoError(t, err)
+		return resp.Msg.Report
+	}
+
+	t.Run("Generated In The Background", func(t *testing.T) {
+		queued := generate(t, "2024-01")
+		assert.Equal(t, "2024-01", queued.Month)
+		assert.Equal(t, v1.ReportStatus_REPORT_STATUS_PENDING, queued.Status)
+		assert.Empty(t, status(t, queued.Id).DownloadUrl)
+
+		job.GenerateDue(ctx)
+
+		completed := status(t, queued.Id)
+		assert.Equal(t, v1.ReportStatus_REPORT_STATUS_COMPLETED, completed.Status)
+		assert.NotEmpty(t, completed.DownloadUrl)
+		assert.Equal(t, fixedTime.Add(reportDownloadTTL), completed.DownloadUrlExpiresAt.AsTime())
+		assert.Equal(t, fixedTime, completed.CompletedAt.AsTime())
+
+		pdf, err := store.ReadPrefix(ctx, "reports/"+testUserID.String()+"/"+queued.Id+".pdf", 1<<20)
+		require.NoError(t, err)
+		content := string(pdf)
+		assert.True(t, len(content) > 5 && content[:5] == "%PDF-")
+		assert.Contains(t, content, "Monthly Report - January 2024")
+		assert.Contains(t, content, "Start 72.4 kg, end 71.1 kg, change -1.3 kg (3 weigh-ins)")
+		assert.Contains(t, content, "Sessions: 1")
+		assert.Contains(t, content, "Total duration: 45 min")
+		assert.Contains(t, content, "Calories burned: 400 kcal")
+		assert.Contains(t, content, "Jan 20  New routine")
+		assert.Contains(t, content, "Started running before work")
+	})
+
+	t.Run("Empty Months", func(t *testing.T) {
+		queued := generate(t, "2023-06")
+		job.GenerateDue(ctx)
+		require.Equal(t, v1.ReportStatus_REPORT_STATUS_COMPLETED, status(t, queued.Id).Status)
+
+		pdf, err := store.ReadPrefix(ctx, "reports/"+testUserID.String()+"/"+queued.Id+".pdf", 1<<20)
+		require.NoError(t, err)
+		assert.Contains(t, string(pdf), "No weigh-ins this month")
+		assert.Contains(t, string(pdf), "No diary entries this month")
+	})
+
+	t.Run("Failed Reports Are Retried", func(t *testing.T) {
+		failingJob := newJob(failingStore{store})
+		queued := generate(t, "2023-12")
+		failingJob.GenerateDue(ctx)
+
+		retried := status(t, queued.Id)
+		assert.Equal(t, v1.ReportStatus_REPORT_STATUS_PENDING, retried.Status)
+		assert.Contains(t, retried.Error, "store unavailable")
+
+		// Each attempt waits for the retry delay; the last one fails the report
+		for range 2 {
+			mockClock.SetTime(mockClock.Now().Add(time.Hour))
+			failingJob.GenerateDue(ctx)
+		}
+		assert.Equal(t, v1.ReportStatus_REPORT_STATUS_FAILED, status(t, queued.Id).Status)
+		mockClock.SetTime(fixedTime)
+	})
+
+	t.Run("Only Owners See Reports", func(t *testing.T) {
+		queued := generate(t, "2024-01")
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		otherCtx := context.WithValue(ctx, auth.UserContextKey, otherUserID)
+
+		_, err = handler.GetReportStatus(otherCtx, connect.NewRequest(&v1.GetReportStatusRequest{Id: queued.Id}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		for name, month := range map[string]string{
+			"Empty":        "",
+			"Full Date":    "2024-01-15",
+			"Invalid":      "2024-13",
+			"Future Month": "2024-03",
+		} {
+			_, err := handler.GenerateMonthlyReport(testCtx, connect.NewRequest(&v1.GenerateMonthlyReportRequest{Month: month}))
+			require.Error(t, err, name)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+		}
+
+		_, err := handler.GetReportStatus(testCtx, connect.NewRequest(&v1.GetReportStatusRequest{Id: "not-a-uuid"}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/units"
+	"github.com/google/uuid"
+)
+
+// The handlers depend on the repositories through the interfaces below, each listing the methods
+// of a repository of package repo that handlers call, so repositories can be wrapped, e.g. with
+// caching or metrics, or replaced in tests. Package repo provides their PostgreSQL
+// implementations.
+
+// The PostgreSQL repositories implement the interfaces
+var (
+	_ APIUsageRepository         = (*repo.APIUsageRepository)(nil)
+	_ AchievementRepository      = (*repo.AchievementRepository)(nil)
+	_ AttachmentRepository       = (*repo.AttachmentRepository)(nil)
+	_ BodyRecordRepository       = (*repo.BodyRecordRepository)(nil)
+	_ ColumnDigestRepository     = (*repo.ColumnDigestRepository)(nil)
+	_ ColumnRepository           = (*repo.ColumnRepository)(nil)
+	_ DailyTargetRepository      = (*repo.DailyTargetRepository)(nil)
+	_ DataShareRepository        = (*repo.DataShareRepository)(nil)
+	_ DiaryEntryRepository       = (*repo.DiaryEntryRepository)(nil)
+	_ ExerciseRecordRepository   = (*repo.ExerciseRecordRepository)(nil)
+	_ ExerciseTemplateRepository = (*repo.ExerciseTemplateRepository)(nil)
+	_ FastRepository             = (*repo.FastRepository)(nil)
+	_ GoalRepository             = (*repo.GoalRepository)(nil)
+	_ HeartRateRepository        = (*repo.HeartRateRepository)(nil)
+	_ ImportRepository           = (*repo.ImportRepository)(nil)
+	_ IntegrationRepository      = (*repo.IntegrationRepository)(nil)
+	_ MealRecordRepository       = (*repo.MealRecordRepository)(nil)
+	_ MonthlyReportRepository    = (*repo.MonthlyReportRepository)(nil)
+	_ MoodRecordRepository       = (*repo.MoodRecordRepository)(nil)
+	_ PlannedMealRepository      = (*repo.PlannedMealRepository)(nil)
+	_ PreferenceRepository       = (*repo.PreferenceRepository)(nil)
+	_ PushRepository             = (*repo.PushRepository)(nil)
+	_ RecipeRepository           = (*repo.RecipeRepository)(nil)
+	_ RecordChangeRepository     = (*repo.RecordChangeRepository)(nil)
+	_ ReminderRepository         = (*repo.ReminderRepository)(nil)
+	_ ResearchRepository         = (*repo.ResearchRepository)(nil)
+	_ RetentionRepository        = (*repo.RetentionRepository)(nil)
+	_ SessionRepository          = (*repo.SessionRepository)(nil)
+	_ StatsRepository            = (*repo.StatsRepository)(nil)
+	_ StepRecordRepository       = (*repo.StepRecordRepository)(nil)
+	_ SupplementRepository       = (*repo.SupplementRepository)(nil)
+	_ SupportRepository          = (*repo.SupportRepository)(nil)
+	_ TrashRepository            = (*repo.TrashRepository)(nil)
+	_ UserRepository             = (*repo.UserRepository)(nil)
+	_ WorkoutSessionRepository   = (*repo.WorkoutSessionRepository)(nil)
+)
+
+// APIUsageRepository reads the API usage of users, for their quota
+type APIUsageRepository interface {
+	FindByDay(ctx context.Context, userID uuid.UUID, now time.Time) (int32, error)
+}
+
+// AchievementRepository reads the achievements and streaks of users
+type AchievementRepository interface {
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]db.Achievement, error)
+	Streak(ctx context.Context, userID uuid.UUID, kind string) (db.Streak, error)
+	Streaks(ctx context.Context, userID uuid.UUID) ([]db.Streak, error)
+}
+
+// AttachmentRepository stores the attachments of body records
+type AttachmentRepository interface {
+	CreateForBodyRecord(ctx context.Context, userID, bodyRecordID, id uuid.UUID, contentType string, size, maxCount int64, now time.Time) (db.Attachment, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) (db.Attachment, error)
+	Discard(ctx context.Context, id, userID uuid.UUID) error
+	FindByID(ctx context.Context, id, userID uuid.UUID) (db.Attachment, error)
+	FindReadyByBodyRecords(ctx context.Context, bodyRecordIDs []uuid.UUID) ([]db.Attachment, error)
+	MarkReady(ctx context.Context, id, userID uuid.UUID, now time.Time) (db.Attachment, error)
+}
+
+// BodyRecordRepository stores the body records of users
+type BodyRecordRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) (int64, error)
+	DeleteByDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, expected int64, now time.Time) error
+	EachBatchByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, batchSize int32, fn func([]db.BodyRecord) error) error
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.BodyRecord, error)
+	FindByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]db.BodyRecord, error)
+	FindByUserAndDateRangeOrdered(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, newestFirst bool) ([]db.BodyRecord, error)
+	Upsert(ctx context.Context, userID uuid.UUID, date time.Time, weightKg *float64, bodyFatPercentage *float64, note string, now time.Time) (repo.BodyRecordUpsert, error)
+}
+
+// ColumnDigestRepository stores the column digest settings of users
+type ColumnDigestRepository interface {
+	GetSettings(ctx context.Context, userID uuid.UUID) (repo.ColumnDigestSettings, error)
+	SetSettings(ctx context.Context, userID uuid.UUID, optIn bool, categories []string) (repo.ColumnDigestSettings, error)
+}
+
+// ColumnRepository reads the published columns and counts their reads
+type ColumnRepository interface {
+	CountByCategory(ctx context.Context, category string, now time.Time) (int64, error)
+	CountByTag(ctx context.Context, tag string, now time.Time) (int64, error)
+	CountPublished(ctx context.Context, now time.Time) (int64, error)
+	FindByCategory(ctx context.Context, category string, limit, offset int, now time.Time) ([]db.Column, error)
+	FindByID(ctx context.Context, id uuid.UUID, now time.Time) (db.Column, error)
+	FindByTag(ctx context.Context, tag string, limit, offset int, now time.Time) ([]db.Column, error)
+	FindPublished(ctx context.Context, limit, offset int, now time.Time) ([]db.Column, error)
+	RecordRead(ctx context.Context, id uuid.UUID, now time.Time) error
+}
+
+// DailyTargetRepository stores the daily targets of users and reads what they are derived from
+type DailyTargetRepository interface {
+	Find(ctx context.Context, userID uuid.UUID) (db.DailyTarget, error)
+	GetActivityLevel(ctx context.Context, userID uuid.UUID) (string, error)
+	LatestWeight(ctx context.Context, userID uuid.UUID) (db.GetLatestBodyWeightRow, error)
+	LogWaterIntake(ctx context.Context, userID uuid.UUID, amountMl int32, takenAt, now time.Time) (db.WaterIntake, error)
+	Save(ctx context.Context, userID uuid.UUID, waterMl, calories int32, weightKg float64, activityLevel string, now time.Time) (db.DailyTarget, error)
+	SetActivityLevel(ctx context.Context, userID uuid.UUID, level string) (string, error)
+	WaterIntakeTotal(ctx context.Context, userID uuid.UUID, start, end time.Time) (int64, error)
+}
+
+// DataShareRepository stores the data shares between users
+type DataShareRepository interface {
+	Create(ctx context.Context, ownerID, granteeID uuid.UUID, recordTypes []string, startsAt, expiresAt, now time.Time) (db.DataShare, error)
+	FindByGrantee(ctx context.Context, granteeID uuid.UUID, now time.Time) ([]repo.DataShareWithEmails, error)
+	FindByOwner(ctx context.Context, ownerID uuid.UUID, now time.Time) ([]repo.DataShareWithEmails, error)
+	Revoke(ctx context.Context, id, ownerID uuid.UUID, now time.Time) error
+}
+
+// DiaryEntryRepository stores the diary entries of users, their revisions and share links
+type DiaryEntryRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountRevisions(ctx context.Context, id, userID uuid.UUID) (int64, error)
+	CreateShareLink(ctx context.Context, entryID, userID uuid.UUID, expiresAt, now time.Time) (db.DiaryShareLink, error)
+	CreateUnlessDuplicate(ctx context.Context, userID uuid.UUID, title *string, content string, entryDate, since, now time.Time) (db.DiaryEntry, bool, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByID(ctx context.Context, id, userID uuid.UUID) (db.DiaryEntry, error)
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.DiaryEntry, error)
+	FindRevisions(ctx context.Context, id, userID uuid.UUID, limit, offset int) ([]db.DiaryEntryRevision, error)
+	FindShared(ctx context.Context, linkID uuid.UUID, now time.Time) (db.DiaryEntry, time.Time, error)
+	RestoreRevision(ctx context.Context, id, revisionID, userID uuid.UUID, now time.Time) (db.DiaryEntry, error)
+	RevokeShareLink(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	Update(ctx context.Context, id, userID uuid.UUID, title *string, content string, now time.Time) (db.DiaryEntry, error)
+}
+
+// ExerciseRecordRepository stores the exercise records of users and their routes
+type ExerciseRecordRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountByUserAndRange(ctx context.Context, userID uuid.UUID, start, end time.Time) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, exerciseName string, durationMinutes *int32, caloriesBurned *int32, recordedAt time.Time, startedAt, endedAt *time.Time, effort repo.ExerciseEffort, now time.Time) (db.ExerciseRecord, error)
+	DailyTrainingLoads(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ListDailyTrainingLoadsByUserRangeRow, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	DeleteByRange(ctx context.Context, userID uuid.UUID, start, end time.Time, expected int64, now time.Time) error
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.ExerciseRecord, error)
+	FindOverlapping(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ExerciseRecord, error)
+	FindRoute(ctx context.Context, recordID, userID uuid.UUID) (db.ExerciseRoute, error)
+	Merge(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, now time.Time) (db.ExerciseRecord, []uuid.UUID, error)
+	SetRoute(ctx context.Context, recordID, userID uuid.UUID, polyline []byte, pointCount int32, distanceMeters float64, now time.Time) (db.ExerciseRoute, error)
+	TotalsByUser(ctx context.Context, userID uuid.UUID, start, end time.Time) (db.GetExerciseTotalsByUserRangeRow, error)
+}
+
+// ExerciseTemplateRepository stores the exercise templates of users
+type ExerciseTemplateRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, fields repo.ExerciseTemplateFields, now time.Time) (db.ExerciseTemplate, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByID(ctx context.Context, id, userID uuid.UUID) (db.ExerciseTemplate, error)
+	FindByUser(ctx context.Context, userID uuid.UUID, favoritesOnly bool) ([]db.ExerciseTemplate, error)
+	MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+	Update(ctx context.Context, id, userID uuid.UUID, fields repo.ExerciseTemplateFields, now time.Time) (db.ExerciseTemplate, error)
+}
+
+// FastRepository stores the fasts of users
+type FastRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	End(ctx context.Context, userID uuid.UUID, endedAt, now time.Time) (db.Fast, error)
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.Fast, error)
+	FindRunning(ctx context.Context, userID uuid.UUID) (db.Fast, error)
+	Start(ctx context.Context, userID uuid.UUID, startedAt time.Time, targetMinutes *int32, now time.Time) (db.Fast, error)
+	Streak(ctx context.Context, userID uuid.UUID) (db.GetFastingStreakRow, error)
+}
+
+// GoalRepository stores the goals of users and summarizes their weeks
+type GoalRepository interface {
+	FindByUser(ctx context.Context, userID uuid.UUID) (db.Goal, error)
+	Set(ctx context.Context, userID uuid.UUID, targets repo.GoalTargets, now time.Time) (db.Goal, error)
+	WeeklySummary(ctx context.Context, userID uuid.UUID, weekStart time.Time) (db.GetWeeklySummaryRow, error)
+}
+
+// HeartRateRepository stores the heart rate samples of users
+type HeartRateRepository interface {
+	Downsample(ctx context.Context, userID uuid.UUID, resolution string, start, end time.Time) ([]repo.HeartRatePoint, error)
+	Insert(ctx context.Context, userID uuid.UUID, samples []repo.HeartRateSample, now time.Time) (int64, error)
+}
+
+// ImportRepository imports batches of records from other apps
+type ImportRepository interface {
+	ImportBatch(ctx context.Context, userID uuid.UUID, source string, batch repo.ImportBatch, now time.Time) (repo.ImportBatchResult, error)
+}
+
+// IntegrationRepository stores the links of users to integration providers
+type IntegrationRepository interface {
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]db.Integration, error)
+	Link(ctx context.Context, userID uuid.UUID, provider, externalUserID, accessToken, refreshToken string, tokenExpiresAt, now time.Time) (db.Integration, error)
+	Unlink(ctx context.Context, userID uuid.UUID, provider string) (repo.Integration, error)
+}
+
+// MealRecordRepository stores the meal records of users
+type MealRecordRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, name string, calories int32, eatenAt, now time.Time) (db.MealRecord, error)
+	DailyCalorieBalance(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ListDailyCalorieBalanceRow, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.MealRecord, error)
+}
+
+// MonthlyReportRepository stores the monthly reports requested by users
+type MonthlyReportRepository interface {
+	Create(ctx context.Context, userID uuid.UUID, month, now time.Time) (db.MonthlyReport, error)
+	FindByID(ctx context.Context, id, userID uuid.UUID) (db.MonthlyReport, error)
+}
+
+// MoodRecordRepository stores the mood records of users
+type MoodRecordRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, moodScore, energyLevel int16, symptoms []string, recordedAt, now time.Time) (db.MoodRecord, error)
+	DailyMetrics(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.ListDailyMoodMetricsRow, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.MoodRecord, error)
+}
+
+// PlannedMealRepository stores the meal plans of users
+type PlannedMealRepository interface {
+	CountByUserDate(ctx context.Context, userID uuid.UUID, date time.Time) (int64, error)
+	Create(ctx context.Context, userID, plannedBy uuid.UUID, date time.Time, name string, calories int32, now time.Time) (db.PlannedMeal, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByUserDateRange(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.PlannedMeal, error)
+	Update(ctx context.Context, id, userID uuid.UUID, date time.Time, name string, calories int32, now time.Time) (db.PlannedMeal, error)
+}
+
+// PreferenceRepository stores the unit preferences of users
+type PreferenceRepository interface {
+	GetUnits(ctx context.Context, userID uuid.UUID) (units.Preferences, error)
+	SetUnits(ctx context.Context, userID uuid.UUID, prefs units.Preferences) (units.Preferences, error)
+}
+
+// PushRepository stores the devices users receive push notifications on
+type PushRepository interface {
+	FindDevicesByUser(ctx context.Context, userID uuid.UUID) ([]db.DeviceToken, error)
+	RegisterDevice(ctx context.Context, userID uuid.UUID, platform, token string, now time.Time) (db.DeviceToken, error)
+	UnregisterDevice(ctx context.Context, userID uuid.UUID, platform, token string) error
+}
+
+// RecipeRepository stores the recipes of users
+type RecipeRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, fields repo.RecipeFields, now time.Time) (repo.Recipe, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByID(ctx context.Context, id, userID uuid.UUID) (repo.Recipe, error)
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]repo.Recipe, error)
+	Update(ctx context.Context, id, userID uuid.UUID, fields repo.RecipeFields, now time.Time) (repo.Recipe, error)
+}
+
+// RecordChangeRepository reads the change history of records
+type RecordChangeRepository interface {
+	FindByRecord(ctx context.Context, userID uuid.UUID, entityType string, entityID uuid.UUID) ([]db.RecordChange, error)
+}
+
+// ReminderRepository stores the reminders of users
+type ReminderRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, title, message, schedule, timezone string, nextFireAt, now time.Time) (db.Reminder, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]db.Reminder, error)
+	Update(ctx context.Context, id, userID uuid.UUID, title, message, schedule, timezone string, enabled bool, nextFireAt *time.Time, now time.Time) (db.Reminder, error)
+}
+
+// ResearchRepository stores the research exports and the research opt-ins of users
+type ResearchRepository interface {
+	CountExports(ctx context.Context) (int64, error)
+	CreateExport(ctx context.Context, requestedBy uuid.UUID, startDate, endDate time.Time, minGroupSize int, now time.Time) (db.ResearchExport, error)
+	GetExport(ctx context.Context, id uuid.UUID) (db.ResearchExport, error)
+	GetOptIn(ctx context.Context, userID uuid.UUID) (bool, error)
+	ListExports(ctx context.Context, limit, offset int) ([]db.ResearchExport, error)
+	SetOptIn(ctx context.Context, userID uuid.UUID, optIn bool) (bool, error)
+}
+
+// RetentionRepository stores the retention opt-outs of users
+type RetentionRepository interface {
+	GetOptOut(ctx context.Context, userID uuid.UUID) (bool, error)
+	SetOptOut(ctx context.Context, userID uuid.UUID, optOut bool) (bool, error)
+}
+
+// SessionRepository stores the sessions of users
+type SessionRepository interface {
+	Create(ctx context.Context, userID uuid.UUID, deviceName, userAgent string, roles, scopes []string, tokenHash []byte, expiresAt, now time.Time) (db.Session, error)
+	FindActiveByUser(ctx context.Context, userID uuid.UUID, now time.Time) ([]db.Session, error)
+	Revoke(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	Rotate(ctx context.Context, oldHash, newHash []byte, expiresAt, now time.Time) (db.Session, error)
+}
+
+// StatsRepository reads the usage statistics of the service
+type StatsRepository interface {
+	MostReadColumns(ctx context.Context, startDate, endDate time.Time, limit int32) ([]db.ListMostReadColumnsRow, error)
+	RecordsCreatedByDay(ctx context.Context, startDate, endDate time.Time) ([]db.CountRecordsCreatedByDayRow, error)
+	UserStats(ctx context.Context, now time.Time) (db.GetUserStatsRow, error)
+}
+
+// StepRecordRepository stores the step records of users
+type StepRecordRepository interface {
+	DailySteps(ctx context.Context, userID uuid.UUID, timezone string, start, end time.Time) ([]db.ListDailyStepsByUserRow, error)
+	EachBatchByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time, batchSize int32, fn func([]db.StepRecord) error) error
+	FindByUserAndDateRange(ctx context.Context, userID uuid.UUID, startDate, endDate time.Time) ([]db.StepRecord, error)
+	UpsertBuckets(ctx context.Context, userID uuid.UUID, buckets []repo.StepBucket, now time.Time) (int64, error)
+}
+
+// SupplementRepository stores the supplements of users and their intakes
+type SupplementRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, fields repo.SupplementFields, now time.Time) (repo.Supplement, error)
+	Delete(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	DeleteIntake(ctx context.Context, id, userID uuid.UUID, now time.Time) error
+	FindByUser(ctx context.Context, userID uuid.UUID) ([]repo.Supplement, error)
+	FindIntakesByUser(ctx context.Context, userID uuid.UUID, start, end time.Time, limit int32) ([]repo.SupplementIntake, error)
+	IntakeCountsByUser(ctx context.Context, userID uuid.UUID, start, end time.Time) ([]db.CountSupplementIntakesByUserRangeRow, error)
+	LogIntake(ctx context.Context, supplementID, userID uuid.UUID, dose string, takenAt, now time.Time) (repo.SupplementIntake, error)
+	Update(ctx context.Context, id, userID uuid.UUID, fields repo.SupplementFields, now time.Time) (repo.Supplement, error)
+}
+
+// SupportRepository summarizes the records of users for support
+type SupportRepository interface {
+	GetUserRecordSummary(ctx context.Context, userID uuid.UUID) (db.GetUserRecordSummaryRow, error)
+	ListIntegrationSyncStates(ctx context.Context, userID uuid.UUID) ([]db.ListIntegrationSyncStatesByUserRow, error)
+}
+
+// TrashRepository lists and restores the deleted records of users
+type TrashRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID, cutoff time.Time) (int64, error)
+	FindByUser(ctx context.Context, userID uuid.UUID, cutoff time.Time, limit, offset int) ([]db.Trash, error)
+	Restore(ctx context.Context, id, userID uuid.UUID, cutoff, now time.Time) (db.Trash, error)
+}
+
+// UserRepository looks up and administers users
+type UserRepository interface {
+	CountBySubjectIDSearch(ctx context.Context, query string) (int64, error)
+	FindByEmail(ctx context.Context, email string) (db.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (db.User, error)
+	FindBySubjectID(ctx context.Context, subjectID string) (db.User, error)
+	SearchBySubjectID(ctx context.Context, query string, limit, offset int) ([]db.User, error)
+	Suspend(ctx context.Context, id uuid.UUID, now time.Time) (db.User, error)
+	Unsuspend(ctx context.Context, id uuid.UUID) (db.User, error)
+}
+
+// WorkoutSessionRepository stores the workout sessions of users
+type WorkoutSessionRepository interface {
+	CountByUser(ctx context.Context, userID uuid.UUID) (int64, error)
+	Create(ctx context.Context, userID uuid.UUID, name string, startedAt time.Time, exercises []repo.WorkoutExercise, now time.Time) (db.WorkoutSession, []db.ExerciseRecord, error)
+	FindByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]db.WorkoutSession, error)
+	FindRecords(ctx context.Context, userID uuid.UUID, sessionIDs []uuid.UUID) (map[uuid.UUID][]db.ExerciseRecord, error)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDiaryRepository counts the diary entries looked up through it, as a decorator adding
+// metrics would
+type countingDiaryRepository struct {
+	DiaryEntryRepository
+	lookups int
+}
+
+func (r *countingDiaryRepository) FindByID(ctx context.Context, id, userID uuid.UUID) (db.DiaryEntry, error) {
+	r.lookups++
+	return r.DiaryEntryRepository.FindByID(ctx, id, userID)
+}
+
+// failingDiaryRepository fails every lookup of a diary entry with err
+type failingDiaryRepository struct {
+	DiaryEntryRepository
+	err error
+}
+
+func (r *failingDiaryRepository) FindByID(ctx context.Context, id, userID uuid.UUID) (db.DiaryEntry, error) {
+	return db.DiaryEntry{}, r.err
+}
+
+func TestRepositoryInterfaces(t *testing.T) {
+	ctx := newTestContext(context.Background())
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	t.Run("Decorated Repository", func(t *testing.T) {
+		resetDB(t, testPool)
+		counting := &countingDiaryRepository{DiaryEntryRepository: repo.NewDiaryEntryRepository(testPool, testDiaryCipher)}
+		handler := NewDiaryHandler(counting, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+
+		created, err := handler.CreateDiaryEntry(ctx, connect.NewRequest(&v1.CreateDiaryEntryRequest{Content: "Ran 5k", EntryDate: "2024-01-15"}))
+		require.NoError(t, err)
+		got, err := handler.GetDiaryEntry(ctx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: created.Msg.DiaryEntry.Id}))
+		require.NoError(t, err)
+		assert.Equal(t, "Ran 5k", got.Msg.DiaryEntry.Content)
+		assert.Equal(t, 1, counting.lookups)
+	})
+
+	t.Run("Fake Repository", func(t *testing.T) {
+		testCases := []struct {
+			name string
+			err  error
+			code connect.Code
+		}{
+			{"Not Found", repo.ErrDiaryEntryNotFound, connect.CodeNotFound},
+			{"Database Error", errors.New("connection reset by peer"), connect.CodeInternal},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				handler := NewDiaryHandler(&failingDiaryRepository{err: tc.err}, testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+				_, err := handler.GetDiaryEntry(ctx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: uuid.NewString()}))
+				assert.Equal(t, tc.code, connect.CodeOf(err))
+			})
+		}
+	})
+}
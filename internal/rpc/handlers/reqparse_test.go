@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestParsing(t *testing.T) {
+	t.Run("Dates", func(t *testing.T) {
+		date, err := reqparse.ParseDate("entry_date", "2024-01-15")
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), date)
+
+		_, err = reqparse.ParseDate("entry_date", "15/01/2024")
+		var fieldErr *reqparse.FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		assert.Equal(t, "entry_date", fieldErr.Field)
+		assert.EqualError(t, err, "entry_date must be a date in YYYY-MM-DD format")
+	})
+
+	t.Run("UUIDs", func(t *testing.T) {
+		id := uuid.New()
+		got, err := reqparse.ParseUUID("id", id.String())
+		require.NoError(t, err)
+		assert.Equal(t, id, got)
+
+		_, err = reqparse.ParseUUID("id", "42")
+		assert.EqualError(t, err, "id must be a UUID")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := reqparse.ParseUUID("diary_entry_id", "")
+		connectErr := reqparse.Error(err)
+		assert.Equal(t, connect.CodeInvalidArgument, connectErr.Code())
+		assert.Equal(t, "diary_entry_id must be a UUID", connectErr.Message())
+
+		// Messages are localized like those of the other validation errors
+		i18n.Localize(connectErr, i18n.Japanese)
+		require.Len(t, connectErr.Details(), 1)
+		detail, err := connectErr.Details()[0].Value()
+		require.NoError(t, err)
+		assert.Equal(t, "diary_entry_idにはUUIDを指定してください", detail.(*v1.LocalizedError).Message)
+
+		assert.Equal(t, connect.CodeInternal, reqparse.Error(errors.New("boom")).Code())
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		limits := reqparse.PageLimits{DefaultPageSize: 20, MaxPageSize: 100}
+		testCases := []struct {
+			name string
+			req  *v1.PageRequest
+			want reqparse.Page
+		}{
+			{"Unset", nil, reqparse.Page{Size: 20, Number: 1, Offset: 0}},
+			{"Defaults", &v1.PageRequest{PageSize: -5, PageNumber: 0}, reqparse.Page{Size: 20, Number: 1, Offset: 0}},
+			{"Requested", &v1.PageRequest{PageSize: 10, PageNumber: 3}, reqparse.Page{Size: 10, Number: 3, Offset: 20}},
+			{"Capped", &v1.PageRequest{PageSize: 500, PageNumber: 2}, reqparse.Page{Size: 100, Number: 2, Offset: 100}},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				got, err := reqparse.NormalizePagination(tc.req, limits)
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			})
+		}
+
+		page := reqparse.Page{Size: 10, Number: 2, Offset: 10}
+		resp := page.Response(21)
+		assert.Equal(t, int32(3), resp.TotalPages)
+		assert.Equal(t, int32(2), resp.CurrentPage)
+		assert.True(t, resp.HasNext)
+		assert.True(t, resp.HasPrevious)
+		assert.Equal(t, int32(1), page.Response(0).TotalPages)
+
+		// The next page token continues after the page, taking precedence over the page number
+		next, err := reqparse.NormalizePagination(&v1.PageRequest{PageSize: 10, PageNumber: 1, PageToken: resp.NextPageToken}, limits)
+		require.NoError(t, err)
+		assert.Equal(t, reqparse.Page{Size: 10, Number: 3, Offset: 20}, next)
+		last := next.Response(21)
+		assert.False(t, last.HasNext)
+		assert.Empty(t, last.NextPageToken)
+
+		for _, token := range []string{"not base64!", "b2Zmc2V0", reqparse.EncodePageToken(-1)} {
+			_, err := reqparse.NormalizePagination(&v1.PageRequest{PageToken: token}, limits)
+			assert.EqualError(t, err, "page_token must be the next_page_token of a previous response", "token %q", token)
+		}
+	})
+
+	t.Run("Handlers", func(t *testing.T) {
+		ctx := newTestContext(context.Background())
+		handler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+
+		_, err := handler.GetDiaryEntry(ctx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: "not-a-uuid"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		assert.ErrorContains(t, err, "id must be a UUID")
+
+		_, err = handler.CreateDiaryEntry(ctx, connect.NewRequest(&v1.CreateDiaryEntryRequest{Content: "Ran 5k", EntryDate: "yesterday"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		assert.ErrorContains(t, err, "entry_date must be a date in YYYY-MM-DD format")
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+)
+
+// ResearchHandler implements the research service RPCs
+type ResearchHandler struct {
+	repo ResearchRepository
+	log  *slog.Logger
+}
+
+// NewResearchHandler creates a new research handler
+func NewResearchHandler(repo ResearchRepository, log *slog.Logger) *ResearchHandler {
+	return &ResearchHandler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// GetResearchSettings returns whether the user opted in to research exports
+func (h *ResearchHandler) GetResearchSettings(ctx context.Context, req *connect.Request[v1.GetResearchSettingsRequest]) (*connect.Response[v1.GetResearchSettingsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	optedIn, err := h.repo.GetOptIn(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get research opt-in", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get research settings"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetResearchSettingsResponse{
+		Settings: &v1.ResearchSettings{OptedIn: optedIn},
+	})
+
+	return res, nil
+}
+
+// UpdateResearchSettings opts the user in to research exports, or back out
+func (h *ResearchHandler) UpdateResearchSettings(ctx context.Context, req *connect.Request[v1.UpdateResearchSettingsRequest]) (*connect.Response[v1.UpdateResearchSettingsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	optedIn, err := h.repo.SetOptIn(ctx, userID, req.Msg.OptedIn)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to set research opt-in", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update research settings"))
+	}
+	h.log.InfoContext(ctx, "Research opt-in updated", "optedIn", optedIn)
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateResearchSettingsResponse{
+		Settings: &v1.ResearchSettings{OptedIn: optedIn},
+	})
+
+	return res, nil
+}
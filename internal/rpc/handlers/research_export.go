@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxResearchExportDays is the longest window of a research export
+	maxResearchExportDays = 366
+	// researchDownloadTTL is how long a signed dataset download URL is valid
+	researchDownloadTTL = time.Hour
+)
+
+// researchExportStatuses maps the stored statuses of research exports to their proto values
+var researchExportStatuses = map[string]v1.ResearchExportStatus{
+	repo.ResearchExportPending:   v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_PENDING,
+	repo.ResearchExportRunning:   v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_RUNNING,
+	repo.ResearchExportCompleted: v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_COMPLETED,
+	repo.ResearchExportFailed:    v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_FAILED,
+}
+
+// ResearchExportHandler implements the research export service RPCs
+type ResearchExportHandler struct {
+	repo       ResearchRepository
+	store      storage.Store
+	cfg        config.ResearchConfig
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewResearchExportHandler creates a new research export handler, signing dataset downloads
+// from store
+func NewResearchExportHandler(repo ResearchRepository, store storage.Store, cfg config.ResearchConfig, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *ResearchExportHandler {
+	return &ResearchExportHandler{
+		repo:       repo,
+		store:      store,
+		cfg:        cfg,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// StartResearchExport queues an export for the research export job
+func (h *ResearchExportHandler) StartResearchExport(ctx context.Context, req *connect.Request[v1.StartResearchExportRequest]) (*connect.Response[v1.StartResearchExportResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+	// Without the job, queued exports would never be written
+	if !h.cfg.Enabled {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("research exports are disabled"))
+	}
+
+	// Validate input
+	startDate, err := reqparse.ParseDate("start_date", req.Msg.StartDate)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid start date format", "startDate", req.Msg.StartDate, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	endDate, err := reqparse.ParseDate("end_date", req.Msg.EndDate)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid end date format", "endDate", req.Msg.EndDate, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	if endDate.Before(startDate) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+	if endDate.Sub(startDate) >= maxResearchExportDays*24*time.Hour {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("exports cover at most %d days", maxResearchExportDays))
+	}
+	minGroupSize := int(req.Msg.MinGroupSize)
+	if minGroupSize == 0 {
+		minGroupSize = h.cfg.MinGroupSize
+	}
+	if minGroupSize < h.cfg.MinGroupSize {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("min_group_size must be at least %d", h.cfg.MinGroupSize))
+	}
+
+	export, err := h.repo.CreateExport(ctx, callerID, startDate, endDate, minGroupSize, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create research export", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to start research export"))
+	}
+	h.log.InfoContext(ctx, "Research export requested", "callerID", callerID, "exportID", export.ID, "startDate", req.Msg.StartDate, "endDate", req.Msg.EndDate, "minGroupSize", minGroupSize)
+
+	// Create response
+	res := connect.NewResponse(&v1.StartResearchExportResponse{
+		Export: toProtoResearchExport(export, nil),
+	})
+
+	return res, nil
+}
+
+// GetResearchExport returns an export with download URLs of its datasets
+func (h *ResearchExportHandler) GetResearchExport(ctx context.Context, req *connect.Request[v1.GetResearchExportRequest]) (*connect.Response[v1.GetResearchExportResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	exportID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid research export ID", "exportID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	export, err := h.repo.GetExport(ctx, exportID)
+	if err != nil {
+		if errors.Is(err, repo.ErrResearchExportNotFound) {
+			return nil, connect.NewError(connect.CodeNotFound, err)
+		}
+		h.log.ErrorContext(ctx, "Failed to get research export", "exportID", exportID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get research export"))
+	}
+	datasets, err := repo.ResearchDatasets(export)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to read research datasets", "exportID", exportID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get research export"))
+	}
+
+	// Sign the downloads of the datasets
+	now := h.clock.Now()
+	protoDatasets := make([]*v1.ResearchDataset, len(datasets))
+	for i, dataset := range datasets {
+		url, err := h.store.SignDownload(ctx, dataset.Key, researchDownloadTTL)
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to sign research dataset download", "exportID", exportID, "dataset", dataset.Name, "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get research export"))
+		}
+		protoDatasets[i] = &v1.ResearchDataset{
+			Name:                 dataset.Name,
+			Rows:                 int32(dataset.Rows),
+			DownloadUrl:          url,
+			DownloadUrlExpiresAt: timestamppb.New(now.Add(researchDownloadTTL)),
+		}
+	}
+	h.log.InfoContext(ctx, "Research export accessed", "callerID", callerID, "exportID", exportID)
+
+	// Create response
+	res := connect.NewResponse(&v1.GetResearchExportResponse{
+		Export: toProtoResearchExport(export, protoDatasets),
+	})
+
+	return res, nil
+}
+
+// ListResearchExports lists the exports, newest first
+func (h *ResearchExportHandler) ListResearchExports(ctx context.Context, req *connect.Request[v1.ListResearchExportsRequest]) (*connect.Response[v1.ListResearchExportsResponse], error) {
+	if _, err := authorizeAdmin(ctx, h.log); err != nil {
+		return nil, err
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	exports, err := h.repo.ListExports(ctx, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list research exports", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list research exports"))
+	}
+	total, err := h.repo.CountExports(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count research exports", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list research exports"))
+	}
+
+	// Create response; downloads are signed by GetResearchExport only
+	protoExports := make([]*v1.ResearchExport, len(exports))
+	for i, export := range exports {
+		datasets, err := repo.ResearchDatasets(export)
+		if err != nil {
+			h.log.ErrorContext(ctx, "Failed to read research datasets", "exportID", export.ID, "error", err)
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list research exports"))
+		}
+		protoDatasets := make([]*v1.ResearchDataset, len(datasets))
+		for j, dataset := range datasets {
+			protoDatasets[j] = &v1.ResearchDataset{Name: dataset.Name, Rows: int32(dataset.Rows)}
+		}
+		protoExports[i] = toProtoResearchExport(export, protoDatasets)
+	}
+	res := connect.NewResponse(&v1.ListResearchExportsResponse{
+		Exports:    protoExports,
+		Pagination: page.Response(total),
+	})
+
+	return res, nil
+}
+
+// toProtoResearchExport converts an export with its datasets to its proto message
+func toProtoResearchExport(export db.ResearchExport, datasets []*v1.ResearchDataset) *v1.ResearchExport {
+	protoExport := &v1.ResearchExport{
+		Id:               export.ID.String(),
+		Status:           researchExportStatuses[export.Status],
+		StartDate:        export.StartDate.Time.Format("2006-01-02"),
+		EndDate:          export.EndDate.Time.Format("2006-01-02"),
+		MinGroupSize:     export.MinGroupSize,
+		Contributors:     export.Contributors,
+		SuppressedGroups: export.SuppressedGroups,
+		Datasets:         datasets,
+		Error:            export.LastError.String,
+		CreatedAt:        timestamppb.New(export.CreatedAt),
+	}
+	if export.CompletedAt.Valid {
+		protoExport.CompletedAt = timestamppb.New(export.CompletedAt.Time)
+	}
+	return protoExport
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/atreya2011/health-management-api/internal/research"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore is a store whose writes fail
+type failingStore struct {
+	storage.Store
+}
+
+func (failingStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	return errors.New("store unavailable")
+}
+
+func TestResearch(t *testing.T) {
+	resetDB(t, testPool)
+	researchRepo := repo.NewResearchRepository(testPool)
+	cfg := config.ResearchConfig{
+		Enabled:      true,
+		MinGroupSize: 2,
+		ExportPrefix: "research/",
+		Interval:     time.Minute,
+	}
+	store := newTestStore(t)
+	settingsHandler := NewResearchHandler(researchRepo, testLogger)
+	handler := NewResearchExportHandler(researchRepo, store, cfg, DefaultPageLimits, testLogger, mockClock)
+	job := research.NewJob(researchRepo, store, cfg, testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	adminCtx := newTestContext(context.WithValue(ctx, auth.RolesContextKey, []string{auth.RoleAdmin}))
+	fixedTime := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	// Two users opt in and a third doesn't; each has a January body record and exercise
+	optedIn := make([]uuid.UUID, 2)
+	for i := range optedIn {
+		userID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		_, err = researchRepo.SetOptIn(ctx, userID, true)
+		require.NoError(t, err)
+		optedIn[i] = userID
+	}
+	optedOut, err := testutil.CreateTestUser(ctx, testQueries)
+	require.NoError(t, err)
+	january := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	for i, userID := range append(optedIn, optedOut) {
+		weight := 70.0 + 10*float64(i)
+		_, err := testutil.CreateTestBodyRecord(ctx, testQueries, userID, january, &weight, nil, fixedTime)
+		require.NoError(t, err)
+		duration := int32(30)
+		_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, userID, []string{"Running", " running", "Running"}[i], &duration, nil, january.Add(7*time.Hour), fixedTime)
+		require.NoError(t, err)
+	}
+	// Groups of a single user are left out
+	weight := 90.0
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, optedIn[0], january.AddDate(0, 1, 0), &weight, nil, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, optedIn[1], "Yoga", nil, nil, january.Add(8*time.Hour), fixedTime)
+	require.NoError(t, err)
+
+	t.Run("Opt-In Settings", func(t *testing.T) {
+		resp, err := settingsHandler.GetResearchSettings(testCtx, connect.NewRequest(&v1.GetResearchSettingsRequest{}))
+		require.NoError(t, err)
+		assert.False(t, resp.Msg.Settings.OptedIn)
+
+		updateResp, err := settingsHandler.UpdateResearchSettings(testCtx, connect.NewRequest(&v1.UpdateResearchSettingsRequest{OptedIn: true}))
+		require.NoError(t, err)
+		assert.True(t, updateResp.Msg.Settings.OptedIn)
+		updateResp, err = settingsHandler.UpdateResearchSettings(testCtx, connect.NewRequest(&v1.UpdateResearchSettingsRequest{OptedIn: false}))
+		require.NoError(t, err)
+		assert.False(t, updateResp.Msg.Settings.OptedIn)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		startResp, err := handler.StartResearchExport(adminCtx, connect.NewRequest(&v1.StartResearchExportRequest{
+			StartDate: "2024-01-01",
+			EndDate:   "2024-02-29",
+		}))
+		require.NoError(t, err)
+		export := startResp.Msg.Export
+		assert.Equal(t, v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_PENDING, export.Status)
+		assert.EqualValues(t, 2, export.MinGroupSize)
+
+		job.ExportDue(ctx)
+
+		getResp, err := handler.GetResearchExport(adminCtx, connect.NewRequest(&v1.GetResearchExportRequest{Id: export.Id}))
+		require.NoError(t, err)
+		export = getResp.Msg.Export
+		assert.Equal(t, v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_COMPLETED, export.Status)
+		assert.EqualValues(t, 2, export.Contributors)
+		assert.EqualValues(t, 2, export.SuppressedGroups)
+		require.Len(t, export.Datasets, 3)
+		for _, dataset := range export.Datasets {
+			assert.NotEmpty(t, dataset.DownloadUrl)
+		}
+
+		readDataset := func(t *testing.T, name string) string {
+			t.Helper()
+			content, err := store.ReadPrefix(ctx, fmt.Sprintf("research/%s/%s.csv", export.Id, name), 1<<20)
+			require.NoError(t, err)
+			return string(content)
+		}
+		// The opted-out user's weight of 90 kg isn't averaged in, and February is left out
+		assert.Equal(t, "month,users,weight_records,avg_weight_kg,body_fat_records,avg_body_fat_percentage\n2024-01,2,2,75.00,0,\n", readDataset(t, research.DatasetBodyMetrics))
+		// Activities are grouped case-insensitively; yoga of a single user is left out
+		assert.Equal(t, "month,activity,users,sessions,total_minutes,calorie_records,avg_calories_burned\n2024-01,running,2,2,60,0,\n", readDataset(t, research.DatasetExerciseActivity))
+		assert.Equal(t, "month,users,days,avg_steps\n", readDataset(t, research.DatasetDailySteps))
+		// No row refers to a user
+		for _, userID := range append(optedIn, optedOut) {
+			assert.NotContains(t, readDataset(t, research.DatasetBodyMetrics), userID.String())
+		}
+
+		listResp, err := handler.ListResearchExports(adminCtx, connect.NewRequest(&v1.ListResearchExportsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Exports, 1)
+		assert.EqualValues(t, 1, listResp.Msg.Exports[0].Datasets[0].Rows)
+		assert.Empty(t, listResp.Msg.Exports[0].Datasets[0].DownloadUrl)
+	})
+
+	t.Run("Larger Groups Suppress More", func(t *testing.T) {
+		startResp, err := handler.StartResearchExport(adminCtx, connect.NewRequest(&v1.StartResearchExportRequest{
+			StartDate:    "2024-01-01",
+			EndDate:      "2024-01-31",
+			MinGroupSize: 3,
+		}))
+		require.NoError(t, err)
+		job.ExportDue(ctx)
+
+		getResp, err := handler.GetResearchExport(adminCtx, connect.NewRequest(&v1.GetResearchExportRequest{Id: startResp.Msg.Export.Id}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, getResp.Msg.Export.SuppressedGroups)
+		for _, dataset := range getResp.Msg.Export.Datasets {
+			assert.Zero(t, dataset.Rows, dataset.Name)
+		}
+	})
+
+	t.Run("Failed Exports Are Retried", func(t *testing.T) {
+		failingJob := research.NewJob(researchRepo, failingStore{store}, cfg, testLogger, mockClock)
+		startResp, err := handler.StartResearchExport(adminCtx, connect.NewRequest(&v1.StartResearchExportRequest{
+			StartDate: "2024-01-01",
+			EndDate:   "2024-01-31",
+		}))
+		require.NoError(t, err)
+		defer mockClock.SetTime(fixedTime)
+
+		getStatus := func(t *testing.T) *v1.ResearchExport {
+			t.Helper()
+			resp, err := handler.GetResearchExport(adminCtx, connect.NewRequest(&v1.GetResearchExportRequest{Id: startResp.Msg.Export.Id}))
+			require.NoError(t, err)
+			return resp.Msg.Export
+		}
+		for attempt := 1; attempt < 3; attempt++ {
+			failingJob.ExportDue(ctx)
+			export := getStatus(t)
+			assert.Equal(t, v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_PENDING, export.Status)
+			assert.Contains(t, export.Error, "store unavailable")
+
+			// Not retried before the delay
+			failingJob.ExportDue(ctx)
+			mockClock.SetTime(mockClock.Now().Add(10 * time.Minute))
+		}
+		failingJob.ExportDue(ctx)
+		export := getStatus(t)
+		assert.Equal(t, v1.ResearchExportStatus_RESEARCH_EXPORT_STATUS_FAILED, export.Status)
+		assert.NotNil(t, export.CompletedAt)
+	})
+
+	t.Run("Error - Invalid Input", func(t *testing.T) {
+		for _, req := range []*v1.StartResearchExportRequest{
+			{StartDate: "2024-01-01"},
+			{StartDate: "2024-02-01", EndDate: "2024-01-01"},
+			{StartDate: "2023-01-01", EndDate: "2024-01-31"},
+			{StartDate: "2024-01-01", EndDate: "2024-01-31", MinGroupSize: 1},
+		} {
+			_, err := handler.StartResearchExport(adminCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "%v", req)
+		}
+		_, err := handler.GetResearchExport(adminCtx, connect.NewRequest(&v1.GetResearchExportRequest{Id: uuid.NewString()}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Disabled", func(t *testing.T) {
+		disabled := cfg
+		disabled.Enabled = false
+		disabledHandler := NewResearchExportHandler(researchRepo, store, disabled, DefaultPageLimits, testLogger, mockClock)
+		_, err := disabledHandler.StartResearchExport(adminCtx, connect.NewRequest(&v1.StartResearchExportRequest{StartDate: "2024-01-01", EndDate: "2024-01-31"}))
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Missing Admin Role", func(t *testing.T) {
+		_, err := handler.ListResearchExports(testCtx, connect.NewRequest(&v1.ListResearchExportsRequest{}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		_, err = handler.StartResearchExport(testCtx, connect.NewRequest(&v1.StartResearchExportRequest{StartDate: "2024-01-01", EndDate: "2024-01-31"}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+}
+
+func TestResearchDatasets(t *testing.T) {
+	month := func(m time.Month) pgtype.Date {
+		return pgtype.Date{Time: time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	}
+	data := repo.ResearchData{
+		DailySteps: []db.ListResearchDailyStepsRow{
+			{Month: month(time.January), Users: 10, Days: 300, AvgSteps: 8123.456},
+			{Month: month(time.February), Users: 9, Days: 250, AvgSteps: 7000},
+		},
+	}
+
+	datasets := research.Datasets(data, 10)
+	require.Len(t, datasets, 3)
+	steps := datasets[2]
+	assert.Equal(t, research.DatasetDailySteps, steps.Name)
+	// Groups of exactly the minimum size are kept
+	assert.Equal(t, [][]string{{"month", "users", "days", "avg_steps"}, {"2024-01", "10", "300", "8123.46"}}, steps.Rows)
+	assert.Equal(t, 1, steps.Suppressed)
+	for _, dataset := range datasets[:2] {
+		assert.Len(t, dataset.Rows, 1, "only the header of %s", dataset.Name)
+		assert.Zero(t, dataset.Suppressed)
+	}
+}
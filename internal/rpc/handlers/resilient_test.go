@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/metrics"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingDB fails the first failures statements it runs with err, then runs them on the test database
+type failingDB struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *failingDB) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *failingDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if err := f.fail(); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return testPool.Exec(ctx, sql, args...)
+}
+
+func (f *failingDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return testPool.Query(ctx, sql, args...)
+}
+
+func (f *failingDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if err := f.fail(); err != nil {
+		return errRow{err}
+	}
+	return testPool.QueryRow(ctx, sql, args...)
+}
+
+func (f *failingDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return testPool.Begin(ctx)
+}
+
+// resettableDB is a failingDB counting the resets of its pool
+type resettableDB struct {
+	failingDB
+	resets int
+}
+
+func (r *resettableDB) Reset() {
+	r.resets++
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...any) error { return r.err }
+
+func TestResilientDB(t *testing.T) {
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	breakerClock := clock.NewMockClock(fixedTime)
+	cfg := &config.DatabaseConfig{
+		Retry:          config.DatabaseRetryConfig{MaxAttempts: 3, Backoff: time.Millisecond},
+		CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: 10 * time.Second},
+	}
+	shutdown := &pgconn.PgError{Code: "57P01"} // admin_shutdown, as when the primary fails over
+	const read = "SELECT 1"
+	const write = "UPDATE users SET updated_at = updated_at WHERE id = $1"
+
+	t.Run("Reads Are Retried", func(t *testing.T) {
+		db := &failingDB{failures: 2, err: shutdown}
+		resilient := repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		var one int
+		require.NoError(t, resilient.QueryRow(ctx, read).Scan(&one))
+		assert.Equal(t, 1, one)
+		assert.Equal(t, 3, db.calls)
+	})
+
+	t.Run("Writes Are Retried Only If Not Sent", func(t *testing.T) {
+		db := &failingDB{failures: 1, err: shutdown}
+		resilient := repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		_, err := resilient.Exec(ctx, write, testUserID)
+		assert.ErrorIs(t, err, shutdown)
+		assert.Equal(t, 1, db.calls)
+
+		// The server refused to start, so the write didn't run
+		db = &failingDB{failures: 1, err: &pgconn.PgError{Code: "57P03"}} // cannot_connect_now
+		resilient = repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		_, err = resilient.Exec(ctx, write, testUserID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, db.calls)
+	})
+
+	t.Run("Statement Errors Are Not Retried", func(t *testing.T) {
+		db := &failingDB{failures: 1, err: &pgconn.PgError{Code: "23505"}} // unique_violation
+		resilient := repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		err := resilient.QueryRow(ctx, read).Scan(new(int))
+		assert.Error(t, err)
+		assert.Equal(t, 1, db.calls)
+
+		db = &failingDB{}
+		resilient = repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		err = resilient.QueryRow(ctx, "SELECT id FROM users WHERE subject_id = 'missing'").Scan(new(string))
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+		assert.Equal(t, 1, db.calls)
+	})
+
+	t.Run("Circuit Breaker", func(t *testing.T) {
+		db := &failingDB{failures: 6, err: shutdown}
+		resilient := repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		tracked := repo.WithUnavailableTracking(ctx)
+
+		// Two statements failing after their retries open the circuit
+		for range 2 {
+			assert.ErrorIs(t, resilient.QueryRow(tracked, read).Scan(new(int)), shutdown)
+		}
+		assert.True(t, repo.DatabaseUnavailable(tracked))
+		assert.False(t, repo.DatabaseUnavailable(repo.WithUnavailableTracking(ctx)))
+		assert.Equal(t, 6, db.calls)
+
+		// While open, statements fail without reaching the database
+		_, err := resilient.Query(ctx, read)
+		assert.ErrorIs(t, err, repo.ErrDatabaseUnavailable)
+		_, err = resilient.Begin(ctx)
+		assert.ErrorIs(t, err, repo.ErrDatabaseUnavailable)
+		assert.Equal(t, 6, db.calls)
+
+		// After the cooldown, a successful statement closes the circuit
+		breakerClock.SetTime(fixedTime.Add(cfg.CircuitBreaker.Cooldown))
+		rows, err := resilient.Query(ctx, read)
+		require.NoError(t, err)
+		rows.Close()
+		_, err = resilient.Exec(ctx, write, testUserID)
+		require.NoError(t, err)
+	})
+
+	t.Run("Failed Probe Reopens the Circuit", func(t *testing.T) {
+		breakerClock.SetTime(fixedTime)
+		db := &failingDB{failures: 100, err: shutdown}
+		resilient := repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+		for range 2 {
+			assert.Error(t, resilient.QueryRow(ctx, read).Scan(new(int)))
+		}
+		breakerClock.SetTime(fixedTime.Add(cfg.CircuitBreaker.Cooldown))
+		assert.ErrorIs(t, resilient.QueryRow(ctx, read).Scan(new(int)), shutdown)
+		calls := db.calls
+		assert.ErrorIs(t, resilient.QueryRow(ctx, read).Scan(new(int)), repo.ErrDatabaseUnavailable)
+		assert.Equal(t, calls, db.calls)
+	})
+
+	t.Run("Failovers Reset the Pool", func(t *testing.T) {
+		breakerClock.SetTime(fixedTime)
+		failovers := metrics.DBFailovers.Value()
+		readOnly := &pgconn.PgError{Code: "25006"} // read_only_sql_transaction, a write reaching a demoted primary
+		db := &resettableDB{failingDB: failingDB{failures: 1, err: readOnly}}
+		resilient := repo.NewResilientDB(db, cfg, testLogger, breakerClock)
+
+		// The write didn't run, so it is retried on a new connection
+		_, err := resilient.Exec(ctx, write, testUserID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, db.calls)
+		assert.Equal(t, 1, db.resets)
+		assert.Equal(t, failovers+1, metrics.DBFailovers.Value())
+
+		// Statements failing on the replaced connections right after don't reset the pool again
+		db.failures, db.calls = 1, 0
+		_, err = resilient.Exec(ctx, write, testUserID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, db.resets)
+
+		// Nor do statement errors
+		db.failures, db.calls, db.err = 1, 0, &pgconn.PgError{Code: "23505"} // unique_violation
+		breakerClock.SetTime(fixedTime.Add(time.Minute))
+		assert.Error(t, resilient.QueryRow(ctx, read).Scan(new(int)))
+		assert.Equal(t, 1, db.resets)
+
+		// Writes of transactions reaching a read-only server reset the pool too
+		tx, err := resilient.Begin(ctx)
+		require.NoError(t, err)
+		defer tx.Rollback(ctx) //nolint:errcheck
+		_, err = tx.Exec(ctx, "SET TRANSACTION READ ONLY")
+		require.NoError(t, err)
+		_, err = tx.Exec(ctx, write, testUserID)
+		assert.ErrorAs(t, err, new(*pgconn.PgError))
+		assert.Equal(t, 2, db.resets)
+		assert.Equal(t, failovers+2, metrics.DBFailovers.Value())
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/config"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+)
+
+// RetentionHandler implements the retention service RPCs
+type RetentionHandler struct {
+	repo   RetentionRepository
+	policy config.RetentionConfig
+	log    *slog.Logger
+}
+
+// NewRetentionHandler creates a new retention handler reporting policy
+func NewRetentionHandler(repo RetentionRepository, policy config.RetentionConfig, log *slog.Logger) *RetentionHandler {
+	return &RetentionHandler{
+		repo:   repo,
+		policy: policy,
+		log:    log,
+	}
+}
+
+// GetRetentionSettings returns the retention policy and whether the user opted out of it
+func (h *RetentionHandler) GetRetentionSettings(ctx context.Context, req *connect.Request[v1.GetRetentionSettingsRequest]) (*connect.Response[v1.GetRetentionSettingsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	optedOut, err := h.repo.GetOptOut(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get retention opt-out", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get retention settings"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetRetentionSettingsResponse{
+		Settings: h.toProtoSettings(optedOut),
+	})
+
+	return res, nil
+}
+
+// UpdateRetentionSettings opts the user out of the retention policy, or back into it
+func (h *RetentionHandler) UpdateRetentionSettings(ctx context.Context, req *connect.Request[v1.UpdateRetentionSettingsRequest]) (*connect.Response[v1.UpdateRetentionSettingsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	optedOut, err := h.repo.SetOptOut(ctx, userID, req.Msg.OptedOut)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to set retention opt-out", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update retention settings"))
+	}
+	h.log.InfoContext(ctx, "Retention opt-out updated", "optedOut", optedOut)
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateRetentionSettingsResponse{
+		Settings: h.toProtoSettings(optedOut),
+	})
+
+	return res, nil
+}
+
+// toProtoSettings returns the settings of the policy for a user
+func (h *RetentionHandler) toProtoSettings(optedOut bool) *v1.RetentionSettings {
+	return &v1.RetentionSettings{
+		OptedOut:          optedOut,
+		ChangeHistoryDays: int32(h.policy.ChangeHistoryDays),
+		ArchiveAfterYears: int32(h.policy.ArchiveAfterYears),
+		Enabled:           h.policy.Enabled,
+	}
+}
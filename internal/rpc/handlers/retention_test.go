@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/retention"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention(t *testing.T) {
+	resetDB(t, testPool)
+	retentionRepo := repo.NewRetentionRepository(testPool)
+	policy := config.RetentionConfig{
+		Enabled:           true,
+		ChangeHistoryDays: 365,
+		APIUsageDays:      90,
+		ArchiveAfterYears: 2,
+		ArchivePrefix:     "archive/",
+		Interval:          24 * time.Hour,
+	}
+	handler := NewRetentionHandler(retentionRepo, policy, testLogger)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	archiveDir := t.TempDir()
+	store := storage.NewLocal(archiveDir, "http://attachments.test", []byte("test-signing-key"), mockClock)
+	job := retention.NewJob(retentionRepo, store, policy, testLogger, mockClock)
+
+	// The test user keeps to the policy, the other user opts out
+	otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+	require.NoError(t, err)
+	_, err = retentionRepo.SetOptOut(ctx, otherUserID, true)
+	require.NoError(t, err)
+
+	// Record changes of 400 and 10 days ago, and records of 3 years and 1 year ago, for both users
+	for _, userID := range []uuid.UUID{testUserID, otherUserID} {
+		for _, days := range []int{400, 10} {
+			_, err := testPool.Exec(ctx,
+				"INSERT INTO record_changes (user_id, entity_type, entity_id, action, source, changed_at) VALUES ($1, 'body_record', $2, 'created', 'user', $3)",
+				userID, uuid.New(), fixedTime.AddDate(0, 0, -days))
+			require.NoError(t, err)
+			// Daily request counts are purged whether users opted out or not
+			_, err = testPool.Exec(ctx, "INSERT INTO api_usage (user_id, day, request_count) VALUES ($1, $2, 1)", userID, fixedTime.AddDate(0, 0, -days))
+			require.NoError(t, err)
+		}
+		for _, years := range []int{3, 1} {
+			weight := 70.0
+			date := fixedTime.Truncate(24*time.Hour).AddDate(-years, 0, 0)
+			_, err := testutil.CreateTestBodyRecord(ctx, testQueries, userID, date, &weight, nil, fixedTime)
+			require.NoError(t, err)
+			_, err = testutil.CreateTestExerciseRecord(ctx, testQueries, userID, "Running", nil, nil, date.Add(18*time.Hour), fixedTime)
+			require.NoError(t, err)
+		}
+	}
+
+	// A photo of the test user's old body record, archived and deleted with it
+	photoKey := "attachments/" + uuid.NewString()
+	require.NoError(t, store.Put(ctx, photoKey, "image/jpeg", []byte("photo")))
+	_, err = testPool.Exec(ctx,
+		"INSERT INTO attachments (id, user_id, body_record_id, body_record_date, storage_key, content_type, size_bytes, status) SELECT $1, user_id, id, date, $2, 'image/jpeg', 5, 'ready' FROM body_records WHERE user_id = $3 AND date < $4",
+		uuid.New(), photoKey, testUserID, fixedTime.AddDate(-2, 0, 0))
+	require.NoError(t, err)
+
+	countRows := func(t *testing.T, table string, userID uuid.UUID) int {
+		t.Helper()
+		var count int
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT COUNT(*) FROM "+table+" WHERE user_id = $1", userID).Scan(&count))
+		return count
+	}
+
+	t.Run("Opt-Out Settings", func(t *testing.T) {
+		resp, err := handler.GetRetentionSettings(testCtx, connect.NewRequest(&v1.GetRetentionSettingsRequest{}))
+		require.NoError(t, err)
+		assert.False(t, resp.Msg.Settings.OptedOut)
+		assert.True(t, resp.Msg.Settings.Enabled)
+		assert.EqualValues(t, 365, resp.Msg.Settings.ChangeHistoryDays)
+		assert.EqualValues(t, 2, resp.Msg.Settings.ArchiveAfterYears)
+
+		updated, err := handler.UpdateRetentionSettings(testCtx, connect.NewRequest(&v1.UpdateRetentionSettingsRequest{OptedOut: true}))
+		require.NoError(t, err)
+		assert.True(t, updated.Msg.Settings.OptedOut)
+		updated, err = handler.UpdateRetentionSettings(testCtx, connect.NewRequest(&v1.UpdateRetentionSettingsRequest{OptedOut: false}))
+		require.NoError(t, err)
+		assert.False(t, updated.Msg.Settings.OptedOut)
+	})
+
+	t.Run("Dry Run Only Reports", func(t *testing.T) {
+		report, err := job.Apply(ctx, true)
+		require.NoError(t, err)
+		assert.Equal(t, retention.Report{DryRun: true, RecordChangesPurged: 1, APIUsagePurged: 2, BodyRecordsArchived: 1, AttachmentsArchived: 1, ExerciseRecordsArchived: 1}, report)
+		assert.Equal(t, 2, countRows(t, "record_changes", testUserID))
+		assert.Equal(t, 2, countRows(t, "body_records", testUserID))
+	})
+
+	t.Run("Old Data Is Purged and Archived", func(t *testing.T) {
+		report, err := job.Apply(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, retention.Report{RecordChangesPurged: 1, APIUsagePurged: 2, BodyRecordsArchived: 1, AttachmentsArchived: 1, ExerciseRecordsArchived: 1, FilesDeleted: 1}, report)
+
+		assert.Equal(t, 1, countRows(t, "record_changes", testUserID))
+		assert.Equal(t, 1, countRows(t, "api_usage", testUserID))
+		assert.Equal(t, 1, countRows(t, "api_usage", otherUserID))
+		assert.Equal(t, 1, countRows(t, "body_records", testUserID))
+		assert.Equal(t, 1, countRows(t, "exercise_records", testUserID))
+		// Users who opted out keep all their data
+		assert.Equal(t, 2, countRows(t, "record_changes", otherUserID))
+		assert.Equal(t, 2, countRows(t, "body_records", otherUserID))
+		assert.Equal(t, 2, countRows(t, "exercise_records", otherUserID))
+
+		archives, err := filepath.Glob(filepath.Join(archiveDir, "archive", "body_records", "2024-01-15", "*.jsonl"))
+		require.NoError(t, err)
+		require.Len(t, archives, 1)
+		f, err := os.Open(archives[0])
+		require.NoError(t, err)
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		require.True(t, scanner.Scan())
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		assert.Equal(t, testUserID.String(), record["user_id"])
+		assert.Equal(t, "2021-01-15", record["date"])
+		assert.False(t, scanner.Scan())
+
+		// The attachment row is archived too, and its photo deleted
+		assert.Equal(t, 0, countRows(t, "attachments", testUserID))
+		archives, err = filepath.Glob(filepath.Join(archiveDir, "archive", "attachments", "2024-01-15", "*.jsonl"))
+		require.NoError(t, err)
+		assert.Len(t, archives, 1)
+		_, err = store.Stat(ctx, photoKey)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		report, err = job.Apply(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, retention.Report{}, report)
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/sandbox"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSandboxReset(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	bodyHandler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	diaryHandler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	userRepo := repo.NewUserRepository(testPool)
+	resetter := sandbox.NewResetter(repo.NewSandboxRepository(testPool, nil, nil), "sandbox-test", 3*time.Hour, testLogger, mockClock)
+
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	weight := 80.0
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, mockClock.Now().UTC().Truncate(24*time.Hour), &weight, nil, mockClock.Now())
+	require.NoError(t, err)
+
+	sandboxCtx := func(t *testing.T) context.Context {
+		t.Helper()
+		user, err := userRepo.FindBySubjectID(ctx, "sandbox-test")
+		require.NoError(t, err)
+		return context.WithValue(ctx, auth.UserContextKey, user.ID)
+	}
+	fixtures := sandbox.Fixtures(mockClock.Now())
+
+	require.NoError(t, resetter.Reset(ctx))
+	sCtx := sandboxCtx(t)
+
+	// Changes made through the API, as on a demo environment
+	_, err = bodyHandler.CreateBodyRecord(sCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-15",
+		WeightKg: wrapperspb.Double(99),
+	}))
+	require.NoError(t, err)
+	_, err = diaryHandler.CreateDiaryEntry(sCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+		Content:   "Scribbled by a visitor",
+		EntryDate: "2024-01-15",
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, resetter.Reset(ctx))
+	sCtx = sandboxCtx(t)
+
+	t.Run("Restores Fixtures", func(t *testing.T) {
+		bodyResp, err := bodyHandler.ListBodyRecords(sCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{
+			Pagination: &v1.PageRequest{PageSize: 100},
+		}))
+		require.NoError(t, err)
+		assert.EqualValues(t, len(fixtures.BodyRecords), bodyResp.Msg.Pagination.TotalItems)
+		require.NotEmpty(t, bodyResp.Msg.BodyRecords)
+		assert.Equal(t, *fixtures.BodyRecords[0].WeightKg, bodyResp.Msg.BodyRecords[0].WeightKg.GetValue())
+
+		diaryResp, err := diaryHandler.ListDiaryEntries(sCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{
+			Pagination: &v1.PageRequest{PageSize: 100},
+		}))
+		require.NoError(t, err)
+		assert.EqualValues(t, len(fixtures.DiaryEntries), diaryResp.Msg.Pagination.TotalItems)
+	})
+
+	t.Run("Leaves Other Users Untouched", func(t *testing.T) {
+		resp, err := bodyHandler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.BodyRecords, 1)
+		assert.Equal(t, weight, resp.Msg.BodyRecords[0].WeightKg.GetValue())
+	})
+
+	t.Run("Next Reset", func(t *testing.T) {
+		assert.Equal(t, time.Date(2024, 1, 16, 3, 0, 0, 0, time.UTC), resetter.NextReset(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)))
+		assert.Equal(t, time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC), resetter.NextReset(time.Date(2024, 1, 15, 2, 59, 0, 0, time.UTC)))
+		assert.Equal(t, time.Date(2024, 1, 16, 3, 0, 0, 0, time.UTC), resetter.NextReset(time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC)))
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// TestRPCScopes makes sure every RPC of the API has a scope policy
+func TestRPCScopes(t *testing.T) {
+	var procedures int
+	protoregistry.GlobalFiles.RangeFilesByPackage(v1.File_healthapp_v1_body_record_proto.Package(), func(file protoreflect.FileDescriptor) bool {
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				procedure := "/" + string(services.Get(i).FullName()) + "/" + string(methods.Get(j).Name())
+				_, ok := authz.RPCScopes[procedure]
+				assert.True(t, ok, "no scope policy for %s", procedure)
+				procedures++
+			}
+		}
+		return true
+	})
+	assert.Equal(t, len(authz.RPCScopes), procedures, "scope policies of unknown procedures")
+}
+
+func TestScopeInterceptor(t *testing.T) {
+	resetDB(t, testPool)
+	userRepo := repo.NewUserRepository(testPool)
+	handler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	ctx := context.Background()
+	mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	// Serve the service behind the auth and scope interceptors, as in production
+	jwtConfig := &auth.JWTConfig{SecretKey: "test-secret"}
+	interceptors := connect.WithInterceptors(
+		auth.AuthInterceptor(jwtConfig, userRepo, repo.NewSessionRepository(testPool), testLogger),
+		authz.ScopeInterceptor(authz.RPCScopes, testLogger),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewBodyRecordServiceHandler(handler, interceptors))
+	mux.Handle(healthappv1connect.NewFHIRServiceHandler(NewFHIRHandler(repo.NewBodyRecordRepository(testPool), repo.NewStepRecordRepository(testPool), testLogger, mockClock), interceptors))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := healthappv1connect.NewBodyRecordServiceClient(server.Client(), server.URL)
+
+	user, err := userRepo.FindByID(ctx, testUserID)
+	require.NoError(t, err)
+	// token signs a token of the test user granted scope
+	token := func(t *testing.T, scope string) string {
+		t.Helper()
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": user.SubjectID, "scope": scope}).SignedString([]byte(jwtConfig.SecretKey))
+		require.NoError(t, err)
+		return signed
+	}
+	readOnly := token(t, authz.ScopeRecordsRead)
+
+	t.Run("Granted Scope", func(t *testing.T) {
+		req := connect.NewRequest(&v1.ListBodyRecordsRequest{})
+		req.Header().Set("Authorization", "Bearer "+readOnly)
+		_, err := client.ListBodyRecords(ctx, req)
+		require.NoError(t, err)
+
+		createReq := connect.NewRequest(&v1.CreateBodyRecordRequest{Date: "2024-01-15", WeightKg: wrapperspb.Double(70)})
+		createReq.Header().Set("Authorization", "Bearer "+token(t, authz.ScopeRecordsRead+" "+authz.ScopeRecordsWrite))
+		_, err = client.CreateBodyRecord(ctx, createReq)
+		require.NoError(t, err)
+	})
+
+	t.Run("Error - Missing Scope", func(t *testing.T) {
+		for _, signed := range []string{readOnly, token(t, "")} {
+			req := connect.NewRequest(&v1.CreateBodyRecordRequest{Date: "2024-01-14", WeightKg: wrapperspb.Double(70)})
+			req.Header().Set("Authorization", "Bearer "+signed)
+			_, err := client.CreateBodyRecord(ctx, req)
+			assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+			assert.Equal(t, apierror.ReasonMissingScope, apierror.Reason(err))
+		}
+	})
+
+	t.Run("Legacy Tokens", func(t *testing.T) {
+		// Tokens without scopes are granted the legacy scopes during their deprecation
+		legacyConfig := &auth.JWTConfig{SecretKey: jwtConfig.SecretKey, LegacyScopes: []string{authz.ScopeRecordsRead}}
+		legacyMux := http.NewServeMux()
+		legacyMux.Handle(healthappv1connect.NewBodyRecordServiceHandler(handler, connect.WithInterceptors(
+			auth.AuthInterceptor(legacyConfig, userRepo, repo.NewSessionRepository(testPool), testLogger),
+			authz.ScopeInterceptor(authz.RPCScopes, testLogger),
+		)))
+		legacyServer := httptest.NewServer(legacyMux)
+		defer legacyServer.Close()
+		legacyClient := healthappv1connect.NewBodyRecordServiceClient(legacyServer.Client(), legacyServer.URL)
+		legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": user.SubjectID}).SignedString([]byte(jwtConfig.SecretKey))
+		require.NoError(t, err)
+
+		req := connect.NewRequest(&v1.ListBodyRecordsRequest{})
+		req.Header().Set("Authorization", "Bearer "+legacy)
+		_, err = legacyClient.ListBodyRecords(ctx, req)
+		require.NoError(t, err)
+
+		// The scopes of scoped tokens aren't extended
+		createReq := connect.NewRequest(&v1.CreateBodyRecordRequest{Date: "2024-01-14", WeightKg: wrapperspb.Double(70)})
+		createReq.Header().Set("Authorization", "Bearer "+token(t, authz.ScopeSharesWrite))
+		_, err = legacyClient.CreateBodyRecord(ctx, createReq)
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		listReq := connect.NewRequest(&v1.ListBodyRecordsRequest{})
+		listReq.Header().Set("Authorization", "Bearer "+token(t, authz.ScopeSharesWrite))
+		_, err = legacyClient.ListBodyRecords(ctx, listReq)
+		assert.Equal(t, apierror.ReasonMissingScope, apierror.Reason(err))
+	})
+
+	t.Run("Streams", func(t *testing.T) {
+		// Streaming RPCs are authenticated and authorized like unary ones
+		fhirClient := healthappv1connect.NewFHIRServiceClient(server.Client(), server.URL)
+		stream := func(t *testing.T, authorization string) error {
+			t.Helper()
+			req := connect.NewRequest(&v1.StreamObservationsRequest{StartDate: "2024-01-01", EndDate: "2024-01-15"})
+			if authorization != "" {
+				req.Header().Set("Authorization", authorization)
+			}
+			res, err := fhirClient.StreamObservations(ctx, req)
+			require.NoError(t, err)
+			defer res.Close()
+			for res.Receive() {
+			}
+			return res.Err()
+		}
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(stream(t, "")))
+		err := stream(t, "Bearer "+token(t, ""))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonMissingScope, apierror.Reason(err))
+		assert.NoError(t, stream(t, "Bearer "+readOnly))
+	})
+
+	t.Run("Error - Procedure Without Policy", func(t *testing.T) {
+		interceptor := authz.ScopeInterceptor(map[string]string{}, testLogger)
+		_, err := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&v1.ListBodyRecordsResponse{}), nil
+		})(newTestContext(ctx), connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+}
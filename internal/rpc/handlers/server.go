@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ServerHandler implements the server service RPCs
+type ServerHandler struct {
+	info version.Info
+	log  *slog.Logger
+}
+
+// NewServerHandler creates a new server handler describing the build of info
+func NewServerHandler(info version.Info, log *slog.Logger) *ServerHandler {
+	return &ServerHandler{
+		info: info,
+		log:  log,
+	}
+}
+
+// GetServerInfo returns the version and build of the running server
+func (h *ServerHandler) GetServerInfo(ctx context.Context, req *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error) {
+	// Create response
+	serverInfo := &v1.ServerInfo{
+		Version:   h.info.Version,
+		GitSha:    h.info.GitSHA,
+		GoVersion: h.info.GoVersion,
+	}
+	// The build date is injected as text; a malformed one is left out rather than failing the call
+	if buildDate, err := time.Parse(time.RFC3339, h.info.BuildDate); err == nil {
+		serverInfo.BuildDate = timestamppb.New(buildDate)
+	} else if h.info.BuildDate != "" {
+		h.log.WarnContext(ctx, "Invalid build date", "buildDate", h.info.BuildDate, "error", err)
+	}
+	res := connect.NewResponse(&v1.GetServerInfoResponse{
+		ServerInfo: serverInfo,
+	})
+
+	return res, nil
+}
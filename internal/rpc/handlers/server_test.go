@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInfo(t *testing.T) {
+	testCtx := newTestContext(context.Background())
+
+	t.Run("Build Info", func(t *testing.T) {
+		handler := NewServerHandler(version.Info{Version: "v1.2.0", GitSHA: "0123abc", BuildDate: "2024-01-15T10:00:00Z", GoVersion: "go1.22.5"}, testLogger)
+		resp, err := handler.GetServerInfo(testCtx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		require.NoError(t, err)
+		info := resp.Msg.ServerInfo
+		assert.Equal(t, "v1.2.0", info.Version)
+		assert.Equal(t, "0123abc", info.GitSha)
+		assert.Equal(t, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), info.BuildDate.AsTime())
+		assert.Equal(t, "go1.22.5", info.GoVersion)
+	})
+
+	t.Run("Unknown Build Date", func(t *testing.T) {
+		handler := NewServerHandler(version.Get(), testLogger)
+		resp, err := handler.GetServerInfo(testCtx, connect.NewRequest(&v1.GetServerInfoRequest{}))
+		require.NoError(t, err)
+		assert.Equal(t, "dev", resp.Msg.ServerInfo.Version)
+		assert.NotEmpty(t, resp.Msg.ServerInfo.GoVersion)
+	})
+}
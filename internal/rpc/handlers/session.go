@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxDeviceNameLength is the maximum length of a session's device name, in characters
+	maxDeviceNameLength = 100
+	// maxUserAgentLength is the length user agents are truncated to, in bytes
+	maxUserAgentLength = 500
+)
+
+// AuthHandler implements the auth service RPCs
+type AuthHandler struct {
+	repo      SessionRepository
+	users     UserRepository
+	jwtConfig *auth.JWTConfig
+	log       *slog.Logger
+	clock     clock.Clock
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(repo SessionRepository, users UserRepository, jwtConfig *auth.JWTConfig, log *slog.Logger, clock clock.Clock) *AuthHandler {
+	return &AuthHandler{
+		repo:      repo,
+		users:     users,
+		jwtConfig: jwtConfig,
+		log:       log,
+		clock:     clock,
+	}
+}
+
+// CreateSession creates a session for a device of the authenticated user
+func (h *AuthHandler) CreateSession(ctx context.Context, req *connect.Request[v1.CreateSessionRequest]) (*connect.Response[v1.CreateSessionResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+	// Sessions must not extend themselves past their expiry by creating new sessions
+	if _, ok := auth.GetSessionID(ctx); ok {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("sessions must be created with an identity provider token"))
+	}
+
+	// Validate input
+	deviceName := strings.TrimSpace(req.Msg.DeviceName)
+	if utf8.RuneCountInString(deviceName) > maxDeviceNameLength {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("device name must be at most %d characters", maxDeviceNameLength))
+	}
+	userAgent := strings.ToValidUTF8(req.Header().Get("User-Agent"), "")
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get user", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create session"))
+	}
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to generate refresh token", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create session"))
+	}
+	now := h.clock.Now()
+	session, err := h.repo.Create(ctx, userID, deviceName, userAgent, auth.GetRoles(ctx), auth.GetScopes(ctx), refreshHash, now.Add(h.jwtConfig.RefreshTokenTTL), now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create session", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create session"))
+	}
+	h.log.InfoContext(ctx, "Session created", "sessionID", session.ID)
+
+	tokens, err := h.sessionTokens(user.SubjectID, session, refreshToken, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to issue access token", "sessionID", session.ID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create session"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateSessionResponse{
+		Session: ToProtoSession(session, uuid.Nil),
+		Tokens:  tokens,
+	})
+
+	return res, nil
+}
+
+// RefreshSession exchanges a refresh token for new tokens of its session
+func (h *AuthHandler) RefreshSession(ctx context.Context, req *connect.Request[v1.RefreshSessionRequest]) (*connect.Response[v1.RefreshSessionResponse], error) {
+	// Validate input
+	if req.Msg.RefreshToken == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RefreshTokenRequired))
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to generate refresh token", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to refresh session"))
+	}
+	now := h.clock.Now()
+	session, err := h.repo.Rotate(ctx, auth.HashRefreshToken(req.Msg.RefreshToken), refreshHash, now.Add(h.jwtConfig.RefreshTokenTTL), now)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrRefreshTokenReused):
+			h.log.WarnContext(ctx, "Refresh token reused, session revoked")
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("refresh token already used, session revoked"))
+		case errors.Is(err, repo.ErrSessionNotFound):
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid or expired refresh token"))
+		}
+		h.log.ErrorContext(ctx, "Failed to rotate refresh token", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to refresh session"))
+	}
+
+	user, err := h.users.FindByID(ctx, session.UserID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get user", "userID", session.UserID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to refresh session"))
+	}
+	// Suspended users keep their sessions but can't refresh them, as they can't use them
+	if user.SuspendedAt.Valid {
+		return nil, apierror.New(connect.CodePermissionDenied, apierror.ReasonAccountSuspended, errors.New("account suspended"))
+	}
+	tokens, err := h.sessionTokens(user.SubjectID, session, refreshToken, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to issue access token", "sessionID", session.ID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to refresh session"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.RefreshSessionResponse{
+		Session: ToProtoSession(session, uuid.Nil),
+		Tokens:  tokens,
+	})
+
+	return res, nil
+}
+
+// ListSessions lists the authenticated user's active sessions
+func (h *AuthHandler) ListSessions(ctx context.Context, req *connect.Request[v1.ListSessionsRequest]) (*connect.Response[v1.ListSessionsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	sessions, err := h.repo.FindActiveByUser(ctx, userID, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list sessions", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list sessions"))
+	}
+
+	// Create response
+	currentID, _ := auth.GetSessionID(ctx)
+	protoSessions := make([]*v1.Session, len(sessions))
+	for i, session := range sessions {
+		protoSessions[i] = ToProtoSession(session, currentID)
+	}
+	res := connect.NewResponse(&v1.ListSessionsResponse{
+		Sessions: protoSessions,
+	})
+
+	return res, nil
+}
+
+// RevokeSession revokes one of the authenticated user's sessions
+func (h *AuthHandler) RevokeSession(ctx context.Context, req *connect.Request[v1.RevokeSessionRequest]) (*connect.Response[v1.RevokeSessionResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	sessionID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid session ID", "sessionID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Revoke(ctx, sessionID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrSessionNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("session not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to revoke session", "sessionID", sessionID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to revoke session"))
+	}
+	h.log.InfoContext(ctx, "Session revoked", "sessionID", sessionID)
+
+	return connect.NewResponse(&v1.RevokeSessionResponse{}), nil
+}
+
+// sessionTokens issues an access token of the session and pairs it with its new refresh token
+func (h *AuthHandler) sessionTokens(subjectID string, session db.Session, refreshToken string, now time.Time) (*v1.SessionTokens, error) {
+	accessToken, accessExpiresAt, err := auth.IssueAccessToken(h.jwtConfig, subjectID, session, now)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.SessionTokens{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  timestamppb.New(accessExpiresAt),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: timestamppb.New(session.ExpiresAt),
+	}, nil
+}
+
+// ToProtoSession converts a db.Session to a v1.Session, marking it current if it is currentID
+func ToProtoSession(session db.Session, currentID uuid.UUID) *v1.Session {
+	return &v1.Session{
+		Id:         session.ID.String(),
+		DeviceName: session.DeviceName,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  timestamppb.New(session.CreatedAt),
+		LastUsedAt: timestamppb.New(session.LastUsedAt),
+		ExpiresAt:  timestamppb.New(session.ExpiresAt),
+		Current:    session.ID == currentID,
+	}
+}
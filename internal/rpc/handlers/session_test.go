@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHandler(t *testing.T) {
+	resetDB(t, testPool)
+	userRepo := repo.NewUserRepository(testPool)
+	sessionRepo := repo.NewSessionRepository(testPool)
+	jwtConfig := &auth.JWTConfig{SecretKey: "test-secret", AccessTokenTTL: 15 * time.Minute, RefreshTokenTTL: 24 * time.Hour}
+	handler := NewAuthHandler(sessionRepo, userRepo, jwtConfig, testLogger, mockClock)
+	ctx := context.Background()
+	// Tokens are validated against the wall clock, so the mock clock starts at the current time
+	start := time.Now().UTC().Truncate(time.Second)
+	mockClock.SetTime(start)
+	defer mockClock.SetTime(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	// Serve the service behind the auth interceptor, as in production
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewAuthServiceHandler(handler, connect.WithInterceptors(auth.AuthInterceptor(jwtConfig, userRepo, sessionRepo, testLogger))))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := healthappv1connect.NewAuthServiceClient(server.Client(), server.URL)
+
+	user, err := userRepo.FindByID(ctx, testUserID)
+	require.NoError(t, err)
+	idpToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   user.SubjectID,
+		"roles": []string{auth.RoleSupport},
+		"scope": authz.ScopeRecordsRead + " " + authz.ScopeRecordsWrite,
+		"exp":   start.Add(time.Hour).Unix(),
+	}).SignedString([]byte(jwtConfig.SecretKey))
+	require.NoError(t, err)
+
+	// request authenticates req with token
+	request := func(req connect.AnyRequest, token string) {
+		req.Header().Set("Authorization", "Bearer "+token)
+	}
+	createSession := func(t *testing.T, deviceName string) *v1.CreateSessionResponse {
+		t.Helper()
+		req := connect.NewRequest(&v1.CreateSessionRequest{DeviceName: deviceName})
+		request(req, idpToken)
+		req.Header().Set("User-Agent", "HealthApp/1.0")
+		resp, err := client.CreateSession(ctx, req)
+		require.NoError(t, err)
+		return resp.Msg
+	}
+	listSessions := func(token string) (*connect.Response[v1.ListSessionsResponse], error) {
+		req := connect.NewRequest(&v1.ListSessionsRequest{})
+		request(req, token)
+		return client.ListSessions(ctx, req)
+	}
+
+	var phone *v1.CreateSessionResponse
+	t.Run("Create Session", func(t *testing.T) {
+		phone = createSession(t, " Pixel 8 ")
+		assert.Equal(t, "Pixel 8", phone.Session.DeviceName)
+		assert.Equal(t, "HealthApp/1.0", phone.Session.UserAgent)
+		assert.Equal(t, start.Add(jwtConfig.AccessTokenTTL), phone.Tokens.AccessTokenExpiresAt.AsTime())
+		assert.Equal(t, start.Add(jwtConfig.RefreshTokenTTL), phone.Tokens.RefreshTokenExpiresAt.AsTime())
+		assert.NotEmpty(t, phone.Tokens.RefreshToken)
+
+		// The access token authenticates requests and marks its session as current
+		resp, err := listSessions(phone.Tokens.AccessToken)
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Sessions, 1)
+		assert.Equal(t, phone.Session.Id, resp.Msg.Sessions[0].Id)
+		assert.True(t, resp.Msg.Sessions[0].Current)
+
+		// Refresh tokens are stored hashed
+		var stored []byte
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT token_hash FROM refresh_tokens WHERE session_id = $1", phone.Session.Id).Scan(&stored))
+		assert.Equal(t, auth.HashRefreshToken(phone.Tokens.RefreshToken), stored)
+	})
+
+	t.Run("Session Access Token Keeps Roles and Scopes", func(t *testing.T) {
+		token, err := jwt.Parse(phone.Tokens.AccessToken, func(*jwt.Token) (interface{}, error) {
+			return []byte(jwtConfig.SecretKey), nil
+		})
+		require.NoError(t, err)
+		claims := token.Claims.(jwt.MapClaims)
+		assert.Equal(t, phone.Session.Id, claims["sid"])
+		assert.Equal(t, []interface{}{auth.RoleSupport}, claims["roles"])
+		assert.Equal(t, authz.ScopeRecordsRead+" "+authz.ScopeRecordsWrite, claims["scope"])
+	})
+
+	t.Run("Error - Create Session With Access Token", func(t *testing.T) {
+		req := connect.NewRequest(&v1.CreateSessionRequest{})
+		request(req, phone.Tokens.AccessToken)
+		_, err := client.CreateSession(ctx, req)
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+
+	t.Run("Refresh and Reuse", func(t *testing.T) {
+		mockClock.SetTime(start.Add(time.Minute))
+		defer mockClock.SetTime(start)
+
+		// Refreshing needs no access token
+		resp, err := client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{RefreshToken: phone.Tokens.RefreshToken}))
+		require.NoError(t, err)
+		refreshed := resp.Msg
+		assert.Equal(t, phone.Session.Id, refreshed.Session.Id)
+		assert.NotEqual(t, phone.Tokens.RefreshToken, refreshed.Tokens.RefreshToken)
+		assert.Equal(t, start.Add(time.Minute+jwtConfig.RefreshTokenTTL), refreshed.Tokens.RefreshTokenExpiresAt.AsTime())
+		assert.Equal(t, start.Add(time.Minute), refreshed.Session.LastUsedAt.AsTime())
+		_, err = listSessions(refreshed.Tokens.AccessToken)
+		require.NoError(t, err)
+
+		// Replaying the used refresh token revokes the session and all of its tokens
+		_, err = client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{RefreshToken: phone.Tokens.RefreshToken}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+		_, err = client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{RefreshToken: refreshed.Tokens.RefreshToken}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+		_, err = listSessions(refreshed.Tokens.AccessToken)
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+
+	t.Run("Revoke Session", func(t *testing.T) {
+		tablet := createSession(t, "Tablet")
+		laptop := createSession(t, "Laptop")
+
+		req := connect.NewRequest(&v1.RevokeSessionRequest{Id: tablet.Session.Id})
+		request(req, laptop.Tokens.AccessToken)
+		_, err := client.RevokeSession(ctx, req)
+		require.NoError(t, err)
+
+		_, err = listSessions(tablet.Tokens.AccessToken)
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+		_, err = client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{RefreshToken: tablet.Tokens.RefreshToken}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+		resp, err := listSessions(idpToken)
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Sessions, 1)
+		assert.Equal(t, laptop.Session.Id, resp.Msg.Sessions[0].Id)
+		assert.False(t, resp.Msg.Sessions[0].Current)
+
+		req = connect.NewRequest(&v1.RevokeSessionRequest{Id: tablet.Session.Id})
+		request(req, idpToken)
+		_, err = client.RevokeSession(ctx, req)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Other User's Session", func(t *testing.T) {
+		otherUserID, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		_, refreshHash, err := auth.NewRefreshToken()
+		require.NoError(t, err)
+		other, err := sessionRepo.Create(ctx, otherUserID, "", "", nil, nil, refreshHash, start.Add(time.Hour), start)
+		require.NoError(t, err)
+
+		req := connect.NewRequest(&v1.RevokeSessionRequest{Id: other.ID.String()})
+		request(req, idpToken)
+		_, err = client.RevokeSession(ctx, req)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Expired Session", func(t *testing.T) {
+		session := createSession(t, "Old Phone")
+		mockClock.SetTime(start.Add(jwtConfig.RefreshTokenTTL + time.Minute))
+		defer mockClock.SetTime(start)
+
+		_, err := client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{RefreshToken: session.Tokens.RefreshToken}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+		resp, err := listSessions(idpToken)
+		require.NoError(t, err)
+		for _, s := range resp.Msg.Sessions {
+			assert.NotEqual(t, session.Session.Id, s.Id)
+		}
+	})
+
+	t.Run("Error - Invalid Input", func(t *testing.T) {
+		_, err := client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = client.RefreshSession(ctx, connect.NewRequest(&v1.RefreshSessionRequest{RefreshToken: "unknown"}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+		req := connect.NewRequest(&v1.CreateSessionRequest{DeviceName: strings.Repeat("x", maxDeviceNameLength+1)})
+		request(req, idpToken)
+		_, err = client.CreateSession(ctx, req)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+		revokeReq := connect.NewRequest(&v1.RevokeSessionRequest{Id: "invalid"})
+		request(revokeReq, idpToken)
+		_, err = client.RevokeSession(ctx, revokeReq)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+		revokeReq = connect.NewRequest(&v1.RevokeSessionRequest{Id: uuid.NewString()})
+		request(revokeReq, idpToken)
+		_, err = client.RevokeSession(ctx, revokeReq)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := client.ListSessions(ctx, connect.NewRequest(&v1.ListSessionsRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/authz"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxShareDuration bounds the time window of a data share
+const maxShareDuration = 366 * 24 * time.Hour
+
+// sharedRecordTypes are the record types that can be shared, in enum order, with the record
+// type stored in data_shares
+var sharedRecordTypes = []struct {
+	recordType v1.SharedRecordType
+	stored     string
+}{
+	{v1.SharedRecordType_SHARED_RECORD_TYPE_BODY_RECORDS, authz.RecordTypeBodyRecords},
+	{v1.SharedRecordType_SHARED_RECORD_TYPE_EXERCISE_RECORDS, authz.RecordTypeExerciseRecords},
+	{v1.SharedRecordType_SHARED_RECORD_TYPE_DIARY_ENTRIES, authz.RecordTypeDiaryEntries},
+	{v1.SharedRecordType_SHARED_RECORD_TYPE_MEAL_RECORDS, authz.RecordTypeMealRecords},
+	{v1.SharedRecordType_SHARED_RECORD_TYPE_MEAL_PLANS, authz.RecordTypeMealPlans},
+}
+
+// SharingHandler implements the sharing service RPCs
+type SharingHandler struct {
+	repo  DataShareRepository
+	users UserRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewSharingHandler creates a new sharing handler
+func NewSharingHandler(repo DataShareRepository, users UserRepository, log *slog.Logger, clock clock.Clock) *SharingHandler {
+	return &SharingHandler{
+		repo:  repo,
+		users: users,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// CreateShare grants another user read access to record types of the user for a time window
+func (h *SharingHandler) CreateShare(ctx context.Context, req *connect.Request[v1.CreateShareRequest]) (*connect.Response[v1.CreateShareResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	granteeEmail := strings.TrimSpace(req.Msg.GranteeEmail)
+	if granteeEmail == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.GranteeEmailRequired))
+	}
+	if len(req.Msg.RecordTypes) == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RecordTypesRequired))
+	}
+	var recordTypes []string
+	for _, recordType := range req.Msg.RecordTypes {
+		stored, ok := storedRecordType(recordType)
+		if !ok {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("invalid record type %v", recordType))
+		}
+		if !slices.Contains(recordTypes, stored) {
+			recordTypes = append(recordTypes, stored)
+		}
+	}
+	now := h.clock.Now()
+	startsAt := now
+	if req.Msg.StartsAt != nil {
+		startsAt = req.Msg.StartsAt.AsTime()
+	}
+	if req.Msg.ExpiresAt == nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ExpiresAtRequired))
+	}
+	expiresAt := req.Msg.ExpiresAt.AsTime()
+	if !expiresAt.After(startsAt) || !expiresAt.After(now) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ShareExpiryInvalid))
+	}
+	if expiresAt.Sub(startsAt) > maxShareDuration {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.ShareTooLong))
+	}
+
+	grantee, err := h.users.FindByEmail(ctx, granteeEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrUserNotFound):
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("no user has this email address"))
+		case errors.Is(err, repo.ErrAmbiguousEmail):
+			return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("several users have this email address"))
+		}
+		h.log.ErrorContext(ctx, "Failed to find grantee", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create share"))
+	}
+	if grantee.ID == userID {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.CannotShareWithSelf))
+	}
+	owner, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get user", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create share"))
+	}
+
+	h.log.InfoContext(ctx, "Creating data share", "granteeID", grantee.ID, "recordTypes", recordTypes, "expiresAt", expiresAt)
+	share, err := h.repo.Create(ctx, userID, grantee.ID, recordTypes, startsAt, expiresAt, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create data share", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create share"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateShareResponse{
+		Share: ToProtoDataShare(repo.DataShareWithEmails{
+			DataShare:    share,
+			OwnerEmail:   owner.Email.String,
+			GranteeEmail: grantee.Email.String,
+		}),
+	})
+
+	return res, nil
+}
+
+// ListShares lists the shares granted by the user that are not revoked or expired
+func (h *SharingHandler) ListShares(ctx context.Context, req *connect.Request[v1.ListSharesRequest]) (*connect.Response[v1.ListSharesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	shares, err := h.repo.FindByOwner(ctx, userID, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list data shares", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list shares"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListSharesResponse{
+		Shares: toProtoDataShares(shares),
+	})
+
+	return res, nil
+}
+
+// ListSharesWithMe lists the shares granted to the user that are in effect
+func (h *SharingHandler) ListSharesWithMe(ctx context.Context, req *connect.Request[v1.ListSharesWithMeRequest]) (*connect.Response[v1.ListSharesWithMeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	shares, err := h.repo.FindByGrantee(ctx, userID, h.clock.Now())
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list received data shares", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list shares"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListSharesWithMeResponse{
+		Shares: toProtoDataShares(shares),
+	})
+
+	return res, nil
+}
+
+// RevokeShare revokes a share granted by the user
+func (h *SharingHandler) RevokeShare(ctx context.Context, req *connect.Request[v1.RevokeShareRequest]) (*connect.Response[v1.RevokeShareResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse share ID
+	shareID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid share ID", "shareID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Revoking data share", "shareID", shareID)
+	if err := h.repo.Revoke(ctx, shareID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrDataShareNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("share not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to revoke data share", "shareID", shareID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to revoke share"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.RevokeShareResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// readableOwner returns the user whose records of recordType a list or get request reads: the
+// authenticated user, or the owner named by the request if they share the record type with them,
+// and the context to read them with, in the database of the owner's data region. The returned
+// errors are ready to return from the handler.
+func readableOwner(ctx context.Context, authorizer *authz.Authorizer, log *slog.Logger, ownerID, recordType string) (context.Context, uuid.UUID, error) {
+	return authorizedOwner(ctx, authorizer.ReadableOwner, log, ownerID, recordType)
+}
+
+// writableOwner returns the user whose records of recordType a write request writes, like
+// readableOwner; only the record types grantees may write are writable for other owners.
+func writableOwner(ctx context.Context, authorizer *authz.Authorizer, log *slog.Logger, ownerID, recordType string) (context.Context, uuid.UUID, error) {
+	return authorizedOwner(ctx, authorizer.WritableOwner, log, ownerID, recordType)
+}
+
+// authorizedOwner authorizes the authenticated user's access to the records of an owner with
+// authorize, converting its errors for the handler
+func authorizedOwner(ctx context.Context, authorize func(ctx context.Context, callerID uuid.UUID, ownerID, recordType string) (context.Context, uuid.UUID, error), log *slog.Logger, ownerID, recordType string) (context.Context, uuid.UUID, error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "User ID not found in context")
+		return nil, uuid.Nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	ownerCtx, owner, err := authorize(ctx, userID, ownerID, recordType)
+	if err != nil {
+		switch {
+		case errors.Is(err, authz.ErrInvalidOwner):
+			log.WarnContext(ctx, "Invalid owner ID", "ownerID", ownerID, "error", err)
+			return nil, uuid.Nil, connect.NewError(connect.CodeInvalidArgument, err)
+		case errors.Is(err, authz.ErrNotShared):
+			log.WarnContext(ctx, "Records not shared with user", "ownerID", ownerID, "recordType", recordType)
+			return nil, uuid.Nil, apierror.New(connect.CodePermissionDenied, apierror.ReasonNotShared, fmt.Errorf("%s are not shared with you", strings.ReplaceAll(recordType, "_", " ")))
+		}
+		log.ErrorContext(ctx, "Failed to authorize request", "ownerID", ownerID, "error", err)
+		return nil, uuid.Nil, connect.NewError(connect.CodeInternal, errors.New("failed to authorize request"))
+	}
+	return ownerCtx, owner, nil
+}
+
+// storedRecordType returns the record type stored in data_shares for an enum value
+func storedRecordType(recordType v1.SharedRecordType) (string, bool) {
+	for _, t := range sharedRecordTypes {
+		if t.recordType == recordType {
+			return t.stored, true
+		}
+	}
+	return "", false
+}
+
+// ToProtoDataShare converts a repo.DataShareWithEmails to a v1.DataShare
+func ToProtoDataShare(share repo.DataShareWithEmails) *v1.DataShare {
+	protoShare := &v1.DataShare{
+		Id:           share.ID.String(),
+		OwnerId:      share.OwnerID.String(),
+		OwnerEmail:   share.OwnerEmail,
+		GranteeId:    share.GranteeID.String(),
+		GranteeEmail: share.GranteeEmail,
+		StartsAt:     timestamppb.New(share.StartsAt),
+		ExpiresAt:    timestamppb.New(share.ExpiresAt),
+		CreatedAt:    timestamppb.New(share.CreatedAt),
+	}
+	for _, t := range sharedRecordTypes {
+		if slices.Contains(share.RecordTypes, t.stored) {
+			protoShare.RecordTypes = append(protoShare.RecordTypes, t.recordType)
+		}
+	}
+	return protoShare
+}
+
+func toProtoDataShares(shares []repo.DataShareWithEmails) []*v1.DataShare {
+	protoShares := make([]*v1.DataShare, len(shares))
+	for i, share := range shares {
+		protoShares[i] = ToProtoDataShare(share)
+	}
+	return protoShares
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestSharingHandler(t *testing.T) {
+	resetDB(t, testPool)
+	userRepo := repo.NewUserRepository(testPool)
+	handler := NewSharingHandler(repo.NewDataShareRepository(testPool), userRepo, testLogger, mockClock)
+	diaryHandler := NewDiaryHandler(repo.NewDiaryEntryRepository(testPool, testDiaryCipher), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	bodyHandler := NewBodyRecordHandler(repo.NewBodyRecordRepository(testPool), repo.NewAttachmentRepository(testPool), repo.NewPreferenceRepository(testPool), newTestStore(t), testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	ctx := context.Background()
+	ownerCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	// newUser creates a user with a unique email address, returning their context and address
+	newUser := func(t *testing.T, name string) (context.Context, string) {
+		t.Helper()
+		id, err := testutil.CreateTestUser(ctx, testQueries)
+		require.NoError(t, err)
+		email := name + "-" + uuid.NewString() + "@example.com"
+		require.NoError(t, userRepo.SetEmail(ctx, id, email))
+		return context.WithValue(ctx, auth.UserContextKey, id), email
+	}
+	coachCtx, coachEmail := newUser(t, "coach")
+	strangerCtx, _ := newUser(t, "stranger")
+	ownerEmail := "owner-" + uuid.NewString() + "@example.com"
+	require.NoError(t, userRepo.SetEmail(ctx, testUserID, ownerEmail))
+	ownerID := testUserID.String()
+
+	entry, err := diaryHandler.CreateDiaryEntry(ownerCtx, connect.NewRequest(&v1.CreateDiaryEntryRequest{
+		Title:     wrapperspb.String("Week 1"),
+		Content:   "Felt great",
+		EntryDate: "2024-01-14",
+	}))
+	require.NoError(t, err)
+	_, err = bodyHandler.CreateBodyRecord(ownerCtx, connect.NewRequest(&v1.CreateBodyRecordRequest{
+		Date:     "2024-01-14",
+		WeightKg: wrapperspb.Double(70),
+	}))
+	require.NoError(t, err)
+
+	// assertNotShared checks that err denies access to unshared records
+	assertNotShared := func(t *testing.T, err error) {
+		t.Helper()
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonNotShared, apierror.Reason(err))
+	}
+
+	t.Run("Not Shared", func(t *testing.T) {
+		_, err := diaryHandler.ListDiaryEntries(coachCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{OwnerId: ownerID}))
+		assertNotShared(t, err)
+	})
+
+	var shareID string
+	t.Run("Create Share", func(t *testing.T) {
+		resp, err := handler.CreateShare(ownerCtx, connect.NewRequest(&v1.CreateShareRequest{
+			GranteeEmail: " " + coachEmail + " ",
+			RecordTypes: []v1.SharedRecordType{
+				v1.SharedRecordType_SHARED_RECORD_TYPE_DIARY_ENTRIES,
+				v1.SharedRecordType_SHARED_RECORD_TYPE_DIARY_ENTRIES,
+			},
+			ExpiresAt: timestamppb.New(fixedTime.AddDate(0, 0, 7)),
+		}))
+		require.NoError(t, err)
+		share := resp.Msg.Share
+		assert.Equal(t, ownerID, share.OwnerId)
+		assert.Equal(t, ownerEmail, share.OwnerEmail)
+		assert.Equal(t, coachEmail, share.GranteeEmail)
+		assert.Equal(t, []v1.SharedRecordType{v1.SharedRecordType_SHARED_RECORD_TYPE_DIARY_ENTRIES}, share.RecordTypes)
+		assert.Equal(t, fixedTime, share.StartsAt.AsTime())
+		shareID = share.Id
+	})
+
+	t.Run("Read Shared Records", func(t *testing.T) {
+		listResp, err := diaryHandler.ListDiaryEntries(coachCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{OwnerId: ownerID}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.DiaryEntries, 1)
+		assert.Equal(t, entry.Msg.DiaryEntry.Id, listResp.Msg.DiaryEntries[0].Id)
+
+		getResp, err := diaryHandler.GetDiaryEntry(coachCtx, connect.NewRequest(&v1.GetDiaryEntryRequest{Id: entry.Msg.DiaryEntry.Id, OwnerId: ownerID}))
+		require.NoError(t, err)
+		assert.Equal(t, "Felt great", getResp.Msg.DiaryEntry.Content)
+
+		// Without owner_id, the coach reads their own entries
+		ownResp, err := diaryHandler.ListDiaryEntries(coachCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{}))
+		require.NoError(t, err)
+		assert.Empty(t, ownResp.Msg.DiaryEntries)
+
+		// Only the shared record types are readable, and only by the grantee
+		_, err = bodyHandler.ListBodyRecords(coachCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{OwnerId: ownerID}))
+		assertNotShared(t, err)
+		_, err = diaryHandler.ListDiaryEntries(strangerCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{OwnerId: ownerID}))
+		assertNotShared(t, err)
+	})
+
+	t.Run("Share Starting Later", func(t *testing.T) {
+		_, err := handler.CreateShare(ownerCtx, connect.NewRequest(&v1.CreateShareRequest{
+			GranteeEmail: coachEmail,
+			RecordTypes:  []v1.SharedRecordType{v1.SharedRecordType_SHARED_RECORD_TYPE_BODY_RECORDS},
+			StartsAt:     timestamppb.New(fixedTime.AddDate(0, 0, 1)),
+			ExpiresAt:    timestamppb.New(fixedTime.AddDate(0, 0, 2)),
+		}))
+		require.NoError(t, err)
+
+		_, err = bodyHandler.GetBodyRecordsByDateRange(coachCtx, connect.NewRequest(&v1.GetBodyRecordsByDateRangeRequest{
+			StartDate: "2024-01-01",
+			EndDate:   "2024-01-31",
+			OwnerId:   ownerID,
+		}))
+		assertNotShared(t, err)
+
+		// The owner lists upcoming shares; the grantee only those in effect
+		ownerResp, err := handler.ListShares(ownerCtx, connect.NewRequest(&v1.ListSharesRequest{}))
+		require.NoError(t, err)
+		assert.Len(t, ownerResp.Msg.Shares, 2)
+		coachResp, err := handler.ListSharesWithMe(coachCtx, connect.NewRequest(&v1.ListSharesWithMeRequest{}))
+		require.NoError(t, err)
+		require.Len(t, coachResp.Msg.Shares, 1)
+		assert.Equal(t, shareID, coachResp.Msg.Shares[0].Id)
+
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 1))
+		defer mockClock.SetTime(fixedTime)
+		resp, err := bodyHandler.GetBodyRecordsByDateRange(coachCtx, connect.NewRequest(&v1.GetBodyRecordsByDateRangeRequest{
+			StartDate: "2024-01-01",
+			EndDate:   "2024-01-31",
+			OwnerId:   ownerID,
+		}))
+		require.NoError(t, err)
+		assert.Len(t, resp.Msg.BodyRecords, 1)
+	})
+
+	t.Run("Expired Share", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 7))
+		defer mockClock.SetTime(fixedTime)
+		_, err := diaryHandler.ListDiaryEntries(coachCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{OwnerId: ownerID}))
+		assertNotShared(t, err)
+	})
+
+	t.Run("Revoke Share", func(t *testing.T) {
+		_, err := handler.RevokeShare(coachCtx, connect.NewRequest(&v1.RevokeShareRequest{Id: shareID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "only the owner can revoke")
+
+		_, err = handler.RevokeShare(ownerCtx, connect.NewRequest(&v1.RevokeShareRequest{Id: shareID}))
+		require.NoError(t, err)
+		_, err = diaryHandler.ListDiaryEntries(coachCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{OwnerId: ownerID}))
+		assertNotShared(t, err)
+
+		_, err = handler.RevokeShare(ownerCtx, connect.NewRequest(&v1.RevokeShareRequest{Id: shareID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Invalid Owner ID", func(t *testing.T) {
+		_, err := diaryHandler.ListDiaryEntries(coachCtx, connect.NewRequest(&v1.ListDiaryEntriesRequest{OwnerId: "invalid"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Invalid Input", func(t *testing.T) {
+		diaryEntries := []v1.SharedRecordType{v1.SharedRecordType_SHARED_RECORD_TYPE_DIARY_ENTRIES}
+		expiresAt := timestamppb.New(fixedTime.AddDate(0, 0, 7))
+		tests := []struct {
+			name string
+			req  *v1.CreateShareRequest
+			code connect.Code
+		}{
+			{"Missing Email", &v1.CreateShareRequest{RecordTypes: diaryEntries, ExpiresAt: expiresAt}, connect.CodeInvalidArgument},
+			{"Missing Record Types", &v1.CreateShareRequest{GranteeEmail: coachEmail, ExpiresAt: expiresAt}, connect.CodeInvalidArgument},
+			{"Unspecified Record Type", &v1.CreateShareRequest{GranteeEmail: coachEmail, RecordTypes: []v1.SharedRecordType{v1.SharedRecordType_SHARED_RECORD_TYPE_UNSPECIFIED}, ExpiresAt: expiresAt}, connect.CodeInvalidArgument},
+			{"Missing Expiry", &v1.CreateShareRequest{GranteeEmail: coachEmail, RecordTypes: diaryEntries}, connect.CodeInvalidArgument},
+			{"Expiry Before Start", &v1.CreateShareRequest{GranteeEmail: coachEmail, RecordTypes: diaryEntries, StartsAt: expiresAt, ExpiresAt: timestamppb.New(fixedTime)}, connect.CodeInvalidArgument},
+			{"Window Too Long", &v1.CreateShareRequest{GranteeEmail: coachEmail, RecordTypes: diaryEntries, ExpiresAt: timestamppb.New(fixedTime.AddDate(1, 0, 2))}, connect.CodeInvalidArgument},
+			{"Unknown Email", &v1.CreateShareRequest{GranteeEmail: "nobody-" + uuid.NewString() + "@example.com", RecordTypes: diaryEntries, ExpiresAt: expiresAt}, connect.CodeNotFound},
+			{"Share With Self", &v1.CreateShareRequest{GranteeEmail: ownerEmail, RecordTypes: diaryEntries, ExpiresAt: expiresAt}, connect.CodeInvalidArgument},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := handler.CreateShare(ownerCtx, connect.NewRequest(tt.req))
+				assert.Equal(t, tt.code, connect.CodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.ListShares(ctx, connect.NewRequest(&v1.ListSharesRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+		_, err = diaryHandler.ListDiaryEntries(ctx, connect.NewRequest(&v1.ListDiaryEntriesRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
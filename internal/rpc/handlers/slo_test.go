@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/log"
+	"github.com/atreya2011/health-management-api/internal/metrics"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowServerHandler answers GetServerInfo after advancing the mock clock by delay
+type slowServerHandler struct {
+	healthappv1connect.UnimplementedServerServiceHandler
+	delay time.Duration
+}
+
+func (h *slowServerHandler) GetServerInfo(ctx context.Context, req *connect.Request[v1.GetServerInfoRequest]) (*connect.Response[v1.GetServerInfoResponse], error) {
+	mockClock.SetTime(mockClock.Now().Add(h.delay))
+	return connect.NewResponse(&v1.GetServerInfoResponse{}), nil
+}
+
+func TestLatencySLO(t *testing.T) {
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	slo := metrics.NewSLO(300*time.Millisecond, 0.99, 5*time.Minute, mockClock)
+	metrics.Register(slo)
+	handler := &slowServerHandler{}
+	mux := http.NewServeMux()
+	mux.Handle(healthappv1connect.NewServerServiceHandler(handler, connect.WithInterceptors(slo.Interceptor())))
+	server := httptest.NewServer(log.Middleware(mux))
+	defer server.Close()
+	client := healthappv1connect.NewServerServiceClient(server.Client(), server.URL)
+
+	call := func(t *testing.T, delay time.Duration, traceparent string) {
+		t.Helper()
+		handler.delay = delay
+		req := connect.NewRequest(&v1.GetServerInfoRequest{})
+		if traceparent != "" {
+			req.Header().Set("Traceparent", traceparent)
+		}
+		_, err := client.GetServerInfo(ctx, req)
+		require.NoError(t, err)
+	}
+	scrape := func(t *testing.T, accept string) (string, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", accept)
+		metrics.PrometheusHandler().ServeHTTP(rec, req)
+		return rec.Header().Get("Content-Type"), rec.Body.String()
+	}
+	procedure := `procedure="` + healthappv1connect.ServerServiceGetServerInfoProcedure + `"`
+
+	call(t, 100*time.Millisecond, "")
+	call(t, 200*time.Millisecond, "")
+	call(t, 500*time.Millisecond, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	call(t, 300*time.Millisecond, "")
+
+	t.Run("Compliance Is Served", func(t *testing.T) {
+		contentType, body := scrape(t, "text/plain")
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", contentType)
+		assert.Contains(t, body, "# TYPE healthapp_rpc_slo_requests_total counter")
+		assert.Contains(t, body, "healthapp_rpc_slo_requests_total{"+procedure+"} 4\n")
+		assert.Contains(t, body, "healthapp_rpc_slo_fast_requests_total{"+procedure+"} 3\n")
+		assert.Contains(t, body, "healthapp_rpc_slo_compliance_ratio{"+procedure+"} 0.75\n")
+		assert.Contains(t, body, "healthapp_rpc_slo_compliance_ratio 0.75\n")
+		assert.Contains(t, body, "healthapp_rpc_slo_objective_ratio 0.99\n")
+		assert.Contains(t, body, "healthapp_rpc_slo_latency_threshold_seconds 0.3\n")
+		// Exemplars are only served in OpenMetrics
+		assert.Contains(t, body, "healthapp_rpc_duration_seconds_bucket{"+procedure+",le=\"0.5\"} 4\n")
+		assert.NotContains(t, body, "trace_id")
+	})
+
+	t.Run("OpenMetrics With Exemplars", func(t *testing.T) {
+		contentType, body := scrape(t, "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+		assert.Equal(t, "application/openmetrics-text; version=1.0.0; charset=utf-8", contentType)
+		assert.Contains(t, body, "# TYPE healthapp_rpc_slo_requests counter")
+		assert.Contains(t, body, "healthapp_rpc_slo_requests_total{"+procedure+"} 4\n")
+		assert.Contains(t, body, "healthapp_rpc_duration_seconds_bucket{"+procedure+",le=\"0.5\"} 4 # {trace_id=\"4bf92f3577b34da6a3ce929d0e0e4736\"} 0.5\n")
+		// Untraced requests are exemplified by their request ID
+		assert.Regexp(t, `healthapp_rpc_duration_seconds_bucket\{`+procedure+`,le="0.1"\} 1 # \{request_id="[0-9a-f-]{36}"\} 0.1\n`, body)
+		assert.Contains(t, body, "healthapp_rpc_duration_seconds_bucket{"+procedure+",le=\"0.05\"} 0\n")
+		assert.True(t, strings.HasSuffix(body, "# EOF\n"))
+	})
+
+	t.Run("Window Moves On", func(t *testing.T) {
+		mockClock.SetTime(mockClock.Now().Add(3 * time.Minute))
+		call(t, 100*time.Millisecond, "")
+		_, body := scrape(t, "text/plain")
+		assert.Contains(t, body, "healthapp_rpc_slo_compliance_ratio{"+procedure+"} 0.8\n")
+
+		// RPCs older than the window don't count, but the counters keep them
+		mockClock.SetTime(mockClock.Now().Add(3 * time.Minute))
+		call(t, 100*time.Millisecond, "")
+		_, body = scrape(t, "text/plain")
+		assert.Contains(t, body, "healthapp_rpc_slo_compliance_ratio{"+procedure+"} 1\n")
+		assert.Contains(t, body, "healthapp_rpc_slo_requests_total{"+procedure+"} 6\n")
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementTimeouts(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	pool, err := repo.NewDBPool(&config.DatabaseConfig{
+		URL:               testPool.Config().ConnString(),
+		MaxConns:          2,
+		MaxConnLifetime:   time.Hour,
+		MaxConnIdleTime:   time.Minute,
+		HealthCheckPeriod: time.Minute,
+		StatementTimeout:  200 * time.Millisecond,
+		LockTimeout:       50 * time.Millisecond,
+	}, slog.New(slog.NewJSONHandler(&logs, nil)))
+	require.NoError(t, err)
+	defer pool.Close()
+
+	t.Run("Connections Start With the Timeouts", func(t *testing.T) {
+		var statementTimeout, lockTimeout string
+		require.NoError(t, pool.QueryRow(ctx, "SHOW statement_timeout").Scan(&statementTimeout))
+		require.NoError(t, pool.QueryRow(ctx, "SHOW lock_timeout").Scan(&lockTimeout))
+		assert.Equal(t, "200ms", statementTimeout)
+		assert.Equal(t, "50ms", lockTimeout)
+	})
+
+	t.Run("Runaway Statements Are Cancelled", func(t *testing.T) {
+		logs.Reset()
+		tracked := repo.WithTimeoutTracking(ctx)
+		_, err := pool.Exec(tracked, "-- name: RunawayQuery :exec\nSELECT pg_sleep(5)")
+		require.Error(t, err)
+		assert.True(t, repo.StatementTimedOut(tracked))
+		assert.Contains(t, logs.String(), `"msg":"Query timed out"`)
+		assert.Contains(t, logs.String(), `"queryName":"RunawayQuery"`)
+	})
+
+	t.Run("Lock Waits Are Cancelled", func(t *testing.T) {
+		holder, err := testPool.Begin(ctx)
+		require.NoError(t, err)
+		defer holder.Rollback(ctx) //nolint:errcheck
+		_, err = holder.Exec(ctx, "LOCK TABLE users IN ACCESS EXCLUSIVE MODE")
+		require.NoError(t, err)
+
+		tracked := repo.WithTimeoutTracking(ctx)
+		_, err = pool.Exec(tracked, "SELECT count(*) FROM users")
+		require.Error(t, err)
+		assert.True(t, repo.StatementTimedOut(tracked))
+	})
+
+	t.Run("Cancelled Contexts Are Not Timeouts", func(t *testing.T) {
+		tracked, cancel := context.WithTimeout(repo.WithTimeoutTracking(ctx), 20*time.Millisecond)
+		defer cancel()
+		_, err := pool.Exec(tracked, "SELECT pg_sleep(1)")
+		require.Error(t, err)
+		assert.False(t, repo.StatementTimedOut(tracked))
+		assert.False(t, repo.StatementTimedOut(repo.WithTimeoutTracking(ctx)))
+	})
+}
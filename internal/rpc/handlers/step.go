@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/reminder"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+)
+
+const (
+	// maxStepBuckets bounds the buckets of a batch, about six weeks of one device's hours
+	maxStepBuckets = 1000
+	// maxStepsPerHour bounds the steps of a bucket
+	maxStepsPerHour = 50000
+	// maxStepOffsetMinutes bounds the UTC offsets of buckets, those of the time zones furthest from UTC
+	maxStepOffsetMinutes = 14 * 60
+	// maxStepSourceLength bounds the source of a bucket, in bytes
+	maxStepSourceLength = 100
+	// dailyStepsMaxDays bounds the days of a daily steps request
+	dailyStepsMaxDays = 366
+)
+
+// StepHandler implements the step service RPCs
+type StepHandler struct {
+	repo  StepRecordRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewStepHandler creates a new step handler
+func NewStepHandler(repo StepRecordRepository, log *slog.Logger, clock clock.Clock) *StepHandler {
+	return &StepHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// stepBucketKey identifies the bucket of an hour and source
+type stepBucketKey struct {
+	hour   time.Time
+	source string
+}
+
+// BatchUpsertSteps writes hourly step buckets of the user's devices
+func (h *StepHandler) BatchUpsertSteps(ctx context.Context, req *connect.Request[v1.BatchUpsertStepsRequest]) (*connect.Response[v1.BatchUpsertStepsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	if len(req.Msg.Buckets) == 0 || len(req.Msg.Buckets) > maxStepBuckets {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StepBucketCount, maxStepBuckets))
+	}
+	now := h.clock.Now()
+	// Devices resend the hours they are still counting, so later buckets of an hour and source win
+	buckets := make([]repo.StepBucket, 0, len(req.Msg.Buckets))
+	index := make(map[stepBucketKey]int, len(req.Msg.Buckets))
+	for _, b := range req.Msg.Buckets {
+		if b.Hour == nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StepHourNotAligned))
+		}
+		if b.UtcOffsetMinutes%15 != 0 || b.UtcOffsetMinutes < -maxStepOffsetMinutes || b.UtcOffsetMinutes > maxStepOffsetMinutes {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StepOffsetInvalid, maxStepOffsetMinutes, maxStepOffsetMinutes))
+		}
+		// Hours start on the hour of the device's time zone, e.g. at half past the UTC hour in India
+		hour := b.Hour.AsTime()
+		local := hour.Add(time.Duration(b.UtcOffsetMinutes) * time.Minute)
+		if !local.Truncate(time.Hour).Equal(local) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StepHourNotAligned))
+		}
+		if hour.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("step bucket hours cannot be in the future"))
+		}
+		if b.Source == "" || len(b.Source) > maxStepSourceLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StepSourceInvalid, maxStepSourceLength))
+		}
+		if b.Steps < 0 || b.Steps > maxStepsPerHour {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StepsOutOfRange, maxStepsPerHour))
+		}
+
+		bucket := repo.StepBucket{Hour: hour, Source: b.Source, Steps: b.Steps}
+		key := stepBucketKey{hour: hour, source: b.Source}
+		if i, ok := index[key]; ok {
+			buckets[i] = bucket
+			continue
+		}
+		index[key] = len(buckets)
+		buckets = append(buckets, bucket)
+	}
+
+	upserted, err := h.repo.UpsertBuckets(ctx, userID, buckets, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to upsert step buckets", "buckets", len(buckets), "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to upsert steps"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.BatchUpsertStepsResponse{
+		UpsertedCount: int32(upserted),
+	})
+
+	return res, nil
+}
+
+// GetDailySteps returns the daily step totals of the user over a date range
+func (h *StepHandler) GetDailySteps(ctx context.Context, req *connect.Request[v1.GetDailyStepsRequest]) (*connect.Response[v1.GetDailyStepsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	timezone := req.Msg.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := reminder.LoadLocation(timezone)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.TimezoneInvalid, timezone))
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", req.Msg.StartDate, loc)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DateInvalid, "start_date"))
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", req.Msg.EndDate, loc)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DateInvalid, "end_date"))
+	}
+	if endDate.Before(startDate) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.EndDateBeforeStartDate))
+	}
+	if endDate.After(startDate.AddDate(0, 0, dailyStepsMaxDays)) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DateRangeTooLong, dailyStepsMaxDays))
+	}
+
+	days, err := h.repo.DailySteps(ctx, userID, loc.String(), startDate, endDate.AddDate(0, 0, 1))
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get daily steps", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get daily steps"))
+	}
+
+	// Create response
+	resp := &v1.GetDailyStepsResponse{
+		Days: make([]*v1.DailySteps, 0, len(days)),
+	}
+	for _, d := range days {
+		resp.Days = append(resp.Days, &v1.DailySteps{
+			Date:        d.Day.Time.Format("2006-01-02"),
+			Steps:       d.Steps,
+			ActiveHours: d.ActiveHours,
+		})
+	}
+
+	return connect.NewResponse(resp), nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestStepHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 16, 12, 30, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	stepRepo := repo.NewStepRecordRepository(testPool)
+	handler := NewStepHandler(stepRepo, testLogger, mockClock)
+
+	hour := func(day, h int) *timestamppb.Timestamp {
+		return timestamppb.New(time.Date(2024, 1, day, h, 0, 0, 0, time.UTC))
+	}
+
+	t.Run("Batch Upsert Across Devices", func(t *testing.T) {
+		resp, err := handler.BatchUpsertSteps(testCtx, connect.NewRequest(&v1.BatchUpsertStepsRequest{
+			Buckets: []*v1.StepBucket{
+				{Hour: hour(15, 8), Source: "phone", Steps: 1200},
+				{Hour: hour(15, 8), Source: "watch", Steps: 1500},
+				{Hour: hour(15, 9), Source: "phone", Steps: 300},
+				{Hour: hour(15, 23), Source: "watch", Steps: 400},
+				{Hour: hour(16, 7), Source: "watch", Steps: 100},
+				// A later bucket of the same hour and source replaces the earlier one
+				{Hour: hour(16, 7), Source: "watch", Steps: 250},
+			},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), resp.Msg.UpsertedCount)
+
+		// Resending an hour replaces its count
+		_, err = handler.BatchUpsertSteps(testCtx, connect.NewRequest(&v1.BatchUpsertStepsRequest{
+			Buckets: []*v1.StepBucket{{Hour: hour(15, 9), Source: "phone", Steps: 800}},
+		}))
+		require.NoError(t, err)
+	})
+
+	t.Run("Daily Totals Count Each Hour Once", func(t *testing.T) {
+		resp, err := handler.GetDailySteps(testCtx, connect.NewRequest(&v1.GetDailyStepsRequest{
+			StartDate: "2024-01-15",
+			EndDate:   "2024-01-16",
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Days, 2)
+		// 1500 (the watch's count of 8:00) + 800 + 400
+		assert.Equal(t, &v1.DailySteps{Date: "2024-01-15", Steps: 2700, ActiveHours: 3}, resp.Msg.Days[0])
+		assert.Equal(t, &v1.DailySteps{Date: "2024-01-16", Steps: 250, ActiveHours: 1}, resp.Msg.Days[1])
+	})
+
+	t.Run("Daily Totals In A Time Zone", func(t *testing.T) {
+		// 23:00 UTC on the 15th is 08:00 on the 16th in Tokyo
+		resp, err := handler.GetDailySteps(testCtx, connect.NewRequest(&v1.GetDailyStepsRequest{
+			StartDate: "2024-01-15",
+			EndDate:   "2024-01-16",
+			Timezone:  "Asia/Tokyo",
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Days, 2)
+		assert.Equal(t, &v1.DailySteps{Date: "2024-01-15", Steps: 2300, ActiveHours: 2}, resp.Msg.Days[0])
+		assert.Equal(t, &v1.DailySteps{Date: "2024-01-16", Steps: 650, ActiveHours: 2}, resp.Msg.Days[1])
+	})
+
+	t.Run("Daily Step Records Are Merged", func(t *testing.T) {
+		records, err := stepRepo.FindByUserAndDateRange(ctx, testUserID, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, int32(2700), records[0].Steps)
+		assert.Equal(t, int32(250), records[1].Steps)
+	})
+
+	t.Run("Resent Hours Recompute the Day", func(t *testing.T) {
+		// A device correcting an hour downwards lowers the day's record too
+		_, err := handler.BatchUpsertSteps(testCtx, connect.NewRequest(&v1.BatchUpsertStepsRequest{
+			Buckets: []*v1.StepBucket{{Hour: hour(16, 7), Source: "watch", Steps: 100}},
+		}))
+		require.NoError(t, err)
+		records, err := stepRepo.FindByUserAndDateRange(ctx, testUserID, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, int32(100), records[0].Steps)
+	})
+
+	t.Run("Hours of Half-Hour Time Zones", func(t *testing.T) {
+		// 10:00 in India is 04:30 UTC; the day there started at 18:30 UTC on the 15th
+		_, err := handler.BatchUpsertSteps(testCtx, connect.NewRequest(&v1.BatchUpsertStepsRequest{
+			Buckets: []*v1.StepBucket{{Hour: timestamppb.New(time.Date(2024, 1, 16, 4, 30, 0, 0, time.UTC)), Source: "phone", Steps: 50, UtcOffsetMinutes: 330}},
+		}))
+		require.NoError(t, err)
+		resp, err := handler.GetDailySteps(testCtx, connect.NewRequest(&v1.GetDailyStepsRequest{
+			StartDate: "2024-01-16",
+			EndDate:   "2024-01-16",
+			Timezone:  "Asia/Kolkata",
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Days, 1)
+		assert.Equal(t, &v1.DailySteps{Date: "2024-01-16", Steps: 550, ActiveHours: 3}, resp.Msg.Days[0])
+	})
+
+	t.Run("Invalid Buckets", func(t *testing.T) {
+		for name, buckets := range map[string][]*v1.StepBucket{
+			"Empty":                 nil,
+			"Not Aligned":           {{Hour: timestamppb.New(time.Date(2024, 1, 15, 8, 30, 0, 0, time.UTC)), Source: "phone", Steps: 10}},
+			"Not Aligned At Offset": {{Hour: hour(15, 8), Source: "phone", Steps: 10, UtcOffsetMinutes: 330}},
+			"Offset Not Quarter":    {{Hour: hour(15, 8), Source: "phone", Steps: 10, UtcOffsetMinutes: 7}},
+			"Offset Too Large":      {{Hour: hour(15, 8), Source: "phone", Steps: 10, UtcOffsetMinutes: 900}},
+			"Future":                {{Hour: hour(16, 13), Source: "phone", Steps: 10}},
+			"No Source":             {{Hour: hour(15, 8), Steps: 10}},
+			"Negative":              {{Hour: hour(15, 8), Source: "phone", Steps: -1}},
+			"Too Many":              {{Hour: hour(15, 8), Source: "phone", Steps: maxStepsPerHour + 1}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.BatchUpsertSteps(testCtx, connect.NewRequest(&v1.BatchUpsertStepsRequest{Buckets: buckets}))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+
+		_, err := handler.GetDailySteps(testCtx, connect.NewRequest(&v1.GetDailyStepsRequest{
+			StartDate: "2024-01-15",
+			EndDate:   "2024-01-16",
+			Timezone:  "Mars/Olympus_Mons",
+		}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+}
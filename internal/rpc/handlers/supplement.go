@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxSupplementsPerUser bounds the supplements a user can create, and so the reminders
+	// created for them
+	maxSupplementsPerUser = 50
+	// maxSupplementNameLength keeps the title of the reminder, "Take <name>", within
+	// maxReminderTitleLength
+	maxSupplementNameLength = 80
+	// maxSupplementDoseLength bounds the dose of a supplement or intake, in characters
+	maxSupplementDoseLength = 100
+	// maxSupplementIntakeDays and maxSupplementIntakesListed bound a list of intakes
+	maxSupplementIntakeDays    = 92
+	maxSupplementIntakesListed = 1000
+)
+
+// SupplementHandler implements the supplement service RPCs
+type SupplementHandler struct {
+	repo  SupplementRepository
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewSupplementHandler creates a new supplement handler
+func NewSupplementHandler(repo SupplementRepository, log *slog.Logger, clock clock.Clock) *SupplementHandler {
+	return &SupplementHandler{
+		repo:  repo,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// CreateSupplement creates a supplement for the user, with a reminder if it has a schedule
+func (h *SupplementHandler) CreateSupplement(ctx context.Context, req *connect.Request[v1.CreateSupplementRequest]) (*connect.Response[v1.CreateSupplementResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	fields, err := h.supplementFields(req.Msg.Name, req.Msg.Dose, req.Msg.GetDailyAt(), req.Msg.GetCron(), req.Msg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count supplements", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create supplement"))
+	}
+	if count >= maxSupplementsPerUser {
+		return nil, apierror.New(connect.CodeResourceExhausted, apierror.ReasonLimitExceeded, fmt.Errorf("too many supplements (maximum %d)", maxSupplementsPerUser))
+	}
+
+	created, err := h.repo.Create(ctx, userID, fields, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrSupplementExists) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, errors.New("a supplement with this name already exists"))
+		}
+		h.log.ErrorContext(ctx, "Failed to create supplement", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create supplement"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateSupplementResponse{
+		Supplement: ToProtoSupplement(created),
+	})
+
+	return res, nil
+}
+
+// ListSupplements lists the supplements of the user by name
+func (h *SupplementHandler) ListSupplements(ctx context.Context, req *connect.Request[v1.ListSupplementsRequest]) (*connect.Response[v1.ListSupplementsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	supplements, err := h.repo.FindByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list supplements", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list supplements"))
+	}
+
+	// Create response
+	resp := &v1.ListSupplementsResponse{
+		Supplements: make([]*v1.Supplement, 0, len(supplements)),
+	}
+	for _, s := range supplements {
+		resp.Supplements = append(resp.Supplements, ToProtoSupplement(s))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// UpdateSupplement replaces a supplement of the user and brings its reminder in line
+func (h *SupplementHandler) UpdateSupplement(ctx context.Context, req *connect.Request[v1.UpdateSupplementRequest]) (*connect.Response[v1.UpdateSupplementResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	supplementID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid supplement ID", "supplementID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	fields, err := h.supplementFields(req.Msg.Name, req.Msg.Dose, req.Msg.GetDailyAt(), req.Msg.GetCron(), req.Msg.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := h.repo.Update(ctx, supplementID, userID, fields, h.clock.Now())
+	if err != nil {
+		if errors.Is(err, repo.ErrSupplementNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("supplement not found"))
+		}
+		if errors.Is(err, repo.ErrSupplementExists) {
+			return nil, connect.NewError(connect.CodeAlreadyExists, errors.New("a supplement with this name already exists"))
+		}
+		h.log.ErrorContext(ctx, "Failed to update supplement", "supplementID", supplementID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to update supplement"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UpdateSupplementResponse{
+		Supplement: ToProtoSupplement(updated),
+	})
+
+	return res, nil
+}
+
+// DeleteSupplement deletes a supplement of the user with its intakes and reminder
+func (h *SupplementHandler) DeleteSupplement(ctx context.Context, req *connect.Request[v1.DeleteSupplementRequest]) (*connect.Response[v1.DeleteSupplementResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	supplementID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid supplement ID", "supplementID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.Delete(ctx, supplementID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrSupplementNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("supplement not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete supplement", "supplementID", supplementID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete supplement"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteSupplementResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// LogSupplementIntake logs an intake of a supplement of the user
+func (h *SupplementHandler) LogSupplementIntake(ctx context.Context, req *connect.Request[v1.LogSupplementIntakeRequest]) (*connect.Response[v1.LogSupplementIntakeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	supplementID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid supplement ID", "supplementID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+	dose := strings.TrimSpace(req.Msg.Dose)
+	if utf8.RuneCountInString(dose) > maxSupplementDoseLength {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DoseTooLong, maxSupplementDoseLength))
+	}
+	now := h.clock.Now()
+	takenAt := now
+	if req.Msg.TakenAt != nil {
+		takenAt = req.Msg.TakenAt.AsTime()
+		if takenAt.After(now) {
+			return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("taken_at cannot be in the future"))
+		}
+	}
+
+	intake, err := h.repo.LogIntake(ctx, supplementID, userID, dose, takenAt, now)
+	if err != nil {
+		if errors.Is(err, repo.ErrSupplementNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("supplement not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to log supplement intake", "supplementID", supplementID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to log supplement intake"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.LogSupplementIntakeResponse{
+		Intake: ToProtoSupplementIntake(intake),
+	})
+
+	return res, nil
+}
+
+// ListSupplementIntakes lists the user's intakes of supplements taken over a range of UTC days
+func (h *SupplementHandler) ListSupplementIntakes(ctx context.Context, req *connect.Request[v1.ListSupplementIntakesRequest]) (*connect.Response[v1.ListSupplementIntakesResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	startDate, endDate := req.Msg.StartDate, req.Msg.EndDate
+	switch {
+	case startDate == "" && endDate == "":
+		startDate = h.clock.Now().UTC().Format("2006-01-02")
+		endDate = startDate
+	case startDate == "":
+		startDate = endDate
+	case endDate == "":
+		endDate = startDate
+	}
+	start, err := reqparse.ParseDate("start_date", startDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	end, err := reqparse.ParseDate("end_date", endDate)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+	if start.After(end) {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.StartDateAfterEndDate))
+	}
+	if end.Sub(start) >= maxSupplementIntakeDays*24*time.Hour {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.RangeTooLong, maxSupplementIntakeDays))
+	}
+
+	intakes, err := h.repo.FindIntakesByUser(ctx, userID, start, end.AddDate(0, 0, 1), maxSupplementIntakesListed)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list supplement intakes", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list supplement intakes"))
+	}
+
+	// Create response
+	resp := &v1.ListSupplementIntakesResponse{
+		Intakes: make([]*v1.SupplementIntake, 0, len(intakes)),
+	}
+	for _, intake := range intakes {
+		resp.Intakes = append(resp.Intakes, ToProtoSupplementIntake(intake))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// DeleteSupplementIntake deletes a supplement intake of the user
+func (h *SupplementHandler) DeleteSupplementIntake(ctx context.Context, req *connect.Request[v1.DeleteSupplementIntakeRequest]) (*connect.Response[v1.DeleteSupplementIntakeResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	intakeID, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid supplement intake ID", "intakeID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	if err := h.repo.DeleteIntake(ctx, intakeID, userID, h.clock.Now()); err != nil {
+		if errors.Is(err, repo.ErrSupplementIntakeNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("supplement intake not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to delete supplement intake", "intakeID", intakeID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to delete supplement intake"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.DeleteSupplementIntakeResponse{
+		Success: true,
+	})
+
+	return res, nil
+}
+
+// supplementFields validates the fields of a create or update request. A schedule, given as a
+// daily time or a cron expression, is validated like that of a reminder.
+func (h *SupplementHandler) supplementFields(name, dose, dailyAt, cron, timezone string) (repo.SupplementFields, error) {
+	fields := repo.SupplementFields{Name: strings.TrimSpace(name), Dose: strings.TrimSpace(dose)}
+	if fields.Name == "" {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameRequired))
+	}
+	if utf8.RuneCountInString(fields.Name) > maxSupplementNameLength {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameTooLong, maxSupplementNameLength))
+	}
+	if fields.Dose == "" || utf8.RuneCountInString(fields.Dose) > maxSupplementDoseLength {
+		return fields, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.DoseInvalid, maxSupplementDoseLength))
+	}
+
+	if dailyAt == "" && cron == "" {
+		return fields, nil
+	}
+	schedule, err := parseReminderSchedule(dailyAt, cron, timezone, h.clock.Now())
+	if err != nil {
+		return fields, err
+	}
+	fields.Reminder = &repo.SupplementReminder{
+		Schedule:   schedule.cron,
+		Timezone:   schedule.timezone,
+		NextFireAt: schedule.nextFireAt,
+	}
+	return fields, nil
+}
+
+// ToProtoSupplement converts a repo.Supplement to a v1.Supplement
+func ToProtoSupplement(s repo.Supplement) *v1.Supplement {
+	protoSupplement := &v1.Supplement{
+		Id:        s.ID.String(),
+		Name:      s.Name,
+		Dose:      s.Dose,
+		Schedule:  s.Schedule,
+		Timezone:  s.Timezone,
+		CreatedAt: timestamppb.New(s.CreatedAt),
+		UpdatedAt: timestamppb.New(s.UpdatedAt),
+	}
+	if s.ReminderID.Valid {
+		protoSupplement.ReminderId = uuid.UUID(s.ReminderID.Bytes).String()
+	}
+	return protoSupplement
+}
+
+// ToProtoSupplementIntake converts a repo.SupplementIntake to a v1.SupplementIntake
+func ToProtoSupplementIntake(i repo.SupplementIntake) *v1.SupplementIntake {
+	return &v1.SupplementIntake{
+		Id:             i.ID.String(),
+		SupplementId:   i.SupplementID.String(),
+		SupplementName: i.SupplementName,
+		Dose:           i.Dose,
+		TakenAt:        timestamppb.New(i.TakenAt),
+		CreatedAt:      timestamppb.New(i.CreatedAt),
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSupplementHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	supplementRepo := repo.NewSupplementRepository(testPool)
+	reminderRepo := repo.NewReminderRepository(testPool)
+	handler := NewSupplementHandler(supplementRepo, testLogger, mockClock)
+
+	var vitaminDID, magnesiumID, reminderID string
+
+	t.Run("Create Supplement With Reminder", func(t *testing.T) {
+		resp, err := handler.CreateSupplement(testCtx, connect.NewRequest(&v1.CreateSupplementRequest{
+			Name:     " Vitamin D ",
+			Dose:     "1000 IU",
+			Schedule: &v1.CreateSupplementRequest_DailyAt{DailyAt: "08:30"},
+			Timezone: "Asia/Tokyo",
+		}))
+		require.NoError(t, err)
+		supplement := resp.Msg.Supplement
+		vitaminDID = supplement.Id
+		reminderID = supplement.ReminderId
+		assert.Equal(t, "Vitamin D", supplement.Name)
+		assert.Equal(t, "1000 IU", supplement.Dose)
+		assert.Equal(t, "30 8 * * *", supplement.Schedule)
+		assert.Equal(t, "Asia/Tokyo", supplement.Timezone)
+		require.NotEmpty(t, reminderID)
+
+		reminders, err := reminderRepo.FindByUser(ctx, testUserID)
+		require.NoError(t, err)
+		require.Len(t, reminders, 1)
+		assert.Equal(t, reminderID, reminders[0].ID.String())
+		assert.Equal(t, "Take Vitamin D", reminders[0].Title)
+		assert.Equal(t, "1000 IU", reminders[0].Message)
+		// 08:30 in Tokyo is 23:30 UTC
+		assert.Equal(t, time.Date(2024, 1, 15, 23, 30, 0, 0, time.UTC), reminders[0].NextFireAt.Time.UTC())
+	})
+
+	t.Run("Create Supplement Without Reminder", func(t *testing.T) {
+		resp, err := handler.CreateSupplement(testCtx, connect.NewRequest(&v1.CreateSupplementRequest{
+			Name: "Magnesium",
+			Dose: "2 capsules",
+		}))
+		require.NoError(t, err)
+		magnesiumID = resp.Msg.Supplement.Id
+		assert.Empty(t, resp.Msg.Supplement.ReminderId)
+		assert.Empty(t, resp.Msg.Supplement.Schedule)
+
+		_, err = handler.CreateSupplement(testCtx, connect.NewRequest(&v1.CreateSupplementRequest{
+			Name: "MAGNESIUM",
+			Dose: "1 capsule",
+		}))
+		assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+
+		listResp, err := handler.ListSupplements(testCtx, connect.NewRequest(&v1.ListSupplementsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Supplements, 2)
+		assert.Equal(t, "Magnesium", listResp.Msg.Supplements[0].Name)
+		assert.Equal(t, "Vitamin D", listResp.Msg.Supplements[1].Name)
+		assert.Equal(t, "30 8 * * *", listResp.Msg.Supplements[1].Schedule)
+	})
+
+	t.Run("Log And List Intakes", func(t *testing.T) {
+		resp, err := handler.LogSupplementIntake(testCtx, connect.NewRequest(&v1.LogSupplementIntakeRequest{Id: vitaminDID}))
+		require.NoError(t, err)
+		assert.Equal(t, "Vitamin D", resp.Msg.Intake.SupplementName)
+		assert.Equal(t, "1000 IU", resp.Msg.Intake.Dose)
+		assert.True(t, fixedTime.Equal(resp.Msg.Intake.TakenAt.AsTime()))
+
+		resp, err = handler.LogSupplementIntake(testCtx, connect.NewRequest(&v1.LogSupplementIntakeRequest{
+			Id:      magnesiumID,
+			Dose:    "1 capsule",
+			TakenAt: timestamppb.New(fixedTime.Add(-time.Hour)),
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "1 capsule", resp.Msg.Intake.Dose)
+
+		_, err = handler.LogSupplementIntake(testCtx, connect.NewRequest(&v1.LogSupplementIntakeRequest{
+			Id:      vitaminDID,
+			TakenAt: timestamppb.New(fixedTime.AddDate(0, 0, -1)),
+		}))
+		require.NoError(t, err)
+
+		// Today by default, newest first
+		listResp, err := handler.ListSupplementIntakes(testCtx, connect.NewRequest(&v1.ListSupplementIntakesRequest{}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Intakes, 2)
+		assert.Equal(t, "Vitamin D", listResp.Msg.Intakes[0].SupplementName)
+		assert.Equal(t, "Magnesium", listResp.Msg.Intakes[1].SupplementName)
+
+		listResp, err = handler.ListSupplementIntakes(testCtx, connect.NewRequest(&v1.ListSupplementIntakesRequest{StartDate: "2024-01-14"}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Intakes, 1)
+
+		_, err = handler.DeleteSupplementIntake(testCtx, connect.NewRequest(&v1.DeleteSupplementIntakeRequest{Id: listResp.Msg.Intakes[0].Id}))
+		require.NoError(t, err)
+		_, err = handler.DeleteSupplementIntake(testCtx, connect.NewRequest(&v1.DeleteSupplementIntakeRequest{Id: listResp.Msg.Intakes[0].Id}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Dashboard Shows Today's Intakes", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.Add(time.Minute))
+		_, err := handler.UpdateSupplement(testCtx, connect.NewRequest(&v1.UpdateSupplementRequest{
+			Id:       magnesiumID,
+			Name:     "Magnesium",
+			Dose:     "2 capsules",
+			Schedule: &v1.UpdateSupplementRequest_Cron{Cron: "0 8,20 * * *"},
+		}))
+		require.NoError(t, err)
+		dashboardHandler := NewDashboardHandler(repo.NewUserRepository(testPool), repo.NewBodyRecordRepository(testPool), repo.NewExerciseRecordRepository(testPool), repo.NewDiaryEntryRepository(testPool, testDiaryCipher), repo.NewGoalRepository(testPool), repo.NewColumnRepository(testPool), repo.NewMealRecordRepository(testPool), repo.NewAchievementRepository(testPool), supplementRepo, repo.NewDailyTargetRepository(testPool), testLogger, mockClock)
+
+		resp, err := dashboardHandler.GetDashboard(testCtx, connect.NewRequest(&v1.GetDashboardRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.TodaySupplements, 2)
+		magnesium := resp.Msg.TodaySupplements[0]
+		assert.Equal(t, magnesiumID, magnesium.SupplementId)
+		assert.Equal(t, int32(1), magnesium.IntakeCount)
+		assert.Equal(t, int32(2), magnesium.ScheduledCount)
+		vitaminD := resp.Msg.TodaySupplements[1]
+		assert.Equal(t, "1000 IU", vitaminD.Dose)
+		assert.Equal(t, int32(1), vitaminD.IntakeCount)
+		assert.Equal(t, int32(1), vitaminD.ScheduledCount)
+	})
+
+	t.Run("Update Supplement Syncs Reminder", func(t *testing.T) {
+		resp, err := handler.UpdateSupplement(testCtx, connect.NewRequest(&v1.UpdateSupplementRequest{
+			Id:       vitaminDID,
+			Name:     "Vitamin D3",
+			Dose:     "2000 IU",
+			Schedule: &v1.UpdateSupplementRequest_DailyAt{DailyAt: "09:00"},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, reminderID, resp.Msg.Supplement.ReminderId)
+		assert.Equal(t, "0 9 * * *", resp.Msg.Supplement.Schedule)
+		assert.Equal(t, "UTC", resp.Msg.Supplement.Timezone)
+
+		reminders, err := reminderRepo.FindByUser(ctx, testUserID)
+		require.NoError(t, err)
+		require.Len(t, reminders, 2)
+		assert.Equal(t, "Take Vitamin D3", reminders[0].Title)
+		assert.Equal(t, "2000 IU", reminders[0].Message)
+
+		// Unsetting the schedule deletes the reminder
+		resp, err = handler.UpdateSupplement(testCtx, connect.NewRequest(&v1.UpdateSupplementRequest{
+			Id:   vitaminDID,
+			Name: "Vitamin D3",
+			Dose: "2000 IU",
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, resp.Msg.Supplement.ReminderId)
+		reminders, err = reminderRepo.FindByUser(ctx, testUserID)
+		require.NoError(t, err)
+		require.Len(t, reminders, 1)
+		assert.Equal(t, "Take Magnesium", reminders[0].Title)
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		for _, req := range []*v1.CreateSupplementRequest{
+			{Name: "", Dose: "1 tablet"},
+			{Name: "Zinc"},
+			{Name: "Zinc", Dose: "1 tablet", Schedule: &v1.CreateSupplementRequest_DailyAt{DailyAt: "25:00"}},
+			{Name: "Zinc", Dose: "1 tablet", Schedule: &v1.CreateSupplementRequest_Cron{Cron: "every day"}},
+			{Name: "Zinc", Dose: "1 tablet", Schedule: &v1.CreateSupplementRequest_DailyAt{DailyAt: "08:00"}, Timezone: "Mars/Olympus"},
+		} {
+			_, err := handler.CreateSupplement(testCtx, connect.NewRequest(req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		}
+
+		_, err := handler.LogSupplementIntake(testCtx, connect.NewRequest(&v1.LogSupplementIntakeRequest{
+			Id:      magnesiumID,
+			TakenAt: timestamppb.New(fixedTime.Add(time.Hour)),
+		}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.ListSupplementIntakes(testCtx, connect.NewRequest(&v1.ListSupplementIntakesRequest{StartDate: "2024-01-01", EndDate: "2024-04-30"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Delete Supplement", func(t *testing.T) {
+		_, err := handler.DeleteSupplement(testCtx, connect.NewRequest(&v1.DeleteSupplementRequest{Id: magnesiumID}))
+		require.NoError(t, err)
+
+		// The reminder and intakes go with it
+		reminders, err := reminderRepo.FindByUser(ctx, testUserID)
+		require.NoError(t, err)
+		assert.Empty(t, reminders)
+		listResp, err := handler.ListSupplementIntakes(testCtx, connect.NewRequest(&v1.ListSupplementIntakesRequest{}))
+		require.NoError(t, err)
+		require.Len(t, listResp.Msg.Intakes, 1)
+		assert.Equal(t, vitaminDID, listResp.Msg.Intakes[0].SupplementId)
+
+		_, err = handler.DeleteSupplement(testCtx, connect.NewRequest(&v1.DeleteSupplementRequest{Id: magnesiumID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		_, err = handler.LogSupplementIntake(testCtx, connect.NewRequest(&v1.LogSupplementIntakeRequest{Id: magnesiumID}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+}
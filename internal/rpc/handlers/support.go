@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SupportHandler implements the support service RPCs
+type SupportHandler struct {
+	users   UserRepository
+	support SupportRepository
+	log     *slog.Logger
+	clock   clock.Clock
+}
+
+// NewSupportHandler creates a new support handler
+func NewSupportHandler(users UserRepository, support SupportRepository, log *slog.Logger, clock clock.Clock) *SupportHandler {
+	return &SupportHandler{
+		users:   users,
+		support: support,
+		log:     log,
+		clock:   clock,
+	}
+}
+
+// GetUserSupportView returns a redacted view of a user's account for support staff
+func (h *SupportHandler) GetUserSupportView(ctx context.Context, req *connect.Request[v1.GetUserSupportViewRequest]) (*connect.Response[v1.GetUserSupportViewResponse], error) {
+	// Get caller ID from context
+	callerID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Only support staff may use this service
+	if !auth.HasRole(ctx, auth.RoleSupport) {
+		h.log.WarnContext(ctx, "Support view requested without support role", "callerID", callerID)
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("support role required"))
+	}
+
+	// Exactly one lookup key must be provided
+	if (req.Msg.UserId == "") == (req.Msg.SubjectId == "") {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.UserSelectorInvalid))
+	}
+
+	// Resolve the target user
+	var user db.User
+	if req.Msg.UserId != "" {
+		userID, err := reqparse.ParseUUID("user_id", req.Msg.UserId)
+		if err != nil {
+			h.log.WarnContext(ctx, "Invalid user ID", "userID", req.Msg.UserId, "error", err)
+			return nil, reqparse.Error(err)
+		}
+		user, err = h.users.FindByID(ctx, userID)
+	} else {
+		user, err = h.users.FindBySubjectID(ctx, req.Msg.SubjectId)
+	}
+	if err != nil {
+		if errors.Is(err, repo.ErrUserNotFound) {
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("user not found"))
+		}
+		h.log.ErrorContext(ctx, "Failed to look up user for support view", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to look up user"))
+	}
+
+	// Every access is logged so support lookups remain auditable
+	h.log.InfoContext(ctx, "Support view accessed", "callerID", callerID, "userID", user.ID, "now", h.clock.Now())
+	// The records of users pinned to a data region are summarized in the database of the region
+	recordsCtx := repo.WithRegion(ctx, user.DataRegion.String)
+	summary, err := h.support.GetUserRecordSummary(recordsCtx, user.ID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get user record summary", "userID", user.ID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get user record summary"))
+	}
+	integrations, err := h.support.ListIntegrationSyncStates(recordsCtx, user.ID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list integration sync states", "userID", user.ID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list integration sync states"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetUserSupportViewResponse{
+		User: ToProtoUserSupportView(user, summary, integrations),
+	})
+
+	return res, nil
+}
+
+// ToProtoUserSupportView converts a user, its record summary and the sync states of its
+// integrations to a v1.UserSupportView
+func ToProtoUserSupportView(user db.User, summary db.GetUserRecordSummaryRow, integrations []db.ListIntegrationSyncStatesByUserRow) *v1.UserSupportView {
+	view := &v1.UserSupportView{
+		UserId:          user.ID.String(),
+		RegisteredAt:    timestamppb.New(user.CreatedAt),
+		LastUpdatedAt:   timestamppb.New(user.UpdatedAt),
+		BodyRecords:     toProtoRecordTypeSummary(summary.BodyRecordCount, summary.BodyRecordLastUpdatedAt),
+		ExerciseRecords: toProtoRecordTypeSummary(summary.ExerciseRecordCount, summary.ExerciseRecordLastUpdatedAt),
+		DiaryEntries:    toProtoRecordTypeSummary(summary.DiaryEntryCount, summary.DiaryEntryLastUpdatedAt),
+		Integrations:    make([]*v1.IntegrationSyncState, 0, len(integrations)),
+	}
+	if user.SuspendedAt.Valid {
+		view.SuspendedAt = timestamppb.New(user.SuspendedAt.Time)
+	}
+	for _, i := range integrations {
+		state := &v1.IntegrationSyncState{
+			Provider:         toProtoIntegrationProvider(i.Provider),
+			SyncFailureCount: i.SyncFailureCount,
+		}
+		if i.LastSyncedAt.Valid {
+			state.LastSyncedAt = timestamppb.New(i.LastSyncedAt.Time)
+		}
+		if i.LastSyncError.Valid {
+			state.LastSyncError = i.LastSyncError.String
+		}
+		view.Integrations = append(view.Integrations, state)
+	}
+	return view
+}
+
+// toProtoRecordTypeSummary builds a summary; lastUpdatedAt is NULL (nil) when the user has no records
+func toProtoRecordTypeSummary(count int64, lastUpdatedAt interface{}) *v1.RecordTypeSummary {
+	summary := &v1.RecordTypeSummary{Count: count}
+	if t, ok := lastUpdatedAt.(time.Time); ok {
+		summary.LastUpdatedAt = timestamppb.New(t)
+	}
+	return summary
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/integration"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestGetUserSupportView(t *testing.T) {
+	resetDB(t, testPool)
+	handler := NewSupportHandler(repo.NewUserRepository(testPool), repo.NewSupportRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	supportCtx := newTestContext(context.WithValue(ctx, auth.RolesContextKey, []string{auth.RoleSupport}))
+
+	// Setup: one body record and one diary entry, no exercise records
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	weight := 75.5
+	_, err := testutil.CreateTestBodyRecord(ctx, testQueries, testUserID, fixedTime.Truncate(24*time.Hour), &weight, nil, fixedTime)
+	require.NoError(t, err)
+	_, err = testutil.CreateTestDiaryEntry(ctx, testQueries, testUserID, "Private title", "Private diary content", fixedTime.Truncate(24*time.Hour), fixedTime)
+	require.NoError(t, err)
+
+	// Error states: a suspended account with an integration failing to sync twice in a row
+	_, err = repo.NewUserRepository(testPool).Suspend(ctx, testUserID, fixedTime)
+	require.NoError(t, err)
+	integrationRepo := newTestIntegrationRepository(t)
+	linked, err := integrationRepo.Link(ctx, testUserID, integration.ProviderGoogleFit, "", "private-access", "private-refresh", fixedTime.Add(time.Hour), fixedTime)
+	require.NoError(t, err)
+	require.NoError(t, integrationRepo.RecordSyncFailure(ctx, linked, errors.New("request to /token failed with status 500"), fixedTime.Add(-time.Hour)))
+	require.NoError(t, integrationRepo.RecordSyncFailure(ctx, linked, errors.New("request to /token failed with status 401"), fixedTime))
+
+	testCases := []struct {
+		name         string
+		ctx          context.Context
+		req          *v1.GetUserSupportViewRequest
+		expectedCode connect.Code
+	}{
+		{
+			name: "Success - By User ID",
+			ctx:  supportCtx,
+			req:  &v1.GetUserSupportViewRequest{UserId: testUserID.String()},
+		},
+		{
+			name:         "Error - Missing Support Role",
+			ctx:          newTestContext(ctx),
+			req:          &v1.GetUserSupportViewRequest{UserId: testUserID.String()},
+			expectedCode: connect.CodePermissionDenied,
+		},
+		{
+			name:         "Error - No Lookup Key",
+			ctx:          supportCtx,
+			req:          &v1.GetUserSupportViewRequest{},
+			expectedCode: connect.CodeInvalidArgument,
+		},
+		{
+			name:         "Error - Unknown User",
+			ctx:          supportCtx,
+			req:          &v1.GetUserSupportViewRequest{UserId: uuid.NewString()},
+			expectedCode: connect.CodeNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := handler.GetUserSupportView(tc.ctx, connect.NewRequest(tc.req))
+
+			if tc.expectedCode != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedCode, connect.CodeOf(err))
+				assert.Nil(t, resp)
+				return
+			}
+
+			require.NoError(t, err)
+			view := resp.Msg.User
+			require.NotNil(t, view)
+			assert.Equal(t, testUserID.String(), view.UserId)
+			assert.EqualValues(t, 1, view.BodyRecords.Count)
+			assert.True(t, view.BodyRecords.LastUpdatedAt.AsTime().Equal(fixedTime))
+			assert.EqualValues(t, 0, view.ExerciseRecords.Count)
+			assert.Nil(t, view.ExerciseRecords.LastUpdatedAt)
+			assert.EqualValues(t, 1, view.DiaryEntries.Count)
+			require.NotNil(t, view.SuspendedAt)
+			assert.True(t, view.SuspendedAt.AsTime().Equal(fixedTime))
+			require.Len(t, view.Integrations, 1)
+			assert.Equal(t, v1.IntegrationProvider_INTEGRATION_PROVIDER_GOOGLE_FIT, view.Integrations[0].Provider)
+			assert.True(t, view.Integrations[0].LastSyncedAt.AsTime().Equal(fixedTime))
+			assert.Equal(t, "request to /token failed with status 401", view.Integrations[0].LastSyncError)
+			assert.EqualValues(t, 2, view.Integrations[0].SyncFailureCount)
+
+			// The serialized view must not leak any record contents
+			raw, err := protojson.Marshal(resp.Msg)
+			require.NoError(t, err)
+			assert.NotContains(t, string(raw), "Private")
+			assert.NotContains(t, string(raw), "75.5")
+			assert.NotContains(t, string(raw), "private-")
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/timeout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutInterceptor(t *testing.T) {
+	ctx := context.Background()
+	interceptor := timeout.Interceptor(100*time.Millisecond, nil)
+
+	// slowQuery runs a query taking longer than the timeout, failing as handlers do
+	slowQuery := func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if _, err := testPool.Exec(ctx, "SELECT pg_sleep(5)"); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get records"))
+		}
+		return connect.NewResponse(&v1.GetGoalsResponse{}), nil
+	}
+
+	t.Run("Slow Query Is Cancelled", func(t *testing.T) {
+		start := time.Now()
+		_, err := interceptor(slowQuery)(ctx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(err))
+		// The query was cancelled instead of holding its connection for its full duration
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("Earlier Client Deadline Is Kept", func(t *testing.T) {
+		var deadline time.Time
+		clientCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := interceptor(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			deadline, _ = ctx.Deadline()
+			return connect.NewResponse(&v1.GetGoalsResponse{}), nil
+		})(clientCtx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		require.NoError(t, err)
+		clientDeadline, _ := clientCtx.Deadline()
+		assert.Equal(t, clientDeadline, deadline)
+	})
+
+	t.Run("Errors Before the Deadline Are Kept", func(t *testing.T) {
+		_, err := interceptor(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return nil, connect.NewError(connect.CodeNotFound, errors.New("record not found"))
+		})(ctx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/metrics"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTracer(t *testing.T) {
+	ctx := context.Background()
+	newPool := func(t *testing.T, logArgs bool) *bytes.Buffer {
+		t.Helper()
+		var logs bytes.Buffer
+		cfg := &config.DatabaseConfig{
+			URL:                testPool.Config().ConnString(),
+			MaxConns:           1,
+			MaxConnLifetime:    time.Hour,
+			MaxConnIdleTime:    time.Minute,
+			HealthCheckPeriod:  time.Minute,
+			SlowQueryThreshold: 50 * time.Millisecond,
+			LogQueryArgs:       logArgs,
+		}
+		pool, err := repo.NewDBPool(cfg, slog.New(slog.NewJSONHandler(&logs, nil)))
+		require.NoError(t, err)
+		t.Cleanup(pool.Close)
+
+		_, err = pool.Exec(ctx, "-- name: FastQuery :exec\nSELECT $1::text", "fast")
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, "-- name: SlowQuery :exec\nSELECT pg_sleep(0.1), $1::text", "slow")
+		require.NoError(t, err)
+		return &logs
+	}
+
+	t.Run("Slow Queries Are Logged Without Arguments", func(t *testing.T) {
+		logs := newPool(t, false)
+		lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+		require.Len(t, lines, 1)
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		assert.Equal(t, "Slow query", entry["msg"])
+		assert.Equal(t, "SlowQuery", entry["queryName"])
+		assert.EqualValues(t, 1, entry["rows"])
+		assert.NotContains(t, entry, "args")
+	})
+
+	t.Run("Arguments Are Logged When Enabled", func(t *testing.T) {
+		logs := newPool(t, true)
+		assert.Contains(t, logs.String(), `"args":["slow"]`)
+	})
+
+	t.Run("Durations Are Served as Histograms", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		metrics.PrometheusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		body := rec.Body.String()
+		assert.Contains(t, body, "# TYPE healthapp_db_query_duration_seconds histogram")
+		assert.Contains(t, body, `healthapp_db_query_duration_seconds_bucket{query="SlowQuery",le="0.05"} 0`)
+		assert.Contains(t, body, `healthapp_db_query_duration_seconds_bucket{query="SlowQuery",le="0.25"} 2`)
+		assert.Contains(t, body, `healthapp_db_query_duration_seconds_count{query="FastQuery"} 2`)
+	})
+}
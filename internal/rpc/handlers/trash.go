@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// trashItemTypes maps the entity types of the trash to proto trash item types
+var trashItemTypes = map[string]v1.TrashItemType{
+	repo.EntityTypeBodyRecord:       v1.TrashItemType_TRASH_ITEM_TYPE_BODY_RECORD,
+	repo.EntityTypeExerciseRecord:   v1.TrashItemType_TRASH_ITEM_TYPE_EXERCISE_RECORD,
+	repo.EntityTypeDiaryEntry:       v1.TrashItemType_TRASH_ITEM_TYPE_DIARY_ENTRY,
+	repo.EntityTypeAttachment:       v1.TrashItemType_TRASH_ITEM_TYPE_ATTACHMENT,
+	repo.EntityTypeExerciseTemplate: v1.TrashItemType_TRASH_ITEM_TYPE_EXERCISE_TEMPLATE,
+	repo.EntityTypeFast:             v1.TrashItemType_TRASH_ITEM_TYPE_FAST,
+	repo.EntityTypeMealRecord:       v1.TrashItemType_TRASH_ITEM_TYPE_MEAL_RECORD,
+	repo.EntityTypeMoodRecord:       v1.TrashItemType_TRASH_ITEM_TYPE_MOOD_RECORD,
+	repo.EntityTypePlannedMeal:      v1.TrashItemType_TRASH_ITEM_TYPE_PLANNED_MEAL,
+	repo.EntityTypeRecipe:           v1.TrashItemType_TRASH_ITEM_TYPE_RECIPE,
+	repo.EntityTypeReminder:         v1.TrashItemType_TRASH_ITEM_TYPE_REMINDER,
+	repo.EntityTypeSupplement:       v1.TrashItemType_TRASH_ITEM_TYPE_SUPPLEMENT,
+	repo.EntityTypeSupplementIntake: v1.TrashItemType_TRASH_ITEM_TYPE_SUPPLEMENT_INTAKE,
+}
+
+// TrashHandler implements the trash service RPCs
+type TrashHandler struct {
+	repo       TrashRepository
+	window     time.Duration // How long deleted records can be restored
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewTrashHandler creates a new trash handler listing and restoring the records deleted within
+// window
+func NewTrashHandler(repo TrashRepository, window time.Duration, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *TrashHandler {
+	return &TrashHandler{
+		repo:       repo,
+		window:     window,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// ListTrash returns the user's deleted records that can still be restored
+func (h *TrashHandler) ListTrash(ctx context.Context, req *connect.Request[v1.ListTrashRequest]) (*connect.Response[v1.ListTrashResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	// Items past the window are left out, even before the trash job purged them
+	cutoff := h.clock.Now().Add(-h.window)
+	items, err := h.repo.FindByUser(ctx, userID, cutoff, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to fetch trash", "userID", userID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to fetch trash"))
+	}
+
+	total, err := h.repo.CountByUser(ctx, userID, cutoff)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count trash", "userID", userID, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to count trash"))
+	}
+
+	protoItems := make([]*v1.TrashItem, len(items))
+	for i, item := range items {
+		protoItems[i] = ToProtoTrashItem(item, h.window)
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.ListTrashResponse{
+		Items:      protoItems,
+		Pagination: page.Response(total),
+	})
+
+	return res, nil
+}
+
+// RestoreTrashItem restores a deleted record of the user from the trash
+func (h *TrashHandler) RestoreTrashItem(ctx context.Context, req *connect.Request[v1.RestoreTrashItemRequest]) (*connect.Response[v1.RestoreTrashItemResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Parse trash item ID
+	id, err := reqparse.ParseUUID("id", req.Msg.Id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid trash item ID", "trashItemID", req.Msg.Id, "error", err)
+		return nil, reqparse.Error(err)
+	}
+
+	now := h.clock.Now()
+	h.log.InfoContext(ctx, "Restoring trash item", "trashItemID", id)
+	item, err := h.repo.Restore(ctx, id, userID, now.Add(-h.window), now)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrTrashItemNotFound):
+			return nil, apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("trash item not found"))
+		case errors.Is(err, repo.ErrTrashRestoreConflict):
+			return nil, connect.NewError(connect.CodeAlreadyExists, errors.New("the record conflicts with a record saved since it was deleted"))
+		case errors.Is(err, repo.ErrTrashRestoreParentMissing):
+			return nil, connect.NewError(connect.CodeFailedPrecondition, errors.New("the record belongs to a deleted record; restore that first"))
+		}
+		h.log.ErrorContext(ctx, "Failed to restore trash item", "trashItemID", id, "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to restore trash item"))
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.RestoreTrashItemResponse{
+		Item: ToProtoTrashItem(item, h.window),
+	})
+
+	return res, nil
+}
+
+// ToProtoTrashItem converts a db.Trash to a v1.TrashItem purged window after its deletion
+func ToProtoTrashItem(item db.Trash, window time.Duration) *v1.TrashItem {
+	return &v1.TrashItem{
+		Id:        item.ID.String(),
+		Type:      trashItemTypes[item.EntityType],
+		RecordId:  item.EntityID.String(),
+		DeletedAt: timestamppb.New(item.DeletedAt),
+		PurgeAt:   timestamppb.New(item.DeletedAt.Add(window)),
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/storage"
+	"github.com/atreya2011/health-management-api/internal/trash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTrash(t *testing.T) {
+	resetDB(t, testPool)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	cfg := config.TrashConfig{Days: 30, Enabled: true, Interval: time.Hour}
+	store := newTestStore(t)
+	trashRepo := repo.NewTrashRepository(testPool)
+	handler := NewTrashHandler(trashRepo, cfg.Window(), DefaultPageLimits, testLogger, mockClock)
+	bodyRecordRepo := repo.NewBodyRecordRepository(testPool)
+	attachmentRepo := repo.NewAttachmentRepository(testPool)
+	bodyHandler := NewBodyRecordHandler(bodyRecordRepo, attachmentRepo, repo.NewPreferenceRepository(testPool), store, testAuthorizer, DefaultPageLimits, DefaultMaxDateRangeDays, testLogger, mockClock)
+	attachmentHandler := NewAttachmentHandler(attachmentRepo, store, 1024, testLogger, mockClock)
+	mealHandler := NewMealRecordHandler(repo.NewMealRecordRepository(testPool), testAuthorizer, DefaultPageLimits, testLogger, mockClock)
+	supplementHandler := NewSupplementHandler(repo.NewSupplementRepository(testPool), testLogger, mockClock)
+	reminderHandler := NewReminderHandler(repo.NewReminderRepository(testPool), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+
+	// listTrash returns the trash of the test user, most recently deleted first
+	listTrash := func(t *testing.T) []*v1.TrashItem {
+		t.Helper()
+		resp, err := handler.ListTrash(testCtx, connect.NewRequest(&v1.ListTrashRequest{}))
+		require.NoError(t, err)
+		return resp.Msg.Items
+	}
+	// itemOfType returns the ID of the item of a type in items
+	itemOfType := func(t *testing.T, items []*v1.TrashItem, itemType v1.TrashItemType) string {
+		t.Helper()
+		for _, item := range items {
+			if item.Type == itemType {
+				return item.Id
+			}
+		}
+		require.Failf(t, "missing trash item", "no %s in the trash", itemType)
+		return ""
+	}
+	restore := func(id string) (*connect.Response[v1.RestoreTrashItemResponse], error) {
+		return handler.RestoreTrashItem(testCtx, connect.NewRequest(&v1.RestoreTrashItemRequest{Id: id}))
+	}
+
+	// A body record with a ready photo, whose file is kept in the trash
+	weight := 70.0
+	record, err := bodyRecordRepo.Save(ctx, testUserID, fixedTime.Truncate(24*time.Hour), &weight, nil, "", fixedTime)
+	require.NoError(t, err)
+	photo, err := attachmentRepo.CreateForBodyRecord(ctx, testUserID, record.ID, uuid.New(), "image/png", int64(len(testPNG)), maxAttachmentsPerBodyRecord, fixedTime)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(ctx, photo.StorageKey, "image/png", testPNG))
+	_, err = attachmentRepo.MarkReady(ctx, photo.ID, testUserID, fixedTime)
+	require.NoError(t, err)
+
+	t.Run("Meal Record", func(t *testing.T) {
+		created, err := mealHandler.CreateMealRecord(testCtx, connect.NewRequest(&v1.CreateMealRecordRequest{Name: "Oatmeal", Calories: 350}))
+		require.NoError(t, err)
+		_, err = mealHandler.DeleteMealRecord(testCtx, connect.NewRequest(&v1.DeleteMealRecordRequest{Id: created.Msg.MealRecord.Id}))
+		require.NoError(t, err)
+
+		items := listTrash(t)
+		require.Len(t, items, 1)
+		assert.Equal(t, v1.TrashItemType_TRASH_ITEM_TYPE_MEAL_RECORD, items[0].Type)
+		assert.Equal(t, created.Msg.MealRecord.Id, items[0].RecordId)
+		assert.Equal(t, fixedTime, items[0].DeletedAt.AsTime())
+		assert.Equal(t, fixedTime.AddDate(0, 0, 30), items[0].PurgeAt.AsTime())
+
+		restored, err := restore(items[0].Id)
+		require.NoError(t, err)
+		assert.Equal(t, items[0].Id, restored.Msg.Item.Id)
+		assert.Empty(t, listTrash(t))
+
+		// The record is back under its ID, as it was
+		list, err := mealHandler.ListMealRecords(testCtx, connect.NewRequest(&v1.ListMealRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, list.Msg.MealRecords, 1)
+		assert.Equal(t, created.Msg.MealRecord.Id, list.Msg.MealRecords[0].Id)
+		assert.Equal(t, "Oatmeal", list.Msg.MealRecords[0].Name)
+
+		_, err = restore(items[0].Id)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		_, err = restore("not-a-uuid")
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Body Record With Photos", func(t *testing.T) {
+		deleteByRange := func(t *testing.T) {
+			t.Helper()
+			_, err := bodyHandler.DeleteBodyRecordsByDateRange(testCtx, connect.NewRequest(&v1.DeleteBodyRecordsByDateRangeRequest{
+				StartDate:     "2024-01-15",
+				EndDate:       "2024-01-15",
+				ExpectedCount: 1,
+			}))
+			require.NoError(t, err)
+		}
+
+		// A photo can't be restored without its body record
+		_, err := attachmentHandler.DeleteAttachment(testCtx, connect.NewRequest(&v1.DeleteAttachmentRequest{Id: photo.ID.String()}))
+		require.NoError(t, err)
+		deleteByRange(t)
+		items := listTrash(t)
+		require.Len(t, items, 2)
+		recordItemID := itemOfType(t, items, v1.TrashItemType_TRASH_ITEM_TYPE_BODY_RECORD)
+		photoItemID := itemOfType(t, items, v1.TrashItemType_TRASH_ITEM_TYPE_ATTACHMENT)
+		_, err = restore(photoItemID)
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+
+		_, err = restore(recordItemID)
+		require.NoError(t, err)
+		_, err = restore(photoItemID)
+		require.NoError(t, err)
+		list, err := bodyHandler.ListBodyRecords(testCtx, connect.NewRequest(&v1.ListBodyRecordsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, list.Msg.BodyRecords, 1)
+		assert.Equal(t, record.ID.String(), list.Msg.BodyRecords[0].Id)
+		require.Len(t, list.Msg.BodyRecords[0].Photos, 1)
+		assert.Equal(t, photo.ID.String(), list.Msg.BodyRecords[0].Photos[0].Id)
+
+		// A record saved for the same date since conflicts with the deleted one
+		deleteByRange(t)
+		_, err = bodyRecordRepo.Save(ctx, testUserID, fixedTime.Truncate(24*time.Hour), &weight, nil, "", fixedTime)
+		require.NoError(t, err)
+		items = listTrash(t)
+		require.Len(t, items, 1)
+		_, err = restore(items[0].Id)
+		assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+		assert.Len(t, listTrash(t), 1)
+	})
+
+	t.Run("Supplement With Reminder", func(t *testing.T) {
+		created, err := supplementHandler.CreateSupplement(testCtx, connect.NewRequest(&v1.CreateSupplementRequest{
+			Name:     "Vitamin D",
+			Dose:     "1000 IU",
+			Schedule: &v1.CreateSupplementRequest_DailyAt{DailyAt: "08:30"},
+		}))
+		require.NoError(t, err)
+		_, err = supplementHandler.LogSupplementIntake(testCtx, connect.NewRequest(&v1.LogSupplementIntakeRequest{
+			Id:      created.Msg.Supplement.Id,
+			TakenAt: timestamppb.New(fixedTime.Add(-time.Hour)),
+		}))
+		require.NoError(t, err)
+		_, err = supplementHandler.DeleteSupplement(testCtx, connect.NewRequest(&v1.DeleteSupplementRequest{Id: created.Msg.Supplement.Id}))
+		require.NoError(t, err)
+
+		_, err = restore(itemOfType(t, listTrash(t), v1.TrashItemType_TRASH_ITEM_TYPE_SUPPLEMENT))
+		require.NoError(t, err)
+
+		supplements, err := supplementHandler.ListSupplements(testCtx, connect.NewRequest(&v1.ListSupplementsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, supplements.Msg.Supplements, 1)
+		assert.Equal(t, created.Msg.Supplement.ReminderId, supplements.Msg.Supplements[0].ReminderId)
+		reminders, err := reminderHandler.ListReminders(testCtx, connect.NewRequest(&v1.ListRemindersRequest{}))
+		require.NoError(t, err)
+		assert.Len(t, reminders.Msg.Reminders, 1)
+		intakes, err := supplementHandler.ListSupplementIntakes(testCtx, connect.NewRequest(&v1.ListSupplementIntakesRequest{StartDate: "2024-01-15"}))
+		require.NoError(t, err)
+		assert.Len(t, intakes.Msg.Intakes, 1)
+	})
+
+	t.Run("Columns Added Since Get Their Defaults", func(t *testing.T) {
+		created, err := mealHandler.CreateMealRecord(testCtx, connect.NewRequest(&v1.CreateMealRecordRequest{Name: "Soup", Calories: 200}))
+		require.NoError(t, err)
+		_, err = mealHandler.DeleteMealRecord(testCtx, connect.NewRequest(&v1.DeleteMealRecordRequest{Id: created.Msg.MealRecord.Id}))
+		require.NoError(t, err)
+
+		// A later release adds a NOT NULL column, which the trashed row doesn't have
+		_, err = testPool.Exec(ctx, "ALTER TABLE meal_records ADD COLUMN portion TEXT NOT NULL DEFAULT 'regular'")
+		require.NoError(t, err)
+		defer func() {
+			_, err := testPool.Exec(ctx, "ALTER TABLE meal_records DROP COLUMN portion")
+			require.NoError(t, err)
+		}()
+
+		_, err = restore(itemOfType(t, listTrash(t), v1.TrashItemType_TRASH_ITEM_TYPE_MEAL_RECORD))
+		require.NoError(t, err)
+		var portion, name string
+		require.NoError(t, testPool.QueryRow(ctx, "SELECT portion, name FROM meal_records WHERE id = $1", created.Msg.MealRecord.Id).Scan(&portion, &name))
+		assert.Equal(t, "regular", portion)
+		assert.Equal(t, "Soup", name)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		// The body record conflicting above is still in the trash, with its photo file
+		items := listTrash(t)
+		require.Len(t, items, 1)
+		_, err := store.Stat(ctx, photo.StorageKey)
+		require.NoError(t, err)
+
+		// A photo uploaded but never completed, purged with its file
+		weight := 71.0
+		other, err := bodyRecordRepo.Save(ctx, testUserID, fixedTime.Truncate(24*time.Hour).AddDate(0, 0, -1), &weight, nil, "", fixedTime)
+		require.NoError(t, err)
+		pending, err := attachmentRepo.CreateForBodyRecord(ctx, testUserID, other.ID, uuid.New(), "image/png", int64(len(testPNG)), maxAttachmentsPerBodyRecord, fixedTime)
+		require.NoError(t, err)
+		require.NoError(t, store.Put(ctx, pending.StorageKey, "image/png", testPNG))
+
+		// Past the window, items are no longer listed or restorable, before the job purges them
+		mockClock.SetTime(fixedTime.AddDate(0, 0, 30).Add(time.Second))
+		assert.Empty(t, listTrash(t))
+		_, err = restore(items[0].Id)
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+		job := trash.NewJob(trashRepo, store, cfg, testLogger, mockClock)
+		report, err := job.Purge(ctx, true)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, report.ItemsPurged)
+		assert.EqualValues(t, 1, report.PendingAttachmentsPurged)
+		_, err = store.Stat(ctx, photo.StorageKey)
+		require.NoError(t, err, "dry runs keep the files")
+
+		report, err = job.Purge(ctx, false)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, report.ItemsPurged)
+		assert.EqualValues(t, 1, report.PendingAttachmentsPurged)
+		assert.EqualValues(t, 2, report.FilesDeleted)
+		_, err = store.Stat(ctx, photo.StorageKey)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = store.Stat(ctx, pending.StorageKey)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		count, err := trashRepo.CountExpired(ctx, mockClock.Now())
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/quota"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UsageHandler implements the usage service RPCs
+type UsageHandler struct {
+	repo  APIUsageRepository
+	limit *quota.Limit
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewUsageHandler creates a new usage handler reporting usage against the daily quota of limit
+func NewUsageHandler(repo APIUsageRepository, limit *quota.Limit, log *slog.Logger, clock clock.Clock) *UsageHandler {
+	return &UsageHandler{
+		repo:  repo,
+		limit: limit,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// GetUsage returns the user's request count and remaining quota of the current UTC day
+func (h *UsageHandler) GetUsage(ctx context.Context, req *connect.Request[v1.GetUsageRequest]) (*connect.Response[v1.GetUsageResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	now := h.clock.Now()
+	count, err := h.repo.FindByDay(ctx, userID, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to get API usage", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to get usage"))
+	}
+
+	// Create response
+	dailyQuota := h.limit.DailyRequests()
+	usage := &v1.Usage{
+		Date:         now.UTC().Format("2006-01-02"),
+		RequestCount: count,
+		DailyQuota:   dailyQuota,
+		ResetsAt:     timestamppb.New(quota.ResetsAt(now)),
+	}
+	if dailyQuota > 0 {
+		usage.Remaining = max(dailyQuota-count, 0)
+	}
+	res := connect.NewResponse(&v1.GetUsageResponse{
+		Usage: usage,
+	})
+
+	return res, nil
+}
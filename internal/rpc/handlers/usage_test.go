@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/quota"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1/healthappv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageQuota(t *testing.T) {
+	resetDB(t, testPool)
+	usageRepo := repo.NewAPIUsageRepository(testPool)
+	handler := NewUsageHandler(usageRepo, quota.NewLimit(2), testLogger, mockClock)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	// call runs a request through a quota interceptor allowing dailyQuota requests
+	call := func(ctx context.Context, dailyQuota int32) error {
+		interceptor := quota.Interceptor(usageRepo, quota.NewLimit(dailyQuota), nil, testLogger, mockClock)
+		_, err := interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&v1.GetGoalsResponse{}), nil
+		})(ctx, connect.NewRequest(&v1.GetGoalsRequest{}))
+		return err
+	}
+	getUsage := func(t *testing.T) *v1.Usage {
+		t.Helper()
+		resp, err := handler.GetUsage(testCtx, connect.NewRequest(&v1.GetUsageRequest{}))
+		require.NoError(t, err)
+		return resp.Msg.Usage
+	}
+
+	t.Run("Quota Exceeded", func(t *testing.T) {
+		usage := getUsage(t)
+		assert.Equal(t, "2024-01-15", usage.Date)
+		assert.EqualValues(t, 0, usage.RequestCount)
+		assert.EqualValues(t, 2, usage.DailyQuota)
+		assert.EqualValues(t, 2, usage.Remaining)
+		assert.Equal(t, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), usage.ResetsAt.AsTime())
+
+		require.NoError(t, call(testCtx, 2))
+		require.NoError(t, call(testCtx, 2))
+		err := call(testCtx, 2)
+		assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonQuotaExceeded, apierror.Reason(err))
+		var connectErr *connect.Error
+		require.ErrorAs(t, err, &connectErr)
+		assert.Equal(t, "50400", connectErr.Meta().Get("Retry-After"))
+
+		// Rejected requests are not counted
+		usage = getUsage(t)
+		assert.EqualValues(t, 2, usage.RequestCount)
+		assert.EqualValues(t, 0, usage.Remaining)
+	})
+
+	t.Run("Quota Resets Daily", func(t *testing.T) {
+		mockClock.SetTime(fixedTime.Add(24 * time.Hour))
+		defer mockClock.SetTime(fixedTime)
+
+		require.NoError(t, call(testCtx, 2))
+		usage := getUsage(t)
+		assert.Equal(t, "2024-01-16", usage.Date)
+		assert.EqualValues(t, 1, usage.RequestCount)
+		assert.EqualValues(t, 1, usage.Remaining)
+	})
+
+	t.Run("Unlimited Requests Are Counted", func(t *testing.T) {
+		require.NoError(t, call(testCtx, 0))
+		count, err := usageRepo.FindByDay(ctx, testUserID, fixedTime)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, count)
+
+		unlimited := NewUsageHandler(usageRepo, quota.NewLimit(0), testLogger, mockClock)
+		resp, err := unlimited.GetUsage(testCtx, connect.NewRequest(&v1.GetUsageRequest{}))
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, resp.Msg.Usage.DailyQuota)
+		assert.EqualValues(t, 0, resp.Msg.Usage.Remaining)
+	})
+
+	t.Run("Unauthenticated Requests Are Not Counted", func(t *testing.T) {
+		require.NoError(t, call(ctx, 2))
+	})
+
+	t.Run("GetUsage Is Exempt", func(t *testing.T) {
+		// Serve the service behind the quota interceptor, authenticating calls as the test user
+		authenticate := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				return next(newTestContext(ctx), req)
+			}
+		})
+		interceptor := quota.Interceptor(usageRepo, quota.NewLimit(2), []string{healthappv1connect.UsageServiceGetUsageProcedure}, testLogger, mockClock)
+		mux := http.NewServeMux()
+		mux.Handle(healthappv1connect.NewUsageServiceHandler(handler, connect.WithInterceptors(authenticate, interceptor)))
+		server := httptest.NewServer(mux)
+		defer server.Close()
+		client := healthappv1connect.NewUsageServiceClient(server.Client(), server.URL)
+
+		// The quota is exhausted, yet usage can be polled without being counted
+		for range 3 {
+			resp, err := client.GetUsage(ctx, connect.NewRequest(&v1.GetUsageRequest{}))
+			require.NoError(t, err)
+			assert.EqualValues(t, 3, resp.Msg.Usage.RequestCount)
+		}
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.GetUsage(ctx, connect.NewRequest(&v1.GetUsageRequest{}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserAdminHandler implements the user admin service RPCs
+type UserAdminHandler struct {
+	users      UserRepository
+	records    UserRepository // Reads the copies of users in the databases of their data regions
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewUserAdminHandler creates a new user admin handler administering users with users, in the home
+// database, and reading the record counts of users pinned to a data region with records
+func NewUserAdminHandler(users, records UserRepository, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *UserAdminHandler {
+	return &UserAdminHandler{
+		users:      users,
+		records:    records,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// SearchUsers lists the users whose subject ID contains the query
+func (h *UserAdminHandler) SearchUsers(ctx context.Context, req *connect.Request[v1.SearchUsersRequest]) (*connect.Response[v1.SearchUsersResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	query := strings.TrimSpace(req.Msg.SubjectId)
+	if query == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.SubjectIDRequired))
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	h.log.InfoContext(ctx, "Admin user search", "callerID", callerID, "query", query, "page", page.Number)
+	users, err := h.users.SearchBySubjectID(ctx, query, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to search users", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to search users"))
+	}
+	total, err := h.users.CountBySubjectIDSearch(ctx, query)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count users", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to search users"))
+	}
+
+	// Create response
+	protoUsers := make([]*v1.AdminUser, len(users))
+	for i, user := range users {
+		counted, err := h.withRecordCounts(ctx, user)
+		if err != nil {
+			return nil, h.userError(ctx, user.ID, err, "failed to search users")
+		}
+		protoUsers[i] = ToProtoAdminUser(counted)
+	}
+	res := connect.NewResponse(&v1.SearchUsersResponse{
+		Users:      protoUsers,
+		Pagination: page.Response(total),
+	})
+
+	return res, nil
+}
+
+// GetUser returns a user's account
+func (h *UserAdminHandler) GetUser(ctx context.Context, req *connect.Request[v1.GetUserRequest]) (*connect.Response[v1.GetUserResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	userID, err := h.parseUserID(ctx, req.Msg.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	h.log.InfoContext(ctx, "Admin user view accessed", "callerID", callerID, "userID", userID)
+	user, err := h.users.FindByID(ctx, userID)
+	if err == nil {
+		user, err = h.withRecordCounts(ctx, user)
+	}
+	if err != nil {
+		return nil, h.userError(ctx, userID, err, "failed to get user")
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.GetUserResponse{
+		User: ToProtoAdminUser(user),
+	})
+
+	return res, nil
+}
+
+// SuspendUser suspends a user's account, so the auth interceptor rejects their requests
+func (h *UserAdminHandler) SuspendUser(ctx context.Context, req *connect.Request[v1.SuspendUserRequest]) (*connect.Response[v1.SuspendUserResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	userID, err := h.parseUserID(ctx, req.Msg.Id)
+	if err != nil {
+		return nil, err
+	}
+	// An admin suspending themselves would have no way to undo it
+	if userID == callerID {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.CannotSuspendSelf))
+	}
+
+	h.log.InfoContext(ctx, "Suspending user", "callerID", callerID, "userID", userID)
+	user, err := h.users.Suspend(ctx, userID, h.clock.Now())
+	if err == nil {
+		user, err = h.withRecordCounts(ctx, user)
+	}
+	if err != nil {
+		return nil, h.userError(ctx, userID, err, "failed to suspend user")
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.SuspendUserResponse{
+		User: ToProtoAdminUser(user),
+	})
+
+	return res, nil
+}
+
+// UnsuspendUser lifts the suspension of a user's account
+func (h *UserAdminHandler) UnsuspendUser(ctx context.Context, req *connect.Request[v1.UnsuspendUserRequest]) (*connect.Response[v1.UnsuspendUserResponse], error) {
+	callerID, err := authorizeAdmin(ctx, h.log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate input
+	userID, err := h.parseUserID(ctx, req.Msg.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	h.log.InfoContext(ctx, "Unsuspending user", "callerID", callerID, "userID", userID)
+	user, err := h.users.Unsuspend(ctx, userID)
+	if err == nil {
+		user, err = h.withRecordCounts(ctx, user)
+	}
+	if err != nil {
+		return nil, h.userError(ctx, userID, err, "failed to unsuspend user")
+	}
+
+	// Create response
+	res := connect.NewResponse(&v1.UnsuspendUserResponse{
+		User: ToProtoAdminUser(user),
+	})
+
+	return res, nil
+}
+
+// authorizeAdmin returns the ID of the caller, failing unless they have the admin role
+func authorizeAdmin(ctx context.Context, log *slog.Logger) (uuid.UUID, error) {
+	// Get caller ID from context
+	callerID, err := auth.GetUserID(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "User ID not found in context")
+		return uuid.Nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Only admins may use the admin services
+	if !auth.HasRole(ctx, auth.RoleAdmin) {
+		log.WarnContext(ctx, "Admin service called without admin role", "callerID", callerID)
+		return uuid.Nil, connect.NewError(connect.CodePermissionDenied, errors.New("admin role required"))
+	}
+	return callerID, nil
+}
+
+// parseUserID parses the ID of the user an admin RPC targets
+func (h *UserAdminHandler) parseUserID(ctx context.Context, id string) (uuid.UUID, error) {
+	userID, err := reqparse.ParseUUID("id", id)
+	if err != nil {
+		h.log.WarnContext(ctx, "Invalid user ID", "userID", id, "error", err)
+		return uuid.Nil, reqparse.Error(err)
+	}
+	return userID, nil
+}
+
+// withRecordCounts returns the home database row of user with the record counts and last activity
+// of their copy in the database of their data region, where the records of pinned users are kept
+func (h *UserAdminHandler) withRecordCounts(ctx context.Context, user db.User) (db.User, error) {
+	if !user.DataRegion.Valid {
+		return user, nil
+	}
+	copied, err := h.records.FindByID(repo.WithRegion(ctx, user.DataRegion.String), user.ID)
+	if err != nil {
+		return db.User{}, fmt.Errorf("failed to get user in region %s: %w", user.DataRegion.String, err)
+	}
+	user.BodyRecordCount = copied.BodyRecordCount
+	user.ExerciseRecordCount = copied.ExerciseRecordCount
+	user.DiaryEntryCount = copied.DiaryEntryCount
+	user.StepRecordCount = copied.StepRecordCount
+	user.LastActivityAt = copied.LastActivityAt
+	return user, nil
+}
+
+// userError converts an error looking up or updating a user into a Connect error
+func (h *UserAdminHandler) userError(ctx context.Context, userID uuid.UUID, err error, msg string) error {
+	if errors.Is(err, repo.ErrUserNotFound) {
+		return apierror.New(connect.CodeNotFound, apierror.ReasonNotFound, errors.New("user not found"))
+	}
+	h.log.ErrorContext(ctx, "Failed to administer user", "userID", userID, "error", err)
+	return connect.NewError(connect.CodeInternal, errors.New(msg))
+}
+
+// ToProtoAdminUser converts a db.User to a v1.AdminUser
+func ToProtoAdminUser(user db.User) *v1.AdminUser {
+	protoUser := &v1.AdminUser{
+		Id:                  user.ID.String(),
+		SubjectId:           user.SubjectID,
+		RegisteredAt:        timestamppb.New(user.CreatedAt),
+		BodyRecordCount:     user.BodyRecordCount,
+		ExerciseRecordCount: user.ExerciseRecordCount,
+		DiaryEntryCount:     user.DiaryEntryCount,
+		StepRecordCount:     user.StepRecordCount,
+		Suspended:           user.SuspendedAt.Valid,
+	}
+	if user.LastActivityAt.Valid {
+		protoUser.LastActivityAt = timestamppb.New(user.LastActivityAt.Time)
+	}
+	if user.SuspendedAt.Valid {
+		protoUser.SuspendedAt = timestamppb.New(user.SuspendedAt.Time)
+	}
+	return protoUser
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAdminHandler(t *testing.T) {
+	resetDB(t, testPool)
+	// Suspensions apply at once although the auth interceptor resolves users through the cache
+	userRepo := repo.NewCachedUserRepository(testPool, repo.NewUserCache(10, time.Hour, mockClock))
+	handler := NewUserAdminHandler(userRepo, userRepo, DefaultPageLimits, testLogger, mockClock)
+	ctx := context.Background()
+	adminCtx := newTestContext(context.WithValue(ctx, auth.RolesContextKey, []string{auth.RoleAdmin}))
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+
+	targetID, err := testutil.CreateTestUser(ctx, testQueries)
+	require.NoError(t, err)
+	target, err := userRepo.FindByID(ctx, targetID)
+	require.NoError(t, err)
+	weight := 70.0
+	_, err = testutil.CreateTestBodyRecord(ctx, testQueries, targetID, fixedTime.Truncate(24*time.Hour), &weight, nil, fixedTime)
+	require.NoError(t, err)
+
+	// call runs a request of the target user through the auth interceptor
+	const secretKey = "test-secret"
+	interceptor := auth.AuthInterceptor(&auth.JWTConfig{SecretKey: secretKey}, userRepo, repo.NewSessionRepository(testPool), testLogger)
+	call := func(t *testing.T) error {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": target.SubjectID}).SignedString([]byte(secretKey))
+		require.NoError(t, err)
+		req := connect.NewRequest(&v1.GetAuthenticatedUserRequest{})
+		req.Header().Set("Authorization", "Bearer "+token)
+		_, err = interceptor.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&v1.GetAuthenticatedUserResponse{}), nil
+		})(ctx, req)
+		return err
+	}
+
+	t.Run("Search Users", func(t *testing.T) {
+		// Subject IDs are "test|<uuid>", so a part of the UUID matches only the target
+		query := strings.TrimPrefix(target.SubjectID, "test|")[:13]
+		resp, err := handler.SearchUsers(adminCtx, connect.NewRequest(&v1.SearchUsersRequest{SubjectId: query}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.Users, 1)
+		user := resp.Msg.Users[0]
+		assert.Equal(t, targetID.String(), user.Id)
+		assert.Equal(t, target.SubjectID, user.SubjectId)
+		assert.Equal(t, target.CreatedAt, user.RegisteredAt.AsTime())
+		assert.EqualValues(t, 1, user.BodyRecordCount)
+		assert.EqualValues(t, 0, user.DiaryEntryCount)
+		assert.False(t, user.Suspended)
+		assert.EqualValues(t, 1, resp.Msg.Pagination.TotalItems)
+
+		// Every test user matches the common prefix
+		resp, err = handler.SearchUsers(adminCtx, connect.NewRequest(&v1.SearchUsersRequest{
+			SubjectId:  "test|",
+			Pagination: &v1.PageRequest{PageSize: 1},
+		}))
+		require.NoError(t, err)
+		assert.Len(t, resp.Msg.Users, 1)
+		assert.GreaterOrEqual(t, resp.Msg.Pagination.TotalItems, int32(2))
+	})
+
+	t.Run("Suspend and Unsuspend", func(t *testing.T) {
+		require.NoError(t, call(t))
+
+		resp, err := handler.SuspendUser(adminCtx, connect.NewRequest(&v1.SuspendUserRequest{Id: targetID.String()}))
+		require.NoError(t, err)
+		assert.True(t, resp.Msg.User.Suspended)
+		assert.Equal(t, fixedTime, resp.Msg.User.SuspendedAt.AsTime())
+
+		err = call(t)
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		assert.Equal(t, apierror.ReasonAccountSuspended, apierror.Reason(err))
+
+		// Suspending again keeps the original suspension time
+		mockClock.SetTime(fixedTime.Add(time.Hour))
+		defer mockClock.SetTime(fixedTime)
+		resp, err = handler.SuspendUser(adminCtx, connect.NewRequest(&v1.SuspendUserRequest{Id: targetID.String()}))
+		require.NoError(t, err)
+		assert.Equal(t, fixedTime, resp.Msg.User.SuspendedAt.AsTime())
+
+		getResp, err := handler.GetUser(adminCtx, connect.NewRequest(&v1.GetUserRequest{Id: targetID.String()}))
+		require.NoError(t, err)
+		assert.True(t, getResp.Msg.User.Suspended)
+
+		unsuspendResp, err := handler.UnsuspendUser(adminCtx, connect.NewRequest(&v1.UnsuspendUserRequest{Id: targetID.String()}))
+		require.NoError(t, err)
+		assert.False(t, unsuspendResp.Msg.User.Suspended)
+		assert.Nil(t, unsuspendResp.Msg.User.SuspendedAt)
+		require.NoError(t, call(t))
+	})
+
+	t.Run("Error - Suspend Self", func(t *testing.T) {
+		_, err := handler.SuspendUser(adminCtx, connect.NewRequest(&v1.SuspendUserRequest{Id: testUserID.String()}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Invalid Input", func(t *testing.T) {
+		_, err := handler.SearchUsers(adminCtx, connect.NewRequest(&v1.SearchUsersRequest{SubjectId: " "}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.GetUser(adminCtx, connect.NewRequest(&v1.GetUserRequest{Id: "invalid"}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = handler.GetUser(adminCtx, connect.NewRequest(&v1.GetUserRequest{Id: uuid.NewString()}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+		_, err = handler.SuspendUser(adminCtx, connect.NewRequest(&v1.SuspendUserRequest{Id: uuid.NewString()}))
+		assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Missing Admin Role", func(t *testing.T) {
+		supportCtx := newTestContext(context.WithValue(ctx, auth.RolesContextKey, []string{auth.RoleSupport}))
+		_, err := handler.SuspendUser(supportCtx, connect.NewRequest(&v1.SuspendUserRequest{Id: targetID.String()}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+		_, err = handler.SearchUsers(newTestContext(ctx), connect.NewRequest(&v1.SearchUsersRequest{SubjectId: "test|"}))
+		assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	})
+
+	t.Run("Error - Unauthenticated", func(t *testing.T) {
+		_, err := handler.GetUser(ctx, connect.NewRequest(&v1.GetUserRequest{Id: targetID.String()}))
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	})
+}
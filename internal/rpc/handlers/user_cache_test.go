@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserCache(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	newUser := func(subjectID string) db.User {
+		return db.User{ID: uuid.New(), SubjectID: subjectID}
+	}
+
+	t.Run("Users Expire After the TTL", func(t *testing.T) {
+		cacheClock := clock.NewDefaultMockClock()
+		cacheClock.SetTime(now)
+		cache := repo.NewUserCache(10, 30*time.Second, cacheClock)
+		user := newUser("test|ttl")
+		cache.Put(user)
+
+		cacheClock.SetTime(now.Add(29 * time.Second))
+		cached, ok := cache.Get("test|ttl")
+		assert.True(t, ok)
+		assert.Equal(t, user.ID, cached.ID)
+
+		cacheClock.SetTime(now.Add(30 * time.Second))
+		_, ok = cache.Get("test|ttl")
+		assert.False(t, ok)
+		assert.Zero(t, cache.Len())
+	})
+
+	t.Run("Least Recently Used User Is Evicted", func(t *testing.T) {
+		cacheClock := clock.NewDefaultMockClock()
+		cache := repo.NewUserCache(2, time.Minute, cacheClock)
+		cache.Put(newUser("test|a"))
+		cache.Put(newUser("test|b"))
+		_, ok := cache.Get("test|a")
+		assert.True(t, ok)
+
+		cache.Put(newUser("test|c"))
+		assert.Equal(t, 2, cache.Len())
+		_, ok = cache.Get("test|b")
+		assert.False(t, ok)
+		_, ok = cache.Get("test|a")
+		assert.True(t, ok)
+		_, ok = cache.Get("test|c")
+		assert.True(t, ok)
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		cache := repo.NewUserCache(10, time.Minute, clock.NewDefaultMockClock())
+		user := newUser("test|invalidate")
+		cache.Put(user)
+		cache.Invalidate(user.ID)
+		_, ok := cache.Get("test|invalidate")
+		assert.False(t, ok)
+
+		cache.Put(user)
+		cache.InvalidateSubject("test|invalidate")
+		_, ok = cache.Get("test|invalidate")
+		assert.False(t, ok)
+		assert.Zero(t, cache.Len())
+	})
+
+	t.Run("Disabled Cache", func(t *testing.T) {
+		cache := repo.NewUserCache(10, 0, clock.NewDefaultMockClock())
+		cache.Put(newUser("test|disabled"))
+		_, ok := cache.Get("test|disabled")
+		assert.False(t, ok)
+
+		var none *repo.UserCache
+		none.Put(newUser("test|nil"))
+		_, ok = none.Get("test|nil")
+		assert.False(t, ok)
+	})
+}
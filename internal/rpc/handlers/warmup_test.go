@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/grpchealth"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/health"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/warmup"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmup(t *testing.T) {
+	ctx := context.Background()
+	fixedTime := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+
+	t.Run("Statements Are Primed", func(t *testing.T) {
+		pool, err := repo.NewDBPool(&config.DatabaseConfig{
+			URL:               testPool.Config().ConnString(),
+			MaxConns:          2,
+			MaxConnLifetime:   time.Hour,
+			MaxConnIdleTime:   time.Minute,
+			HealthCheckPeriod: time.Minute,
+		}, testLogger)
+		require.NoError(t, err)
+		defer pool.Close()
+
+		require.NoError(t, repo.Prime(ctx, pool, 2, 20, fixedTime))
+		// Both connections of the pool have the statements of the hot queries prepared
+		conns := make([]*pgxpool.Conn, 2)
+		for i := range conns {
+			conns[i], err = pool.Acquire(ctx)
+			require.NoError(t, err)
+			defer conns[i].Release()
+			var prepared int
+			require.NoError(t, conns[i].QueryRow(ctx, "SELECT count(*) FROM pg_prepared_statements").Scan(&prepared))
+			assert.GreaterOrEqual(t, prepared, 4)
+		}
+	})
+
+	t.Run("JWT Signing Key", func(t *testing.T) {
+		assert.NoError(t, auth.CheckSigningKey(&auth.JWTConfig{SecretKey: "secret", AccessTokenTTL: time.Minute}, fixedTime))
+		assert.Error(t, auth.CheckSigningKey(&auth.JWTConfig{SecretKey: "secret"}, fixedTime), "tokens expiring right away aren't valid")
+	})
+
+	t.Run("Not Ready While Warming Up", func(t *testing.T) {
+		checker := health.NewChecker(testLogger, mockClock)
+		checker.AddDependency("database", health.DatabaseCheck(testPool))
+		checker.Warming()
+		healthz := health.ReadyHandler(checker, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status":"ok"}`))
+		}))
+		check := func(t *testing.T) (grpchealth.Status, int) {
+			t.Helper()
+			resp, err := checker.Check(ctx, &grpchealth.CheckRequest{})
+			require.NoError(t, err)
+			rec := httptest.NewRecorder()
+			healthz.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			return resp.Status, rec.Code
+		}
+
+		status, code := check(t)
+		assert.Equal(t, grpchealth.StatusNotServing, status)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		// Failed steps don't stop the warmup
+		var ran []string
+		ok := warmup.Run(ctx, []warmup.Step{
+			{Name: "failing", Run: func(ctx context.Context) error {
+				ran = append(ran, "failing")
+				return errors.New("unreachable")
+			}},
+			{Name: "database", Run: func(ctx context.Context) error {
+				ran = append(ran, "database")
+				return health.DatabaseCheck(testPool)(ctx)
+			}},
+		}, time.Second, testLogger, mockClock)
+		assert.False(t, ok)
+		assert.Equal(t, []string{"failing", "database"}, ran)
+
+		checker.Ready()
+		status, code = check(t)
+		assert.Equal(t, grpchealth.StatusServing, status)
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/apierror"
+	"github.com/atreya2011/health-management-api/internal/auth"
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	db "github.com/atreya2011/health-management-api/internal/repo/gen"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/atreya2011/health-management-api/internal/rpc/reqparse"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// maxWorkoutSessionNameLength bounds the name of a workout session, in characters
+	maxWorkoutSessionNameLength = 100
+	// maxWorkoutExercises bounds the exercises of a workout session
+	maxWorkoutExercises = 50
+)
+
+// WorkoutSessionHandler implements the workout session service RPCs
+type WorkoutSessionHandler struct {
+	repo       WorkoutSessionRepository
+	pageLimits PageLimits
+	log        *slog.Logger
+	clock      clock.Clock
+}
+
+// NewWorkoutSessionHandler creates a new workout session handler
+func NewWorkoutSessionHandler(repo WorkoutSessionRepository, pageLimits PageLimits, log *slog.Logger, clock clock.Clock) *WorkoutSessionHandler {
+	return &WorkoutSessionHandler{
+		repo:       repo,
+		pageLimits: pageLimits,
+		log:        log,
+		clock:      clock,
+	}
+}
+
+// CreateWorkoutSession creates a workout session with its exercise records
+func (h *WorkoutSessionHandler) CreateWorkoutSession(ctx context.Context, req *connect.Request[v1.CreateWorkoutSessionRequest]) (*connect.Response[v1.CreateWorkoutSessionResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Validate input
+	name := strings.TrimSpace(req.Msg.Name)
+	if name == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameRequired))
+	}
+	if utf8.RuneCountInString(name) > maxWorkoutSessionNameLength {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.NameTooLong, maxWorkoutSessionNameLength))
+	}
+	if len(req.Msg.Exercises) == 0 || len(req.Msg.Exercises) > maxWorkoutExercises {
+		return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WorkoutExerciseCount, maxWorkoutExercises))
+	}
+	now := h.clock.Now()
+	startedAt := now
+	if req.Msg.StartedAt != nil {
+		startedAt = req.Msg.StartedAt.AsTime()
+	}
+	if startedAt.After(now) {
+		return nil, apierror.New(connect.CodeInvalidArgument, apierror.ReasonValidationFutureDate, errors.New("started_at cannot be in the future"))
+	}
+	exercises := make([]repo.WorkoutExercise, 0, len(req.Msg.Exercises))
+	for i, e := range req.Msg.Exercises {
+		exercise, err := workoutExercise(e)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, i18n.NewError(i18n.WorkoutExerciseInvalid, i+1, err))
+		}
+		exercises = append(exercises, exercise)
+	}
+
+	session, records, err := h.repo.Create(ctx, userID, name, startedAt, exercises, now)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to create workout session", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to create workout session"))
+	}
+	h.log.InfoContext(ctx, "Workout session created", "workoutSessionID", session.ID, "exercises", len(records))
+
+	// Create response
+	res := connect.NewResponse(&v1.CreateWorkoutSessionResponse{
+		WorkoutSession: ToProtoWorkoutSession(session, records),
+	})
+
+	return res, nil
+}
+
+// ListWorkoutSessions lists the workout sessions of the user with their exercise records
+func (h *WorkoutSessionHandler) ListWorkoutSessions(ctx context.Context, req *connect.Request[v1.ListWorkoutSessionsRequest]) (*connect.Response[v1.ListWorkoutSessionsResponse], error) {
+	// Get user ID from context
+	userID, err := auth.GetUserID(ctx)
+	if err != nil {
+		h.log.ErrorContext(ctx, "User ID not found in context")
+		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("user not authenticated"))
+	}
+
+	// Get pagination parameters
+	page, err := reqparse.NormalizePagination(req.Msg.Pagination, h.pageLimits)
+	if err != nil {
+		return nil, reqparse.Error(err)
+	}
+
+	sessions, err := h.repo.FindByUser(ctx, userID, page.Size, page.Offset)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list workout sessions", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list workout sessions"))
+	}
+	total, err := h.repo.CountByUser(ctx, userID)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to count workout sessions", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list workout sessions"))
+	}
+
+	// Get the records of the page's sessions with a single query
+	sessionIDs := make([]uuid.UUID, len(sessions))
+	for i, s := range sessions {
+		sessionIDs[i] = s.ID
+	}
+	records, err := h.repo.FindRecords(ctx, userID, sessionIDs)
+	if err != nil {
+		h.log.ErrorContext(ctx, "Failed to list workout session records", "error", err)
+		return nil, connect.NewError(connect.CodeInternal, errors.New("failed to list workout sessions"))
+	}
+
+	// Create response
+	resp := &v1.ListWorkoutSessionsResponse{
+		WorkoutSessions: make([]*v1.WorkoutSession, 0, len(sessions)),
+		Pagination:      page.Response(total),
+	}
+	for _, s := range sessions {
+		resp.WorkoutSessions = append(resp.WorkoutSessions, ToProtoWorkoutSession(s, records[s.ID]))
+	}
+
+	return connect.NewResponse(resp), nil
+}
+
+// workoutExercise validates an exercise of a new workout session against the limits of
+// exercise records
+func workoutExercise(e *v1.WorkoutExercise) (repo.WorkoutExercise, error) {
+	exercise := repo.WorkoutExercise{ExerciseName: e.ExerciseName}
+	if e.ExerciseName == "" {
+		return exercise, i18n.NewError(i18n.ExerciseNameEmpty)
+	}
+	if len(e.ExerciseName) > 100 {
+		return exercise, i18n.NewError(i18n.ExerciseNameTooLong)
+	}
+	if e.DurationMinutes != nil {
+		if e.DurationMinutes.Value <= 0 {
+			return exercise, i18n.NewError(i18n.DurationNotPositive)
+		}
+		if e.DurationMinutes.Value > 1440 { // 24 hours in minutes
+			return exercise, i18n.NewError(i18n.DurationTooLong)
+		}
+		exercise.DurationMinutes = &e.DurationMinutes.Value
+	}
+	if e.CaloriesBurned != nil {
+		if e.CaloriesBurned.Value < 0 {
+			return exercise, i18n.NewError(i18n.CaloriesNegative)
+		}
+		if e.CaloriesBurned.Value > 10000 {
+			return exercise, i18n.NewError(i18n.CaloriesTooLarge)
+		}
+		exercise.CaloriesBurned = &e.CaloriesBurned.Value
+	}
+	if e.Rpe != nil {
+		if e.Rpe.Value < 1 || e.Rpe.Value > 10 {
+			return exercise, i18n.NewError(i18n.RPEOutOfRange)
+		}
+		exercise.Effort.RPE = &e.Rpe.Value
+	}
+	if e.Intensity != v1.ExerciseIntensity_EXERCISE_INTENSITY_UNSPECIFIED {
+		intensity, ok := exerciseIntensityNames[e.Intensity]
+		if !ok {
+			return exercise, i18n.NewError(i18n.UnsupportedIntensity)
+		}
+		exercise.Effort.Intensity = intensity
+	}
+	return exercise, nil
+}
+
+// ToProtoWorkoutSession converts a db.WorkoutSession and its exercise records to a v1.WorkoutSession
+func ToProtoWorkoutSession(s db.WorkoutSession, records []db.ExerciseRecord) *v1.WorkoutSession {
+	protoSession := &v1.WorkoutSession{
+		Id:              s.ID.String(),
+		Name:            s.Name,
+		StartedAt:       timestamppb.New(s.StartedAt),
+		ExerciseRecords: make([]*v1.ExerciseRecord, 0, len(records)),
+		CreatedAt:       timestamppb.New(s.CreatedAt),
+		UpdatedAt:       timestamppb.New(s.UpdatedAt),
+	}
+
+	for _, record := range records {
+		protoSession.ExerciseRecords = append(protoSession.ExerciseRecords, ToProtoExerciseRecord(record))
+		protoSession.TotalDurationMinutes += record.DurationMinutes.Int32
+		protoSession.TotalCaloriesBurned += record.CaloriesBurned.Int32
+	}
+
+	return protoSession
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestWorkoutSessionHandler(t *testing.T) {
+	resetDB(t, testPool)
+	ctx := context.Background()
+	testCtx := newTestContext(ctx)
+	fixedTime := time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC)
+	mockClock.SetTime(fixedTime)
+	defer mockClock.SetTime(fixedTime)
+	recordRepo := repo.NewExerciseRecordRepository(testPool)
+	handler := NewWorkoutSessionHandler(repo.NewWorkoutSessionRepository(testPool), DefaultPageLimits, testLogger, mockClock)
+	yesterday := fixedTime.Add(-24 * time.Hour)
+
+	t.Run("Create Session With Its Records", func(t *testing.T) {
+		resp, err := handler.CreateWorkoutSession(testCtx, connect.NewRequest(&v1.CreateWorkoutSessionRequest{
+			Name:      "Leg day",
+			StartedAt: timestamppb.New(yesterday),
+			Exercises: []*v1.WorkoutExercise{
+				{ExerciseName: "Squats", DurationMinutes: wrapperspb.Int32(15), CaloriesBurned: wrapperspb.Int32(120), Rpe: wrapperspb.Int32(8)},
+				{ExerciseName: "Lunges", DurationMinutes: wrapperspb.Int32(10)},
+				{ExerciseName: "Calf raises", CaloriesBurned: wrapperspb.Int32(40), Intensity: v1.ExerciseIntensity_EXERCISE_INTENSITY_LIGHT},
+			},
+		}))
+		require.NoError(t, err)
+		session := resp.Msg.WorkoutSession
+		assert.Equal(t, "Leg day", session.Name)
+		assert.Equal(t, yesterday, session.StartedAt.AsTime())
+		assert.Equal(t, int32(25), session.TotalDurationMinutes)
+		assert.Equal(t, int32(160), session.TotalCaloriesBurned)
+		require.Len(t, session.ExerciseRecords, 3)
+		for i, name := range []string{"Squats", "Lunges", "Calf raises"} {
+			assert.Equal(t, name, session.ExerciseRecords[i].ExerciseName)
+			assert.Equal(t, session.Id, session.ExerciseRecords[i].WorkoutSessionId)
+			assert.Equal(t, yesterday, session.ExerciseRecords[i].RecordedAt.AsTime())
+		}
+		assert.Equal(t, int32(8), session.ExerciseRecords[0].Rpe.Value)
+		assert.Equal(t, v1.ExerciseIntensity_EXERCISE_INTENSITY_LIGHT, session.ExerciseRecords[2].Intensity)
+
+		// The records are regular exercise records
+		records, err := recordRepo.FindByUser(ctx, testUserID, 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, records, 3)
+	})
+
+	t.Run("Invalid Input Creates Nothing", func(t *testing.T) {
+		for name, req := range map[string]*v1.CreateWorkoutSessionRequest{
+			"No Name":      {Exercises: []*v1.WorkoutExercise{{ExerciseName: "Run"}}},
+			"No Exercises": {Name: "Cardio"},
+			"Future Start": {Name: "Cardio", StartedAt: timestamppb.New(fixedTime.Add(time.Hour)), Exercises: []*v1.WorkoutExercise{{ExerciseName: "Run"}}},
+			"Invalid Exercise": {Name: "Cardio", Exercises: []*v1.WorkoutExercise{
+				{ExerciseName: "Run"},
+				{ExerciseName: "Row", DurationMinutes: wrapperspb.Int32(0)},
+			}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := handler.CreateWorkoutSession(testCtx, connect.NewRequest(req))
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			})
+		}
+
+		records, err := recordRepo.FindByUser(ctx, testUserID, 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, records, 3)
+	})
+
+	t.Run("List Sessions Newest First", func(t *testing.T) {
+		_, err := handler.CreateWorkoutSession(testCtx, connect.NewRequest(&v1.CreateWorkoutSessionRequest{
+			Name:      "Cardio",
+			Exercises: []*v1.WorkoutExercise{{ExerciseName: "Run", DurationMinutes: wrapperspb.Int32(30)}},
+		}))
+		require.NoError(t, err)
+
+		resp, err := handler.ListWorkoutSessions(testCtx, connect.NewRequest(&v1.ListWorkoutSessionsRequest{}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.WorkoutSessions, 2)
+		assert.Equal(t, int32(2), resp.Msg.Pagination.TotalItems)
+		assert.Equal(t, "Cardio", resp.Msg.WorkoutSessions[0].Name)
+		assert.Equal(t, fixedTime, resp.Msg.WorkoutSessions[0].StartedAt.AsTime())
+		require.Len(t, resp.Msg.WorkoutSessions[0].ExerciseRecords, 1)
+		legDay := resp.Msg.WorkoutSessions[1]
+		require.Len(t, legDay.ExerciseRecords, 3)
+		assert.Equal(t, "Squats", legDay.ExerciseRecords[0].ExerciseName)
+		assert.Equal(t, "Calf raises", legDay.ExerciseRecords[2].ExerciseName)
+
+		resp, err = handler.ListWorkoutSessions(testCtx, connect.NewRequest(&v1.ListWorkoutSessionsRequest{
+			Pagination: &v1.PageRequest{PageSize: 1, PageNumber: 2},
+		}))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.WorkoutSessions, 1)
+		assert.Equal(t, "Leg day", resp.Msg.WorkoutSessions[0].Name)
+		assert.Equal(t, int32(2), resp.Msg.Pagination.TotalPages)
+	})
+}
@@ -0,0 +1,145 @@
+// Package reqparse parses the request fields shared by the RPC handlers: dates, UUIDs and page
+// requests. Fields that fail to parse are reported as *FieldError, which Error maps to a
+// connect.CodeInvalidArgument error with a localized message naming the field.
+package reqparse
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+
+	"github.com/atreya2011/health-management-api/internal/i18n"
+	v1 "github.com/atreya2011/health-management-api/internal/rpc/gen/healthapp/v1"
+)
+
+// DateLayout is the layout of the date fields of requests and responses
+const DateLayout = "2006-01-02"
+
+// FieldError is a request field that failed to parse
+type FieldError struct {
+	Field string // Proto name of the field, e.g. "entry_date"
+	Code  string // i18n code of the message
+	Err   error  // Error of the parser
+}
+
+// Error returns the English message, e.g. "entry_date must be a date in YYYY-MM-DD format"
+func (e *FieldError) Error() string {
+	return i18n.Message(i18n.English, e.Code, e.Field)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ParseDate parses the date field named field, in DateLayout
+func ParseDate(field, value string) (time.Time, error) {
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return time.Time{}, &FieldError{Field: field, Code: i18n.DateInvalid, Err: err}
+	}
+	return date, nil
+}
+
+// ParseUUID parses the ID field named field
+func ParseUUID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, &FieldError{Field: field, Code: i18n.IDInvalid, Err: err}
+	}
+	return id, nil
+}
+
+// Error maps an error of the parse functions to a Connect error: *FieldError to
+// connect.CodeInvalidArgument, anything else to connect.CodeInternal
+func Error(err error) *connect.Error {
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		return connect.NewError(connect.CodeInvalidArgument, i18n.NewError(fieldErr.Code, fieldErr.Field))
+	}
+	return connect.NewError(connect.CodeInternal, err)
+}
+
+// PageLimits are the default and maximum page sizes of a list endpoint
+type PageLimits struct {
+	DefaultPageSize int // Used when a request does not set a page size
+	MaxPageSize     int // Larger requested page sizes are capped to this
+}
+
+// Page is a page request with the defaults applied
+type Page struct {
+	Size   int
+	Number int // 1-based; the page the offset falls in for pages continued from a token
+	Offset int // Number of items before the page
+}
+
+// pageTokenPrefix versions the page tokens, so other kinds of tokens, e.g. keyset cursors, can be
+// told apart from offsets
+const pageTokenPrefix = "o:"
+
+// EncodePageToken returns the page token of the page starting after offset items
+func EncodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.Itoa(offset)))
+}
+
+// decodePageToken returns the offset of a page token of EncodePageToken
+func decodePageToken(token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	digits, ok := strings.CutPrefix(string(raw), pageTokenPrefix)
+	if !ok {
+		return 0, errors.New("unknown page token kind")
+	}
+	offset, err := strconv.Atoi(digits)
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid page token offset")
+	}
+	return offset, nil
+}
+
+// NormalizePagination applies the default page size and number to the unset fields of req, which
+// may be nil, and caps the page size. A page token takes precedence over the page number.
+func NormalizePagination(req *v1.PageRequest, limits PageLimits) (Page, error) {
+	size, number := limits.DefaultPageSize, 1
+	if req != nil {
+		if req.PageSize > 0 {
+			size = int(req.PageSize)
+		}
+		if req.PageNumber > 0 {
+			number = int(req.PageNumber)
+		}
+	}
+	size = min(size, limits.MaxPageSize)
+	if req.GetPageToken() == "" {
+		return Page{Size: size, Number: number, Offset: (number - 1) * size}, nil
+	}
+
+	offset, err := decodePageToken(req.PageToken)
+	if err != nil {
+		return Page{}, &FieldError{Field: "page_token", Code: i18n.PageTokenInvalid, Err: err}
+	}
+	return Page{Size: size, Number: offset/size + 1, Offset: offset}, nil
+}
+
+// Response returns the page response of the page of a list of total items; empty lists have one
+// page
+func (p Page) Response(total int64) *v1.PageResponse {
+	totalPages := max((int(total)+p.Size-1)/p.Size, 1) // Ceiling division
+	resp := &v1.PageResponse{
+		TotalItems:  int32(total),
+		TotalPages:  int32(totalPages),
+		CurrentPage: int32(p.Number),
+		HasNext:     int64(p.Offset+p.Size) < total,
+		HasPrevious: p.Offset > 0,
+	}
+	if resp.HasNext {
+		resp.NextPageToken = EncodePageToken(p.Offset + p.Size)
+	}
+	return resp
+}
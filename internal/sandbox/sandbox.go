@@ -0,0 +1,134 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/repo"
+)
+
+// fixtureDays is how many days of history the sandbox user is reset to
+const fixtureDays = 30
+
+// Resetter resets the sandbox user to the seeded fixtures once a day, so demo environments
+// start every day from the same data regardless of what was changed through the API
+type Resetter struct {
+	repo      *repo.SandboxRepository
+	subjectID string
+	// resetAt is the offset from midnight UTC of the daily reset
+	resetAt time.Duration
+	log     *slog.Logger
+	clock   clock.Clock
+}
+
+// NewResetter creates a resetter for the user with the given JWT subject, resetting daily at
+// resetAt past midnight UTC
+func NewResetter(repo *repo.SandboxRepository, subjectID string, resetAt time.Duration, log *slog.Logger, clock clock.Clock) *Resetter {
+	return &Resetter{
+		repo:      repo,
+		subjectID: subjectID,
+		resetAt:   resetAt,
+		log:       log,
+		clock:     clock,
+	}
+}
+
+// Run resets the sandbox user immediately and then daily until ctx is cancelled
+func (r *Resetter) Run(ctx context.Context) {
+	for {
+		if err := r.Reset(ctx); err != nil {
+			r.log.ErrorContext(ctx, "Failed to reset sandbox user", "subjectID", r.subjectID, "error", err)
+		}
+
+		timer := time.NewTimer(r.NextReset(r.clock.Now()).Sub(r.clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Reset replaces all data of the sandbox user with the fixtures
+func (r *Resetter) Reset(ctx context.Context) error {
+	now := r.clock.Now()
+	user, err := r.repo.Reset(ctx, r.subjectID, Fixtures(now), now)
+	if err != nil {
+		return fmt.Errorf("failed to reset sandbox user: %w", err)
+	}
+	r.log.InfoContext(ctx, "Sandbox user reset", "subjectID", r.subjectID, "userID", user.ID, "now", now)
+	return nil
+}
+
+// NextReset returns the first daily reset time after now
+func (r *Resetter) NextReset(now time.Time) time.Time {
+	next := now.UTC().Truncate(24 * time.Hour).Add(r.resetAt)
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
+
+// Fixtures returns the sandbox data for the fixtureDays days up to now. The values are fixed
+// per day offset, so every reset yields the same data relative to the current date.
+func Fixtures(now time.Time) repo.SandboxFixtures {
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	var fixtures repo.SandboxFixtures
+	for i := 0; i < fixtureDays; i++ {
+		date := today.AddDate(0, 0, -i)
+
+		// A slow downward weight trend with day-to-day noise
+		weight := 72.0 + float64(i)*0.05 + float64(i%3)*0.2
+		bodyFat := 18.0 + float64(i%4)*0.3
+		fixtures.BodyRecords = append(fixtures.BodyRecords, repo.SandboxBodyRecord{
+			Date:              date,
+			WeightKg:          &weight,
+			BodyFatPercentage: &bodyFat,
+		})
+
+		fixtures.StepRecords = append(fixtures.StepRecords, repo.SandboxStepRecord{
+			Date:  date,
+			Steps: int32(6000 + (i*1733)%6000),
+		})
+
+		// Workouts every other day, in the early evening UTC
+		if i%2 == 0 && i > 0 {
+			exercise := sandboxExercises[(i/2)%len(sandboxExercises)]
+			startedAt := date.Add(18 * time.Hour)
+			calories := exercise.calories
+			fixtures.ExerciseRecords = append(fixtures.ExerciseRecords, repo.SandboxExerciseRecord{
+				ExerciseName:   exercise.name,
+				StartedAt:      startedAt,
+				EndedAt:        startedAt.Add(exercise.duration),
+				CaloriesBurned: &calories,
+			})
+		}
+
+		if i%7 == 1 {
+			fixtures.DiaryEntries = append(fixtures.DiaryEntries, repo.SandboxDiaryEntry{
+				Title:     fmt.Sprintf("Week %d check-in", i/7+1),
+				Content:   "Slept well most nights and kept up with the workout plan.",
+				EntryDate: date,
+			})
+		}
+	}
+
+	return fixtures
+}
+
+// sandboxExercises are the workouts the sandbox user rotates through
+var sandboxExercises = []struct {
+	name     string
+	duration time.Duration
+	calories int32
+}{
+	{name: "Running", duration: 35 * time.Minute, calories: 380},
+	{name: "Cycling", duration: 50 * time.Minute, calories: 420},
+	{name: "Yoga", duration: 45 * time.Minute, calories: 150},
+	{name: "Swimming", duration: 40 * time.Minute, calories: 350},
+}
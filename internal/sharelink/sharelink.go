@@ -0,0 +1,67 @@
+// Package sharelink signs the tokens of share links. A token carries the ID of its link, when it
+// expires and the data region of its database, so forged or expired tokens are rejected before
+// the database is queried; links revoked before they expire are checked against the database by
+// their callers.
+package sharelink
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, not signed with the key, or expired
+var ErrInvalidToken = errors.New("invalid or expired share link")
+
+// payloadSize is the size of the fixed signed part of a token: the link ID and the Unix expiry.
+// The data region follows it; tokens of links in the home database end there.
+const payloadSize = 16 + 8
+
+// Signer signs and verifies the tokens of share links
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer of tokens with key
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Sign returns the URL-safe token of the link with ID id expiring at expiresAt, stored in the
+// database of the data region; "" is the home database
+func (s *Signer) Sign(id uuid.UUID, expiresAt time.Time, region string) string {
+	payload := make([]byte, payloadSize, payloadSize+len(region)+sha256.Size)
+	copy(payload, id[:])
+	binary.BigEndian.PutUint64(payload[16:], uint64(expiresAt.Unix()))
+	payload = append(payload, region...)
+	return base64.RawURLEncoding.EncodeToString(append(payload, s.mac(payload)...))
+}
+
+// Verify returns the link ID and data region of a token signed with the key that has not expired
+// at now
+func (s *Signer) Verify(token string, now time.Time) (uuid.UUID, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < payloadSize+sha256.Size {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	payload, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(signature, s.mac(payload)) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	if now.Unix() >= int64(binary.BigEndian.Uint64(payload[16:payloadSize])) {
+		return uuid.Nil, "", ErrInvalidToken
+	}
+	id, _ := uuid.FromBytes(payload[:16])
+	return id, string(payload[payloadSize:]), nil
+}
+
+func (s *Signer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// metaSuffix is the file name suffix of the metadata stored next to each object
+const metaSuffix = ".meta"
+
+// localMeta is the metadata of an object of a Local store
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+// Local stores objects as files in a directory, for development and single-server deployments.
+// Its signed URLs point at the server itself and are served by Handler.
+type Local struct {
+	dir string
+	// baseURL is the URL Handler is mounted at
+	baseURL    string
+	signingKey []byte
+	clock      clock.Clock
+}
+
+// NewLocal creates a store keeping objects in dir, with URLs under baseURL signed with
+// signingKey
+func NewLocal(dir, baseURL string, signingKey []byte, clock clock.Clock) *Local {
+	return &Local{
+		dir:        dir,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		signingKey: signingKey,
+		clock:      clock,
+	}
+}
+
+// SignUpload returns a PUT request uploading the object to Handler
+func (l *Local) SignUpload(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (SignedRequest, error) {
+	if !fs.ValidPath(key) {
+		return SignedRequest{}, fmt.Errorf("invalid key %q", key)
+	}
+	return SignedRequest{
+		Method: http.MethodPut,
+		URL:    l.signedURL(http.MethodPut, key, contentType, size, ttl),
+		Headers: map[string]string{
+			"Content-Type":   contentType,
+			"Content-Length": strconv.FormatInt(size, 10),
+		},
+	}, nil
+}
+
+// SignDownload returns a GET URL of the object, served by Handler
+func (l *Local) SignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if !fs.ValidPath(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return l.signedURL(http.MethodGet, key, "", 0, ttl), nil
+}
+
+// Stat returns the size and content type of the object
+func (l *Local) Stat(ctx context.Context, key string) (Object, error) {
+	info, err := os.Stat(l.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Object{}, ErrNotFound
+		}
+		return Object{}, fmt.Errorf("failed to stat object: %w", err)
+	}
+	meta, err := l.readMeta(key)
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Size: info.Size(), ContentType: meta.ContentType}, nil
+}
+
+// ReadPrefix returns up to the first n bytes of the object
+func (l *Local) ReadPrefix(ctx context.Context, key string, n int64) ([]byte, error) {
+	f, err := os.Open(l.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, n))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return data, nil
+}
+
+// Put writes the object and its metadata
+func (l *Local) Put(ctx context.Context, key, contentType string, data []byte) error {
+	if !fs.ValidPath(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	return l.write(key, contentType, int64(len(data)), bytes.NewReader(data))
+}
+
+// Delete deletes the object and its metadata
+func (l *Local) Delete(ctx context.Context, key string) error {
+	for _, path := range []string{l.path(key), l.path(key) + metaSuffix} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+	return nil
+}
+
+// Handler serves the signed URLs of the store; it must be mounted at the path of the base URL
+// with the prefix stripped
+func (l *Local) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if !fs.ValidPath(key) || key == "." || strings.HasSuffix(key, metaSuffix) {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			contentType := r.Header.Get("Content-Type")
+			if !l.verify(r, key, contentType, r.ContentLength) {
+				http.Error(w, "invalid or expired signature", http.StatusForbidden)
+				return
+			}
+			if err := l.write(key, contentType, r.ContentLength, r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			if !l.verify(r, key, "", 0) {
+				http.Error(w, "invalid or expired signature", http.StatusForbidden)
+				return
+			}
+			f, err := os.Open(l.path(key))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			defer f.Close()
+			info, err := f.Stat()
+			if err != nil {
+				http.Error(w, "failed to read object", http.StatusInternalServerError)
+				return
+			}
+			if meta, err := l.readMeta(key); err == nil && meta.ContentType != "" {
+				w.Header().Set("Content-Type", meta.ContentType)
+			}
+			http.ServeContent(w, r, "", info.ModTime(), f)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// write stores exactly size bytes of body as the object, replacing it atomically
+func (l *Local) write(key, contentType string, size int64, body io.Reader) error {
+	path := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create object: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	n, err := io.Copy(f, io.LimitReader(body, size+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if n != size {
+		return fmt.Errorf("body has %d bytes, expected %d", n, size)
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to encode object metadata: %w", err)
+	}
+	if err := os.WriteFile(path+metaSuffix, meta, 0o640); err != nil {
+		return fmt.Errorf("failed to write object metadata: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) readMeta(key string) (localMeta, error) {
+	var meta localMeta
+	data, err := os.ReadFile(l.path(key) + metaSuffix)
+	if err != nil {
+		return meta, fmt.Errorf("failed to read object metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("failed to decode object metadata: %w", err)
+	}
+	return meta, nil
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(key))
+}
+
+// signedURL returns the URL of the object with an expiry and a signature over the method, key,
+// expiry, and for uploads the content type and length
+func (l *Local) signedURL(method, key, contentType string, size int64, ttl time.Duration) string {
+	expires := l.clock.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", l.signature(method, key, contentType, size, expires))
+	return l.baseURL + "/" + key + "?" + query.Encode()
+}
+
+// verify reports whether r carries an unexpired signature of the object for its method
+func (l *Local) verify(r *http.Request, key, contentType string, size int64) bool {
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || l.clock.Now().Unix() > expires {
+		return false
+	}
+	signature, err := hex.DecodeString(r.URL.Query().Get("signature"))
+	if err != nil {
+		return false
+	}
+	expected, _ := hex.DecodeString(l.signature(r.Method, key, contentType, size, expires))
+	return hmac.Equal(signature, expected)
+}
+
+func (l *Local) signature(method, key, contentType string, size int64, expires int64) string {
+	return hex.EncodeToString(hmacSHA256(l.signingKey, strings.Join([]string{
+		method, key, strconv.FormatInt(expires, 10), contentType, strconv.FormatInt(size, 10),
+	}, "\n")))
+}
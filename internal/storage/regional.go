@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Regional keeps the objects of the users pinned to a data region in the store of the region, so
+// their photos and reports stay in the region as their records do. The region of a call is read
+// from its context by regionOf; calls of regions without a store of their own use the home store.
+type Regional struct {
+	home     Store
+	regions  map[string]Store
+	regionOf func(ctx context.Context) string
+}
+
+// NewRegional creates a store routing the calls of each region in regions to its store, and all
+// others to home
+func NewRegional(home Store, regions map[string]Store, regionOf func(ctx context.Context) string) *Regional {
+	return &Regional{home: home, regions: regions, regionOf: regionOf}
+}
+
+// store returns the store of the context's region
+func (r *Regional) store(ctx context.Context) Store {
+	if s, ok := r.regions[r.regionOf(ctx)]; ok {
+		return s
+	}
+	return r.home
+}
+
+// SignUpload returns a request uploading the object to the store of the context's region
+func (r *Regional) SignUpload(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (SignedRequest, error) {
+	return r.store(ctx).SignUpload(ctx, key, contentType, size, ttl)
+}
+
+// SignDownload returns a URL downloading the object from the store of the context's region
+func (r *Regional) SignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return r.store(ctx).SignDownload(ctx, key, ttl)
+}
+
+// Stat returns the object stored at key in the store of the context's region
+func (r *Regional) Stat(ctx context.Context, key string) (Object, error) {
+	return r.store(ctx).Stat(ctx, key)
+}
+
+// ReadPrefix returns the first n bytes of the object in the store of the context's region
+func (r *Regional) ReadPrefix(ctx context.Context, key string, n int64) ([]byte, error) {
+	return r.store(ctx).ReadPrefix(ctx, key, n)
+}
+
+// Put stores the object in the store of the context's region
+func (r *Regional) Put(ctx context.Context, key, contentType string, data []byte) error {
+	return r.store(ctx).Put(ctx, key, contentType, data)
+}
+
+// Delete deletes the object from the store of the context's region
+func (r *Regional) Delete(ctx context.Context, key string) error {
+	return r.store(ctx).Delete(ctx, key)
+}
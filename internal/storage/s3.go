@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statTTL is the validity of the URLs signed for the store's own requests
+const statTTL = time.Minute
+
+// S3 stores objects in an Amazon S3 bucket, or in a bucket of an S3-compatible service such as
+// Google Cloud Storage's XML API. Requests use path-style URLs signed with Signature Version 4.
+type S3 struct {
+	Bucket string
+	// Endpoint is the base URL of the service, e.g. "https://s3.eu-west-1.amazonaws.com"
+	Endpoint   string
+	HTTPClient *http.Client
+	signer     presigner
+}
+
+// NewS3 creates a store for an S3 bucket, authenticating with the access key of an IAM user or
+// role allowed to get, put and delete its objects. endpoint defaults to the S3 endpoint of
+// region; set it for S3-compatible services such as MinIO.
+func NewS3(bucket, region, accessKeyID, secretAccessKey, endpoint string) *S3 {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	return &S3{
+		Bucket:     bucket,
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		signer: presigner{
+			scheme:          "AWS4",
+			paramPrefix:     "X-Amz-",
+			terminator:      "aws4_request",
+			region:          region,
+			service:         "s3",
+			accessKeyID:     accessKeyID,
+			secretAccessKey: secretAccessKey,
+		},
+	}
+}
+
+// NewGCS creates a store for a Google Cloud Storage bucket through its XML API, authenticating
+// with an HMAC key of a service account allowed to manage its objects
+func NewGCS(bucket, accessID, secret string) *S3 {
+	return &S3{
+		Bucket:     bucket,
+		Endpoint:   "https://storage.googleapis.com",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		signer: presigner{
+			scheme:          "GOOG4",
+			paramPrefix:     "X-Goog-",
+			terminator:      "goog4_request",
+			region:          "auto",
+			service:         "storage",
+			accessKeyID:     accessID,
+			secretAccessKey: secret,
+		},
+	}
+}
+
+// SignUpload returns a PUT request uploading the object. The content type and length are
+// signed, so the store rejects uploads of other types or sizes.
+func (s *S3) SignUpload(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (SignedRequest, error) {
+	headers := map[string]string{
+		"Content-Type":   contentType,
+		"Content-Length": strconv.FormatInt(size, 10),
+	}
+	return SignedRequest{
+		Method:  http.MethodPut,
+		URL:     s.signer.presign(http.MethodPut, s.objectURL(key), headers, ttl, time.Now()),
+		Headers: headers,
+	}, nil
+}
+
+// SignDownload returns a GET URL of the object
+func (s *S3) SignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return s.signer.presign(http.MethodGet, s.objectURL(key), nil, ttl, time.Now()), nil
+}
+
+// Stat returns the size and content type of the object
+func (s *S3) Stat(ctx context.Context, key string) (Object, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	defer resp.Body.Close()
+	return Object{Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// ReadPrefix returns up to the first n bytes of the object
+func (s *S3) ReadPrefix(ctx context.Context, key string, n int64) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, map[string]string{"Range": fmt.Sprintf("bytes=0-%d", n-1)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, n))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return data, nil
+}
+
+// Put uploads the object with a signed PUT request
+func (s *S3) Put(ctx context.Context, key, contentType string, data []byte) error {
+	signed, err := s.SignUpload(ctx, key, contentType, int64(len(data)), statTTL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, signed.Method, signed.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create storage request: %w", err)
+	}
+	for name, value := range signed.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send storage request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("storage request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Delete deletes the object
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for the object, returning ErrNotFound for a missing object and an
+// error for any other unsuccessful status. headers are sent unsigned.
+func (s *S3) do(ctx context.Context, method, key string, headers map[string]string) (*http.Response, error) {
+	signedURL := s.signer.presign(method, s.objectURL(key), nil, statTTL, time.Now())
+	req, err := http.NewRequestWithContext(ctx, method, signedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send storage request: %w", err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return nil, fmt.Errorf("storage request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// objectURL returns the path-style URL of the object
+func (s *S3) objectURL(key string) *url.URL {
+	u, _ := url.Parse(s.Endpoint)
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket + "/" + key
+	return u
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// presigner presigns URLs with query string authentication of AWS Signature Version 4, or
+// its Google Cloud Storage variant, which differs only in its names
+type presigner struct {
+	// scheme is "AWS4" or "GOOG4"; it prefixes the algorithm and the signing key
+	scheme string
+	// paramPrefix prefixes the authentication query parameters, "X-Amz-" or "X-Goog-"
+	paramPrefix string
+	// terminator ends the credential scope, "aws4_request" or "goog4_request"
+	terminator      string
+	region          string
+	service         string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+// presign returns u signed for method at now, valid for ttl. headers are signed along with the
+// host and must be sent with the request.
+func (p presigner) presign(method string, u *url.URL, headers map[string]string, ttl time.Duration, now time.Time) string {
+	now = now.UTC()
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	algorithm := p.scheme + "-HMAC-SHA256"
+	scope := date + "/" + p.region + "/" + p.service + "/" + p.terminator
+
+	canonicalHeaders := map[string]string{"host": u.Host}
+	for name, value := range headers {
+		canonicalHeaders[strings.ToLower(name)] = strings.TrimSpace(value)
+	}
+	names := make([]string, 0, len(canonicalHeaders))
+	for name := range canonicalHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	signedHeaders := strings.Join(names, ";")
+
+	query := url.Values{}
+	query.Set(p.paramPrefix+"Algorithm", algorithm)
+	query.Set(p.paramPrefix+"Credential", p.accessKeyID+"/"+scope)
+	query.Set(p.paramPrefix+"Date", timestamp)
+	query.Set(p.paramPrefix+"Expires", fmt.Sprint(int64(ttl.Seconds())))
+	query.Set(p.paramPrefix+"SignedHeaders", signedHeaders)
+	canonicalQuery := canonicalQueryString(query)
+
+	var b strings.Builder
+	b.WriteString(method + "\n")
+	b.WriteString(uriEncode(u.Path, false) + "\n")
+	b.WriteString(canonicalQuery + "\n")
+	for _, name := range names {
+		b.WriteString(name + ":" + canonicalHeaders[name] + "\n")
+	}
+	b.WriteString("\n" + signedHeaders + "\n")
+	b.WriteString("UNSIGNED-PAYLOAD")
+
+	stringToSign := algorithm + "\n" + timestamp + "\n" + scope + "\n" + sha256Hex([]byte(b.String()))
+	key := hmacSHA256([]byte(p.scheme+p.secretAccessKey), date)
+	key = hmacSHA256(key, p.region)
+	key = hmacSHA256(key, p.service)
+	key = hmacSHA256(key, p.terminator)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	signed := *u
+	signed.RawQuery = canonicalQuery + "&" + p.paramPrefix + "Signature=" + signature
+	return signed.String()
+}
+
+// canonicalQueryString encodes query sorted by name, with the encoding of Signature Version 4
+func canonicalQueryString(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte of s except the unreserved characters, and "/" unless
+// encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
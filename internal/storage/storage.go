@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Drivers of the attachment store
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+	DriverGCS   = "gcs"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored object
+type Object struct {
+	Size        int64
+	ContentType string
+}
+
+// SignedRequest is a presigned request that lets a client upload an object directly to the
+// store, without proxying the data through the API
+type SignedRequest struct {
+	Method string
+	URL    string
+	// Headers must be sent with the request as is; they are part of the signature
+	Headers map[string]string
+}
+
+// Store stores attachments under keys. URLs are signed, so clients can use them without
+// credentials until they expire.
+type Store interface {
+	// SignUpload returns a request uploading an object of exactly size bytes and contentType
+	SignUpload(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (SignedRequest, error)
+	// SignDownload returns a URL downloading an object
+	SignDownload(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// Stat returns the object stored at key, or ErrNotFound
+	Stat(ctx context.Context, key string) (Object, error)
+	// ReadPrefix returns up to the first n bytes of the object stored at key, or ErrNotFound
+	ReadPrefix(ctx context.Context, key string, n int64) ([]byte, error)
+	// Put stores data as the object at key, for objects written by the server itself
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Delete deletes the object stored at key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
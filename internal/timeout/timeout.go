@@ -0,0 +1,36 @@
+// Package timeout bounds the time RPCs may take, so that slow handlers and database queries are
+// cancelled instead of holding pool connections past the point where the client gave up.
+package timeout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// Interceptor creates a Connect interceptor that cancels the context of each RPC after the timeout
+// timeouts maps its procedure to, or defaultTimeout for other procedures. Deadlines set by
+// clients are kept when they are earlier. RPCs failing after their deadline expired fail with
+// deadline_exceeded, whatever error the handler returned. It should run before the auth
+// interceptor so that authentication queries are bounded too. Streaming RPCs are not bounded:
+// they last as long as they have messages to send, until the client cancels them.
+func Interceptor(defaultTimeout time.Duration, timeouts map[string]time.Duration) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			timeout, ok := timeouts[req.Spec().Procedure]
+			if !ok {
+				timeout = defaultTimeout
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			resp, err := next(ctx, req)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, connect.NewError(connect.CodeDeadlineExceeded, errors.New("request timed out"))
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package trash purges the records deleted before the trash window, with the files of their
+// photos, and the photos whose uploads were never completed
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+	"github.com/atreya2011/health-management-api/internal/config"
+	"github.com/atreya2011/health-management-api/internal/repo"
+	"github.com/atreya2011/health-management-api/internal/storage"
+)
+
+// batchSize is how many trash items are purged at once
+const batchSize = 500
+
+// Report is the outcome of purging the trash once; in dry runs, the items it would purge
+type Report struct {
+	DryRun      bool
+	ItemsPurged int64
+	// PendingAttachmentsPurged counts the photos left pending past repo.PendingAttachmentWindow
+	PendingAttachmentsPurged int64
+	FilesDeleted             int64
+}
+
+// Job purges the trash items deleted before the trash window, and the photo files they kept, and
+// the pending photos that can no longer be completed, with their uploaded files if any
+type Job struct {
+	repo  *repo.TrashRepository
+	store storage.Store
+	cfg   config.TrashConfig
+	log   *slog.Logger
+	clock clock.Clock
+}
+
+// NewJob creates a job purging the trash past the window of cfg, deleting photo files from store
+func NewJob(repo *repo.TrashRepository, store storage.Store, cfg config.TrashConfig, log *slog.Logger, clock clock.Clock) *Job {
+	return &Job{
+		repo:  repo,
+		store: store,
+		cfg:   cfg,
+		log:   log,
+		clock: clock,
+	}
+}
+
+// Run purges the trash immediately and then once per interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Purge(ctx, j.cfg.DryRun); err != nil {
+			j.log.ErrorContext(ctx, "Failed to purge trash", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes the trash items past the window, or with dryRun only counts them, and logs the
+// report
+func (j *Job) Purge(ctx context.Context, dryRun bool) (Report, error) {
+	cutoff := j.clock.Now().Add(-j.cfg.Window())
+	report := Report{DryRun: dryRun}
+	if dryRun {
+		count, err := j.repo.CountExpired(ctx, cutoff)
+		if err != nil {
+			return Report{}, err
+		}
+		report.ItemsPurged = count
+		if report.PendingAttachmentsPurged, err = j.repo.CountStalePendingAttachments(ctx, j.pendingCutoff()); err != nil {
+			return Report{}, err
+		}
+		j.logReport(ctx, report)
+		return report, nil
+	}
+
+	// Partial progress is reported, so a failed run shows what it already did
+	err := j.purge(ctx, cutoff, &report)
+	j.logReport(ctx, report)
+	return report, err
+}
+
+func (j *Job) purge(ctx context.Context, cutoff time.Time, report *Report) error {
+	for {
+		items, err := j.repo.PurgeExpired(ctx, cutoff, batchSize)
+		if err != nil {
+			return err
+		}
+		report.ItemsPurged += int64(len(items))
+
+		// The rows are gone, so files left behind are only storage; don't fail the run for them
+		for _, item := range items {
+			keys, err := repo.TrashStorageKeys(item)
+			if err != nil {
+				j.log.WarnContext(ctx, "Failed to read photo files of purged trash item", "trashItemID", item.ID, "error", err)
+				continue
+			}
+			for _, key := range keys {
+				if err := j.store.Delete(ctx, key); err != nil {
+					j.log.WarnContext(ctx, "Failed to delete photo file of purged trash item", "trashItemID", item.ID, "key", key, "error", err)
+					continue
+				}
+				report.FilesDeleted++
+			}
+		}
+		if len(items) < batchSize {
+			break
+		}
+	}
+
+	for {
+		attachments, err := j.repo.PurgeStalePendingAttachments(ctx, j.pendingCutoff(), batchSize)
+		if err != nil {
+			return err
+		}
+		report.PendingAttachmentsPurged += int64(len(attachments))
+		for _, attachment := range attachments {
+			if err := j.store.Delete(ctx, attachment.StorageKey); err != nil {
+				j.log.WarnContext(ctx, "Failed to delete photo file of stale pending attachment", "attachmentID", attachment.ID, "key", attachment.StorageKey, "error", err)
+				continue
+			}
+			report.FilesDeleted++
+		}
+		if len(attachments) < batchSize {
+			return nil
+		}
+	}
+}
+
+// pendingCutoff returns the time before which pending photos can no longer be completed
+func (j *Job) pendingCutoff() time.Time {
+	return j.clock.Now().Add(-repo.PendingAttachmentWindow)
+}
+
+func (j *Job) logReport(ctx context.Context, report Report) {
+	j.log.InfoContext(ctx, "Trash purged",
+		"dryRun", report.DryRun,
+		"itemsPurged", report.ItemsPurged,
+		"pendingAttachmentsPurged", report.PendingAttachmentsPurged,
+		"filesDeleted", report.FilesDeleted)
+}
@@ -0,0 +1,81 @@
+// Package units converts weights and heights between the units users can prefer. Values are
+// stored in kilograms and centimeters; other units exist only at the edges of the API.
+package units
+
+import "math"
+
+// WeightUnit is the unit weights are shown and entered in
+type WeightUnit string
+
+// HeightUnit is the unit heights are shown and entered in
+type HeightUnit string
+
+// Weight and height units, as stored in the users' preferences
+const (
+	Kilograms   WeightUnit = "kg"
+	Pounds      WeightUnit = "lb"
+	Centimeters HeightUnit = "cm"
+	Inches      HeightUnit = "in"
+)
+
+const (
+	// kgPerLb is the international avoirdupois pound
+	kgPerLb = 0.45359237
+	// cmPerIn is the international inch
+	cmPerIn = 2.54
+)
+
+// Preferences are the units a user prefers
+type Preferences struct {
+	Weight WeightUnit
+	Height HeightUnit
+}
+
+// DefaultPreferences are the units of users who never chose any
+var DefaultPreferences = Preferences{Weight: Kilograms, Height: Centimeters}
+
+// Valid reports whether u is a known weight unit
+func (u WeightUnit) Valid() bool {
+	return u == Kilograms || u == Pounds
+}
+
+// FromKg converts a weight in kilograms to u, rounded to two decimals like stored weights
+func (u WeightUnit) FromKg(kg float64) float64 {
+	if u == Pounds {
+		return round(kg / kgPerLb)
+	}
+	return kg
+}
+
+// ToKg converts a weight in u to kilograms
+func (u WeightUnit) ToKg(value float64) float64 {
+	if u == Pounds {
+		return value * kgPerLb
+	}
+	return value
+}
+
+// Valid reports whether u is a known height unit
+func (u HeightUnit) Valid() bool {
+	return u == Centimeters || u == Inches
+}
+
+// FromCm converts a height in centimeters to u, rounded to two decimals
+func (u HeightUnit) FromCm(cm float64) float64 {
+	if u == Inches {
+		return round(cm / cmPerIn)
+	}
+	return cm
+}
+
+// ToCm converts a height in u to centimeters
+func (u HeightUnit) ToCm(value float64) float64 {
+	if u == Inches {
+		return value * cmPerIn
+	}
+	return value
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,50 @@
+// Package version reports the build of the running binary. The values are injected when
+// building, e.g. by make build:
+//
+//	go build -ldflags "-X github.com/atreya2011/health-management-api/internal/version.Version=v1.2.0"
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X at build time
+var (
+	// Version is the release of the build, e.g. "v1.2.0"
+	Version = "dev"
+	// GitSHA is the commit the binary was built from
+	GitSHA = ""
+	// BuildDate is the time of the build in RFC 3339
+	BuildDate = ""
+)
+
+// Info describes the build of the running binary
+type Info struct {
+	Version   string
+	GitSHA    string
+	BuildDate string
+	GoVersion string
+}
+
+// Get returns the build of the running binary. Without injected values, the commit and its time
+// are taken from the VCS information Go embeds in binaries built within the repository.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitSHA:    GitSHA,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitSHA == "":
+				info.GitSHA = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
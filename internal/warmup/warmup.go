@@ -0,0 +1,38 @@
+// Package warmup runs the startup steps priming the server before it reports ready, so the first
+// requests after a deploy don't pay for opening connections and preparing statements.
+package warmup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/atreya2011/health-management-api/internal/clock"
+)
+
+// Step is a step of the warmup
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Run runs steps in order, logging their durations, until they complete or timeout passes.
+// Failed steps are logged and don't stop the warmup, as the server can serve without them, only
+// slower at first. It reports whether every step succeeded.
+func Run(ctx context.Context, steps []Step, timeout time.Duration, log *slog.Logger, clock clock.Clock) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := clock.Now()
+	ok := true
+	for _, step := range steps {
+		stepStart := clock.Now()
+		if err := step.Run(ctx); err != nil {
+			log.WarnContext(ctx, "Warmup step failed", "step", step.Name, "duration", clock.Now().Sub(stepStart), "error", err)
+			ok = false
+			continue
+		}
+		log.DebugContext(ctx, "Warmup step completed", "step", step.Name, "duration", clock.Now().Sub(stepStart))
+	}
+	log.InfoContext(ctx, "Warmup completed", "duration", clock.Now().Sub(start), "ok", ok)
+	return ok
+}
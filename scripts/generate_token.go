@@ -12,8 +12,11 @@ func main() {
 	claims := jwt.MapClaims{
 		"sub":  "test-subject-id", // Subject ID matching the test user
 		"name": "Test User",
-		"iat":  time.Now().Unix(),
-		"exp":  time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
+		// Scopes of the RPCs of the user's own records and shares; tokens without any are only
+		// granted jwt.legacy_scopes
+		"scope": "records:read records:write shares:write",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
 	}
 
 	// Create the token